@@ -180,3 +180,21 @@ func DisallowDeprecated(ctx context.Context) context.Context {
 func IsDeprecatedAllowed(ctx context.Context) bool {
 	return ctx.Value(disallowDeprecated{}) == nil
 }
+
+// This is attached to contexts as they are passed down through a resource
+// being validated to indicate the release of Knative serving it.
+type releaseVersionKey struct{}
+
+// WithReleaseVersion notes on the context the version of the Knative release
+// serving the resources, which the fields removed in, or before, it are
+// rejected by.
+func WithReleaseVersion(ctx context.Context, v Version) context.Context {
+	return context.WithValue(ctx, releaseVersionKey{}, v)
+}
+
+// GetReleaseVersion returns the version of the Knative release serving the
+// resources, if it's on the context.
+func GetReleaseVersion(ctx context.Context) (Version, bool) {
+	v, ok := ctx.Value(releaseVersionKey{}).(Version)
+	return v, ok
+}
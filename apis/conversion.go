@@ -0,0 +1,176 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apis
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DroppedFieldsAnnotation is the annotation in which down-conversions record
+// the fields the lower version can't represent, so up-conversions can restore
+// them and objects written by older clients don't lose their newer fields.
+const DroppedFieldsAnnotation = "conversion.knative.dev/dropped-fields"
+
+// topLevelFields are the fields shared by all versions, never recorded as dropped.
+var topLevelFields = []string{"apiVersion", "kind", "metadata"}
+
+// PreserveDroppedFields records in an annotation of `to` the fields of `from`
+// which were dropped down-converting it into `to`. It's meant to be called at the
+// end of ConvertDown, and fields are compared on their JSON representation, so
+// the fields renamed between versions aren't recorded. Lists are compared as a
+// whole, so they are only recorded if they were dropped entirely.
+func PreserveDroppedFields(from interface{}, to metav1.Object) error {
+	fromFields, err := toFieldMap(from)
+	if err != nil {
+		return err
+	}
+	toFields, err := toFieldMap(to)
+	if err != nil {
+		return err
+	}
+	for _, f := range topLevelFields {
+		delete(fromFields, f)
+	}
+
+	annotations := to.GetAnnotations()
+	dropped := droppedFields(fromFields, toFields)
+	if len(dropped) == 0 {
+		if _, ok := annotations[DroppedFieldsAnnotation]; ok {
+			annotations = copyAnnotations(annotations)
+			delete(annotations, DroppedFieldsAnnotation)
+			to.SetAnnotations(annotations)
+		}
+		return nil
+	}
+
+	b, err := json.Marshal(dropped)
+	if err != nil {
+		return fmt.Errorf("failed to encode the dropped fields: %v", err)
+	}
+	annotations = copyAnnotations(annotations)
+	annotations[DroppedFieldsAnnotation] = string(b)
+	to.SetAnnotations(annotations)
+	return nil
+}
+
+// RestoreDroppedFields restores into `to` the fields recorded by
+// PreserveDroppedFields in the annotation of `from`, and removes the annotation
+// from `to`. It's meant to be called at the end of ConvertUp. Only the fields
+// missing from `to` are restored, so the ones set by the up-conversion win.
+func RestoreDroppedFields(from metav1.Object, to interface{}) error {
+	if acc, ok := to.(metav1.Object); ok {
+		if annotations := acc.GetAnnotations(); annotations != nil {
+			if _, ok := annotations[DroppedFieldsAnnotation]; ok {
+				annotations = copyAnnotations(annotations)
+				delete(annotations, DroppedFieldsAnnotation)
+				acc.SetAnnotations(annotations)
+			}
+		}
+	}
+
+	raw, ok := from.GetAnnotations()[DroppedFieldsAnnotation]
+	if !ok {
+		return nil
+	}
+	dropped := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(raw), &dropped); err != nil {
+		return fmt.Errorf("failed to decode the %s annotation: %v", DroppedFieldsAnnotation, err)
+	}
+	toFields, err := toFieldMap(to)
+	if err != nil {
+		return err
+	}
+	mergeMissingFields(toFields, dropped)
+
+	b, err := json.Marshal(toFields)
+	if err != nil {
+		return err
+	}
+	// Decode into a zero value, so the lists and maps of `to` are replaced
+	// rather than merged with their restored values.
+	v := reflect.ValueOf(to)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("expected a non-nil pointer, got %T", to)
+	}
+	restored := reflect.New(v.Elem().Type())
+	if err := json.Unmarshal(b, restored.Interface()); err != nil {
+		return fmt.Errorf("failed to restore the dropped fields: %v", err)
+	}
+	v.Elem().Set(restored.Elem())
+	return nil
+}
+
+// toFieldMap returns the JSON representation of the object as a map.
+func toFieldMap(obj interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// droppedFields returns the fields of `from` missing from `to`.
+func droppedFields(from, to map[string]interface{}) map[string]interface{} {
+	dropped := map[string]interface{}{}
+	for k, fv := range from {
+		tv, ok := to[k]
+		if !ok {
+			dropped[k] = fv
+			continue
+		}
+		fm, fok := fv.(map[string]interface{})
+		tm, tok := tv.(map[string]interface{})
+		if fok && tok {
+			if sub := droppedFields(fm, tm); len(sub) > 0 {
+				dropped[k] = sub
+			}
+		}
+	}
+	return dropped
+}
+
+// mergeMissingFields sets the fields of `from` missing from `to` in `to`.
+func mergeMissingFields(to, from map[string]interface{}) {
+	for k, fv := range from {
+		tv, ok := to[k]
+		if !ok || tv == nil {
+			to[k] = fv
+			continue
+		}
+		fm, fok := fv.(map[string]interface{})
+		tm, tok := tv.(map[string]interface{})
+		if fok && tok {
+			mergeMissingFields(tm, fm)
+		}
+	}
+}
+
+func copyAnnotations(annotations map[string]string) map[string]string {
+	c := make(map[string]string, len(annotations)+1)
+	for k, v := range annotations {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apis
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type resourceV1 struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              resourceV1Spec `json:"spec,omitempty"`
+}
+
+type resourceV1Spec struct {
+	Image    string `json:"image,omitempty"`
+	Replicas int    `json:"replicas,omitempty"`
+}
+
+type resourceV2 struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              resourceV2Spec `json:"spec,omitempty"`
+}
+
+type resourceV2Spec struct {
+	Image    string            `json:"image,omitempty"`
+	Replicas int               `json:"replicas,omitempty"`
+	Timeout  string            `json:"timeout,omitempty"`
+	Ports    []int             `json:"ports,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+}
+
+func convertDown(t *testing.T, from *resourceV2) *resourceV1 {
+	t.Helper()
+	to := &resourceV1{
+		ObjectMeta: *from.ObjectMeta.DeepCopy(),
+		Spec: resourceV1Spec{
+			Image:    from.Spec.Image,
+			Replicas: from.Spec.Replicas,
+		},
+	}
+	if err := PreserveDroppedFields(from, to); err != nil {
+		t.Fatalf("PreserveDroppedFields() = %v", err)
+	}
+	return to
+}
+
+func convertUp(t *testing.T, from *resourceV1) *resourceV2 {
+	t.Helper()
+	to := &resourceV2{
+		ObjectMeta: *from.ObjectMeta.DeepCopy(),
+		Spec: resourceV2Spec{
+			Image:    from.Spec.Image,
+			Replicas: from.Spec.Replicas,
+		},
+	}
+	if err := RestoreDroppedFields(from, to); err != nil {
+		t.Fatalf("RestoreDroppedFields() = %v", err)
+	}
+	return to
+}
+
+func TestDroppedFieldsRoundTrip(t *testing.T) {
+	v2 := &resourceV2{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "foo",
+			Annotations: map[string]string{"user": "annotation"},
+		},
+		Spec: resourceV2Spec{
+			Image:    "busybox",
+			Replicas: 1,
+			Timeout:  "10s",
+			Ports:    []int{80, 443},
+			Env:      map[string]string{"FOO": "bar"},
+		},
+	}
+
+	v1 := convertDown(t, v2)
+	if _, ok := v1.Annotations[DroppedFieldsAnnotation]; !ok {
+		t.Fatalf("Annotations = %v, wanted the dropped fields recorded", v1.Annotations)
+	}
+	if _, ok := v2.Annotations[DroppedFieldsAnnotation]; ok {
+		t.Error("PreserveDroppedFields() modified the annotations of the source")
+	}
+
+	// An older client updates the fields it knows about.
+	v1.Spec.Image = "helloworld"
+	v1.Spec.Replicas = 3
+
+	got := convertUp(t, v1)
+	want := &resourceV2{
+		ObjectMeta: *v2.ObjectMeta.DeepCopy(),
+		Spec:       v2.Spec,
+	}
+	want.Spec.Image = "helloworld"
+	want.Spec.Replicas = 3
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Round trip (-want, +got): %s", diff)
+	}
+}
+
+func TestPreserveDroppedFieldsNothingDropped(t *testing.T) {
+	v2 := &resourceV2{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "foo",
+			// A stale annotation from a previous conversion.
+			Annotations: map[string]string{DroppedFieldsAnnotation: `{"spec":{"timeout":"1s"}}`},
+		},
+		Spec: resourceV2Spec{Image: "busybox"},
+	}
+	v1 := convertDown(t, v2)
+	if _, ok := v1.Annotations[DroppedFieldsAnnotation]; ok {
+		t.Errorf("Annotations = %v, wanted no dropped fields", v1.Annotations)
+	}
+}
+
+func TestRestoreDroppedFieldsKeepsConvertedValues(t *testing.T) {
+	v1 := &resourceV1{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "foo",
+			Annotations: map[string]string{DroppedFieldsAnnotation: `{"spec":{"image":"stale","timeout":"10s"}}`},
+		},
+		Spec: resourceV1Spec{Image: "busybox"},
+	}
+	got := convertUp(t, v1)
+	want := &resourceV2{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+		Spec:       resourceV2Spec{Image: "busybox", Timeout: "10s"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("RestoreDroppedFields (-want, +got): %s", diff)
+	}
+}
+
+func TestRestoreDroppedFieldsErrors(t *testing.T) {
+	v1 := &resourceV1{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{DroppedFieldsAnnotation: `not json`},
+		},
+	}
+	if err := RestoreDroppedFields(v1, &resourceV2{}); err == nil {
+		t.Error("RestoreDroppedFields() = nil, wanted an error for a malformed annotation")
+	}
+
+	v1.Annotations[DroppedFieldsAnnotation] = `{"spec":{"timeout":"10s"}}`
+	if err := RestoreDroppedFields(v1, resourceV2{}); err == nil {
+		t.Error("RestoreDroppedFields() = nil, wanted an error for a non-pointer")
+	}
+}
@@ -0,0 +1,354 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DeprecationTag is the struct tag declaring that a field is deprecated, since
+// which release, in which release it's removed, and what replaces it, e.g.
+//
+//	Foo string `json:"foo,omitempty" deprecation:"since=v0.10,removedIn=v0.12,use=bar"`
+//
+// Only since is required.
+const DeprecationTag = "deprecation"
+
+// Version is the semantic version of a Knative release, e.g. v0.10.1.
+// +k8s:deepcopy-gen=false
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// ParseVersion parses a version like v0.10.1, where the v and the patch are
+// optional.
+func ParseVersion(s string) (Version, error) {
+	parts := strings.Split(strings.TrimPrefix(s, "v"), ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Version{}, fmt.Errorf("invalid version %q, want v<major>.<minor>[.<patch>]", s)
+	}
+	var nums [3]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid version %q, want v<major>.<minor>[.<patch>]", s)
+		}
+		nums[i] = n
+	}
+	return Version{Major: nums[0], Minor: nums[1], Patch: nums[2]}, nil
+}
+
+// String returns the version as v<major>.<minor>.<patch>.
+func (v Version) String() string {
+	return fmt.Sprintf("v%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// MarshalJSON implements json.Marshaler, marshaling the version as a string.
+func (v Version) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (v *Version) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseVersion(s)
+	if err != nil {
+		return err
+	}
+	*v = parsed
+	return nil
+}
+
+// Less returns true if v is an earlier version than o.
+func (v Version) Less(o Version) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor < o.Minor
+	}
+	return v.Patch < o.Patch
+}
+
+// Deprecation is the deprecation of a field declared with DeprecationTag.
+// +k8s:deepcopy-gen=false
+type Deprecation struct {
+	// Field is the JSON path of the field, e.g. spec.template.foo. The
+	// elements of lists and maps are denoted by [*].
+	Field string `json:"field"`
+	// Since is the release the field is deprecated since.
+	Since Version `json:"since"`
+	// RemovedIn is the release the field is removed in, if it's planned.
+	RemovedIn *Version `json:"removedIn,omitempty"`
+	// Replacement is the field to use instead, if any.
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// IsRemoved returns true if the field is removed in the given release.
+func (d Deprecation) IsRemoved(release Version) bool {
+	return d.RemovedIn != nil && !release.Less(*d.RemovedIn)
+}
+
+// Message describes the deprecation of the field at the given path.
+func (d Deprecation) Message(path string) string {
+	msg := fmt.Sprintf("%s is deprecated since %s", path, d.Since)
+	if d.RemovedIn != nil {
+		msg += fmt.Sprintf(" and removed in %s", d.RemovedIn)
+	}
+	if d.Replacement != "" {
+		msg += fmt.Sprintf(", use %s instead", d.Replacement)
+	}
+	return msg
+}
+
+// parseDeprecationTag parses the value of DeprecationTag.
+func parseDeprecationTag(tag string) (Deprecation, error) {
+	var d Deprecation
+	hasSince := false
+	for _, kv := range strings.Split(tag, ",") {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return d, fmt.Errorf("invalid deprecation %q, want key=value", kv)
+		}
+		switch k, v := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]); k {
+		case "since":
+			since, err := ParseVersion(v)
+			if err != nil {
+				return d, err
+			}
+			d.Since, hasSince = since, true
+		case "removedIn":
+			removedIn, err := ParseVersion(v)
+			if err != nil {
+				return d, err
+			}
+			d.RemovedIn = &removedIn
+		case "use":
+			d.Replacement = v
+		default:
+			return d, fmt.Errorf("unknown deprecation key %q", k)
+		}
+	}
+	if !hasSince {
+		return d, fmt.Errorf("deprecation %q must declare since which release", tag)
+	}
+	if d.RemovedIn != nil && d.RemovedIn.Less(d.Since) {
+		return d, fmt.Errorf("deprecation %q is removed before it's deprecated", tag)
+	}
+	return d, nil
+}
+
+// jsonField returns the JSON name of the field, or an empty string if it's
+// inlined, and whether it's serialized at all.
+func jsonField(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" && !f.Anonymous {
+		// Unexported.
+		return "", false
+	}
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	switch {
+	case name == "-":
+		return "", false
+	case f.Tag.Get("json") == ",inline", name == "" && f.Anonymous:
+		return "", true
+	case name == "":
+		return f.Name, true
+	}
+	return name, true
+}
+
+// joinPath appends the field to the JSON path.
+func joinPath(path, field string) string {
+	switch {
+	case field == "":
+		return path
+	case path == "":
+		return field
+	}
+	return path + "." + field
+}
+
+// Deprecations returns the deprecations declared on the fields of the type of
+// obj and of its nested types, sorted by field. It returns an error if any of
+// them is malformed, so API authors can check them in their unit tests.
+func Deprecations(obj interface{}) ([]Deprecation, error) {
+	var deprecations []Deprecation
+	if err := typeDeprecations(reflect.TypeOf(obj), "", map[reflect.Type]bool{}, &deprecations); err != nil {
+		return nil, err
+	}
+	sort.Slice(deprecations, func(i, j int) bool {
+		return deprecations[i].Field < deprecations[j].Field
+	})
+	return deprecations, nil
+}
+
+func typeDeprecations(t reflect.Type, path string, visiting map[reflect.Type]bool, out *[]Deprecation) error {
+	if t == nil {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeDeprecations(t.Elem(), path, visiting, out)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return typeDeprecations(t.Elem(), path+"[*]", visiting, out)
+	case reflect.Struct:
+	default:
+		return nil
+	}
+	// Stop at recursive types.
+	if visiting[t] {
+		return nil
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := jsonField(f)
+		if !ok {
+			continue
+		}
+		fieldPath := joinPath(path, name)
+		if tag, ok := f.Tag.Lookup(DeprecationTag); ok {
+			d, err := parseDeprecationTag(tag)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %v", t, f.Name, err)
+			}
+			d.Field = fieldPath
+			*out = append(*out, d)
+		}
+		if err := typeDeprecations(f.Type, fieldPath, visiting, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeprecatedField is a deprecated field set in an object.
+// +k8s:deepcopy-gen=false
+type DeprecatedField struct {
+	Deprecation
+	// Path is the JSON path of the field in the object, e.g. spec.items[1].foo.
+	Path string
+	// Value is the value of the field.
+	Value reflect.Value
+}
+
+// FindDeprecatedFields returns the deprecated fields which are set in obj,
+// in the order of their declaration. Malformed deprecations are ignored.
+func FindDeprecatedFields(obj interface{}) []DeprecatedField {
+	var fields []DeprecatedField
+	valueDeprecations(reflect.ValueOf(obj), "", &fields)
+	return fields
+}
+
+func valueDeprecations(v reflect.Value, path string, out *[]DeprecatedField) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			valueDeprecations(v.Elem(), path, out)
+		}
+		return
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			valueDeprecations(v.Index(i), fmt.Sprintf("%s[%d]", path, i), out)
+		}
+		return
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, k := range keys {
+			valueDeprecations(v.MapIndex(k), fmt.Sprintf("%s[%v]", path, k.Interface()), out)
+		}
+		return
+	case reflect.Struct:
+	default:
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := jsonField(f)
+		if !ok {
+			continue
+		}
+		fieldPath := joinPath(path, name)
+		fv := v.Field(i)
+		if tag, ok := f.Tag.Lookup(DeprecationTag); ok && fv.CanInterface() && nonZero(fv) {
+			if d, err := parseDeprecationTag(tag); err == nil {
+				d.Field = fieldPath
+				*out = append(*out, DeprecatedField{Deprecation: d, Path: fieldPath, Value: fv})
+			}
+		}
+		valueDeprecations(fv, fieldPath, out)
+	}
+}
+
+// CheckFieldDeprecations returns a warning for each deprecated field set in
+// obj, and errors for the ones which are removed in the release on the
+// context, see WithReleaseVersion. On update, the removed fields which are
+// unchanged from the baseline are only warned about, so that the resources
+// created before their removal can still be updated.
+func CheckFieldDeprecations(ctx context.Context, obj interface{}) ([]string, *FieldError) {
+	fields := FindDeprecatedFields(obj)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	release, hasRelease := GetReleaseVersion(ctx)
+	original := map[string]reflect.Value{}
+	if IsInUpdate(ctx) {
+		for _, f := range FindDeprecatedFields(GetBaseline(ctx)) {
+			original[f.Path] = f.Value
+		}
+	}
+
+	var (
+		warnings []string
+		errs     *FieldError
+	)
+	for _, f := range fields {
+		if hasRelease && f.IsRemoved(release) {
+			if o, ok := original[f.Path]; !ok || differ(o, f.Value) {
+				fe := &FieldError{
+					Message: fmt.Sprintf("field removed in %s", f.RemovedIn),
+					Paths:   []string{f.Path},
+				}
+				if f.Replacement != "" {
+					fe.Details = fmt.Sprintf("use %s instead", f.Replacement)
+				}
+				errs = errs.Also(fe)
+				continue
+			}
+		}
+		warnings = append(warnings, f.Message(f.Path))
+	}
+	return warnings, errs
+}
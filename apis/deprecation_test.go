@@ -0,0 +1,232 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apis
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type deprecationItem struct {
+	Old  string           `json:"old,omitempty" deprecation:"since=v0.9,removedIn=v0.11,use=new"`
+	New  string           `json:"new,omitempty"`
+	Next *deprecationItem `json:"next,omitempty"`
+}
+
+type deprecationInlined struct {
+	Legacy bool `json:"legacy,omitempty" deprecation:"since=v0.10"`
+}
+
+type deprecationSpec struct {
+	deprecationInlined `json:",inline"`
+
+	Items  []deprecationItem          `json:"items,omitempty"`
+	ByName map[string]deprecationItem `json:"byName,omitempty"`
+	Count  *int                       `json:"count,omitempty" deprecation:"since=v0.10.1,removedIn=v0.12"`
+	Hidden string                     `json:"-" deprecation:"since=v0.1"`
+}
+
+type deprecationResource struct {
+	Spec deprecationSpec `json:"spec"`
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := map[string]struct {
+		want    Version
+		wantErr bool
+	}{
+		"v0.10.1":  {want: Version{Minor: 10, Patch: 1}},
+		"1.2":      {want: Version{Major: 1, Minor: 2}},
+		"v1":       {wantErr: true},
+		"v1.2.3.4": {wantErr: true},
+		"v1.x":     {wantErr: true},
+		"v1.-2":    {wantErr: true},
+	}
+	for s, test := range tests {
+		t.Run(s, func(t *testing.T) {
+			got, err := ParseVersion(s)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParseVersion() = %v, wantErr %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("ParseVersion() = %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	if !(Version{Minor: 9, Patch: 5}).Less(Version{Minor: 10}) {
+		t.Error("v0.9.5 is not less than v0.10.0")
+	}
+	if (Version{Major: 1}).Less(Version{Minor: 10}) {
+		t.Error("v1.0.0 is less than v0.10.0")
+	}
+}
+
+func TestVersionJSON(t *testing.T) {
+	v := Version{Major: 1, Minor: 2, Patch: 3}
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal() = %v", err)
+	}
+	if got, want := string(data), `"v1.2.3"`; got != want {
+		t.Errorf("Marshal() = %s, want %s", got, want)
+	}
+	var got Version
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal() = %v", err)
+	}
+	if got != v {
+		t.Errorf("Unmarshal() = %v, want %v", got, v)
+	}
+	if err := json.Unmarshal([]byte(`"latest"`), &got); err == nil {
+		t.Error("Unmarshal() = nil, wanted an error for an invalid version")
+	}
+}
+
+func TestDeprecations(t *testing.T) {
+	got, err := Deprecations(&deprecationResource{})
+	if err != nil {
+		t.Fatalf("Deprecations() = %v", err)
+	}
+	removedIn11, removedIn12 := Version{Minor: 11}, Version{Minor: 12}
+	want := []Deprecation{{
+		Field:       "spec.byName[*].old",
+		Since:       Version{Minor: 9},
+		RemovedIn:   &removedIn11,
+		Replacement: "new",
+	}, {
+		Field:     "spec.count",
+		Since:     Version{Minor: 10, Patch: 1},
+		RemovedIn: &removedIn12,
+	}, {
+		Field:       "spec.items[*].old",
+		Since:       Version{Minor: 9},
+		RemovedIn:   &removedIn11,
+		Replacement: "new",
+	}, {
+		Field: "spec.legacy",
+		Since: Version{Minor: 10},
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Deprecations (-want, +got) = %s", diff)
+	}
+}
+
+func TestInvalidDeprecations(t *testing.T) {
+	tests := map[string]interface{}{
+		"no since": struct {
+			F string `deprecation:"removedIn=v0.1"`
+		}{},
+		"bad version": struct {
+			F string `deprecation:"since=latest"`
+		}{},
+		"unknown key": struct {
+			F string `deprecation:"since=v0.1,by=me"`
+		}{},
+		"not key=value": struct {
+			F string `deprecation:"since"`
+		}{},
+		"removed before deprecated": struct {
+			F string `deprecation:"since=v0.2,removedIn=v0.1"`
+		}{},
+	}
+	for name, obj := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Deprecations(obj); err == nil {
+				t.Error("Deprecations() = nil, wanted an error")
+			}
+		})
+	}
+}
+
+func TestCheckFieldDeprecations(t *testing.T) {
+	count := 3
+	obj := &deprecationResource{Spec: deprecationSpec{
+		deprecationInlined: deprecationInlined{Legacy: true},
+		Items: []deprecationItem{
+			{New: "a"},
+			{Old: "b", Next: &deprecationItem{Old: "c"}},
+		},
+		Count:  &count,
+		Hidden: "not serialized",
+	}}
+
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		wantWarnings []string
+		wantErr      string
+	}{{
+		name: "no release",
+		ctx:  context.Background(),
+		wantWarnings: []string{
+			"spec.legacy is deprecated since v0.10.0",
+			"spec.items[1].old is deprecated since v0.9.0 and removed in v0.11.0, use new instead",
+			"spec.items[1].next.old is deprecated since v0.9.0 and removed in v0.11.0, use new instead",
+			"spec.count is deprecated since v0.10.1 and removed in v0.12.0",
+		},
+	}, {
+		name: "before the removal",
+		ctx:  WithReleaseVersion(context.Background(), Version{Minor: 10, Patch: 2}),
+		wantWarnings: []string{
+			"spec.legacy is deprecated since v0.10.0",
+			"spec.items[1].old is deprecated since v0.9.0 and removed in v0.11.0, use new instead",
+			"spec.items[1].next.old is deprecated since v0.9.0 and removed in v0.11.0, use new instead",
+			"spec.count is deprecated since v0.10.1 and removed in v0.12.0",
+		},
+	}, {
+		name: "after some removals",
+		ctx:  WithReleaseVersion(context.Background(), Version{Minor: 11}),
+		wantWarnings: []string{
+			"spec.legacy is deprecated since v0.10.0",
+			"spec.count is deprecated since v0.10.1 and removed in v0.12.0",
+		},
+		wantErr: "field removed in v0.11.0: spec.items[1].next.old, spec.items[1].old\nuse new instead",
+	}, {
+		name: "unchanged on update",
+		ctx: WithinUpdate(WithReleaseVersion(context.Background(), Version{Minor: 12}),
+			&deprecationResource{Spec: deprecationSpec{
+				Items: []deprecationItem{{}, {Old: "b", Next: &deprecationItem{Old: "changed"}}},
+				Count: &count,
+			}}),
+		wantWarnings: []string{
+			"spec.legacy is deprecated since v0.10.0",
+			"spec.items[1].old is deprecated since v0.9.0 and removed in v0.11.0, use new instead",
+			"spec.count is deprecated since v0.10.1 and removed in v0.12.0",
+		},
+		wantErr: "field removed in v0.11.0: spec.items[1].next.old\nuse new instead",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			warnings, err := CheckFieldDeprecations(test.ctx, obj)
+			if diff := cmp.Diff(test.wantWarnings, warnings); diff != "" {
+				t.Errorf("warnings (-want, +got) = %s", diff)
+			}
+			if got := err.Error(); got != test.wantErr {
+				t.Errorf("CheckFieldDeprecations() = %q, want %q", got, test.wantErr)
+			}
+		})
+	}
+
+	if warnings, err := CheckFieldDeprecations(context.Background(), &deprecationResource{}); warnings != nil || err != nil {
+		t.Errorf("CheckFieldDeprecations() = %v, %v, want nothing for an object without deprecated fields", warnings, err)
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ducktest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"text/tabwriter"
+
+	"knative.dev/pkg/apis/duck"
+)
+
+// Default is the Registry of Register.
+var Default = &Registry{}
+
+// Register registers a concrete type and the duck types it implements in the
+// Default registry.
+func Register(instance interface{}, ifaces ...duck.Implementable) {
+	Default.Register(instance, ifaces...)
+}
+
+// Registry holds concrete types along with the duck types they implement, to
+// report the conformance of all of the types of a binary, e.g.
+//
+//	func init() {
+//	  ducktest.Register(&Service{}, &duckv1.Addressable{}, &duckv1.Conditions{})
+//	}
+//
+//	func main() {
+//	  report := ducktest.Default.Report()
+//	  report.WriteText(os.Stdout)
+//	  if !report.Conforms() {
+//	    os.Exit(1)
+//	  }
+//	}
+type Registry struct {
+	mu      sync.Mutex
+	entries []entry
+}
+
+// entry is a concrete type along with a duck type it implements.
+type entry struct {
+	typ   reflect.Type
+	iface duck.Implementable
+}
+
+// Register registers a concrete type, given as a pointer to an instance, and
+// the duck types it implements.
+func (r *Registry) Register(instance interface{}, ifaces ...duck.Implementable) {
+	typ := reflect.TypeOf(instance)
+	if typ == nil || typ.Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("ducktest: %T must be a pointer to be registered", instance))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, iface := range ifaces {
+		r.entries = append(r.entries, entry{typ: typ, iface: iface})
+	}
+}
+
+// Report verifies all of the registered types against their duck types, in
+// the order they were registered.
+func (r *Registry) Report() Report {
+	r.mu.Lock()
+	entries := append([]entry(nil), r.entries...)
+	r.mu.Unlock()
+
+	report := Report{Results: make([]Result, 0, len(entries))}
+	for _, e := range entries {
+		// Verify a fresh instance, as the round trip fills it.
+		report.Results = append(report.Results, Verify(reflect.New(e.typ.Elem()).Interface(), e.iface))
+	}
+	return report
+}
+
+// Report is the conformance report of the types of a Registry.
+type Report struct {
+	Results []Result `json:"results"`
+}
+
+// Conforms returns true if all of the types implement their duck types.
+func (r Report) Conforms() bool {
+	for _, result := range r.Results {
+		if !result.Conforms {
+			return false
+		}
+	}
+	return true
+}
+
+// Failures returns the results of the types which don't implement their
+// duck types.
+func (r Report) Failures() []Result {
+	var failures []Result
+	for _, result := range r.Results {
+		if !result.Conforms {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
+
+// WriteJSON writes the report as JSON, e.g. for the dashboards.
+func (r Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteText writes a table of the results, followed by the details of the
+// failures.
+func (r Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TYPE\tDUCK\tCONFORMS")
+	for _, result := range r.Results {
+		fmt.Fprintf(tw, "%s\t%s\t%v\n", result.Type, result.Duck, result.Conforms)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, failure := range r.Failures() {
+		if _, err := fmt.Fprintf(w, "\n%v\n", failure); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ducktest
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+)
+
+func sortedCopy(in []string) []string {
+	out := append([]string(nil), in...)
+	sort.Strings(out)
+	return out
+}
+
+func TestRegistry(t *testing.T) {
+	r := &Registry{}
+	if report := r.Report(); !report.Conforms() || len(report.Results) != 0 {
+		t.Errorf("Report() = %+v, wanted an empty conforming report", report)
+	}
+
+	r.Register(&duckv1.AddressableType{}, &duckv1.Addressable{})
+	r.Register(&duckv1.KResource{}, &duckv1.Conditions{}, &duckv1.Addressable{})
+	report := r.Report()
+	if report.Conforms() {
+		t.Error("Conforms() = true, wanted false")
+	}
+	var got []string
+	for _, result := range report.Results {
+		got = append(got, result.String())
+	}
+	want := []string{
+		"*v1.AddressableType implements the duck type *v1.Addressable",
+		"*v1.KResource implements the duck type *v1.Conditions",
+		"*v1.KResource does not implement the duck type *v1.Addressable, the following fields were lost:\n\tstatus.address is missing",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Results (-want, +got): %s", diff)
+	}
+	if failures := report.Failures(); len(failures) != 1 || failures[0].Type != "*v1.KResource" {
+		t.Errorf("Failures() = %+v, wanted the KResource one", failures)
+	}
+
+	// The registered instances are fresh for every report.
+	if diff := cmp.Diff(report, r.Report()); diff != "" {
+		t.Errorf("Second report (-want, +got): %s", diff)
+	}
+}
+
+func TestRegisterNotPointer(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Register() didn't panic for a struct")
+		}
+	}()
+	(&Registry{}).Register(duckv1.KResource{}, &duckv1.Conditions{})
+}
+
+func TestReportWrite(t *testing.T) {
+	report := Report{Results: []Result{{
+		Type:     "*v1.Service",
+		Duck:     "*v1.Addressable",
+		Conforms: true,
+	}, {
+		Type:       "*v1.Broken",
+		Duck:       "*v1.Conditions",
+		LostFields: []string{"status.conditions is missing"},
+	}}}
+
+	var text bytes.Buffer
+	if err := report.WriteText(&text); err != nil {
+		t.Fatalf("WriteText() = %v", err)
+	}
+	wantText := `TYPE         DUCK             CONFORMS
+*v1.Service  *v1.Addressable  true
+*v1.Broken   *v1.Conditions   false
+
+*v1.Broken does not implement the duck type *v1.Conditions, the following fields were lost:
+	status.conditions is missing
+`
+	if diff := cmp.Diff(wantText, text.String()); diff != "" {
+		t.Errorf("WriteText (-want, +got): %s", diff)
+	}
+
+	var js bytes.Buffer
+	if err := report.WriteJSON(&js); err != nil {
+		t.Fatalf("WriteJSON() = %v", err)
+	}
+	var got Report
+	if err := json.Unmarshal(js.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal() = %v", err)
+	}
+	if diff := cmp.Diff(report, got); diff != "" {
+		t.Errorf("JSON report (-want, +got): %s", diff)
+	}
+}
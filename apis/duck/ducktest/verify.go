@@ -0,0 +1,157 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ducktest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"knative.dev/pkg/apis/duck"
+)
+
+// Result is the outcome of the verification of a concrete type against a
+// duck type.
+type Result struct {
+	// Type is the concrete type verified, e.g. "*v1.Service".
+	Type string `json:"type"`
+	// Duck is the duck type it was verified against, e.g. "*v1.Addressable".
+	Duck string `json:"duck"`
+	// Conforms is true if the concrete type implements the duck type.
+	Conforms bool `json:"conforms"`
+	// LostFields describe the fields of the duck type which were lost or
+	// changed through the concrete type, by JSON path.
+	LostFields []string `json:"lostFields,omitempty"`
+	// Error is the error the round trip failed with, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// String describes the result for humans.
+func (r Result) String() string {
+	switch {
+	case r.Error != "":
+		return fmt.Sprintf("%s cannot be verified against the duck type %s: %s", r.Type, r.Duck, r.Error)
+	case !r.Conforms:
+		return fmt.Sprintf("%s does not implement the duck type %s, the following fields were lost:\n\t%s",
+			r.Type, r.Duck, strings.Join(r.LostFields, "\n\t"))
+	default:
+		return fmt.Sprintf("%s implements the duck type %s", r.Type, r.Duck)
+	}
+}
+
+// VerifyType fails the test, with the fields which were lost, if the concrete
+// instance doesn't properly implement the duck type, like duck.VerifyType:
+//
+//	func TestImplementsAddressable(t *testing.T) {
+//	  ducktest.VerifyType(t, &Service{}, &duckv1.Addressable{})
+//	}
+func VerifyType(t testing.TB, instance interface{}, iface duck.Implementable) {
+	t.Helper()
+	if r := Verify(instance, iface); !r.Conforms {
+		t.Error(r)
+	}
+}
+
+// Verify populates the full type of the duck type, round-trips it through the
+// concrete instance as JSON, and returns which of its fields were lost.
+func Verify(instance interface{}, iface duck.Implementable) Result {
+	r := Result{
+		Type: fmt.Sprintf("%T", instance),
+		Duck: fmt.Sprintf("%T", iface),
+	}
+	input, output := iface.GetFullType(), iface.GetFullType()
+	input.Populate()
+
+	if err := convert(input, instance); err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	if err := convert(instance, output); err != nil {
+		r.Error = err.Error()
+		return r
+	}
+
+	// The fields are compared as JSON, so that they're reported by path.
+	var before, after interface{}
+	if err := convert(input, &before); err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	if err := convert(output, &after); err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.LostFields = lostFields("", before, after)
+	sort.Strings(r.LostFields)
+	r.Conforms = len(r.LostFields) == 0
+	return r
+}
+
+// convert serializes from to JSON and deserializes it into to.
+func convert(from, to interface{}) error {
+	b, err := json.Marshal(from)
+	if err != nil {
+		return fmt.Errorf("error serializing %T: %v", from, err)
+	}
+	if err := json.Unmarshal(b, to); err != nil {
+		return fmt.Errorf("error deserializing %T into %T: %v", from, to, err)
+	}
+	return nil
+}
+
+// lostFields returns the paths of the leaves of the JSON value before which
+// are missing or different in the JSON value after.
+func lostFields(path string, before, after interface{}) []string {
+	switch b := before.(type) {
+	case map[string]interface{}:
+		a, _ := after.(map[string]interface{})
+		var lost []string
+		for k, v := range b {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			av, ok := a[k]
+			if !ok {
+				lost = append(lost, p+" is missing")
+				continue
+			}
+			lost = append(lost, lostFields(p, v, av)...)
+		}
+		return lost
+	case []interface{}:
+		a, _ := after.([]interface{})
+		var lost []string
+		for i, v := range b {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			if i >= len(a) {
+				lost = append(lost, p+" is missing")
+				continue
+			}
+			lost = append(lost, lostFields(p, v, a[i])...)
+		}
+		return lost
+	default:
+		if !reflect.DeepEqual(before, after) {
+			return []string{fmt.Sprintf("%s changed from %v to %v", path, before, after)}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ducktest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+)
+
+// recorder records the errors of VerifyType.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Error(args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprint(args...))
+}
+
+// lossyAddressable only keeps the URL of its address.
+type lossyAddressable struct {
+	Status struct {
+		Address struct {
+			URL string `json:"url"`
+		} `json:"address"`
+	} `json:"status"`
+}
+
+// unserializable cannot be deserialized into.
+type unserializable struct {
+	Status string `json:"status"`
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name     string
+		instance interface{}
+		want     Result
+	}{{
+		name:     "conforms",
+		instance: &duckv1.AddressableType{},
+		want: Result{
+			Type:     "*v1.AddressableType",
+			Duck:     "*v1.Addressable",
+			Conforms: true,
+		},
+	}, {
+		name:     "missing duck",
+		instance: &duckv1.KResource{},
+		want: Result{
+			Type:       "*v1.KResource",
+			Duck:       "*v1.Addressable",
+			LostFields: []string{"status.address is missing"},
+		},
+	}, {
+		name:     "lossy",
+		instance: &lossyAddressable{},
+		want: Result{
+			Type: "*ducktest.lossyAddressable",
+			Duck: "*v1.Addressable",
+			LostFields: []string{
+				"status.address.CACerts is missing",
+				"status.address.audience is missing",
+			},
+		},
+	}, {
+		name:     "not deserializable",
+		instance: &unserializable{},
+		want: Result{
+			Type:  "*ducktest.unserializable",
+			Duck:  "*v1.Addressable",
+			Error: "error deserializing *v1.AddressableType into *ducktest.unserializable: json: cannot unmarshal object into Go struct field unserializable.status of type string",
+		},
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Verify(test.instance, &duckv1.Addressable{})
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Verify (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestLostFields(t *testing.T) {
+	before := map[string]interface{}{
+		"a": "x",
+		"b": []interface{}{"1", map[string]interface{}{"c": 2.0}},
+		"d": []interface{}{"1", "2"},
+	}
+	after := map[string]interface{}{
+		"a": "y",
+		"b": []interface{}{"1", map[string]interface{}{}},
+		"d": []interface{}{"1"},
+	}
+	got := lostFields("", before, after)
+	want := []string{
+		"a changed from x to y",
+		"b[1].c is missing",
+		"d[1] is missing",
+	}
+	if diff := cmp.Diff(want, got, cmp.Transformer("sort", sortedCopy)); diff != "" {
+		t.Errorf("lostFields (-want, +got): %s", diff)
+	}
+}
+
+func TestVerifyType(t *testing.T) {
+	r := &recorder{TB: t}
+	VerifyType(r, &duckv1.AddressableType{}, &duckv1.Addressable{})
+	if len(r.errors) != 0 {
+		t.Errorf("VerifyType() failed with %v, wanted no failure", r.errors)
+	}
+
+	VerifyType(r, &duckv1.KResource{}, &duckv1.Addressable{})
+	want := []string{"*v1.KResource does not implement the duck type *v1.Addressable, the following fields were lost:\n\tstatus.address is missing"}
+	if diff := cmp.Diff(want, r.errors); diff != "" {
+		t.Errorf("VerifyType errors (-want, +got): %s", diff)
+	}
+}
@@ -25,6 +25,7 @@ import (
 
 	"knative.dev/pkg/apis"
 	"knative.dev/pkg/apis/duck"
+	"knative.dev/pkg/ptr"
 )
 
 // Addressable provides a generic mechanism for a custom resource
@@ -35,6 +36,16 @@ import (
 // be generated by the controller.
 type Addressable struct {
 	URL *apis.URL `json:"url,omitempty"`
+
+	// CACerts is the PEM encoded CA bundle to verify the certificate
+	// served at the URL, if it uses TLS.
+	// +optional
+	CACerts *string `json:"CACerts,omitempty"`
+
+	// Audience is the audience of the tokens the destination accepts
+	// to authenticate its callers.
+	// +optional
+	Audience *string `json:"audience,omitempty"`
 }
 
 var (
@@ -94,6 +105,8 @@ func (t *AddressableType) Populate() {
 				Scheme: "http",
 				Host:   "foo.com",
 			},
+			CACerts:  ptr.String("-----BEGIN CERTIFICATE-----"),
+			Audience: ptr.String("foo.com"),
 		},
 	}
 }
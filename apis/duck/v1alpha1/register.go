@@ -53,6 +53,8 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		(&Target{}).GetListType(),
 		&LegacyTarget{},
 		(&LegacyTarget{}).GetListType(),
+		&Binding{},
+		(&Binding{}).GetListType(),
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
@@ -0,0 +1,186 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apis
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Rule is a validation rule, returning the errors of the fields it checks,
+// relative to the validated object, or nil if they're valid. The rules are
+// composed with AllOf, so that a Validate method lists its rules instead of
+// building their errors. For example:
+//
+//	func (ss *ServiceSpec) Validate(ctx context.Context) *apis.FieldError {
+//	  return apis.AllOf(
+//	    apis.OneOf(
+//	      apis.Field{Name: "template", Set: ss.Template != nil},
+//	      apis.Field{Name: "ref", Set: ss.Ref != nil},
+//	    ),
+//	    apis.RequiredIf(ss.Public, apis.Field{Name: "host", Set: ss.Host != ""}),
+//	    apis.DurationInRange(ss.Timeout, 0, 10*time.Minute, "timeout"),
+//	    apis.Rule(func() *apis.FieldError {
+//	      return ss.Template.Validate(ctx)
+//	    }).ViaField("template").When(ss.Template != nil),
+//	  ).Validate()
+//	}
+type Rule func() *FieldError
+
+// Field is a field checked by a rule, with whether it's set in the validated
+// object.
+type Field struct {
+	// Name is the path of the field, relative to the validated object.
+	Name string
+	// Set is whether the field has a value, e.g. is non-nil or non-empty.
+	Set bool
+}
+
+// Validate returns the errors of the rule, or nil if it's valid. A nil rule
+// is always valid.
+func (r Rule) Validate() *FieldError {
+	if r == nil {
+		return nil
+	}
+	return r()
+}
+
+// ViaField returns the rule with the paths of its errors prefixed by the
+// given fields, see FieldError.ViaField.
+func (r Rule) ViaField(prefix ...string) Rule {
+	return func() *FieldError {
+		return r.Validate().ViaField(prefix...)
+	}
+}
+
+// ViaIndex returns the rule with the paths of its errors prefixed by the
+// given index, see FieldError.ViaIndex.
+func (r Rule) ViaIndex(index int) Rule {
+	return r.ViaField(asIndex(index))
+}
+
+// ViaKey returns the rule with the paths of its errors prefixed by the given
+// key, see FieldError.ViaKey.
+func (r Rule) ViaKey(key string) Rule {
+	return r.ViaField(asKey(key))
+}
+
+// When returns the rule checked only if the condition holds, e.g. when the
+// field it checks is set.
+func (r Rule) When(cond bool) Rule {
+	if !cond {
+		return nil
+	}
+	return r
+}
+
+// AllOf returns the rule checking all of the given rules, with all of their
+// errors.
+func AllOf(rules ...Rule) Rule {
+	return func() *FieldError {
+		var errs *FieldError
+		for _, r := range rules {
+			errs = errs.Also(r.Validate())
+		}
+		return errs
+	}
+}
+
+// RequiredIf returns the rule requiring the field to be set if the condition
+// holds.
+func RequiredIf(cond bool, f Field) Rule {
+	return func() *FieldError {
+		if cond && !f.Set {
+			return ErrMissingField(f.Name)
+		}
+		return nil
+	}
+}
+
+// DisallowedIf returns the rule requiring the field not to be set if the
+// condition holds.
+func DisallowedIf(cond bool, f Field) Rule {
+	return func() *FieldError {
+		if cond && f.Set {
+			return ErrDisallowedFields(f.Name)
+		}
+		return nil
+	}
+}
+
+// MutuallyExclusive returns the rule requiring at most one of the fields to
+// be set. The error lists the fields which are set.
+func MutuallyExclusive(fields ...Field) Rule {
+	return func() *FieldError {
+		if set := setFields(fields); len(set) > 1 {
+			return ErrGeneric("expected at most one, got several", set...)
+		}
+		return nil
+	}
+}
+
+// OneOf returns the rule requiring exactly one of the fields to be set. The
+// error lists all of the fields if none is set, or else the ones which are.
+func OneOf(fields ...Field) Rule {
+	return func() *FieldError {
+		set := setFields(fields)
+		switch {
+		case len(set) == 0:
+			names := make([]string, 0, len(fields))
+			for _, f := range fields {
+				names = append(names, f.Name)
+			}
+			return ErrMissingOneOf(names...)
+		case len(set) > 1:
+			return ErrMultipleOneOf(set...)
+		}
+		return nil
+	}
+}
+
+func setFields(fields []Field) []string {
+	var set []string
+	for _, f := range fields {
+		if f.Set {
+			set = append(set, f.Name)
+		}
+	}
+	return set
+}
+
+// DurationInRange returns the rule requiring the duration of the field to be
+// within the given bounds, inclusive.
+func DurationInRange(d, lower, upper time.Duration, field string) Rule {
+	return func() *FieldError {
+		if d < lower || d > upper {
+			return ErrOutOfBoundsValue(d, lower, upper, field)
+		}
+		return nil
+	}
+}
+
+// QuantityInRange returns the rule requiring the quantity of the field, e.g.
+// a size, to be within the given bounds, inclusive.
+func QuantityInRange(q, lower, upper resource.Quantity, field string) Rule {
+	return func() *FieldError {
+		if q.Cmp(lower) < 0 || q.Cmp(upper) > 0 {
+			return ErrOutOfBoundsValue(q.String(), lower.String(), upper.String(), field)
+		}
+		return nil
+	}
+}
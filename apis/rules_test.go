@@ -0,0 +1,151 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apis
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+		want string
+	}{{
+		name: "nil",
+		rule: nil,
+	}, {
+		name: "required if, set",
+		rule: RequiredIf(true, Field{Name: "host", Set: true}),
+	}, {
+		name: "required if, condition doesn't hold",
+		rule: RequiredIf(false, Field{Name: "host"}),
+	}, {
+		name: "required if, missing",
+		rule: RequiredIf(true, Field{Name: "host"}),
+		want: "missing field(s): host",
+	}, {
+		name: "disallowed if, set",
+		rule: DisallowedIf(true, Field{Name: "host", Set: true}),
+		want: "must not set the field(s): host",
+	}, {
+		name: "disallowed if, condition doesn't hold",
+		rule: DisallowedIf(false, Field{Name: "host", Set: true}),
+	}, {
+		name: "mutually exclusive, none",
+		rule: MutuallyExclusive(Field{Name: "a"}, Field{Name: "b"}),
+	}, {
+		name: "mutually exclusive, one",
+		rule: MutuallyExclusive(Field{Name: "a"}, Field{Name: "b", Set: true}),
+	}, {
+		name: "mutually exclusive, several",
+		rule: MutuallyExclusive(Field{Name: "a", Set: true}, Field{Name: "b"}, Field{Name: "c", Set: true}),
+		want: "expected at most one, got several: a, c",
+	}, {
+		name: "one of, none",
+		rule: OneOf(Field{Name: "a"}, Field{Name: "b"}),
+		want: "expected exactly one, got neither: a, b",
+	}, {
+		name: "one of, one",
+		rule: OneOf(Field{Name: "a", Set: true}, Field{Name: "b"}),
+	}, {
+		name: "one of, several",
+		rule: OneOf(Field{Name: "a", Set: true}, Field{Name: "b", Set: true}, Field{Name: "c"}),
+		want: "expected exactly one, got both: a, b",
+	}, {
+		name: "duration in range",
+		rule: DurationInRange(time.Minute, 0, time.Minute, "timeout"),
+	}, {
+		name: "duration out of range",
+		rule: DurationInRange(2*time.Minute, time.Second, time.Minute, "timeout"),
+		want: "expected 1s <= 2m0s <= 1m0s: timeout",
+	}, {
+		name: "quantity in range",
+		rule: QuantityInRange(resource.MustParse("1Gi"), resource.MustParse("1Mi"), resource.MustParse("1Gi"), "size"),
+	}, {
+		name: "quantity out of range",
+		rule: QuantityInRange(resource.MustParse("512Ki"), resource.MustParse("1Mi"), resource.MustParse("1Gi"), "size"),
+		want: "expected 1Mi <= 512Ki <= 1Gi: size",
+	}, {
+		name: "when",
+		rule: RequiredIf(true, Field{Name: "host"}).When(false),
+	}, {
+		name: "via field",
+		rule: OneOf(Field{Name: "a"}, Field{Name: "b"}).ViaField("spec", "source"),
+		want: "expected exactly one, got neither: spec.source.a, spec.source.b",
+	}, {
+		name: "via index and key",
+		rule: AllOf(
+			RequiredIf(true, Field{Name: "name"}).ViaIndex(1).ViaField("ports"),
+			RequiredIf(true, Field{Name: "value"}).ViaKey("foo").ViaField("env"),
+		),
+		want: `missing field(s): env[foo].value, ports[1].name`,
+	}, {
+		name: "all of",
+		rule: AllOf(
+			nil,
+			RequiredIf(true, Field{Name: "host", Set: true}),
+			RequiredIf(true, Field{Name: "port"}),
+			OneOf(Field{Name: "a", Set: true}, Field{Name: "b", Set: true}),
+			DurationInRange(-time.Second, 0, time.Minute, "timeout"),
+		).ViaField("spec"),
+		want: `expected 0s <= -1s <= 1m0s: spec.timeout
+expected exactly one, got both: spec.a, spec.b
+missing field(s): spec.port`,
+	}, {
+		name: "all of, valid",
+		rule: AllOf(
+			RequiredIf(true, Field{Name: "host", Set: true}),
+			OneOf(Field{Name: "a", Set: true}, Field{Name: "b"}),
+		),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.rule.Validate()
+			if test.want == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v, wanted nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() = nil, wanted %q", test.want)
+			}
+			if got := err.Error(); got != test.want {
+				t.Errorf("Validate() = %q, wanted %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRuleIsLazy(t *testing.T) {
+	called := false
+	r := Rule(func() *FieldError {
+		called = true
+		return nil
+	}).ViaField("spec").When(false)
+	if err := AllOf(r).Validate(); err != nil {
+		t.Errorf("Validate() = %v, wanted nil", err)
+	}
+	if called {
+		t.Error("The rule was checked, though its condition doesn't hold")
+	}
+}
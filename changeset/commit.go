@@ -22,35 +22,147 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 )
 
 const (
 	commitIDFile      = "HEAD"
+	packedRefsFile    = "packed-refs"
 	koDataPathEnvName = "KO_DATA_PATH"
+
+	// maxSymbolicRefs is the number of symbolic refs followed from HEAD,
+	// e.g. HEAD pointing at a branch which points at another branch.
+	maxSymbolicRefs = 2
 )
 
 var commitIDRE = regexp.MustCompile(`^[a-f0-9]{40}$`)
 
 // Get tries to fetch the first 7 digitals of GitHub commit ID from HEAD file in
-// KO_DATA_PATH. If it fails, it returns the error it gets.
+// KO_DATA_PATH, falling back to the build information of the binary, see GetInfo.
+// If it fails, it returns the error it gets.
 func Get() (string, error) {
-	data, err := readFileFromKoData(commitIDFile)
+	info, err := GetInfo()
 	if err != nil {
 		return "", err
 	}
+	return info.ShortCommit(), nil
+}
+
+// readKoData reads the commit ID and the branch from the HEAD file in
+// KO_DATA_PATH, and the tag pointing at the commit if the tags are linked
+// into KO_DATA_PATH along with it.
+func readKoData() (Info, error) {
+	data, err := readFileFromKoData(commitIDFile)
+	if err != nil {
+		return Info{}, err
+	}
+	var info Info
 	commitID := strings.TrimSpace(string(data))
-	if rID := strings.TrimPrefix(commitID, "ref: "); rID != commitID {
-		data, err := readFileFromKoData(rID)
-		if err != nil {
-			return "", err
+	for i := 0; strings.HasPrefix(commitID, "ref: "); i++ {
+		if i == maxSymbolicRefs {
+			return Info{}, fmt.Errorf("more than %d levels of symbolic refs from %s", maxSymbolicRefs, commitIDFile)
+		}
+		rID := strings.TrimPrefix(commitID, "ref: ")
+		if commitID, err = resolveRef(rID); err != nil {
+			return Info{}, err
+		}
+		info.Branch = strings.TrimPrefix(rID, "refs/heads/")
+	}
+	if !commitIDRE.MatchString(commitID) {
+		return Info{}, fmt.Errorf("%q is not a valid GitHub commit ID", commitID)
+	}
+	info.Commit = commitID
+	info.Tag = findKoDataTag(commitID)
+	return info, nil
+}
+
+// resolveRef returns the content of the given ref in KO_DATA_PATH, i.e. a
+// commit ID or a symbolic ref, falling back to packed-refs when the ref isn't
+// a file, e.g. once the repository has been garbage collected. If the ref
+// can't be found, it returns the error of reading its file.
+func resolveRef(ref string) (string, error) {
+	data, err := readFileFromKoData(ref)
+	if err == nil {
+		return strings.TrimSpace(string(data)), nil
+	}
+	if packed, perr := readPackedRefs(); perr == nil {
+		if commitID, ok := packed.refs[ref]; ok {
+			return commitID, nil
+		}
+	}
+	return "", err
+}
+
+// packedRefs are the refs listed in packed-refs.
+type packedRefs struct {
+	// refs maps the refs to the object IDs they point at.
+	refs map[string]string
+	// peeled maps the annotated tags to the commit IDs they point at.
+	peeled map[string]string
+}
+
+// readPackedRefs parses the packed-refs file in KO_DATA_PATH, whose lines are
+// either `<object ID> <ref>` or `^<commit ID>` for the annotated tag above.
+func readPackedRefs() (packedRefs, error) {
+	data, err := readFileFromKoData(packedRefsFile)
+	if err != nil {
+		return packedRefs{}, err
+	}
+	packed := packedRefs{refs: make(map[string]string), peeled: make(map[string]string)}
+	var last string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "^"):
+			if last != "" {
+				packed.peeled[last] = strings.TrimPrefix(line, "^")
+			}
+		default:
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				return packedRefs{}, fmt.Errorf("malformed line %q in %s", line, packedRefsFile)
+			}
+			packed.refs[fields[1]] = fields[0]
+			last = fields[1]
+		}
+	}
+	return packed, nil
+}
+
+// findKoDataTag returns the tag under refs/tags in KO_DATA_PATH, or in its
+// packed-refs, pointing at the given commit ID, if any.
+func findKoDataTag(commitID string) string {
+	tagsDir := filepath.Join(os.Getenv(koDataPathEnvName), "refs", "tags")
+	if files, err := ioutil.ReadDir(tagsDir); err == nil {
+		for _, f := range files {
+			data, err := ioutil.ReadFile(filepath.Join(tagsDir, f.Name()))
+			if err == nil && strings.TrimSpace(string(data)) == commitID {
+				return f.Name()
+			}
+		}
+	}
+
+	packed, err := readPackedRefs()
+	if err != nil {
+		return ""
+	}
+	var tags []string
+	for ref, id := range packed.refs {
+		if !strings.HasPrefix(ref, "refs/tags/") {
+			continue
+		}
+		if id == commitID || packed.peeled[ref] == commitID {
+			tags = append(tags, strings.TrimPrefix(ref, "refs/tags/"))
 		}
-		commitID = strings.TrimSpace(string(data))
 	}
-	if commitIDRE.MatchString(commitID) {
-		return commitID[:7], nil
+	if len(tags) == 0 {
+		return ""
 	}
-	return "", fmt.Errorf("%q is not a valid GitHub commit ID", commitID)
+	sort.Strings(tags)
+	return tags[0]
 }
 
 // readFileFromKoData tries to read data as string from the file with given name
@@ -19,5 +19,10 @@ limitations under the License.
 // Knative component source code via the following command:
 //   ln -s -r .git/HEAD ./cmd/<knative-component-name>/kodata/
 // Then ko will build this file into $KO_DATA_PATH when building the container
-// for a Knative component.
+// for a Knative component. Linking the tags as well, e.g. `.git/refs/tags`,
+// gives access to the tag of the commit. Refs which have been packed are
+// looked up in `.git/packed-refs`, which can be linked the same way.
+//
+// Binaries built without ko fall back to the commit ID and the other
+// information stamped by Go or injected via -ldflags, see GetInfo.
 package changeset
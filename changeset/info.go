@@ -0,0 +1,165 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package changeset
+
+import (
+	"regexp"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// These variables can be injected when building binaries without ko, e.g.
+//
+//	go build -ldflags "-X knative.dev/pkg/changeset.commit=$(git rev-parse HEAD)"
+//
+// dirty is "true" if the working tree had uncommitted changes, and buildDate
+// is in the RFC 3339 format.
+var (
+	commit    string
+	branch    string
+	tag       string
+	dirty     string
+	buildDate string
+)
+
+var (
+	// shortCommitIDRE matches the commit IDs of the build information,
+	// which can be abbreviated when injected.
+	shortCommitIDRE = regexp.MustCompile(`^[a-f0-9]{7,40}$`)
+	// releaseVersionRE matches the module versions which are release tags,
+	// rather than pseudo-versions.
+	releaseVersionRE = regexp.MustCompile(`^v[0-9]+\.[0-9]+\.[0-9]+(-[0-9A-Za-z.]+)?$`)
+	pseudoVersionRE  = regexp.MustCompile(`[0-9]{14}-[a-f0-9]{12}$`)
+)
+
+// Info is the information about the source code a binary was built from.
+type Info struct {
+	// Commit is the ID of the Git commit.
+	Commit string
+	// Branch is the Git branch, if known.
+	Branch string
+	// Tag is the Git tag of the commit, if known.
+	Tag string
+	// Dirty is true if the working tree had uncommitted changes.
+	Dirty bool
+	// BuildDate is the time the binary was built, or the time of the
+	// commit if only that is known. It's zero if unknown.
+	BuildDate time.Time
+}
+
+// ShortCommit returns the first 7 digitals of the commit ID.
+func (i Info) ShortCommit() string {
+	if len(i.Commit) > 7 {
+		return i.Commit[:7]
+	}
+	return i.Commit
+}
+
+var (
+	overrideMu sync.RWMutex
+	override   *Info
+)
+
+// SetForTesting makes GetInfo and Get return the given information, until
+// the returned function is called to restore them.
+func SetForTesting(info Info) func() {
+	overrideMu.Lock()
+	defer overrideMu.Unlock()
+	previous := override
+	override = &info
+	return func() {
+		overrideMu.Lock()
+		defer overrideMu.Unlock()
+		override = previous
+	}
+}
+
+// GetInfo returns the information about the source code the binary was built
+// from. It's read from the HEAD file in KO_DATA_PATH for binaries built by ko,
+// see the package documentation. Otherwise, it falls back to the variables
+// injected with -ldflags, and to the build information Go stamps the binaries
+// with. If no commit ID can be found, it returns the error of reading
+// KO_DATA_PATH.
+func GetInfo() (Info, error) {
+	overrideMu.RLock()
+	defer overrideMu.RUnlock()
+	if override != nil {
+		return *override, nil
+	}
+
+	fallback := buildInfo()
+	info, err := readKoData()
+	if err != nil {
+		if fallback.Commit == "" {
+			return Info{}, err
+		}
+		return fallback, nil
+	}
+	// The build information can only complete the information of the same commit.
+	if fallback.Commit == "" || fallback.Commit == info.Commit {
+		info.Dirty = fallback.Dirty
+		info.BuildDate = fallback.BuildDate
+		if info.Tag == "" {
+			info.Tag = fallback.Tag
+		}
+	}
+	return info, nil
+}
+
+// buildInfo returns the information injected with -ldflags, completed by
+// the build information of the binary.
+func buildInfo() Info {
+	var info Info
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if v := bi.Main.Version; releaseVersionRE.MatchString(v) && !pseudoVersionRE.MatchString(v) {
+			info.Tag = v
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				info.Commit = s.Value
+			case "vcs.modified":
+				info.Dirty = s.Value == "true"
+			case "vcs.time":
+				if t, err := time.Parse(time.RFC3339, s.Value); err == nil {
+					info.BuildDate = t
+				}
+			}
+		}
+	}
+
+	if commit != "" {
+		info.Commit = commit
+	}
+	if branch != "" {
+		info.Branch = branch
+	}
+	if tag != "" {
+		info.Tag = tag
+	}
+	if dirty != "" {
+		info.Dirty = dirty == "true"
+	}
+	if t, err := time.Parse(time.RFC3339, buildDate); err == nil {
+		info.BuildDate = t
+	}
+	if !shortCommitIDRE.MatchString(info.Commit) {
+		info.Commit = ""
+	}
+	return info
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package changeset
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const fullTestCommitID = "a2d1bdfe929516d7da141aef68631a7ee6941b2d"
+
+// setLdflags sets the variables injected with -ldflags, until the returned
+// function is called.
+func setLdflags(c, b, t, d, date string) func() {
+	commit, branch, tag, dirty, buildDate = c, b, t, d, date
+	return func() {
+		commit, branch, tag, dirty, buildDate = "", "", "", "", ""
+	}
+}
+
+func TestGetInfo(t *testing.T) {
+	date := time.Date(2019, 12, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		koDataPath string
+		ldflags    []string
+		want       Info
+		wantErr    bool
+	}{{
+		name:       "kodata with branch and tag",
+		koDataPath: "testdata/with-tags",
+		want:       Info{Commit: fullTestCommitID, Branch: "release-0.1", Tag: "v0.1.0"},
+	}, {
+		name:       "kodata with packed refs and an annotated tag",
+		koDataPath: "testdata/packed",
+		want:       Info{Commit: fullTestCommitID, Branch: "main", Tag: "v0.2.0"},
+	}, {
+		name:       "kodata with a symbolic ref to a packed ref",
+		koDataPath: "testdata/symbolic",
+		want:       Info{Commit: fullTestCommitID, Branch: "main"},
+	}, {
+		name:       "kodata completed by ldflags",
+		koDataPath: "testdata/with-refs",
+		ldflags:    []string{fullTestCommitID, "", "", "true", date.Format(time.RFC3339)},
+		want:       Info{Commit: fullTestCommitID, Branch: "branch-name", Dirty: true, BuildDate: date},
+	}, {
+		name:       "kodata not completed by ldflags of another commit",
+		koDataPath: "testdata",
+		ldflags:    []string{"1234567", "", "", "true", ""},
+		want:       Info{Commit: fullTestCommitID},
+	}, {
+		name:    "ldflags without kodata",
+		ldflags: []string{"1234567", "main", "v0.2.0", "false", date.Format(time.RFC3339)},
+		want:    Info{Commit: "1234567", Branch: "main", Tag: "v0.2.0", BuildDate: date},
+	}, {
+		name:    "invalid ldflags commit",
+		ldflags: []string{"not a commit", "", "", "", ""},
+		wantErr: true,
+	}, {
+		name:    "nothing",
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.Setenv(koDataPathEnvName, test.koDataPath)
+			defer os.Unsetenv(koDataPathEnvName)
+			if test.ldflags != nil {
+				defer setLdflags(test.ldflags[0], test.ldflags[1], test.ldflags[2], test.ldflags[3], test.ldflags[4])()
+			}
+
+			got, err := GetInfo()
+			if (err != nil) != test.wantErr {
+				t.Fatalf("GetInfo() = %v, wantErr %v", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("GetInfo() (-want, +got) = %s", diff)
+			}
+		})
+	}
+}
+
+func TestGetFallback(t *testing.T) {
+	os.Unsetenv(koDataPathEnvName)
+	defer setLdflags(fullTestCommitID, "", "", "", "")()
+	got, err := Get()
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if got != testCommitID {
+		t.Errorf("Get() = %q, want %q", got, testCommitID)
+	}
+}
+
+func TestSetForTesting(t *testing.T) {
+	info := Info{Commit: "deadbeef", Branch: "test"}
+	restore := SetForTesting(info)
+	got, err := GetInfo()
+	if err != nil {
+		t.Fatalf("GetInfo() = %v", err)
+	}
+	if diff := cmp.Diff(info, got); diff != "" {
+		t.Errorf("GetInfo() (-want, +got) = %s", diff)
+	}
+	if got, _ := Get(); got != "deadbee" {
+		t.Errorf("Get() = %q, want %q", got, "deadbee")
+	}
+
+	restore()
+	os.Unsetenv(koDataPathEnvName)
+	if _, err := GetInfo(); err == nil {
+		t.Error("GetInfo() = nil after restoring, wanted an error")
+	}
+}
@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Apiextensions().V1beta1().CustomResourceDefinitions()
-	return context.WithValue(ctx, customresourcedefinition.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, customresourcedefinition.Key{}, inf), injection.LazyInformer(ctx, customresourcedefinition.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
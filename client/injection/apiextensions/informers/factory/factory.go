@@ -37,10 +37,13 @@ type Key struct{}
 
 func withInformerFactory(ctx context.Context) context.Context {
 	c := client.Get(ctx)
-	opts := make([]externalversions.SharedInformerOption, 0, 1)
+	opts := make([]externalversions.SharedInformerOption, 0, 2)
 	if injection.HasNamespaceScope(ctx) {
 		opts = append(opts, externalversions.WithNamespace(injection.GetNamespaceScope(ctx)))
 	}
+	if resyncs := controller.GetResyncPeriods(ctx); len(resyncs) > 0 {
+		opts = append(opts, externalversions.WithCustomResyncConfig(resyncs))
+	}
 	return context.WithValue(ctx, Key{},
 		externalversions.NewSharedInformerFactoryWithOptions(c, controller.GetResyncPeriod(ctx), opts...))
 }
@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Authentication().V1alpha1().Policies()
-	return context.WithValue(ctx, policy.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, policy.Key{}, inf), injection.LazyInformer(ctx, policy.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
@@ -36,10 +36,13 @@ func init() {
 
 func withInformerFactory(ctx context.Context) context.Context {
 	c := fake.Get(ctx)
-	opts := make([]externalversions.SharedInformerOption, 0, 1)
+	opts := make([]externalversions.SharedInformerOption, 0, 2)
 	if injection.HasNamespaceScope(ctx) {
 		opts = append(opts, externalversions.WithNamespace(injection.GetNamespaceScope(ctx)))
 	}
+	if resyncs := controller.GetResyncPeriods(ctx); len(resyncs) > 0 {
+		opts = append(opts, externalversions.WithCustomResyncConfig(resyncs))
+	}
 	return context.WithValue(ctx, factory.Key{},
 		externalversions.NewSharedInformerFactoryWithOptions(c, controller.GetResyncPeriod(ctx), opts...))
 }
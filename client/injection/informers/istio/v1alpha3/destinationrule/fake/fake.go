@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Networking().V1alpha3().DestinationRules()
-	return context.WithValue(ctx, destinationrule.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, destinationrule.Key{}, inf), injection.LazyInformer(ctx, destinationrule.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Networking().V1alpha3().Gateways()
-	return context.WithValue(ctx, gateway.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, gateway.Key{}, inf), injection.LazyInformer(ctx, gateway.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
@@ -38,11 +38,14 @@ type Key struct{}
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := factory.Get(ctx)
 	inf := f.Networking().V1alpha3().Gateways()
-	return context.WithValue(ctx, Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, Key{}, inf), injection.LazyInformer(ctx, Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
 
 // Get extracts the typed informer from the context.
 func Get(ctx context.Context) v1alpha3.GatewayInformer {
+	injection.InformerRetrieved(ctx, Key{})
 	untyped := ctx.Value(Key{})
 	if untyped == nil {
 		logging.FromContext(ctx).Panic(
@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Networking().V1alpha3().VirtualServices()
-	return context.WithValue(ctx, virtualservice.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, virtualservice.Key{}, inf), injection.LazyInformer(ctx, virtualservice.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Apps().V1().ControllerRevisions()
-	return context.WithValue(ctx, controllerrevision.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, controllerrevision.Key{}, inf), injection.LazyInformer(ctx, controllerrevision.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
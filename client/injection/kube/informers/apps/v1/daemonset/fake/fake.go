@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Apps().V1().DaemonSets()
-	return context.WithValue(ctx, daemonset.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, daemonset.Key{}, inf), injection.LazyInformer(ctx, daemonset.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
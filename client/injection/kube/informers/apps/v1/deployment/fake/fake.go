@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Apps().V1().Deployments()
-	return context.WithValue(ctx, deployment.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, deployment.Key{}, inf), injection.LazyInformer(ctx, deployment.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Apps().V1().ReplicaSets()
-	return context.WithValue(ctx, replicaset.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, replicaset.Key{}, inf), injection.LazyInformer(ctx, replicaset.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
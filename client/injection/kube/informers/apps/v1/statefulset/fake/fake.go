@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Apps().V1().StatefulSets()
-	return context.WithValue(ctx, statefulset.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, statefulset.Key{}, inf), injection.LazyInformer(ctx, statefulset.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Autoscaling().V2beta1().HorizontalPodAutoscalers()
-	return context.WithValue(ctx, horizontalpodautoscaler.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, horizontalpodautoscaler.Key{}, inf), injection.LazyInformer(ctx, horizontalpodautoscaler.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Batch().V1().Jobs()
-	return context.WithValue(ctx, job.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, job.Key{}, inf), injection.LazyInformer(ctx, job.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
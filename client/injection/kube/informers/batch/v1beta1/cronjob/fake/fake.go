@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Batch().V1beta1().CronJobs()
-	return context.WithValue(ctx, cronjob.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, cronjob.Key{}, inf), injection.LazyInformer(ctx, cronjob.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Core().V1().ComponentStatuses()
-	return context.WithValue(ctx, componentstatus.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, componentstatus.Key{}, inf), injection.LazyInformer(ctx, componentstatus.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Core().V1().ConfigMaps()
-	return context.WithValue(ctx, configmap.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, configmap.Key{}, inf), injection.LazyInformer(ctx, configmap.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
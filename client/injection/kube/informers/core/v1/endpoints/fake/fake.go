@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Core().V1().Endpoints()
-	return context.WithValue(ctx, endpoints.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, endpoints.Key{}, inf), injection.LazyInformer(ctx, endpoints.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
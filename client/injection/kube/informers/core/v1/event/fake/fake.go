@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Core().V1().Events()
-	return context.WithValue(ctx, event.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, event.Key{}, inf), injection.LazyInformer(ctx, event.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
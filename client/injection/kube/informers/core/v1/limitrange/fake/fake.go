@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Core().V1().LimitRanges()
-	return context.WithValue(ctx, limitrange.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, limitrange.Key{}, inf), injection.LazyInformer(ctx, limitrange.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Core().V1().Namespaces()
-	return context.WithValue(ctx, namespace.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, namespace.Key{}, inf), injection.LazyInformer(ctx, namespace.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
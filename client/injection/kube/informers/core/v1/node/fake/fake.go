@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Core().V1().Nodes()
-	return context.WithValue(ctx, node.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, node.Key{}, inf), injection.LazyInformer(ctx, node.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Core().V1().PersistentVolumes()
-	return context.WithValue(ctx, persistentvolume.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, persistentvolume.Key{}, inf), injection.LazyInformer(ctx, persistentvolume.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Core().V1().PersistentVolumeClaims()
-	return context.WithValue(ctx, persistentvolumeclaim.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, persistentvolumeclaim.Key{}, inf), injection.LazyInformer(ctx, persistentvolumeclaim.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
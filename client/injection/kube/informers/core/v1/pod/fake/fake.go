@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Core().V1().Pods()
-	return context.WithValue(ctx, pod.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, pod.Key{}, inf), injection.LazyInformer(ctx, pod.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Core().V1().PodTemplates()
-	return context.WithValue(ctx, podtemplate.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, podtemplate.Key{}, inf), injection.LazyInformer(ctx, podtemplate.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
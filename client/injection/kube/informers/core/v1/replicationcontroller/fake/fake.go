@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Core().V1().ReplicationControllers()
-	return context.WithValue(ctx, replicationcontroller.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, replicationcontroller.Key{}, inf), injection.LazyInformer(ctx, replicationcontroller.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
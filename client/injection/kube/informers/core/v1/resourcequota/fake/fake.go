@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Core().V1().ResourceQuotas()
-	return context.WithValue(ctx, resourcequota.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, resourcequota.Key{}, inf), injection.LazyInformer(ctx, resourcequota.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Core().V1().Secrets()
-	return context.WithValue(ctx, secret.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, secret.Key{}, inf), injection.LazyInformer(ctx, secret.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
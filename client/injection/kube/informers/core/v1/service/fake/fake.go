@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Core().V1().Services()
-	return context.WithValue(ctx, service.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, service.Key{}, inf), injection.LazyInformer(ctx, service.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Core().V1().ServiceAccounts()
-	return context.WithValue(ctx, serviceaccount.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, serviceaccount.Key{}, inf), injection.LazyInformer(ctx, serviceaccount.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
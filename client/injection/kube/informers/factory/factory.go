@@ -37,10 +37,13 @@ type Key struct{}
 
 func withInformerFactory(ctx context.Context) context.Context {
 	c := client.Get(ctx)
-	opts := make([]informers.SharedInformerOption, 0, 1)
+	opts := make([]informers.SharedInformerOption, 0, 2)
 	if injection.HasNamespaceScope(ctx) {
 		opts = append(opts, informers.WithNamespace(injection.GetNamespaceScope(ctx)))
 	}
+	if resyncs := controller.GetResyncPeriods(ctx); len(resyncs) > 0 {
+		opts = append(opts, informers.WithCustomResyncConfig(resyncs))
+	}
 	return context.WithValue(ctx, Key{},
 		informers.NewSharedInformerFactoryWithOptions(c, controller.GetResyncPeriod(ctx), opts...))
 }
@@ -36,10 +36,13 @@ func init() {
 
 func withInformerFactory(ctx context.Context) context.Context {
 	c := fake.Get(ctx)
-	opts := make([]informers.SharedInformerOption, 0, 1)
+	opts := make([]informers.SharedInformerOption, 0, 2)
 	if injection.HasNamespaceScope(ctx) {
 		opts = append(opts, informers.WithNamespace(injection.GetNamespaceScope(ctx)))
 	}
+	if resyncs := controller.GetResyncPeriods(ctx); len(resyncs) > 0 {
+		opts = append(opts, informers.WithCustomResyncConfig(resyncs))
+	}
 	return context.WithValue(ctx, factory.Key{},
 		informers.NewSharedInformerFactoryWithOptions(c, controller.GetResyncPeriod(ctx), opts...))
 }
@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Rbac().V1().ClusterRoles()
-	return context.WithValue(ctx, clusterrole.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, clusterrole.Key{}, inf), injection.LazyInformer(ctx, clusterrole.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Rbac().V1().ClusterRoleBindings()
-	return context.WithValue(ctx, clusterrolebinding.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, clusterrolebinding.Key{}, inf), injection.LazyInformer(ctx, clusterrolebinding.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Rbac().V1().Roles()
-	return context.WithValue(ctx, role.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, role.Key{}, inf), injection.LazyInformer(ctx, role.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
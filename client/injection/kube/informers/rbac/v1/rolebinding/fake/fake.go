@@ -36,5 +36,7 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, controller.Informer) {
 	f := fake.Get(ctx)
 	inf := f.Rbac().V1().RoleBindings()
-	return context.WithValue(ctx, rolebinding.Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, rolebinding.Key{}, inf), injection.LazyInformer(ctx, rolebinding.Key{}, func() controller.Informer {
+		return inf.Informer()
+	})
 }
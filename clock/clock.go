@@ -0,0 +1,47 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package clock injects the clock time-dependent code reads the time from
+// and waits with through the context, so that tests can replace it with a
+// fake one instead of sleeping.
+package clock
+
+import (
+	"context"
+
+	k8sclock "k8s.io/apimachinery/pkg/util/clock"
+)
+
+// Clock is the clock interface of Kubernetes, so that the clocks of this
+// package can be passed to the Kubernetes libraries accepting one.
+type Clock = k8sclock.Clock
+
+// clockKey is used as the key for associating a Clock with a context.
+type clockKey struct{}
+
+// WithClock returns a copy of the context with the given Clock attached.
+func WithClock(ctx context.Context, c Clock) context.Context {
+	return context.WithValue(ctx, clockKey{}, c)
+}
+
+// FromContext returns the Clock attached to the context, or the wall clock
+// if there is none.
+func FromContext(ctx context.Context) Clock {
+	if c, ok := ctx.Value(clockKey{}).(Clock); ok {
+		return c
+	}
+	return k8sclock.RealClock{}
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	k8sclock "k8s.io/apimachinery/pkg/util/clock"
+)
+
+var epoch = time.Date(2019, time.November, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFromContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()).(k8sclock.RealClock); !ok {
+		t.Errorf("FromContext() = %T, want the wall clock by default", FromContext(context.Background()))
+	}
+
+	f := NewFake(epoch)
+	if got := FromContext(WithClock(context.Background(), f)); got != f {
+		t.Errorf("FromContext() = %v, want %v", got, f)
+	}
+}
+
+func TestFakeAutoAdvance(t *testing.T) {
+	f := NewAutoAdvancingFake(epoch)
+
+	select {
+	case got := <-f.After(time.Hour):
+		if want := epoch.Add(time.Hour); !got.Equal(want) {
+			t.Errorf("After() fired at %v, want %v", got, want)
+		}
+	default:
+		t.Fatal("After() didn't fire right away")
+	}
+
+	timer := f.NewTimer(time.Minute)
+	select {
+	case <-timer.C():
+	default:
+		t.Fatal("NewTimer() didn't fire right away")
+	}
+
+	f.Sleep(time.Second)
+	if got, want := f.Now(), epoch.Add(time.Hour+time.Minute+time.Second); !got.Equal(want) {
+		t.Errorf("Now() = %v, want %v", got, want)
+	}
+}
+
+func TestFakeManual(t *testing.T) {
+	f := NewFake(epoch)
+	ch := f.After(time.Minute)
+	select {
+	case <-ch:
+		t.Fatal("After() fired without the clock being stepped")
+	default:
+	}
+
+	f.Step(time.Minute)
+	select {
+	case <-ch:
+	default:
+		t.Fatal("After() didn't fire after the clock has been stepped")
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clock
+
+import (
+	"sync/atomic"
+	"time"
+
+	k8sclock "k8s.io/apimachinery/pkg/util/clock"
+)
+
+// Fake is a Clock whose time only changes when it is stepped. With
+// auto-advancing enabled, waiting for a duration through After, NewTimer
+// or Sleep steps the clock by that duration right away, so that code
+// waiting for timeouts or backoffs runs through without sleeping.
+// Tickers are never auto-advanced.
+type Fake struct {
+	*k8sclock.FakeClock
+
+	autoAdvance int32
+}
+
+var _ Clock = (*Fake)(nil)
+
+// NewFake returns a Fake set to the given time, which doesn't auto-advance.
+func NewFake(t time.Time) *Fake {
+	return &Fake{FakeClock: k8sclock.NewFakeClock(t)}
+}
+
+// NewAutoAdvancingFake returns a Fake set to the given time, which
+// auto-advances.
+func NewAutoAdvancingFake(t time.Time) *Fake {
+	f := NewFake(t)
+	f.SetAutoAdvance(true)
+	return f
+}
+
+// SetAutoAdvance enables or disables auto-advancing.
+func (f *Fake) SetAutoAdvance(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&f.autoAdvance, v)
+}
+
+func (f *Fake) autoAdvancing() bool {
+	return atomic.LoadInt32(&f.autoAdvance) == 1
+}
+
+// After implements Clock.
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	ch := f.FakeClock.After(d)
+	if f.autoAdvancing() {
+		f.Step(d)
+	}
+	return ch
+}
+
+// NewTimer implements Clock.
+func (f *Fake) NewTimer(d time.Duration) k8sclock.Timer {
+	t := f.FakeClock.NewTimer(d)
+	if f.autoAdvancing() {
+		f.Step(d)
+	}
+	return t
+}
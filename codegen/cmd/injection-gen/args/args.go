@@ -18,21 +18,45 @@ package args
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/spf13/pflag"
 	"k8s.io/gengo/args"
 )
 
+// The kinds of informers the generator can produce.
+const (
+	// TypedInformers are informers built on the versioned clientset and
+	// its shared informer factory.
+	TypedInformers = "typed"
+	// DynamicInformers are informers built on the dynamic client, which
+	// yield unstructured objects.
+	DynamicInformers = "dynamic"
+	// MetadataInformers are informers built on the metadata client, which
+	// only yield the objects' metadata.
+	MetadataInformers = "metadata"
+)
+
 // CustomArgs is used by the gengo framework to pass args specific to this generator.
 type CustomArgs struct {
 	VersionedClientSetPackage        string
 	ExternalVersionsInformersPackage string
+
+	// InformerKinds are the kinds of informers to generate.
+	InformerKinds []string
+	// ForceKinds are the names of the types in the input packages to
+	// generate informers for, even though they are not tagged with
+	// +genclient. This allows generating injection for types owned by
+	// other projects.
+	ForceKinds []string
 }
 
 // NewDefaults returns default arguments for the generator.
 func NewDefaults() (*args.GeneratorArgs, *CustomArgs) {
 	genericArgs := args.Default().WithoutDefaultFlagParsing()
-	customArgs := &CustomArgs{}
+	customArgs := &CustomArgs{
+		InformerKinds: []string{TypedInformers},
+	}
 	genericArgs.CustomArgs = customArgs
 	return genericArgs, customArgs
 }
@@ -41,6 +65,32 @@ func NewDefaults() (*args.GeneratorArgs, *CustomArgs) {
 func (ca *CustomArgs) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&ca.VersionedClientSetPackage, "versioned-clientset-package", ca.VersionedClientSetPackage, "the full package name for the versioned injection clientset to use")
 	fs.StringVar(&ca.ExternalVersionsInformersPackage, "external-versions-informers-package", ca.ExternalVersionsInformersPackage, "the full package name for the external versions injection informer to use")
+	fs.StringSliceVar(&ca.InformerKinds, "informer-kinds", ca.InformerKinds, fmt.Sprintf("the kinds of informers to generate, any of %s", strings.Join(informerKinds, ", ")))
+	fs.StringSliceVar(&ca.ForceKinds, "force-kinds", ca.ForceKinds, "the names of types in the input packages to generate informers for even if they are not tagged with +genclient")
+}
+
+// informerKinds are the valid values of InformerKinds.
+var informerKinds = []string{TypedInformers, DynamicInformers, MetadataInformers}
+
+// Generates returns true if informers of the given kind are requested.
+func (ca *CustomArgs) Generates(kind string) bool {
+	for _, k := range ca.InformerKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Forces returns true if informers are requested for the given type name
+// regardless of its tags.
+func (ca *CustomArgs) Forces(name string) bool {
+	for _, k := range ca.ForceKinds {
+		if k == name {
+			return true
+		}
+	}
+	return false
 }
 
 // Validate checks the given arguments.
@@ -50,6 +100,22 @@ func Validate(genericArgs *args.GeneratorArgs) error {
 	if len(genericArgs.OutputPackagePath) == 0 {
 		return fmt.Errorf("output package cannot be empty")
 	}
+	if len(customArgs.InformerKinds) == 0 {
+		return fmt.Errorf("informer kinds cannot be empty")
+	}
+	for _, kind := range customArgs.InformerKinds {
+		valid := false
+		for _, k := range informerKinds {
+			valid = valid || k == kind
+		}
+		if !valid {
+			return fmt.Errorf("unknown informer kind %q, must be one of %s", kind, strings.Join(informerKinds, ", "))
+		}
+	}
+	if !customArgs.Generates(TypedInformers) {
+		// The clientset and informers packages are only used by typed informers.
+		return nil
+	}
 	if len(customArgs.VersionedClientSetPackage) == 0 {
 		return fmt.Errorf("versioned clientset package cannot be empty")
 	}
@@ -72,6 +72,8 @@ func (g *factoryGenerator) GenerateType(c *generator.Context, t *types.Type, w i
 		"injectionRegisterInformerFactory":             c.Universe.Type(types.Name{Package: "knative.dev/pkg/injection", Name: "Default.RegisterInformerFactory"}),
 		"injectionHasNamespace":                        c.Universe.Type(types.Name{Package: "knative.dev/pkg/injection", Name: "HasNamespaceScope"}),
 		"injectionGetNamespace":                        c.Universe.Type(types.Name{Package: "knative.dev/pkg/injection", Name: "GetNamespaceScope"}),
+		"controllerGetResyncPeriods":                   c.Universe.Type(types.Name{Package: "knative.dev/pkg/controller", Name: "GetResyncPeriods"}),
+		"informersWithCustomResyncConfig":              c.Universe.Function(types.Name{Package: g.sharedInformerFactoryPackage, Name: "WithCustomResyncConfig"}),
 		"controllerGetResyncPeriod":                    c.Universe.Type(types.Name{Package: "knative.dev/pkg/controller", Name: "GetResyncPeriod"}),
 		"loggingFromContext": c.Universe.Function(types.Name{
 			Package: "knative.dev/pkg/logging",
@@ -94,10 +96,13 @@ type Key struct{}
 
 func withInformerFactory(ctx context.Context) context.Context {
 	c := {{.cachingClientGet|raw}}(ctx)
-	opts := make([]{{.informersSharedInformerOption|raw}}, 0, 1)
+	opts := make([]{{.informersSharedInformerOption|raw}}, 0, 2)
 	if {{.injectionHasNamespace|raw}}(ctx) {
 		opts = append(opts, {{.informersWithNamespace|raw}}({{.injectionGetNamespace|raw}}(ctx)))
 	}
+	if resyncs := {{.controllerGetResyncPeriods|raw}}(ctx); len(resyncs) > 0 {
+		opts = append(opts, {{.informersWithCustomResyncConfig|raw}}(resyncs))
+	}
 	return context.WithValue(ctx, Key{},
 		{{.informersNewSharedInformerFactoryWithOptions|raw}}(c, {{.controllerGetResyncPeriod|raw}}(ctx), opts...))
 }
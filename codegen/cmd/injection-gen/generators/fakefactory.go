@@ -76,9 +76,11 @@ func (g *fakeFactoryGenerator) GenerateType(c *generator.Context, t *types.Type,
 			Package: "knative.dev/pkg/injection",
 			Name:    "Fake.RegisterInformerFactory",
 		}),
-		"injectionHasNamespace":     c.Universe.Type(types.Name{Package: "knative.dev/pkg/injection", Name: "HasNamespaceScope"}),
-		"injectionGetNamespace":     c.Universe.Type(types.Name{Package: "knative.dev/pkg/injection", Name: "GetNamespaceScope"}),
-		"controllerGetResyncPeriod": c.Universe.Type(types.Name{Package: "knative.dev/pkg/controller", Name: "GetResyncPeriod"}),
+		"injectionHasNamespace":           c.Universe.Type(types.Name{Package: "knative.dev/pkg/injection", Name: "HasNamespaceScope"}),
+		"injectionGetNamespace":           c.Universe.Type(types.Name{Package: "knative.dev/pkg/injection", Name: "GetNamespaceScope"}),
+		"controllerGetResyncPeriods":      c.Universe.Type(types.Name{Package: "knative.dev/pkg/controller", Name: "GetResyncPeriods"}),
+		"informersWithCustomResyncConfig": c.Universe.Function(types.Name{Package: g.sharedInformerFactoryPackage, Name: "WithCustomResyncConfig"}),
+		"controllerGetResyncPeriod":       c.Universe.Type(types.Name{Package: "knative.dev/pkg/controller", Name: "GetResyncPeriod"}),
 	}
 
 	sw.Do(injectionFakeInformerFactory, m)
@@ -95,10 +97,13 @@ func init() {
 
 func withInformerFactory(ctx context.Context) context.Context {
 	c := {{.clientGet|raw}}(ctx)
-	opts := make([]{{.informersSharedInformerOption|raw}}, 0, 1)
+	opts := make([]{{.informersSharedInformerOption|raw}}, 0, 2)
 	if {{.injectionHasNamespace|raw}}(ctx) {
 		opts = append(opts, {{.informersWithNamespace|raw}}({{.injectionGetNamespace|raw}}(ctx)))
 	}
+	if resyncs := {{.controllerGetResyncPeriods|raw}}(ctx); len(resyncs) > 0 {
+		opts = append(opts, {{.informersWithCustomResyncConfig|raw}}(resyncs))
+	}
 	return context.WithValue(ctx, {{.factoryKey|raw}}{},
 		{{.informersNewSharedInformerFactoryWithOptions|raw}}(c, {{.controllerGetResyncPeriod|raw}}(ctx), opts...))
 }
@@ -88,6 +88,10 @@ func (g *fakeInformerGenerator) GenerateType(c *generator.Context, t *types.Type
 		"type":               t,
 		"version":            namer.IC(g.groupVersion.Version.String()),
 		"controllerInformer": c.Universe.Type(types.Name{Package: "knative.dev/pkg/controller", Name: "Informer"}),
+		"injectionLazyInformer": c.Universe.Function(types.Name{
+			Package: "knative.dev/pkg/injection",
+			Name:    "LazyInformer",
+		}),
 		"injectionRegisterInformer": c.Universe.Function(types.Name{
 			Package: "knative.dev/pkg/injection",
 			Name:    "Fake.RegisterInformer",
@@ -109,6 +113,8 @@ func init() {
 func withInformer(ctx context.Context) (context.Context, {{.controllerInformer|raw}}) {
 	f := {{.factoryGet|raw}}(ctx)
 	inf := f.{{.group}}().{{.version}}().{{.type|publicPlural}}()
-	return context.WithValue(ctx, {{.informerKey|raw}}{}, inf), inf.Informer()
+	return context.WithValue(ctx, {{.informerKey|raw}}{}, inf), {{.injectionLazyInformer|raw}}(ctx, {{.informerKey|raw}}{}, func() {{.controllerInformer|raw}} {
+		return inf.Informer()
+	})
 }
 `
@@ -83,6 +83,8 @@ func (g *injectionGenerator) GenerateType(c *generator.Context, t *types.Type, w
 		"type":                      t,
 		"version":                   namer.IC(g.groupVersion.Version.String()),
 		"injectionRegisterInformer": c.Universe.Type(types.Name{Package: "knative.dev/pkg/injection", Name: "Default.RegisterInformer"}),
+		"injectionLazyInformer":     c.Universe.Function(types.Name{Package: "knative.dev/pkg/injection", Name: "LazyInformer"}),
+		"injectionRetrieved":        c.Universe.Function(types.Name{Package: "knative.dev/pkg/injection", Name: "InformerRetrieved"}),
 		"controllerInformer":        c.Universe.Type(types.Name{Package: "knative.dev/pkg/controller", Name: "Informer"}),
 		"informersTypedInformer":    c.Universe.Type(types.Name{Package: g.typedInformerPackage, Name: t.Name.Name + "Informer"}),
 		"factoryGet":                c.Universe.Type(types.Name{Package: g.groupInformerFactoryPackage, Name: "Get"}),
@@ -108,11 +110,14 @@ type Key struct{}
 func withInformer(ctx context.Context) (context.Context, {{.controllerInformer|raw}}) {
 	f := {{.factoryGet|raw}}(ctx)
 	inf := f.{{.group}}().{{.version}}().{{.type|publicPlural}}()
-	return context.WithValue(ctx, Key{}, inf), inf.Informer()
+	return context.WithValue(ctx, Key{}, inf), {{.injectionLazyInformer|raw}}(ctx, Key{}, func() {{.controllerInformer|raw}} {
+		return inf.Informer()
+	})
 }
 
 // Get extracts the typed informer from the context.
 func Get(ctx context.Context) {{.informersTypedInformer|raw}} {
+	{{.injectionRetrieved|raw}}(ctx, Key{})
 	untyped := ctx.Value(Key{})
 	if untyped == nil {
 		{{.loggingFromContext|raw}}(ctx).Panic(
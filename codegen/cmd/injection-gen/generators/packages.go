@@ -55,7 +55,7 @@ func Packages(context *generator.Context, arguments *args.GeneratorArgs) generat
 	for _, inputDir := range arguments.InputDirs {
 		p := context.Universe.Package(vendorless(inputDir))
 
-		objectMeta, _, err := objectMetaForPackage(p) // TODO: ignoring internal.
+		objectMeta, _, err := objectMetaForPackage(p, customArgs) // TODO: ignoring internal.
 		if err != nil {
 			klog.Fatal(err)
 		}
@@ -89,16 +89,17 @@ func Packages(context *generator.Context, arguments *args.GeneratorArgs) generat
 			groupGoNames[groupPackageName] = namer.IC(override[0])
 		}
 
-		// Generate the client and fake.
-		packageList = append(packageList, versionClientsPackages(versionPackagePath, boilerplate, customArgs)...)
+		if customArgs.Generates(informergenargs.TypedInformers) {
+			// Generate the client and fake.
+			packageList = append(packageList, versionClientsPackages(versionPackagePath, boilerplate, customArgs)...)
 
-		// Generate the informer factory and fake.
-		packageList = append(packageList, versionFactoryPackages(versionPackagePath, boilerplate, customArgs)...)
+			// Generate the informer factory and fake.
+			packageList = append(packageList, versionFactoryPackages(versionPackagePath, boilerplate, customArgs)...)
+		}
 
 		var typesToGenerate []*types.Type
 		for _, t := range p.Types {
-			tags := util.MustParseClientGenTags(append(t.SecondClosestCommentLines, t.CommentLines...))
-			if !tags.GenerateClient || tags.NoVerbs || !tags.HasVerb("list") || !tags.HasVerb("watch") {
+			if !generatesInformer(t, customArgs) {
 				continue
 			}
 
@@ -126,18 +127,37 @@ func Packages(context *generator.Context, arguments *args.GeneratorArgs) generat
 		orderer := namer.Orderer{Namer: namer.NewPrivateNamer(0)}
 		typesToGenerate = orderer.OrderTypes(typesToGenerate)
 
-		// Generate the informer and fake, for each type.
-		packageList = append(packageList, versionInformerPackages(versionPackagePath, groupPackageName, gv, groupGoNames[groupPackageName], boilerplate, typesToGenerate, customArgs)...)
+		if customArgs.Generates(informergenargs.TypedInformers) {
+			// Generate the informer and fake, for each type.
+			packageList = append(packageList, versionInformerPackages(versionPackagePath, groupPackageName, gv, groupGoNames[groupPackageName], boilerplate, typesToGenerate, customArgs)...)
+		}
+		for _, kind := range []string{informergenargs.DynamicInformers, informergenargs.MetadataInformers} {
+			if customArgs.Generates(kind) {
+				// Generate the untyped informer and fake, for each type.
+				packageList = append(packageList, untypedInformerPackages(kind, versionPackagePath, groupPackageName, gv, boilerplate, typesToGenerate, customArgs)...)
+			}
+		}
 	}
 
 	return packageList
 }
 
+// generatesInformer returns true if informers are generated for type t,
+// either because it's tagged with +genclient and supports listing and
+// watching, or because it's forced to.
+func generatesInformer(t *types.Type, customArgs *informergenargs.CustomArgs) bool {
+	if customArgs.Forces(t.Name.Name) {
+		return true
+	}
+	tags := util.MustParseClientGenTags(append(t.SecondClosestCommentLines, t.CommentLines...))
+	return tags.GenerateClient && !tags.NoVerbs && tags.HasVerb("list") && tags.HasVerb("watch")
+}
+
 // objectMetaForPackage returns the type of ObjectMeta used by package p.
-func objectMetaForPackage(p *types.Package) (*types.Type, bool, error) {
+func objectMetaForPackage(p *types.Package, customArgs *informergenargs.CustomArgs) (*types.Type, bool, error) {
 	generatingForPackage := false
 	for _, t := range p.Types {
-		if !util.MustParseClientGenTags(append(t.SecondClosestCommentLines, t.CommentLines...)).GenerateClient {
+		if !util.MustParseClientGenTags(append(t.SecondClosestCommentLines, t.CommentLines...)).GenerateClient && !customArgs.Forces(t.Name.Name) {
 			continue
 		}
 		generatingForPackage = true
@@ -193,8 +213,7 @@ func versionClientsPackages(basePackage string, boilerplate []byte, customArgs *
 			return generators
 		},
 		FilterFunc: func(c *generator.Context, t *types.Type) bool {
-			tags := util.MustParseClientGenTags(append(t.SecondClosestCommentLines, t.CommentLines...))
-			return tags.GenerateClient && tags.HasVerb("list") && tags.HasVerb("watch")
+			return generatesInformer(t, customArgs)
 		},
 	})
 
@@ -219,8 +238,7 @@ func versionClientsPackages(basePackage string, boilerplate []byte, customArgs *
 			return generators
 		},
 		FilterFunc: func(c *generator.Context, t *types.Type) bool {
-			tags := util.MustParseClientGenTags(append(t.SecondClosestCommentLines, t.CommentLines...))
-			return tags.GenerateClient && tags.HasVerb("list") && tags.HasVerb("watch")
+			return generatesInformer(t, customArgs)
 		},
 	})
 
@@ -252,8 +270,7 @@ func versionFactoryPackages(basePackage string, boilerplate []byte, customArgs *
 			return generators
 		},
 		FilterFunc: func(c *generator.Context, t *types.Type) bool {
-			tags := util.MustParseClientGenTags(append(t.SecondClosestCommentLines, t.CommentLines...))
-			return tags.GenerateClient && tags.HasVerb("list") && tags.HasVerb("watch")
+			return generatesInformer(t, customArgs)
 		},
 	})
 
@@ -279,8 +296,7 @@ func versionFactoryPackages(basePackage string, boilerplate []byte, customArgs *
 			return generators
 		},
 		FilterFunc: func(c *generator.Context, t *types.Type) bool {
-			tags := util.MustParseClientGenTags(append(t.SecondClosestCommentLines, t.CommentLines...))
-			return tags.GenerateClient && tags.HasVerb("list") && tags.HasVerb("watch")
+			return generatesInformer(t, customArgs)
 		},
 	})
 
@@ -323,8 +339,7 @@ func versionInformerPackages(basePackage string, groupPkgName string, gv clientg
 				return generators
 			},
 			FilterFunc: func(c *generator.Context, t *types.Type) bool {
-				tags := util.MustParseClientGenTags(append(t.SecondClosestCommentLines, t.CommentLines...))
-				return tags.GenerateClient && tags.HasVerb("list") && tags.HasVerb("watch")
+				return generatesInformer(t, customArgs)
 			},
 		})
 
@@ -351,8 +366,73 @@ func versionInformerPackages(basePackage string, groupPkgName string, gv clientg
 				return generators
 			},
 			FilterFunc: func(c *generator.Context, t *types.Type) bool {
-				tags := util.MustParseClientGenTags(append(t.SecondClosestCommentLines, t.CommentLines...))
-				return tags.GenerateClient && tags.HasVerb("list") && tags.HasVerb("watch")
+				return generatesInformer(t, customArgs)
+			},
+		})
+	}
+	return vers
+}
+
+func untypedInformerPackages(kind string, basePackage string, groupPkgName string, gv clientgentypes.GroupVersion, boilerplate []byte, typesToGenerate []*types.Type, customArgs *informergenargs.CustomArgs) []generator.Package {
+	packagePath := filepath.Join(basePackage, kind+"informers", groupPkgName, strings.ToLower(gv.Version.NonEmpty()))
+
+	vers := make([]generator.Package, 0, len(typesToGenerate))
+
+	for _, t := range typesToGenerate {
+		// Fix for golang iterator bug.
+		t := t
+
+		packagePath := packagePath + "/" + strings.ToLower(t.Name.Name)
+
+		// Impl
+		vers = append(vers, &generator.DefaultPackage{
+			PackageName: strings.ToLower(t.Name.Name),
+			PackagePath: packagePath,
+			HeaderText:  boilerplate,
+			GeneratorFunc: func(c *generator.Context) (generators []generator.Generator) {
+				// Impl
+				generators = append(generators, &untypedInformerGenerator{
+					DefaultGen: generator.DefaultGen{
+						OptionalName: strings.ToLower(t.Name.Name),
+					},
+					outputPackage:  packagePath,
+					kind:           kind,
+					groupVersion:   gv,
+					typeToGenerate: t,
+					imports:        generator.NewImportTracker(),
+				})
+
+				return generators
+			},
+			FilterFunc: func(c *generator.Context, t *types.Type) bool {
+				return generatesInformer(t, customArgs)
+			},
+		})
+
+		// Fake
+		vers = append(vers, &generator.DefaultPackage{
+			PackageName: "fake",
+			PackagePath: packagePath + "/fake",
+			HeaderText:  boilerplate,
+			GeneratorFunc: func(c *generator.Context) (generators []generator.Generator) {
+				// Impl
+				generators = append(generators, &untypedInformerGenerator{
+					DefaultGen: generator.DefaultGen{
+						OptionalName: "fake",
+					},
+					outputPackage:        packagePath + "/fake",
+					kind:                 kind,
+					groupVersion:         gv,
+					typeToGenerate:       t,
+					imports:              generator.NewImportTracker(),
+					fake:                 true,
+					informerInjectionPkg: packagePath,
+				})
+
+				return generators
+			},
+			FilterFunc: func(c *generator.Context, t *types.Type) bool {
+				return generatesInformer(t, customArgs)
 			},
 		})
 	}
@@ -0,0 +1,157 @@
+/*
+Copyright 2019 The Knative Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generators
+
+import (
+	"io"
+
+	clientgentypes "k8s.io/code-generator/cmd/client-gen/types"
+	"k8s.io/gengo/generator"
+	"k8s.io/gengo/namer"
+	"k8s.io/gengo/types"
+	"k8s.io/klog"
+)
+
+// untypedInformerGenerator produces an injected dynamic or metadata
+// informer, or its fake, for a given GroupVersion and type. These don't
+// need a typed clientset, so they work for types of other projects too.
+type untypedInformerGenerator struct {
+	generator.DefaultGen
+	outputPackage  string
+	kind           string
+	groupVersion   clientgentypes.GroupVersion
+	typeToGenerate *types.Type
+	imports        namer.ImportTracker
+
+	// fake is true when generating the fake of the informer found in
+	// informerInjectionPkg.
+	fake                 bool
+	informerInjectionPkg string
+}
+
+var _ generator.Generator = (*untypedInformerGenerator)(nil)
+
+func (g *untypedInformerGenerator) Filter(c *generator.Context, t *types.Type) bool {
+	// Only process the type for this informer generator.
+	return t == g.typeToGenerate
+}
+
+func (g *untypedInformerGenerator) Namers(c *generator.Context) namer.NameSystems {
+	return namer.NameSystems{
+		"raw":                namer.NewRawNamer(g.outputPackage, g.imports),
+		"allLowercasePlural": namer.NewAllLowercasePluralNamer(map[string]string{"Endpoints": "endpoints"}),
+	}
+}
+
+func (g *untypedInformerGenerator) Imports(c *generator.Context) (imports []string) {
+	imports = append(imports, g.imports.ImportLines()...)
+	return
+}
+
+func (g *untypedInformerGenerator) GenerateType(c *generator.Context, t *types.Type, w io.Writer) error {
+	sw := generator.NewSnippetWriter(w, c, "{{", "}}")
+
+	klog.V(5).Infof("processing type %v", t)
+
+	clientPkg := "knative.dev/pkg/injection/clients/" + g.kind + "client"
+	informerPkg := "knative.dev/pkg/injection/informers/" + g.kind + "informer"
+	group := g.groupVersion.Group.String()
+	if g.groupVersion.Group.NonEmpty() == "core" {
+		group = ""
+	}
+
+	m := map[string]interface{}{
+		"kind":                  g.kind,
+		"type":                  t,
+		"group":                 group,
+		"version":               g.groupVersion.Version.String(),
+		"schemaGVR":             c.Universe.Type(types.Name{Package: "k8s.io/apimachinery/pkg/runtime/schema", Name: "GroupVersionResource"}),
+		"genericInformer":       c.Universe.Type(types.Name{Package: "k8s.io/client-go/informers", Name: "GenericInformer"}),
+		"newInformer":           c.Universe.Function(types.Name{Package: informerPkg, Name: "NewInformer"}),
+		"clientGet":             c.Universe.Function(types.Name{Package: clientPkg, Name: "Get"}),
+		"controllerInformer":    c.Universe.Type(types.Name{Package: "knative.dev/pkg/controller", Name: "Informer"}),
+		"injectionLazyInformer": c.Universe.Function(types.Name{Package: "knative.dev/pkg/injection", Name: "LazyInformer"}),
+		"injectionRetrieved":    c.Universe.Function(types.Name{Package: "knative.dev/pkg/injection", Name: "InformerRetrieved"}),
+		"loggingFromContext": c.Universe.Function(types.Name{
+			Package: "knative.dev/pkg/logging",
+			Name:    "FromContext",
+		}),
+	}
+
+	if !g.fake {
+		m["injectionRegisterInformer"] = c.Universe.Function(types.Name{Package: "knative.dev/pkg/injection", Name: "Default.RegisterInformer"})
+		sw.Do(untypedInformer, m)
+		return sw.Error()
+	}
+
+	m["injectionRegisterInformer"] = c.Universe.Function(types.Name{Package: "knative.dev/pkg/injection", Name: "Fake.RegisterInformer"})
+	m["clientGet"] = c.Universe.Function(types.Name{Package: clientPkg + "/fake", Name: "Get"})
+	m["informerKey"] = c.Universe.Type(types.Name{Package: g.informerInjectionPkg, Name: "Key"})
+	m["informerGet"] = c.Universe.Function(types.Name{Package: g.informerInjectionPkg, Name: "Get"})
+	m["informerResource"] = c.Universe.Variable(types.Name{Package: g.informerInjectionPkg, Name: "Resource"})
+	sw.Do(untypedFakeInformer, m)
+	return sw.Error()
+}
+
+var untypedInformer = `
+func init() {
+	{{.injectionRegisterInformer|raw}}(withInformer)
+}
+
+// Resource is the resource watched by the informer.
+var Resource = {{.schemaGVR|raw}}{
+	Group:    "{{.group}}",
+	Version:  "{{.version}}",
+	Resource: "{{.type|allLowercasePlural}}",
+}
+
+// Key is used for associating the Informer inside the context.Context.
+type Key struct{}
+
+func withInformer(ctx context.Context) (context.Context, {{.controllerInformer|raw}}) {
+	inf := {{.newInformer|raw}}(ctx, {{.clientGet|raw}}(ctx), Resource)
+	return context.WithValue(ctx, Key{}, inf), {{.injectionLazyInformer|raw}}(ctx, Key{}, func() {{.controllerInformer|raw}} {
+		return inf.Informer()
+	})
+}
+
+// Get extracts the {{.kind}} informer of {{.type|allLowercasePlural}} from the context.
+func Get(ctx context.Context) {{.genericInformer|raw}} {
+	{{.injectionRetrieved|raw}}(ctx, Key{})
+	untyped := ctx.Value(Key{})
+	if untyped == nil {
+		{{.loggingFromContext|raw}}(ctx).Panic(
+			"Unable to fetch {{.kind}} informer of {{.type|allLowercasePlural}} from context.")
+	}
+	return untyped.({{.genericInformer|raw}})
+}
+`
+
+var untypedFakeInformer = `
+var Get = {{.informerGet|raw}}
+
+func init() {
+	{{.injectionRegisterInformer|raw}}(withInformer)
+}
+
+func withInformer(ctx context.Context) (context.Context, {{.controllerInformer|raw}}) {
+	inf := {{.newInformer|raw}}(ctx, {{.clientGet|raw}}(ctx), {{.informerResource|raw}})
+	return context.WithValue(ctx, {{.informerKey|raw}}{}, inf), {{.injectionLazyInformer|raw}}(ctx, {{.informerKey|raw}}{}, func() {{.controllerInformer|raw}} {
+		return inf.Informer()
+	})
+}
+`
@@ -0,0 +1,151 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmap
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// DiffObserver is the signature of the callbacks that notify an observer of a
+// change of a particular configuration, with both its previous and its new
+// state and the key-level difference between them. The previous state is nil
+// the first time the observer is called. Like Observer, it should not modify
+// the provided ConfigMaps.
+type DiffObserver func(old, new *corev1.ConfigMap, diff Diff)
+
+// Change is the change of the value of a single key.
+type Change struct {
+	Old string
+	New string
+}
+
+// Diff is the key-level difference between the data of two versions of
+// a ConfigMap.
+type Diff struct {
+	// Added holds the values of the keys which were added.
+	Added map[string]string
+	// Removed holds the former values of the keys which were removed.
+	Removed map[string]string
+	// Changed holds the changes of the keys whose value changed.
+	Changed map[string]Change
+}
+
+// ComputeDiff returns the difference between the data of the old and the
+// new ConfigMap, either of which can be nil.
+func ComputeDiff(old, new *corev1.ConfigMap) Diff {
+	var oldData, newData map[string]string
+	if old != nil {
+		oldData = old.Data
+	}
+	if new != nil {
+		newData = new.Data
+	}
+
+	diff := Diff{
+		Added:   make(map[string]string),
+		Removed: make(map[string]string),
+		Changed: make(map[string]Change),
+	}
+	for k, v := range newData {
+		ov, ok := oldData[k]
+		switch {
+		case !ok:
+			diff.Added[k] = v
+		case ov != v:
+			diff.Changed[k] = Change{Old: ov, New: v}
+		}
+	}
+	for k, v := range oldData {
+		if _, ok := newData[k]; !ok {
+			diff.Removed[k] = v
+		}
+	}
+	return diff
+}
+
+// Empty returns true if no key was added, removed or changed.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Keys returns the keys which were added, removed or changed.
+func (d Diff) Keys() sets.String {
+	keys := sets.NewString()
+	for k := range d.Added {
+		keys.Insert(k)
+	}
+	for k := range d.Removed {
+		keys.Insert(k)
+	}
+	for k := range d.Changed {
+		keys.Insert(k)
+	}
+	return keys
+}
+
+// Touches returns true if any of the given keys was added, removed or changed.
+func (d Diff) Touches(keys ...string) bool {
+	return d.Keys().HasAny(keys...)
+}
+
+// String returns a line per key which was added, removed or changed, sorted
+// by key, e.g. `key "foo" changed from "bar" to "baz"`.
+func (d Diff) String() string {
+	lines := make([]string, 0, len(d.Added)+len(d.Removed)+len(d.Changed))
+	for _, k := range d.Keys().List() {
+		if v, ok := d.Added[k]; ok {
+			lines = append(lines, fmt.Sprintf("key %q added with %q", k, v))
+		} else if v, ok := d.Removed[k]; ok {
+			lines = append(lines, fmt.Sprintf("key %q removed, was %q", k, v))
+		} else {
+			c := d.Changed[k]
+			lines = append(lines, fmt.Sprintf("key %q changed from %q to %q", k, c.Old, c.New))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ObserveDiff returns an Observer calling the given DiffObserver with the
+// previously observed ConfigMap and the difference with the new one. The
+// returned Observer must only be registered for a single ConfigMap.
+func ObserveDiff(o DiffObserver) Observer {
+	var (
+		mu   sync.Mutex
+		last *corev1.ConfigMap
+	)
+	return func(cm *corev1.ConfigMap) {
+		mu.Lock()
+		defer mu.Unlock()
+		o(last, cm, ComputeDiff(last, cm))
+		last = cm.DeepCopy()
+	}
+}
+
+// ObserveKeys is like ObserveDiff, but only calls the given DiffObserver
+// the first time and when any of the given keys is added, removed or changed.
+func ObserveKeys(o DiffObserver, keys ...string) Observer {
+	return ObserveDiff(func(old, new *corev1.ConfigMap, diff Diff) {
+		if old == nil || diff.Touches(keys...) {
+			o(old, new, diff)
+		}
+	})
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmap
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func configMapWithData(data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo"},
+		Data:       data,
+	}
+}
+
+func TestComputeDiff(t *testing.T) {
+	tests := []struct {
+		name       string
+		old, new   *corev1.ConfigMap
+		want       Diff
+		wantString string
+	}{{
+		name: "initial",
+		new:  configMapWithData(map[string]string{"a": "1"}),
+		want: Diff{
+			Added:   map[string]string{"a": "1"},
+			Removed: map[string]string{},
+			Changed: map[string]Change{},
+		},
+		wantString: `key "a" added with "1"`,
+	}, {
+		name: "unchanged",
+		old:  configMapWithData(map[string]string{"a": "1"}),
+		new:  configMapWithData(map[string]string{"a": "1"}),
+		want: Diff{
+			Added:   map[string]string{},
+			Removed: map[string]string{},
+			Changed: map[string]Change{},
+		},
+	}, {
+		name: "added, removed and changed",
+		old:  configMapWithData(map[string]string{"a": "1", "b": "2", "c": "3"}),
+		new:  configMapWithData(map[string]string{"a": "1", "b": "20", "d": "4"}),
+		want: Diff{
+			Added:   map[string]string{"d": "4"},
+			Removed: map[string]string{"c": "3"},
+			Changed: map[string]Change{"b": {Old: "2", New: "20"}},
+		},
+		wantString: `key "b" changed from "2" to "20"
+key "c" removed, was "3"
+key "d" added with "4"`,
+	}, {
+		name: "deleted",
+		old:  configMapWithData(map[string]string{"a": "1"}),
+		want: Diff{
+			Added:   map[string]string{},
+			Removed: map[string]string{"a": "1"},
+			Changed: map[string]Change{},
+		},
+		wantString: `key "a" removed, was "1"`,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ComputeDiff(test.old, test.new)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("ComputeDiff (-want, +got): %s", diff)
+			}
+			if got, want := got.Empty(), test.wantString == ""; got != want {
+				t.Errorf("Empty() = %v, want %v", got, want)
+			}
+			if got := got.String(); got != test.wantString {
+				t.Errorf("String() = %q, want %q", got, test.wantString)
+			}
+		})
+	}
+}
+
+type observation struct {
+	old, new *corev1.ConfigMap
+	diff     Diff
+}
+
+func TestObserveDiff(t *testing.T) {
+	watcher := ManualWatcher{Namespace: "default"}
+	var all, filtered []observation
+	watcher.Watch("foo",
+		ObserveDiff(func(old, new *corev1.ConfigMap, diff Diff) {
+			all = append(all, observation{old: old, new: new, diff: diff})
+		}),
+		ObserveKeys(func(old, new *corev1.ConfigMap, diff Diff) {
+			filtered = append(filtered, observation{old: old, new: new, diff: diff})
+		}, "a"))
+
+	v1 := configMapWithData(map[string]string{"a": "1", "b": "1"})
+	v2 := configMapWithData(map[string]string{"a": "1", "b": "2"})
+	v3 := configMapWithData(map[string]string{"a": "3", "b": "2"})
+	for _, cm := range []*corev1.ConfigMap{v1, v2, v3} {
+		watcher.OnChange(cm)
+	}
+
+	if got, want := len(all), 3; got != want {
+		t.Fatalf("len(all) = %d, want %d", got, want)
+	}
+	if all[0].old != nil {
+		t.Errorf("First old = %v, want nil", all[0].old)
+	}
+	if diff := cmp.Diff(v1, all[1].old); diff != "" {
+		t.Errorf("Second old (-want, +got): %s", diff)
+	}
+	if got, want := all[1].diff.Keys().List(), []string{"b"}; !cmp.Equal(got, want) {
+		t.Errorf("Second diff keys = %v, want %v", got, want)
+	}
+
+	// The change of "b" alone is filtered out.
+	if got, want := len(filtered), 2; got != want {
+		t.Fatalf("len(filtered) = %d, want %d", got, want)
+	}
+	if want := map[string]Change{"a": {Old: "1", New: "3"}}; !cmp.Equal(filtered[1].diff.Changed, want) {
+		t.Errorf("Changed = %v, want %v", filtered[1].diff.Changed, want)
+	}
+	if !filtered[1].diff.Touches("a", "c") || filtered[1].diff.Touches("c") {
+		t.Error("Touches() didn't report the changed keys")
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmap
+
+import (
+	"fmt"
+	"hash/crc32"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// ExampleChecksumAnnotation is the annotation holding the checksum of the
+// ConfigMap's example, used to detect edits of the example.
+const ExampleChecksumAnnotation = "knative.dev/example-checksum"
+
+// Checksum returns the checksum of the given value, as expected in the
+// ExampleChecksumAnnotation.
+func Checksum(value string) string {
+	return fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(value)))
+}
+
+// ValidateExampleChecksum returns an error if the ConfigMap has an
+// ExampleChecksumAnnotation not matching its example. Such a mismatch is
+// most likely caused by a user editing the example instead of adding the
+// setting to the data of the ConfigMap. ConfigMaps without the annotation
+// are valid.
+func ValidateExampleChecksum(cm *corev1.ConfigMap) error {
+	want, ok := cm.Annotations[ExampleChecksumAnnotation]
+	if !ok {
+		return nil
+	}
+	if got := Checksum(cm.Data[ExampleKey]); got != want {
+		return fmt.Errorf("the update modifies a key in %q which is probably not what you want. "+
+			"Instead, copy the respective setting to the top-level of the ConfigMap, directly below %q "+
+			"(the %s annotation is %q, but the example's checksum is %q)",
+			ExampleKey, "data", ExampleChecksumAnnotation, want, got)
+	}
+	return nil
+}
+
+// ValidateExampleKeys returns an error describing how the keys documented
+// in the ConfigMap's example differ from the given known keys, if they do.
+func ValidateExampleKeys(cm *corev1.ConfigMap, known ...string) error {
+	documented, err := ExampleKeys(cm)
+	if err != nil {
+		return err
+	}
+	knownSet := sets.NewString(known...)
+
+	var problems []string
+	if missing := knownSet.Difference(documented); missing.Len() > 0 {
+		problems = append(problems, fmt.Sprintf("add %v to the example", missing.List()))
+	}
+	if unknown := documented.Difference(knownSet); unknown.Len() > 0 {
+		problems = append(problems, fmt.Sprintf("remove %v from the example, or teach the config about them", unknown.List()))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("the example of ConfigMap %q drifted from its keys: %s", cm.Name, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ExampleKeys returns the keys documented in the ConfigMap's example.
+func ExampleKeys(cm *corev1.ConfigMap) (sets.String, error) {
+	var example map[string]interface{}
+	if err := yaml.Unmarshal([]byte(cm.Data[ExampleKey]), &example); err != nil {
+		return nil, fmt.Errorf("failed to parse %q of ConfigMap %q: %w", ExampleKey, cm.Name, err)
+	}
+	keys := sets.NewString()
+	for k := range example {
+		keys.Insert(k)
+	}
+	return keys, nil
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmap
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testExample = `
+# The timeout of things.
+timeout: 5s
+
+# Whether to do stuff.
+enabled: "true"
+`
+
+func exampleConfigMap(example string, annotations map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "config-test",
+			Annotations: annotations,
+		},
+		Data: map[string]string{
+			ExampleKey: example,
+		},
+	}
+}
+
+func TestValidateExampleChecksum(t *testing.T) {
+	tests := []struct {
+		name    string
+		cm      *corev1.ConfigMap
+		wantErr bool
+	}{{
+		name: "no annotation",
+		cm:   exampleConfigMap(testExample, nil),
+	}, {
+		name: "matching checksum",
+		cm: exampleConfigMap(testExample, map[string]string{
+			ExampleChecksumAnnotation: Checksum(testExample),
+		}),
+	}, {
+		name: "edited example",
+		cm: exampleConfigMap(testExample+"foo: bar\n", map[string]string{
+			ExampleChecksumAnnotation: Checksum(testExample),
+		}),
+		wantErr: true,
+	}, {
+		name: "removed example",
+		cm: exampleConfigMap("", map[string]string{
+			ExampleChecksumAnnotation: Checksum(testExample),
+		}),
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateExampleChecksum(test.cm)
+			if (err != nil) != test.wantErr {
+				t.Errorf("ValidateExampleChecksum() = %v, wantErr = %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	if got, want := Checksum(""), "00000000"; got != want {
+		t.Errorf("Checksum() = %q, want %q", got, want)
+	}
+	if Checksum(testExample) == Checksum(testExample+" ") {
+		t.Error("Checksum() is the same for different values")
+	}
+}
+
+func TestValidateExampleKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		example string
+		known   []string
+		wantErr []string
+	}{{
+		name:    "matching keys",
+		example: testExample,
+		known:   []string{"timeout", "enabled"},
+	}, {
+		name:    "undocumented keys",
+		example: testExample,
+		known:   []string{"timeout", "enabled", "zeta", "alpha"},
+		wantErr: []string{"add [alpha zeta] to the example"},
+	}, {
+		name:    "unknown keys",
+		example: testExample,
+		known:   []string{"timeout"},
+		wantErr: []string{"remove [enabled] from the example"},
+	}, {
+		name:    "both",
+		example: testExample,
+		known:   []string{"timeout", "other"},
+		wantErr: []string{"add [other]", "remove [enabled]"},
+	}, {
+		name:    "invalid example",
+		example: "not: [valid",
+		wantErr: []string{`failed to parse "_example"`},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateExampleKeys(exampleConfigMap(test.example, nil), test.known...)
+			if len(test.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("ValidateExampleKeys() = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("ValidateExampleKeys() = nil, wanted an error")
+			}
+			for _, want := range test.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("ValidateExampleKeys() = %v, wanted it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
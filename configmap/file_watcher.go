@@ -0,0 +1,228 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmap
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// dataDirName is the symlink Kubernetes atomically swaps to update the
+// files of a ConfigMap volume.
+const dataDirName = "..data"
+
+// maxTornReads is the number of times reading a ConfigMap volume is retried
+// if it was updated while being read.
+const maxTornReads = 5
+
+// NewFileWatcher returns a FileWatcher reading the ConfigMaps mounted as
+// volumes in the subdirectories of root, named after the ConfigMaps, and
+// checking them for changes in the given interval.
+func NewFileWatcher(root, namespace string, interval time.Duration) *FileWatcher {
+	return &FileWatcher{
+		root:     root,
+		interval: interval,
+		observed: make(map[string]map[string]string),
+		ManualWatcher: ManualWatcher{
+			Namespace: namespace,
+		},
+	}
+}
+
+// FileWatcher provides an implementation of Watcher reading ConfigMaps
+// mounted as volumes, for components that can't list and watch ConfigMaps.
+// Observers are called with ConfigMaps carrying the watcher's namespace,
+// the directory's name and the files' contents. Observers can be added after
+// Start too.
+type FileWatcher struct {
+	root     string
+	interval time.Duration
+	started  bool
+
+	// observed holds the data last handed to the observers by name.
+	// observedMu guards it, and serializes the calls to the observers so
+	// that the ones added after Start don't miss or reorder updates.
+	observedMu sync.Mutex
+	observed   map[string]map[string]string
+
+	// Embedding this struct allows us to reuse the logic
+	// of registering and notifying observers.
+	ManualWatcher
+}
+
+// Asserts that FileWatcher implements Watcher.
+var _ Watcher = (*FileWatcher)(nil)
+
+// Watch implements Watcher. The observers added after Start are called with
+// the current data of the ConfigMap right away, if it has been read.
+func (w *FileWatcher) Watch(name string, o ...Observer) {
+	w.observedMu.Lock()
+	defer w.observedMu.Unlock()
+	w.ManualWatcher.Watch(name, o...)
+
+	w.m.RLock()
+	started := w.started
+	w.m.RUnlock()
+	if !started {
+		return
+	}
+	data, ok := w.observed[name]
+	if !ok {
+		// Errors are transient, the ConfigMap is read again with the
+		// next check.
+		w.checkLocked(name)
+		return
+	}
+	cm := w.configMap(name, data)
+	for _, observer := range o {
+		observer(cm)
+	}
+}
+
+// names returns the names of the ConfigMaps watched.
+func (w *FileWatcher) names() []string {
+	w.m.RLock()
+	defer w.m.RUnlock()
+	names := make([]string, 0, len(w.observers))
+	for name := range w.observers {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Start implements Watcher.
+func (w *FileWatcher) Start(stopCh <-chan struct{}) error {
+	w.m.Lock()
+	if w.started {
+		w.m.Unlock()
+		return errors.New("watcher already started")
+	}
+	w.started = true
+	w.m.Unlock()
+
+	for _, name := range w.names() {
+		if err := w.check(name); err != nil {
+			return err
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// The observers added since are checked too.
+				for _, name := range w.names() {
+					// Errors are transient, e.g. a volume being updated,
+					// the last observed data stays in place until then.
+					w.check(name)
+				}
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// check reads the named ConfigMap and notifies the observers if it changed.
+func (w *FileWatcher) check(name string) error {
+	w.observedMu.Lock()
+	defer w.observedMu.Unlock()
+	return w.checkLocked(name)
+}
+
+// checkLocked is check, with observedMu held.
+func (w *FileWatcher) checkLocked(name string) error {
+	data, err := readConfigMapDir(filepath.Join(w.root, name))
+	if err != nil {
+		return err
+	}
+	if old, ok := w.observed[name]; ok && reflect.DeepEqual(old, data) {
+		return nil
+	}
+	w.observed[name] = data
+	w.OnChange(w.configMap(name, data))
+	return nil
+}
+
+// configMap returns the ConfigMap with the given name and data.
+func (w *FileWatcher) configMap(name string, data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: w.Namespace,
+			Name:      name,
+		},
+		Data: data,
+	}
+}
+
+// readConfigMapDir reads the files of a ConfigMap volume, retrying if the
+// volume has been updated while reading it.
+func readConfigMapDir(dir string) (map[string]string, error) {
+	for i := 0; i < maxTornReads; i++ {
+		before, _ := os.Readlink(filepath.Join(dir, dataDirName))
+		data, err := readFiles(dir)
+		after, _ := os.Readlink(filepath.Join(dir, dataDirName))
+		if before != after {
+			continue
+		}
+		return data, err
+	}
+	return nil, fmt.Errorf("%s kept changing while being read", dir)
+}
+
+// readFiles reads all files in the directory, skipping the entries
+// Kubernetes uses to implement atomic updates.
+func readFiles(dir string) (map[string]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "..") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		// Follow the symlinks into the data directory.
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			continue
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		data[entry.Name()] = string(b)
+	}
+	return data, nil
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmap
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// writeVolume lays out the data the way the kubelet does for ConfigMap
+// volumes: the files live in a versioned directory, which the ..data
+// symlink is atomically swapped to, and the keys link into ..data.
+func writeVolume(t *testing.T, dir string, version int, data map[string]string) {
+	t.Helper()
+	versioned := "..v" + strconv.Itoa(version)
+	if err := os.MkdirAll(filepath.Join(dir, versioned), 0755); err != nil {
+		t.Fatal("MkdirAll() =", err)
+	}
+	for k, v := range data {
+		if err := ioutil.WriteFile(filepath.Join(dir, versioned, k), []byte(v), 0644); err != nil {
+			t.Fatal("WriteFile() =", err)
+		}
+		link := filepath.Join(dir, k)
+		if _, err := os.Lstat(link); os.IsNotExist(err) {
+			if err := os.Symlink(filepath.Join(dataDirName, k), link); err != nil {
+				t.Fatal("Symlink() =", err)
+			}
+		}
+	}
+	tmp := filepath.Join(dir, "..data_tmp")
+	if err := os.Symlink(versioned, tmp); err != nil {
+		t.Fatal("Symlink() =", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, dataDirName)); err != nil {
+		t.Fatal("Rename() =", err)
+	}
+}
+
+func TestFileWatcher(t *testing.T) {
+	root, err := ioutil.TempDir("", "file-watcher")
+	if err != nil {
+		t.Fatal("TempDir() =", err)
+	}
+	defer os.RemoveAll(root)
+	writeVolume(t, filepath.Join(root, "foo"), 1, map[string]string{"key": "val"})
+
+	w := NewFileWatcher(root, "default", 10*time.Millisecond)
+	foo := &counter{name: "foo"}
+	w.Watch("foo", foo.callback)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	if err := w.Start(stopCh); err != nil {
+		t.Fatalf("w.Start() = %v", err)
+	}
+
+	// When Start returns the callbacks should have been called with the
+	// data that is available.
+	if got, want := foo.count(), 1; got != want {
+		t.Fatalf("foo.count = %v, want %v", got, want)
+	}
+	if got, want := foo.cfg[0].Data, map[string]string{"key": "val"}; !cmp.Equal(got, want) {
+		t.Errorf("Observed data = %v, want %v", got, want)
+	}
+	if got, want := foo.cfg[0].Namespace, "default"; got != want {
+		t.Errorf("Observed namespace = %v, want %v", got, want)
+	}
+
+	// An update swapping the data directory is observed once.
+	foo.mu.Lock()
+	foo.wg = &sync.WaitGroup{}
+	foo.mu.Unlock()
+	foo.wg.Add(1)
+	writeVolume(t, filepath.Join(root, "foo"), 2, map[string]string{"key": "new", "other": "val"})
+	foo.wg.Wait()
+
+	// Give the watcher time to (wrongly) observe the same data again.
+	time.Sleep(50 * time.Millisecond)
+	if got, want := foo.count(), 2; got != want {
+		t.Fatalf("foo.count = %v, want %v", got, want)
+	}
+	if got, want := foo.cfg[1].Data, map[string]string{"key": "new", "other": "val"}; !cmp.Equal(got, want) {
+		t.Errorf("Observed data = %v, want %v", got, want)
+	}
+
+	// This should error because we already called Start()
+	if err := w.Start(stopCh); err == nil {
+		t.Error("w.Start() succeeded, wanted error")
+	}
+}
+
+func TestFileWatcherWatchAfterStart(t *testing.T) {
+	root, err := ioutil.TempDir("", "file-watcher")
+	if err != nil {
+		t.Fatal("TempDir() =", err)
+	}
+	defer os.RemoveAll(root)
+	writeVolume(t, filepath.Join(root, "foo"), 1, map[string]string{"key": "val"})
+	writeVolume(t, filepath.Join(root, "bar"), 1, map[string]string{"key": "val"})
+
+	w := NewFileWatcher(root, "default", 10*time.Millisecond)
+	foo := &counter{name: "foo"}
+	w.Watch("foo", foo.callback)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	if err := w.Start(stopCh); err != nil {
+		t.Fatalf("w.Start() = %v", err)
+	}
+
+	// The observers added once started are called with the current data,
+	// whether the ConfigMap was watched before or not.
+	lateFoo := &counter{name: "foo"}
+	w.Watch("foo", lateFoo.callback)
+	bar := &counter{name: "bar"}
+	w.Watch("bar", bar.callback)
+	for _, c := range []*counter{lateFoo, bar} {
+		if got, want := c.count(), 1; got != want {
+			t.Fatalf("%s.count = %v, want %v", c.name, got, want)
+		}
+	}
+
+	// And with the updates.
+	bar.mu.Lock()
+	bar.wg = &sync.WaitGroup{}
+	bar.mu.Unlock()
+	bar.wg.Add(1)
+	writeVolume(t, filepath.Join(root, "bar"), 2, map[string]string{"key": "new"})
+	bar.wg.Wait()
+	if got, want := bar.cfg[1].Data, map[string]string{"key": "new"}; !cmp.Equal(got, want) {
+		t.Errorf("Observed data = %v, want %v", got, want)
+	}
+}
+
+func TestFileWatcherPlainFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "file-watcher")
+	if err != nil {
+		t.Fatal("TempDir() =", err)
+	}
+	defer os.RemoveAll(root)
+	os.Mkdir(filepath.Join(root, "foo"), 0755)
+	os.Mkdir(filepath.Join(root, "foo", "subdir"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "foo", "key"), []byte("val"), 0644)
+
+	w := NewFileWatcher(root, "default", time.Hour)
+	foo := &counter{name: "foo"}
+	w.Watch("foo", foo.callback)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	if err := w.Start(stopCh); err != nil {
+		t.Fatalf("w.Start() = %v", err)
+	}
+	if got, want := foo.cfg[0].Data, map[string]string{"key": "val"}; !cmp.Equal(got, want) {
+		t.Errorf("Observed data = %v, want %v", got, want)
+	}
+}
+
+func TestFileWatcherMissingFailsOnStart(t *testing.T) {
+	root, err := ioutil.TempDir("", "file-watcher")
+	if err != nil {
+		t.Fatal("TempDir() =", err)
+	}
+	defer os.RemoveAll(root)
+
+	w := NewFileWatcher(root, "default", time.Hour)
+	w.Watch("foo", (&counter{name: "foo"}).callback)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	// This should error because there is no directory named "foo".
+	if err := w.Start(stopCh); err == nil {
+		t.Fatal("w.Start() succeeded, wanted error")
+	}
+}
@@ -18,10 +18,13 @@ package configmap
 
 import (
 	"errors"
+	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
-	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
 	informers "k8s.io/client-go/informers"
 	corev1informers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
@@ -43,19 +46,60 @@ func NewInformedWatcherFromFactory(sif informers.SharedInformerFactory, namespac
 		ManualWatcher: ManualWatcher{
 			Namespace: namespace,
 		},
-		defaults: make(map[string]*corev1.ConfigMap),
+		defaults:     make(map[string]*corev1.ConfigMap),
+		requirements: make(requirements),
 	}
 }
 
 // NewInformedWatcher watches a Kubernetes namespace for configmap changes.
-func NewInformedWatcher(kc kubernetes.Interface, namespace string) *InformedWatcher {
-	return NewInformedWatcherFromFactory(informers.NewSharedInformerFactoryWithOptions(
+// Optional label requirements restrict the watched ConfigMaps to those
+// matching all of them, so that only the relevant ConfigMaps are cached.
+func NewInformedWatcher(kc kubernetes.Interface, namespace string, lr ...labels.Requirement) *InformedWatcher {
+	w := NewInformedWatcherFromFactory(informers.NewSharedInformerFactoryWithOptions(
 		kc,
 		// We noticed that we're getting updates all the time anyway, due to the
 		// watches being terminated and re-spawned.
 		0,
 		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(addLabelRequirementsToListOptions(lr)),
 	), namespace)
+	w.labelRequirements = lr
+	return w
+}
+
+// addLabelRequirementsToListOptions returns a function which adds the given
+// label requirements to the selector of the ListOptions it's called with.
+func addLabelRequirementsToListOptions(lr []labels.Requirement) func(*metav1.ListOptions) {
+	if len(lr) == 0 {
+		return nil
+	}
+	return func(lo *metav1.ListOptions) {
+		sel, err := labels.Parse(lo.LabelSelector)
+		if err != nil {
+			panic(fmt.Sprintf("could not parse label selector %q: %v", lo.LabelSelector, err))
+		}
+		lo.LabelSelector = sel.Add(lr...).String()
+	}
+}
+
+// FilterConfigByLabelExists returns a label requirement for NewInformedWatcher
+// selecting the ConfigMaps which have the given label, regardless of its value.
+func FilterConfigByLabelExists(label string) (*labels.Requirement, error) {
+	req, err := labels.NewRequirement(label, selection.Exists, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %q label requirement: %w", label, err)
+	}
+	return req, nil
+}
+
+// FilterConfigByLabelEquals returns a label requirement for NewInformedWatcher
+// selecting the ConfigMaps which have the given label set to value.
+func FilterConfigByLabelEquals(label, value string) (*labels.Requirement, error) {
+	req, err := labels.NewRequirement(label, selection.Equals, []string{value})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %q label requirement: %w", label, err)
+	}
+	return req, nil
 }
 
 // InformedWatcher provides an informer-based implementation of Watcher.
@@ -67,6 +111,12 @@ type InformedWatcher struct {
 	// defaults are the default ConfigMaps to use if the real ones do not exist or are deleted.
 	defaults map[string]*corev1.ConfigMap
 
+	// labelRequirements are the label requirements the watched ConfigMaps are filtered by.
+	labelRequirements []labels.Requirement
+
+	// requirements are the keys of the ConfigMaps required at Start.
+	requirements requirements
+
 	// Embedding this struct allows us to reuse the logic
 	// of registering and notifying observers. This simplifies the
 	// InformedWatcher to just setting up the Kubernetes informer.
@@ -98,6 +148,19 @@ func (i *InformedWatcher) WatchWithDefault(cm corev1.ConfigMap, o ...Observer) {
 	i.Watch(cm.Name, o...)
 }
 
+// Asserts that InformedWatcher implements RequiringWatcher.
+var _ RequiringWatcher = (*InformedWatcher)(nil)
+
+// Require implements RequiringWatcher.
+func (i *InformedWatcher) Require(reqs ...Requirement) {
+	i.m.Lock()
+	defer i.m.Unlock()
+	if i.started {
+		panic("cannot Require after the InformedWatcher has started")
+	}
+	i.requirements.add(reqs...)
+}
+
 // Start implements Watcher.
 func (i *InformedWatcher) Start(stopCh <-chan struct{}) error {
 	// Pretend that all the defaulted ConfigMaps were just created. This is done before we start
@@ -140,20 +203,29 @@ func (i *InformedWatcher) registerCallbackAndStartInformer(stopCh <-chan struct{
 	return nil
 }
 
+// checkObservedResourcesExist checks that all of the observed and required
+// ConfigMaps exist in our informers, unless they're defaulted, and that they
+// have the required keys. All of those which don't are listed in a single
+// RequirementsError.
 func (i *InformedWatcher) checkObservedResourcesExist() error {
 	i.m.RLock()
 	defer i.m.RUnlock()
-	// Check that all objects with Observers exist in our informers.
+	names := make([]string, 0, len(i.observers))
 	for k := range i.observers {
-		if _, err := i.informer.Lister().ConfigMaps(i.Namespace).Get(k); err != nil {
-			if _, ok := i.defaults[k]; ok && k8serrors.IsNotFound(err) {
-				// It is defaulted, so it is OK that it doesn't exist.
-				continue
-			}
-			return err
-		}
+		names = append(names, k)
 	}
-	return nil
+	missing, err := i.requirements.check(names, i.defaults, i.informer.Lister().ConfigMaps(i.Namespace).Get)
+	if err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	rerr := &RequirementsError{Namespace: i.Namespace, Missing: missing}
+	if len(i.labelRequirements) > 0 {
+		rerr.Selector = labels.NewSelector().Add(i.labelRequirements...).String()
+	}
+	return rerr
 }
 
 func (i *InformedWatcher) addConfigMapEvent(obj interface{}) {
@@ -392,3 +392,81 @@ func TestWatchWithDefaultAfterStart(t *testing.T) {
 		t.Fatalf("foo1.count = %v, want %d", len(foo1.cfg), len(expected))
 	}
 }
+
+func TestFilterConfigByLabel(t *testing.T) {
+	exists, err := FilterConfigByLabelExists("knative.dev/config")
+	if err != nil {
+		t.Fatalf("FilterConfigByLabelExists() = %v", err)
+	}
+	if got, want := exists.String(), "knative.dev/config"; got != want {
+		t.Errorf("FilterConfigByLabelExists() = %q, want %q", got, want)
+	}
+
+	equals, err := FilterConfigByLabelEquals("knative.dev/config", "true")
+	if err != nil {
+		t.Fatalf("FilterConfigByLabelEquals() = %v", err)
+	}
+	if got, want := equals.String(), "knative.dev/config=true"; got != want {
+		t.Errorf("FilterConfigByLabelEquals() = %q, want %q", got, want)
+	}
+
+	if _, err := FilterConfigByLabelExists("not a label"); err == nil {
+		t.Error("FilterConfigByLabelExists() = nil, wanted an error")
+	}
+}
+
+func TestInformedWatcherWithLabels(t *testing.T) {
+	labeled := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "foo",
+			Labels:    map[string]string{"knative.dev/config": "true"},
+		},
+		Data: map[string]string{"key": "val"},
+	}
+	unlabeled := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "bar",
+		},
+		Data: map[string]string{"key2": "val2"},
+	}
+	req, err := FilterConfigByLabelEquals("knative.dev/config", "true")
+	if err != nil {
+		t.Fatalf("FilterConfigByLabelEquals() = %v", err)
+	}
+
+	t.Run("matching", func(t *testing.T) {
+		kc := fakekubeclientset.NewSimpleClientset(labeled, unlabeled)
+		cm := NewInformedWatcher(kc, "default", *req)
+
+		foo := &counter{name: "foo"}
+		cm.Watch("foo", foo.callback)
+
+		stopCh := make(chan struct{})
+		defer close(stopCh)
+		if err := cm.Start(stopCh); err != nil {
+			t.Fatalf("cm.Start() = %v", err)
+		}
+		if got, want := foo.count(), 1; got != want {
+			t.Errorf("foo.count = %v, want %v", got, want)
+		}
+		if _, err := cm.informer.Lister().ConfigMaps("default").Get("bar"); err == nil {
+			t.Error("The unlabeled ConfigMap has been cached")
+		}
+	})
+
+	t.Run("not matching", func(t *testing.T) {
+		kc := fakekubeclientset.NewSimpleClientset(labeled, unlabeled)
+		cm := NewInformedWatcher(kc, "default", *req)
+
+		bar := &counter{name: "bar"}
+		cm.Watch("bar", bar.callback)
+
+		stopCh := make(chan struct{})
+		defer close(stopCh)
+		if err := cm.Start(stopCh); err == nil {
+			t.Fatal("cm.Start() = nil, wanted an error")
+		}
+	})
+}
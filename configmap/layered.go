@@ -0,0 +1,142 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmap
+
+import (
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LayeredConfig merges multiple ConfigMaps, the layers, into a single
+// logical ConfigMap handed to its observers. Layers added later take
+// precedence, e.g. code defaults < cluster-wide ConfigMap < namespace-local
+// override. Whenever a layer changes, the observers are called with the
+// merged ConfigMap, named after the LayeredConfig and carrying the
+// namespace of the highest layer observed so far.
+type LayeredConfig struct {
+	name      string
+	observers []Observer
+
+	m      sync.Mutex
+	layers []*configLayer
+}
+
+type configLayer struct {
+	cm *corev1.ConfigMap
+}
+
+// NewLayeredConfig returns a LayeredConfig without layers, notifying the
+// given observers of the merged ConfigMap with the given name.
+func NewLayeredConfig(name string, o ...Observer) *LayeredConfig {
+	return &LayeredConfig{
+		name:      name,
+		observers: o,
+	}
+}
+
+// AddDefaults adds a static layer with the given ConfigMap, typically
+// holding the defaults from code. Adding it doesn't notify the observers.
+func (l *LayeredConfig) AddDefaults(cm corev1.ConfigMap) {
+	layer := l.newLayer()
+	l.m.Lock()
+	defer l.m.Unlock()
+	layer.cm = cm.DeepCopy()
+}
+
+// AddLayer adds a layer with the ConfigMap of the given name, as observed
+// by the given watcher. The watcher must be started afterwards and will
+// fail to start if the ConfigMap does not exist.
+func (l *LayeredConfig) AddLayer(w Watcher, name string) {
+	layer := l.newLayer()
+	w.Watch(name, func(cm *corev1.ConfigMap) {
+		l.update(layer, cm)
+	})
+}
+
+// AddOptionalLayer is like AddLayer, but the ConfigMap may not exist.
+// Missing ConfigMaps don't contribute any keys.
+func (l *LayeredConfig) AddOptionalLayer(w DefaultingWatcher, namespace, name string) {
+	layer := l.newLayer()
+	w.WatchWithDefault(corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+	}, func(cm *corev1.ConfigMap) {
+		if len(cm.Data) == 0 {
+			// Missing or empty ConfigMaps don't contribute to the provenance.
+			cm = nil
+		}
+		l.update(layer, cm)
+	})
+}
+
+func (l *LayeredConfig) newLayer() *configLayer {
+	l.m.Lock()
+	defer l.m.Unlock()
+	layer := &configLayer{}
+	l.layers = append(l.layers, layer)
+	return layer
+}
+
+// update records the new state of the layer and notifies the observers.
+func (l *LayeredConfig) update(layer *configLayer, cm *corev1.ConfigMap) {
+	l.m.Lock()
+	if cm != nil {
+		cm = cm.DeepCopy()
+	}
+	layer.cm = cm
+	merged, _ := l.mergeLocked()
+	l.m.Unlock()
+
+	for _, o := range l.observers {
+		o(merged)
+	}
+}
+
+// Provenance returns, for each key of the merged ConfigMap, the
+// "namespace/name" of the ConfigMap its value comes from.
+func (l *LayeredConfig) Provenance() map[string]string {
+	l.m.Lock()
+	defer l.m.Unlock()
+	_, provenance := l.mergeLocked()
+	return provenance
+}
+
+func (l *LayeredConfig) mergeLocked() (*corev1.ConfigMap, map[string]string) {
+	merged := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: l.name},
+		Data:       make(map[string]string),
+	}
+	provenance := make(map[string]string)
+	for _, layer := range l.layers {
+		if layer.cm == nil {
+			continue
+		}
+		if layer.cm.Namespace != "" {
+			merged.Namespace = layer.cm.Namespace
+		}
+		source := layer.cm.Namespace + "/" + layer.cm.Name
+		for k, v := range layer.cm.Data {
+			merged.Data[k] = v
+			provenance[k] = source
+		}
+	}
+	return merged, provenance
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmap
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekubeclientset "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLayeredConfig(t *testing.T) {
+	clusterCM := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "knative-serving",
+			Name:      "config-foo",
+		},
+		Data: map[string]string{
+			"shared":  "cluster",
+			"cluster": "cluster",
+		},
+	}
+	localCM := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "my-ns",
+			Name:      "config-foo-override",
+		},
+		Data: map[string]string{
+			"shared": "local",
+		},
+	}
+
+	clusterWatcher := &ManualWatcher{Namespace: "knative-serving"}
+	localWatcher := NewInformedWatcher(fakekubeclientset.NewSimpleClientset(), "my-ns")
+
+	merged := &counter{name: "merged"}
+	l := NewLayeredConfig("config-foo", merged.callback)
+	l.AddDefaults(corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "defaults"},
+		Data: map[string]string{
+			"shared":   "default",
+			"defaults": "default",
+		},
+	})
+	l.AddLayer(clusterWatcher, "config-foo")
+	l.AddOptionalLayer(localWatcher, "my-ns", "config-foo-override")
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	if err := localWatcher.Start(stopCh); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+
+	// The missing override contributes nothing.
+	if got, want := merged.count(), 1; got != want {
+		t.Fatalf("merged.count = %v, want %v", got, want)
+	}
+	if got, want := merged.cfg[0].Data["shared"], "default"; got != want {
+		t.Errorf("shared = %v, want %v", got, want)
+	}
+
+	clusterWatcher.OnChange(clusterCM)
+	localWatcher.OnChange(localCM)
+
+	want := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "my-ns",
+			Name:      "config-foo",
+		},
+		Data: map[string]string{
+			"shared":   "local",
+			"cluster":  "cluster",
+			"defaults": "default",
+		},
+	}
+	if got := merged.cfg[len(merged.cfg)-1]; !cmp.Equal(got, want) {
+		t.Errorf("Merged = %v, diff(-want,+got):\n%s", got, cmp.Diff(want, got))
+	}
+
+	wantProvenance := map[string]string{
+		"shared":   "my-ns/config-foo-override",
+		"cluster":  "knative-serving/config-foo",
+		"defaults": "/defaults",
+	}
+	if got := l.Provenance(); !cmp.Equal(got, wantProvenance) {
+		t.Errorf("Provenance() = %v, diff(-want,+got):\n%s", got, cmp.Diff(wantProvenance, got))
+	}
+
+	// Removing the override reveals the cluster value again.
+	localWatcher.deleteConfigMapEvent(localCM)
+	if got, want := merged.cfg[len(merged.cfg)-1].Data["shared"], "cluster"; got != want {
+		t.Errorf("shared = %v, want %v", got, want)
+	}
+	if got, want := l.Provenance()["shared"], "knative-serving/config-foo"; got != want {
+		t.Errorf("Provenance()[shared] = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// ParseFunc is a function taking ConfigMap data and applying a parse operation to it.
+type ParseFunc func(map[string]string) error
+
+// AsString passes the value at key through into the target, if it exists.
+func AsString(key string, target *string) ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok {
+			*target = raw
+		}
+		return nil
+	}
+}
+
+// AsBool parses the value at key as a boolean into the target, if it exists.
+func AsBool(key string, target *bool) ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok {
+			val, err := strconv.ParseBool(raw)
+			if err != nil {
+				return fmt.Errorf("failed to parse %q: %w", key, err)
+			}
+			*target = val
+		}
+		return nil
+	}
+}
+
+// AsInt32 parses the value at key as an int32 into the target, if it exists.
+func AsInt32(key string, target *int32) ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok {
+			val, err := strconv.ParseInt(raw, 10, 32)
+			if err != nil {
+				return fmt.Errorf("failed to parse %q: %w", key, err)
+			}
+			*target = int32(val)
+		}
+		return nil
+	}
+}
+
+// AsInt64 parses the value at key as an int64 into the target, if it exists.
+func AsInt64(key string, target *int64) ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok {
+			val, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				return fmt.Errorf("failed to parse %q: %w", key, err)
+			}
+			*target = val
+		}
+		return nil
+	}
+}
+
+// AsFloat64 parses the value at key as a float64 into the target, if it exists.
+func AsFloat64(key string, target *float64) ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok {
+			val, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return fmt.Errorf("failed to parse %q: %w", key, err)
+			}
+			*target = val
+		}
+		return nil
+	}
+}
+
+// AsDuration parses the value at key as a time.Duration into the target, if it exists.
+func AsDuration(key string, target *time.Duration) ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok {
+			val, err := time.ParseDuration(raw)
+			if err != nil {
+				return fmt.Errorf("failed to parse %q: %w", key, err)
+			}
+			*target = val
+		}
+		return nil
+	}
+}
+
+// AsStringSlice parses the value at key as a comma-separated list of strings
+// into the target, if it exists. Elements are trimmed and empty elements are
+// dropped.
+func AsStringSlice(key string, target *[]string) ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok {
+			*target = splitList(raw)
+		}
+		return nil
+	}
+}
+
+// AsStringSet parses the value at key as a comma-separated set of strings
+// into the target, if it exists.
+func AsStringSet(key string, target *sets.String) ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok {
+			*target = sets.NewString(splitList(raw)...)
+		}
+		return nil
+	}
+}
+
+// AsStringMap parses the value at key as a comma-separated list of
+// key=value pairs into the target, if it exists.
+func AsStringMap(key string, target *map[string]string) ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok {
+			m := make(map[string]string)
+			for _, pair := range splitList(raw) {
+				parts := strings.SplitN(pair, "=", 2)
+				k := strings.TrimSpace(parts[0])
+				if len(parts) != 2 || k == "" {
+					return fmt.Errorf("failed to parse %q: %q is not a key=value pair", key, pair)
+				}
+				m[k] = strings.TrimSpace(parts[1])
+			}
+			*target = m
+		}
+		return nil
+	}
+}
+
+// AsQuantity parses the value at key as a resource.Quantity into the target,
+// if it exists.
+func AsQuantity(key string, target **resource.Quantity) ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok {
+			val, err := resource.ParseQuantity(raw)
+			if err != nil {
+				return fmt.Errorf("failed to parse %q: %w", key, err)
+			}
+			*target = &val
+		}
+		return nil
+	}
+}
+
+// AsEnum passes the value at key through into the target, if it exists,
+// and validates that it is one of the allowed values.
+func AsEnum(key string, target *string, allowed ...string) ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok {
+			if !sets.NewString(allowed...).Has(raw) {
+				return fmt.Errorf("failed to parse %q: %q is not one of %v", key, raw, allowed)
+			}
+			*target = raw
+		}
+		return nil
+	}
+}
+
+// Required validates that all of the given keys exist and are not empty.
+func Required(keys ...string) ParseFunc {
+	return func(data map[string]string) error {
+		for _, key := range keys {
+			if strings.TrimSpace(data[key]) == "" {
+				return fmt.Errorf("missing required key %q", key)
+			}
+		}
+		return nil
+	}
+}
+
+// Parse parses the given map using the parser functions passed in.
+// It stops at the first error.
+func Parse(data map[string]string, parsers ...ParseFunc) error {
+	for _, parse := range parsers {
+		if err := parse(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitList splits a comma-separated list, trimming its elements and
+// dropping empty ones.
+func splitList(raw string) []string {
+	var list []string
+	for _, s := range strings.Split(raw, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+type testConfig struct {
+	Str   string
+	Boo   bool
+	I32   int32
+	I64   int64
+	F64   float64
+	Dur   time.Duration
+	Slice []string
+	Set   sets.String
+	M     map[string]string
+	Qty   *resource.Quantity
+	Enum  string
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		conf      testConfig
+		data      map[string]string
+		want      testConfig
+		expectErr bool
+	}{{
+		name: "all good",
+		data: map[string]string{
+			"test-string":   "foo.bar",
+			"test-bool":     "true",
+			"test-int32":    "1",
+			"test-int64":    "2",
+			"test-float64":  "1.0",
+			"test-duration": "1m",
+			"test-slice":    "a, b,,c ",
+			"test-set":      "a,b,a",
+			"test-map":      "a=1, b = 2",
+			"test-quantity": "500m",
+			"test-enum":     "blue",
+		},
+		want: testConfig{
+			Str:   "foo.bar",
+			Boo:   true,
+			I32:   1,
+			I64:   2,
+			F64:   1.0,
+			Dur:   time.Minute,
+			Slice: []string{"a", "b", "c"},
+			Set:   sets.NewString("a", "b"),
+			M:     map[string]string{"a": "1", "b": "2"},
+			Qty:   resource.NewMilliQuantity(500, resource.DecimalSI),
+			Enum:  "blue",
+		},
+	}, {
+		name: "respect defaults",
+		conf: testConfig{
+			Str:  "foo.bar",
+			Boo:  true,
+			I32:  1,
+			Enum: "red",
+		},
+		want: testConfig{
+			Str:  "foo.bar",
+			Boo:  true,
+			I32:  1,
+			Enum: "red",
+		},
+	}, {
+		name:      "bool error",
+		data:      map[string]string{"test-bool": "foo"},
+		expectErr: true,
+	}, {
+		name:      "int32 error",
+		data:      map[string]string{"test-int32": "foo"},
+		expectErr: true,
+	}, {
+		name:      "int64 error",
+		data:      map[string]string{"test-int64": "foo"},
+		expectErr: true,
+	}, {
+		name:      "float64 error",
+		data:      map[string]string{"test-float64": "foo"},
+		expectErr: true,
+	}, {
+		name:      "duration error",
+		data:      map[string]string{"test-duration": "foo"},
+		expectErr: true,
+	}, {
+		name:      "map error",
+		data:      map[string]string{"test-map": "a=1,b"},
+		expectErr: true,
+	}, {
+		name:      "map empty key",
+		data:      map[string]string{"test-map": "=1"},
+		expectErr: true,
+	}, {
+		name:      "quantity error",
+		data:      map[string]string{"test-quantity": "foo"},
+		expectErr: true,
+	}, {
+		name:      "enum error",
+		data:      map[string]string{"test-enum": "green"},
+		expectErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := Parse(test.data,
+				AsString("test-string", &test.conf.Str),
+				AsBool("test-bool", &test.conf.Boo),
+				AsInt32("test-int32", &test.conf.I32),
+				AsInt64("test-int64", &test.conf.I64),
+				AsFloat64("test-float64", &test.conf.F64),
+				AsDuration("test-duration", &test.conf.Dur),
+				AsStringSlice("test-slice", &test.conf.Slice),
+				AsStringSet("test-set", &test.conf.Set),
+				AsStringMap("test-map", &test.conf.M),
+				AsQuantity("test-quantity", &test.conf.Qty),
+				AsEnum("test-enum", &test.conf.Enum, "red", "blue"),
+			); (err != nil) != test.expectErr {
+				t.Fatalf("Parse() = %v, expectErr = %v", err, test.expectErr)
+			}
+			if test.expectErr {
+				return
+			}
+			if !cmp.Equal(test.conf, test.want) {
+				t.Errorf("Parsed = %v, want %v, diff(-want,+got):\n%s",
+					test.conf, test.want, cmp.Diff(test.want, test.conf))
+			}
+		})
+	}
+}
+
+func TestRequired(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      map[string]string
+		expectErr bool
+	}{{
+		name: "all present",
+		data: map[string]string{"a": "1", "b": "2"},
+	}, {
+		name:      "missing",
+		data:      map[string]string{"a": "1"},
+		expectErr: true,
+	}, {
+		name:      "empty",
+		data:      map[string]string{"a": "1", "b": " "},
+		expectErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := Parse(test.data, Required("a", "b")); (err != nil) != test.expectErr {
+				t.Errorf("Parse() = %v, expectErr = %v", err, test.expectErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Requirement declares a ConfigMap a component requires to start, along with
+// the keys it must have.
+type Requirement struct {
+	// Name is the name of the ConfigMap.
+	Name string
+	// Keys are the keys the ConfigMap must have, if any.
+	Keys []string
+}
+
+// RequiringWatcher is a Watcher checking, when it starts, that the ConfigMaps
+// it watches exist and that the required ones have their keys, failing with
+// a RequirementsError listing all of the ones missing at once.
+type RequiringWatcher interface {
+	Watcher
+
+	// Require declares the ConfigMaps and keys required at Start. The
+	// requirements of the same ConfigMap add up.
+	Require(...Requirement)
+}
+
+// Require declares the requirements on the watcher, if it's a
+// RequiringWatcher, e.g. in the constructor of a controller:
+//
+//	configmap.Require(cmw, configmap.Requirement{
+//		Name: "config-network",
+//		Keys: []string{"ingress.class"},
+//	})
+//
+// It returns whether the watcher will check them.
+func Require(w Watcher, reqs ...Requirement) bool {
+	rw, ok := w.(RequiringWatcher)
+	if ok {
+		rw.Require(reqs...)
+	}
+	return ok
+}
+
+// MissingConfig is a ConfigMap which is missing, or which misses some of the
+// keys required.
+type MissingConfig struct {
+	// Name is the name of the ConfigMap.
+	Name string
+	// NotFound is whether the ConfigMap doesn't exist.
+	NotFound bool
+	// Keys are the required keys the ConfigMap misses, all of them if it
+	// doesn't exist.
+	Keys []string
+}
+
+func (m MissingConfig) String() string {
+	var b strings.Builder
+	if m.NotFound {
+		fmt.Fprintf(&b, "ConfigMap %q does not exist", m.Name)
+		if len(m.Keys) > 0 {
+			fmt.Fprintf(&b, " (required keys: %s)", strings.Join(m.Keys, ", "))
+		}
+	} else {
+		fmt.Fprintf(&b, "ConfigMap %q is missing the keys: %s", m.Name, strings.Join(m.Keys, ", "))
+	}
+	return b.String()
+}
+
+// RequirementsError lists all of the ConfigMaps and keys missing when a
+// watcher starts, so that they can be fixed at once.
+type RequirementsError struct {
+	// Namespace is the namespace of the ConfigMaps.
+	Namespace string
+	// Selector is the label selector the ConfigMaps must match, if any.
+	Selector string
+	// Missing are the missing ConfigMaps and keys, sorted by name.
+	Missing []MissingConfig
+}
+
+// Error implements error.
+func (e *RequirementsError) Error() string {
+	lines := make([]string, len(e.Missing))
+	for i, m := range e.Missing {
+		lines[i] = m.String()
+	}
+	msg := fmt.Sprintf("%d required ConfigMap(s) missing or incomplete in namespace %q: %s",
+		len(e.Missing), e.Namespace, strings.Join(lines, "; "))
+	if e.Selector != "" {
+		msg += fmt.Sprintf(" (only the ConfigMaps matching the label selector %q are watched)", e.Selector)
+	}
+	return msg
+}
+
+// requirements are the keys required per ConfigMap, by name.
+type requirements map[string][]string
+
+// add adds the requirements, merging the keys of the same ConfigMaps.
+func (r requirements) add(reqs ...Requirement) {
+	for _, req := range reqs {
+		keys := r[req.Name]
+	keys:
+		for _, k := range req.Keys {
+			for _, existing := range keys {
+				if existing == k {
+					continue keys
+				}
+			}
+			keys = append(keys, k)
+		}
+		r[req.Name] = keys
+	}
+}
+
+// check checks that the ConfigMaps of the given names, which are required
+// without keys unless listed in the requirements, and the ones required exist
+// and have their keys. A ConfigMap which doesn't exist but has a default is
+// checked through its default. get returns the ConfigMap of a name.
+func (r requirements) check(names []string, defaults map[string]*corev1.ConfigMap, get func(string) (*corev1.ConfigMap, error)) ([]MissingConfig, error) {
+	all := make(map[string][]string, len(names)+len(r))
+	for _, name := range names {
+		all[name] = nil
+	}
+	for name, keys := range r {
+		all[name] = keys
+	}
+	sorted := make([]string, 0, len(all))
+	for name := range all {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var missing []MissingConfig
+	for _, name := range sorted {
+		cm, err := get(name)
+		if k8serrors.IsNotFound(err) {
+			cm, err = defaults[name], nil
+			if cm == nil {
+				missing = append(missing, MissingConfig{Name: name, NotFound: true, Keys: all[name]})
+				continue
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		var keys []string
+		for _, k := range all[name] {
+			if _, ok := cm.Data[k]; !ok {
+				if _, ok := cm.BinaryData[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+		}
+		if len(keys) > 0 {
+			missing = append(missing, MissingConfig{Name: name, Keys: keys})
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmap
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekubeclientset "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRequirementsAdd(t *testing.T) {
+	r := make(requirements)
+	r.add(Requirement{Name: "foo", Keys: []string{"a", "b"}}, Requirement{Name: "bar"})
+	r.add(Requirement{Name: "foo", Keys: []string{"b", "c"}})
+
+	want := requirements{
+		"foo": {"a", "b", "c"},
+		"bar": nil,
+	}
+	if diff := cmp.Diff(want, r); diff != "" {
+		t.Errorf("requirements (-want, +got): %s", diff)
+	}
+}
+
+func TestRequireStartFailsWithAllMissing(t *testing.T) {
+	kc := fakekubeclientset.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "incomplete"},
+		Data:       map[string]string{"present": "val"},
+	}, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "complete"},
+		Data:       map[string]string{"key": "val"},
+		BinaryData: map[string][]byte{"binary": []byte("val")},
+	})
+	cm := NewInformedWatcher(kc, "default")
+
+	cm.Watch("observed", (&counter{name: "observed"}).callback)
+	cm.Watch("complete", (&counter{name: "complete"}).callback)
+	cm.WatchWithDefault(corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "defaulted"},
+		Data:       map[string]string{"key": "default"},
+	}, (&counter{name: "defaulted"}).callback)
+	if !Require(cm,
+		Requirement{Name: "incomplete", Keys: []string{"present", "missing", "other"}},
+		Requirement{Name: "complete", Keys: []string{"key", "binary"}},
+		Requirement{Name: "defaulted", Keys: []string{"key", "undefaulted"}},
+		Requirement{Name: "required", Keys: []string{"key"}},
+	) {
+		t.Fatal("Require() = false, wanted the InformedWatcher to check the requirements")
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	err := cm.Start(stopCh)
+	var rerr *RequirementsError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("Start() = %v, wanted a RequirementsError", err)
+	}
+	want := &RequirementsError{
+		Namespace: "default",
+		Missing: []MissingConfig{{
+			Name: "defaulted",
+			Keys: []string{"undefaulted"},
+		}, {
+			Name: "incomplete",
+			Keys: []string{"missing", "other"},
+		}, {
+			Name:     "observed",
+			NotFound: true,
+		}, {
+			Name:     "required",
+			NotFound: true,
+			Keys:     []string{"key"},
+		}},
+	}
+	if diff := cmp.Diff(want, rerr); diff != "" {
+		t.Errorf("RequirementsError (-want, +got): %s", diff)
+	}
+	if got, want := err.Error(), `4 required ConfigMap(s) missing or incomplete in namespace "default": `+
+		`ConfigMap "defaulted" is missing the keys: undefaulted; `+
+		`ConfigMap "incomplete" is missing the keys: missing, other; `+
+		`ConfigMap "observed" does not exist; `+
+		`ConfigMap "required" does not exist (required keys: key)`; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestRequireWithLabelSelector(t *testing.T) {
+	req, err := FilterConfigByLabelExists("knative.dev/config")
+	if err != nil {
+		t.Fatalf("FilterConfigByLabelExists() = %v", err)
+	}
+	kc := fakekubeclientset.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "unlabeled"},
+	})
+	cm := NewInformedWatcher(kc, "default", *req)
+	cm.Require(Requirement{Name: "unlabeled"})
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	want := `1 required ConfigMap(s) missing or incomplete in namespace "default": ConfigMap "unlabeled" does not exist ` +
+		`(only the ConfigMaps matching the label selector "knative.dev/config" are watched)`
+	if err := cm.Start(stopCh); err == nil || err.Error() != want {
+		t.Errorf("Start() = %v, want %s", err, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Require() after Start didn't panic")
+		}
+	}()
+	cm.Require(Requirement{Name: "late"})
+}
+
+func TestRequireUnsupported(t *testing.T) {
+	if Require(NewStaticWatcher(), Requirement{Name: "foo"}) {
+		t.Error("Require() = true for a StaticWatcher")
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmap
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+)
+
+// The helpers below decode values typically kept in Secrets, as handed to
+// Observers by the InformedSecretWatcher.
+
+// AsBytes passes the value at key through into the target, if it exists.
+func AsBytes(key string, target *[]byte) ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok {
+			*target = []byte(raw)
+		}
+		return nil
+	}
+}
+
+// AsBase64 decodes the base64 encoded value at key into the target, if it
+// exists. This is for values that are encoded on top of the encoding
+// Kubernetes applies to all Secret values.
+func AsBase64(key string, target *[]byte) ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok {
+			val, err := base64.StdEncoding.DecodeString(raw)
+			if err != nil {
+				return fmt.Errorf("failed to parse %q: %w", key, err)
+			}
+			*target = val
+		}
+		return nil
+	}
+}
+
+// AsCertPool parses the PEM encoded certificates at key into the target,
+// if it exists.
+func AsCertPool(key string, target **x509.CertPool) ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM([]byte(raw)) {
+				return fmt.Errorf("failed to parse %q: no PEM encoded certificates found", key)
+			}
+			*target = pool
+		}
+		return nil
+	}
+}
+
+// AsTLSCertificate parses the PEM encoded certificate and key at the given
+// keys into the target, if both exist. It is an error if only one of them
+// exists.
+func AsTLSCertificate(certKey, keyKey string, target **tls.Certificate) ParseFunc {
+	return func(data map[string]string) error {
+		cert, certOK := data[certKey]
+		key, keyOK := data[keyKey]
+		if certOK != keyOK {
+			return fmt.Errorf("%q and %q must be specified together", certKey, keyKey)
+		}
+		if !certOK {
+			return nil
+		}
+		val, err := tls.X509KeyPair([]byte(cert), []byte(key))
+		if err != nil {
+			return fmt.Errorf("failed to parse %q and %q: %w", certKey, keyKey, err)
+		}
+		*target = &val
+		return nil
+	}
+}
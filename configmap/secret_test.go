@@ -0,0 +1,134 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmap
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// selfSignedCert returns a PEM encoded self-signed certificate and its key.
+func selfSignedCert(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("GenerateKey() =", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal("CreateCertificate() =", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal("MarshalECPrivateKey() =", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestSecretParsers(t *testing.T) {
+	cert, key := selfSignedCert(t)
+
+	tests := []struct {
+		name      string
+		data      map[string]string
+		expectErr bool
+		check     func(t *testing.T, bytes, decoded []byte, pool *x509.CertPool, pair *tls.Certificate)
+	}{{
+		name: "all good",
+		data: map[string]string{
+			"bytes":   "raw",
+			"base64":  "ZGVjb2RlZA==",
+			"ca.crt":  cert,
+			"tls.crt": cert,
+			"tls.key": key,
+		},
+		check: func(t *testing.T, bytes, decoded []byte, pool *x509.CertPool, pair *tls.Certificate) {
+			if string(bytes) != "raw" {
+				t.Errorf("bytes = %q, want raw", bytes)
+			}
+			if string(decoded) != "decoded" {
+				t.Errorf("decoded = %q, want decoded", decoded)
+			}
+			if pool == nil {
+				t.Error("pool = nil, want a pool")
+			}
+			if pair == nil || len(pair.Certificate) != 1 {
+				t.Errorf("pair = %v, want a certificate", pair)
+			}
+		},
+	}, {
+		name: "nothing set",
+		check: func(t *testing.T, bytes, decoded []byte, pool *x509.CertPool, pair *tls.Certificate) {
+			if bytes != nil || decoded != nil || pool != nil || pair != nil {
+				t.Error("Expected targets to be left alone")
+			}
+		},
+	}, {
+		name:      "invalid base64",
+		data:      map[string]string{"base64": "%%%"},
+		expectErr: true,
+	}, {
+		name:      "invalid ca",
+		data:      map[string]string{"ca.crt": "foo"},
+		expectErr: true,
+	}, {
+		name:      "cert without key",
+		data:      map[string]string{"tls.crt": cert},
+		expectErr: true,
+	}, {
+		name:      "invalid key pair",
+		data:      map[string]string{"tls.crt": cert, "tls.key": "foo"},
+		expectErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				bytes, decoded []byte
+				pool           *x509.CertPool
+				pair           *tls.Certificate
+			)
+			err := Parse(test.data,
+				AsBytes("bytes", &bytes),
+				AsBase64("base64", &decoded),
+				AsCertPool("ca.crt", &pool),
+				AsTLSCertificate("tls.crt", "tls.key", &pair),
+			)
+			if (err != nil) != test.expectErr {
+				t.Fatalf("Parse() = %v, expectErr = %v", err, test.expectErr)
+			}
+			if test.check != nil {
+				test.check(t, bytes, decoded, pool, pair)
+			}
+		})
+	}
+}
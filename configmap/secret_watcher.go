@@ -0,0 +1,141 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmap
+
+import (
+	"errors"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	informers "k8s.io/client-go/informers"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NewInformedSecretWatcherFromFactory watches a Kubernetes namespace for secret changes.
+func NewInformedSecretWatcherFromFactory(sif informers.SharedInformerFactory, namespace string) *InformedSecretWatcher {
+	return &InformedSecretWatcher{
+		sif:      sif,
+		informer: sif.Core().V1().Secrets(),
+		ManualWatcher: ManualWatcher{
+			Namespace: namespace,
+		},
+	}
+}
+
+// NewInformedSecretWatcher watches a Kubernetes namespace for secret changes.
+func NewInformedSecretWatcher(kc kubernetes.Interface, namespace string) *InformedSecretWatcher {
+	return NewInformedSecretWatcherFromFactory(informers.NewSharedInformerFactoryWithOptions(
+		kc,
+		0,
+		informers.WithNamespace(namespace),
+	), namespace)
+}
+
+// InformedSecretWatcher provides an informer-based implementation of Watcher
+// for Secrets. Observers are handed the Secrets converted by SecretToConfigMap,
+// so the same Observers and parsers can be used for ConfigMaps and Secrets.
+type InformedSecretWatcher struct {
+	sif      informers.SharedInformerFactory
+	informer corev1informers.SecretInformer
+	started  bool
+
+	// Embedding this struct allows us to reuse the logic
+	// of registering and notifying observers.
+	ManualWatcher
+}
+
+// Asserts that InformedSecretWatcher implements Watcher.
+var _ Watcher = (*InformedSecretWatcher)(nil)
+
+// Start implements Watcher.
+func (i *InformedSecretWatcher) Start(stopCh <-chan struct{}) error {
+	if err := i.registerCallbackAndStartInformer(stopCh); err != nil {
+		return err
+	}
+
+	// Wait until it has been synced (WITHOUT holing the mutex, so callbacks happen)
+	if ok := cache.WaitForCacheSync(stopCh, i.informer.Informer().HasSynced); !ok {
+		return errors.New("error waiting for Secret informer to sync")
+	}
+
+	return i.checkObservedResourcesExist()
+}
+
+func (i *InformedSecretWatcher) registerCallbackAndStartInformer(stopCh <-chan struct{}) error {
+	i.m.Lock()
+	defer i.m.Unlock()
+	if i.started {
+		return errors.New("watcher already started")
+	}
+	i.started = true
+
+	i.informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    i.addSecretEvent,
+		UpdateFunc: i.updateSecretEvent,
+	})
+
+	// Start the shared informer factory (non-blocking).
+	i.sif.Start(stopCh)
+	return nil
+}
+
+func (i *InformedSecretWatcher) checkObservedResourcesExist() error {
+	i.m.RLock()
+	defer i.m.RUnlock()
+	// Check that all objects with Observers exist in our informers.
+	for k := range i.observers {
+		if _, err := i.informer.Lister().Secrets(i.Namespace).Get(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *InformedSecretWatcher) addSecretEvent(obj interface{}) {
+	secret := obj.(*corev1.Secret)
+	i.OnChange(SecretToConfigMap(secret))
+}
+
+func (i *InformedSecretWatcher) updateSecretEvent(o, n interface{}) {
+	// Ignore updates that are idempotent. We are seeing those
+	// periodically.
+	if equality.Semantic.DeepEqual(o, n) {
+		return
+	}
+	secret := n.(*corev1.Secret)
+	i.OnChange(SecretToConfigMap(secret))
+}
+
+// SecretToConfigMap converts a Secret into a ConfigMap with the same
+// metadata and data, so it can be handed to Observers. The values are kept
+// as is, i.e. the bytes of the Secret's values become the strings of the
+// ConfigMap's values.
+func SecretToConfigMap(secret *corev1.Secret) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: *secret.ObjectMeta.DeepCopy(),
+		Data:       make(map[string]string, len(secret.Data)+len(secret.StringData)),
+	}
+	for k, v := range secret.Data {
+		cm.Data[k] = string(v)
+	}
+	for k, v := range secret.StringData {
+		cm.Data[k] = v
+	}
+	return cm
+}
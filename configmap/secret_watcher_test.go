@@ -0,0 +1,121 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmap
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekubeclientset "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestInformedSecretWatcher(t *testing.T) {
+	fooSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "foo",
+		},
+		Data: map[string][]byte{"key": []byte("val")},
+	}
+	kc := fakekubeclientset.NewSimpleClientset(fooSecret)
+	sw := NewInformedSecretWatcher(kc, "default")
+
+	foo := &counter{name: "foo"}
+	sw.Watch("foo", foo.callback)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	if err := sw.Start(stopCh); err != nil {
+		t.Fatalf("sw.Start() = %v", err)
+	}
+
+	// When Start returns the callbacks should have been called with the
+	// version of the objects that is available.
+	if got, want := foo.count(), 1; got != want {
+		t.Fatalf("foo.count = %v, want %v", got, want)
+	}
+	if got, want := foo.cfg[0].Data, map[string]string{"key": "val"}; !cmp.Equal(got, want) {
+		t.Errorf("Observed data = %v, want %v", got, want)
+	}
+
+	// Updates are observed.
+	foo.mu.Lock()
+	foo.wg = &sync.WaitGroup{}
+	foo.mu.Unlock()
+	foo.wg.Add(1)
+	updated := fooSecret.DeepCopy()
+	updated.Data["key"] = []byte("new")
+	if _, err := kc.CoreV1().Secrets("default").Update(updated); err != nil {
+		t.Fatalf("Error updating fooSecret: %v", err)
+	}
+	foo.wg.Wait()
+	if got, want := foo.cfg[1].Data, map[string]string{"key": "new"}; !cmp.Equal(got, want) {
+		t.Errorf("Observed data = %v, want %v", got, want)
+	}
+
+	// Idempotent updates are not observed.
+	sw.updateSecretEvent(updated, updated)
+	if got, want := foo.count(), 2; got != want {
+		t.Errorf("foo.count = %v, want %v", got, want)
+	}
+
+	// This should error because we already called Start()
+	if err := sw.Start(stopCh); err == nil {
+		t.Error("sw.Start() succeeded, wanted error")
+	}
+}
+
+func TestSecretWatchMissingFailsOnStart(t *testing.T) {
+	kc := fakekubeclientset.NewSimpleClientset()
+	sw := NewInformedSecretWatcher(kc, "default")
+
+	foo := &counter{name: "foo"}
+	sw.Watch("foo", foo.callback)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	// This should error because we don't have a Secret named "foo".
+	if err := sw.Start(stopCh); err == nil {
+		t.Fatal("sw.Start() succeeded, wanted error")
+	}
+}
+
+func TestSecretToConfigMap(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "foo",
+			Labels:    map[string]string{"a": "b"},
+		},
+		Data:       map[string][]byte{"data": []byte("val")},
+		StringData: map[string]string{"string": "val"},
+	}
+	want := &corev1.ConfigMap{
+		ObjectMeta: secret.ObjectMeta,
+		Data: map[string]string{
+			"data":   "val",
+			"string": "val",
+		},
+	}
+	if got := SecretToConfigMap(secret); !cmp.Equal(got, want) {
+		t.Errorf("SecretToConfigMap() = %v, diff(-want,+got):\n%s", got, cmp.Diff(want, got))
+	}
+}
@@ -125,12 +125,29 @@ func (s *UntypedStore) UntypedLoad(name string) interface{} {
 	return storage.Load()
 }
 
+// WatchConfigsValidated is like WatchConfigs, but ConfigMaps that fail to
+// be constructed once the store has been initialized are rejected through
+// the ValidatingWatcher, rather than only logged.
+func (s *UntypedStore) WatchConfigsValidated(w *ValidatingWatcher) {
+	for configMapName := range s.constructors {
+		w.WatchValidated(configMapName, s.onConfigChangedValidated)
+	}
+}
+
 // OnConfigChanged will invoke the mapped constructor against
 // a Kubernetes ConfigMap. If successful it will be stored.
 // If construction fails during the first appearance the store
 // will log a fatal error. If construction fails while updating
 // the store will log an error message.
 func (s *UntypedStore) OnConfigChanged(c *corev1.ConfigMap) {
+	if err := s.onConfigChangedValidated(c); err != nil {
+		s.logger.Errorf("Error updating %s config %q: %q", s.name, c.Name, err)
+	}
+}
+
+// onConfigChangedValidated is like OnConfigChanged, but returns the error
+// of constructors failing to update an initialized store.
+func (s *UntypedStore) onConfigChangedValidated(c *corev1.ConfigMap) error {
 	name := c.ObjectMeta.Name
 
 	storage := s.storages[name]
@@ -145,13 +162,11 @@ func (s *UntypedStore) OnConfigChanged(c *corev1.ConfigMap) {
 	errVal := outputs[1]
 
 	if !errVal.IsNil() {
-		err := errVal.Interface()
-		if storage.Load() != nil {
-			s.logger.Errorf("Error updating %s config %q: %q", s.name, name, err)
-		} else {
+		err := errVal.Interface().(error)
+		if storage.Load() == nil {
 			s.logger.Fatalf("Error initializing %s config %q: %q", s.name, name, err)
 		}
-		return
+		return err
 	}
 
 	s.logger.Infof("%s config %q config was added or updated: %#v", s.name, name, result)
@@ -162,4 +177,5 @@ func (s *UntypedStore) OnConfigChanged(c *corev1.ConfigMap) {
 			f(name, result)
 		}
 	}()
+	return nil
 }
@@ -73,6 +73,11 @@ func ConfigMapsFromTestFile(t *testing.T, name string, allowed ...string) (*core
 	}
 	// With the length and membership checks, we know that the keyspace matches.
 
+	if err := configmap.ValidateExampleChecksum(&orig); err != nil {
+		t.Errorf("%s, update the annotation to %q if the example was changed on purpose",
+			err, configmap.Checksum(orig.Data[configmap.ExampleKey]))
+	}
+
 	exampleBody := orig.Data[configmap.ExampleKey]
 	// Check that exampleBody does not have lines that end in a trailing space,
 	for i, line := range strings.Split(exampleBody, "\n") {
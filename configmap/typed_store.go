@@ -0,0 +1,102 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmap
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TypedConfig pairs the name of a ConfigMap with the constructor of the
+// config of type T it holds, so the config can be loaded from an
+// UntypedStore without type assertions.
+type TypedConfig[T any] struct {
+	name        string
+	constructor func(*corev1.ConfigMap) (T, error)
+}
+
+// NewTypedConfig returns a TypedConfig for the named ConfigMap.
+func NewTypedConfig[T any](name string, constructor func(*corev1.ConfigMap) (T, error)) TypedConfig[T] {
+	return TypedConfig[T]{
+		name:        name,
+		constructor: constructor,
+	}
+}
+
+// Name returns the name of the ConfigMap.
+func (c TypedConfig[T]) Name() string {
+	return c.name
+}
+
+// Register adds the config's constructor to the given Constructors.
+func (c TypedConfig[T]) Register(constructors Constructors) {
+	constructors[c.name] = c.constructor
+}
+
+// Load returns the config from the given store, which must have been
+// created with the Constructors the config has been registered with. It
+// returns the zero value of T if the config has not been loaded yet.
+func (c TypedConfig[T]) Load(s *UntypedStore) T {
+	value, _ := s.UntypedLoad(c.name).(T)
+	return value
+}
+
+// TypedStore is a store for a single config of type T.
+type TypedStore[T any] struct {
+	config  TypedConfig[T]
+	untyped *UntypedStore
+}
+
+// NewTypedStore creates a TypedStore with the given name and Logger, for
+// the given config. The onAfterStore callbacks are run like the ones of
+// NewUntypedStore.
+func NewTypedStore[T any](name string, logger Logger, config TypedConfig[T], onAfterStore ...func(name string, value T)) *TypedStore[T] {
+	constructors := Constructors{}
+	config.Register(constructors)
+
+	untypedOnAfterStore := make([]func(string, interface{}), 0, len(onAfterStore))
+	for _, f := range onAfterStore {
+		f := f
+		untypedOnAfterStore = append(untypedOnAfterStore, func(name string, value interface{}) {
+			f(name, value.(T))
+		})
+	}
+
+	return &TypedStore[T]{
+		config:  config,
+		untyped: NewUntypedStore(name, logger, constructors, untypedOnAfterStore...),
+	}
+}
+
+// WatchConfigs uses the provided Watcher to keep the store up to date.
+func (s *TypedStore[T]) WatchConfigs(w Watcher) {
+	s.untyped.WatchConfigs(w)
+}
+
+// OnConfigChanged constructs and stores the config from the ConfigMap,
+// like UntypedStore.OnConfigChanged.
+func (s *TypedStore[T]) OnConfigChanged(cm *corev1.ConfigMap) {
+	s.untyped.OnConfigChanged(cm)
+}
+
+// Load returns the current config, or the zero value of T if it has not
+// been loaded yet.
+func (s *TypedStore[T]) Load() T {
+	return s.config.Load(s.untyped)
+}
@@ -0,0 +1,98 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmap
+
+import (
+	"strconv"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "knative.dev/pkg/logging/testing"
+)
+
+type fooConfig struct {
+	Replicas int
+}
+
+func newFooConfig(cm *corev1.ConfigMap) (*fooConfig, error) {
+	replicas, err := strconv.Atoi(cm.Data["replicas"])
+	if err != nil {
+		return nil, err
+	}
+	return &fooConfig{Replicas: replicas}, nil
+}
+
+func newBarConfig(cm *corev1.ConfigMap) (string, error) {
+	return cm.Data["bar"], nil
+}
+
+func TestTypedStore(t *testing.T) {
+	stored := make(chan int, 1)
+	store := NewTypedStore("name", TestLogger(t), NewTypedConfig("config-foo", newFooConfig),
+		func(name string, value *fooConfig) {
+			stored <- value.Replicas
+		})
+
+	if got := store.Load(); got != nil {
+		t.Errorf("Load() = %v, want nil before the config is loaded", got)
+	}
+
+	watcher := &ManualWatcher{}
+	store.WatchConfigs(watcher)
+	watcher.OnChange(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "config-foo"},
+		Data:       map[string]string{"replicas": "3"},
+	})
+
+	if got := store.Load(); got == nil || got.Replicas != 3 {
+		t.Errorf("Load() = %v, want 3 replicas", got)
+	}
+	if got := <-stored; got != 3 {
+		t.Errorf("onAfterStore saw %d replicas, want 3", got)
+	}
+}
+
+func TestTypedConfigs(t *testing.T) {
+	foo := NewTypedConfig("config-foo", newFooConfig)
+	bar := NewTypedConfig("config-bar", newBarConfig)
+
+	constructors := Constructors{}
+	foo.Register(constructors)
+	bar.Register(constructors)
+	store := NewUntypedStore("name", TestLogger(t), constructors)
+
+	store.OnConfigChanged(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: foo.Name()},
+		Data:       map[string]string{"replicas": "1"},
+	})
+	store.OnConfigChanged(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: bar.Name()},
+		Data:       map[string]string{"bar": "baz"},
+	})
+
+	if got := foo.Load(store); got.Replicas != 1 {
+		t.Errorf("foo.Load() = %v, want 1 replica", got)
+	}
+	if got := bar.Load(store); got != "baz" {
+		t.Errorf("bar.Load() = %q, want baz", got)
+	}
+}
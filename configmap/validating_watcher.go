@@ -0,0 +1,127 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmap
+
+import (
+	"context"
+	"sync"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	corev1 "k8s.io/api/core/v1"
+
+	"knative.dev/pkg/metrics"
+)
+
+var (
+	rejectedConfigsStat = stats.Int64(
+		"configmap_rejections",
+		"Number of ConfigMap updates rejected by an observer",
+		stats.UnitDimensionless)
+
+	configMapNameKey = tag.MustNewKey("configmap")
+)
+
+func init() {
+	if err := view.Register(&view.View{
+		Description: rejectedConfigsStat.Description(),
+		Measure:     rejectedConfigsStat,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{configMapNameKey},
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// ValidatingObserver is like Observer, but may reject the ConfigMap by
+// returning an error.
+type ValidatingObserver func(*corev1.ConfigMap) error
+
+// RejectionHandler is notified of ConfigMaps rejected by an observer, e.g.
+// to log the error or to emit an event.
+type RejectionHandler func(cm *corev1.ConfigMap, err error)
+
+// ValidatingWatcher wraps a Watcher to let ValidatingObservers reject
+// invalid ConfigMaps. The observers of a ConfigMap are called in order;
+// if one of them rejects it, the observers that already accepted it are
+// rolled back to the last ConfigMap accepted by all of them, the
+// last-known-good one. The rejection is counted in the configmap_rejections
+// metric and passed to the RejectionHandler. The next update is validated
+// again.
+type ValidatingWatcher struct {
+	Watcher
+
+	onReject RejectionHandler
+}
+
+// NewValidatingWatcher wraps the given watcher. onReject may be nil.
+func NewValidatingWatcher(w Watcher, onReject RejectionHandler) *ValidatingWatcher {
+	return &ValidatingWatcher{
+		Watcher:  w,
+		onReject: onReject,
+	}
+}
+
+// WatchValidated registers the observers of the named ConfigMap. Unlike
+// calling Watch for each observer, all of them are guaranteed to have
+// accepted the same ConfigMap. Each registration keeps its own
+// last-known-good ConfigMap, which the returned function returns, or nil
+// if there is none yet.
+func (w *ValidatingWatcher) WatchValidated(name string, o ...ValidatingObserver) func() *corev1.ConfigMap {
+	// Serializes updates and rollbacks of this set of observers.
+	var m sync.Mutex
+	var lastGood *corev1.ConfigMap
+	w.Watch(name, func(cm *corev1.ConfigMap) {
+		m.Lock()
+		defer m.Unlock()
+
+		for i, observer := range o {
+			if err := observer(cm); err != nil {
+				w.reject(cm, err, lastGood, o[:i])
+				return
+			}
+		}
+		lastGood = cm.DeepCopy()
+	})
+	return func() *corev1.ConfigMap {
+		m.Lock()
+		defer m.Unlock()
+		return lastGood
+	}
+}
+
+// reject rolls back the observers that accepted the ConfigMap and reports
+// the rejection.
+func (w *ValidatingWatcher) reject(cm *corev1.ConfigMap, err error, lastGood *corev1.ConfigMap, accepted []ValidatingObserver) {
+	if lastGood != nil {
+		for _, observer := range accepted {
+			// The last-known-good ConfigMap has been accepted before.
+			observer(lastGood.DeepCopy())
+		}
+	}
+
+	ctx, tagErr := tag.New(context.Background(), tag.Insert(configMapNameKey, cm.Name))
+	if tagErr != nil {
+		ctx = context.Background()
+	}
+	metrics.Record(ctx, rejectedConfigsStat.M(1))
+
+	if w.onReject != nil {
+		w.onReject(cm, err)
+	}
+}
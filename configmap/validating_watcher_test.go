@@ -0,0 +1,163 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmap
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opencensus.io/stats/view"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "knative.dev/pkg/logging/testing"
+)
+
+func configMapWith(value string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "foo",
+		},
+		Data: map[string]string{"key": value},
+	}
+}
+
+// applier records the values it accepted, rejecting "bad" ones.
+type applier struct {
+	applied []string
+}
+
+func (a *applier) observe(cm *corev1.ConfigMap) error {
+	if cm.Data["key"] == "bad" {
+		return errors.New("bad value")
+	}
+	a.applied = append(a.applied, cm.Data["key"])
+	return nil
+}
+
+func rejections() int64 {
+	rows, err := view.RetrieveData(rejectedConfigsStat.Name())
+	if err != nil {
+		return 0
+	}
+	var count int64
+	for _, row := range rows {
+		count += row.Data.(*view.CountData).Value
+	}
+	return count
+}
+
+func TestValidatingWatcher(t *testing.T) {
+	manual := &ManualWatcher{Namespace: "default"}
+	var rejected []error
+	w := NewValidatingWatcher(manual, func(cm *corev1.ConfigMap, err error) {
+		rejected = append(rejected, err)
+	})
+
+	// first accepts everything, second rejects bad values.
+	var first []string
+	second := &applier{}
+	lastKnownGood := w.WatchValidated("foo", func(cm *corev1.ConfigMap) error {
+		first = append(first, cm.Data["key"])
+		return nil
+	}, second.observe)
+
+	before := rejections()
+
+	// Nothing to roll back to yet.
+	manual.OnChange(configMapWith("bad"))
+	if got := lastKnownGood(); got != nil {
+		t.Errorf("LastKnownGood() = %v, want nil", got)
+	}
+
+	manual.OnChange(configMapWith("good"))
+	manual.OnChange(configMapWith("bad"))
+	manual.OnChange(configMapWith("better"))
+
+	if got, want := first, []string{"bad", "good", "bad", "good", "better"}; !cmp.Equal(got, want) {
+		t.Errorf("first observed %v, want %v", got, want)
+	}
+	if got, want := second.applied, []string{"good", "better"}; !cmp.Equal(got, want) {
+		t.Errorf("second observed %v, want %v", got, want)
+	}
+	if got, want := len(rejected), 2; got != want {
+		t.Errorf("Rejections = %d, want %d", got, want)
+	}
+	if got, want := rejections()-before, int64(2); got != want {
+		t.Errorf("Recorded rejections = %d, want %d", got, want)
+	}
+	if got, want := lastKnownGood().Data["key"], "better"; got != want {
+		t.Errorf("LastKnownGood() = %v, want %v", got, want)
+	}
+}
+
+func TestValidatingWatcherIndependentWatches(t *testing.T) {
+	manual := &ManualWatcher{Namespace: "default"}
+	w := NewValidatingWatcher(manual, nil)
+
+	// strict rejects bad values, lenient accepts everything.
+	strict := &applier{}
+	strictLastKnownGood := w.WatchValidated("foo", strict.observe)
+	var lenient []string
+	lenientLastKnownGood := w.WatchValidated("foo", func(cm *corev1.ConfigMap) error {
+		lenient = append(lenient, cm.Data["key"])
+		return nil
+	})
+
+	manual.OnChange(configMapWith("good"))
+	manual.OnChange(configMapWith("bad"))
+
+	if got, want := strict.applied, []string{"good"}; !cmp.Equal(got, want) {
+		t.Errorf("strict observed %v, want %v", got, want)
+	}
+	if got, want := lenient, []string{"good", "bad"}; !cmp.Equal(got, want) {
+		t.Errorf("lenient observed %v, want %v", got, want)
+	}
+	if got, want := strictLastKnownGood().Data["key"], "good"; got != want {
+		t.Errorf("strict LastKnownGood() = %v, want %v", got, want)
+	}
+	if got, want := lenientLastKnownGood().Data["key"], "bad"; got != want {
+		t.Errorf("lenient LastKnownGood() = %v, want %v", got, want)
+	}
+}
+
+func TestStoreWatchConfigsValidated(t *testing.T) {
+	manual := &ManualWatcher{}
+	w := NewValidatingWatcher(manual, nil)
+	store := NewUntypedStore("name", TestLogger(t), Constructors{
+		"foo": func(cm *corev1.ConfigMap) (string, error) {
+			if cm.Data["key"] == "bad" {
+				return "", errors.New("bad value")
+			}
+			return cm.Data["key"], nil
+		},
+	})
+	store.WatchConfigsValidated(w)
+
+	cm := configMapWith("good")
+	cm.Namespace = ""
+	manual.OnChange(cm)
+	cm = configMapWith("bad")
+	cm.Namespace = ""
+	manual.OnChange(cm)
+
+	if got, want := store.UntypedLoad("foo"), "good"; got != want {
+		t.Errorf("UntypedLoad() = %v, want %v", got, want)
+	}
+}
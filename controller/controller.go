@@ -28,12 +28,14 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	k8sclock "k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 
+	"knative.dev/pkg/clock"
 	"knative.dev/pkg/kmeta"
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/logging/logkey"
@@ -133,6 +135,42 @@ type Impl struct {
 
 	// StatsReporter is used to send common controller metrics.
 	statsReporter StatsReporter
+
+	// clock is used to measure the reconciliation latency, and attached
+	// to the context passed to the Reconciler.
+	clock k8sclock.Clock
+
+	// workQueueName is the name of the work queue, used to create a new
+	// one when the controller is restarted.
+	workQueueName string
+	// queueMu guards WorkQueue, which is replaced on restart.
+	queueMu sync.RWMutex
+
+	// runMu guards the run state of the controller below.
+	runMu sync.Mutex
+	// stopCh is closed to stop the running controller, nil if it's not running.
+	stopCh chan struct{}
+	// workers tracks the worker threads of the running controller.
+	workers sync.WaitGroup
+
+	// handlers are the event handlers added through AddEventHandler,
+	// removed when the controller is stopped.
+	handlersMu sync.Mutex
+	handlers   []*HandlerRegistration
+
+	// debugKeys are the keys of the resources enqueued with the
+	// logging.DebugAnnotation, whose reconciliations log at the debug level.
+	debugMu   sync.RWMutex
+	debugKeys map[types.NamespacedName]struct{}
+
+	// kinds are the kinds of resources reconciled next to the primary
+	// one, added through AddKind.
+	kindsMu sync.RWMutex
+	kinds   []*kind
+
+	// inFlight tracks the objects being reconciled, so that they're never
+	// reconciled twice at once across the work queues of the kinds.
+	inFlight inFlight
 }
 
 // NewImpl instantiates an instance of our controller that will feed work to the
@@ -150,9 +188,20 @@ func NewImplWithStats(r Reconciler, logger *zap.SugaredLogger, workQueueName str
 		),
 		logger:        logger,
 		statsReporter: reporter,
+		clock:         k8sclock.RealClock{},
+		workQueueName: workQueueName,
 	}
 }
 
+// NewImplWithContext is like NewImpl, but uses the clock attached to the
+// context, see clock.WithClock. The clock is passed on to the Reconciler
+// through the context of each reconciliation.
+func NewImplWithContext(ctx context.Context, r Reconciler, logger *zap.SugaredLogger, workQueueName string) *Impl {
+	impl := NewImpl(r, logger, workQueueName)
+	impl.clock = clock.FromContext(ctx)
+	return impl
+}
+
 // EnqueueAfter takes a resource, converts it into a namespace/name string,
 // and passes it to EnqueueKey.
 func (c *Impl) EnqueueAfter(obj interface{}, after time.Duration) {
@@ -161,7 +210,10 @@ func (c *Impl) EnqueueAfter(obj interface{}, after time.Duration) {
 		c.logger.Errorw("Enqueue", zap.Error(err))
 		return
 	}
-	c.EnqueueKeyAfter(types.NamespacedName{Namespace: object.GetNamespace(), Name: object.GetName()}, after)
+	key := types.NamespacedName{Namespace: object.GetNamespace(), Name: object.GetName()}
+	c.trackDebug(key, obj, object)
+	c.inFlight.track(c.workQueueName, key, obj, object)
+	c.EnqueueKeyAfter(key, after)
 }
 
 // Enqueue takes a resource, converts it into a namespace/name string,
@@ -172,7 +224,39 @@ func (c *Impl) Enqueue(obj interface{}) {
 		c.logger.Errorw("Enqueue", zap.Error(err))
 		return
 	}
-	c.EnqueueKey(types.NamespacedName{Namespace: object.GetNamespace(), Name: object.GetName()})
+	key := types.NamespacedName{Namespace: object.GetNamespace(), Name: object.GetName()}
+	c.trackDebug(key, obj, object)
+	c.inFlight.track(c.workQueueName, key, obj, object)
+	c.EnqueueKey(key)
+}
+
+// trackDebug records whether the reconciliations of the enqueued resource
+// should log at the debug level, as requested by its logging.DebugAnnotation.
+// The resources enqueued by key keep the setting of the last state enqueued
+// through Enqueue or EnqueueAfter, which is cleared by a deletion tombstone.
+func (c *Impl) trackDebug(key types.NamespacedName, obj interface{}, object metav1.Object) {
+	_, tombstone := obj.(cache.DeletedFinalStateUnknown)
+	debug := !tombstone && logging.IsDebugEnabled(object)
+
+	c.debugMu.Lock()
+	defer c.debugMu.Unlock()
+	if !debug {
+		delete(c.debugKeys, key)
+		return
+	}
+	if c.debugKeys == nil {
+		c.debugKeys = make(map[types.NamespacedName]struct{})
+	}
+	c.debugKeys[key] = struct{}{}
+}
+
+// isDebug returns whether the reconciliations of the resource with the
+// given key should log at the debug level.
+func (c *Impl) isDebug(key types.NamespacedName) bool {
+	c.debugMu.RLock()
+	defer c.debugMu.RUnlock()
+	_, ok := c.debugKeys[key]
+	return ok
 }
 
 // EnqueueControllerOf takes a resource, identifies its controller resource,
@@ -256,72 +340,176 @@ func (c *Impl) EnqueueLabelOfClusterScopedResource(nameLabel string) func(obj in
 
 // EnqueueKey takes a namespace/name string and puts it onto the work queue.
 func (c *Impl) EnqueueKey(key types.NamespacedName) {
-	c.WorkQueue.Add(key)
-	c.logger.Debugf("Adding to queue %s (depth: %d)", safeKey(key), c.WorkQueue.Len())
+	c.workQueue().Add(key)
+	c.logger.Debugf("Adding to queue %s (depth: %d)", safeKey(key), c.workQueue().Len())
 }
 
 // EnqueueKeyAfter takes a namespace/name string and schedules its execution in
 // the work queue after given delay.
 func (c *Impl) EnqueueKeyAfter(key types.NamespacedName, delay time.Duration) {
-	c.WorkQueue.AddAfter(key, delay)
-	c.logger.Debugf("Adding to queue %s (delay: %v, depth: %d)", safeKey(key), delay, c.WorkQueue.Len())
+	c.workQueue().AddAfter(key, delay)
+	c.logger.Debugf("Adding to queue %s (delay: %v, depth: %d)", safeKey(key), delay, c.workQueue().Len())
 }
 
 // Run starts the controller's worker threads, the number of which is threadiness.
-// It then blocks until stopCh is closed, at which point it shuts down its internal
-// work queue and waits for workers to finish processing their current work items.
+// It then blocks until stopCh is closed or Stop is called, at which point it shuts
+// down its internal work queue and waits for workers to finish processing their
+// current work items.
 func (c *Impl) Run(threadiness int, stopCh <-chan struct{}) error {
 	defer runtime.HandleCrash()
-	sg := sync.WaitGroup{}
-	defer sg.Wait()
-	defer func() {
-		c.WorkQueue.ShutDown()
-		for c.WorkQueue.Len() > 0 {
-			time.Sleep(time.Millisecond * 100)
-		}
-	}()
+	stopped, err := c.start(threadiness)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-stopCh:
+		c.Stop()
+	case <-stopped:
+		// Wait for Stop to finish shutting down the workers.
+		c.runMu.Lock()
+		c.runMu.Unlock()
+	}
+	return nil
+}
+
+// Start starts the controller's worker threads in the background, the number
+// of which is threadiness, and returns without blocking. The controller runs
+// until Stop is called, after which it can be started again, e.g. when a feature
+// flag re-enables its reconciler.
+func (c *Impl) Start(threadiness int) error {
+	_, err := c.start(threadiness)
+	return err
+}
+
+func (c *Impl) start(threadiness int) (<-chan struct{}, error) {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+	if c.stopCh != nil {
+		return nil, fmt.Errorf("controller %q is already running", c.workQueueName)
+	}
+
+	// The work queue is shut down when the controller stops, so create a new
+	// one to restart it. Keys enqueued while the controller was stopped were
+	// dropped, so informers must be resynced to catch up on missed events.
+	if c.workQueue().ShuttingDown() {
+		c.queueMu.Lock()
+		c.WorkQueue = workqueue.NewNamedRateLimitingQueue(
+			workqueue.DefaultControllerRateLimiter(),
+			c.workQueueName,
+		)
+		c.queueMu.Unlock()
+	}
+	c.stopCh = make(chan struct{})
 
 	// Launch workers to process resources that get enqueued to our workqueue.
 	logger := c.logger
 	logger.Info("Starting controller and workers")
+	if kinds := c.restartKinds(); len(kinds) > 0 {
+		c.startKinds(threadiness, kinds)
+		logger.Info("Started workers")
+		return c.stopCh, nil
+	}
 	for i := 0; i < threadiness; i++ {
-		sg.Add(1)
+		c.workers.Add(1)
 		go func() {
-			defer sg.Done()
+			defer c.workers.Done()
 			for c.processNextWorkItem() {
 			}
 		}()
 	}
-
 	logger.Info("Started workers")
-	<-stopCh
-	logger.Info("Shutting down workers")
 
-	return nil
+	return c.stopCh, nil
+}
+
+// Stop stops the running controller. It removes the event handlers added through
+// AddEventHandler, shuts down its internal work queues and blocks until the workers
+// finish processing their current work items. Other event handlers enqueueing into
+// the controller are no-ops until it is restarted.
+// Stop does nothing if the controller is not running.
+func (c *Impl) Stop() {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+	if c.stopCh == nil {
+		return
+	}
+	c.logger.Info("Shutting down workers")
+	close(c.stopCh)
+	c.stopCh = nil
+	c.removeEventHandlers()
+
+	queues := []workqueue.RateLimitingInterface{c.workQueue()}
+	c.kindsMu.RLock()
+	for _, k := range c.kinds {
+		queues = append(queues, k.queue)
+	}
+	c.kindsMu.RUnlock()
+	for _, wq := range queues {
+		wq.ShutDown()
+	}
+	for _, wq := range queues {
+		for wq.Len() > 0 {
+			time.Sleep(time.Millisecond * 100)
+		}
+	}
+	c.workers.Wait()
+}
+
+// Running returns whether the controller is running.
+func (c *Impl) Running() bool {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+	return c.stopCh != nil
+}
+
+// workQueue returns the current work queue of the controller.
+func (c *Impl) workQueue() workqueue.RateLimitingInterface {
+	c.queueMu.RLock()
+	defer c.queueMu.RUnlock()
+	return c.WorkQueue
 }
 
 // processNextWorkItem will read a single work item off the workqueue and
 // attempt to process it, by calling Reconcile on our Reconciler.
 func (c *Impl) processNextWorkItem() bool {
-	obj, shutdown := c.WorkQueue.Get()
+	wq := c.workQueue()
+	obj, shutdown := wq.Get()
 	if shutdown {
 		return false
 	}
-	key := obj.(types.NamespacedName)
+	// Send the metrics for the current queue depth
+	c.statsReporter.ReportQueueDepth(int64(wq.Len()))
+	c.reconcile(c.workQueueName, wq, c.Reconciler, obj.(types.NamespacedName))
+	return true
+}
+
+// reconcile processes the key taken from the given work queue of the lane, by
+// calling Reconcile on the given Reconciler. If the object of the key is being
+// reconciled by another worker, the key is enqueued again once it's done.
+func (c *Impl) reconcile(lane string, wq workqueue.RateLimitingInterface, r Reconciler, key types.NamespacedName) {
 	keyStr := safeKey(key)
 
-	c.logger.Debugf("Processing from queue %s (depth: %d)", safeKey(key), c.WorkQueue.Len())
+	id, enqueued := c.inFlight.identity(lane, key)
+	if !c.inFlight.acquire(id, deferredKey{queue: wq, key: key}) {
+		wq.Done(key)
+		c.logger.Debugf("Deferring %s from the %s queue, which is being reconciled", keyStr, lane)
+		if r, ok := c.statsReporter.(DuplicateStatsReporter); ok {
+			r.ReportSuppressedDuplicate(lane)
+		}
+		return
+	}
+	defer c.inFlight.release(id)
+
+	c.logger.Debugf("Processing from queue %s (depth: %d)", safeKey(key), wq.Len())
 
-	startTime := time.Now()
-	// Send the metrics for the current queue depth
-	c.statsReporter.ReportQueueDepth(int64(c.WorkQueue.Len()))
+	startTime := c.clock.Now()
 
 	// We call Done here so the workqueue knows we have finished
 	// processing this item. We also must remember to call Forget if
 	// reconcile succeeds. If a transient error occurs, we do not call
 	// Forget and put the item back to the queue with an increased
 	// delay.
-	defer c.WorkQueue.Done(key)
+	defer wq.Done(key)
 
 	var err error
 	defer func() {
@@ -329,44 +517,51 @@ func (c *Impl) processNextWorkItem() bool {
 		if err != nil {
 			status = falseString
 		}
-		c.statsReporter.ReportReconcile(time.Since(startTime), keyStr, status)
+		c.statsReporter.ReportReconcile(c.clock.Since(startTime), keyStr, status)
 	}()
 
 	// Embed the key into the logger and attach that to the context we pass
 	// to the Reconciler.
 	logger := c.logger.With(zap.String(logkey.TraceId, uuid.New().String()), zap.String(logkey.Key, keyStr))
-	ctx := logging.WithLogger(context.TODO(), logger)
+	if c.isDebug(key) {
+		logger = logging.WithDebugLevel(logger)
+	}
+	ctx := logging.WithLogger(clock.WithClock(context.TODO(), c.clock), logger)
 
 	// Run Reconcile, passing it the namespace/name string of the
 	// resource to be synced.
-	if err = c.Reconciler.Reconcile(ctx, keyStr); err != nil {
-		c.handleErr(err, key)
+	if err = r.Reconcile(ctx, keyStr); err != nil {
+		if !c.handleErr(wq, err, key) {
+			c.inFlight.forget(lane, key, enqueued)
+		}
 		logger.Infof("Reconcile failed. Time taken: %v.", time.Since(startTime))
-		return true
+		return
 	}
 
 	// Finally, if no error occurs we Forget this item so it does not
 	// have any delay when another change happens.
-	c.WorkQueue.Forget(key)
+	wq.Forget(key)
+	c.inFlight.forget(lane, key, enqueued)
 	logger.Infof("Reconcile succeeded. Time taken: %v.", time.Since(startTime))
-
-	return true
 }
 
-func (c *Impl) handleErr(err error, key types.NamespacedName) {
+// handleErr handles the error of the reconciliation of the key, and returns
+// whether the key was enqueued again.
+func (c *Impl) handleErr(wq workqueue.RateLimitingInterface, err error, key types.NamespacedName) bool {
 	c.logger.Errorw("Reconcile error", zap.Error(err))
 
 	// Re-queue the key if it's an transient error.
 	// We want to check that the queue is shutting down here
 	// since controller Run might have exited by now (since while this item was
 	// being processed, queue.Len==0).
-	if !IsPermanentError(err) && !c.WorkQueue.ShuttingDown() {
-		c.WorkQueue.AddRateLimited(key)
-		c.logger.Debugf("Requeuing key %s due to non-permanent error (depth: %d)", safeKey(key), c.WorkQueue.Len())
-		return
+	if !IsPermanentError(err) && !wq.ShuttingDown() {
+		wq.AddRateLimited(key)
+		c.logger.Debugf("Requeuing key %s due to non-permanent error (depth: %d)", safeKey(key), wq.Len())
+		return true
 	}
 
-	c.WorkQueue.Forget(key)
+	wq.Forget(key)
+	return false
 }
 
 // GlobalResync enqueues (with a delay) all objects from the passed SharedInformer
@@ -378,7 +573,7 @@ func (c *Impl) GlobalResync(si cache.SharedInformer) {
 // FilteredGlobalResync enqueues (with a delay) all objects from the
 // SharedInformer that pass the filter function
 func (c *Impl) FilteredGlobalResync(f func(interface{}) bool, si cache.SharedInformer) {
-	if c.WorkQueue.ShuttingDown() {
+	if c.workQueue().ShuttingDown() {
 		return
 	}
 	list := si.GetStore().List()
@@ -500,6 +695,31 @@ func GetResyncPeriod(ctx context.Context) time.Duration {
 	return rp.(time.Duration)
 }
 
+// This is attached to contexts to associate resync periods with the
+// informers of particular types.
+type resyncPeriodsKey struct{}
+
+// WithResyncPeriodFor associates the given resync period with the informers
+// of the type of obj, e.g. &corev1.Secret{}, overriding the resync period of
+// the context for them.
+func WithResyncPeriodFor(ctx context.Context, obj metav1.Object, resync time.Duration) context.Context {
+	existing := GetResyncPeriods(ctx)
+	periods := make(map[metav1.Object]time.Duration, len(existing)+1)
+	for o, p := range existing {
+		periods[o] = p
+	}
+	periods[obj] = resync
+	return context.WithValue(ctx, resyncPeriodsKey{}, periods)
+}
+
+// GetResyncPeriods returns the resync periods associated with the informers of
+// particular types by WithResyncPeriodFor. The result is suitable to be passed
+// to the WithCustomResyncConfig option of shared informer factories.
+func GetResyncPeriods(ctx context.Context) map[metav1.Object]time.Duration {
+	periods, _ := ctx.Value(resyncPeriodsKey{}).(map[metav1.Object]time.Duration)
+	return periods
+}
+
 // GetTrackerLease fetches the tracker lease from the controller context.
 func GetTrackerLease(ctx context.Context) time.Duration {
 	return 3 * GetResyncPeriod(ctx)
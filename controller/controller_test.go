@@ -24,14 +24,20 @@ import (
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 
+	"knative.dev/pkg/clock"
 	. "knative.dev/pkg/controller/testing"
+	"knative.dev/pkg/logging"
 	. "knative.dev/pkg/logging/testing"
 	. "knative.dev/pkg/testing"
 )
@@ -568,6 +574,90 @@ func (cr *CountingReconciler) Reconcile(context.Context, string) error {
 	return nil
 }
 
+// DebugReconciler records whether the logger of each reconciliation
+// logs at the debug level.
+type DebugReconciler struct {
+	Debug map[string]bool
+}
+
+func (dr *DebugReconciler) Reconcile(ctx context.Context, key string) error {
+	dr.Debug[key] = logging.FromContext(ctx).Desugar().Core().Enabled(zapcore.DebugLevel)
+	return nil
+}
+
+func TestDebugAnnotation(t *testing.T) {
+	debug := map[string]string{logging.DebugAnnotation: "true"}
+	resource := func(annotations map[string]string) *Resource {
+		return &Resource{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "bar",
+				Name:        "foo",
+				Annotations: annotations,
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		enqueue func(*Impl)
+		want    bool
+	}{{
+		name: "not annotated",
+		enqueue: func(impl *Impl) {
+			impl.Enqueue(resource(nil))
+		},
+	}, {
+		name: "annotated",
+		enqueue: func(impl *Impl) {
+			impl.Enqueue(resource(debug))
+		},
+		want: true,
+	}, {
+		name: "annotated with a delay",
+		enqueue: func(impl *Impl) {
+			impl.EnqueueAfter(resource(debug), 0)
+		},
+		want: true,
+	}, {
+		name: "annotated then enqueued by key",
+		enqueue: func(impl *Impl) {
+			impl.Enqueue(resource(debug))
+			impl.EnqueueKey(types.NamespacedName{Namespace: "bar", Name: "foo"})
+		},
+		want: true,
+	}, {
+		name: "annotation removed",
+		enqueue: func(impl *Impl) {
+			impl.Enqueue(resource(debug))
+			impl.Enqueue(resource(nil))
+		},
+	}, {
+		name: "deleted",
+		enqueue: func(impl *Impl) {
+			impl.Enqueue(resource(debug))
+			impl.Enqueue(cache.DeletedFinalStateUnknown{Key: "bar/foo", Obj: resource(debug)})
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &DebugReconciler{Debug: map[string]bool{}}
+			// The controller's logger doesn't log at the debug level.
+			impl := NewImplWithStats(r, zap.NewNop().Sugar(), "Testing", &FakeStatsReporter{})
+			test.enqueue(impl)
+			if err := wait.PollImmediate(time.Millisecond, time.Second, func() (bool, error) {
+				return impl.WorkQueue.Len() == 1, nil
+			}); err != nil {
+				t.Fatalf("Len() = %d, wanted 1", impl.WorkQueue.Len())
+			}
+			impl.processNextWorkItem()
+			if got := r.Debug["bar/foo"]; got != test.want {
+				t.Errorf("Debug = %v, wanted %v", got, test.want)
+			}
+		})
+	}
+}
+
 func TestStartAndShutdown(t *testing.T) {
 	defer ClearAll()
 	r := &CountingReconciler{}
@@ -642,6 +732,102 @@ func TestStartAndShutdownWithWork(t *testing.T) {
 	checkStats(t, reporter, 1, 0, 1, trueString)
 }
 
+func TestStopAndRestart(t *testing.T) {
+	defer ClearAll()
+	r := &CountingReconciler{}
+	impl := NewImplWithStats(r, TestLogger(t), "Testing", &FakeStatsReporter{})
+	key := types.NamespacedName{Namespace: "foo", Name: "bar"}
+	count := func() int {
+		r.m.Lock()
+		defer r.m.Unlock()
+		return r.Count
+	}
+	waitForCount := func(want int) {
+		t.Helper()
+		if err := wait.PollImmediate(time.Millisecond, time.Second, func() (bool, error) {
+			return count() == want, nil
+		}); err != nil {
+			t.Fatalf("Count = %d, wanted %d", count(), want)
+		}
+	}
+
+	if err := impl.Start(1); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+	if err := impl.Start(1); err == nil {
+		t.Error("Start() = nil, wanted an error when already running")
+	}
+	if !impl.Running() {
+		t.Error("Running() = false after Start")
+	}
+	impl.EnqueueKey(key)
+	waitForCount(1)
+
+	impl.Stop()
+	if impl.Running() {
+		t.Error("Running() = true after Stop")
+	}
+	// Keys enqueued while stopped are dropped.
+	impl.EnqueueKey(key)
+	// Stopping again is a no-op.
+	impl.Stop()
+
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		impl.Run(1, stopCh)
+	}()
+	if err := wait.PollImmediate(time.Millisecond, time.Second, func() (bool, error) {
+		return impl.Running(), nil
+	}); err != nil {
+		t.Fatal("Controller wasn't restarted")
+	}
+	impl.EnqueueKey(key)
+	waitForCount(2)
+
+	// Stopping the controller makes Run return.
+	impl.Stop()
+	select {
+	case <-time.After(time.Second):
+		t.Error("Timed out waiting for Run to return.")
+	case <-doneCh:
+	}
+	close(stopCh)
+	if got, want := count(), 2; got != want {
+		t.Errorf("Count = %d, wanted %d", got, want)
+	}
+}
+
+// SteppingReconciler steps the fake clock passed through the context.
+type SteppingReconciler struct {
+	step time.Duration
+}
+
+func (sr *SteppingReconciler) Reconcile(ctx context.Context, _ string) error {
+	clock.FromContext(ctx).(*clock.Fake).Step(sr.step)
+	return nil
+}
+
+func TestNewImplWithContext(t *testing.T) {
+	fc := clock.NewFake(time.Now())
+	ctx := clock.WithClock(context.Background(), fc)
+	impl := NewImplWithContext(ctx, &SteppingReconciler{step: 5 * time.Second}, TestLogger(t), "Testing")
+	reporter := &FakeStatsReporter{}
+	impl.statsReporter = reporter
+
+	impl.EnqueueKey(types.NamespacedName{Namespace: "foo", Name: "bar"})
+	impl.processNextWorkItem()
+
+	data := reporter.GetReconcileData()
+	if len(data) != 1 {
+		t.Fatalf("Reported %d reconciliations, want 1", len(data))
+	}
+	if got, want := data[0].Duration, 5*time.Second; got != want {
+		t.Errorf("Reconcile duration = %v, want %v", got, want)
+	}
+}
+
 type ErrorReconciler struct{}
 
 func (er *ErrorReconciler) Reconcile(context.Context, string) error {
@@ -1108,6 +1294,25 @@ func TestGetResyncPeriod(t *testing.T) {
 	}
 }
 
+func TestGetResyncPeriods(t *testing.T) {
+	ctx := context.Background()
+
+	if got := GetResyncPeriods(ctx); len(got) != 0 {
+		t.Errorf("GetResyncPeriods() = %v, wanted none", got)
+	}
+
+	secret, pod := &corev1.Secret{}, &corev1.Pod{}
+	withSecret := WithResyncPeriodFor(ctx, secret, time.Minute)
+	withBoth := WithResyncPeriodFor(withSecret, pod, time.Hour)
+
+	if got := GetResyncPeriods(withSecret); len(got) != 1 || got[secret] != time.Minute {
+		t.Errorf("GetResyncPeriods() = %v, wanted only the secret's", got)
+	}
+	if got := GetResyncPeriods(withBoth); len(got) != 2 || got[secret] != time.Minute || got[pod] != time.Hour {
+		t.Errorf("GetResyncPeriods() = %v, wanted the secret's and the pod's", got)
+	}
+}
+
 func TestGetEventRecorder(t *testing.T) {
 	ctx := context.Background()
 
@@ -0,0 +1,129 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1beta1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"knative.dev/pkg/logging"
+)
+
+var (
+	// crdPollInterval is the interval at which the CRDs are checked for
+	// being established.
+	crdPollInterval = time.Second
+
+	// crdReportInterval is the interval at which the CRDs which aren't
+	// established yet are logged.
+	crdReportInterval = 10 * time.Second
+)
+
+// CRDStatus is the status of a CustomResourceDefinition a controller
+// requires.
+type CRDStatus struct {
+	// Name is the name of the CRD, e.g. "services.serving.knative.dev".
+	Name string
+	// Established is whether the CRD is installed and served.
+	Established bool
+	// Reason describes why the CRD isn't established yet.
+	Reason string
+}
+
+// CRDStatuses returns the status of each of the named CRDs.
+func CRDStatuses(client apiextensionsclient.CustomResourceDefinitionsGetter, names ...string) []CRDStatus {
+	statuses := make([]CRDStatus, len(names))
+	for i, name := range names {
+		statuses[i] = crdStatus(client, name)
+	}
+	return statuses
+}
+
+func crdStatus(client apiextensionsclient.CustomResourceDefinitionsGetter, name string) CRDStatus {
+	status := CRDStatus{Name: name}
+	crd, err := client.CustomResourceDefinitions().Get(name, metav1.GetOptions{})
+	switch {
+	case apierrs.IsNotFound(err):
+		status.Reason = "not found"
+		return status
+	case err != nil:
+		status.Reason = err.Error()
+		return status
+	}
+	for _, cond := range crd.Status.Conditions {
+		if cond.Type != apiextensionsv1beta1.Established {
+			continue
+		}
+		if cond.Status == apiextensionsv1beta1.ConditionTrue {
+			status.Established = true
+			return status
+		}
+		status.Reason = fmt.Sprintf("not established: %s", cond.Message)
+		return status
+	}
+	status.Reason = "not established yet"
+	return status
+}
+
+// WaitForCRDs blocks until all of the named CRDs are established, so that the
+// informers of the controllers are only set up once the resources they watch
+// are served, e.g. when the CRDs are installed concurrently with the
+// controllers. The CRDs which aren't established yet are logged periodically.
+// It returns an error naming them if they aren't all established within the
+// timeout, or when the context is done.
+func WaitForCRDs(ctx context.Context, client apiextensionsclient.CustomResourceDefinitionsGetter, timeout time.Duration, names ...string) error {
+	logger := logging.FromContext(ctx)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var pending []CRDStatus
+	lastReport := time.Now()
+	err := wait.PollImmediateUntil(crdPollInterval, func() (bool, error) {
+		pending = pending[:0]
+		for _, s := range CRDStatuses(client, names...) {
+			if !s.Established {
+				pending = append(pending, s)
+			}
+		}
+		if len(pending) == 0 {
+			return true, nil
+		}
+		if time.Since(lastReport) >= crdReportInterval {
+			lastReport = time.Now()
+			for _, s := range pending {
+				logger.Infof("Waiting for the CRD %q: %s", s.Name, s.Reason)
+			}
+		}
+		return false, nil
+	}, ctx.Done())
+	if err != nil {
+		reasons := make([]string, len(pending))
+		for i, s := range pending {
+			reasons[i] = fmt.Sprintf("%s (%s)", s.Name, s.Reason)
+		}
+		return fmt.Errorf("timed out waiting for the CRDs to be established: %v", reasons)
+	}
+	logger.Infof("The CRDs %v are established", names)
+	return nil
+}
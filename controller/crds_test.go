@@ -0,0 +1,102 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "knative.dev/pkg/logging/testing"
+)
+
+func crd(name string, established apiextensionsv1beta1.ConditionStatus) *apiextensionsv1beta1.CustomResourceDefinition {
+	c := &apiextensionsv1beta1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+	}
+	if established != "" {
+		c.Status.Conditions = []apiextensionsv1beta1.CustomResourceDefinitionCondition{{
+			Type:    apiextensionsv1beta1.Established,
+			Status:  established,
+			Message: "installing",
+		}}
+	}
+	return c
+}
+
+func TestCRDStatuses(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		crd("established.knative.dev", apiextensionsv1beta1.ConditionTrue),
+		crd("installing.knative.dev", apiextensionsv1beta1.ConditionFalse),
+		crd("new.knative.dev", ""),
+	).ApiextensionsV1beta1()
+
+	got := CRDStatuses(client, "established.knative.dev", "installing.knative.dev", "new.knative.dev", "missing.knative.dev")
+	want := []CRDStatus{{
+		Name:        "established.knative.dev",
+		Established: true,
+	}, {
+		Name:   "installing.knative.dev",
+		Reason: "not established: installing",
+	}, {
+		Name:   "new.knative.dev",
+		Reason: "not established yet",
+	}, {
+		Name:   "missing.knative.dev",
+		Reason: "not found",
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("CRDStatuses (-want, +got): %s", diff)
+	}
+}
+
+func TestWaitForCRDs(t *testing.T) {
+	defer func(poll, report time.Duration) {
+		crdPollInterval, crdReportInterval = poll, report
+	}(crdPollInterval, crdReportInterval)
+	crdPollInterval, crdReportInterval = time.Millisecond, 0
+
+	ctx := TestContextWithLogger(t)
+	clientset := fake.NewSimpleClientset(crd("established.knative.dev", apiextensionsv1beta1.ConditionTrue))
+	client := clientset.ApiextensionsV1beta1()
+
+	if err := WaitForCRDs(ctx, client, time.Second, "established.knative.dev"); err != nil {
+		t.Errorf("WaitForCRDs() = %v", err)
+	}
+
+	// The CRD is installed while waiting.
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		client.CustomResourceDefinitions().Create(crd("late.knative.dev", apiextensionsv1beta1.ConditionTrue))
+	}()
+	if err := WaitForCRDs(ctx, client, 5*time.Second, "established.knative.dev", "late.knative.dev"); err != nil {
+		t.Errorf("WaitForCRDs() = %v", err)
+	}
+
+	err := WaitForCRDs(ctx, client, 20*time.Millisecond, "established.knative.dev", "missing.knative.dev")
+	if err == nil {
+		t.Fatal("WaitForCRDs() = nil, wanted a timeout")
+	}
+	if got, want := err.Error(), "[missing.knative.dev (not found)]"; !strings.Contains(got, want) {
+		t.Errorf("WaitForCRDs() = %q, wanted it to contain %q", got, want)
+	}
+}
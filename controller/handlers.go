@@ -0,0 +1,103 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sync"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// HandlerRegistrar is the subset of cache.SharedInformer event handlers are
+// registered with.
+type HandlerRegistrar interface {
+	AddEventHandler(handler cache.ResourceEventHandler)
+}
+
+// HandlerRegistration is an event handler registered through a controller.
+// It forwards events to the handler until it's removed.
+type HandlerRegistration struct {
+	mu      sync.RWMutex
+	handler cache.ResourceEventHandler
+}
+
+var _ cache.ResourceEventHandler = (*HandlerRegistration)(nil)
+
+func (r *HandlerRegistration) get() cache.ResourceEventHandler {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.handler
+}
+
+// OnAdd implements cache.ResourceEventHandler
+func (r *HandlerRegistration) OnAdd(obj interface{}) {
+	if h := r.get(); h != nil {
+		h.OnAdd(obj)
+	}
+}
+
+// OnUpdate implements cache.ResourceEventHandler
+func (r *HandlerRegistration) OnUpdate(oldObj, newObj interface{}) {
+	if h := r.get(); h != nil {
+		h.OnUpdate(oldObj, newObj)
+	}
+}
+
+// OnDelete implements cache.ResourceEventHandler
+func (r *HandlerRegistration) OnDelete(obj interface{}) {
+	if h := r.get(); h != nil {
+		h.OnDelete(obj)
+	}
+}
+
+// Removed returns whether the registration was removed.
+func (r *HandlerRegistration) Removed() bool {
+	return r.get() == nil
+}
+
+// remove stops forwarding events to the handler.
+// The informers in our version of client-go can't remove event handlers, so the
+// registration itself stays with the informer, but it releases the handler and
+// everything it references, e.g. the controller and its work queue.
+func (r *HandlerRegistration) remove() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handler = nil
+}
+
+// AddEventHandler registers the handler with the informer on behalf of the
+// controller. The handler is removed when the controller is stopped, so
+// controllers created and stopped at runtime don't leak handlers on long-lived
+// shared informers. Handlers must be added again when the controller is restarted.
+func (c *Impl) AddEventHandler(informer HandlerRegistrar, handler cache.ResourceEventHandler) *HandlerRegistration {
+	r := &HandlerRegistration{handler: handler}
+	c.handlersMu.Lock()
+	c.handlers = append(c.handlers, r)
+	c.handlersMu.Unlock()
+	informer.AddEventHandler(r)
+	return r
+}
+
+// removeEventHandlers removes the event handlers added through AddEventHandler.
+func (c *Impl) removeEventHandlers() {
+	c.handlersMu.Lock()
+	defer c.handlersMu.Unlock()
+	for _, r := range c.handlers {
+		r.remove()
+	}
+	c.handlers = nil
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/client-go/tools/cache"
+
+	. "knative.dev/pkg/controller/testing"
+	. "knative.dev/pkg/logging/testing"
+)
+
+type fakeRegistrar struct {
+	handlers []cache.ResourceEventHandler
+}
+
+func (fr *fakeRegistrar) AddEventHandler(h cache.ResourceEventHandler) {
+	fr.handlers = append(fr.handlers, h)
+}
+
+func (fr *fakeRegistrar) add(obj interface{}) {
+	for _, h := range fr.handlers {
+		h.OnAdd(obj)
+	}
+}
+
+func TestAddEventHandler(t *testing.T) {
+	defer ClearAll()
+	impl := NewImplWithStats(&NopReconciler{}, TestLogger(t), "Testing", &FakeStatsReporter{})
+	informer := &fakeRegistrar{}
+
+	var adds, updates, deletes int
+	r := impl.AddEventHandler(informer, cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { adds++ },
+		UpdateFunc: func(interface{}, interface{}) { updates++ },
+		DeleteFunc: func(interface{}) { deletes++ },
+	})
+	if got, want := len(informer.handlers), 1; got != want {
+		t.Fatalf("Registered handlers = %d, want %d", got, want)
+	}
+
+	r.OnAdd("foo")
+	r.OnUpdate("foo", "bar")
+	r.OnDelete("bar")
+	if adds != 1 || updates != 1 || deletes != 1 {
+		t.Errorf("Events (add, update, delete) = (%d, %d, %d), want (1, 1, 1)", adds, updates, deletes)
+	}
+
+	if err := impl.Start(1); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+	informer.add("foo")
+	impl.Stop()
+	if !r.Removed() {
+		t.Error("Removed() = false after the controller stopped")
+	}
+	informer.add("foo")
+	if got, want := adds, 2; got != want {
+		t.Errorf("Adds = %d, want %d", got, want)
+	}
+
+	// Handlers added after a restart are removed on the next stop too.
+	if err := impl.Start(1); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+	r = impl.AddEventHandler(informer, cache.ResourceEventHandlerFuncs{
+		AddFunc: func(interface{}) { adds++ },
+	})
+	informer.add("foo")
+	impl.Stop()
+	informer.add("foo")
+	if got, want := adds, 3; got != want {
+		t.Errorf("Adds = %d, want %d", got, want)
+	}
+	if !r.Removed() {
+		t.Error("Removed() = false after the controller stopped again")
+	}
+}
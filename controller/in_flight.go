@@ -0,0 +1,141 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// laneKey is a key enqueued into the work queue of a kind, the lane.
+type laneKey struct {
+	lane string
+	key  types.NamespacedName
+}
+
+// deferredKey is a key taken off a work queue while its object was being
+// reconciled, to be enqueued again once it's done.
+type deferredKey struct {
+	queue workqueue.RateLimitingInterface
+	key   types.NamespacedName
+}
+
+// inFlight tracks the objects being reconciled, so that an object is never
+// reconciled by two workers at once. A work queue doesn't hand out a key
+// again until it's done, but an object can be enqueued into the work queues
+// of several kinds, see Impl.AddKind. The objects are identified by their UID,
+// which is recorded when they're enqueued through Enqueue, EnqueueAfter or
+// EnqueueKind, and forgotten once the key is reconciled without having been
+// enqueued again meanwhile. The keys enqueued without their object are only
+// deduplicated within their work queue.
+type inFlight struct {
+	mu sync.Mutex
+	// uids are the UIDs of the objects of the keys, by lane.
+	uids map[laneKey]trackedUID
+	// running are the identities of the objects being reconciled, with the
+	// keys of the same objects taken off the work queues meanwhile.
+	running map[interface{}][]deferredKey
+}
+
+// trackedUID is the UID of the object of a key, along with the number of
+// times the object was enqueued.
+type trackedUID struct {
+	uid      types.UID
+	enqueued uint64
+}
+
+// track records the UID of the object enqueued with the key into the lane,
+// or forgets it if the object was deleted.
+func (f *inFlight) track(lane string, key types.NamespacedName, obj interface{}, object metav1.Object) {
+	lk := laneKey{lane: lane, key: key}
+	_, tombstone := obj.(cache.DeletedFinalStateUnknown)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if tombstone || object.GetUID() == "" {
+		delete(f.uids, lk)
+		return
+	}
+	if f.uids == nil {
+		f.uids = make(map[laneKey]trackedUID)
+	}
+	f.uids[lk] = trackedUID{uid: object.GetUID(), enqueued: f.uids[lk].enqueued + 1}
+}
+
+// identity returns the identity of the object of the key of the lane, which
+// is its UID if it's known, along with the number of times the object was
+// enqueued, to pass to forget once the key is reconciled.
+func (f *inFlight) identity(lane string, key types.NamespacedName) (interface{}, uint64) {
+	lk := laneKey{lane: lane, key: key}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if t, ok := f.uids[lk]; ok {
+		return t.uid, t.enqueued
+	}
+	return lk, 0
+}
+
+// forget forgets the UID of the object of the key of the lane once the key is
+// reconciled and won't be again, unless the object was enqueued again since
+// the given identity was taken.
+func (f *inFlight) forget(lane string, key types.NamespacedName, enqueued uint64) {
+	lk := laneKey{lane: lane, key: key}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if t, ok := f.uids[lk]; ok && t.enqueued == enqueued {
+		delete(f.uids, lk)
+	}
+}
+
+// acquire marks the object of the given identity as being reconciled, and
+// returns true, unless it's already being reconciled. The key is then
+// deferred until the object is released, and false is returned.
+func (f *inFlight) acquire(id interface{}, d deferredKey) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if deferred, ok := f.running[id]; ok {
+		for _, other := range deferred {
+			if other.queue == d.queue && other.key == d.key {
+				return false
+			}
+		}
+		f.running[id] = append(deferred, d)
+		return false
+	}
+	if f.running == nil {
+		f.running = make(map[interface{}][]deferredKey)
+	}
+	f.running[id] = nil
+	return true
+}
+
+// release marks the object of the given identity as reconciled, and enqueues
+// the keys deferred meanwhile again.
+func (f *inFlight) release(id interface{}) {
+	f.mu.Lock()
+	deferred := f.running[id]
+	delete(f.running, id)
+	f.mu.Unlock()
+
+	for _, d := range deferred {
+		d.queue.Add(d.key)
+	}
+}
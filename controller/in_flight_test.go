@@ -0,0 +1,169 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	. "knative.dev/pkg/controller/testing"
+	. "knative.dev/pkg/logging/testing"
+	. "knative.dev/pkg/testing"
+)
+
+func TestInFlightIdentity(t *testing.T) {
+	var f inFlight
+	key := types.NamespacedName{Namespace: "ns", Name: "foo"}
+	obj := &Resource{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "foo", UID: "uid"}}
+
+	if got, _ := f.identity("primary", key); got != (laneKey{lane: "primary", key: key}) {
+		t.Errorf("identity() = %v, want: %v", got, laneKey{lane: "primary", key: key})
+	}
+
+	f.track("primary", key, obj, obj)
+	f.track("child", key, obj, obj)
+	for _, lane := range []string{"primary", "child"} {
+		if got, _ := f.identity(lane, key); got != types.UID("uid") {
+			t.Errorf("identity(%q) = %v, want: uid", lane, got)
+		}
+	}
+
+	// The UID is forgotten once the object is deleted.
+	f.track("child", key, cache.DeletedFinalStateUnknown{Key: "ns/foo", Obj: obj}, obj)
+	if got, _ := f.identity("child", key); got != (laneKey{lane: "child", key: key}) {
+		t.Errorf("identity() = %v, want: %v", got, laneKey{lane: "child", key: key})
+	}
+}
+
+func TestInFlightForget(t *testing.T) {
+	var f inFlight
+	key := types.NamespacedName{Namespace: "ns", Name: "foo"}
+	obj := &Resource{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "foo", UID: "uid"}}
+
+	f.track("primary", key, obj, obj)
+	_, enqueued := f.identity("primary", key)
+	// The object is enqueued again while its key is being reconciled.
+	f.track("primary", key, obj, obj)
+	f.forget("primary", key, enqueued)
+	if got, _ := f.identity("primary", key); got != types.UID("uid") {
+		t.Errorf("identity() = %v after forgetting a stale reconciliation, want: uid", got)
+	}
+
+	_, enqueued = f.identity("primary", key)
+	f.forget("primary", key, enqueued)
+	if len(f.uids) != 0 {
+		t.Errorf("uids = %v after forgetting, want none", f.uids)
+	}
+}
+
+func TestInFlightShrinks(t *testing.T) {
+	r := newRecordingReconcilers(0, "shared")[0]
+	impl := NewImplWithStats(r, TestLogger(t), "Testing", &FakeStatsReporter{})
+	if err := impl.AddKind("fast", r, 0); err != nil {
+		t.Fatalf("AddKind() = %v", err)
+	}
+	for _, name := range []string{"foo", "bar", "baz"} {
+		obj := &Resource{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name, UID: types.UID(name)}}
+		impl.Enqueue(obj)
+		impl.EnqueueKind("fast", obj)
+	}
+	if err := impl.Start(2); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+	defer impl.Stop()
+	waitForReconciled(t, r, 6)
+
+	// The reconciliation is counted before its key is forgotten.
+	if err := wait.PollImmediate(time.Millisecond, 5*time.Second, func() (bool, error) {
+		impl.inFlight.mu.Lock()
+		defer impl.inFlight.mu.Unlock()
+		return len(impl.inFlight.uids) == 0, nil
+	}); err != nil {
+		impl.inFlight.mu.Lock()
+		defer impl.inFlight.mu.Unlock()
+		t.Errorf("uids = %v once reconciled, want none", impl.inFlight.uids)
+	}
+}
+
+func TestInFlightAcquireRelease(t *testing.T) {
+	var f inFlight
+	wq := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer wq.ShutDown()
+	key := types.NamespacedName{Namespace: "ns", Name: "foo"}
+	d := deferredKey{queue: wq, key: key}
+
+	if !f.acquire(types.UID("uid"), d) {
+		t.Fatal("acquire() = false, wanted true")
+	}
+	// The same key is deferred only once.
+	for i := 0; i < 2; i++ {
+		if f.acquire(types.UID("uid"), d) {
+			t.Fatal("acquire() = true while running, wanted false")
+		}
+	}
+	if !f.acquire(types.UID("other"), d) {
+		t.Error("acquire() = false for another object, wanted true")
+	}
+	if got := wq.Len(); got != 0 {
+		t.Errorf("Len() = %d before release, want 0", got)
+	}
+
+	f.release(types.UID("uid"))
+	if got := wq.Len(); got != 1 {
+		t.Errorf("Len() = %d after release, want 1", got)
+	}
+	if !f.acquire(types.UID("uid"), d) {
+		t.Error("acquire() = false after release, wanted true")
+	}
+}
+
+func TestNoConcurrentReconcileAcrossKinds(t *testing.T) {
+	// The same reconciler is used for both kinds, to count the reconciliations
+	// of the object in flight across them.
+	r := newRecordingReconcilers(50*time.Millisecond, "shared")[0]
+	reporter := &FakeStatsReporter{}
+	impl := NewImplWithStats(r, TestLogger(t), "Testing", reporter)
+	if err := impl.AddKind("fast", r, 0); err != nil {
+		t.Fatalf("AddKind() = %v", err)
+	}
+
+	obj := &Resource{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "foo", UID: "uid"}}
+	impl.Enqueue(obj)
+	impl.EnqueueKind("fast", obj)
+	if err := impl.Start(2); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+	defer impl.Stop()
+
+	// The deferred key is reconciled once the first reconciliation is done.
+	waitForReconciled(t, r, 2)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.maxInFlight != 1 {
+		t.Errorf("Max in flight = %d, want 1", r.maxInFlight)
+	}
+	if got := reporter.GetSuppressedDuplicates("Testing") + reporter.GetSuppressedDuplicates("fast"); got == 0 {
+		t.Error("No suppressed duplicate reported")
+	}
+}
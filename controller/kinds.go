@@ -0,0 +1,247 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+
+	"knative.dev/pkg/kmeta"
+)
+
+// kind is a kind of resources reconciled by a controller, with its own work
+// queue and Reconciler.
+type kind struct {
+	name       string
+	primary    bool
+	reconciler Reconciler
+	// maxInFlight caps the keys of the kind reconciled at once, if positive.
+	maxInFlight int
+	queue       workqueue.RateLimitingInterface
+
+	// slots holds a token per key of the kind taken off its work queue,
+	// nil if the kind isn't capped. It's recreated when the controller starts.
+	slots chan struct{}
+	// inFlight is the number of keys of the kind being reconciled.
+	inFlight int64
+}
+
+// workItem is a key taken off the work queue of a kind, handed to a worker.
+type workItem struct {
+	kind *kind
+	key  types.NamespacedName
+}
+
+// AddKind adds a kind of resources reconciled by the given Reconciler next to
+// the primary one, e.g. the children of the primary resources which are
+// reconciled on their own. The keys of the kind are enqueued through EnqueueKind,
+// EnqueueKindKey or EnqueueKindKeyAfter into a work queue of their own, and the
+// kinds with keys to reconcile take turns for the workers, so a storm of changes
+// of one kind can't starve the others. At most maxInFlight keys of the kind are
+// reconciled at once, or up to the threadiness if it's not positive.
+// Kinds must be added while the controller is not running.
+func (c *Impl) AddKind(name string, r Reconciler, maxInFlight int) error {
+	if name == "" {
+		return errors.New("kind name cannot be empty")
+	}
+	if name == c.workQueueName {
+		return fmt.Errorf("kind %q is the primary kind of the controller", name)
+	}
+	if c.Running() {
+		return fmt.Errorf("cannot add kind %q to running controller %q", name, c.workQueueName)
+	}
+
+	c.kindsMu.Lock()
+	defer c.kindsMu.Unlock()
+	for _, k := range c.kinds {
+		if k.name == name {
+			return fmt.Errorf("kind %q is already added", name)
+		}
+	}
+	c.kinds = append(c.kinds, &kind{
+		name:        name,
+		reconciler:  r,
+		maxInFlight: maxInFlight,
+		queue:       c.newKindQueue(name),
+	})
+	return nil
+}
+
+func (c *Impl) newKindQueue(name string) workqueue.RateLimitingInterface {
+	return workqueue.NewNamedRateLimitingQueue(
+		workqueue.DefaultControllerRateLimiter(),
+		c.workQueueName+"-"+name,
+	)
+}
+
+// kindQueue returns the work queue of the given kind, or nil if it was not added.
+func (c *Impl) kindQueue(name string) workqueue.RateLimitingInterface {
+	c.kindsMu.RLock()
+	defer c.kindsMu.RUnlock()
+	for _, k := range c.kinds {
+		if k.name == name {
+			return k.queue
+		}
+	}
+	return nil
+}
+
+// EnqueueKind is like Enqueue, for a resource of the given kind added through
+// AddKind.
+func (c *Impl) EnqueueKind(kind string, obj interface{}) {
+	object, err := kmeta.DeletionHandlingAccessor(obj)
+	if err != nil {
+		c.logger.Errorw("Enqueue", zap.Error(err))
+		return
+	}
+	key := types.NamespacedName{Namespace: object.GetNamespace(), Name: object.GetName()}
+	c.inFlight.track(kind, key, obj, object)
+	c.EnqueueKindKey(kind, key)
+}
+
+// EnqueueKindKey is like EnqueueKey, for a key of the given kind added through
+// AddKind.
+func (c *Impl) EnqueueKindKey(kind string, key types.NamespacedName) {
+	wq := c.kindQueue(kind)
+	if wq == nil {
+		c.logger.Errorf("Cannot enqueue %s of unknown kind %q", safeKey(key), kind)
+		return
+	}
+	wq.Add(key)
+	c.logger.Debugf("Adding to %s queue %s (depth: %d)", kind, safeKey(key), wq.Len())
+}
+
+// EnqueueKindKeyAfter is like EnqueueKeyAfter, for a key of the given kind
+// added through AddKind.
+func (c *Impl) EnqueueKindKeyAfter(kind string, key types.NamespacedName, delay time.Duration) {
+	wq := c.kindQueue(kind)
+	if wq == nil {
+		c.logger.Errorf("Cannot enqueue %s of unknown kind %q", safeKey(key), kind)
+		return
+	}
+	wq.AddAfter(key, delay)
+	c.logger.Debugf("Adding to %s queue %s (delay: %v, depth: %d)", kind, safeKey(key), delay, wq.Len())
+}
+
+// restartKinds returns all of the kinds of the controller, starting with the
+// primary one, to start the controller with, or nil if there is only the
+// primary kind. The work queues shut down by a previous Stop are recreated.
+func (c *Impl) restartKinds() []*kind {
+	c.kindsMu.Lock()
+	defer c.kindsMu.Unlock()
+	if len(c.kinds) == 0 {
+		return nil
+	}
+
+	kinds := []*kind{{name: c.workQueueName, primary: true, reconciler: c.Reconciler, queue: c.workQueue()}}
+	for _, k := range c.kinds {
+		if k.queue.ShuttingDown() {
+			k.queue = c.newKindQueue(k.name)
+		}
+		kinds = append(kinds, k)
+	}
+	for _, k := range kinds {
+		k.slots = nil
+		if k.maxInFlight > 0 {
+			k.slots = make(chan struct{}, k.maxInFlight)
+		}
+	}
+	return kinds
+}
+
+// startKinds launches the workers of a controller reconciling several kinds.
+// A feeder per kind takes the keys off the work queue of the kind one at a time
+// and hands them over to the workers. The feeders waiting to hand over a key are
+// served in turn, so the kinds with keys to reconcile are reconciled in turn.
+// The workers exit once the work queues are shut down and drained.
+func (c *Impl) startKinds(threadiness int, kinds []*kind) {
+	work := make(chan workItem)
+	var feeders sync.WaitGroup
+	for _, k := range kinds {
+		feeders.Add(1)
+		go func(k *kind) {
+			defer feeders.Done()
+			feed(k, work)
+		}(k)
+	}
+	go func() {
+		feeders.Wait()
+		close(work)
+	}()
+
+	for i := 0; i < threadiness; i++ {
+		c.workers.Add(1)
+		go func() {
+			defer c.workers.Done()
+			for item := range work {
+				c.processKindItem(item)
+			}
+		}()
+	}
+}
+
+// feed hands the keys of the kind over to the workers, as long as fewer than
+// its maxInFlight keys are taken off its work queue, until it's shut down.
+func feed(k *kind, work chan<- workItem) {
+	for {
+		if k.slots != nil {
+			k.slots <- struct{}{}
+		}
+		obj, shutdown := k.queue.Get()
+		if shutdown {
+			if k.slots != nil {
+				<-k.slots
+			}
+			return
+		}
+		work <- workItem{kind: k, key: obj.(types.NamespacedName)}
+	}
+}
+
+// processKindItem reconciles a key handed over by the feeder of its kind.
+func (c *Impl) processKindItem(item workItem) {
+	k := item.kind
+	if k.primary {
+		c.statsReporter.ReportQueueDepth(int64(k.queue.Len()))
+	}
+	c.reportKind(k, atomic.AddInt64(&k.inFlight, 1))
+	defer func() {
+		c.reportKind(k, atomic.AddInt64(&k.inFlight, -1))
+		if k.slots != nil {
+			<-k.slots
+		}
+	}()
+	c.reconcile(k.name, k.queue, k.reconciler, item.key)
+}
+
+// reportKind reports the depth of the work queue of the kind and the number of
+// its keys being reconciled, if the StatsReporter reports the kinds' metrics.
+func (c *Impl) reportKind(k *kind, inFlight int64) {
+	r, ok := c.statsReporter.(KindStatsReporter)
+	if !ok {
+		return
+	}
+	r.ReportKindQueueDepth(k.name, int64(k.queue.Len()))
+	r.ReportInFlight(k.name, inFlight)
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	. "knative.dev/pkg/controller/testing"
+	. "knative.dev/pkg/logging/testing"
+	. "knative.dev/pkg/testing"
+)
+
+// recordingReconciler records the keys it reconciles, prefixed by its kind,
+// along with the maximum number of reconciliations in flight.
+type recordingReconciler struct {
+	kind  string
+	delay time.Duration
+
+	mu          *sync.Mutex
+	order       *[]string
+	inFlight    int
+	maxInFlight int
+}
+
+func (r *recordingReconciler) Reconcile(_ context.Context, key string) error {
+	r.mu.Lock()
+	r.inFlight++
+	if r.inFlight > r.maxInFlight {
+		r.maxInFlight = r.inFlight
+	}
+	r.mu.Unlock()
+
+	time.Sleep(r.delay)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inFlight--
+	*r.order = append(*r.order, r.kind+"/"+key)
+	return nil
+}
+
+func (r *recordingReconciler) reconciled() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), *r.order...)
+}
+
+func newRecordingReconcilers(delay time.Duration, kinds ...string) []*recordingReconciler {
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	rs := make([]*recordingReconciler, len(kinds))
+	for i, kind := range kinds {
+		rs[i] = &recordingReconciler{kind: kind, delay: delay, mu: &mu, order: &order}
+	}
+	return rs
+}
+
+func waitForReconciled(t *testing.T, r *recordingReconciler, count int) []string {
+	t.Helper()
+	var got []string
+	if err := wait.PollImmediate(5*time.Millisecond, 5*time.Second, func() (bool, error) {
+		got = r.reconciled()
+		return len(got) >= count, nil
+	}); err != nil {
+		t.Fatalf("Reconciled %d keys, wanted %d", len(got), count)
+	}
+	return got
+}
+
+func TestAddKind(t *testing.T) {
+	impl := NewImplWithStats(&NopReconciler{}, TestLogger(t), "Testing", &FakeStatsReporter{})
+
+	if err := impl.AddKind("child", &NopReconciler{}, 0); err != nil {
+		t.Fatalf("AddKind() = %v", err)
+	}
+	for _, name := range []string{"", "Testing", "child"} {
+		if err := impl.AddKind(name, &NopReconciler{}, 0); err == nil {
+			t.Errorf("AddKind(%q) = nil, wanted an error", name)
+		}
+	}
+
+	if err := impl.Start(1); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+	defer impl.Stop()
+	if err := impl.AddKind("other", &NopReconciler{}, 0); err == nil {
+		t.Error("AddKind() = nil, wanted an error for a running controller")
+	}
+	// Enqueueing a kind which wasn't added is dropped.
+	impl.EnqueueKindKey("unknown", types.NamespacedName{Namespace: "foo", Name: "bar"})
+}
+
+func TestKindsTakeTurns(t *testing.T) {
+	rs := newRecordingReconcilers(time.Millisecond, "primary", "child")
+	primary, child := rs[0], rs[1]
+	impl := NewImplWithStats(primary, TestLogger(t), "Testing", &FakeStatsReporter{})
+	if err := impl.AddKind("child", child, 0); err != nil {
+		t.Fatalf("AddKind() = %v", err)
+	}
+
+	// A storm of the primary kind doesn't starve the child kind.
+	const storm = 30
+	for i := 0; i < storm; i++ {
+		impl.EnqueueKey(types.NamespacedName{Namespace: "ns", Name: fmt.Sprintf("p%d", i)})
+	}
+	for i := 0; i < 3; i++ {
+		impl.EnqueueKind("child", &Resource{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: fmt.Sprintf("c%d", i)}})
+	}
+
+	if err := impl.Start(1); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+	defer impl.Stop()
+
+	got := waitForReconciled(t, primary, storm+3)
+	last := -1
+	for i, key := range got {
+		if strings.HasPrefix(key, "child/") {
+			last = i
+		}
+	}
+	// The kinds take turns, give or take the time to start the feeders.
+	if last < 0 || last > 10 {
+		t.Errorf("Reconciled the last child at %d, wanted it among the first keys: %v", last, got)
+	}
+}
+
+func TestKindMaxInFlight(t *testing.T) {
+	rs := newRecordingReconcilers(5*time.Millisecond, "primary", "capped")
+	primary, capped := rs[0], rs[1]
+	reporter := &FakeStatsReporter{}
+	impl := NewImplWithStats(primary, TestLogger(t), "Testing", reporter)
+	if err := impl.AddKind("capped", capped, 1); err != nil {
+		t.Fatalf("AddKind() = %v", err)
+	}
+
+	for i := 0; i < 8; i++ {
+		impl.EnqueueKindKey("capped", types.NamespacedName{Namespace: "ns", Name: fmt.Sprintf("c%d", i)})
+		impl.EnqueueKey(types.NamespacedName{Namespace: "ns", Name: fmt.Sprintf("p%d", i)})
+	}
+	if err := impl.Start(4); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+	waitForReconciled(t, primary, 16)
+	impl.Stop()
+
+	capped.mu.Lock()
+	defer capped.mu.Unlock()
+	if capped.maxInFlight != 1 {
+		t.Errorf("Max in flight = %d, want 1", capped.maxInFlight)
+	}
+	for _, v := range reporter.GetInFlight("capped") {
+		if v > 1 {
+			t.Errorf("Reported %d in flight, want at most 1", v)
+		}
+	}
+	if got := len(reporter.GetInFlight("Testing")); got == 0 {
+		t.Error("No in flight reported for the primary kind")
+	}
+}
+
+func TestKindStopAndRestart(t *testing.T) {
+	rs := newRecordingReconcilers(0, "primary", "child")
+	primary, child := rs[0], rs[1]
+	impl := NewImplWithStats(primary, TestLogger(t), "Testing", &FakeStatsReporter{})
+	if err := impl.AddKind("child", child, 0); err != nil {
+		t.Fatalf("AddKind() = %v", err)
+	}
+
+	if err := impl.Start(1); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+	impl.EnqueueKindKey("child", types.NamespacedName{Namespace: "ns", Name: "first"})
+	waitForReconciled(t, child, 1)
+	impl.Stop()
+
+	// The work queue of the kind is recreated when restarting.
+	if err := impl.Start(1); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+	defer impl.Stop()
+	impl.EnqueueKindKeyAfter("child", types.NamespacedName{Namespace: "ns", Name: "second"}, time.Millisecond)
+	if got := waitForReconciled(t, child, 2); got[1] != "child/ns/second" {
+		t.Errorf("Reconciled %v, wanted ns/second last", got)
+	}
+}
@@ -35,6 +35,9 @@ var (
 	workQueueDepthStat   = stats.Int64("work_queue_depth", "Depth of the work queue", stats.UnitNone)
 	reconcileCountStat   = stats.Int64("reconcile_count", "Number of reconcile operations", stats.UnitNone)
 	reconcileLatencyStat = stats.Int64("reconcile_latency", "Latency of reconcile operations", stats.UnitMilliseconds)
+	kindQueueDepthStat   = stats.Int64("kind_work_queue_depth", "Depth of the work queue of each kind", stats.UnitNone)
+	inFlightStat         = stats.Int64("reconcile_in_flight", "Number of reconcile operations in flight for each kind", stats.UnitNone)
+	suppressedStat       = stats.Int64("reconcile_suppressed_duplicates", "Number of reconcile operations deferred because the object was being reconciled", stats.UnitNone)
 
 	// reconcileDistribution defines the bucket boundaries for the histogram of reconcile latency metric.
 	// Bucket boundaries are 10ms, 100ms, 1s, 10s, 30s and 60s.
@@ -48,6 +51,7 @@ var (
 	reconcilerTagKey = tag.MustNewKey("reconciler")
 	keyTagKey        = tag.MustNewKey("key")
 	successTagKey    = tag.MustNewKey("success")
+	kindTagKey       = tag.MustNewKey("kind")
 )
 
 func init() {
@@ -173,6 +177,21 @@ func init() {
 		Measure:     reconcileLatencyStat,
 		Aggregation: reconcileDistribution,
 		TagKeys:     []tag.Key{reconcilerTagKey, keyTagKey, successTagKey},
+	}, {
+		Description: "Depth of the work queue of each kind",
+		Measure:     kindQueueDepthStat,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{reconcilerTagKey, kindTagKey},
+	}, {
+		Description: "Number of reconcile operations in flight for each kind",
+		Measure:     inFlightStat,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{reconcilerTagKey, kindTagKey},
+	}, {
+		Description: "Number of reconcile operations deferred because the object was being reconciled",
+		Measure:     suppressedStat,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{reconcilerTagKey, kindTagKey},
 	}}
 	for _, view := range wp.DefaultViews() {
 		views = append(views, view)
@@ -201,6 +220,31 @@ type StatsReporter interface {
 	ReportReconcile(duration time.Duration, key, success string) error
 }
 
+// KindStatsReporter is implemented by the StatsReporters which report the
+// metrics of the kinds of a controller reconciling several of them, see
+// Impl.AddKind.
+type KindStatsReporter interface {
+	// ReportKindQueueDepth reports the depth of the work queue of the kind
+	ReportKindQueueDepth(kind string, v int64) error
+
+	// ReportInFlight reports the number of reconcile operations in flight for the kind
+	ReportInFlight(kind string, v int64) error
+}
+
+// DuplicateStatsReporter is implemented by the StatsReporters which report
+// the reconcile operations deferred because their object was being reconciled
+// by another worker, e.g. after being enqueued into the work queues of
+// several kinds.
+type DuplicateStatsReporter interface {
+	// ReportSuppressedDuplicate reports a reconcile operation of the kind deferred
+	ReportSuppressedDuplicate(kind string) error
+}
+
+var (
+	_ KindStatsReporter      = (*reporter)(nil)
+	_ DuplicateStatsReporter = (*reporter)(nil)
+)
+
 // Reporter holds cached metric objects to report metrics
 type reporter struct {
 	reconciler string
@@ -254,3 +298,30 @@ func (r *reporter) ReportReconcile(duration time.Duration, key, success string)
 	metrics.Record(ctx, reconcileLatencyStat.M(int64(duration/time.Millisecond)))
 	return nil
 }
+
+// ReportKindQueueDepth reports the depth of the work queue of the kind
+func (r *reporter) ReportKindQueueDepth(kind string, v int64) error {
+	return r.recordKind(kind, kindQueueDepthStat.M(v))
+}
+
+// ReportInFlight reports the number of reconcile operations in flight for the kind
+func (r *reporter) ReportInFlight(kind string, v int64) error {
+	return r.recordKind(kind, inFlightStat.M(v))
+}
+
+// ReportSuppressedDuplicate reports a reconcile operation of the kind deferred
+func (r *reporter) ReportSuppressedDuplicate(kind string) error {
+	return r.recordKind(kind, suppressedStat.M(1))
+}
+
+func (r *reporter) recordKind(kind string, m stats.Measurement) error {
+	if r.globalCtx == nil {
+		return errors.New("reporter is not initialized correctly")
+	}
+	ctx, err := tag.New(r.globalCtx, tag.Insert(kindTagKey, kind))
+	if err != nil {
+		return err
+	}
+	metrics.Record(ctx, m)
+	return nil
+}
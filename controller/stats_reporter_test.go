@@ -88,6 +88,27 @@ func TestReportReconcile(t *testing.T) {
 	checkDistributionData(t, "reconcile_latency", wantTags, initialReconcileLatency+25)
 }
 
+func TestReportKind(t *testing.T) {
+	r1 := &reporter{}
+	if err := r1.ReportInFlight("child", 1); err == nil {
+		t.Error("Reporter.Report() expected an error for Report call before init. Got success.")
+	}
+
+	r, _ := NewStatsReporter("testreconciler")
+	kr := r.(KindStatsReporter)
+	wantTags := map[string]string{
+		"reconciler": "testreconciler",
+		"kind":       "child",
+	}
+
+	expectSuccess(t, func() error { return kr.ReportKindQueueDepth("child", 5) })
+	checkLastValueData(t, "kind_work_queue_depth", wantTags, 5)
+
+	expectSuccess(t, func() error { return kr.ReportInFlight("child", 2) })
+	expectSuccess(t, func() error { return kr.ReportInFlight("child", 1) })
+	checkLastValueData(t, "reconcile_in_flight", wantTags, 1)
+}
+
 func expectSuccess(t *testing.T, f func() error) {
 	t.Helper()
 	if err := f(); err != nil {
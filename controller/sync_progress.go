@@ -0,0 +1,177 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	k8sclock "k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// syncPollInterval is the interval at which the informers are checked for
+// being synced, like cache.WaitForCacheSync does.
+const syncPollInterval = 100 * time.Millisecond
+
+// NamedInformer is implemented by the informers which can tell what they're
+// informing about, used to name them in the sync progress.
+type NamedInformer interface {
+	Informer
+
+	// Name returns a human readable name of the informer, e.g. the
+	// resource it watches.
+	Name() string
+}
+
+// InformerSyncStatus is the cache sync status of a single informer.
+type InformerSyncStatus struct {
+	// Name is the name of the informer.
+	Name string `json:"name"`
+	// Synced is whether the informer's cache has synced.
+	Synced bool `json:"synced"`
+	// Duration is how long the informer has been syncing for, or how long
+	// it took to sync.
+	Duration time.Duration `json:"duration"`
+}
+
+// SyncProgressFunc is called with the sync status of all of the informers.
+type SyncProgressFunc func([]InformerSyncStatus)
+
+// SyncProgress starts informers and tracks the progress of their cache sync,
+// which it can report periodically and serves as JSON.
+type SyncProgress struct {
+	informers []Informer
+	names     []string
+	clock     k8sclock.Clock
+
+	mu       sync.Mutex
+	started  time.Time
+	syncedIn []time.Duration
+	synced   []bool
+}
+
+var _ http.Handler = (*SyncProgress)(nil)
+
+// NewSyncProgress creates a SyncProgress for the given informers. Informers
+// implementing NamedInformer are named after it, the others after their
+// type and position.
+func NewSyncProgress(informers ...Informer) *SyncProgress {
+	names := make([]string, len(informers))
+	for i, informer := range informers {
+		if named, ok := informer.(NamedInformer); ok {
+			names[i] = named.Name()
+		} else {
+			names[i] = fmt.Sprintf("%T[%d]", informer, i)
+		}
+	}
+	return &SyncProgress{
+		informers: informers,
+		names:     names,
+		clock:     k8sclock.RealClock{},
+		syncedIn:  make([]time.Duration, len(informers)),
+		synced:    make([]bool, len(informers)),
+	}
+}
+
+// Start kicks off all of the informers and then waits for all of them to
+// synchronize, like StartInformers. Until they have, report is called with
+// their status every interval, and once more when they have all synced. The
+// report can be nil.
+func (p *SyncProgress) Start(stopCh <-chan struct{}, interval time.Duration, report SyncProgressFunc) error {
+	p.mu.Lock()
+	p.started = p.clock.Now()
+	p.mu.Unlock()
+
+	for _, informer := range p.informers {
+		informer := informer
+		go informer.Run(stopCh)
+	}
+
+	lastReport := p.clock.Now()
+	err := wait.PollImmediateUntil(syncPollInterval, func() (bool, error) {
+		statuses := p.Status()
+		if pending := Pending(statuses); len(pending) > 0 {
+			if report != nil && p.clock.Since(lastReport) >= interval {
+				lastReport = p.clock.Now()
+				report(statuses)
+			}
+			return false, nil
+		}
+		if report != nil {
+			report(statuses)
+		}
+		return true, nil
+	}, stopCh)
+	if err != nil {
+		pending := Pending(p.Status())
+		names := make([]string, len(pending))
+		for i, s := range pending {
+			names[i] = s.Name
+		}
+		return fmt.Errorf("failed to wait for caches to sync, still syncing: %v", names)
+	}
+	return nil
+}
+
+// Status returns the current sync status of the informers.
+func (p *SyncProgress) Status() []InformerSyncStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.clock.Now()
+	statuses := make([]InformerSyncStatus, len(p.informers))
+	for i, informer := range p.informers {
+		if !p.synced[i] && !p.started.IsZero() && informer.HasSynced() {
+			p.synced[i] = true
+			p.syncedIn[i] = now.Sub(p.started)
+		}
+		statuses[i] = InformerSyncStatus{
+			Name:   p.names[i],
+			Synced: p.synced[i],
+		}
+		switch {
+		case p.synced[i]:
+			statuses[i].Duration = p.syncedIn[i]
+		case !p.started.IsZero():
+			statuses[i].Duration = now.Sub(p.started)
+		}
+	}
+	return statuses
+}
+
+// ServeHTTP serves the sync status of the informers as JSON.
+func (p *SyncProgress) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(p.Status()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Pending returns the statuses of the informers which haven't synced yet.
+func Pending(statuses []InformerSyncStatus) []InformerSyncStatus {
+	var pending []InformerSyncStatus
+	for _, s := range statuses {
+		if !s.Synced {
+			pending = append(pending, s)
+		}
+	}
+	return pending
+}
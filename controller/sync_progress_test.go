@@ -0,0 +1,166 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	k8sclock "k8s.io/apimachinery/pkg/util/clock"
+)
+
+type syncingInformer struct {
+	name string
+
+	m      sync.Mutex
+	synced bool
+}
+
+func (i *syncingInformer) Run(<-chan struct{}) {}
+
+func (i *syncingInformer) HasSynced() bool {
+	i.m.Lock()
+	defer i.m.Unlock()
+	return i.synced
+}
+
+func (i *syncingInformer) Name() string {
+	return i.name
+}
+
+func (i *syncingInformer) sync() {
+	i.m.Lock()
+	defer i.m.Unlock()
+	i.synced = true
+}
+
+type unnamedInformer struct{}
+
+func (unnamedInformer) Run(<-chan struct{}) {}
+
+func (unnamedInformer) HasSynced() bool {
+	return true
+}
+
+func TestSyncProgressStatus(t *testing.T) {
+	slow := &syncingInformer{name: "slow"}
+	p := NewSyncProgress(slow, unnamedInformer{})
+	clock := k8sclock.NewFakeClock(time.Now())
+	p.clock = clock
+
+	// Nothing is synced before the informers are started.
+	want := []InformerSyncStatus{{
+		Name: "slow",
+	}, {
+		Name: "controller.unnamedInformer[1]",
+	}}
+	if diff := cmp.Diff(want, p.Status()); diff != "" {
+		t.Errorf("Status() (-want, +got) = %s", diff)
+	}
+
+	p.started = clock.Now()
+	clock.Step(time.Second)
+	want = []InformerSyncStatus{{
+		Name:     "slow",
+		Duration: time.Second,
+	}, {
+		Name:     "controller.unnamedInformer[1]",
+		Synced:   true,
+		Duration: time.Second,
+	}}
+	if diff := cmp.Diff(want, p.Status()); diff != "" {
+		t.Errorf("Status() (-want, +got) = %s", diff)
+	}
+
+	// The informers keep the time it took them to sync, as observed.
+	clock.Step(time.Minute)
+	slow.sync()
+	p.Status()
+	clock.Step(time.Minute)
+	want[0].Synced = true
+	want[0].Duration = time.Minute + time.Second
+	if diff := cmp.Diff(want, p.Status()); diff != "" {
+		t.Errorf("Status() (-want, +got) = %s", diff)
+	}
+	if got := Pending(p.Status()); len(got) != 0 {
+		t.Errorf("Pending() = %v, wanted none", got)
+	}
+}
+
+func TestSyncProgressStart(t *testing.T) {
+	slow := &syncingInformer{name: "slow"}
+	p := NewSyncProgress(slow, &syncingInformer{name: "fast", synced: true})
+
+	var (
+		m       sync.Mutex
+		reports [][]InformerSyncStatus
+	)
+	report := func(statuses []InformerSyncStatus) {
+		m.Lock()
+		defer m.Unlock()
+		reports = append(reports, statuses)
+	}
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		slow.sync()
+	}()
+	if err := p.Start(make(chan struct{}), time.Millisecond, report); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	if len(reports) < 2 {
+		t.Fatalf("len(reports) = %d, wanted at least 2", len(reports))
+	}
+	if got := Pending(reports[0]); len(got) != 1 || got[0].Name != "slow" {
+		t.Errorf("Pending(first report) = %v, wanted slow", got)
+	}
+	if got := Pending(reports[len(reports)-1]); len(got) != 0 {
+		t.Errorf("Pending(last report) = %v, wanted none", got)
+	}
+}
+
+func TestSyncProgressStartStopped(t *testing.T) {
+	p := NewSyncProgress(&syncingInformer{name: "never"})
+	stopCh := make(chan struct{})
+	close(stopCh)
+	if err := p.Start(stopCh, time.Second, nil); err == nil {
+		t.Error("Start() = nil, wanted an error")
+	}
+}
+
+func TestSyncProgressServeHTTP(t *testing.T) {
+	p := NewSyncProgress(&syncingInformer{name: "slow"})
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/debug/informers", nil))
+	if got, want := w.Header().Get("Content-Type"), "application/json"; got != want {
+		t.Errorf("Content-Type = %q, wanted %q", got, want)
+	}
+	var got []InformerSyncStatus
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("Decode() = %v", err)
+	}
+	if diff := cmp.Diff([]InformerSyncStatus{{Name: "slow"}}, got); diff != "" {
+		t.Errorf("ServeHTTP() (-want, +got) = %s", diff)
+	}
+}
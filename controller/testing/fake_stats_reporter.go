@@ -25,6 +25,9 @@ import (
 type FakeStatsReporter struct {
 	queueDepths   []int64
 	reconcileData []FakeReconcileStatData
+	kindDepths    map[string][]int64
+	inFlight      map[string][]int64
+	suppressed    map[string]int
 	Lock          sync.Mutex
 }
 
@@ -63,3 +66,58 @@ func (r *FakeStatsReporter) GetReconcileData() []FakeReconcileStatData {
 	defer r.Lock.Unlock()
 	return r.reconcileData
 }
+
+// ReportKindQueueDepth records the call and returns success.
+func (r *FakeStatsReporter) ReportKindQueueDepth(kind string, v int64) error {
+	r.Lock.Lock()
+	defer r.Lock.Unlock()
+	if r.kindDepths == nil {
+		r.kindDepths = make(map[string][]int64)
+	}
+	r.kindDepths[kind] = append(r.kindDepths[kind], v)
+	return nil
+}
+
+// ReportInFlight records the call and returns success.
+func (r *FakeStatsReporter) ReportInFlight(kind string, v int64) error {
+	r.Lock.Lock()
+	defer r.Lock.Unlock()
+	if r.inFlight == nil {
+		r.inFlight = make(map[string][]int64)
+	}
+	r.inFlight[kind] = append(r.inFlight[kind], v)
+	return nil
+}
+
+// ReportSuppressedDuplicate records the call and returns success.
+func (r *FakeStatsReporter) ReportSuppressedDuplicate(kind string) error {
+	r.Lock.Lock()
+	defer r.Lock.Unlock()
+	if r.suppressed == nil {
+		r.suppressed = make(map[string]int)
+	}
+	r.suppressed[kind]++
+	return nil
+}
+
+// GetKindQueueDepths returns the recorded queue depth values of the kind
+func (r *FakeStatsReporter) GetKindQueueDepths(kind string) []int64 {
+	r.Lock.Lock()
+	defer r.Lock.Unlock()
+	return r.kindDepths[kind]
+}
+
+// GetInFlight returns the recorded in flight values of the kind
+func (r *FakeStatsReporter) GetInFlight(kind string) []int64 {
+	r.Lock.Lock()
+	defer r.Lock.Unlock()
+	return r.inFlight[kind]
+}
+
+// GetSuppressedDuplicates returns the number of suppressed duplicates recorded
+// for the kind
+func (r *FakeStatsReporter) GetSuppressedDuplicates(kind string) int {
+	r.Lock.Lock()
+	defer r.Lock.Unlock()
+	return r.suppressed[kind]
+}
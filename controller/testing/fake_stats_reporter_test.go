@@ -26,7 +26,10 @@ import (
 	"knative.dev/pkg/controller"
 )
 
-var _ controller.StatsReporter = (*FakeStatsReporter)(nil)
+var (
+	_ controller.StatsReporter          = (*FakeStatsReporter)(nil)
+	_ controller.DuplicateStatsReporter = (*FakeStatsReporter)(nil)
+)
 
 func TestReportQueueDepth(t *testing.T) {
 	r := &FakeStatsReporter{}
@@ -43,3 +46,12 @@ func TestReportReconcile(t *testing.T) {
 		t.Errorf("reconcile data len: want: %v, got: %v", want, got)
 	}
 }
+
+func TestReportSuppressedDuplicate(t *testing.T) {
+	r := &FakeStatsReporter{}
+	r.ReportSuppressedDuplicate("child")
+	r.ReportSuppressedDuplicate("child")
+	if got, want := r.GetSuppressedDuplicates("child"), 2; got != want {
+		t.Errorf("suppressed duplicates: want: %v, got: %v", want, got)
+	}
+}
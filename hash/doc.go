@@ -0,0 +1,27 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package hash implements consistent hashing, to shard keys, e.g. the
+// resources reconciled by a controller or the revisions handled by an
+// activator, across a changing set of members. Adding or removing a member
+// only moves the keys from or to that member, so the work that has to be
+// migrated on every change is minimal:
+//
+//	ring := hash.NewRing(hash.DefaultVirtualNodes)
+//	ring.Add("pod-a", 1)
+//	ring.Add("pod-b", 2) // Gets about twice as many keys as pod-a.
+//	owner := ring.Get("namespace/name")
+package hash
@@ -0,0 +1,182 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hash
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultVirtualNodes is the number of virtual nodes per unit of weight
+// which distributes keys evenly enough for most uses.
+const DefaultVirtualNodes = 100
+
+// point is a virtual node on the ring.
+type point struct {
+	hash   uint64
+	member string
+}
+
+// Ring is a consistent hash ring of weighted members. It is safe for
+// concurrent use.
+type Ring struct {
+	virtualNodes int
+
+	mu      sync.RWMutex
+	weights map[string]int
+	// points is sorted by hash.
+	points []point
+}
+
+// NewRing returns an empty Ring placing the given number of virtual nodes
+// per unit of weight of a member.
+func NewRing(virtualNodes int) *Ring {
+	if virtualNodes < 1 {
+		virtualNodes = 1
+	}
+	return &Ring{
+		virtualNodes: virtualNodes,
+		weights:      make(map[string]int),
+	}
+}
+
+// Add adds the member with the given weight, or updates the weight of an
+// existing member. A member gets a share of the keys proportional to its
+// weight. Members with a weight below one are removed.
+func (r *Ring) Add(member string, weight int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if weight < 1 {
+		delete(r.weights, member)
+	} else {
+		r.weights[member] = weight
+	}
+	r.rebuild()
+}
+
+// Remove removes the member.
+func (r *Ring) Remove(member string) {
+	r.Add(member, 0)
+}
+
+// Members returns the sorted members of the ring.
+func (r *Ring) Members() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	members := make([]string, 0, len(r.weights))
+	for m := range r.weights {
+		members = append(members, m)
+	}
+	sort.Strings(members)
+	return members
+}
+
+// Get returns the member owning the key, or "" if the ring is empty.
+func (r *Ring) Get(key string) string {
+	if owners := r.GetN(key, 1); len(owners) > 0 {
+		return owners[0]
+	}
+	return ""
+}
+
+// GetN returns up to n distinct members for the key, in order of
+// preference, e.g. to replicate the key. The first member is the one Get
+// returns.
+func (r *Ring) GetN(key string, n int) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if n > len(r.weights) {
+		n = len(r.weights)
+	}
+	if n < 1 {
+		return nil
+	}
+
+	h := hashOf(key)
+	start := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= h
+	})
+	owners := make([]string, 0, n)
+	seen := make(map[string]bool, n)
+	for i := 0; len(owners) < n; i++ {
+		p := r.points[(start+i)%len(r.points)]
+		if !seen[p.member] {
+			seen[p.member] = true
+			owners = append(owners, p.member)
+		}
+	}
+	return owners
+}
+
+// rebuild recomputes the virtual nodes of the ring. It must be called with
+// the lock held.
+func (r *Ring) rebuild() {
+	points := r.points[:0]
+	for member, weight := range r.weights {
+		for i := 0; i < weight*r.virtualNodes; i++ {
+			points = append(points, point{
+				hash:   hashOf(member + "#" + strconv.Itoa(i)),
+				member: member,
+			})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		// Break (unlikely) ties by member to stay deterministic.
+		if points[i].hash != points[j].hash {
+			return points[i].hash < points[j].hash
+		}
+		return points[i].member < points[j].member
+	})
+	r.points = points
+}
+
+// Moved returns the keys whose owner differs between the two rings, e.g.
+// to migrate the keys affected by a membership change:
+//
+//	next := ring.Clone()
+//	next.Add("pod-c", 1)
+//	moved := hash.Moved(ring, next, keys)
+func Moved(before, after *Ring, keys []string) []string {
+	var moved []string
+	for _, k := range keys {
+		if before.Get(k) != after.Get(k) {
+			moved = append(moved, k)
+		}
+	}
+	return moved
+}
+
+// Clone returns a copy of the ring, which can be changed independently.
+func (r *Ring) Clone() *Ring {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c := NewRing(r.virtualNodes)
+	for m, w := range r.weights {
+		c.weights[m] = w
+	}
+	c.points = append([]point(nil), r.points...)
+	return c
+}
+
+// hashOf hashes the string onto the ring.
+func hashOf(s string) uint64 {
+	sum := sha256.Sum256([]byte(s))
+	return binary.BigEndian.Uint64(sum[:8])
+}
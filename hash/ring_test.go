@@ -0,0 +1,145 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hash
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func keys(n int) []string {
+	ks := make([]string, n)
+	for i := range ks {
+		ks[i] = fmt.Sprintf("namespace-%d/name-%d", i%17, i)
+	}
+	return ks
+}
+
+func TestEmptyRing(t *testing.T) {
+	r := NewRing(DefaultVirtualNodes)
+	if got := r.Get("key"); got != "" {
+		t.Errorf("Get() = %q, want empty", got)
+	}
+	if got := r.GetN("key", 3); len(got) != 0 {
+		t.Errorf("GetN() = %v, want none", got)
+	}
+}
+
+func TestMembers(t *testing.T) {
+	r := NewRing(DefaultVirtualNodes)
+	r.Add("b", 1)
+	r.Add("a", 2)
+	r.Add("c", 1)
+	r.Remove("c")
+	r.Add("d", 0)
+	if diff := cmp.Diff([]string{"a", "b"}, r.Members()); diff != "" {
+		t.Errorf("Members (-want, +got) = %s", diff)
+	}
+}
+
+func TestWeightedDistribution(t *testing.T) {
+	r := NewRing(DefaultVirtualNodes)
+	r.Add("a", 1)
+	r.Add("b", 1)
+	r.Add("c", 2)
+
+	const n = 20000
+	counts := map[string]int{}
+	for _, k := range keys(n) {
+		counts[r.Get(k)]++
+	}
+	for member, share := range map[string]float64{"a": 0.25, "b": 0.25, "c": 0.5} {
+		got := float64(counts[member]) / n
+		if math.Abs(got-share) > 0.05 {
+			t.Errorf("%s owns %.3f of the keys, want about %.3f", member, got, share)
+		}
+	}
+}
+
+func TestMinimalMovement(t *testing.T) {
+	before := NewRing(DefaultVirtualNodes)
+	for _, m := range []string{"a", "b", "c"} {
+		before.Add(m, 1)
+	}
+	ks := keys(10000)
+
+	// Adding a member only moves keys to it.
+	after := before.Clone()
+	after.Add("d", 1)
+	moved := Moved(before, after, ks)
+	for _, k := range moved {
+		if got := after.Get(k); got != "d" {
+			t.Fatalf("Key %q moved to %q, want it to move to the new member", k, got)
+		}
+	}
+	if share := float64(len(moved)) / float64(len(ks)); share < 0.15 || share > 0.35 {
+		t.Errorf("Moved %.3f of the keys, want about 0.25", share)
+	}
+
+	// Removing a member only moves its keys.
+	after = before.Clone()
+	after.Remove("b")
+	for _, k := range Moved(before, after, ks) {
+		if got := before.Get(k); got != "b" {
+			t.Fatalf("Key %q of %q moved, want only keys of the removed member to move", k, got)
+		}
+	}
+
+	// The clone is independent of the original.
+	if diff := cmp.Diff([]string{"a", "b", "c"}, before.Members()); diff != "" {
+		t.Errorf("Members (-want, +got) = %s", diff)
+	}
+}
+
+func TestGetN(t *testing.T) {
+	r := NewRing(DefaultVirtualNodes)
+	for _, m := range []string{"a", "b", "c"} {
+		r.Add(m, 1)
+	}
+	for _, k := range keys(100) {
+		owners := r.GetN(k, 5)
+		if len(owners) != 3 {
+			t.Fatalf("GetN(%q) = %v, want all 3 members", k, owners)
+		}
+		if owners[0] != r.Get(k) {
+			t.Errorf("GetN(%q)[0] = %q, want Get() = %q", k, owners[0], r.Get(k))
+		}
+		seen := map[string]bool{}
+		for _, o := range owners {
+			if seen[o] {
+				t.Errorf("GetN(%q) = %v, want distinct members", k, owners)
+			}
+			seen[o] = true
+		}
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	r1, r2 := NewRing(10), NewRing(10)
+	for _, m := range []string{"a", "b", "c"} {
+		r1.Add(m, 1)
+	}
+	for _, m := range []string{"c", "a", "b"} {
+		r2.Add(m, 1)
+	}
+	if moved := Moved(r1, r2, keys(1000)); len(moved) != 0 {
+		t.Errorf("Rings with the same members disagree on %d keys", len(moved))
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package injection
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
+
+	"knative.dev/pkg/changeset"
+)
+
+// ClientOptions configures the rest.Config all of the injected clients are
+// created from. Zero values keep the settings of the rest.Config passed to
+// SetupInformers.
+type ClientOptions struct {
+	// UserAgent is the user agent the clients identify themselves with.
+	UserAgent string
+
+	// QPS and Burst configure the default rate limiter of the clients.
+	QPS   float32
+	Burst int
+
+	// RateLimiter replaces the rate limiter configured by QPS and Burst.
+	// As rate limiters are stateful, all clients share this one.
+	RateLimiter flowcontrol.RateLimiter
+}
+
+// clientOptionsKey is the key that ClientOptions are associated with on
+// contexts returned by WithClientOptions.
+type clientOptionsKey struct{}
+
+// WithClientOptions associates the given ClientOptions with the provided
+// context, to be applied by SetupInformers.
+func WithClientOptions(ctx context.Context, opts ClientOptions) context.Context {
+	return context.WithValue(ctx, clientOptionsKey{}, opts)
+}
+
+// GetClientOptions accesses the ClientOptions associated with the provided
+// context, or returns zero ClientOptions if there are none.
+func GetClientOptions(ctx context.Context) ClientOptions {
+	opts, _ := ctx.Value(clientOptionsKey{}).(ClientOptions)
+	return opts
+}
+
+// ApplyTo returns a copy of the given rest.Config with the options applied.
+func (o ClientOptions) ApplyTo(cfg *rest.Config) *rest.Config {
+	cfg = rest.CopyConfig(cfg)
+	if o.UserAgent != "" {
+		cfg.UserAgent = o.UserAgent
+	}
+	if o.QPS != 0 {
+		cfg.QPS = o.QPS
+	}
+	if o.Burst != 0 {
+		cfg.Burst = o.Burst
+	}
+	if o.RateLimiter != nil {
+		cfg.RateLimiter = o.RateLimiter
+	}
+	return cfg
+}
+
+// UserAgent returns a user agent identifying the given component and the
+// commit it has been built from, as reported by the changeset package, e.g.
+// "controller/1a2b3c4 (linux/amd64) knative".
+func UserAgent(component string) string {
+	commit, err := changeset.Get()
+	if err != nil {
+		commit = "unknown"
+	}
+	return fmt.Sprintf("%s/%s (%s/%s) knative", component, commit, runtime.GOOS, runtime.GOARCH)
+}
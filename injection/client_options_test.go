@@ -0,0 +1,90 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package injection
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+func TestClientOptionsApplyTo(t *testing.T) {
+	base := &rest.Config{Host: "https://example.com", UserAgent: "base", QPS: 5, Burst: 10}
+
+	got := ClientOptions{}.ApplyTo(base)
+	if got == base {
+		t.Error("ApplyTo() did not copy the config")
+	}
+	if got.UserAgent != "base" || got.QPS != 5 || got.Burst != 10 {
+		t.Errorf("ApplyTo() = %+v, wanted the base settings", got)
+	}
+
+	limiter := flowcontrol.NewFakeAlwaysRateLimiter()
+	got = ClientOptions{UserAgent: "agent", QPS: 50, Burst: 100, RateLimiter: limiter}.ApplyTo(base)
+	if got.UserAgent != "agent" || got.QPS != 50 || got.Burst != 100 || got.RateLimiter != limiter {
+		t.Errorf("ApplyTo() = %+v, wanted the options applied", got)
+	}
+	if base.UserAgent != "base" {
+		t.Error("ApplyTo() modified the base config")
+	}
+}
+
+func TestSetupInformersAppliesClientOptions(t *testing.T) {
+	i := &impl{}
+	var seen *rest.Config
+	i.RegisterClient(func(ctx context.Context, cfg *rest.Config) context.Context {
+		seen = cfg
+		return ctx
+	})
+
+	ctx := WithClientOptions(context.Background(), ClientOptions{UserAgent: "agent", QPS: 42})
+	if got := GetClientOptions(ctx); got.UserAgent != "agent" {
+		t.Errorf("GetClientOptions() = %+v, wanted the associated options", got)
+	}
+	i.SetupInformers(ctx, &rest.Config{Burst: 7})
+
+	if seen.UserAgent != "agent" || seen.QPS != 42 || seen.Burst != 7 {
+		t.Errorf("Client saw config %+v, wanted the client options applied", seen)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	if got := UserAgent("controller"); !strings.HasPrefix(got, "controller/unknown (") {
+		t.Errorf("UserAgent() = %q, wanted an unknown commit", got)
+	}
+
+	dir, err := ioutil.TempDir("", "kodata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "HEAD"), []byte("a2d1bdfe929516d7da141aef68631a7ee6941b2d"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("KO_DATA_PATH", dir)
+	defer os.Unsetenv("KO_DATA_PATH")
+
+	if got := UserAgent("controller"); !strings.HasPrefix(got, "controller/a2d1bdf (") {
+		t.Errorf("UserAgent() = %q, wanted the commit a2d1bdf", got)
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadataclient
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1beta1 "k8s.io/apimachinery/pkg/apis/meta/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// acceptObject and acceptList ask the API server to return objects
+	// stripped down to their metadata.
+	acceptObject = "application/json;as=PartialObjectMetadata;g=meta.k8s.io;v=v1," +
+		"application/json;as=PartialObjectMetadata;g=meta.k8s.io;v=v1beta1,application/json"
+	acceptList = "application/json;as=PartialObjectMetadataList;g=meta.k8s.io;v=v1," +
+		"application/json;as=PartialObjectMetadataList;g=meta.k8s.io;v=v1beta1,application/json"
+)
+
+var (
+	scheme    = runtime.NewScheme()
+	codecs    = serializer.NewCodecFactory(scheme)
+	versionV1 = schema.GroupVersion{Version: "v1"}
+
+	parameterScheme = runtime.NewParameterCodec(scheme)
+)
+
+func init() {
+	metav1.AddToGroupVersion(scheme, versionV1)
+	metav1.AddMetaToScheme(scheme)
+	scheme.AddKnownTypes(metav1beta1.SchemeGroupVersion,
+		&metav1beta1.PartialObjectMetadata{},
+		&metav1beta1.PartialObjectMetadataList{},
+	)
+}
+
+// Interface allows to list and watch the metadata of any resource, without
+// having to decode, or even transfer, their spec and status.
+type Interface interface {
+	Resource(schema.GroupVersionResource) NamespaceableResourceInterface
+}
+
+// NamespaceableResourceInterface accesses the metadata of a resource, either
+// in all namespaces or in the given one.
+type NamespaceableResourceInterface interface {
+	Namespace(string) ResourceInterface
+	ResourceInterface
+}
+
+// ResourceInterface accesses the metadata of the objects of a resource.
+type ResourceInterface interface {
+	Get(name string, opts metav1.GetOptions) (*metav1.PartialObjectMetadata, error)
+	List(opts metav1.ListOptions) (*metav1.PartialObjectMetadataList, error)
+	Watch(opts metav1.ListOptions) (watch.Interface, error)
+}
+
+// NewForConfig creates a new metadata client for the given config.
+func NewForConfig(inConfig *rest.Config) (Interface, error) {
+	config := rest.CopyConfig(inConfig)
+	config.AcceptContentTypes = "application/json"
+	config.ContentType = "application/json"
+	config.NegotiatedSerializer = codecs.WithoutConversion()
+	// for serializing the options
+	config.GroupVersion = &schema.GroupVersion{}
+	config.APIPath = "/if-you-see-this-search-for-the-break"
+	if config.UserAgent == "" {
+		config.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+
+	restClient, err := rest.RESTClientFor(config)
+	if err != nil {
+		return nil, err
+	}
+	return &client{client: restClient}, nil
+}
+
+// NewForConfigOrDie creates a new metadata client for the given config and
+// panics if there is an error in the config.
+func NewForConfigOrDie(c *rest.Config) Interface {
+	ret, err := NewForConfig(c)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+type client struct {
+	client *rest.RESTClient
+}
+
+func (c *client) Resource(resource schema.GroupVersionResource) NamespaceableResourceInterface {
+	return &resourceClient{client: c.client, resource: resource}
+}
+
+type resourceClient struct {
+	client    *rest.RESTClient
+	namespace string
+	resource  schema.GroupVersionResource
+}
+
+func (c *resourceClient) Namespace(ns string) ResourceInterface {
+	ret := *c
+	ret.namespace = ns
+	return &ret
+}
+
+func (c *resourceClient) Get(name string, opts metav1.GetOptions) (*metav1.PartialObjectMetadata, error) {
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	obj, err := c.client.Get().AbsPath(c.makeURLSegments(name)...).
+		SetHeader("Accept", acceptObject).
+		SpecificallyVersionedParams(&opts, parameterScheme, versionV1).
+		Do().Get()
+	if err != nil {
+		return nil, err
+	}
+	partial, ok := obj.(*metav1.PartialObjectMetadata)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object, expected PartialObjectMetadata but got %T", obj)
+	}
+	return partial, nil
+}
+
+func (c *resourceClient) List(opts metav1.ListOptions) (*metav1.PartialObjectMetadataList, error) {
+	obj, err := c.client.Get().AbsPath(c.makeURLSegments("")...).
+		SetHeader("Accept", acceptList).
+		SpecificallyVersionedParams(&opts, parameterScheme, versionV1).
+		Do().Get()
+	if err != nil {
+		return nil, err
+	}
+	partial, ok := obj.(*metav1.PartialObjectMetadataList)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object, expected PartialObjectMetadataList but got %T", obj)
+	}
+	return partial, nil
+}
+
+func (c *resourceClient) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return c.client.Get().AbsPath(c.makeURLSegments("")...).
+		SetHeader("Accept", acceptObject).
+		SpecificallyVersionedParams(&opts, parameterScheme, versionV1).
+		Watch()
+}
+
+func (c *resourceClient) makeURLSegments(name string) []string {
+	url := []string{}
+	if c.resource.Group == "" {
+		url = append(url, "api")
+	} else {
+		url = append(url, "apis", c.resource.Group)
+	}
+	url = append(url, c.resource.Version)
+
+	if c.namespace != "" {
+		url = append(url, "namespaces", c.namespace)
+	}
+	url = append(url, c.resource.Resource)
+
+	if name != "" {
+		url = append(url, name)
+	}
+	return url
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadataclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+)
+
+var pods = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+const (
+	podJSON = `{"apiVersion":"meta.k8s.io/v1","kind":"PartialObjectMetadata",` +
+		`"metadata":{"name":"foo","namespace":"ns","labels":{"app":"foo"}}}`
+	podListJSON = `{"apiVersion":"meta.k8s.io/v1","kind":"PartialObjectMetadataList",` +
+		`"metadata":{"resourceVersion":"42"},"items":[` + podJSON + `]}`
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) (Interface, *httptest.Server) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	c, err := NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		server.Close()
+		t.Fatalf("NewForConfig() = %v", err)
+	}
+	return c, server
+}
+
+func TestList(t *testing.T) {
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Path, "/api/v1/namespaces/ns/pods"; got != want {
+			t.Errorf("Path = %q, want %q", got, want)
+		}
+		if got := r.Header.Get("Accept"); !strings.Contains(got, "as=PartialObjectMetadataList") {
+			t.Errorf("Accept = %q, wanted it to ask for PartialObjectMetadataList", got)
+		}
+		if got, want := r.URL.Query().Get("labelSelector"), "app=foo"; got != want {
+			t.Errorf("labelSelector = %q, want %q", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, podListJSON)
+	})
+	defer server.Close()
+
+	list, err := c.Resource(pods).Namespace("ns").List(metav1.ListOptions{LabelSelector: "app=foo"})
+	if err != nil {
+		t.Fatalf("List() = %v", err)
+	}
+	if got, want := list.ResourceVersion, "42"; got != want {
+		t.Errorf("ResourceVersion = %q, want %q", got, want)
+	}
+	if len(list.Items) != 1 || list.Items[0].Name != "foo" || list.Items[0].Labels["app"] != "foo" {
+		t.Errorf("Items = %v, wanted the foo pod", list.Items)
+	}
+}
+
+func TestGet(t *testing.T) {
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Path, "/apis/apps/v1/namespaces/ns/deployments/foo"; got != want {
+			t.Errorf("Path = %q, want %q", got, want)
+		}
+		if got := r.Header.Get("Accept"); !strings.Contains(got, "as=PartialObjectMetadata;") {
+			t.Errorf("Accept = %q, wanted it to ask for PartialObjectMetadata", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, podJSON)
+	})
+	defer server.Close()
+
+	deployments := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	obj, err := c.Resource(deployments).Namespace("ns").Get("foo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if obj.Name != "foo" {
+		t.Errorf("Name = %q, want foo", obj.Name)
+	}
+
+	if _, err := c.Resource(deployments).Get("", metav1.GetOptions{}); err == nil {
+		t.Error("Get() = nil, wanted an error for an empty name")
+	}
+}
+
+func TestGetUnexpectedType(t *testing.T) {
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, podListJSON)
+	})
+	defer server.Close()
+
+	if _, err := c.Resource(pods).Namespace("ns").Get("foo", metav1.GetOptions{}); err == nil {
+		t.Error("Get() = nil, wanted an error")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Path, "/api/v1/pods"; got != want {
+			t.Errorf("Path = %q, want %q", got, want)
+		}
+		if got, want := r.URL.Query().Get("watch"), "true"; got != want {
+			t.Errorf("watch = %q, want %q", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"type":"ADDED","object":%s}`, podJSON)
+	})
+	defer server.Close()
+
+	w, err := c.Resource(pods).Watch(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Watch() = %v", err)
+	}
+	defer w.Stop()
+
+	event := <-w.ResultChan()
+	if event.Type != watch.Added {
+		t.Errorf("Type = %v, want %v", event.Type, watch.Added)
+	}
+	obj, ok := event.Object.(*metav1.PartialObjectMetadata)
+	if !ok {
+		t.Fatalf("Object = %T, wanted *metav1.PartialObjectMetadata", event.Object)
+	}
+	if obj.Name != "foo" {
+		t.Errorf("Name = %q, want foo", obj.Name)
+	}
+}
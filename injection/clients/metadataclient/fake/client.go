@@ -0,0 +1,169 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"sort"
+	"sync"
+
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"knative.dev/pkg/injection/clients/metadataclient"
+)
+
+// FakeMetadataClient is an in-memory metadataclient.Interface. Objects are
+// added, updated and deleted through its methods, which notify the
+// watches opened on the affected resource.
+type FakeMetadataClient struct {
+	m        sync.Mutex
+	objects  map[schema.GroupVersionResource]map[types.NamespacedName]*metav1.PartialObjectMetadata
+	watchers map[schema.GroupVersionResource][]*fakeWatcher
+}
+
+var _ metadataclient.Interface = (*FakeMetadataClient)(nil)
+
+type fakeWatcher struct {
+	namespace string
+	selector  labels.Selector
+	*watch.RaceFreeFakeWatcher
+}
+
+// NewSimpleMetadataClient returns an empty FakeMetadataClient.
+func NewSimpleMetadataClient() *FakeMetadataClient {
+	return &FakeMetadataClient{
+		objects:  make(map[schema.GroupVersionResource]map[types.NamespacedName]*metav1.PartialObjectMetadata),
+		watchers: make(map[schema.GroupVersionResource][]*fakeWatcher),
+	}
+}
+
+// Add adds the object to the given resource.
+func (c *FakeMetadataClient) Add(gvr schema.GroupVersionResource, obj *metav1.PartialObjectMetadata) {
+	c.set(gvr, obj, watch.Added)
+}
+
+// Update replaces the object of the given resource.
+func (c *FakeMetadataClient) Update(gvr schema.GroupVersionResource, obj *metav1.PartialObjectMetadata) {
+	c.set(gvr, obj, watch.Modified)
+}
+
+// Delete removes the object from the given resource.
+func (c *FakeMetadataClient) Delete(gvr schema.GroupVersionResource, namespace, name string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	if obj, ok := c.objects[gvr][key]; ok {
+		delete(c.objects[gvr], key)
+		c.notify(gvr, watch.Deleted, obj)
+	}
+}
+
+func (c *FakeMetadataClient) set(gvr schema.GroupVersionResource, obj *metav1.PartialObjectMetadata, eventType watch.EventType) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.objects[gvr] == nil {
+		c.objects[gvr] = make(map[types.NamespacedName]*metav1.PartialObjectMetadata)
+	}
+	obj = obj.DeepCopy()
+	c.objects[gvr][types.NamespacedName{Namespace: obj.Namespace, Name: obj.Name}] = obj
+	c.notify(gvr, eventType, obj)
+}
+
+func (c *FakeMetadataClient) notify(gvr schema.GroupVersionResource, eventType watch.EventType, obj *metav1.PartialObjectMetadata) {
+	for _, w := range c.watchers[gvr] {
+		if w.matches(obj) && !w.IsStopped() {
+			w.Action(eventType, obj.DeepCopy())
+		}
+	}
+}
+
+// Resource implements metadataclient.Interface.
+func (c *FakeMetadataClient) Resource(gvr schema.GroupVersionResource) metadataclient.NamespaceableResourceInterface {
+	return &fakeResourceClient{client: c, resource: gvr}
+}
+
+type fakeResourceClient struct {
+	client    *FakeMetadataClient
+	namespace string
+	resource  schema.GroupVersionResource
+}
+
+func (c *fakeResourceClient) Namespace(ns string) metadataclient.ResourceInterface {
+	ret := *c
+	ret.namespace = ns
+	return &ret
+}
+
+func (c *fakeResourceClient) Get(name string, _ metav1.GetOptions) (*metav1.PartialObjectMetadata, error) {
+	c.client.m.Lock()
+	defer c.client.m.Unlock()
+	obj, ok := c.client.objects[c.resource][types.NamespacedName{Namespace: c.namespace, Name: name}]
+	if !ok {
+		return nil, apierrs.NewNotFound(c.resource.GroupResource(), name)
+	}
+	return obj.DeepCopy(), nil
+}
+
+func (c *fakeResourceClient) List(opts metav1.ListOptions) (*metav1.PartialObjectMetadataList, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+	w := &fakeWatcher{namespace: c.namespace, selector: selector}
+
+	c.client.m.Lock()
+	defer c.client.m.Unlock()
+	list := &metav1.PartialObjectMetadataList{}
+	for _, obj := range c.client.objects[c.resource] {
+		if w.matches(obj) {
+			list.Items = append(list.Items, *obj.DeepCopy())
+		}
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		if list.Items[i].Namespace != list.Items[j].Namespace {
+			return list.Items[i].Namespace < list.Items[j].Namespace
+		}
+		return list.Items[i].Name < list.Items[j].Name
+	})
+	return list, nil
+}
+
+func (c *fakeResourceClient) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+	w := &fakeWatcher{
+		namespace:           c.namespace,
+		selector:            selector,
+		RaceFreeFakeWatcher: watch.NewRaceFreeFake(),
+	}
+
+	c.client.m.Lock()
+	defer c.client.m.Unlock()
+	c.client.watchers[c.resource] = append(c.client.watchers[c.resource], w)
+	return w, nil
+}
+
+func (w *fakeWatcher) matches(obj *metav1.PartialObjectMetadata) bool {
+	return (w.namespace == "" || w.namespace == obj.Namespace) &&
+		w.selector.Matches(labels.Set(obj.Labels))
+}
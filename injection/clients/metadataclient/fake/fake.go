@@ -0,0 +1,52 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+
+	"k8s.io/client-go/rest"
+
+	"knative.dev/pkg/injection"
+	"knative.dev/pkg/injection/clients/metadataclient"
+	"knative.dev/pkg/logging"
+)
+
+func init() {
+	injection.Fake.RegisterClient(withClient)
+}
+
+func withClient(ctx context.Context, cfg *rest.Config) context.Context {
+	ctx, _ = With(ctx)
+	return ctx
+}
+
+// With associates a new FakeMetadataClient with the context.
+func With(ctx context.Context) (context.Context, *FakeMetadataClient) {
+	cs := NewSimpleMetadataClient()
+	return context.WithValue(ctx, metadataclient.Key{}, cs), cs
+}
+
+// Get extracts the fake metadata client from the context.
+func Get(ctx context.Context) *FakeMetadataClient {
+	untyped := ctx.Value(metadataclient.Key{})
+	if untyped == nil {
+		logging.FromContext(ctx).Panicf(
+			"Unable to fetch %T from context.", (*FakeMetadataClient)(nil))
+	}
+	return untyped.(*FakeMetadataClient)
+}
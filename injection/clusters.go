@@ -0,0 +1,115 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package injection
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+)
+
+// Cluster is a named Kubernetes cluster, which clients and informers are
+// injected for by SetupClusters.
+type Cluster struct {
+	// Name identifies the cluster in ForCluster.
+	Name string
+	// Config is used to create the clients of the cluster.
+	Config *rest.Config
+}
+
+// ClusterFromKubeconfig returns the Cluster described by the given context of
+// the kubeconfig file at the given path. The kubeconfig's current context is
+// used if kubeContext is empty.
+func ClusterFromKubeconfig(name, path, kubeContext string) (Cluster, error) {
+	cfg, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: path},
+		&clientcmd.ConfigOverrides{CurrentContext: kubeContext},
+	).ClientConfig()
+	if err != nil {
+		return Cluster{}, fmt.Errorf("failed to load kubeconfig %q of cluster %q: %w", path, name, err)
+	}
+	return Cluster{Name: name, Config: cfg}, nil
+}
+
+// ClusterFromToken returns the Cluster whose API server is at host,
+// authenticating with the given bearer token, e.g. of a service account. The
+// server's certificate is verified with the given PEM-encoded CA bundle.
+func ClusterFromToken(name, host, token string, caData []byte) Cluster {
+	return Cluster{
+		Name: name,
+		Config: &rest.Config{
+			Host:        host,
+			BearerToken: token,
+			TLSClientConfig: rest.TLSClientConfig{
+				CAData: caData,
+			},
+		},
+	}
+}
+
+// clustersKey is the key that the contexts of the clusters are associated
+// with on contexts returned by SetupClusters.
+type clustersKey struct{}
+
+// SetupClusters runs the injectors of the given Interface once for each of
+// the given clusters. It returns a context from which the context holding the
+// clients and informers of each cluster can be accessed through ForCluster,
+// along with the informers of all of the clusters.
+func SetupClusters(ctx context.Context, i Interface, clusters ...Cluster) (context.Context, []controller.Informer) {
+	clusterCtxs := make(map[string]context.Context, len(clusters))
+	var informers []controller.Informer
+	for _, cluster := range clusters {
+		if _, ok := clusterCtxs[cluster.Name]; ok {
+			logging.FromContext(ctx).Panicf("Cluster %q is set up more than once.", cluster.Name)
+		}
+		clusterCtx, clusterInformers := i.SetupInformers(ctx, cluster.Config)
+		clusterCtxs[cluster.Name] = clusterCtx
+		informers = append(informers, clusterInformers...)
+	}
+	return context.WithValue(ctx, clustersKey{}, clusterCtxs), informers
+}
+
+// ForCluster returns the context holding the clients and informers of the
+// named cluster, as set up by SetupClusters, e.g.
+//
+//	podinformer.Get(injection.ForCluster(ctx, "east"))
+func ForCluster(ctx context.Context, name string) context.Context {
+	clusterCtxs, _ := ctx.Value(clustersKey{}).(map[string]context.Context)
+	clusterCtx, ok := clusterCtxs[name]
+	if !ok {
+		logging.FromContext(ctx).Panicf("Unable to fetch cluster %q from context.", name)
+	}
+	return clusterCtx
+}
+
+// ClusterNames returns the sorted names of the clusters set up by
+// SetupClusters.
+func ClusterNames(ctx context.Context) []string {
+	clusterCtxs, _ := ctx.Value(clustersKey{}).(map[string]context.Context)
+	names := make([]string, 0, len(clusterCtxs))
+	for name := range clusterCtxs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package injection
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/client-go/rest"
+
+	"knative.dev/pkg/controller"
+)
+
+type hostKey struct{}
+
+func TestSetupClusters(t *testing.T) {
+	i := &impl{}
+	i.RegisterClient(func(ctx context.Context, cfg *rest.Config) context.Context {
+		return context.WithValue(ctx, hostKey{}, cfg.Host)
+	})
+	i.RegisterInformer(func(ctx context.Context) (context.Context, controller.Informer) {
+		return ctx, &fakeInformer{}
+	})
+
+	ctx, infs := SetupClusters(context.Background(), i,
+		ClusterFromToken("west", "https://west.example.com", "token", nil),
+		Cluster{Name: "east", Config: &rest.Config{Host: "https://east.example.com"}},
+	)
+
+	if got, want := len(infs), 2; got != want {
+		t.Errorf("SetupClusters() = %d informers, wanted %d", got, want)
+	}
+	if got, want := ClusterNames(ctx), []string{"east", "west"}; !cmp.Equal(got, want) {
+		t.Errorf("ClusterNames() = %v, wanted %v", got, want)
+	}
+	for _, name := range []string{"east", "west"} {
+		if got, want := ForCluster(ctx, name).Value(hostKey{}), "https://"+name+".example.com"; got != want {
+			t.Errorf("ForCluster(%q) has host %v, wanted %v", name, got, want)
+		}
+	}
+}
+
+func TestForClusterPanics(t *testing.T) {
+	ctx, _ := SetupClusters(context.Background(), &impl{}, Cluster{Name: "east", Config: &rest.Config{}})
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("ForCluster() should have panicked")
+		}
+	}()
+	ForCluster(ctx, "west")
+}
+
+func TestSetupClustersDuplicate(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("SetupClusters() should have panicked")
+		}
+	}()
+	SetupClusters(context.Background(), &impl{},
+		Cluster{Name: "east", Config: &rest.Config{}},
+		Cluster{Name: "east", Config: &rest.Config{}},
+	)
+}
+
+func TestClusterFromToken(t *testing.T) {
+	c := ClusterFromToken("east", "https://east.example.com", "token", []byte("ca"))
+	if c.Name != "east" || c.Config.Host != "https://east.example.com" ||
+		c.Config.BearerToken != "token" || string(c.Config.CAData) != "ca" {
+		t.Errorf("ClusterFromToken() = %+v", c)
+	}
+}
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: east
+  cluster:
+    server: https://east.example.com
+- name: west
+  cluster:
+    server: https://west.example.com
+users:
+- name: user
+  user:
+    token: secret
+contexts:
+- name: east
+  context:
+    cluster: east
+    user: user
+- name: west
+  context:
+    cluster: west
+    user: user
+current-context: east
+`
+
+func TestClusterFromKubeconfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(path, []byte(testKubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		kubeContext string
+		wantHost    string
+	}{{
+		wantHost: "https://east.example.com",
+	}, {
+		kubeContext: "west",
+		wantHost:    "https://west.example.com",
+	}}
+	for _, test := range tests {
+		c, err := ClusterFromKubeconfig("cluster", path, test.kubeContext)
+		if err != nil {
+			t.Fatalf("ClusterFromKubeconfig(%q) = %v", test.kubeContext, err)
+		}
+		if c.Config.Host != test.wantHost || c.Config.BearerToken != "secret" {
+			t.Errorf("ClusterFromKubeconfig(%q) = %+v, wanted host %q", test.kubeContext, c.Config, test.wantHost)
+		}
+	}
+
+	if _, err := ClusterFromKubeconfig("cluster", filepath.Join(dir, "missing"), ""); err == nil {
+		t.Error("ClusterFromKubeconfig() = nil, wanted an error for a missing file")
+	}
+}
@@ -47,3 +47,25 @@ func GetNamespaceScope(ctx context.Context) string {
 	}
 	return value.(string)
 }
+
+// nsScopesKey is the key that the namespaces are associated with on
+// contexts returned by WithNamespaceScopes.
+type nsScopesKey struct{}
+
+// WithNamespaceScopes associates a list of namespaces with the provided
+// context. SetupNamespacedInformers sets up the informers once for each
+// of them, scoped to that namespace, so that a component only needs
+// namespace-scoped permissions in each of them.
+func WithNamespaceScopes(ctx context.Context, namespaces ...string) context.Context {
+	return context.WithValue(ctx, nsScopesKey{}, namespaces)
+}
+
+// GetNamespaceScopes accesses the namespaces associated with the provided
+// context by WithNamespaceScopes.
+func GetNamespaceScopes(ctx context.Context) []string {
+	value := ctx.Value(nsScopesKey{})
+	if value == nil {
+		return nil
+	}
+	return value.([]string)
+}
@@ -39,3 +39,16 @@ func TestGetBaseline(t *testing.T) {
 		t.Errorf("GetNamespaceScope() = %v, wanted %v", got, want)
 	}
 }
+
+func TestGetNamespaceScopes(t *testing.T) {
+	ctx := context.Background()
+
+	if got := GetNamespaceScopes(ctx); got != nil {
+		t.Errorf("GetNamespaceScopes() = %v, wanted nil", got)
+	}
+
+	ctx = WithNamespaceScopes(ctx, "foo", "bar")
+	if got := GetNamespaceScopes(ctx); len(got) != 2 || got[0] != "foo" || got[1] != "bar" {
+		t.Errorf("GetNamespaceScopes() = %v, wanted [foo bar]", got)
+	}
+}
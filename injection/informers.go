@@ -44,6 +44,9 @@ func (i *impl) GetInformers() []InformerInjector {
 }
 
 func (i *impl) SetupInformers(ctx context.Context, cfg *rest.Config) (context.Context, []controller.Informer) {
+	// Apply the client options once, so that all clients share them.
+	cfg = GetClientOptions(ctx).ApplyTo(cfg)
+
 	// Based on the reconcilers we have linked, build up a set of clients and inject
 	// them onto the context.
 	for _, ci := range i.GetClients() {
@@ -66,3 +69,24 @@ func (i *impl) SetupInformers(ctx context.Context, cfg *rest.Config) (context.Co
 	}
 	return ctx, informers
 }
+
+// SetupNamespacedInformers runs the injectors of the given Interface once
+// for each namespace associated with the context by WithNamespaceScopes,
+// returning a context per namespace, in the same order, along with all of the
+// injected informers. Without namespaces, it is equivalent to SetupInformers.
+func SetupNamespacedInformers(ctx context.Context, i Interface, cfg *rest.Config) ([]context.Context, []controller.Informer) {
+	namespaces := GetNamespaceScopes(ctx)
+	if len(namespaces) == 0 {
+		ctx, informers := i.SetupInformers(ctx, cfg)
+		return []context.Context{ctx}, informers
+	}
+
+	ctxs := make([]context.Context, 0, len(namespaces))
+	var informers []controller.Informer
+	for _, ns := range namespaces {
+		nsCtx, nsInformers := i.SetupInformers(WithNamespaceScope(ctx, ns), cfg)
+		ctxs = append(ctxs, nsCtx)
+		informers = append(informers, nsInformers...)
+	}
+	return ctxs, informers
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicinformer
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+
+	"knative.dev/pkg/apis/duck"
+	kubeclient "knative.dev/pkg/client/injection/kube/client"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/injection"
+	"knative.dev/pkg/injection/clients/dynamicclient"
+	"knative.dev/pkg/logging"
+)
+
+// DefaultInterval is the interval in which the injected DiscoveringFactory
+// polls for pending resources.
+const DefaultInterval = 10 * time.Second
+
+func init() {
+	injection.Default.RegisterInformerFactory(withInformerFactory)
+}
+
+// Key is used as the key for associating information
+// with a context.Context.
+type Key struct{}
+
+func withInformerFactory(ctx context.Context) context.Context {
+	return WithInformerFactory(ctx, dynamicclient.Get(ctx), kubeclient.Get(ctx).Discovery())
+}
+
+// WithInformerFactory associates a DiscoveringFactory creating informers with
+// the given client, once the given discovery reports their resources as
+// served, with the context. The informers are stopped when the context is
+// done.
+func WithInformerFactory(ctx context.Context, client dynamic.Interface, discovery ResourceDiscoverer) context.Context {
+	return context.WithValue(ctx, Key{}, &DiscoveringFactory{
+		Delegate: &duck.CachedInformerFactory{
+			Delegate: &InformerFactory{
+				Client:       client,
+				Namespace:    injection.GetNamespaceScope(ctx),
+				ResyncPeriod: controller.GetResyncPeriod(ctx),
+				StopChannel:  ctx.Done(),
+			},
+		},
+		Discovery:   discovery,
+		Interval:    DefaultInterval,
+		StopChannel: ctx.Done(),
+	})
+}
+
+// Get extracts the DiscoveringFactory from the context.
+func Get(ctx context.Context) *DiscoveringFactory {
+	untyped := ctx.Value(Key{})
+	if untyped == nil {
+		logging.FromContext(ctx).Panic(
+			"Unable to fetch dynamic DiscoveringFactory from context.")
+	}
+	return untyped.(*DiscoveringFactory)
+}
+
+// NewInformer creates an informer for the given resource with the given
+// dynamic client, without starting it. It backs the injected dynamic
+// informers generated by injection-gen, which pass the client found in the
+// context, or its fake in tests.
+func NewInformer(ctx context.Context, client dynamic.Interface, gvr schema.GroupVersionResource) informers.GenericInformer {
+	f := &InformerFactory{
+		Client:       client,
+		Namespace:    injection.GetNamespaceScope(ctx),
+		ResyncPeriod: controller.GetResyncPeriod(ctx),
+		StopChannel:  ctx.Done(),
+	}
+	inf, lister := f.New(gvr)
+	return &genericInformer{informer: inf, lister: lister}
+}
+
+// genericInformer implements informers.GenericInformer.
+type genericInformer struct {
+	informer cache.SharedIndexInformer
+	lister   cache.GenericLister
+}
+
+// Informer implements informers.GenericInformer.
+func (i *genericInformer) Informer() cache.SharedIndexInformer {
+	return i.informer
+}
+
+// Lister implements informers.GenericInformer.
+func (i *genericInformer) Lister() cache.GenericLister {
+	return i.lister
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicinformer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+
+	"knative.dev/pkg/apis/duck"
+)
+
+// InformerFactory implements duck.InformerFactory such that the elements
+// tracked by the informer/lister are *unstructured.Unstructured.
+type InformerFactory struct {
+	Client       dynamic.Interface
+	Namespace    string
+	ResyncPeriod time.Duration
+	StopChannel  <-chan struct{}
+}
+
+// Check that InformerFactory implements duck.InformerFactory.
+var _ duck.InformerFactory = (*InformerFactory)(nil)
+
+// Get implements duck.InformerFactory.
+func (f *InformerFactory) Get(gvr schema.GroupVersionResource) (cache.SharedIndexInformer, cache.GenericLister, error) {
+	inf, lister := f.New(gvr)
+
+	go inf.Run(f.StopChannel)
+
+	if ok := cache.WaitForCacheSync(f.StopChannel, inf.HasSynced); !ok {
+		return nil, nil, fmt.Errorf("failed starting dynamic informer for %v", gvr)
+	}
+
+	return inf, lister, nil
+}
+
+// New creates an informer and a lister for the given resource without
+// starting the informer.
+func (f *InformerFactory) New(gvr schema.GroupVersionResource) (cache.SharedIndexInformer, cache.GenericLister) {
+	var ri dynamic.ResourceInterface = f.Client.Resource(gvr)
+	if f.Namespace != "" {
+		ri = f.Client.Resource(gvr).Namespace(f.Namespace)
+	}
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return ri.List(opts)
+		},
+		WatchFunc: ri.Watch,
+	}
+	inf := cache.NewSharedIndexInformer(lw, &unstructured.Unstructured{}, f.ResyncPeriod, cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+	})
+	return inf, cache.NewGenericLister(inf.GetIndexer(), gvr.GroupResource())
+}
+
+// ResourceDiscoverer is the part of discovery.DiscoveryInterface used to
+// find out whether a resource is served.
+type ResourceDiscoverer interface {
+	ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error)
+}
+
+// Callback is called with the started and synced informer and the lister of
+// a resource, once the resource is served.
+type Callback func(schema.GroupVersionResource, cache.SharedIndexInformer, cache.GenericLister)
+
+// DiscoveringFactory creates informers for resources which might not be
+// served yet, like the ones of CRDs installed after the process started.
+// It polls the API server's discovery until the resources are served and
+// then calls the callbacks registered for them.
+type DiscoveringFactory struct {
+	// Delegate creates and starts the informers.
+	Delegate duck.InformerFactory
+	// Discovery is polled for the resources.
+	Discovery ResourceDiscoverer
+	// Interval is the time between two polls.
+	Interval time.Duration
+	// StopChannel stops polling.
+	StopChannel <-chan struct{}
+
+	once    sync.Once
+	m       sync.Mutex
+	pending map[schema.GroupVersionResource][]Callback
+	ready   map[schema.GroupVersionResource]bool
+}
+
+// OnAvailable registers a callback to be called once the given resource is
+// served and its informer is synced. If that's the case already, the
+// callback is called right away. The callback is called at most once.
+func (f *DiscoveringFactory) OnAvailable(gvr schema.GroupVersionResource, cb Callback) {
+	f.m.Lock()
+	if f.ready[gvr] {
+		f.m.Unlock()
+		f.notify(gvr, cb)
+		return
+	}
+	if f.pending == nil {
+		f.pending = make(map[schema.GroupVersionResource][]Callback)
+		f.ready = make(map[schema.GroupVersionResource]bool)
+	}
+	f.pending[gvr] = append(f.pending[gvr], cb)
+	f.m.Unlock()
+
+	f.once.Do(func() {
+		go wait.Until(f.poll, f.Interval, f.StopChannel)
+	})
+}
+
+// poll checks the pending resources and calls the callbacks of the ones
+// which are served now.
+func (f *DiscoveringFactory) poll() {
+	f.m.Lock()
+	gvrs := make([]schema.GroupVersionResource, 0, len(f.pending))
+	for gvr := range f.pending {
+		gvrs = append(gvrs, gvr)
+	}
+	f.m.Unlock()
+
+	for _, gvr := range gvrs {
+		if !f.served(gvr) {
+			continue
+		}
+		f.m.Lock()
+		cbs := f.pending[gvr]
+		delete(f.pending, gvr)
+		f.ready[gvr] = true
+		f.m.Unlock()
+
+		for _, cb := range cbs {
+			f.notify(gvr, cb)
+		}
+	}
+}
+
+// served returns true if the API server serves the given resource.
+func (f *DiscoveringFactory) served(gvr schema.GroupVersionResource) bool {
+	resources, err := f.Discovery.ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+	if err != nil {
+		// The group version is not served (yet), or the API server can't
+		// be reached right now, try again later.
+		return false
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == gvr.Resource {
+			return true
+		}
+	}
+	return false
+}
+
+// notify calls the callback with the informer of the resource, unless it
+// fails to be started.
+func (f *DiscoveringFactory) notify(gvr schema.GroupVersionResource, cb Callback) {
+	inf, lister, err := f.Delegate.Get(gvr)
+	if err != nil {
+		// The informer only fails to sync if we are being stopped.
+		return
+	}
+	cb(gvr, inf, lister)
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicinformer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/tools/cache"
+
+	. "knative.dev/pkg/testing"
+)
+
+var resources = SchemeGroupVersion.WithResource("resources")
+
+// discoverer is a ResourceDiscoverer whose served resources can be changed.
+type discoverer struct {
+	m         sync.Mutex
+	resources map[string][]string
+}
+
+func (d *discoverer) serve(gvr schema.GroupVersionResource) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	gv := gvr.GroupVersion().String()
+	d.resources[gv] = append(d.resources[gv], gvr.Resource)
+}
+
+func (d *discoverer) ServerResourcesForGroupVersion(gv string) (*metav1.APIResourceList, error) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	names, ok := d.resources[gv]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	list := &metav1.APIResourceList{GroupVersion: gv}
+	for _, name := range names {
+		list.APIResources = append(list.APIResources, metav1.APIResource{Name: name})
+	}
+	return list, nil
+}
+
+func newClient() *fake.FakeDynamicClient {
+	scheme := runtime.NewScheme()
+	AddToScheme(scheme)
+	return fake.NewSimpleDynamicClient(scheme, &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "pkg.knative.dev/v2",
+			"kind":       "Resource",
+			"metadata": map[string]interface{}{
+				"namespace": "foo",
+				"name":      "bar",
+			},
+		},
+	})
+}
+
+func TestInformerFactory(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	f := &InformerFactory{
+		Client:      newClient(),
+		StopChannel: stopCh,
+	}
+	_, lister, err := f.Get(resources)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	elt, err := lister.ByNamespace("foo").Get("bar")
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if _, ok := elt.(*unstructured.Unstructured); !ok {
+		t.Errorf("Get() = %T, wanted *unstructured.Unstructured", elt)
+	}
+}
+
+func TestDiscoveringFactory(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	disco := &discoverer{resources: map[string][]string{
+		// The group version is served, but not the resource yet.
+		SchemeGroupVersion.String(): {"others"},
+	}}
+	f := Get(WithInformerFactory(ctx, newClient(), disco))
+	f.Interval = 10 * time.Millisecond
+
+	called := make(chan cache.GenericLister, 2)
+	cb := func(gvr schema.GroupVersionResource, _ cache.SharedIndexInformer, lister cache.GenericLister) {
+		if gvr != resources {
+			t.Errorf("Callback called with %v, wanted %v", gvr, resources)
+		}
+		called <- lister
+	}
+	f.OnAvailable(resources, cb)
+
+	select {
+	case <-called:
+		t.Fatal("The callback was called before the resource was served")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	disco.serve(resources)
+	select {
+	case lister := <-called:
+		if _, err := lister.ByNamespace("foo").Get("bar"); err != nil {
+			t.Errorf("Get() = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The callback was not called after the resource was served")
+	}
+
+	// Callbacks registered once the resource is served are called right away.
+	f.OnAvailable(resources, cb)
+	select {
+	case <-called:
+	default:
+		t.Error("The callback was not called for an available resource")
+	}
+}
+
+func TestGetPanic(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("Get() should have panicked")
+		}
+	}()
+	Get(context.Background())
+}
+
+func TestNewInformer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	inf := NewInformer(ctx, newClient(), resources)
+	go inf.Informer().Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), inf.Informer().HasSynced) {
+		t.Fatal("Failed to sync the informer")
+	}
+	if _, err := inf.Lister().ByNamespace("foo").Get("bar"); err != nil {
+		t.Errorf("Get() = %v", err)
+	}
+}
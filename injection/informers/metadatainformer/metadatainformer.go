@@ -0,0 +1,148 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadatainformer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+
+	"knative.dev/pkg/apis/duck"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/injection"
+	"knative.dev/pkg/injection/clients/metadataclient"
+	"knative.dev/pkg/logging"
+)
+
+func init() {
+	injection.Default.RegisterInformerFactory(withInformerFactory)
+}
+
+// Key is used as the key for associating information
+// with a context.Context.
+type Key struct{}
+
+func withInformerFactory(ctx context.Context) context.Context {
+	return WithInformerFactory(ctx, metadataclient.Get(ctx))
+}
+
+// WithInformerFactory associates an InformerFactory using the given client
+// with the context. The informers are stopped when the context is done.
+func WithInformerFactory(ctx context.Context, client metadataclient.Interface) context.Context {
+	return context.WithValue(ctx, Key{}, &duck.CachedInformerFactory{
+		Delegate: &InformerFactory{
+			Client:       client,
+			Namespace:    injection.GetNamespaceScope(ctx),
+			ResyncPeriod: controller.GetResyncPeriod(ctx),
+			StopChannel:  ctx.Done(),
+		},
+	})
+}
+
+// Get extracts the metadata InformerFactory from the context.
+func Get(ctx context.Context) duck.InformerFactory {
+	untyped := ctx.Value(Key{})
+	if untyped == nil {
+		logging.FromContext(ctx).Panic(
+			"Unable to fetch metadata InformerFactory from context.")
+	}
+	return untyped.(duck.InformerFactory)
+}
+
+// NewInformer creates an informer for the given resource with the given
+// metadata client, without starting it. It backs the injected metadata
+// informers generated by injection-gen, which pass the client found in the
+// context, or its fake in tests.
+func NewInformer(ctx context.Context, client metadataclient.Interface, gvr schema.GroupVersionResource) informers.GenericInformer {
+	f := &InformerFactory{
+		Client:       client,
+		Namespace:    injection.GetNamespaceScope(ctx),
+		ResyncPeriod: controller.GetResyncPeriod(ctx),
+		StopChannel:  ctx.Done(),
+	}
+	inf, lister := f.New(gvr)
+	return &genericInformer{informer: inf, lister: lister}
+}
+
+// genericInformer implements informers.GenericInformer.
+type genericInformer struct {
+	informer cache.SharedIndexInformer
+	lister   cache.GenericLister
+}
+
+// Informer implements informers.GenericInformer.
+func (i *genericInformer) Informer() cache.SharedIndexInformer {
+	return i.informer
+}
+
+// Lister implements informers.GenericInformer.
+func (i *genericInformer) Lister() cache.GenericLister {
+	return i.lister
+}
+
+// InformerFactory implements duck.InformerFactory such that the elements
+// tracked by the informer/lister are *metav1.PartialObjectMetadata, holding
+// only the metadata of the objects. This drastically cuts the memory used
+// for watching resources with many or large objects, when the labels or
+// owner references are all a controller needs.
+type InformerFactory struct {
+	Client       metadataclient.Interface
+	Namespace    string
+	ResyncPeriod time.Duration
+	StopChannel  <-chan struct{}
+}
+
+// Check that InformerFactory implements duck.InformerFactory.
+var _ duck.InformerFactory = (*InformerFactory)(nil)
+
+// Get implements duck.InformerFactory.
+func (f *InformerFactory) Get(gvr schema.GroupVersionResource) (cache.SharedIndexInformer, cache.GenericLister, error) {
+	inf, lister := f.New(gvr)
+
+	go inf.Run(f.StopChannel)
+
+	if ok := cache.WaitForCacheSync(f.StopChannel, inf.HasSynced); !ok {
+		return nil, nil, fmt.Errorf("failed starting metadata informer for %v", gvr)
+	}
+
+	return inf, lister, nil
+}
+
+// New creates an informer and a lister for the given resource without
+// starting the informer.
+func (f *InformerFactory) New(gvr schema.GroupVersionResource) (cache.SharedIndexInformer, cache.GenericLister) {
+	var ri metadataclient.ResourceInterface = f.Client.Resource(gvr)
+	if f.Namespace != "" {
+		ri = f.Client.Resource(gvr).Namespace(f.Namespace)
+	}
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return ri.List(opts)
+		},
+		WatchFunc: ri.Watch,
+	}
+	inf := cache.NewSharedIndexInformer(lw, &metav1.PartialObjectMetadata{}, f.ResyncPeriod, cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+	})
+	return inf, cache.NewGenericLister(inf.GetIndexer(), gvr.GroupResource())
+}
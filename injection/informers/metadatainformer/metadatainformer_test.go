@@ -0,0 +1,149 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadatainformer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+
+	fakemetadataclient "knative.dev/pkg/injection/clients/metadataclient/fake"
+)
+
+var pods = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+func pod(namespace, name string, labels map[string]string) *metav1.PartialObjectMetadata {
+	return &metav1.PartialObjectMetadata{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels:    labels,
+		},
+	}
+}
+
+func TestGetPanic(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("Get() should have panicked")
+		}
+	}()
+
+	Get(context.Background())
+}
+
+func TestInformerFactory(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := fakemetadataclient.NewSimpleMetadataClient()
+	client.Add(pods, pod("ns", "foo", map[string]string{"app": "foo"}))
+	client.Add(pods, pod("other", "bar", nil))
+
+	factory := Get(WithInformerFactory(ctx, client))
+	inf, lister, err := factory.Get(pods)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+
+	objs, err := lister.ByNamespace("ns").List(labels.Everything())
+	if err != nil {
+		t.Fatalf("List() = %v", err)
+	}
+	if len(objs) != 1 {
+		t.Fatalf("List() = %v, wanted the foo pod", objs)
+	}
+	if got := objs[0].(*metav1.PartialObjectMetadata); got.Labels["app"] != "foo" {
+		t.Errorf("Labels = %v, wanted app=foo", got.Labels)
+	}
+
+	// Updates are delivered to the informer.
+	client.Update(pods, pod("ns", "foo", map[string]string{"app": "bar"}))
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		obj, err := lister.ByNamespace("ns").Get("foo")
+		if err != nil {
+			return false, err
+		}
+		return obj.(*metav1.PartialObjectMetadata).Labels["app"] == "bar", nil
+	}); err != nil {
+		t.Errorf("The update was not observed: %v", err)
+	}
+
+	client.Delete(pods, "other", "bar")
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return len(inf.GetStore().List()) == 1, nil
+	}); err != nil {
+		t.Errorf("The deletion was not observed: %v", err)
+	}
+
+	// The informers are cached.
+	if again, _, _ := factory.Get(pods); again != inf {
+		t.Error("Get() returned a different informer for the same resource")
+	}
+}
+
+func TestInformerFactoryNamespaced(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	client := fakemetadataclient.NewSimpleMetadataClient()
+	client.Add(pods, pod("ns", "foo", nil))
+	client.Add(pods, pod("other", "bar", nil))
+
+	factory := &InformerFactory{
+		Client:      client,
+		Namespace:   "other",
+		StopChannel: stopCh,
+	}
+	_, lister, err := factory.Get(pods)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	objs, err := lister.List(labels.Everything())
+	if err != nil {
+		t.Fatalf("List() = %v", err)
+	}
+	if len(objs) != 1 || objs[0].(*metav1.PartialObjectMetadata).Name != "bar" {
+		t.Errorf("List() = %v, wanted only the bar pod", objs)
+	}
+}
+
+func TestNewInformer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := fakemetadataclient.NewSimpleMetadataClient()
+	client.Add(pods, pod("ns", "foo", nil))
+
+	inf := NewInformer(ctx, client, pods)
+	if inf.Informer().HasSynced() {
+		t.Error("NewInformer() started the informer")
+	}
+	go inf.Informer().Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), inf.Informer().HasSynced) {
+		t.Fatal("Failed to sync the informer")
+	}
+	if _, err := inf.Lister().ByNamespace("ns").Get("foo"); err != nil {
+		t.Errorf("Get() = %v", err)
+	}
+}
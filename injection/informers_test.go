@@ -68,3 +68,32 @@ func TestRegisterInformersAndSetup(t *testing.T) {
 		t.Errorf("SetupInformers() = %d, wanted %d", want, got)
 	}
 }
+
+func TestSetupNamespacedInformers(t *testing.T) {
+	i := &impl{}
+	var namespaces []string
+	i.RegisterInformer(func(ctx context.Context) (context.Context, controller.Informer) {
+		namespaces = append(namespaces, GetNamespaceScope(ctx))
+		return ctx, &fakeInformer{}
+	})
+
+	ctxs, infs := SetupNamespacedInformers(context.Background(), i, &rest.Config{})
+	if len(ctxs) != 1 || len(infs) != 1 {
+		t.Errorf("SetupNamespacedInformers() = %d contexts, %d informers, wanted 1 and 1", len(ctxs), len(infs))
+	}
+
+	namespaces = nil
+	ctx := WithNamespaceScopes(context.Background(), "foo", "bar")
+	ctxs, infs = SetupNamespacedInformers(ctx, i, &rest.Config{})
+	if len(ctxs) != 2 || len(infs) != 2 {
+		t.Errorf("SetupNamespacedInformers() = %d contexts, %d informers, wanted 2 and 2", len(ctxs), len(infs))
+	}
+	for idx, want := range []string{"foo", "bar"} {
+		if got := GetNamespaceScope(ctxs[idx]); got != want {
+			t.Errorf("GetNamespaceScope(ctxs[%d]) = %q, wanted %q", idx, got, want)
+		}
+		if namespaces[idx] != want {
+			t.Errorf("Informer %d was set up in %q, wanted %q", idx, namespaces[idx], want)
+		}
+	}
+}
@@ -54,7 +54,8 @@ type Interface interface {
 	// the clients and the given rest.Config.  The resulting context is returned
 	// along with a list of the .Informer() for each of the injected informers,
 	// which is suitable for passing to controller.StartInformers().
-	// This does not setup or start any controllers.
+	// This does not setup or start any controllers. The ClientOptions
+	// associated with the context are applied to the rest.Config.
 	SetupInformers(context.Context, *rest.Config) (context.Context, []controller.Informer)
 }
 
@@ -0,0 +1,148 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package injection
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"knative.dev/pkg/controller"
+)
+
+// lazyKey is the key that the retrieved informers are associated with on
+// contexts returned by WithLazyInformers.
+type lazyKey struct{}
+
+// retrievedInformers records the keys of the informers which have been
+// retrieved from a context.
+type retrievedInformers struct {
+	m    sync.RWMutex
+	keys map[interface{}]struct{}
+}
+
+func (r *retrievedInformers) add(key interface{}) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.keys[key] = struct{}{}
+}
+
+func (r *retrievedInformers) has(key interface{}) bool {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	_, ok := r.keys[key]
+	return ok
+}
+
+// WithLazyInformers returns a context in which the informers set up by
+// SetupInformers are only created and started if they have been retrieved
+// through their accessor (e.g. `podinformer.Get(ctx)`) by the time they are
+// started. This avoids watching resources, and needing the permissions to do
+// so, just because their informers are linked into the binary.
+func WithLazyInformers(ctx context.Context) context.Context {
+	return context.WithValue(ctx, lazyKey{}, &retrievedInformers{
+		keys: make(map[interface{}]struct{}),
+	})
+}
+
+// HasLazyInformers determines whether the provided context has been set up
+// with WithLazyInformers.
+func HasLazyInformers(ctx context.Context) bool {
+	return ctx.Value(lazyKey{}) != nil
+}
+
+// LazyInformer returns an informer which is only created, using create,
+// once it has been retrieved from the context under the given key. Without
+// WithLazyInformers the informer is created right away. This should be
+// called by the informer injectors.
+func LazyInformer(ctx context.Context, key interface{}, create func() controller.Informer) controller.Informer {
+	retrieved, ok := ctx.Value(lazyKey{}).(*retrievedInformers)
+	if !ok {
+		return create()
+	}
+	return &lazyInformer{
+		retrieved: retrieved,
+		key:       key,
+		scope:     GetNamespaceScope(ctx),
+		create:    create,
+	}
+}
+
+// InformerRetrieved records that the informer associated with the given key
+// has been retrieved from the context. This should be called by the informer
+// accessors.
+func InformerRetrieved(ctx context.Context, key interface{}) {
+	if retrieved, ok := ctx.Value(lazyKey{}).(*retrievedInformers); ok {
+		retrieved.add(key)
+	}
+}
+
+// lazyInformer is a controller.Informer which decides whether to create
+// the actual informer the first time it's run or checked for being synced.
+type lazyInformer struct {
+	retrieved *retrievedInformers
+	key       interface{}
+	scope     string
+	create    func() controller.Informer
+
+	once     sync.Once
+	informer controller.Informer
+}
+
+var _ controller.NamedInformer = (*lazyInformer)(nil)
+
+// get returns the actual informer, or nil if it was not retrieved.
+func (l *lazyInformer) get() controller.Informer {
+	l.once.Do(func() {
+		if l.retrieved.has(l.key) {
+			l.informer = l.create()
+		}
+	})
+	return l.informer
+}
+
+// Run implements controller.Informer. It returns right away for informers
+// which were not retrieved.
+func (l *lazyInformer) Run(stopCh <-chan struct{}) {
+	if inf := l.get(); inf != nil {
+		inf.Run(stopCh)
+	}
+}
+
+// HasSynced implements controller.Informer. Informers which were not
+// retrieved are always synced.
+func (l *lazyInformer) HasSynced() bool {
+	if inf := l.get(); inf != nil {
+		return inf.HasSynced()
+	}
+	return true
+}
+
+// Name implements controller.NamedInformer. Informers are named after the
+// type of their key, which lives in the package of their injector, and the
+// namespace they are scoped to, if any.
+func (l *lazyInformer) Name() string {
+	name := fmt.Sprintf("%T", l.key)
+	if t := reflect.TypeOf(l.key); t != nil && t.PkgPath() != "" {
+		name = t.PkgPath() + "." + t.Name()
+	}
+	if l.scope != "" {
+		name += " (namespace " + l.scope + ")"
+	}
+	return name
+}
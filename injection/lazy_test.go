@@ -0,0 +1,124 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package injection
+
+import (
+	"context"
+	"testing"
+
+	"knative.dev/pkg/controller"
+)
+
+type fooKey struct{}
+
+type countingInformer struct {
+	created int
+	runs    int
+}
+
+func (c *countingInformer) create() controller.Informer {
+	c.created++
+	return c
+}
+
+func (c *countingInformer) Run(<-chan struct{}) {
+	c.runs++
+}
+
+func (c *countingInformer) HasSynced() bool {
+	return false
+}
+
+func TestLazyInformerEager(t *testing.T) {
+	ctx := context.Background()
+	if HasLazyInformers(ctx) {
+		t.Error("HasLazyInformers() = true, wanted false")
+	}
+
+	c := &countingInformer{}
+	inf := LazyInformer(ctx, fooKey{}, c.create)
+	if c.created != 1 {
+		t.Errorf("created = %d, wanted 1", c.created)
+	}
+	if inf != c {
+		t.Error("LazyInformer() did not return the created informer")
+	}
+}
+
+func TestLazyInformerRetrieved(t *testing.T) {
+	ctx := WithLazyInformers(context.Background())
+	if !HasLazyInformers(ctx) {
+		t.Error("HasLazyInformers() = false, wanted true")
+	}
+
+	c := &countingInformer{}
+	inf := LazyInformer(ctx, fooKey{}, c.create)
+	if c.created != 0 {
+		t.Errorf("created = %d, wanted 0", c.created)
+	}
+
+	InformerRetrieved(ctx, fooKey{})
+	inf.Run(nil)
+	inf.Run(nil)
+	if c.created != 1 {
+		t.Errorf("created = %d, wanted 1", c.created)
+	}
+	if c.runs != 2 {
+		t.Errorf("runs = %d, wanted 2", c.runs)
+	}
+	if inf.HasSynced() {
+		t.Error("HasSynced() = true, wanted the result of the created informer")
+	}
+}
+
+func TestLazyInformerNotRetrieved(t *testing.T) {
+	ctx := WithLazyInformers(context.Background())
+
+	c := &countingInformer{}
+	inf := LazyInformer(ctx, fooKey{}, c.create)
+	InformerRetrieved(ctx, struct{}{})
+
+	inf.Run(nil)
+	if !inf.HasSynced() {
+		t.Error("HasSynced() = false, wanted true")
+	}
+
+	// Retrieving the informer after it has been started has no effect.
+	InformerRetrieved(ctx, fooKey{})
+	inf.Run(nil)
+	if !inf.HasSynced() {
+		t.Error("HasSynced() = false, wanted true")
+	}
+	if c.created != 0 {
+		t.Errorf("created = %d, wanted 0", c.created)
+	}
+}
+
+func TestLazyInformerName(t *testing.T) {
+	ctx := WithLazyInformers(context.Background())
+	c := &countingInformer{}
+
+	inf := LazyInformer(ctx, fooKey{}, c.create).(controller.NamedInformer)
+	if got, want := inf.Name(), "knative.dev/pkg/injection.fooKey"; got != want {
+		t.Errorf("Name() = %q, wanted %q", got, want)
+	}
+
+	inf = LazyInformer(WithNamespaceScope(ctx, "foo"), fooKey{}, c.create).(controller.NamedInformer)
+	if got, want := inf.Name(), "knative.dev/pkg/injection.fooKey (namespace foo)"; got != want {
+		t.Errorf("Name() = %q, wanted %q", got, want)
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sharedmain
+
+import (
+	"context"
+
+	"knative.dev/pkg/configmap"
+)
+
+// Component is run by MainWithConfig alongside the controllers, e.g. a
+// webhook. Components are stopped first at shutdown, before the controllers
+// are drained. Components implementing HealthChecker or ReadinessChecker are
+// checked by the probes.
+type Component interface {
+	// Run runs the component until the stop channel is closed.
+	Run(stop <-chan struct{}) error
+}
+
+// ComponentConstructor constructs a Component, once the injection and the
+// logger are set up in the context.
+type ComponentConstructor func(context.Context, configmap.Watcher) Component
+
+// componentsKey is used as the key for associating component constructors
+// with a context.
+type componentsKey struct{}
+
+// WithComponents associates the constructors of the components run alongside
+// the controllers with the given context, in addition to the ones already
+// associated with it.
+func WithComponents(ctx context.Context, ctors ...ComponentConstructor) context.Context {
+	return context.WithValue(ctx, componentsKey{}, append(GetComponents(ctx), ctors...))
+}
+
+// GetComponents returns the constructors of the components associated with
+// the given context.
+func GetComponents(ctx context.Context) []ComponentConstructor {
+	ctors, _ := ctx.Value(componentsKey{}).([]ComponentConstructor)
+	// Copy the constructors, so that appending to them doesn't alias.
+	return append([]ComponentConstructor(nil), ctors...)
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sharedmain
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	// livenessPath is the path of the profiling server at which the
+	// liveness probes are answered.
+	livenessPath = "/healthz"
+
+	// readinessPath is the path of the profiling server at which the
+	// readiness probes are answered.
+	readinessPath = "/readyz"
+)
+
+// errShuttingDown is the readiness error of a Health once shutting down.
+var errShuttingDown = errors.New("shutting down")
+
+// Check checks the health or the readiness of a component of the process,
+// and returns an error telling why it isn't healthy or ready, if it isn't.
+type Check func() error
+
+// HealthChecker is implemented by the components which can tell whether they
+// are healthy, i.e. don't need to be restarted.
+type HealthChecker interface {
+	Healthy() error
+}
+
+// ReadinessChecker is implemented by the components which can tell whether
+// they are ready, e.g. a webhook which isn't until it serves.
+type ReadinessChecker interface {
+	Ready() error
+}
+
+// Health aggregates the liveness and readiness checks of the components of a
+// process, e.g. its webhooks, controllers and informers, and answers the
+// probes of the kubelet. Readiness fails as soon as the process starts to
+// shut down, so that it's taken out of rotation first.
+type Health struct {
+	mu           sync.RWMutex
+	liveness     []namedCheck
+	readiness    []namedCheck
+	shuttingDown bool
+}
+
+// namedCheck is a check along with the name of the component it checks.
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// AddLivenessCheck adds the liveness check of the named component.
+func (h *Health) AddLivenessCheck(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.liveness = append(h.liveness, namedCheck{name: name, check: check})
+}
+
+// AddReadinessCheck adds the readiness check of the named component.
+func (h *Health) AddReadinessCheck(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.readiness = append(h.readiness, namedCheck{name: name, check: check})
+}
+
+// AddComponent adds the checks of the named component, depending on whether
+// it implements HealthChecker and ReadinessChecker.
+func (h *Health) AddComponent(name string, component interface{}) {
+	if hc, ok := component.(HealthChecker); ok {
+		h.AddLivenessCheck(name, hc.Healthy)
+	}
+	if rc, ok := component.(ReadinessChecker); ok {
+		h.AddReadinessCheck(name, rc.Ready)
+	}
+}
+
+// ShutDown marks the process as shutting down, which fails its readiness.
+func (h *Health) ShutDown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.shuttingDown = true
+}
+
+// Healthy returns an error listing the components which aren't healthy, if any.
+func (h *Health) Healthy() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return runChecks(h.liveness)
+}
+
+// Ready returns an error listing the components which aren't ready, if any.
+func (h *Health) Ready() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.shuttingDown {
+		return errShuttingDown
+	}
+	return runChecks(h.readiness)
+}
+
+// LivenessHandler answers the liveness probes.
+func (h *Health) LivenessHandler() http.Handler {
+	return probeHandler(h.Healthy)
+}
+
+// ReadinessHandler answers the readiness probes.
+func (h *Health) ReadinessHandler() http.Handler {
+	return probeHandler(h.Ready)
+}
+
+// runChecks runs all of the checks, and returns an error listing the failed
+// ones, if any.
+func runChecks(checks []namedCheck) error {
+	var failed []string
+	for _, c := range checks {
+		if err := c.check(); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", c.name, err))
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(failed, "; "))
+}
+
+// probeHandler answers probes with http.StatusOK if the check passes, and with
+// http.StatusServiceUnavailable along with its error otherwise.
+func probeHandler(check Check) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := check(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+}
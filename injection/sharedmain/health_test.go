@@ -0,0 +1,166 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sharedmain
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"knative.dev/pkg/configmap"
+)
+
+// fakeComponent is a Component whose checks return the configured errors.
+type fakeComponent struct {
+	healthy, ready error
+}
+
+func (c *fakeComponent) Run(<-chan struct{}) error { return nil }
+func (c *fakeComponent) Healthy() error            { return c.healthy }
+func (c *fakeComponent) Ready() error              { return c.ready }
+
+// runOnly is a Component without checks.
+type runOnly struct{}
+
+func (runOnly) Run(<-chan struct{}) error { return nil }
+
+func TestHealth(t *testing.T) {
+	h := &Health{}
+	if err := h.Healthy(); err != nil {
+		t.Errorf("Healthy() = %v without checks", err)
+	}
+	if err := h.Ready(); err != nil {
+		t.Errorf("Ready() = %v without checks", err)
+	}
+
+	c := &fakeComponent{}
+	h.AddComponent("webhook", c)
+	h.AddComponent("other", runOnly{})
+	synced := false
+	h.AddReadinessCheck("informers", func() error {
+		if !synced {
+			return errors.New("still syncing")
+		}
+		return nil
+	})
+
+	if got, want := errString(h.Ready()), "informers: still syncing"; got != want {
+		t.Errorf("Ready() = %q, want %q", got, want)
+	}
+	synced = true
+	if err := h.Ready(); err != nil {
+		t.Errorf("Ready() = %v, wanted ready", err)
+	}
+
+	c.healthy = errors.New("stuck")
+	c.ready = errors.New("not serving")
+	if got, want := errString(h.Healthy()), "webhook: stuck"; got != want {
+		t.Errorf("Healthy() = %q, want %q", got, want)
+	}
+	synced = false
+	if got, want := errString(h.Ready()), "webhook: not serving; informers: still syncing"; got != want {
+		t.Errorf("Ready() = %q, want %q", got, want)
+	}
+
+	c.healthy, c.ready, synced = nil, nil, true
+	h.ShutDown()
+	if err := h.Healthy(); err != nil {
+		t.Errorf("Healthy() = %v while shutting down, wanted healthy", err)
+	}
+	if got, want := errString(h.Ready()), errShuttingDown.Error(); got != want {
+		t.Errorf("Ready() = %q while shutting down, want %q", got, want)
+	}
+}
+
+func TestHealthHandlers(t *testing.T) {
+	h := &Health{}
+	c := &fakeComponent{}
+	h.AddComponent("webhook", c)
+
+	tests := []struct {
+		name     string
+		handler  http.Handler
+		err      error
+		wantCode int
+		wantBody string
+	}{{
+		name:     "live",
+		handler:  h.LivenessHandler(),
+		wantCode: http.StatusOK,
+		wantBody: "ok",
+	}, {
+		name:     "not live",
+		handler:  h.LivenessHandler(),
+		err:      errors.New("stuck"),
+		wantCode: http.StatusServiceUnavailable,
+		wantBody: "webhook: stuck\n",
+	}, {
+		name:     "ready",
+		handler:  h.ReadinessHandler(),
+		wantCode: http.StatusOK,
+		wantBody: "ok",
+	}, {
+		name:     "not ready",
+		handler:  h.ReadinessHandler(),
+		err:      errors.New("stuck"),
+		wantCode: http.StatusServiceUnavailable,
+		wantBody: "webhook: stuck\n",
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c.healthy, c.ready = test.err, test.err
+			w := httptest.NewRecorder()
+			test.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != test.wantCode {
+				t.Errorf("Code = %d, want %d", w.Code, test.wantCode)
+			}
+			if got := w.Body.String(); got != test.wantBody {
+				t.Errorf("Body = %q, want %q", got, test.wantBody)
+			}
+		})
+	}
+}
+
+func TestWithComponents(t *testing.T) {
+	ctx := context.Background()
+	if got := GetComponents(ctx); len(got) != 0 {
+		t.Errorf("GetComponents() = %d constructors, wanted none", len(got))
+	}
+
+	ctor := func(context.Context, configmap.Watcher) Component { return runOnly{} }
+	ctx = WithComponents(ctx, ctor)
+	first := WithComponents(ctx, ctor)
+	second := WithComponents(ctx, ctor, ctor)
+	if got := len(GetComponents(ctx)); got != 1 {
+		t.Errorf("GetComponents() = %d constructors, want 1", got)
+	}
+	if got := len(GetComponents(first)); got != 2 {
+		t.Errorf("GetComponents() = %d constructors, want 2", got)
+	}
+	if got := len(GetComponents(second)); got != 3 {
+		t.Errorf("GetComponents() = %d constructors, want 3", got)
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -18,6 +18,7 @@ package sharedmain
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -25,6 +26,9 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.opencensus.io/stats/view"
@@ -44,6 +48,29 @@ import (
 	"knative.dev/pkg/profiling"
 	"knative.dev/pkg/signals"
 	"knative.dev/pkg/system"
+	"knative.dev/pkg/version"
+)
+
+const (
+	// informersSyncPath is the path of the profiling server at which the
+	// progress of the informers' cache sync is served.
+	informersSyncPath = "/debug/informers"
+
+	// loggingHistoryPath is the path of the profiling server at which the
+	// history of the changes of the logging level is served.
+	loggingHistoryPath = "/debug/logging/history"
+
+	// versionPath is the path of the profiling server at which the build
+	// information of the binary is served, see version.GetBuildInfo.
+	versionPath = "/version"
+
+	// informersSyncReportInterval is how often the informers which are
+	// still syncing are logged at startup.
+	informersSyncReportInterval = 10 * time.Second
+
+	// defaultDrainPeriod is the default of --drain-period, the time the
+	// endpoints are given at shutdown to stop routing to the components.
+	defaultDrainPeriod = 10 * time.Second
 )
 
 // GetConfig returns a rest.Config to be used for kubernetes client creation.
@@ -99,9 +126,30 @@ func MainWithContext(ctx context.Context, component string, ctors ...injection.C
 	var (
 		masterURL  = flag.String("master", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
 		kubeconfig = flag.String("kubeconfig", "", "Path to a kubeconfig. Only required if out-of-cluster.")
+		namespaces = flag.String("namespaces", "", "Comma-separated list of namespaces to restrict the informers to. All namespaces are watched if empty.")
+		qps        = flag.Float64("kube-api-qps", 0, "The maximum QPS to the Kubernetes API server. Scales with the number of controllers if zero.")
+		burst      = flag.Int("kube-api-burst", 0, "The maximum burst to the Kubernetes API server. Scales with the number of controllers if zero.")
+		resync     = flag.Duration("resync-period", 0, "The period in which informers resync. Defaults to controller.DefaultResyncPeriod if zero.")
+		drain      = flag.Duration("drain-period", defaultDrainPeriod, "The time waited for after a termination signal between failing the readiness probes and stopping the components.")
 	)
 	flag.Parse()
 
+	if *resync != 0 {
+		ctx = controller.WithResyncPeriod(ctx, *resync)
+	}
+	signals.SetDrainDelay(*drain)
+
+	if *qps != 0 || *burst != 0 {
+		opts := injection.GetClientOptions(ctx)
+		opts.QPS = float32(*qps)
+		opts.Burst = *burst
+		ctx = injection.WithClientOptions(ctx, opts)
+	}
+
+	if ns := parseNamespaces(*namespaces); len(ns) > 0 {
+		ctx = injection.WithNamespaceScopes(ctx, ns...)
+	}
+
 	cfg, err := GetConfig(*masterURL, *kubeconfig)
 	if err != nil {
 		log.Fatal("Error building kubeconfig", err)
@@ -109,6 +157,18 @@ func MainWithContext(ctx context.Context, component string, ctors ...injection.C
 	MainWithConfig(ctx, component, cfg, ctors...)
 }
 
+// parseNamespaces parses the comma-separated list of namespaces, skipping the
+// empty entries, e.g. "ns1, ns2," is ns1 and ns2.
+func parseNamespaces(list string) []string {
+	var namespaces []string
+	for _, ns := range strings.Split(list, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
+
 func MainWithConfig(ctx context.Context, component string, cfg *rest.Config, ctors ...injection.ControllerConstructor) {
 	log.Printf("Registering %d clients", len(injection.Default.GetClients()))
 	log.Printf("Registering %d informer factories", len(injection.Default.GetInformerFactories()))
@@ -123,11 +183,29 @@ func MainWithConfig(ctx context.Context, component string, cfg *rest.Config, cto
 		log.Fatalf("Error exporting go memstats view: %v", err)
 	}
 
-	// Adjust our client's rate limits based on the number of controller's we are running.
-	cfg.QPS = float32(len(ctors)) * rest.DefaultQPS
-	cfg.Burst = len(ctors) * rest.DefaultBurst
+	// Adjust our client's rate limits based on the number of controller's we are running,
+	// unless they have been configured explicitly.
+	scopes := len(injection.GetNamespaceScopes(ctx))
+	if scopes == 0 {
+		scopes = 1
+	}
+	clientOpts := injection.GetClientOptions(ctx)
+	if clientOpts.UserAgent == "" {
+		clientOpts.UserAgent = injection.UserAgent(component)
+	}
+	if clientOpts.QPS == 0 {
+		clientOpts.QPS = float32(scopes*len(ctors)) * rest.DefaultQPS
+	}
+	if clientOpts.Burst == 0 {
+		clientOpts.Burst = scopes * len(ctors) * rest.DefaultBurst
+	}
+	ctx = injection.WithClientOptions(ctx, clientOpts)
 
-	ctx, informers := injection.Default.SetupInformers(ctx, cfg)
+	// Only start the informers which the controllers actually use. If the
+	// process is restricted to a list of namespaces, the informers and
+	// controllers are set up once for each of them.
+	ctxs, informers := injection.SetupNamespacedInformers(injection.WithLazyInformers(ctx), injection.Default, cfg)
+	ctx = ctxs[0]
 
 	// Set up our logger.
 	loggingConfig, err := GetLoggingConfig(ctx)
@@ -135,16 +213,30 @@ func MainWithConfig(ctx context.Context, component string, cfg *rest.Config, cto
 		log.Fatal("Error reading/parsing logging configuration:", err)
 	}
 	logger, atomicLevel := logging.NewLoggerFromConfig(loggingConfig, component)
-	defer flush(logger)
 	ctx = logging.WithLogger(ctx, logger)
+	logger.Infof("Running %s", version.GetBuildInfo())
 
 	// TODO(mattmoor): This should itself take a context and be injection-based.
 	cmw := configmap.NewInformedWatcher(kubeclient.Get(ctx), system.Namespace())
 
 	// Based on the reconcilers we have linked, build up the set of controllers to run.
-	controllers := make([]*controller.Impl, 0, len(ctors))
-	for _, cf := range ctors {
-		controllers = append(controllers, cf(ctx, cmw))
+	controllers := make([]*controller.Impl, 0, len(ctxs)*len(ctors))
+	for _, nsCtx := range ctxs {
+		nsLogger := logger
+		if injection.HasNamespaceScope(nsCtx) {
+			nsLogger = logger.With(zap.String("scope", injection.GetNamespaceScope(nsCtx)))
+		}
+		nsCtx = logging.WithLogger(nsCtx, nsLogger)
+		for _, cf := range ctors {
+			controllers = append(controllers, cf(nsCtx, cmw))
+		}
+	}
+
+	// The components, e.g. webhooks, aren't namespaced.
+	componentCtors := GetComponents(ctx)
+	components := make([]Component, 0, len(componentCtors))
+	for _, cf := range componentCtors {
+		components = append(components, cf(ctx, cmw))
 	}
 
 	profilingHandler := profiling.NewHandler(logger, false)
@@ -161,25 +253,89 @@ func MainWithConfig(ctx context.Context, component string, cfg *rest.Config, cto
 		logger.Fatalw("failed to start configuration manager", zap.Error(err))
 	}
 
+	// The health of the components, the controllers and the informers is
+	// aggregated into the probe endpoints.
+	syncProgress := controller.NewSyncProgress(informers...)
+	health := &Health{}
+	health.AddReadinessCheck("informers", informersReady(syncProgress))
+	var controllersStarted int32
+	health.AddReadinessCheck("controllers", func() error {
+		if atomic.LoadInt32(&controllersStarted) == 0 {
+			return errors.New("not started")
+		}
+		return nil
+	})
+	for i, c := range components {
+		health.AddComponent(fmt.Sprintf("%T[%d]", c, i), c)
+	}
+	// On a termination signal the readiness probes fail before the drain
+	// delay, see signals.SetDrainDelay, so that the endpoints stop routing
+	// to the components before the context is done and they are stopped.
+	signals.RegisterShutdownHook("readiness", signals.PriorityStopAccepting, 0, func(context.Context) error {
+		health.ShutDown()
+		return nil
+	})
+
+	// The progress of the informers' sync and the probes are served next to
+	// the profiling endpoints, so start the server before waiting for them.
+	mux := http.NewServeMux()
+	mux.Handle(informersSyncPath, syncProgress)
+	mux.Handle(loggingHistoryPath, logging.DefaultHistory)
+	mux.Handle(versionPath, version.BuildInfoHandler(version.DefaultModules...))
+	mux.Handle(livenessPath, health.LivenessHandler())
+	mux.Handle(readinessPath, health.ReadinessHandler())
+	mux.Handle("/", profilingHandler)
+	profilingServer := profiling.NewServer(mux)
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.Go(profilingServer.ListenAndServe)
+
 	// Start all of the informers and wait for them to sync.
 	logger.Info("Starting informers.")
-	if err := controller.StartInformers(ctx.Done(), informers...); err != nil {
-		logger.Fatalw("Failed to start informers", err)
+	if err := syncProgress.Start(ctx.Done(), informersSyncReportInterval, reportSyncProgress(logger)); err != nil {
+		logger.Fatalw("Failed to start informers", zap.Error(err))
+	}
+
+	// The components and the controllers are stopped in order at shutdown,
+	// rather than all at once when the context is done.
+	componentsStopCh := make(chan struct{})
+	var componentsWg sync.WaitGroup
+	for _, c := range components {
+		c := c
+		componentsWg.Add(1)
+		eg.Go(func() error {
+			defer componentsWg.Done()
+			return c.Run(componentsStopCh)
+		})
 	}
 
 	// Start all of the controllers.
 	logger.Info("Starting controllers...")
-	go controller.StartAll(ctx.Done(), controllers...)
-
-	profilingServer := profiling.NewServer(profilingHandler)
-
-	eg, egCtx := errgroup.WithContext(ctx)
-	eg.Go(profilingServer.ListenAndServe)
+	controllersStopCh := make(chan struct{})
+	controllersDone := make(chan struct{})
+	go func() {
+		defer close(controllersDone)
+		controller.StartAll(controllersStopCh, controllers...)
+	}()
+	atomic.StoreInt32(&controllersStarted, 1)
 
 	// This will block until either a signal arrives or one of the grouped functions
 	// returns an error.
 	<-egCtx.Done()
 
+	// Fail the readiness probes first, unless the shutdown hook did already,
+	// then stop the components, e.g. the webhooks, so that no new work comes
+	// in while the controllers drain their queues, and finally flush the logs
+	// and metrics.
+	logger.Info("Shutting down.")
+	health.ShutDown()
+	close(componentsStopCh)
+	componentsWg.Wait()
+	logger.Info("Draining controllers.")
+	close(controllersStopCh)
+	<-controllersDone
+	flush(logger)
+
 	profilingServer.Shutdown(context.Background())
 	// Don't forward ErrServerClosed as that indicates we're already shutting down.
 	if err := eg.Wait(); err != nil && err != http.ErrServerClosed {
@@ -187,6 +343,39 @@ func MainWithConfig(ctx context.Context, component string, cfg *rest.Config, cto
 	}
 }
 
+// informersReady returns a Check failing until all of the informers synced.
+func informersReady(p *controller.SyncProgress) Check {
+	return func() error {
+		pending := controller.Pending(p.Status())
+		if len(pending) == 0 {
+			return nil
+		}
+		names := make([]string, len(pending))
+		for i, s := range pending {
+			names[i] = s.Name
+		}
+		return fmt.Errorf("still syncing: %v", names)
+	}
+}
+
+// reportSyncProgress returns a controller.SyncProgressFunc logging which
+// informers are still syncing, and for how long.
+func reportSyncProgress(logger *zap.SugaredLogger) controller.SyncProgressFunc {
+	return func(statuses []controller.InformerSyncStatus) {
+		pending := controller.Pending(statuses)
+		if len(pending) == 0 {
+			logger.Infof("All %d informers synced.", len(statuses))
+			return
+		}
+		syncing := make([]string, len(pending))
+		for i, s := range pending {
+			syncing[i] = fmt.Sprintf("%s (%v)", s.Name, s.Duration.Round(time.Second))
+		}
+		logger.Infow(fmt.Sprintf("Waiting for %d of %d informers to sync.", len(pending), len(statuses)),
+			zap.Strings("informers", syncing))
+	}
+}
+
 func flush(logger *zap.SugaredLogger) {
 	logger.Sync()
 	metrics.FlushExporter()
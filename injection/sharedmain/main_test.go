@@ -0,0 +1,52 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sharedmain
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseNamespaces(t *testing.T) {
+	tests := []struct {
+		name string
+		list string
+		want []string
+	}{{
+		name: "empty",
+	}, {
+		name: "blank entries",
+		list: " , ,",
+	}, {
+		name: "single",
+		list: "foo",
+		want: []string{"foo"},
+	}, {
+		name: "spaces and empty entries",
+		list: " foo,, bar ,",
+		want: []string{"foo", "bar"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.want, parseNamespaces(test.list)); diff != "" {
+				t.Errorf("parseNamespaces (-want, +got) = %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kmeta
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// AppLabelKey is the label holding the name of the application a
+	// resource belongs to.
+	AppLabelKey = "app.kubernetes.io/name"
+
+	// ComponentLabelKey is the label holding the component of the
+	// application a resource is part of.
+	ComponentLabelKey = "app.kubernetes.io/component"
+
+	// OwnerUIDHashLabelKey is the label holding the hash of the UID of the
+	// parent of a resource, see OwnerUIDHash.
+	OwnerUIDHashLabelKey = "knative.dev/owner-uid-hash"
+
+	// ownerUIDHashLen is the number of hexadecimal digits of OwnerUIDHash.
+	ownerUIDHashLen = 16
+)
+
+// OwnerUIDHash returns the hash of the UID of the owner, which is the value of
+// its children's OwnerUIDHashLabelKey label. It doesn't change when the owner
+// is updated, and differs from the one of an owner recreated with the same
+// name, whose UID differs.
+func OwnerUIDHash(owner metav1.Object) string {
+	h := sha256.Sum256([]byte(owner.GetUID()))
+	return fmt.Sprintf("%x", h)[:ownerUIDHashLen]
+}
+
+// LabelBuilder builds the standard set of labels of the resources created by a
+// controller, along with the selectors matching them, so that the children
+// are labeled the same way across controllers. For example:
+//
+//	ls, err := kmeta.NewLabelBuilder().
+//		App("serving").
+//		Component("activator").
+//		Owner(revision).
+//		Build()
+//
+// The same calls always build the same labels. Invalid keys and values are
+// reported by Build and Selector, instead of being rejected by the API server.
+type LabelBuilder struct {
+	labels labels.Set
+	errs   map[string][]string
+}
+
+// NewLabelBuilder returns an empty LabelBuilder.
+func NewLabelBuilder() *LabelBuilder {
+	return &LabelBuilder{
+		labels: labels.Set{},
+		errs:   map[string][]string{},
+	}
+}
+
+// App sets the AppLabelKey label.
+func (b *LabelBuilder) App(name string) *LabelBuilder {
+	return b.With(AppLabelKey, name)
+}
+
+// Component sets the ComponentLabelKey label.
+func (b *LabelBuilder) Component(component string) *LabelBuilder {
+	return b.With(ComponentLabelKey, component)
+}
+
+// Owner sets the OwnerUIDHashLabelKey label of the children of the owner.
+func (b *LabelBuilder) Owner(owner metav1.Object) *LabelBuilder {
+	if owner.GetUID() == "" {
+		b.errs[OwnerUIDHashLabelKey] = append(b.errs[OwnerUIDHashLabelKey], "the owner has no UID")
+		return b
+	}
+	return b.With(OwnerUIDHashLabelKey, OwnerUIDHash(owner))
+}
+
+// With sets the label of the given key, overriding its previous value.
+func (b *LabelBuilder) With(key, value string) *LabelBuilder {
+	if msgs := validation.IsQualifiedName(key); len(msgs) > 0 {
+		b.errs[key] = append(b.errs[key], msgs...)
+		return b
+	}
+	delete(b.errs, key)
+	if msgs := validation.IsValidLabelValue(value); len(msgs) > 0 {
+		for _, msg := range msgs {
+			b.errs[key] = append(b.errs[key], fmt.Sprintf("invalid value %q: %s", value, msg))
+		}
+		return b
+	}
+	b.labels[key] = value
+	return b
+}
+
+// Build returns a copy of the labels, or an error listing the invalid ones.
+func (b *LabelBuilder) Build() (labels.Set, error) {
+	if err := b.err(); err != nil {
+		return nil, err
+	}
+	ls := make(labels.Set, len(b.labels))
+	for k, v := range b.labels {
+		ls[k] = v
+	}
+	return ls, nil
+}
+
+// Selector returns the selector matching the labels, or an error listing the
+// invalid ones.
+func (b *LabelBuilder) Selector() (labels.Selector, error) {
+	ls, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	return labels.SelectorFromSet(ls), nil
+}
+
+// LabelSelector is like Selector, returning the selector as an API field,
+// e.g. of the spec of a Deployment.
+func (b *LabelBuilder) LabelSelector() (*metav1.LabelSelector, error) {
+	ls, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	return metav1.SetAsLabelSelector(ls), nil
+}
+
+func (b *LabelBuilder) err() error {
+	var msgs []string
+	for k, v := range b.errs {
+		for _, msg := range v {
+			msgs = append(msgs, fmt.Sprintf("%s: %s", k, msg))
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	sort.Strings(msgs)
+	return fmt.Errorf("invalid labels:\n%s", strings.Join(msgs, "\n"))
+}
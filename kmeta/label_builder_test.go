@@ -0,0 +1,144 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kmeta
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestOwnerUIDHash(t *testing.T) {
+	owner := &metav1.ObjectMeta{Name: "parent", UID: "6a7b5e8c-1d0f-4f5b-9a6e-2c3d4e5f6a7b"}
+	got := OwnerUIDHash(owner)
+	if len(got) != ownerUIDHashLen {
+		t.Errorf("len(OwnerUIDHash()) = %d, want: %d", len(got), ownerUIDHashLen)
+	}
+	if again := OwnerUIDHash(owner.DeepCopy()); again != got {
+		t.Errorf("OwnerUIDHash() = %q, then %q, wanted the same", got, again)
+	}
+	recreated := &metav1.ObjectMeta{Name: "parent", UID: "0f1e2d3c-4b5a-4968-8776-655443322110"}
+	if other := OwnerUIDHash(recreated); other == got {
+		t.Errorf("OwnerUIDHash() = %q for both owners, wanted different ones", got)
+	}
+}
+
+func TestLabelBuilder(t *testing.T) {
+	owner := &metav1.ObjectMeta{Name: "parent", UID: "6a7b5e8c-1d0f-4f5b-9a6e-2c3d4e5f6a7b"}
+	tests := []struct {
+		name    string
+		build   func(*LabelBuilder) *LabelBuilder
+		want    labels.Set
+		wantErr string
+	}{{
+		name:  "empty",
+		build: func(b *LabelBuilder) *LabelBuilder { return b },
+		want:  labels.Set{},
+	}, {
+		name: "standard labels",
+		build: func(b *LabelBuilder) *LabelBuilder {
+			return b.App("serving").Component("activator").Owner(owner)
+		},
+		want: labels.Set{
+			AppLabelKey:          "serving",
+			ComponentLabelKey:    "activator",
+			OwnerUIDHashLabelKey: OwnerUIDHash(owner),
+		},
+	}, {
+		name: "custom labels override",
+		build: func(b *LabelBuilder) *LabelBuilder {
+			return b.App("serving").With("example.com/tier", "frontend").App("eventing")
+		},
+		want: labels.Set{
+			AppLabelKey:        "eventing",
+			"example.com/tier": "frontend",
+		},
+	}, {
+		name: "invalid value",
+		build: func(b *LabelBuilder) *LabelBuilder {
+			return b.App("not a valid value").Component(strings.Repeat("a", 64))
+		},
+		wantErr: `app.kubernetes.io/component: invalid value "` + strings.Repeat("a", 64) + `": must be no more than 63 characters`,
+	}, {
+		name: "invalid key",
+		build: func(b *LabelBuilder) *LabelBuilder {
+			return b.With("not/a/key", "value")
+		},
+		wantErr: "not/a/key: a qualified name must consist of",
+	}, {
+		name: "owner without UID",
+		build: func(b *LabelBuilder) *LabelBuilder {
+			return b.Owner(&metav1.ObjectMeta{Name: "parent"})
+		},
+		wantErr: OwnerUIDHashLabelKey + ": the owner has no UID",
+	}, {
+		name: "invalid value overridden",
+		build: func(b *LabelBuilder) *LabelBuilder {
+			return b.App("not valid").App("valid")
+		},
+		want: labels.Set{AppLabelKey: "valid"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := test.build(NewLabelBuilder())
+			got, err := b.Build()
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("Build() = %v, wanted an error containing %q", err, test.wantErr)
+				}
+				if _, err := b.Selector(); err == nil {
+					t.Error("Selector() = nil, wanted an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Build() = %v", err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Build() (-want, +got): %s", diff)
+			}
+
+			selector, err := b.Selector()
+			if err != nil {
+				t.Fatalf("Selector() = %v", err)
+			}
+			if !selector.Matches(got) {
+				t.Errorf("Selector() = %v, wanted it to match %v", selector, got)
+			}
+			ls, err := b.LabelSelector()
+			if err != nil {
+				t.Fatalf("LabelSelector() = %v", err)
+			}
+			if diff := cmp.Diff(map[string]string(test.want), ls.MatchLabels); len(test.want) > 0 && diff != "" {
+				t.Errorf("LabelSelector().MatchLabels (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestLabelBuilderBuildCopies(t *testing.T) {
+	b := NewLabelBuilder().App("serving")
+	ls, _ := b.Build()
+	ls[AppLabelKey] = "changed"
+	if got, _ := b.Build(); got[AppLabelKey] != "serving" {
+		t.Errorf("Build()[%s] = %q, wanted the builder to be unchanged", AppLabelKey, got[AppLabelKey])
+	}
+}
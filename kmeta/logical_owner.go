@@ -0,0 +1,96 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kmeta
+
+import (
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// LogicalOwnerAnnotation is the annotation of the resources a controller
+// creates for a parent in another namespace, which an OwnerReference can't
+// point to, e.g. in the namespaces of the users. Since the garbage collector
+// of Kubernetes doesn't know about them, they're collected by the controllers,
+// see knative.dev/pkg/reconciler/gc.
+const LogicalOwnerAnnotation = "knative.dev/logical-owner"
+
+// LogicalOwner identifies the parent of a resource in another namespace.
+type LogicalOwner struct {
+	APIVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	Namespace  string    `json:"namespace,omitempty"`
+	Name       string    `json:"name"`
+	UID        types.UID `json:"uid"`
+}
+
+// NewLogicalOwner returns the LogicalOwner of the children of the given parent.
+func NewLogicalOwner(obj OwnerRefable) LogicalOwner {
+	om := obj.GetObjectMeta()
+	apiVersion, kind := obj.GetGroupVersionKind().ToAPIVersionAndKind()
+	return LogicalOwner{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Namespace:  om.GetNamespace(),
+		Name:       om.GetName(),
+		UID:        om.GetUID(),
+	}
+}
+
+// String returns a human readable reference to the owner.
+func (o LogicalOwner) String() string {
+	return fmt.Sprintf("%s %s/%s (%s)", o.Kind, o.Namespace, o.Name, o.UID)
+}
+
+// SetLogicalOwner annotates the child with its parent, which can be in another
+// namespace.
+func SetLogicalOwner(child metav1.Object, parent OwnerRefable) {
+	b, err := json.Marshal(NewLogicalOwner(parent))
+	if err != nil {
+		// The LogicalOwner only has strings.
+		panic(fmt.Sprintf("failed to encode the logical owner: %v", err))
+	}
+	annotations := child.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string, 1)
+	}
+	annotations[LogicalOwnerAnnotation] = string(b)
+	child.SetAnnotations(annotations)
+}
+
+// GetLogicalOwner returns the parent the child is annotated with by
+// SetLogicalOwner, or nil if it has none.
+func GetLogicalOwner(child metav1.Object) (*LogicalOwner, error) {
+	value, ok := child.GetAnnotations()[LogicalOwnerAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	owner := &LogicalOwner{}
+	if err := json.Unmarshal([]byte(value), owner); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation %q: %v", LogicalOwnerAnnotation, value, err)
+	}
+	return owner, nil
+}
+
+// IsLogicallyOwnedBy returns true if the child is annotated with the given
+// parent, compared by UID.
+func IsLogicallyOwnedBy(child metav1.Object, parent metav1.Object) bool {
+	owner, err := GetLogicalOwner(child)
+	return err == nil && owner != nil && owner.UID == parent.GetUID()
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kmeta
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLogicalOwner(t *testing.T) {
+	parent := &Frobber{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "knative-system",
+			Name:      "foo",
+			UID:       "42",
+		},
+	}
+	child := &metav1.ObjectMeta{
+		Namespace:   "user",
+		Name:        "bar",
+		Annotations: map[string]string{"other": "annotation"},
+	}
+
+	if owner, err := GetLogicalOwner(child); owner != nil || err != nil {
+		t.Errorf("GetLogicalOwner() = %v, %v, wanted none", owner, err)
+	}
+	if IsLogicallyOwnedBy(child, parent) {
+		t.Error("IsLogicallyOwnedBy() = true before SetLogicalOwner")
+	}
+
+	SetLogicalOwner(child, parent)
+	want := &LogicalOwner{
+		APIVersion: "example.knative.dev/v1alpha1",
+		Kind:       "Frobber",
+		Namespace:  "knative-system",
+		Name:       "foo",
+		UID:        "42",
+	}
+	got, err := GetLogicalOwner(child)
+	if err != nil {
+		t.Fatalf("GetLogicalOwner() = %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetLogicalOwner() (-want, +got): %s", diff)
+	}
+	if got, want := got.String(), "Frobber knative-system/foo (42)"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if child.Annotations["other"] != "annotation" {
+		t.Errorf("Annotations = %v, wanted the other annotations kept", child.Annotations)
+	}
+	if !IsLogicallyOwnedBy(child, parent) {
+		t.Error("IsLogicallyOwnedBy() = false after SetLogicalOwner")
+	}
+	if IsLogicallyOwnedBy(child, &metav1.ObjectMeta{Namespace: "knative-system", Name: "foo", UID: "43"}) {
+		t.Error("IsLogicallyOwnedBy() = true for a recreated parent")
+	}
+
+	// The annotations are created if needed.
+	bare := &metav1.ObjectMeta{Name: "baz"}
+	SetLogicalOwner(bare, parent)
+	if !IsLogicallyOwnedBy(bare, parent) {
+		t.Error("IsLogicallyOwnedBy() = false after SetLogicalOwner without annotations")
+	}
+
+	child.Annotations[LogicalOwnerAnnotation] = "not json"
+	if _, err := GetLogicalOwner(child); err == nil {
+		t.Error("GetLogicalOwner() = nil, wanted an error for an invalid annotation")
+	}
+}
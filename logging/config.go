@@ -75,7 +75,16 @@ func enrichLoggerWithCommitID(logger *zap.SugaredLogger) *zap.SugaredLogger {
 // NewLoggerFromConfig creates a logger using the provided Config
 func NewLoggerFromConfig(config *Config, name string, opts ...zap.Option) (*zap.SugaredLogger, zap.AtomicLevel) {
 	logger, level := NewLogger(config.LoggingConfig, config.LoggingLevel[name].String(), opts...)
-	return logger.Named(name), level
+	fingerprint := config.Fingerprint()
+	logger = logger.Named(name)
+	logger.Infow("Applied the logging configuration.", zap.String(logkey.ConfigFingerprint, fingerprint))
+	DefaultHistory.Record(LevelChange{
+		Component:   name,
+		NewLevel:    level.Level().String(),
+		Source:      "startup",
+		Fingerprint: fingerprint,
+	})
+	return logger, level
 }
 
 func newLoggerFromConfig(configJSON string, levelOverride string, opts []zap.Option) (*zap.Logger, zap.AtomicLevel, error) {
@@ -172,7 +181,7 @@ func levelFromString(level string) (*zapcore.Level, error) {
 }
 
 // UpdateLevelFromConfigMap returns a helper func that can be used to update the logging level
-// when a config map is updated
+// when a config map is updated. The changes are recorded in DefaultHistory.
 func UpdateLevelFromConfigMap(logger *zap.SugaredLogger, atomicLevel zap.AtomicLevel,
 	levelKey string) func(configMap *corev1.ConfigMap) {
 	return func(configMap *corev1.ConfigMap) {
@@ -184,7 +193,16 @@ func UpdateLevelFromConfigMap(logger *zap.SugaredLogger, atomicLevel zap.AtomicL
 
 		level := loggingConfig.LoggingLevel[levelKey]
 		if atomicLevel.Level() != level {
-			logger.Infof("Updating logging level for %v from %v to %v.", levelKey, atomicLevel.Level(), level)
+			change := LevelChange{
+				Component:   levelKey,
+				OldLevel:    atomicLevel.Level().String(),
+				NewLevel:    level.String(),
+				Source:      configMapSource(configMap),
+				Fingerprint: loggingConfig.Fingerprint(),
+			}
+			logger.With(zap.String(logkey.ConfigFingerprint, change.Fingerprint)).Infof(
+				"Updating logging level for %v from %v to %v, from %v.", levelKey, change.OldLevel, change.NewLevel, change.Source)
+			DefaultHistory.Record(change)
 			atomicLevel.SetLevel(level)
 		}
 	}
@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -157,10 +158,20 @@ func TestNewConfig(t *testing.T) {
 
 func TestNewLoggerFromConfig(t *testing.T) {
 	c, _, _ := getTestConfig()
+	DefaultHistory = NewHistory(defaultHistorySize)
 	_, atomicLevel := NewLoggerFromConfig(c, "queueproxy")
 	if atomicLevel.Level() != zapcore.DebugLevel {
 		t.Errorf("logger level wanted: DebugLevel, got: %v", atomicLevel)
 	}
+	want := []LevelChange{{
+		Component:   "queueproxy",
+		NewLevel:    "debug",
+		Source:      "startup",
+		Fingerprint: c.Fingerprint(),
+	}}
+	if diff := cmp.Diff(want, DefaultHistory.Changes(), cmpopts.IgnoreFields(LevelChange{}, "Time")); diff != "" {
+		t.Errorf("Recorded changes (-want, +got): %s", diff)
+	}
 }
 
 func TestEmptyLevel(t *testing.T) {
@@ -281,6 +292,7 @@ func TestUpdateLevelFromConfigMap(t *testing.T) {
 		{"debug", zapcore.DebugLevel},
 	}
 
+	DefaultHistory = NewHistory(defaultHistorySize)
 	u := UpdateLevelFromConfigMap(logger, atomicLevel, "controller")
 	for _, tt := range tests {
 		cm.Data["loglevel.controller"] = tt.setLevel
@@ -289,6 +301,19 @@ func TestUpdateLevelFromConfigMap(t *testing.T) {
 			t.Errorf("Invalid logging level. want: %v, got: %v", tt.wantLevel, atomicLevel.Level())
 		}
 	}
+
+	// Only the actual changes are recorded.
+	var got []string
+	for _, c := range DefaultHistory.Changes() {
+		if c.Component != "controller" || c.Source != "configmap knative-something/config-logging@" {
+			t.Errorf("Unexpected change recorded: %+v", c)
+		}
+		got = append(got, c.OldLevel+"->"+c.NewLevel)
+	}
+	wantChanges := []string{"debug->info", "info->error", "error->debug"}
+	if diff := cmp.Diff(wantChanges, got); diff != "" {
+		t.Errorf("Recorded changes (-want, +got): %s", diff)
+	}
 }
 
 func TestLoggingConfig(t *testing.T) {
@@ -0,0 +1,69 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"strconv"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DebugAnnotation is the annotation which, set to "true" on a resource,
+// elevates the verbosity of the logs of its reconciliations to the debug
+// level, regardless of the configured level.
+const DebugAnnotation = "logging.knative.dev/debug"
+
+// IsDebugEnabled returns whether the object has DebugAnnotation set to true.
+func IsDebugEnabled(obj metav1.Object) bool {
+	enabled, _ := strconv.ParseBool(obj.GetAnnotations()[DebugAnnotation])
+	return enabled
+}
+
+// WithDebugLevel returns a logger which logs at the debug level and above,
+// even when the level of the given logger is higher.
+func WithDebugLevel(logger *zap.SugaredLogger) *zap.SugaredLogger {
+	return logger.Desugar().WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &levelCore{Core: core, level: zapcore.DebugLevel}
+	})).Sugar()
+}
+
+// levelCore is a zapcore.Core which overrides the level of the core it wraps.
+type levelCore struct {
+	zapcore.Core
+	level zapcore.Level
+}
+
+// Enabled implements zapcore.LevelEnabler
+func (c *levelCore) Enabled(lvl zapcore.Level) bool {
+	return c.level.Enabled(lvl)
+}
+
+// With implements zapcore.Core
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), level: c.level}
+}
+
+// Check implements zapcore.Core
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	// Don't defer to the wrapped core, which checks its own level.
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsDebugEnabled(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        bool
+	}{{
+		name: "no annotations",
+	}, {
+		name:        "enabled",
+		annotations: map[string]string{DebugAnnotation: "true"},
+		want:        true,
+	}, {
+		name:        "disabled",
+		annotations: map[string]string{DebugAnnotation: "false"},
+	}, {
+		name:        "invalid",
+		annotations: map[string]string{DebugAnnotation: "yes please"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			obj := &metav1.ObjectMeta{Annotations: test.annotations}
+			if got := IsDebugEnabled(obj); got != test.want {
+				t.Errorf("IsDebugEnabled() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWithDebugLevel(t *testing.T) {
+	buf := &bytes.Buffer{}
+	encoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	logger := zap.New(zapcore.NewCore(encoder, zapcore.AddSync(buf), zapcore.InfoLevel)).Sugar()
+
+	logger.Debug("dropped")
+	debugLogger := WithDebugLevel(logger).With("key", "value")
+	debugLogger.Debug("kept")
+	debugLogger.Info("also kept")
+	// The original logger is left untouched.
+	logger.Debug("dropped too")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if got, want := len(lines), 2; got != want {
+		t.Fatalf("len(lines) = %d, want %d: %v", got, want, lines)
+	}
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("json.Unmarshal() = %v", err)
+	}
+	if got, want := entry["msg"], "kept"; got != want {
+		t.Errorf("msg = %v, want %v", got, want)
+	}
+	if got, want := entry["key"], "value"; got != want {
+		t.Errorf("key = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// defaultHistorySize is the number of changes kept by DefaultHistory.
+const defaultHistorySize = 100
+
+// DefaultHistory records the changes of the logging level applied by
+// NewLoggerFromConfig and UpdateLevelFromConfigMap.
+var DefaultHistory = NewHistory(defaultHistorySize)
+
+// LevelChange is a change of the logging level of a component.
+type LevelChange struct {
+	// Time is when the change was applied.
+	Time time.Time `json:"time"`
+	// Component is the component whose logging level changed.
+	Component string `json:"component"`
+	// OldLevel is the logging level before the change, empty at startup.
+	OldLevel string `json:"oldLevel,omitempty"`
+	// NewLevel is the logging level after the change.
+	NewLevel string `json:"newLevel"`
+	// Source is where the change came from, e.g. the ConfigMap with its
+	// resource version, and the manager which last updated it if known.
+	Source string `json:"source"`
+	// Fingerprint is the fingerprint of the logging configuration applied.
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// History keeps the most recent changes of the logging level in memory, so
+// that they can be served from a debug endpoint.
+type History struct {
+	mu      sync.RWMutex
+	size    int
+	changes []LevelChange
+}
+
+// NewHistory creates a History keeping at most the given number of changes.
+func NewHistory(size int) *History {
+	return &History{size: size}
+}
+
+// Record records a change, dropping the oldest one if the history is full.
+func (h *History) Record(change LevelChange) {
+	if change.Time.IsZero() {
+		change.Time = time.Now()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.changes = append(h.changes, change)
+	if over := len(h.changes) - h.size; over > 0 {
+		h.changes = append([]LevelChange(nil), h.changes[over:]...)
+	}
+}
+
+// Changes returns the changes recorded, oldest first.
+func (h *History) Changes() []LevelChange {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return append([]LevelChange(nil), h.changes...)
+}
+
+// ServeHTTP serves the changes recorded as a JSON array, oldest first.
+func (h *History) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	changes := h.Changes()
+	if changes == nil {
+		changes = []LevelChange{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(changes)
+}
+
+// Fingerprint returns a short digest of the configuration, which identifies
+// it in the logs without dumping it.
+func (c *Config) Fingerprint() string {
+	components := make([]string, 0, len(c.LoggingLevel))
+	for component := range c.LoggingLevel {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%q\n", c.LoggingConfig)
+	for _, component := range components {
+		fmt.Fprintf(hash, "%q=%v\n", component, c.LoggingLevel[component])
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil))[:16]
+}
+
+// configMapSource describes the ConfigMap a change came from, along with the
+// manager which last updated it, if its managed fields are tracked.
+func configMapSource(configMap *corev1.ConfigMap) string {
+	source := fmt.Sprintf("configmap %s/%s@%s", configMap.Namespace, configMap.Name, configMap.ResourceVersion)
+	var (
+		manager string
+		last    time.Time
+	)
+	for _, entry := range configMap.ManagedFields {
+		// The entries of server-side applies have no time, prefer the
+		// latest update.
+		if entry.Time == nil {
+			if last.IsZero() {
+				manager = entry.Manager
+			}
+		} else if !entry.Time.Time.Before(last) {
+			manager, last = entry.Manager, entry.Time.Time
+		}
+	}
+	if manager != "" {
+		source += " by " + manager
+	}
+	return source
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"go.uber.org/zap/zapcore"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestHistory(t *testing.T) {
+	h := NewHistory(2)
+	if got := h.Changes(); len(got) != 0 {
+		t.Errorf("Changes() = %v, wanted none", got)
+	}
+
+	now := time.Now()
+	h.Record(LevelChange{Time: now, Component: "a", NewLevel: "info"})
+	h.Record(LevelChange{Time: now, Component: "b", NewLevel: "info"})
+	h.Record(LevelChange{Component: "c", OldLevel: "info", NewLevel: "debug"})
+
+	got := h.Changes()
+	want := []LevelChange{
+		{Time: now, Component: "b", NewLevel: "info"},
+		{Component: "c", OldLevel: "info", NewLevel: "debug"},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(LevelChange{}, "Time")); diff != "" {
+		t.Errorf("Changes (-want, +got): %s", diff)
+	}
+	if got[1].Time.IsZero() {
+		t.Error("Time of the last change wasn't set")
+	}
+}
+
+func TestHistoryServeHTTP(t *testing.T) {
+	h := NewHistory(10)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if got := w.Body.String(); got != "[]\n" {
+		t.Errorf("Body = %q, wanted an empty array", got)
+	}
+
+	change := LevelChange{
+		Time:      time.Date(2019, 11, 5, 10, 0, 0, 0, time.UTC),
+		Component: "controller",
+		OldLevel:  "info",
+		NewLevel:  "debug",
+		Source:    "configmap knative-serving/config-logging@42 by kubectl",
+	}
+	h.Record(change)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if got, want := w.Header().Get("Content-Type"), "application/json"; got != want {
+		t.Errorf("Content-Type = %q, want %q", got, want)
+	}
+	var got []LevelChange
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal() = %v", err)
+	}
+	if diff := cmp.Diff([]LevelChange{change}, got); diff != "" {
+		t.Errorf("Served changes (-want, +got): %s", diff)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	c := &Config{
+		LoggingConfig: "{}",
+		LoggingLevel: map[string]zapcore.Level{
+			"controller": zapcore.InfoLevel,
+			"webhook":    zapcore.DebugLevel,
+		},
+	}
+	fingerprint := c.Fingerprint()
+	if len(fingerprint) != 16 {
+		t.Errorf("Fingerprint() = %q, wanted 16 characters", fingerprint)
+	}
+	for i := 0; i < 10; i++ {
+		if got := c.DeepCopy().Fingerprint(); got != fingerprint {
+			t.Fatalf("Fingerprint() = %q, wanted the same %q for the same config", got, fingerprint)
+		}
+	}
+
+	changed := c.DeepCopy()
+	changed.LoggingLevel["webhook"] = zapcore.InfoLevel
+	if got := changed.Fingerprint(); got == fingerprint {
+		t.Error("Fingerprint() didn't change with the levels")
+	}
+	changed = c.DeepCopy()
+	changed.LoggingConfig = `{"level": "info"}`
+	if got := changed.Fingerprint(); got == fingerprint {
+		t.Error("Fingerprint() didn't change with the logging config")
+	}
+}
+
+func TestConfigMapSource(t *testing.T) {
+	earlier := metav1.NewTime(time.Date(2019, 11, 5, 10, 0, 0, 0, time.UTC))
+	later := metav1.NewTime(earlier.Add(time.Hour))
+	tests := []struct {
+		name    string
+		managed []metav1.ManagedFieldsEntry
+		want    string
+	}{{
+		name: "no managed fields",
+		want: "configmap knative-serving/config-logging@42",
+	}, {
+		name: "latest update",
+		managed: []metav1.ManagedFieldsEntry{
+			{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationUpdate, Time: &later},
+			{Manager: "operator", Operation: metav1.ManagedFieldsOperationUpdate, Time: &earlier},
+		},
+		want: "configmap knative-serving/config-logging@42 by kubectl",
+	}, {
+		name: "apply only",
+		managed: []metav1.ManagedFieldsEntry{
+			{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationApply},
+		},
+		want: "configmap knative-serving/config-logging@42 by kubectl",
+	}, {
+		name: "update preferred over apply",
+		managed: []metav1.ManagedFieldsEntry{
+			{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationApply},
+			{Manager: "operator", Operation: metav1.ManagedFieldsOperationUpdate, Time: &earlier},
+		},
+		want: "configmap knative-serving/config-logging@42 by operator",
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:       "knative-serving",
+					Name:            "config-logging",
+					ResourceVersion: "42",
+					ManagedFields:   test.managed,
+				},
+			}
+			if got := configMapSource(cm); got != test.want {
+				t.Errorf("configMapSource() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
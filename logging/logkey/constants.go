@@ -59,6 +59,14 @@ const (
 	// KubernetesService is the key used to represent a Kubernetes service name in logs
 	KubernetesService = "knative.dev/k8sservice"
 
+	// ValidationBypass is the key used to represent the validation which was
+	// bypassed by a break-glass principal in the webhook's audit logs
+	ValidationBypass = "knative.dev/validationbypass"
+
+	// ConfigFingerprint is the key used to represent the fingerprint of the
+	// logging configuration in logs
+	ConfigFingerprint = "knative.dev/configfingerprint"
+
 	// GitHubCommitID is the key used to represent the GitHub Commit ID where the
 	// Knative component was built from in logs
 	GitHubCommitID = "commit"
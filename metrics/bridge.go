@@ -0,0 +1,172 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// Instrument records the measurements of a migrated view through another
+// metrics SDK, typically an OpenTelemetry instrument (a counter for Count
+// and Sum views, a histogram for Distribution views, a gauge for LastValue
+// views). Implementations must be safe for concurrent use.
+type Instrument interface {
+	// Record records a single measurement with the given attributes, which
+	// are the view's tags present on the recording context.
+	Record(ctx context.Context, value float64, attributes map[string]string)
+}
+
+// InstrumentFunc is an adapter to allow the use of ordinary functions
+// as Instruments.
+type InstrumentFunc func(ctx context.Context, value float64, attributes map[string]string)
+
+// Record implements Instrument
+func (f InstrumentFunc) Record(ctx context.Context, value float64, attributes map[string]string) {
+	f(ctx, value, attributes)
+}
+
+// migratedView is a view whose measurements are routed to an Instrument
+// instead of being aggregated by OpenCensus.
+type migratedView struct {
+	view       *view.View
+	instrument Instrument
+}
+
+var (
+	// migratedViews holds the migrated views, keyed by the name of their measure.
+	migratedViews   = map[string][]migratedView{}
+	migratedViewsMu sync.RWMutex
+)
+
+// MigrateView moves the given view from OpenCensus to the given Instrument:
+// the view is unregistered from OpenCensus, and the measurements of its
+// measure going through Record are forwarded to the Instrument, along with
+// the view's tags. The other views stay on OpenCensus, which allows migrating
+// one view at a time.
+// Note that only the tags on the recording context are forwarded, not the
+// ones passed with stats.WithTags.
+func MigrateView(v *view.View, instrument Instrument) error {
+	if v == nil || v.Measure == nil {
+		return fmt.Errorf("cannot migrate a view without a measure: %v", v)
+	}
+	if instrument == nil {
+		return fmt.Errorf("cannot migrate view %q without an instrument", viewName(v))
+	}
+
+	migratedViewsMu.Lock()
+	defer migratedViewsMu.Unlock()
+	for _, mvs := range migratedViews {
+		for _, mv := range mvs {
+			if viewName(mv.view) == viewName(v) {
+				return fmt.Errorf("view %q is already migrated", viewName(v))
+			}
+		}
+	}
+
+	// Don't export the view through both OpenCensus and the instrument.
+	if registered := view.Find(viewName(v)); registered != nil {
+		view.Unregister(registered)
+	}
+	measure := v.Measure.Name()
+	migratedViews[measure] = append(migratedViews[measure], migratedView{
+		view:       v,
+		instrument: instrument,
+	})
+	return nil
+}
+
+// UnmigrateView stops forwarding the measurements of the view with the given
+// name to its Instrument. The view is not registered with OpenCensus again.
+func UnmigrateView(name string) {
+	migratedViewsMu.Lock()
+	defer migratedViewsMu.Unlock()
+	for measure, mvs := range migratedViews {
+		for i, mv := range mvs {
+			if viewName(mv.view) != name {
+				continue
+			}
+			mvs = append(mvs[:i:i], mvs[i+1:]...)
+			if len(mvs) == 0 {
+				delete(migratedViews, measure)
+			} else {
+				migratedViews[measure] = mvs
+			}
+			return
+		}
+	}
+}
+
+// CheckMigratedViews returns an error listing the migrated views which are
+// registered with OpenCensus again, e.g. by a package registering its views
+// after they were migrated, and whose measurements are thus double-exported.
+// It's meant to be called once all the views have been registered and migrated.
+func CheckMigratedViews() error {
+	migratedViewsMu.RLock()
+	defer migratedViewsMu.RUnlock()
+	var names []string
+	for _, mvs := range migratedViews {
+		for _, mv := range mvs {
+			if view.Find(viewName(mv.view)) != nil {
+				names = append(names, viewName(mv.view))
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	return fmt.Errorf("views exported through both OpenCensus and OpenTelemetry: %s", strings.Join(names, ", "))
+}
+
+// recordMigrated forwards the measurement to the Instruments of the views of
+// its measure which were migrated.
+func recordMigrated(ctx context.Context, ms stats.Measurement) {
+	migratedViewsMu.RLock()
+	mvs := migratedViews[ms.Measure().Name()]
+	migratedViewsMu.RUnlock()
+	if len(mvs) == 0 {
+		return
+	}
+
+	tags := tag.FromContext(ctx)
+	for _, mv := range mvs {
+		attributes := make(map[string]string, len(mv.view.TagKeys))
+		for _, k := range mv.view.TagKeys {
+			if value, ok := tags.Value(k); ok {
+				attributes[k.Name()] = value
+			}
+		}
+		mv.instrument.Record(ctx, ms.Value(), attributes)
+	}
+}
+
+// viewName returns the name of the view, which defaults to the name of
+// its measure, like OpenCensus does.
+func viewName(v *view.View) string {
+	if v.Name != "" {
+		return v.Name
+	}
+	return v.Measure.Name()
+}
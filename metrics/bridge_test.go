@@ -0,0 +1,148 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	"knative.dev/pkg/metrics/metricstest"
+)
+
+type recorded struct {
+	value      float64
+	attributes map[string]string
+}
+
+// fakeInstrument records the measurements it's given.
+type fakeInstrument struct {
+	mu       sync.Mutex
+	recorded []recorded
+}
+
+func (f *fakeInstrument) Record(ctx context.Context, value float64, attributes map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.recorded = append(f.recorded, recorded{value: value, attributes: attributes})
+}
+
+func TestMigrateView(t *testing.T) {
+	setCurMetricsConfig(nil)
+	measure := stats.Int64("bridge_requests", "Number of requests", stats.UnitNone)
+	keyA := tag.MustNewKey("a")
+	keyB := tag.MustNewKey("b")
+	countView := &view.View{
+		Name:        "bridge_request_count",
+		Measure:     measure,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{keyA},
+	}
+	lastView := &view.View{
+		Name:        "bridge_request_last",
+		Measure:     measure,
+		Aggregation: view.LastValue(),
+	}
+	if err := view.Register(countView, lastView); err != nil {
+		t.Fatalf("view.Register() = %v", err)
+	}
+	defer view.Unregister(countView, lastView)
+
+	instrument := &fakeInstrument{}
+	if err := MigrateView(countView, instrument); err != nil {
+		t.Fatalf("MigrateView() = %v", err)
+	}
+	defer UnmigrateView(countView.Name)
+
+	if v := view.Find(countView.Name); v != nil {
+		t.Errorf("view.Find(%q) = %v, wanted the migrated view unregistered", countView.Name, v)
+	}
+
+	ctx, err := tag.New(context.Background(), tag.Insert(keyA, "1"), tag.Insert(keyB, "2"))
+	if err != nil {
+		t.Fatalf("tag.New() = %v", err)
+	}
+	Record(ctx, measure.M(3))
+
+	want := []recorded{{value: 3, attributes: map[string]string{"a": "1"}}}
+	if diff := cmp.Diff(want, instrument.recorded, cmp.AllowUnexported(recorded{})); diff != "" {
+		t.Errorf("Recorded (-want, +got): %s", diff)
+	}
+	// The views which weren't migrated stay on OpenCensus.
+	metricstest.CheckLastValueData(t, lastView.Name, map[string]string{}, 3)
+
+	UnmigrateView(countView.Name)
+	Record(ctx, measure.M(4))
+	if got := len(instrument.recorded); got != 1 {
+		t.Errorf("len(recorded) = %d after UnmigrateView, wanted 1", got)
+	}
+}
+
+func TestMigrateViewErrors(t *testing.T) {
+	measure := stats.Int64("bridge_errors", "Number of errors", stats.UnitNone)
+	v := &view.View{Measure: measure, Aggregation: view.Count()}
+	instrument := &fakeInstrument{}
+
+	if err := MigrateView(nil, instrument); err == nil {
+		t.Error("MigrateView(nil) = nil, wanted an error")
+	}
+	if err := MigrateView(&view.View{Name: "no-measure"}, instrument); err == nil {
+		t.Error("MigrateView(no measure) = nil, wanted an error")
+	}
+	if err := MigrateView(v, nil); err == nil {
+		t.Error("MigrateView(nil instrument) = nil, wanted an error")
+	}
+
+	if err := MigrateView(v, instrument); err != nil {
+		t.Fatalf("MigrateView() = %v", err)
+	}
+	defer UnmigrateView(measure.Name())
+	// The view's name defaults to its measure's.
+	if err := MigrateView(v.WithName(measure.Name()), InstrumentFunc(instrument.Record)); err == nil {
+		t.Error("MigrateView(already migrated) = nil, wanted an error")
+	}
+}
+
+func TestCheckMigratedViews(t *testing.T) {
+	measure := stats.Float64("bridge_latency", "Latency", stats.UnitMilliseconds)
+	v := &view.View{
+		Name:        "bridge_latency_last",
+		Measure:     measure,
+		Aggregation: view.LastValue(),
+	}
+	if err := MigrateView(v, &fakeInstrument{}); err != nil {
+		t.Fatalf("MigrateView() = %v", err)
+	}
+	defer UnmigrateView(v.Name)
+	if err := CheckMigratedViews(); err != nil {
+		t.Errorf("CheckMigratedViews() = %v", err)
+	}
+
+	// Registering a migrated view again double-exports it.
+	if err := view.Register(v); err != nil {
+		t.Fatalf("view.Register() = %v", err)
+	}
+	defer view.Unregister(v)
+	if err := CheckMigratedViews(); err == nil {
+		t.Error("CheckMigratedViews() = nil, wanted an error")
+	}
+}
@@ -22,12 +22,14 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 const (
@@ -47,6 +49,14 @@ const (
 	ReportingPeriodKey                  = "metrics.reporting-period-seconds"
 	StackdriverProjectIDKey             = "metrics.stackdriver-project-id"
 	StackdriverCustomMetricSubDomainKey = "metrics.stackdriver-custom-metrics-subdomain"
+	// StackdriverResourceMappingsKey is a JSON list of
+	// StackdriverResourceMapping, which map groups of metrics to custom
+	// metric type prefixes and monitored resource types.
+	StackdriverResourceMappingsKey = "metrics.stackdriver-resource-mappings"
+	// AllowedNamespacesKey and DeniedNamespacesKey are comma-separated lists
+	// of namespaces, whose metrics are exported or not.
+	AllowedNamespacesKey = "metrics.allowed-namespaces"
+	DeniedNamespacesKey  = "metrics.denied-namespaces"
 
 	// Stackdriver is used for Stackdriver backend
 	Stackdriver metricsBackend = "stackdriver"
@@ -98,6 +108,12 @@ type metricsConfig struct {
 	// reportingPeriod specifies the interval between reporting aggregated views.
 	// If duration is less than or equal to zero, it enables the default behavior.
 	reportingPeriod time.Duration
+	// allowedNamespaces are the namespaces whose metrics are exported. If nil,
+	// the metrics of all of the namespaces are exported.
+	allowedNamespaces sets.String
+	// deniedNamespaces are the namespaces whose metrics aren't exported, even
+	// if allowed.
+	deniedNamespaces sets.String
 
 	// ---- Prometheus specific below ----
 	// prometheusPort is the port where metrics are exposed in Prometheus
@@ -129,6 +145,10 @@ type metricsConfig struct {
 	// E.g., "custom.googleapis.com/<subdomain>/<component>".
 	// Store this in a variable to reduce string join operations.
 	stackdriverCustomMetricTypePrefix string
+	// stackdriverResourceMappings map groups of metrics to the metric type
+	// prefixes and monitored resource types they are exported with.
+	// If backendDestination is not Stackdriver, this is ignored.
+	stackdriverResourceMappings stackdriverResourceMappings
 }
 
 func getMetricsConfig(ops ExporterOptions, logger *zap.SugaredLogger) (*metricsConfig, error) {
@@ -197,6 +217,13 @@ func getMetricsConfig(ops ExporterOptions, logger *zap.SugaredLogger) (*metricsC
 			}
 			mc.allowStackdriverCustomMetrics = ascmBool
 		}
+		if mappings, ok := m[StackdriverResourceMappingsKey]; ok && mappings != "" {
+			ms, err := parseStackdriverResourceMappings(mappings, mc.stackdriverMetricTypePrefix)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s value %q: %v", StackdriverResourceMappingsKey, mappings, err)
+			}
+			mc.stackdriverResourceMappings = ms
+		}
 	}
 
 	// If reporting period is specified, use the value from the configuration.
@@ -218,6 +245,9 @@ func getMetricsConfig(ops ExporterOptions, logger *zap.SugaredLogger) (*metricsC
 		mc.reportingPeriod = 5 * time.Second
 	}
 
+	mc.allowedNamespaces = parseNamespaces(m[AllowedNamespacesKey])
+	mc.deniedNamespaces = parseNamespaces(m[DeniedNamespacesKey])
+
 	return &mc, nil
 }
 
@@ -265,13 +295,17 @@ func UpdateExporter(ops ExporterOptions, logger *zap.SugaredLogger) error {
 }
 
 // isNewExporterRequired compares the non-nil newConfig against curMetricsConfig. When backend changes,
-// or stackdriver project ID changes for stackdriver backend, we need to update the metrics exporter.
+// or stackdriver project ID or resource mappings change for stackdriver backend, we need to update the
+// metrics exporter.
 func isNewExporterRequired(newConfig *metricsConfig) bool {
 	cc := getCurMetricsConfig()
 	if cc == nil || newConfig.backendDestination != cc.backendDestination {
 		return true
 	} else if newConfig.backendDestination == Stackdriver && newConfig.stackdriverProjectID != cc.stackdriverProjectID {
 		return true
+	} else if newConfig.backendDestination == Stackdriver &&
+		!reflect.DeepEqual(newConfig.stackdriverResourceMappings, cc.stackdriverResourceMappings) {
+		return true
 	}
 
 	return false
@@ -104,6 +104,17 @@ var (
 			Component: testComponent,
 		},
 		expectedErr: "invalid metrics.allow-stackdriver-custom-metrics value \"test\"",
+	}, {
+		name: "invalidStackdriverResourceMappings",
+		ops: ExporterOptions{
+			ConfigMap: map[string]string{
+				"metrics.backend-destination":           "stackdriver",
+				"metrics.stackdriver-resource-mappings": `[{"metrics": []}]`,
+			},
+			Domain:    servingDomain,
+			Component: testComponent,
+		},
+		expectedErr: "invalid metrics.stackdriver-resource-mappings value \"[{\\\"metrics\\\": []}]\": mapping 0 has no metrics",
 	}, {
 		name: "tooSmallPrometheusPort",
 		ops: ExporterOptions{
@@ -382,6 +393,34 @@ var (
 				stackdriverCustomMetricTypePrefix: path.Join(customMetricTypePrefix, customSubDomain, testComponent),
 				stackdriverCustomMetricsSubDomain: customSubDomain,
 			},
+		}, {
+			name: "stackdriverResourceMappings",
+			ops: ExporterOptions{
+				ConfigMap: map[string]string{
+					"metrics.backend-destination":           "stackdriver",
+					"metrics.stackdriver-project-id":        "test2",
+					"metrics.stackdriver-resource-mappings": `[{"metrics": ["queue_depth"], "resourceType": "k8s_pod"}]`,
+				},
+				Domain:    servingDomain,
+				Component: testComponent,
+			},
+			expectedConfig: metricsConfig{
+				domain:                            servingDomain,
+				component:                         testComponent,
+				backendDestination:                Stackdriver,
+				stackdriverProjectID:              "test2",
+				reportingPeriod:                   60 * time.Second,
+				isStackdriverBackend:              true,
+				stackdriverMetricTypePrefix:       path.Join(servingDomain, testComponent),
+				stackdriverCustomMetricTypePrefix: path.Join(customMetricTypePrefix, defaultCustomMetricSubDomain, testComponent),
+				stackdriverCustomMetricsSubDomain: defaultCustomMetricSubDomain,
+				stackdriverResourceMappings: stackdriverResourceMappings{{
+					Metrics:          []string{"queue_depth"},
+					MetricTypePrefix: path.Join(servingDomain, testComponent),
+					ResourceType:     "k8s_pod",
+				}},
+			},
+			expectedNewExporter: true,
 		}, {
 			name: "overridePrometheusPort",
 			ops: ExporterOptions{
@@ -55,7 +55,7 @@ func newMetricsExporter(config *metricsConfig, logger *zap.SugaredLogger) (view.
 	if err != nil {
 		return nil, err
 	}
-	return e, nil
+	return &namespaceFilteringExporter{Exporter: e}, nil
 }
 
 func getCurMetricsExporter() view.Exporter {
@@ -97,6 +97,9 @@ func FlushExporter() bool {
 	if e == nil {
 		return false
 	}
+	if fe, ok := e.(*namespaceFilteringExporter); ok {
+		e = fe.Exporter
+	}
 
 	if f, ok := e.(flushable); ok {
 		f.Flush()
@@ -0,0 +1,101 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"strings"
+
+	"go.opencensus.io/stats/view"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"knative.dev/pkg/metrics/metricskey"
+)
+
+// parseNamespaces parses a comma-separated list of namespaces, or returns
+// nil if it's empty.
+func parseNamespaces(s string) sets.String {
+	var namespaces sets.String
+	for _, ns := range strings.Split(s, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			if namespaces == nil {
+				namespaces = sets.NewString()
+			}
+			namespaces.Insert(ns)
+		}
+	}
+	return namespaces
+}
+
+// namespaceAllowed returns true if the metrics of the given namespace are
+// exported according to the config. The denied namespaces take precedence
+// over the allowed ones, and all of the namespaces are allowed if none is.
+func (mc *metricsConfig) namespaceAllowed(ns string) bool {
+	if mc == nil {
+		return true
+	}
+	if mc.deniedNamespaces.Has(ns) {
+		return false
+	}
+	return mc.allowedNamespaces == nil || mc.allowedNamespaces.Has(ns)
+}
+
+// filtersNamespaces returns true if the config filters the metrics by namespace.
+func (mc *metricsConfig) filtersNamespaces() bool {
+	return mc != nil && (mc.allowedNamespaces != nil || mc.deniedNamespaces != nil)
+}
+
+// namespaceFilteringExporter wraps an exporter to drop the rows of the
+// namespaces whose metrics aren't exported according to the current config.
+// The rows without namespace are always exported.
+type namespaceFilteringExporter struct {
+	view.Exporter
+}
+
+var _ view.Exporter = (*namespaceFilteringExporter)(nil)
+
+// ExportView implements view.Exporter.
+func (e *namespaceFilteringExporter) ExportView(vd *view.Data) {
+	mc := getCurMetricsConfig()
+	if !mc.filtersNamespaces() {
+		e.Exporter.ExportView(vd)
+		return
+	}
+
+	rows := make([]*view.Row, 0, len(vd.Rows))
+	for _, row := range vd.Rows {
+		if rowNamespaceAllowed(mc, row) {
+			rows = append(rows, row)
+		}
+	}
+	if len(rows) == len(vd.Rows) {
+		e.Exporter.ExportView(vd)
+		return
+	}
+	filtered := *vd
+	filtered.Rows = rows
+	e.Exporter.ExportView(&filtered)
+}
+
+// rowNamespaceAllowed returns true if the row has no namespace tag, or if the
+// metrics of its namespace are exported.
+func rowNamespaceAllowed(mc *metricsConfig, row *view.Row) bool {
+	for _, t := range row.Tags {
+		if t.Key.Name() == metricskey.LabelNamespaceName {
+			return mc.namespaceAllowed(t.Value)
+		}
+	}
+	return true
+}
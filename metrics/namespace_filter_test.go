@@ -0,0 +1,113 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	. "knative.dev/pkg/logging/testing"
+	"knative.dev/pkg/metrics/metricskey"
+)
+
+type recordingExporter struct {
+	data []*view.Data
+}
+
+func (e *recordingExporter) ExportView(vd *view.Data) {
+	e.data = append(e.data, vd)
+}
+
+func TestNamespaceFilterConfig(t *testing.T) {
+	defer ClearAll()
+	mc, err := getMetricsConfig(ExporterOptions{
+		Domain:    servingDomain,
+		Component: testComponent,
+		ConfigMap: map[string]string{
+			BackendDestinationKey: string(Prometheus),
+			AllowedNamespacesKey:  "foo, bar,",
+			DeniedNamespacesKey:   "bar",
+		},
+	}, TestLogger(t))
+	if err != nil {
+		t.Fatalf("getMetricsConfig() = %v", err)
+	}
+	if diff := cmp.Diff(sets.NewString("foo", "bar"), mc.allowedNamespaces); diff != "" {
+		t.Errorf("allowedNamespaces (-want, +got) = %s", diff)
+	}
+	for ns, want := range map[string]bool{"foo": true, "bar": false, "baz": false} {
+		if got := mc.namespaceAllowed(ns); got != want {
+			t.Errorf("namespaceAllowed(%q) = %v, want %v", ns, got, want)
+		}
+	}
+}
+
+func TestNamespaceFilteringExporter(t *testing.T) {
+	defer setCurMetricsConfig(nil)
+	nsKey := tag.MustNewKey(metricskey.LabelNamespaceName)
+	otherKey := tag.MustNewKey("other")
+	rows := []*view.Row{
+		{Tags: []tag.Tag{{Key: nsKey, Value: "tenant-a"}}},
+		{Tags: []tag.Tag{{Key: nsKey, Value: "tenant-b"}}},
+		{Tags: []tag.Tag{{Key: otherKey, Value: "tenant-b"}}},
+	}
+
+	tests := []struct {
+		name   string
+		config *metricsConfig
+		want   []*view.Row
+	}{{
+		name: "no config",
+		want: rows,
+	}, {
+		name:   "no filter",
+		config: &metricsConfig{},
+		want:   rows,
+	}, {
+		name:   "denied",
+		config: &metricsConfig{deniedNamespaces: sets.NewString("tenant-b")},
+		want:   []*view.Row{rows[0], rows[2]},
+	}, {
+		name:   "allowed",
+		config: &metricsConfig{allowedNamespaces: sets.NewString("tenant-b")},
+		want:   []*view.Row{rows[1], rows[2]},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setCurMetricsConfig(test.config)
+			inner := &recordingExporter{}
+			e := &namespaceFilteringExporter{Exporter: inner}
+			vd := &view.Data{Rows: rows}
+			e.ExportView(vd)
+
+			if len(inner.data) != 1 {
+				t.Fatalf("Exported %d views, want 1", len(inner.data))
+			}
+			if diff := cmp.Diff(test.want, inner.data[0].Rows, cmp.Comparer(func(a, b *view.Row) bool { return a == b })); diff != "" {
+				t.Errorf("Exported rows (-want, +got) = %s", diff)
+			}
+			if len(vd.Rows) != len(rows) {
+				t.Error("ExportView() modified the original view data")
+			}
+		})
+	}
+}
@@ -36,21 +36,21 @@ import (
 //   2) The backend is not Stackdriver.
 //   3) The backend is Stackdriver and it is allowed to use custom metrics.
 //   4) The backend is Stackdriver and the metric is one of the built-in metrics: "knative_revision", "knative_broker",
-//      "knative_trigger", "knative_source".
+//      "knative_trigger", "knative_source", or is mapped by the Stackdriver resource mappings.
+// The measurement is also forwarded to the Instruments of the views migrated
+// with MigrateView under the same conditions.
 func Record(ctx context.Context, ms stats.Measurement, ros ...stats.Options) {
 	mc := getCurMetricsConfig()
 
-	ros = append(ros, stats.WithMeasurements(ms))
-
 	// Condition 1)
 	if mc == nil {
-		stats.RecordWithOptions(ctx, ros...)
+		record(ctx, ms, ros...)
 		return
 	}
 
 	// Condition 2) and 3)
 	if !mc.isStackdriverBackend || mc.allowStackdriverCustomMetrics {
-		stats.RecordWithOptions(ctx, ros...)
+		record(ctx, ms, ros...)
 		return
 	}
 
@@ -61,11 +61,19 @@ func Record(ctx context.Context, ms stats.Measurement, ros ...stats.Options) {
 		metricskey.KnativeBrokerMetrics.Has(metricType) ||
 		metricskey.KnativeSourceMetrics.Has(metricType)
 
-	if isServingBuiltIn || isEventingBuiltIn {
-		stats.RecordWithOptions(ctx, ros...)
+	isMapped := mc.stackdriverResourceMappings.find(ms.Measure().Name()) != nil
+
+	if isServingBuiltIn || isEventingBuiltIn || isMapped {
+		record(ctx, ms, ros...)
 	}
 }
 
+// record records the measurement via OpenCensus and the migrated views.
+func record(ctx context.Context, ms stats.Measurement, ros ...stats.Options) {
+	stats.RecordWithOptions(ctx, append(ros, stats.WithMeasurements(ms))...)
+	recordMigrated(ctx, ms)
+}
+
 // Buckets125 generates an array of buckets with approximate powers-of-two
 // buckets that also aligns with powers of 10 on every 3rd step. This can
 // be used to create a view.Distribution.
@@ -58,6 +58,16 @@ func TestRecordServing(t *testing.T) {
 				allowStackdriverCustomMetrics: true,
 			},
 			measurement: measure.M(3),
+		}, {
+			name: "stackdriver backend with mapped metric",
+			metricsConfig: &metricsConfig{
+				isStackdriverBackend:        true,
+				stackdriverMetricTypePrefix: "knative.dev/unsupported",
+				stackdriverResourceMappings: stackdriverResourceMappings{{
+					Metrics: []string{"request_*"},
+				}},
+			},
+			measurement: measure.M(6),
 		}, {
 			name:        "empty metricsConfig",
 			measurement: measure.M(4),
@@ -0,0 +1,169 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"go.opencensus.io/metric/metricdata"
+	"go.opencensus.io/metric/metricproducer"
+)
+
+// MetricSnapshot is the current value of a metric, e.g. of a registered view.
+type MetricSnapshot struct {
+	Name        string               `json:"name"`
+	Description string               `json:"description,omitempty"`
+	Unit        string               `json:"unit,omitempty"`
+	Type        string               `json:"type"`
+	TimeSeries  []TimeSeriesSnapshot `json:"timeSeries"`
+}
+
+// TimeSeriesSnapshot is the current value of a metric for a set of labels,
+// i.e. of a row of a view.
+type TimeSeriesSnapshot struct {
+	// Labels are the labels of the time series, without the unset ones.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Start is the time the cumulative value started to be accumulated, nil
+	// for the gauges.
+	Start *time.Time `json:"start,omitempty"`
+	// Value is a float64 or an int64, or a *DistributionSnapshot.
+	Value interface{} `json:"value"`
+}
+
+// DistributionSnapshot is the current value of a distribution.
+type DistributionSnapshot struct {
+	Count   int64            `json:"count"`
+	Sum     float64          `json:"sum"`
+	Buckets []BucketSnapshot `json:"buckets,omitempty"`
+}
+
+// BucketSnapshot is the number of values of a distribution below a bound, and
+// above the bound of the previous bucket. The last bucket has no bound.
+type BucketSnapshot struct {
+	Bound *float64 `json:"bound,omitempty"`
+	Count int64    `json:"count"`
+}
+
+// Snapshot returns the current values of all of the metrics, including the
+// ones of all of the registered views, sorted by name. The metrics whose name
+// doesn't start with the given prefix are skipped. It doesn't depend on the
+// backend the metrics are exported to.
+func Snapshot(prefix string) []MetricSnapshot {
+	var snapshots []MetricSnapshot
+	for _, producer := range metricproducer.GlobalManager().GetAll() {
+		for _, m := range producer.Read() {
+			if m == nil || !strings.HasPrefix(m.Descriptor.Name, prefix) {
+				continue
+			}
+			snapshots = append(snapshots, newMetricSnapshot(m))
+		}
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Name < snapshots[j].Name
+	})
+	return snapshots
+}
+
+func newMetricSnapshot(m *metricdata.Metric) MetricSnapshot {
+	s := MetricSnapshot{
+		Name:        m.Descriptor.Name,
+		Description: m.Descriptor.Description,
+		Unit:        string(m.Descriptor.Unit),
+		Type:        strings.TrimPrefix(m.Descriptor.Type.String(), "Type"),
+		TimeSeries:  make([]TimeSeriesSnapshot, 0, len(m.TimeSeries)),
+	}
+	for _, ts := range m.TimeSeries {
+		if ts == nil || len(ts.Points) == 0 {
+			continue
+		}
+		var tss TimeSeriesSnapshot
+		if !ts.StartTime.IsZero() {
+			start := ts.StartTime
+			tss.Start = &start
+		}
+		for i, v := range ts.LabelValues {
+			if v.Present && i < len(m.Descriptor.LabelKeys) {
+				if tss.Labels == nil {
+					tss.Labels = make(map[string]string, len(ts.LabelValues))
+				}
+				tss.Labels[m.Descriptor.LabelKeys[i].Key] = v.Value
+			}
+		}
+		// The points of the time series of a view are its current value.
+		tss.Value = snapshotValue(ts.Points[len(ts.Points)-1].Value)
+		s.TimeSeries = append(s.TimeSeries, tss)
+	}
+	sort.Slice(s.TimeSeries, func(i, j int) bool {
+		return labelsString(s.TimeSeries[i].Labels) < labelsString(s.TimeSeries[j].Labels)
+	})
+	return s
+}
+
+// labelsString returns the labels as a string, in the order of their keys.
+func labelsString(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func snapshotValue(v interface{}) interface{} {
+	d, ok := v.(*metricdata.Distribution)
+	if !ok {
+		// Summaries are encoded as they are.
+		return v
+	}
+	ds := &DistributionSnapshot{Count: d.Count, Sum: d.Sum}
+	for i, b := range d.Buckets {
+		bs := BucketSnapshot{Count: b.Count}
+		if d.BucketOptions != nil && i < len(d.BucketOptions.Bounds) {
+			bound := d.BucketOptions.Bounds[i]
+			bs.Bound = &bound
+		}
+		ds.Buckets = append(ds.Buckets, bs)
+	}
+	return ds
+}
+
+// SnapshotHandler returns an HTTP handler serving the Snapshot of the metrics
+// as JSON, to inspect the instrumentation while debugging without a metrics
+// backend. The "prefix" query parameter restricts the metrics served, e.g.
+//
+//	http.Handle("/debug/metrics", metrics.SnapshotHandler())
+//
+//	curl localhost:8008/debug/metrics?prefix=reconcile
+func SnapshotHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snapshots := Snapshot(r.URL.Query().Get("prefix"))
+		if snapshots == nil {
+			snapshots = []MetricSnapshot{}
+		}
+		b, err := json.MarshalIndent(snapshots, "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+func TestSnapshotHandler(t *testing.T) {
+	measure := stats.Int64("snapshot_test_latency", "Latency of the snapshot test", stats.UnitMilliseconds)
+	key := tag.MustNewKey("snapshot_test_key")
+	views := []*view.View{{
+		Name:        "snapshot_test_count",
+		Description: "Count of the snapshot test",
+		Measure:     measure,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{key},
+	}, {
+		Name:        "snapshot_test_latency",
+		Description: measure.Description(),
+		Measure:     measure,
+		Aggregation: view.Distribution(10, 100),
+	}}
+	if err := view.Register(views...); err != nil {
+		t.Fatalf("view.Register() = %v", err)
+	}
+	defer view.Unregister(views...)
+
+	for _, tc := range []struct {
+		value int64
+		tag   string
+	}{{5, "b"}, {50, "a"}, {500, "b"}} {
+		ctx, _ := tag.New(context.Background(), tag.Insert(key, tc.tag))
+		stats.Record(ctx, measure.M(tc.value))
+	}
+
+	s := httptest.NewServer(SnapshotHandler())
+	defer s.Close()
+	resp, err := http.Get(s.URL + "?prefix=snapshot_test_")
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	defer resp.Body.Close()
+	if got, want := resp.Header.Get("Content-Type"), "application/json"; got != want {
+		t.Errorf("Content-Type = %q, want: %q", got, want)
+	}
+	var got []MetricSnapshot
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("Decode() = %v", err)
+	}
+
+	// The values are decoded as float64 by encoding/json.
+	want := []MetricSnapshot{{
+		Name:        "snapshot_test_count",
+		Description: "Count of the snapshot test",
+		Unit:        "ms",
+		Type:        "CumulativeInt64",
+		TimeSeries: []TimeSeriesSnapshot{{
+			Labels: map[string]string{"snapshot_test_key": "a"},
+			Value:  1.0,
+		}, {
+			Labels: map[string]string{"snapshot_test_key": "b"},
+			Value:  2.0,
+		}},
+	}, {
+		Name:        "snapshot_test_latency",
+		Description: "Latency of the snapshot test",
+		Unit:        "ms",
+		Type:        "CumulativeDistribution",
+		TimeSeries: []TimeSeriesSnapshot{{
+			Value: map[string]interface{}{
+				"count": 3.0,
+				"sum":   555.0,
+				"buckets": []interface{}{
+					map[string]interface{}{"bound": 10.0, "count": 1.0},
+					map[string]interface{}{"bound": 100.0, "count": 1.0},
+					map[string]interface{}{"count": 1.0},
+				},
+			},
+		}},
+	}}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(TimeSeriesSnapshot{}, "Start")); diff != "" {
+		t.Errorf("Snapshot (-want, +got): %s", diff)
+	}
+}
+
+func TestSnapshotPrefix(t *testing.T) {
+	if got := Snapshot("snapshot_test_nothing_"); len(got) != 0 {
+		t.Errorf("Snapshot() = %v, want none", got)
+	}
+
+	s := httptest.NewServer(SnapshotHandler())
+	defer s.Close()
+	resp, err := http.Get(s.URL + "?prefix=snapshot_test_nothing_")
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	defer resp.Body.Close()
+	var got []MetricSnapshot
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("Decode() = %v", err)
+	}
+	if got == nil || len(got) != 0 {
+		t.Errorf("Snapshot = %v, want an empty list", got)
+	}
+}
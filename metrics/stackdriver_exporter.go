@@ -64,12 +64,15 @@ func newOpencensusSDExporter(o stackdriver.Options) (view.Exporter, error) {
 // 	See https://github.com/knative/pkg/issues/608
 func newStackdriverExporter(config *metricsConfig, logger *zap.SugaredLogger) (view.Exporter, error) {
 	gm := gcpMetadataFunc()
-	mtf := getMetricTypeFunc(config.stackdriverMetricTypePrefix, config.stackdriverCustomMetricTypePrefix)
+	mtf := getMappedMetricTypeFunc(config.stackdriverResourceMappings,
+		getMetricTypeFunc(config.stackdriverMetricTypePrefix, config.stackdriverCustomMetricTypePrefix))
+	mrf := getMappedMonitoredResourceFunc(config.stackdriverResourceMappings, gm,
+		getMonitoredResourceFunc(config.stackdriverMetricTypePrefix, gm))
 	e, err := newStackdriverExporterFunc(stackdriver.Options{
 		ProjectID:               config.stackdriverProjectID,
 		GetMetricDisplayName:    mtf, // Use metric type for display name for custom metrics. No impact on built-in metrics.
 		GetMetricType:           mtf,
-		GetMonitoredResource:    getMonitoredResourceFunc(config.stackdriverMetricTypePrefix, gm),
+		GetMonitoredResource:    mrf,
 		DefaultMonitoringLabels: &stackdriver.Labels{},
 	})
 	if err != nil {
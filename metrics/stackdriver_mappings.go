@@ -0,0 +1,191 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"contrib.go.opencensus.io/exporter/stackdriver/monitoredresource"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"knative.dev/pkg/metrics/metricskey"
+)
+
+// globalResourceType is the type of the Global monitored resource.
+const globalResourceType = "global"
+
+// StackdriverResourceMapping maps a group of metrics to the Stackdriver metric
+// type prefix and monitored resource type they are exported with, instead of
+// the built-in knative_revision, knative_broker, knative_trigger and
+// knative_source mappings. The mappings are set as a JSON list under
+// StackdriverResourceMappingsKey, e.g.
+//
+//	[{
+//	  "metrics": ["queue_depth", "reconcile_*"],
+//	  "metricTypePrefix": "example.com/my-controller",
+//	  "resourceType": "k8s_container",
+//	  "resourceLabels": {
+//	    "namespace_name": "namespace",
+//	    "pod_name": "pod",
+//	    "container_name": "container"
+//	  }
+//	}]
+//
+// The mapped metrics are recorded even if custom metrics aren't allowed.
+type StackdriverResourceMapping struct {
+	// Metrics are the names of the metrics of the group, as measured. A
+	// trailing "*" matches all of the names with the given prefix.
+	Metrics []string `json:"metrics"`
+
+	// MetricTypePrefix is the prefix of the metric types of the group, e.g.
+	// "example.com/my-controller". Defaults to the domain joined with the
+	// component.
+	MetricTypePrefix string `json:"metricTypePrefix,omitempty"`
+
+	// ResourceType is the type of the monitored resource the metrics of the
+	// group are exported with, e.g. "k8s_container". Defaults to "global".
+	ResourceType string `json:"resourceType,omitempty"`
+
+	// ResourceLabels maps the labels of the monitored resource to the tags
+	// of the metrics their values are taken from. These tags are removed from
+	// the metric labels, and the labels whose tag is missing are "unknown".
+	// The project_id, location and cluster_name labels are set from the GCP
+	// metadata unless mapped. They cannot be set for the global type.
+	ResourceLabels map[string]string `json:"resourceLabels,omitempty"`
+}
+
+// matches returns true if the metric of the given name is in the group.
+func (m *StackdriverResourceMapping) matches(name string) bool {
+	for _, pattern := range m.Metrics {
+		if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// stackdriverResourceMappings are the mappings configured, in order.
+type stackdriverResourceMappings []StackdriverResourceMapping
+
+// find returns the first mapping of the metric of the given name, or nil if it
+// isn't mapped.
+func (ms stackdriverResourceMappings) find(name string) *StackdriverResourceMapping {
+	for i := range ms {
+		if ms[i].matches(name) {
+			return &ms[i]
+		}
+	}
+	return nil
+}
+
+// parseStackdriverResourceMappings parses and validates the JSON mappings,
+// defaulting their metric type prefix to the given one.
+func parseStackdriverResourceMappings(s, metricTypePrefix string) (stackdriverResourceMappings, error) {
+	var ms stackdriverResourceMappings
+	if err := json.Unmarshal([]byte(s), &ms); err != nil {
+		return nil, err
+	}
+	for i := range ms {
+		m := &ms[i]
+		if len(m.Metrics) == 0 {
+			return nil, fmt.Errorf("mapping %d has no metrics", i)
+		}
+		for _, name := range m.Metrics {
+			if name == "" {
+				return nil, fmt.Errorf("mapping %d has an empty metric name", i)
+			}
+		}
+		if m.MetricTypePrefix == "" {
+			m.MetricTypePrefix = metricTypePrefix
+		}
+		if m.ResourceType == "" {
+			m.ResourceType = globalResourceType
+		}
+		if m.ResourceType == globalResourceType && len(m.ResourceLabels) != 0 {
+			return nil, fmt.Errorf("mapping %d cannot set resource labels for the %s resource type", i, globalResourceType)
+		}
+	}
+	if len(ms) == 0 {
+		return nil, nil
+	}
+	return ms, nil
+}
+
+// MappedResource is a monitored resource of a type configured by a
+// StackdriverResourceMapping.
+type MappedResource struct {
+	Type   string
+	Labels map[string]string
+}
+
+func (mr *MappedResource) MonitoredResource() (resType string, labels map[string]string) {
+	return mr.Type, mr.Labels
+}
+
+// getMappedMetricTypeFunc returns the metric types of the mapped metrics, and
+// falls back to the given func for the others.
+func getMappedMetricTypeFunc(ms stackdriverResourceMappings, fallback func(*view.View) string) func(*view.View) string {
+	return func(v *view.View) string {
+		if m := ms.find(v.Measure.Name()); m != nil {
+			return path.Join(m.MetricTypePrefix, v.Measure.Name())
+		}
+		return fallback(v)
+	}
+}
+
+// getMappedMonitoredResourceFunc returns the monitored resources of the mapped
+// metrics, and falls back to the given func for the others.
+func getMappedMonitoredResourceFunc(ms stackdriverResourceMappings, gm *gcpMetadata,
+	fallback func(*view.View, []tag.Tag) ([]tag.Tag, monitoredresource.Interface)) func(*view.View, []tag.Tag) ([]tag.Tag, monitoredresource.Interface) {
+	return func(v *view.View, tags []tag.Tag) ([]tag.Tag, monitoredresource.Interface) {
+		m := ms.find(v.Measure.Name())
+		if m == nil {
+			return fallback(v, tags)
+		}
+		if m.ResourceType == globalResourceType {
+			return getGlobalMonitoredResource(v, tags)
+		}
+
+		labels := map[string]string{
+			metricskey.LabelProject:     gm.project,
+			metricskey.LabelLocation:    gm.location,
+			metricskey.LabelClusterName: gm.cluster,
+		}
+		tagsMap := getTagsMap(tags)
+		resourceTags := make(map[string]bool, len(m.ResourceLabels))
+		for label, key := range m.ResourceLabels {
+			labels[label] = valueOrUnknown(key, tagsMap)
+			resourceTags[key] = true
+		}
+
+		var newTags []tag.Tag
+		for _, t := range tags {
+			// Keep the metrics labels that are not resource labels
+			if !resourceTags[t.Key.Name()] {
+				newTags = append(newTags, t)
+			}
+		}
+		return newTags, &MappedResource{Type: m.ResourceType, Labels: labels}
+	}
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"testing"
+
+	"contrib.go.opencensus.io/exporter/stackdriver/monitoredresource"
+	"github.com/google/go-cmp/cmp"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"knative.dev/pkg/metrics/metricskey"
+)
+
+const testMappings = `[{
+  "metrics": ["queue_depth", "reconcile_*"],
+  "metricTypePrefix": "example.com/controller",
+  "resourceType": "k8s_container",
+  "resourceLabels": {
+    "namespace_name": "namespace",
+    "pod_name": "pod"
+  }
+}, {
+  "metrics": ["cache_size"]
+}]`
+
+func TestParseStackdriverResourceMappings(t *testing.T) {
+	got, err := parseStackdriverResourceMappings(testMappings, "knative.dev/custom/controller")
+	if err != nil {
+		t.Fatalf("parseStackdriverResourceMappings() = %v", err)
+	}
+	want := stackdriverResourceMappings{{
+		Metrics:          []string{"queue_depth", "reconcile_*"},
+		MetricTypePrefix: "example.com/controller",
+		ResourceType:     "k8s_container",
+		ResourceLabels: map[string]string{
+			"namespace_name": "namespace",
+			"pod_name":       "pod",
+		},
+	}, {
+		Metrics:          []string{"cache_size"},
+		MetricTypePrefix: "knative.dev/custom/controller",
+		ResourceType:     "global",
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Mappings (-want, +got): %s", diff)
+	}
+
+	for _, name := range []string{"queue_depth", "reconcile_count", "reconcile_"} {
+		if m := got.find(name); m != &got[0] {
+			t.Errorf("find(%q) = %v, want the first mapping", name, m)
+		}
+	}
+	if m := got.find("cache_size"); m != &got[1] {
+		t.Errorf("find(cache_size) = %v, want the second mapping", m)
+	}
+	for _, name := range []string{"queue", "queue_depth_max", "cache_size_bytes"} {
+		if m := got.find(name); m != nil {
+			t.Errorf("find(%q) = %v, want nil", name, m)
+		}
+	}
+}
+
+func TestParseStackdriverResourceMappingsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		mappings string
+		want     string
+	}{{
+		name:     "not JSON",
+		mappings: "queue_depth=k8s_container",
+		want:     "invalid character 'q' looking for beginning of value",
+	}, {
+		name:     "no metrics",
+		mappings: `[{"resourceType": "k8s_pod"}]`,
+		want:     "mapping 0 has no metrics",
+	}, {
+		name:     "empty metric name",
+		mappings: `[{"metrics": ["a"]}, {"metrics": [""]}]`,
+		want:     "mapping 1 has an empty metric name",
+	}, {
+		name:     "labels of global",
+		mappings: `[{"metrics": ["a"], "resourceLabels": {"pod_name": "pod"}}]`,
+		want:     "mapping 0 cannot set resource labels for the global resource type",
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseStackdriverResourceMappings(test.mappings, "knative.dev/custom")
+			if err == nil || err.Error() != test.want {
+				t.Errorf("parseStackdriverResourceMappings() = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestGetMappedFuncs(t *testing.T) {
+	ms, err := parseStackdriverResourceMappings(testMappings, "knative.dev/custom/controller")
+	if err != nil {
+		t.Fatalf("parseStackdriverResourceMappings() = %v", err)
+	}
+	fallbackResource := &Global{}
+	mtf := getMappedMetricTypeFunc(ms, func(*view.View) string { return "fallback" })
+	mrf := getMappedMonitoredResourceFunc(ms, &testGcpMetadata,
+		func(_ *view.View, tags []tag.Tag) ([]tag.Tag, monitoredresource.Interface) {
+			return tags, fallbackResource
+		})
+
+	namespaceKey := tag.MustNewKey("namespace")
+	reasonKey := tag.MustNewKey("reason")
+	tags := []tag.Tag{
+		{Key: namespaceKey, Value: "ns"},
+		{Key: reasonKey, Value: "ok"},
+	}
+
+	tests := []struct {
+		name        string
+		metric      string
+		wantType    string
+		wantTags    []tag.Tag
+		wantResType string
+		wantLabels  map[string]string
+	}{{
+		name:        "mapped to a resource type",
+		metric:      "reconcile_count",
+		wantType:    "example.com/controller/reconcile_count",
+		wantTags:    []tag.Tag{{Key: reasonKey, Value: "ok"}},
+		wantResType: "k8s_container",
+		wantLabels: map[string]string{
+			metricskey.LabelProject:       testGcpMetadata.project,
+			metricskey.LabelLocation:      testGcpMetadata.location,
+			metricskey.LabelClusterName:   testGcpMetadata.cluster,
+			metricskey.LabelNamespaceName: "ns",
+			"pod_name":                    metricskey.ValueUnknown,
+		},
+	}, {
+		name:        "mapped to global",
+		metric:      "cache_size",
+		wantType:    "knative.dev/custom/controller/cache_size",
+		wantTags:    tags,
+		wantResType: "global",
+	}, {
+		name:        "not mapped",
+		metric:      "other",
+		wantType:    "fallback",
+		wantTags:    tags,
+		wantResType: "global",
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v := &view.View{
+				Measure:     stats.Int64(test.metric, "Test Measure", stats.UnitNone),
+				Aggregation: view.LastValue(),
+			}
+			if got := mtf(v); got != test.wantType {
+				t.Errorf("Metric type = %q, want %q", got, test.wantType)
+			}
+			gotTags, mr := mrf(v, tags)
+			if diff := cmp.Diff(test.wantTags, gotTags, cmp.Comparer(func(a, b tag.Key) bool {
+				return a.Name() == b.Name()
+			})); diff != "" {
+				t.Errorf("Tags (-want, +got): %s", diff)
+			}
+			gotResType, gotLabels := mr.MonitoredResource()
+			if gotResType != test.wantResType {
+				t.Errorf("Resource type = %q, want %q", gotResType, test.wantResType)
+			}
+			if diff := cmp.Diff(test.wantLabels, gotLabels); diff != "" {
+				t.Errorf("Resource labels (-want, +got): %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"go.opencensus.io/plugin/ochttp"
+)
+
+// ClientOptions configures the clients created by NewClient.
+type ClientOptions struct {
+	// Name identifies the client in its metrics. If empty, the client
+	// doesn't record metrics.
+	Name string
+
+	// Timeout is the time limit of a request, including retries and reading
+	// the response body. Zero means no timeout.
+	Timeout time.Duration
+
+	// DialTimeout is the time limit of establishing a connection.
+	DialTimeout time.Duration
+	// KeepAlive is the interval of the TCP keep-alive probes.
+	KeepAlive time.Duration
+	// TLSHandshakeTimeout is the time limit of a TLS handshake.
+	TLSHandshakeTimeout time.Duration
+	// ResponseHeaderTimeout is the time limit of waiting for the response
+	// headers once the request is written. Zero means no timeout.
+	ResponseHeaderTimeout time.Duration
+
+	// MaxIdleConns is the maximum number of idle connections across all
+	// hosts. Zero means no limit.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost is the maximum number of idle connections to
+	// each host.
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is the time an idle connection is kept open for.
+	IdleConnTimeout time.Duration
+
+	// DisableHTTP2 prevents the client from negotiating HTTP/2 over TLS.
+	DisableHTTP2 bool
+	// TLSClientConfig is the TLS configuration of the client.
+	TLSClientConfig *tls.Config
+
+	// DialContext overrides how connections are dialed, e.g. with the
+	// DialContext of a DNSCache.
+	DialContext DialContextFunc
+
+	// Retry, if set, retries failed requests according to the policy.
+	Retry *RetryPolicy
+
+	// DisableTracing prevents the client from creating spans for its
+	// requests and from propagating the trace context to the servers.
+	DisableTracing bool
+}
+
+// DefaultClientOptions returns the ClientOptions used by NewClient for the
+// unset fields.
+func DefaultClientOptions() ClientOptions {
+	return ClientOptions{
+		DialTimeout:         5 * time.Second,
+		KeepAlive:           30 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        1000,
+		MaxIdleConnsPerHost: 100,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
+
+// NewClient creates an HTTP client for outbound requests, made of a pooling
+// transport with the given timeouts, optionally retrying the requests,
+// instrumented with metrics and propagating the trace context of the
+// requests.
+func NewClient(opts ClientOptions) *http.Client {
+	return &http.Client{
+		Transport: NewClientTransport(opts),
+		Timeout:   opts.Timeout,
+	}
+}
+
+// NewClientTransport creates the transport of the clients created by
+// NewClient, for the cases where it's wrapped further.
+func NewClientTransport(opts ClientOptions) http.RoundTripper {
+	opts = opts.withDefaults()
+
+	dialer := &net.Dialer{
+		Timeout:   opts.DialTimeout,
+		KeepAlive: opts.KeepAlive,
+	}
+	dial := DialContextFunc(dialer.DialContext)
+	if opts.DialContext != nil {
+		dial = opts.DialContext
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dial,
+		ForceAttemptHTTP2:     !opts.DisableHTTP2,
+		TLSClientConfig:       opts.TLSClientConfig,
+		TLSHandshakeTimeout:   opts.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: opts.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+		MaxIdleConns:          opts.MaxIdleConns,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+	}
+	if opts.DisableHTTP2 {
+		// A non-nil empty map disables HTTP/2.
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	var rt http.RoundTripper = transport
+	if opts.Retry != nil {
+		rt = NewRetryingTransport(rt, *opts.Retry)
+	}
+	if opts.Name != "" {
+		rt = newInstrumentedTransport(rt, opts.Name)
+	}
+	if !opts.DisableTracing {
+		// Outermost, so the span covers the retries and the trace
+		// context is propagated with all of them.
+		rt = &ochttp.Transport{Base: rt}
+	}
+	return rt
+}
+
+// withDefaults returns the options with the unset fields defaulted.
+func (o ClientOptions) withDefaults() ClientOptions {
+	d := DefaultClientOptions()
+	if o.DialTimeout == 0 {
+		o.DialTimeout = d.DialTimeout
+	}
+	if o.KeepAlive == 0 {
+		o.KeepAlive = d.KeepAlive
+	}
+	if o.TLSHandshakeTimeout == 0 {
+		o.TLSHandshakeTimeout = d.TLSHandshakeTimeout
+	}
+	if o.MaxIdleConns == 0 {
+		o.MaxIdleConns = d.MaxIdleConns
+	}
+	if o.MaxIdleConnsPerHost == 0 {
+		o.MaxIdleConnsPerHost = d.MaxIdleConnsPerHost
+	}
+	if o.IdleConnTimeout == 0 {
+		o.IdleConnTimeout = d.IdleConnTimeout
+	}
+	return o
+}
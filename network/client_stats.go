@@ -0,0 +1,97 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"knative.dev/pkg/metrics"
+)
+
+var (
+	clientRequestCountStat = stats.Int64(
+		"outbound_request_count",
+		"Number of requests sent by an HTTP client",
+		stats.UnitDimensionless)
+	clientRequestLatencyStat = stats.Float64(
+		"outbound_request_latencies",
+		"The time in milliseconds an HTTP client took to get the response to a request",
+		stats.UnitMilliseconds)
+
+	// clientNameTagKey is the tag key holding the name of the client.
+	clientNameTagKey = tag.MustNewKey("client_name")
+	// responseCodeClassTagKey is the tag key holding the class of the
+	// response code, or "error" if the request failed.
+	responseCodeClassTagKey = tag.MustNewKey("response_code_class")
+)
+
+func init() {
+	registerClientViews()
+}
+
+func registerClientViews() {
+	if err := view.Register(
+		&view.View{
+			Description: clientRequestCountStat.Description(),
+			Measure:     clientRequestCountStat,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{clientNameTagKey, responseCodeClassTagKey},
+		},
+		&view.View{
+			Description: clientRequestLatencyStat.Description(),
+			Measure:     clientRequestLatencyStat,
+			Aggregation: view.Distribution(metrics.Buckets125(1, 100000)...), // [1 2 5 10 20 50 100 200 500 1000 2000 5000 10000 20000 50000 100000]ms
+			TagKeys:     []tag.Key{clientNameTagKey},
+		},
+	); err != nil {
+		panic(err)
+	}
+}
+
+// newInstrumentedTransport wraps the given transport to record the count and
+// latency of the requests, tagged with the name of the client.
+func newInstrumentedTransport(inner http.RoundTripper, name string) http.RoundTripper {
+	return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := inner.RoundTrip(r)
+		reportClientRequest(name, resp, err, time.Since(start))
+		return resp, err
+	})
+}
+
+// reportClientRequest records the outcome and latency of a request.
+func reportClientRequest(name string, resp *http.Response, err error, latency time.Duration) {
+	codeClass := "error"
+	if err == nil {
+		codeClass = metrics.ResponseCodeClass(resp.StatusCode)
+	}
+	ctx, tagErr := tag.New(context.Background(),
+		tag.Insert(clientNameTagKey, name),
+		tag.Insert(responseCodeClassTagKey, codeClass))
+	if tagErr != nil {
+		// The name is not a valid tag value, record the metrics untagged
+		// rather than not at all.
+		ctx = context.Background()
+	}
+	metrics.Record(ctx, clientRequestCountStat.M(1))
+	metrics.Record(ctx, clientRequestLatencyStat.M(float64(latency)/float64(time.Millisecond)))
+}
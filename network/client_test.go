@@ -0,0 +1,160 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"knative.dev/pkg/metrics/metricstest"
+)
+
+// OpenCensus metrics carry global state that need to be reset between unit tests.
+func resetClientMetrics() {
+	metricstest.Unregister("outbound_request_count", "outbound_request_latencies")
+	registerClientViews()
+}
+
+func TestNewClient(t *testing.T) {
+	resetClientMetrics()
+	var (
+		mu       sync.Mutex
+		requests int
+		traceIDs []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		traceIDs = append(traceIDs, r.Header.Get("X-B3-TraceId"))
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	retry := testPolicy()
+	retry.RetryStatus = RetryStatusCodes(http.StatusServiceUnavailable)
+	client := NewClient(ClientOptions{
+		Name:    "test-client",
+		Timeout: 10 * time.Second,
+		Retry:   &retry,
+	})
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Errorf("StatusCode = %d, want %d", got, want)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got, want := requests, 2; got != want {
+		t.Errorf("requests = %d, want %d", got, want)
+	}
+	// The trace context is propagated, with the same trace across retries.
+	if traceIDs[0] == "" || traceIDs[0] != traceIDs[1] {
+		t.Errorf("X-B3-TraceId = %v, want the same non-empty trace ID", traceIDs)
+	}
+
+	// The retries are recorded as a single request.
+	metricstest.CheckCountData(t, "outbound_request_count",
+		map[string]string{"client_name": "test-client", "response_code_class": "2xx"}, 1)
+	metricstest.CheckStatsReported(t, "outbound_request_latencies")
+}
+
+func TestNewClientTransport(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  ClientOptions
+		check func(*testing.T, *http.Transport)
+	}{{
+		name: "defaults",
+		check: func(t *testing.T, tr *http.Transport) {
+			d := DefaultClientOptions()
+			if got, want := tr.MaxIdleConnsPerHost, d.MaxIdleConnsPerHost; got != want {
+				t.Errorf("MaxIdleConnsPerHost = %d, want %d", got, want)
+			}
+			if got, want := tr.IdleConnTimeout, d.IdleConnTimeout; got != want {
+				t.Errorf("IdleConnTimeout = %v, want %v", got, want)
+			}
+			if !tr.ForceAttemptHTTP2 || tr.TLSNextProto != nil {
+				t.Error("HTTP/2 is disabled, wanted it enabled")
+			}
+		},
+	}, {
+		name: "overrides",
+		opts: ClientOptions{
+			MaxIdleConnsPerHost:   5,
+			ResponseHeaderTimeout: time.Second,
+			DisableHTTP2:          true,
+		},
+		check: func(t *testing.T, tr *http.Transport) {
+			if got, want := tr.MaxIdleConnsPerHost, 5; got != want {
+				t.Errorf("MaxIdleConnsPerHost = %d, want %d", got, want)
+			}
+			if got, want := tr.ResponseHeaderTimeout, time.Second; got != want {
+				t.Errorf("ResponseHeaderTimeout = %v, want %v", got, want)
+			}
+			if tr.ForceAttemptHTTP2 || tr.TLSNextProto == nil {
+				t.Error("HTTP/2 is enabled, wanted it disabled")
+			}
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Without a name, retries and tracing, the transport isn't wrapped.
+			test.opts.DisableTracing = true
+			tr, ok := NewClientTransport(test.opts).(*http.Transport)
+			if !ok {
+				t.Fatalf("NewClientTransport() = %T, wanted *http.Transport", tr)
+			}
+			test.check(t, tr)
+		})
+	}
+}
+
+func TestNewClientDialContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	var dialed []string
+	dialer := &net.Dialer{}
+	client := NewClient(ClientOptions{
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialed = append(dialed, address)
+			return dialer.DialContext(ctx, network, address)
+		},
+	})
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	resp.Body.Close()
+	if got, want := len(dialed), 1; got != want {
+		t.Errorf("len(dialed) = %d, want %d", got, want)
+	}
+}
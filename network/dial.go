@@ -0,0 +1,263 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// AddressFamily is the family of an IP address.
+type AddressFamily string
+
+const (
+	// IPv4 is the family of the IPv4 addresses, including the IPv4-mapped
+	// IPv6 addresses.
+	IPv4 AddressFamily = "ipv4"
+	// IPv6 is the family of the IPv6 addresses.
+	IPv6 AddressFamily = "ipv6"
+)
+
+// FamilyOf returns the family of the given IP address.
+func FamilyOf(ip net.IP) AddressFamily {
+	if ip.To4() != nil {
+		return IPv4
+	}
+	return IPv6
+}
+
+// DefaultFallbackDelay is how long the addresses of the preferred family are
+// dialed alone before the ones of the other family are raced with them, as
+// recommended by RFC 8305.
+const DefaultFallbackDelay = 300 * time.Millisecond
+
+// DialOptions configures the dial functions created by NewDialContext.
+type DialOptions struct {
+	// Name identifies the dial function in its metrics. If empty, it doesn't
+	// record metrics.
+	Name string
+
+	// Dial dials a single address. It defaults to the DialContext of a
+	// net.Dialer with the timeout and keep-alive of DefaultClientOptions.
+	Dial DialContextFunc
+	// Lookup resolves the hosts, e.g. DNSCache.LookupIPAddr. It defaults to
+	// net.DefaultResolver.
+	Lookup func(ctx context.Context, host string) ([]net.IPAddr, error)
+
+	// Family restricts the addresses dialed to a family, e.g. the one of an
+	// IPv6-only cluster, if set. The "tcp4" and "tcp6" networks restrict
+	// them as well.
+	Family AddressFamily
+	// PreferredFamily is the family dialed first. It defaults to the family
+	// of the first address the host resolves to, since the resolvers sort
+	// them by preference.
+	PreferredFamily AddressFamily
+	// FallbackDelay is how long the addresses of the preferred family are
+	// dialed alone before the ones of the other family are raced with them,
+	// or as soon as they all failed. It defaults to DefaultFallbackDelay. A
+	// negative delay dials the families one after the other.
+	FallbackDelay time.Duration
+}
+
+// NewDialContext returns a dial function aware of dual-stack clusters: the
+// addresses of the host are dialed in turn, the ones of the preferred family
+// first, racing the ones of the other family after the fallback delay as in
+// the happy eyeballs algorithm (RFC 8305), so that an unreachable family
+// doesn't hold up the connection. The first connection established is
+// returned, and the other attempts are canceled. The attempts are recorded
+// per family if the options are named.
+func NewDialContext(opts DialOptions) DialContextFunc {
+	if opts.Dial == nil {
+		d := DefaultClientOptions()
+		opts.Dial = (&net.Dialer{Timeout: d.DialTimeout, KeepAlive: d.KeepAlive}).DialContext
+	}
+	if opts.Lookup == nil {
+		opts.Lookup = net.DefaultResolver.LookupIPAddr
+	}
+	if opts.FallbackDelay == 0 {
+		opts.FallbackDelay = DefaultFallbackDelay
+	}
+	return opts.dialContext
+}
+
+func (o *DialOptions) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	family := o.Family
+	switch network {
+	case "tcp", "udp":
+	case "tcp4", "udp4":
+		family = IPv4
+	case "tcp6", "udp6":
+		family = IPv6
+	default:
+		// E.g. unix sockets.
+		return o.Dial(ctx, network, address)
+	}
+	if o.Family != "" && o.Family != family {
+		return nil, &net.AddrError{Err: "network " + network + " excludes the family " + string(o.Family), Addr: address}
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []net.IPAddr
+	if ip := net.ParseIP(host); ip != nil {
+		addrs = []net.IPAddr{{IP: ip}}
+	} else if addrs, err = o.Lookup(ctx, host); err != nil {
+		return nil, err
+	} else if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no addresses found", Name: host}
+	}
+	primaries, fallbacks := partitionAddrs(addrs, family, o.PreferredFamily)
+	if len(primaries) == 0 {
+		return nil, &net.AddrError{Err: "no address of family " + string(family), Addr: host}
+	}
+	return o.dialParallel(ctx, network, port, primaries, fallbacks)
+}
+
+// partitionAddrs returns the addresses of the preferred family, in order,
+// and the ones of the other family, if they aren't restricted to a family.
+func partitionAddrs(addrs []net.IPAddr, family, preferred AddressFamily) (primaries, fallbacks []net.IPAddr) {
+	if family != "" {
+		preferred = family
+	}
+	if preferred == "" {
+		preferred = FamilyOf(addrs[0].IP)
+	}
+	for _, addr := range addrs {
+		switch f := FamilyOf(addr.IP); {
+		case f == preferred:
+			primaries = append(primaries, addr)
+		case family == "":
+			fallbacks = append(fallbacks, addr)
+		}
+	}
+	if len(primaries) == 0 {
+		// Only the other family resolved.
+		return fallbacks, nil
+	}
+	return primaries, fallbacks
+}
+
+// dialResult is the outcome of dialing the addresses of a family.
+type dialResult struct {
+	conn    net.Conn
+	err     error
+	primary bool
+}
+
+// dialParallel dials the primary addresses, and races the fallback ones with
+// them after the fallback delay, or once they all failed. It returns the
+// first connection established, or the error of the primary addresses if
+// none could be.
+func (o *DialOptions) dialParallel(ctx context.Context, network, port string, primaries, fallbacks []net.IPAddr) (net.Conn, error) {
+	if len(fallbacks) == 0 {
+		return o.dialSerial(ctx, network, port, primaries)
+	}
+	if o.FallbackDelay < 0 {
+		conn, err := o.dialSerial(ctx, network, port, primaries)
+		if err == nil {
+			return conn, nil
+		}
+		if conn, ferr := o.dialSerial(ctx, network, port, fallbacks); ferr == nil {
+			return conn, nil
+		}
+		return nil, err
+	}
+
+	// Canceled once a connection is established, to abort the other
+	// attempts.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	returned := make(chan struct{})
+	defer close(returned)
+	results := make(chan dialResult)
+	race := func(addrs []net.IPAddr, primary bool) {
+		conn, err := o.dialSerial(ctx, network, port, addrs)
+		select {
+		case results <- dialResult{conn: conn, err: err, primary: primary}:
+		case <-returned:
+			if conn != nil {
+				conn.Close()
+			}
+		}
+	}
+
+	go race(primaries, true)
+	fallbackTimer := time.NewTimer(o.FallbackDelay)
+	defer fallbackTimer.Stop()
+	fallbackCh := fallbackTimer.C
+	pending := 1
+	var primaryErr, fallbackErr error
+	for {
+		select {
+		case <-fallbackCh:
+			fallbackCh = nil
+			pending++
+			go race(fallbacks, false)
+		case res := <-results:
+			if res.err == nil {
+				return res.conn, nil
+			}
+			pending--
+			if res.primary {
+				primaryErr = res.err
+				if fallbackCh != nil {
+					// Don't wait for the delay to try the other family.
+					fallbackCh = nil
+					pending++
+					go race(fallbacks, false)
+				}
+			} else {
+				fallbackErr = res.err
+			}
+			if pending == 0 {
+				if primaryErr != nil {
+					return nil, primaryErr
+				}
+				return nil, fallbackErr
+			}
+		}
+	}
+}
+
+// dialSerial dials the addresses in turn until a connection is established,
+// and returns the error of the first address otherwise.
+func (o *DialOptions) dialSerial(ctx context.Context, network, port string, addrs []net.IPAddr) (net.Conn, error) {
+	var firstErr error
+	for _, addr := range addrs {
+		if err := ctx.Err(); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			break
+		}
+		start := time.Now()
+		conn, err := o.Dial(ctx, network, net.JoinHostPort(addr.String(), port))
+		if o.Name != "" {
+			reportDial(o.Name, FamilyOf(addr.IP), dialOutcome(ctx, err), time.Since(start))
+		}
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
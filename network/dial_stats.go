@@ -0,0 +1,96 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"context"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"knative.dev/pkg/metrics"
+)
+
+var (
+	dialCountStat = stats.Int64(
+		"outbound_dial_count",
+		"Number of connections dialed, by address family and result",
+		stats.UnitDimensionless)
+	dialLatencyStat = stats.Float64(
+		"outbound_dial_latencies",
+		"The time in milliseconds establishing a connection took, by address family",
+		stats.UnitMilliseconds)
+
+	// addressFamilyTagKey is the tag key holding the family of the address
+	// dialed, i.e. "ipv4" or "ipv6".
+	addressFamilyTagKey = tag.MustNewKey("address_family")
+	// dialResultTagKey is the tag key holding the result of a dial, i.e.
+	// "success", "error", or "canceled" if another address won the race.
+	dialResultTagKey = tag.MustNewKey("dial_result")
+)
+
+func init() {
+	if err := view.Register(
+		&view.View{
+			Description: dialCountStat.Description(),
+			Measure:     dialCountStat,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{clientNameTagKey, addressFamilyTagKey, dialResultTagKey},
+		},
+		&view.View{
+			Description: dialLatencyStat.Description(),
+			Measure:     dialLatencyStat,
+			Aggregation: view.Distribution(metrics.Buckets125(1, 10000)...), // [1 2 5 10 20 50 100 200 500 1000 2000 5000 10000]ms
+			TagKeys:     []tag.Key{clientNameTagKey, addressFamilyTagKey},
+		},
+	); err != nil {
+		panic(err)
+	}
+}
+
+// dialOutcome returns the result of a dial for its metrics.
+func dialOutcome(ctx context.Context, err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case ctx.Err() != nil:
+		return "canceled"
+	default:
+		return "error"
+	}
+}
+
+// reportDial records the result of dialing an address of the given family,
+// and its latency if it succeeded.
+func reportDial(name string, family AddressFamily, result string, latency time.Duration) {
+	ctx, err := tag.New(context.Background(),
+		tag.Insert(clientNameTagKey, name),
+		tag.Insert(addressFamilyTagKey, string(family)))
+	if err != nil {
+		// The name is not a valid tag value, record the metrics untagged
+		// rather than not at all.
+		ctx = context.Background()
+	}
+	if result == "success" {
+		metrics.Record(ctx, dialLatencyStat.M(float64(latency)/float64(time.Millisecond)))
+	}
+	if resultCtx, err := tag.New(ctx, tag.Insert(dialResultTagKey, result)); err == nil {
+		ctx = resultCtx
+	}
+	metrics.Record(ctx, dialCountStat.M(1))
+}
@@ -0,0 +1,423 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opencensus.io/stats/view"
+)
+
+var (
+	v4Addr    = net.IPAddr{IP: net.ParseIP("10.0.0.1")}
+	v4Addr2   = net.IPAddr{IP: net.ParseIP("10.0.0.2")}
+	v6Addr    = net.IPAddr{IP: net.ParseIP("fd00::1")}
+	v6Addr2   = net.IPAddr{IP: net.ParseIP("fd00::2")}
+	dualStack = []net.IPAddr{v6Addr, v4Addr, v6Addr2, v4Addr2}
+)
+
+func TestFamilyOf(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want AddressFamily
+	}{{
+		ip:   "10.0.0.1",
+		want: IPv4,
+	}, {
+		ip:   "::ffff:10.0.0.1",
+		want: IPv4,
+	}, {
+		ip:   "fd00::1",
+		want: IPv6,
+	}, {
+		ip:   "::1",
+		want: IPv6,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.ip, func(t *testing.T) {
+			if got := FamilyOf(net.ParseIP(test.ip)); got != test.want {
+				t.Errorf("FamilyOf() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPartitionAddrs(t *testing.T) {
+	tests := []struct {
+		name          string
+		addrs         []net.IPAddr
+		family        AddressFamily
+		preferred     AddressFamily
+		wantPrimaries []net.IPAddr
+		wantFallbacks []net.IPAddr
+	}{{
+		name:          "first family preferred",
+		addrs:         dualStack,
+		wantPrimaries: []net.IPAddr{v6Addr, v6Addr2},
+		wantFallbacks: []net.IPAddr{v4Addr, v4Addr2},
+	}, {
+		name:          "preferred family",
+		addrs:         dualStack,
+		preferred:     IPv4,
+		wantPrimaries: []net.IPAddr{v4Addr, v4Addr2},
+		wantFallbacks: []net.IPAddr{v6Addr, v6Addr2},
+	}, {
+		name:          "restricted family",
+		addrs:         dualStack,
+		family:        IPv4,
+		preferred:     IPv6,
+		wantPrimaries: []net.IPAddr{v4Addr, v4Addr2},
+	}, {
+		name:      "restricted to a missing family",
+		addrs:     []net.IPAddr{v4Addr},
+		family:    IPv6,
+		preferred: IPv6,
+	}, {
+		name:          "preferred family missing",
+		addrs:         []net.IPAddr{v4Addr, v4Addr2},
+		preferred:     IPv6,
+		wantPrimaries: []net.IPAddr{v4Addr, v4Addr2},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			primaries, fallbacks := partitionAddrs(test.addrs, test.family, test.preferred)
+			if diff := cmp.Diff(test.wantPrimaries, primaries); diff != "" {
+				t.Errorf("primaries (-want, +got): %s", diff)
+			}
+			if diff := cmp.Diff(test.wantFallbacks, fallbacks); diff != "" {
+				t.Errorf("fallbacks (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+// fakeConn is the connection of fakeNet to an address.
+type fakeConn struct {
+	net.Conn
+	address string
+	closed  int32
+}
+
+func (c *fakeConn) Close() error {
+	atomic.StoreInt32(&c.closed, 1)
+	return nil
+}
+
+func (c *fakeConn) isClosed() bool {
+	return atomic.LoadInt32(&c.closed) == 1
+}
+
+// fakeNet dials the addresses which are up right away, fails to dial the
+// ones which are down, and hangs dialing the other ones until canceled.
+type fakeNet struct {
+	up   map[string]bool
+	down map[string]bool
+
+	mu       sync.Mutex
+	dialed   []string
+	canceled []string
+}
+
+func (n *fakeNet) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	n.mu.Lock()
+	n.dialed = append(n.dialed, address)
+	n.mu.Unlock()
+	switch {
+	case n.up[address]:
+		return &fakeConn{address: address}, nil
+	case n.down[address]:
+		return nil, errors.New("connection refused: " + address)
+	}
+	<-ctx.Done()
+	n.mu.Lock()
+	n.canceled = append(n.canceled, address)
+	n.mu.Unlock()
+	return nil, ctx.Err()
+}
+
+func (n *fakeNet) lookup(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return dualStack, nil
+}
+
+func (n *fakeNet) dialedAddrs() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string(nil), n.dialed...)
+}
+
+func TestDialContext(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         DialOptions
+		network      string
+		address      string
+		up           []string
+		down         []string
+		want         string
+		wantDialed   []string
+		wantErr      bool
+		maxDuration  time.Duration
+		minDuration  time.Duration
+		wantCanceled bool
+	}{{
+		name:       "preferred family reachable",
+		opts:       DialOptions{FallbackDelay: time.Hour},
+		address:    "example.com:80",
+		up:         []string{"[fd00::1]:80", "10.0.0.1:80"},
+		want:       "[fd00::1]:80",
+		wantDialed: []string{"[fd00::1]:80"},
+	}, {
+		name: "IPv6 unreachable, fallback raced after the delay",
+		opts: DialOptions{FallbackDelay: 10 * time.Millisecond},
+		// The IPv6 addresses hang, e.g. black-holed.
+		address:      "example.com:80",
+		up:           []string{"10.0.0.1:80"},
+		want:         "10.0.0.1:80",
+		wantDialed:   []string{"[fd00::1]:80", "10.0.0.1:80"},
+		minDuration:  10 * time.Millisecond,
+		wantCanceled: true,
+	}, {
+		name:        "IPv6 refused, fallback without waiting for the delay",
+		opts:        DialOptions{FallbackDelay: time.Hour},
+		address:     "example.com:80",
+		up:          []string{"10.0.0.2:80"},
+		down:        []string{"[fd00::1]:80", "[fd00::2]:80", "10.0.0.1:80"},
+		want:        "10.0.0.2:80",
+		wantDialed:  []string{"[fd00::1]:80", "[fd00::2]:80", "10.0.0.1:80", "10.0.0.2:80"},
+		maxDuration: time.Minute,
+	}, {
+		name:       "preferred family",
+		opts:       DialOptions{PreferredFamily: IPv4, FallbackDelay: time.Hour},
+		address:    "example.com:80",
+		up:         []string{"[fd00::1]:80", "10.0.0.1:80"},
+		want:       "10.0.0.1:80",
+		wantDialed: []string{"10.0.0.1:80"},
+	}, {
+		name:       "IPv6-only cluster",
+		opts:       DialOptions{Family: IPv6},
+		address:    "example.com:80",
+		up:         []string{"[fd00::2]:80", "10.0.0.1:80"},
+		down:       []string{"[fd00::1]:80"},
+		want:       "[fd00::2]:80",
+		wantDialed: []string{"[fd00::1]:80", "[fd00::2]:80"},
+	}, {
+		name:       "tcp4 network",
+		network:    "tcp4",
+		address:    "example.com:80",
+		up:         []string{"[fd00::1]:80", "10.0.0.1:80"},
+		want:       "10.0.0.1:80",
+		wantDialed: []string{"10.0.0.1:80"},
+	}, {
+		name:    "network conflicting with the family",
+		opts:    DialOptions{Family: IPv6},
+		network: "tcp4",
+		address: "example.com:80",
+		wantErr: true,
+	}, {
+		name:       "IP literal",
+		opts:       DialOptions{Family: IPv6},
+		address:    "[fd00::3]:80",
+		up:         []string{"[fd00::3]:80"},
+		want:       "[fd00::3]:80",
+		wantDialed: []string{"[fd00::3]:80"},
+	}, {
+		name:    "IP literal of the wrong family",
+		opts:    DialOptions{Family: IPv6},
+		address: "10.0.0.3:80",
+		wantErr: true,
+	}, {
+		name:       "all down",
+		address:    "example.com:80",
+		down:       []string{"[fd00::1]:80", "[fd00::2]:80", "10.0.0.1:80", "10.0.0.2:80"},
+		wantDialed: []string{"[fd00::1]:80", "[fd00::2]:80", "10.0.0.1:80", "10.0.0.2:80"},
+		wantErr:    true,
+	}, {
+		name:       "families dialed in turn",
+		opts:       DialOptions{FallbackDelay: -1},
+		address:    "example.com:80",
+		up:         []string{"10.0.0.1:80"},
+		down:       []string{"[fd00::1]:80", "[fd00::2]:80"},
+		want:       "10.0.0.1:80",
+		wantDialed: []string{"[fd00::1]:80", "[fd00::2]:80", "10.0.0.1:80"},
+	}, {
+		name:       "unix socket",
+		network:    "unix",
+		address:    "/var/run/socket",
+		up:         []string{"/var/run/socket"},
+		want:       "/var/run/socket",
+		wantDialed: []string{"/var/run/socket"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n := &fakeNet{up: make(map[string]bool), down: make(map[string]bool)}
+			for _, a := range test.up {
+				n.up[a] = true
+			}
+			for _, a := range test.down {
+				n.down[a] = true
+			}
+			opts := test.opts
+			opts.Dial, opts.Lookup = n.dial, n.lookup
+			network := test.network
+			if network == "" {
+				network = "tcp"
+			}
+
+			start := time.Now()
+			conn, err := NewDialContext(opts)(context.Background(), network, test.address)
+			elapsed := time.Since(start)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("Dial() = %v, wanted an error", conn)
+				}
+			} else if err != nil {
+				t.Fatalf("Dial() = %v", err)
+			} else if got := conn.(*fakeConn).address; got != test.want {
+				t.Errorf("Dialed %s, want %s", got, test.want)
+			}
+			if test.minDuration > 0 && elapsed < test.minDuration {
+				t.Errorf("Dial() took %v, wanted at least the fallback delay of %v", elapsed, test.minDuration)
+			}
+			if test.maxDuration > 0 && elapsed > test.maxDuration {
+				t.Errorf("Dial() took %v, wanted it not to wait for the fallback delay", elapsed)
+			}
+
+			if test.wantCanceled {
+				// The losing attempt is canceled once the dial returned.
+				if err := waitFor(func() bool {
+					n.mu.Lock()
+					defer n.mu.Unlock()
+					return len(n.canceled) > 0
+				}); err != nil {
+					t.Error("The attempts racing the connection weren't canceled")
+				}
+			}
+			if test.wantDialed != nil {
+				if diff := cmp.Diff(test.wantDialed, n.dialedAddrs()); diff != "" {
+					t.Errorf("Dialed (-want, +got): %s", diff)
+				}
+			}
+		})
+	}
+}
+
+// waitFor polls the condition until it holds, or fails after a second.
+func waitFor(cond func() bool) error {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if cond() {
+			return nil
+		}
+	}
+	return errors.New("timed out")
+}
+
+func TestDialContextClosesLosingConnection(t *testing.T) {
+	// Both families connect, the fallback one right after the delay while
+	// the primary one is still dialing.
+	release := make(chan struct{})
+	primary := &fakeConn{address: "[fd00::1]:80"}
+	fallback := &fakeConn{address: "10.0.0.1:80"}
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		if address == fallback.address {
+			defer close(release)
+			return fallback, nil
+		}
+		<-release
+		return primary, nil
+	}
+	lookup := func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return []net.IPAddr{v6Addr, v4Addr}, nil
+	}
+
+	conn, err := NewDialContext(DialOptions{Dial: dial, Lookup: lookup, FallbackDelay: time.Millisecond})(context.Background(), "tcp", "example.com:80")
+	if err != nil {
+		t.Fatalf("Dial() = %v", err)
+	}
+	winner, loser := conn.(*fakeConn), primary
+	if winner == primary {
+		loser = fallback
+	}
+	if err := waitFor(loser.isClosed); err != nil {
+		t.Errorf("The connection to %s which lost the race wasn't closed", loser.address)
+	}
+	if winner.isClosed() {
+		t.Errorf("The connection to %s was closed", winner.address)
+	}
+}
+
+func TestDialContextNoAddresses(t *testing.T) {
+	lookup := func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return nil, nil
+	}
+	_, err := NewDialContext(DialOptions{Lookup: lookup})(context.Background(), "tcp", "example.com:80")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) {
+		t.Errorf("Dial() = %v, wanted a DNS error", err)
+	}
+}
+
+func TestDialMetrics(t *testing.T) {
+	count := func(family AddressFamily, result string) int64 {
+		rows, err := view.RetrieveData(dialCountStat.Name())
+		if err != nil {
+			return 0
+		}
+		var value int64
+		for _, row := range rows {
+			tags := make(map[string]string, len(row.Tags))
+			for _, tag := range row.Tags {
+				tags[tag.Key.Name()] = tag.Value
+			}
+			if tags["client_name"] == "test-dialer" && tags["address_family"] == string(family) && tags["dial_result"] == result {
+				value += row.Data.(*view.CountData).Value
+			}
+		}
+		return value
+	}
+
+	v6Errors, v4Successes := count(IPv6, "error"), count(IPv4, "success")
+	n := &fakeNet{
+		up:   map[string]bool{"10.0.0.1:80": true},
+		down: map[string]bool{"[fd00::1]:80": true, "[fd00::2]:80": true},
+	}
+	dial := NewDialContext(DialOptions{Name: "test-dialer", Dial: n.dial, Lookup: n.lookup})
+	conn, err := dial(context.Background(), "tcp", "example.com:80")
+	if err != nil {
+		t.Fatalf("Dial() = %v", err)
+	}
+	conn.Close()
+
+	if got, want := count(IPv6, "error")-v6Errors, int64(2); got != want {
+		t.Errorf("IPv6 errors = %d, want %d", got, want)
+	}
+	if got, want := count(IPv4, "success")-v4Successes, int64(1); got != want {
+		t.Errorf("IPv4 successes = %d, want %d", got, want)
+	}
+	if rows, err := view.RetrieveData(dialLatencyStat.Name()); err != nil || len(rows) == 0 {
+		t.Errorf("Latencies = %v, %v, wanted them recorded", rows, err)
+	}
+}
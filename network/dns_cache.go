@@ -0,0 +1,182 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// LookupFunc resolves the given host to its addresses, and returns for how
+// long the result may be cached. A zero TTL means the cache's default TTL.
+type LookupFunc func(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error)
+
+// DialContextFunc is the signature of net.Dialer.DialContext.
+type DialContextFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// DNSCacheOptions configures a DNSCache.
+type DNSCacheOptions struct {
+	// Lookup resolves hosts. It defaults to net.DefaultResolver, which
+	// doesn't expose the TTLs of DNS records, so TTL is used for all its
+	// results.
+	Lookup LookupFunc
+	// TTL is the time successful lookups are cached for, unless Lookup
+	// returns a TTL.
+	TTL time.Duration
+	// MaxTTL caps the TTLs returned by Lookup.
+	MaxTTL time.Duration
+	// NegativeTTL is the time failed lookups are cached for. Zero disables
+	// caching failures.
+	NegativeTTL time.Duration
+}
+
+// DefaultDNSCacheOptions returns the DNSCacheOptions used by NewDNSCache if
+// none are given.
+func DefaultDNSCacheOptions() DNSCacheOptions {
+	return DNSCacheOptions{
+		Lookup:      defaultLookup,
+		TTL:         30 * time.Second,
+		MaxTTL:      5 * time.Minute,
+		NegativeTTL: 5 * time.Second,
+	}
+}
+
+func defaultLookup(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	return addrs, 0, err
+}
+
+// DNSCache caches DNS lookups to reduce the load on the cluster's DNS
+// server. Concurrent lookups of the same host are coalesced.
+type DNSCache struct {
+	opts DNSCacheOptions
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*dnsCacheEntry
+}
+
+type dnsCacheEntry struct {
+	// ready is closed once the lookup has finished.
+	ready   chan struct{}
+	addrs   []net.IPAddr
+	err     error
+	cached  bool
+	expires time.Time
+}
+
+// NewDNSCache returns a DNSCache configured by the given options.
+func NewDNSCache(opts DNSCacheOptions) *DNSCache {
+	if opts.Lookup == nil {
+		opts.Lookup = defaultLookup
+	}
+	return &DNSCache{
+		opts:    opts,
+		now:     time.Now,
+		entries: make(map[string]*dnsCacheEntry),
+	}
+}
+
+// LookupIPAddr returns the addresses of the given host, from the cache if
+// possible.
+func (c *DNSCache) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	for {
+		c.mu.Lock()
+		e, ok := c.entries[host]
+		if ok && e.isValid(c.now()) {
+			c.mu.Unlock()
+			select {
+			case <-e.ready:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			if !e.cached {
+				// Another caller's lookup was aborted, try again.
+				continue
+			}
+			return e.addrs, e.err
+		}
+
+		e = &dnsCacheEntry{ready: make(chan struct{})}
+		c.pruneLocked()
+		c.entries[host] = e
+		c.mu.Unlock()
+
+		c.lookup(ctx, host, e)
+		return e.addrs, e.err
+	}
+}
+
+// lookup resolves the host into the given entry.
+func (c *DNSCache) lookup(ctx context.Context, host string, e *dnsCacheEntry) {
+	start := c.now()
+	addrs, ttl, err := c.opts.Lookup(ctx, host)
+	reportDNSLookup(c.now().Sub(start), err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer close(e.ready)
+	e.addrs, e.err = addrs, err
+	switch {
+	case err == nil:
+		if ttl <= 0 {
+			ttl = c.opts.TTL
+		}
+		if c.opts.MaxTTL > 0 && ttl > c.opts.MaxTTL {
+			ttl = c.opts.MaxTTL
+		}
+		e.cached, e.expires = true, c.now().Add(ttl)
+	case ctx.Err() == nil && c.opts.NegativeTTL > 0:
+		e.cached, e.expires = true, c.now().Add(c.opts.NegativeTTL)
+	default:
+		// Don't cache the failure, and in particular not the cancellation
+		// of this caller's context.
+		delete(c.entries, host)
+	}
+}
+
+// isValid returns true if the entry is being looked up or has not expired.
+func (e *dnsCacheEntry) isValid(now time.Time) bool {
+	select {
+	case <-e.ready:
+		return e.cached && now.Before(e.expires)
+	default:
+		return true
+	}
+}
+
+// pruneLocked removes all expired entries.
+func (c *DNSCache) pruneLocked() {
+	now := c.now()
+	for host, e := range c.entries {
+		if !e.isValid(now) {
+			delete(c.entries, host)
+		}
+	}
+}
+
+// DialContext wraps the given dial function to resolve hosts through the
+// cache. The resolved addresses are dialed in turn until one succeeds, racing
+// the families of dual-stack hosts, see NewDialContext.
+func (c *DNSCache) DialContext(dial DialContextFunc) DialContextFunc {
+	return NewDialContext(DialOptions{
+		Dial:   dial,
+		Lookup: c.LookupIPAddr,
+	})
+}
@@ -0,0 +1,285 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+)
+
+// fakeClock is a manually advanced clock.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Step(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// countingLookup returns a LookupFunc resolving every host to 127.0.0.1,
+// or failing if err is set, and counting its invocations.
+func countingLookup(calls *int32, ttl time.Duration, err error) LookupFunc {
+	return func(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+		atomic.AddInt32(calls, 1)
+		if err != nil {
+			return nil, 0, err
+		}
+		return []net.IPAddr{{IP: net.IPv4(127, 0, 0, 1)}}, ttl, nil
+	}
+}
+
+func newTestCache(opts DNSCacheOptions) (*DNSCache, *fakeClock) {
+	clock := &fakeClock{now: time.Now()}
+	c := NewDNSCache(opts)
+	c.now = clock.Now
+	return c, clock
+}
+
+func TestDNSCacheTTL(t *testing.T) {
+	tests := []struct {
+		name      string
+		lookupTTL time.Duration
+		wantTTL   time.Duration
+	}{{
+		name:    "default TTL",
+		wantTTL: time.Minute,
+	}, {
+		name:      "lookup TTL",
+		lookupTTL: 10 * time.Second,
+		wantTTL:   10 * time.Second,
+	}, {
+		name:      "capped TTL",
+		lookupTTL: time.Hour,
+		wantTTL:   5 * time.Minute,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls int32
+			c, clock := newTestCache(DNSCacheOptions{
+				Lookup: countingLookup(&calls, test.lookupTTL, nil),
+				TTL:    time.Minute,
+				MaxTTL: 5 * time.Minute,
+			})
+
+			for i := 0; i < 3; i++ {
+				if _, err := c.LookupIPAddr(context.Background(), "example.com"); err != nil {
+					t.Fatal("LookupIPAddr() =", err)
+				}
+			}
+			if calls != 1 {
+				t.Errorf("Lookups = %d, want 1", calls)
+			}
+
+			clock.Step(test.wantTTL - time.Nanosecond)
+			c.LookupIPAddr(context.Background(), "example.com")
+			if calls != 1 {
+				t.Errorf("Lookups before expiry = %d, want 1", calls)
+			}
+
+			clock.Step(time.Nanosecond)
+			c.LookupIPAddr(context.Background(), "example.com")
+			if calls != 2 {
+				t.Errorf("Lookups after expiry = %d, want 2", calls)
+			}
+		})
+	}
+}
+
+func TestDNSCacheNegativeTTL(t *testing.T) {
+	lookupErr := &net.DNSError{Err: "no such host", Name: "example.com"}
+	tests := []struct {
+		name        string
+		negativeTTL time.Duration
+		wantCalls   int32
+	}{{
+		name:        "failures cached",
+		negativeTTL: time.Second,
+		wantCalls:   1,
+	}, {
+		name:      "failures not cached",
+		wantCalls: 3,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls int32
+			c, clock := newTestCache(DNSCacheOptions{
+				Lookup:      countingLookup(&calls, 0, lookupErr),
+				TTL:         time.Minute,
+				NegativeTTL: test.negativeTTL,
+			})
+
+			for i := 0; i < 3; i++ {
+				if _, err := c.LookupIPAddr(context.Background(), "example.com"); err != lookupErr {
+					t.Fatalf("LookupIPAddr() = %v, want %v", err, lookupErr)
+				}
+			}
+			if calls != test.wantCalls {
+				t.Errorf("Lookups = %d, want %d", calls, test.wantCalls)
+			}
+
+			clock.Step(time.Second)
+			c.LookupIPAddr(context.Background(), "example.com")
+			if calls != test.wantCalls+1 {
+				t.Errorf("Lookups after expiry = %d, want %d", calls, test.wantCalls+1)
+			}
+		})
+	}
+}
+
+func TestDNSCacheCoalescesLookups(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	c, _ := newTestCache(DNSCacheOptions{
+		Lookup: func(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return []net.IPAddr{{IP: net.IPv4(127, 0, 0, 1)}}, 0, nil
+		},
+		TTL: time.Minute,
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.LookupIPAddr(context.Background(), "example.com"); err != nil {
+				t.Error("LookupIPAddr() =", err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("Lookups = %d, want 1", calls)
+	}
+}
+
+func TestDNSCacheDoesNotCacheCancellation(t *testing.T) {
+	var calls int32
+	c, _ := newTestCache(DNSCacheOptions{
+		Lookup: func(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+			atomic.AddInt32(&calls, 1)
+			if err := ctx.Err(); err != nil {
+				return nil, 0, err
+			}
+			return []net.IPAddr{{IP: net.IPv4(127, 0, 0, 1)}}, 0, nil
+		},
+		TTL:         time.Minute,
+		NegativeTTL: time.Minute,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.LookupIPAddr(ctx, "example.com"); err == nil {
+		t.Error("LookupIPAddr() = nil, wanted an error")
+	}
+	if _, err := c.LookupIPAddr(context.Background(), "example.com"); err != nil {
+		t.Error("LookupIPAddr() =", err)
+	}
+	if calls != 2 {
+		t.Errorf("Lookups = %d, want 2", calls)
+	}
+}
+
+func TestDNSCacheDialContext(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Listen() =", err)
+	}
+	defer l.Close()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+
+	var calls int32
+	c, _ := newTestCache(DNSCacheOptions{
+		Lookup: func(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+			atomic.AddInt32(&calls, 1)
+			// The first address isn't listening.
+			return []net.IPAddr{{IP: net.IPv4(127, 0, 0, 2)}, {IP: net.IPv4(127, 0, 0, 1)}}, 0, nil
+		},
+		TTL: time.Minute,
+	})
+	var dialed []string
+	dial := c.DialContext(func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = append(dialed, address)
+		if address != l.Addr().String() {
+			return nil, errors.New("connection refused")
+		}
+		return (&net.Dialer{}).DialContext(ctx, network, address)
+	})
+
+	conn, err := dial(context.Background(), "tcp", net.JoinHostPort("example.com", port))
+	if err != nil {
+		t.Fatal("Dial() =", err)
+	}
+	conn.Close()
+	if got, want := len(dialed), 2; got != want {
+		t.Errorf("Dialed %v, want %d addresses", dialed, want)
+	}
+
+	// IPs are dialed directly.
+	conn, err = dial(context.Background(), "tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal("Dial() =", err)
+	}
+	conn.Close()
+	if calls != 1 {
+		t.Errorf("Lookups = %d, want 1", calls)
+	}
+}
+
+func TestDNSLookupMetrics(t *testing.T) {
+	failures := func() int64 {
+		rows, err := view.RetrieveData(dnsLookupFailuresStat.Name())
+		if err != nil || len(rows) == 0 {
+			return 0
+		}
+		return rows[0].Data.(*view.CountData).Value
+	}
+
+	before := failures()
+	c, _ := newTestCache(DNSCacheOptions{
+		Lookup: countingLookup(new(int32), 0, errors.New("boom")),
+	})
+	c.LookupIPAddr(context.Background(), "example.com")
+	if got := failures() - before; got != 1 {
+		t.Errorf("Recorded %d failures, want 1", got)
+	}
+	if rows, err := view.RetrieveData(dnsLookupLatencyStat.Name()); err != nil || len(rows) == 0 {
+		t.Errorf("No lookup latencies recorded: %v", err)
+	}
+}
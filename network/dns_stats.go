@@ -0,0 +1,63 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"context"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"knative.dev/pkg/metrics"
+)
+
+var (
+	dnsLookupLatencyStat = stats.Float64(
+		"dns_lookup_latencies",
+		"The time in milliseconds DNS lookups not served from the cache took",
+		stats.UnitMilliseconds)
+	dnsLookupFailuresStat = stats.Int64(
+		"dns_lookup_failures",
+		"Number of failed DNS lookups not served from the cache",
+		stats.UnitDimensionless)
+)
+
+func init() {
+	if err := view.Register(
+		&view.View{
+			Description: dnsLookupLatencyStat.Description(),
+			Measure:     dnsLookupLatencyStat,
+			Aggregation: view.Distribution(metrics.Buckets125(1, 10000)...), // [1 2 5 10 20 50 100 200 500 1000 2000 5000 10000]ms
+		},
+		&view.View{
+			Description: dnsLookupFailuresStat.Description(),
+			Measure:     dnsLookupFailuresStat,
+			Aggregation: view.Count(),
+		},
+	); err != nil {
+		panic(err)
+	}
+}
+
+// reportDNSLookup records the latency and outcome of a DNS lookup.
+func reportDNSLookup(latency time.Duration, err error) {
+	ctx := context.Background()
+	metrics.Record(ctx, dnsLookupLatencyStat.M(float64(latency)/float64(time.Millisecond)))
+	if err != nil {
+		metrics.Record(ctx, dnsLookupFailuresStat.M(1))
+	}
+}
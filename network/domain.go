@@ -23,38 +23,120 @@ import (
 	"os"
 	"strings"
 	"sync"
+
+	corev1 "k8s.io/api/core/v1"
 )
 
 const (
 	resolverFileName  = "/etc/resolv.conf"
 	defaultDomainName = "cluster.local"
+
+	// ClusterDomainKey is the ConfigMap key overriding the detected
+	// cluster domain name.
+	ClusterDomainKey = "cluster-domain"
 )
 
 var (
-	domainName string
-	once       sync.Once
+	defaultResolver *DomainResolver
+	once            sync.Once
 )
 
 // GetServiceHostname returns the fully qualified service hostname
 func GetServiceHostname(name string, namespace string) string {
-	return fmt.Sprintf("%s.%s.svc.%s", name, namespace, GetClusterDomainName())
+	return DefaultDomainResolver().ServiceHostname(name, namespace)
 }
 
 // GetClusterDomainName returns cluster's domain name or an error
 // Closes issue: https://github.com/knative/eventing/issues/714
 func GetClusterDomainName() string {
-	once.Do(func() {
-		f, err := os.Open(resolverFileName)
-		if err == nil {
-			defer f.Close()
-			domainName = getClusterDomainName(f)
+	return DefaultDomainResolver().Domain()
+}
 
-		} else {
-			domainName = defaultDomainName
-		}
+// DefaultDomainResolver returns the process-wide DomainResolver, which
+// detects the cluster domain name from /etc/resolv.conf on first use.
+func DefaultDomainResolver() *DomainResolver {
+	once.Do(func() {
+		defaultResolver = NewDomainResolver(detectClusterDomainName(resolverFileName))
 	})
+	return defaultResolver
+}
+
+// DomainResolver determines the cluster domain name. The detected name
+// can be overridden through a ConfigMap, see UpdateFromConfigMap.
+type DomainResolver struct {
+	mu       sync.RWMutex
+	detected string
+	override string
+	handlers []func(domain string)
+}
+
+// NewDomainResolver returns a DomainResolver using the given detected
+// domain name unless it is overridden.
+func NewDomainResolver(detected string) *DomainResolver {
+	return &DomainResolver{detected: detected}
+}
+
+// Domain returns the cluster domain name.
+func (r *DomainResolver) Domain() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.domain()
+}
+
+func (r *DomainResolver) domain() string {
+	if r.override != "" {
+		return r.override
+	}
+	return r.detected
+}
+
+// ServiceHostname returns the fully qualified hostname of the given service.
+func (r *DomainResolver) ServiceHostname(name, namespace string) string {
+	return fmt.Sprintf("%s.%s.svc.%s", name, namespace, r.Domain())
+}
+
+// OnChange registers a handler that is called with the new domain name
+// whenever it changes.
+func (r *DomainResolver) OnChange(handler func(domain string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers = append(r.handlers, handler)
+}
+
+// UpdateFromConfigMap overrides the detected domain name with the value of
+// the ClusterDomainKey in the given ConfigMap. If the key is missing or
+// empty, the detected domain name is used again. It can be registered as
+// a configmap.Observer.
+func (r *DomainResolver) UpdateFromConfigMap(cm *corev1.ConfigMap) {
+	var override string
+	if cm != nil {
+		override = strings.TrimSuffix(strings.TrimSpace(cm.Data[ClusterDomainKey]), ".")
+	}
+
+	r.mu.Lock()
+	before := r.domain()
+	r.override = override
+	after := r.domain()
+	handlers := r.handlers
+	r.mu.Unlock()
 
-	return domainName
+	if before == after {
+		return
+	}
+	for _, h := range handlers {
+		h(after)
+	}
+}
+
+// detectClusterDomainName reads the cluster domain name from the given
+// resolv.conf file, falling back to the default domain name.
+func detectClusterDomainName(fileName string) string {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return defaultDomainName
+	}
+	defer f.Close()
+	return getClusterDomainName(f)
 }
 
 func getClusterDomainName(r io.Reader) string {
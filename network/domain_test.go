@@ -17,8 +17,13 @@ limitations under the License.
 package network
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	corev1 "k8s.io/api/core/v1"
 )
 
 func TestGetDomainName(t *testing.T) {
@@ -70,3 +75,69 @@ options ndots:5
 		}
 	}
 }
+
+func TestDetectClusterDomainName(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resolv")
+	if err != nil {
+		t.Fatal("TempDir() =", err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "resolv.conf")
+	if err := ioutil.WriteFile(fileName, []byte("search default.svc.abc.com svc.abc.com abc.com\n"), 0644); err != nil {
+		t.Fatal("WriteFile() =", err)
+	}
+	if got, want := detectClusterDomainName(fileName), "abc.com"; got != want {
+		t.Errorf("detectClusterDomainName() = %s, want %s", got, want)
+	}
+	if got, want := detectClusterDomainName(filepath.Join(dir, "missing")), defaultDomainName; got != want {
+		t.Errorf("detectClusterDomainName(missing) = %s, want %s", got, want)
+	}
+}
+
+func TestDomainResolver(t *testing.T) {
+	r := NewDomainResolver("abc.com")
+	var changes []string
+	r.OnChange(func(domain string) {
+		changes = append(changes, domain)
+	})
+
+	if got, want := r.ServiceHostname("foo", "bar"), "foo.bar.svc.abc.com"; got != want {
+		t.Errorf("ServiceHostname() = %s, want %s", got, want)
+	}
+
+	steps := []struct {
+		name string
+		cm   *corev1.ConfigMap
+		want string
+	}{{
+		name: "unrelated configmap",
+		cm:   &corev1.ConfigMap{Data: map[string]string{"foo": "bar"}},
+		want: "abc.com",
+	}, {
+		name: "override",
+		cm:   &corev1.ConfigMap{Data: map[string]string{ClusterDomainKey: "xyz.com."}},
+		want: "xyz.com",
+	}, {
+		name: "same override",
+		cm:   &corev1.ConfigMap{Data: map[string]string{ClusterDomainKey: "xyz.com"}},
+		want: "xyz.com",
+	}, {
+		name: "override removed",
+		cm:   &corev1.ConfigMap{},
+		want: "abc.com",
+	}, {
+		name: "nil configmap",
+		want: "abc.com",
+	}}
+	for _, step := range steps {
+		r.UpdateFromConfigMap(step.cm)
+		if got := r.Domain(); got != step.want {
+			t.Errorf("%s: Domain() = %s, want %s", step.name, got, step.want)
+		}
+	}
+
+	if got, want := strings.Join(changes, ","), "xyz.com,abc.com"; got != want {
+		t.Errorf("Changes = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"syscall"
+
+	"golang.org/x/net/http2"
+)
+
+// The helpers below classify errors returned by dialers, transports and
+// connections. They unwrap errors with errors.As and errors.Is, so they
+// work on errors wrapped by url.Error, net.OpError or fmt.Errorf's %w.
+
+// IsDialError returns true if the error occurred while establishing the
+// connection, in which case the request never reached the server.
+func IsDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// IsTimeout returns true if the error is caused by a timeout, including
+// an expired context deadline.
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// IsDNSError returns true if the error is caused by a failed DNS lookup.
+func IsDNSError(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+// IsConnectionRefused returns true if the connection was refused by the
+// remote end.
+func IsConnectionRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// IsConnectionReset returns true if the connection was reset by the
+// remote end.
+func IsConnectionReset(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET)
+}
+
+// IsTLSHandshakeError returns true if the error is caused by a failed TLS
+// handshake, either because the peer's certificate could not be verified
+// or because a TLS alert was sent or received.
+func IsTLSHandshakeError(err error) bool {
+	var (
+		unknownAuthorityErr x509.UnknownAuthorityError
+		hostnameErr         x509.HostnameError
+		invalidCertErr      x509.CertificateInvalidError
+		recordHeaderErr     tls.RecordHeaderError
+		opErr               *net.OpError
+	)
+	switch {
+	case errors.As(err, &unknownAuthorityErr),
+		errors.As(err, &hostnameErr),
+		errors.As(err, &invalidCertErr),
+		errors.As(err, &recordHeaderErr):
+		return true
+	case errors.As(err, &opErr):
+		// TLS alerts are wrapped in these operations by crypto/tls.
+		return opErr.Op == "remote error" || opErr.Op == "local error"
+	default:
+		return false
+	}
+}
+
+// IsHTTP2GoAway returns true if the server sent an HTTP/2 GOAWAY frame,
+// i.e. it is shutting down the connection. Only errors returned by
+// golang.org/x/net/http2 are recognized.
+func IsHTTP2GoAway(err error) bool {
+	var goAwayErr http2.GoAwayError
+	return errors.As(err, &goAwayErr)
+}
+
+// IsHTTP2StreamReset returns true if the HTTP/2 stream was reset by either
+// end. Only errors returned by golang.org/x/net/http2 are recognized.
+func IsHTTP2StreamReset(err error) bool {
+	var streamErr http2.StreamError
+	return errors.As(err, &streamErr)
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+
+	"golang.org/x/net/http2"
+)
+
+// wrap wraps the error the way net/http and net do.
+func wrap(err error) error {
+	return &url.Error{
+		Op:  "Get",
+		URL: "http://example.com",
+		Err: &net.OpError{Op: "read", Net: "tcp", Err: err},
+	}
+}
+
+func TestErrorChecks(t *testing.T) {
+	checks := map[string]func(error) bool{
+		"IsDialError":         IsDialError,
+		"IsTimeout":           IsTimeout,
+		"IsDNSError":          IsDNSError,
+		"IsConnectionRefused": IsConnectionRefused,
+		"IsConnectionReset":   IsConnectionReset,
+		"IsTLSHandshakeError": IsTLSHandshakeError,
+		"IsHTTP2GoAway":       IsHTTP2GoAway,
+		"IsHTTP2StreamReset":  IsHTTP2StreamReset,
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want []string
+	}{{
+		name: "nil",
+	}, {
+		name: "unrelated error",
+		err:  errors.New("connection reset by peer"),
+	}, {
+		name: "dial error",
+		err:  &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)},
+		want: []string{"IsDialError", "IsConnectionRefused"},
+	}, {
+		name: "deadline exceeded",
+		err:  fmt.Errorf("probing: %w", context.DeadlineExceeded),
+		want: []string{"IsTimeout"},
+	}, {
+		name: "i/o timeout",
+		err:  wrap(&net.DNSError{Err: "i/o timeout", IsTimeout: true}),
+		want: []string{"IsTimeout", "IsDNSError"},
+	}, {
+		name: "dns error",
+		err:  wrap(&net.DNSError{Err: "no such host", Name: "example.com"}),
+		want: []string{"IsDNSError"},
+	}, {
+		name: "connection reset",
+		err:  wrap(os.NewSyscallError("read", syscall.ECONNRESET)),
+		want: []string{"IsConnectionReset"},
+	}, {
+		name: "unknown authority",
+		err:  wrap(x509.UnknownAuthorityError{}),
+		want: []string{"IsTLSHandshakeError"},
+	}, {
+		name: "hostname mismatch",
+		err:  wrap(x509.HostnameError{Host: "example.com", Certificate: &x509.Certificate{}}),
+		want: []string{"IsTLSHandshakeError"},
+	}, {
+		name: "tls record header",
+		err:  wrap(tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}),
+		want: []string{"IsTLSHandshakeError"},
+	}, {
+		name: "tls alert",
+		err:  &net.OpError{Op: "remote error", Err: errors.New("tls: bad certificate")},
+		want: []string{"IsTLSHandshakeError"},
+	}, {
+		name: "goaway",
+		err:  wrap(http2.GoAwayError{ErrCode: http2.ErrCodeNo}),
+		want: []string{"IsHTTP2GoAway"},
+	}, {
+		name: "stream reset",
+		err:  fmt.Errorf("reading body: %w", http2.StreamError{StreamID: 1, Code: http2.ErrCodeCancel}),
+		want: []string{"IsHTTP2StreamReset"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			want := make(map[string]bool, len(test.want))
+			for _, name := range test.want {
+				want[name] = true
+			}
+			for name, check := range checks {
+				if got := check(test.err); got != want[name] {
+					t.Errorf("%s(%v) = %v, want %v", name, test.err, got, want[name])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// drainPollInterval is the interval in which a draining server checks for
+// in-flight requests to be finished.
+const drainPollInterval = 50 * time.Millisecond
+
+// ServerOptions configures a Server.
+type ServerOptions struct {
+	// ReadHeaderTimeout is the time allowed to read the request headers.
+	ReadHeaderTimeout time.Duration
+	// ReadTimeout and WriteTimeout bound the time to read a whole request
+	// and to write its response. Both are disabled by default as they
+	// break long-running and streaming requests.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// IdleTimeout is the time a keep-alive connection is kept open without
+	// any requests.
+	IdleTimeout time.Duration
+
+	// MaxConcurrentStreams is the maximum number of concurrent HTTP/2
+	// streams per connection.
+	MaxConcurrentStreams uint32
+	// MaxConnections is the maximum number of simultaneously accepted
+	// connections. Zero means no limit.
+	MaxConnections int
+
+	// DrainTimeout is the time in-flight requests are given to finish
+	// when the server is shut down, before connections are forcibly closed.
+	DrainTimeout time.Duration
+
+	// ProxyProtocol defines whether connections may or must start with a
+	// PROXY protocol header carrying the client's address. The header must
+	// be received within the ReadHeaderTimeout.
+	ProxyProtocol ProxyProtocolPolicy
+}
+
+// DefaultServerOptions returns the ServerOptions used by NewServer.
+func DefaultServerOptions() ServerOptions {
+	return ServerOptions{
+		ReadHeaderTimeout:    10 * time.Second,
+		IdleTimeout:          2 * time.Minute,
+		MaxConcurrentStreams: 250,
+		DrainTimeout:         30 * time.Second,
+	}
+}
+
+// Server is an HTTP server that accepts both HTTP/1 and cleartext HTTP/2
+// (h2c) requests and drains gracefully when shut down.
+type Server struct {
+	server *http.Server
+	opts   ServerOptions
+
+	// inflight counts the requests being served. HTTP/2 connections are
+	// hijacked by the h2c handler, so http.Server.Shutdown doesn't wait
+	// for them.
+	inflight int64
+}
+
+// withDefaults returns the options with the unset fields defaulted.
+func (o ServerOptions) withDefaults() ServerOptions {
+	d := DefaultServerOptions()
+	if o.ReadHeaderTimeout == 0 {
+		o.ReadHeaderTimeout = d.ReadHeaderTimeout
+	}
+	if o.IdleTimeout == 0 {
+		o.IdleTimeout = d.IdleTimeout
+	}
+	if o.MaxConcurrentStreams == 0 {
+		o.MaxConcurrentStreams = d.MaxConcurrentStreams
+	}
+	if o.DrainTimeout == 0 {
+		o.DrainTimeout = d.DrainTimeout
+	}
+	return o
+}
+
+// NewServer returns a Server listening on addr with the DefaultServerOptions.
+func NewServer(addr string, h http.Handler) (*Server, error) {
+	return NewServerWithOptions(addr, h, DefaultServerOptions())
+}
+
+// NewServerWithOptions returns a Server listening on addr, configured by opts.
+// The unset fields of opts are defaulted from DefaultServerOptions.
+func NewServerWithOptions(addr string, h http.Handler, opts ServerOptions) (*Server, error) {
+	opts = opts.withDefaults()
+	s := &Server{opts: opts}
+	counted := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&s.inflight, 1)
+		defer atomic.AddInt64(&s.inflight, -1)
+		h.ServeHTTP(w, r)
+	})
+
+	h2s := &http2.Server{
+		MaxConcurrentStreams: opts.MaxConcurrentStreams,
+		IdleTimeout:          opts.IdleTimeout,
+	}
+	s.server = &http.Server{
+		Addr:              addr,
+		Handler:           h2c.NewHandler(counted, h2s),
+		ReadHeaderTimeout: opts.ReadHeaderTimeout,
+		ReadTimeout:       opts.ReadTimeout,
+		WriteTimeout:      opts.WriteTimeout,
+		IdleTimeout:       opts.IdleTimeout,
+	}
+	// Makes http.Server.Shutdown send GOAWAY frames to HTTP/2 connections.
+	if err := http2.ConfigureServer(s.server, h2s); err != nil {
+		return nil, fmt.Errorf("failed to configure HTTP/2: %v", err)
+	}
+	return s, nil
+}
+
+// HTTPServer returns the underlying http.Server, for further customization
+// before the server is started.
+func (s *Server) HTTPServer() *http.Server {
+	return s.server
+}
+
+// ListenAndServe listens on the server's address and serves requests until
+// the context is done, at which point the server is shut down gracefully.
+// It returns nil if the server has been shut down.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	addr := s.server.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, l)
+}
+
+// Serve is like ListenAndServe for the given listener.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	if s.opts.MaxConnections > 0 {
+		l = newLimitListener(l, s.opts.MaxConnections)
+	}
+	l = NewProxyProtocolListener(l, s.opts.ProxyProtocol, s.opts.ReadHeaderTimeout)
+
+	shutdownErr := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			shutdownErr <- s.shutdown()
+		case <-done:
+		}
+	}()
+
+	if err := s.server.Serve(l); err != http.ErrServerClosed {
+		return err
+	}
+	if ctx.Err() == nil {
+		// The server has been shut down through HTTPServer.
+		return nil
+	}
+	return <-shutdownErr
+}
+
+// shutdown drains the server, closing all connections forcibly if draining
+// takes longer than the drain timeout.
+func (s *Server) shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.DrainTimeout)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.server.Close()
+		return err
+	}
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for atomic.LoadInt64(&s.inflight) > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// limitListener limits the number of simultaneously accepted connections.
+type limitListener struct {
+	net.Listener
+	sem       chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func newLimitListener(l net.Listener, n int) net.Listener {
+	return &limitListener{
+		Listener: l,
+		sem:      make(chan struct{}, n),
+		done:     make(chan struct{}),
+	}
+}
+
+// Accept blocks until a connection slot is free before accepting.
+func (l *limitListener) Accept() (net.Conn, error) {
+	select {
+	case l.sem <- struct{}{}:
+	case <-l.done:
+		return nil, http.ErrServerClosed
+	}
+	c, err := l.Listener.Accept()
+	if err != nil {
+		<-l.sem
+		return nil, err
+	}
+	return &limitListenerConn{Conn: c, release: func() { <-l.sem }}, nil
+}
+
+// Close closes the listener, unblocking pending Accept calls.
+func (l *limitListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+// limitListenerConn frees its slot when closed.
+type limitListenerConn struct {
+	net.Conn
+	releaseOnce sync.Once
+	release     func()
+}
+
+func (c *limitListenerConn) Close() error {
+	err := c.Conn.Close()
+	c.releaseOnce.Do(c.release)
+	return err
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/net/http2"
+)
+
+// h2cClient returns a client speaking cleartext HTTP/2.
+func h2cClient() *http.Client {
+	return &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(netw, addr string, _ *tls.Config) (net.Conn, error) {
+				return net.Dial(netw, addr)
+			},
+		},
+	}
+}
+
+func startServer(t *testing.T, s *Server) (string, context.CancelFunc, chan error) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Serve(ctx, l)
+	}()
+	return "http://" + l.Addr().String(), cancel, errCh
+}
+
+func TestServerServesH2C(t *testing.T) {
+	s, err := NewServer("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proto", r.Proto)
+	}))
+	if err != nil {
+		t.Fatal("NewServer() =", err)
+	}
+	url, cancel, errCh := startServer(t, s)
+	defer cancel()
+
+	for _, client := range []*http.Client{http.DefaultClient, h2cClient()} {
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatalf("Get() = %v", err)
+		}
+		resp.Body.Close()
+		if got, want := resp.Header.Get("X-Proto"), resp.Proto; got != want {
+			t.Errorf("Server saw protocol %q, client used %q", got, want)
+		}
+	}
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Errorf("Serve() = %v", err)
+	}
+}
+
+func TestServerDrainsGracefully(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s, err := NewServer("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	if err != nil {
+		t.Fatal("NewServer() =", err)
+	}
+	url, cancel, errCh := startServer(t, s)
+	defer cancel()
+
+	respCh := make(chan error, 1)
+	go func() {
+		resp, err := h2cClient().Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+		respCh <- err
+	}()
+	<-started
+
+	cancel()
+	select {
+	case err := <-errCh:
+		t.Fatalf("Serve() = %v returned before in-flight requests finished", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-respCh; err != nil {
+		t.Errorf("In-flight request failed: %v", err)
+	}
+	if err := <-errCh; err != nil {
+		t.Errorf("Serve() = %v", err)
+	}
+}
+
+func TestServerPartialOptions(t *testing.T) {
+	s, err := NewServerWithOptions("", http.NotFoundHandler(), ServerOptions{
+		IdleTimeout:  time.Minute,
+		WriteTimeout: time.Second,
+	})
+	if err != nil {
+		t.Fatal("NewServerWithOptions() =", err)
+	}
+	want := DefaultServerOptions()
+	want.IdleTimeout = time.Minute
+	want.WriteTimeout = time.Second
+	if diff := cmp.Diff(want, s.opts); diff != "" {
+		t.Errorf("Options (-want, +got): %s", diff)
+	}
+	if got, want := s.HTTPServer().ReadHeaderTimeout, want.ReadHeaderTimeout; got != want {
+		t.Errorf("ReadHeaderTimeout = %v, want %v", got, want)
+	}
+}
+
+func TestServerDrainTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	opts := DefaultServerOptions()
+	opts.DrainTimeout = 50 * time.Millisecond
+	s, err := NewServerWithOptions("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}), opts)
+	if err != nil {
+		t.Fatal("NewServerWithOptions() =", err)
+	}
+	url, cancel, errCh := startServer(t, s)
+	defer cancel()
+
+	go h2cClient().Get(url)
+	<-started
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Error("Serve() = nil, wanted the drain timeout to be reported")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the server to shut down")
+	}
+}
+
+func TestLimitListener(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() = %v", err)
+	}
+	l := newLimitListener(inner, 1)
+	defer l.Close()
+
+	for i := 0; i < 2; i++ {
+		c, err := net.Dial("tcp", inner.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial() = %v", err)
+		}
+		defer c.Close()
+	}
+
+	first, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Accept() = %v", err)
+	}
+
+	accepted := make(chan net.Conn)
+	go func() {
+		c, err := l.Accept()
+		if err == nil {
+			accepted <- c
+		}
+	}()
+	select {
+	case <-accepted:
+		t.Fatal("Accepted a connection beyond the limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	first.Close()
+	select {
+	case c := <-accepted:
+		c.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the second connection to be accepted")
+	}
+}
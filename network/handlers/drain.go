@@ -0,0 +1,170 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"knative.dev/pkg/network"
+)
+
+const (
+	// DefaultQuietPeriod is the default time that has to pass without any
+	// requests before draining completes. It leaves the endpoint enough
+	// time to be removed from all load balancers.
+	DefaultQuietPeriod = 45 * time.Second
+
+	// DefaultRetryAfter is the default delay clients are asked to wait for
+	// before retrying requests rejected while draining.
+	DefaultRetryAfter = time.Second
+)
+
+// Drainer wraps an http.Handler to support draining before shutting down.
+// It answers health probes until drained, failing them once draining
+// starts so the endpoint is taken out of rotation, and it keeps draining
+// until no requests have been received for the quiet period.
+//
+// Health probes are the kubelet's probes and requests to ProbePath, if
+// set. All other requests are passed to Inner.
+type Drainer struct {
+	// Inner is the handler serving all requests but health probes.
+	Inner http.Handler
+
+	// HealthCheck optionally answers health probes while not draining.
+	// If nil, they're answered with http.StatusOK.
+	HealthCheck http.HandlerFunc
+
+	// ProbePath, if set, is the path of health probes, in addition to the
+	// kubelet's probes.
+	ProbePath string
+
+	// QuietPeriod is the time that has to pass without any requests after
+	// draining started before it completes. Defaults to DefaultQuietPeriod.
+	QuietPeriod time.Duration
+
+	// RejectWhileDraining makes the Drainer answer requests received while
+	// draining with http.StatusServiceUnavailable and a Retry-After header,
+	// instead of serving them, so clients retry them on another endpoint.
+	RejectWhileDraining bool
+
+	// RetryAfter is the delay set in the Retry-After header of rejected
+	// requests. Defaults to DefaultRetryAfter.
+	RetryAfter time.Duration
+
+	// Drained, if set, is closed once draining completes.
+	Drained chan struct{}
+
+	mu sync.Mutex
+	// timer fires once the quiet period elapsed. It's reset by every
+	// request received while draining.
+	timer *time.Timer
+	// done is closed when draining completes. It's nil until draining starts.
+	done chan struct{}
+}
+
+var _ http.Handler = (*Drainer)(nil)
+
+// ServeHTTP implements http.Handler.
+func (d *Drainer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	draining := d.draining()
+
+	if d.isHealthProbe(r) {
+		switch {
+		case draining:
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		case d.HealthCheck != nil:
+			d.HealthCheck(w, r)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+		return
+	}
+
+	if draining {
+		d.reset()
+		if d.RejectWhileDraining {
+			w.Header().Set("Retry-After", retryAfterSeconds(d.RetryAfter))
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	d.Inner.ServeHTTP(w, r)
+}
+
+// Drain starts draining and blocks until the quiet period elapsed without
+// any requests being received. It may be called multiple times, all calls
+// return once draining completes.
+func (d *Drainer) Drain() {
+	d.mu.Lock()
+	if d.done == nil {
+		d.done = make(chan struct{})
+		d.timer = time.AfterFunc(d.quietPeriod(), d.complete)
+	}
+	done := d.done
+	d.mu.Unlock()
+
+	<-done
+}
+
+// draining returns true once Drain has been called.
+func (d *Drainer) draining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.done != nil
+}
+
+// reset restarts the quiet period, unless draining already completed.
+func (d *Drainer) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer.Stop() {
+		d.timer.Reset(d.quietPeriod())
+	}
+}
+
+// complete marks draining as completed.
+func (d *Drainer) complete() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	close(d.done)
+	if d.Drained != nil {
+		close(d.Drained)
+	}
+}
+
+func (d *Drainer) isHealthProbe(r *http.Request) bool {
+	return network.IsKubeletProbe(r) || (d.ProbePath != "" && r.URL.Path == d.ProbePath)
+}
+
+func (d *Drainer) quietPeriod() time.Duration {
+	if d.QuietPeriod > 0 {
+		return d.QuietPeriod
+	}
+	return DefaultQuietPeriod
+}
+
+// retryAfterSeconds formats the delay as a Retry-After value, in whole
+// seconds rounded up.
+func retryAfterSeconds(d time.Duration) string {
+	if d <= 0 {
+		d = DefaultRetryAfter
+	}
+	return strconv.Itoa(int((d + time.Second - 1) / time.Second))
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"knative.dev/pkg/network"
+)
+
+var teapot = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusTeapot)
+})
+
+func kubeletProbe() *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("User-Agent", network.KubeProbeUAPrefix+"1.15")
+	return r
+}
+
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestDrainerProbes(t *testing.T) {
+	d := &Drainer{
+		Inner:       teapot,
+		ProbePath:   "/healthz",
+		QuietPeriod: 100 * time.Millisecond,
+		HealthCheck: func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+		},
+	}
+
+	if got, want := serve(d, kubeletProbe()).Code, http.StatusAccepted; got != want {
+		t.Errorf("Kubelet probe status = %d, want %d", got, want)
+	}
+	if got, want := serve(d, httptest.NewRequest(http.MethodGet, "/healthz", nil)).Code, http.StatusAccepted; got != want {
+		t.Errorf("Probe path status = %d, want %d", got, want)
+	}
+	if got, want := serve(d, httptest.NewRequest(http.MethodGet, "/", nil)).Code, http.StatusTeapot; got != want {
+		t.Errorf("Request status = %d, want %d", got, want)
+	}
+
+	go d.Drain()
+	waitForDraining(t, d)
+
+	if got, want := serve(d, kubeletProbe()).Code, http.StatusServiceUnavailable; got != want {
+		t.Errorf("Kubelet probe status while draining = %d, want %d", got, want)
+	}
+	if got, want := serve(d, httptest.NewRequest(http.MethodGet, "/healthz", nil)).Code, http.StatusServiceUnavailable; got != want {
+		t.Errorf("Probe path status while draining = %d, want %d", got, want)
+	}
+	// Requests are still served by default.
+	if got, want := serve(d, httptest.NewRequest(http.MethodGet, "/", nil)).Code, http.StatusTeapot; got != want {
+		t.Errorf("Request status while draining = %d, want %d", got, want)
+	}
+}
+
+func TestDrainerRejectsWhileDraining(t *testing.T) {
+	d := &Drainer{
+		Inner:               teapot,
+		QuietPeriod:         100 * time.Millisecond,
+		RejectWhileDraining: true,
+		RetryAfter:          1500 * time.Millisecond,
+	}
+
+	go d.Drain()
+	waitForDraining(t, d)
+
+	w := serve(d, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got, want := w.Code, http.StatusServiceUnavailable; got != want {
+		t.Errorf("Status = %d, want %d", got, want)
+	}
+	if got, want := w.Header().Get("Retry-After"), "2"; got != want {
+		t.Errorf("Retry-After = %q, want %q", got, want)
+	}
+}
+
+func TestDrainerQuietPeriod(t *testing.T) {
+	const quietPeriod = 200 * time.Millisecond
+	drained := make(chan struct{})
+	d := &Drainer{
+		Inner:       teapot,
+		QuietPeriod: quietPeriod,
+		Drained:     drained,
+	}
+
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		d.Drain()
+		close(done)
+	}()
+	waitForDraining(t, d)
+
+	// Requests received while draining extend the quiet period.
+	var last time.Time
+	for i := 0; i < 3; i++ {
+		time.Sleep(quietPeriod / 2)
+		serve(d, httptest.NewRequest(http.MethodGet, "/", nil))
+		last = time.Now()
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Drain() didn't return")
+	}
+	if elapsed := time.Since(last); elapsed < quietPeriod {
+		t.Errorf("Drain() returned %v after the last request, want at least %v", elapsed, quietPeriod)
+	}
+	if elapsed := time.Since(start); elapsed < 2*quietPeriod {
+		t.Errorf("Drain() returned after %v, want at least %v", elapsed, 2*quietPeriod)
+	}
+
+	select {
+	case <-drained:
+	default:
+		t.Error("Drained was not closed")
+	}
+
+	// Draining again returns immediately.
+	d.Drain()
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{0, "1"},
+		{time.Second, "1"},
+		{1001 * time.Millisecond, "2"},
+		{time.Minute, "60"},
+	}
+	for _, test := range tests {
+		if got := retryAfterSeconds(test.in); got != test.want {
+			t.Errorf("retryAfterSeconds(%v) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func waitForDraining(t *testing.T, d *Drainer) {
+	t.Helper()
+	for start := time.Now(); !d.draining(); time.Sleep(time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("Drainer didn't start draining")
+		}
+	}
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	// ForwardedHeaderName is the name of the standard header carrying the
+	// information of the proxies a request went through, see RFC 7239.
+	ForwardedHeaderName = "Forwarded"
+
+	// ForwardedForHeaderName is the name of the de facto standard header
+	// listing the client and the proxies a request went through.
+	ForwardedForHeaderName = "X-Forwarded-For"
+
+	// ForwardedHostHeaderName is the name of the de facto standard header
+	// carrying the host originally requested by the client.
+	ForwardedHostHeaderName = "X-Forwarded-Host"
+
+	// ForwardedProtoHeaderName is the name of the de facto standard header
+	// carrying the protocol originally used by the client.
+	ForwardedProtoHeaderName = "X-Forwarded-Proto"
+)
+
+// hopByHopHeaders are the headers which only apply to a single connection, and
+// aren't forwarded by proxies, see RFC 7230 section 6.1.
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection", // Non-standard, but still sent by some clients.
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// RemoveHopByHopHeaders removes the hop-by-hop headers, i.e. the standard ones
+// along with the ones listed in the Connection header. `Te: trailers` is kept,
+// since it tells the backends, e.g. gRPC servers, that the client accepts
+// trailers, which the proxies pass along.
+func RemoveHopByHopHeaders(h http.Header) {
+	// The headers listed in Connection are removed first, so that the
+	// Connection header itself is still there.
+	for _, values := range h["Connection"] {
+		for _, name := range strings.Split(values, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+	trailers := acceptsTrailers(h)
+	for _, name := range hopByHopHeaders {
+		h.Del(name)
+	}
+	if trailers {
+		h.Set("Te", "trailers")
+	}
+}
+
+// acceptsTrailers returns true if the Te header accepts trailers.
+func acceptsTrailers(h http.Header) bool {
+	for _, values := range h["Te"] {
+		for _, value := range strings.Split(values, ",") {
+			// Ignore the parameters, e.g. `trailers;q=1`.
+			if i := strings.Index(value, ";"); i >= 0 {
+				value = value[:i]
+			}
+			if strings.EqualFold(strings.TrimSpace(value), "trailers") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CopyHeaders adds the end-to-end headers of src to dst, i.e. all of its
+// headers but the hop-by-hop ones. src is left untouched.
+func CopyHeaders(dst, src http.Header) {
+	h := make(http.Header, len(src))
+	for name, values := range src {
+		h[name] = append([]string(nil), values...)
+	}
+	RemoveHopByHopHeaders(h)
+	for name, values := range h {
+		for _, value := range values {
+			dst.Add(name, value)
+		}
+	}
+}
+
+// SetForwardedHeaders records the hop of the given incoming request, received
+// by a proxy, in the headers of the request forwarded to the backend: the
+// client address is appended to X-Forwarded-For and to Forwarded, along with
+// the host and protocol requested. X-Forwarded-Host and X-Forwarded-Proto are
+// only set if missing, to keep the ones of the client of the first proxy.
+func SetForwardedHeaders(out http.Header, in *http.Request) {
+	proto := "http"
+	if in.TLS != nil {
+		proto = "https"
+	}
+	ip := remoteIP(in)
+
+	if ip != "" {
+		// Multiple headers are folded into a single one, as some backends
+		// only read the first one.
+		out.Set(ForwardedForHeaderName, appendList(out[ForwardedForHeaderName], ip))
+	}
+	if out.Get(ForwardedHostHeaderName) == "" && in.Host != "" {
+		out.Set(ForwardedHostHeaderName, in.Host)
+	}
+	if out.Get(ForwardedProtoHeaderName) == "" {
+		out.Set(ForwardedProtoHeaderName, proto)
+	}
+
+	var element []string
+	if ip != "" {
+		element = append(element, "for="+forwardedNode(ip))
+	}
+	if in.Host != "" {
+		element = append(element, "host="+forwardedValue(in.Host))
+	}
+	element = append(element, "proto="+proto)
+	out.Set(ForwardedHeaderName, appendList(out[ForwardedHeaderName], strings.Join(element, ";")))
+}
+
+// appendList appends the element to the comma separated list held by the
+// values of a header.
+func appendList(values []string, element string) string {
+	return strings.Join(append(append([]string(nil), values...), element), ", ")
+}
+
+// remoteIP returns the IP address of the client of the request, or an empty
+// string if it doesn't have one, e.g. over a Unix socket.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if net.ParseIP(host) == nil {
+		return ""
+	}
+	return host
+}
+
+// forwardedNode formats an IP address as a node of the Forwarded header, where
+// the IPv6 addresses are bracketed and quoted.
+func forwardedNode(ip string) string {
+	if strings.Contains(ip, ":") {
+		return `"[` + ip + `]"`
+	}
+	return ip
+}
+
+// forwardedValue quotes the value of a parameter of the Forwarded header if it
+// isn't a token, e.g. a host with a port.
+func forwardedValue(value string) string {
+	for _, r := range value {
+		if !isTokenRune(r) {
+			return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+		}
+	}
+	return value
+}
+
+// isTokenRune returns true if the rune is allowed in a token, see RFC 7230
+// section 3.2.6.
+func isTokenRune(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	default:
+		return strings.ContainsRune("!#$%&'*+-.^_`|~", r)
+	}
+}
+
+// AnnounceTrailers announces the names of the trailers of a response in the
+// Trailer header of the proxied response, which is hop-by-hop. It must be
+// called before the header of the proxied response is written.
+func AnnounceTrailers(w http.ResponseWriter, trailer http.Header) {
+	for name := range trailer {
+		w.Header().Add("Trailer", name)
+	}
+}
+
+// CopyTrailers copies the trailers of a response to the proxied response, once
+// its body was written. The trailers which weren't announced, e.g. those added
+// by the backend while writing the body, are sent with http.TrailerPrefix.
+func CopyTrailers(w http.ResponseWriter, trailer http.Header) {
+	announced := make(map[string]bool)
+	for _, values := range w.Header()["Trailer"] {
+		for _, name := range strings.Split(values, ",") {
+			announced[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+		}
+	}
+	for name, values := range trailer {
+		if !announced[http.CanonicalHeaderKey(name)] {
+			name = http.TrailerPrefix + name
+		}
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+}
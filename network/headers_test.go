@@ -0,0 +1,180 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRemoveHopByHopHeaders(t *testing.T) {
+	tests := []struct {
+		name string
+		in   http.Header
+		want http.Header
+	}{{
+		name: "standard headers",
+		in: http.Header{
+			"Connection":          {"keep-alive"},
+			"Keep-Alive":          {"timeout=5"},
+			"Proxy-Authorization": {"Basic Zm9vOmJhcg=="},
+			"Proxy-Connection":    {"keep-alive"},
+			"Te":                  {"gzip"},
+			"Trailer":             {"Grpc-Status"},
+			"Transfer-Encoding":   {"chunked"},
+			"Upgrade":             {"websocket"},
+			"Content-Type":        {"text/plain"},
+		},
+		want: http.Header{
+			"Content-Type": {"text/plain"},
+		},
+	}, {
+		name: "headers listed in Connection",
+		in: http.Header{
+			"Connection": {"X-Custom, close", "x-other"},
+			"X-Custom":   {"foo"},
+			"X-Other":    {"bar"},
+			"X-Kept":     {"baz"},
+		},
+		want: http.Header{
+			"X-Kept": {"baz"},
+		},
+	}, {
+		name: "trailers accepted",
+		in: http.Header{
+			"Te": {"deflate, trailers;q=1"},
+		},
+		want: http.Header{
+			"Te": {"trailers"},
+		},
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			RemoveHopByHopHeaders(test.in)
+			if diff := cmp.Diff(test.want, test.in); diff != "" {
+				t.Errorf("RemoveHopByHopHeaders (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestCopyHeaders(t *testing.T) {
+	src := http.Header{
+		"Connection": {"X-Custom"},
+		"X-Custom":   {"foo"},
+		"Accept":     {"text/plain", "text/html"},
+	}
+	dst := http.Header{"Accept": {"*/*"}}
+	CopyHeaders(dst, src)
+
+	want := http.Header{"Accept": {"*/*", "text/plain", "text/html"}}
+	if diff := cmp.Diff(want, dst); diff != "" {
+		t.Errorf("CopyHeaders (-want, +got): %s", diff)
+	}
+	if got := src.Get("X-Custom"); got != "foo" {
+		t.Errorf("X-Custom of the source = %q, wanted it untouched", got)
+	}
+}
+
+func TestSetForwardedHeaders(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		host       string
+		tls        bool
+		out        http.Header
+		want       http.Header
+	}{{
+		name:       "first hop",
+		remoteAddr: "10.0.0.1:1234",
+		host:       "example.com",
+		out:        http.Header{},
+		want: http.Header{
+			"X-Forwarded-For":   {"10.0.0.1"},
+			"X-Forwarded-Host":  {"example.com"},
+			"X-Forwarded-Proto": {"http"},
+			"Forwarded":         {"for=10.0.0.1;host=example.com;proto=http"},
+		},
+	}, {
+		name:       "second hop",
+		remoteAddr: "[2001:db8::1]:1234",
+		host:       "example.com:8443",
+		tls:        true,
+		out: http.Header{
+			"X-Forwarded-For":   {"1.2.3.4", "10.0.0.1"},
+			"X-Forwarded-Host":  {"example.com"},
+			"X-Forwarded-Proto": {"http"},
+			"Forwarded":         {"for=1.2.3.4;proto=http"},
+		},
+		want: http.Header{
+			"X-Forwarded-For":   {"1.2.3.4, 10.0.0.1, 2001:db8::1"},
+			"X-Forwarded-Host":  {"example.com"},
+			"X-Forwarded-Proto": {"http"},
+			"Forwarded":         {`for=1.2.3.4;proto=http, for="[2001:db8::1]";host="example.com:8443";proto=https`},
+		},
+	}, {
+		name:       "no client address",
+		remoteAddr: "@",
+		out:        http.Header{},
+		want: http.Header{
+			"X-Forwarded-Proto": {"http"},
+			"Forwarded":         {"proto=http"},
+		},
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			in := &http.Request{RemoteAddr: test.remoteAddr, Host: test.host}
+			if test.tls {
+				in.TLS = &tls.ConnectionState{}
+			}
+			SetForwardedHeaders(test.out, in)
+			if diff := cmp.Diff(test.want, test.out); diff != "" {
+				t.Errorf("SetForwardedHeaders (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestTrailers(t *testing.T) {
+	backendTrailer := http.Header{
+		"Grpc-Status": {"0"},
+		"X-Late":      {"late"},
+	}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only Grpc-Status was announced by the backend.
+		AnnounceTrailers(w, http.Header{"Grpc-Status": nil})
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("body"))
+		CopyTrailers(w, backendTrailer)
+	}))
+	defer proxy.Close()
+
+	resp, err := http.Get(proxy.URL)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+	if diff := cmp.Diff(backendTrailer, resp.Trailer); diff != "" {
+		t.Errorf("Trailers (-want, +got): %s", diff)
+	}
+}
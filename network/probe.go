@@ -0,0 +1,110 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+const (
+	// ProbeHeaderName is the name of the header identifying a request as a
+	// K-Probe, i.e. a probe sent by a Knative component through the network
+	// layer to check whether it has been programmed.
+	ProbeHeaderName = "K-Network-Probe"
+
+	// ProbeHeaderValue is the value of ProbeHeaderName identifying a K-Probe.
+	ProbeHeaderValue = "probe"
+
+	// HashHeaderName is the name of the header carrying the hash of the
+	// configuration a K-Probe expects to be served by.
+	HashHeaderName = "K-Network-Hash"
+
+	// KubeProbeUAPrefix is the prefix of the User-Agent header of the
+	// probes sent by the kubelet.
+	KubeProbeUAPrefix = "kube-probe/"
+)
+
+// ProbeOptions defines the headers used to identify and verify probes.
+type ProbeOptions struct {
+	// HeaderName and HeaderValue identify a request as a probe.
+	HeaderName  string
+	HeaderValue string
+	// HashHeaderName is the name of the header carrying the expected hash
+	// on requests and the actual hash on responses.
+	HashHeaderName string
+}
+
+// DefaultProbeOptions returns the ProbeOptions of K-Probes.
+func DefaultProbeOptions() ProbeOptions {
+	return ProbeOptions{
+		HeaderName:     ProbeHeaderName,
+		HeaderValue:    ProbeHeaderValue,
+		HashHeaderName: HashHeaderName,
+	}
+}
+
+// IsProbe returns true if the request is a probe according to the options.
+func (o ProbeOptions) IsProbe(r *http.Request) bool {
+	return r.Header.Get(o.HeaderName) == o.HeaderValue
+}
+
+// IsKProbe returns true if the request is a K-Probe.
+func IsKProbe(r *http.Request) bool {
+	return DefaultProbeOptions().IsProbe(r)
+}
+
+// IsKubeletProbe returns true if the request is a probe sent by the kubelet.
+func IsKubeletProbe(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("User-Agent"), KubeProbeUAPrefix)
+}
+
+// ProbeHash returns the hash identifying the given data, e.g. a serialized
+// configuration, for use in the hash header of probes.
+func ProbeHash(data ...[]byte) string {
+	h := sha256.New()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// NewProbeHandler returns a handler answering probes identified by the
+// given options and passing all other requests on to next.
+//
+// Probes are answered with the hash returned by hash in the hash header.
+// If a probe carries a hash itself, it is answered with
+// http.StatusPreconditionFailed unless both hashes match, so probers can
+// tell whether the expected configuration is being served.
+func NewProbeHandler(next http.Handler, opts ProbeOptions, hash func() string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !opts.IsProbe(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		actual := hash()
+		w.Header().Set(opts.HashHeaderName, actual)
+		if expected := r.Header.Get(opts.HashHeaderName); expected != "" && expected != actual {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProbeHandler(t *testing.T) {
+	custom := ProbeOptions{
+		HeaderName:     "X-Probe",
+		HeaderValue:    "yes",
+		HashHeaderName: "X-Probe-Hash",
+	}
+	hash := ProbeHash([]byte("config"))
+
+	tests := []struct {
+		name       string
+		opts       ProbeOptions
+		headers    map[string]string
+		wantStatus int
+		wantHash   string
+	}{{
+		name:       "not a probe",
+		opts:       DefaultProbeOptions(),
+		wantStatus: http.StatusTeapot,
+	}, {
+		name:       "probe without hash",
+		opts:       DefaultProbeOptions(),
+		headers:    map[string]string{ProbeHeaderName: ProbeHeaderValue},
+		wantStatus: http.StatusOK,
+		wantHash:   hash,
+	}, {
+		name: "probe with matching hash",
+		opts: DefaultProbeOptions(),
+		headers: map[string]string{
+			ProbeHeaderName: ProbeHeaderValue,
+			HashHeaderName:  hash,
+		},
+		wantStatus: http.StatusOK,
+		wantHash:   hash,
+	}, {
+		name: "probe with mismatching hash",
+		opts: DefaultProbeOptions(),
+		headers: map[string]string{
+			ProbeHeaderName: ProbeHeaderValue,
+			HashHeaderName:  ProbeHash([]byte("other")),
+		},
+		wantStatus: http.StatusPreconditionFailed,
+		wantHash:   hash,
+	}, {
+		name:       "wrong probe value",
+		opts:       DefaultProbeOptions(),
+		headers:    map[string]string{ProbeHeaderName: "not-a-probe"},
+		wantStatus: http.StatusTeapot,
+	}, {
+		name: "custom headers",
+		opts: custom,
+		headers: map[string]string{
+			"X-Probe":      "yes",
+			"X-Probe-Hash": hash,
+		},
+		wantStatus: http.StatusOK,
+		wantHash:   hash,
+	}, {
+		name:       "custom headers ignore K-Probes",
+		opts:       custom,
+		headers:    map[string]string{ProbeHeaderName: ProbeHeaderValue},
+		wantStatus: http.StatusTeapot,
+	}}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := NewProbeHandler(next, test.opts, func() string { return hash })
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != test.wantStatus {
+				t.Errorf("StatusCode = %d, want %d", w.Code, test.wantStatus)
+			}
+			if got := w.Header().Get(test.opts.HashHeaderName); got != test.wantHash {
+				t.Errorf("Hash header = %q, want %q", got, test.wantHash)
+			}
+		})
+	}
+}
+
+func TestProbeHash(t *testing.T) {
+	if ProbeHash([]byte("a"), []byte("b")) != ProbeHash([]byte("ab")) {
+		t.Error("ProbeHash should hash the concatenation of its inputs")
+	}
+	if ProbeHash([]byte("a")) == ProbeHash([]byte("b")) {
+		t.Error("ProbeHash should differ for different inputs")
+	}
+}
+
+func TestIsKubeletProbe(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if IsKubeletProbe(r) {
+		t.Error("IsKubeletProbe() = true for a regular request")
+	}
+	r.Header.Set("User-Agent", KubeProbeUAPrefix+"1.15")
+	if !IsKubeletProbe(r) {
+		t.Error("IsKubeletProbe() = false for a kubelet probe")
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"knative.dev/pkg/network"
+)
+
+// Preparer is a way for the caller to modify the HTTP request before it
+// goes out.
+type Preparer func(r *http.Request) *http.Request
+
+// Verifier is a way for the caller to validate the HTTP response after it
+// comes back. It returns false if the probe failed, and an error if the
+// probe can't succeed anymore.
+type Verifier func(r *http.Response, b []byte) (bool, error)
+
+// WithHeader sets a header in the probe request.
+func WithHeader(name, value string) Preparer {
+	return func(r *http.Request) *http.Request {
+		r.Header.Set(name, value)
+		return r
+	}
+}
+
+// WithHost sets the host in the probe request.
+func WithHost(host string) Preparer {
+	return func(r *http.Request) *http.Request {
+		r.Host = host
+		return r
+	}
+}
+
+// WithProbeHeaders marks the request as a probe according to the given
+// options. If hash is not empty, it is sent as the expected hash.
+func WithProbeHeaders(opts network.ProbeOptions, hash string) Preparer {
+	return func(r *http.Request) *http.Request {
+		r.Header.Set(opts.HeaderName, opts.HeaderValue)
+		if hash != "" {
+			r.Header.Set(opts.HashHeaderName, hash)
+		}
+		return r
+	}
+}
+
+// ExpectsBody validates that the body of the probe response matches the
+// provided string.
+func ExpectsBody(body string) Verifier {
+	return func(r *http.Response, b []byte) (bool, error) {
+		return string(b) == body, nil
+	}
+}
+
+// ExpectsHeader validates that the given header of the probe response
+// matches the provided value.
+func ExpectsHeader(name, value string) Verifier {
+	return func(r *http.Response, _ []byte) (bool, error) {
+		return r.Header.Get(name) == value, nil
+	}
+}
+
+// ExpectsStatusCodes validates that the status code of the probe response
+// is one of the provided codes.
+func ExpectsStatusCodes(statusCodes ...int) Verifier {
+	return func(r *http.Response, _ []byte) (bool, error) {
+		for _, v := range statusCodes {
+			if r.StatusCode == v {
+				return true, nil
+			}
+		}
+		return false, fmt.Errorf("unexpected status code: want %v, got %v", statusCodes, r.StatusCode)
+	}
+}
+
+// ExpectsHash validates that the probe response has been served with the
+// given hash, as set by network.NewProbeHandler.
+func ExpectsHash(opts network.ProbeOptions, hash string) Verifier {
+	return ExpectsHeader(opts.HashHeaderName, hash)
+}
+
+// Do sends a single probe to the given target, e.g. "http://revision.default.svc.cluster.local:81".
+// ops is a list of Preparers and Verifiers, which modify the request and
+// validate the response respectively. Do returns true if all Verifiers
+// passed.
+func Do(ctx context.Context, transport http.RoundTripper, target string, ops ...interface{}) (bool, error) {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return false, fmt.Errorf("%s is not a valid URL: %v", target, err)
+	}
+	req = req.WithContext(ctx)
+
+	var verifiers []Verifier
+	for _, op := range ops {
+		switch o := op.(type) {
+		case Preparer:
+			req = o(req)
+		case Verifier:
+			verifiers = append(verifiers, o)
+		default:
+			return false, fmt.Errorf("unsupported probe option of type %T", op)
+		}
+	}
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return false, fmt.Errorf("error roundtripping %s: %v", target, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("error reading body: %v", err)
+	}
+
+	for _, v := range verifiers {
+		if ok, err := v(resp, body); !ok || err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// KProbe sends a K-Probe expecting the given hash to the target and
+// returns true if it has been answered successfully with that hash.
+func KProbe(ctx context.Context, transport http.RoundTripper, target, hash string, ops ...interface{}) (bool, error) {
+	opts := network.DefaultProbeOptions()
+	return Do(ctx, transport, target, append([]interface{}{
+		WithProbeHeaders(opts, hash),
+		ExpectsStatusCodes(http.StatusOK, http.StatusPreconditionFailed),
+		ExpectsHash(opts, hash),
+	}, ops...)...)
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"knative.dev/pkg/network"
+)
+
+const (
+	systemName   = "test-server"
+	unexpectedID = "unexpected"
+)
+
+func TestDoServing(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Header") == systemName {
+			w.Write([]byte(systemName))
+			return
+		}
+		w.Write([]byte(unexpectedID))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		headerValue string
+		want        bool
+	}{{
+		name:        "ok",
+		headerValue: systemName,
+		want:        true,
+	}, {
+		name:        "mismatch",
+		headerValue: "bees",
+		want:        false,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Do(context.Background(), http.DefaultTransport, ts.URL,
+				WithHeader("X-Header", test.headerValue), ExpectsBody(systemName))
+			if err != nil {
+				t.Errorf("Do() = %v", err)
+			}
+			if got != test.want {
+				t.Errorf("Do() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDoErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	if _, err := Do(context.Background(), http.DefaultTransport, ":foo"); err == nil {
+		t.Error("Do() = nil, wanted an error for an invalid URL")
+	}
+	if _, err := Do(context.Background(), http.DefaultTransport, ts.URL, "not an option"); err == nil {
+		t.Error("Do() = nil, wanted an error for an unsupported option")
+	}
+	if ok, err := Do(context.Background(), http.DefaultTransport, ts.URL, ExpectsStatusCodes(http.StatusOK)); ok || err == nil {
+		t.Errorf("Do() = %v, %v, wanted an error for an unexpected status code", ok, err)
+	}
+}
+
+func TestKProbe(t *testing.T) {
+	current := network.ProbeHash([]byte("current"))
+	ts := httptest.NewServer(network.NewProbeHandler(
+		http.NotFoundHandler(), network.DefaultProbeOptions(), func() string { return current }))
+	defer ts.Close()
+
+	tests := []struct {
+		name string
+		hash string
+		want bool
+	}{{
+		name: "matching hash",
+		hash: current,
+		want: true,
+	}, {
+		name: "stale hash",
+		hash: network.ProbeHash([]byte("stale")),
+		want: false,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := KProbe(context.Background(), http.DefaultTransport, ts.URL, test.hash)
+			if err != nil {
+				t.Errorf("KProbe() = %v", err)
+			}
+			if got != test.want {
+				t.Errorf("KProbe() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCustomProbeHeaders(t *testing.T) {
+	opts := network.ProbeOptions{
+		HeaderName:     "X-Probe",
+		HeaderValue:    "yes",
+		HashHeaderName: "X-Probe-Hash",
+	}
+	ts := httptest.NewServer(network.NewProbeHandler(
+		http.NotFoundHandler(), opts, func() string { return "hash" }))
+	defer ts.Close()
+
+	got, err := Do(context.Background(), http.DefaultTransport, ts.URL,
+		WithProbeHeaders(opts, "hash"),
+		ExpectsStatusCodes(http.StatusOK),
+		ExpectsHash(opts, "hash"))
+	if err != nil || !got {
+		t.Errorf("Do() = %v, %v, want true, nil", got, err)
+	}
+}
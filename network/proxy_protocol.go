@@ -0,0 +1,276 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProxyProtocolPolicy defines how a listener treats PROXY protocol headers,
+// as sent by L4 load balancers to pass on the client's address.
+// See https://www.haproxy.org/download/1.8/doc/proxy-protocol.txt.
+type ProxyProtocolPolicy int
+
+const (
+	// ProxyProtocolDisabled doesn't look for PROXY protocol headers.
+	ProxyProtocolDisabled ProxyProtocolPolicy = iota
+	// ProxyProtocolOptional uses the header if a connection starts with one.
+	ProxyProtocolOptional
+	// ProxyProtocolRequired rejects connections not starting with a header.
+	ProxyProtocolRequired
+	// ProxyProtocolRejected rejects connections starting with a header.
+	ProxyProtocolRejected
+)
+
+var (
+	// ErrProxyHeaderMissing is returned when reading from a connection
+	// without PROXY protocol header if the header is required.
+	ErrProxyHeaderMissing = errors.New("PROXY protocol header is required but missing")
+	// ErrProxyHeaderRejected is returned when reading from a connection
+	// starting with a PROXY protocol header if headers are rejected.
+	ErrProxyHeaderRejected = errors.New("PROXY protocol header is not allowed")
+)
+
+const (
+	// proxyV1Prefix starts a human-readable (v1) header.
+	proxyV1Prefix = "PROXY "
+	// proxyV1MaxLength is the maximum length of a v1 header, including
+	// the trailing CRLF.
+	proxyV1MaxLength = 107
+	// proxyV2HeaderLength is the length of the fixed part of a binary (v2)
+	// header.
+	proxyV2HeaderLength = 16
+)
+
+// proxyV2Signature starts a binary (v2) header.
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// NewProxyProtocolListener wraps the given listener to parse PROXY protocol
+// headers according to the given policy. The addresses in the header are
+// reported as the RemoteAddr and LocalAddr of the accepted connections.
+//
+// Headers are parsed on the first use of a connection rather than in
+// Accept, so slow clients don't block other connections. The header must
+// be received within the given timeout, if non-zero.
+func NewProxyProtocolListener(l net.Listener, policy ProxyProtocolPolicy, timeout time.Duration) net.Listener {
+	if policy == ProxyProtocolDisabled {
+		return l
+	}
+	return &proxyProtocolListener{Listener: l, policy: policy, timeout: timeout}
+}
+
+type proxyProtocolListener struct {
+	net.Listener
+	policy  ProxyProtocolPolicy
+	timeout time.Duration
+}
+
+// Accept implements net.Listener.
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyProtocolConn{
+		Conn:    c,
+		policy:  l.policy,
+		timeout: l.timeout,
+		reader:  bufio.NewReader(c),
+	}, nil
+}
+
+// proxyProtocolConn parses the PROXY protocol header on first use.
+type proxyProtocolConn struct {
+	net.Conn
+	policy  ProxyProtocolPolicy
+	timeout time.Duration
+
+	once   sync.Once
+	reader *bufio.Reader
+	err    error
+	remote net.Addr
+	local  net.Addr
+}
+
+// Read implements net.Conn.
+func (c *proxyProtocolConn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr implements net.Conn.
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr implements net.Conn.
+func (c *proxyProtocolConn) LocalAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.local != nil {
+		return c.local
+	}
+	return c.Conn.LocalAddr()
+}
+
+func (c *proxyProtocolConn) readHeader() {
+	if c.timeout > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+		defer c.Conn.SetReadDeadline(time.Time{})
+	}
+
+	version, err := detectProxyHeader(c.reader)
+	switch {
+	case err != nil:
+		c.err = err
+	case version == 0 && c.policy == ProxyProtocolRequired:
+		c.err = ErrProxyHeaderMissing
+	case version != 0 && c.policy == ProxyProtocolRejected:
+		c.err = ErrProxyHeaderRejected
+	case version == 1:
+		c.remote, c.local, c.err = readProxyHeaderV1(c.reader)
+	case version == 2:
+		c.remote, c.local, c.err = readProxyHeaderV2(c.reader)
+	}
+}
+
+// detectProxyHeader returns the version of the PROXY protocol header the
+// reader starts with, or zero if there is none.
+func detectProxyHeader(r *bufio.Reader) (int, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return 0, err
+	}
+	// Only peek further if necessary, as short payloads would block.
+	switch first[0] {
+	case proxyV1Prefix[0]:
+		if b, err := r.Peek(len(proxyV1Prefix)); err == nil && string(b) == proxyV1Prefix {
+			return 1, nil
+		}
+	case proxyV2Signature[0]:
+		if b, err := r.Peek(len(proxyV2Signature)); err == nil && bytes.Equal(b, proxyV2Signature) {
+			return 2, nil
+		}
+	}
+	return 0, nil
+}
+
+// readProxyHeaderV1 reads a header like "PROXY TCP4 1.2.3.4 5.6.7.8 1234 80\r\n".
+func readProxyHeaderV1(r *bufio.Reader) (remote, local net.Addr, err error) {
+	var line []byte
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= proxyV1MaxLength {
+			return nil, nil, errors.New("PROXY protocol v1 header is too long")
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, err
+		}
+		line = append(line, b)
+	}
+
+	fields := strings.Split(strings.TrimSuffix(string(line), "\r\n"), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		// The connection's addresses are to be used.
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, fmt.Errorf("malformed PROXY protocol v1 header %q", line)
+	}
+	if remote, err = parseTCPAddr(fields[2], fields[4]); err != nil {
+		return nil, nil, err
+	}
+	if local, err = parseTCPAddr(fields[3], fields[5]); err != nil {
+		return nil, nil, err
+	}
+	return remote, local, nil
+}
+
+func parseTCPAddr(host, port string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP %q in PROXY protocol header", host)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q in PROXY protocol header", port)
+	}
+	return &net.TCPAddr{IP: ip, Port: int(p)}, nil
+}
+
+// readProxyHeaderV2 reads a binary header.
+func readProxyHeaderV2(r *bufio.Reader) (remote, local net.Addr, err error) {
+	header := make([]byte, proxyV2HeaderLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, nil, err
+	}
+	versionCommand, family := header[12], header[13]
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, nil, err
+	}
+
+	if versionCommand>>4 != 2 {
+		return nil, nil, fmt.Errorf("unsupported PROXY protocol version %d", versionCommand>>4)
+	}
+	switch versionCommand & 0xF {
+	case 0x0:
+		// LOCAL: the connection was established by the proxy itself.
+		return nil, nil, nil
+	case 0x1:
+		// PROXY
+	default:
+		return nil, nil, fmt.Errorf("unsupported PROXY protocol command %d", versionCommand&0xF)
+	}
+
+	var ipLength int
+	switch family >> 4 {
+	case 0x1:
+		ipLength = net.IPv4len
+	case 0x2:
+		ipLength = net.IPv6len
+	default:
+		// Unspecified or unix sockets: the connection's addresses are to
+		// be used.
+		return nil, nil, nil
+	}
+	if len(payload) < 2*ipLength+4 {
+		return nil, nil, errors.New("PROXY protocol v2 header is too short")
+	}
+	srcIP := net.IP(payload[:ipLength])
+	dstIP := net.IP(payload[ipLength : 2*ipLength])
+	srcPort := int(binary.BigEndian.Uint16(payload[2*ipLength:]))
+	dstPort := int(binary.BigEndian.Uint16(payload[2*ipLength+2:]))
+	// Any remaining bytes are TLVs, which are ignored.
+	return &net.TCPAddr{IP: srcIP, Port: srcPort}, &net.TCPAddr{IP: dstIP, Port: dstPort}, nil
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+const payload = "GET / HTTP/1.0\r\n\r\n"
+
+// proxyV2 builds a binary header for the given addresses.
+func proxyV2(command, family byte, src, dst net.IP, srcPort, dstPort uint16) string {
+	var addrs []byte
+	addrs = append(addrs, src...)
+	addrs = append(addrs, dst...)
+	addrs = append(addrs, 0, 0, 0, 0)
+	binary.BigEndian.PutUint16(addrs[len(addrs)-4:], srcPort)
+	binary.BigEndian.PutUint16(addrs[len(addrs)-2:], dstPort)
+
+	header := append([]byte{}, proxyV2Signature...)
+	header = append(header, 0x20|command, family, 0, 0)
+	binary.BigEndian.PutUint16(header[14:], uint16(len(addrs)))
+	return string(append(header, addrs...))
+}
+
+func TestProxyProtocolConn(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     ProxyProtocolPolicy
+		input      string
+		wantRemote string
+		wantLocal  string
+		wantErr    bool
+	}{{
+		name:   "no header",
+		policy: ProxyProtocolOptional,
+		input:  payload,
+	}, {
+		name:       "v1 tcp4",
+		policy:     ProxyProtocolOptional,
+		input:      "PROXY TCP4 1.2.3.4 5.6.7.8 1234 80\r\n" + payload,
+		wantRemote: "1.2.3.4:1234",
+		wantLocal:  "5.6.7.8:80",
+	}, {
+		name:       "v1 tcp6",
+		policy:     ProxyProtocolRequired,
+		input:      "PROXY TCP6 ::1 ::2 1234 80\r\n" + payload,
+		wantRemote: "[::1]:1234",
+		wantLocal:  "[::2]:80",
+	}, {
+		name:   "v1 unknown",
+		policy: ProxyProtocolOptional,
+		input:  "PROXY UNKNOWN\r\n" + payload,
+	}, {
+		name:    "v1 malformed",
+		policy:  ProxyProtocolOptional,
+		input:   "PROXY TCP4 1.2.3.4\r\n" + payload,
+		wantErr: true,
+	}, {
+		name:    "v1 invalid port",
+		policy:  ProxyProtocolOptional,
+		input:   "PROXY TCP4 1.2.3.4 5.6.7.8 123456 80\r\n" + payload,
+		wantErr: true,
+	}, {
+		name:    "v1 too long",
+		policy:  ProxyProtocolOptional,
+		input:   "PROXY " + strings.Repeat("x", proxyV1MaxLength) + "\r\n",
+		wantErr: true,
+	}, {
+		name:       "v2 ipv4",
+		policy:     ProxyProtocolOptional,
+		input:      proxyV2(0x1, 0x11, net.IPv4(1, 2, 3, 4).To4(), net.IPv4(5, 6, 7, 8).To4(), 1234, 80) + payload,
+		wantRemote: "1.2.3.4:1234",
+		wantLocal:  "5.6.7.8:80",
+	}, {
+		name:       "v2 ipv6",
+		policy:     ProxyProtocolRequired,
+		input:      proxyV2(0x1, 0x21, net.ParseIP("::1"), net.ParseIP("::2"), 1234, 80) + payload,
+		wantRemote: "[::1]:1234",
+		wantLocal:  "[::2]:80",
+	}, {
+		name:   "v2 local",
+		policy: ProxyProtocolOptional,
+		input:  proxyV2(0x0, 0x11, net.IPv4(1, 2, 3, 4).To4(), net.IPv4(5, 6, 7, 8).To4(), 1234, 80) + payload,
+	}, {
+		name:    "header required",
+		policy:  ProxyProtocolRequired,
+		input:   payload,
+		wantErr: true,
+	}, {
+		name:    "header rejected",
+		policy:  ProxyProtocolRejected,
+		input:   "PROXY TCP4 1.2.3.4 5.6.7.8 1234 80\r\n" + payload,
+		wantErr: true,
+	}, {
+		name:   "no header with headers rejected",
+		policy: ProxyProtocolRejected,
+		input:  payload,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			go func() {
+				client.Write([]byte(test.input))
+				client.Close()
+			}()
+
+			c := &proxyProtocolConn{
+				Conn:    server,
+				policy:  test.policy,
+				timeout: time.Second,
+				reader:  bufio.NewReader(server),
+			}
+
+			wantRemote, wantLocal := test.wantRemote, test.wantLocal
+			if wantRemote == "" {
+				wantRemote, wantLocal = server.RemoteAddr().String(), server.LocalAddr().String()
+			}
+			if got := c.RemoteAddr().String(); got != wantRemote {
+				t.Errorf("RemoteAddr() = %s, want %s", got, wantRemote)
+			}
+			if got := c.LocalAddr().String(); got != wantLocal {
+				t.Errorf("LocalAddr() = %s, want %s", got, wantLocal)
+			}
+
+			got, err := ioutil.ReadAll(c)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ReadAll() = %v, wantErr = %v", err, test.wantErr)
+			}
+			if !test.wantErr && string(got) != payload {
+				t.Errorf("Payload = %q, want %q", got, payload)
+			}
+		})
+	}
+}
+
+func TestProxyProtocolHeaderTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	c := &proxyProtocolConn{
+		Conn:    server,
+		policy:  ProxyProtocolOptional,
+		timeout: 10 * time.Millisecond,
+		reader:  bufio.NewReader(server),
+	}
+	if _, err := c.Read(make([]byte, 1)); err == nil {
+		t.Error("Read() = nil, wanted a timeout")
+	}
+}
+
+func TestServerProxyProtocol(t *testing.T) {
+	opts := DefaultServerOptions()
+	opts.ProxyProtocol = ProxyProtocolRequired
+	s, err := NewServerWithOptions("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.RemoteAddr))
+	}), opts)
+	if err != nil {
+		t.Fatal("NewServerWithOptions() =", err)
+	}
+	url, cancel, _ := startServer(t, s)
+	defer cancel()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatal("Dial() =", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("PROXY TCP4 1.2.3.4 5.6.7.8 1234 80\r\n" + payload))
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal("ReadResponse() =", err)
+	}
+	defer resp.Body.Close()
+	if body, _ := ioutil.ReadAll(resp.Body); string(body) != "1.2.3.4:1234" {
+		t.Errorf("RemoteAddr = %s, want 1.2.3.4:1234", body)
+	}
+}
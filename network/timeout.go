@@ -0,0 +1,261 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TimeoutOptions configures a timeout handler. Zero durations disable the
+// respective timeout.
+type TimeoutOptions struct {
+	// FirstByteTimeout bounds the time until the handler starts writing
+	// the response.
+	FirstByteTimeout time.Duration
+	// IdleTimeout bounds the time between two writes once the handler
+	// started writing the response, which allows streaming responses to
+	// run for as long as they're active.
+	IdleTimeout time.Duration
+	// Timeout bounds the total duration of the request.
+	Timeout time.Duration
+	// Message is the body of the response sent if the handler times out
+	// before writing anything.
+	Message string
+}
+
+// NewTimeoutHandler returns a handler running h with the given timeouts.
+//
+// Unlike http.TimeoutHandler, responses are not buffered: writes and
+// flushes are passed through immediately and Hijack is supported, so
+// server-sent events and gRPC streams keep working. If the handler times
+// out before writing anything, a 504 is sent with the configured message.
+// If it times out afterwards, the response is aborted as the status code
+// can't be changed anymore. Either way, the request's context is canceled.
+func NewTimeoutHandler(h http.Handler, opts TimeoutOptions) http.Handler {
+	return &timeoutHandler{handler: h, opts: opts}
+}
+
+type timeoutHandler struct {
+	handler http.Handler
+	opts    TimeoutOptions
+}
+
+func (h *timeoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	tw := &timeoutWriter{w: w, firstWrite: make(chan struct{})}
+	done := make(chan struct{})
+	panicChan := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				panicChan <- p
+			}
+		}()
+		h.handler.ServeHTTP(tw, r.WithContext(ctx))
+		close(done)
+	}()
+
+	idle := newOptionalTimer(h.opts.FirstByteTimeout)
+	defer idle.stop()
+	total := newOptionalTimer(h.opts.Timeout)
+	defer total.stop()
+
+	firstWrite := tw.firstWrite
+	for {
+		select {
+		case p := <-panicChan:
+			panic(p)
+		case <-done:
+			return
+		case <-firstWrite:
+			// Switch from the first byte timeout to the idle timeout.
+			firstWrite = nil
+			idle.reset(h.opts.IdleTimeout)
+		case <-idle.c:
+			if remaining, ok := tw.idleRemaining(h.opts.IdleTimeout); ok {
+				idle.reset(remaining)
+				continue
+			}
+			tw.timeout(h.opts.Message)
+			return
+		case <-total.c:
+			if tw.hijacked() {
+				// The handler owns the connection now, we can't time it out.
+				total.reset(0)
+				continue
+			}
+			tw.timeout(h.opts.Message)
+			return
+		}
+	}
+}
+
+// optionalTimer is a timer that never fires if created with a zero duration.
+type optionalTimer struct {
+	t *time.Timer
+	c <-chan time.Time
+}
+
+func newOptionalTimer(d time.Duration) *optionalTimer {
+	t := &optionalTimer{}
+	t.reset(d)
+	return t
+}
+
+func (t *optionalTimer) reset(d time.Duration) {
+	t.stop()
+	if d <= 0 {
+		t.t, t.c = nil, nil
+		return
+	}
+	t.t = time.NewTimer(d)
+	t.c = t.t.C
+}
+
+func (t *optionalTimer) stop() {
+	if t.t != nil {
+		t.t.Stop()
+	}
+}
+
+// timeoutWriter passes writes through to the wrapped ResponseWriter until
+// the handler timed out, tracking the time of the last write.
+type timeoutWriter struct {
+	w http.ResponseWriter
+
+	mu          sync.Mutex
+	timedOut    bool
+	wasHijacked bool
+	lastWrite   time.Time
+	firstWrite  chan struct{}
+}
+
+var (
+	_ http.Flusher  = (*timeoutWriter)(nil)
+	_ http.Hijacker = (*timeoutWriter)(nil)
+)
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.w.Header()
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.markActiveLocked()
+	return tw.w.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	tw.markActiveLocked()
+	tw.w.WriteHeader(code)
+}
+
+// Flush implements http.Flusher.
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	if f, ok := tw.w.(http.Flusher); ok {
+		tw.markActiveLocked()
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker. Timeouts don't apply to hijacked
+// connections.
+func (tw *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	h, ok := tw.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the underlying ResponseWriter doesn't support hijacking")
+	}
+	c, rw, err := h.Hijack()
+	if err == nil {
+		tw.wasHijacked = true
+	}
+	return c, rw, err
+}
+
+func (tw *timeoutWriter) markActiveLocked() {
+	if tw.lastWrite.IsZero() {
+		close(tw.firstWrite)
+	}
+	tw.lastWrite = time.Now()
+}
+
+// idleRemaining returns how much longer the writer may stay idle and
+// whether it is still within its timeouts. A zero duration means there is
+// no limit. Hijacked writers never time out.
+func (tw *timeoutWriter) idleRemaining(idleTimeout time.Duration) (time.Duration, bool) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	switch {
+	case tw.wasHijacked:
+		return 0, true
+	case tw.lastWrite.IsZero():
+		// The first byte timeout expired.
+		return 0, false
+	case idleTimeout <= 0:
+		return 0, true
+	}
+	remaining := idleTimeout - time.Since(tw.lastWrite)
+	return remaining, remaining > 0
+}
+
+func (tw *timeoutWriter) hijacked() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.wasHijacked
+}
+
+// timeout marks the writer as timed out and writes the timeout response
+// if nothing has been written yet. Otherwise the response is aborted, as
+// its status code can't be changed anymore.
+func (tw *timeoutWriter) timeout(msg string) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.timedOut = true
+	if !tw.lastWrite.IsZero() {
+		panic(http.ErrAbortHandler)
+	}
+	tw.w.WriteHeader(http.StatusGatewayTimeout)
+	io.WriteString(tw.w, msg)
+}
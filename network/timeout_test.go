@@ -0,0 +1,193 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// streaming returns a handler writing and flushing n chunks, pausing for
+// the given interval before each of them.
+func streaming(n int, interval time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < n; i++ {
+			select {
+			case <-time.After(interval):
+			case <-r.Context().Done():
+				return
+			}
+			w.Write([]byte("x"))
+			w.(http.Flusher).Flush()
+		}
+	}
+}
+
+func serve(h http.Handler) (w *httptest.ResponseRecorder, panicked interface{}) {
+	defer func() {
+		panicked = recover()
+	}()
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+	return w, nil
+}
+
+func TestTimeoutHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		handler   http.Handler
+		opts      TimeoutOptions
+		wantCode  int
+		wantBody  string
+		wantAbort bool
+	}{{
+		name:     "no timeouts",
+		handler:  streaming(3, 10*time.Millisecond),
+		wantCode: http.StatusOK,
+		wantBody: "xxx",
+	}, {
+		name:     "first byte in time",
+		handler:  streaming(1, 0),
+		opts:     TimeoutOptions{FirstByteTimeout: time.Second},
+		wantCode: http.StatusOK,
+		wantBody: "x",
+	}, {
+		name:     "first byte timeout",
+		handler:  streaming(1, time.Second),
+		opts:     TimeoutOptions{FirstByteTimeout: 50 * time.Millisecond, Message: "timeout"},
+		wantCode: http.StatusGatewayTimeout,
+		wantBody: "timeout",
+	}, {
+		name:    "streaming outlives the first byte timeout",
+		handler: streaming(10, 20*time.Millisecond),
+		opts: TimeoutOptions{
+			FirstByteTimeout: 50 * time.Millisecond,
+			IdleTimeout:      100 * time.Millisecond,
+		},
+		wantCode: http.StatusOK,
+		wantBody: "xxxxxxxxxx",
+	}, {
+		name:    "idle timeout",
+		handler: streaming(2, 200*time.Millisecond),
+		opts: TimeoutOptions{
+			FirstByteTimeout: time.Second,
+			IdleTimeout:      50 * time.Millisecond,
+		},
+		wantAbort: true,
+	}, {
+		name:     "total timeout before first byte",
+		handler:  streaming(1, time.Second),
+		opts:     TimeoutOptions{Timeout: 50 * time.Millisecond, Message: "timeout"},
+		wantCode: http.StatusGatewayTimeout,
+		wantBody: "timeout",
+	}, {
+		name:    "total timeout while streaming",
+		handler: streaming(100, 10*time.Millisecond),
+		opts: TimeoutOptions{
+			IdleTimeout: 100 * time.Millisecond,
+			Timeout:     100 * time.Millisecond,
+		},
+		wantAbort: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w, panicked := serve(NewTimeoutHandler(test.handler, test.opts))
+			if test.wantAbort {
+				if panicked != http.ErrAbortHandler {
+					t.Fatalf("Panic = %v, want %v", panicked, http.ErrAbortHandler)
+				}
+				return
+			}
+			if panicked != nil {
+				t.Fatal("Unexpected panic:", panicked)
+			}
+			if w.Code != test.wantCode {
+				t.Errorf("StatusCode = %d, want %d", w.Code, test.wantCode)
+			}
+			if got := w.Body.String(); got != test.wantBody {
+				t.Errorf("Body = %q, want %q", got, test.wantBody)
+			}
+		})
+	}
+}
+
+func TestTimeoutHandlerFlushes(t *testing.T) {
+	w, _ := serve(NewTimeoutHandler(streaming(1, 0), TimeoutOptions{FirstByteTimeout: time.Second}))
+	if !w.Flushed {
+		t.Error("Flush was not passed through")
+	}
+}
+
+func TestTimeoutHandlerCancelsContext(t *testing.T) {
+	canceled := make(chan struct{})
+	h := NewTimeoutHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(canceled)
+	}), TimeoutOptions{FirstByteTimeout: 10 * time.Millisecond})
+
+	serve(h)
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error("The request's context was not canceled")
+	}
+}
+
+func TestTimeoutHandlerPanics(t *testing.T) {
+	h := NewTimeoutHandler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}), TimeoutOptions{FirstByteTimeout: time.Second})
+
+	if _, panicked := serve(h); panicked != "boom" {
+		t.Errorf("Panic = %v, want boom", panicked)
+	}
+}
+
+func TestTimeoutHandlerHijack(t *testing.T) {
+	h := NewTimeoutHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error("Hijack() =", err)
+			return
+		}
+		defer conn.Close()
+		// Outlive all timeouts before responding.
+		time.Sleep(100 * time.Millisecond)
+		rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		rw.Flush()
+	}), TimeoutOptions{
+		FirstByteTimeout: 20 * time.Millisecond,
+		Timeout:          20 * time.Millisecond,
+	})
+
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	resp, err := http.Get(s.URL)
+	if err != nil {
+		t.Fatal("Get() =", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "hijacked" {
+		t.Errorf("Response = %d %q, want 200 %q", resp.StatusCode, body, "hijacked")
+	}
+}
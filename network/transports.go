@@ -0,0 +1,160 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"net/http"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// RoundTripperFunc implementation roundtrips a request.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper.
+func (rt RoundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return rt(r)
+}
+
+// RetryPolicy defines which requests a retrying transport retries and how.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a request is sent,
+	// including the first attempt.
+	MaxAttempts int
+
+	// Backoff defines the time to wait between two attempts. Its Steps
+	// field is ignored in favor of MaxAttempts.
+	Backoff wait.Backoff
+
+	// RetryError decides whether a request that failed with the given error
+	// is retried. If nil, no errors are retried.
+	RetryError func(error) bool
+
+	// RetryStatus decides whether a request that got a response with the
+	// given status code is retried. If nil, no responses are retried.
+	RetryStatus func(int) bool
+
+	// RetryNonIdempotent allows retrying requests with non-idempotent
+	// methods, like POST, after they might have reached the server.
+	// Requests that failed to be dialed are always safe to retry.
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy returns a RetryPolicy that retries requests which
+// failed to connect to the server.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		Backoff: wait.Backoff{
+			Duration: 50 * time.Millisecond,
+			Factor:   1.4,
+			Jitter:   0.1,
+		},
+		RetryError: IsDialError,
+	}
+}
+
+// RetryStatusCodes returns a function for RetryPolicy.RetryStatus that
+// retries the given status codes.
+func RetryStatusCodes(codes ...int) func(int) bool {
+	retry := make(map[int]bool, len(codes))
+	for _, code := range codes {
+		retry[code] = true
+	}
+	return func(code int) bool {
+		return retry[code]
+	}
+}
+
+// NewRetryingTransport wraps the given transport to retry requests
+// according to the given policy. Requests with a body are only retried
+// if the body can be replayed, i.e. if http.Request.GetBody is set.
+func NewRetryingTransport(inner http.RoundTripper, policy RetryPolicy) http.RoundTripper {
+	return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		backoff := policy.Backoff
+		for attempt := 1; ; attempt++ {
+			req := r
+			if attempt > 1 {
+				var err error
+				if req, err = rewind(r); err != nil {
+					return nil, err
+				}
+			}
+
+			resp, err := inner.RoundTrip(req)
+			if attempt >= policy.MaxAttempts || !policy.shouldRetry(r, resp, err) {
+				return resp, err
+			}
+			if resp != nil {
+				resp.Body.Close()
+			}
+
+			select {
+			case <-time.After(backoff.Step()):
+			case <-r.Context().Done():
+				return nil, r.Context().Err()
+			}
+		}
+	})
+}
+
+// shouldRetry decides whether the given outcome of a request is retried.
+func (p RetryPolicy) shouldRetry(r *http.Request, resp *http.Response, err error) bool {
+	if !canReplayBody(r) {
+		return false
+	}
+	if err != nil {
+		if p.RetryError == nil || !p.RetryError(err) {
+			return false
+		}
+		return p.RetryNonIdempotent || isIdempotent(r.Method) || IsDialError(err)
+	}
+	if p.RetryStatus == nil || !p.RetryStatus(resp.StatusCode) {
+		return false
+	}
+	return p.RetryNonIdempotent || isIdempotent(r.Method)
+}
+
+// isIdempotent returns true if the method is idempotent as defined by
+// RFC 7231, section 4.2.2.
+func isIdempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// canReplayBody returns true if the request's body can be sent again.
+func canReplayBody(r *http.Request) bool {
+	return r.Body == nil || r.Body == http.NoBody || r.GetBody != nil
+}
+
+// rewind returns a copy of the request with a fresh body.
+func rewind(r *http.Request) (*http.Request, error) {
+	req := r.Clone(r.Context())
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = body
+	}
+	return req, nil
+}
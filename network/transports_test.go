@@ -0,0 +1,199 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+var (
+	dialErr = &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	readErr = &net.OpError{Op: "read", Err: errors.New("connection reset")}
+)
+
+func testPolicy() RetryPolicy {
+	p := DefaultRetryPolicy()
+	p.MaxAttempts = 3
+	p.Backoff = wait.Backoff{Duration: time.Millisecond}
+	return p
+}
+
+// sequence returns a RoundTripper returning the given results in turn and
+// recording the request bodies it has seen.
+func sequence(bodies *[]string, results ...func() (*http.Response, error)) http.RoundTripper {
+	i := 0
+	return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Body != nil {
+			b, _ := ioutil.ReadAll(r.Body)
+			*bodies = append(*bodies, string(b))
+		}
+		res := results[i]
+		i++
+		return res()
+	})
+}
+
+func fail(err error) func() (*http.Response, error) {
+	return func() (*http.Response, error) {
+		return nil, err
+	}
+}
+
+func status(code int) func() (*http.Response, error) {
+	return func() (*http.Response, error) {
+		return &http.Response{StatusCode: code, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	}
+}
+
+func TestRetryingTransport(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     func(*RetryPolicy)
+		method     string
+		body       string
+		results    []func() (*http.Response, error)
+		wantStatus int
+		wantErr    bool
+		wantBodies int
+	}{{
+		name:       "dial error is retried",
+		method:     http.MethodGet,
+		results:    []func() (*http.Response, error){fail(dialErr), status(http.StatusOK)},
+		wantStatus: http.StatusOK,
+	}, {
+		name:    "attempts are bounded",
+		method:  http.MethodGet,
+		results: []func() (*http.Response, error){fail(dialErr), fail(dialErr), fail(dialErr)},
+		wantErr: true,
+	}, {
+		name:    "other errors are not retried by default",
+		method:  http.MethodGet,
+		results: []func() (*http.Response, error){fail(readErr)},
+		wantErr: true,
+	}, {
+		name: "custom errors are retried for idempotent requests",
+		policy: func(p *RetryPolicy) {
+			p.RetryError = func(error) bool { return true }
+		},
+		method:     http.MethodGet,
+		results:    []func() (*http.Response, error){fail(readErr), status(http.StatusOK)},
+		wantStatus: http.StatusOK,
+	}, {
+		name: "custom errors are not retried for non-idempotent requests",
+		policy: func(p *RetryPolicy) {
+			p.RetryError = func(error) bool { return true }
+		},
+		method:     http.MethodPost,
+		body:       "body",
+		results:    []func() (*http.Response, error){fail(readErr)},
+		wantErr:    true,
+		wantBodies: 1,
+	}, {
+		name: "non-idempotent requests can be allowed",
+		policy: func(p *RetryPolicy) {
+			p.RetryError = func(error) bool { return true }
+			p.RetryNonIdempotent = true
+		},
+		method:     http.MethodPost,
+		body:       "body",
+		results:    []func() (*http.Response, error){fail(readErr), status(http.StatusOK)},
+		wantStatus: http.StatusOK,
+		wantBodies: 2,
+	}, {
+		name:       "dial errors of non-idempotent requests are retried and the body replayed",
+		method:     http.MethodPost,
+		body:       "body",
+		results:    []func() (*http.Response, error){fail(dialErr), status(http.StatusOK)},
+		wantStatus: http.StatusOK,
+		wantBodies: 2,
+	}, {
+		name: "status codes are retried",
+		policy: func(p *RetryPolicy) {
+			p.RetryStatus = RetryStatusCodes(http.StatusServiceUnavailable)
+		},
+		method:     http.MethodGet,
+		results:    []func() (*http.Response, error){status(http.StatusServiceUnavailable), status(http.StatusOK)},
+		wantStatus: http.StatusOK,
+	}, {
+		name: "last response is returned",
+		policy: func(p *RetryPolicy) {
+			p.RetryStatus = RetryStatusCodes(http.StatusServiceUnavailable)
+		},
+		method: http.MethodGet,
+		results: []func() (*http.Response, error){
+			status(http.StatusServiceUnavailable),
+			status(http.StatusServiceUnavailable),
+			status(http.StatusServiceUnavailable),
+		},
+		wantStatus: http.StatusServiceUnavailable,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy := testPolicy()
+			if test.policy != nil {
+				test.policy(&policy)
+			}
+			var bodies []string
+			rt := NewRetryingTransport(sequence(&bodies, test.results...), policy)
+
+			var req *http.Request
+			if test.body != "" {
+				req, _ = http.NewRequest(test.method, "http://example.com", bytes.NewBufferString(test.body))
+			} else {
+				req, _ = http.NewRequest(test.method, "http://example.com", nil)
+			}
+
+			resp, err := rt.RoundTrip(req)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("RoundTrip() = %v, wantErr = %v", err, test.wantErr)
+			}
+			if err == nil && resp.StatusCode != test.wantStatus {
+				t.Errorf("StatusCode = %d, want %d", resp.StatusCode, test.wantStatus)
+			}
+			if len(bodies) != test.wantBodies {
+				t.Errorf("Saw %d bodies, want %d", len(bodies), test.wantBodies)
+			}
+			for _, b := range bodies {
+				if b != test.body {
+					t.Errorf("Body = %q, want %q", b, test.body)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryingTransportUnreplayableBody(t *testing.T) {
+	var bodies []string
+	rt := NewRetryingTransport(sequence(&bodies, fail(dialErr)), testPolicy())
+
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com", nil)
+	req.Body = ioutil.NopCloser(strings.NewReader("body"))
+
+	if _, err := rt.RoundTrip(req); err == nil {
+		t.Error("RoundTrip() = nil, wanted an error")
+	}
+}
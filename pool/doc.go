@@ -0,0 +1,30 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package pool runs work items on a bounded number of goroutines and
+// aggregates their errors, e.g.:
+//
+//	p, ctx := pool.NewWithContext(ctx, 10)
+//	for _, item := range items {
+//		item := item
+//		p.Go(func() error {
+//			return process(ctx, item)
+//		})
+//	}
+//	if err := p.Wait(); err != nil {
+//		...
+//	}
+package pool
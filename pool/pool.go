@@ -0,0 +1,207 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// Interface defines an errgroup-like interface for running work items on
+// a bounded number of goroutines.
+type Interface interface {
+	// Go schedules the given work item. It blocks until one of the pool's
+	// workers picks it up. Go must not be called after Wait.
+	Go(func() error)
+
+	// Wait waits for all of the scheduled work items to complete and
+	// returns the errors they returned, aggregated into one error which
+	// errors.Is and errors.As see through, or nil if all of them succeeded.
+	Wait() error
+}
+
+// PanicError is the error of a work item which panicked.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value interface{}
+	// Stack is the stack trace of the panicking goroutine.
+	Stack []byte
+}
+
+// Error implements error.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("work item panicked: %v\n%s", e.Value, e.Stack)
+}
+
+type impl struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	work    chan func() error
+	workers sync.WaitGroup
+	once    sync.Once
+
+	mu   sync.Mutex
+	errs []error
+}
+
+// New creates a pool running work items on the given number of goroutines.
+// All work items run, regardless of the errors of the others.
+func New(workers int) Interface {
+	p, _ := newPool(context.Background(), workers, false)
+	return p
+}
+
+// NewWithContext creates a pool running work items on the given number of
+// goroutines, along with a context derived from the given one. The context
+// is cancelled as soon as a work item fails, or once Wait returns. Work
+// items which haven't started by the time the context is cancelled are
+// skipped, and the context's error is reported once for all of them.
+func NewWithContext(ctx context.Context, workers int) (Interface, context.Context) {
+	return newPool(ctx, workers, true)
+}
+
+func newPool(ctx context.Context, workers int, failFast bool) (*impl, context.Context) {
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	p := &impl{
+		ctx:    ctx,
+		cancel: cancel,
+		work:   make(chan func() error),
+	}
+	if !failFast {
+		// Only Wait cancels the context.
+		p.ctx = context.Background()
+	}
+	p.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer p.workers.Done()
+			for w := range p.work {
+				p.run(w, failFast)
+			}
+		}()
+	}
+	return p, ctx
+}
+
+// Go implements Interface.
+func (p *impl) Go(w func() error) {
+	p.work <- w
+}
+
+// Wait implements Interface.
+func (p *impl) Wait() error {
+	p.once.Do(func() {
+		close(p.work)
+	})
+	p.workers.Wait()
+	p.cancel()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.errs) == 0 {
+		return nil
+	}
+	return multiError(p.errs)
+}
+
+// run runs the work item, recording its error.
+func (p *impl) run(w func() error, failFast bool) {
+	if err := p.ctx.Err(); err != nil {
+		p.record(err, false)
+		return
+	}
+	p.record(safeRun(w), failFast)
+}
+
+// record records the error, if any, cancelling the context if requested.
+func (p *impl) record(err error, cancel bool) {
+	if err == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// Report the context's error only once, rather than for every
+	// skipped work item.
+	if err == p.ctx.Err() {
+		for _, e := range p.errs {
+			if e == err {
+				return
+			}
+		}
+	}
+	p.errs = append(p.errs, err)
+	if cancel {
+		p.cancel()
+	}
+}
+
+// multiError aggregates the errors of the work items.
+type multiError []error
+
+// Error implements error, with the message of each error on its own line.
+func (m multiError) Error() string {
+	msgs := make([]string, len(m))
+	for i, err := range m {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Errors returns the aggregated errors.
+func (m multiError) Errors() []error {
+	return m
+}
+
+// Is returns true if any of the aggregated errors matches the target, for
+// errors.Is.
+func (m multiError) Is(target error) bool {
+	for _, err := range m {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the aggregated errors which matches the target, for
+// errors.As.
+func (m multiError) As(target interface{}) bool {
+	for _, err := range m {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// safeRun runs the work item, turning panics into a *PanicError.
+func safeRun(w func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return w()
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBoundedConcurrency(t *testing.T) {
+	const workers = 3
+	p := New(workers)
+
+	var running, max int32
+	for i := 0; i < 20; i++ {
+		p.Go(func() error {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		})
+	}
+	if err := p.Wait(); err != nil {
+		t.Fatal("Wait() =", err)
+	}
+	if max > workers {
+		t.Errorf("Ran %d work items concurrently, want at most %d", max, workers)
+	}
+}
+
+func TestErrorsAreAggregated(t *testing.T) {
+	p := New(2)
+	errA, errB := errors.New("a"), errors.New("b")
+	var ran int32
+	for _, err := range []error{errA, nil, errB, nil} {
+		err := err
+		p.Go(func() error {
+			atomic.AddInt32(&ran, 1)
+			return err
+		})
+	}
+	err := p.Wait()
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Errorf("Wait() = %v, want both errors", err)
+	}
+	if got, want := len(err.(interface{ Errors() []error }).Errors()), 2; got != want {
+		t.Errorf("Wait() aggregated %d errors, want %d", got, want)
+	}
+	if ran != 4 {
+		t.Errorf("Ran %d work items, want all 4", ran)
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	p := New(1)
+	p.Go(func() error {
+		panic("boom")
+	})
+	p.Go(func() error {
+		return nil
+	})
+
+	var pe *PanicError
+	if err := p.Wait(); !errors.As(err, &pe) {
+		t.Fatalf("Wait() = %v, want a *PanicError", err)
+	}
+	if pe.Value != "boom" || len(pe.Stack) == 0 {
+		t.Errorf("PanicError = %v, want the panic's value and stack", pe)
+	}
+}
+
+func TestNewWithContextFailsFast(t *testing.T) {
+	p, ctx := NewWithContext(context.Background(), 1)
+	errBoom := errors.New("boom")
+
+	var ran int32
+	p.Go(func() error {
+		return errBoom
+	})
+	// The context is cancelled by the failure, so the following items are
+	// skipped once the worker gets to them.
+	for i := 0; i < 5; i++ {
+		p.Go(func() error {
+			atomic.AddInt32(&ran, 1)
+			return nil
+		})
+	}
+
+	err := p.Wait()
+	if !errors.Is(err, errBoom) {
+		t.Errorf("Wait() = %v, want %v", err, errBoom)
+	}
+	if ctx.Err() == nil {
+		t.Error("The context hasn't been cancelled")
+	}
+	if ran > 1 {
+		t.Errorf("Ran %d work items after the failure, want at most 1", ran)
+	}
+}
+
+func TestNewWithContextCancelled(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p, _ := NewWithContext(parent, 2)
+	for i := 0; i < 5; i++ {
+		p.Go(func() error {
+			t.Error("A work item ran with a cancelled context")
+			return nil
+		})
+	}
+	err := p.Wait()
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait() = %v, want %v", err, context.Canceled)
+	}
+	// The context's error is only reported once.
+	if got, want := err.Error(), context.Canceled.Error(); got != want {
+		t.Errorf("Wait() = %q, want %q", got, want)
+	}
+}
+
+func TestWaitWithoutWork(t *testing.T) {
+	p, ctx := NewWithContext(context.Background(), 4)
+	if err := p.Wait(); err != nil {
+		t.Error("Wait() =", err)
+	}
+	if ctx.Err() == nil {
+		t.Error("The context hasn't been cancelled by Wait")
+	}
+	// Wait is idempotent.
+	if err := p.Wait(); err != nil {
+		t.Error("Wait() =", err)
+	}
+}
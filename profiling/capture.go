@@ -0,0 +1,272 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package profiling
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	perrors "github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	"knative.dev/pkg/changeset"
+)
+
+const (
+	// DefaultCaptureDuration is the duration of the CPU profile of a
+	// capture if none is given.
+	DefaultCaptureDuration = 30 * time.Second
+
+	// maxCaptureDuration bounds the duration of the CPU profile of a capture
+	// requested through the Handler.
+	maxCaptureDuration = 5 * time.Minute
+
+	// gcsScope is the OAuth2 scope needed to upload objects to GCS.
+	gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+	// gcsUploadEndpoint is the endpoint of the GCS JSON upload API.
+	gcsUploadEndpoint = "https://storage.googleapis.com/upload/storage/v1"
+)
+
+// Uploader stores captured profiles.
+type Uploader interface {
+	// Upload stores the data under the given name, along with the metadata.
+	Upload(ctx context.Context, name string, data []byte, metadata map[string]string) error
+}
+
+// cpuProfiling serializes the CPU profiles collected by the package, as the
+// runtime collects one at a time.
+var cpuProfiling = &cpuProfileLock{sem: make(chan struct{}, 1)}
+
+// cpuProfileLock is held while collecting a CPU profile. The continuous
+// profiling agents hold it in the background, yielding it to the profiles
+// requested on demand.
+type cpuProfileLock struct {
+	sem chan struct{}
+
+	mu sync.Mutex
+	// yield is closed to ask the background holder to release the lock,
+	// nil if the lock isn't held in the background.
+	yield chan struct{}
+}
+
+// lock acquires the lock for a profile requested on demand, asking the
+// background holder to release it if any.
+func (l *cpuProfileLock) lock(ctx context.Context) error {
+	l.mu.Lock()
+	if l.yield != nil {
+		close(l.yield)
+		l.yield = nil
+	}
+	l.mu.Unlock()
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// tryLockBackground acquires the lock in the background if it is free. The
+// returned channel is closed when the lock is requested on demand.
+func (l *cpuProfileLock) tryLockBackground() (<-chan struct{}, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case l.sem <- struct{}{}:
+	default:
+		return nil, false
+	}
+	l.yield = make(chan struct{})
+	return l.yield, true
+}
+
+func (l *cpuProfileLock) unlock() {
+	l.mu.Lock()
+	l.yield = nil
+	l.mu.Unlock()
+	<-l.sem
+}
+
+// Capture captures the heap and goroutine profiles, as well as a CPU
+// profile over the given duration. The profiles are returned in the
+// gzipped protobuf format understood by `go tool pprof`, keyed by their
+// file names. It takes over the CPU profiling of the continuous profiling
+// agent, if any, which resumes afterwards.
+func Capture(ctx context.Context, cpuDuration time.Duration) (map[string][]byte, error) {
+	profiles := make(map[string][]byte, 3)
+
+	if err := cpuProfiling.lock(ctx); err != nil {
+		return nil, err
+	}
+	var cpu bytes.Buffer
+	if err := pprof.StartCPUProfile(&cpu); err != nil {
+		cpuProfiling.unlock()
+		return nil, perrors.Wrap(err, "failed to start the CPU profile")
+	}
+	select {
+	case <-time.After(cpuDuration):
+	case <-ctx.Done():
+	}
+	pprof.StopCPUProfile()
+	cpuProfiling.unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	profiles["cpu.pb.gz"] = cpu.Bytes()
+
+	for _, name := range []string{"heap", "goroutine"} {
+		var b bytes.Buffer
+		if err := pprof.Lookup(name).WriteTo(&b, 0); err != nil {
+			return nil, perrors.Wrapf(err, "failed to write the %s profile", name)
+		}
+		profiles[name+".pb.gz"] = b.Bytes()
+	}
+	return profiles, nil
+}
+
+// CaptureAndUpload captures profiles as Capture does and uploads them below
+// "<prefix>/<pod>/<time>/", with metadata identifying the pod and the
+// commit it runs. The pod is named by the POD_NAME environment variable,
+// or by the hostname. It returns the directory the profiles were uploaded
+// to.
+func CaptureAndUpload(ctx context.Context, u Uploader, prefix string, cpuDuration time.Duration) (string, error) {
+	start := time.Now().UTC()
+	profiles, err := Capture(ctx, cpuDuration)
+	if err != nil {
+		return "", err
+	}
+
+	pod := podName()
+	metadata := map[string]string{
+		"pod":         pod,
+		"captured-at": start.Format(time.RFC3339),
+		"duration":    cpuDuration.String(),
+	}
+	if commit, err := changeset.Get(); err == nil {
+		metadata["commit"] = commit
+	}
+
+	dir := path.Join(prefix, pod, start.Format("20060102T150405Z"))
+	for name, data := range profiles {
+		if err := u.Upload(ctx, path.Join(dir, name), data, metadata); err != nil {
+			return "", perrors.Wrapf(err, "failed to upload %s", name)
+		}
+	}
+	return dir, nil
+}
+
+// podName returns the name of the pod the process runs in.
+func podName() string {
+	if name := os.Getenv("POD_NAME"); name != "" {
+		return name
+	}
+	if name, err := os.Hostname(); err == nil {
+		return name
+	}
+	return "unknown"
+}
+
+// GCSUploader uploads profiles to a Google Cloud Storage bucket.
+type GCSUploader struct {
+	// Bucket is the name of the bucket uploaded to.
+	Bucket string
+
+	client   *http.Client
+	endpoint string
+}
+
+// NewGCSUploader returns a GCSUploader for the given bucket, authenticated
+// with the application default credentials.
+func NewGCSUploader(ctx context.Context, bucket string) (*GCSUploader, error) {
+	client, err := google.DefaultClient(ctx, gcsScope)
+	if err != nil {
+		return nil, perrors.Wrap(err, "failed to create the GCS client")
+	}
+	return &GCSUploader{Bucket: bucket, client: client, endpoint: gcsUploadEndpoint}, nil
+}
+
+// Upload implements Uploader, storing the metadata as the object's custom
+// metadata.
+func (u *GCSUploader) Upload(ctx context.Context, name string, data []byte, metadata map[string]string) error {
+	meta, err := json.Marshal(struct {
+		Name     string            `json:"name"`
+		Metadata map[string]string `json:"metadata,omitempty"`
+	}{name, metadata})
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		data        []byte
+	}{{"application/json; charset=UTF-8", meta}, {"application/octet-stream", data}} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(part.data); err != nil {
+			return err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	target := fmt.Sprintf("%s/b/%s/o?uploadType=multipart", u.endpoint, url.PathEscape(u.Bucket))
+	req, err := http.NewRequest(http.MethodPost, target, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "multipart/related; boundary="+mw.Boundary())
+	resp, err := u.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("uploading gs://%s/%s failed with status %d: %s", u.Bucket, name, resp.StatusCode, msg)
+	}
+	return nil
+}
+
+// parseBucket splits a location like "bucket/some/prefix" into the bucket
+// and the prefix.
+func parseBucket(location string) (bucket, prefix string) {
+	location = strings.TrimPrefix(location, "gs://")
+	parts := strings.SplitN(location, "/", 2)
+	if len(parts) == 2 {
+		return parts[0], strings.Trim(parts[1], "/")
+	}
+	return parts[0], ""
+}
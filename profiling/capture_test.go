@@ -0,0 +1,226 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package profiling
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// fakeUploader records the uploaded objects.
+type fakeUploader struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	metadata map[string]string
+}
+
+func (u *fakeUploader) Upload(_ context.Context, name string, data []byte, metadata map[string]string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.objects == nil {
+		u.objects = make(map[string][]byte)
+	}
+	u.objects[name] = data
+	u.metadata = metadata
+	return nil
+}
+
+func (u *fakeUploader) names() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	var names []string
+	for name := range u.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestCaptureAndUpload(t *testing.T) {
+	os.Setenv("POD_NAME", "controller-abcde")
+	defer os.Unsetenv("POD_NAME")
+
+	u := &fakeUploader{}
+	dir, err := CaptureAndUpload(context.Background(), u, "profiles", 10*time.Millisecond)
+	if err != nil {
+		t.Fatal("CaptureAndUpload() =", err)
+	}
+	if !strings.HasPrefix(dir, "profiles/controller-abcde/") {
+		t.Errorf("CaptureAndUpload() = %q, want a directory below profiles/controller-abcde/", dir)
+	}
+
+	want := []string{
+		path.Join(dir, "cpu.pb.gz"),
+		path.Join(dir, "goroutine.pb.gz"),
+		path.Join(dir, "heap.pb.gz"),
+	}
+	if diff := cmp.Diff(want, u.names()); diff != "" {
+		t.Errorf("Uploaded objects (-want, +got) = %s", diff)
+	}
+	for name, data := range u.objects {
+		if len(data) == 0 {
+			t.Errorf("Object %s is empty", name)
+		}
+	}
+	if got := u.metadata["pod"]; got != "controller-abcde" {
+		t.Errorf("pod metadata = %q, want controller-abcde", got)
+	}
+}
+
+func TestCaptureCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Capture(ctx, time.Minute); err != context.Canceled {
+		t.Errorf("Capture() = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestGCSUploader(t *testing.T) {
+	var gotPath, gotMeta, gotData string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.RequestURI()
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			t.Error("ParseMediaType() =", err)
+			return
+		}
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		for _, got := range []*string{&gotMeta, &gotData} {
+			p, err := mr.NextPart()
+			if err != nil {
+				t.Error("NextPart() =", err)
+				return
+			}
+			b, _ := ioutil.ReadAll(p)
+			*got = string(b)
+		}
+	}))
+	defer server.Close()
+
+	u := &GCSUploader{Bucket: "bucket", client: server.Client(), endpoint: server.URL}
+	if err := u.Upload(context.Background(), "pod/heap.pb.gz", []byte("profile"), map[string]string{"pod": "pod"}); err != nil {
+		t.Fatal("Upload() =", err)
+	}
+
+	if want := "/b/bucket/o?uploadType=multipart"; gotPath != want {
+		t.Errorf("Path = %q, want %q", gotPath, want)
+	}
+	var meta struct {
+		Name     string
+		Metadata map[string]string
+	}
+	if err := json.Unmarshal([]byte(gotMeta), &meta); err != nil {
+		t.Fatalf("Unmarshal(%q) = %v", gotMeta, err)
+	}
+	if meta.Name != "pod/heap.pb.gz" || meta.Metadata["pod"] != "pod" {
+		t.Errorf("Metadata = %+v", meta)
+	}
+	if gotData != "profile" {
+		t.Errorf("Data = %q, want profile", gotData)
+	}
+}
+
+func TestGCSUploaderError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "denied", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	u := &GCSUploader{Bucket: "bucket", client: server.Client(), endpoint: server.URL}
+	if err := u.Upload(context.Background(), "heap.pb.gz", nil, nil); err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Errorf("Upload() = %v, want an error containing the response", err)
+	}
+}
+
+func TestCaptureEndpoint(t *testing.T) {
+	u := &fakeUploader{}
+	var gotBucket string
+	defer func(old func(context.Context, string) (Uploader, error)) { newUploader = old }(newUploader)
+	newUploader = func(_ context.Context, bucket string) (Uploader, error) {
+		gotBucket = bucket
+		return u, nil
+	}
+
+	handler := NewHandler(zap.NewNop().Sugar(), true)
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(method, target, nil))
+		return rr
+	}
+
+	if rr := serve(http.MethodPost, "/debug/pprof/capture"); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Capture without location: StatusCode = %v, want %v", rr.Code, http.StatusServiceUnavailable)
+	}
+
+	handler.UpdateFromConfigMap(&corev1.ConfigMap{Data: map[string]string{
+		"profiling.enable":           "true",
+		"profiling.capture-location": "gs://bucket/profiles",
+	}})
+	if gotBucket != "bucket" {
+		t.Errorf("Uploader bucket = %q, want bucket", gotBucket)
+	}
+
+	if rr := serve(http.MethodGet, "/debug/pprof/capture"); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: StatusCode = %v, want %v", rr.Code, http.StatusMethodNotAllowed)
+	}
+	if rr := serve(http.MethodPost, "/debug/pprof/capture?seconds=soon"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Invalid seconds: StatusCode = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	// Make the capture short.
+	rr := serve(http.MethodPost, "/debug/pprof/capture?seconds=1")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("StatusCode = %v, want %v: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+	if got := rr.Body.String(); !strings.HasPrefix(got, "gs://bucket/profiles/") {
+		t.Errorf("Body = %q, want the location of the profiles", got)
+	}
+	if got := len(u.names()); got != 3 {
+		t.Errorf("Uploaded %d objects, want 3", got)
+	}
+}
+
+func TestParseBucket(t *testing.T) {
+	tests := []struct {
+		location, bucket, prefix string
+	}{
+		{"bucket", "bucket", ""},
+		{"gs://bucket/", "bucket", ""},
+		{"bucket/some/prefix/", "bucket", "some/prefix"},
+	}
+	for _, tt := range tests {
+		if bucket, prefix := parseBucket(tt.location); bucket != tt.bucket || prefix != tt.prefix {
+			t.Errorf("parseBucket(%q) = %q, %q, want %q, %q", tt.location, bucket, prefix, tt.bucket, tt.prefix)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package profiling
+
+import (
+	"strconv"
+	"strings"
+
+	perrors "github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// profilesKey is the name of the key in config-observability config map
+	// that holds the comma-separated list of the profiles served. All
+	// profiles are served if it is empty.
+	profilesKey = "profiling.profiles"
+
+	// blockProfileRateKey is the name of the key in config-observability
+	// config map that holds the block profile rate, see
+	// runtime.SetBlockProfileRate.
+	blockProfileRateKey = "profiling.block-profile-rate"
+
+	// mutexProfileFractionKey is the name of the key in config-observability
+	// config map that holds the mutex profile fraction, see
+	// runtime.SetMutexProfileFraction.
+	mutexProfileFractionKey = "profiling.mutex-profile-fraction"
+
+	// captureLocationKey is the name of the key in config-observability
+	// config map that holds the GCS location, like "bucket/some/prefix",
+	// profiles captured on demand are uploaded to.
+	captureLocationKey = "profiling.capture-location"
+
+	// continuousBackendKey, continuousServerAddressKey and
+	// continuousServiceKey are the names of the keys in config-observability
+	// config map that configure continuous profiling.
+	continuousBackendKey       = "profiling.continuous.backend"
+	continuousServerAddressKey = "profiling.continuous.server-address"
+	continuousServiceKey       = "profiling.continuous.service"
+)
+
+// Config is the profiling configuration read from the observability
+// ConfigMap.
+type Config struct {
+	// Enabled defines whether profiling data is served at all.
+	Enabled bool
+
+	// Profiles is the set of profiles served, named like their path below
+	// /debug/pprof/, e.g. "heap", "profile" or "trace". All profiles are
+	// served if it is empty. The index and the symbol lookup are always
+	// served while profiling is enabled.
+	Profiles sets.String
+
+	// BlockProfileRate is the block profile rate applied while profiling is
+	// enabled. Zero disables block profiling.
+	BlockProfileRate int
+
+	// MutexProfileFraction is the mutex profile fraction applied while
+	// profiling is enabled. Zero disables mutex profiling.
+	MutexProfileFraction int
+
+	// CaptureLocation is the GCS location, like "bucket/some/prefix",
+	// profiles captured on demand through /debug/pprof/capture are
+	// uploaded to. Capturing is unavailable if it is empty.
+	CaptureLocation string
+
+	// ContinuousBackend is the name of the backend profiles are
+	// continuously pushed to, see RegisterAgent. Continuous profiling is
+	// independent of Enabled and disabled if this is empty.
+	ContinuousBackend string
+	// ContinuousServerAddress is the address of the continuous profiling
+	// backend, if it needs one.
+	ContinuousServerAddress string
+	// ContinuousService is the name profiles are reported under. It
+	// defaults to the name of the binary.
+	ContinuousService string
+}
+
+// NewConfigFromConfigMap reads the profiling configuration from the given
+// observability ConfigMap.
+func NewConfigFromConfigMap(configMap *corev1.ConfigMap) (*Config, error) {
+	enabled, err := readProfilingFlag(configMap)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{
+		Enabled:         enabled,
+		CaptureLocation: strings.TrimSpace(configMap.Data[captureLocationKey]),
+
+		ContinuousBackend:       strings.TrimSpace(configMap.Data[continuousBackendKey]),
+		ContinuousServerAddress: strings.TrimSpace(configMap.Data[continuousServerAddressKey]),
+		ContinuousService:       strings.TrimSpace(configMap.Data[continuousServiceKey]),
+	}
+
+	if profiles := configMap.Data[profilesKey]; profiles != "" {
+		cfg.Profiles = sets.NewString()
+		for _, p := range strings.Split(profiles, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				cfg.Profiles.Insert(p)
+			}
+		}
+	}
+
+	for key, v := range map[string]*int{
+		blockProfileRateKey:     &cfg.BlockProfileRate,
+		mutexProfileFractionKey: &cfg.MutexProfileFraction,
+	} {
+		raw, ok := configMap.Data[key]
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, perrors.Wrapf(err, "failed to parse %q", key)
+		}
+		if n < 0 {
+			return nil, perrors.Errorf("%q must not be negative, was %d", key, n)
+		}
+		*v = n
+	}
+	return cfg, nil
+}
+
+// serves returns whether the profile with the given name is served.
+func (c *Config) serves(profile string) bool {
+	if !c.Enabled {
+		return false
+	}
+	switch profile {
+	case "", "symbol":
+		return true
+	}
+	return len(c.Profiles) == 0 || c.Profiles.Has(profile)
+}
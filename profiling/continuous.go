@@ -0,0 +1,269 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package profiling
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"strconv"
+	"sync"
+	"time"
+
+	perrors "github.com/pkg/errors"
+	"go.uber.org/zap"
+	"knative.dev/pkg/changeset"
+)
+
+const (
+	// PyroscopeBackend is the name of the built-in continuous profiling
+	// backend pushing CPU and heap profiles to a Pyroscope server.
+	PyroscopeBackend = "pyroscope"
+
+	// defaultUploadPeriod is the period at which the profiles are pushed.
+	defaultUploadPeriod = time.Minute
+	// defaultCPUWindow is the duration of the CPU profile collected every
+	// period, the rest of the period the CPU isn't profiled.
+	defaultCPUWindow = 10 * time.Second
+)
+
+// AgentOptions configures a continuous profiling agent.
+type AgentOptions struct {
+	// ServiceName is the name profiles are reported under.
+	ServiceName string
+	// Version is the version of the service, the commit it was built from
+	// if known.
+	Version string
+	// ServerAddress is the address of the profiling backend, if it needs
+	// one.
+	ServerAddress string
+	// Logger is the logger of the agent.
+	Logger *zap.SugaredLogger
+}
+
+// Agent continuously collects profiles and pushes them to a backend.
+type Agent interface {
+	// Stop stops the agent.
+	Stop()
+}
+
+// StopFunc adapts a function to an Agent.
+type StopFunc func()
+
+// Stop implements Agent.
+func (f StopFunc) Stop() {
+	f()
+}
+
+// AgentFactory starts an Agent.
+type AgentFactory func(AgentOptions) (Agent, error)
+
+var (
+	agentsMu sync.Mutex
+	agents   = map[string]AgentFactory{
+		PyroscopeBackend: newPyroscopeAgent,
+	}
+)
+
+// RegisterAgent makes a continuous profiling backend available under the
+// given name, to be selected through the observability ConfigMap. Binaries
+// which vendor the Cloud Profiler client can register it like:
+//
+//	profiling.RegisterAgent("cloudprofiler", func(o profiling.AgentOptions) (profiling.Agent, error) {
+//		err := profiler.Start(profiler.Config{Service: o.ServiceName, ServiceVersion: o.Version})
+//		// Cloud Profiler can't be stopped.
+//		return profiling.StopFunc(func() {}), err
+//	})
+func RegisterAgent(backend string, f AgentFactory) {
+	agentsMu.Lock()
+	defer agentsMu.Unlock()
+	agents[backend] = f
+}
+
+// startAgent starts the agent of the given backend.
+func startAgent(backend string, opts AgentOptions) (Agent, error) {
+	agentsMu.Lock()
+	f, ok := agents[backend]
+	agentsMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown continuous profiling backend %q", backend)
+	}
+	return f(opts)
+}
+
+// agentOptions returns the AgentOptions of the given configuration.
+func agentOptions(cfg *Config, logger *zap.SugaredLogger) AgentOptions {
+	opts := AgentOptions{
+		ServiceName:   cfg.ContinuousService,
+		ServerAddress: cfg.ContinuousServerAddress,
+		Logger:        logger,
+	}
+	if opts.ServiceName == "" {
+		opts.ServiceName = filepath.Base(os.Args[0])
+	}
+	if commit, err := changeset.Get(); err == nil {
+		opts.Version = commit
+	}
+	return opts
+}
+
+// pyroscopeAgent pushes a CPU profile collected over a short window and a
+// heap profile to a Pyroscope server every period. The CPU profiles requested
+// on demand, through Capture or the Handler, take precedence over its own.
+type pyroscopeAgent struct {
+	opts      AgentOptions
+	period    time.Duration
+	cpuWindow time.Duration
+	client    *http.Client
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+func newPyroscopeAgent(opts AgentOptions) (Agent, error) {
+	if opts.ServerAddress == "" {
+		return nil, perrors.New("the Pyroscope backend needs a server address")
+	}
+	return startPyroscopeAgent(opts, defaultUploadPeriod, defaultCPUWindow, http.DefaultClient), nil
+}
+
+func startPyroscopeAgent(opts AgentOptions, period, cpuWindow time.Duration, client *http.Client) *pyroscopeAgent {
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &pyroscopeAgent{
+		opts:      opts,
+		period:    period,
+		cpuWindow: cpuWindow,
+		client:    client,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	go a.run(ctx)
+	return a
+}
+
+// Stop implements Agent.
+func (a *pyroscopeAgent) Stop() {
+	a.cancel()
+	<-a.done
+}
+
+func (a *pyroscopeAgent) run(ctx context.Context) {
+	defer close(a.done)
+	for ctx.Err() == nil {
+		start := time.Now()
+		a.pushCPU(ctx)
+		a.pushHeap(ctx, start)
+		select {
+		case <-time.After(time.Until(start.Add(a.period))):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// pushCPU collects a CPU profile over the window, or until a CPU profile is
+// requested on demand, and pushes it.
+func (a *pyroscopeAgent) pushCPU(ctx context.Context) {
+	yield, ok := cpuProfiling.tryLockBackground()
+	if !ok {
+		a.opts.Logger.Debug("Skipping the CPU profile, another one is being collected")
+		return
+	}
+	from := time.Now()
+	var b bytes.Buffer
+	if err := pprof.StartCPUProfile(&b); err != nil {
+		// The CPU is profiled outside of the package.
+		cpuProfiling.unlock()
+		a.opts.Logger.Debugw("Failed to start the CPU profile", zap.Error(err))
+		return
+	}
+	select {
+	case <-time.After(a.cpuWindow):
+	case <-yield:
+	case <-ctx.Done():
+	}
+	pprof.StopCPUProfile()
+	cpuProfiling.unlock()
+	if err := a.push(ctx, "cpu", "samples", "sum", from, time.Now(), b.Bytes()); err != nil && ctx.Err() == nil {
+		a.opts.Logger.Warnw("Failed to push the CPU profile", zap.Error(err))
+	}
+}
+
+// pushHeap pushes the heap profile, as of the period started at the given
+// time.
+func (a *pyroscopeAgent) pushHeap(ctx context.Context, from time.Time) {
+	var b bytes.Buffer
+	if err := pprof.Lookup("heap").WriteTo(&b, 0); err != nil {
+		a.opts.Logger.Warnw("Failed to write the heap profile", zap.Error(err))
+		return
+	}
+	if err := a.push(ctx, "heap", "bytes", "average", from, time.Now(), b.Bytes()); err != nil && ctx.Err() == nil {
+		a.opts.Logger.Warnw("Failed to push the heap profile", zap.Error(err))
+	}
+}
+
+// push sends the profile of the given type, collected between from and
+// until, to the server's ingestion API.
+func (a *pyroscopeAgent) push(ctx context.Context, profileType, units, aggregation string, from, until time.Time, profile []byte) error {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	w, err := mw.CreateFormFile("profile", "profile.pprof")
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(profile); err != nil {
+		return err
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	name := a.opts.ServiceName + "." + profileType
+	if a.opts.Version != "" {
+		name += "{version=" + a.opts.Version + "}"
+	}
+	q := url.Values{
+		"name":            {name},
+		"from":            {strconv.FormatInt(from.Unix(), 10)},
+		"until":           {strconv.FormatInt(until.Unix(), 10)},
+		"format":          {"pprof"},
+		"spyName":         {"gospy"},
+		"units":           {units},
+		"aggregationType": {aggregation},
+	}
+	req, err := http.NewRequest(http.MethodPost, a.opts.ServerAddress+"/ingest?"+q.Encode(), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := a.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("pushing the profile failed with status %d: %s", resp.StatusCode, msg)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package profiling
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestPyroscopeAgent(t *testing.T) {
+	pushes := make(chan url.Values, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ingest" {
+			t.Errorf("Path = %q, want /ingest", r.URL.Path)
+		}
+		f, _, err := r.FormFile("profile")
+		if err != nil {
+			t.Error("FormFile() =", err)
+		} else if b, _ := ioutil.ReadAll(f); len(b) == 0 {
+			t.Error("The pushed profile is empty")
+		}
+		pushes <- r.URL.Query()
+	}))
+	defer server.Close()
+
+	a := startPyroscopeAgent(AgentOptions{
+		ServiceName:   "controller",
+		Version:       "abcdef0",
+		ServerAddress: server.URL,
+		Logger:        zap.NewNop().Sugar(),
+	}, 20*time.Millisecond, 10*time.Millisecond, server.Client())
+	defer a.Stop()
+
+	// The CPU profile of a period is pushed, then the heap profile.
+	for _, want := range []string{"controller.cpu{version=abcdef0}", "controller.heap{version=abcdef0}"} {
+		select {
+		case q := <-pushes:
+			if got := q.Get("name"); got != want {
+				t.Errorf("name = %q, want %q", got, want)
+			}
+			if got := q.Get("format"); got != "pprof" {
+				t.Errorf("format = %q, want pprof", got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("No %s profile was pushed", want)
+		}
+	}
+}
+
+func TestCaptureTakesOverPyroscopeAgent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// The agent profiles the CPU for an hour, unless it yields.
+	a := startPyroscopeAgent(AgentOptions{
+		ServiceName:   "controller",
+		ServerAddress: server.URL,
+		Logger:        zap.NewNop().Sugar(),
+	}, time.Hour, time.Hour, server.Client())
+	defer a.Stop()
+	for {
+		cpuProfiling.mu.Lock()
+		profiling := cpuProfiling.yield != nil
+		cpuProfiling.mu.Unlock()
+		if profiling {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	profiles, err := Capture(ctx, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Capture() = %v", err)
+	}
+	if len(profiles["cpu.pb.gz"]) == 0 {
+		t.Error("The captured CPU profile is empty")
+	}
+}
+
+func TestNewPyroscopeAgentNeedsAddress(t *testing.T) {
+	if _, err := newPyroscopeAgent(AgentOptions{ServiceName: "controller"}); err == nil {
+		t.Error("newPyroscopeAgent() = nil, wanted an error")
+	}
+}
+
+func TestContinuousProfilingFromConfigMap(t *testing.T) {
+	var started []AgentOptions
+	stopped := 0
+	RegisterAgent("test", func(o AgentOptions) (Agent, error) {
+		started = append(started, o)
+		return StopFunc(func() { stopped++ }), nil
+	})
+	defer func() {
+		agentsMu.Lock()
+		defer agentsMu.Unlock()
+		delete(agents, "test")
+	}()
+
+	handler := NewHandler(zap.NewNop().Sugar(), false)
+	update := func(data map[string]string) {
+		handler.UpdateFromConfigMap(&corev1.ConfigMap{Data: data})
+	}
+
+	update(map[string]string{
+		"profiling.continuous.backend": "test",
+		"profiling.continuous.service": "webhook",
+	})
+	if len(started) != 1 || started[0].ServiceName != "webhook" {
+		t.Fatalf("Started agents = %+v, want one for webhook", started)
+	}
+
+	// Unrelated changes don't restart the agent.
+	update(map[string]string{
+		"profiling.enable":             "true",
+		"profiling.continuous.backend": "test",
+		"profiling.continuous.service": "webhook",
+	})
+	if len(started) != 1 || stopped != 0 {
+		t.Errorf("Agent restarted on an unrelated change: started %d, stopped %d", len(started), stopped)
+	}
+
+	update(map[string]string{})
+	if stopped != 1 {
+		t.Errorf("Stopped %d agents, want 1", stopped)
+	}
+
+	// Unknown backends are reported, not started.
+	update(map[string]string{"profiling.continuous.backend": "unknown"})
+	if handler.agent != nil {
+		t.Error("An agent was started for an unknown backend")
+	}
+}
@@ -17,10 +17,15 @@ limitations under the License.
 package profiling
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"net/http/pprof"
+	"runtime"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	perrors "github.com/pkg/errors"
 	"go.uber.org/zap"
@@ -31,45 +36,70 @@ const (
 	// ProfilingPort specifies the port where profiling data is available when profiling is enabled
 	ProfilingPort = 8008
 
+	// pprofPrefix is the path below which profiling data is served.
+	pprofPrefix = "/debug/pprof/"
+
 	// profilingKey is the name of the key in config-observability config map
 	// that indicates whether profiling is enabled
 	profilingKey = "profiling.enable"
 )
 
-// Handler holds the main HTTP handler and a flag indicating
-// whether the handler is active
+// Handler holds the main HTTP handler and the profiling configuration
+// defining whether and which profiles it serves
 type Handler struct {
 	enabled    bool
 	enabledMux sync.Mutex
 	handler    http.Handler
 	log        *zap.SugaredLogger
+
+	// config is guarded by enabledMux.
+	config Config
+	// blockProfileRate and mutexProfileFraction are the rates currently
+	// applied to the runtime, guarded by enabledMux.
+	blockProfileRate     int
+	mutexProfileFraction int
+	// uploader uploads the profiles captured on demand to the
+	// config.CaptureLocation, guarded by enabledMux.
+	uploader Uploader
+	// agent is the running continuous profiling agent, guarded by
+	// enabledMux.
+	agent Agent
+}
+
+// newUploader creates the Uploader for the given bucket.
+var newUploader = func(ctx context.Context, bucket string) (Uploader, error) {
+	return NewGCSUploader(ctx, bucket)
 }
 
 // NewHandler create a new ProfilingHandler which serves runtime profiling data
 // according to the given context path
 func NewHandler(logger *zap.SugaredLogger, enableProfiling bool) *Handler {
-	const pprofPrefix = "/debug/pprof/"
+	h := &Handler{
+		enabled: enableProfiling,
+		config:  Config{Enabled: enableProfiling},
+		log:     logger,
+	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc(pprofPrefix, pprof.Index)
 	mux.HandleFunc(pprofPrefix+"cmdline", pprof.Cmdline)
-	mux.HandleFunc(pprofPrefix+"profile", pprof.Profile)
+	mux.HandleFunc(pprofPrefix+"profile", profile)
 	mux.HandleFunc(pprofPrefix+"symbol", pprof.Symbol)
 	mux.HandleFunc(pprofPrefix+"trace", pprof.Trace)
+	mux.HandleFunc(pprofPrefix+"capture", h.capture)
+	h.handler = mux
 
 	logger.Infof("Profiling enabled: %t", enableProfiling)
 
-	return &Handler{
-		enabled: enableProfiling,
-		handler: mux,
-		log:     logger,
-	}
+	return h
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	profile := strings.SplitN(strings.TrimPrefix(r.URL.Path, pprofPrefix), "/", 2)[0]
 	h.enabledMux.Lock()
-	defer h.enabledMux.Unlock()
-	if h.enabled {
+	serves := h.config.serves(profile)
+	h.enabledMux.Unlock()
+	if serves {
 		h.handler.ServeHTTP(w, r)
 	} else {
 		http.NotFoundHandler().ServeHTTP(w, r)
@@ -88,20 +118,129 @@ func readProfilingFlag(configMap *corev1.ConfigMap) (bool, error) {
 	return enabled, nil
 }
 
-// UpdateFromConfigMap modifies the Enabled flag in the Handler
-// according to the value in the given ConfigMap
+// UpdateFromConfigMap modifies the profiling configuration of the Handler
+// according to the values in the given ConfigMap. The block and mutex
+// profile rates are applied to the runtime while profiling is enabled and
+// reset to zero while it is disabled, so they don't cost anything then.
 func (h *Handler) UpdateFromConfigMap(configMap *corev1.ConfigMap) {
-	enabled, err := readProfilingFlag(configMap)
+	cfg, err := NewConfigFromConfigMap(configMap)
 	if err != nil {
-		h.log.Errorw("Failed to update the profiling flag", zap.Error(err))
+		h.log.Errorw("Failed to update the profiling configuration", zap.Error(err))
 		return
 	}
 	h.enabledMux.Lock()
 	defer h.enabledMux.Unlock()
-	if h.enabled != enabled {
-		h.enabled = enabled
+	if h.enabled != cfg.Enabled {
+		h.enabled = cfg.Enabled
 		h.log.Infof("Profiling enabled: %t", h.enabled)
 	}
+
+	blockRate, mutexFraction := cfg.BlockProfileRate, cfg.MutexProfileFraction
+	if !cfg.Enabled {
+		blockRate, mutexFraction = 0, 0
+	}
+	if h.blockProfileRate != blockRate || h.mutexProfileFraction != mutexFraction {
+		h.blockProfileRate, h.mutexProfileFraction = blockRate, mutexFraction
+		runtime.SetBlockProfileRate(blockRate)
+		runtime.SetMutexProfileFraction(mutexFraction)
+		h.log.Infof("Block profile rate: %d, mutex profile fraction: %d", blockRate, mutexFraction)
+	}
+	if !cfg.Profiles.Equal(h.config.Profiles) {
+		if len(cfg.Profiles) == 0 {
+			h.log.Info("Serving all profiles")
+		} else {
+			h.log.Infof("Serving profiles: %v", cfg.Profiles.List())
+		}
+	}
+	if cfg.CaptureLocation != h.config.CaptureLocation {
+		h.uploader = nil
+		if cfg.CaptureLocation != "" {
+			bucket, _ := parseBucket(cfg.CaptureLocation)
+			if h.uploader, err = newUploader(context.Background(), bucket); err != nil {
+				h.log.Errorw("Failed to create the uploader of captured profiles", zap.Error(err))
+			} else {
+				h.log.Infof("Uploading captured profiles to gs://%s", cfg.CaptureLocation)
+			}
+		}
+	}
+	if cfg.ContinuousBackend != h.config.ContinuousBackend ||
+		cfg.ContinuousServerAddress != h.config.ContinuousServerAddress ||
+		cfg.ContinuousService != h.config.ContinuousService {
+		h.restartAgent(cfg)
+	}
+	h.config = *cfg
+}
+
+// restartAgent replaces the running continuous profiling agent by one for
+// the given configuration. It must be called with enabledMux held.
+func (h *Handler) restartAgent(cfg *Config) {
+	if h.agent != nil {
+		h.agent.Stop()
+		h.agent = nil
+		h.log.Info("Stopped continuous profiling")
+	}
+	if cfg.ContinuousBackend == "" {
+		return
+	}
+	opts := agentOptions(cfg, h.log)
+	agent, err := startAgent(cfg.ContinuousBackend, opts)
+	if err != nil {
+		h.log.Errorw("Failed to start continuous profiling", zap.Error(err))
+		return
+	}
+	h.agent = agent
+	h.log.Infof("Continuously profiling %s (version %q) with %s", opts.ServiceName, opts.Version, cfg.ContinuousBackend)
+}
+
+// profile serves the CPU profile like pprof.Profile, taking over the CPU
+// profiling of the continuous profiling agent, if any.
+func profile(w http.ResponseWriter, r *http.Request) {
+	if err := cpuProfiling.lock(r.Context()); err != nil {
+		return
+	}
+	defer cpuProfiling.unlock()
+	pprof.Profile(w, r)
+}
+
+// capture captures profiles over the duration given by the "seconds" query
+// parameter and uploads them to the configured capture location. It
+// responds with the location of the uploaded profiles.
+func (h *Handler) capture(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "profiles must be captured with a POST request", http.StatusMethodNotAllowed)
+		return
+	}
+	duration := DefaultCaptureDuration
+	if s := r.FormValue("seconds"); s != "" {
+		sec, err := strconv.Atoi(s)
+		if err != nil || sec <= 0 {
+			http.Error(w, "invalid seconds: "+s, http.StatusBadRequest)
+			return
+		}
+		duration = time.Duration(sec) * time.Second
+	}
+	if duration > maxCaptureDuration {
+		duration = maxCaptureDuration
+	}
+
+	h.enabledMux.Lock()
+	uploader, location := h.uploader, h.config.CaptureLocation
+	h.enabledMux.Unlock()
+	if uploader == nil {
+		http.Error(w, "no capture location is configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	bucket, prefix := parseBucket(location)
+	dir, err := CaptureAndUpload(r.Context(), uploader, prefix, duration)
+	if err != nil {
+		h.log.Errorw("Failed to capture profiles", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.log.Infof("Uploaded captured profiles to gs://%s/%s", bucket, dir)
+	fmt.Fprintf(w, "gs://%s/%s\n", bucket, dir)
 }
 
 // NewServer creates a new http server that exposes profiling data on the default profiling port
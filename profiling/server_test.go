@@ -19,11 +19,14 @@ package profiling
 import (
 	"net/http"
 	"net/http/httptest"
+	"runtime"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"knative.dev/pkg/metrics"
 	"knative.dev/pkg/system"
 	_ "knative.dev/pkg/system/testing"
@@ -101,3 +104,129 @@ func TestUpdateFromConfigMap(t *testing.T) {
 		})
 	}
 }
+
+func TestProfilesServed(t *testing.T) {
+	handler := NewHandler(zap.NewNop().Sugar(), false)
+	handler.UpdateFromConfigMap(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: system.Namespace(),
+			Name:      metrics.ConfigMapName(),
+		},
+		Data: map[string]string{
+			"profiling.enable":   "true",
+			"profiling.profiles": "heap, goroutine",
+		},
+	})
+
+	tests := map[string]int{
+		"/debug/pprof/":             http.StatusOK,
+		"/debug/pprof/heap":         http.StatusOK,
+		"/debug/pprof/goroutine":    http.StatusOK,
+		"/debug/pprof/symbol":       http.StatusOK,
+		"/debug/pprof/mutex":        http.StatusNotFound,
+		"/debug/pprof/trace":        http.StatusNotFound,
+		"/debug/pprof/threadcreate": http.StatusNotFound,
+	}
+	for path, want := range tests {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal("Error creating request:", err)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("GET %s: StatusCode = %v, want: %v", path, rr.Code, want)
+		}
+	}
+}
+
+func TestProfileRates(t *testing.T) {
+	defer runtime.SetBlockProfileRate(0)
+	defer runtime.SetMutexProfileFraction(0)
+
+	handler := NewHandler(zap.NewNop().Sugar(), false)
+	update := func(data map[string]string) {
+		handler.UpdateFromConfigMap(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: system.Namespace(),
+				Name:      metrics.ConfigMapName(),
+			},
+			Data: data,
+		})
+	}
+
+	update(map[string]string{
+		"profiling.enable":                 "true",
+		"profiling.block-profile-rate":     "1000",
+		"profiling.mutex-profile-fraction": "10",
+	})
+	// SetMutexProfileFraction returns the previous fraction when passed a
+	// negative value.
+	if got := runtime.SetMutexProfileFraction(-1); got != 10 {
+		t.Errorf("Mutex profile fraction = %d, want 10", got)
+	}
+	if handler.blockProfileRate != 1000 {
+		t.Errorf("Block profile rate = %d, want 1000", handler.blockProfileRate)
+	}
+
+	// Disabling profiling turns the profiles off.
+	update(map[string]string{
+		"profiling.enable":                 "false",
+		"profiling.block-profile-rate":     "1000",
+		"profiling.mutex-profile-fraction": "10",
+	})
+	if got := runtime.SetMutexProfileFraction(-1); got != 0 {
+		t.Errorf("Mutex profile fraction = %d, want 0", got)
+	}
+	if handler.blockProfileRate != 0 {
+		t.Errorf("Block profile rate = %d, want 0", handler.blockProfileRate)
+	}
+}
+
+func TestNewConfigFromConfigMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		want    *Config
+		wantErr bool
+	}{{
+		name: "empty",
+		want: &Config{},
+	}, {
+		name: "all set",
+		data: map[string]string{
+			"profiling.enable":                 "true",
+			"profiling.profiles":               "heap,,profile ",
+			"profiling.block-profile-rate":     "1",
+			"profiling.mutex-profile-fraction": "5",
+			"profiling.capture-location":       "bucket/profiles",
+		},
+		want: &Config{
+			Enabled:              true,
+			Profiles:             sets.NewString("heap", "profile"),
+			BlockProfileRate:     1,
+			MutexProfileFraction: 5,
+			CaptureLocation:      "bucket/profiles",
+		},
+	}, {
+		name:    "invalid rate",
+		data:    map[string]string{"profiling.block-profile-rate": "often"},
+		wantErr: true,
+	}, {
+		name:    "negative fraction",
+		data:    map[string]string{"profiling.mutex-profile-fraction": "-1"},
+		wantErr: true,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewConfigFromConfigMap(&corev1.ConfigMap{Data: tt.data})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewConfigFromConfigMap() = %v, wantErr = %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("NewConfigFromConfigMap (-want, +got) = %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,452 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package binding contains a reconciler which implements the common
+// parts of "binding"-style resources: resources which select a subject
+// (by name or by label selector) whose PodSpecable they augment, and which
+// undo their changes when they are deleted.
+package binding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/apis/duck"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	duckv1alpha1 "knative.dev/pkg/apis/duck/v1alpha1"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/tracker"
+)
+
+// Bindable is implemented by Binding resources whose subjects are
+// PodSpecable and that want to leverage the BaseReconciler.
+type Bindable interface {
+	duckv1alpha1.Bindable
+
+	// Do performs this binding's mutation with the specified context on the
+	// provided PodSpecable. The provided context may be decorated by
+	// passing a WithContext to the BaseReconciler.
+	Do(context.Context, *duckv1.WithPod)
+
+	// Undo is the dual of Do, it undoes the binding. It is also applied to
+	// the subjects a label selector stopped selecting since they were bound.
+	Undo(context.Context, *duckv1.WithPod)
+}
+
+// BaseReconciler helps implement controller.Reconciler for Binding resources.
+type BaseReconciler struct {
+	// GVR is the GroupVersionResource of the Binding resources this
+	// reconciles, used to update their status and to name their finalizer.
+	GVR schema.GroupVersionResource
+
+	// Get is a callback to fetch the Bindable with the provided name and
+	// namespace, typically from a lister.
+	Get func(namespace string, name string) (Bindable, error)
+
+	// WithContext is an optional callback to decorate the context passed to
+	// the Bindable's Do and Undo methods.
+	WithContext func(context.Context, Bindable) (context.Context, error)
+
+	// DynamicClient is used to patch the subjects and to update the
+	// Binding resources.
+	DynamicClient dynamic.Interface
+
+	// Factory is used to get listers for the subjects' resources.
+	Factory duck.InformerFactory
+
+	// Tracker is used to track the subjects, so the Binding is reconciled
+	// again when they change.
+	Tracker tracker.Interface
+}
+
+// Check that our Reconciler implements controller.Reconciler
+var _ controller.Reconciler = (*BaseReconciler)(nil)
+
+// Reconcile implements controller.Reconciler
+func (r *BaseReconciler) Reconcile(ctx context.Context, key string) error {
+	logger := logging.FromContext(ctx)
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		logger.Errorw("Invalid resource key", zap.Error(err))
+		return nil
+	}
+
+	original, err := r.Get(namespace, name)
+	if apierrs.IsNotFound(err) {
+		// The resource may no longer exist, in which case we stop processing.
+		logger.Errorf("resource %q no longer exists", key)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Don't modify the informer's copy.
+	resource := original.DeepCopyObject().(Bindable)
+
+	reconcileErr := r.reconcile(ctx, resource)
+	if equality.Semantic.DeepEqual(original.GetBindingStatus(), resource.GetBindingStatus()) {
+		// If we didn't change anything then don't call updateStatus.
+		// This is important because the copy we loaded from the informer's
+		// cache may be stale and we don't want to overwrite a prior update
+		// to status with this stale state.
+	} else if err := r.UpdateStatus(resource); err != nil {
+		logger.Warnw("Failed to update resource status", zap.Error(err))
+		return err
+	}
+	return reconcileErr
+}
+
+func (r *BaseReconciler) reconcile(ctx context.Context, fb Bindable) error {
+	if fb.GetDeletionTimestamp() != nil {
+		return r.finalize(ctx, fb)
+	}
+	if err := r.EnsureFinalizer(fb); err != nil {
+		return err
+	}
+
+	fb.GetBindingStatus().InitializeConditions()
+	defer fb.GetBindingStatus().SetObservedGeneration(fb.GetGeneration())
+
+	ctx, err := r.decorateContext(ctx, fb)
+	if err != nil {
+		fb.GetBindingStatus().MarkBindingUnavailable("BindingContextFailed", err.Error())
+		return err
+	}
+
+	subjects, err := r.GetSubjects(fb)
+	if err != nil {
+		fb.GetBindingStatus().MarkBindingUnavailable("SubjectMissing", err.Error())
+		return err
+	}
+
+	if err := r.ReconcileSubjects(ctx, fb, subjects, fb.Do); err != nil {
+		fb.GetBindingStatus().MarkBindingUnavailable("SubjectUnavailable", err.Error())
+		return err
+	}
+
+	// Unbind the subjects which are no longer selected, e.g. after their
+	// labels changed, then record the ones bound now.
+	dropped, err := r.droppedSubjects(fb, subjects)
+	if err != nil {
+		return err
+	}
+	if err := r.ReconcileSubjects(ctx, fb, dropped, fb.Undo); err != nil {
+		fb.GetBindingStatus().MarkBindingUnavailable("SubjectUnavailable", err.Error())
+		return err
+	}
+	if err := r.recordBoundSubjects(fb, subjects); err != nil {
+		return err
+	}
+
+	fb.GetBindingStatus().MarkBindingAvailable()
+	return nil
+}
+
+// finalize undoes the binding on the subjects and removes our finalizer,
+// so the Binding resource can be deleted.
+func (r *BaseReconciler) finalize(ctx context.Context, fb Bindable) error {
+	if !sets.NewString(fb.GetFinalizers()...).Has(r.finalizer()) {
+		return nil
+	}
+
+	ctx, err := r.decorateContext(ctx, fb)
+	if err != nil {
+		return err
+	}
+
+	subjects, err := r.GetSubjects(fb)
+	if err != nil && !apierrs.IsNotFound(err) {
+		return err
+	}
+	// The subjects which were bound but aren't selected anymore are
+	// unbound too.
+	dropped, err := r.droppedSubjects(fb, subjects)
+	if err != nil {
+		return err
+	}
+	if err := r.ReconcileSubjects(ctx, fb, append(subjects, dropped...), fb.Undo); err != nil {
+		return err
+	}
+	return r.RemoveFinalizer(fb)
+}
+
+func (r *BaseReconciler) decorateContext(ctx context.Context, fb Bindable) (context.Context, error) {
+	if r.WithContext == nil {
+		return ctx, nil
+	}
+	return r.WithContext(ctx, fb)
+}
+
+// finalizer returns the name of the finalizer added to the Binding
+// resources, which is their qualified resource name.
+func (r *BaseReconciler) finalizer() string {
+	return r.GVR.GroupResource().String()
+}
+
+// boundSubjectsAnnotation returns the annotation of the Binding resources
+// recording the names of the subjects selected by their label selector when
+// they were last reconciled.
+func (r *BaseReconciler) boundSubjectsAnnotation() string {
+	return r.finalizer() + "/bound-subjects"
+}
+
+// boundSubjects returns the names of the subjects the provided Binding's
+// label selector selected when it was last reconciled.
+func (r *BaseReconciler) boundSubjects(fb Bindable) sets.String {
+	var names []string
+	if value, ok := fb.GetAnnotations()[r.boundSubjectsAnnotation()]; ok {
+		// A corrupted annotation is overwritten with the next
+		// reconciliation, the subjects it recorded are left bound.
+		json.Unmarshal([]byte(value), &names)
+	}
+	return sets.NewString(names...)
+}
+
+// recordBoundSubjects records the names of the subjects selected by the label
+// selector of the provided Binding, if they changed, so that they're unbound
+// once the selector stops selecting them.
+func (r *BaseReconciler) recordBoundSubjects(fb Bindable, subjects []*duckv1.WithPod) error {
+	if fb.GetSubject().Selector == nil {
+		return nil
+	}
+	bound := sets.NewString()
+	for _, subject := range subjects {
+		bound.Insert(subject.Name)
+	}
+	if bound.Equal(r.boundSubjects(fb)) {
+		return nil
+	}
+
+	var value interface{}
+	if bound.Len() > 0 {
+		b, err := json.Marshal(bound.List())
+		if err != nil {
+			return err
+		}
+		value = string(b)
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				r.boundSubjectsAnnotation(): value,
+			},
+			"resourceVersion": fb.GetResourceVersion(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	patched, err := r.DynamicClient.Resource(r.GVR).Namespace(fb.GetNamespace()).
+		Patch(fb.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return err
+	}
+	fb.SetAnnotations(patched.GetAnnotations())
+	fb.SetResourceVersion(patched.GetResourceVersion())
+	return nil
+}
+
+// EnsureFinalizer makes sure that the provided resource has a finalizer in the
+// form of this BaseReconciler's GVR's stringified GroupResource.
+func (r *BaseReconciler) EnsureFinalizer(fb Bindable) error {
+	finalizers := sets.NewString(fb.GetFinalizers()...)
+	if finalizers.Has(r.finalizer()) {
+		return nil
+	}
+	return r.patchFinalizers(fb, append(fb.GetFinalizers(), r.finalizer()))
+}
+
+// RemoveFinalizer is the dual of EnsureFinalizer.
+func (r *BaseReconciler) RemoveFinalizer(fb Bindable) error {
+	finalizers := sets.NewString(fb.GetFinalizers()...)
+	if !finalizers.Has(r.finalizer()) {
+		return nil
+	}
+	finalizers.Delete(r.finalizer())
+	return r.patchFinalizers(fb, finalizers.List())
+}
+
+// patchFinalizers replaces the finalizers of the provided resource through
+// a merge patch, which includes the resourceVersion so that we don't clobber
+// concurrent changes to them.
+func (r *BaseReconciler) patchFinalizers(fb Bindable, finalizers []string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"finalizers":      finalizers,
+			"resourceVersion": fb.GetResourceVersion(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	patched, err := r.DynamicClient.Resource(r.GVR).Namespace(fb.GetNamespace()).
+		Patch(fb.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return err
+	}
+	fb.SetFinalizers(patched.GetFinalizers())
+	fb.SetResourceVersion(patched.GetResourceVersion())
+	return nil
+}
+
+// GetSubjects returns the PodSpecable resources referenced by the Subject
+// of the provided Binding, tracking them so the Binding is reconciled again
+// when they change.
+func (r *BaseReconciler) GetSubjects(fb Bindable) ([]*duckv1.WithPod, error) {
+	ref := fb.GetSubject()
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+	gvr := apis.KindToResource(gv.WithKind(ref.Kind))
+
+	if err := r.Tracker.TrackReference(ref, fb); err != nil {
+		return nil, fmt.Errorf("error tracking subject %s: %v", ref.String(), err)
+	}
+
+	_, lister, err := r.Factory.Get(gvr)
+	if err != nil {
+		return nil, fmt.Errorf("error getting a lister for resource '%+v': %v", gvr, err)
+	}
+
+	if ref.Name != "" {
+		obj, err := lister.ByNamespace(ref.Namespace).Get(ref.Name)
+		if err != nil {
+			return nil, err
+		}
+		return []*duckv1.WithPod{obj.(*duckv1.WithPod)}, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(ref.Selector)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := lister.ByNamespace(ref.Namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	subjects := make([]*duckv1.WithPod, 0, len(objs))
+	for _, obj := range objs {
+		subjects = append(subjects, obj.(*duckv1.WithPod))
+	}
+	return subjects, nil
+}
+
+// droppedSubjects returns the subjects the label selector of the provided
+// Binding selected when it was last reconciled, but doesn't select anymore,
+// or none if it refers to its subject by name. The dropped subjects which
+// don't exist anymore are skipped.
+func (r *BaseReconciler) droppedSubjects(fb Bindable, subjects []*duckv1.WithPod) ([]*duckv1.WithPod, error) {
+	ref := fb.GetSubject()
+	if ref.Selector == nil {
+		return nil, nil
+	}
+	dropped := r.boundSubjects(fb)
+	for _, subject := range subjects {
+		dropped.Delete(subject.Name)
+	}
+	if dropped.Len() == 0 {
+		return nil, nil
+	}
+
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+	gvr := apis.KindToResource(gv.WithKind(ref.Kind))
+	_, lister, err := r.Factory.Get(gvr)
+	if err != nil {
+		return nil, fmt.Errorf("error getting a lister for resource '%+v': %v", gvr, err)
+	}
+	objs := make([]*duckv1.WithPod, 0, dropped.Len())
+	for _, name := range dropped.List() {
+		obj, err := lister.ByNamespace(ref.Namespace).Get(name)
+		if apierrs.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		objs = append(objs, obj.(*duckv1.WithPod))
+	}
+	return objs, nil
+}
+
+// ReconcileSubjects applies the provided mutation (the Binding's Do or
+// Undo) to each of the subjects, patching the ones it changes.
+func (r *BaseReconciler) ReconcileSubjects(ctx context.Context, fb Bindable, subjects []*duckv1.WithPod,
+	mutation func(context.Context, *duckv1.WithPod)) error {
+	ref := fb.GetSubject()
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return err
+	}
+	gvr := apis.KindToResource(gv.WithKind(ref.Kind))
+
+	for _, subject := range subjects {
+		// Don't modify the informer's copy.
+		orig := subject
+		subject = orig.DeepCopy()
+		mutation(ctx, subject)
+
+		patch, err := duck.CreatePatch(orig, subject)
+		if err != nil {
+			return err
+		}
+		if len(patch) == 0 {
+			continue
+		}
+		patchBytes, err := patch.MarshalJSON()
+		if err != nil {
+			return err
+		}
+
+		_, err = r.DynamicClient.Resource(gvr).Namespace(subject.Namespace).
+			Patch(subject.Name, types.JSONPatchType, patchBytes, metav1.PatchOptions{})
+		if err != nil {
+			return fmt.Errorf("failed patching %s %s/%s: %v", ref.Kind, subject.Namespace, subject.Name, err)
+		}
+	}
+	return nil
+}
+
+// UpdateStatus updates the status of the provided Binding resource.
+func (r *BaseReconciler) UpdateStatus(desired Bindable) error {
+	ux, err := runtime.DefaultUnstructuredConverter.ToUnstructured(desired)
+	if err != nil {
+		return err
+	}
+	_, err = r.DynamicClient.Resource(r.GVR).Namespace(desired.GetNamespace()).
+		UpdateStatus(&unstructured.Unstructured{Object: ux}, metav1.UpdateOptions{})
+	return err
+}
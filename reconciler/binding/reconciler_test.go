@@ -0,0 +1,520 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package binding
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	clientgotesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	duckv1alpha1 "knative.dev/pkg/apis/duck/v1alpha1"
+	"knative.dev/pkg/tracker"
+)
+
+var (
+	bindingGVR    = schema.GroupVersionResource{Group: "testing.knative.dev", Version: "v1alpha1", Resource: "foobindings"}
+	deploymentGVR = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+
+	bindingCondSet = apis.NewLivingConditionSet()
+)
+
+const boundSubjectsAnnotation = "foobindings.testing.knative.dev/bound-subjects"
+
+// fooBinding is a Bindable which sets the FOO environment variable in the
+// containers of its subjects.
+type fooBinding struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   fooBindingSpec   `json:"spec"`
+	Status fooBindingStatus `json:"status"`
+}
+
+type fooBindingSpec struct {
+	duckv1alpha1.BindingSpec `json:",inline"`
+
+	Value string `json:"value"`
+}
+
+type fooBindingStatus struct {
+	duckv1.Status `json:",inline"`
+}
+
+var _ Bindable = (*fooBinding)(nil)
+
+func (fb *fooBinding) DeepCopyObject() runtime.Object {
+	b, err := json.Marshal(fb)
+	if err != nil {
+		panic(err)
+	}
+	c := &fooBinding{}
+	if err := json.Unmarshal(b, c); err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func (fb *fooBinding) GetGroupVersionKind() schema.GroupVersionKind {
+	return bindingGVR.GroupVersion().WithKind("FooBinding")
+}
+
+func (fb *fooBinding) GetSubject() tracker.Reference {
+	return fb.Spec.Subject
+}
+
+func (fb *fooBinding) GetBindingStatus() duckv1alpha1.BindableStatus {
+	return &fb.Status
+}
+
+func (fb *fooBinding) Do(ctx context.Context, ps *duckv1.WithPod) {
+	fb.Undo(ctx, ps)
+	spec := &ps.Spec.Template.Spec
+	for i := range spec.Containers {
+		spec.Containers[i].Env = append(spec.Containers[i].Env, corev1.EnvVar{
+			Name:  "FOO",
+			Value: fb.Spec.Value,
+		})
+	}
+}
+
+func (fb *fooBinding) Undo(ctx context.Context, ps *duckv1.WithPod) {
+	spec := &ps.Spec.Template.Spec
+	for i, c := range spec.Containers {
+		env := make([]corev1.EnvVar, 0, len(c.Env))
+		for _, e := range c.Env {
+			if e.Name != "FOO" {
+				env = append(env, e)
+			}
+		}
+		spec.Containers[i].Env = env
+	}
+}
+
+func (fbs *fooBindingStatus) InitializeConditions() {
+	bindingCondSet.Manage(fbs).InitializeConditions()
+}
+
+func (fbs *fooBindingStatus) MarkBindingAvailable() {
+	bindingCondSet.Manage(fbs).MarkTrue(apis.ConditionReady)
+}
+
+func (fbs *fooBindingStatus) MarkBindingUnavailable(reason, message string) {
+	bindingCondSet.Manage(fbs).MarkFalse(apis.ConditionReady, reason, "%s", message)
+}
+
+func (fbs *fooBindingStatus) SetObservedGeneration(gen int64) {
+	fbs.ObservedGeneration = gen
+}
+
+type bindingOption func(*fooBinding)
+
+func binding(name string, subject tracker.Reference, opts ...bindingOption) *fooBinding {
+	fb := &fooBinding{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: bindingGVR.GroupVersion().String(),
+			Kind:       "FooBinding",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "ns",
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: fooBindingSpec{
+			BindingSpec: duckv1alpha1.BindingSpec{Subject: subject},
+			Value:       "bar",
+		},
+	}
+	for _, opt := range opts {
+		opt(fb)
+	}
+	return fb
+}
+
+func withFinalizer(fb *fooBinding) {
+	fb.Finalizers = []string{bindingGVR.GroupResource().String()}
+}
+
+// withBoundSubjects records the subjects bound by the binding's selector.
+func withBoundSubjects(names string) bindingOption {
+	return func(fb *fooBinding) {
+		fb.Annotations = map[string]string{boundSubjectsAnnotation: names}
+	}
+}
+
+func deleted(fb *fooBinding) {
+	t := metav1.Unix(1, 0)
+	fb.DeletionTimestamp = &t
+}
+
+func byName(name string) tracker.Reference {
+	return tracker.Reference{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "ns", Name: name}
+}
+
+func bySelector(labels map[string]string) tracker.Reference {
+	return tracker.Reference{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Namespace:  "ns",
+		Selector:   &metav1.LabelSelector{MatchLabels: labels},
+	}
+}
+
+func deployment(name string, labels map[string]string, env ...corev1.EnvVar) *duckv1.WithPod {
+	return &duckv1.WithPod{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      name,
+			Labels:    labels,
+		},
+		Spec: duckv1.WithPodSpec{
+			Template: duckv1.PodSpecable{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "user-container",
+						Image: "busybox",
+						Env:   env,
+					}},
+				},
+			},
+		},
+	}
+}
+
+func toUnstructured(t *testing.T, obj interface{}) *unstructured.Unstructured {
+	t.Helper()
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		t.Fatalf("ToUnstructured() = %v", err)
+	}
+	return &unstructured.Unstructured{Object: u}
+}
+
+// fakeFactory is a duck.InformerFactory backed by an indexer.
+type fakeFactory struct {
+	indexer cache.Indexer
+}
+
+func (f *fakeFactory) Get(gvr schema.GroupVersionResource) (cache.SharedIndexInformer, cache.GenericLister, error) {
+	return nil, cache.NewGenericLister(f.indexer, gvr.GroupResource()), nil
+}
+
+type action struct {
+	verb      string
+	gvr       schema.GroupVersionResource
+	name      string
+	patchType types.PatchType
+	patch     string
+}
+
+func TestReconcile(t *testing.T) {
+	foo := corev1.EnvVar{Name: "FOO", Value: "bar"}
+	finalizerPatch := `{"metadata":{"finalizers":["foobindings.testing.knative.dev"],"resourceVersion":""}}`
+
+	tests := []struct {
+		name        string
+		binding     *fooBinding
+		deployments []*duckv1.WithPod
+		wantActions []action
+		wantErr     bool
+		wantReady   corev1.ConditionStatus
+	}{{
+		name:        "binds a subject by name",
+		binding:     binding("b", byName("d")),
+		deployments: []*duckv1.WithPod{deployment("d", nil)},
+		wantActions: []action{{
+			verb:      "patch",
+			gvr:       bindingGVR,
+			name:      "b",
+			patchType: types.MergePatchType,
+			patch:     finalizerPatch,
+		}, {
+			verb:      "patch",
+			gvr:       deploymentGVR,
+			name:      "d",
+			patchType: types.JSONPatchType,
+			patch:     `[{"op":"add","path":"/spec/template/spec/containers/0/env","value":[{"name":"FOO","value":"bar"}]}]`,
+		}, {
+			verb: "update",
+			gvr:  bindingGVR,
+			name: "b",
+		}},
+		wantReady: corev1.ConditionTrue,
+	}, {
+		name:    "binds subjects by selector",
+		binding: binding("b", bySelector(map[string]string{"app": "foo"}), withFinalizer),
+		deployments: []*duckv1.WithPod{
+			deployment("d1", map[string]string{"app": "foo"}),
+			deployment("d2", map[string]string{"app": "bar"}),
+		},
+		wantActions: []action{{
+			verb:      "patch",
+			gvr:       deploymentGVR,
+			name:      "d1",
+			patchType: types.JSONPatchType,
+			patch:     `[{"op":"add","path":"/spec/template/spec/containers/0/env","value":[{"name":"FOO","value":"bar"}]}]`,
+		}, {
+			verb:      "patch",
+			gvr:       bindingGVR,
+			name:      "b",
+			patchType: types.MergePatchType,
+			patch:     `{"metadata":{"annotations":{"foobindings.testing.knative.dev/bound-subjects":"[\"d1\"]"},"resourceVersion":""}}`,
+		}, {
+			verb: "update",
+			gvr:  bindingGVR,
+			name: "b",
+		}},
+		wantReady: corev1.ConditionTrue,
+	}, {
+		name: "unbinds the subjects which are no longer selected",
+		binding: binding("b", bySelector(map[string]string{"app": "foo"}), withFinalizer,
+			withBoundSubjects(`["d1","d2","gone"]`)),
+		deployments: []*duckv1.WithPod{
+			deployment("d1", map[string]string{"app": "foo"}, foo),
+			deployment("d2", map[string]string{"app": "bar"}, foo),
+			deployment("d3", nil),
+			// Bound by another binding, it's left alone.
+			deployment("d4", map[string]string{"app": "bar"}, foo),
+		},
+		wantActions: []action{{
+			verb:      "patch",
+			gvr:       deploymentGVR,
+			name:      "d2",
+			patchType: types.JSONPatchType,
+			patch:     `[{"op":"remove","path":"/spec/template/spec/containers/0/env"}]`,
+		}, {
+			verb:      "patch",
+			gvr:       bindingGVR,
+			name:      "b",
+			patchType: types.MergePatchType,
+			patch:     `{"metadata":{"annotations":{"foobindings.testing.knative.dev/bound-subjects":"[\"d1\"]"},"resourceVersion":""}}`,
+		}, {
+			verb: "update",
+			gvr:  bindingGVR,
+			name: "b",
+		}},
+		wantReady: corev1.ConditionTrue,
+	}, {
+		name: "bound subjects unchanged",
+		binding: binding("b", bySelector(map[string]string{"app": "foo"}), withFinalizer,
+			withBoundSubjects(`["d1"]`)),
+		deployments: []*duckv1.WithPod{
+			deployment("d1", map[string]string{"app": "foo"}, foo),
+			deployment("d2", map[string]string{"app": "bar"}, foo),
+		},
+		wantActions: []action{{
+			verb: "update",
+			gvr:  bindingGVR,
+			name: "b",
+		}},
+		wantReady: corev1.ConditionTrue,
+	}, {
+		name: "no subject selected anymore",
+		binding: binding("b", bySelector(map[string]string{"app": "foo"}), withFinalizer,
+			withBoundSubjects(`["d1"]`)),
+		deployments: []*duckv1.WithPod{
+			deployment("d1", map[string]string{"app": "bar"}, foo),
+		},
+		wantActions: []action{{
+			verb:      "patch",
+			gvr:       deploymentGVR,
+			name:      "d1",
+			patchType: types.JSONPatchType,
+			patch:     `[{"op":"remove","path":"/spec/template/spec/containers/0/env"}]`,
+		}, {
+			verb:      "patch",
+			gvr:       bindingGVR,
+			name:      "b",
+			patchType: types.MergePatchType,
+			patch:     `{"metadata":{"annotations":{"foobindings.testing.knative.dev/bound-subjects":null},"resourceVersion":""}}`,
+		}, {
+			verb: "update",
+			gvr:  bindingGVR,
+			name: "b",
+		}},
+		wantReady: corev1.ConditionTrue,
+	}, {
+		name:        "subject already bound",
+		binding:     binding("b", byName("d"), withFinalizer),
+		deployments: []*duckv1.WithPod{deployment("d", nil, foo)},
+		wantActions: []action{{
+			verb: "update",
+			gvr:  bindingGVR,
+			name: "b",
+		}},
+		wantReady: corev1.ConditionTrue,
+	}, {
+		name:    "missing subject",
+		binding: binding("b", byName("d"), withFinalizer),
+		wantActions: []action{{
+			verb: "update",
+			gvr:  bindingGVR,
+			name: "b",
+		}},
+		wantErr:   true,
+		wantReady: corev1.ConditionFalse,
+	}, {
+		name:        "unbinds the subject on deletion",
+		binding:     binding("b", byName("d"), withFinalizer, deleted),
+		deployments: []*duckv1.WithPod{deployment("d", nil, foo)},
+		wantActions: []action{{
+			verb:      "patch",
+			gvr:       deploymentGVR,
+			name:      "d",
+			patchType: types.JSONPatchType,
+			patch:     `[{"op":"remove","path":"/spec/template/spec/containers/0/env"}]`,
+		}, {
+			verb:      "patch",
+			gvr:       bindingGVR,
+			name:      "b",
+			patchType: types.MergePatchType,
+			patch:     `{"metadata":{"finalizers":[],"resourceVersion":""}}`,
+		}},
+	}, {
+		name: "unbinds the subjects no longer selected on deletion",
+		binding: binding("b", bySelector(map[string]string{"app": "foo"}), withFinalizer, deleted,
+			withBoundSubjects(`["d1","d2"]`)),
+		deployments: []*duckv1.WithPod{
+			deployment("d1", map[string]string{"app": "foo"}, foo),
+			deployment("d2", map[string]string{"app": "bar"}, foo),
+		},
+		wantActions: []action{{
+			verb:      "patch",
+			gvr:       deploymentGVR,
+			name:      "d1",
+			patchType: types.JSONPatchType,
+			patch:     `[{"op":"remove","path":"/spec/template/spec/containers/0/env"}]`,
+		}, {
+			verb:      "patch",
+			gvr:       deploymentGVR,
+			name:      "d2",
+			patchType: types.JSONPatchType,
+			patch:     `[{"op":"remove","path":"/spec/template/spec/containers/0/env"}]`,
+		}, {
+			verb:      "patch",
+			gvr:       bindingGVR,
+			name:      "b",
+			patchType: types.MergePatchType,
+			patch:     `{"metadata":{"finalizers":[],"resourceVersion":""}}`,
+		}},
+	}, {
+		name:    "deletion with a missing subject",
+		binding: binding("b", byName("d"), withFinalizer, deleted),
+		wantActions: []action{{
+			verb:      "patch",
+			gvr:       bindingGVR,
+			name:      "b",
+			patchType: types.MergePatchType,
+			patch:     `{"metadata":{"finalizers":[],"resourceVersion":""}}`,
+		}},
+	}, {
+		name:    "deletion without our finalizer",
+		binding: binding("b", byName("d"), deleted),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			objs := []runtime.Object{toUnstructured(t, test.binding)}
+			for _, d := range test.deployments {
+				indexer.Add(d)
+				objs = append(objs, toUnstructured(t, d))
+			}
+			client := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), objs...)
+
+			var got *fooBinding
+			r := &BaseReconciler{
+				GVR: bindingGVR,
+				Get: func(namespace, name string) (Bindable, error) {
+					if namespace != test.binding.Namespace || name != test.binding.Name {
+						return nil, apierrs.NewNotFound(bindingGVR.GroupResource(), name)
+					}
+					return test.binding, nil
+				},
+				WithContext: func(ctx context.Context, fb Bindable) (context.Context, error) {
+					got = fb.(*fooBinding)
+					return ctx, nil
+				},
+				DynamicClient: client,
+				Factory:       &fakeFactory{indexer: indexer},
+				Tracker:       tracker.New(func(types.NamespacedName) {}, time.Minute),
+			}
+
+			err := r.Reconcile(context.Background(), "ns/b")
+			if (err != nil) != test.wantErr {
+				t.Errorf("Reconcile() = %v, wantErr %v", err, test.wantErr)
+			}
+
+			var gotActions []action
+			for _, a := range client.Actions() {
+				ga := action{verb: a.GetVerb(), gvr: a.GetResource()}
+				switch a := a.(type) {
+				case clientgotesting.PatchAction:
+					ga.name = a.GetName()
+					ga.patchType = a.GetPatchType()
+					ga.patch = string(a.GetPatch())
+				case clientgotesting.UpdateAction:
+					ga.name = a.GetObject().(*unstructured.Unstructured).GetName()
+				}
+				gotActions = append(gotActions, ga)
+			}
+			if diff := cmp.Diff(test.wantActions, gotActions, cmp.AllowUnexported(action{})); diff != "" {
+				t.Errorf("Actions (-want, +got): %s", diff)
+			}
+
+			if test.wantReady == "" {
+				return
+			}
+			if got == nil {
+				t.Fatal("The binding was never reconciled")
+			}
+			if cond := got.Status.GetCondition(apis.ConditionReady); cond == nil || cond.Status != test.wantReady {
+				t.Errorf("Ready = %v, wanted %v", cond, test.wantReady)
+			}
+			if got.Status.ObservedGeneration != got.Generation {
+				t.Errorf("ObservedGeneration = %d, wanted %d", got.Status.ObservedGeneration, got.Generation)
+			}
+		})
+	}
+}
+
+func TestReconcileNotFound(t *testing.T) {
+	r := &BaseReconciler{
+		GVR: bindingGVR,
+		Get: func(namespace, name string) (Bindable, error) {
+			return nil, apierrs.NewNotFound(bindingGVR.GroupResource(), name)
+		},
+	}
+	if err := r.Reconcile(context.Background(), "ns/b"); err != nil {
+		t.Errorf("Reconcile() = %v", err)
+	}
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package external contains a helper for the reconcilers which create
+// resources outside of Kubernetes, e.g. cloud load balancers or queues, for
+// the Kubernetes resources they reconcile. The external resources are
+// created in two phases, so that they are never orphaned:
+//
+//  1. the intent to create the external resource is recorded in an
+//     annotation of the Kubernetes resource, with a token the external
+//     resource is created with, along with a finalizer;
+//  2. once the external resource is created, its ID is recorded in another
+//     annotation, which confirms it.
+//
+// If the reconciler fails or crashes between the two phases, the next
+// reconciliation finds the external resource by its token before trying to
+// create it again, and the finalizer makes sure it's deleted along with the
+// Kubernetes resource.
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/dynamic"
+
+	"knative.dev/pkg/logging"
+)
+
+// Provider creates, finds and deletes the external resources of Kubernetes
+// resources.
+type Provider interface {
+	// Create creates the external resource of the Kubernetes resource and
+	// returns its ID. The external resource must be tagged with the token,
+	// e.g. as an idempotency key or a label, so that Find can find it.
+	Create(ctx context.Context, obj metav1.Object, token string) (string, error)
+
+	// Find returns the ID of the external resource created with the token,
+	// or an empty ID if there is none.
+	Find(ctx context.Context, obj metav1.Object, token string) (string, error)
+
+	// Delete deletes the external resource with the ID. It must succeed if
+	// the external resource doesn't exist anymore.
+	Delete(ctx context.Context, obj metav1.Object, id string) error
+}
+
+// Creator creates the external resources of Kubernetes resources in two
+// phases, recording their state in annotations of the Kubernetes resources.
+type Creator struct {
+	// Name is the qualified name of the external resources, e.g.
+	// `loadbalancers.example.dev`. It's the finalizer of the Kubernetes
+	// resources and prefixes the annotations recording the state of the
+	// external resources.
+	Name string
+
+	// GVR is the GroupVersionResource of the Kubernetes resources, used
+	// to patch their annotations and finalizers.
+	GVR schema.GroupVersionResource
+
+	// DynamicClient is used to patch the Kubernetes resources.
+	DynamicClient dynamic.Interface
+
+	// Provider creates, finds and deletes the external resources.
+	Provider Provider
+
+	// NewToken is an optional callback generating the tokens the external
+	// resources are created with. Defaults to random UUIDs.
+	NewToken func() string
+}
+
+// IntentAnnotation returns the key of the annotation holding the token of the
+// external resource about to be created.
+func (c *Creator) IntentAnnotation() string {
+	return c.Name + "/intent"
+}
+
+// IDAnnotation returns the key of the annotation holding the ID of the created
+// external resource.
+func (c *Creator) IDAnnotation() string {
+	return c.Name + "/id"
+}
+
+// ID returns the ID of the external resource of the Kubernetes resource, or an
+// empty ID if it's not confirmed yet.
+func (c *Creator) ID(obj metav1.Object) string {
+	return obj.GetAnnotations()[c.IDAnnotation()]
+}
+
+// Reconcile makes sure the external resource of the Kubernetes resource exists,
+// and returns its ID. The annotations, finalizers and resource version of the
+// provided resource are updated as it's patched, so it must not be the
+// informer's copy.
+func (c *Creator) Reconcile(ctx context.Context, obj metav1.Object) (string, error) {
+	logger := logging.FromContext(ctx)
+	if id := c.ID(obj); id != "" {
+		return id, nil
+	}
+
+	token := obj.GetAnnotations()[c.IntentAnnotation()]
+	id := ""
+	if token == "" {
+		// Phase 1: record the intent before creating anything, so that the
+		// external resource can't be created without a trace of it.
+		token = c.newToken()
+		finalizers := sets.NewString(obj.GetFinalizers()...)
+		finalizers.Insert(c.Name)
+		if err := c.patch(obj, finalizers.List(), map[string]interface{}{c.IntentAnnotation(): token}); err != nil {
+			return "", fmt.Errorf("failed to record the intent to create %s: %v", c.Name, err)
+		}
+	} else {
+		// The previous attempt may have created the external resource
+		// before failing to confirm it.
+		var err error
+		if id, err = c.Provider.Find(ctx, obj, token); err != nil {
+			return "", fmt.Errorf("failed to find %s with token %q: %v", c.Name, token, err)
+		}
+	}
+
+	if id == "" {
+		var err error
+		if id, err = c.Provider.Create(ctx, obj, token); err != nil {
+			return "", fmt.Errorf("failed to create %s: %v", c.Name, err)
+		}
+		if id == "" {
+			return "", fmt.Errorf("created %s without an ID", c.Name)
+		}
+		logger.Infof("Created %s %q", c.Name, id)
+	}
+
+	// Phase 2: confirm the external resource. If this fails, the next
+	// reconciliation finds it by its token.
+	if err := c.patch(obj, nil, map[string]interface{}{c.IDAnnotation(): id, c.IntentAnnotation(): nil}); err != nil {
+		return "", fmt.Errorf("failed to record the ID of %s %q: %v", c.Name, id, err)
+	}
+	return id, nil
+}
+
+// Finalize deletes the external resource of the Kubernetes resource, whether
+// it was confirmed or only intended, and then removes the finalizer so that
+// the Kubernetes resource can be deleted. Like Reconcile, it patches the
+// provided resource.
+func (c *Creator) Finalize(ctx context.Context, obj metav1.Object) error {
+	finalizers := sets.NewString(obj.GetFinalizers()...)
+	if !finalizers.Has(c.Name) {
+		return nil
+	}
+
+	id := c.ID(obj)
+	if token := obj.GetAnnotations()[c.IntentAnnotation()]; id == "" && token != "" {
+		var err error
+		if id, err = c.Provider.Find(ctx, obj, token); err != nil {
+			return fmt.Errorf("failed to find %s with token %q: %v", c.Name, token, err)
+		}
+	}
+	if id != "" {
+		if err := c.Provider.Delete(ctx, obj, id); err != nil {
+			return fmt.Errorf("failed to delete %s %q: %v", c.Name, id, err)
+		}
+		logging.FromContext(ctx).Infof("Deleted %s %q", c.Name, id)
+	}
+
+	finalizers.Delete(c.Name)
+	return c.patch(obj, finalizers.List(), map[string]interface{}{c.IDAnnotation(): nil, c.IntentAnnotation(): nil})
+}
+
+func (c *Creator) newToken() string {
+	if c.NewToken != nil {
+		return c.NewToken()
+	}
+	return uuid.New().String()
+}
+
+// patch sets the annotations, removing the nil ones, and replaces the
+// finalizers if not nil, through a merge patch which includes the
+// resourceVersion so that we don't clobber concurrent changes.
+func (c *Creator) patch(obj metav1.Object, finalizers []string, annotations map[string]interface{}) error {
+	if c.DynamicClient == nil {
+		return errors.New("no dynamic client to patch the resource with")
+	}
+	metadata := map[string]interface{}{
+		"annotations":     annotations,
+		"resourceVersion": obj.GetResourceVersion(),
+	}
+	if finalizers != nil {
+		metadata["finalizers"] = finalizers
+	}
+	patch, err := json.Marshal(map[string]interface{}{"metadata": metadata})
+	if err != nil {
+		return err
+	}
+
+	patched, err := c.DynamicClient.Resource(c.GVR).Namespace(obj.GetNamespace()).
+		Patch(obj.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return err
+	}
+	obj.SetAnnotations(patched.GetAnnotations())
+	obj.SetFinalizers(patched.GetFinalizers())
+	obj.SetResourceVersion(patched.GetResourceVersion())
+	return nil
+}
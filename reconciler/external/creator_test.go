@@ -0,0 +1,251 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package external
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	clientgotesting "k8s.io/client-go/testing"
+)
+
+const name = "loadbalancers.example.dev"
+
+var gvr = schema.GroupVersionResource{Group: "testing.knative.dev", Version: "v1alpha1", Resource: "services"}
+
+// fakeProvider keeps the external resources in memory, by token.
+type fakeProvider struct {
+	resources map[string]string
+	deleted   []string
+	created   int
+
+	// failCreate fails Create after creating the resource, like a
+	// lost response.
+	failCreate bool
+}
+
+func (p *fakeProvider) Create(_ context.Context, obj metav1.Object, token string) (string, error) {
+	p.created++
+	if p.resources == nil {
+		p.resources = make(map[string]string)
+	}
+	id := fmt.Sprintf("lb-%s-%d", obj.GetName(), p.created)
+	p.resources[token] = id
+	if p.failCreate {
+		return "", errors.New("connection reset")
+	}
+	return id, nil
+}
+
+func (p *fakeProvider) Find(_ context.Context, _ metav1.Object, token string) (string, error) {
+	return p.resources[token], nil
+}
+
+func (p *fakeProvider) Delete(_ context.Context, _ metav1.Object, id string) error {
+	for token, rid := range p.resources {
+		if rid == id {
+			delete(p.resources, token)
+		}
+	}
+	p.deleted = append(p.deleted, id)
+	return nil
+}
+
+func service(annotations map[string]string, finalizers ...string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("testing.knative.dev/v1alpha1")
+	u.SetKind("Service")
+	u.SetNamespace("ns")
+	u.SetName("svc")
+	u.SetAnnotations(annotations)
+	u.SetFinalizers(finalizers)
+	return u
+}
+
+func newCreator(provider Provider, objs ...runtime.Object) (*Creator, *fakedynamic.FakeDynamicClient) {
+	client := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), objs...)
+	return &Creator{
+		Name:          name,
+		GVR:           gvr,
+		DynamicClient: client,
+		Provider:      provider,
+		NewToken:      func() string { return "token" },
+	}, client
+}
+
+func failPatches(client *fakedynamic.FakeDynamicClient, after int) {
+	patches := 0
+	client.PrependReactor("patch", "*", func(clientgotesting.Action) (bool, runtime.Object, error) {
+		patches++
+		if patches > after {
+			return true, nil, errors.New("inducing failure for patch")
+		}
+		return false, nil, nil
+	})
+}
+
+func TestReconcile(t *testing.T) {
+	provider := &fakeProvider{}
+	svc := service(nil)
+	c, _ := newCreator(provider, svc.DeepCopy())
+
+	id, err := c.Reconcile(context.Background(), svc)
+	if err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+	if want := "lb-svc-1"; id != want {
+		t.Errorf("Reconcile() = %q, want %q", id, want)
+	}
+	if diff := cmp.Diff(map[string]string{name + "/id": id}, svc.GetAnnotations()); diff != "" {
+		t.Errorf("Annotations (-want, +got): %s", diff)
+	}
+	if diff := cmp.Diff([]string{name}, svc.GetFinalizers()); diff != "" {
+		t.Errorf("Finalizers (-want, +got): %s", diff)
+	}
+
+	// Reconciling again returns the confirmed resource.
+	if id, err := c.Reconcile(context.Background(), svc); err != nil || id != "lb-svc-1" {
+		t.Errorf("Reconcile() = %q, %v, want lb-svc-1", id, err)
+	}
+	if provider.created != 1 {
+		t.Errorf("Created %d resources, want 1", provider.created)
+	}
+}
+
+func TestReconcileIntentNotRecorded(t *testing.T) {
+	provider := &fakeProvider{}
+	svc := service(nil)
+	c, client := newCreator(provider, svc.DeepCopy())
+	failPatches(client, 0)
+
+	if _, err := c.Reconcile(context.Background(), svc); err == nil {
+		t.Fatal("Reconcile() = nil, wanted an error")
+	}
+	if provider.created != 0 {
+		t.Errorf("Created %d resources without recording the intent", provider.created)
+	}
+}
+
+func TestReconcileRecovers(t *testing.T) {
+	tests := []struct {
+		name       string
+		failCreate bool
+		// patches is the number of patches succeeding in the first attempt.
+		patches int
+	}{{
+		name:    "confirmation failed",
+		patches: 1,
+	}, {
+		name:       "create response lost",
+		failCreate: true,
+		patches:    1,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := &fakeProvider{failCreate: test.failCreate}
+			svc := service(nil)
+			c, client := newCreator(provider, svc.DeepCopy())
+			failPatches(client, test.patches)
+
+			if _, err := c.Reconcile(context.Background(), svc); err == nil {
+				t.Fatal("Reconcile() = nil, wanted an error")
+			}
+			if got, want := svc.GetAnnotations()[c.IntentAnnotation()], "token"; got != want {
+				t.Errorf("Intent = %q, want %q", got, want)
+			}
+
+			// The next attempt finds the resource created by the first one.
+			provider.failCreate = false
+			client.ReactionChain = client.ReactionChain[1:]
+			id, err := c.Reconcile(context.Background(), svc)
+			if err != nil {
+				t.Fatalf("Reconcile() = %v", err)
+			}
+			if want := "lb-svc-1"; id != want {
+				t.Errorf("Reconcile() = %q, want %q", id, want)
+			}
+			if provider.created != 1 {
+				t.Errorf("Created %d resources, want 1", provider.created)
+			}
+			if diff := cmp.Diff(map[string]string{name + "/id": id}, svc.GetAnnotations()); diff != "" {
+				t.Errorf("Annotations (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestFinalize(t *testing.T) {
+	tests := []struct {
+		name        string
+		svc         *unstructured.Unstructured
+		resources   map[string]string
+		wantDeleted []string
+	}{{
+		name:        "confirmed",
+		svc:         service(map[string]string{name + "/id": "lb-1"}, name, "other"),
+		resources:   map[string]string{"token": "lb-1"},
+		wantDeleted: []string{"lb-1"},
+	}, {
+		name:        "intended and created",
+		svc:         service(map[string]string{name + "/intent": "token"}, name),
+		resources:   map[string]string{"token": "lb-1"},
+		wantDeleted: []string{"lb-1"},
+	}, {
+		name: "intended but not created",
+		svc:  service(map[string]string{name + "/intent": "token"}, name),
+	}, {
+		name: "not ours",
+		svc:  service(nil, "other"),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := &fakeProvider{resources: test.resources}
+			c, client := newCreator(provider, test.svc.DeepCopy())
+
+			if err := c.Finalize(context.Background(), test.svc); err != nil {
+				t.Fatalf("Finalize() = %v", err)
+			}
+			if diff := cmp.Diff(test.wantDeleted, provider.deleted); diff != "" {
+				t.Errorf("Deleted (-want, +got): %s", diff)
+			}
+			if len(provider.resources) != 0 {
+				t.Errorf("Orphaned resources: %v", provider.resources)
+			}
+			for _, f := range test.svc.GetFinalizers() {
+				if f == name {
+					t.Errorf("Finalizers = %v, wanted %s removed", test.svc.GetFinalizers(), name)
+				}
+			}
+			if len(test.svc.GetAnnotations()) != 0 {
+				t.Errorf("Annotations = %v, wanted none", test.svc.GetAnnotations())
+			}
+			if test.name == "not ours" && len(client.Actions()) != 0 {
+				t.Errorf("Actions = %v, wanted none", client.Actions())
+			}
+		})
+	}
+}
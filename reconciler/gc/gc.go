@@ -0,0 +1,224 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package gc contains a helper garbage collecting the resources a controller
+// creates for parents in other namespaces, e.g. in the namespaces of the
+// users. OwnerReferences can't point across namespaces, so such children are
+// annotated with their parent by kmeta.SetLogicalOwner instead, and would be
+// leaked once their parent is deleted without the Collector.
+package gc
+
+import (
+	"context"
+	"fmt"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	"knative.dev/pkg/kmeta"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/metrics"
+)
+
+const (
+	// resultDeleted is the result of the orphans which were deleted.
+	resultDeleted = "deleted"
+	// resultDryRun is the result of the orphans which would have been
+	// deleted, in dry run.
+	resultDryRun = "dry_run"
+	// resultFailed is the result of the orphans which failed to be deleted.
+	resultFailed = "failed"
+)
+
+var (
+	collectedChildrenStat = stats.Int64(
+		"gc_collected_children",
+		"Number of orphaned children found by the garbage collector of logically owned resources",
+		stats.UnitDimensionless)
+
+	resourceKey = tag.MustNewKey("resource")
+	resultKey   = tag.MustNewKey("result")
+)
+
+func init() {
+	register()
+}
+
+func register() {
+	if err := view.Register(&view.View{
+		Description: collectedChildrenStat.Description(),
+		Measure:     collectedChildrenStat,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{resourceKey, resultKey},
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// ParentExistsFunc returns whether the parent a child is logically owned by
+// still exists. It must compare the UIDs, so that the children of a deleted
+// parent are collected even if it has been recreated since.
+type ParentExistsFunc func(ctx context.Context, owner kmeta.LogicalOwner) (bool, error)
+
+// DynamicParentExists returns a ParentExistsFunc getting the parents, which
+// are of the given resource, with the dynamic client.
+func DynamicParentExists(client dynamic.Interface, gvr schema.GroupVersionResource) ParentExistsFunc {
+	return func(_ context.Context, owner kmeta.LogicalOwner) (bool, error) {
+		parent, err := client.Resource(gvr).Namespace(owner.Namespace).Get(owner.Name, metav1.GetOptions{})
+		if apierrs.IsNotFound(err) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		return parent.GetUID() == owner.UID, nil
+	}
+}
+
+// Collector garbage collects the children of a resource whose logical owner,
+// see kmeta.SetLogicalOwner, doesn't exist anymore.
+type Collector struct {
+	// DynamicClient is used to list and delete the children.
+	DynamicClient dynamic.Interface
+
+	// GVR is the GroupVersionResource of the children.
+	GVR schema.GroupVersionResource
+
+	// Namespace restricts the collection to the children of a namespace.
+	// All namespaces are collected by default.
+	Namespace string
+
+	// ParentExists returns whether the logical owner of a child still
+	// exists, see DynamicParentExists.
+	ParentExists ParentExistsFunc
+
+	// DryRun only logs and counts the orphans, without deleting them.
+	DryRun bool
+}
+
+// Result is the outcome of a collection.
+type Result struct {
+	// Orphans are the names, as `namespace/name`, of the children whose
+	// parent doesn't exist anymore.
+	Orphans []string
+	// Deleted is the number of orphans deleted, none in dry run.
+	Deleted int
+}
+
+// Children returns the children of the given parent.
+func (c *Collector) Children(owner kmeta.LogicalOwner) ([]unstructured.Unstructured, error) {
+	all, err := c.list()
+	if err != nil {
+		return nil, err
+	}
+	var children []unstructured.Unstructured
+	for _, child := range all {
+		if o, err := kmeta.GetLogicalOwner(&child); err == nil && o != nil && o.UID == owner.UID {
+			children = append(children, child)
+		}
+	}
+	return children, nil
+}
+
+// Collect deletes the children whose logical owner doesn't exist anymore.
+// The children without a logical owner, or with an invalid one, are left
+// alone. The parents are checked once per collection.
+func (c *Collector) Collect(ctx context.Context) (Result, error) {
+	logger := logging.FromContext(ctx)
+	all, err := c.list()
+	if err != nil {
+		return Result{}, err
+	}
+
+	var result Result
+	exists := make(map[kmeta.LogicalOwner]bool)
+	for i := range all {
+		child := &all[i]
+		owner, err := kmeta.GetLogicalOwner(child)
+		if err != nil {
+			logger.Warnw("Ignoring the logical owner of "+childName(child), "error", err)
+			continue
+		} else if owner == nil {
+			continue
+		}
+		ok, checked := exists[*owner]
+		if !checked {
+			if ok, err = c.ParentExists(ctx, *owner); err != nil {
+				return result, fmt.Errorf("failed to check whether %v exists: %v", owner, err)
+			}
+			exists[*owner] = ok
+		}
+		if ok {
+			continue
+		}
+
+		name := childName(child)
+		result.Orphans = append(result.Orphans, name)
+		if c.DryRun {
+			logger.Infof("Would delete %s %s, whose parent %v doesn't exist anymore", c.GVR.Resource, name, owner)
+			c.record(ctx, resultDryRun)
+			continue
+		}
+		logger.Infof("Deleting %s %s, whose parent %v doesn't exist anymore", c.GVR.Resource, name, owner)
+		if err := c.delete(child); err != nil {
+			c.record(ctx, resultFailed)
+			return result, fmt.Errorf("failed to delete %s %s: %v", c.GVR.Resource, name, err)
+		}
+		c.record(ctx, resultDeleted)
+		result.Deleted++
+	}
+	return result, nil
+}
+
+// list lists the children of the namespace of the collector.
+func (c *Collector) list() ([]unstructured.Unstructured, error) {
+	list, err := c.DynamicClient.Resource(c.GVR).Namespace(c.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %v", c.GVR.Resource, err)
+	}
+	return list.Items, nil
+}
+
+// delete deletes the child, unless it has been recreated since it was listed.
+func (c *Collector) delete(child *unstructured.Unstructured) error {
+	uid := child.GetUID()
+	err := c.DynamicClient.Resource(c.GVR).Namespace(child.GetNamespace()).Delete(child.GetName(), &metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &uid},
+	})
+	if apierrs.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (c *Collector) record(ctx context.Context, result string) {
+	ctx, err := tag.New(ctx,
+		tag.Insert(resourceKey, c.GVR.GroupResource().String()),
+		tag.Insert(resultKey, result))
+	if err != nil {
+		return
+	}
+	metrics.Record(ctx, collectedChildrenStat.M(1))
+}
+
+func childName(child *unstructured.Unstructured) string {
+	return child.GetNamespace() + "/" + child.GetName()
+}
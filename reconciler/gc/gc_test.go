@@ -0,0 +1,213 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gc
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opencensus.io/stats/view"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"knative.dev/pkg/kmeta"
+	. "knative.dev/pkg/logging/testing"
+	"knative.dev/pkg/metrics/metricstest"
+)
+
+var (
+	parentGVR = schema.GroupVersionResource{Group: "example.knative.dev", Version: "v1alpha1", Resource: "frobbers"}
+	childGVR  = schema.GroupVersionResource{Group: "example.knative.dev", Version: "v1alpha1", Resource: "widgets"}
+)
+
+// parent is a kmeta.OwnerRefable parent.
+type parent struct {
+	metav1.ObjectMeta
+}
+
+func (p *parent) GetObjectMeta() metav1.Object { return &p.ObjectMeta }
+
+func (p *parent) GetGroupVersionKind() schema.GroupVersionKind {
+	return parentGVR.GroupVersion().WithKind("Frobber")
+}
+
+func newParent(name string, uid types.UID) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(parentGVR.GroupVersion().String())
+	u.SetKind("Frobber")
+	u.SetNamespace("knative-system")
+	u.SetName(name)
+	u.SetUID(uid)
+	return u
+}
+
+func newChild(namespace, name string, owner *parent) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(childGVR.GroupVersion().String())
+	u.SetKind("Widget")
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	u.SetUID(types.UID(namespace + "-" + name))
+	if owner != nil {
+		kmeta.SetLogicalOwner(u, owner)
+	}
+	return u
+}
+
+func owner(name string, uid types.UID) *parent {
+	return &parent{metav1.ObjectMeta{Namespace: "knative-system", Name: name, UID: uid}}
+}
+
+func names(objs []unstructured.Unstructured) []string {
+	var names []string
+	for _, o := range objs {
+		names = append(names, childName(&o))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// collected returns the number of children collected with the given result.
+func collected(t *testing.T, result string) int64 {
+	t.Helper()
+	rows, err := view.RetrieveData("gc_collected_children")
+	if err != nil {
+		t.Fatalf("RetrieveData() = %v", err)
+	}
+	for _, row := range rows {
+		for _, tag := range row.Tags {
+			if tag.Key == resultKey && tag.Value == result {
+				return row.Data.(*view.CountData).Value
+			}
+		}
+	}
+	return 0
+}
+
+// OpenCensus metrics carry global state that need to be reset between unit tests.
+func resetMetrics() {
+	metricstest.Unregister("gc_collected_children")
+	register()
+}
+
+func TestCollect(t *testing.T) {
+	resetMetrics()
+	alive, deleted, recreated := owner("alive", "1"), owner("deleted", "2"), owner("recreated", "3")
+	invalid := newChild("user2", "invalid", nil)
+	invalid.SetAnnotations(map[string]string{kmeta.LogicalOwnerAnnotation: "not json"})
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+		newParent("alive", "1"),
+		newParent("recreated", "4"),
+		newChild("user1", "of-alive", alive),
+		newChild("user1", "of-deleted", deleted),
+		newChild("user2", "of-deleted", deleted),
+		newChild("user2", "of-recreated", recreated),
+		newChild("user2", "unowned", nil),
+		invalid,
+	)
+	c := &Collector{
+		DynamicClient: client,
+		GVR:           childGVR,
+		ParentExists:  DynamicParentExists(client, parentGVR),
+		DryRun:        true,
+	}
+	ctx := TestContextWithLogger(t)
+
+	children, err := c.Children(kmeta.NewLogicalOwner(deleted))
+	if err != nil {
+		t.Fatalf("Children() = %v", err)
+	}
+	if diff := cmp.Diff([]string{"user1/of-deleted", "user2/of-deleted"}, names(children)); diff != "" {
+		t.Errorf("Children (-want, +got): %s", diff)
+	}
+
+	wantOrphans := []string{"user1/of-deleted", "user2/of-deleted", "user2/of-recreated"}
+	got, err := c.Collect(ctx)
+	if err != nil {
+		t.Fatalf("Collect() = %v", err)
+	}
+	if diff := cmp.Diff(Result{Orphans: wantOrphans}, got); diff != "" {
+		t.Errorf("Collect() in dry run (-want, +got): %s", diff)
+	}
+	if got := collected(t, "dry_run"); got != 3 {
+		t.Errorf("gc_collected_children{result=dry_run} = %d, want 3", got)
+	}
+	if all, err := c.list(); err != nil || len(all) != 6 {
+		t.Errorf("list() = %d children, %v, wanted nothing deleted in dry run", len(all), err)
+	}
+
+	c.DryRun = false
+	got, err = c.Collect(ctx)
+	if err != nil {
+		t.Fatalf("Collect() = %v", err)
+	}
+	if diff := cmp.Diff(Result{Orphans: wantOrphans, Deleted: 3}, got); diff != "" {
+		t.Errorf("Collect() (-want, +got): %s", diff)
+	}
+	if got := collected(t, "deleted"); got != 3 {
+		t.Errorf("gc_collected_children{result=deleted} = %d, want 3", got)
+	}
+	all, err := c.list()
+	if err != nil {
+		t.Fatalf("list() = %v", err)
+	}
+	if diff := cmp.Diff([]string{"user1/of-alive", "user2/invalid", "user2/unowned"}, names(all)); diff != "" {
+		t.Errorf("Remaining children (-want, +got): %s", diff)
+	}
+
+	// A collection of a namespace only deletes the orphans of it.
+	client = dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+		newChild("user1", "of-deleted", deleted),
+		newChild("user2", "of-deleted", deleted),
+	)
+	c = &Collector{
+		DynamicClient: client,
+		GVR:           childGVR,
+		Namespace:     "user1",
+		ParentExists:  DynamicParentExists(client, parentGVR),
+	}
+	if got, err := c.Collect(ctx); err != nil || got.Deleted != 1 {
+		t.Errorf("Collect() = %v, %v, wanted 1 child deleted", got, err)
+	}
+}
+
+func TestCollectParentCheckFails(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+		newChild("user1", "child", owner("parent", "1")))
+	checks := 0
+	c := &Collector{
+		DynamicClient: client,
+		GVR:           childGVR,
+		ParentExists: func(context.Context, kmeta.LogicalOwner) (bool, error) {
+			checks++
+			return false, errors.New("boom")
+		},
+	}
+	if _, err := c.Collect(TestContextWithLogger(t)); err == nil {
+		t.Error("Collect() = nil, wanted an error")
+	}
+	if all, _ := c.list(); len(all) != 1 {
+		t.Errorf("list() = %d children, wanted the child kept", len(all))
+	}
+}
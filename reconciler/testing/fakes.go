@@ -0,0 +1,247 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	clientgotesting "k8s.io/client-go/testing"
+)
+
+// FakeClient is implemented by the fake clientsets generated by client-gen,
+// like the ones returned by the injected fake clients, e.g.
+// `fakekubeclient.Get(ctx)`.
+type FakeClient interface {
+	Tracker() clientgotesting.ObjectTracker
+	PrependReactor(verb, resource string, reaction clientgotesting.ReactionFunc)
+	PrependWatchReactor(resource string, reaction clientgotesting.WatchReactionFunc)
+}
+
+// SeedObjects adds the given objects to the tracker of the fake client, so
+// that they are returned by the client and by the informers fed by it.
+func SeedObjects(t *testing.T, c FakeClient, objs ...runtime.Object) {
+	t.Helper()
+	for _, obj := range objs {
+		if err := c.Tracker().Add(obj); err != nil {
+			t.Fatalf("Failed to seed %T: %v", obj, err)
+		}
+	}
+}
+
+// PrependReactors prepends the given reactors to the reactor chain of the
+// fake client, in order, such that the first one is consulted first.
+func PrependReactors(c FakeClient, reactors ...clientgotesting.ReactionFunc) {
+	for i := len(reactors) - 1; i >= 0; i-- {
+		c.PrependReactor("*", "*", reactors[i])
+	}
+}
+
+// InduceFailureTimes is like InduceFailure, but only fails the first times
+// matching calls, after which the calls are passed on to the next reactors.
+func InduceFailureTimes(verb, resource string, times int) clientgotesting.ReactionFunc {
+	var m sync.Mutex
+	failures := 0
+	return func(action clientgotesting.Action) (handled bool, ret runtime.Object, err error) {
+		if !action.Matches(verb, resource) {
+			return false, nil, nil
+		}
+		m.Lock()
+		defer m.Unlock()
+		if failures >= times {
+			return false, nil, nil
+		}
+		failures++
+		return true, nil, fmt.Errorf("inducing failure %d of %d for %s %s",
+			failures, times, action.GetVerb(), action.GetResource().Resource)
+	}
+}
+
+// FakeWatches holds back the events of the watches opened on a fake client,
+// until the test delivers them through Step or Flush. This allows tests to
+// decide deterministically which changes the informers have seen.
+type FakeWatches struct {
+	m       sync.Mutex
+	watches []*controlledWatch
+}
+
+// InterceptWatches makes the watches opened on the given fake client from
+// now on, e.g. by informers started afterwards, hold back their events.
+func InterceptWatches(c FakeClient) *FakeWatches {
+	w := &FakeWatches{}
+	c.PrependWatchReactor("*", func(action clientgotesting.Action) (bool, watch.Interface, error) {
+		inner, err := c.Tracker().Watch(action.GetResource(), action.GetNamespace())
+		if err != nil {
+			return false, nil, err
+		}
+		cw := &controlledWatch{
+			inner:   inner,
+			result:  make(chan watch.Event),
+			stopped: make(chan struct{}),
+		}
+		w.m.Lock()
+		defer w.m.Unlock()
+		w.watches = append(w.watches, cw)
+		return true, cw, nil
+	})
+	return w
+}
+
+// WaitForWatches waits until at least n watches have been opened.
+func (w *FakeWatches) WaitForWatches(n int, timeout time.Duration) error {
+	return wait.PollImmediate(10*time.Millisecond, timeout, func() (bool, error) {
+		w.m.Lock()
+		defer w.m.Unlock()
+		return len(w.watches) >= n, nil
+	})
+}
+
+// Pending returns the number of events which have not been delivered yet.
+func (w *FakeWatches) Pending() int {
+	pending := 0
+	for _, cw := range w.active() {
+		pending += cw.collect()
+	}
+	return pending
+}
+
+// Step delivers the oldest pending event of the first watch with pending
+// events and returns whether there was one. It returns once the event has
+// been received by the watch's consumer.
+func (w *FakeWatches) Step() bool {
+	for _, cw := range w.active() {
+		if cw.collect() > 0 {
+			return cw.deliverNext()
+		}
+	}
+	return false
+}
+
+// Flush delivers all of the pending events. It returns once the consumers of
+// the watches, i.e. the informers' reflectors, have queued all of them for
+// their handlers.
+func (w *FakeWatches) Flush() {
+	for _, cw := range w.active() {
+		cw.collect()
+		for cw.deliverNext() {
+		}
+		cw.sync()
+	}
+}
+
+// active returns the watches which have not been stopped.
+func (w *FakeWatches) active() []*controlledWatch {
+	w.m.Lock()
+	defer w.m.Unlock()
+	active := make([]*controlledWatch, 0, len(w.watches))
+	for _, cw := range w.watches {
+		select {
+		case <-cw.stopped:
+		default:
+			active = append(active, cw)
+		}
+	}
+	return active
+}
+
+// controlledWatch is a watch.Interface holding back the events of an inner
+// watch until they are delivered explicitly.
+type controlledWatch struct {
+	inner   watch.Interface
+	result  chan watch.Event
+	stopped chan struct{}
+	once    sync.Once
+
+	m       sync.Mutex
+	pending []watch.Event
+	last    runtime.Object
+}
+
+var _ watch.Interface = (*controlledWatch)(nil)
+
+// Stop implements watch.Interface.
+func (cw *controlledWatch) Stop() {
+	cw.once.Do(func() {
+		close(cw.stopped)
+		cw.inner.Stop()
+	})
+}
+
+// ResultChan implements watch.Interface.
+func (cw *controlledWatch) ResultChan() <-chan watch.Event {
+	return cw.result
+}
+
+// collect moves the events the inner watch has produced so far to the
+// pending events and returns how many are pending.
+func (cw *controlledWatch) collect() int {
+	cw.m.Lock()
+	defer cw.m.Unlock()
+	for {
+		select {
+		case event, ok := <-cw.inner.ResultChan():
+			if !ok {
+				return len(cw.pending)
+			}
+			cw.pending = append(cw.pending, event)
+		default:
+			return len(cw.pending)
+		}
+	}
+}
+
+// deliverNext delivers the oldest pending event, if any, and returns whether
+// an event was delivered.
+func (cw *controlledWatch) deliverNext() bool {
+	cw.m.Lock()
+	if len(cw.pending) == 0 {
+		cw.m.Unlock()
+		return false
+	}
+	event := cw.pending[0]
+	cw.pending = cw.pending[1:]
+	cw.last = event.Object
+	cw.m.Unlock()
+
+	return cw.send(event)
+}
+
+// sync returns once the consumer has processed the last delivered event. It
+// sends a bookmark, which the consumer only receives after it's done with
+// the previous event, and which doesn't change its state.
+func (cw *controlledWatch) sync() {
+	cw.m.Lock()
+	last := cw.last
+	cw.m.Unlock()
+	if last != nil {
+		cw.send(watch.Event{Type: watch.Bookmark, Object: last.DeepCopyObject()})
+	}
+}
+
+func (cw *controlledWatch) send(event watch.Event) bool {
+	select {
+	case cw.result <- event:
+		return true
+	case <-cw.stopped:
+		return false
+	}
+}
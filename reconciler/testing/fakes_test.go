@@ -0,0 +1,133 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func pod(name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      name,
+		},
+	}
+}
+
+func TestSeedObjects(t *testing.T) {
+	c := fake.NewSimpleClientset()
+	SeedObjects(t, c, pod("foo"), pod("bar"))
+
+	pods, err := c.CoreV1().Pods("ns").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("List() = %v", err)
+	}
+	if got, want := len(pods.Items), 2; got != want {
+		t.Errorf("len(List()) = %d, want %d", got, want)
+	}
+}
+
+func TestInduceFailureTimes(t *testing.T) {
+	c := fake.NewSimpleClientset()
+	PrependReactors(c, InduceFailureTimes("create", "pods", 2))
+
+	for i, wantErr := range []bool{true, true, false} {
+		_, err := c.CoreV1().Pods("ns").Create(pod("foo"))
+		if (err != nil) != wantErr {
+			t.Errorf("Create() #%d = %v, wantErr = %v", i, err, wantErr)
+		}
+	}
+	if _, err := c.CoreV1().Pods("ns").Create(pod("foo")); !apierrs.IsAlreadyExists(err) {
+		t.Errorf("Create() = %v, wanted the tracker's AlreadyExists", err)
+	}
+}
+
+func TestFakeWatches(t *testing.T) {
+	c := fake.NewSimpleClientset()
+	SeedObjects(t, c, pod("seeded"))
+	watches := InterceptWatches(c)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	factory := informers.NewSharedInformerFactory(c, 0)
+	inf := factory.Core().V1().Pods()
+	lister := inf.Lister()
+	factory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, inf.Informer().HasSynced) {
+		t.Fatal("Failed to sync the informer")
+	}
+	if err := watches.WaitForWatches(1, 5*time.Second); err != nil {
+		t.Fatalf("WaitForWatches() = %v", err)
+	}
+
+	// Listing isn't affected by the interception.
+	if _, err := lister.Pods("ns").Get("seeded"); err != nil {
+		t.Errorf("Get(seeded) = %v", err)
+	}
+
+	for _, name := range []string{"first", "second"} {
+		if _, err := c.CoreV1().Pods("ns").Create(pod(name)); err != nil {
+			t.Fatalf("Create() = %v", err)
+		}
+	}
+	if got, want := watches.Pending(), 2; got != want {
+		t.Fatalf("Pending() = %d, want %d", got, want)
+	}
+	if _, err := lister.Pods("ns").Get("first"); !apierrs.IsNotFound(err) {
+		t.Errorf("Get(first) = %v, wanted NotFound before the event is delivered", err)
+	}
+
+	if !watches.Step() {
+		t.Fatal("Step() = false, wanted an event to be delivered")
+	}
+	if got, want := watches.Pending(), 1; got != want {
+		t.Errorf("Pending() = %d, want %d", got, want)
+	}
+	waitForPod(t, lister.Pods("ns").Get, "first")
+	if _, err := lister.Pods("ns").Get("second"); !apierrs.IsNotFound(err) {
+		t.Errorf("Get(second) = %v, wanted NotFound before the event is delivered", err)
+	}
+
+	watches.Flush()
+	if got, want := watches.Pending(), 0; got != want {
+		t.Errorf("Pending() = %d, want %d", got, want)
+	}
+	waitForPod(t, lister.Pods("ns").Get, "second")
+	if watches.Step() {
+		t.Error("Step() = true, wanted no events to be pending")
+	}
+}
+
+func waitForPod(t *testing.T, get func(string) (*corev1.Pod, error), name string) {
+	t.Helper()
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		_, err := get(name)
+		return err == nil, nil
+	}); err != nil {
+		t.Errorf("Pod %q never showed up in the lister: %v", name, err)
+	}
+}
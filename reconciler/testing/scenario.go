@@ -0,0 +1,266 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	clientgotesting "k8s.io/client-go/testing"
+
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/kmeta"
+)
+
+// ScenarioStep is a single reconciliation in a Scenario.
+type ScenarioStep struct {
+	// TableRow holds the key to reconcile and the expectations of the step.
+	// Its Objects are ignored: the step starts from the state the previous
+	// steps left the world in.
+	TableRow
+
+	// Event changes the state of the world before the reconciliation, e.g. to
+	// simulate a user updating or deleting a resource. It's passed copies of
+	// the objects and returns the new state, see ReplaceObjects.
+	Event func(objs []runtime.Object) []runtime.Object
+
+	// WantObjects, if set, holds the state of the world we expect after the
+	// reconciliation.
+	WantObjects []runtime.Object
+}
+
+// Scenario is a sequence of reconciliations sharing an evolving state of the
+// world, for testing the lifecycle of a resource, e.g. its creation, updates and
+// finalization. The creates, updates, deletes and patches recorded in a step are
+// applied to the state the next step starts from.
+type Scenario struct {
+	// Name is a descriptive name for this scenario suitable as a first argument to t.Run()
+	Name string
+
+	// Objects holds the state of the world at the onset of the scenario.
+	Objects []runtime.Object
+
+	// Steps are the reconciliations of the scenario, run in order.
+	Steps []ScenarioStep
+}
+
+// Test executes the steps of the scenario, stopping at the first failing one
+// since the following ones would start from an unexpected state.
+func (s *Scenario) Test(t *testing.T, factory Factory) {
+	t.Helper()
+	state := copyObjects(s.Objects)
+	for i := range s.Steps {
+		step := s.Steps[i]
+		if step.Event != nil {
+			state = step.Event(copyObjects(state))
+		}
+		step.Objects = copyObjects(state)
+
+		var recorders ActionRecorderList
+		ok := t.Run(step.Name, func(t *testing.T) {
+			t.Helper()
+			// Capture the recorders of the step to apply their actions.
+			stepFactory := func(t *testing.T, r *TableRow) (controller.Reconciler, ActionRecorderList, EventList, *FakeStatsReporter) {
+				c, l, e, sr := factory(t, r)
+				recorders = l
+				return c, l, e, sr
+			}
+			step.TableRow.Test(t, stepFactory)
+
+			next, err := applyActions(state, recorders)
+			if err != nil {
+				t.Fatalf("Failed to apply the actions of the step: %v", err)
+			}
+			state = next
+
+			if step.WantObjects != nil {
+				if diff := cmp.Diff(sortedObjects(step.WantObjects), sortedObjects(state), ignoreLastTransitionTime, safeDeployDiff, cmpopts.EquateEmpty()); diff != "" {
+					t.Errorf("Unexpected objects after reconciliation (-want, +got): %s", diff)
+				}
+			}
+		})
+		if !ok {
+			return
+		}
+	}
+}
+
+// Scenarios represents a list of Scenario tests instances.
+type Scenarios []Scenario
+
+// Test executes the whole suite of the scenario tests.
+func (ss Scenarios) Test(t *testing.T, factory Factory) {
+	t.Helper()
+	for _, s := range ss {
+		s := s
+		t.Run(s.Name, func(t *testing.T) {
+			t.Helper()
+			s.Test(t, factory)
+		})
+	}
+}
+
+// ReplaceObjects returns a ScenarioStep.Event replacing the objects of the state
+// with the given ones, matched by type, namespace and name. The objects missing
+// from the state are added to it.
+func ReplaceObjects(objs ...runtime.Object) func([]runtime.Object) []runtime.Object {
+	return func(state []runtime.Object) []runtime.Object {
+		for _, obj := range objs {
+			state = setObject(state, obj.DeepCopyObject())
+		}
+		return state
+	}
+}
+
+// setObject replaces the object with the same key in the state, or adds it.
+func setObject(state []runtime.Object, obj runtime.Object) []runtime.Object {
+	key := objKey(obj)
+	for i, o := range state {
+		if objKey(o) == key {
+			state[i] = obj
+			return state
+		}
+	}
+	return append(state, obj)
+}
+
+// applyActions returns the state with the mutating actions recorded by the
+// recorders applied to it.
+func applyActions(state []runtime.Object, recorders ActionRecorderList) ([]runtime.Object, error) {
+	for _, recorder := range recorders {
+		for _, action := range recorder.Actions() {
+			switch action.GetVerb() {
+			case "create", "update":
+				// Both create and update actions implement CreateAction.
+				a := action.(clientgotesting.CreateAction)
+				state = setObject(state, a.GetObject().DeepCopyObject())
+			case "delete":
+				a := action.(clientgotesting.DeleteAction)
+				state = removeObject(state, a.GetResource(), a.GetNamespace(), a.GetName())
+			case "patch":
+				a := action.(clientgotesting.PatchAction)
+				i := findObject(state, a.GetResource(), a.GetNamespace(), a.GetName())
+				if i < 0 {
+					return nil, fmt.Errorf("patched object %s %s/%s does not exist", a.GetResource().Resource, a.GetNamespace(), a.GetName())
+				}
+				patched, err := applyPatch(state[i], a)
+				if err != nil {
+					return nil, err
+				}
+				state[i] = patched
+			}
+		}
+	}
+	return state, nil
+}
+
+// findObject returns the index of the object of the resource with the given
+// namespace and name in the state, or -1 if it's not there. The resource of the
+// objects is guessed from the name of their type, as the tests don't populate
+// their kind information.
+func findObject(state []runtime.Object, gvr schema.GroupVersionResource, namespace, name string) int {
+	for i, o := range state {
+		acc := o.(kmeta.Accessor)
+		if acc.GetNamespace() != namespace || acc.GetName() != name {
+			continue
+		}
+		kind := reflect.TypeOf(o).Elem().Name()
+		plural, _ := meta.UnsafeGuessKindToResource(schema.GroupVersionKind{Kind: kind})
+		if plural.Resource == gvr.Resource {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeObject returns the state without the object of the resource with the
+// given namespace and name.
+func removeObject(state []runtime.Object, gvr schema.GroupVersionResource, namespace, name string) []runtime.Object {
+	if i := findObject(state, gvr, namespace, name); i >= 0 {
+		return append(state[:i], state[i+1:]...)
+	}
+	return state
+}
+
+// applyPatch returns a copy of the object with the patch of the action applied.
+func applyPatch(obj runtime.Object, action clientgotesting.PatchAction) (runtime.Object, error) {
+	original, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var patched []byte
+	switch action.GetPatchType() {
+	case types.JSONPatchType:
+		patch, err := jsonpatch.DecodePatch(action.GetPatch())
+		if err != nil {
+			return nil, err
+		}
+		patched, err = patch.Apply(original)
+		if err != nil {
+			return nil, err
+		}
+	case types.MergePatchType:
+		patched, err = jsonpatch.MergePatch(original, action.GetPatch())
+		if err != nil {
+			return nil, err
+		}
+	case types.StrategicMergePatchType:
+		patched, err = strategicpatch.StrategicMergePatch(original, action.GetPatch(), obj)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported patch type %q", action.GetPatchType())
+	}
+
+	result := reflect.New(reflect.TypeOf(obj).Elem()).Interface().(runtime.Object)
+	if err := json.Unmarshal(patched, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func copyObjects(objs []runtime.Object) []runtime.Object {
+	copies := make([]runtime.Object, 0, len(objs))
+	for _, o := range objs {
+		copies = append(copies, o.DeepCopyObject())
+	}
+	return copies
+}
+
+// sortedObjects returns the objects sorted by key, so the state can be compared
+// regardless of the order the objects were created in.
+func sortedObjects(objs []runtime.Object) []runtime.Object {
+	sorted := append([]runtime.Object(nil), objs...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return objKey(sorted[i]) < objKey(sorted[j])
+	})
+	return sorted
+}
@@ -0,0 +1,230 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	fakekubeclientset "k8s.io/client-go/kubernetes/fake"
+	clientgotesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	"knative.dev/pkg/controller"
+)
+
+const testFinalizer = "scenario.knative.dev"
+
+// childReconciler mirrors the data of parent config maps into child config maps,
+// which it deletes when the parent is finalized.
+type childReconciler struct {
+	client kubernetes.Interface
+}
+
+func (r *childReconciler) Reconcile(ctx context.Context, key string) error {
+	ns, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	cms := r.client.CoreV1().ConfigMaps(ns)
+	parent, err := cms.Get(name, metav1.GetOptions{})
+	if apierrs.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if parent.DeletionTimestamp != nil {
+		if err := cms.Delete(name+"-child", &metav1.DeleteOptions{}); err != nil && !apierrs.IsNotFound(err) {
+			return err
+		}
+		_, err := cms.Patch(name, types.MergePatchType, []byte(`{"metadata":{"finalizers":null}}`))
+		return err
+	}
+
+	if len(parent.Finalizers) == 0 {
+		if _, err := cms.Patch(name, types.MergePatchType, []byte(`{"metadata":{"finalizers":["`+testFinalizer+`"]}}`)); err != nil {
+			return err
+		}
+	}
+
+	want := child(ns, name, parent.Data)
+	got, err := cms.Get(want.Name, metav1.GetOptions{})
+	if apierrs.IsNotFound(err) {
+		_, err = cms.Create(want)
+		return err
+	} else if err != nil {
+		return err
+	}
+	if !cmp.Equal(got.Data, want.Data) {
+		got = got.DeepCopy()
+		got.Data = want.Data
+		_, err = cms.Update(got)
+	}
+	return err
+}
+
+func parent(ns, name string, data map[string]string, opts ...func(*corev1.ConfigMap)) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+		Data:       data,
+	}
+	for _, opt := range opts {
+		opt(cm)
+	}
+	return cm
+}
+
+func child(ns, name string, data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name + "-child"},
+		Data:       data,
+	}
+}
+
+func withFinalizer(cm *corev1.ConfigMap) {
+	cm.Finalizers = []string{testFinalizer}
+}
+
+func deleted(cm *corev1.ConfigMap) {
+	t := metav1.Unix(1, 0)
+	cm.DeletionTimestamp = &t
+}
+
+func childFactory(t *testing.T, r *TableRow) (controller.Reconciler, ActionRecorderList, EventList, *FakeStatsReporter) {
+	client := fakekubeclientset.NewSimpleClientset(r.Objects...)
+	eventList := EventList{Recorder: record.NewFakeRecorder(10)}
+	return &childReconciler{client: client}, ActionRecorderList{client}, eventList, &FakeStatsReporter{}
+}
+
+func patch(ns, name, p string) clientgotesting.PatchActionImpl {
+	action := clientgotesting.PatchActionImpl{}
+	action.Namespace = ns
+	action.Name = name
+	action.Patch = []byte(p)
+	return action
+}
+
+func TestScenario(t *testing.T) {
+	v1 := map[string]string{"key": "v1"}
+	v2 := map[string]string{"key": "v2"}
+
+	Scenarios{{
+		Name:    "lifecycle",
+		Objects: []runtime.Object{parent("ns", "parent", v1)},
+		Steps: []ScenarioStep{{
+			TableRow: TableRow{
+				Name:        "create",
+				Key:         "ns/parent",
+				WantCreates: []runtime.Object{child("ns", "parent", v1)},
+				WantPatches: []clientgotesting.PatchActionImpl{
+					patch("ns", "parent", `{"metadata":{"finalizers":["`+testFinalizer+`"]}}`),
+				},
+			},
+			WantObjects: []runtime.Object{
+				parent("ns", "parent", v1, withFinalizer),
+				child("ns", "parent", v1),
+			},
+		}, {
+			TableRow: TableRow{
+				Name: "steady state",
+				Key:  "ns/parent",
+			},
+		}, {
+			TableRow: TableRow{
+				Name: "update",
+				Key:  "ns/parent",
+				WantUpdates: []clientgotesting.UpdateActionImpl{{
+					Object: child("ns", "parent", v2),
+				}},
+			},
+			Event: ReplaceObjects(parent("ns", "parent", v2, withFinalizer)),
+		}, {
+			TableRow: TableRow{
+				Name: "finalize",
+				Key:  "ns/parent",
+				WantDeletes: []clientgotesting.DeleteActionImpl{{
+					Name: "parent-child",
+				}},
+				WantPatches: []clientgotesting.PatchActionImpl{
+					patch("ns", "parent", `{"metadata":{"finalizers":null}}`),
+				},
+			},
+			Event: ReplaceObjects(parent("ns", "parent", v2, deleted, withFinalizer)),
+			WantObjects: []runtime.Object{
+				parent("ns", "parent", v2, deleted),
+			},
+		}},
+	}}.Test(t, childFactory)
+}
+
+func TestApplyPatch(t *testing.T) {
+	cm := parent("ns", "name", map[string]string{"a": "1", "b": "2"})
+
+	tests := []struct {
+		name      string
+		patchType types.PatchType
+		patch     string
+		want      *corev1.ConfigMap
+		wantErr   bool
+	}{{
+		name:      "json",
+		patchType: types.JSONPatchType,
+		patch:     `[{"op":"remove","path":"/data/a"}]`,
+		want:      parent("ns", "name", map[string]string{"b": "2"}),
+	}, {
+		name:      "merge",
+		patchType: types.MergePatchType,
+		patch:     `{"data":{"a":null,"c":"3"}}`,
+		want:      parent("ns", "name", map[string]string{"b": "2", "c": "3"}),
+	}, {
+		name:      "strategic merge",
+		patchType: types.StrategicMergePatchType,
+		patch:     `{"metadata":{"finalizers":["` + testFinalizer + `"]}}`,
+		want:      parent("ns", "name", map[string]string{"a": "1", "b": "2"}, withFinalizer),
+	}, {
+		name:      "unsupported",
+		patchType: types.ApplyPatchType,
+		patch:     `{}`,
+		wantErr:   true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			action := clientgotesting.NewPatchAction(corev1.SchemeGroupVersion.WithResource("configmaps"),
+				"ns", "name", test.patchType, []byte(test.patch))
+			got, err := applyPatch(cm, action)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("applyPatch() = %v, wantErr %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("applyPatch() (-want, +got): %s", diff)
+			}
+		})
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -32,6 +33,7 @@ import (
 	clientgotesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/cache"
 
+	"knative.dev/pkg/clock"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/kmeta"
 	"knative.dev/pkg/logging"
@@ -47,6 +49,10 @@ type TableRow struct {
 	// Ctx is the context to pass to Reconcile. Defaults to context.Background()
 	Ctx context.Context
 
+	// Now, if set, is the time of an auto-advancing fake clock attached to
+	// the context passed to Reconcile, see clock.FromContext.
+	Now time.Time
+
 	// Objects holds the state of the world at the onset of reconciliation.
 	Objects []runtime.Object
 
@@ -122,6 +128,9 @@ func (r *TableRow) Test(t *testing.T, factory Factory) {
 		l = l.With(zap.String(logkey.Key, r.Key))
 		ctx = logging.WithLogger(ctx, l)
 	}
+	if !r.Now.IsZero() {
+		ctx = clock.WithClock(ctx, clock.NewAutoAdvancingFake(r.Now))
+	}
 
 	// Run the Reconcile we're testing.
 	if err := c.Reconcile(ctx, r.Key); (err != nil) != r.WantErr {
@@ -33,6 +33,7 @@ type NullTracker = FakeTracker
 type FakeTracker struct {
 	sync.Mutex
 	references []corev1.ObjectReference
+	tracked    []tracker.Reference
 }
 
 var _ tracker.Interface = (*FakeTracker)(nil)
@@ -40,12 +41,33 @@ var _ tracker.Interface = (*FakeTracker)(nil)
 // OnChanged implements OnChanged.
 func (*FakeTracker) OnChanged(interface{}) {}
 
+// ForgetAllFor implements ForgetAllFor.
+func (*FakeTracker) ForgetAllFor(interface{}) {}
+
 // Track implements Track.
 func (n *FakeTracker) Track(ref corev1.ObjectReference, obj interface{}) error {
 	n.Lock()
 	defer n.Unlock()
 
 	n.references = append(n.references, ref)
+	n.tracked = append(n.tracked, tracker.Reference{
+		APIVersion: ref.APIVersion,
+		Kind:       ref.Kind,
+		Namespace:  ref.Namespace,
+		Name:       ref.Name,
+	})
+	return nil
+}
+
+// TrackReference implements TrackReference.
+func (n *FakeTracker) TrackReference(ref tracker.Reference, obj interface{}) error {
+	n.Lock()
+	defer n.Unlock()
+
+	if ref.Selector == nil {
+		n.references = append(n.references, ref.ObjectReference())
+	}
+	n.tracked = append(n.tracked, ref)
 	return nil
 }
 
@@ -56,3 +78,12 @@ func (n *FakeTracker) References() []corev1.ObjectReference {
 
 	return append(n.references[:0:0], n.references...)
 }
+
+// TrackedReferences returns the list of references being tracked, by name
+// or by label selector.
+func (n *FakeTracker) TrackedReferences() []tracker.Reference {
+	n.Lock()
+	defer n.Unlock()
+
+	return append(n.tracked[:0:0], n.tracked...)
+}
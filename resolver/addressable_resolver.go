@@ -28,7 +28,7 @@ import (
 
 	"knative.dev/pkg/apis"
 	pkgapisduck "knative.dev/pkg/apis/duck"
-	duckv1beta1 "knative.dev/pkg/apis/duck/v1beta1"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
 	apisv1alpha1 "knative.dev/pkg/apis/v1alpha1"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/network"
@@ -41,18 +41,38 @@ import (
 type URIResolver struct {
 	tracker         tracker.Interface
 	informerFactory pkgapisduck.InformerFactory
+	cache           *uriCache
+
+	// The options for resolving Kubernetes Services.
+	domains                *network.DomainResolver
+	externalNames          bool
+	servicePortName        string
+	serviceInformerFactory pkgapisduck.InformerFactory
 }
 
 // NewURIResolver constructs a new URIResolver with context and a callback passed to the URIResolver's tracker.
-func NewURIResolver(ctx context.Context, callback func(types.NamespacedName)) *URIResolver {
+func NewURIResolver(ctx context.Context, callback func(types.NamespacedName), opts ...Option) *URIResolver {
 	ret := &URIResolver{}
+	for _, opt := range opts {
+		opt(ret)
+	}
 
 	ret.tracker = tracker.New(callback, controller.GetTrackerLease(ctx))
 	ret.informerFactory = &pkgapisduck.CachedInformerFactory{
 		Delegate: &pkgapisduck.EnqueueInformerFactory{
 			Delegate: &pkgapisduck.TypedInformerFactory{
 				Client:       dynamicclient.Get(ctx),
-				Type:         &duckv1beta1.AddressableType{},
+				Type:         &duckv1.AddressableType{},
+				ResyncPeriod: controller.GetResyncPeriod(ctx),
+				StopChannel:  ctx.Done(),
+			},
+			EventHandler: controller.HandleAll(ret.onChanged),
+		},
+	}
+	ret.serviceInformerFactory = &pkgapisduck.CachedInformerFactory{
+		Delegate: &pkgapisduck.EnqueueInformerFactory{
+			Delegate: &unstructuredInformerFactory{
+				Client:       dynamicclient.Get(ctx),
 				ResyncPeriod: controller.GetResyncPeriod(ctx),
 				StopChannel:  ctx.Done(),
 			},
@@ -63,26 +83,66 @@ func NewURIResolver(ctx context.Context, callback func(types.NamespacedName)) *U
 	return ret
 }
 
+// domainResolver returns the DomainResolver to build the hostnames of
+// Kubernetes Services with.
+func (r *URIResolver) domainResolver() *network.DomainResolver {
+	if r.domains != nil {
+		return r.domains
+	}
+	return network.DefaultDomainResolver()
+}
+
+// onChanged drops the cached URI of the changed object before notifying the
+// tracker, so the tracking objects resolve the current URI.
+func (r *URIResolver) onChanged(obj interface{}) {
+	r.cache.invalidate(obj)
+	r.tracker.OnChanged(obj)
+}
+
 // URIFromDestination resolves a Destination into a URI string.
 func (r *URIResolver) URIFromDestination(dest apisv1alpha1.Destination, parent interface{}) (string, error) {
 	// Prefer resolved object reference + path, then try URI + path, honoring the Destination documentation
+	addr, err := r.AddressableFromDestination(dest, parent)
+	if err != nil {
+		return "", err
+	}
+	return addr.URL.String(), nil
+}
+
+// AddressableFromDestination resolves a Destination into an Addressable,
+// which carries the CA certificates and the audience of the destination
+// along with its URL, if the referenced Addressable exposes them.
+func (r *URIResolver) AddressableFromDestination(dest apisv1alpha1.Destination, parent interface{}) (*duckv1.Addressable, error) {
 	if dest.ObjectReference != nil {
-		url, err := r.URIFromObjectReference(dest.ObjectReference, parent)
+		addr, err := r.AddressableFromObjectReference(dest.ObjectReference, parent)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		return extendPath(url.DeepCopy(), dest.Path).String(), nil
+		addr.URL = extendPath(addr.URL, dest.Path)
+		return addr, nil
 	}
 
 	if dest.URI != nil {
-		return extendPath(dest.URI.DeepCopy(), dest.Path).String(), nil
+		return &duckv1.Addressable{
+			URL: extendPath(dest.URI.DeepCopy(), dest.Path),
+		}, nil
 	}
 
-	return "", fmt.Errorf("destination missing ObjectReference and URI, expected exactly one")
+	return nil, fmt.Errorf("destination missing ObjectReference and URI, expected exactly one")
 }
 
 // URIFromObjectReference resolves an ObjectReference to a URI string.
 func (r *URIResolver) URIFromObjectReference(ref *corev1.ObjectReference, parent interface{}) (*apis.URL, error) {
+	addr, err := r.AddressableFromObjectReference(ref, parent)
+	if err != nil {
+		return nil, err
+	}
+	return addr.URL, nil
+}
+
+// AddressableFromObjectReference resolves an ObjectReference to an
+// Addressable. The returned Addressable is a copy which may be modified.
+func (r *URIResolver) AddressableFromObjectReference(ref *corev1.ObjectReference, parent interface{}) (*duckv1.Addressable, error) {
 	if ref == nil {
 		return nil, errors.New("ref is nil")
 	}
@@ -93,14 +153,16 @@ func (r *URIResolver) URIFromObjectReference(ref *corev1.ObjectReference, parent
 
 	// K8s Services are special cased. They can be called, even though they do not satisfy the
 	// Callable interface.
-	// TODO(spencer-p,n3wscott) Verify that the service actually exists in K8s.
 	if ref.APIVersion == "v1" && ref.Kind == "Service" {
-		url := &apis.URL{
-			Scheme: "http",
-			Host:   ServiceHostName(ref.Name, ref.Namespace),
-			Path:   "/",
+		url, err := r.serviceURL(ref)
+		if err != nil {
+			return nil, err
 		}
-		return url, nil
+		return &duckv1.Addressable{URL: url}, nil
+	}
+
+	if addr, ok := r.cache.get(*ref); ok {
+		return addr, nil
 	}
 
 	gvr, _ := meta.UnsafeGuessKindToResource(ref.GroupVersionKind())
@@ -114,7 +176,7 @@ func (r *URIResolver) URIFromObjectReference(ref *corev1.ObjectReference, parent
 		return nil, fmt.Errorf("failed to get ref %+v: %v", ref, err)
 	}
 
-	addressable, ok := obj.(*duckv1beta1.AddressableType)
+	addressable, ok := obj.(*duckv1.AddressableType)
 	if !ok {
 		return nil, fmt.Errorf("%+v is not an AddressableType", ref)
 	}
@@ -128,7 +190,8 @@ func (r *URIResolver) URIFromObjectReference(ref *corev1.ObjectReference, parent
 	if url.Host == "" {
 		return nil, fmt.Errorf("hostname missing in address of %+v", ref)
 	}
-	return url, nil
+	r.cache.put(*ref, addressable.Status.Address)
+	return addressable.Status.Address.DeepCopy(), nil
 }
 
 // extendPath is a convenience wrapper to add a destination's path.
@@ -143,5 +206,5 @@ func extendPath(url *apis.URL, extrapath *string) *apis.URL {
 
 // ServiceHostName resolves the hostname for a Kubernetes Service.
 func ServiceHostName(serviceName, namespace string) string {
-	return fmt.Sprintf("%s.%s.svc.%s", serviceName, namespace, network.GetClusterDomainName())
+	return network.GetServiceHostname(serviceName, namespace)
 }
@@ -20,14 +20,19 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes/scheme"
 	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
 	duckv1alpha1 "knative.dev/pkg/apis/duck/v1alpha1"
 	duckv1beta1 "knative.dev/pkg/apis/duck/v1beta1"
 	apisv1alpha1 "knative.dev/pkg/apis/v1alpha1"
@@ -53,6 +58,7 @@ var (
 
 func init() {
 	// Add types to scheme
+	duckv1.AddToScheme(scheme.Scheme)
 	duckv1alpha1.AddToScheme(scheme.Scheme)
 	duckv1beta1.AddToScheme(scheme.Scheme)
 }
@@ -287,3 +293,214 @@ func getUnaddressableRef() *corev1.ObjectReference {
 		Namespace:  testNS,
 	}
 }
+
+func TestGetURI_CacheInvalidation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx, client := fakedynamicclient.With(ctx, scheme.Scheme, getAddressable())
+	r := resolver.NewURIResolver(ctx, func(types.NamespacedName) {}, resolver.WithCacheTTL(time.Hour))
+
+	dest := apisv1alpha1.Destination{ObjectReference: getAddressableRef()}
+	uri, err := r.URIFromDestination(dest, getAddressable())
+	if err != nil {
+		t.Fatalf("URIFromDestination() = %v", err)
+	}
+	if uri != addressableDNS {
+		t.Fatalf("URIFromDestination() = %s, want %s", uri, addressableDNS)
+	}
+
+	// Changing the Addressable drops the cached URI long before the TTL.
+	const newDNS = "http://new.sink.svc.cluster.local"
+	updated := getAddressable()
+	updated.Object["status"] = map[string]interface{}{
+		"address": map[string]interface{}{
+			"url": newDNS,
+		},
+	}
+	gvr := schema.GroupVersionResource{Group: "duck.knative.dev", Version: "v1alpha1", Resource: "sinks"}
+	if _, err := client.Resource(gvr).Namespace(testNS).Update(updated, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Update() = %v", err)
+	}
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		uri, err := r.URIFromDestination(dest, getAddressable())
+		return uri == newDNS, err
+	}); err != nil {
+		t.Errorf("Never resolved the updated URI: %v", err)
+	}
+}
+
+func TestGetAddressable(t *testing.T) {
+	addressable := getAddressable()
+	addressable.Object["status"] = map[string]interface{}{
+		"address": map[string]interface{}{
+			"url":      addressableDNS,
+			"CACerts":  "-----BEGIN CERTIFICATE-----",
+			"audience": "sink",
+		},
+	}
+
+	tests := map[string]struct {
+		dest apisv1alpha1.Destination
+		want *duckv1.Addressable
+	}{"addressable with CA certs and audience": {
+		dest: apisv1alpha1.Destination{
+			ObjectReference: getAddressableRef(),
+			Path:            ptr.String("/foo"),
+		},
+		want: &duckv1.Addressable{
+			URL: &apis.URL{
+				Scheme: "http",
+				Host:   "addressable.sink.svc.cluster.local",
+				Path:   "/foo",
+			},
+			CACerts:  ptr.String("-----BEGIN CERTIFICATE-----"),
+			Audience: ptr.String("sink"),
+		},
+	}, "service": {
+		dest: apisv1alpha1.Destination{
+			ObjectReference: &corev1.ObjectReference{
+				APIVersion: "v1",
+				Kind:       "Service",
+				Namespace:  testNS,
+				Name:       "svc",
+			},
+		},
+		want: &duckv1.Addressable{
+			URL: &apis.URL{
+				Scheme: "http",
+				Host:   "svc." + testNS + ".svc.cluster.local",
+				Path:   "/",
+			},
+		},
+	}, "URI": {
+		dest: apisv1alpha1.Destination{
+			URI: &apis.URL{Scheme: "http", Host: "example.com"},
+		},
+		want: &duckv1.Addressable{
+			URL: &apis.URL{Scheme: "http", Host: "example.com"},
+		},
+	}}
+
+	for n, tc := range tests {
+		t.Run(n, func(t *testing.T) {
+			ctx, _ := fakedynamicclient.With(context.Background(), scheme.Scheme, addressable)
+			r := resolver.NewURIResolver(ctx, func(types.NamespacedName) {})
+
+			got, err := r.AddressableFromDestination(tc.dest, getAddressable())
+			if err != nil {
+				t.Fatalf("AddressableFromDestination() = %v", err)
+			}
+			if !cmp.Equal(got, tc.want) {
+				t.Errorf("AddressableFromDestination() (-want, +got) = %s", cmp.Diff(tc.want, got))
+			}
+		})
+	}
+}
+
+func TestGetURI_ServiceOptions(t *testing.T) {
+	service := func(name string, spec map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Service",
+				"metadata": map[string]interface{}{
+					"namespace": testNS,
+					"name":      name,
+				},
+				"spec": spec,
+			},
+		}
+	}
+	objects := []runtime.Object{
+		service("external", map[string]interface{}{
+			"type":         "ExternalName",
+			"externalName": "example.com",
+		}),
+		service("ports", map[string]interface{}{
+			"ports": []interface{}{
+				map[string]interface{}{"name": "http", "port": int64(80)},
+				map[string]interface{}{"name": "http-admin", "port": int64(8080)},
+				map[string]interface{}{"name": "https", "port": int64(443)},
+				map[string]interface{}{"name": "https-admin", "port": int64(8443)},
+			},
+		}),
+	}
+	serviceRef := func(name string) *corev1.ObjectReference {
+		return &corev1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Service",
+			Namespace:  testNS,
+			Name:       name,
+		}
+	}
+
+	tests := map[string]struct {
+		opts    []resolver.Option
+		ref     *corev1.ObjectReference
+		wantURI string
+		wantErr string
+	}{"cluster domain": {
+		opts:    []resolver.Option{resolver.WithClusterDomain("mesh.internal")},
+		ref:     serviceRef("ports"),
+		wantURI: "http://ports.testnamespace.svc.mesh.internal/",
+	}, "external name ignored by default": {
+		opts:    []resolver.Option{resolver.WithClusterDomain("cluster.local")},
+		ref:     serviceRef("external"),
+		wantURI: "http://external.testnamespace.svc.cluster.local/",
+	}, "external name": {
+		opts:    []resolver.Option{resolver.WithExternalNames()},
+		ref:     serviceRef("external"),
+		wantURI: "http://example.com/",
+	}, "external name of regular service": {
+		opts:    []resolver.Option{resolver.WithExternalNames(), resolver.WithClusterDomain("cluster.local")},
+		ref:     serviceRef("ports"),
+		wantURI: "http://ports.testnamespace.svc.cluster.local/",
+	}, "default http port": {
+		opts:    []resolver.Option{resolver.WithServicePortName("http"), resolver.WithClusterDomain("cluster.local")},
+		ref:     serviceRef("ports"),
+		wantURI: "http://ports.testnamespace.svc.cluster.local/",
+	}, "http port": {
+		opts:    []resolver.Option{resolver.WithServicePortName("http-admin"), resolver.WithClusterDomain("cluster.local")},
+		ref:     serviceRef("ports"),
+		wantURI: "http://ports.testnamespace.svc.cluster.local:8080/",
+	}, "default https port": {
+		opts:    []resolver.Option{resolver.WithServicePortName("https"), resolver.WithClusterDomain("cluster.local")},
+		ref:     serviceRef("ports"),
+		wantURI: "https://ports.testnamespace.svc.cluster.local/",
+	}, "https port": {
+		opts:    []resolver.Option{resolver.WithServicePortName("https-admin"), resolver.WithClusterDomain("cluster.local")},
+		ref:     serviceRef("ports"),
+		wantURI: "https://ports.testnamespace.svc.cluster.local:8443/",
+	}, "missing port": {
+		opts:    []resolver.Option{resolver.WithServicePortName("grpc")},
+		ref:     serviceRef("ports"),
+		wantErr: `service testnamespace/ports has no port named "grpc"`,
+	}, "missing service": {
+		opts:    []resolver.Option{resolver.WithExternalNames()},
+		ref:     serviceRef("missing"),
+		wantErr: fmt.Sprintf(`failed to get ref %+v: services "missing" not found`, serviceRef("missing")),
+	}}
+
+	for n, tc := range tests {
+		t.Run(n, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			ctx, _ = fakedynamicclient.With(ctx, scheme.Scheme, objects...)
+			r := resolver.NewURIResolver(ctx, func(types.NamespacedName) {}, tc.opts...)
+
+			uri, err := r.URIFromDestination(apisv1alpha1.Destination{ObjectReference: tc.ref}, getAddressable())
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Errorf("URIFromDestination() = %v, wanted error %s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("URIFromDestination() = %v", err)
+			}
+			if uri != tc.wantURI {
+				t.Errorf("URIFromDestination() = %s, want %s", uri, tc.wantURI)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"knative.dev/pkg/kmeta"
+)
+
+// uriCache caches resolved Addressables by the reference they have been
+// resolved from. A nil uriCache caches nothing.
+type uriCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	m       sync.RWMutex
+	entries map[corev1.ObjectReference]uriCacheEntry
+}
+
+type uriCacheEntry struct {
+	addr   *duckv1.Addressable
+	expiry time.Time
+}
+
+func newURICache(ttl time.Duration) *uriCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &uriCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[corev1.ObjectReference]uriCacheEntry),
+	}
+}
+
+// cacheKey strips the fields that don't identify the referenced object.
+func cacheKey(ref corev1.ObjectReference) corev1.ObjectReference {
+	return corev1.ObjectReference{
+		APIVersion: ref.APIVersion,
+		Kind:       ref.Kind,
+		Namespace:  ref.Namespace,
+		Name:       ref.Name,
+	}
+}
+
+// get returns a copy of the cached Addressable for the reference, if it
+// hasn't expired yet.
+func (c *uriCache) get(ref corev1.ObjectReference) (*duckv1.Addressable, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.m.RLock()
+	defer c.m.RUnlock()
+	e, ok := c.entries[cacheKey(ref)]
+	if !ok || c.now().After(e.expiry) {
+		return nil, false
+	}
+	return e.addr.DeepCopy(), true
+}
+
+// put caches a copy of the Addressable resolved for the reference.
+func (c *uriCache) put(ref corev1.ObjectReference, addr *duckv1.Addressable) {
+	if c == nil {
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	now := c.now()
+	// Drop the expired entries on the way, so they don't accumulate.
+	for k, e := range c.entries {
+		if now.After(e.expiry) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[cacheKey(ref)] = uriCacheEntry{
+		addr:   addr.DeepCopy(),
+		expiry: now.Add(c.ttl),
+	}
+}
+
+// invalidate drops the cached Addressable of the given object, which changed.
+func (c *uriCache) invalidate(obj interface{}) {
+	if c == nil {
+		return
+	}
+	item, err := kmeta.DeletionHandlingAccessor(obj)
+	if err != nil {
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	delete(c.entries, cacheKey(kmeta.ObjectReference(item)))
+}
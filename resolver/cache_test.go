@@ -0,0 +1,99 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"knative.dev/pkg/ptr"
+)
+
+func TestURICache(t *testing.T) {
+	now := time.Now()
+	c := newURICache(time.Minute)
+	c.now = func() time.Time { return now }
+
+	ref := corev1.ObjectReference{
+		APIVersion: "duck.knative.dev/v1alpha1",
+		Kind:       "Sink",
+		Namespace:  "ns",
+		Name:       "sink",
+	}
+	addr := &duckv1.Addressable{
+		URL:     &apis.URL{Scheme: "http", Host: "sink.ns.svc.cluster.local"},
+		CACerts: ptr.String("certs"),
+	}
+
+	if got, ok := c.get(ref); ok {
+		t.Errorf("get() = %v, wanted a miss", got)
+	}
+
+	c.put(ref, addr)
+	// Fields not identifying the object don't matter.
+	withUID := ref
+	withUID.UID = "1234"
+	got, ok := c.get(withUID)
+	if !ok || !cmp.Equal(got, addr) {
+		t.Errorf("get() = %v, %v, wanted %v", got, ok, addr)
+	}
+	// The cache hands out copies.
+	got.URL.Host = "changed"
+	if got, _ := c.get(ref); !cmp.Equal(got, addr) {
+		t.Errorf("get() = %v, wanted the cached copy not to change", got)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if got, ok := c.get(ref); ok {
+		t.Errorf("get() = %v, wanted the entry to have expired", got)
+	}
+
+	c.put(ref, addr)
+	c.invalidate(cache.DeletedFinalStateUnknown{
+		Key: "ns/sink",
+		Obj: &duckv1.AddressableType{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: ref.APIVersion,
+				Kind:       ref.Kind,
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ref.Namespace,
+				Name:      ref.Name,
+			},
+		},
+	})
+	if got, ok := c.get(ref); ok {
+		t.Errorf("get() = %v, wanted the entry to have been invalidated", got)
+	}
+}
+
+func TestURICacheDisabled(t *testing.T) {
+	c := newURICache(0)
+	ref := corev1.ObjectReference{Name: "sink"}
+	c.put(ref, &duckv1.Addressable{URL: &apis.URL{Host: "sink"}})
+	if got, ok := c.get(ref); ok {
+		t.Errorf("get() = %v, wanted a miss", got)
+	}
+	c.invalidate(nil)
+}
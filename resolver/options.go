@@ -0,0 +1,69 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"time"
+
+	"knative.dev/pkg/network"
+)
+
+// Option configures a URIResolver.
+type Option func(*URIResolver)
+
+// WithCacheTTL makes the resolver cache the URIs resolved from Addressables
+// for up to the given time. Cached URIs are also dropped as soon as the
+// resolver's informers see a change of the Addressable. A zero TTL, the
+// default, disables caching.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(r *URIResolver) {
+		r.cache = newURICache(ttl)
+	}
+}
+
+// WithDomainResolver makes the resolver use the given DomainResolver to build
+// the hostnames of Kubernetes Services, instead of the process-wide one.
+func WithDomainResolver(dr *network.DomainResolver) Option {
+	return func(r *URIResolver) {
+		r.domains = dr
+	}
+}
+
+// WithClusterDomain makes the resolver build the hostnames of Kubernetes
+// Services with the given cluster domain, instead of the detected one.
+func WithClusterDomain(domain string) Option {
+	return WithDomainResolver(network.NewDomainResolver(domain))
+}
+
+// WithExternalNames makes references to Kubernetes Services of type
+// ExternalName resolve to their external name, instead of their cluster
+// local hostname.
+func WithExternalNames() Option {
+	return func(r *URIResolver) {
+		r.externalNames = true
+	}
+}
+
+// WithServicePortName makes references to Kubernetes Services resolve to the
+// port with the given name. The URI uses the https scheme if the port is
+// named "https" or "https-<suffix>". Services without such a port fail to
+// resolve.
+func WithServicePortName(name string) Option {
+	return func(r *URIResolver) {
+		r.servicePortName = name
+	}
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+
+	"knative.dev/pkg/apis"
+	pkgapisduck "knative.dev/pkg/apis/duck"
+)
+
+var serviceGVR = corev1.SchemeGroupVersion.WithResource("services")
+
+// unstructuredInformerFactory implements duck.InformerFactory such that the
+// elements tracked by the informer/lister are *unstructured.Unstructured, as
+// the Services aren't duck types.
+type unstructuredInformerFactory struct {
+	Client       dynamic.Interface
+	ResyncPeriod time.Duration
+	StopChannel  <-chan struct{}
+}
+
+// Check that unstructuredInformerFactory implements duck.InformerFactory.
+var _ pkgapisduck.InformerFactory = (*unstructuredInformerFactory)(nil)
+
+// Get implements duck.InformerFactory.
+func (f *unstructuredInformerFactory) Get(gvr schema.GroupVersionResource) (cache.SharedIndexInformer, cache.GenericLister, error) {
+	ri := f.Client.Resource(gvr)
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return ri.List(opts)
+		},
+		WatchFunc: ri.Watch,
+	}
+	inf := cache.NewSharedIndexInformer(lw, &unstructured.Unstructured{}, f.ResyncPeriod, cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+	})
+	lister := cache.NewGenericLister(inf.GetIndexer(), gvr.GroupResource())
+
+	go inf.Run(f.StopChannel)
+
+	if ok := cache.WaitForCacheSync(f.StopChannel, inf.HasSynced); !ok {
+		return nil, nil, fmt.Errorf("failed starting unstructured informer for %v", gvr)
+	}
+	return inf, lister, nil
+}
+
+// serviceURL resolves a reference to a Kubernetes Service.
+func (r *URIResolver) serviceURL(ref *corev1.ObjectReference) (*apis.URL, error) {
+	url := &apis.URL{
+		Scheme: "http",
+		Host:   r.domainResolver().ServiceHostname(ref.Name, ref.Namespace),
+		Path:   "/",
+	}
+	if !r.externalNames && r.servicePortName == "" {
+		// TODO(spencer-p,n3wscott) Verify that the service actually exists in K8s.
+		return url, nil
+	}
+
+	_, lister, err := r.serviceInformerFactory.Get(serviceGVR)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lister for %+v: %v", serviceGVR, err)
+	}
+	obj, err := lister.ByNamespace(ref.Namespace).Get(ref.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ref %+v: %v", ref, err)
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("%+v is not unstructured: %T", ref, obj)
+	}
+	svc := &corev1.Service{}
+	if err := pkgapisduck.FromUnstructured(u, svc); err != nil {
+		return nil, fmt.Errorf("failed to convert %+v to a Service: %v", ref, err)
+	}
+
+	if r.externalNames && svc.Spec.Type == corev1.ServiceTypeExternalName {
+		url.Host = svc.Spec.ExternalName
+	}
+	if r.servicePortName != "" {
+		port, ok := findPort(svc, r.servicePortName)
+		if !ok {
+			return nil, fmt.Errorf("service %s/%s has no port named %q", ref.Namespace, ref.Name, r.servicePortName)
+		}
+		if isHTTPSPort(port) {
+			url.Scheme = "https"
+		}
+		if !isDefaultPort(url.Scheme, port.Port) {
+			url.Host = net.JoinHostPort(url.Host, strconv.Itoa(int(port.Port)))
+		}
+	}
+	return url, nil
+}
+
+func findPort(svc *corev1.Service, name string) (corev1.ServicePort, bool) {
+	for _, p := range svc.Spec.Ports {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return corev1.ServicePort{}, false
+}
+
+// isHTTPSPort follows the convention of naming ports by their protocol,
+// optionally followed by a suffix, e.g. "https" or "https-admin".
+func isHTTPSPort(port corev1.ServicePort) bool {
+	return port.Name == "https" || strings.HasPrefix(port.Name, "https-")
+}
+
+func isDefaultPort(scheme string, port int32) bool {
+	return (scheme == "http" && port == 80) || (scheme == "https" && port == 443)
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signals
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// drainer sequences the shutdown: the hooks that stop accepting new work run
+// first, then the drain delay passes, giving the removal of the endpoint
+// time to propagate, and only then do the remaining hooks run.
+type drainer struct {
+	hooks *ShutdownHooks
+
+	m     sync.Mutex
+	delay time.Duration
+
+	once    sync.Once
+	drained chan struct{}
+}
+
+func newDrainer(hooks *ShutdownHooks) *drainer {
+	return &drainer{
+		hooks:   hooks,
+		drained: make(chan struct{}),
+	}
+}
+
+// defaultDrainer is used by the signal handler and the PreStopHandler.
+var defaultDrainer = newDrainer(defaultHooks)
+
+// SetDrainDelay configures the time to wait after a termination signal, or a
+// call of the PreStopHandler, before the shutdown continues with the hooks of
+// PriorityDrain and above and the context is cancelled. The hooks with a
+// lower priority, i.e. the ones that stop accepting new work, run before.
+func SetDrainDelay(d time.Duration) {
+	defaultDrainer.setDelay(d)
+}
+
+// PreStopHandler returns a handler to be called by the preStop hook of the
+// container. It starts the shutdown like a termination signal and responds
+// once the drain delay has passed, such that the termination signal sent
+// by the kubelet afterwards doesn't have to wait again.
+func PreStopHandler() http.Handler {
+	return defaultDrainer
+}
+
+func (d *drainer) setDelay(delay time.Duration) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	d.delay = delay
+}
+
+func (d *drainer) getDelay() time.Duration {
+	d.m.Lock()
+	defer d.m.Unlock()
+	return d.delay
+}
+
+// start starts draining, if it hasn't started yet, and returns a channel
+// which is closed once the drain delay has passed.
+func (d *drainer) start() <-chan struct{} {
+	d.once.Do(func() {
+		go func() {
+			defer close(d.drained)
+			if err := d.hooks.run(func(p int) bool { return p < PriorityDrain }); err != nil {
+				log.Print(err)
+			}
+			time.Sleep(d.getDelay())
+		}()
+	})
+	return d.drained
+}
+
+// shutdown drains and then runs the remaining hooks.
+func (d *drainer) shutdown() {
+	<-d.start()
+	if err := d.hooks.run(func(p int) bool { return p >= PriorityDrain }); err != nil {
+		log.Print(err)
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (d *drainer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-d.start():
+		w.WriteHeader(http.StatusOK)
+	case <-r.Context().Done():
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signals
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// recorder records the hooks which ran and when.
+type recorder struct {
+	m    sync.Mutex
+	ran  []string
+	when map[string]time.Time
+}
+
+func (r *recorder) hook(name string) ShutdownHookFunc {
+	return func(context.Context) error {
+		r.m.Lock()
+		defer r.m.Unlock()
+		r.ran = append(r.ran, name)
+		r.when[name] = time.Now()
+		return nil
+	}
+}
+
+func (r *recorder) get() []string {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return append([]string(nil), r.ran...)
+}
+
+func newTestDrainer(delay time.Duration) (*drainer, *recorder) {
+	rec := &recorder{when: map[string]time.Time{}}
+	hooks := &ShutdownHooks{}
+	hooks.Register("stop-accepting", PriorityStopAccepting, 0, rec.hook("stop-accepting"))
+	hooks.Register("drain", PriorityDrain, 0, rec.hook("drain"))
+	hooks.Register("release", PriorityRelease, 0, rec.hook("release"))
+	d := newDrainer(hooks)
+	d.setDelay(delay)
+	return d, rec
+}
+
+func TestDrainerShutdown(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	d, rec := newTestDrainer(delay)
+
+	start := time.Now()
+	d.shutdown()
+
+	want := []string{"stop-accepting", "drain", "release"}
+	if got := rec.get(); !cmp.Equal(got, want) {
+		t.Errorf("Hooks ran in unexpected order (-want, +got): %s", cmp.Diff(want, got))
+	}
+	if got := rec.when["stop-accepting"].Sub(start); got >= delay {
+		t.Errorf("stop-accepting ran after %v, wanted it before the delay of %v", got, delay)
+	}
+	if got := rec.when["drain"].Sub(start); got < delay {
+		t.Errorf("drain ran after %v, wanted it after the delay of %v", got, delay)
+	}
+}
+
+func TestDrainerPreStop(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	d, rec := newTestDrainer(delay)
+
+	start := time.Now()
+	resp := httptest.NewRecorder()
+	d.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got, want := resp.Code, http.StatusOK; got != want {
+		t.Errorf("StatusCode = %d, want %d", got, want)
+	}
+	if got := time.Since(start); got < delay {
+		t.Errorf("PreStop returned after %v, wanted it to wait for the delay of %v", got, delay)
+	}
+	want := []string{"stop-accepting"}
+	if got := rec.get(); !cmp.Equal(got, want) {
+		t.Errorf("Hooks ran unexpectedly (-want, +got): %s", cmp.Diff(want, got))
+	}
+
+	// The termination signal following the preStop hook doesn't wait again.
+	start = time.Now()
+	d.shutdown()
+	if got := time.Since(start); got >= delay {
+		t.Errorf("shutdown() took %v, wanted it not to wait for the delay again", got)
+	}
+	want = []string{"stop-accepting", "drain", "release"}
+	if got := rec.get(); !cmp.Equal(got, want) {
+		t.Errorf("Hooks ran in unexpected order (-want, +got): %s", cmp.Diff(want, got))
+	}
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signals
+
+import (
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"runtime/pprof"
+)
+
+// EnableGoroutineDumps makes SIGQUIT write the stacks of all goroutines to
+// the given writer, instead of dumping them and exiting as Go does by
+// default. This helps debugging hanging processes without restarting them.
+// It is a no-op on platforms without SIGQUIT.
+func EnableGoroutineDumps(w io.Writer) {
+	if len(dumpSignals) == 0 {
+		return
+	}
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, dumpSignals...)
+	go func() {
+		for range c {
+			if err := dumpGoroutines(w); err != nil {
+				log.Printf("Failed to dump goroutines: %v", err)
+			}
+		}
+	}()
+}
+
+func dumpGoroutines(w io.Writer) error {
+	return pprof.Lookup("goroutine").WriteTo(w, 2)
+}
@@ -0,0 +1,33 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signals
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDumpGoroutines(t *testing.T) {
+	var buf bytes.Buffer
+	if err := dumpGoroutines(&buf); err != nil {
+		t.Fatalf("dumpGoroutines() = %v", err)
+	}
+	if !strings.Contains(buf.String(), "TestDumpGoroutines") {
+		t.Errorf("dumpGoroutines() = %q, wanted it to contain the stack of the test", buf.String())
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signals
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The priorities of the usual shutdown phases. Hooks with a lower priority
+// run first.
+const (
+	// PriorityStopAccepting is for hooks that stop accepting new work,
+	// e.g. by failing readiness probes.
+	PriorityStopAccepting = 100
+	// PriorityDrain is for hooks that wait for in-flight work to finish.
+	PriorityDrain = 200
+	// PriorityFlush is for hooks that flush buffered data, e.g. metrics.
+	PriorityFlush = 300
+	// PriorityRelease is for hooks that release held resources, e.g. leases.
+	PriorityRelease = 400
+)
+
+// ShutdownHookFunc is called on shutdown. The context is cancelled once the
+// hook's timeout has passed.
+type ShutdownHookFunc func(ctx context.Context) error
+
+type shutdownHook struct {
+	name     string
+	priority int
+	timeout  time.Duration
+	fn       ShutdownHookFunc
+}
+
+// ShutdownHooks is a registry of hooks to run on shutdown.
+type ShutdownHooks struct {
+	m     sync.Mutex
+	hooks []shutdownHook
+}
+
+// Register adds a hook with the given name, priority and timeout. A zero
+// timeout lets the hook run until it returns.
+func (h *ShutdownHooks) Register(name string, priority int, timeout time.Duration, fn ShutdownHookFunc) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	h.hooks = append(h.hooks, shutdownHook{
+		name:     name,
+		priority: priority,
+		timeout:  timeout,
+		fn:       fn,
+	})
+}
+
+// Run runs the registered hooks one after another, in increasing order of
+// priority and in the order they have been registered for equal priorities.
+// A hook exceeding its timeout is abandoned and the next one is started.
+// The returned error lists the hooks that failed or timed out.
+func (h *ShutdownHooks) Run() error {
+	return h.run(func(int) bool { return true })
+}
+
+// run is like Run for the hooks whose priority matches the filter.
+func (h *ShutdownHooks) run(filter func(priority int) bool) error {
+	h.m.Lock()
+	hooks := make([]shutdownHook, 0, len(h.hooks))
+	for _, hook := range h.hooks {
+		if filter(hook.priority) {
+			hooks = append(hooks, hook)
+		}
+	}
+	h.m.Unlock()
+	sort.SliceStable(hooks, func(i, j int) bool {
+		return hooks[i].priority < hooks[j].priority
+	})
+
+	var errs []string
+	for _, hook := range hooks {
+		if err := hook.run(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", hook.name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("shutdown hooks failed: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (h shutdownHook) run() error {
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if h.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+	}
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- h.fn(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out after %v", h.timeout)
+	}
+}
+
+// defaultHooks are run by the signal handler.
+var defaultHooks = &ShutdownHooks{}
+
+// RegisterShutdownHook adds a hook to run when a termination signal is
+// received, before the channel returned by SetupSignalHandler is closed and
+// hence before the context returned by NewContext is cancelled.
+func RegisterShutdownHook(name string, priority int, timeout time.Duration, fn ShutdownHookFunc) {
+	defaultHooks.Register(name, priority, timeout, fn)
+}
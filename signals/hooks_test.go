@@ -0,0 +1,87 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signals
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestShutdownHooksOrder(t *testing.T) {
+	var got []string
+	record := func(name string) ShutdownHookFunc {
+		return func(context.Context) error {
+			got = append(got, name)
+			return nil
+		}
+	}
+
+	hooks := &ShutdownHooks{}
+	hooks.Register("release", PriorityRelease, 0, record("release"))
+	hooks.Register("drain", PriorityDrain, 0, record("drain"))
+	hooks.Register("flush-metrics", PriorityFlush, 0, record("flush-metrics"))
+	hooks.Register("flush-traces", PriorityFlush, 0, record("flush-traces"))
+	hooks.Register("stop-accepting", PriorityStopAccepting, 0, record("stop-accepting"))
+
+	if err := hooks.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	want := []string{"stop-accepting", "drain", "flush-metrics", "flush-traces", "release"}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Hooks ran in unexpected order (-want, +got): %s", cmp.Diff(want, got))
+	}
+}
+
+func TestShutdownHooksErrors(t *testing.T) {
+	ran := false
+	hooks := &ShutdownHooks{}
+	hooks.Register("failing", 1, 0, func(context.Context) error {
+		return errors.New("boom")
+	})
+	hooks.Register("hanging", 2, 10*time.Millisecond, func(context.Context) error {
+		select {}
+	})
+	hooks.Register("cancelled", 3, 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	hooks.Register("last", 4, time.Second, func(context.Context) error {
+		ran = true
+		return nil
+	})
+
+	err := hooks.Run()
+	if err == nil {
+		t.Fatal("Run() = nil, wanted an error")
+	}
+	for _, want := range []string{"failing: boom", "hanging: timed out", "cancelled:"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Run() = %v, wanted it to contain %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "last") {
+		t.Errorf("Run() = %v, wanted no error for the last hook", err)
+	}
+	if !ran {
+		t.Error("The last hook didn't run after the others failed")
+	}
+}
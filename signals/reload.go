@@ -0,0 +1,102 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signals
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+)
+
+// ReloadFunc is called when a reload is requested, e.g. to re-read file-based
+// configuration, rotate certificates or reopen log sinks.
+type ReloadFunc func() error
+
+type reloadCallback struct {
+	name string
+	fn   ReloadFunc
+}
+
+// ReloadCallbacks is a registry of callbacks to run on reload.
+type ReloadCallbacks struct {
+	m         sync.Mutex
+	callbacks []reloadCallback
+}
+
+// Register adds a callback with the given name.
+func (r *ReloadCallbacks) Register(name string, fn ReloadFunc) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.callbacks = append(r.callbacks, reloadCallback{name: name, fn: fn})
+}
+
+// Reload runs all of the callbacks in the order they have been registered,
+// even if some of them fail. The returned error lists the failed callbacks.
+func (r *ReloadCallbacks) Reload() error {
+	r.m.Lock()
+	callbacks := make([]reloadCallback, len(r.callbacks))
+	copy(callbacks, r.callbacks)
+	r.m.Unlock()
+
+	var errs []string
+	for _, cb := range callbacks {
+		if err := cb.fn(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", cb.name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("reload callbacks failed: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// defaultReloadCallbacks are run by the signal handler.
+var defaultReloadCallbacks = &ReloadCallbacks{}
+
+// RegisterReloadCallback adds a callback to run when a reload signal (SIGHUP)
+// is received. Reload signals are only handled once SetupSignalHandler has
+// been called and are ignored on platforms without them.
+func RegisterReloadCallback(name string, fn ReloadFunc) {
+	defaultReloadCallbacks.Register(name, fn)
+}
+
+// handleReloadSignals runs the reload callbacks on every reload signal until
+// the stop channel is closed. Signals received while the callbacks are
+// running are coalesced into one further reload.
+func handleReloadSignals(stopCh <-chan struct{}) {
+	if len(reloadSignals) == 0 {
+		return
+	}
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, reloadSignals...)
+	go func() {
+		defer signal.Stop(c)
+		for {
+			select {
+			case <-c:
+				if err := defaultReloadCallbacks.Reload(); err != nil {
+					log.Print(err)
+				}
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signals
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReloadCallbacks(t *testing.T) {
+	var got []string
+	callbacks := &ReloadCallbacks{}
+	callbacks.Register("config", func() error {
+		got = append(got, "config")
+		return nil
+	})
+	callbacks.Register("certs", func() error {
+		got = append(got, "certs")
+		return errors.New("no such file")
+	})
+	callbacks.Register("logs", func() error {
+		got = append(got, "logs")
+		return nil
+	})
+
+	err := callbacks.Reload()
+	if err == nil || !strings.Contains(err.Error(), "certs: no such file") {
+		t.Errorf("Reload() = %v, wanted the error of the certs callback", err)
+	}
+	if want := []string{"config", "certs", "logs"}; !cmp.Equal(got, want) {
+		t.Errorf("Callbacks ran unexpectedly (-want, +got): %s", cmp.Diff(want, got))
+	}
+}
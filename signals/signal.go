@@ -21,30 +21,59 @@ import (
 	"errors"
 	"os"
 	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 )
 
-var onlyOneSignalHandler = make(chan struct{})
+var (
+	onlyOneSignalHandler = make(chan struct{})
+
+	// shutdownRequests receives the shutdown signals as well as the
+	// requests made through RequestShutdown.
+	shutdownRequests    = make(chan os.Signal, 2)
+	requestShutdownOnce sync.Once
+)
 
 // SetupSignalHandler registered for SIGTERM and SIGINT. A stop channel is returned
-// which is closed on one of these signals. If a second signal is caught, the program
-// is terminated with exit code 1.
+// which is closed on one of these signals, once the drain delay has passed and the
+// registered shutdown hooks have run. If a second signal is caught, the program is
+// terminated with exit code 1. Until the stop channel is closed, the registered reload
+// callbacks are run on SIGHUP.
 func SetupSignalHandler() (stopCh <-chan struct{}) {
 	close(onlyOneSignalHandler) // panics when called twice
 
 	stop := make(chan struct{})
-	c := make(chan os.Signal, 2)
+	c := shutdownRequests
 	signal.Notify(c, shutdownSignals...)
 	go func() {
 		<-c
+		go func() {
+			<-c
+			os.Exit(1) // second signal. Exit directly.
+		}()
+		defaultDrainer.shutdown()
 		close(stop)
-		<-c
-		os.Exit(1) // second signal. Exit directly.
 	}()
+	handleReloadSignals(stop)
 
 	return stop
 }
 
+// RequestShutdown initiates the shutdown as if a termination signal had been
+// received, e.g. when a Windows service is asked to stop by the service
+// control manager. Only the first call has an effect, further calls don't
+// terminate the program like a second signal does.
+func RequestShutdown() {
+	requestShutdownOnce.Do(func() {
+		select {
+		case shutdownRequests <- syscall.SIGTERM:
+		default:
+			// Signals are already pending.
+		}
+	})
+}
+
 // NewContext creates a new context with SetupSignalHandler()
 // as our Done() channel.
 func NewContext() context.Context {
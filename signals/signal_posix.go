@@ -23,4 +23,8 @@ import (
 	"syscall"
 )
 
-var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+var (
+	shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	reloadSignals   = []os.Signal{syscall.SIGHUP}
+	dumpSignals     = []os.Signal{syscall.SIGQUIT}
+)
@@ -18,6 +18,13 @@ package signals
 
 import (
 	"os"
+	"syscall"
 )
 
-var shutdownSignals = []os.Signal{os.Interrupt}
+var (
+	// Console close, logoff and system shutdown events are delivered as
+	// SIGTERM.
+	shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	reloadSignals   []os.Signal
+	dumpSignals     []os.Signal
+)
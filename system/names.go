@@ -18,17 +18,54 @@ package system
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strings"
+	"sync"
 )
 
 const (
 	NamespaceEnvKey = "SYSTEM_NAMESPACE"
 )
 
+// NamespaceSource describes where the system namespace has been resolved
+// from.
+type NamespaceSource string
+
+const (
+	// SourceOverride means the namespace has been set through
+	// OverrideNamespace.
+	SourceOverride NamespaceSource = "override"
+	// SourceEnv means the namespace has been read from the environment
+	// variable named by NamespaceEnvKey.
+	SourceEnv NamespaceSource = "env"
+	// SourceFile means the namespace has been read from the namespace file
+	// of the pod's service account.
+	SourceFile NamespaceSource = "file"
+	// SourceDefault means the namespace is the one set through
+	// SetDefaultNamespace.
+	SourceDefault NamespaceSource = "default"
+)
+
+var (
+	// namespaceFile is the file Kubernetes mounts into pods holding the
+	// namespace of the pod.
+	namespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+	nsMu sync.RWMutex
+	// override, defaultNS and fileNS are guarded by nsMu. fileNS caches the
+	// contents of the namespace file, which doesn't change over the
+	// lifetime of a pod. fileRead records whether it has been read.
+	override  string
+	defaultNS string
+	fileNS    string
+	fileRead  bool
+)
+
 // Namespace holds the K8s namespace where our serving system
 // components run.
 func Namespace() string {
-	if ns := os.Getenv(NamespaceEnvKey); ns != "" {
+	if ns, src := ResolveNamespace(); src != "" {
 		return ns
 	}
 
@@ -50,3 +87,78 @@ import (
 	_ "knative.dev/pkg/system/testing"
 )`, NamespaceEnvKey, NamespaceEnvKey))
 }
+
+// ResolveNamespace resolves the system namespace and returns where it has
+// been resolved from, trying in turn:
+//  1. the namespace set through OverrideNamespace,
+//  2. the environment variable named by NamespaceEnvKey,
+//  3. the namespace file of the pod's service account,
+//  4. the namespace set through SetDefaultNamespace.
+//
+// The source is empty if none of them yields a namespace.
+func ResolveNamespace() (string, NamespaceSource) {
+	nsMu.RLock()
+	ov, def := override, defaultNS
+	nsMu.RUnlock()
+
+	if ov != "" {
+		return ov, SourceOverride
+	}
+	if ns := os.Getenv(NamespaceEnvKey); ns != "" {
+		return ns, SourceEnv
+	}
+	if ns := readNamespaceFile(); ns != "" {
+		return ns, SourceFile
+	}
+	if def != "" {
+		return def, SourceDefault
+	}
+	return "", ""
+}
+
+// SetDefaultNamespace sets the namespace used if no other source yields
+// one, e.g. for components embedded into other binaries which run outside
+// of a cluster.
+func SetDefaultNamespace(ns string) {
+	nsMu.Lock()
+	defer nsMu.Unlock()
+	defaultNS = ns
+}
+
+// OverrideNamespace makes Namespace return the given namespace regardless
+// of the environment, until the returned function is called, which
+// restores the previous override. It is meant for tests:
+//
+//	defer system.OverrideNamespace("test-system")()
+func OverrideNamespace(ns string) func() {
+	nsMu.Lock()
+	defer nsMu.Unlock()
+	prev := override
+	override = ns
+	return func() {
+		nsMu.Lock()
+		defer nsMu.Unlock()
+		override = prev
+	}
+}
+
+// readNamespaceFile returns the contents of the namespace file, reading it
+// on first use.
+func readNamespaceFile() string {
+	nsMu.RLock()
+	ns, read := fileNS, fileRead
+	nsMu.RUnlock()
+	if read {
+		return ns
+	}
+
+	nsMu.Lock()
+	defer nsMu.Unlock()
+	if !fileRead {
+		if b, err := ioutil.ReadFile(namespaceFile); err == nil {
+			fileNS = strings.TrimSpace(string(b))
+		}
+		fileRead = true
+	}
+	return fileNS
+}
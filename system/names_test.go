@@ -0,0 +1,137 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package system
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// withNamespaceFile points the namespace file to a file holding the given
+// contents, or to a missing file if they are empty.
+func withNamespaceFile(t *testing.T, contents string) func() {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "namespace")
+	if err != nil {
+		t.Fatal("TempDir() =", err)
+	}
+	prev := namespaceFile
+	namespaceFile = filepath.Join(dir, "namespace")
+	if contents != "" {
+		if err := ioutil.WriteFile(namespaceFile, []byte(contents), 0644); err != nil {
+			t.Fatal("WriteFile() =", err)
+		}
+	}
+	resetFile := func() {
+		nsMu.Lock()
+		defer nsMu.Unlock()
+		fileNS, fileRead = "", false
+	}
+	resetFile()
+	return func() {
+		namespaceFile = prev
+		resetFile()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestResolveNamespace(t *testing.T) {
+	tests := []struct {
+		name     string
+		override string
+		env      string
+		file     string
+		def      string
+		wantNS   string
+		wantSrc  NamespaceSource
+	}{{
+		name:     "override wins",
+		override: "overridden",
+		env:      "from-env",
+		file:     "from-file",
+		def:      "default",
+		wantNS:   "overridden",
+		wantSrc:  SourceOverride,
+	}, {
+		name:    "env",
+		env:     "from-env",
+		file:    "from-file",
+		def:     "default",
+		wantNS:  "from-env",
+		wantSrc: SourceEnv,
+	}, {
+		name:    "file",
+		file:    "from-file\n",
+		def:     "default",
+		wantNS:  "from-file",
+		wantSrc: SourceFile,
+	}, {
+		name:    "default",
+		def:     "default",
+		wantNS:  "default",
+		wantSrc: SourceDefault,
+	}, {
+		name: "nothing",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer withNamespaceFile(t, test.file)()
+			defer OverrideNamespace(test.override)()
+			SetDefaultNamespace(test.def)
+			defer SetDefaultNamespace("")
+			defer os.Setenv(NamespaceEnvKey, os.Getenv(NamespaceEnvKey))
+			os.Setenv(NamespaceEnvKey, test.env)
+
+			ns, src := ResolveNamespace()
+			if ns != test.wantNS || src != test.wantSrc {
+				t.Errorf("ResolveNamespace() = %q, %q, want %q, %q", ns, src, test.wantNS, test.wantSrc)
+			}
+		})
+	}
+}
+
+func TestNamespacePanics(t *testing.T) {
+	defer withNamespaceFile(t, "")()
+	defer os.Setenv(NamespaceEnvKey, os.Getenv(NamespaceEnvKey))
+	os.Unsetenv(NamespaceEnvKey)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Namespace() didn't panic")
+		}
+	}()
+	Namespace()
+}
+
+func TestOverrideNamespaceRestores(t *testing.T) {
+	restoreOuter := OverrideNamespace("outer")
+	restoreInner := OverrideNamespace("inner")
+	if got := Namespace(); got != "inner" {
+		t.Errorf("Namespace() = %q, want inner", got)
+	}
+	restoreInner()
+	if got := Namespace(); got != "outer" {
+		t.Errorf("Namespace() = %q, want outer", got)
+	}
+	restoreOuter()
+	if _, src := ResolveNamespace(); src == SourceOverride {
+		t.Error("The override hasn't been restored")
+	}
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package certificates mints throwaway CAs and the server and client
+// certificates they sign, and installs them into secrets, for tests of
+// TLS features.
+package certificates
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DefaultValidity is the validity of certificates without one.
+	DefaultValidity = 24 * time.Hour
+
+	// CACertKey is the key of the CA certificate in secrets created by
+	// InstallSecret, next to corev1.TLSCertKey and corev1.TLSPrivateKeyKey.
+	CACertKey = "ca.crt"
+)
+
+// Options define a certificate.
+type Options struct {
+	CommonName  string
+	DNSNames    []string
+	IPAddresses []net.IP
+
+	// NotBefore defaults to now. Set it in the past, along with a short
+	// Validity, to mint expired certificates.
+	NotBefore time.Time
+	// Validity defaults to DefaultValidity.
+	Validity time.Duration
+}
+
+// KeyPair is a certificate and its private key.
+type KeyPair struct {
+	Cert    *x509.Certificate
+	Key     *ecdsa.PrivateKey
+	CertPEM []byte
+	KeyPEM  []byte
+
+	// opts and usage are kept for rotation.
+	opts  Options
+	usage []x509.ExtKeyUsage
+}
+
+// TLSCertificate returns the key pair for use in a tls.Config.
+func (kp *KeyPair) TLSCertificate() (tls.Certificate, error) {
+	return tls.X509KeyPair(kp.CertPEM, kp.KeyPEM)
+}
+
+// CA is a certificate authority signing certificates.
+type CA struct {
+	KeyPair
+}
+
+// NewCA mints a self-signed CA.
+func NewCA(opts Options) (*CA, error) {
+	tmpl, key, err := template(opts)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.IsCA = true
+	tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+
+	kp, err := sign(tmpl, tmpl, key, key)
+	if err != nil {
+		return nil, err
+	}
+	kp.opts = opts
+	return &CA{KeyPair: *kp}, nil
+}
+
+// CertPool returns a pool trusting the CA.
+func (ca *CA) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+	return pool
+}
+
+// ServerCert mints a server certificate signed by the CA.
+func (ca *CA) ServerCert(opts Options) (*KeyPair, error) {
+	return ca.issue(opts, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth})
+}
+
+// ClientCert mints a client certificate signed by the CA.
+func (ca *CA) ClientCert(opts Options) (*KeyPair, error) {
+	return ca.issue(opts, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth})
+}
+
+// Rotate mints a new certificate with a new key, like the given one issued
+// by this or another CA, valid from now on.
+func (ca *CA) Rotate(kp *KeyPair) (*KeyPair, error) {
+	opts := kp.opts
+	opts.NotBefore = time.Time{}
+	return ca.issue(opts, kp.usage)
+}
+
+func (ca *CA) issue(opts Options, usage []x509.ExtKeyUsage) (*KeyPair, error) {
+	tmpl, key, err := template(opts)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+	tmpl.ExtKeyUsage = usage
+
+	kp, err := sign(tmpl, ca.Cert, key, ca.Key)
+	if err != nil {
+		return nil, err
+	}
+	kp.opts, kp.usage = opts, usage
+	return kp, nil
+}
+
+// template returns the certificate template of the options and a new key.
+func template(opts Options) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate a key: %v", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate a serial number: %v", err)
+	}
+
+	notBefore := opts.NotBefore
+	if notBefore.IsZero() {
+		// Allow for some clock skew.
+		notBefore = time.Now().Add(-time.Minute)
+	}
+	validity := opts.Validity
+	if validity == 0 {
+		validity = DefaultValidity
+	}
+	return &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: opts.CommonName, Organization: []string{"knative.dev"}},
+		DNSNames:              opts.DNSNames,
+		IPAddresses:           opts.IPAddresses,
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(validity),
+		BasicConstraintsValid: true,
+	}, key, nil
+}
+
+// sign creates the certificate of the template, signed by the parent.
+func sign(tmpl, parent *x509.Certificate, key, parentKey *ecdsa.PrivateKey) (*KeyPair, error) {
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign the certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{
+		Cert:    cert,
+		Key:     key,
+		CertPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		KeyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}, nil
+}
+
+// InstallSecret creates or updates a kubernetes.io/tls secret holding the
+// key pair and the certificate of the CA.
+func InstallSecret(kube kubernetes.Interface, namespace, name string, kp *KeyPair, ca *CA) (*corev1.Secret, error) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       kp.CertPEM,
+			corev1.TLSPrivateKeyKey: kp.KeyPEM,
+			CACertKey:               ca.CertPEM,
+		},
+	}
+
+	secrets := kube.CoreV1().Secrets(namespace)
+	created, err := secrets.Create(secret)
+	if !apierrs.IsAlreadyExists(err) {
+		return created, err
+	}
+	existing, err := secrets.Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	existing = existing.DeepCopy()
+	existing.Data = secret.Data
+	return secrets.Update(existing)
+}
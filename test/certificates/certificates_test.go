@@ -0,0 +1,163 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificates
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMutualTLS(t *testing.T) {
+	ca, err := NewCA(Options{CommonName: "test-ca"})
+	if err != nil {
+		t.Fatalf("NewCA() = %v", err)
+	}
+	server, err := ca.ServerCert(Options{
+		CommonName:  "server",
+		DNSNames:    []string{"example.com"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	})
+	if err != nil {
+		t.Fatalf("ServerCert() = %v", err)
+	}
+	client, err := ca.ClientCert(Options{CommonName: "client"})
+	if err != nil {
+		t.Fatalf("ClientCert() = %v", err)
+	}
+
+	serverCert, err := server.TLSCertificate()
+	if err != nil {
+		t.Fatalf("TLSCertificate() = %v", err)
+	}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	ts.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    ca.CertPool(),
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	clientCert, err := client.TLSCertificate()
+	if err != nil {
+		t.Fatalf("TLSCertificate() = %v", err)
+	}
+	c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		Certificates: []tls.Certificate{clientCert},
+		RootCAs:      ca.CertPool(),
+	}}}
+	resp, err := c.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	defer resp.Body.Close()
+	var body bytes.Buffer
+	body.ReadFrom(resp.Body)
+	if got, want := body.String(), "client"; got != want {
+		t.Errorf("Peer = %q, want %q", got, want)
+	}
+}
+
+func TestExpiredAndRotated(t *testing.T) {
+	ca, err := NewCA(Options{CommonName: "test-ca"})
+	if err != nil {
+		t.Fatalf("NewCA() = %v", err)
+	}
+	expired, err := ca.ServerCert(Options{
+		DNSNames:  []string{"example.com"},
+		NotBefore: time.Now().Add(-2 * time.Hour),
+		Validity:  time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("ServerCert() = %v", err)
+	}
+
+	verify := func(kp *KeyPair) error {
+		_, err := kp.Cert.Verify(x509.VerifyOptions{
+			DNSName: "example.com",
+			Roots:   ca.CertPool(),
+		})
+		return err
+	}
+	if err := verify(expired); err == nil {
+		t.Error("Verify() = nil for an expired certificate, wanted an error")
+	}
+
+	rotated, err := ca.Rotate(expired)
+	if err != nil {
+		t.Fatalf("Rotate() = %v", err)
+	}
+	if err := verify(rotated); err != nil {
+		t.Errorf("Verify() = %v for the rotated certificate", err)
+	}
+	if rotated.Cert.SerialNumber.Cmp(expired.Cert.SerialNumber) == 0 {
+		t.Error("Rotated certificate has the same serial number")
+	}
+	if got, want := rotated.Cert.NotAfter.Sub(rotated.Cert.NotBefore), time.Hour; got != want {
+		t.Errorf("Rotated validity = %v, want %v", got, want)
+	}
+}
+
+func TestInstallSecret(t *testing.T) {
+	ca, err := NewCA(Options{CommonName: "test-ca"})
+	if err != nil {
+		t.Fatalf("NewCA() = %v", err)
+	}
+	kp, err := ca.ServerCert(Options{DNSNames: []string{"example.com"}})
+	if err != nil {
+		t.Fatalf("ServerCert() = %v", err)
+	}
+	kube := kubefake.NewSimpleClientset()
+
+	if _, err := InstallSecret(kube, "ns", "tls", kp, ca); err != nil {
+		t.Fatalf("InstallSecret() = %v", err)
+	}
+	// Installing a rotated certificate updates the secret.
+	rotated, err := ca.Rotate(kp)
+	if err != nil {
+		t.Fatalf("Rotate() = %v", err)
+	}
+	if _, err := InstallSecret(kube, "ns", "tls", rotated, ca); err != nil {
+		t.Fatalf("InstallSecret() = %v", err)
+	}
+
+	secret, err := kube.CoreV1().Secrets("ns").Get("tls", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if secret.Type != corev1.SecretTypeTLS {
+		t.Errorf("Type = %v, want %v", secret.Type, corev1.SecretTypeTLS)
+	}
+	if !bytes.Equal(secret.Data[corev1.TLSCertKey], rotated.CertPEM) {
+		t.Error("Secret doesn't hold the rotated certificate")
+	}
+	if !bytes.Equal(secret.Data[CACertKey], ca.CertPEM) {
+		t.Error("Secret doesn't hold the CA certificate")
+	}
+}
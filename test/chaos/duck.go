@@ -0,0 +1,239 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package chaos provides helpers to disrupt the system under test during
+// end-to-end tests, to validate it survives the disruption.
+package chaos
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"knative.dev/pkg/test/logging"
+)
+
+const (
+	// defaultPeriod is the period of a Duck without one.
+	defaultPeriod = 20 * time.Second
+	// recoveryInterval is the interval in which recovery is checked.
+	recoveryInterval = 100 * time.Millisecond
+)
+
+// Disruption is the window from killing the leader pod of a deployment
+// to the deployment's recovery.
+type Disruption struct {
+	Deployment string
+	Pod        string
+	Start      time.Time
+	// End is zero if the deployment didn't recover within the Duck's period.
+	End time.Time
+}
+
+// Duration returns the length of the disruption, or zero if the deployment
+// didn't recover.
+func (d Disruption) Duration() time.Duration {
+	if d.End.IsZero() {
+		return 0
+	}
+	return d.End.Sub(d.Start)
+}
+
+// Duck (the "chaos duck") periodically deletes the leader pods of the given
+// deployments, recording the disruptions this causes.
+//
+// The leader of a deployment is the pod whose name prefixes the holder
+// identity of a Lease in the namespace, as set by client-go's leader
+// election. If no leader can be identified, a random pod of the deployment
+// is deleted. A deployment has recovered once all its replicas are available
+// again and, if the leader was identified, another pod holds the lease.
+type Duck struct {
+	Kube        kubernetes.Interface
+	Namespace   string
+	Deployments []string
+
+	// Period is the time between disruptions of each deployment, which
+	// also bounds the time waited for a deployment to recover. Defaults
+	// to 20 seconds.
+	Period time.Duration
+
+	// Logf, if set, is used to log the disruptions.
+	Logf logging.FormatLogger
+
+	mu          sync.Mutex
+	disruptions []Disruption
+	errs        []error
+}
+
+// Start starts disrupting the deployments in the background. It returns a
+// function which stops the disruptions and returns the recorded ones, along
+// with the errors encountered.
+func (d *Duck) Start() func() ([]Disruption, []error) {
+	period := d.Period
+	if period <= 0 {
+		period = defaultPeriod
+	}
+
+	stopCh := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, name := range d.Deployments {
+		name := name
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait.Until(func() {
+				d.record(d.disrupt(name, period, stopCh))
+			}, period, stopCh)
+		}()
+	}
+
+	return func() ([]Disruption, []error) {
+		close(stopCh)
+		wg.Wait()
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.disruptions, d.errs
+	}
+}
+
+func (d *Duck) record(disruption *Disruption, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err != nil {
+		d.errs = append(d.errs, err)
+	}
+	if disruption != nil {
+		d.disruptions = append(d.disruptions, *disruption)
+	}
+}
+
+func (d *Duck) logf(format string, args ...interface{}) {
+	if d.Logf != nil {
+		d.Logf(format, args...)
+	}
+}
+
+// disrupt deletes the leader pod of the deployment and waits for the
+// deployment to recover, for at most the given timeout.
+func (d *Duck) disrupt(name string, timeout time.Duration, stopCh <-chan struct{}) (*Disruption, error) {
+	pod, leaseName, err := d.leader(name)
+	if err != nil || pod == "" {
+		return nil, err
+	}
+
+	disruption := &Disruption{
+		Deployment: name,
+		Pod:        pod,
+		Start:      time.Now(),
+	}
+	if err := d.Kube.CoreV1().Pods(d.Namespace).Delete(pod, &metav1.DeleteOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to delete pod %s of deployment %s: %v", pod, name, err)
+	}
+	d.logf("Deleted pod %s of deployment %s", pod, name)
+
+	err = wait.PollImmediate(recoveryInterval, timeout, func() (bool, error) {
+		select {
+		case <-stopCh:
+			// Stop waiting, the disruption remains open.
+			return false, wait.ErrWaitTimeout
+		default:
+		}
+		return d.recovered(name, pod, leaseName)
+	})
+	if err == wait.ErrWaitTimeout {
+		d.logf("Deployment %s didn't recover from deleting pod %s", name, pod)
+		return disruption, nil
+	} else if err != nil {
+		return disruption, fmt.Errorf("failed to check the recovery of deployment %s: %v", name, err)
+	}
+	disruption.End = time.Now()
+	d.logf("Deployment %s recovered from deleting pod %s after %v", name, pod, disruption.Duration())
+	return disruption, nil
+}
+
+// leader returns the leader pod of the deployment and the name of the lease
+// it holds, if known.
+func (d *Duck) leader(name string) (pod, lease string, err error) {
+	deployment, err := d.Kube.AppsV1().Deployments(d.Namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get deployment %s: %v", name, err)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid selector of deployment %s: %v", name, err)
+	}
+	pods, err := d.Kube.CoreV1().Pods(d.Namespace).List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to list the pods of deployment %s: %v", name, err)
+	}
+	var candidates []corev1.Pod
+	for _, p := range pods.Items {
+		if p.DeletionTimestamp == nil {
+			candidates = append(candidates, p)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", "", nil
+	}
+
+	leases, err := d.Kube.CoordinationV1().Leases(d.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to list leases: %v", err)
+	}
+	for _, l := range leases.Items {
+		if l.Spec.HolderIdentity == nil {
+			continue
+		}
+		for _, p := range candidates {
+			if strings.HasPrefix(*l.Spec.HolderIdentity, p.Name) {
+				return p.Name, l.Name, nil
+			}
+		}
+	}
+	return candidates[rand.Intn(len(candidates))].Name, "", nil
+}
+
+// recovered returns true if all replicas of the deployment are available
+// and, if known, the lease is held by another pod than the deleted one.
+func (d *Duck) recovered(name, pod, leaseName string) (bool, error) {
+	deployment, err := d.Kube.AppsV1().Deployments(d.Namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	want := int32(1)
+	if deployment.Spec.Replicas != nil {
+		want = *deployment.Spec.Replicas
+	}
+	if deployment.Status.AvailableReplicas < want {
+		return false, nil
+	}
+	if leaseName == "" {
+		return true, nil
+	}
+
+	lease, err := d.Kube.CoordinationV1().Leases(d.Namespace).Get(leaseName, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	holder := lease.Spec.HolderIdentity
+	return holder != nil && *holder != "" && !strings.HasPrefix(*holder, pod), nil
+}
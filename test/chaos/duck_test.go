@@ -0,0 +1,141 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaos
+
+import (
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clientgotesting "k8s.io/client-go/testing"
+	"knative.dev/pkg/ptr"
+)
+
+func pod(name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "knative-serving",
+			Name:      name,
+			Labels:    map[string]string{"app": "controller"},
+		},
+	}
+}
+
+func objects(holder string) []runtime.Object {
+	return []runtime.Object{
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "knative-serving", Name: "controller"},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: ptr.Int32(2),
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "controller"}},
+			},
+			Status: appsv1.DeploymentStatus{AvailableReplicas: 2},
+		},
+		pod("controller-a"),
+		pod("controller-b"),
+		&coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "knative-serving", Name: "controller.reconciler"},
+			Spec:       coordinationv1.LeaseSpec{HolderIdentity: ptr.String(holder)},
+		},
+	}
+}
+
+func TestDuckDeletesLeader(t *testing.T) {
+	kube := kubefake.NewSimpleClientset(objects("controller-b_1234")...)
+	deleted := make(chan string, 10)
+	kube.PrependReactor("delete", "pods", func(action clientgotesting.Action) (bool, runtime.Object, error) {
+		name := action.(clientgotesting.DeleteAction).GetName()
+		deleted <- name
+		// The other pod takes over the lease. Go through the tracker, as
+		// reactors can't call the client.
+		gvr := coordinationv1.SchemeGroupVersion.WithResource("leases")
+		obj, _ := kube.Tracker().Get(gvr, "knative-serving", "controller.reconciler")
+		lease := obj.(*coordinationv1.Lease).DeepCopy()
+		lease.Spec.HolderIdentity = ptr.String("controller-a_5678")
+		kube.Tracker().Update(gvr, lease, "knative-serving")
+		return false, nil, nil
+	})
+
+	duck := &Duck{
+		Kube:        kube,
+		Namespace:   "knative-serving",
+		Deployments: []string{"controller"},
+		Period:      time.Hour,
+		Logf:        t.Logf,
+	}
+	stop := duck.Start()
+
+	select {
+	case name := <-deleted:
+		if name != "controller-b" {
+			t.Errorf("Deleted pod %s, want the leader controller-b", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a pod to be deleted")
+	}
+
+	// Wait for the recovery to be observed.
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		duck.mu.Lock()
+		n := len(duck.disruptions)
+		duck.mu.Unlock()
+		if n > 0 {
+			break
+		}
+	}
+	disruptions, errs := stop()
+	if len(errs) != 0 {
+		t.Errorf("Errors = %v", errs)
+	}
+	if len(disruptions) != 1 {
+		t.Fatalf("Disruptions = %v, want one", disruptions)
+	}
+	if d := disruptions[0]; d.Pod != "controller-b" || d.End.IsZero() {
+		t.Errorf("Disruption = %+v, wanted a recovered disruption of controller-b", d)
+	}
+}
+
+func TestLeaderWithoutLease(t *testing.T) {
+	kube := kubefake.NewSimpleClientset(objects("someone-else")...)
+	duck := &Duck{Kube: kube, Namespace: "knative-serving"}
+
+	pod, lease, err := duck.leader("controller")
+	if err != nil {
+		t.Fatalf("leader() = %v", err)
+	}
+	if pod != "controller-a" && pod != "controller-b" {
+		t.Errorf("leader() = %s, wanted a pod of the deployment", pod)
+	}
+	if lease != "" {
+		t.Errorf("leader() lease = %s, want none", lease)
+	}
+}
+
+func TestDisruptionDuration(t *testing.T) {
+	start := time.Now()
+	if got := (Disruption{Start: start}).Duration(); got != 0 {
+		t.Errorf("Duration() = %v for an open disruption, want 0", got)
+	}
+	if got, want := (Disruption{Start: start, End: start.Add(time.Second)}).Duration(), time.Second; got != want {
+		t.Errorf("Duration() = %v, want %v", got, want)
+	}
+}
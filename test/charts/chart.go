@@ -0,0 +1,182 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package charts
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	width   = 800
+	height  = 400
+	margin  = 60
+	legendW = 160
+	ticks   = 5
+)
+
+// palette holds the colors of the series, in order.
+var palette = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f"}
+
+// Point is a sample of a time-series.
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Series is a named time-series.
+type Series struct {
+	Name   string
+	Points []Point
+}
+
+// Chart is a line chart of time-series sharing a unit. It is safe for
+// concurrent use.
+type Chart struct {
+	// Title is the title of the chart.
+	Title string
+	// Unit is the unit of the values, used to label the y axis.
+	Unit string
+
+	mu     sync.Mutex
+	series []*Series
+}
+
+// Add adds a sample to the named series, creating it if needed.
+func (c *Chart) Add(series string, t time.Time, value float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range c.series {
+		if s.Name == series {
+			s.Points = append(s.Points, Point{Time: t, Value: value})
+			return
+		}
+	}
+	c.series = append(c.series, &Series{Name: series, Points: []Point{{Time: t, Value: value}}})
+}
+
+// Series returns a copy of the chart's series, with their points sorted
+// by time.
+func (c *Chart) Series() []Series {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ret := make([]Series, 0, len(c.series))
+	for _, s := range c.series {
+		points := append([]Point(nil), s.Points...)
+		sort.SliceStable(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+		ret = append(ret, Series{Name: s.Name, Points: points})
+	}
+	return ret
+}
+
+// WriteSVG renders the chart as an SVG image. The x axis shows the time in
+// seconds since the earliest sample.
+func (c *Chart) WriteSVG(w io.Writer) error {
+	series := c.Series()
+	start, end, min, max := bounds(series)
+	span := end.Sub(start).Seconds()
+	if span == 0 {
+		span = 1
+	}
+	if max == min {
+		max, min = max+1, min-1
+	}
+
+	plotW := float64(width - 2*margin - legendW)
+	plotH := float64(height - 2*margin)
+	x := func(t time.Time) float64 {
+		return margin + t.Sub(start).Seconds()/span*plotW
+	}
+	y := func(v float64) float64 {
+		return margin + plotH - (v-min)/(max-min)*plotH
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="12">`+"\n", width, height)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="16" text-anchor="middle">%s</text>`+"\n", width/2, margin/2, html.EscapeString(c.Title))
+
+	// Axes and their ticks.
+	fmt.Fprintf(&b, `<g stroke="black"><line x1="%d" y1="%d" x2="%d" y2="%d"/><line x1="%d" y1="%d" x2="%d" y2="%d"/></g>`+"\n",
+		margin, margin, margin, height-margin, margin, height-margin, width-margin-legendW, height-margin)
+	for i := 0; i <= ticks; i++ {
+		f := float64(i) / ticks
+		tx := margin + f*plotW
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`+"\n", tx, height-margin+16, formatValue(f*span))
+		ty := margin + plotH - f*plotH
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#ddd"/>`+"\n", margin, ty, margin+plotW, ty)
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`+"\n", margin-4, ty+4, formatValue(min+f*(max-min)))
+	}
+	fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">time (s)</text>`+"\n", margin+plotW/2, height-margin/4)
+	if c.Unit != "" {
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle" transform="rotate(-90 %d %d)">%s</text>`+"\n",
+			margin/4, height/2, margin/4, height/2, html.EscapeString(c.Unit))
+	}
+
+	// Series and their legend.
+	for i, s := range series {
+		color := palette[i%len(palette)]
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="1.5" points="`, color)
+		for j, p := range s.Points {
+			if j > 0 {
+				b.WriteByte(' ')
+			}
+			fmt.Fprintf(&b, "%.1f,%.1f", x(p.Time), y(p.Value))
+		}
+		b.WriteString(`"/>` + "\n")
+		ly := margin + 16*i
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="10" height="10" fill="%s"/>`+"\n", width-margin-legendW+16, ly, color)
+		fmt.Fprintf(&b, `<text x="%d" y="%d">%s</text>`+"\n", width-margin-legendW+32, ly+10, html.EscapeString(s.Name))
+	}
+	b.WriteString("</svg>\n")
+
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// bounds returns the time range and the value range of the series.
+func bounds(series []Series) (start, end time.Time, min, max float64) {
+	min, max = math.Inf(1), math.Inf(-1)
+	for _, s := range series {
+		for _, p := range s.Points {
+			if start.IsZero() || p.Time.Before(start) {
+				start = p.Time
+			}
+			if p.Time.After(end) {
+				end = p.Time
+			}
+			min = math.Min(min, p.Value)
+			max = math.Max(max, p.Value)
+		}
+	}
+	if math.IsInf(min, 0) {
+		return start, start, 0, 0
+	}
+	return start, end, min, max
+}
+
+// formatValue formats a tick label.
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', 4, 64)
+}
@@ -0,0 +1,28 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package charts renders the time-series collected by benchmarks into SVG
+// line charts and writes them, along with an HTML index, to the artifacts
+// directory. This gives a visual summary of each performance run right
+// next to its other artifacts:
+//
+//	report := charts.NewReport("dataplane-probe")
+//	latency := report.Chart("Latency", "ms")
+//	...
+//	latency.Add("activator", time.Now(), float64(d.Milliseconds()))
+//	...
+//	dir, err := report.Write()
+package charts
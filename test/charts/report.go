@@ -0,0 +1,144 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package charts
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"knative.dev/pkg/test"
+	"knative.dev/pkg/test/prow"
+)
+
+// invalidFileChars matches the characters not used in chart file names.
+var invalidFileChars = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+{{with .Job}}<p>{{.}}</p>{{end}}
+{{range .Charts}}<h2>{{.Title}}</h2>
+<img src="{{.File}}" alt="{{.Title}}">
+{{end}}</body>
+</html>
+`))
+
+// Report is a set of charts of a benchmark run, written as SVG images along
+// with an HTML index. It is safe for concurrent use.
+type Report struct {
+	// Title is the title of the report, usually the benchmark's name.
+	Title string
+
+	mu     sync.Mutex
+	charts []*Chart
+}
+
+// NewReport returns an empty Report with the given title.
+func NewReport(title string) *Report {
+	return &Report{Title: title}
+}
+
+// Chart returns the chart with the given title, creating it if needed.
+func (r *Report) Chart(title, unit string) *Chart {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.charts {
+		if c.Title == title {
+			return c
+		}
+	}
+	c := &Chart{Title: title, Unit: unit}
+	r.charts = append(r.charts, c)
+	return c
+}
+
+// Write writes the report to the subdirectory of the artifacts directory
+// named after the report, see WriteTo. It returns the directory written to.
+func (r *Report) Write() (string, error) {
+	dir := filepath.Join(test.ArtifactsDir(), "perf", fileName(r.Title))
+	return dir, r.WriteTo(dir)
+}
+
+// WriteTo writes every chart as an SVG image named after its title and an
+// index.html showing all of them to the given directory.
+func (r *Report) WriteTo(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	charts := append([]*Chart(nil), r.charts...)
+	r.mu.Unlock()
+
+	type entry struct {
+		Title string
+		File  string
+	}
+	index := struct {
+		Title  string
+		Job    string
+		Charts []entry
+	}{Title: r.Title}
+	if job := prow.GetJob(); job.IsCI() {
+		index.Job = job.String()
+	}
+
+	used := make(map[string]int, len(charts))
+	for _, c := range charts {
+		name := fileName(c.Title)
+		// Disambiguate titles mapping to the same file name.
+		if n := used[name]; n > 0 {
+			used[name]++
+			name = fmt.Sprintf("%s-%d", name, n)
+		} else {
+			used[name] = 1
+		}
+		file := name + ".svg"
+
+		var b bytes.Buffer
+		if err := c.WriteSVG(&b); err != nil {
+			return fmt.Errorf("failed to render chart %q: %w", c.Title, err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, file), b.Bytes(), 0644); err != nil {
+			return err
+		}
+		index.Charts = append(index.Charts, entry{Title: c.Title, File: file})
+	}
+
+	var b bytes.Buffer
+	if err := indexTemplate.Execute(&b, index); err != nil {
+		return fmt.Errorf("failed to render the index: %w", err)
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "index.html"), b.Bytes(), 0644)
+}
+
+// fileName turns a title into a file name.
+func fileName(title string) string {
+	name := strings.Trim(invalidFileChars.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if name == "" {
+		return "chart"
+	}
+	return name
+}
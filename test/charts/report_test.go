@@ -0,0 +1,125 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package charts
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestChartSeries(t *testing.T) {
+	start := time.Now()
+	c := &Chart{Title: "Latency"}
+	c.Add("a", start.Add(2*time.Second), 3)
+	c.Add("b", start, 1)
+	c.Add("a", start, 2)
+
+	want := []Series{{
+		Name:   "a",
+		Points: []Point{{Time: start, Value: 2}, {Time: start.Add(2 * time.Second), Value: 3}},
+	}, {
+		Name:   "b",
+		Points: []Point{{Time: start, Value: 1}},
+	}}
+	if diff := cmp.Diff(want, c.Series()); diff != "" {
+		t.Errorf("Series (-want, +got) = %s", diff)
+	}
+}
+
+func TestWriteSVG(t *testing.T) {
+	start := time.Now()
+	c := &Chart{Title: "Latency <p99>", Unit: "ms"}
+	for i := 0; i < 10; i++ {
+		c.Add("activator", start.Add(time.Duration(i)*time.Second), float64(i*i))
+	}
+	// A single point and an empty chart must render as well.
+	c.Add("queue-proxy", start, 5)
+
+	for _, chart := range []*Chart{c, {Title: "empty"}} {
+		var b bytes.Buffer
+		if err := chart.WriteSVG(&b); err != nil {
+			t.Fatalf("WriteSVG() = %v", err)
+		}
+		// The result must be well-formed XML.
+		d := xml.NewDecoder(&b)
+		for {
+			if _, err := d.Token(); err != nil {
+				if err != io.EOF {
+					t.Fatalf("Invalid SVG for %q: %v", chart.Title, err)
+				}
+				break
+			}
+		}
+	}
+}
+
+func TestReportWriteTo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "charts")
+	if err != nil {
+		t.Fatal("TempDir() =", err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := NewReport("dataplane-probe")
+	now := time.Now()
+	r.Chart("Latency", "ms").Add("activator", now, 12)
+	r.Chart("Error rate", "%").Add("activator", now, 0)
+	if r.Chart("Latency", "ms") != r.Chart("Latency", "") {
+		t.Error("Chart() returned a new chart for an existing title")
+	}
+	r.Chart("latency!", "ms")
+
+	if err := r.WriteTo(dir); err != nil {
+		t.Fatal("WriteTo() =", err)
+	}
+	for _, f := range []string{"latency.svg", "error-rate.svg", "latency-1.svg", "index.html"} {
+		if _, err := os.Stat(filepath.Join(dir, f)); err != nil {
+			t.Errorf("Stat(%s) = %v", f, err)
+		}
+	}
+	index, err := ioutil.ReadFile(filepath.Join(dir, "index.html"))
+	if err != nil {
+		t.Fatal("ReadFile() =", err)
+	}
+	for _, want := range []string{"<h1>dataplane-probe</h1>", `src="latency.svg"`, `src="error-rate.svg"`, `src="latency-1.svg"`} {
+		if !strings.Contains(string(index), want) {
+			t.Errorf("index.html doesn't contain %q:\n%s", want, index)
+		}
+	}
+}
+
+func TestFileName(t *testing.T) {
+	tests := map[string]string{
+		"Latency":          "latency",
+		"p99 latency (ms)": "p99-latency-ms",
+		"!!!":              "chart",
+	}
+	for title, want := range tests {
+		if got := fileName(title); got != want {
+			t.Errorf("fileName(%q) = %q, want %q", title, got, want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// Deleter is implemented by the typed clients of a resource, like the ones
+// returned by kubernetes.Interface.CoreV1().ConfigMaps(namespace).
+type Deleter interface {
+	Delete(name string, options *metav1.DeleteOptions) error
+}
+
+// TestingT is the subset of testing.TB used by the Cleaner.
+type TestingT interface {
+	Logf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+	Failed() bool
+}
+
+// Cleaner records the resources created by a test and deletes them in
+// reverse order of creation when the test exits. Use it as:
+//
+//	cleaner := test.NewCleaner(t)
+//	defer cleaner.Cleanup()
+//
+// As deferred calls run on panics, too, the resources don't leak when a
+// test panics. The resources are also deleted if the test is interrupted.
+type Cleaner struct {
+	t TestingT
+
+	// LeaveOnFailure leaves the resources in place for inspection if the
+	// test has failed. Defaults to the -leaveonfailure flag.
+	LeaveOnFailure bool
+
+	mu      sync.Mutex
+	entries []cleanupEntry
+}
+
+type cleanupEntry struct {
+	description string
+	delete      func() error
+}
+
+// NewCleaner returns a Cleaner for the given test.
+func NewCleaner(t TestingT) *Cleaner {
+	c := &Cleaner{
+		t:              t,
+		LeaveOnFailure: Flags.LeaveOnFailure,
+	}
+	registerCleaner(c)
+	return c
+}
+
+var (
+	// interruptOnce installs the interrupt handler of the Cleaners once
+	// for the whole process.
+	interruptOnce sync.Once
+
+	cleanersMu sync.Mutex
+	// cleaners are the Cleaners which haven't cleaned up yet, deleted on
+	// interrupt.
+	cleaners = make(map[*Cleaner]struct{})
+)
+
+// registerCleaner registers the Cleaner to be cleaned up if the test is
+// interrupted, until it cleans up.
+func registerCleaner(c *Cleaner) {
+	interruptOnce.Do(func() {
+		CleanupOnInterrupt(cleanupCleaners, log.Printf)
+	})
+	cleanersMu.Lock()
+	defer cleanersMu.Unlock()
+	cleaners[c] = struct{}{}
+}
+
+func unregisterCleaner(c *Cleaner) {
+	cleanersMu.Lock()
+	defer cleanersMu.Unlock()
+	delete(cleaners, c)
+}
+
+// cleanupCleaners cleans up all of the registered Cleaners in turn, before
+// CleanupOnInterrupt exits.
+func cleanupCleaners() {
+	cleanersMu.Lock()
+	pending := make([]*Cleaner, 0, len(cleaners))
+	for c := range cleaners {
+		pending = append(pending, c)
+	}
+	cleanersMu.Unlock()
+
+	for _, c := range pending {
+		c.cleanup(false)
+	}
+}
+
+// Add registers a function deleting the described resource.
+func (c *Cleaner) Add(description string, delete func() error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = append(c.entries, cleanupEntry{
+		description: description,
+		delete:      delete,
+	})
+}
+
+// AddTyped registers the given object to be deleted through the typed
+// client it has been created with.
+func (c *Cleaner) AddTyped(client Deleter, obj metav1.Object) {
+	name := obj.GetName()
+	c.Add(objectDescription(obj), func() error {
+		return client.Delete(name, deleteOptions())
+	})
+}
+
+// AddDynamic registers the given object of the given resource to be deleted
+// through the dynamic client.
+func (c *Cleaner) AddDynamic(client dynamic.Interface, gvr schema.GroupVersionResource, obj metav1.Object) {
+	namespace, name := obj.GetNamespace(), obj.GetName()
+	c.Add(fmt.Sprintf("%s %s", gvr.GroupResource(), objectDescription(obj)), func() error {
+		return client.Resource(gvr).Namespace(namespace).Delete(name, deleteOptions())
+	})
+}
+
+// Cleanup deletes all registered resources in reverse order of their
+// registration, unless the test has failed and LeaveOnFailure is set.
+// Resources which are already gone are ignored, other errors fail the test.
+// Cleanup can be called more than once; each resource is deleted only once.
+func (c *Cleaner) Cleanup() {
+	unregisterCleaner(c)
+	c.cleanup(true)
+}
+
+// cleanup deletes the resources, reporting the errors on the test if
+// reportErrors is set. Otherwise the test may have finished, e.g. when it is
+// interrupted, so it logs through the log package instead.
+func (c *Cleaner) cleanup(reportErrors bool) {
+	c.mu.Lock()
+	entries := c.entries
+	c.entries = nil
+	c.mu.Unlock()
+
+	logf, errorf := c.t.Logf, c.t.Errorf
+	if !reportErrors {
+		logf, errorf = log.Printf, log.Printf
+	}
+
+	if c.LeaveOnFailure && c.t.Failed() {
+		for _, e := range entries {
+			logf("Test failed, leaving %s in place", e.description)
+		}
+		return
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		logf("Cleaning up %s", e.description)
+		if err := e.delete(); err != nil && !apierrs.IsNotFound(err) {
+			errorf("Failed to clean up %s: %v", e.description, err)
+		}
+	}
+}
+
+func objectDescription(obj metav1.Object) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
+
+func deleteOptions() *metav1.DeleteOptions {
+	policy := metav1.DeletePropagationForeground
+	return &metav1.DeleteOptions{PropagationPolicy: &policy}
+}
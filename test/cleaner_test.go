@@ -0,0 +1,186 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeT records the outcome of a Cleanup.
+type fakeT struct {
+	failed bool
+	errors []string
+}
+
+func (t *fakeT) Logf(string, ...interface{}) {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeT) Failed() bool {
+	return t.failed || len(t.errors) > 0
+}
+
+func TestCleanerOrder(t *testing.T) {
+	c := NewCleaner(&fakeT{})
+	var deleted []string
+	for _, name := range []string{"first", "second", "third"} {
+		name := name
+		c.Add(name, func() error {
+			deleted = append(deleted, name)
+			return nil
+		})
+	}
+
+	c.Cleanup()
+	if diff := cmp.Diff([]string{"third", "second", "first"}, deleted); diff != "" {
+		t.Errorf("Deleted (-want, +got) = %s", diff)
+	}
+
+	// A second call doesn't delete anything again.
+	c.Cleanup()
+	if got := len(deleted); got != 3 {
+		t.Errorf("Deleted %d resources, want 3", got)
+	}
+}
+
+func TestCleanerErrors(t *testing.T) {
+	ft := &fakeT{}
+	c := NewCleaner(ft)
+	c.Add("gone", func() error {
+		return apierrs.NewNotFound(schema.GroupResource{Resource: "configmaps"}, "gone")
+	})
+	c.Add("broken", func() error {
+		return errors.New("boom")
+	})
+
+	c.Cleanup()
+	if got := len(ft.errors); got != 1 {
+		t.Errorf("Got %d errors, want 1: %v", got, ft.errors)
+	}
+}
+
+func TestCleanerLeaveOnFailure(t *testing.T) {
+	for _, failed := range []bool{true, false} {
+		t.Run(fmt.Sprint("failed=", failed), func(t *testing.T) {
+			c := NewCleaner(&fakeT{failed: failed})
+			c.LeaveOnFailure = true
+			deleted := false
+			c.Add("resource", func() error {
+				deleted = true
+				return nil
+			})
+
+			c.Cleanup()
+			if deleted == failed {
+				t.Errorf("Deleted = %v, want %v", deleted, !failed)
+			}
+		})
+	}
+}
+
+func TestCleanerPanic(t *testing.T) {
+	c := NewCleaner(&fakeT{})
+	deleted := false
+	c.Add("resource", func() error {
+		deleted = true
+		return nil
+	})
+
+	func() {
+		defer func() { recover() }()
+		defer c.Cleanup()
+		panic("test panicked")
+	}()
+	if !deleted {
+		t.Error("Resource has not been deleted on panic")
+	}
+}
+
+func TestCleanerInterrupt(t *testing.T) {
+	var deleted []string
+	newCleaner := func(name string) *Cleaner {
+		c := NewCleaner(&fakeT{})
+		c.Add(name, func() error {
+			deleted = append(deleted, name)
+			return nil
+		})
+		return c
+	}
+	done, pending := newCleaner("done"), newCleaner("pending")
+	defer pending.Cleanup()
+
+	done.Cleanup()
+	deleted = nil
+	cleanersMu.Lock()
+	_, doneRegistered := cleaners[done]
+	_, pendingRegistered := cleaners[pending]
+	cleanersMu.Unlock()
+	if doneRegistered || !pendingRegistered {
+		t.Errorf("Registered = %v, %v, want only the pending cleaner", doneRegistered, pendingRegistered)
+	}
+
+	// The interrupt handler cleans up the cleaners which haven't yet.
+	cleanupCleaners()
+	if diff := cmp.Diff([]string{"pending"}, deleted); diff != "" {
+		t.Errorf("Deleted (-want, +got) = %s", diff)
+	}
+}
+
+func TestCleanerClients(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "config"},
+	}
+	kube := kubefake.NewSimpleClientset(cm)
+
+	gvr := schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+	widget := &unstructured.Unstructured{}
+	widget.SetAPIVersion("example.com/v1")
+	widget.SetKind("Widget")
+	widget.SetNamespace("ns")
+	widget.SetName("widget")
+	dyn := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), widget)
+
+	ft := &fakeT{}
+	c := NewCleaner(ft)
+	c.AddTyped(kube.CoreV1().ConfigMaps("ns"), cm)
+	c.AddDynamic(dyn, gvr, widget)
+	c.Cleanup()
+
+	if len(ft.errors) != 0 {
+		t.Fatalf("Cleanup() errors = %v", ft.errors)
+	}
+	if _, err := kube.CoreV1().ConfigMaps("ns").Get("config", metav1.GetOptions{}); !apierrs.IsNotFound(err) {
+		t.Errorf("Get(config) = %v, want NotFound", err)
+	}
+	if _, err := dyn.Resource(gvr).Namespace("ns").Get("widget", metav1.GetOptions{}); !apierrs.IsNotFound(err) {
+		t.Errorf("Get(widget) = %v, want NotFound", err)
+	}
+}
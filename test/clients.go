@@ -19,6 +19,7 @@ limitations under the License.
 package test
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
@@ -57,6 +58,38 @@ func NewKubeClient(configPath string, clusterName string) (*KubeClient, error) {
 	return &KubeClient{Kube: k}, nil
 }
 
+// NewKubeClientForContext instantiates and returns the clientsets of the cluster of
+// the given context in the kubeconfig file at configPath.
+func NewKubeClientForContext(configPath string, context string) (*KubeClient, error) {
+	cfg, err := BuildClientConfigForContext(configPath, context)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &KubeClient{Kube: k}, nil
+}
+
+// NewClusterKubeClients returns a KubeClient for each of the clusters of
+// multi-cluster tests, keyed by the contexts given by the --kubeconfigs flag.
+func NewClusterKubeClients() (map[string]*KubeClient, error) {
+	if len(Flags.KubeContexts) == 0 {
+		return nil, errors.New("no clusters given, use the --kubeconfigs flag")
+	}
+	clients := make(map[string]*KubeClient, len(Flags.KubeContexts))
+	for _, context := range Flags.KubeContexts {
+		client, err := NewKubeClientForContext(Flags.Kubeconfig, context)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the client of context %q: %v", context, err)
+		}
+		clients[context] = client
+	}
+	return clients, nil
+}
+
 // BuildClientConfig builds the client config specified by the config path and the cluster name
 func BuildClientConfig(kubeConfigPath string, clusterName string) (*rest.Config, error) {
 	overrides := clientcmd.ConfigOverrides{}
@@ -69,6 +102,14 @@ func BuildClientConfig(kubeConfigPath string, clusterName string) (*rest.Config,
 		&overrides).ClientConfig()
 }
 
+// BuildClientConfigForContext builds the client config of the given context in the
+// kubeconfig file at the config path.
+func BuildClientConfigForContext(kubeConfigPath string, context string) (*rest.Config, error) {
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeConfigPath},
+		&clientcmd.ConfigOverrides{CurrentContext: context}).ClientConfig()
+}
+
 // UpdateConfigMap updates the config map for specified @name with values
 func (client *KubeClient) UpdateConfigMap(name string, configName string, values map[string]string) error {
 	configMap, err := client.GetConfigMap(name).Get(configName, metav1.GetOptions{})
@@ -0,0 +1,93 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const kubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: east
+  cluster:
+    server: https://east.example.com
+- name: west
+  cluster:
+    server: https://west.example.com
+contexts:
+- name: ctx1
+  context:
+    cluster: east
+    user: user
+- name: ctx2
+  context:
+    cluster: west
+    user: user
+current-context: ctx1
+users:
+- name: user
+  user:
+    token: secret
+`
+
+func TestBuildClientConfigForContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(path, []byte(kubeconfig), 0600); err != nil {
+		t.Fatalf("WriteFile() = %v", err)
+	}
+
+	for context, want := range map[string]string{
+		"ctx1": "https://east.example.com",
+		"ctx2": "https://west.example.com",
+	} {
+		cfg, err := BuildClientConfigForContext(path, context)
+		if err != nil {
+			t.Fatalf("BuildClientConfigForContext(%s) = %v", context, err)
+		}
+		if cfg.Host != want {
+			t.Errorf("BuildClientConfigForContext(%s).Host = %s, want %s", context, cfg.Host, want)
+		}
+	}
+
+	if _, err := BuildClientConfigForContext(path, "missing"); err == nil {
+		t.Error("BuildClientConfigForContext(missing) = nil, wanted an error")
+	}
+}
+
+func TestListFlag(t *testing.T) {
+	var l list
+	if err := l.Set("ctx1, ctx2,"); err != nil {
+		t.Fatalf("Set() = %v", err)
+	}
+	if diff := cmp.Diff(list{"ctx1", "ctx2"}, l); diff != "" {
+		t.Errorf("Set() (-want, +got) = %s", diff)
+	}
+	if got, want := l.String(), "ctx1,ctx2"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// context provides a context bound to the deadline of the test binary, so
+// that the tests stop waiting and report what they were waiting for before
+// `go test -timeout` kills them without any diagnostics.
+
+package test
+
+import (
+	"context"
+	"flag"
+	"testing"
+	"time"
+)
+
+var (
+	// started approximates the start of the tests, which the -test.timeout
+	// flag counts from.
+	started = time.Now()
+
+	// deadlineMargin is the time left, at most, between the cancellation of
+	// the context returned by Context and the deadline of the test binary,
+	// to report the failure and clean up.
+	deadlineMargin = 30 * time.Second
+)
+
+// Deadline returns the time the test binary times out, as set by the
+// -test.timeout flag, and false if it has no timeout.
+func Deadline(t testing.TB) (time.Time, bool) {
+	// Go 1.15 and later know the deadline.
+	if d, ok := t.(interface{ Deadline() (time.Time, bool) }); ok {
+		return d.Deadline()
+	}
+	f := flag.Lookup("test.timeout")
+	if f == nil {
+		return time.Time{}, false
+	}
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return time.Time{}, false
+	}
+	timeout, ok := getter.Get().(time.Duration)
+	if !ok || timeout <= 0 {
+		return time.Time{}, false
+	}
+	return started.Add(timeout), true
+}
+
+// Context returns a context for the test, which is cancelled shortly before
+// the test binary times out, leaving a tenth of the remaining time, at most
+// 30 seconds, to report the failure. The context is also cancelled once the
+// test completes, on Go versions supporting cleanups, i.e. Go 1.14 and later.
+// Pass it to the clients and polling helpers, e.g. EventuallyContext, so that
+// a hung test fails with what it was waiting for instead of being killed.
+func Context(t testing.TB) context.Context {
+	ctx, cancel := deadlineContext(t)
+	if c, ok := t.(interface{ Cleanup(func()) }); ok {
+		c.Cleanup(cancel)
+	}
+	return ctx
+}
+
+// deadlineContext returns a context cancelled shortly before the test binary
+// times out, see Context.
+func deadlineContext(t testing.TB) (context.Context, context.CancelFunc) {
+	deadline, ok := Deadline(t)
+	if !ok {
+		return context.WithCancel(context.Background())
+	}
+	margin := time.Until(deadline) / 10
+	if margin > deadlineMargin {
+		margin = deadlineMargin
+	}
+	return context.WithDeadline(context.Background(), deadline.Add(-margin))
+}
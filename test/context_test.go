@@ -0,0 +1,97 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"context"
+	"flag"
+	"testing"
+	"time"
+)
+
+// withTimeout sets the -test.timeout flag, returning a function restoring it.
+func withTimeout(t *testing.T, timeout string) func() {
+	t.Helper()
+	f := flag.Lookup("test.timeout")
+	old := f.Value.String()
+	if err := f.Value.Set(timeout); err != nil {
+		t.Fatalf("Set(%q) = %v", timeout, err)
+	}
+	return func() {
+		f.Value.Set(old)
+	}
+}
+
+// withoutDeadline hides the Deadline method of testing.T, as on Go versions
+// before 1.15, so that the -test.timeout flag is read.
+type withoutDeadline struct {
+	testing.TB
+}
+
+func TestDeadline(t *testing.T) {
+	defer withTimeout(t, "1h")()
+	deadline, ok := Deadline(withoutDeadline{t})
+	if !ok {
+		t.Fatal("Deadline() = false, wanted a deadline")
+	}
+	if want := started.Add(time.Hour); !deadline.Equal(want) {
+		t.Errorf("Deadline() = %v, want: %v", deadline, want)
+	}
+
+	defer withTimeout(t, "0")()
+	if deadline, ok := Deadline(withoutDeadline{t}); ok {
+		t.Errorf("Deadline() = %v, wanted none", deadline)
+	}
+}
+
+func TestContext(t *testing.T) {
+	defer withTimeout(t, "1h")()
+	ctx := Context(withoutDeadline{t})
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		t.Fatal("Deadline() = false, wanted a deadline")
+	}
+	// The margin is at most 30 seconds.
+	if want := started.Add(time.Hour - deadlineMargin); deadline.Before(want) {
+		t.Errorf("Deadline() = %v, wanted at least %v", deadline, want)
+	}
+	if want := started.Add(time.Hour); !deadline.Before(want) {
+		t.Errorf("Deadline() = %v, wanted before %v", deadline, want)
+	}
+
+	defer withTimeout(t, "0")()
+	ctx = Context(withoutDeadline{t})
+	if deadline, ok := ctx.Deadline(); ok {
+		t.Errorf("Deadline() = %v, wanted none", deadline)
+	}
+}
+
+func TestContextCancelledOnCleanup(t *testing.T) {
+	if _, ok := interface{}(t).(interface{ Cleanup(func()) }); !ok {
+		t.Skip("Cleanups are supported by Go 1.14 and later")
+	}
+	var ctx context.Context
+	t.Run("subtest", func(t *testing.T) {
+		ctx = Context(t)
+		if err := ctx.Err(); err != nil {
+			t.Errorf("Err() = %v, wanted the context to be live", err)
+		}
+	})
+	if err := ctx.Err(); err != context.Canceled {
+		t.Errorf("Err() = %v, want: %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// This file contains helpers to dump the state of the cluster when a test
+// fails, to help triaging failures in CI.
+
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"knative.dev/pkg/test/prow"
+)
+
+// ArtifactsDir returns the directory test artifacts are written to, which
+// is the Prow job's artifacts directory, or ./artifacts for local runs.
+func ArtifactsDir() string {
+	return prow.GetJob().ArtifactsDir
+}
+
+// DumpOptions define what DumpClusterState dumps.
+type DumpOptions struct {
+	// Namespace is the test namespace whose pods, events and resources are
+	// dumped.
+	Namespace string
+
+	// Dynamic is used to dump the Resources. Required if Resources is set.
+	Dynamic dynamic.Interface
+	// Resources are the resources in Namespace to dump, like Knative
+	// Services.
+	Resources []schema.GroupVersionResource
+
+	// ControllerNamespace and ControllerSelector select the pods whose
+	// logs are dumped. No logs are dumped if ControllerSelector is empty.
+	ControllerNamespace string
+	ControllerSelector  string
+
+	// Dir is the directory to write the dump to. Defaults to a
+	// subdirectory of ArtifactsDir named after the namespace.
+	Dir string
+}
+
+// DumpOnFailure dumps the cluster state through DumpClusterState if the
+// test has failed. Use it as:
+//
+//	defer test.DumpOnFailure(t, kubeClient.Kube, opts)
+//
+// Errors while dumping are logged rather than failing the test.
+func DumpOnFailure(t TestingT, kube kubernetes.Interface, opts DumpOptions) {
+	if !t.Failed() {
+		return
+	}
+	dir, err := DumpClusterState(kube, opts)
+	if err != nil {
+		t.Logf("Failed to dump the cluster state: %v", err)
+	}
+	t.Logf("Dumped the cluster state to %s", dir)
+}
+
+// DumpClusterState writes the pods, events and resources of the test
+// namespace as YAML along with a human-readable summary.txt, and the logs
+// of the controller pods, to the dump directory, which it returns. It dumps
+// as much as it can, returning the aggregated errors.
+func DumpClusterState(kube kubernetes.Interface, opts DumpOptions) (string, error) {
+	dir := opts.Dir
+	if dir == "" {
+		dir = filepath.Join(ArtifactsDir(), "cluster-state", opts.Namespace)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return dir, err
+	}
+
+	var (
+		errs    []error
+		summary bytes.Buffer
+	)
+
+	pods, err := kube.CoreV1().Pods(opts.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to list pods: %v", err))
+	} else {
+		errs = appendErr(errs, writeYAML(filepath.Join(dir, "pods.yaml"), pods))
+		summarizePods(&summary, pods.Items)
+	}
+
+	events, err := kube.CoreV1().Events(opts.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to list events: %v", err))
+	} else {
+		errs = appendErr(errs, writeYAML(filepath.Join(dir, "events.yaml"), events))
+		summarizeEvents(&summary, events.Items)
+	}
+
+	for _, gvr := range opts.Resources {
+		list, err := opts.Dynamic.Resource(gvr).Namespace(opts.Namespace).List(metav1.ListOptions{})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to list %s: %v", gvr.GroupResource(), err))
+			continue
+		}
+		errs = appendErr(errs, writeYAML(filepath.Join(dir, gvr.GroupResource().String()+".yaml"), list))
+		summarizeResources(&summary, gvr.GroupResource(), list.Items)
+	}
+
+	if opts.ControllerSelector != "" {
+		errs = appendErr(errs, dumpLogs(kube, opts.ControllerNamespace, opts.ControllerSelector, filepath.Join(dir, "logs")))
+	}
+
+	errs = appendErr(errs, ioutil.WriteFile(filepath.Join(dir, "summary.txt"), summary.Bytes(), 0644))
+	return dir, errors.NewAggregate(errs)
+}
+
+// dumpLogs writes the logs of all containers of the selected pods to dir.
+func dumpLogs(kube kubernetes.Interface, namespace, selector, dir string) error {
+	pods, err := kube.CoreV1().Pods(namespace).List(metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return fmt.Errorf("failed to list controller pods: %v", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, pod := range pods.Items {
+		for _, c := range pod.Spec.Containers {
+			logs, err := kube.CoreV1().Pods(namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+				Container: c.Name,
+			}).Do().Raw()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to get logs of %s/%s: %v", pod.Name, c.Name, err))
+				continue
+			}
+			errs = appendErr(errs, ioutil.WriteFile(filepath.Join(dir, pod.Name+"_"+c.Name+".log"), logs, 0644))
+		}
+	}
+	return errors.NewAggregate(errs)
+}
+
+func writeYAML(path string, obj interface{}) error {
+	b, err := yaml.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %v", filepath.Base(path), err)
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+func appendErr(errs []error, err error) []error {
+	if err != nil {
+		return append(errs, err)
+	}
+	return errs
+}
+
+func summarizePods(buf *bytes.Buffer, pods []corev1.Pod) {
+	fmt.Fprintf(buf, "Pods:\n")
+	w := tabwriter.NewWriter(buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPHASE\tREADY\tRESTARTS\tMESSAGE")
+	for _, pod := range pods {
+		ready, restarts := 0, int32(0)
+		var messages []string
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.Ready {
+				ready++
+			}
+			restarts += cs.RestartCount
+			if cs.State.Waiting != nil && cs.State.Waiting.Reason != "" {
+				messages = append(messages, cs.Name+": "+cs.State.Waiting.Reason)
+			}
+			if cs.State.Terminated != nil && cs.State.Terminated.Reason != "" {
+				messages = append(messages, cs.Name+": "+cs.State.Terminated.Reason)
+			}
+		}
+		if pod.Status.Message != "" {
+			messages = append(messages, pod.Status.Message)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d/%d\t%d\t%s\n", pod.Name, pod.Status.Phase,
+			ready, len(pod.Spec.Containers), restarts, strings.Join(messages, "; "))
+	}
+	w.Flush()
+	fmt.Fprintln(buf)
+}
+
+func summarizeEvents(buf *bytes.Buffer, events []corev1.Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].LastTimestamp.Before(&events[j].LastTimestamp)
+	})
+	fmt.Fprintf(buf, "Events:\n")
+	w := tabwriter.NewWriter(buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "LAST SEEN\tTYPE\tREASON\tOBJECT\tMESSAGE")
+	for _, e := range events {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s/%s\t%s\n", e.LastTimestamp.UTC().Format("15:04:05"), e.Type,
+			e.Reason, strings.ToLower(e.InvolvedObject.Kind), e.InvolvedObject.Name, e.Message)
+	}
+	w.Flush()
+	fmt.Fprintln(buf)
+}
+
+func summarizeResources(buf *bytes.Buffer, gr schema.GroupResource, items []unstructured.Unstructured) {
+	fmt.Fprintf(buf, "%s:\n", gr)
+	w := tabwriter.NewWriter(buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tREADY\tREASON\tMESSAGE")
+	for _, item := range items {
+		ready, reason, message := "Unknown", "", ""
+		conditions, _, _ := unstructured.NestedSlice(item.Object, "status", "conditions")
+		for _, c := range conditions {
+			cond, ok := c.(map[string]interface{})
+			if !ok || cond["type"] != "Ready" {
+				continue
+			}
+			ready, _ = cond["status"].(string)
+			reason, _ = cond["reason"].(string)
+			message, _ = cond["message"].(string)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", item.GetName(), ready, reason, message)
+	}
+	w.Flush()
+	fmt.Fprintln(buf)
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDumpClusterState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dump")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	kube := kubefake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "crashing"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "user-container"}},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:         "user-container",
+				RestartCount: 3,
+				State: corev1.ContainerState{
+					Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"},
+				},
+			}},
+		},
+	}, &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Namespace: "ns", Name: "crashing.1"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "crashing"},
+		Type:           corev1.EventTypeWarning,
+		Reason:         "BackOff",
+		Message:        "Back-off restarting failed container",
+	})
+
+	gvr := schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+	widget := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"metadata": map[string]interface{}{
+			"namespace": "ns",
+			"name":      "widget",
+		},
+		"status": map[string]interface{}{
+			"conditions": []interface{}{map[string]interface{}{
+				"type":    "Ready",
+				"status":  "False",
+				"reason":  "RevisionFailed",
+				"message": "the revision failed",
+			}},
+		},
+	}}
+	dyn := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), widget)
+
+	got, err := DumpClusterState(kube, DumpOptions{
+		Namespace: "ns",
+		Dynamic:   dyn,
+		Resources: []schema.GroupVersionResource{gvr},
+		Dir:       dir,
+	})
+	if err != nil {
+		t.Fatalf("DumpClusterState() = %v", err)
+	}
+	if got != dir {
+		t.Errorf("DumpClusterState() = %q, want %q", got, dir)
+	}
+
+	for _, f := range []string{"pods.yaml", "events.yaml", "widgets.example.com.yaml"} {
+		if _, err := os.Stat(filepath.Join(dir, f)); err != nil {
+			t.Errorf("Stat(%s) = %v", f, err)
+		}
+	}
+
+	summary, err := ioutil.ReadFile(filepath.Join(dir, "summary.txt"))
+	if err != nil {
+		t.Fatalf("ReadFile(summary.txt) = %v", err)
+	}
+	for _, want := range []string{"CrashLoopBackOff", "Back-off restarting failed container", "RevisionFailed"} {
+		if !strings.Contains(string(summary), want) {
+			t.Errorf("Summary doesn't contain %q:\n%s", want, summary)
+		}
+	}
+}
+
+func TestDumpOnFailureSkipsPassingTests(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dump")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	DumpOnFailure(&fakeT{}, kubefake.NewSimpleClientset(), DumpOptions{
+		Namespace: "ns",
+		Dir:       filepath.Join(dir, "dump"),
+	})
+	if _, err := os.Stat(filepath.Join(dir, "dump")); !os.IsNotExist(err) {
+		t.Errorf("Stat() = %v, wanted the dump to not exist", err)
+	}
+}
@@ -20,11 +20,13 @@ limitations under the License.
 package test
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"os/user"
 	"path"
+	"strings"
 )
 
 // Flags holds the command line flags or defaults for settings in the user's environment.
@@ -35,17 +37,25 @@ var Flags = initializeFlags()
 type EnvironmentFlags struct {
 	Cluster         string // K8s cluster (defaults to cluster in kubeconfig)
 	Kubeconfig      string // Path to kubeconfig (defaults to ./kube/config)
+	KubeContexts    list   // Kubeconfig contexts of the clusters of multi-cluster tests
 	Namespace       string // K8s namespace (blank by default, to be overwritten by test suite)
 	IngressEndpoint string // Host to use for ingress endpoint
 	LogVerbose      bool   // Enable verbose logging
 	EmitMetrics     bool   // Emit metrics
 	DockerRepo      string // Docker repo (defaults to $KO_DOCKER_REPO)
 	Tag             string // Tag for test images
+	LeaveOnFailure  bool   // Leave the resources of failed tests in place
 }
 
 func initializeFlags() *EnvironmentFlags {
 	var f EnvironmentFlags
-	flag.StringVar(&f.Cluster, "cluster", "",
+	RegisterFlags(commonFlagGroup, f.register, f.validate)
+	return &f
+}
+
+// register defines the flags on the given flag.FlagSet.
+func (f *EnvironmentFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.Cluster, "cluster", "",
 		"Provide the cluster to test against. Defaults to the current cluster in kubeconfig.")
 
 	var defaultKubeconfig string
@@ -53,27 +63,58 @@ func initializeFlags() *EnvironmentFlags {
 		defaultKubeconfig = path.Join(usr.HomeDir, ".kube/config")
 	}
 
-	flag.StringVar(&f.Kubeconfig, "kubeconfig", defaultKubeconfig,
+	fs.StringVar(&f.Kubeconfig, "kubeconfig", defaultKubeconfig,
 		"Provide the path to the `kubeconfig` file you'd like to use for these tests. The `current-context` will be used.")
 
-	flag.StringVar(&f.Namespace, "namespace", "",
+	fs.Var(&f.KubeContexts, "kubeconfigs",
+		"Provide the comma-separated contexts in the `kubeconfig` file of the clusters to run multi-cluster tests against.")
+
+	fs.StringVar(&f.Namespace, "namespace", "",
 		"Provide the namespace you would like to use for these tests.")
 
-	flag.StringVar(&f.IngressEndpoint, "ingressendpoint", "", "Provide a static endpoint url to the ingress server used during tests.")
+	fs.StringVar(&f.IngressEndpoint, "ingressendpoint", "", "Provide a static endpoint url to the ingress server used during tests.")
 
-	flag.BoolVar(&f.LogVerbose, "logverbose", false,
+	fs.BoolVar(&f.LogVerbose, "logverbose", false,
 		"Set this flag to true if you would like to see verbose logging.")
 
-	flag.BoolVar(&f.EmitMetrics, "emitmetrics", false,
+	fs.BoolVar(&f.EmitMetrics, "emitmetrics", false,
 		"Set this flag to true if you would like tests to emit metrics, e.g. latency of resources being realized in the system.")
 
 	defaultRepo := os.Getenv("KO_DOCKER_REPO")
-	flag.StringVar(&f.DockerRepo, "dockerrepo", defaultRepo,
+	fs.StringVar(&f.DockerRepo, "dockerrepo", defaultRepo,
 		"Provide the uri of the docker repo you have uploaded the test image to using `uploadtestimage.sh`. Defaults to $KO_DOCKER_REPO")
 
-	flag.StringVar(&f.Tag, "tag", "latest", "Provide the version tag for the test images.")
+	fs.StringVar(&f.Tag, "tag", "latest", "Provide the version tag for the test images.")
 
-	return &f
+	fs.BoolVar(&f.LeaveOnFailure, "leaveonfailure", false,
+		"Set this flag to true if you would like to keep the resources of failed tests for inspection.")
+}
+
+// validate checks that the parsed flags are consistent.
+func (f *EnvironmentFlags) validate() error {
+	if f.Tag == "" {
+		return errors.New("--tag must not be empty")
+	}
+	return nil
+}
+
+// list is a flag.Value holding a comma-separated list.
+type list []string
+
+// String implements flag.Value.
+func (l *list) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set implements flag.Value.
+func (l *list) Set(s string) error {
+	*l = nil
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			*l = append(*l, e)
+		}
+	}
+	return nil
 }
 
 // ImagePath is a helper function to prefix image name with repo and suffix with tag
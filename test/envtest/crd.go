@@ -0,0 +1,145 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1beta1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	"knative.dev/pkg/controller"
+)
+
+// ReadCRDs reads the CRDs of the YAML or JSON files at the given paths, or of
+// the files of the directories at the given paths, e.g. the config directory
+// of a repository. The documents of other kinds are skipped.
+func ReadCRDs(paths ...string) ([]*apiextensionsv1beta1.CustomResourceDefinition, error) {
+	var crds []*apiextensionsv1beta1.CustomResourceDefinition
+	for _, path := range paths {
+		files, err := filesAt(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			read, err := readCRDs(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the CRDs of %s: %v", file, err)
+			}
+			crds = append(crds, read...)
+		}
+	}
+	return crds, nil
+}
+
+// filesAt returns the path if it's a file, or else the YAML and JSON files of
+// the directory, not recursively, in the order of their names, as applied by
+// ko.
+func filesAt(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml", "*.json"} {
+		matches, err := filepath.Glob(filepath.Join(path, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func readCRDs(file string) ([]*apiextensionsv1beta1.CustomResourceDefinition, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var crds []*apiextensionsv1beta1.CustomResourceDefinition
+	decoder := yaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		var obj map[string]interface{}
+		if err := decoder.Decode(&obj); err == io.EOF {
+			return crds, nil
+		} else if err != nil {
+			return nil, err
+		}
+		// Empty documents are decoded as nil.
+		if obj == nil || obj["kind"] != "CustomResourceDefinition" ||
+			!strings.HasPrefix(fmt.Sprint(obj["apiVersion"]), apiextensionsv1beta1.GroupName+"/") {
+			continue
+		}
+		crd := &apiextensionsv1beta1.CustomResourceDefinition{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj, crd); err != nil {
+			return nil, err
+		}
+		crds = append(crds, crd)
+	}
+}
+
+// InstallCRDs creates the CRDs, or updates them if they exist, and waits
+// until they're established, so that the informers of their resources can be
+// started.
+func (e *Environment) InstallCRDs(ctx context.Context, crds ...*apiextensionsv1beta1.CustomResourceDefinition) error {
+	client, err := apiextensionsclient.NewForConfig(e.Config)
+	if err != nil {
+		return err
+	}
+	return installCRDs(ctx, client, e.startTimeout(), crds...)
+}
+
+func installCRDs(ctx context.Context, client apiextensionsclient.CustomResourceDefinitionsGetter, timeout time.Duration, crds ...*apiextensionsv1beta1.CustomResourceDefinition) error {
+	names := make([]string, 0, len(crds))
+	for _, crd := range crds {
+		names = append(names, crd.Name)
+		_, err := client.CustomResourceDefinitions().Create(crd)
+		if !apierrs.IsAlreadyExists(err) {
+			if err != nil {
+				return fmt.Errorf("failed to create the CRD %q: %v", crd.Name, err)
+			}
+			continue
+		}
+		existing, err := client.CustomResourceDefinitions().Get(crd.Name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get the CRD %q: %v", crd.Name, err)
+		}
+		existing = existing.DeepCopy()
+		existing.Spec = crd.Spec
+		if _, err := client.CustomResourceDefinitions().Update(existing); err != nil {
+			return fmt.Errorf("failed to update the CRD %q: %v", crd.Name, err)
+		}
+	}
+	return controller.WaitForCRDs(ctx, client, timeout, names...)
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	fakeapiextensions "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+func TestReadCRDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		paths   []string
+		want    []string
+		wantErr bool
+	}{{
+		name:  "directory",
+		paths: []string{"testdata/config"},
+		want:  []string{"resources.pkg.knative.dev", "otherresources.pkg.knative.dev"},
+	}, {
+		name:  "file",
+		paths: []string{"testdata/config/300-resources.yaml"},
+		want:  []string{"resources.pkg.knative.dev"},
+	}, {
+		name:    "missing",
+		paths:   []string{"testdata/missing"},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			crds, err := ReadCRDs(test.paths...)
+			if test.wantErr {
+				if err == nil {
+					t.Error("ReadCRDs() = nil, wanted an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadCRDs() = %v", err)
+			}
+			var got []string
+			for _, crd := range crds {
+				got = append(got, crd.Name)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("ReadCRDs (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestReadCRDsSpec(t *testing.T) {
+	crds, err := ReadCRDs("testdata/config/300-resources.yaml")
+	if err != nil {
+		t.Fatalf("ReadCRDs() = %v", err)
+	}
+	want := apiextensionsv1beta1.CustomResourceDefinitionSpec{
+		Group:   "pkg.knative.dev",
+		Version: "v1alpha1",
+		Names: apiextensionsv1beta1.CustomResourceDefinitionNames{
+			Kind:     "Resource",
+			Plural:   "resources",
+			Singular: "resource",
+		},
+		Scope: apiextensionsv1beta1.NamespaceScoped,
+	}
+	if diff := cmp.Diff(want, crds[0].Spec); diff != "" {
+		t.Errorf("Spec (-want, +got): %s", diff)
+	}
+}
+
+func TestInstallCRDs(t *testing.T) {
+	crds, err := ReadCRDs("testdata/config")
+	if err != nil {
+		t.Fatalf("ReadCRDs() = %v", err)
+	}
+	// The fake client doesn't establish the CRDs, so they exist already,
+	// established, with an outdated spec.
+	var existing []*apiextensionsv1beta1.CustomResourceDefinition
+	for _, crd := range crds {
+		crd := crd.DeepCopy()
+		crd.Spec.Version = "v1"
+		crd.Status.Conditions = []apiextensionsv1beta1.CustomResourceDefinitionCondition{{
+			Type:   apiextensionsv1beta1.Established,
+			Status: apiextensionsv1beta1.ConditionTrue,
+		}}
+		existing = append(existing, crd)
+	}
+	client := fakeapiextensions.NewSimpleClientset(existing[0], existing[1]).ApiextensionsV1beta1()
+
+	ctx := logtesting.TestContextWithLogger(t)
+	if err := installCRDs(ctx, client, time.Second, crds...); err != nil {
+		t.Fatalf("installCRDs() = %v", err)
+	}
+	for _, crd := range crds {
+		got, err := client.CustomResourceDefinitions().Get(crd.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Get() = %v", err)
+		}
+		if got.Spec.Version != "v1alpha1" {
+			t.Errorf("The CRD %q has version %q, wanted it updated", crd.Name, got.Spec.Version)
+		}
+	}
+}
+
+func TestInstallCRDsNotEstablished(t *testing.T) {
+	crds, err := ReadCRDs("testdata/config/300-resources.yaml")
+	if err != nil {
+		t.Fatalf("ReadCRDs() = %v", err)
+	}
+	client := fakeapiextensions.NewSimpleClientset().ApiextensionsV1beta1()
+
+	ctx := logtesting.TestContextWithLogger(t)
+	if err := installCRDs(ctx, client, 10*time.Millisecond, crds...); err == nil {
+		t.Error("installCRDs() = nil, wanted a timeout")
+	}
+	if _, err := client.CustomResourceDefinitions().Get(crds[0].Name, metav1.GetOptions{}); err != nil {
+		t.Errorf("Get() = %v, wanted the CRD created", err)
+	}
+}
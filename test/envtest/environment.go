@@ -0,0 +1,310 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package envtest runs a local control plane, i.e. an etcd and a
+// kube-apiserver started from their binaries, and the informers, reconcilers
+// and webhooks built with this repository against it, so that they can be
+// tested together without a cluster.
+package envtest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// AssetsEnv is the environment variable of the directory of the etcd and
+	// kube-apiserver binaries, the same as controller-runtime's envtest's.
+	AssetsEnv = "KUBEBUILDER_ASSETS"
+	// EtcdEnv is the environment variable of the path of the etcd binary,
+	// overriding AssetsEnv.
+	EtcdEnv = "TEST_ASSET_ETCD"
+	// APIServerEnv is the environment variable of the path of the
+	// kube-apiserver binary, overriding AssetsEnv.
+	APIServerEnv = "TEST_ASSET_KUBE_APISERVER"
+
+	// DefaultAssetsDir is where the binaries are looked for by default.
+	DefaultAssetsDir = "/usr/local/kubebuilder/bin"
+
+	defaultStartTimeout = time.Minute
+	defaultStopTimeout  = 20 * time.Second
+	healthPollInterval  = 100 * time.Millisecond
+)
+
+// ErrNoBinaries is returned by Start when the etcd or kube-apiserver binary
+// can't be found, so that the tests can be skipped where they aren't
+// installed.
+var ErrNoBinaries = errors.New("the envtest binaries aren't installed")
+
+// Environment is a local control plane. The zero value looks for the
+// binaries in the directory of AssetsEnv, or else DefaultAssetsDir.
+type Environment struct {
+	// EtcdPath and APIServerPath are the paths of the binaries, overriding
+	// the environment variables.
+	EtcdPath      string
+	APIServerPath string
+
+	// APIServerFlags are appended to the flags the kube-apiserver is started
+	// with, e.g. to enable feature gates.
+	APIServerFlags []string
+
+	// StartTimeout bounds how long Start waits for the processes to be
+	// healthy, and StopTimeout how long Stop waits for them to exit before
+	// killing them. They default to 1m and 20s.
+	StartTimeout time.Duration
+	StopTimeout  time.Duration
+
+	// Output receives the output of the processes, e.g. os.Stderr to debug
+	// them. It's discarded if nil.
+	Output io.Writer
+
+	// Config is the config of the clients of the kube-apiserver, set by Start.
+	Config *rest.Config
+
+	dir       string
+	etcd      *process
+	apiServer *process
+}
+
+// Start starts etcd and the kube-apiserver on free local ports, and returns
+// the config of the clients of the kube-apiserver once it's healthy. It
+// returns ErrNoBinaries if the binaries can't be found.
+func (e *Environment) Start() (*rest.Config, error) {
+	etcdPath, apiServerPath, err := e.binaries()
+	if err != nil {
+		return nil, err
+	}
+	if e.dir, err = ioutil.TempDir("", "envtest"); err != nil {
+		return nil, fmt.Errorf("failed to create the data directory: %v", err)
+	}
+	ports, err := freePorts(4)
+	if err != nil {
+		e.Stop()
+		return nil, err
+	}
+	etcdURL := fmt.Sprintf("http://127.0.0.1:%d", ports[0])
+	host := fmt.Sprintf("http://127.0.0.1:%d", ports[2])
+
+	timeout := e.startTimeout()
+	e.etcd, err = startProcess("etcd", etcdPath, etcdFlags(e.dir, etcdURL, ports[1]), e.Output)
+	if err == nil {
+		err = e.etcd.waitHealthy(etcdURL+"/health", timeout)
+	}
+	if err != nil {
+		e.Stop()
+		return nil, err
+	}
+	flags := append(apiServerFlags(e.dir, etcdURL, ports[2], ports[3]), e.APIServerFlags...)
+	e.apiServer, err = startProcess("kube-apiserver", apiServerPath, flags, e.Output)
+	if err == nil {
+		err = e.apiServer.waitHealthy(host+"/healthz", timeout)
+	}
+	if err != nil {
+		e.Stop()
+		return nil, err
+	}
+
+	e.Config = &rest.Config{
+		Host: host,
+		// The tests create many resources at once.
+		QPS:   1000,
+		Burst: 2000,
+	}
+	return e.Config, nil
+}
+
+// Stop stops the kube-apiserver, then etcd, and removes their data.
+func (e *Environment) Stop() error {
+	timeout := e.StopTimeout
+	if timeout == 0 {
+		timeout = defaultStopTimeout
+	}
+	var errs []error
+	for _, p := range []*process{e.apiServer, e.etcd} {
+		if p == nil {
+			continue
+		}
+		if err := p.stop(timeout); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	e.apiServer, e.etcd = nil, nil
+	if e.dir != "" {
+		if err := os.RemoveAll(e.dir); err != nil {
+			errs = append(errs, err)
+		}
+		e.dir = ""
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to stop the environment: %v", errs)
+	}
+	return nil
+}
+
+// binaries returns the paths of the etcd and kube-apiserver binaries.
+func (e *Environment) binaries() (string, string, error) {
+	dir := os.Getenv(AssetsEnv)
+	if dir == "" {
+		dir = DefaultAssetsDir
+	}
+	etcd := firstNonEmpty(e.EtcdPath, os.Getenv(EtcdEnv), filepath.Join(dir, "etcd"))
+	apiServer := firstNonEmpty(e.APIServerPath, os.Getenv(APIServerEnv), filepath.Join(dir, "kube-apiserver"))
+	for _, path := range []string{etcd, apiServer} {
+		if _, err := os.Stat(path); err != nil {
+			return "", "", fmt.Errorf("%w: %v", ErrNoBinaries, err)
+		}
+	}
+	return etcd, apiServer, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// etcdFlags returns the flags of an etcd storing its data in the given
+// directory and serving on the given URL.
+func etcdFlags(dir, url string, peerPort int) []string {
+	peerURL := fmt.Sprintf("http://127.0.0.1:%d", peerPort)
+	return []string{
+		"--data-dir=" + filepath.Join(dir, "etcd"),
+		"--listen-client-urls=" + url,
+		"--advertise-client-urls=" + url,
+		"--listen-peer-urls=" + peerURL,
+		"--initial-advertise-peer-urls=" + peerURL,
+		"--initial-cluster=default=" + peerURL,
+	}
+}
+
+// apiServerFlags returns the flags of a kube-apiserver backed by the given
+// etcd, serving insecurely on the given port, which the clients use. The
+// admission webhooks are enabled, and the service accounts aren't, since no
+// controller manager creates their tokens.
+func apiServerFlags(dir, etcdURL string, port, securePort int) []string {
+	return []string{
+		"--etcd-servers=" + etcdURL,
+		"--cert-dir=" + filepath.Join(dir, "apiserver"),
+		"--advertise-address=127.0.0.1",
+		"--insecure-bind-address=127.0.0.1",
+		"--insecure-port=" + strconv.Itoa(port),
+		"--bind-address=127.0.0.1",
+		"--secure-port=" + strconv.Itoa(securePort),
+		"--service-cluster-ip-range=10.0.0.0/24",
+		"--allow-privileged=true",
+		"--enable-admission-plugins=MutatingAdmissionWebhook,ValidatingAdmissionWebhook",
+		"--disable-admission-plugins=ServiceAccount",
+	}
+}
+
+// freePorts returns n free local ports. They're free when returned, but may
+// be taken by another process before they're listened on.
+func freePorts(n int) ([]int, error) {
+	ports := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, fmt.Errorf("failed to find a free port: %v", err)
+		}
+		// Keep them open until all are picked, so that they differ.
+		defer l.Close()
+		ports = append(ports, l.Addr().(*net.TCPAddr).Port)
+	}
+	return ports, nil
+}
+
+// process is a process of the control plane.
+type process struct {
+	name string
+	cmd  *exec.Cmd
+	// done is closed once the process exited, with its error in err.
+	done chan struct{}
+	err  error
+}
+
+func startProcess(name, path string, args []string, out io.Writer) (*process, error) {
+	cmd := exec.Command(path, args...)
+	cmd.Stdout, cmd.Stderr = out, out
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %v", name, err)
+	}
+	p := &process{name: name, cmd: cmd, done: make(chan struct{})}
+	go func() {
+		p.err = cmd.Wait()
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// waitHealthy polls the health endpoint of the process until it returns
+// 200, the process exits or the timeout expires.
+func (p *process) waitHealthy(url string, timeout time.Duration) error {
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(healthPollInterval)
+	defer ticker.Stop()
+	for {
+		if resp, err := http.Get(url); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+		select {
+		case <-p.done:
+			return fmt.Errorf("%s exited before being healthy: %v", p.name, p.err)
+		case <-deadline:
+			return fmt.Errorf("%s isn't healthy after %v", p.name, timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// stop terminates the process, and kills it if it doesn't exit in time.
+func (p *process) stop(timeout time.Duration) error {
+	select {
+	case <-p.done:
+		return nil
+	default:
+	}
+	if err := p.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		return fmt.Errorf("failed to stop %s: %v", p.name, err)
+	}
+	select {
+	case <-p.done:
+		return nil
+	case <-time.After(timeout):
+	}
+	if err := p.cmd.Process.Kill(); err != nil {
+		return fmt.Errorf("failed to kill %s: %v", p.name, err)
+	}
+	<-p.done
+	return nil
+}
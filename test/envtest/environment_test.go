@@ -0,0 +1,177 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envtest
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBinaries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envtest-assets")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"etcd", "kube-apiserver", "other-etcd"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0755); err != nil {
+			t.Fatalf("WriteFile() = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		env           Environment
+		assets        string
+		etcd          string
+		wantEtcd      string
+		wantAPIServer string
+		wantErr       bool
+	}{{
+		name:          "assets directory",
+		assets:        dir,
+		wantEtcd:      filepath.Join(dir, "etcd"),
+		wantAPIServer: filepath.Join(dir, "kube-apiserver"),
+	}, {
+		name:          "etcd from its variable",
+		assets:        dir,
+		etcd:          filepath.Join(dir, "other-etcd"),
+		wantEtcd:      filepath.Join(dir, "other-etcd"),
+		wantAPIServer: filepath.Join(dir, "kube-apiserver"),
+	}, {
+		name: "explicit paths",
+		env: Environment{
+			EtcdPath:      filepath.Join(dir, "other-etcd"),
+			APIServerPath: filepath.Join(dir, "kube-apiserver"),
+		},
+		assets:        filepath.Join(dir, "missing"),
+		etcd:          filepath.Join(dir, "etcd"),
+		wantEtcd:      filepath.Join(dir, "other-etcd"),
+		wantAPIServer: filepath.Join(dir, "kube-apiserver"),
+	}, {
+		name:    "missing",
+		assets:  filepath.Join(dir, "missing"),
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer setenv(t, AssetsEnv, test.assets)()
+			defer setenv(t, EtcdEnv, test.etcd)()
+			defer setenv(t, APIServerEnv, "")()
+
+			etcd, apiServer, err := test.env.binaries()
+			if test.wantErr {
+				if !errors.Is(err, ErrNoBinaries) {
+					t.Errorf("binaries() = %v, wanted ErrNoBinaries", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("binaries() = %v", err)
+			}
+			if etcd != test.wantEtcd {
+				t.Errorf("etcd = %q, want %q", etcd, test.wantEtcd)
+			}
+			if apiServer != test.wantAPIServer {
+				t.Errorf("kube-apiserver = %q, want %q", apiServer, test.wantAPIServer)
+			}
+		})
+	}
+}
+
+// setenv sets the environment variable, or unsets it if the value is empty,
+// and returns the function restoring it.
+func setenv(t *testing.T, key, value string) func() {
+	t.Helper()
+	old, had := os.LookupEnv(key)
+	if value == "" {
+		os.Unsetenv(key)
+	} else {
+		os.Setenv(key, value)
+	}
+	return func() {
+		if had {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestFlags(t *testing.T) {
+	wantEtcd := []string{
+		"--data-dir=/tmp/envtest/etcd",
+		"--listen-client-urls=http://127.0.0.1:2379",
+		"--advertise-client-urls=http://127.0.0.1:2379",
+		"--listen-peer-urls=http://127.0.0.1:2380",
+		"--initial-advertise-peer-urls=http://127.0.0.1:2380",
+		"--initial-cluster=default=http://127.0.0.1:2380",
+	}
+	if diff := cmp.Diff(wantEtcd, etcdFlags("/tmp/envtest", "http://127.0.0.1:2379", 2380)); diff != "" {
+		t.Errorf("etcdFlags (-want, +got): %s", diff)
+	}
+
+	wantAPIServer := []string{
+		"--etcd-servers=http://127.0.0.1:2379",
+		"--cert-dir=/tmp/envtest/apiserver",
+		"--advertise-address=127.0.0.1",
+		"--insecure-bind-address=127.0.0.1",
+		"--insecure-port=8080",
+		"--bind-address=127.0.0.1",
+		"--secure-port=6443",
+		"--service-cluster-ip-range=10.0.0.0/24",
+		"--allow-privileged=true",
+		"--enable-admission-plugins=MutatingAdmissionWebhook,ValidatingAdmissionWebhook",
+		"--disable-admission-plugins=ServiceAccount",
+	}
+	if diff := cmp.Diff(wantAPIServer, apiServerFlags("/tmp/envtest", "http://127.0.0.1:2379", 8080, 6443)); diff != "" {
+		t.Errorf("apiServerFlags (-want, +got): %s", diff)
+	}
+}
+
+func TestFreePorts(t *testing.T) {
+	ports, err := freePorts(3)
+	if err != nil {
+		t.Fatalf("freePorts() = %v", err)
+	}
+	seen := make(map[int]bool)
+	for _, port := range ports {
+		if port == 0 || seen[port] {
+			t.Errorf("freePorts() = %v, wanted distinct ports", ports)
+		}
+		seen[port] = true
+	}
+}
+
+func TestStartWithoutBinaries(t *testing.T) {
+	env := &Environment{
+		EtcdPath:      "/does/not/exist/etcd",
+		APIServerPath: "/does/not/exist/kube-apiserver",
+	}
+	if _, err := env.Start(); !errors.Is(err, ErrNoBinaries) {
+		t.Errorf("Start() = %v, wanted ErrNoBinaries", err)
+	}
+	if err := env.Stop(); err != nil {
+		t.Errorf("Stop() = %v", err)
+	}
+}
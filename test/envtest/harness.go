@@ -0,0 +1,144 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envtest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	kubeclient "knative.dev/pkg/client/injection/kube/client"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/injection"
+	"knative.dev/pkg/injection/sharedmain"
+	"knative.dev/pkg/logging"
+)
+
+// readyPollInterval is the interval at which the components are checked for
+// being ready.
+var readyPollInterval = 100 * time.Millisecond
+
+// Run runs the controllers and the components, e.g. the webhooks, against
+// the environment, the way sharedmain.MainWithConfig does in a cluster: the
+// informers the controllers and the components retrieve are started and
+// synced, the config maps they watch are read from the given namespace, and
+// Run returns once the components implementing sharedmain.ReadinessChecker are
+// ready. The config maps which aren't optional must exist.
+//
+// It returns the context of the injection, to get the clients and the
+// informers, e.g. kubeclient.Get(ctx), and a function waiting for the
+// controllers and the components to stop once the given context is done,
+// which returns the first error of the components.
+func (e *Environment) Run(ctx context.Context, namespace string, ctors []injection.ControllerConstructor, components ...sharedmain.ComponentConstructor) (context.Context, func() error, error) {
+	logger := logging.FromContext(ctx)
+	ctx, informers := injection.Default.SetupInformers(injection.WithLazyInformers(ctx), e.Config)
+
+	cmw := configmap.NewInformedWatcher(kubeclient.Get(ctx), namespace)
+	controllers := make([]*controller.Impl, 0, len(ctors))
+	for _, cf := range ctors {
+		controllers = append(controllers, cf(ctx, cmw))
+	}
+	comps := make([]sharedmain.Component, 0, len(components))
+	for _, cf := range components {
+		comps = append(comps, cf(ctx, cmw))
+	}
+	if err := cmw.Start(ctx.Done()); err != nil {
+		return nil, nil, fmt.Errorf("failed to start the config map watcher: %v", err)
+	}
+
+	logger.Info("Starting the informers.")
+	waitInformers, err := controller.RunInformers(ctx.Done(), informers...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start the informers: %v", err)
+	}
+
+	errCh := make(chan error, len(comps))
+	var wg sync.WaitGroup
+	for _, c := range comps {
+		c := c
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Run(ctx.Done()); err != nil {
+				errCh <- err
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		controller.StartAll(ctx.Done(), controllers...)
+	}()
+	waitAll := func() error {
+		wg.Wait()
+		waitInformers()
+		select {
+		case err := <-errCh:
+			return err
+		default:
+			return nil
+		}
+	}
+
+	if err := waitForReadiness(ctx, errCh, e.startTimeout(), comps...); err != nil {
+		return nil, nil, err
+	}
+	logger.Info("The controllers and components are running.")
+	return ctx, waitAll, nil
+}
+
+func (e *Environment) startTimeout() time.Duration {
+	if e.StartTimeout == 0 {
+		return defaultStartTimeout
+	}
+	return e.StartTimeout
+}
+
+// waitForReadiness waits until all of the components implementing
+// sharedmain.ReadinessChecker are ready. It fails if one of the components
+// returns an error, which is put back on errCh, or after the timeout.
+func waitForReadiness(ctx context.Context, errCh chan error, timeout time.Duration, comps ...sharedmain.Component) error {
+	var notReady error
+	err := wait.PollImmediate(readyPollInterval, timeout, func() (bool, error) {
+		select {
+		case err := <-errCh:
+			errCh <- err
+			return false, fmt.Errorf("a component failed to start: %v", err)
+		case <-ctx.Done():
+			return false, ctx.Err()
+		default:
+		}
+		notReady = nil
+		for i, c := range comps {
+			if rc, ok := c.(sharedmain.ReadinessChecker); ok {
+				if err := rc.Ready(); err != nil {
+					notReady = fmt.Errorf("%T[%d] isn't ready: %v", c, i, err)
+					return false, nil
+				}
+			}
+		}
+		return true, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("timed out waiting for the components: %v", notReady)
+	}
+	return err
+}
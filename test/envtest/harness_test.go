@@ -0,0 +1,190 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envtest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kubeclient "knative.dev/pkg/client/injection/kube/client"
+	configmapinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/configmap"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/injection"
+	"knative.dev/pkg/injection/sharedmain"
+	"knative.dev/pkg/logging"
+	logtesting "knative.dev/pkg/logging/testing"
+	"knative.dev/pkg/webhook"
+)
+
+// fakeComponent is a component which is ready once ready is closed, or fails
+// with err.
+type fakeComponent struct {
+	ready chan struct{}
+	err   error
+}
+
+func (c *fakeComponent) Run(stop <-chan struct{}) error {
+	if c.err != nil {
+		return c.err
+	}
+	<-stop
+	return nil
+}
+
+func (c *fakeComponent) Ready() error {
+	select {
+	case <-c.ready:
+		return nil
+	default:
+		return errors.New("not ready")
+	}
+}
+
+func TestWaitForReadiness(t *testing.T) {
+	defer func(interval time.Duration) { readyPollInterval = interval }(readyPollInterval)
+	readyPollInterval = time.Millisecond
+	ctx := context.Background()
+
+	ready := &fakeComponent{ready: make(chan struct{})}
+	close(ready.ready)
+	errCh := make(chan error, 1)
+	if err := waitForReadiness(ctx, errCh, time.Second, ready, &fakeComponent{}); err == nil {
+		t.Error("waitForReadiness() = nil, wanted a timeout")
+	} else if !strings.Contains(err.Error(), "[1] isn't ready: not ready") {
+		t.Errorf("waitForReadiness() = %v, wanted the component which isn't ready", err)
+	}
+
+	later := &fakeComponent{ready: make(chan struct{})}
+	time.AfterFunc(10*time.Millisecond, func() { close(later.ready) })
+	if err := waitForReadiness(ctx, errCh, time.Second, ready, later); err != nil {
+		t.Errorf("waitForReadiness() = %v", err)
+	}
+
+	errCh <- errors.New("boom")
+	if err := waitForReadiness(ctx, errCh, time.Second, ready); err == nil {
+		t.Error("waitForReadiness() = nil, wanted the error of the component")
+	}
+	// The error is kept for the caller waiting for the components.
+	if err := <-errCh; err.Error() != "boom" {
+		t.Errorf("<-errCh = %v, want boom", err)
+	}
+}
+
+// recordingReconciler sends the keys it reconciles, unless its buffer is
+// full, so that the controller can be drained.
+type recordingReconciler chan string
+
+func (r recordingReconciler) Reconcile(ctx context.Context, key string) error {
+	select {
+	case r <- key:
+	default:
+	}
+	return nil
+}
+
+func TestEnvironment(t *testing.T) {
+	env := &Environment{}
+	if _, err := env.Start(); errors.Is(err, ErrNoBinaries) {
+		t.Skipf("Skipping the integration test: %v", err)
+	} else if err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+	defer func() {
+		if err := env.Stop(); err != nil {
+			t.Errorf("Stop() = %v", err)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(logtesting.TestContextWithLogger(t))
+	defer cancel()
+
+	crds, err := ReadCRDs("testdata/config")
+	if err != nil {
+		t.Fatalf("ReadCRDs() = %v", err)
+	}
+	if err := env.InstallCRDs(ctx, crds...); err != nil {
+		t.Fatalf("InstallCRDs() = %v", err)
+	}
+
+	opts, err := WebhookOptions("webhook", "knative-testing")
+	if err != nil {
+		t.Fatalf("WebhookOptions() = %v", err)
+	}
+	opts.ConfigValidationControllerPath = "/config-validation"
+	if err := env.PrepareWebhook(opts); err != nil {
+		t.Fatalf("PrepareWebhook() = %v", err)
+	}
+
+	reconciled := make(recordingReconciler, 100)
+	ctrl := func(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
+		impl := controller.NewImpl(reconciled, logging.FromContext(ctx), "ConfigMaps")
+		configmapinformer.Get(ctx).Informer().AddEventHandler(controller.HandleAll(impl.Enqueue))
+		return impl
+	}
+	wh := func(ctx context.Context, cmw configmap.Watcher) sharedmain.Component {
+		acs := map[string]webhook.AdmissionController{
+			opts.ConfigValidationControllerPath: webhook.NewConfigValidationController(configmap.Constructors{}, opts),
+		}
+		w, err := webhook.New(kubeclient.Get(ctx), opts, acs, logging.FromContext(ctx), nil)
+		if err != nil {
+			t.Fatalf("webhook.New() = %v", err)
+		}
+		return w
+	}
+	ctx, wait, err := env.Run(ctx, opts.Namespace, []injection.ControllerConstructor{ctrl}, wh)
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	defer func() {
+		cancel()
+		if err := wait(); err != nil {
+			t.Errorf("wait() = %v", err)
+		}
+	}()
+	if err := env.RouteWebhooks(opts); err != nil {
+		t.Fatalf("RouteWebhooks() = %v", err)
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "config-test",
+			Namespace: opts.Namespace,
+		},
+	}
+	if _, err := kubeclient.Get(ctx).CoreV1().ConfigMaps(cm.Namespace).Create(cm); err != nil {
+		t.Fatalf("Create() = %v", err)
+	}
+	want := cm.Namespace + "/" + cm.Name
+	timeout := time.After(30 * time.Second)
+	for {
+		select {
+		case key := <-reconciled:
+			if key == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for %s to be reconciled", want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envtest
+
+import (
+	"fmt"
+
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"knative.dev/pkg/webhook"
+)
+
+// webhookHost is the address the API server calls the webhooks at.
+const webhookHost = "127.0.0.1"
+
+// WebhookOptions returns the options of a webhook run in the environment,
+// named after the given name: it's served on a free local port, with a
+// certificate valid for the address the API server calls it at, and
+// registered right away. The other options, e.g. the paths, are left to the
+// caller.
+func WebhookOptions(name, namespace string) (webhook.ControllerOptions, error) {
+	ports, err := freePorts(1)
+	if err != nil {
+		return webhook.ControllerOptions{}, err
+	}
+	return webhook.ControllerOptions{
+		ResourceMutatingWebhookName: name + ".webhook.knative.dev",
+		ConfigValidationWebhookName: "config." + name + ".webhook.knative.dev",
+		ServiceName:                 name,
+		DeploymentName:              name,
+		SecretName:                  name + "-certs",
+		Namespace:                   namespace,
+		Port:                        ports[0],
+		CertOptions: webhook.CertOptions{
+			Hosts: []string{webhookHost},
+		},
+	}, nil
+}
+
+// PrepareWebhook creates the namespace of the webhook, and the deployment its
+// registrations are owned by. No pod runs it, since the webhook is run by the
+// test.
+func (e *Environment) PrepareWebhook(opts webhook.ControllerOptions) error {
+	client, err := kubernetes.NewForConfig(e.Config)
+	if err != nil {
+		return err
+	}
+	return prepareWebhook(client, opts)
+}
+
+func prepareWebhook(client kubernetes.Interface, opts webhook.ControllerOptions) error {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: opts.Namespace}}
+	if _, err := client.CoreV1().Namespaces().Create(ns); err != nil && !apierrs.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create the namespace %q: %v", opts.Namespace, err)
+	}
+
+	labels := map[string]string{"app": opts.DeploymentName}
+	d := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      opts.DeploymentName,
+			Namespace: opts.Namespace,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "webhook",
+						Image: "webhook",
+					}},
+				},
+			},
+		},
+	}
+	if _, err := client.AppsV1().Deployments(opts.Namespace).Create(d); err != nil && !apierrs.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create the deployment %q: %v", opts.DeploymentName, err)
+	}
+	return nil
+}
+
+// RouteWebhooks points the registrations of the webhook at the port it's
+// served on locally instead of at its service, which the API server can't
+// reach without the network of a cluster. It must be called once the
+// webhook is ready, i.e. registered, and again if it registers itself
+// anew, e.g. after rotating its certificates.
+func (e *Environment) RouteWebhooks(opts webhook.ControllerOptions) error {
+	client, err := kubernetes.NewForConfig(e.Config)
+	if err != nil {
+		return err
+	}
+	return routeWebhooks(client, opts)
+}
+
+func routeWebhooks(client kubernetes.Interface, opts webhook.ControllerOptions) error {
+	routed := 0
+	if opts.ResourceMutatingWebhookName != "" {
+		mwhc, err := client.AdmissionregistrationV1beta1().MutatingWebhookConfigurations().Get(opts.ResourceMutatingWebhookName, metav1.GetOptions{})
+		switch {
+		case apierrs.IsNotFound(err):
+		case err != nil:
+			return fmt.Errorf("failed to get the mutating webhook configuration: %v", err)
+		default:
+			mwhc = mwhc.DeepCopy()
+			for i := range mwhc.Webhooks {
+				routeClientConfig(&mwhc.Webhooks[i].ClientConfig, opts)
+			}
+			if _, err := client.AdmissionregistrationV1beta1().MutatingWebhookConfigurations().Update(mwhc); err != nil {
+				return fmt.Errorf("failed to update the mutating webhook configuration: %v", err)
+			}
+			routed++
+		}
+	}
+	if opts.ConfigValidationWebhookName != "" {
+		vwhc, err := client.AdmissionregistrationV1beta1().ValidatingWebhookConfigurations().Get(opts.ConfigValidationWebhookName, metav1.GetOptions{})
+		switch {
+		case apierrs.IsNotFound(err):
+		case err != nil:
+			return fmt.Errorf("failed to get the validating webhook configuration: %v", err)
+		default:
+			vwhc = vwhc.DeepCopy()
+			for i := range vwhc.Webhooks {
+				routeClientConfig(&vwhc.Webhooks[i].ClientConfig, opts)
+			}
+			if _, err := client.AdmissionregistrationV1beta1().ValidatingWebhookConfigurations().Update(vwhc); err != nil {
+				return fmt.Errorf("failed to update the validating webhook configuration: %v", err)
+			}
+			routed++
+		}
+	}
+	if routed == 0 {
+		return fmt.Errorf("the webhook %q isn't registered", opts.ServiceName)
+	}
+	return nil
+}
+
+// routeClientConfig replaces the service of the webhook by the URL of its
+// local port, keeping its path.
+func routeClientConfig(cc *admissionregistrationv1beta1.WebhookClientConfig, opts webhook.ControllerOptions) {
+	svc := cc.Service
+	if svc == nil || svc.Namespace != opts.Namespace || svc.Name != opts.ServiceName {
+		return
+	}
+	path := ""
+	if svc.Path != nil {
+		path = *svc.Path
+	}
+	url := fmt.Sprintf("https://%s:%d%s", webhookHost, opts.Port, path)
+	cc.URL = &url
+	cc.Service = nil
+}
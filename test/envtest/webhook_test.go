@@ -0,0 +1,143 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekubeclientset "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWebhookOptions(t *testing.T) {
+	opts, err := WebhookOptions("webhook", "knative-testing")
+	if err != nil {
+		t.Fatalf("WebhookOptions() = %v", err)
+	}
+	if opts.Port == 0 {
+		t.Error("Port = 0, wanted a free port")
+	}
+	if diff := cmp.Diff([]string{"127.0.0.1"}, opts.CertOptions.Hosts); diff != "" {
+		t.Errorf("Hosts (-want, +got): %s", diff)
+	}
+	if err := opts.CertOptions.Validate(); err != nil {
+		t.Errorf("CertOptions.Validate() = %v", err)
+	}
+	if opts.RegistrationDelay != 0 {
+		t.Errorf("RegistrationDelay = %v, wanted the webhook registered right away", opts.RegistrationDelay)
+	}
+}
+
+func TestPrepareWebhook(t *testing.T) {
+	opts, err := WebhookOptions("webhook", "knative-testing")
+	if err != nil {
+		t.Fatalf("WebhookOptions() = %v", err)
+	}
+	client := fakekubeclientset.NewSimpleClientset()
+
+	// Preparing twice, e.g. for several tests, is fine.
+	for i := 0; i < 2; i++ {
+		if err := prepareWebhook(client, opts); err != nil {
+			t.Fatalf("prepareWebhook() = %v", err)
+		}
+	}
+	if _, err := client.CoreV1().Namespaces().Get(opts.Namespace, metav1.GetOptions{}); err != nil {
+		t.Errorf("Get(namespace) = %v", err)
+	}
+	if _, err := client.AppsV1().Deployments(opts.Namespace).Get(opts.DeploymentName, metav1.GetOptions{}); err != nil {
+		t.Errorf("Get(deployment) = %v", err)
+	}
+}
+
+func TestRouteWebhooks(t *testing.T) {
+	opts, err := WebhookOptions("webhook", "knative-testing")
+	if err != nil {
+		t.Fatalf("WebhookOptions() = %v", err)
+	}
+	path, configPath := "/", "/config-validation"
+	other := &admissionregistrationv1beta1.ServiceReference{Namespace: "other", Name: "webhook"}
+	client := fakekubeclientset.NewSimpleClientset(
+		&admissionregistrationv1beta1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: opts.ResourceMutatingWebhookName},
+			Webhooks: []admissionregistrationv1beta1.MutatingWebhook{{
+				Name: opts.ResourceMutatingWebhookName,
+				ClientConfig: admissionregistrationv1beta1.WebhookClientConfig{
+					Service: &admissionregistrationv1beta1.ServiceReference{
+						Namespace: opts.Namespace,
+						Name:      opts.ServiceName,
+						Path:      &path,
+					},
+				},
+			}, {
+				Name:         "other.webhook.knative.dev",
+				ClientConfig: admissionregistrationv1beta1.WebhookClientConfig{Service: other},
+			}},
+		},
+		&admissionregistrationv1beta1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: opts.ConfigValidationWebhookName},
+			Webhooks: []admissionregistrationv1beta1.ValidatingWebhook{{
+				Name: opts.ConfigValidationWebhookName,
+				ClientConfig: admissionregistrationv1beta1.WebhookClientConfig{
+					Service: &admissionregistrationv1beta1.ServiceReference{
+						Namespace: opts.Namespace,
+						Name:      opts.ServiceName,
+						Path:      &configPath,
+					},
+				},
+			}},
+		},
+	)
+
+	if err := routeWebhooks(client, opts); err != nil {
+		t.Fatalf("routeWebhooks() = %v", err)
+	}
+
+	mwhc, err := client.AdmissionregistrationV1beta1().MutatingWebhookConfigurations().Get(opts.ResourceMutatingWebhookName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	url := fmt.Sprintf("https://127.0.0.1:%d/", opts.Port)
+	if diff := cmp.Diff(admissionregistrationv1beta1.WebhookClientConfig{URL: &url}, mwhc.Webhooks[0].ClientConfig); diff != "" {
+		t.Errorf("ClientConfig (-want, +got): %s", diff)
+	}
+	// The webhooks of other services are left alone.
+	if diff := cmp.Diff(admissionregistrationv1beta1.WebhookClientConfig{Service: other}, mwhc.Webhooks[1].ClientConfig); diff != "" {
+		t.Errorf("ClientConfig (-want, +got): %s", diff)
+	}
+
+	vwhc, err := client.AdmissionregistrationV1beta1().ValidatingWebhookConfigurations().Get(opts.ConfigValidationWebhookName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	configURL := fmt.Sprintf("https://127.0.0.1:%d/config-validation", opts.Port)
+	if diff := cmp.Diff(admissionregistrationv1beta1.WebhookClientConfig{URL: &configURL}, vwhc.Webhooks[0].ClientConfig); diff != "" {
+		t.Errorf("ClientConfig (-want, +got): %s", diff)
+	}
+}
+
+func TestRouteWebhooksNotRegistered(t *testing.T) {
+	opts, err := WebhookOptions("webhook", "knative-testing")
+	if err != nil {
+		t.Fatalf("WebhookOptions() = %v", err)
+	}
+	if err := routeWebhooks(fakekubeclientset.NewSimpleClientset(), opts); err == nil {
+		t.Error("routeWebhooks() = nil, wanted an error")
+	}
+}
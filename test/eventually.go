@@ -0,0 +1,156 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// eventually contains polling helpers which report the progress towards
+// the desired state and explain why it hasn't been reached on timeout.
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"knative.dev/pkg/test/logging"
+)
+
+// StateFunc observes the current state of what is waited for, returning
+// whether it is in the desired state. Returning an error stops the polling.
+type StateFunc func() (state interface{}, done bool, err error)
+
+// TimeoutError is returned when the desired state hasn't been reached in
+// time. It carries the last observed state.
+type TimeoutError struct {
+	// Description is the description of what was waited for.
+	Description string
+	// Timeout is the time waited for.
+	Timeout time.Duration
+	// LastState is the rendering of the last observed state.
+	LastState string
+	// Diff is the difference of the last observed state to the desired
+	// one (-want, +got), if known.
+	Diff string
+}
+
+// Error implements error.
+func (e *TimeoutError) Error() string {
+	msg := fmt.Sprintf("timed out after %v waiting for %s", e.Timeout, e.Description)
+	if e.Diff != "" {
+		return msg + "; diff (-want, +got):\n" + e.Diff
+	}
+	return msg + "; last observed state:\n" + e.LastState
+}
+
+// Unwrap returns wait.ErrWaitTimeout, so that errors.Is(err, wait.ErrWaitTimeout)
+// holds for a TimeoutError.
+func (e *TimeoutError) Unwrap() error {
+	return wait.ErrWaitTimeout
+}
+
+// Eventually calls check every interval until it returns done or an error,
+// or the timeout has passed, in which case a *TimeoutError is returned.
+// Changes of the observed state are logged through logf, prefixed with desc.
+func Eventually(logf logging.FormatLogger, desc string, interval, timeout time.Duration, check StateFunc) error {
+	return eventually(context.Background(), logf, desc, interval, timeout, check, nil)
+}
+
+// EventuallyContext is like Eventually, but also stops once the context is
+// done, e.g. the one of Context when the test is about to time out, returning
+// a *TimeoutError as well.
+func EventuallyContext(ctx context.Context, logf logging.FormatLogger, desc string, interval, timeout time.Duration, check StateFunc) error {
+	return eventually(ctx, logf, desc, interval, timeout, check, nil)
+}
+
+// EventuallyEqual calls get every interval until the returned state equals
+// want as per cmp.Equal with the given options, get returns an error or the
+// timeout has passed, in which case the *TimeoutError carries the diff of
+// the last observed state to want. Changes of the observed state are logged
+// through logf, prefixed with desc.
+func EventuallyEqual(logf logging.FormatLogger, desc string, interval, timeout time.Duration,
+	want interface{}, get func() (interface{}, error), opts ...cmp.Option) error {
+	return EventuallyEqualContext(context.Background(), logf, desc, interval, timeout, want, get, opts...)
+}
+
+// EventuallyEqualContext is like EventuallyEqual, but also stops once the
+// context is done, see EventuallyContext.
+func EventuallyEqualContext(ctx context.Context, logf logging.FormatLogger, desc string, interval, timeout time.Duration,
+	want interface{}, get func() (interface{}, error), opts ...cmp.Option) error {
+	check := func() (interface{}, bool, error) {
+		state, err := get()
+		if err != nil {
+			return nil, true, err
+		}
+		return state, cmp.Equal(want, state, opts...), nil
+	}
+	diff := func(state interface{}) string {
+		return cmp.Diff(want, state, opts...)
+	}
+	return eventually(ctx, logf, desc, interval, timeout, check, diff)
+}
+
+func eventually(ctx context.Context, logf logging.FormatLogger, desc string, interval, timeout time.Duration,
+	check StateFunc, diff func(interface{}) string) error {
+	var (
+		last     interface{}
+		observed bool
+		start    = time.Now()
+	)
+	pollCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := wait.PollImmediateUntil(interval, func() (bool, error) {
+		state, done, err := check()
+		if err != nil {
+			return true, err
+		}
+		if !observed || !reflect.DeepEqual(state, last) {
+			logf("%s: after %v observed: %s", desc, time.Since(start).Round(time.Millisecond), render(state))
+		}
+		last, observed = state, true
+		return done, nil
+	}, pollCtx.Done())
+	if err != wait.ErrWaitTimeout {
+		return err
+	}
+
+	te := &TimeoutError{
+		Description: desc,
+		Timeout:     timeout,
+		LastState:   "<none>",
+	}
+	if ctx.Err() != nil {
+		// Cut short, e.g. because the test is about to time out.
+		te.Timeout = time.Since(start).Round(time.Millisecond)
+	}
+	if observed {
+		te.LastState = render(last)
+		if diff != nil {
+			te.Diff = diff(last)
+		}
+	}
+	return te
+}
+
+// render returns a human-readable representation of the state.
+func render(state interface{}) string {
+	if b, err := json.MarshalIndent(state, "", "  "); err == nil {
+		return string(b)
+	}
+	return fmt.Sprintf("%+v", state)
+}
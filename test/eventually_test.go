@@ -0,0 +1,134 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+type replicas struct {
+	Ready   int
+	Desired int
+}
+
+func TestEventually(t *testing.T) {
+	var logs []string
+	logf := func(format string, args ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}
+
+	states := []int{0, 0, 1, 1, 2}
+	i := 0
+	err := Eventually(logf, "ready replicas", time.Millisecond, 5*time.Second, func() (interface{}, bool, error) {
+		state := replicas{Ready: states[i], Desired: 2}
+		i++
+		return state, state.Ready == state.Desired, nil
+	})
+	if err != nil {
+		t.Fatalf("Eventually() = %v", err)
+	}
+	// Only the transitions are logged.
+	if got, want := len(logs), 3; got != want {
+		t.Errorf("Logged %d lines, want %d: %v", got, want, logs)
+	}
+}
+
+func TestEventuallyError(t *testing.T) {
+	want := errors.New("boom")
+	err := Eventually(t.Logf, "failing", time.Millisecond, 5*time.Second, func() (interface{}, bool, error) {
+		return nil, false, want
+	})
+	if err != want {
+		t.Errorf("Eventually() = %v, want %v", err, want)
+	}
+}
+
+func TestEventuallyTimeout(t *testing.T) {
+	err := Eventually(t.Logf, "ready replicas", time.Millisecond, 10*time.Millisecond, func() (interface{}, bool, error) {
+		return replicas{Ready: 1, Desired: 2}, false, nil
+	})
+	var te *TimeoutError
+	if !errors.As(err, &te) {
+		t.Fatalf("Eventually() = %v, wanted a TimeoutError", err)
+	}
+	if !errors.Is(err, wait.ErrWaitTimeout) {
+		t.Error("errors.Is(err, wait.ErrWaitTimeout) = false, wanted true")
+	}
+	if !strings.Contains(err.Error(), `"Ready": 1`) {
+		t.Errorf("Error() = %q, wanted it to contain the last state", err)
+	}
+}
+
+func TestEventuallyEqual(t *testing.T) {
+	ready := 0
+	get := func() (interface{}, error) {
+		if ready < 2 {
+			ready++
+		}
+		return replicas{Ready: ready, Desired: 2}, nil
+	}
+	if err := EventuallyEqual(t.Logf, "ready replicas", time.Millisecond, 5*time.Second, replicas{Ready: 2, Desired: 2}, get); err != nil {
+		t.Errorf("EventuallyEqual() = %v", err)
+	}
+
+	err := EventuallyEqual(t.Logf, "ready replicas", time.Millisecond, 10*time.Millisecond, replicas{Ready: 3, Desired: 2}, get)
+	var te *TimeoutError
+	if !errors.As(err, &te) {
+		t.Fatalf("EventuallyEqual() = %v, wanted a TimeoutError", err)
+	}
+	if !strings.Contains(te.Diff, "Ready") {
+		t.Errorf("Diff = %q, wanted it to show the differing field", te.Diff)
+	}
+}
+
+func TestEventuallyContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := EventuallyContext(ctx, t.Logf, "ready replicas", time.Millisecond, time.Minute, func() (interface{}, bool, error) {
+		return replicas{Ready: 1, Desired: 2}, false, nil
+	})
+	var te *TimeoutError
+	if !errors.As(err, &te) {
+		t.Fatalf("EventuallyContext() = %v, wanted a TimeoutError", err)
+	}
+	if te.Timeout >= time.Minute || time.Since(start) >= time.Minute {
+		t.Errorf("Timeout = %v, wanted the polling to stop with the context", te.Timeout)
+	}
+	if !strings.Contains(err.Error(), `"Ready": 1`) {
+		t.Errorf("Error() = %q, wanted it to contain the last state", err)
+	}
+}
+
+func TestEventuallyEqualContext(t *testing.T) {
+	i := 0
+	err := EventuallyEqualContext(context.Background(), t.Logf, "replicas", time.Millisecond, 5*time.Second,
+		2, func() (interface{}, error) {
+			i++
+			return i, nil
+		})
+	if err != nil {
+		t.Errorf("EventuallyEqualContext() = %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// commonFlagGroup is the name of the group of the flags defined by this package.
+const commonFlagGroup = "Common e2e flags"
+
+// FlagRegistry groups the flags of a flag.FlagSet, so that the flags of every
+// group can be validated once parsed and are listed together in the usage.
+type FlagRegistry struct {
+	fs *flag.FlagSet
+
+	mu     sync.Mutex
+	groups []*flagGroup
+}
+
+type flagGroup struct {
+	name     string
+	flags    []*flag.Flag
+	validate func() error
+}
+
+// defaultFlagRegistry is the registry of the flags of the command line.
+var defaultFlagRegistry = newDefaultFlagRegistry()
+
+func newDefaultFlagRegistry() *FlagRegistry {
+	r := NewFlagRegistry(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		r.PrintDefaults()
+	}
+	return r
+}
+
+// NewFlagRegistry creates a FlagRegistry registering the flags on the given
+// flag.FlagSet.
+func NewFlagRegistry(fs *flag.FlagSet) *FlagRegistry {
+	return &FlagRegistry{fs: fs}
+}
+
+// Register calls register with a flag.FlagSet to define the flags of the group
+// with the given name on, and adds them to the registry's flag.FlagSet.
+// validate is called by Validate once the flags are parsed, and can be nil.
+// Like flag.FlagSet, Register panics if a group or a flag is defined twice.
+func (r *FlagRegistry) Register(group string, register func(*flag.FlagSet), validate func() error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, g := range r.groups {
+		if g.name == group {
+			panic(fmt.Sprintf("flag group redefined: %s", group))
+		}
+	}
+
+	groupFS := flag.NewFlagSet(group, flag.ContinueOnError)
+	register(groupFS)
+	g := &flagGroup{name: group, validate: validate}
+	groupFS.VisitAll(func(f *flag.Flag) {
+		r.fs.Var(f.Value, f.Name, f.Usage)
+		g.flags = append(g.flags, r.fs.Lookup(f.Name))
+	})
+	r.groups = append(r.groups, g)
+}
+
+// Validate validates the flags of all of the groups, returning the errors of
+// all of the groups which are invalid.
+func (r *FlagRegistry) Validate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []string
+	for _, g := range r.groups {
+		if g.validate == nil {
+			continue
+		}
+		if err := g.validate(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", g.name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid flags: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// PrintDefaults prints the defaults of the flags of every group under the
+// name of the group, followed by the flags which aren't in any group, to the
+// output of the registry's flag.FlagSet.
+func (r *FlagRegistry) PrintDefaults() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := r.fs.Output()
+	grouped := make(map[string]bool)
+	for _, g := range r.groups {
+		fmt.Fprintf(out, "\n%s:\n", g.name)
+		printDefaults(out, g.flags)
+		for _, f := range g.flags {
+			grouped[f.Name] = true
+		}
+	}
+
+	var others []*flag.Flag
+	r.fs.VisitAll(func(f *flag.Flag) {
+		if !grouped[f.Name] {
+			others = append(others, f)
+		}
+	})
+	if len(others) > 0 {
+		fmt.Fprint(out, "\nOther flags:\n")
+		printDefaults(out, others)
+	}
+}
+
+// printDefaults prints the defaults of the given flags like
+// flag.FlagSet.PrintDefaults, sorted by name.
+func printDefaults(out io.Writer, flags []*flag.Flag) {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(out)
+	for _, f := range flags {
+		fs.Var(f.Value, f.Name, f.Usage)
+		fs.Lookup(f.Name).DefValue = f.DefValue
+	}
+	fs.PrintDefaults()
+}
+
+// RegisterFlags registers a group of e2e flags on the command line, next to
+// the common ones. Downstream repos can use it to define their own flags, e.g.
+//
+//	var ServingFlags = struct{ ResolvableDomain bool }{}
+//
+//	func init() {
+//		test.RegisterFlags("Serving e2e flags", func(fs *flag.FlagSet) {
+//			fs.BoolVar(&ServingFlags.ResolvableDomain, "resolvabledomain", false,
+//				"Set this flag to true if you have configured the `domainSuffix` on your Route controller to a domain that will resolve to your test cluster.")
+//		}, nil)
+//	}
+//
+// See FlagRegistry.Register.
+func RegisterFlags(group string, register func(*flag.FlagSet), validate func() error) {
+	defaultFlagRegistry.Register(group, register, validate)
+}
+
+// ValidateFlags validates the flags of all of the groups registered on the
+// command line, including the common ones. It should be called once the
+// flags are parsed, e.g. in TestMain.
+func ValidateFlags() error {
+	return defaultFlagRegistry.Validate()
+}
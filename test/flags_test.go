@@ -0,0 +1,108 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestFlagRegistry(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Bool("ungrouped", false, "Not in any group.")
+	r := NewFlagRegistry(fs)
+
+	var (
+		name  string
+		count int
+	)
+	r.Register("Group A", func(fs *flag.FlagSet) {
+		fs.StringVar(&name, "name", "default", "The name.")
+	}, func() error {
+		if name == "" {
+			return errors.New("--name must not be empty")
+		}
+		return nil
+	})
+	r.Register("Group B", func(fs *flag.FlagSet) {
+		fs.IntVar(&count, "count", 1, "The count.")
+	}, nil)
+
+	if err := fs.Parse([]string{"--name=foo", "--count=3"}); err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+	if name != "foo" || count != 3 {
+		t.Errorf("name, count = %q, %d, want %q, %d", name, count, "foo", 3)
+	}
+	if err := r.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+
+	if err := fs.Parse([]string{"--name="}); err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+	if err := r.Validate(); err == nil || !strings.Contains(err.Error(), "Group A: --name must not be empty") {
+		t.Errorf("Validate() = %v, wanted the error of Group A", err)
+	}
+
+	var out bytes.Buffer
+	fs.SetOutput(&out)
+	r.PrintDefaults()
+	usage := out.String()
+	a := strings.Index(usage, "Group A:")
+	b := strings.Index(usage, "Group B:")
+	others := strings.Index(usage, "Other flags:")
+	if a < 0 || b < a || others < b {
+		t.Fatalf("PrintDefaults() = %s, wanted the groups in order", usage)
+	}
+	for _, want := range []struct {
+		flag       string
+		start, end int
+	}{
+		{`-name string`, a, b},
+		{`(default "default")`, a, b},
+		{`-count int`, b, others},
+		{`-ungrouped`, others, len(usage)},
+	} {
+		if i := strings.Index(usage, want.flag); i < want.start || i > want.end {
+			t.Errorf("PrintDefaults() = %s, wanted %q in its group", usage, want.flag)
+		}
+	}
+}
+
+func TestFlagRegistryRedefinedGroup(t *testing.T) {
+	r := NewFlagRegistry(flag.NewFlagSet("test", flag.ContinueOnError))
+	r.Register("group", func(*flag.FlagSet) {}, nil)
+	defer func() {
+		if recover() == nil {
+			t.Error("Register() didn't panic for a redefined group")
+		}
+	}()
+	r.Register("group", func(*flag.FlagSet) {}, nil)
+}
+
+func TestCommonFlags(t *testing.T) {
+	if flag.Lookup("kubeconfig") == nil {
+		t.Error("The common flags aren't registered on the command line")
+	}
+	if err := ValidateFlags(); err != nil {
+		t.Errorf("ValidateFlags() = %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// gcsEndpoint is the endpoint of the XML API of GCS.
+	gcsEndpoint = "https://storage.googleapis.com"
+	// gcsScope is the OAuth2 scope to read and write the objects of GCS.
+	gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+	// defaultS3Region is the region of the S3 buckets, unless overridden by
+	// the AWS_REGION environment variable.
+	defaultS3Region = "us-east-1"
+)
+
+// BucketStore is an ArtifactStore storing the artifacts as the objects of a
+// directory of a GCS or S3 bucket, through their common XML API.
+type BucketStore struct {
+	// endpoint is the URL of the bucket, e.g. "https://storage.googleapis.com/bucket".
+	endpoint string
+	dir      string
+	client   *http.Client
+	// sign signs the requests, if the client doesn't.
+	sign func(req *http.Request, body []byte) error
+	// s3 is set for the S3 buckets, whose chunked uploads are multipart
+	// uploads rather than resumable uploads.
+	s3 bool
+}
+
+var _ ArtifactStore = (*BucketStore)(nil)
+
+// NewGCSStore creates a BucketStore in the directory of the GCS bucket,
+// authenticated with the application default credentials.
+func NewGCSStore(ctx context.Context, bucket, dir string) (*BucketStore, error) {
+	client, err := google.DefaultClient(ctx, gcsScope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the GCS client: %v", err)
+	}
+	return &BucketStore{
+		endpoint: gcsEndpoint + "/" + bucket,
+		dir:      dir,
+		client:   client,
+	}, nil
+}
+
+// NewS3Store creates a BucketStore in the directory of the S3 bucket,
+// authenticated with the default credentials of the AWS SDK, e.g. the
+// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables.
+func NewS3Store(bucket, dir string) (*BucketStore, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = defaultS3Region
+	}
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the AWS session: %v", err)
+	}
+	signer := v4.NewSigner(sess.Config.Credentials)
+	return &BucketStore{
+		endpoint: fmt.Sprintf("https://s3.%s.amazonaws.com/%s", region, bucket),
+		dir:      dir,
+		client:   http.DefaultClient,
+		sign: func(req *http.Request, body []byte) error {
+			_, err := signer.Sign(req, bytes.NewReader(body), "s3", region, time.Now())
+			return err
+		},
+		s3: true,
+	}, nil
+}
+
+// Write implements ArtifactStore.
+func (s *BucketStore) Write(ctx context.Context, name string, content []byte) error {
+	_, err := s.do(ctx, http.MethodPut, s.objectURL(name), content)
+	return err
+}
+
+// Read implements ArtifactStore.
+func (s *BucketStore) Read(ctx context.Context, name string) ([]byte, error) {
+	return s.do(ctx, http.MethodGet, s.objectURL(name), nil)
+}
+
+// listBucketResult is the page of objects of a bucket listing.
+type listBucketResult struct {
+	IsTruncated bool
+	NextMarker  string
+	Contents    []struct {
+		Key string
+	}
+}
+
+// List implements ArtifactStore.
+func (s *BucketStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	dirPrefix := ""
+	if s.dir != "" {
+		dirPrefix = s.dir + "/"
+	}
+	query := url.Values{"prefix": []string{dirPrefix + prefix}}
+	for {
+		body, err := s.do(ctx, http.MethodGet, s.endpoint+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		var page listBucketResult
+		if err := xml.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("invalid listing of %s: %v", s.endpoint, err)
+		}
+		for _, o := range page.Contents {
+			names = append(names, strings.TrimPrefix(o.Key, dirPrefix))
+		}
+		if !page.IsTruncated || len(page.Contents) == 0 {
+			break
+		}
+		// S3 only returns the next marker when listing with a delimiter,
+		// in which case the listing resumes after the last object.
+		marker := page.NextMarker
+		if marker == "" {
+			marker = page.Contents[len(page.Contents)-1].Key
+		}
+		query.Set("marker", marker)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// URL implements ArtifactStore.
+func (s *BucketStore) URL(name string) string {
+	return s.objectURL(name)
+}
+
+func (s *BucketStore) objectURL(name string) string {
+	// The slashes of the names are kept, as they're part of the object names.
+	return s.endpoint + "/" + (&url.URL{Path: objectName(s.dir, name)}).EscapedPath()
+}
+
+// do sends the request, and returns the body of the response if it succeeded.
+func (s *BucketStore) do(ctx context.Context, method, rawURL string, content []byte) ([]byte, error) {
+	resp, body, err := s.send(ctx, method, rawURL, nil, content)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusError(method, rawURL, resp, body)
+	}
+	return body, nil
+}
+
+// send sends the request with the given headers, and returns the response
+// along with its body, whatever its status.
+func (s *BucketStore) send(ctx context.Context, method, rawURL string, header http.Header, content []byte) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(method, rawURL, bytes.NewReader(content))
+	if err != nil {
+		return nil, nil, err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if s.sign != nil {
+		if err := s.sign(req, content); err != nil {
+			return nil, nil, fmt.Errorf("failed to sign the request: %v", err)
+		}
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, body, nil
+}
+
+// statusError is the error of a request which failed with an unexpected
+// status.
+type statusError struct {
+	method     string
+	url        string
+	statusCode int
+	body       []byte
+}
+
+func newStatusError(method, rawURL string, resp *http.Response, body []byte) *statusError {
+	return &statusError{method: method, url: rawURL, statusCode: resp.StatusCode, body: body}
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s %s failed with status %d: %s", e.method, e.url, e.statusCode, e.body)
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcs
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeBucket serves the XML API of a bucket named "bucket", listing pageSize
+// objects per page, without the next marker like S3.
+type fakeBucket struct {
+	pageSize int
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	signed  int
+}
+
+func (b *fakeBucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if r.Header.Get("Authorization") != "" {
+		b.signed++
+	}
+
+	if r.URL.Path == "/bucket" {
+		var keys []string
+		for k := range b.objects {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) && k > r.URL.Query().Get("marker") {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		var result listBucketResult
+		if len(keys) > b.pageSize {
+			keys, result.IsTruncated = keys[:b.pageSize], true
+		}
+		for _, k := range keys {
+			result.Contents = append(result.Contents, struct{ Key string }{k})
+		}
+		xml.NewEncoder(w).Encode(struct {
+			XMLName xml.Name `xml:"ListBucketResult"`
+			listBucketResult
+		}{listBucketResult: result})
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	switch r.Method {
+	case http.MethodPut:
+		b.objects[key], _ = ioutil.ReadAll(r.Body)
+	case http.MethodGet:
+		content, ok := b.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(content)
+	}
+}
+
+func TestBucketStore(t *testing.T) {
+	bucket := &fakeBucket{pageSize: 1, objects: map[string][]byte{"other/file": nil}}
+	server := httptest.NewServer(bucket)
+	defer server.Close()
+
+	s := &BucketStore{
+		endpoint: server.URL + "/bucket",
+		dir:      "prefix/run",
+		client:   server.Client(),
+		sign: func(req *http.Request, body []byte) error {
+			req.Header.Set("Authorization", "signed")
+			return nil
+		},
+	}
+	testStore(t, s)
+
+	if _, ok := bucket.objects["prefix/run/logs/controller.log"]; !ok {
+		t.Errorf("Objects = %v, wanted the artifacts in the directory", bucket.objects)
+	}
+	if bucket.signed == 0 {
+		t.Error("None of the requests were signed")
+	}
+}
+
+func TestNewS3Store(t *testing.T) {
+	defer os.Setenv("AWS_REGION", os.Getenv("AWS_REGION"))
+	os.Setenv("AWS_REGION", "eu-west-1")
+
+	s, err := NewS3Store("bucket", "dir")
+	if err != nil {
+		t.Fatalf("NewS3Store() = %v", err)
+	}
+	if got, want := s.URL("logs/a b.log"), "https://s3.eu-west-1.amazonaws.com/bucket/dir/logs/a%20b.log"; got != want {
+		t.Errorf("URL() = %q, want %q", got, want)
+	}
+}
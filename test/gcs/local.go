@@ -0,0 +1,94 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcs
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LocalStore is an ArtifactStore storing the artifacts as files in a local
+// directory, e.g. the artifacts directory of the Prow job.
+type LocalStore struct {
+	dir string
+}
+
+var _ ArtifactStore = (*LocalStore)(nil)
+
+// NewLocalStore creates a LocalStore in the directory, which is created with
+// the first artifact written.
+func NewLocalStore(dir string) *LocalStore {
+	return &LocalStore{dir: dir}
+}
+
+// Write implements ArtifactStore.
+func (s *LocalStore) Write(_ context.Context, name string, content []byte) error {
+	p := s.path(name)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p, content, 0644)
+}
+
+// Read implements ArtifactStore.
+func (s *LocalStore) Read(_ context.Context, name string) ([]byte, error) {
+	return ioutil.ReadFile(s.path(name))
+}
+
+// List implements ArtifactStore.
+func (s *LocalStore) List(_ context.Context, prefix string) ([]string, error) {
+	var names []string
+	err := filepath.Walk(s.dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == s.dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, p)
+		if err != nil {
+			return err
+		}
+		if name := filepath.ToSlash(rel); strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	sort.Strings(names)
+	return names, err
+}
+
+// URL implements ArtifactStore.
+func (s *LocalStore) URL(name string) string {
+	p, err := filepath.Abs(s.path(name))
+	if err != nil {
+		p = s.path(name)
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(p)}).String()
+}
+
+func (s *LocalStore) path(name string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(name))
+}
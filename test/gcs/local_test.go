@@ -0,0 +1,96 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// testStore writes and reads artifacts through the store.
+func testStore(t *testing.T, s ArtifactStore) {
+	t.Helper()
+	ctx := context.Background()
+
+	names, err := s.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List() = %v", err)
+	}
+	if len(names) != 0 {
+		t.Errorf("List() = %v, wanted no artifacts", names)
+	}
+
+	for _, name := range []string{"logs/controller.log", "logs/webhook.log", "results.json"} {
+		if err := s.Write(ctx, name, []byte(name)); err != nil {
+			t.Fatalf("Write(%q) = %v", name, err)
+		}
+	}
+	if err := s.Write(ctx, "results.json", []byte("{}")); err != nil {
+		t.Fatalf("Write(results.json) = %v", err)
+	}
+
+	got, err := s.Read(ctx, "logs/webhook.log")
+	if err != nil {
+		t.Fatalf("Read() = %v", err)
+	}
+	if want := "logs/webhook.log"; string(got) != want {
+		t.Errorf("Read() = %q, want %q", got, want)
+	}
+	if got, err := s.Read(ctx, "results.json"); err != nil || string(got) != "{}" {
+		t.Errorf("Read(results.json) = %q, %v, wanted it overwritten", got, err)
+	}
+	if _, err := s.Read(ctx, "missing"); err == nil {
+		t.Error("Read(missing) = nil, wanted an error")
+	}
+
+	names, err = s.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List() = %v", err)
+	}
+	if diff := cmp.Diff([]string{"logs/controller.log", "logs/webhook.log", "results.json"}, names); diff != "" {
+		t.Errorf("List() (-want, +got): %s", diff)
+	}
+	names, err = s.List(ctx, "logs/")
+	if err != nil {
+		t.Fatalf("List(logs/) = %v", err)
+	}
+	if diff := cmp.Diff([]string{"logs/controller.log", "logs/webhook.log"}, names); diff != "" {
+		t.Errorf("List(logs/) (-want, +got): %s", diff)
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifacts")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	testStore(t, NewLocalStore(filepath.Join(dir, "artifacts")))
+
+	if _, err := os.Stat(filepath.Join(dir, "artifacts", "logs", "controller.log")); err != nil {
+		t.Errorf("Stat() = %v, wanted the artifact written as a file", err)
+	}
+	if got, want := NewLocalStore(dir).URL("logs/a b.log"), "file://"+dir+"/logs/a%20b.log"; got != want {
+		t.Errorf("URL() = %q, want %q", got, want)
+	}
+}
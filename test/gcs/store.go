@@ -0,0 +1,105 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package gcs stores the artifacts of the perf and e2e tests, e.g. the
+// benchmark results and the logs, in Google Cloud Storage, or behind the same
+// ArtifactStore interface in S3 or on the local filesystem, so the same flows
+// run on CI environments outside of GCP.
+package gcs
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ArtifactStore stores artifacts by name, e.g. "logs/controller.log". The
+// names are slash separated, whatever the backend.
+type ArtifactStore interface {
+	// Write stores the content of the named artifact, replacing it if it
+	// exists.
+	Write(ctx context.Context, name string, content []byte) error
+	// Read returns the content of the named artifact.
+	Read(ctx context.Context, name string) ([]byte, error)
+	// List returns the sorted names of the artifacts starting with prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+	// URL returns the URL to link the named artifact with, e.g. in the
+	// Github issues.
+	URL(name string) string
+}
+
+// NewArtifactStore creates the ArtifactStore of the location, which is either
+// a bucket, with an optional directory, in GCS, like "gs://bucket/dir", or in
+// S3, like "s3://bucket/dir", or a local directory, like "file:///tmp/dir" or
+// just "/tmp/dir".
+func NewArtifactStore(ctx context.Context, location string) (ArtifactStore, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid artifact store %q: %v", location, err)
+	}
+	dir := strings.Trim(u.Path, "/")
+	switch u.Scheme {
+	case "gs":
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid artifact store %q: missing the bucket", location)
+		}
+		store, err := NewGCSStore(ctx, u.Host, dir)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case "s3":
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid artifact store %q: missing the bucket", location)
+		}
+		store, err := NewS3Store(u.Host, dir)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case "file", "":
+		if u.Path == "" {
+			return nil, fmt.Errorf("invalid artifact store %q: missing the directory", location)
+		}
+		return NewLocalStore(u.Path), nil
+	default:
+		return nil, fmt.Errorf("invalid artifact store %q: unsupported scheme %q", location, u.Scheme)
+	}
+}
+
+// UploadFile stores the content of the local file as the named artifact, with
+// the default UploadOptions, see UploadFileWithOptions.
+func UploadFile(ctx context.Context, store ArtifactStore, name, filePath string) error {
+	return UploadFileWithOptions(ctx, store, name, filePath, UploadOptions{})
+}
+
+// DownloadFile writes the content of the named artifact to the local file.
+func DownloadFile(ctx context.Context, store ArtifactStore, name, filePath string) error {
+	content, err := store.Read(ctx, name)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filePath, content, 0644)
+}
+
+// objectName returns the name of the object of the artifact in the directory
+// of a bucket.
+func objectName(dir, name string) string {
+	return path.Join(dir, name)
+}
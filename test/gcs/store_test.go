@@ -0,0 +1,101 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewArtifactStore(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		location string
+		want     ArtifactStore
+		wantErr  bool
+	}{{
+		location: "/tmp/artifacts",
+		want:     NewLocalStore("/tmp/artifacts"),
+	}, {
+		location: "file:///tmp/artifacts",
+		want:     NewLocalStore("/tmp/artifacts"),
+	}, {
+		location: "s3://bucket/dir/",
+		want:     &BucketStore{},
+	}, {
+		location: "s3:///dir",
+		wantErr:  true,
+	}, {
+		location: "gs:///dir",
+		wantErr:  true,
+	}, {
+		location: "file://",
+		wantErr:  true,
+	}, {
+		location: "ftp://host/dir",
+		wantErr:  true,
+	}}
+	for _, test := range tests {
+		t.Run(test.location, func(t *testing.T) {
+			got, err := NewArtifactStore(ctx, test.location)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("NewArtifactStore() = %v, wantErr %v", err, test.wantErr)
+			}
+			switch want := test.want.(type) {
+			case *LocalStore:
+				if got, ok := got.(*LocalStore); !ok || got.dir != want.dir {
+					t.Errorf("NewArtifactStore() = %#v, want %#v", got, want)
+				}
+			case *BucketStore:
+				if got, ok := got.(*BucketStore); !ok || got.dir != "dir" {
+					t.Errorf("NewArtifactStore() = %#v, wanted an S3 store of dir", got)
+				}
+			}
+		})
+	}
+}
+
+func TestUploadDownloadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifacts")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	store := NewLocalStore(filepath.Join(dir, "store"))
+
+	src := filepath.Join(dir, "results.json")
+	if err := ioutil.WriteFile(src, []byte("{}"), 0644); err != nil {
+		t.Fatalf("WriteFile() = %v", err)
+	}
+	if err := UploadFile(ctx, store, "run/results.json", src); err != nil {
+		t.Fatalf("UploadFile() = %v", err)
+	}
+	dst := filepath.Join(dir, "downloaded.json")
+	if err := DownloadFile(ctx, store, "run/results.json", dst); err != nil {
+		t.Fatalf("DownloadFile() = %v", err)
+	}
+	if got, err := ioutil.ReadFile(dst); err != nil || string(got) != "{}" {
+		t.Errorf("ReadFile() = %q, %v, want %q", got, err, "{}")
+	}
+	if err := UploadFile(ctx, store, "missing", filepath.Join(dir, "missing")); err == nil {
+		t.Error("UploadFile(missing) = nil, wanted an error")
+	}
+}
@@ -0,0 +1,488 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcs
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"knative.dev/pkg/test/helpers"
+)
+
+const (
+	// DefaultChunkSize is the size of the chunks the artifacts larger than it
+	// are uploaded in.
+	DefaultChunkSize = 8 << 20
+
+	// gcsChunkAlignment is what the size of the chunks of the GCS resumable
+	// uploads must be a multiple of, except for the last one.
+	gcsChunkAlignment = 256 << 10
+	// s3MinPartSize and s3MaxParts are the minimum size of the parts of the
+	// S3 multipart uploads, except for the last one, and their maximum
+	// number.
+	s3MinPartSize = 5 << 20
+	s3MaxParts    = 10000
+
+	// defaultParallelism is the default number of files UploadDir uploads at
+	// once.
+	defaultParallelism = 8
+)
+
+// UploadOptions configures the uploads of UploadFileWithOptions.
+type UploadOptions struct {
+	// ChunkSize is the size of the chunks the artifacts larger than it are
+	// uploaded in, if the store is a ChunkedWriter. Defaults to
+	// DefaultChunkSize.
+	ChunkSize int64
+	// Retry is the retry policy of the uploads of the artifacts, or of each
+	// of their chunks. Defaults to retrying the transient errors, see
+	// IsTransientError, with helpers.DefaultRetryPolicy.
+	Retry *helpers.RetryPolicy
+}
+
+func (o UploadOptions) withDefaults() UploadOptions {
+	if o.ChunkSize <= 0 {
+		o.ChunkSize = DefaultChunkSize
+	}
+	if o.Retry == nil {
+		policy := helpers.DefaultRetryPolicy(IsTransientError)
+		o.Retry = &policy
+	}
+	return o
+}
+
+// ChunkedWriter is implemented by the ArtifactStores which can upload large
+// artifacts in chunks, retrying each chunk which fails rather than the whole
+// artifact.
+type ChunkedWriter interface {
+	// WriteChunked stores the content of the named artifact, of the given
+	// size, in chunks. An upload which fails for good is aborted, so that
+	// it isn't left incomplete.
+	WriteChunked(ctx context.Context, name string, content io.ReaderAt, size int64, opts UploadOptions) error
+}
+
+var _ ChunkedWriter = (*BucketStore)(nil)
+
+// IsTransientError returns true if the request failed transiently and is
+// worth retrying, i.e. it couldn't be sent or answered, or it failed with a
+// server error, a timeout or a rate limit.
+func IsTransientError(err error) bool {
+	switch err := err.(type) {
+	case *statusError:
+		return err.statusCode >= http.StatusInternalServerError ||
+			err.statusCode == http.StatusRequestTimeout ||
+			err.statusCode == http.StatusTooManyRequests
+	case *url.Error:
+		return err.Err != context.Canceled && err.Err != context.DeadlineExceeded
+	default:
+		return err == io.ErrUnexpectedEOF
+	}
+}
+
+// UploadFileWithOptions stores the content of the local file as the named
+// artifact, retrying the upload if it fails transiently. The files larger than
+// the chunk size are uploaded in chunks if the store supports it, see
+// ChunkedWriter.
+func UploadFileWithOptions(ctx context.Context, store ArtifactStore, name, filePath string, opts UploadOptions) error {
+	opts = opts.withDefaults()
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if cw, ok := store.(ChunkedWriter); ok && info.Size() > opts.ChunkSize {
+		return cw.WriteChunked(ctx, name, f, info.Size(), opts)
+	}
+
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	return helpers.RunWithRetry(ctx, fmt.Sprintf("uploading %s to %s", filePath, store.URL(name)),
+		func(int) error {
+			return store.Write(ctx, name, content)
+		}, false, *opts.Retry)
+}
+
+// UploadDirOptions configures UploadDir.
+type UploadDirOptions struct {
+	UploadOptions
+
+	// Include are the glob patterns, see path.Match, of the files to
+	// upload, e.g. "logs/*.log". The patterns with a slash match the path
+	// of the files relative to the directory, slash separated, the others
+	// their base name, e.g. "*.log". Defaults to all the files.
+	Include []string
+	// Exclude are the glob patterns of the files not to upload, even if
+	// they're included.
+	Exclude []string
+	// Parallelism is the maximum number of files uploaded at once.
+	// Defaults to 8.
+	Parallelism int
+}
+
+// UploadDir uploads the files of the local directory and of its
+// subdirectories in parallel, each like UploadFileWithOptions, as the artifacts
+// named after their path relative to the directory under the given prefix,
+// e.g. "run1/logs/controller.log" for "<dir>/logs/controller.log" under
+// "run1". A file which fails to upload doesn't stop the others, the errors of
+// all of the files are returned.
+func UploadDir(ctx context.Context, store ArtifactStore, prefix, dir string, opts UploadDirOptions) error {
+	for _, pattern := range append(append([]string(nil), opts.Include...), opts.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+	}
+	files, err := listFiles(dir, opts.Include, opts.Exclude)
+	if err != nil {
+		return err
+	}
+	parallelism := opts.Parallelism
+	if parallelism <= 0 {
+		parallelism = defaultParallelism
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	sem := make(chan struct{}, parallelism)
+	for _, rel := range files {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			mu.Lock()
+			errs = append(errs, fmt.Errorf("failed to upload %s: %v", rel, ctx.Err()))
+			mu.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func(rel string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			filePath := filepath.Join(dir, filepath.FromSlash(rel))
+			if err := UploadFileWithOptions(ctx, store, path.Join(prefix, rel), filePath, opts.UploadOptions); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("failed to upload %s: %v", rel, err))
+				mu.Unlock()
+			}
+		}(rel)
+	}
+	wg.Wait()
+
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return helpers.CombineErrors(errs)
+}
+
+// listFiles returns the paths, relative to the directory and slash separated,
+// of the files of the directory and of its subdirectories which are included
+// and not excluded.
+func listFiles(dir string, include, exclude []string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if (len(include) == 0 || matchesAny(include, rel)) && !matchesAny(exclude, rel) {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// matchesAny returns whether one of the patterns matches the relative path of
+// the file, see UploadDirOptions.Include.
+func matchesAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		name := rel
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(rel)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteChunked implements ChunkedWriter, through the resumable uploads of GCS
+// or the multipart uploads of S3.
+func (s *BucketStore) WriteChunked(ctx context.Context, name string, content io.ReaderAt, size int64, opts UploadOptions) error {
+	opts = opts.withDefaults()
+	if size == 0 {
+		return s.Write(ctx, name, nil)
+	}
+	if s.s3 {
+		return s.writeMultipart(ctx, name, content, size, opts)
+	}
+	return s.writeResumable(ctx, name, content, size, opts)
+}
+
+// retry runs the call, retrying it according to the policy of the options.
+func retry(ctx context.Context, message string, opts UploadOptions, call func(attempt int) error) error {
+	return helpers.RunWithRetry(ctx, message, call, false, *opts.Retry)
+}
+
+// readChunk reads the chunk of the content starting at the offset, of the
+// given size or up to the end of the content, into the buffer.
+func readChunk(content io.ReaderAt, buf []byte, offset, size int64) ([]byte, error) {
+	n := int64(len(buf))
+	if rest := size - offset; rest < n {
+		n = rest
+	}
+	if _, err := content.ReadAt(buf[:n], offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// writeResumable uploads the content through a resumable upload of GCS,
+// resuming it after the last byte persisted by the server when a chunk fails.
+func (s *BucketStore) writeResumable(ctx context.Context, name string, content io.ReaderAt, size int64, opts UploadOptions) error {
+	chunkSize := (opts.ChunkSize + gcsChunkAlignment - 1) / gcsChunkAlignment * gcsChunkAlignment
+	objectURL := s.objectURL(name)
+
+	var session string
+	if err := retry(ctx, "starting the upload of "+objectURL, opts, func(int) error {
+		resp, body, err := s.send(ctx, http.MethodPost, objectURL, http.Header{"X-Goog-Resumable": []string{"start"}}, nil)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusCreated {
+			return newStatusError(http.MethodPost, objectURL, resp, body)
+		}
+		if session = resp.Header.Get("Location"); session == "" {
+			return fmt.Errorf("starting the upload of %s returned no session", objectURL)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	buf := make([]byte, chunkSize)
+	var (
+		offset int64
+		done   bool
+	)
+	for !done {
+		err := retry(ctx, fmt.Sprintf("uploading %s from byte %d", objectURL, offset), opts, func(attempt int) error {
+			if attempt > 1 {
+				// The failed attempt may have persisted part of the
+				// chunk, resume after it.
+				var err error
+				if offset, done, err = s.resumableStatus(ctx, session, size); err != nil || done {
+					return err
+				}
+			}
+			chunk, err := readChunk(content, buf, offset, size)
+			if err != nil {
+				return err
+			}
+			header := http.Header{"Content-Range": []string{fmt.Sprintf("bytes %d-%d/%d", offset, offset+int64(len(chunk))-1, size)}}
+			resp, body, err := s.send(ctx, http.MethodPut, session, header, chunk)
+			if err != nil {
+				return err
+			}
+			next, complete, err := resumableProgress(session, resp, body)
+			if err != nil {
+				return err
+			}
+			if !complete && next <= offset {
+				return fmt.Errorf("uploading %s made no progress from byte %d", objectURL, offset)
+			}
+			offset, done = next, complete
+			return nil
+		})
+		if err != nil {
+			// Cancel the upload rather than leaving it incomplete.
+			s.send(ctx, http.MethodDelete, session, nil, nil)
+			return err
+		}
+	}
+	return nil
+}
+
+// resumableStatus returns the offset to resume the resumable upload of the
+// session from, or whether it is complete.
+func (s *BucketStore) resumableStatus(ctx context.Context, session string, size int64) (int64, bool, error) {
+	header := http.Header{"Content-Range": []string{fmt.Sprintf("bytes */%d", size)}}
+	resp, body, err := s.send(ctx, http.MethodPut, session, header, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	return resumableProgress(session, resp, body)
+}
+
+// resumableProgress returns the offset after the last byte persisted by the
+// response to a chunk of a resumable upload, or whether the upload is
+// complete.
+func resumableProgress(session string, resp *http.Response, body []byte) (int64, bool, error) {
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return 0, true, nil
+	case http.StatusPermanentRedirect:
+		// The Range header, e.g. "bytes=0-1023", is missing if nothing was
+		// persisted yet.
+		r := resp.Header.Get("Range")
+		if r == "" {
+			return 0, false, nil
+		}
+		i := strings.LastIndex(r, "-")
+		last, err := strconv.ParseInt(r[i+1:], 10, 64)
+		if i < 0 || err != nil {
+			return 0, false, fmt.Errorf("invalid range %q of the upload %s", r, session)
+		}
+		return last + 1, false, nil
+	default:
+		return 0, false, newStatusError(http.MethodPut, session, resp, body)
+	}
+}
+
+// initiateMultipartUploadResult is the response to the creation of a
+// multipart upload of S3.
+type initiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+// completeMultipartUpload lists the parts of a multipart upload of S3 to
+// complete it with.
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+type completedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+// writeMultipart uploads the content through a multipart upload of S3,
+// retrying each part which fails.
+func (s *BucketStore) writeMultipart(ctx context.Context, name string, content io.ReaderAt, size int64, opts UploadOptions) error {
+	partSize := opts.ChunkSize
+	if partSize < s3MinPartSize {
+		partSize = s3MinPartSize
+	}
+	if min := (size + s3MaxParts - 1) / s3MaxParts; partSize < min {
+		partSize = min
+	}
+	objectURL := s.objectURL(name)
+
+	var uploadID string
+	if err := retry(ctx, "starting the upload of "+objectURL, opts, func(int) error {
+		body, err := s.do(ctx, http.MethodPost, objectURL+"?uploads", nil)
+		if err != nil {
+			return err
+		}
+		var result initiateMultipartUploadResult
+		if err := xml.Unmarshal(body, &result); err != nil || result.UploadID == "" {
+			return fmt.Errorf("invalid upload of %s: %s", objectURL, body)
+		}
+		uploadID = result.UploadID
+		return nil
+	}); err != nil {
+		return err
+	}
+	uploadURL := objectURL + "?" + url.Values{"uploadId": []string{uploadID}}.Encode()
+
+	err := func() error {
+		buf := make([]byte, partSize)
+		var complete completeMultipartUpload
+		for offset, number := int64(0), 1; offset < size; offset, number = offset+partSize, number+1 {
+			chunk, err := readChunk(content, buf, offset, size)
+			if err != nil {
+				return err
+			}
+			partURL := objectURL + "?" + url.Values{
+				"partNumber": []string{strconv.Itoa(number)},
+				"uploadId":   []string{uploadID},
+			}.Encode()
+			part := completedPart{PartNumber: number}
+			if err := retry(ctx, fmt.Sprintf("uploading part %d of %s", number, objectURL), opts, func(int) error {
+				resp, body, err := s.send(ctx, http.MethodPut, partURL, nil, chunk)
+				if err != nil {
+					return err
+				}
+				if resp.StatusCode != http.StatusOK {
+					return newStatusError(http.MethodPut, partURL, resp, body)
+				}
+				part.ETag = resp.Header.Get("ETag")
+				return nil
+			}); err != nil {
+				return err
+			}
+			complete.Parts = append(complete.Parts, part)
+		}
+
+		b, err := xml.Marshal(complete)
+		if err != nil {
+			return err
+		}
+		return retry(ctx, "completing the upload of "+objectURL, opts, func(int) error {
+			body, err := s.do(ctx, http.MethodPost, uploadURL, b)
+			if err != nil {
+				return err
+			}
+			// S3 can fail to complete the upload after answering 200.
+			if bytesContainError(body) {
+				return fmt.Errorf("failed to complete the upload of %s: %s", objectURL, body)
+			}
+			return nil
+		})
+	}()
+	if err != nil {
+		// Abort the upload, so that its parts aren't left behind.
+		s.send(ctx, http.MethodDelete, uploadURL, nil, nil)
+	}
+	return err
+}
+
+// bytesContainError returns whether the body of a response is an S3 error.
+func bytesContainError(body []byte) bool {
+	var root struct {
+		XMLName xml.Name
+	}
+	return xml.Unmarshal(body, &root) == nil && root.XMLName.Local == "Error"
+}
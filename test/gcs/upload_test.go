@@ -0,0 +1,419 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"knative.dev/pkg/test/helpers"
+)
+
+// fakeUploads serves the resumable uploads of GCS and the multipart uploads
+// of S3 of a bucket named "bucket", along with the plain uploads. The first
+// failChunks chunks or parts fail with a server error, after persisting half
+// of their content for the resumable uploads.
+type fakeUploads struct {
+	failChunks int
+
+	mu       sync.Mutex
+	objects  map[string][]byte
+	sessions map[string]*fakeSession
+	aborted  int
+	inFlight int
+	maxInFl  int
+}
+
+type fakeSession struct {
+	key     string
+	content []byte
+	parts   map[int][]byte
+}
+
+func newFakeUploads() *fakeUploads {
+	return &fakeUploads{
+		objects:  map[string][]byte{},
+		sessions: map[string]*fakeSession{},
+	}
+}
+
+func (f *fakeUploads) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.inFlight++
+	if f.inFlight > f.maxInFl {
+		f.maxInFl = f.inFlight
+	}
+	f.mu.Unlock()
+	// Give the other uploads a chance to overlap.
+	time.Sleep(time.Millisecond)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	defer func() { f.inFlight-- }()
+
+	body, _ := ioutil.ReadAll(r.Body)
+	query := r.URL.Query()
+	if strings.HasPrefix(r.URL.Path, "/upload/") {
+		f.serveResumable(w, r, strings.TrimPrefix(r.URL.Path, "/upload/"), body)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	switch {
+	case r.Method == http.MethodPost && r.Header.Get("X-Goog-Resumable") == "start":
+		id := strconv.Itoa(len(f.sessions))
+		f.sessions[id] = &fakeSession{key: key}
+		w.Header().Set("Location", "http://"+r.Host+"/upload/"+id)
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPost && query["uploads"] != nil:
+		id := strconv.Itoa(len(f.sessions))
+		f.sessions[id] = &fakeSession{key: key, parts: map[int][]byte{}}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case query.Get("uploadId") != "":
+		f.serveMultipart(w, r, query, body)
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func (f *fakeUploads) serveResumable(w http.ResponseWriter, r *http.Request, id string, body []byte) {
+	s, ok := f.sessions[id]
+	if !ok {
+		http.Error(w, "no such upload", http.StatusNotFound)
+		return
+	}
+	if r.Method == http.MethodDelete {
+		delete(f.sessions, id)
+		f.aborted++
+		return
+	}
+
+	var total int
+	if !strings.HasPrefix(r.Header.Get("Content-Range"), "bytes */") {
+		var first, last int
+		if _, err := fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%d", &first, &last, &total); err != nil || first != len(s.content) {
+			http.Error(w, "invalid range", http.StatusBadRequest)
+			return
+		}
+		if f.failChunks > 0 {
+			f.failChunks--
+			s.content = append(s.content, body[:len(body)/2]...)
+			http.Error(w, "backend error", http.StatusServiceUnavailable)
+			return
+		}
+		s.content = append(s.content, body...)
+	} else {
+		fmt.Sscanf(r.Header.Get("Content-Range"), "bytes */%d", &total)
+	}
+	if len(s.content) == total {
+		f.objects[s.key] = s.content
+		delete(f.sessions, id)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if len(s.content) > 0 {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(s.content)-1))
+	}
+	w.WriteHeader(http.StatusPermanentRedirect)
+}
+
+func (f *fakeUploads) serveMultipart(w http.ResponseWriter, r *http.Request, query map[string][]string, body []byte) {
+	id := query["uploadId"][0]
+	s, ok := f.sessions[id]
+	if !ok {
+		http.Error(w, "NoSuchUpload", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		if f.failChunks > 0 {
+			f.failChunks--
+			http.Error(w, "InternalError", http.StatusInternalServerError)
+			return
+		}
+		n, _ := strconv.Atoi(query["partNumber"][0])
+		s.parts[n] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"etag%d"`, n))
+	case http.MethodPost:
+		var complete completeMultipartUpload
+		if err := xml.Unmarshal(body, &complete); err != nil {
+			http.Error(w, "MalformedXML", http.StatusBadRequest)
+			return
+		}
+		var content []byte
+		for _, p := range complete.Parts {
+			if p.ETag != fmt.Sprintf(`"etag%d"`, p.PartNumber) {
+				http.Error(w, "InvalidPart", http.StatusBadRequest)
+				return
+			}
+			content = append(content, s.parts[p.PartNumber]...)
+		}
+		f.objects[s.key] = content
+		delete(f.sessions, id)
+	case http.MethodDelete:
+		delete(f.sessions, id)
+		f.aborted++
+	}
+}
+
+// fastRetries retries the transient errors 3 times without waiting.
+var fastRetries = helpers.RetryPolicy{
+	MaxAttempts: 3,
+	Backoff:     wait.Backoff{Duration: time.Millisecond, Factor: 1},
+	Retryable:   IsTransientError,
+}
+
+func writeTempFile(t *testing.T, dir, name string, content []byte) string {
+	t.Helper()
+	p := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatalf("MkdirAll() = %v", err)
+	}
+	if err := ioutil.WriteFile(p, content, 0644); err != nil {
+		t.Fatalf("WriteFile() = %v", err)
+	}
+	return p
+}
+
+func TestUploadFileChunked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upload")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	gcsContent := bytes.Repeat([]byte("0123456789"), 60<<10)
+	s3Content := bytes.Repeat([]byte("0123456789"), 1100<<10)
+	tests := []struct {
+		name       string
+		s3         bool
+		content    []byte
+		failChunks int
+		wantErr    bool
+	}{{
+		name:    "resumable",
+		content: gcsContent,
+	}, {
+		name:       "resumable with retries",
+		content:    gcsContent,
+		failChunks: 2,
+	}, {
+		name:       "resumable failing",
+		content:    gcsContent,
+		failChunks: 10,
+		wantErr:    true,
+	}, {
+		name:    "multipart",
+		s3:      true,
+		content: s3Content,
+	}, {
+		name:       "multipart with retries",
+		s3:         true,
+		content:    s3Content,
+		failChunks: 2,
+	}, {
+		name:       "multipart failing",
+		s3:         true,
+		content:    s3Content,
+		failChunks: 10,
+		wantErr:    true,
+	}, {
+		name:    "small",
+		content: []byte("small"),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			uploads := newFakeUploads()
+			uploads.failChunks = test.failChunks
+			server := httptest.NewServer(uploads)
+			defer server.Close()
+			s := &BucketStore{
+				endpoint: server.URL + "/bucket",
+				dir:      "run",
+				client:   server.Client(),
+				s3:       test.s3,
+			}
+
+			p := writeTempFile(t, dir, "file", test.content)
+			err := UploadFileWithOptions(context.Background(), s, "logs/file", p, UploadOptions{ChunkSize: 1, Retry: &fastRetries})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("UploadFileWithOptions() = %v, wantErr %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				if uploads.aborted != 1 || len(uploads.sessions) != 0 {
+					t.Errorf("Aborted %d uploads with %d left, want the failed upload aborted", uploads.aborted, len(uploads.sessions))
+				}
+				return
+			}
+			if !bytes.Equal(uploads.objects["run/logs/file"], test.content) {
+				t.Errorf("Uploaded %d bytes, want the %d bytes of the file", len(uploads.objects["run/logs/file"]), len(test.content))
+			}
+		})
+	}
+}
+
+func TestUploadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upload")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"build.log", "logs/controller.log", "logs/webhook.log", "logs/debug/trace.log", "dumps/pods.yaml", "junit.xml"} {
+		writeTempFile(t, dir, name, []byte(name))
+	}
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    []string
+	}{{
+		name: "all",
+		want: []string{"run/build.log", "run/dumps/pods.yaml", "run/junit.xml", "run/logs/controller.log", "run/logs/debug/trace.log", "run/logs/webhook.log"},
+	}, {
+		name:    "base name",
+		include: []string{"*.log"},
+		want:    []string{"run/build.log", "run/logs/controller.log", "run/logs/debug/trace.log", "run/logs/webhook.log"},
+	}, {
+		name:    "relative path",
+		include: []string{"logs/*.log", "junit.xml"},
+		want:    []string{"run/junit.xml", "run/logs/controller.log", "run/logs/webhook.log"},
+	}, {
+		name:    "excluded",
+		include: []string{"*.log"},
+		exclude: []string{"logs/debug/*", "build.*"},
+		want:    []string{"run/logs/controller.log", "run/logs/webhook.log"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			uploads := newFakeUploads()
+			server := httptest.NewServer(uploads)
+			defer server.Close()
+			s := &BucketStore{endpoint: server.URL + "/bucket", client: server.Client()}
+
+			if err := UploadDir(context.Background(), s, "run", dir, UploadDirOptions{
+				Include:     test.include,
+				Exclude:     test.exclude,
+				Parallelism: 2,
+			}); err != nil {
+				t.Fatalf("UploadDir() = %v", err)
+			}
+			var got []string
+			for k, v := range uploads.objects {
+				got = append(got, k)
+				if want := strings.TrimPrefix(k, "run/"); string(v) != want {
+					t.Errorf("Content of %s = %q, want %q", k, v, want)
+				}
+			}
+			sort.Strings(got)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Uploaded (-want, +got) = %s", diff)
+			}
+			if uploads.maxInFl > 2 {
+				t.Errorf("Uploaded %d files at once, want at most 2", uploads.maxInFl)
+			}
+		})
+	}
+}
+
+func TestUploadDirErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upload")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	writeTempFile(t, dir, "a.log", []byte("a"))
+	writeTempFile(t, dir, "b.log", []byte("b"))
+
+	if err := UploadDir(context.Background(), NewLocalStore(dir), "", dir, UploadDirOptions{Include: []string{"[a"}}); err == nil {
+		t.Error("UploadDir() with an invalid pattern = nil, want an error")
+	}
+
+	// The files are all tried, and their errors returned.
+	uploads := newFakeUploads()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/a.log") {
+			http.Error(w, "AccessDenied", http.StatusForbidden)
+			return
+		}
+		uploads.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	s := &BucketStore{endpoint: server.URL + "/bucket", client: server.Client()}
+	err = UploadDir(context.Background(), s, "", dir, UploadDirOptions{UploadOptions: UploadOptions{Retry: &fastRetries}})
+	if err == nil || !strings.Contains(err.Error(), "failed to upload a.log") {
+		t.Errorf("UploadDir() = %v, want the error of a.log", err)
+	}
+	if _, ok := uploads.objects["b.log"]; !ok {
+		t.Error("b.log wasn't uploaded after a.log failed")
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{{
+		name: "server error",
+		err:  &statusError{statusCode: http.StatusServiceUnavailable},
+		want: true,
+	}, {
+		name: "rate limit",
+		err:  &statusError{statusCode: http.StatusTooManyRequests},
+		want: true,
+	}, {
+		name: "client error",
+		err:  &statusError{statusCode: http.StatusForbidden},
+	}, {
+		name: "canceled",
+		err:  &url.Error{Op: "Put", URL: "http://bucket", Err: context.Canceled},
+	}, {
+		name: "transport error",
+		err:  &url.Error{Op: "Put", URL: "http://bucket", Err: fmt.Errorf("connection reset")},
+		want: true,
+	}, {
+		name: "other",
+		err:  os.ErrNotExist,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := IsTransientError(test.err); got != test.want {
+				t.Errorf("IsTransientError() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// auth.go defines how the clients authenticate to Github, with a personal
+// access token or as a Github App installation
+
+package ghutil
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/jws"
+)
+
+const (
+	// appJWTLifetime is how long the JWTs authenticating as a Github App are
+	// valid, Github accepts at most 10 minutes.
+	appJWTLifetime = 9 * time.Minute
+	// appClockSkew is how far in the past the JWTs are issued, to allow for
+	// the clock drift with Github.
+	appClockSkew = time.Minute
+)
+
+// Auth authenticates a GithubClient.
+type Auth interface {
+	// TokenSource returns the source of the tokens authenticating the calls
+	// to the Github API at the given base URL.
+	TokenSource(baseURL string) (oauth2.TokenSource, error)
+}
+
+// TokenAuth authenticates with a personal access token.
+type TokenAuth struct {
+	// TokenFilePath is the path of the file holding the token.
+	TokenFilePath string
+}
+
+var _ Auth = TokenAuth{}
+
+// TokenSource returns the token read from the file.
+func (a TokenAuth) TokenSource(string) (oauth2.TokenSource, error) {
+	b, err := ioutil.ReadFile(a.TokenFilePath)
+	if err != nil {
+		return nil, err
+	}
+	return oauth2.StaticTokenSource(
+		&oauth2.Token{AccessToken: strings.TrimSpace(string(b))},
+	), nil
+}
+
+// AppAuth authenticates as an installation of a Github App, with installation
+// tokens which are refreshed automatically before they expire.
+type AppAuth struct {
+	// AppID is the ID of the Github App.
+	AppID int64
+	// InstallationID is the ID of the installation of the App in the
+	// organization or the repositories to operate on.
+	InstallationID int64
+	// PrivateKeyPath is the path of the PEM encoded private key of the App.
+	PrivateKeyPath string
+}
+
+var _ Auth = AppAuth{}
+
+// TokenSource returns the installation tokens, refreshed as they expire.
+func (a AppAuth) TokenSource(baseURL string) (oauth2.TokenSource, error) {
+	if a.AppID == 0 || a.InstallationID == 0 {
+		return nil, errors.New("app ID and installation ID of the Github App cannot be empty")
+	}
+	b, err := ioutil.ReadFile(a.PrivateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	key, err := parsePrivateKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key of Github App %d: %v", a.AppID, err)
+	}
+	return oauth2.ReuseTokenSource(nil, &appTokenSource{auth: a, key: key, baseURL: baseURL, now: time.Now}), nil
+}
+
+// appTokenSource creates the installation tokens of a Github App.
+type appTokenSource struct {
+	auth    AppAuth
+	key     *rsa.PrivateKey
+	baseURL string
+	now     func() time.Time
+}
+
+// Token creates a new installation token, authenticating as the App with a
+// JWT signed by its private key.
+func (s *appTokenSource) Token() (*oauth2.Token, error) {
+	now := s.now()
+	jwt, err := jws.Encode(
+		&jws.Header{Algorithm: "RS256", Typ: "JWT"},
+		&jws.ClaimSet{
+			Iss: strconv.FormatInt(s.auth.AppID, 10),
+			Iat: now.Add(-appClockSkew).Unix(),
+			Exp: now.Add(appJWTLifetime).Unix(),
+		},
+		s.key,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign the JWT of Github App %d: %v", s.auth.AppID, err)
+	}
+	client, err := newClient(s.baseURL, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: jwt, TokenType: "Bearer"}))
+	if err != nil {
+		return nil, err
+	}
+	token, _, err := client.Apps.CreateInstallationToken(ctx, s.auth.InstallationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a token for installation %d of Github App %d: %v", s.auth.InstallationID, s.auth.AppID, err)
+	}
+	return &oauth2.Token{AccessToken: token.GetToken(), Expiry: token.GetExpiresAt()}, nil
+}
+
+// parsePrivateKey parses a PEM encoded RSA private key, in the PKCS #1 format
+// Github generates, or in the PKCS #8 format.
+func parsePrivateKey(b []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM encoded key found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("expected an RSA key, got %T", parsed)
+	}
+	return key, nil
+}
+
+// newClient creates a client of the Github API at the given base URL,
+// github.com if empty, authenticated by the tokens of the source.
+func newClient(baseURL string, ts oauth2.TokenSource) (*github.Client, error) {
+	httpClient := oauth2.NewClient(ctx, ts)
+	if baseURL == "" {
+		return github.NewClient(httpClient), nil
+	}
+	client, err := github.NewEnterpriseClient(baseURL, baseURL, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Github API URL %q: %v", baseURL, err)
+	}
+	return client, nil
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ghutil
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2/jws"
+)
+
+// fakeEnterprise is a fake Github Enterprise API, minting installation
+// tokens for the Github App with ID 7 and installation ID 42.
+type fakeEnterprise struct {
+	key *rsa.PrivateKey
+	// tokenLifetime is how long the installation tokens are valid.
+	tokenLifetime time.Duration
+
+	mu     sync.Mutex
+	minted int
+	users  []string
+}
+
+func (f *fakeEnterprise) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	switch r.URL.Path {
+	case "/api/v3/app/installations/42/access_tokens":
+		if err := jws.Verify(auth, &f.key.PublicKey); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if claims, err := jws.Decode(auth); err != nil || claims.Iss != "7" {
+			http.Error(w, "wrong app", http.StatusUnauthorized)
+			return
+		}
+		f.minted++
+		fmt.Fprintf(w, `{"token": "token-%d", "expires_at": %q}`, f.minted, time.Now().Add(f.tokenLifetime).Format(time.RFC3339))
+	case "/api/v3/user":
+		f.users = append(f.users, auth)
+		json.NewEncoder(w).Encode(map[string]string{"login": "bot"})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeTempFile(t *testing.T, dir, name string, b []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, b, 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestAppAuth(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate the key: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "ghutil")
+	if err != nil {
+		t.Fatalf("Failed to create a temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	keyPath := writeTempFile(t, dir, "app.pem", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+
+	tests := []struct {
+		name          string
+		tokenLifetime time.Duration
+		wantUsers     []string
+	}{{
+		name:          "token reused until it expires",
+		tokenLifetime: time.Hour,
+		wantUsers:     []string{"token-1", "token-1"},
+	}, {
+		name:          "expiring token refreshed",
+		tokenLifetime: time.Second,
+		wantUsers:     []string{"token-1", "token-2"},
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := &fakeEnterprise{key: key, tokenLifetime: test.tokenLifetime}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			client, err := NewGithubClientWithOptions(ClientOptions{
+				Auth:    AppAuth{AppID: 7, InstallationID: 42, PrivateKeyPath: keyPath},
+				BaseURL: server.URL + "/api/v3",
+			})
+			if err != nil {
+				t.Fatalf("NewGithubClientWithOptions() = %v", err)
+			}
+			for range test.wantUsers {
+				if _, err := client.GetGithubUser(); err != nil {
+					t.Fatalf("GetGithubUser() = %v", err)
+				}
+			}
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+			if got, want := strings.Join(fake.users, ","), strings.Join(test.wantUsers, ","); got != want {
+				t.Errorf("Tokens = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestAuthErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ghutil")
+	if err != nil {
+		t.Fatalf("Failed to create a temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	notAKey := writeTempFile(t, dir, "token", []byte("not a key\n"))
+
+	for _, opts := range []ClientOptions{
+		{},
+		{Auth: TokenAuth{TokenFilePath: filepath.Join(dir, "missing")}},
+		{Auth: AppAuth{AppID: 7, PrivateKeyPath: notAKey}},
+		{Auth: AppAuth{AppID: 7, InstallationID: 42, PrivateKeyPath: notAKey}},
+		{Auth: TokenAuth{TokenFilePath: notAKey}, BaseURL: "://bad"},
+	} {
+		if _, err := NewGithubClientWithOptions(opts); err == nil {
+			t.Errorf("NewGithubClientWithOptions(%+v) = nil, wanted an error", opts)
+		}
+	}
+}
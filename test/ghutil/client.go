@@ -20,14 +20,12 @@ package ghutil
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"strings"
 	"time"
 
 	"github.com/google/go-github/github"
-	"golang.org/x/oauth2"
 )
 
 const (
@@ -44,7 +42,10 @@ type GithubOperations interface {
 	GetGithubUser() (*github.User, error)
 	ListRepos(org string) ([]string, error)
 	ListIssuesByRepo(org, repo string, labels []string) ([]*github.Issue, error)
+	SearchIssues(query string) ([]*github.Issue, error)
 	CreateIssue(org, repo, title, body string) (*github.Issue, error)
+	CreateLabeledIssue(org, repo, title, body string, labels []string) (*github.Issue, error)
+	EditIssueBody(org, repo string, issueNumber int, body string) error
 	CloseIssue(org, repo string, issueNumber int) error
 	ReopenIssue(org, repo string, issueNumber int) error
 	ListComments(org, repo string, issueNumber int) ([]*github.IssueComment, error)
@@ -54,11 +55,13 @@ type GithubOperations interface {
 	DeleteComment(org, repo string, commentID int64) error
 	AddLabelsToIssue(org, repo string, issueNumber int, labels []string) error
 	RemoveLabelForIssue(org, repo string, issueNumber int, label string) error
+	AddAssigneesToIssue(org, repo string, issueNumber int, assignees []string) error
 	GetPullRequest(org, repo string, ID int) (*github.PullRequest, error)
 	GetPullRequestByCommitID(org, repo, commitID string) (*github.PullRequest, error)
 	EditPullRequest(org, repo string, ID int, title, body string) (*github.PullRequest, error)
 	ListPullRequests(org, repo, head, base string) ([]*github.PullRequest, error)
 	ListCommits(org, repo string, ID int) ([]*github.RepositoryCommit, error)
+	CompareCommits(org, repo, base, head string) (*github.CommitsComparison, error)
 	ListFiles(org, repo string, ID int) ([]*github.CommitFile, error)
 	CreatePullRequest(org, repo, head, base, title, body string) (*github.PullRequest, error)
 }
@@ -69,17 +72,36 @@ type GithubClient struct {
 	Client *github.Client
 }
 
+// ClientOptions configures how a GithubClient authenticates, and to which
+// Github API.
+type ClientOptions struct {
+	// Auth authenticates the client, e.g. TokenAuth or AppAuth.
+	Auth Auth
+	// BaseURL is the URL of the API of a Github Enterprise server, e.g.
+	// `https://github.example.com/api/v3/`. Defaults to github.com.
+	BaseURL string
+}
+
 // NewGithubClient explicitly authenticates to github with giving token and returns a handle
 func NewGithubClient(tokenFilePath string) (*GithubClient, error) {
-	b, err := ioutil.ReadFile(tokenFilePath)
+	return NewGithubClientWithOptions(ClientOptions{Auth: TokenAuth{TokenFilePath: tokenFilePath}})
+}
+
+// NewGithubClientWithOptions authenticates to the Github API with the given
+// options and returns a handle
+func NewGithubClientWithOptions(opts ClientOptions) (*GithubClient, error) {
+	if opts.Auth == nil {
+		return nil, errors.New("authentication cannot be empty")
+	}
+	ts, err := opts.Auth.TokenSource(opts.BaseURL)
 	if err != nil {
 		return nil, err
 	}
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: strings.TrimSpace(string(b))},
-	)
-
-	return &GithubClient{github.NewClient(oauth2.NewClient(ctx, ts))}, nil
+	client, err := newClient(opts.BaseURL, ts)
+	if err != nil {
+		return nil, err
+	}
+	return &GithubClient{client}, nil
 }
 
 // GetGithubUser gets current authenticated user
@@ -0,0 +1,73 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// error.go classifies the errors of Github calls, to decide which ones to retry
+
+package ghutil
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// IsRejectedError returns true if the Github call failed because it was
+// rejected by a rate limit, in which case it wasn't processed and can be
+// retried even if it isn't idempotent, e.g. creating an issue.
+func IsRejectedError(err error) bool {
+	switch err.(type) {
+	case *github.RateLimitError, *github.AbuseRateLimitError:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsTransientError returns true if the Github call failed transiently and is
+// worth retrying, i.e. it was rejected by a rate limit, failed with a server
+// error or timed out. The call might have been processed despite failing,
+// so only idempotent calls should be retried blindly.
+func IsTransientError(err error) bool {
+	if IsRejectedError(err) {
+		return true
+	}
+	switch err := err.(type) {
+	case *github.ErrorResponse:
+		return err.Response != nil && err.Response.StatusCode >= http.StatusInternalServerError
+	case net.Error:
+		return err.Timeout()
+	default:
+		return false
+	}
+}
+
+// RetryAfter returns how long Github asked to wait before retrying a call
+// which failed with the given error, or zero.
+func RetryAfter(err error) time.Duration {
+	switch err := err.(type) {
+	case *github.AbuseRateLimitError:
+		if err.RetryAfter != nil {
+			return *err.RetryAfter
+		}
+	case *github.RateLimitError:
+		if d := time.Until(err.Rate.Reset.Time); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -36,9 +36,11 @@ type FakeGithubClient struct {
 	PullRequests map[string]map[int]*github.PullRequest // map of repo: map of PullRequest Number: pullrequests
 	PRCommits    map[int][]*github.RepositoryCommit     // map of PR number: slice of commits
 	CommitFiles  map[string][]*github.CommitFile        // map of commit SHA: slice of files
+	Comparisons  map[string]*github.CommitsComparison   // map of "base...head": comparisons
 
-	NextNumber int    // number to be assigned to next newly created issue/comment
-	BaseURL    string // base URL of Github
+	NextNumber  int    // number to be assigned to next newly created issue/comment
+	BaseURL     string // base URL of Github
+	SearchCount int    // number of calls to SearchIssues
 }
 
 // NewFakeGithubClient creates a FakeGithubClient and initialize it's maps
@@ -49,6 +51,7 @@ func NewFakeGithubClient() *FakeGithubClient {
 		PullRequests: make(map[string]map[int]*github.PullRequest),
 		PRCommits:    make(map[int][]*github.RepositoryCommit),
 		CommitFiles:  make(map[string][]*github.CommitFile),
+		Comparisons:  make(map[string]*github.CommitsComparison),
 		BaseURL:      "fakeurl",
 	}
 }
@@ -85,6 +88,93 @@ func (fgc *FakeGithubClient) ListIssuesByRepo(org, repo string, labels []string)
 	return issues, nil
 }
 
+// SearchIssues lists the issues matching the given query. Only the `repo:`,
+// `is:`, `state:` and `label:` qualifiers and the terms in the title or body
+// of the issues are supported.
+func (fgc *FakeGithubClient) SearchIssues(query string) ([]*github.Issue, error) {
+	fgc.SearchCount++
+	var repos, labels, terms []string
+	state := ""
+	for _, token := range splitQuery(query) {
+		qualifier, value := "", token
+		if i := strings.Index(token, ":"); i > 0 && !strings.HasPrefix(token, `"`) {
+			qualifier, value = token[:i], strings.Trim(token[i+1:], `"`)
+		}
+		switch qualifier {
+		case "repo":
+			parts := strings.SplitN(value, "/", 2)
+			repos = append(repos, parts[len(parts)-1])
+		case "label":
+			labels = append(labels, value)
+		case "state":
+			state = value
+		case "is":
+			if value == "open" || value == "closed" {
+				state = value
+			}
+		case "":
+			terms = append(terms, strings.ToLower(strings.Trim(value, `"`)))
+		default:
+			return nil, fmt.Errorf("unsupported search qualifier %q", qualifier)
+		}
+	}
+	if len(repos) == 0 {
+		for repo := range fgc.Issues {
+			repos = append(repos, repo)
+		}
+	}
+
+	var issues []*github.Issue
+	for _, repo := range repos {
+		matches, _ := fgc.ListIssuesByRepo("", repo, labels)
+		for _, issue := range matches {
+			if state != "" && issue.GetState() != state {
+				continue
+			}
+			text := strings.ToLower(issue.GetTitle() + "\n" + issue.GetBody())
+			matched := true
+			for _, term := range terms {
+				if !strings.Contains(text, term) {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				issues = append(issues, issue)
+			}
+		}
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		return issues[i].GetNumber() < issues[j].GetNumber()
+	})
+	return issues, nil
+}
+
+// splitQuery splits a search query on the spaces which aren't quoted.
+func splitQuery(query string) []string {
+	var tokens []string
+	var token strings.Builder
+	quoted := false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			token.WriteRune(r)
+		case r == ' ' && !quoted:
+			if token.Len() > 0 {
+				tokens = append(tokens, token.String())
+				token.Reset()
+			}
+		default:
+			token.WriteRune(r)
+		}
+	}
+	if token.Len() > 0 {
+		tokens = append(tokens, token.String())
+	}
+	return tokens
+}
+
 // CreateIssue creates issue
 func (fgc *FakeGithubClient) CreateIssue(org, repo, title, body string) (*github.Issue, error) {
 	issueNumber := fgc.getNextNumber()
@@ -106,6 +196,25 @@ func (fgc *FakeGithubClient) CreateIssue(org, repo, title, body string) (*github
 	return newIssue, nil
 }
 
+// CreateLabeledIssue creates issue with labels
+func (fgc *FakeGithubClient) CreateLabeledIssue(org, repo, title, body string, labels []string) (*github.Issue, error) {
+	newIssue, err := fgc.CreateIssue(org, repo, title, body)
+	if err != nil {
+		return nil, err
+	}
+	return newIssue, fgc.AddLabelsToIssue(org, repo, *newIssue.Number, labels)
+}
+
+// EditIssueBody replaces the body of issue
+func (fgc *FakeGithubClient) EditIssueBody(org, repo string, issueNumber int, body string) error {
+	targetIssue := fgc.Issues[repo][issueNumber]
+	if nil == targetIssue {
+		return fmt.Errorf("cannot find issue")
+	}
+	targetIssue.Body = &body
+	return nil
+}
+
 // CloseIssue closes issue
 func (fgc *FakeGithubClient) CloseIssue(org, repo string, issueNumber int) error {
 	return fgc.updateIssueState(org, repo, ghutil.IssueCloseState, issueNumber)
@@ -116,12 +225,15 @@ func (fgc *FakeGithubClient) ReopenIssue(org, repo string, issueNumber int) erro
 	return fgc.updateIssueState(org, repo, ghutil.IssueOpenState, issueNumber)
 }
 
-// ListComments gets all comments from issue
+// ListComments gets all comments from issue, in the order they were created
 func (fgc *FakeGithubClient) ListComments(org, repo string, issueNumber int) ([]*github.IssueComment, error) {
 	var comments []*github.IssueComment
 	for _, comment := range fgc.Comments[issueNumber] {
 		comments = append(comments, comment)
 	}
+	sort.Slice(comments, func(i, j int) bool {
+		return comments[i].GetID() < comments[j].GetID()
+	})
 	return comments, nil
 }
 
@@ -204,6 +316,21 @@ func (fgc *FakeGithubClient) RemoveLabelForIssue(org, repo string, issueNumber i
 	return nil
 }
 
+// AddAssigneesToIssue assigns the given users to issue
+func (fgc *FakeGithubClient) AddAssigneesToIssue(org, repo string, issueNumber int, assignees []string) error {
+	targetIssue := fgc.Issues[repo][issueNumber]
+	if nil == targetIssue {
+		return fmt.Errorf("cannot find issue")
+	}
+	for _, assignee := range assignees {
+		login := assignee
+		targetIssue.Assignees = append(targetIssue.Assignees, &github.User{
+			Login: &login,
+		})
+	}
+	return nil
+}
+
 // ListPullRequests lists pull requests within given repo, filters by head user and branch name if
 // provided as "user:ref-name", and by base name if provided, i.e. "master"
 func (fgc *FakeGithubClient) ListPullRequests(org, repo, head, base string) ([]*github.PullRequest, error) {
@@ -235,6 +362,15 @@ func (fgc *FakeGithubClient) ListCommits(org, repo string, ID int) ([]*github.Re
 	return commits, nil
 }
 
+// CompareCommits compares the base commit of a repo with the head one
+func (fgc *FakeGithubClient) CompareCommits(org, repo, base, head string) (*github.CommitsComparison, error) {
+	comparison, ok := fgc.Comparisons[base+"..."+head]
+	if !ok {
+		return nil, fmt.Errorf("no comparison found for '%s...%s'", base, head)
+	}
+	return comparison, nil
+}
+
 // ListFiles lists files from a pull request
 func (fgc *FakeGithubClient) ListFiles(org, repo string, ID int) ([]*github.CommitFile, error) {
 	var res []*github.CommitFile
@@ -269,7 +405,7 @@ func (fgc *FakeGithubClient) GetPullRequestByCommitID(org, repo string, commitID
 	for prNum, commits := range fgc.PRCommits {
 		for _, commit := range commits {
 			if commit.GetSHA() == commitID {
-				if pullRequest, err := fgc.GetPullRequest(org, repo, prNum); err != nil {
+				if pullRequest, err := fgc.GetPullRequest(org, repo, prNum); err == nil {
 					res = append(res, pullRequest)
 				}
 			}
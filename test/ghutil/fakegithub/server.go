@@ -0,0 +1,585 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fakegithub runs an in-process fake of the Github API, serving the
+// issues, comments, labels, assignees and issue search, so that the code using
+// ghutil.GithubClient can be tested end to end without Github. The requests
+// are recorded, and the responses to some of them can be scripted, e.g. to
+// inject failures.
+package fakegithub
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// apiPrefix is the path of the API, like on Github Enterprise.
+const apiPrefix = "/api/v3"
+
+// Request is a request received by the Server.
+type Request struct {
+	Method string `json:"method"`
+	// Path is the path of the request, relative to the API URL, e.g.
+	// `/repos/knative/pkg/issues`.
+	Path  string `json:"path"`
+	Query string `json:"query,omitempty"`
+	Body  string `json:"body,omitempty"`
+}
+
+// Step scripts the response of the Server to the requests matching its method
+// and path, instead of serving them.
+type Step struct {
+	// Method is the method of the matching requests, any if empty.
+	Method string
+	// Path is the pattern of the paths of the matching requests, relative to
+	// the API URL, as matched by path.Match, e.g. `/repos/*/*/issues`.
+	Path string
+	// Times is the number of requests the step responds to, 1 if not positive.
+	Times int
+	// Process processes the request before responding with the step, e.g.
+	// to simulate a response lost after the request succeeded.
+	Process bool
+
+	// Status is the status code of the response.
+	Status int
+	// Header are the headers of the response.
+	Header http.Header
+	// Body is the body of the response.
+	Body string
+}
+
+// ServerError returns a step failing the matching request with a 502 error.
+func ServerError(method, pattern string) Step {
+	return Step{Method: method, Path: pattern, Status: http.StatusBadGateway, Body: `{"message": "Server Error"}`}
+}
+
+// AbuseRateLimited returns a step rejecting the matching request with the
+// abuse rate limit of Github, asking to retry after the given seconds.
+func AbuseRateLimited(method, pattern string, retryAfter int) Step {
+	return Step{
+		Method: method,
+		Path:   pattern,
+		Status: http.StatusForbidden,
+		Header: http.Header{"Retry-After": []string{strconv.Itoa(retryAfter)}},
+		Body:   `{"message": "You have triggered an abuse detection mechanism.", "documentation_url": "https://developer.github.com/v3/#abuse-rate-limits"}`,
+	}
+}
+
+// issueComment is a comment along with the issue it belongs to.
+type issueComment struct {
+	repo    string
+	number  int
+	comment *github.IssueComment
+}
+
+// Server is a fake Github API. Its issues and comments are numbered in a
+// single sequence starting at 1, and only the first page of results is ever
+// served.
+type Server struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	user     string
+	issues   map[string][]*github.Issue
+	comments []*issueComment
+	next     int
+	requests []Request
+	script   []*Step
+}
+
+// NewServer starts a Server, authenticated as the given user. It must be closed
+// once done.
+func NewServer(user string) *Server {
+	s := &Server{user: user, issues: make(map[string][]*github.Issue)}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Close shuts the Server down.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// URL returns the URL of the API, to use as the base URL of the clients, e.g.
+// ghutil.ClientOptions.BaseURL.
+func (s *Server) URL() string {
+	return s.server.URL + apiPrefix + "/"
+}
+
+// Script adds the given steps to the script of the Server. The first step
+// matching a request responds to it, and is dropped once it has responded as
+// many times as configured.
+func (s *Server) Script(steps ...Step) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range steps {
+		step := steps[i]
+		if step.Times <= 0 {
+			step.Times = 1
+		}
+		s.script = append(s.script, &step)
+	}
+}
+
+// Requests returns the requests received so far, in order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// AddIssue adds a copy of the given issue to the repository, e.g. to start with
+// the issues of a scenario, and returns its number. The number, state and
+// creation and update times of the issue default like for a new issue.
+func (s *Server) AddIssue(org, repo string, issue github.Issue) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.next++
+	issue.Number = github.Int(s.next)
+	if issue.State == nil {
+		issue.State = github.String("open")
+	}
+	if issue.CreatedAt == nil {
+		issue.CreatedAt = &now
+	}
+	if issue.UpdatedAt == nil {
+		issue.UpdatedAt = &now
+	}
+	key := org + "/" + repo
+	s.issues[key] = append(s.issues[key], &issue)
+	return s.next
+}
+
+// Issues returns copies of the issues of the repository, by number.
+func (s *Server) Issues(org, repo string) []github.Issue {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var issues []github.Issue
+	for _, issue := range s.issues[org+"/"+repo] {
+		issues = append(issues, *issue)
+	}
+	return issues
+}
+
+// Comments returns copies of the comments of the issue, in order.
+func (s *Server) Comments(org, repo string, number int) []github.IssueComment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var comments []github.IssueComment
+	for _, c := range s.comments {
+		if c.repo == org+"/"+repo && c.number == number {
+			comments = append(comments, *c.comment)
+		}
+	}
+	return comments
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := strings.TrimPrefix(r.URL.Path, apiPrefix)
+	s.requests = append(s.requests, Request{Method: r.Method, Path: p, Query: r.URL.RawQuery, Body: string(body)})
+
+	step := s.scripted(r.Method, p)
+	if step == nil || step.Process {
+		status, resp := s.handle(r.Method, p, r.URL.Query(), body)
+		if step == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			if resp != nil {
+				json.NewEncoder(w).Encode(resp)
+			}
+			return
+		}
+	}
+	for k, v := range step.Header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(step.Status)
+	fmt.Fprint(w, step.Body)
+}
+
+// scripted returns the step responding to the request, if any.
+func (s *Server) scripted(method, p string) *Step {
+	for i, step := range s.script {
+		if step.Method != "" && step.Method != method {
+			continue
+		}
+		if ok, _ := path.Match(step.Path, p); !ok {
+			continue
+		}
+		step.Times--
+		if step.Times == 0 {
+			s.script = append(s.script[:i], s.script[i+1:]...)
+		}
+		return step
+	}
+	return nil
+}
+
+// errorResponse is the body of the errors of the Github API.
+type errorResponse struct {
+	Message string `json:"message"`
+}
+
+func notFound() (int, interface{}) {
+	return http.StatusNotFound, errorResponse{Message: "Not Found"}
+}
+
+func badRequest(err error) (int, interface{}) {
+	return http.StatusBadRequest, errorResponse{Message: err.Error()}
+}
+
+// handle serves the request, returning the status and the body of the response.
+func (s *Server) handle(method, p string, query map[string][]string, body []byte) (int, interface{}) {
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	switch {
+	case method == http.MethodGet && p == "/user":
+		return http.StatusOK, &github.User{Login: github.String(s.user)}
+	case method == http.MethodGet && p == "/search/issues":
+		issues := s.search(firstValue(query, "q"))
+		return http.StatusOK, &github.IssuesSearchResult{
+			Total:             github.Int(len(issues)),
+			IncompleteResults: github.Bool(false),
+			Issues:            issues,
+		}
+	case len(parts) < 4 || parts[0] != "repos" || parts[3] != "issues":
+		return notFound()
+	}
+
+	repo := parts[1] + "/" + parts[2]
+	parts = parts[4:]
+	switch {
+	case len(parts) == 0 && method == http.MethodGet:
+		return http.StatusOK, s.list(repo, firstValue(query, "state"), splitLabels(firstValue(query, "labels")))
+	case len(parts) == 0 && method == http.MethodPost:
+		var req github.IssueRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return badRequest(err)
+		}
+		return http.StatusCreated, s.createIssue(repo, req)
+	case len(parts) == 2 && parts[0] == "comments":
+		return s.handleComment(method, repo, parts[1], body)
+	}
+
+	number, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return notFound()
+	}
+	issue := s.issue(repo, number)
+	if issue == nil {
+		return notFound()
+	}
+	switch {
+	case len(parts) == 1 && method == http.MethodGet:
+		return http.StatusOK, issue
+	case len(parts) == 1 && method == http.MethodPatch:
+		var req github.IssueRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return badRequest(err)
+		}
+		editIssue(issue, req)
+		return http.StatusOK, issue
+	case len(parts) == 2 && parts[1] == "comments" && method == http.MethodGet:
+		comments := []*github.IssueComment{}
+		for _, c := range s.comments {
+			if c.repo == repo && c.number == number {
+				comments = append(comments, c.comment)
+			}
+		}
+		return http.StatusOK, comments
+	case len(parts) == 2 && parts[1] == "comments" && method == http.MethodPost:
+		var req github.IssueComment
+		if err := json.Unmarshal(body, &req); err != nil {
+			return badRequest(err)
+		}
+		return http.StatusCreated, s.addComment(repo, issue, req.GetBody())
+	case len(parts) == 2 && parts[1] == "labels" && method == http.MethodPost:
+		var labels []string
+		if err := json.Unmarshal(body, &labels); err != nil {
+			return badRequest(err)
+		}
+		for _, label := range labels {
+			if !hasLabel(issue, label) {
+				issue.Labels = append(issue.Labels, github.Label{Name: github.String(label)})
+			}
+		}
+		touch(issue)
+		return http.StatusOK, issue.Labels
+	case len(parts) == 3 && parts[1] == "labels" && method == http.MethodDelete:
+		for i, label := range issue.Labels {
+			if label.GetName() == parts[2] {
+				issue.Labels = append(issue.Labels[:i], issue.Labels[i+1:]...)
+				touch(issue)
+				return http.StatusOK, issue.Labels
+			}
+		}
+		return notFound()
+	case len(parts) == 2 && parts[1] == "assignees" && method == http.MethodPost:
+		var req struct {
+			Assignees []string `json:"assignees"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			return badRequest(err)
+		}
+		for _, assignee := range req.Assignees {
+			issue.Assignees = append(issue.Assignees, &github.User{Login: github.String(assignee)})
+		}
+		touch(issue)
+		return http.StatusCreated, issue
+	}
+	return notFound()
+}
+
+// handleComment serves the requests on a comment by ID.
+func (s *Server) handleComment(method, repo, id string, body []byte) (int, interface{}) {
+	for i, c := range s.comments {
+		if c.repo != repo || strconv.FormatInt(c.comment.GetID(), 10) != id {
+			continue
+		}
+		switch method {
+		case http.MethodGet:
+			return http.StatusOK, c.comment
+		case http.MethodPatch:
+			var req github.IssueComment
+			if err := json.Unmarshal(body, &req); err != nil {
+				return badRequest(err)
+			}
+			now := time.Now()
+			c.comment.Body = req.Body
+			c.comment.UpdatedAt = &now
+			return http.StatusOK, c.comment
+		case http.MethodDelete:
+			s.comments = append(s.comments[:i], s.comments[i+1:]...)
+			return http.StatusNoContent, nil
+		}
+	}
+	return notFound()
+}
+
+func (s *Server) issue(repo string, number int) *github.Issue {
+	for _, issue := range s.issues[repo] {
+		if issue.GetNumber() == number {
+			return issue
+		}
+	}
+	return nil
+}
+
+func (s *Server) createIssue(repo string, req github.IssueRequest) *github.Issue {
+	now := time.Now()
+	s.next++
+	issue := &github.Issue{
+		Number:    github.Int(s.next),
+		Title:     req.Title,
+		Body:      req.Body,
+		State:     github.String("open"),
+		User:      &github.User{Login: github.String(s.user)},
+		CreatedAt: &now,
+		UpdatedAt: &now,
+	}
+	if req.Labels != nil {
+		for _, label := range *req.Labels {
+			issue.Labels = append(issue.Labels, github.Label{Name: github.String(label)})
+		}
+	}
+	if req.Assignees != nil {
+		for _, assignee := range *req.Assignees {
+			issue.Assignees = append(issue.Assignees, &github.User{Login: github.String(assignee)})
+		}
+	}
+	s.issues[repo] = append(s.issues[repo], issue)
+	return issue
+}
+
+func editIssue(issue *github.Issue, req github.IssueRequest) {
+	if req.Title != nil {
+		issue.Title = req.Title
+	}
+	if req.Body != nil {
+		issue.Body = req.Body
+	}
+	if req.State != nil {
+		issue.State = req.State
+		if req.GetState() == "closed" {
+			now := time.Now()
+			issue.ClosedAt = &now
+		}
+	}
+	touch(issue)
+}
+
+func (s *Server) addComment(repo string, issue *github.Issue, body string) *github.IssueComment {
+	now := time.Now()
+	s.next++
+	comment := &github.IssueComment{
+		ID:        github.Int64(int64(s.next)),
+		Body:      &body,
+		User:      &github.User{Login: github.String(s.user)},
+		CreatedAt: &now,
+		UpdatedAt: &now,
+	}
+	s.comments = append(s.comments, &issueComment{repo: repo, number: issue.GetNumber(), comment: comment})
+	issue.Comments = github.Int(issue.GetComments() + 1)
+	touch(issue)
+	return comment
+}
+
+// touch marks the issue as updated, like Github does on every change.
+func touch(issue *github.Issue) {
+	now := time.Now()
+	issue.UpdatedAt = &now
+}
+
+// list returns the issues of the repository in the given state, `open` if
+// empty, with all of the given labels.
+func (s *Server) list(repo, state string, labels []string) []*github.Issue {
+	if state == "" {
+		state = "open"
+	}
+	issues := []*github.Issue{}
+	for _, issue := range s.issues[repo] {
+		if (state == "all" || issue.GetState() == state) && hasLabels(issue, labels) {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// search returns the issues matching the query. Only the `repo:`, `is:`,
+// `state:` and `label:` qualifiers and the terms in the title or body of the
+// issues are supported.
+func (s *Server) search(query string) []github.Issue {
+	var repos, labels, terms []string
+	state := ""
+	for _, token := range splitQuery(query) {
+		qualifier, value := "", strings.Trim(token, `"`)
+		if i := strings.Index(token, ":"); i > 0 && !strings.HasPrefix(token, `"`) {
+			qualifier, value = token[:i], strings.Trim(token[i+1:], `"`)
+		}
+		switch qualifier {
+		case "repo":
+			repos = append(repos, value)
+		case "label":
+			labels = append(labels, value)
+		case "state":
+			state = value
+		case "is":
+			if value == "open" || value == "closed" {
+				state = value
+			}
+		default:
+			terms = append(terms, strings.ToLower(value))
+		}
+	}
+	if len(repos) == 0 {
+		for repo := range s.issues {
+			repos = append(repos, repo)
+		}
+	}
+
+	issues := []github.Issue{}
+	for _, repo := range repos {
+		for _, issue := range s.issues[repo] {
+			if (state != "" && issue.GetState() != state) || !hasLabels(issue, labels) {
+				continue
+			}
+			text := strings.ToLower(issue.GetTitle() + "\n" + issue.GetBody())
+			matched := true
+			for _, term := range terms {
+				matched = matched && strings.Contains(text, term)
+			}
+			if matched {
+				issues = append(issues, *issue)
+			}
+		}
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		return issues[i].GetNumber() < issues[j].GetNumber()
+	})
+	return issues
+}
+
+func hasLabel(issue *github.Issue, name string) bool {
+	for _, label := range issue.Labels {
+		if label.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasLabels(issue *github.Issue, names []string) bool {
+	for _, name := range names {
+		if !hasLabel(issue, name) {
+			return false
+		}
+	}
+	return true
+}
+
+func firstValue(query map[string][]string, key string) string {
+	if values := query[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func splitLabels(labels string) []string {
+	if labels == "" {
+		return nil
+	}
+	return strings.Split(labels, ",")
+}
+
+// splitQuery splits a search query on the spaces which aren't quoted.
+func splitQuery(query string) []string {
+	var tokens []string
+	var token strings.Builder
+	quoted := false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			token.WriteRune(r)
+		case r == ' ' && !quoted:
+			if token.Len() > 0 {
+				tokens = append(tokens, token.String())
+				token.Reset()
+			}
+		default:
+			token.WriteRune(r)
+		}
+	}
+	if token.Len() > 0 {
+		tokens = append(tokens, token.String())
+	}
+	return tokens
+}
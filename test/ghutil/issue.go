@@ -20,6 +20,7 @@ package ghutil
 
 import (
 	"fmt"
+	"log"
 
 	"github.com/google/go-github/github"
 )
@@ -92,12 +93,51 @@ func (gc *GithubClient) ListIssuesByRepo(org, repo string, labels []string) ([]*
 	return res, err
 }
 
+// SearchIssues lists the issues and pull requests matching the given query of the
+// Github search syntax, e.g. `repo:knative/pkg is:issue label:"auto:perf"`.
+// The search API returns at most 1000 results.
+func (gc *GithubClient) SearchIssues(query string) ([]*github.Issue, error) {
+	searchOptions := &github.SearchOptions{}
+	genericList, err := gc.depaginate(
+		fmt.Sprintf("searching issues with query '%s'", query),
+		maxRetryCount,
+		&searchOptions.ListOptions,
+		func() ([]interface{}, *github.Response, error) {
+			result, resp, err := gc.Client.Search.Issues(ctx, query, searchOptions)
+			var interfaceList []interface{}
+			if nil == err {
+				if result.GetIncompleteResults() {
+					log.Printf("The results of searching issues with query '%s' are incomplete", query)
+				}
+				for i := range result.Issues {
+					interfaceList = append(interfaceList, &result.Issues[i])
+				}
+			}
+			return interfaceList, resp, err
+		},
+	)
+	res := make([]*github.Issue, len(genericList))
+	for i, elem := range genericList {
+		res[i] = elem.(*github.Issue)
+	}
+	return res, err
+}
+
 // CreateIssue creates issue
 func (gc *GithubClient) CreateIssue(org, repo, title, body string) (*github.Issue, error) {
+	return gc.CreateLabeledIssue(org, repo, title, body, nil)
+}
+
+// CreateLabeledIssue creates issue with the given labels. Unlike adding the
+// labels once the issue is created, it can't leave the issue unlabeled.
+func (gc *GithubClient) CreateLabeledIssue(org, repo, title, body string, labels []string) (*github.Issue, error) {
 	issue := &github.IssueRequest{
 		Title: &title,
 		Body:  &body,
 	}
+	if len(labels) > 0 {
+		issue.Labels = &labels
+	}
 
 	var res *github.Issue
 	_, err := gc.retry(
@@ -113,6 +153,22 @@ func (gc *GithubClient) CreateIssue(org, repo, title, body string) (*github.Issu
 	return res, err
 }
 
+// EditIssueBody replaces the body of issue
+func (gc *GithubClient) EditIssueBody(org, repo string, issueNumber int, body string) error {
+	issueRequest := &github.IssueRequest{
+		Body: &body,
+	}
+	_, err := gc.retry(
+		fmt.Sprintf("editing body of issue '%s %s %d'", org, repo, issueNumber),
+		maxRetryCount,
+		func() (*github.Response, error) {
+			_, resp, err := gc.Client.Issues.Edit(ctx, org, repo, issueNumber, issueRequest)
+			return resp, err
+		},
+	)
+	return err
+}
+
 // CloseIssue closes issue
 func (gc *GithubClient) CloseIssue(org, repo string, issueNumber int) error {
 	return gc.updateIssueState(org, repo, IssueCloseState, issueNumber)
@@ -237,6 +293,19 @@ func (gc *GithubClient) RemoveLabelForIssue(org, repo string, issueNumber int, l
 	return err
 }
 
+// AddAssigneesToIssue assigns the given users to issue
+func (gc *GithubClient) AddAssigneesToIssue(org, repo string, issueNumber int, assignees []string) error {
+	_, err := gc.retry(
+		fmt.Sprintf("add assignees '%v' to '%s %s %d'", assignees, org, repo, issueNumber),
+		maxRetryCount,
+		func() (*github.Response, error) {
+			_, resp, err := gc.Client.Issues.AddAssignees(ctx, org, repo, issueNumber, assignees)
+			return resp, err
+		},
+	)
+	return err
+}
+
 func (gc *GithubClient) updateIssueState(org, repo string, state IssueStateEnum, issueNumber int) error {
 	stateString := string(state)
 	issueRequest := &github.IssueRequest{
@@ -92,6 +92,23 @@ func (gc *GithubClient) ListCommits(org, repo string, ID int) ([]*github.Reposit
 	return res, err
 }
 
+// CompareCommits compares the base commit of a repo with the head one, e.g. to
+// list the commits in between. Github lists up to 250 commits.
+func (gc *GithubClient) CompareCommits(org, repo, base, head string) (*github.CommitsComparison, error) {
+	var res *github.CommitsComparison
+	_, err := gc.retry(
+		fmt.Sprintf("comparing commits '%s...%s'", base, head),
+		maxRetryCount,
+		func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			res, resp, err = gc.Client.Repositories.CompareCommits(ctx, org, repo, base, head)
+			return resp, err
+		},
+	)
+	return res, err
+}
+
 // ListFiles lists files from a pull request
 func (gc *GithubClient) ListFiles(org, repo string, ID int) ([]*github.CommitFile, error) {
 	options := &github.ListOptions{}
@@ -0,0 +1,94 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// retry.go helps with retrying calls failing transiently
+
+package helpers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// RetryPolicy defines which failed calls are retried and how.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a call is made, including
+	// the first attempt. Zero or one disables the retries.
+	MaxAttempts int
+
+	// Backoff defines the time to wait between two attempts, growing
+	// exponentially by its Factor with some Jitter up to its Cap. Its Steps
+	// field is ignored in favor of MaxAttempts.
+	Backoff wait.Backoff
+
+	// Retryable decides whether a call that failed with the given error is
+	// retried. If nil, no errors are retried.
+	Retryable func(error) bool
+
+	// RetryAfter returns the minimum time to wait before retrying a call
+	// that failed with the given error, e.g. as requested by the server, or
+	// zero. It can be nil.
+	RetryAfter func(error) time.Duration
+}
+
+// DefaultRetryPolicy returns a RetryPolicy retrying the calls failing with the
+// given retryable errors up to 5 times, waiting from 1 second up to 30 seconds.
+func DefaultRetryPolicy(retryable func(error) bool) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		Backoff: wait.Backoff{
+			Duration: time.Second,
+			Factor:   2,
+			Jitter:   0.5,
+			Cap:      30 * time.Second,
+		},
+		Retryable: retryable,
+	}
+}
+
+// RunWithRetry is like Run, but retries the call according to the given policy
+// until it succeeds, fails with an error which isn't retryable, runs out of
+// attempts or the context is done. The call is given the number of the
+// attempt, starting at 1, so that it can check whether a previous attempt
+// succeeded despite failing, to not repeat operations which aren't idempotent.
+func RunWithRetry(ctx context.Context, message string, call func(attempt int) error, dryrun bool, policy RetryPolicy) error {
+	return Run(message, func() error {
+		backoff := policy.Backoff
+		backoff.Steps = policy.MaxAttempts
+		for attempt := 1; ; attempt++ {
+			err := call(attempt)
+			if err == nil || attempt >= policy.MaxAttempts || policy.Retryable == nil || !policy.Retryable(err) {
+				return err
+			}
+
+			wait := backoff.Step()
+			if policy.RetryAfter != nil {
+				if after := policy.RetryAfter(err); after > wait {
+					wait = after
+				}
+			}
+			log.Printf("%s failed: %v, retrying in %v", message, err, wait)
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}, dryrun)
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helpers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+var (
+	errTransient = errors.New("transient")
+	errFatal     = errors.New("fatal")
+)
+
+func testRetryPolicy() RetryPolicy {
+	p := DefaultRetryPolicy(func(err error) bool { return err == errTransient })
+	p.Backoff = wait.Backoff{Duration: time.Millisecond, Factor: 2}
+	return p
+}
+
+func TestRunWithRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		errs         []error
+		dryrun       bool
+		wantErr      error
+		wantAttempts int
+	}{{
+		name:         "succeeds at once",
+		wantAttempts: 1,
+	}, {
+		name:         "succeeds after transient errors",
+		errs:         []error{errTransient, errTransient},
+		wantAttempts: 3,
+	}, {
+		name:         "fatal error",
+		errs:         []error{errTransient, errFatal},
+		wantErr:      errFatal,
+		wantAttempts: 2,
+	}, {
+		name:         "out of attempts",
+		errs:         []error{errTransient, errTransient, errTransient, errTransient, errTransient, errTransient},
+		wantErr:      errTransient,
+		wantAttempts: 5,
+	}, {
+		name:   "dry run",
+		errs:   []error{errFatal},
+		dryrun: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attempts := 0
+			err := RunWithRetry(context.Background(), test.name, func(attempt int) error {
+				attempts++
+				if attempt != attempts {
+					t.Errorf("attempt = %d, want %d", attempt, attempts)
+				}
+				if attempt <= len(test.errs) {
+					return test.errs[attempt-1]
+				}
+				return nil
+			}, test.dryrun, testRetryPolicy())
+			if err != test.wantErr {
+				t.Errorf("RunWithRetry() = %v, want %v", err, test.wantErr)
+			}
+			if attempts != test.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, test.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRunWithRetryContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := testRetryPolicy()
+	policy.RetryAfter = func(error) time.Duration { return time.Hour }
+	attempts := 0
+	err := RunWithRetry(ctx, "canceled", func(int) error {
+		attempts++
+		cancel()
+		return errTransient
+	}, false, policy)
+	if err != context.Canceled {
+		t.Errorf("RunWithRetry() = %v, want %v", err, context.Canceled)
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1", attempts)
+	}
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// This file contains helpers to resolve the references of test images.
+
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"runtime"
+	"strings"
+	"text/template"
+)
+
+const (
+	// ImageTemplateEnvKey is the environment variable holding the template
+	// of image references, see ImageData.
+	ImageTemplateEnvKey = "TEST_IMAGE_TEMPLATE"
+	// ImageDigestsEnvKey is the environment variable holding the path to a
+	// JSON file mapping image names to the digests pinning them. Names
+	// suffixed with "/<arch>" map the digests of specific architectures.
+	ImageDigestsEnvKey = "TEST_IMAGE_DIGESTS"
+	// ImageArchEnvKey is the environment variable holding the architecture
+	// of the cluster's nodes. Defaults to the architecture the tests run on.
+	ImageArchEnvKey = "TEST_IMAGE_ARCH"
+
+	// DefaultImageTemplate is the template used if none is given.
+	DefaultImageTemplate = "{{.Repository}}/{{.Name}}{{if .Digest}}@{{.Digest}}{{else}}:{{.Tag}}{{end}}"
+
+	koPrefix = "ko://"
+)
+
+var digestRE = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// ImageData is the data the image template is executed with.
+type ImageData struct {
+	// Repository is the repository of the test images, see --dockerrepo.
+	Repository string
+	// Name is the image's name.
+	Name string
+	// Tag is the tag of the test images, see --tag.
+	Tag string
+	// Arch is the architecture of the cluster's nodes.
+	Arch string
+	// Digest is the digest pinning the image, if any.
+	Digest string
+}
+
+// ImageResolver resolves the references of test images.
+type ImageResolver struct {
+	Template   *template.Template
+	Repository string
+	Tag        string
+	Arch       string
+	// Digests maps image names, optionally suffixed with "/<arch>", to
+	// the digests pinning them.
+	Digests map[string]string
+}
+
+// NewImageResolver returns an ImageResolver configured by the --dockerrepo
+// and --tag flags, and the TEST_IMAGE_* environment variables.
+func NewImageResolver() (*ImageResolver, error) {
+	text := os.Getenv(ImageTemplateEnvKey)
+	if text == "" {
+		text = DefaultImageTemplate
+	}
+	tmpl, err := template.New("image").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", ImageTemplateEnvKey, err)
+	}
+
+	arch := os.Getenv(ImageArchEnvKey)
+	if arch == "" {
+		arch = runtime.GOARCH
+	}
+
+	r := &ImageResolver{
+		Template:   tmpl,
+		Repository: Flags.DockerRepo,
+		Tag:        Flags.Tag,
+		Arch:       arch,
+	}
+	if p := os.Getenv(ImageDigestsEnvKey); p != "" {
+		if r.Digests, err = readDigests(p); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// readDigests reads and validates the digests file at the given path.
+func readDigests(p string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the image digests: %v", err)
+	}
+	var digests map[string]string
+	if err := json.Unmarshal(b, &digests); err != nil {
+		return nil, fmt.Errorf("failed to parse the image digests in %s: %v", p, err)
+	}
+	for name, digest := range digests {
+		if !digestRE.MatchString(digest) {
+			return nil, fmt.Errorf("invalid digest %q of image %s in %s", digest, name, p)
+		}
+	}
+	return digests, nil
+}
+
+// Resolve returns the reference of the given test image. The image is given
+// either by its name, or as ko://<import path>, in which case its name is the
+// last element of the import path. Other references, e.g. ones including a
+// registry, are returned unchanged.
+func (r *ImageResolver) Resolve(image string) (string, error) {
+	name := image
+	if strings.HasPrefix(image, koPrefix) {
+		name = path.Base(strings.TrimPrefix(image, koPrefix))
+	} else if strings.ContainsAny(image, "/:@") {
+		return image, nil
+	}
+
+	data := ImageData{
+		Repository: r.Repository,
+		Name:       name,
+		Tag:        r.Tag,
+		Arch:       r.Arch,
+		Digest:     r.digest(name),
+	}
+	var sb strings.Builder
+	if err := r.Template.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to resolve image %s: %v", image, err)
+	}
+	return sb.String(), nil
+}
+
+// digest returns the digest pinning the image, preferring the one of the
+// resolver's architecture.
+func (r *ImageResolver) digest(name string) string {
+	if d, ok := r.Digests[name+"/"+r.Arch]; ok {
+		return d
+	}
+	return r.Digests[name]
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+var (
+	amd64Digest = "sha256:" + strings.Repeat("a", 64)
+	arm64Digest = "sha256:" + strings.Repeat("b", 64)
+)
+
+func TestResolveImage(t *testing.T) {
+	r := &ImageResolver{
+		Template:   template.Must(template.New("image").Parse(DefaultImageTemplate)),
+		Repository: "registry.example.com/knative",
+		Tag:        "e2e",
+		Arch:       "arm64",
+		Digests: map[string]string{
+			"pinned":      amd64Digest,
+			"multi":       amd64Digest,
+			"multi/arm64": arm64Digest,
+		},
+	}
+
+	tests := []struct {
+		image string
+		want  string
+	}{{
+		image: "helloworld",
+		want:  "registry.example.com/knative/helloworld:e2e",
+	}, {
+		image: "ko://knative.dev/serving/test/test_images/helloworld",
+		want:  "registry.example.com/knative/helloworld:e2e",
+	}, {
+		image: "pinned",
+		want:  "registry.example.com/knative/pinned@" + amd64Digest,
+	}, {
+		image: "multi",
+		want:  "registry.example.com/knative/multi@" + arm64Digest,
+	}, {
+		image: "gcr.io/other/image:v1",
+		want:  "gcr.io/other/image:v1",
+	}}
+
+	for _, test := range tests {
+		got, err := r.Resolve(test.image)
+		if err != nil {
+			t.Errorf("Resolve(%s) = %v", test.image, err)
+		} else if got != test.want {
+			t.Errorf("Resolve(%s) = %s, want %s", test.image, got, test.want)
+		}
+	}
+}
+
+func TestNewImageResolver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "images")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	digests := filepath.Join(dir, "digests.json")
+	if err := ioutil.WriteFile(digests, []byte(`{"helloworld": "`+amd64Digest+`"}`), 0644); err != nil {
+		t.Fatalf("WriteFile() = %v", err)
+	}
+
+	for k, v := range map[string]string{
+		ImageTemplateEnvKey: "mirror.local/{{.Arch}}/{{.Name}}{{if .Digest}}@{{.Digest}}{{end}}",
+		ImageDigestsEnvKey:  digests,
+		ImageArchEnvKey:     "s390x",
+	} {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	r, err := NewImageResolver()
+	if err != nil {
+		t.Fatalf("NewImageResolver() = %v", err)
+	}
+	got, err := r.Resolve("helloworld")
+	if err != nil {
+		t.Fatalf("Resolve() = %v", err)
+	}
+	if want := "mirror.local/s390x/helloworld@" + amd64Digest; got != want {
+		t.Errorf("Resolve() = %s, want %s", got, want)
+	}
+}
+
+func TestReadDigestsInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "images")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range map[string]string{
+		"malformed": `{"helloworld": `,
+		"digest":    `{"helloworld": "latest"}`,
+	} {
+		p := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile() = %v", err)
+		}
+		if _, err := readDigests(p); err == nil {
+			t.Errorf("readDigests(%s) = nil, wanted an error", name)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package junit lets tests and benchmark harnesses emit structured results,
+// as JUnit XML consumed by Testgrid and as a JSON summary for custom
+// dashboards, into the artifacts directory.
+package junit
+
+import (
+	"encoding/xml"
+	"sort"
+)
+
+// TestSuites is the root element of a JUnit XML file.
+type TestSuites struct {
+	XMLName xml.Name    `xml:"testsuites"`
+	Suites  []TestSuite `xml:"testsuite"`
+}
+
+// TestSuite is a JUnit test suite.
+type TestSuite struct {
+	XMLName    xml.Name    `xml:"testsuite"`
+	Name       string      `xml:"name,attr"`
+	Tests      int         `xml:"tests,attr"`
+	Failures   int         `xml:"failures,attr"`
+	Skipped    int         `xml:"skipped,attr"`
+	Time       float64     `xml:"time,attr"`
+	Properties *Properties `xml:"properties,omitempty"`
+	TestCases  []TestCase  `xml:"testcase"`
+}
+
+// TestCase is a JUnit test case.
+type TestCase struct {
+	ClassName  string      `xml:"classname,attr"`
+	Name       string      `xml:"name,attr"`
+	Time       float64     `xml:"time,attr"`
+	Failure    *Failure    `xml:"failure,omitempty"`
+	Skipped    *Skipped    `xml:"skipped,omitempty"`
+	Properties *Properties `xml:"properties,omitempty"`
+}
+
+// Failure describes why a test case failed.
+type Failure struct {
+	Message string `xml:"message,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// Skipped describes why a test case was skipped.
+type Skipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// Properties hold custom properties of a test suite or case.
+type Properties struct {
+	Properties []Property `xml:"property"`
+}
+
+// Property is a custom property.
+type Property struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// newProperties returns the properties of the given map, sorted by name,
+// or nil if there are none.
+func newProperties(m map[string]string) *Properties {
+	if len(m) == 0 {
+		return nil
+	}
+	ps := &Properties{Properties: make([]Property, 0, len(m))}
+	for k, v := range m {
+		ps.Properties = append(ps.Properties, Property{Name: k, Value: v})
+	}
+	sort.Slice(ps.Properties, func(i, j int) bool {
+		return ps.Properties[i].Name < ps.Properties[j].Name
+	})
+	return ps
+}
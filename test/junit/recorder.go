@@ -0,0 +1,227 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package junit
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"knative.dev/pkg/test"
+	"knative.dev/pkg/test/prow"
+)
+
+// Status is the outcome of a test case.
+type Status string
+
+const (
+	// StatusPassed means the test case passed.
+	StatusPassed Status = "passed"
+	// StatusFailed means the test case failed.
+	StatusFailed Status = "failed"
+	// StatusSkipped means the test case was skipped.
+	StatusSkipped Status = "skipped"
+	// StatusQuarantined means the test case failed but is quarantined, so
+	// its failure doesn't fail the suite.
+	StatusQuarantined Status = "quarantined"
+)
+
+// Result is the result of a test case in the JSON summary.
+type Result struct {
+	Name       string            `json:"name"`
+	Status     Status            `json:"status"`
+	Duration   float64           `json:"durationSeconds"`
+	Message    string            `json:"message,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+// Summary is the JSON summary of a test suite.
+type Summary struct {
+	Suite    string `json:"suite"`
+	Tests    int    `json:"tests"`
+	Failures int    `json:"failures"`
+	Skipped  int    `json:"skipped"`
+	// Quarantined is the number of failures of quarantined test cases, which
+	// aren't counted as Failures.
+	Quarantined int               `json:"quarantined"`
+	Properties  map[string]string `json:"properties,omitempty"`
+	Results     []Result          `json:"results"`
+}
+
+// Recorder records the results of a test suite. It is safe for concurrent
+// use.
+type Recorder struct {
+	mu      sync.Mutex
+	summary Summary
+}
+
+// NewRecorder returns a Recorder for the named suite. When running in a
+// Prow job, the suite's properties identify the job run.
+func NewRecorder(suite string) *Recorder {
+	r := &Recorder{summary: Summary{Suite: suite}}
+	if job := prow.GetJob(); job.IsCI() {
+		r.SetProperty("job", job.Name)
+		r.SetProperty("build", job.BuildID)
+	}
+	return r
+}
+
+// SetProperty sets a custom property of the suite.
+func (r *Recorder) SetProperty(name, value string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.summary.Properties == nil {
+		r.summary.Properties = make(map[string]string)
+	}
+	r.summary.Properties[name] = value
+}
+
+// Pass records a passed test case with the given custom properties.
+func (r *Recorder) Pass(name string, d time.Duration, props map[string]string) {
+	r.add(Result{Name: name, Status: StatusPassed, Duration: d.Seconds(), Properties: props})
+}
+
+// Fail records a failed test case with the given custom properties.
+func (r *Recorder) Fail(name, message string, d time.Duration, props map[string]string) {
+	r.add(Result{Name: name, Status: StatusFailed, Duration: d.Seconds(), Message: message, Properties: props})
+}
+
+// Skip records a skipped test case.
+func (r *Recorder) Skip(name, message string) {
+	r.add(Result{Name: name, Status: StatusSkipped, Message: message})
+}
+
+// Quarantine records a failed test case which is quarantined with the given
+// custom properties. It is reported apart from the failures.
+func (r *Recorder) Quarantine(name, message string, d time.Duration, props map[string]string) {
+	r.add(Result{Name: name, Status: StatusQuarantined, Duration: d.Seconds(), Message: message, Properties: props})
+}
+
+func (r *Recorder) add(res Result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.summary.Results = append(r.summary.Results, res)
+	r.summary.Tests++
+	switch res.Status {
+	case StatusFailed:
+		r.summary.Failures++
+	case StatusSkipped:
+		r.summary.Skipped++
+	case StatusQuarantined:
+		r.summary.Quarantined++
+	}
+}
+
+// Summary returns the JSON summary of the recorded results.
+func (r *Recorder) Summary() Summary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.summary
+	s.Results = append([]Result(nil), s.Results...)
+	return s
+}
+
+// JUnit returns the JUnit test suite of the recorded results. As JUnit has
+// no notion of quarantine, the quarantined test cases are reported as
+// skipped, with their failure as the message.
+func (r *Recorder) JUnit() TestSuite {
+	s := r.Summary()
+	suite := TestSuite{
+		Name:       s.Suite,
+		Tests:      s.Tests,
+		Failures:   s.Failures,
+		Skipped:    s.Skipped + s.Quarantined,
+		Properties: newProperties(s.Properties),
+	}
+	for _, res := range s.Results {
+		tc := TestCase{
+			ClassName:  s.Suite,
+			Name:       res.Name,
+			Time:       res.Duration,
+			Properties: newProperties(res.Properties),
+		}
+		switch res.Status {
+		case StatusFailed:
+			tc.Failure = &Failure{Message: res.Message, Text: res.Message}
+		case StatusSkipped:
+			tc.Skipped = &Skipped{Message: res.Message}
+		case StatusQuarantined:
+			tc.Skipped = &Skipped{Message: "quarantined: " + res.Message}
+		}
+		suite.Time += res.Duration
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	return suite
+}
+
+// Write writes the results to the artifacts directory, see WriteTo.
+func (r *Recorder) Write() error {
+	return r.WriteTo(test.ArtifactsDir())
+}
+
+// WriteTo writes the results as junit_<suite>.xml, where Prow picks them up,
+// and as the JSON summary <suite>.json to the given directory.
+func (r *Recorder) WriteTo(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	name := r.Summary().Suite
+
+	b, err := xml.MarshalIndent(TestSuites{Suites: []TestSuite{r.JUnit()}}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal the JUnit results: %v", err)
+	}
+	b = append([]byte(xml.Header), b...)
+	if err := ioutil.WriteFile(filepath.Join(dir, "junit_"+name+".xml"), b, 0644); err != nil {
+		return err
+	}
+
+	if b, err = json.MarshalIndent(r.Summary(), "", "  "); err != nil {
+		return fmt.Errorf("failed to marshal the summary: %v", err)
+	}
+	return ioutil.WriteFile(filepath.Join(dir, name+".json"), b, 0644)
+}
+
+// Percentiles returns properties holding the given percentiles (0-100) of
+// the latencies, named like "p99", in seconds. Use it to attach latency
+// distributions to results.
+func Percentiles(latencies []time.Duration, percentiles ...float64) map[string]string {
+	props := make(map[string]string, len(percentiles))
+	if len(latencies) == 0 {
+		return props
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for _, p := range percentiles {
+		// Nearest-rank method.
+		rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+		if rank < 1 {
+			rank = 1
+		} else if rank > len(sorted) {
+			rank = len(sorted)
+		}
+		props[fmt.Sprintf("p%g", p)] = fmt.Sprintf("%g", sorted[rank-1].Seconds())
+	}
+	return props
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package junit
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "junit")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := NewRecorder("load-test")
+	r.SetProperty("cluster", "gke")
+	r.Pass("steady", 2*time.Second, map[string]string{"p99": "0.1", "p50": "0.01"})
+	r.Fail("burst", "SLO missed", time.Second, nil)
+	r.Skip("scale-to-zero", "not supported")
+	r.Quarantine("flaky", "timed out", time.Second, map[string]string{"quarantined": "true"})
+
+	if err := r.WriteTo(dir); err != nil {
+		t.Fatalf("WriteTo() = %v", err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "junit_load-test.xml"))
+	if err != nil {
+		t.Fatalf("ReadFile(xml) = %v", err)
+	}
+	var suites TestSuites
+	if err := xml.Unmarshal(b, &suites); err != nil {
+		t.Fatalf("xml.Unmarshal() = %v", err)
+	}
+	if len(suites.Suites) != 1 {
+		t.Fatalf("Suites = %d, want 1", len(suites.Suites))
+	}
+	suite := suites.Suites[0]
+	if suite.Tests != 4 || suite.Failures != 1 || suite.Skipped != 2 || suite.Time != 4 {
+		t.Errorf("Suite = %d tests, %d failures, %d skipped in %vs, want 4, 1, 2 in 4s",
+			suite.Tests, suite.Failures, suite.Skipped, suite.Time)
+	}
+	wantProps := &Properties{Properties: []Property{{Name: "p50", Value: "0.01"}, {Name: "p99", Value: "0.1"}}}
+	if diff := cmp.Diff(wantProps, suite.TestCases[0].Properties); diff != "" {
+		t.Errorf("Properties (-want, +got) = %s", diff)
+	}
+	if f := suite.TestCases[1].Failure; f == nil || f.Message != "SLO missed" {
+		t.Errorf("Failure = %v, want SLO missed", f)
+	}
+	if suite.TestCases[2].Skipped == nil {
+		t.Error("Skipped = nil, wanted the test case to be skipped")
+	}
+	if s := suite.TestCases[3].Skipped; s == nil || s.Message != "quarantined: timed out" {
+		t.Errorf("Skipped = %v, want quarantined: timed out", s)
+	}
+	if suite.TestCases[3].Failure != nil {
+		t.Errorf("Failure = %v, want none for a quarantined test case", suite.TestCases[3].Failure)
+	}
+
+	b, err = ioutil.ReadFile(filepath.Join(dir, "load-test.json"))
+	if err != nil {
+		t.Fatalf("ReadFile(json) = %v", err)
+	}
+	var summary Summary
+	if err := json.Unmarshal(b, &summary); err != nil {
+		t.Fatalf("json.Unmarshal() = %v", err)
+	}
+	if diff := cmp.Diff(r.Summary(), summary); diff != "" {
+		t.Errorf("Summary (-want, +got) = %s", diff)
+	}
+	if summary.Failures != 1 || summary.Quarantined != 1 {
+		t.Errorf("Summary = %d failures, %d quarantined, want 1, 1", summary.Failures, summary.Quarantined)
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	var latencies []time.Duration
+	for i := 100; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	got := Percentiles(latencies, 50, 99, 99.9, 100)
+	want := map[string]string{
+		"p50":   "0.05",
+		"p99":   "0.099",
+		"p99.9": "0.1",
+		"p100":  "0.1",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Percentiles (-want, +got) = %s", diff)
+	}
+
+	if got := Percentiles(nil, 50); len(got) != 0 {
+		t.Errorf("Percentiles(nil) = %v, want none", got)
+	}
+}
@@ -0,0 +1,279 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package loadgen drives HTTP load at a target rate for the latency probes of
+// the benchmarks, and records a sample per request, which can be published to
+// Mako.
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/lib"
+)
+
+// defaultTimeout is the default timeout of the requests.
+const defaultTimeout = 30 * time.Second
+
+// Options configures a Driver.
+type Options struct {
+	// RPS is the number of requests sent per second, unless Pacer is set.
+	RPS int
+	// Pacer paces the requests instead, e.g. a pacer of
+	// knative.dev/pkg/test/vegeta/pacers ramping the rate up.
+	Pacer vegeta.Pacer
+	// Duration is how long the load lasts.
+	Duration time.Duration
+	// Timeout is the timeout of each request. Defaults to 30 seconds.
+	Timeout time.Duration
+	// MaxInFlight caps the requests in flight, if positive. The requests due
+	// while the cap is reached are sent late, which their samples record.
+	MaxInFlight int
+
+	// KeepAlive reuses the connections across requests. Otherwise every
+	// request opens a new connection, whose setup is part of its latency.
+	KeepAlive bool
+	// MaxConnections caps the connections per host, if positive.
+	MaxConnections int
+	// Transport sends the requests instead of a transport configured by
+	// KeepAlive and MaxConnections, e.g. to resolve the domain of the target
+	// to an ingress.
+	Transport http.RoundTripper
+}
+
+// Target is the request sent by a Driver.
+type Target struct {
+	// Method is the method of the request. Defaults to GET.
+	Method string
+	// URL is the URL of the request.
+	URL string
+	// Header are the headers of the request, e.g. its Host.
+	Header http.Header
+	// Body is the body of the request, if any.
+	Body []byte
+}
+
+// Sample is the outcome of a request sent by a Driver.
+type Sample struct {
+	// Start is when the request was sent.
+	Start time.Time
+	// Delay is how late the request was sent, if MaxInFlight was reached.
+	Delay time.Duration
+	// Latency is the time from sending the request to reading its response.
+	Latency time.Duration
+	// Code is the status code of the response, zero if the request failed.
+	Code int
+	// BytesIn is the size of the body of the response.
+	BytesIn int64
+	// Error is the error the request failed with, if any.
+	Error string
+}
+
+// OK returns true if the request succeeded with a 2xx status code.
+func (s Sample) OK() bool {
+	return s.Error == "" && s.Code >= http.StatusOK && s.Code < http.StatusMultipleChoices
+}
+
+// Driver sends requests at the configured rate.
+type Driver struct {
+	opts   Options
+	pacer  vegeta.Pacer
+	client *http.Client
+}
+
+// New creates a Driver with the given options.
+func New(opts Options) (*Driver, error) {
+	if opts.Duration <= 0 {
+		return nil, fmt.Errorf("duration must be positive, got %v", opts.Duration)
+	}
+	pacer := opts.Pacer
+	if pacer == nil {
+		if opts.RPS <= 0 {
+			return nil, fmt.Errorf("RPS must be positive without a pacer, got %d", opts.RPS)
+		}
+		pacer = vegeta.ConstantPacer{Freq: opts.RPS, Per: time.Second}
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultTimeout
+	}
+
+	transport := opts.Transport
+	if transport == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.DisableKeepAlives = !opts.KeepAlive
+		t.MaxConnsPerHost = opts.MaxConnections
+		if opts.MaxConnections > 0 {
+			t.MaxIdleConnsPerHost = opts.MaxConnections
+		} else {
+			t.MaxIdleConnsPerHost = t.MaxIdleConns
+		}
+		transport = t
+	}
+	return &Driver{
+		opts:  opts,
+		pacer: pacer,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   opts.Timeout,
+			// The latency of the target itself is measured, not the
+			// latency of the redirections.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}, nil
+}
+
+// Run sends the target requests for the configured duration, and returns their
+// samples sorted by start time. If the context is done, Run stops early and
+// returns the samples so far along with the error of the context.
+func (d *Driver) Run(ctx context.Context, target Target) ([]Sample, error) {
+	if target.URL == "" {
+		return nil, errors.New("the URL of the target cannot be empty")
+	}
+	// Make sure that the target request can be built before sending it.
+	if _, err := target.request(ctx); err != nil {
+		return nil, err
+	}
+
+	var (
+		mu      sync.Mutex
+		samples []Sample
+		wg      sync.WaitGroup
+		slots   chan struct{}
+	)
+	if d.opts.MaxInFlight > 0 {
+		slots = make(chan struct{}, d.opts.MaxInFlight)
+	}
+
+	start := time.Now()
+	var hits uint64
+	err := func() error {
+		for {
+			elapsed := time.Since(start)
+			if elapsed >= d.opts.Duration {
+				return nil
+			}
+			wait, stop := d.pacer.Pace(elapsed, hits)
+			if stop {
+				return nil
+			}
+			if wait > 0 {
+				if err := sleep(ctx, wait); err != nil {
+					return err
+				}
+				continue
+			}
+
+			due := time.Now()
+			if slots != nil {
+				select {
+				case slots <- struct{}{}:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			hits++
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sample := d.send(ctx, target)
+				sample.Delay = sample.Start.Sub(due)
+				if slots != nil {
+					<-slots
+				}
+				mu.Lock()
+				samples = append(samples, sample)
+				mu.Unlock()
+			}()
+		}
+	}()
+	wg.Wait()
+
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].Start.Before(samples[j].Start)
+	})
+	return samples, err
+}
+
+// send sends the target request and reads its response.
+func (d *Driver) send(ctx context.Context, target Target) Sample {
+	sample := Sample{Start: time.Now()}
+	req, err := target.request(ctx)
+	if err != nil {
+		sample.Error = err.Error()
+		return sample
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		sample.Latency = time.Since(sample.Start)
+		sample.Error = err.Error()
+		return sample
+	}
+	defer resp.Body.Close()
+	// The body is read fully so that the connection can be reused.
+	sample.BytesIn, err = io.Copy(ioutil.Discard, resp.Body)
+	sample.Latency = time.Since(sample.Start)
+	sample.Code = resp.StatusCode
+	if err != nil {
+		sample.Error = err.Error()
+	}
+	return sample
+}
+
+// request builds the request of the target.
+func (t Target) request(ctx context.Context) (*http.Request, error) {
+	method := t.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if t.Body != nil {
+		body = bytes.NewReader(t.Body)
+	}
+	req, err := http.NewRequest(method, t.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range t.Header {
+		req.Header[k] = v
+	}
+	if host := t.Header.Get("Host"); host != "" {
+		req.Host = host
+	}
+	return req.WithContext(ctx), nil
+}
+
+// sleep waits for the given duration, unless the context is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
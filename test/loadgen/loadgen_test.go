@@ -0,0 +1,168 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadgen
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newServer starts a server echoing the request bodies, failing the requests
+// with the `fail` query parameter, and counting the connections opened.
+func newServer(delay time.Duration) (*httptest.Server, *int64) {
+	var conns int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		if r.URL.Query().Get("fail") != "" {
+			http.Error(w, "failed", http.StatusInternalServerError)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write(b)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&conns, 1)
+		}
+	}
+	server.Start()
+	return server, &conns
+}
+
+func TestNew(t *testing.T) {
+	for _, opts := range []Options{
+		{RPS: 10},
+		{Duration: time.Second},
+		{Duration: -time.Second, RPS: 10},
+	} {
+		if _, err := New(opts); err == nil {
+			t.Errorf("New(%+v) = nil, wanted an error", opts)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	server, conns := newServer(0)
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		keepAlive bool
+		// wantConns is the maximum number of connections opened, or the
+		// minimum if negative.
+		wantConns int64
+	}{{
+		name:      "connections reused",
+		keepAlive: true,
+		wantConns: 1,
+	}, {
+		name:      "connection per request",
+		wantConns: -10,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			atomic.StoreInt64(conns, 0)
+			d, err := New(Options{RPS: 100, Duration: 200 * time.Millisecond, MaxInFlight: 1, KeepAlive: test.keepAlive})
+			if err != nil {
+				t.Fatalf("New() = %v", err)
+			}
+			samples, err := d.Run(context.Background(), Target{Method: http.MethodPost, URL: server.URL, Body: []byte("probe")})
+			if err != nil {
+				t.Fatalf("Run() = %v", err)
+			}
+			// The rate is approximate on a loaded machine.
+			if len(samples) < 10 || len(samples) > 21 {
+				t.Errorf("Got %d samples, want about 20", len(samples))
+			}
+			for i, s := range samples {
+				if !s.OK() || s.BytesIn != int64(len("probe")) {
+					t.Errorf("Sample %d = %+v, want a successful echo", i, s)
+				}
+				if i > 0 && s.Start.Before(samples[i-1].Start) {
+					t.Errorf("Sample %d started before sample %d", i, i-1)
+				}
+			}
+			got := atomic.LoadInt64(conns)
+			if test.wantConns > 0 && got > test.wantConns {
+				t.Errorf("Opened %d connections, want at most %d", got, test.wantConns)
+			}
+			if test.wantConns < 0 && got < -test.wantConns {
+				t.Errorf("Opened %d connections, want at least %d", got, -test.wantConns)
+			}
+		})
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	server, _ := newServer(0)
+	defer server.Close()
+	d, err := New(Options{RPS: 50, Duration: 100 * time.Millisecond, KeepAlive: true})
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+
+	samples, err := d.Run(context.Background(), Target{URL: server.URL + "?fail=true"})
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if len(samples) == 0 {
+		t.Fatal("Got no samples")
+	}
+	for _, s := range samples {
+		if s.OK() || s.Code != http.StatusInternalServerError {
+			t.Errorf("Sample = %+v, wanted a server error", s)
+		}
+	}
+
+	if _, err := d.Run(context.Background(), Target{}); err == nil {
+		t.Error("Run() = nil, wanted an error without URL")
+	}
+	if _, err := d.Run(context.Background(), Target{Method: "bad method", URL: server.URL}); err == nil {
+		t.Error("Run() = nil, wanted an error for an invalid request")
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	server, _ := newServer(time.Second)
+	defer server.Close()
+	d, err := New(Options{RPS: 100, Duration: time.Minute, MaxInFlight: 2, KeepAlive: true})
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	samples, err := d.Run(ctx, Target{URL: server.URL})
+	if err != context.DeadlineExceeded {
+		t.Errorf("Run() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	// The requests in flight are aborted.
+	if len(samples) != 2 {
+		t.Errorf("Got %d samples, want 2", len(samples))
+	}
+	for _, s := range samples {
+		if s.OK() || s.Error == "" {
+			t.Errorf("Sample = %+v, wanted an aborted request", s)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadgen
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"knative.dev/pkg/test/mako"
+)
+
+// Publish adds the samples to the Mako storage of a run: the latency of each
+// successful request, in seconds, under the given value key at the time it
+// was sent, and an error for each request which failed.
+func Publish(storage mako.Storage, samples []Sample, latencyKey string) error {
+	for _, s := range samples {
+		xval := mako.XTime(s.Start)
+		if !s.OK() {
+			message := s.Error
+			if message == "" {
+				message = fmt.Sprintf("status code %d", s.Code)
+			}
+			if err := storage.AddError(xval, message); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := storage.AddSamplePoint(xval, map[string]float64{latencyKey: s.Latency.Seconds()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Summary summarizes the samples of a load.
+type Summary struct {
+	// Requests is the number of requests sent.
+	Requests int
+	// Errors is the number of requests which failed.
+	Errors int
+	// P50, P90, P99 and Max are percentiles of the latencies of the
+	// successful requests.
+	P50, P90, P99, Max time.Duration
+	// Throughput is the number of successful requests per second, between
+	// the first request sent and the last response read.
+	Throughput float64
+}
+
+// Summarize summarizes the samples.
+func Summarize(samples []Sample) Summary {
+	summary := Summary{Requests: len(samples)}
+	var latencies []time.Duration
+	var first, last time.Time
+	for _, s := range samples {
+		if !s.OK() {
+			summary.Errors++
+			continue
+		}
+		latencies = append(latencies, s.Latency)
+		if first.IsZero() || s.Start.Before(first) {
+			first = s.Start
+		}
+		if end := s.Start.Add(s.Latency); end.After(last) {
+			last = end
+		}
+	}
+	if len(latencies) == 0 {
+		return summary
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	summary.P50 = percentile(latencies, 0.5)
+	summary.P90 = percentile(latencies, 0.9)
+	summary.P99 = percentile(latencies, 0.99)
+	summary.Max = latencies[len(latencies)-1]
+	if span := last.Sub(first); span > 0 {
+		summary.Throughput = float64(len(latencies)) / span.Seconds()
+	}
+	return summary
+}
+
+// percentile returns the nearest-rank percentile of the sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
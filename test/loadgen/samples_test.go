@@ -0,0 +1,103 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadgen
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"knative.dev/pkg/test/mako"
+)
+
+// fakeStorage records the points and errors added to it.
+type fakeStorage struct {
+	points []map[string]float64
+	errors []string
+}
+
+func (f *fakeStorage) AddSamplePoint(_ float64, values map[string]float64) error {
+	f.points = append(f.points, values)
+	return nil
+}
+
+func (f *fakeStorage) AddError(_ float64, message string) error {
+	f.errors = append(f.errors, message)
+	return nil
+}
+
+func (f *fakeStorage) AddRunAggregate(string, float64) error {
+	return nil
+}
+
+func (f *fakeStorage) Store(context.Context) (string, error) {
+	return "run", nil
+}
+
+var _ mako.Storage = (*fakeStorage)(nil)
+
+func TestPublish(t *testing.T) {
+	start := time.Now()
+	samples := []Sample{
+		{Start: start, Latency: 250 * time.Millisecond, Code: 200},
+		{Start: start, Latency: time.Second, Code: 503},
+		{Start: start, Error: "connection refused"},
+	}
+	storage := &fakeStorage{}
+	if err := Publish(storage, samples, "latency"); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	if diff := cmp.Diff([]map[string]float64{{"latency": 0.25}}, storage.points); diff != "" {
+		t.Errorf("Points (-want, +got): %s", diff)
+	}
+	if diff := cmp.Diff([]string{"status code 503", "connection refused"}, storage.errors); diff != "" {
+		t.Errorf("Errors (-want, +got): %s", diff)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	start := time.Now()
+	var samples []Sample
+	for i := 1; i <= 100; i++ {
+		samples = append(samples, Sample{
+			Start:   start.Add(time.Duration(i) * 10 * time.Millisecond),
+			Latency: time.Duration(i) * time.Millisecond,
+			Code:    200,
+		})
+	}
+	samples = append(samples, Sample{Start: start, Error: "timeout"})
+
+	want := Summary{
+		Requests: 101,
+		Errors:   1,
+		P50:      50 * time.Millisecond,
+		P90:      90 * time.Millisecond,
+		P99:      99 * time.Millisecond,
+		Max:      100 * time.Millisecond,
+		// 100 requests between 10ms and 1.1s.
+		Throughput: 100 / 1.09,
+	}
+	if diff := cmp.Diff(want, Summarize(samples), cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("Summarize (-want, +got): %s", diff)
+	}
+	if diff := cmp.Diff(Summary{}, Summarize(nil)); diff != "" {
+		t.Errorf("Summarize(nil) (-want, +got): %s", diff)
+	}
+}
@@ -17,5 +17,7 @@ limitations under the License.
 // Package logstream lets end-to-end tests incorporate controller logs
 // into the error output of tests.  It is enabled by setting the
 // SYSTEM_NAMESPACE environment variable, which tells this package
-// what namespace to stream logs from.
+// what namespaces (comma-separated) to stream logs from.  The streamed
+// pods and containers can be filtered by setting LOGSTREAM_LABEL_SELECTOR
+// and LOGSTREAM_CONTAINERS, or through Configure.
 package logstream
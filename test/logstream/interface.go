@@ -18,11 +18,22 @@ package logstream
 
 import (
 	"os"
+	"strings"
 	"testing"
 
+	"golang.org/x/time/rate"
 	"knative.dev/pkg/system"
 )
 
+const (
+	// LabelSelectorEnvKey is the environment variable holding the label
+	// selector of the pods whose logs are streamed.
+	LabelSelectorEnvKey = "LOGSTREAM_LABEL_SELECTOR"
+	// ContainersEnvKey is the environment variable holding the
+	// comma-separated names of the containers whose logs are streamed.
+	ContainersEnvKey = "LOGSTREAM_CONTAINERS"
+)
+
 // Canceler is the type of a function returned when a logstream is started to be
 // deferred so that the logstream can be stopped when the test is complete.
 type Canceler func()
@@ -40,13 +51,57 @@ type streamer interface {
 
 var stream streamer
 
+// Options define which logs are streamed.
+type Options struct {
+	// Namespaces are the namespaces of the pods whose logs are streamed.
+	Namespaces []string
+	// LabelSelector restricts the streamed pods to the ones matching it.
+	LabelSelector string
+	// Containers restricts the streamed containers to the ones with these
+	// names. All containers are streamed if empty.
+	Containers []string
+
+	// RateLimit is the maximum number of lines per second passed to each
+	// test, with bursts of up to Burst lines. Excess lines are dropped and
+	// reported when the test's stream is canceled. No limit is applied if
+	// RateLimit is zero.
+	RateLimit rate.Limit
+	Burst     int
+}
+
+// Configure replaces the options of the stream, which default to the ones
+// read from the environment: SYSTEM_NAMESPACE holds the comma-separated
+// namespaces, LOGSTREAM_LABEL_SELECTOR the label selector and
+// LOGSTREAM_CONTAINERS the comma-separated container names. It must be
+// called before the first call to Start, e.g. in TestMain.
+func Configure(opts Options) {
+	stream = newStreamer(opts)
+}
+
+func newStreamer(opts Options) streamer {
+	if len(opts.Namespaces) == 0 {
+		return &null{}
+	}
+	return &kubelogs{Options: opts}
+}
+
 func init() {
-	ns := os.Getenv(system.NamespaceEnvKey)
-	if ns != "" {
-		// If SYSTEM_NAMESPACE is set, then start the stream.
-		stream = &kubelogs{namespace: ns}
-	} else {
-		// Otherwise set up a null stream.
-		stream = &null{}
+	// If SYSTEM_NAMESPACE is set, then start the stream.
+	// Otherwise set up a null stream.
+	stream = newStreamer(Options{
+		Namespaces:    splitList(os.Getenv(system.NamespaceEnvKey)),
+		LabelSelector: os.Getenv(LabelSelectorEnvKey),
+		Containers:    splitList(os.Getenv(ContainersEnvKey)),
+	})
+}
+
+// splitList splits a comma-separated list, dropping empty elements.
+func splitList(s string) []string {
+	var list []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
 	}
+	return list
 }
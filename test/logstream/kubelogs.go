@@ -22,10 +22,12 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -35,15 +37,32 @@ import (
 )
 
 type kubelogs struct {
-	namespace string
+	Options
 
 	once sync.Once
 	m    sync.RWMutex
-	keys map[string]logger
+	keys map[string]*logger
 	err  error
 }
 
-type logger func(string, ...interface{})
+// logger passes the lines of a single test to its logf, rate limited.
+type logger struct {
+	logf    func(string, ...interface{})
+	limiter *rate.Limiter
+
+	// dropped counts the lines dropped by the rate limit.
+	dropped int64
+}
+
+// log passes the line on, unless it exceeds the rate limit. It is called
+// with the kubelogs lock held for reading.
+func (l *logger) log(msg string) {
+	if l.limiter != nil && !l.limiter.Allow() {
+		atomic.AddInt64(&l.dropped, 1)
+		return
+	}
+	l.logf(msg)
+}
 
 var _ streamer = (*kubelogs)(nil)
 
@@ -51,25 +70,47 @@ var _ streamer = (*kubelogs)(nil)
 const timeFormat = "15:04:05.000"
 
 func (k *kubelogs) init(t *testing.T) {
-	k.keys = make(map[string]logger)
+	k.keys = make(map[string]*logger)
 
 	kc, err := test.NewKubeClient(test.Flags.Kubeconfig, test.Flags.Cluster)
 	if err != nil {
 		t.Errorf("Error loading client config: %v", err)
+		return
 	}
 
-	// List the pods in the given namespace.
-	pl, err := kc.Kube.CoreV1().Pods(k.namespace).List(metav1.ListOptions{})
-	if err != nil {
-		t.Errorf("Error listing pods: %v", err)
+	eg := errgroup.Group{}
+	for _, ns := range k.Namespaces {
+		// List the pods in the given namespace.
+		pl, err := kc.Kube.CoreV1().Pods(ns).List(metav1.ListOptions{LabelSelector: k.LabelSelector})
+		if err != nil {
+			t.Errorf("Error listing pods in %s: %v", ns, err)
+			continue
+		}
+		k.streamPods(kc, pl.Items, &eg)
 	}
 
-	eg := errgroup.Group{}
-	for _, pod := range pl.Items {
+	// Monitor the error group in the background and surface an error on the kubelogs
+	// in case anything had an active stream open.
+	go func() {
+		if err := eg.Wait(); err != nil {
+			k.m.Lock()
+			defer k.m.Unlock()
+			k.err = err
+		}
+	}()
+}
+
+// streamPods starts streaming the logs of the selected containers of the
+// given pods in the error group.
+func (k *kubelogs) streamPods(kc *test.KubeClient, pods []corev1.Pod, eg *errgroup.Group) {
+	for _, pod := range pods {
 		// Grab data from all containers in the pods.  We need this in case
 		// an envoy sidecar is injected for mesh installs.  This should be
 		// equivalent to --all-containers.
 		for _, container := range pod.Spec.Containers {
+			if !k.streamsContainer(container.Name) {
+				continue
+			}
 			// Required for capture below.
 			pod, container := pod, container
 			eg.Go(func() error {
@@ -81,7 +122,7 @@ func (k *kubelogs) init(t *testing.T) {
 					SinceSeconds: ptr.Int64(1),
 				}
 
-				req := kc.Kube.CoreV1().Pods(k.namespace).GetLogs(pod.Name, options)
+				req := kc.Kube.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, options)
 				stream, err := req.Stream()
 				if err != nil {
 					return err
@@ -91,20 +132,24 @@ func (k *kubelogs) init(t *testing.T) {
 				for scanner := bufio.NewScanner(stream); scanner.Scan(); {
 					k.handleLine(scanner.Text())
 				}
-				return fmt.Errorf("logstream completed prematurely for: %s/%s", pod.Name, container.Name)
+				return fmt.Errorf("logstream completed prematurely for: %s/%s/%s", pod.Namespace, pod.Name, container.Name)
 			})
 		}
 	}
+}
 
-	// Monitor the error group in the background and surface an error on the kubelogs
-	// in case anything had an active stream open.
-	go func() {
-		if err := eg.Wait(); err != nil {
-			k.m.Lock()
-			defer k.m.Unlock()
-			k.err = err
+// streamsContainer returns true if the logs of the named container are
+// streamed.
+func (k *kubelogs) streamsContainer(name string) bool {
+	if len(k.Containers) == 0 {
+		return true
+	}
+	for _, c := range k.Containers {
+		if c == name {
+			return true
 		}
-	}()
+	}
+	return false
 }
 
 func (k *kubelogs) handleLine(l string) {
@@ -131,7 +176,7 @@ func (k *kubelogs) handleLine(l string) {
 	k.m.RLock()
 	defer k.m.RUnlock()
 
-	for name, logf := range k.keys {
+	for name, l := range k.keys {
 		// TODO(mattmoor): Do a slightly smarter match.
 		if !strings.Contains(line.Key, name) {
 			continue
@@ -155,7 +200,7 @@ func (k *kubelogs) handleLine(l string) {
 			msg += " err=" + line.Error
 		}
 
-		logf(msg)
+		l.log(msg)
 	}
 }
 
@@ -164,11 +209,19 @@ func (k *kubelogs) Start(t *testing.T) Canceler {
 	k.once.Do(func() { k.init(t) })
 
 	name := helpers.ObjectPrefixForTest(t)
+	l := &logger{logf: t.Logf}
+	if k.RateLimit != 0 {
+		burst := k.Burst
+		if burst < 1 {
+			burst = 1
+		}
+		l.limiter = rate.NewLimiter(k.RateLimit, burst)
+	}
 
 	// Register a key
 	k.m.Lock()
 	defer k.m.Unlock()
-	k.keys[name] = t.Logf
+	k.keys[name] = l
 
 	// Return a function that unregisters that key.
 	return func() {
@@ -176,6 +229,9 @@ func (k *kubelogs) Start(t *testing.T) Canceler {
 		defer k.m.Unlock()
 		delete(k.keys, name)
 
+		if dropped := atomic.LoadInt64(&l.dropped); dropped > 0 {
+			t.Logf("logstream dropped %d lines exceeding the rate limit", dropped)
+		}
 		if k.err != nil {
 			t.Errorf("error during logstream: %v", k.err)
 		}
@@ -0,0 +1,102 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logstream
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/time/rate"
+)
+
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{{
+		in: "",
+	}, {
+		in:   "knative-serving",
+		want: []string{"knative-serving"},
+	}, {
+		in:   "knative-serving, knative-eventing,,",
+		want: []string{"knative-serving", "knative-eventing"},
+	}}
+
+	for _, test := range tests {
+		if got := splitList(test.in); !cmp.Equal(got, test.want) {
+			t.Errorf("splitList(%q) = %v, want %v", test.in, got, test.want)
+		}
+	}
+}
+
+func TestNewStreamer(t *testing.T) {
+	if _, ok := newStreamer(Options{}).(*null); !ok {
+		t.Error("newStreamer() without namespaces didn't return a null streamer")
+	}
+	if _, ok := newStreamer(Options{Namespaces: []string{"ns"}}).(*kubelogs); !ok {
+		t.Error("newStreamer() with namespaces didn't return a kubelogs streamer")
+	}
+}
+
+func TestStreamsContainer(t *testing.T) {
+	k := &kubelogs{}
+	if !k.streamsContainer("queue-proxy") {
+		t.Error("streamsContainer() = false without a filter, wanted true")
+	}
+
+	k.Containers = []string{"controller"}
+	if !k.streamsContainer("controller") {
+		t.Error("streamsContainer(controller) = false, wanted true")
+	}
+	if k.streamsContainer("istio-proxy") {
+		t.Error("streamsContainer(istio-proxy) = true, wanted false")
+	}
+}
+
+func TestHandleLine(t *testing.T) {
+	var got []string
+	l := &logger{
+		logf: func(format string, args ...interface{}) {
+			got = append(got, fmt.Sprintf(format, args...))
+		},
+		// Allow only the first two lines.
+		limiter: rate.NewLimiter(rate.Every(24*time.Hour), 2),
+	}
+	k := &kubelogs{keys: map[string]*logger{"test-name": l}}
+
+	line := `{"level":"info","ts":"2019-11-01T10:00:00.000Z","knative.dev/controller":"route-controller","knative.dev/key":"ns/test-name-abc","msg":"%s"}`
+	k.handleLine("not json")
+	k.handleLine(`{"level":"info","msg":"no key"}`)
+	k.handleLine(`{"level":"info","knative.dev/key":"ns/other","msg":"other test"}`)
+	for i := 0; i < 4; i++ {
+		k.handleLine(fmt.Sprintf(line, fmt.Sprint("message ", i)))
+	}
+
+	want := []string{
+		"I 10:00:00.000 [route-controller] [ns/test-name-abc] message 0",
+		"I 10:00:00.000 [route-controller] [ns/test-name-abc] message 1",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Lines (-want, +got) = %s", diff)
+	}
+	if l.dropped != 2 {
+		t.Errorf("Dropped = %d, want 2", l.dropped)
+	}
+}
@@ -21,59 +21,148 @@ import (
 	"log"
 
 	qpb "github.com/google/mako/proto/quickstore/quickstore_go_proto"
+	"knative.dev/pkg/test/ghutil"
 	"knative.dev/pkg/test/helpers"
 	"knative.dev/pkg/test/mako/alerter/github"
 	"knative.dev/pkg/test/mako/alerter/slack"
 	"knative.dev/pkg/test/mako/config"
+	"knative.dev/pkg/test/prow"
 )
 
 // Alerter controls alert for performance regressions detected by Mako.
 type Alerter struct {
-	githubIssueHandler  *github.IssueHandler
-	slackMessageHandler *slack.MessageHandler
+	githubIssueHandler  github.IssueOperations
+	slackMessageHandler slack.MessageOperations
+	// commits lists the changes in the commit range of the regressions, if set up.
+	commits     *commits
+	commitRange CommitRange
 }
 
 // SetupGitHub will setup SetupGitHub for the alerter.
-func (alerter *Alerter) SetupGitHub(org, repo, githubTokenPath string) {
-	issueHandler, err := github.Setup(org, repo, githubTokenPath, false)
+func (alerter *Alerter) SetupGitHub(org, repo, githubTokenPath string, opts github.Options) {
+	issueHandler, err := github.Setup(org, repo, githubTokenPath, opts, false)
 	if err != nil {
 		log.Printf("Error happens in setup '%v', Github alerter will not be enabled", err)
+		return
 	}
 	alerter.githubIssueHandler = issueHandler
 }
 
+// SetGitHubIssueOperations sets the Github issue operations of the alerter,
+// e.g. a fake.FakeIssueOperations for the tests to check the alerts.
+func (alerter *Alerter) SetGitHubIssueOperations(issueOperations github.IssueOperations) {
+	alerter.githubIssueHandler = issueOperations
+}
+
 // SetupSlack will setup Slack for the alerter.
 func (alerter *Alerter) SetupSlack(userName, readTokenPath, writeTokenPath string, channels []config.Channel) {
 	messageHandler, err := slack.Setup(userName, readTokenPath, writeTokenPath, channels, false)
 	if err != nil {
 		log.Printf("Error happens in setup '%v', Slack alerter will not be enabled", err)
+		return
 	}
 	alerter.slackMessageHandler = messageHandler
 }
 
+// SetupCommits will setup the correlation of the regressions with the PRs
+// merged in the given repository since the last run without regression, see
+// SetCommitRange.
+func (alerter *Alerter) SetupCommits(org, repo string, opts ghutil.ClientOptions) {
+	client, err := ghutil.NewGithubClientWithOptions(opts)
+	if err != nil {
+		log.Printf("Error happens in setup '%v', the regressions will not be correlated with the commits", err)
+		return
+	}
+	alerter.SetCommitOperations(org, repo, client)
+}
+
+// SetCommitOperations sets the Github operations the PRs merged in the given
+// repository are listed with, e.g. a fakeghutil.FakeGithubClient for the tests.
+func (alerter *Alerter) SetCommitOperations(org, repo string, client ghutil.GithubOperations) {
+	alerter.commits = &commits{org: org, repo: repo, client: client}
+}
+
+// SetCommitRange sets the range of commits the regressions alerted on next
+// were introduced in. The alerts then link to the comparison of the commits
+// and list the PRs merged in the range, if the commits are set up.
+func (alerter *Alerter) SetCommitRange(r CommitRange) {
+	alerter.commitRange = r
+}
+
 // HandleBenchmarkResult will handle the benchmark result which returns from `q.Store()`
 func (alerter *Alerter) HandleBenchmarkResult(testName string, output qpb.QuickstoreOutput, err error) error {
 	if err != nil {
 		if output.GetStatus() == qpb.QuickstoreOutput_ANALYSIS_FAIL {
-			var errs []error
 			summary := fmt.Sprintf("%s\n\nSee run chart at: %s", output.GetSummaryOutput(), output.GetRunChartLink())
-			if alerter.githubIssueHandler != nil {
-				if err := alerter.githubIssueHandler.CreateIssueForTest(testName, summary); err != nil {
-					errs = append(errs, err)
-				}
-			}
-			if alerter.slackMessageHandler != nil {
-				if err := alerter.slackMessageHandler.SendAlert(testName, summary); err != nil {
-					errs = append(errs, err)
-				}
-			}
-			return helpers.CombineErrors(errs)
+			return alerter.alert(testName, output.GetRunKey(), summary)
 		}
 		return err
 	}
+	return alerter.resolve(testName)
+}
+
+// HandleAnalysis will alert on the regressions detected by an analyzer in the
+// given run, described by the summary, or close the alert for the test if
+// there are none.
+func (alerter *Alerter) HandleAnalysis(testName, runID string, regressed bool, summary string) error {
+	if regressed {
+		return alerter.alert(testName, runID, summary)
+	}
+	return alerter.resolve(testName)
+}
+
+// HandleSLOStatus will alert if an SLO is violated, with the given summary of its status,
+// or close the alert for it if it's not.
+func (alerter *Alerter) HandleSLOStatus(testName, runID string, violated bool, summary string) error {
+	if violated {
+		return alerter.alert(testName, runID, summary)
+	}
+	return alerter.resolve(testName)
+}
+
+// alert alerts on the regression detected for the test in the given run on all channels.
+func (alerter *Alerter) alert(testName, runID, summary string) error {
+	var errs []error
+	summary = alerter.withCommits(withJob(summary))
 	if alerter.githubIssueHandler != nil {
-		return alerter.githubIssueHandler.CloseIssueForTest(testName)
+		if err := alerter.githubIssueHandler.CreateIssueForTest(testName, runID, summary); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := alerter.alertSlack(testName, summary); err != nil {
+		errs = append(errs, err)
+	}
+	return helpers.CombineErrors(errs)
+}
+
+// alertSlack alerts on the regression detected for the test on Slack, if set up.
+func (alerter *Alerter) alertSlack(testName, summary string) error {
+	if alerter.slackMessageHandler == nil {
+		return nil
+	}
+	return alerter.slackMessageHandler.SendAlert(testName, summary)
+}
+
+// withJob appends the Prow job which detected the regression to its summary, if any.
+func withJob(summary string) string {
+	if job := prow.GetJob(); job.IsCI() {
+		summary += fmt.Sprintf("\n\nDetected by %s", job)
 	}
+	return summary
+}
 
-	return nil
+// resolve records a run of the test without regression, resolving its alert on all channels.
+func (alerter *Alerter) resolve(testName string) error {
+	var errs []error
+	if alerter.githubIssueHandler != nil {
+		if err := alerter.githubIssueHandler.ResolveIssue(testName); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if alerter.slackMessageHandler != nil {
+		if err := alerter.slackMessageHandler.ResolveAlert(testName); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return helpers.CombineErrors(errs)
 }
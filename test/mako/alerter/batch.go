@@ -0,0 +1,89 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package alerter
+
+import (
+	"sync"
+	"time"
+
+	"knative.dev/pkg/test/mako/alerter/github"
+	"knative.dev/pkg/test/prow"
+)
+
+// Batch collects the regressions alerted on during a run, e.g. by the
+// benchmarks of a job, to report them at once on Github when flushed, instead
+// of creating or updating an issue per test. The Slack alerts, which are
+// threaded per test, and the resolved alerts are still sent right away.
+type Batch struct {
+	alerter *Alerter
+	name    string
+
+	mu          sync.Mutex
+	regressions []github.Regression
+}
+
+// NewBatch creates a Batch alerting through the alerter. The batch is named
+// after the Prow job run, or the time for a local run.
+func (alerter *Alerter) NewBatch() *Batch {
+	name := "local run " + time.Now().UTC().Format(time.RFC3339)
+	if job := prow.GetJob(); job.IsCI() {
+		name = job.String()
+	}
+	return &Batch{alerter: alerter, name: name}
+}
+
+// HandleAnalysis is like Alerter.HandleAnalysis, but the regressions are
+// reported on Github when the batch is flushed.
+func (b *Batch) HandleAnalysis(testName, runID string, regressed bool, summary string) error {
+	if regressed {
+		return b.add(testName, runID, summary)
+	}
+	return b.alerter.resolve(testName)
+}
+
+// HandleSLOStatus is like Alerter.HandleSLOStatus, but the violations are
+// reported on Github when the batch is flushed.
+func (b *Batch) HandleSLOStatus(testName, runID string, violated bool, summary string) error {
+	if violated {
+		return b.add(testName, runID, summary)
+	}
+	return b.alerter.resolve(testName)
+}
+
+// add adds the regression of the test to the batch, and alerts on it on Slack.
+func (b *Batch) add(testName, runID, summary string) error {
+	summary = b.alerter.withCommits(withJob(summary))
+	b.mu.Lock()
+	b.regressions = append(b.regressions, github.Regression{TestName: testName, RunID: runID, Description: summary})
+	b.mu.Unlock()
+	return b.alerter.alertSlack(testName, summary)
+}
+
+// Flush reports the regressions collected since the last flush on Github,
+// see github.IssueHandler.ReportRegressions. The regressions are dropped
+// even if reporting them fails, as they have all been retried already.
+func (b *Batch) Flush() error {
+	b.mu.Lock()
+	regressions := b.regressions
+	b.regressions = nil
+	b.mu.Unlock()
+
+	if len(regressions) == 0 || b.alerter.githubIssueHandler == nil {
+		return nil
+	}
+	return b.alerter.githubIssueHandler.ReportRegressions(b.name, regressions)
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package alerter
+
+import (
+	"testing"
+
+	"knative.dev/pkg/test/mako/alerter/github"
+)
+
+// fakeIssueOperations records the calls of the alerter.
+type fakeIssueOperations struct {
+	created  []string
+	resolved []string
+	reported [][]github.Regression
+}
+
+func (f *fakeIssueOperations) CreateIssueForTest(testName, runID, desc string) error {
+	f.created = append(f.created, testName)
+	return nil
+}
+
+func (f *fakeIssueOperations) CloseIssueForTest(testName string) error {
+	return nil
+}
+
+func (f *fakeIssueOperations) ResolveIssue(testName string) error {
+	f.resolved = append(f.resolved, testName)
+	return nil
+}
+
+func (f *fakeIssueOperations) ReportRegressions(batch string, regressions []github.Regression) error {
+	f.reported = append(f.reported, regressions)
+	return nil
+}
+
+func TestBatch(t *testing.T) {
+	issues := &fakeIssueOperations{}
+	batch := (&Alerter{githubIssueHandler: issues}).NewBatch()
+
+	if err := batch.HandleAnalysis("test1", "run1", true, "regressed"); err != nil {
+		t.Fatalf("HandleAnalysis() = %v", err)
+	}
+	if err := batch.HandleSLOStatus("test1 SLO", "run1", true, "violated"); err != nil {
+		t.Fatalf("HandleSLOStatus() = %v", err)
+	}
+	if err := batch.HandleAnalysis("test2", "run1", false, ""); err != nil {
+		t.Fatalf("HandleAnalysis() = %v", err)
+	}
+	// The regressions wait for the flush, the resolved alerts don't.
+	if len(issues.created) != 0 || len(issues.reported) != 0 {
+		t.Errorf("Alerted before the flush: %v, %v", issues.created, issues.reported)
+	}
+	if got, want := len(issues.resolved), 1; got != want {
+		t.Errorf("len(resolved) = %d, want %d", got, want)
+	}
+
+	if err := batch.Flush(); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	if got, want := len(issues.reported), 1; got != want {
+		t.Fatalf("len(reported) = %d, want %d", got, want)
+	}
+	if got, want := len(issues.reported[0]), 2; got != want {
+		t.Errorf("len(regressions) = %d, want %d", got, want)
+	}
+
+	// Nothing is left to report.
+	if err := batch.Flush(); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	if got, want := len(issues.reported), 1; got != want {
+		t.Errorf("len(reported) = %d, want %d", got, want)
+	}
+}
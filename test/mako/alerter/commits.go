@@ -0,0 +1,161 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package alerter
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+	"knative.dev/pkg/test/ghutil"
+)
+
+// maxPullRequests is the maximum number of merged PRs listed in an alert.
+const maxPullRequests = 20
+
+// CommitRange is the range of commits a regression was introduced in, from
+// the commit of the last run without regression, excluded, to the commit of
+// the regressed run, as returned by changeset.Get.
+type CommitRange struct {
+	Good string
+	Bad  string
+}
+
+// commits correlates the regressions with the PRs merged in their commit range.
+type commits struct {
+	org    string
+	repo   string
+	client ghutil.GithubOperations
+
+	// correlations caches the correlations of the commit ranges, since all
+	// of the regressions of a run share the same range.
+	mu           sync.Mutex
+	correlations map[CommitRange]Correlation
+}
+
+// Correlation is the list of changes which may have caused a regression.
+type Correlation struct {
+	// CompareURL is the link comparing the commits of the range.
+	CompareURL string
+	// PullRequests are the PRs merged in the range, the most recent first.
+	PullRequests []*github.PullRequest
+	// Commits is the number of commits in the range, including the ones
+	// which weren't merged through a PR.
+	Commits int
+}
+
+// correlate lists the changes merged in the commit range, once per range. The
+// compare URL is returned even if listing the PRs fails, in which case it's
+// listed again for the next regression.
+func (c *commits) correlate(r CommitRange) (Correlation, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if corr, ok := c.correlations[r]; ok {
+		return corr, nil
+	}
+	corr, err := c.list(r)
+	if err != nil {
+		return corr, err
+	}
+	if c.correlations == nil {
+		c.correlations = make(map[CommitRange]Correlation)
+	}
+	c.correlations[r] = corr
+	return corr, nil
+}
+
+// list lists the changes merged in the commit range from Github.
+func (c *commits) list(r CommitRange) (Correlation, error) {
+	corr := Correlation{
+		CompareURL: fmt.Sprintf("https://github.com/%s/%s/compare/%s...%s", c.org, c.repo, r.Good, r.Bad),
+	}
+	comparison, err := c.client.CompareCommits(c.org, c.repo, r.Good, r.Bad)
+	if err != nil {
+		return corr, fmt.Errorf("failed to compare %s...%s: %v", r.Good, r.Bad, err)
+	}
+	if url := comparison.GetHTMLURL(); url != "" {
+		// The URL of the Github Enterprise servers differ.
+		corr.CompareURL = url
+	}
+	corr.Commits = comparison.GetTotalCommits()
+	if corr.Commits == 0 {
+		corr.Commits = len(comparison.Commits)
+	}
+
+	seen := make(map[int]bool)
+	for _, commit := range comparison.Commits {
+		pr, err := c.client.GetPullRequestByCommitID(c.org, c.repo, commit.GetSHA())
+		if err != nil {
+			// The commit may have been pushed without PR.
+			log.Printf("No PR found for commit %s: %v", commit.GetSHA(), err)
+			continue
+		}
+		if pr.MergedAt == nil || seen[pr.GetNumber()] {
+			continue
+		}
+		seen[pr.GetNumber()] = true
+		corr.PullRequests = append(corr.PullRequests, pr)
+	}
+	sort.SliceStable(corr.PullRequests, func(i, j int) bool {
+		return corr.PullRequests[i].MergedAt.After(*corr.PullRequests[j].MergedAt)
+	})
+	return corr, nil
+}
+
+// String describes the correlation for the alerts, in Markdown.
+func (c Correlation) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Changes since the last run without regression: %s", c.CompareURL)
+	if c.Commits > 0 {
+		fmt.Fprintf(&sb, " (%d commits)", c.Commits)
+	}
+	if len(c.PullRequests) == 0 {
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, "\n\nMerged PRs (%d):", len(c.PullRequests))
+	for i, pr := range c.PullRequests {
+		if i == maxPullRequests {
+			fmt.Fprintf(&sb, "\n- ... and %d more", len(c.PullRequests)-maxPullRequests)
+			break
+		}
+		fmt.Fprintf(&sb, "\n- #%d %s", pr.GetNumber(), pr.GetTitle())
+		if login := pr.GetUser().GetLogin(); login != "" {
+			fmt.Fprintf(&sb, " (@%s)", login)
+		}
+		if url := pr.GetHTMLURL(); url != "" {
+			fmt.Fprintf(&sb, " %s", url)
+		}
+	}
+	return sb.String()
+}
+
+// withCommits appends the changes of the commit range of the regression to
+// its summary, if the commits are set up and the range is known.
+func (alerter *Alerter) withCommits(summary string) string {
+	r := alerter.commitRange
+	if alerter.commits == nil || r.Good == "" || r.Bad == "" || r.Good == r.Bad {
+		return summary
+	}
+	corr, err := alerter.commits.correlate(r)
+	if err != nil {
+		log.Printf("Error listing the PRs merged in %s...%s: %v", r.Good, r.Bad, err)
+	}
+	return summary + "\n\n" + corr.String()
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package alerter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"knative.dev/pkg/test/ghutil/fakeghutil"
+	alertergithub "knative.dev/pkg/test/mako/alerter/github"
+)
+
+const (
+	testOrg  = "knative"
+	testRepo = "serving"
+)
+
+// newFakeCommits returns a fake Github client comparing the good and bad
+// commits, with the given PRs merged in between, the oldest first, and a
+// commit pushed without PR.
+func newFakeCommits(t *testing.T, titles ...string) *fakeghutil.FakeGithubClient {
+	t.Helper()
+	client := fakeghutil.NewFakeGithubClient()
+	client.PullRequests[testRepo] = make(map[int]*github.PullRequest)
+	comparison := &github.CommitsComparison{
+		HTMLURL:      github.String("https://github.com/knative/serving/compare/good...bad"),
+		TotalCommits: github.Int(len(titles) + 1),
+	}
+	merged := time.Date(2019, 12, 1, 10, 0, 0, 0, time.UTC)
+	for i, title := range titles {
+		number := i + 1
+		mergedAt := merged.Add(time.Duration(i) * time.Minute)
+		client.PullRequests[testRepo][number] = &github.PullRequest{
+			Number:   github.Int(number),
+			Title:    github.String(title),
+			User:     &github.User{Login: github.String("author")},
+			MergedAt: &mergedAt,
+		}
+		sha := fmt.Sprintf("sha%d", number)
+		if err := client.AddCommitToPullRequest(testOrg, testRepo, number, sha); err != nil {
+			t.Fatalf("AddCommitToPullRequest() = %v", err)
+		}
+		comparison.Commits = append(comparison.Commits, github.RepositoryCommit{SHA: github.String(sha)})
+	}
+	comparison.Commits = append(comparison.Commits, github.RepositoryCommit{SHA: github.String("pushed")})
+	client.Comparisons["good...bad"] = comparison
+	return client
+}
+
+func TestCorrelate(t *testing.T) {
+	c := &commits{org: testOrg, repo: testRepo, client: newFakeCommits(t, "Add a feature", "Fix a bug")}
+
+	corr, err := c.correlate(CommitRange{Good: "good", Bad: "bad"})
+	if err != nil {
+		t.Fatalf("correlate() = %v", err)
+	}
+	want := `Changes since the last run without regression: https://github.com/knative/serving/compare/good...bad (3 commits)
+
+Merged PRs (2):
+- #2 Fix a bug (@author)
+- #1 Add a feature (@author)`
+	if got := corr.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	// The compare URL is still returned if the commits can't be compared.
+	corr, err = c.correlate(CommitRange{Good: "unknown", Bad: "bad"})
+	if err == nil {
+		t.Error("correlate() = nil, wanted an error")
+	}
+	if got, want := corr.CompareURL, "https://github.com/knative/serving/compare/unknown...bad"; got != want {
+		t.Errorf("CompareURL = %q, want %q", got, want)
+	}
+}
+
+// countingCommits counts the comparisons of commits.
+type countingCommits struct {
+	*fakeghutil.FakeGithubClient
+	comparisons int
+}
+
+func (c *countingCommits) CompareCommits(org, repo, base, head string) (*github.CommitsComparison, error) {
+	c.comparisons++
+	return c.FakeGithubClient.CompareCommits(org, repo, base, head)
+}
+
+func TestCorrelateCached(t *testing.T) {
+	client := &countingCommits{FakeGithubClient: newFakeCommits(t, "Add a feature")}
+	c := &commits{org: testOrg, repo: testRepo, client: client}
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.correlate(CommitRange{Good: "good", Bad: "bad"}); err != nil {
+			t.Fatalf("correlate() = %v", err)
+		}
+	}
+	if got, want := client.comparisons, 1; got != want {
+		t.Errorf("Compared the commits %d times, want %d", got, want)
+	}
+
+	// The failures aren't cached.
+	for i := 0; i < 2; i++ {
+		if _, err := c.correlate(CommitRange{Good: "unknown", Bad: "bad"}); err == nil {
+			t.Error("correlate() = nil, wanted an error")
+		}
+	}
+	if got, want := client.comparisons, 3; got != want {
+		t.Errorf("Compared the commits %d times, want %d", got, want)
+	}
+}
+
+func TestCorrelationTruncated(t *testing.T) {
+	titles := make([]string, maxPullRequests+5)
+	for i := range titles {
+		titles[i] = fmt.Sprintf("Change %d", i)
+	}
+	c := &commits{org: testOrg, repo: testRepo, client: newFakeCommits(t, titles...)}
+
+	corr, err := c.correlate(CommitRange{Good: "good", Bad: "bad"})
+	if err != nil {
+		t.Fatalf("correlate() = %v", err)
+	}
+	got := corr.String()
+	if n := strings.Count(got, "\n- #"); n != maxPullRequests {
+		t.Errorf("Listed %d PRs, want %d:\n%s", n, maxPullRequests, got)
+	}
+	if !strings.HasSuffix(got, "\n- ... and 5 more") {
+		t.Errorf("String() = %q, wanted the number of PRs left out", got)
+	}
+}
+
+// recordingIssueOperations records the descriptions of the issues created.
+type recordingIssueOperations struct {
+	fakeIssueOperations
+	descs []string
+}
+
+func (r *recordingIssueOperations) CreateIssueForTest(testName, runID, desc string) error {
+	r.descs = append(r.descs, desc)
+	return nil
+}
+
+func (r *recordingIssueOperations) ReportRegressions(batch string, regressions []alertergithub.Regression) error {
+	for _, regression := range regressions {
+		r.descs = append(r.descs, regression.Description)
+	}
+	return nil
+}
+
+func TestAlertWithCommits(t *testing.T) {
+	tests := []struct {
+		name  string
+		r     CommitRange
+		setup bool
+		want  bool
+	}{{
+		name:  "with commits",
+		r:     CommitRange{Good: "good", Bad: "bad"},
+		setup: true,
+		want:  true,
+	}, {
+		name: "not set up",
+		r:    CommitRange{Good: "good", Bad: "bad"},
+	}, {
+		name:  "no good run",
+		r:     CommitRange{Bad: "bad"},
+		setup: true,
+	}, {
+		name:  "same commit",
+		r:     CommitRange{Good: "bad", Bad: "bad"},
+		setup: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			issues := &recordingIssueOperations{}
+			alerter := &Alerter{githubIssueHandler: issues}
+			if test.setup {
+				alerter.SetCommitOperations(testOrg, testRepo, newFakeCommits(t, "Fix a bug"))
+			}
+			alerter.SetCommitRange(test.r)
+
+			if err := alerter.HandleAnalysis("test", "run", true, "regressed"); err != nil {
+				t.Fatalf("HandleAnalysis() = %v", err)
+			}
+			batch := alerter.NewBatch()
+			if err := batch.HandleAnalysis("test", "run", true, "regressed"); err != nil {
+				t.Fatalf("HandleAnalysis() = %v", err)
+			}
+			if err := batch.Flush(); err != nil {
+				t.Fatalf("Flush() = %v", err)
+			}
+
+			if got, want := len(issues.descs), 2; got != want {
+				t.Fatalf("Alerted %d times, want %d", got, want)
+			}
+			for _, desc := range issues.descs {
+				if got := strings.Contains(desc, "#1 Fix a bug"); got != test.want {
+					t.Errorf("Description %q lists the PRs: %v, want %v", desc, got, test.want)
+				}
+			}
+		})
+	}
+}
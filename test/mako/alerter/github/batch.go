@@ -0,0 +1,209 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"knative.dev/pkg/test/ghutil"
+	"knative.dev/pkg/test/helpers"
+	makoconfig "knative.dev/pkg/test/mako/config"
+)
+
+// Regression is a regression detected for a test, reported along with the
+// others of its batch by ReportRegressions.
+type Regression struct {
+	// TestName is the name of the test which regressed.
+	TestName string
+	// RunID is the ID of the Mako run the regression was detected in.
+	RunID string
+	// Description describes the regression.
+	Description string
+}
+
+// Suite groups the regressions of the tests of a suite, i.e. the tests whose
+// names share the same prefix up to the first `/` or space, e.g.
+// `dataplane-probe` for `dataplane-probe SLO p95-latency`.
+type Suite struct {
+	// Name is the name of the suite.
+	Name string
+	// Regressions are the regressions of the tests of the suite.
+	Regressions []Regression
+}
+
+// suiteName returns the name of the suite of the given test.
+func suiteName(testName string) string {
+	if i := strings.IndexAny(testName, "/ "); i > 0 {
+		return testName[:i]
+	}
+	return testName
+}
+
+// groupBySuite groups the regressions by test suite, sorted by name. The
+// regressions of each suite keep their order.
+func groupBySuite(regressions []Regression) []Suite {
+	var suites []Suite
+	index := make(map[string]int)
+	for _, r := range regressions {
+		name := suiteName(r.TestName)
+		i, ok := index[name]
+		if !ok {
+			i = len(suites)
+			index[name] = i
+			suites = append(suites, Suite{Name: name})
+		}
+		suites[i].Regressions = append(suites[i].Regressions, r)
+	}
+	sort.SliceStable(suites, func(i, j int) bool {
+		return suites[i].Name < suites[j].Name
+	})
+	return suites
+}
+
+// ReportRegressions reports the regressions detected in the given batch, e.g.
+// a job run, at once. They're grouped by test suite in a single report per
+// repository the tests are routed to, which is the body of a new digest issue
+// of the batch with makoconfig.BatchIssue, or a single comment of the digest
+// issue of the run or the day with makoconfig.BatchComment. The regressions
+// which were already reported are skipped, so that a batch can be retried.
+// Without a batch mode, each regression is handled like by CreateIssueForTest.
+func (gih *IssueHandler) ReportRegressions(batch string, regressions []Regression) error {
+	var errs []error
+	if gih.config.batch == "" {
+		for _, r := range regressions {
+			if err := gih.CreateIssueForTest(r.TestName, r.RunID, r.Description); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return helpers.CombineErrors(errs)
+	}
+
+	// Group the regressions by the repository they're routed to, in order.
+	var repos []string
+	handlers := make(map[string]*IssueHandler)
+	byRepo := make(map[string][]Regression)
+	for _, r := range regressions {
+		routed := gih.forTest(r.TestName)
+		repo := routed.config.org + "/" + routed.config.repo
+		if _, ok := handlers[repo]; !ok {
+			repos = append(repos, repo)
+			handlers[repo] = routed
+		}
+		byRepo[repo] = append(byRepo[repo], r)
+	}
+	for _, repo := range repos {
+		if err := handlers[repo].reportBatch(batch, byRepo[repo]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return helpers.CombineErrors(errs)
+}
+
+// reportBatch reports the given regressions of the batch in the repository
+// of the handler, creating or reopening the digest issue if needed. Only the
+// creation of the digest issue mentions the configured Github users or teams.
+func (gih *IssueHandler) reportBatch(batch string, regressions []Regression) error {
+	period := batch
+	if gih.config.batch == makoconfig.BatchComment {
+		period = gih.digestPeriod(regressions[0].RunID)
+	}
+	issue, md, err := gih.findIssue(digestPrefix + period)
+	if err != nil {
+		return fmt.Errorf("failed to find the digest issue of %q: %v, skipped reporting %d regressions", period, err, len(regressions))
+	}
+
+	// If some regressions have already been reported, only report the others.
+	if issue != nil {
+		var unreported []Regression
+		for _, r := range regressions {
+			if !md.isDigested(r.TestName, r.RunID) {
+				unreported = append(unreported, r)
+			}
+		}
+		if len(unreported) == 0 {
+			return nil
+		}
+		regressions = unreported
+	}
+	data := gih.templateData("")
+	data.Period = period
+	data.Suites = groupBySuite(regressions)
+	report, err := execute(gih.templates().DigestReport, data)
+	if err != nil {
+		return err
+	}
+
+	if issue == nil {
+		md = &issueMetadata{TestName: digestPrefix + period, Severity: gih.config.severity}
+		title, err := execute(gih.templates().DigestTitle, data)
+		if err != nil {
+			return err
+		}
+		body, err := execute(gih.templates().DigestBody, data)
+		if err != nil {
+			return err
+		}
+		// The report of a new issue of the batch is its body, so the
+		// regressions are recorded along with it.
+		newBatch := gih.config.batch == makoconfig.BatchIssue
+		if newBatch {
+			body += "\n" + report
+			for _, r := range regressions {
+				md.addDigested(r.TestName, r.RunID)
+			}
+		}
+		issueBody, err := gih.issueBody(digestPrefix+period, body, md)
+		if err != nil {
+			return err
+		}
+		issue, err = gih.createNewIssue(title, issueBody)
+		if err != nil {
+			return fmt.Errorf("failed to create the digest issue of %q: %v", period, err)
+		}
+		if issue == nil || newBatch {
+			// Nothing was created in dry run, or there is nothing left to report.
+			return nil
+		}
+	}
+
+	issueNumber := issue.GetNumber()
+	if issue.GetState() == string(ghutil.IssueCloseState) {
+		if err := gih.reopenIssue(issueNumber); err != nil {
+			return fmt.Errorf("failed to reopen digest issue %d: %v", issueNumber, err)
+		}
+	}
+	if report, err = gih.fitComment(digestPrefix+period, report); err != nil {
+		return err
+	}
+	if err := gih.addComment(issueNumber, report); err != nil {
+		return fmt.Errorf("failed to add the report of %d regressions to digest issue %d: %v", len(regressions), issueNumber, err)
+	}
+
+	for _, r := range regressions {
+		md.addDigested(r.TestName, r.RunID)
+	}
+	issueBody, err := embedMetadata(issue.GetBody(), md)
+	if err != nil {
+		return err
+	}
+	if err := gih.editIssueBody(issueNumber, issueBody); err != nil {
+		return fmt.Errorf("failed to update the metadata of digest issue %d: %v", issueNumber, err)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"knative.dev/pkg/test/ghutil/fakeghutil"
+	makoconfig "knative.dev/pkg/test/mako/config"
+)
+
+var testRegressions = []Regression{
+	{TestName: "serving-dataplane", RunID: "run1", Description: "latency regressed"},
+	{TestName: "eventing/broker", RunID: "run1", Description: "throughput regressed"},
+	{TestName: "serving-dataplane SLO p95", RunID: "run1", Description: "SLO violated"},
+}
+
+func TestGroupBySuite(t *testing.T) {
+	got := groupBySuite(testRegressions)
+	want := []Suite{{
+		Name:        "eventing",
+		Regressions: []Regression{testRegressions[1]},
+	}, {
+		Name:        "serving-dataplane",
+		Regressions: []Regression{testRegressions[0], testRegressions[2]},
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("groupBySuite() (-want, +got) = %s", diff)
+	}
+}
+
+func TestReportRegressionsInIssue(t *testing.T) {
+	client := fakeghutil.NewFakeGithubClient()
+	handler := &IssueHandler{
+		client: client,
+		config: config{org: "test_org", repo: "test_repo", batch: makoconfig.BatchIssue},
+	}
+
+	if err := handler.ReportRegressions("job #1", testRegressions); err != nil {
+		t.Fatalf("ReportRegressions() = %v", err)
+	}
+	if got, want := len(client.Issues["test_repo"]), 1; got != want {
+		t.Fatalf("len(issues) = %d, want %d", got, want)
+	}
+	issue, md, err := handler.findIssue(digestPrefix + "job #1")
+	if err != nil || issue == nil {
+		t.Fatalf("findIssue() = %v, %v, wanted the digest issue of the batch", issue, err)
+	}
+	body := issue.GetBody()
+	for _, want := range []string{"#### eventing (1)", "#### serving-dataplane (2)", "<details><summary>serving-dataplane SLO p95</summary>", "SLO violated"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Body = %q, wanted it to contain %q", body, want)
+		}
+	}
+	if got, want := md.Occurrences, 3; got != want {
+		t.Errorf("Occurrences = %d, want %d", got, want)
+	}
+
+	// Retrying the batch doesn't report the regressions again.
+	if err := handler.ReportRegressions("job #1", testRegressions); err != nil {
+		t.Fatalf("ReportRegressions() = %v", err)
+	}
+	if comments, _ := client.ListComments("test_org", "test_repo", issue.GetNumber()); len(comments) != 0 {
+		t.Errorf("len(comments) = %d, want 0", len(comments))
+	}
+}
+
+func TestReportRegressionsInComment(t *testing.T) {
+	client := fakeghutil.NewFakeGithubClient()
+	handler := &IssueHandler{
+		client: client,
+		config: config{org: "test_org", repo: "test_repo", digest: makoconfig.DigestPerRun, batch: makoconfig.BatchComment},
+	}
+
+	if err := handler.ReportRegressions("job #1", testRegressions[:2]); err != nil {
+		t.Fatalf("ReportRegressions() = %v", err)
+	}
+	if err := handler.ReportRegressions("job #1", testRegressions); err != nil {
+		t.Fatalf("ReportRegressions() = %v", err)
+	}
+	if got, want := len(client.Issues["test_repo"]), 1; got != want {
+		t.Fatalf("len(issues) = %d, want %d", got, want)
+	}
+	issue, md, err := handler.findIssue(digestPrefix + "run run1")
+	if err != nil || issue == nil {
+		t.Fatalf("findIssue() = %v, %v, wanted the digest issue of the run", issue, err)
+	}
+	if got, want := md.Occurrences, 3; got != want {
+		t.Errorf("Occurrences = %d, want %d", got, want)
+	}
+	// A comment per batch, with only the regressions not reported yet.
+	comments, _ := client.ListComments("test_org", "test_repo", issue.GetNumber())
+	if got, want := len(comments), 2; got != want {
+		t.Fatalf("len(comments) = %d, want %d", got, want)
+	}
+	if got := comments[1].GetBody(); strings.Contains(got, "eventing") || !strings.Contains(got, "SLO violated") {
+		t.Errorf("comments[1] = %q, wanted only the new regression", got)
+	}
+}
+
+func TestReportRegressionsRouted(t *testing.T) {
+	client := fakeghutil.NewFakeGithubClient()
+	handler := &IssueHandler{
+		client: client,
+		config: config{org: "test_org", repo: "test_repo", batch: makoconfig.BatchIssue, routes: []makoconfig.GithubRoute{{
+			Pattern: "eventing/*",
+			Org:     "test_org",
+			Repo:    "eventing",
+		}}},
+	}
+
+	if err := handler.ReportRegressions("job #1", testRegressions); err != nil {
+		t.Fatalf("ReportRegressions() = %v", err)
+	}
+	for repo, want := range map[string]int{"test_repo": 1, "eventing": 1} {
+		if got := len(client.Issues[repo]); got != want {
+			t.Errorf("len(%s issues) = %d, want %d", repo, got, want)
+		}
+	}
+}
+
+func TestReportRegressionsWithoutBatch(t *testing.T) {
+	client := fakeghutil.NewFakeGithubClient()
+	handler := &IssueHandler{
+		client: client,
+		config: config{org: "test_org", repo: "test_repo"},
+	}
+
+	if err := handler.ReportRegressions("job #1", testRegressions); err != nil {
+		t.Fatalf("ReportRegressions() = %v", err)
+	}
+	if got, want := len(client.Issues["test_repo"]), len(testRegressions); got != want {
+		t.Errorf("len(issues) = %d, want %d", got, want)
+	}
+}
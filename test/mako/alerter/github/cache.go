@@ -0,0 +1,112 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// defaultCacheTTL is the default duration for which the issues listed in a
+// repository are reused, long enough for a single alerter run.
+const defaultCacheTTL = 10 * time.Minute
+
+// issueCache caches the performance issues of the repositories, so that they
+// are only listed once when many tests are alerted on at once. The issues are
+// updated in place when the alerter changes them.
+type issueCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*issueCacheEntry
+}
+
+type issueCacheEntry struct {
+	issues  []*github.Issue
+	fetched time.Time
+}
+
+// newIssueCache creates an issueCache keeping the issues for the given
+// duration, or not at all if it is negative.
+func newIssueCache(ttl time.Duration) *issueCache {
+	if ttl == 0 {
+		ttl = defaultCacheTTL
+	}
+	return &issueCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]*issueCacheEntry),
+	}
+}
+
+// list returns the issues of the given repository, calling fetch to list them
+// if they aren't cached or have expired.
+func (c *issueCache) list(org, repo string, fetch func() ([]*github.Issue, error)) ([]*github.Issue, error) {
+	if c == nil || c.ttl < 0 {
+		return fetch()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := org + "/" + repo
+	if e, ok := c.entries[key]; ok && c.now().Sub(e.fetched) < c.ttl {
+		return e.issues, nil
+	}
+	issues, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	c.entries[key] = &issueCacheEntry{issues: issues, fetched: c.now()}
+	return issues, nil
+}
+
+// add adds a newly created issue to the cached issues of the given repository.
+func (c *issueCache) add(org, repo string, issue *github.Issue) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[org+"/"+repo]; ok {
+		e.issues = append(e.issues, issue)
+	}
+}
+
+// update calls mutate with the given issue of the given repository, if cached.
+func (c *issueCache) update(org, repo string, issueNumber int, mutate func(*github.Issue)) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[org+"/"+repo]
+	if !ok {
+		return
+	}
+	for _, issue := range e.issues {
+		if issue.GetNumber() == issueNumber {
+			mutate(issue)
+			now := c.now()
+			issue.UpdatedAt = &now
+		}
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"testing"
+	"time"
+
+	"knative.dev/pkg/test/ghutil/fakeghutil"
+)
+
+func TestIssueCache(t *testing.T) {
+	client := fakeghutil.NewFakeGithubClient()
+	cache := newIssueCache(0)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	handler := &IssueHandler{
+		client: client,
+		config: config{org: "test_org", repo: "test_repo"},
+		cache:  cache,
+	}
+
+	// The issues are only searched once for all of the tests.
+	for _, testName := range []string{"test1", "test2", "test3"} {
+		if err := handler.CreateIssueForTest(testName, "run1", "regressed"); err != nil {
+			t.Fatalf("CreateIssueForTest(%q) = %v", testName, err)
+		}
+	}
+	if got, want := client.SearchCount, 1; got != want {
+		t.Errorf("SearchCount = %d, want %d", got, want)
+	}
+
+	// The issues created and changed by the handler are up to date in the cache.
+	issue, md, err := handler.findIssue("test2")
+	if err != nil || issue == nil {
+		t.Fatalf("findIssue() = %v, %v, wanted the issue created for the test", issue, err)
+	}
+	if got, want := md.LastAlertedRunID, "run1"; got != want {
+		t.Errorf("LastAlertedRunID = %q, want %q", got, want)
+	}
+	if err := handler.CreateIssueForTest("test2", "run2", "regressed again"); err != nil {
+		t.Fatalf("CreateIssueForTest() = %v", err)
+	}
+	if _, md, _ := handler.findIssue("test2"); md.Occurrences != 2 {
+		t.Errorf("Occurrences = %d, want 2", md.Occurrences)
+	}
+	if got, want := client.SearchCount, 1; got != want {
+		t.Errorf("SearchCount = %d, want %d", got, want)
+	}
+
+	// Once expired, the issues are searched again.
+	now = now.Add(defaultCacheTTL)
+	if _, _, err := handler.findIssue("test1"); err != nil {
+		t.Fatalf("findIssue() = %v", err)
+	}
+	if got, want := client.SearchCount, 2; got != want {
+		t.Errorf("SearchCount = %d, want %d", got, want)
+	}
+}
+
+func TestIssueCacheDisabled(t *testing.T) {
+	client := fakeghutil.NewFakeGithubClient()
+	handler := &IssueHandler{
+		client: client,
+		config: config{org: "test_org", repo: "test_repo"},
+		cache:  newIssueCache(-1),
+	}
+	for i := 0; i < 2; i++ {
+		if _, _, err := handler.findIssue("test"); err != nil {
+			t.Fatalf("findIssue() = %v", err)
+		}
+	}
+	if got, want := client.SearchCount, 2; got != want {
+		t.Errorf("SearchCount = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"fmt"
+	"time"
+
+	"knative.dev/pkg/test/ghutil"
+	makoconfig "knative.dev/pkg/test/mako/config"
+)
+
+// digestPrefix prefixes the names the digest issues are tracked by in their
+// metadata, so they can't be mistaken for the issue of a test.
+const digestPrefix = "digest "
+
+// digestPeriod returns the period the regression detected in the given run is
+// batched for, i.e. the run or the current day in UTC. Regressions without a
+// run ID are batched per day.
+func (gih *IssueHandler) digestPeriod(runID string) string {
+	if gih.config.digest == makoconfig.DigestPerRun && runID != "" {
+		return "run " + runID
+	}
+	return time.Now().UTC().Format("2006-01-02")
+}
+
+// addToDigest adds the regression of the given test, detected in the given run,
+// to the digest issue of the period, creating or reopening it if needed.
+// Only the creation of the digest issue mentions the configured Github users
+// or teams, to notify them once per period.
+func (gih *IssueHandler) addToDigest(testName, runID, desc string) error {
+	period := gih.digestPeriod(runID)
+	issue, md, err := gih.findIssue(digestPrefix + period)
+	if err != nil {
+		return fmt.Errorf("failed to find the digest issue of %q: %v, skipped adding test %q", period, err, testName)
+	}
+	data := gih.templateData(testName)
+	data.Description = desc
+	data.Period = period
+	entry, err := execute(gih.templates().DigestEntry, data)
+	if err != nil {
+		return err
+	}
+	if entry, err = gih.fitComment(testName, entry); err != nil {
+		return err
+	}
+
+	// If the digest issue hasn't been created, create one. The regression is
+	// recorded in its metadata once added below, like for the existing issues.
+	if issue == nil {
+		md = &issueMetadata{TestName: digestPrefix + period, Severity: gih.config.severity}
+		title, err := execute(gih.templates().DigestTitle, data)
+		if err != nil {
+			return err
+		}
+		body, err := execute(gih.templates().DigestBody, data)
+		if err != nil {
+			return err
+		}
+		issueBody, err := gih.issueBody(digestPrefix+period, body, md)
+		if err != nil {
+			return err
+		}
+		issue, err = gih.createNewIssue(title, issueBody)
+		if err != nil {
+			return fmt.Errorf("failed to create the digest issue of %q: %v", period, err)
+		}
+		if issue == nil {
+			// Nothing was created in dry run.
+			return nil
+		}
+	}
+
+	// If the regression has already been added, there is nothing new to report
+	if md.isDigested(testName, runID) {
+		return nil
+	}
+
+	issueNumber := issue.GetNumber()
+	if issue.GetState() == string(ghutil.IssueCloseState) {
+		if err := gih.reopenIssue(issueNumber); err != nil {
+			return fmt.Errorf("failed to reopen digest issue %d: %v", issueNumber, err)
+		}
+	}
+	if err := gih.addComment(issueNumber, entry); err != nil {
+		return fmt.Errorf("failed to add comment for test %q to digest issue %d: %v", testName, issueNumber, err)
+	}
+
+	md.addDigested(testName, runID)
+	issueBody, err := embedMetadata(issue.GetBody(), md)
+	if err != nil {
+		return err
+	}
+	if err := gih.editIssueBody(issueNumber, issueBody); err != nil {
+		return fmt.Errorf("failed to update the metadata of digest issue %d: %v", issueNumber, err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"knative.dev/pkg/test/ghutil/fakeghutil"
+	makoconfig "knative.dev/pkg/test/mako/config"
+)
+
+func TestDigestPerRun(t *testing.T) {
+	client := fakeghutil.NewFakeGithubClient()
+	handler := &IssueHandler{
+		client: client,
+		config: config{org: "test_org", repo: "test_repo", digest: makoconfig.DigestPerRun},
+	}
+
+	for _, testName := range []string{"test1", "test2", "test1"} {
+		if err := handler.CreateIssueForTest(testName, "run1", "regressed"); err != nil {
+			t.Fatalf("CreateIssueForTest(%q) = %v", testName, err)
+		}
+	}
+	if got, want := len(client.Issues["test_repo"]), 1; got != want {
+		t.Fatalf("len(issues) = %d, want %d", got, want)
+	}
+	issue, md, err := handler.findIssue(digestPrefix + "run run1")
+	if err != nil || issue == nil {
+		t.Fatalf("findIssue() = %v, %v, wanted the digest issue of the run", issue, err)
+	}
+	if got, want := issue.GetTitle(), "[performance] Regressions of run run1"; got != want {
+		t.Errorf("Title = %q, want %q", got, want)
+	}
+	if got, want := md.Occurrences, 2; got != want {
+		t.Errorf("Occurrences = %d, want %d", got, want)
+	}
+	comments, _ := client.ListComments("test_org", "test_repo", issue.GetNumber())
+	if got, want := len(comments), 2; got != want {
+		t.Fatalf("len(comments) = %d, want %d", got, want)
+	}
+	for i, testName := range []string{"test1", "test2"} {
+		if !strings.Contains(comments[i].GetBody(), "**"+testName+"**") {
+			t.Errorf("comments[%d] = %q, wanted it to be about %q", i, comments[i].GetBody(), testName)
+		}
+	}
+
+	// The regressions of the next run go into another issue.
+	if err := handler.CreateIssueForTest("test1", "run2", "regressed"); err != nil {
+		t.Fatalf("CreateIssueForTest() = %v", err)
+	}
+	if got, want := len(client.Issues["test_repo"]), 2; got != want {
+		t.Errorf("len(issues) = %d, want %d", got, want)
+	}
+}
+
+func TestDigestDailyRoute(t *testing.T) {
+	client := fakeghutil.NewFakeGithubClient()
+	handler := &IssueHandler{
+		client: client,
+		config: config{org: "test_org", repo: "test_repo", routes: []makoconfig.GithubRoute{{
+			Pattern: "eventing-*",
+			Org:     "test_org",
+			Repo:    "eventing",
+			Digest:  makoconfig.DigestDaily,
+		}}},
+	}
+
+	for _, testName := range []string{"eventing-a", "eventing-b", "serving-a"} {
+		if err := handler.CreateIssueForTest(testName, "run1", "regressed"); err != nil {
+			t.Fatalf("CreateIssueForTest(%q) = %v", testName, err)
+		}
+	}
+	// The routed tests share the digest issue of the day, the others get their own.
+	if got, want := len(client.Issues["eventing"]), 1; got != want {
+		t.Errorf("len(eventing issues) = %d, want %d", got, want)
+	}
+	if got, want := len(client.Issues["test_repo"]), 1; got != want {
+		t.Errorf("len(test_repo issues) = %d, want %d", got, want)
+	}
+	period := time.Now().UTC().Format("2006-01-02")
+	if issue, _, _ := handler.forTest("eventing-a").findIssue(digestPrefix + period); issue == nil {
+		t.Errorf("No digest issue found for %s", period)
+	}
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fake fakes the Github issue operations of the alerter, for the tests
+// of the benchmarks to check their alerts without Github.
+package fake
+
+import (
+	"sort"
+	"sync"
+
+	"knative.dev/pkg/test/ghutil"
+	"knative.dev/pkg/test/mako/alerter/github"
+)
+
+// defaultRecoveryRuns is the number of consecutive runs without regression
+// after which an issue is resolved, like the IssueHandler's default.
+const defaultRecoveryRuns = 3
+
+// Issue is the issue of a test kept by FakeIssueOperations.
+type Issue struct {
+	// TestName is the name of the test of the issue.
+	TestName string
+	// State is the state of the issue, i.e. `open` or `closed`.
+	State ghutil.IssueStateEnum
+	// Occurrences is the number of runs the test regressed in.
+	Occurrences int
+	// LastRunID is the ID of the last run the test regressed in.
+	LastRunID string
+	// ConsecutivePasses is the number of runs without regression since the
+	// last regression.
+	ConsecutivePasses int
+	// Descriptions are the descriptions of the regressions, in order.
+	Descriptions []string
+}
+
+// FakeIssueOperations implements github.IssueOperations with the issues kept
+// in memory, one per test, following the IssueHandler's lifecycle.
+type FakeIssueOperations struct {
+	// RecoveryRuns is the number of consecutive runs without regression
+	// after which an issue is resolved. Defaults to 3.
+	RecoveryRuns int
+	// Err, if set, fails all the operations.
+	Err error
+
+	mu     sync.Mutex
+	issues map[string]*Issue
+	// reports are the regressions reported by batch.
+	reports map[string][]github.Regression
+}
+
+var _ github.IssueOperations = (*FakeIssueOperations)(nil)
+
+// NewFakeIssueOperations creates a FakeIssueOperations without issues.
+func NewFakeIssueOperations() *FakeIssueOperations {
+	return &FakeIssueOperations{
+		issues:  make(map[string]*Issue),
+		reports: make(map[string][]github.Regression),
+	}
+}
+
+// CreateIssueForTest creates the issue of the test for the regression, or
+// updates it, reopening it if closed. A run is only counted once.
+func (f *FakeIssueOperations) CreateIssueForTest(testName, runID, desc string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	issue, ok := f.issues[testName]
+	if !ok {
+		issue = &Issue{TestName: testName, State: ghutil.IssueOpenState}
+		f.issues[testName] = issue
+	}
+	if runID != "" && issue.LastRunID == runID {
+		return nil
+	}
+	issue.State = ghutil.IssueOpenState
+	issue.Occurrences++
+	issue.LastRunID = runID
+	issue.ConsecutivePasses = 0
+	issue.Descriptions = append(issue.Descriptions, desc)
+	return nil
+}
+
+// CloseIssueForTest closes the issue of the test, if any.
+func (f *FakeIssueOperations) CloseIssueForTest(testName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	if issue, ok := f.issues[testName]; ok {
+		issue.State = ghutil.IssueCloseState
+	}
+	return nil
+}
+
+// ResolveIssue records a run of the test without regression, and closes its
+// issue once enough consecutive runs were clean.
+func (f *FakeIssueOperations) ResolveIssue(testName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	issue, ok := f.issues[testName]
+	if !ok || issue.State == ghutil.IssueCloseState {
+		return nil
+	}
+	issue.ConsecutivePasses++
+	recoveryRuns := f.RecoveryRuns
+	if recoveryRuns == 0 {
+		recoveryRuns = defaultRecoveryRuns
+	}
+	if issue.ConsecutivePasses >= recoveryRuns {
+		issue.State = ghutil.IssueCloseState
+	}
+	return nil
+}
+
+// ReportRegressions records the regressions of the batch which weren't
+// reported yet.
+func (f *FakeIssueOperations) ReportRegressions(batch string, regressions []github.Regression) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	for _, r := range regressions {
+		reported := false
+		for _, existing := range f.reports[batch] {
+			if existing.TestName == r.TestName && existing.RunID == r.RunID {
+				reported = true
+				break
+			}
+		}
+		if !reported {
+			f.reports[batch] = append(f.reports[batch], r)
+		}
+	}
+	return nil
+}
+
+// Issue returns a copy of the issue of the test, or nil if there is none.
+func (f *FakeIssueOperations) Issue(testName string) *Issue {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	issue, ok := f.issues[testName]
+	if !ok {
+		return nil
+	}
+	c := *issue
+	c.Descriptions = append([]string(nil), issue.Descriptions...)
+	return &c
+}
+
+// OpenIssues returns the names of the tests whose issues are open.
+func (f *FakeIssueOperations) OpenIssues() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var names []string
+	for name, issue := range f.issues {
+		if issue.State == ghutil.IssueOpenState {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Reported returns the regressions reported for the batch.
+func (f *FakeIssueOperations) Reported(batch string) []github.Regression {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]github.Regression(nil), f.reports[batch]...)
+}
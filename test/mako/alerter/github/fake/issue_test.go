@@ -0,0 +1,89 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"knative.dev/pkg/test/ghutil"
+	"knative.dev/pkg/test/mako/alerter"
+	"knative.dev/pkg/test/mako/alerter/github"
+)
+
+func TestAlerts(t *testing.T) {
+	issues := NewFakeIssueOperations()
+	issues.RecoveryRuns = 2
+	a := &alerter.Alerter{}
+	a.SetGitHubIssueOperations(issues)
+
+	runs := []struct {
+		runID     string
+		regressed bool
+	}{{"run1", true}, {"run1", true}, {"run2", false}, {"run3", true}, {"run4", false}}
+	for _, run := range runs {
+		if err := a.HandleAnalysis("test", run.runID, run.regressed, "regressed in "+run.runID); err != nil {
+			t.Fatalf("HandleAnalysis(%s) = %v", run.runID, err)
+		}
+	}
+	want := &Issue{
+		TestName:          "test",
+		State:             ghutil.IssueOpenState,
+		Occurrences:       2,
+		LastRunID:         "run3",
+		ConsecutivePasses: 1,
+		Descriptions:      []string{"regressed in run1", "regressed in run3"},
+	}
+	if diff := cmp.Diff(want, issues.Issue("test")); diff != "" {
+		t.Errorf("Issue (-want, +got): %s", diff)
+	}
+
+	if err := a.HandleAnalysis("test", "run5", false, ""); err != nil {
+		t.Fatalf("HandleAnalysis(run5) = %v", err)
+	}
+	if got := issues.OpenIssues(); len(got) != 0 {
+		t.Errorf("OpenIssues() = %v, wanted the issue resolved", got)
+	}
+	if got := issues.Issue("other"); got != nil {
+		t.Errorf("Issue(other) = %+v, want nil", got)
+	}
+}
+
+func TestReportRegressions(t *testing.T) {
+	issues := NewFakeIssueOperations()
+	regressions := []github.Regression{
+		{TestName: "test1", RunID: "run1", Description: "regressed"},
+		{TestName: "test2", RunID: "run1", Description: "regressed"},
+	}
+	if err := issues.ReportRegressions("job", regressions); err != nil {
+		t.Fatalf("ReportRegressions() = %v", err)
+	}
+	// Retrying a batch doesn't report its regressions twice.
+	if err := issues.ReportRegressions("job", regressions[1:]); err != nil {
+		t.Fatalf("ReportRegressions() = %v", err)
+	}
+	if diff := cmp.Diff(regressions, issues.Reported("job")); diff != "" {
+		t.Errorf("Reported (-want, +got): %s", diff)
+	}
+
+	issues.Err = errors.New("github is down")
+	if err := issues.CreateIssueForTest("test1", "run2", ""); err == nil {
+		t.Error("CreateIssueForTest() = nil, wanted an error")
+	}
+}
@@ -17,14 +17,19 @@ limitations under the License.
 package github
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/google/go-github/github"
 
+	"knative.dev/pkg/test/gcs"
 	"knative.dev/pkg/test/ghutil"
 	"knative.dev/pkg/test/helpers"
+	makoconfig "knative.dev/pkg/test/mako/config"
 )
 
 const (
@@ -38,133 +43,365 @@ const (
 	// (update, comment, etc.) on it for a specified time
 	daysConsideredActive = 3
 
-	// issueTitleTemplate is a template for issue title
-	issueTitleTemplate = "[performance] %s"
+	// defaultRecoveryRuns is the default number of consecutive runs without
+	// regression after which an issue is resolved
+	defaultRecoveryRuns = 3
 
-	// issueBodyTemplate is a template for issue body
-	issueBodyTemplate = `
-### Auto-generated issue tracking performance regression
-* **Test name**: %s
-* **Repository name**: %s`
+	// mentionTemplate is a template for the line mentioning the people to notify
+	mentionTemplate = `
 
-	// issueSummaryCommentTemplate is a template for the summary of an issue
-	issueSummaryCommentTemplate = `
-A new regression for this test has been detected:
-%s`
-
-	// reopenIssueCommentTemplate is a template for the comment of an issue that is reopened
-	reopenIssueCommentTemplate = `
-New regression has been detected, reopening this issue:
-%s`
-
-	// closeIssueComment is the comment of an issue when it is closed
-	closeIssueComment = `
-The performance regression goes away for this test, closing this issue.`
+/cc %s`
 )
 
+// IssueOperations defines the operations on the Github issues tracking the
+// performance regressions of the tests
+type IssueOperations interface {
+	// CreateIssueForTest creates or updates the issue of the test for the
+	// regression detected in the given run.
+	CreateIssueForTest(testName, runID, desc string) error
+	// CloseIssueForTest closes the issue of the test, unless it's still active.
+	CloseIssueForTest(testName string) error
+	// ResolveIssue records a run of the test without regression, and closes
+	// its issue once enough consecutive runs were clean.
+	ResolveIssue(testName string) error
+	// ReportRegressions reports the regressions detected in a batch, e.g.
+	// a job run, at once.
+	ReportRegressions(batch string, regressions []Regression) error
+}
+
 // IssueHandler handles methods for github issues
 type IssueHandler struct {
 	client ghutil.GithubOperations
 	config config
+	// cache caches the issues of the repositories, shared with the handlers of the routes
+	cache *issueCache
 }
 
+var _ IssueOperations = (*IssueHandler)(nil)
+
 // config is the global config that can be used in Github operations
 type config struct {
-	org    string
-	repo   string
+	org  string
+	repo string
+	// severity is the severity of the regressions, recorded in the issue metadata
+	severity string
+	// mentions are the Github users or teams to mention when creating or reopening issues
+	mentions []string
+	// routes route the issues of some tests to other repositories
+	routes []makoconfig.GithubRoute
+	// recoveryRuns is the number of consecutive runs without regression after which issues are resolved
+	recoveryRuns int
+	// templates are the templates of the issues and their comments
+	templates Templates
+	// labels are the labels added to new issues along with perfLabel
+	labels []string
+	// assignees are the Github users assigned to new issues
+	assignees []string
+	// digest batches the regressions in an issue per run or per day, if set
+	digest string
+	// batch reports the regressions of a batch in a new issue or a single comment, if set
+	batch string
+	// overflow stores the full text of the bodies and comments too long for Github, if set
+	overflow gcs.ArtifactStore
+	// maxLength is the maximum length of the bodies and comments
+	maxLength int
+	// retry is the retry policy of the Github calls which fail transiently
+	retry helpers.RetryPolicy
+	// ctx bounds the retries of the Github calls and the writes to overflow
+	ctx    context.Context
 	dryrun bool
 }
 
+// Options holds the optional settings of an IssueHandler.
+type Options struct {
+	// Severity is the severity of the regressions of the tests, e.g. `p1`.
+	Severity string
+	// Mentions are the Github users or teams, e.g. `@knative/serving-wg-leads`,
+	// to mention when creating or reopening issues, unless in dry run.
+	Mentions []string
+	// Routes route the issues of the tests matching their patterns to other
+	// repositories than the default one. The first matching route applies.
+	Routes []makoconfig.GithubRoute
+	// RecoveryRuns is the number of consecutive runs without regression
+	// after which the issue of a test is resolved. Defaults to 3.
+	RecoveryRuns int
+	// Templates customize the issues and their comments. The templates
+	// which aren't set default to the built-in ones.
+	Templates Templates
+	// Labels are added to the new issues, in addition to `auto:perf`.
+	Labels []string
+	// Assignees are the Github users assigned to the new issues.
+	Assignees []string
+	// CacheTTL is how long the issues listed in a repository are reused
+	// for, which avoids listing them for every test. Defaults to 10 minutes,
+	// a negative TTL disables the cache.
+	CacheTTL time.Duration
+	// Digest batches the regressions filed in the default repository in a
+	// single issue per run or per day, see makoconfig.DigestPerRun and
+	// makoconfig.DigestDaily, instead of an issue per test. The routes
+	// have their own digest mode.
+	Digest string
+	// Batch sets how ReportRegressions reports the regressions of a batch,
+	// see makoconfig.BatchIssue and makoconfig.BatchComment. Without it,
+	// the regressions are handled one by one like by CreateIssueForTest.
+	Batch string
+	// Auth authenticates to Github, e.g. as a Github App installation with
+	// ghutil.AppAuth. Defaults to the personal access token passed to Setup.
+	Auth ghutil.Auth
+	// BaseURL is the URL of the API of a Github Enterprise server, e.g.
+	// `https://github.example.com/api/v3/`. Defaults to github.com.
+	BaseURL string
+	// Retry is the retry policy of the Github calls. Only the calls failing
+	// transiently are retried, see ghutil.IsTransientError, and the creation
+	// of issues only if rejected by a rate limit. Defaults to
+	// helpers.DefaultRetryPolicy.
+	Retry *helpers.RetryPolicy
+	// Recorder, if set, records the changes to the issues instead of making
+	// them, while still reading the issues from Github, even in dry run.
+	// Unlike a plain dry run, the changes can then be checked, see
+	// Recorder.Operations.
+	Recorder *Recorder
+	// Overflow, if set, stores the full text of the bodies and comments of
+	// the issues too long for Github, e.g. the descriptions of many
+	// regressions, which are then truncated and link to it. Without it,
+	// they're only truncated.
+	Overflow gcs.ArtifactStore
+	// MaxLength is the maximum length of the bodies and comments of the
+	// issues, in bytes. Defaults to the limit of Github, 65536.
+	MaxLength int
+	// Context bounds the retries of the Github calls and the writes of the
+	// overflowing texts, e.g. to give up on alerting before the benchmark
+	// times out. Defaults to context.Background().
+	Context context.Context
+}
+
 // Setup creates the necessary setup to make calls to work with github issues
-func Setup(org, repo, githubTokenPath string, dryrun bool) (*IssueHandler, error) {
+func Setup(org, repo, githubTokenPath string, opts Options, dryrun bool) (*IssueHandler, error) {
 	if org == "" {
 		return nil, errors.New("org cannot be empty")
 	}
 	if repo == "" {
 		return nil, errors.New("repo cannot be empty")
 	}
-	ghc, err := ghutil.NewGithubClient(githubTokenPath)
+	for _, route := range opts.Routes {
+		if err := route.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	auth := opts.Auth
+	if auth == nil {
+		auth = ghutil.TokenAuth{TokenFilePath: githubTokenPath}
+	}
+	ghc, err := ghutil.NewGithubClientWithOptions(ghutil.ClientOptions{Auth: auth, BaseURL: opts.BaseURL})
 	if err != nil {
 		return nil, fmt.Errorf("cannot authenticate to github: %v", err)
 	}
-	conf := config{org: org, repo: repo, dryrun: dryrun}
-	return &IssueHandler{client: ghc, config: conf}, nil
+	var client ghutil.GithubOperations = ghc
+	if opts.Recorder != nil {
+		client = opts.Recorder.client(ghc)
+	}
+	if err := makoconfig.ValidateDigest(opts.Digest); err != nil {
+		return nil, err
+	}
+	if err := makoconfig.ValidateBatch(opts.Batch); err != nil {
+		return nil, err
+	}
+	if opts.RecoveryRuns < 0 {
+		return nil, fmt.Errorf("recovery runs cannot be negative, got %d", opts.RecoveryRuns)
+	}
+	if opts.MaxLength < 0 {
+		return nil, fmt.Errorf("max length cannot be negative, got %d", opts.MaxLength)
+	}
+	conf := config{org: org, repo: repo, severity: opts.Severity, mentions: opts.Mentions, routes: opts.Routes,
+		recoveryRuns: opts.RecoveryRuns, templates: opts.Templates,
+		labels: opts.Labels, assignees: opts.Assignees, digest: opts.Digest, batch: opts.Batch,
+		overflow: opts.Overflow, maxLength: opts.MaxLength, ctx: opts.Context, dryrun: dryrun}
+	if opts.Recorder != nil {
+		// The recorded client makes no change, the calls aren't skipped
+		// so that the issues are read and the changes recorded.
+		conf.dryrun = false
+	}
+	if opts.Retry != nil {
+		conf.retry = *opts.Retry
+	} else {
+		conf.retry = helpers.DefaultRetryPolicy(ghutil.IsTransientError)
+		conf.retry.RetryAfter = ghutil.RetryAfter
+	}
+	return &IssueHandler{client: client, config: conf, cache: newIssueCache(opts.CacheTTL)}, nil
 }
 
-// CreateIssueForTest will try to add an issue with the given testName and description.
+// CreateIssueForTest will try to add an issue with the given testName and description,
+// for the regression detected in the Mako run with the given ID.
 // If there is already an issue related to the test, it will try to update that issue.
-func (gih *IssueHandler) CreateIssueForTest(testName, desc string) error {
-	title := fmt.Sprintf(issueTitleTemplate, testName)
-	issue, err := gih.findIssue(title)
+// In digest mode, the regression is added to the digest issue of the run or the day instead.
+func (gih *IssueHandler) CreateIssueForTest(testName, runID, desc string) error {
+	routed := gih.forTest(testName)
+	if routed.config.digest != "" {
+		return routed.addToDigest(testName, runID, desc)
+	}
+	return routed.createIssueForTest(testName, runID, desc)
+}
+
+// forTest returns the handler for the repository the issues of the given test are routed to.
+func (gih *IssueHandler) forTest(testName string) *IssueHandler {
+	for _, route := range gih.config.routes {
+		if route.Matches(testName) {
+			routed := *gih
+			routed.config.org, routed.config.repo = route.Org, route.Repo
+			routed.config.digest = route.Digest
+			return &routed
+		}
+	}
+	return gih
+}
+
+func (gih *IssueHandler) createIssueForTest(testName, runID, desc string) error {
+	issue, md, err := gih.findIssue(testName)
 	if err != nil {
 		return fmt.Errorf("failed to find issues for test %q: %v, skipped creating new issue", testName, err)
 	}
-	// If the issue hasn't been created, create one
+	data := gih.templateData(testName)
+	data.Description = desc
+	// If the issue hasn't been created, create one. The regression is recorded
+	// in its metadata once it's fully set up below, like for the existing issues,
+	// so that the steps which failed are completed if the alert is retried.
 	if issue == nil {
-		commentBody := fmt.Sprintf(issueBodyTemplate, testName, gih.config.repo)
-		issue, err := gih.createNewIssue(title, commentBody)
+		md = &issueMetadata{TestName: testName, Severity: gih.config.severity}
+		title, err := execute(gih.templates().Title, data)
+		if err != nil {
+			return err
+		}
+		body, err := execute(gih.templates().Body, data)
+		if err != nil {
+			return err
+		}
+		issueBody, err := gih.issueBody(testName, body, md)
+		if err != nil {
+			return err
+		}
+		issue, err = gih.createNewIssue(title, issueBody)
 		if err != nil {
 			return fmt.Errorf("failed to create a new issue for test %q: %v", testName, err)
 		}
-		commentBody = fmt.Sprintf(issueSummaryCommentTemplate, desc)
-		if err := gih.addComment(*issue.Number, commentBody); err != nil {
-			return fmt.Errorf("failed to add comment for new issue %d: %v", *issue.Number, err)
+		if issue == nil {
+			// Nothing was created in dry run.
+			return nil
 		}
-		return nil
 	}
 
-	// If the issue has been created, edit it
 	issueNumber := *issue.Number
 
+	// If the issue has already been updated for this run, there is nothing new to report
+	if runID != "" && md.LastAlertedRunID == runID {
+		return nil
+	}
+
 	// If the issue has been closed, reopen it
 	if *issue.State == string(ghutil.IssueCloseState) {
 		if err := gih.reopenIssue(issueNumber); err != nil {
 			return fmt.Errorf("failed to reopen issue %d: %v", issueNumber, err)
 		}
-		commentBody := fmt.Sprintf(reopenIssueCommentTemplate, desc)
+		commentBody, err := execute(gih.templates().Reopen, data)
+		if err != nil {
+			return err
+		}
+		// The mention line is kept whole.
+		if commentBody, err = gih.fit(testName, commentBody, gih.maxLength()-len(gih.mentionLine())); err != nil {
+			return err
+		}
+		commentBody += gih.mentionLine()
 		if err := gih.addComment(issueNumber, commentBody); err != nil {
 			return fmt.Errorf("failed to add comment for reopened issue %d: %v", issueNumber, err)
 		}
 	}
 
-	// Edit the old comment
+	// Edit the summary comment, which is the first one as the issue body is not a comment
 	comments, err := gih.getComments(issueNumber)
 	if err != nil {
 		return fmt.Errorf("failed to get comments from issue %d: %v", issueNumber, err)
 	}
-	if len(comments) < 2 {
-		return fmt.Errorf("existing issue %d is malformed, cannot update", issueNumber)
+	commentBody, err := execute(gih.templates().Summary, data)
+	if err != nil {
+		return err
+	}
+	if commentBody, err = gih.fitComment(testName, commentBody); err != nil {
+		return err
 	}
-	commentBody := fmt.Sprintf(issueSummaryCommentTemplate, desc)
-	if err := gih.editComment(issueNumber, *comments[1].ID, commentBody); err != nil {
+	// The summary comment is missing if adding it to the new issue failed, add it.
+	if len(comments) == 0 {
+		if err := gih.addComment(issueNumber, commentBody); err != nil {
+			return fmt.Errorf("failed to add comment for issue %d: %v", issueNumber, err)
+		}
+	} else if err := gih.editComment(issueNumber, *comments[0].ID, commentBody); err != nil {
 		return fmt.Errorf("failed to edit the comment for issue %d: %v", issueNumber, err)
 	}
 
+	// Record this regression in the issue metadata
+	md.LastAlertedRunID = runID
+	md.Occurrences++
+	md.Severity = gih.config.severity
+	md.ConsecutivePasses = 0
+	issueBody, err := embedMetadata(issue.GetBody(), md)
+	if err != nil {
+		return err
+	}
+	if err := gih.editIssueBody(issueNumber, issueBody); err != nil {
+		return fmt.Errorf("failed to update the metadata of issue %d: %v", issueNumber, err)
+	}
+
 	return nil
 }
 
-// createNewIssue will create a new issue, and add perfLabel for it.
+// templates returns the templates of the issues and their comments.
+func (gih *IssueHandler) templates() Templates {
+	return gih.config.templates.withDefaults()
+}
+
+// templateData returns the data to execute the templates of the issue of the given test with.
+func (gih *IssueHandler) templateData(testName string) TemplateData {
+	return TemplateData{TestName: testName, Org: gih.config.org, Repo: gih.config.repo}
+}
+
+// mentionLine returns the line mentioning the configured Github users or teams,
+// or an empty string if there are none or this is a dry run, to not notify anyone.
+func (gih *IssueHandler) mentionLine() string {
+	if len(gih.config.mentions) == 0 || gih.config.dryrun {
+		return ""
+	}
+	return fmt.Sprintf(mentionTemplate, strings.Join(gih.config.mentions, " "))
+}
+
+// createNewIssue will create a new issue with perfLabel and the configured labels,
+// and assign it to the configured assignees.
+// The labels are set when creating the issue, as an issue left without perfLabel
+// wouldn't be found again by findIssue, and would be duplicated by the next alert.
 func (gih *IssueHandler) createNewIssue(title, body string) (*github.Issue, error) {
 	var newIssue *github.Issue
-	if err := helpers.Run(
+	labels := append([]string{perfLabel}, gih.config.labels...)
+	// Creating an issue isn't idempotent, only retry it if it was rejected.
+	if err := gih.runWithPolicy(
 		fmt.Sprintf("creating issue %q in %q", title, gih.config.repo),
-		func() error {
+		func(int) error {
 			var err error
-			newIssue, err = gih.client.CreateIssue(gih.config.org, gih.config.repo, title, body)
+			newIssue, err = gih.client.CreateLabeledIssue(gih.config.org, gih.config.repo, title, body, labels)
+			if err == nil {
+				gih.cache.add(gih.config.org, gih.config.repo, newIssue)
+			}
 			return err
 		},
-		gih.config.dryrun,
+		gih.rejectedRetryPolicy(),
 	); nil != err {
 		return nil, err
 	}
-	if err := helpers.Run(
-		fmt.Sprintf("adding perf label for issue %q in %q", title, gih.config.repo),
-		func() error {
-			return gih.client.AddLabelsToIssue(gih.config.org, gih.config.repo, *newIssue.Number, []string{perfLabel})
-		},
-		gih.config.dryrun,
-	); nil != err {
-		return nil, err
+	if len(gih.config.assignees) != 0 {
+		if err := gih.run(
+			fmt.Sprintf("assigning %v to issue %q in %q", gih.config.assignees, title, gih.config.repo),
+			func() error {
+				return gih.client.AddAssigneesToIssue(gih.config.org, gih.config.repo, *newIssue.Number, gih.config.assignees)
+			},
+		); nil != err {
+			return nil, err
+		}
 	}
 	return newIssue, nil
 }
@@ -172,8 +409,11 @@ func (gih *IssueHandler) createNewIssue(title, body string) (*github.Issue, erro
 // CloseIssueForTest will try to close the issue for the given testName.
 // If there is no issue related to the test or the issue is already closed, the function will do nothing.
 func (gih *IssueHandler) CloseIssueForTest(testName string) error {
-	title := fmt.Sprintf(issueTitleTemplate, testName)
-	issue, err := gih.findIssue(title)
+	return gih.forTest(testName).closeIssueForTest(testName)
+}
+
+func (gih *IssueHandler) closeIssueForTest(testName string) error {
+	issue, _, err := gih.findIssue(testName)
 	// If no issue has been found, or the issue has already been closed, do nothing.
 	if issue == nil || err != nil || *issue.State == string(ghutil.IssueCloseState) {
 		return nil
@@ -184,7 +424,57 @@ func (gih *IssueHandler) CloseIssueForTest(testName string) error {
 	}
 
 	issueNumber := *issue.Number
-	if err := gih.addComment(issueNumber, closeIssueComment); err != nil {
+	commentBody, err := execute(gih.templates().Close, gih.templateData(testName))
+	if err != nil {
+		return err
+	}
+	if err := gih.addComment(issueNumber, commentBody); err != nil {
+		return fmt.Errorf("failed to add comment for the issue %d to close: %v", issueNumber, err)
+	}
+	if err := gih.closeIssue(issueNumber); err != nil {
+		return fmt.Errorf("failed to close the issue %d: %v", issueNumber, err)
+	}
+	return nil
+}
+
+// ResolveIssue will record a run of the given testName without regression in the
+// metadata of its issue, and close the issue with a comment once the configured number
+// of consecutive runs were clean.
+// If there is no issue related to the test or the issue is already closed, the function will do nothing.
+func (gih *IssueHandler) ResolveIssue(testName string) error {
+	return gih.forTest(testName).resolveIssue(testName)
+}
+
+func (gih *IssueHandler) resolveIssue(testName string) error {
+	issue, md, err := gih.findIssue(testName)
+	if err != nil {
+		return fmt.Errorf("failed to find issues for test %q: %v, skipped resolving the issue", testName, err)
+	}
+	// If no issue has been found, or the issue has already been closed, do nothing.
+	if issue == nil || issue.GetState() == string(ghutil.IssueCloseState) {
+		return nil
+	}
+
+	issueNumber := *issue.Number
+	md.ConsecutivePasses++
+	issueBody, err := embedMetadata(issue.GetBody(), md)
+	if err != nil {
+		return err
+	}
+	if err := gih.editIssueBody(issueNumber, issueBody); err != nil {
+		return fmt.Errorf("failed to update the metadata of issue %d: %v", issueNumber, err)
+	}
+	if md.ConsecutivePasses < gih.recoveryRuns() {
+		return nil
+	}
+
+	data := gih.templateData(testName)
+	data.Runs = md.ConsecutivePasses
+	commentBody, err := execute(gih.templates().Recovered, data)
+	if err != nil {
+		return err
+	}
+	if err := gih.addComment(issueNumber, commentBody); err != nil {
 		return fmt.Errorf("failed to add comment for the issue %d to close: %v", issueNumber, err)
 	}
 	if err := gih.closeIssue(issueNumber); err != nil {
@@ -193,73 +483,147 @@ func (gih *IssueHandler) CloseIssueForTest(testName string) error {
 	return nil
 }
 
+// recoveryRuns returns the number of consecutive runs without regression after which issues are resolved.
+func (gih *IssueHandler) recoveryRuns() int {
+	if gih.config.recoveryRuns == 0 {
+		return defaultRecoveryRuns
+	}
+	return gih.config.recoveryRuns
+}
+
 // reopenIssue will reopen the given issue.
 func (gih *IssueHandler) reopenIssue(issueNumber int) error {
-	return helpers.Run(
+	return gih.run(
 		fmt.Sprintf("reopening issue %d in %q", issueNumber, gih.config.repo),
 		func() error {
-			return gih.client.ReopenIssue(gih.config.org, gih.config.repo, issueNumber)
+			if err := gih.client.ReopenIssue(gih.config.org, gih.config.repo, issueNumber); err != nil {
+				return err
+			}
+			gih.updateCachedIssue(issueNumber, func(issue *github.Issue) {
+				issue.State = github.String(string(ghutil.IssueOpenState))
+			})
+			return nil
 		},
-		gih.config.dryrun,
 	)
 }
 
 // closeIssue will close the given issue.
 func (gih *IssueHandler) closeIssue(issueNumber int) error {
-	return helpers.Run(
+	return gih.run(
 		fmt.Sprintf("closing issue %d in %q", issueNumber, gih.config.repo),
 		func() error {
-			return gih.client.CloseIssue(gih.config.org, gih.config.repo, issueNumber)
+			if err := gih.client.CloseIssue(gih.config.org, gih.config.repo, issueNumber); err != nil {
+				return err
+			}
+			gih.updateCachedIssue(issueNumber, func(issue *github.Issue) {
+				issue.State = github.String(string(ghutil.IssueCloseState))
+			})
+			return nil
 		},
-		gih.config.dryrun,
 	)
 }
 
-// findIssue will return the issue in the given repo if it exists.
-func (gih *IssueHandler) findIssue(title string) (*github.Issue, error) {
+// run runs the given Github call, which must be idempotent, with dry run support,
+// retrying it if it fails transiently.
+func (gih *IssueHandler) run(message string, call func() error) error {
+	return gih.runWithPolicy(message, func(int) error { return call() }, gih.config.retry)
+}
+
+// runWithPolicy runs the given Github call with dry run support, retrying it
+// according to the given policy.
+func (gih *IssueHandler) runWithPolicy(message string, call func(attempt int) error, policy helpers.RetryPolicy) error {
+	return helpers.RunWithRetry(gih.context(), message, call, gih.config.dryrun, policy)
+}
+
+// context returns the context of the Github calls.
+func (gih *IssueHandler) context() context.Context {
+	if gih.config.ctx == nil {
+		return context.Background()
+	}
+	return gih.config.ctx
+}
+
+// rejectedRetryPolicy returns the retry policy of the calls which aren't
+// idempotent, only retried when they were rejected and so not processed.
+func (gih *IssueHandler) rejectedRetryPolicy() helpers.RetryPolicy {
+	policy := gih.config.retry
+	policy.Retryable = ghutil.IsRejectedError
+	return policy
+}
+
+// updateCachedIssue calls mutate with the cached issue of the given number, once the
+// issue was changed on Github. The issue is also marked as updated, like Github does.
+func (gih *IssueHandler) updateCachedIssue(issueNumber int, mutate func(*github.Issue)) {
+	gih.cache.update(gih.config.org, gih.config.repo, issueNumber, mutate)
+}
+
+// findIssue will return the issue for the given test in the given repo if it exists,
+// along with its metadata. Issues are identified by the test name in their metadata,
+// or by their title if they have none.
+// The issues with perfLabel are searched once per repo, and then cached.
+func (gih *IssueHandler) findIssue(testName string) (*github.Issue, *issueMetadata, error) {
 	var issues []*github.Issue
-	if err := helpers.Run(
+	if err := gih.run(
 		fmt.Sprintf("listing issues in %q", gih.config.repo),
 		func() error {
 			var err error
-			issues, err = gih.client.ListIssuesByRepo(gih.config.org, gih.config.repo, []string{perfLabel})
+			issues, err = gih.cache.list(gih.config.org, gih.config.repo, func() ([]*github.Issue, error) {
+				query := fmt.Sprintf("repo:%s/%s is:issue label:%q", gih.config.org, gih.config.repo, perfLabel)
+				return gih.client.SearchIssues(query)
+			})
 			return err
 		},
-		gih.config.dryrun,
 	); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
+	title, err := execute(gih.templates().Title, gih.templateData(testName))
+	if err != nil {
+		return nil, nil, err
+	}
 	var existingIssue *github.Issue
+	var existingMetadata *issueMetadata
 	for _, issue := range issues {
-		if *issue.Title == title {
-			// If the issue has been closed a long time ago, ignore this issue.
-			if issue.GetState() == string(ghutil.IssueCloseState) &&
-				time.Now().Sub(*issue.UpdatedAt) > daysConsideredOld*24*time.Hour {
+		md, err := parseMetadata(issue.GetBody())
+		if err != nil {
+			log.Printf("Ignoring the metadata of issue %d: %v", issue.GetNumber(), err)
+		}
+		if md == nil {
+			if issue.GetTitle() != title {
 				continue
 			}
+			// The issue predates the metadata, start tracking it.
+			md = &issueMetadata{TestName: testName}
+		} else if md.TestName != testName {
+			continue
+		}
 
-			// If there are multiple issues, return the one that was created most recently.
-			if existingIssue == nil || issue.CreatedAt.After(*existingIssue.CreatedAt) {
-				existingIssue = issue
-			}
+		// If the issue has been closed a long time ago, ignore this issue.
+		if issue.GetState() == string(ghutil.IssueCloseState) &&
+			time.Now().Sub(issue.GetUpdatedAt()) > daysConsideredOld*24*time.Hour {
+			continue
+		}
+
+		// If there are multiple issues, return the one that was created most recently.
+		if existingIssue == nil || issue.GetCreatedAt().After(existingIssue.GetCreatedAt()) {
+			existingIssue = issue
+			existingMetadata = md
 		}
 	}
 
-	return existingIssue, nil
+	return existingIssue, existingMetadata, nil
 }
 
 // getComments will get comments for the given issue.
 func (gih *IssueHandler) getComments(issueNumber int) ([]*github.IssueComment, error) {
 	var comments []*github.IssueComment
-	if err := helpers.Run(
+	if err := gih.run(
 		fmt.Sprintf("getting comments for issue %d in %q", issueNumber, gih.config.repo),
 		func() error {
 			var err error
 			comments, err = gih.client.ListComments(gih.config.org, gih.config.repo, issueNumber)
 			return err
 		},
-		gih.config.dryrun,
 	); err != nil {
 		return comments, err
 	}
@@ -268,23 +632,53 @@ func (gih *IssueHandler) getComments(issueNumber int) ([]*github.IssueComment, e
 
 // addComment will add comment for the given issue.
 func (gih *IssueHandler) addComment(issueNumber int, commentBody string) error {
-	return helpers.Run(
+	return gih.runWithPolicy(
 		fmt.Sprintf("adding comment %q for issue %d in %q", commentBody, issueNumber, gih.config.repo),
+		func(attempt int) error {
+			// A failed attempt might have added the comment anyway, don't add it twice.
+			if attempt > 1 {
+				comments, err := gih.client.ListComments(gih.config.org, gih.config.repo, issueNumber)
+				if err != nil {
+					return err
+				}
+				for _, comment := range comments {
+					if comment.GetBody() == commentBody {
+						return nil
+					}
+				}
+			}
+			if _, err := gih.client.CreateComment(gih.config.org, gih.config.repo, issueNumber, commentBody); err != nil {
+				return err
+			}
+			gih.updateCachedIssue(issueNumber, func(*github.Issue) {})
+			return nil
+		},
+		gih.config.retry,
+	)
+}
+
+// editIssueBody will replace the body of the given issue.
+func (gih *IssueHandler) editIssueBody(issueNumber int, body string) error {
+	return gih.run(
+		fmt.Sprintf("editing body of issue %d in %q", issueNumber, gih.config.repo),
 		func() error {
-			_, err := gih.client.CreateComment(gih.config.org, gih.config.repo, issueNumber, commentBody)
-			return err
+			if err := gih.client.EditIssueBody(gih.config.org, gih.config.repo, issueNumber, body); err != nil {
+				return err
+			}
+			gih.updateCachedIssue(issueNumber, func(issue *github.Issue) {
+				issue.Body = &body
+			})
+			return nil
 		},
-		gih.config.dryrun,
 	)
 }
 
 // editComment will edit the comment to the new body.
 func (gih *IssueHandler) editComment(issueNumber int, commentID int64, commentBody string) error {
-	return helpers.Run(
+	return gih.run(
 		fmt.Sprintf("editting comment to %q for issue %d in %q", commentBody, issueNumber, gih.config.repo),
 		func() error {
 			return gih.client.EditComment(gih.config.org, gih.config.repo, commentID, commentBody)
 		},
-		gih.config.dryrun,
 	)
 }
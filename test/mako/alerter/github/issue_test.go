@@ -17,12 +17,16 @@ limitations under the License.
 package github
 
 import (
-	"fmt"
 	"os"
+	"strings"
 	"testing"
+	"text/template"
+	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"knative.dev/pkg/test/ghutil"
 	"knative.dev/pkg/test/ghutil/fakeghutil"
+	makoconfig "knative.dev/pkg/test/mako/config"
 )
 
 var gih IssueHandler
@@ -38,11 +42,10 @@ func TestMain(m *testing.M) {
 func TestNewIssueWillBeAdded(t *testing.T) {
 	testName := "test add new issue"
 	testDesc := "test add new issue desc"
-	if err := gih.CreateIssueForTest(testName, testDesc); err != nil {
+	if err := gih.CreateIssueForTest(testName, "run1", testDesc); err != nil {
 		t.Fatalf("expected to create a new issue %v, but failed", testName)
 	}
-	issueTitle := fmt.Sprintf(issueTitleTemplate, testName)
-	issueFound, err := gih.findIssue(issueTitle)
+	issueFound, _, err := gih.findIssue(testName)
 	if issueFound == nil || err != nil {
 		t.Fatalf("expected to find the new created issue %v, but failed to", testName)
 	}
@@ -53,14 +56,14 @@ func TestClosedIssueWillBeReopened(t *testing.T) {
 	repo := gih.config.repo
 	testName := "test reopening close issue"
 	testDesc := "test reopening close issue desc"
-	issueTitle := fmt.Sprintf(issueTitleTemplate, testName)
+	issueTitle := "[performance] " + testName
 	issue, _ := gih.client.CreateIssue(org, repo, issueTitle, testDesc)
 	gih.client.CloseIssue(org, repo, *issue.Number)
 
-	if err := gih.CreateIssueForTest(testName, testDesc); err != nil {
+	if err := gih.CreateIssueForTest(testName, "run1", testDesc); err != nil {
 		t.Fatalf("expected to update the existed issue %v, but failed", testName)
 	}
-	updatedIssue, err := gih.findIssue(issueTitle)
+	updatedIssue, _, err := gih.findIssue(testName)
 	if updatedIssue == nil || err != nil || *updatedIssue.State != string(ghutil.IssueOpenState) {
 		t.Fatalf("expected to reopen the closed issue %v, but failed", testName)
 	}
@@ -69,7 +72,7 @@ func TestClosedIssueWillBeReopened(t *testing.T) {
 func TestIssueCanBeClosed(t *testing.T) {
 	testName := "test closing existed issue"
 	testDesc := "test closing existed issue desc"
-	if err := gih.CreateIssueForTest(testName, testDesc); err != nil {
+	if err := gih.CreateIssueForTest(testName, "run1", testDesc); err != nil {
 		t.Fatalf("expected to create a new issue %v, but failed", testName)
 	}
 
@@ -77,3 +80,271 @@ func TestIssueCanBeClosed(t *testing.T) {
 		t.Fatalf("tried to close the existed issue %v, but got an error %v", testName, err)
 	}
 }
+
+func TestMentions(t *testing.T) {
+	mentioning := IssueHandler{
+		client: fakeghutil.NewFakeGithubClient(),
+		config: config{org: "test_org", repo: "test_repo", mentions: []string{"@knative/team", "@someone"}},
+	}
+	wantMention := "/cc @knative/team @someone"
+
+	testName := "test mentions"
+	if err := mentioning.CreateIssueForTest(testName, "run1", "desc"); err != nil {
+		t.Fatalf("expected to create a new issue %v, but failed: %v", testName, err)
+	}
+	issue, _, err := mentioning.findIssue(testName)
+	if issue == nil || err != nil {
+		t.Fatalf("expected to find the new created issue %v, but failed to", testName)
+	}
+	if !strings.Contains(issue.GetBody(), wantMention) {
+		t.Errorf("expected the issue body to mention %q, but got %q", wantMention, issue.GetBody())
+	}
+
+	mentioning.client.CloseIssue("test_org", "test_repo", *issue.Number)
+	now := time.Now()
+	issue.UpdatedAt = &now
+	if err := mentioning.CreateIssueForTest(testName, "run2", "desc"); err != nil {
+		t.Fatalf("expected to reopen the issue %v, but failed: %v", testName, err)
+	}
+	comments, _ := mentioning.client.ListComments("test_org", "test_repo", *issue.Number)
+	mentioned := false
+	for _, comment := range comments {
+		mentioned = mentioned || strings.Contains(comment.GetBody(), wantMention)
+	}
+	if !mentioned {
+		t.Errorf("expected the reopening comment to mention %q, but got %v", wantMention, comments)
+	}
+
+	mentioning.config.dryrun = true
+	if got := mentioning.mentionLine(); got != "" {
+		t.Errorf("expected no mentions in dry run, but got %q", got)
+	}
+}
+
+func TestIssueMetadata(t *testing.T) {
+	handler := IssueHandler{
+		client: fakeghutil.NewFakeGithubClient(),
+		config: config{org: "test_org", repo: "test_repo", severity: "p1"},
+	}
+	testName := "test metadata"
+
+	for _, runID := range []string{"run1", "run2", "run2", "run3"} {
+		if err := handler.CreateIssueForTest(testName, runID, "desc"); err != nil {
+			t.Fatalf("expected to create or update the issue for run %s, but failed: %v", runID, err)
+		}
+	}
+
+	issue, md, err := handler.findIssue(testName)
+	if issue == nil || err != nil {
+		t.Fatalf("expected to find the issue %v, but failed to", testName)
+	}
+	// The same run is only counted once.
+	want := &issueMetadata{TestName: testName, LastAlertedRunID: "run3", Occurrences: 3, Severity: "p1"}
+	if diff := cmp.Diff(want, md); diff != "" {
+		t.Errorf("Metadata (-want, +got): %s", diff)
+	}
+	if got, err := parseMetadata(issue.GetBody()); err != nil || !cmp.Equal(want, got) {
+		t.Errorf("parseMetadata(body) = %v, %v, want %v", got, err, want)
+	}
+
+	// Issues are found by their metadata, even if renamed.
+	renamed := "renamed"
+	issue.Title = &renamed
+	if found, _, _ := handler.findIssue(testName); found == nil || found.GetNumber() != issue.GetNumber() {
+		t.Errorf("expected to find the renamed issue %d, but got %v", issue.GetNumber(), found)
+	}
+}
+
+func TestEmbedMetadata(t *testing.T) {
+	md := &issueMetadata{TestName: "test", LastAlertedRunID: "run", Occurrences: 2}
+	body, err := embedMetadata("body", md)
+	if err != nil {
+		t.Fatalf("embedMetadata() = %v", err)
+	}
+	if !strings.HasPrefix(body, "body\n\n<!--") {
+		t.Errorf("expected the metadata to be hidden after the body, but got %q", body)
+	}
+
+	md.Occurrences = 3
+	if body, err = embedMetadata(body, md); err != nil {
+		t.Fatalf("embedMetadata() = %v", err)
+	}
+	if got := strings.Count(body, "mako-alert-metadata"); got != 1 {
+		t.Errorf("expected the metadata to be replaced, but found %d blocks in %q", got, body)
+	}
+	if got, err := parseMetadata(body); err != nil || !cmp.Equal(md, got) {
+		t.Errorf("parseMetadata() = %v, %v, want %v", got, err, md)
+	}
+
+	if got, err := parseMetadata("no metadata"); got != nil || err != nil {
+		t.Errorf("parseMetadata() = %v, %v, want nil", got, err)
+	}
+	if _, err := parseMetadata("<!-- mako-alert-metadata\n{\n-->"); err == nil {
+		t.Error("parseMetadata() = nil, wanted an error for malformed metadata")
+	}
+}
+
+func TestIssueRouting(t *testing.T) {
+	client := fakeghutil.NewFakeGithubClient()
+	handler := IssueHandler{
+		client: client,
+		config: config{
+			org:  "test_org",
+			repo: "test_repo",
+			routes: []makoconfig.GithubRoute{
+				{Pattern: "serving-*", Org: "test_org", Repo: "serving"},
+				{Pattern: "eventing-*", Org: "test_org", Repo: "eventing"},
+			},
+		},
+	}
+
+	tests := map[string]string{
+		"serving-dataplane-probe": "serving",
+		"eventing-broker":         "eventing",
+		"other":                   "test_repo",
+	}
+	for testName, wantRepo := range tests {
+		if err := handler.CreateIssueForTest(testName, "run1", "desc"); err != nil {
+			t.Fatalf("expected to create a new issue for %v, but failed: %v", testName, err)
+		}
+		issues, _ := client.ListIssuesByRepo("test_org", wantRepo, []string{perfLabel})
+		found := false
+		for _, issue := range issues {
+			found = found || issue.GetTitle() == "[performance] "+testName
+		}
+		if !found {
+			t.Errorf("expected the issue for %v to be filed in %v, but it wasn't", testName, wantRepo)
+		}
+		if issue, _, _ := handler.forTest(testName).findIssue(testName); issue == nil {
+			t.Errorf("expected to find the issue for %v through its route, but failed to", testName)
+		}
+	}
+}
+
+func TestIssueResolved(t *testing.T) {
+	handler := IssueHandler{
+		client: fakeghutil.NewFakeGithubClient(),
+		config: config{org: "test_org", repo: "test_repo", recoveryRuns: 2},
+	}
+	testName := "test resolved"
+
+	if err := handler.CreateIssueForTest(testName, "run1", "desc"); err != nil {
+		t.Fatalf("expected to create a new issue %v, but failed: %v", testName, err)
+	}
+	// A regression in between resets the count of clean runs.
+	if err := handler.ResolveIssue(testName); err != nil {
+		t.Fatalf("ResolveIssue() = %v", err)
+	}
+	if err := handler.CreateIssueForTest(testName, "run2", "desc"); err != nil {
+		t.Fatalf("expected to update the issue %v, but failed: %v", testName, err)
+	}
+	if _, md, _ := handler.findIssue(testName); md.ConsecutivePasses != 0 {
+		t.Errorf("ConsecutivePasses = %d, want 0 after a regression", md.ConsecutivePasses)
+	}
+
+	if err := handler.ResolveIssue(testName); err != nil {
+		t.Fatalf("ResolveIssue() = %v", err)
+	}
+	issue, md, _ := handler.findIssue(testName)
+	if issue.GetState() != string(ghutil.IssueOpenState) || md.ConsecutivePasses != 1 {
+		t.Fatalf("expected the issue to stay open after 1 clean run, but got state %q and %d passes",
+			issue.GetState(), md.ConsecutivePasses)
+	}
+
+	if err := handler.ResolveIssue(testName); err != nil {
+		t.Fatalf("ResolveIssue() = %v", err)
+	}
+	now := time.Now()
+	issue.UpdatedAt = &now
+	if issue.GetState() != string(ghutil.IssueCloseState) {
+		t.Errorf("expected the issue to be closed after 2 clean runs, but got state %q", issue.GetState())
+	}
+	comments, _ := handler.client.ListComments("test_org", "test_repo", issue.GetNumber())
+	if last := comments[len(comments)-1].GetBody(); !strings.Contains(last, "recovered") {
+		t.Errorf("expected a recovered comment, but got %q", last)
+	}
+
+	// Resolving a closed issue does nothing.
+	if err := handler.ResolveIssue(testName); err != nil {
+		t.Errorf("ResolveIssue() = %v", err)
+	}
+	if got, _ := handler.client.ListComments("test_org", "test_repo", issue.GetNumber()); len(got) != len(comments) {
+		t.Errorf("expected no new comment on the closed issue, but got %d comments, want %d", len(got), len(comments))
+	}
+}
+
+func TestIssueCustomization(t *testing.T) {
+	client := fakeghutil.NewFakeGithubClient()
+	templates := Templates{
+		Title:   template.Must(template.New("title").Parse(`Perf regression in {{.Org}}/{{.Repo}}: {{.TestName}}`)),
+		Summary: template.Must(template.New("summary").Parse(`Regression: {{.Description}}`)),
+	}
+	handler := IssueHandler{
+		client: client,
+		config: config{
+			org:       "test_org",
+			repo:      "test_repo",
+			templates: templates,
+			labels:    []string{"area/perf"},
+			assignees: []string{"someone"},
+		},
+	}
+	testName := "test customization"
+
+	if err := handler.CreateIssueForTest(testName, "run1", "desc"); err != nil {
+		t.Fatalf("expected to create a new issue %v, but failed: %v", testName, err)
+	}
+	issue, _, err := handler.findIssue(testName)
+	if issue == nil || err != nil {
+		t.Fatalf("expected to find the new created issue %v, but failed to", testName)
+	}
+	if got, want := issue.GetTitle(), "Perf regression in test_org/test_repo: test customization"; got != want {
+		t.Errorf("Title = %q, want %q", got, want)
+	}
+	// The templates which aren't set default to the built-in ones.
+	if !strings.Contains(issue.GetBody(), "Auto-generated issue tracking performance regression") {
+		t.Errorf("expected the default body, but got %q", issue.GetBody())
+	}
+	comments, _ := client.ListComments("test_org", "test_repo", issue.GetNumber())
+	if len(comments) != 1 || comments[0].GetBody() != "Regression: desc" {
+		t.Errorf("expected the custom summary comment, but got %v", comments)
+	}
+
+	var labels []string
+	for _, label := range issue.Labels {
+		labels = append(labels, label.GetName())
+	}
+	if diff := cmp.Diff([]string{perfLabel, "area/perf"}, labels); diff != "" {
+		t.Errorf("Labels (-want, +got): %s", diff)
+	}
+	if len(issue.Assignees) != 1 || issue.Assignees[0].GetLogin() != "someone" {
+		t.Errorf("Assignees = %v, want someone", issue.Assignees)
+	}
+
+	// Templates failing to execute are reported.
+	handler.config.templates.Summary = template.Must(template.New("summary").Parse(`{{.Unknown}}`))
+	if err := handler.CreateIssueForTest("test broken template", "run1", "desc"); err == nil {
+		t.Error("CreateIssueForTest() = nil, wanted an error for a broken template")
+	}
+}
+
+func TestParseTemplates(t *testing.T) {
+	templates, err := ParseTemplates(makoconfig.GithubTemplates{Title: "perf: {{.TestName}}"})
+	if err != nil {
+		t.Fatalf("ParseTemplates() = %v", err)
+	}
+	if templates.Body != nil || templates.Close != nil {
+		t.Error("ParseTemplates() set templates which weren't configured")
+	}
+	title, err := execute(templates.withDefaults().Title, TemplateData{TestName: "foo"})
+	if err != nil {
+		t.Fatalf("execute() = %v", err)
+	}
+	if title != "perf: foo" {
+		t.Errorf("title = %q, want %q", title, "perf: foo")
+	}
+
+	if _, err := ParseTemplates(makoconfig.GithubTemplates{Body: "{{.TestName"}); err == nil {
+		t.Error("ParseTemplates() = nil, wanted an error for an invalid template")
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// metadataTemplate is a template for the hidden block holding the metadata of an issue
+const metadataTemplate = `
+
+<!-- mako-alert-metadata
+%s
+-->`
+
+// metadataRegexp matches the hidden metadata block in issue bodies.
+var metadataRegexp = regexp.MustCompile(`(?s)\s*<!-- mako-alert-metadata\n(.*?)\n-->`)
+
+// issueMetadata is the alert state of an issue. It's embedded in the issue body
+// as JSON in an HTML comment, so it's hidden when the issue is rendered but can be
+// read back on subsequent runs.
+type issueMetadata struct {
+	// TestName is the name of the test the issue tracks the regressions of.
+	TestName string `json:"testName"`
+	// LastAlertedRunID is the key of the last Mako run the issue was updated for.
+	LastAlertedRunID string `json:"lastAlertedRunID,omitempty"`
+	// Occurrences is the number of runs a regression was detected in.
+	Occurrences int `json:"occurrences"`
+	// Severity is the severity of the regressions.
+	Severity string `json:"severity,omitempty"`
+	// ConsecutivePasses is the number of runs without regression since the
+	// last one detected.
+	ConsecutivePasses int `json:"consecutivePasses,omitempty"`
+	// Digested are the regressions batched in a digest issue, as
+	// `<test name>@<run ID>`.
+	Digested []string `json:"digested,omitempty"`
+}
+
+// embedMetadata returns the body with the given metadata, replacing the existing
+// metadata if there is any.
+func embedMetadata(body string, md *issueMetadata) (string, error) {
+	b, err := json.Marshal(md)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode the issue metadata: %v", err)
+	}
+	body = metadataRegexp.ReplaceAllString(body, "")
+	return strings.TrimRight(body, "\n") + fmt.Sprintf(metadataTemplate, b), nil
+}
+
+// parseMetadata returns the metadata embedded in the body, or nil if there is none.
+func parseMetadata(body string) (*issueMetadata, error) {
+	match := metadataRegexp.FindStringSubmatch(body)
+	if match == nil {
+		return nil, nil
+	}
+	md := &issueMetadata{}
+	if err := json.Unmarshal([]byte(match[1]), md); err != nil {
+		return nil, fmt.Errorf("failed to decode the issue metadata: %v", err)
+	}
+	return md, nil
+}
+
+// isDigested returns true if the regression of the given test, detected in the
+// given run, has already been batched in the digest issue.
+func (md *issueMetadata) isDigested(testName, runID string) bool {
+	digested := testName + "@" + runID
+	for _, d := range md.Digested {
+		if d == digested {
+			return true
+		}
+	}
+	return false
+}
+
+// addDigested records the regression of the given test, detected in the given
+// run, as batched in the digest issue.
+func (md *issueMetadata) addDigested(testName, runID string) {
+	md.LastAlertedRunID = runID
+	md.Occurrences++
+	md.Digested = append(md.Digested, testName+"@"+runID)
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// defaultMaxLength is the maximum length of the bodies of the issues and
+	// of their comments accepted by Github.
+	defaultMaxLength = 65536
+
+	// overflowTemplate is a template for the line linking the full text of a
+	// truncated body or comment.
+	overflowTemplate = `
+
+... truncated, the full report is at %s`
+	// truncatedTemplate is a template for the line ending a truncated body or
+	// comment whose full text couldn't be stored.
+	truncatedTemplate = `
+
+... truncated, %d bytes were omitted`
+)
+
+// unsafeNameChars matches the characters replaced in the names of the
+// overflowing reports.
+var unsafeNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// maxLength returns the maximum length of the bodies and comments.
+func (gih *IssueHandler) maxLength() int {
+	if gih.config.maxLength == 0 {
+		return defaultMaxLength
+	}
+	return gih.config.maxLength
+}
+
+// fitComment returns the comment, for the issue of the given name, truncated
+// to fit in a comment of Github if needed, see fit.
+func (gih *IssueHandler) fitComment(name, comment string) (string, error) {
+	return gih.fit(name, comment, gih.maxLength())
+}
+
+// issueBody returns the body of a new issue of the given name, with the given
+// text followed by the mention line and the metadata, the text being truncated
+// to fit in the body of a Github issue if needed, see fit.
+func (gih *IssueHandler) issueBody(name, text string, md *issueMetadata) (string, error) {
+	mention := gih.mentionLine()
+	// The mention line and the metadata are kept whole.
+	empty, err := embedMetadata(mention, md)
+	if err != nil {
+		return "", err
+	}
+	text, err = gih.fit(name, text, gih.maxLength()-len(empty))
+	if err != nil {
+		return "", err
+	}
+	return embedMetadata(text+mention, md)
+}
+
+// fit returns the text if it's at most limit bytes long. Otherwise the text is
+// truncated, after its full version is stored in the overflow store and
+// linked, or with a note of how much was omitted if there is no store.
+func (gih *IssueHandler) fit(name, text string, limit int) (string, error) {
+	if len(text) <= limit {
+		return text, nil
+	}
+	if gih.config.overflow == nil {
+		note := fmt.Sprintf(truncatedTemplate, len(text))
+		kept := truncate(text, limit-len(note))
+		return kept + fmt.Sprintf(truncatedTemplate, len(text)-len(kept)), nil
+	}
+
+	// The name is derived from the text, so that retries store it once.
+	sum := sha256.Sum256([]byte(text))
+	object := path.Join(gih.config.org, gih.config.repo,
+		strings.Trim(unsafeNameChars.ReplaceAllString(name, "-"), "-")+"-"+hex.EncodeToString(sum[:])[:12]+".md")
+	if err := gih.run(
+		fmt.Sprintf("storing the full report %q of %d bytes", object, len(text)),
+		func() error {
+			return gih.config.overflow.Write(gih.context(), object, []byte(text))
+		},
+	); err != nil {
+		return "", fmt.Errorf("failed to store the full report %q: %v", object, err)
+	}
+	link := fmt.Sprintf(overflowTemplate, gih.config.overflow.URL(object))
+	return truncate(text, limit-len(link)) + link, nil
+}
+
+// truncate returns the longest prefix of the text of at most limit bytes,
+// cut at the end of a line if there is one in its second half, and never in
+// the middle of a character.
+func truncate(text string, limit int) string {
+	if limit <= 0 {
+		return ""
+	}
+	if len(text) <= limit {
+		return text
+	}
+	text = text[:limit]
+	// Drop the bytes of the character which was cut, if any.
+	for len(text) > 0 {
+		if r, size := utf8.DecodeLastRuneInString(text); r != utf8.RuneError || size != 1 {
+			break
+		}
+		text = text[:len(text)-1]
+	}
+	if i := strings.LastIndexByte(text, '\n'); i > len(text)/2 {
+		return text[:i]
+	}
+	return text
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"knative.dev/pkg/test/gcs"
+	"knative.dev/pkg/test/ghutil/fakeghutil"
+	makoconfig "knative.dev/pkg/test/mako/config"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  string
+	}{{
+		name:  "short enough",
+		text:  "short",
+		limit: 5,
+		want:  "short",
+	}, {
+		name:  "cut",
+		text:  "a long line",
+		limit: 6,
+		want:  "a long",
+	}, {
+		name:  "at the end of a line",
+		text:  "first line\nsecond line",
+		limit: 15,
+		want:  "first line",
+	}, {
+		name:  "not in a character",
+		text:  "regressé",
+		limit: 8,
+		want:  "regress",
+	}, {
+		name:  "no room",
+		text:  "text",
+		limit: -1,
+		want:  "",
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := truncate(test.text, test.limit); got != test.want {
+				t.Errorf("truncate() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFitWithoutOverflow(t *testing.T) {
+	handler := &IssueHandler{config: config{org: "test_org", repo: "test_repo", maxLength: 100}}
+	text := strings.Repeat("regression\n", 20)
+
+	got, err := handler.fitComment("test", text)
+	if err != nil {
+		t.Fatalf("fitComment() = %v", err)
+	}
+	if len(got) > 100 {
+		t.Errorf("len(fitComment()) = %d, want at most 100", len(got))
+	}
+	if !strings.HasPrefix(text, strings.Split(got, "\n\n...")[0]) || !strings.Contains(got, "bytes were omitted") {
+		t.Errorf("fitComment() = %q, wanted a truncated text with a note", got)
+	}
+
+	if got, err := handler.fitComment("test", "short"); err != nil || got != "short" {
+		t.Errorf("fitComment() = %q, %v, wanted it unchanged", got, err)
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "overflow")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	store := gcs.NewLocalStore(dir)
+
+	client := fakeghutil.NewFakeGithubClient()
+	handler := &IssueHandler{
+		client: client,
+		config: config{org: "test_org", repo: "test_repo", batch: makoconfig.BatchIssue,
+			mentions: []string{"@knative/team"}, overflow: store, maxLength: 1000},
+	}
+	var regressions []Regression
+	for i := 0; i < 20; i++ {
+		regressions = append(regressions, Regression{TestName: "suite/test", RunID: "run1", Description: strings.Repeat("latency regressed\n", 5)})
+	}
+	if err := handler.ReportRegressions("job #1", regressions); err != nil {
+		t.Fatalf("ReportRegressions() = %v", err)
+	}
+
+	issue, md, err := handler.findIssue(digestPrefix + "job #1")
+	if err != nil || issue == nil {
+		t.Fatalf("findIssue() = %v, %v, wanted the digest issue of the batch", issue, err)
+	}
+	body := issue.GetBody()
+	if len(body) > 1000 {
+		t.Errorf("len(Body) = %d, want at most 1000", len(body))
+	}
+	if md.Occurrences != 20 {
+		t.Errorf("Occurrences = %d, want 20, the metadata must be kept whole", md.Occurrences)
+	}
+	if !strings.Contains(body, "/cc @knative/team") {
+		t.Errorf("Body = %q, wanted the mention kept whole", body)
+	}
+
+	names, err := store.List(context.Background(), "")
+	if err != nil || len(names) != 1 {
+		t.Fatalf("List() = %v, %v, wanted the full report", names, err)
+	}
+	if !strings.Contains(body, "the full report is at "+store.URL(names[0])) {
+		t.Errorf("Body = %q, wanted it to link %q", body, store.URL(names[0]))
+	}
+	full, err := store.Read(context.Background(), names[0])
+	if err != nil {
+		t.Fatalf("Read() = %v", err)
+	}
+	if got, want := strings.Count(string(full), "<details>"), 20; got != want {
+		t.Errorf("The full report has %d regressions, want %d", got, want)
+	}
+}
@@ -0,0 +1,281 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+
+	"knative.dev/pkg/test/ghutil"
+)
+
+// OperationType is the type of a change to the Github issues.
+type OperationType string
+
+const (
+	// OperationCreate creates an issue.
+	OperationCreate OperationType = "create"
+	// OperationEdit replaces the body of an issue.
+	OperationEdit OperationType = "edit"
+	// OperationReopen reopens an issue.
+	OperationReopen OperationType = "reopen"
+	// OperationClose closes an issue.
+	OperationClose OperationType = "close"
+	// OperationComment adds a comment to an issue.
+	OperationComment OperationType = "comment"
+	// OperationEditComment replaces the body of a comment.
+	OperationEditComment OperationType = "edit-comment"
+	// OperationLabel adds labels to an issue.
+	OperationLabel OperationType = "label"
+	// OperationAssign assigns users to an issue.
+	OperationAssign OperationType = "assign"
+)
+
+// Operation is a change to the Github issues recorded by a Recorder.
+type Operation struct {
+	Type OperationType `json:"type"`
+	Org  string        `json:"org"`
+	Repo string        `json:"repo"`
+	// Issue is the number of the issue. The issues created while recording
+	// are numbered -1, -2 and so on.
+	Issue int `json:"issue,omitempty"`
+	// CommentID is the ID of the edited comment. The comments added while
+	// recording have negative IDs too.
+	CommentID int64    `json:"commentID,omitempty"`
+	Title     string   `json:"title,omitempty"`
+	Body      string   `json:"body,omitempty"`
+	Labels    []string `json:"labels,omitempty"`
+	Assignees []string `json:"assignees,omitempty"`
+}
+
+// Recorder records the changes an IssueHandler would make to the Github issues
+// instead of making them, so that a dry run can be checked, e.g. by the CI jobs
+// testing the alerts. The issues are still read from Github, along with the
+// issues and comments created while recording.
+type Recorder struct {
+	mu         sync.Mutex
+	operations []Operation
+	// issues are the issues created while recording, by repository and number.
+	issues map[string]*github.Issue
+	// comments are the comments added while recording, by issue.
+	comments map[string][]*github.IssueComment
+	// next is the number of the last issue or comment created while recording.
+	next int
+}
+
+// NewRecorder creates a Recorder, to pass to Setup through Options.Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{
+		issues:   make(map[string]*github.Issue),
+		comments: make(map[string][]*github.IssueComment),
+	}
+}
+
+// Operations returns the recorded changes, in order.
+func (r *Recorder) Operations() []Operation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Operation(nil), r.operations...)
+}
+
+// JSON returns the recorded changes as an indented JSON array.
+func (r *Recorder) JSON() ([]byte, error) {
+	operations := r.Operations()
+	if operations == nil {
+		operations = []Operation{}
+	}
+	return json.MarshalIndent(operations, "", "  ")
+}
+
+// client returns a client reading the issues through the given one, which
+// records the changes instead of making them.
+func (r *Recorder) client(client ghutil.GithubOperations) ghutil.GithubOperations {
+	return &recordingClient{GithubOperations: client, recorder: r}
+}
+
+func (r *Recorder) record(op Operation) {
+	r.operations = append(r.operations, op)
+}
+
+func issueKey(org, repo string, issueNumber int) string {
+	return fmt.Sprintf("%s/%s#%d", org, repo, issueNumber)
+}
+
+// recordingClient is the client of a Recorder. The calls reading from Github
+// are passed through, the others are recorded.
+type recordingClient struct {
+	ghutil.GithubOperations
+	recorder *Recorder
+}
+
+// SearchIssues adds the issues created while recording in the repository of
+// the query, if it has a `repo:` qualifier, to the issues found on Github.
+func (c *recordingClient) SearchIssues(query string) ([]*github.Issue, error) {
+	issues, err := c.GithubOperations.SearchIssues(query)
+	if err != nil {
+		return nil, err
+	}
+	prefix := ""
+	for _, token := range strings.Fields(query) {
+		if strings.HasPrefix(token, "repo:") {
+			prefix = strings.TrimPrefix(token, "repo:") + "#"
+		}
+	}
+	if prefix == "" {
+		return issues, nil
+	}
+	c.recorder.mu.Lock()
+	defer c.recorder.mu.Unlock()
+	for key, issue := range c.recorder.issues {
+		if strings.HasPrefix(key, prefix) {
+			issues = append(issues, issue)
+		}
+	}
+	return issues, nil
+}
+
+func (c *recordingClient) CreateIssue(org, repo, title, body string) (*github.Issue, error) {
+	return c.CreateLabeledIssue(org, repo, title, body, nil)
+}
+
+func (c *recordingClient) CreateLabeledIssue(org, repo, title, body string, labels []string) (*github.Issue, error) {
+	c.recorder.mu.Lock()
+	defer c.recorder.mu.Unlock()
+	c.recorder.next--
+	issue := &github.Issue{
+		Number: github.Int(c.recorder.next),
+		Title:  &title,
+		Body:   &body,
+		State:  github.String(string(ghutil.IssueOpenState)),
+	}
+	for _, label := range labels {
+		issue.Labels = append(issue.Labels, github.Label{Name: github.String(label)})
+	}
+	c.recorder.issues[issueKey(org, repo, c.recorder.next)] = issue
+	c.recorder.record(Operation{Type: OperationCreate, Org: org, Repo: repo, Issue: c.recorder.next, Title: title, Body: body, Labels: labels})
+	return issue, nil
+}
+
+func (c *recordingClient) EditIssueBody(org, repo string, issueNumber int, body string) error {
+	return c.changeIssue(Operation{Type: OperationEdit, Org: org, Repo: repo, Issue: issueNumber, Body: body}, func(issue *github.Issue) {
+		issue.Body = &body
+	})
+}
+
+func (c *recordingClient) CloseIssue(org, repo string, issueNumber int) error {
+	return c.changeIssue(Operation{Type: OperationClose, Org: org, Repo: repo, Issue: issueNumber}, func(issue *github.Issue) {
+		issue.State = github.String(string(ghutil.IssueCloseState))
+	})
+}
+
+func (c *recordingClient) ReopenIssue(org, repo string, issueNumber int) error {
+	return c.changeIssue(Operation{Type: OperationReopen, Org: org, Repo: repo, Issue: issueNumber}, func(issue *github.Issue) {
+		issue.State = github.String(string(ghutil.IssueOpenState))
+	})
+}
+
+func (c *recordingClient) AddLabelsToIssue(org, repo string, issueNumber int, labels []string) error {
+	return c.changeIssue(Operation{Type: OperationLabel, Org: org, Repo: repo, Issue: issueNumber, Labels: labels}, func(issue *github.Issue) {
+		for _, label := range labels {
+			issue.Labels = append(issue.Labels, github.Label{Name: github.String(label)})
+		}
+	})
+}
+
+func (c *recordingClient) AddAssigneesToIssue(org, repo string, issueNumber int, assignees []string) error {
+	return c.changeIssue(Operation{Type: OperationAssign, Org: org, Repo: repo, Issue: issueNumber, Assignees: assignees}, func(issue *github.Issue) {
+		for _, assignee := range assignees {
+			issue.Assignees = append(issue.Assignees, &github.User{Login: github.String(assignee)})
+		}
+	})
+}
+
+// changeIssue records the change, and applies it to the issue if it was
+// created while recording.
+func (c *recordingClient) changeIssue(op Operation, mutate func(*github.Issue)) error {
+	c.recorder.mu.Lock()
+	defer c.recorder.mu.Unlock()
+	if issue, ok := c.recorder.issues[issueKey(op.Org, op.Repo, op.Issue)]; ok {
+		mutate(issue)
+	}
+	c.recorder.record(op)
+	return nil
+}
+
+// ListComments adds the comments added while recording to the comments of the
+// issue on Github, if it's not an issue created while recording.
+func (c *recordingClient) ListComments(org, repo string, issueNumber int) ([]*github.IssueComment, error) {
+	var comments []*github.IssueComment
+	if issueNumber > 0 {
+		var err error
+		if comments, err = c.GithubOperations.ListComments(org, repo, issueNumber); err != nil {
+			return nil, err
+		}
+	}
+	c.recorder.mu.Lock()
+	defer c.recorder.mu.Unlock()
+	return append(comments, c.recorder.comments[issueKey(org, repo, issueNumber)]...), nil
+}
+
+func (c *recordingClient) CreateComment(org, repo string, issueNumber int, commentBody string) (*github.IssueComment, error) {
+	c.recorder.mu.Lock()
+	defer c.recorder.mu.Unlock()
+	c.recorder.next--
+	comment := &github.IssueComment{ID: github.Int64(int64(c.recorder.next)), Body: &commentBody}
+	key := issueKey(org, repo, issueNumber)
+	c.recorder.comments[key] = append(c.recorder.comments[key], comment)
+	c.recorder.record(Operation{Type: OperationComment, Org: org, Repo: repo, Issue: issueNumber, Body: commentBody})
+	return comment, nil
+}
+
+func (c *recordingClient) EditComment(org, repo string, commentID int64, commentBody string) error {
+	c.recorder.mu.Lock()
+	defer c.recorder.mu.Unlock()
+	for _, comments := range c.recorder.comments {
+		for _, comment := range comments {
+			if comment.GetID() == commentID {
+				comment.Body = &commentBody
+			}
+		}
+	}
+	c.recorder.record(Operation{Type: OperationEditComment, Org: org, Repo: repo, CommentID: commentID, Body: commentBody})
+	return nil
+}
+
+// errNotRecorded is returned by the changes the issue handlers don't make.
+var errNotRecorded = errors.New("not supported while recording")
+
+func (c *recordingClient) DeleteComment(string, string, int64) error {
+	return errNotRecorded
+}
+
+func (c *recordingClient) RemoveLabelForIssue(string, string, int, string) error {
+	return errNotRecorded
+}
+
+func (c *recordingClient) EditPullRequest(string, string, int, string, string) (*github.PullRequest, error) {
+	return nil, errNotRecorded
+}
+
+func (c *recordingClient) CreatePullRequest(string, string, string, string, string, string) (*github.PullRequest, error) {
+	return nil, errNotRecorded
+}
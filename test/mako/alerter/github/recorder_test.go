@@ -0,0 +1,122 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"knative.dev/pkg/test/ghutil"
+	"knative.dev/pkg/test/ghutil/fakeghutil"
+)
+
+func TestRecorder(t *testing.T) {
+	client := fakeghutil.NewFakeGithubClient()
+	existing, _ := client.CreateIssue("test_org", "test_repo", "[performance] test existing", "desc")
+	client.AddLabelsToIssue("test_org", "test_repo", existing.GetNumber(), []string{perfLabel})
+	summary, _ := client.CreateComment("test_org", "test_repo", existing.GetNumber(), "summary")
+	client.CloseIssue("test_org", "test_repo", existing.GetNumber())
+	now := time.Now()
+	existing.UpdatedAt = &now
+
+	recorder := NewRecorder()
+	handler := IssueHandler{
+		client: recorder.client(client),
+		config: config{org: "test_org", repo: "test_repo"},
+		cache:  newIssueCache(-1),
+	}
+	if err := handler.CreateIssueForTest("test existing", "run1", "regressed"); err != nil {
+		t.Fatalf("CreateIssueForTest() = %v", err)
+	}
+	if err := handler.CreateIssueForTest("test new", "run1", "regressed"); err != nil {
+		t.Fatalf("CreateIssueForTest() = %v", err)
+	}
+	// The issue created while recording is found again, even without cache.
+	if err := handler.CreateIssueForTest("test new", "run1", "regressed"); err != nil {
+		t.Fatalf("CreateIssueForTest() = %v", err)
+	}
+
+	type change struct {
+		Type      OperationType
+		Issue     int
+		CommentID int64
+	}
+	var got []change
+	for _, op := range recorder.Operations() {
+		if op.Org != "test_org" || op.Repo != "test_repo" {
+			t.Errorf("Operation %+v on the wrong repository", op)
+		}
+		got = append(got, change{Type: op.Type, Issue: op.Issue, CommentID: op.CommentID})
+	}
+	want := []change{
+		{Type: OperationReopen, Issue: existing.GetNumber()},
+		{Type: OperationComment, Issue: existing.GetNumber()},
+		{Type: OperationEditComment, CommentID: summary.GetID()},
+		{Type: OperationEdit, Issue: existing.GetNumber()},
+		// The new issue is labeled when it is created.
+		{Type: OperationCreate, Issue: -2},
+		{Type: OperationComment, Issue: -2},
+		{Type: OperationEdit, Issue: -2},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Operations (-want, +got): %s", diff)
+	}
+	for _, op := range recorder.Operations() {
+		if op.Type == OperationCreate && !cmp.Equal(op.Labels, []string{perfLabel}) {
+			t.Errorf("Labels of the new issue = %v, want %v", op.Labels, []string{perfLabel})
+		}
+	}
+
+	// Nothing was changed on Github.
+	if got := existing.GetState(); got != string(ghutil.IssueCloseState) {
+		t.Errorf("State of issue %d = %s, want closed", existing.GetNumber(), got)
+	}
+	if got := len(client.Issues["test_repo"]); got != 1 {
+		t.Errorf("Got %d issues, want 1", got)
+	}
+	if comments, _ := client.ListComments("test_org", "test_repo", existing.GetNumber()); len(comments) != 1 || comments[0].GetBody() != "summary" {
+		t.Errorf("Comments = %v, want the summary only", comments)
+	}
+
+	b, err := recorder.JSON()
+	if err != nil {
+		t.Fatalf("JSON() = %v", err)
+	}
+	var decoded []Operation
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("Failed to decode %s: %v", b, err)
+	}
+	if diff := cmp.Diff(recorder.Operations(), decoded); diff != "" {
+		t.Errorf("JSON (-want, +got): %s", diff)
+	}
+	if !strings.Contains(string(b), `"type": "create"`) {
+		t.Errorf("JSON = %s, wanted the create operation", b)
+	}
+}
+
+func TestRecorderEmptyJSON(t *testing.T) {
+	b, err := NewRecorder().JSON()
+	if err != nil {
+		t.Fatalf("JSON() = %v", err)
+	}
+	if got, want := string(b), "[]"; got != want {
+		t.Errorf("JSON() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"knative.dev/pkg/test/ghutil"
+	"knative.dev/pkg/test/ghutil/fakeghutil"
+	"knative.dev/pkg/test/helpers"
+)
+
+// flakyClient fails some of the calls of the fake client transiently.
+type flakyClient struct {
+	*fakeghutil.FakeGithubClient
+	// createFailures is the number of times creating an issue is rejected.
+	createFailures int
+	// commentFailures is the number of times adding a comment fails after
+	// adding it, like when the response is lost.
+	commentFailures int
+}
+
+func githubError(statusCode int) *http.Response {
+	return &http.Response{
+		StatusCode: statusCode,
+		Request:    &http.Request{Method: http.MethodPost, URL: &url.URL{Path: "/"}},
+	}
+}
+
+func (c *flakyClient) CreateLabeledIssue(org, repo, title, body string, labels []string) (*github.Issue, error) {
+	if c.createFailures > 0 {
+		c.createFailures--
+		return nil, &github.AbuseRateLimitError{Response: githubError(http.StatusForbidden)}
+	}
+	return c.FakeGithubClient.CreateLabeledIssue(org, repo, title, body, labels)
+}
+
+func (c *flakyClient) CreateComment(org, repo string, issueNumber int, commentBody string) (*github.IssueComment, error) {
+	comment, err := c.FakeGithubClient.CreateComment(org, repo, issueNumber, commentBody)
+	if err == nil && c.commentFailures > 0 {
+		c.commentFailures--
+		return nil, &github.ErrorResponse{Response: githubError(http.StatusBadGateway)}
+	}
+	return comment, err
+}
+
+func TestCreateIssueRetries(t *testing.T) {
+	client := &flakyClient{
+		FakeGithubClient: fakeghutil.NewFakeGithubClient(),
+		createFailures:   2,
+		commentFailures:  1,
+	}
+	handler := &IssueHandler{
+		client: client,
+		config: config{org: "test_org", repo: "test_repo", retry: helpers.RetryPolicy{
+			MaxAttempts: 3,
+			Backoff:     wait.Backoff{Duration: time.Millisecond},
+			Retryable:   ghutil.IsTransientError,
+		}},
+	}
+
+	testName := "test retries"
+	if err := handler.CreateIssueForTest(testName, "run1", "regressed"); err != nil {
+		t.Fatalf("CreateIssueForTest() = %v", err)
+	}
+	issue, md, err := handler.findIssue(testName)
+	if err != nil || issue == nil {
+		t.Fatalf("findIssue() = %v, %v, wanted the issue despite the failures", issue, err)
+	}
+	if got, want := md.Occurrences, 1; got != want {
+		t.Errorf("Occurrences = %d, want %d", got, want)
+	}
+	if labels := issue.Labels; len(labels) != 1 || labels[0].GetName() != perfLabel {
+		t.Errorf("Labels = %v, want %s", labels, perfLabel)
+	}
+	// The comment which was added despite failing isn't added again.
+	comments, _ := client.ListComments("test_org", "test_repo", issue.GetNumber())
+	if got, want := len(comments), 1; got != want {
+		t.Errorf("len(comments) = %d, want %d", got, want)
+	}
+
+	// Retrying the alert doesn't change anything.
+	if err := handler.CreateIssueForTest(testName, "run1", "regressed"); err != nil {
+		t.Fatalf("CreateIssueForTest() = %v", err)
+	}
+	if got, want := len(client.Issues["test_repo"]), 1; got != want {
+		t.Errorf("len(issues) = %d, want %d", got, want)
+	}
+	if comments, _ := client.ListComments("test_org", "test_repo", issue.GetNumber()); len(comments) != 1 {
+		t.Errorf("len(comments) = %d, want 1", len(comments))
+	}
+}
+
+func TestCreateIssueCompletesMissingSteps(t *testing.T) {
+	client := &flakyClient{
+		FakeGithubClient: fakeghutil.NewFakeGithubClient(),
+		commentFailures:  1,
+	}
+	// Without retries, adding the summary comment fails.
+	handler := &IssueHandler{client: client, config: config{org: "test_org", repo: "test_repo"}}
+	testName := "test missing steps"
+	if err := handler.CreateIssueForTest(testName, "run1", "regressed"); err == nil {
+		t.Fatal("CreateIssueForTest() = nil, wanted an error")
+	}
+	client.Comments = make(map[int]map[int64]*github.IssueComment)
+
+	// Retrying the alert adds the summary comment and records the regression.
+	if err := handler.CreateIssueForTest(testName, "run1", "regressed"); err != nil {
+		t.Fatalf("CreateIssueForTest() = %v", err)
+	}
+	issue, md, _ := handler.findIssue(testName)
+	if got, want := md.LastAlertedRunID, "run1"; got != want {
+		t.Errorf("LastAlertedRunID = %q, want %q", got, want)
+	}
+	if comments, _ := client.ListComments("test_org", "test_repo", issue.GetNumber()); len(comments) != 1 {
+		t.Errorf("len(comments) = %d, want 1", len(comments))
+	}
+}
+
+func TestRetriesStopWithContext(t *testing.T) {
+	client := &flakyClient{
+		FakeGithubClient: fakeghutil.NewFakeGithubClient(),
+		createFailures:   1,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	handler := &IssueHandler{
+		client: client,
+		config: config{org: "test_org", repo: "test_repo", ctx: ctx, retry: helpers.RetryPolicy{
+			MaxAttempts: 3,
+			Backoff:     wait.Backoff{Duration: time.Hour},
+			Retryable:   ghutil.IsTransientError,
+		}},
+	}
+
+	if err := handler.CreateIssueForTest("test canceled", "run1", "regressed"); err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("CreateIssueForTest() = %v, want %v", err, context.Canceled)
+	}
+	if got := len(client.Issues["test_repo"]); got != 0 {
+		t.Errorf("len(issues) = %d, want 0", got)
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"knative.dev/pkg/test/ghutil"
+	"knative.dev/pkg/test/ghutil/fakegithub"
+	"knative.dev/pkg/test/helpers"
+)
+
+// setupWithServer sets up an IssueHandler for knative/serving against a fake
+// Github server, retrying the failed calls right away. The returned func
+// cleans up.
+func setupWithServer(t *testing.T, opts Options) (*IssueHandler, *fakegithub.Server, func()) {
+	t.Helper()
+	server := fakegithub.NewServer("bot")
+	tokenFile, err := ioutil.TempFile("", "token")
+	if err != nil {
+		server.Close()
+		t.Fatalf("Failed to create the token file: %v", err)
+	}
+	tokenFile.WriteString("token")
+	tokenFile.Close()
+	cleanup := func() {
+		server.Close()
+		os.Remove(tokenFile.Name())
+	}
+
+	opts.BaseURL = server.URL()
+	opts.Retry = &helpers.RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     wait.Backoff{Duration: time.Millisecond, Factor: 1},
+		Retryable:   ghutil.IsTransientError,
+		RetryAfter:  ghutil.RetryAfter,
+	}
+	handler, err := Setup("knative", "serving", tokenFile.Name(), opts, false)
+	if err != nil {
+		cleanup()
+		t.Fatalf("Setup() = %v", err)
+	}
+	return handler, server, cleanup
+}
+
+func TestIssueLifecycleWithServer(t *testing.T) {
+	handler, server, cleanup := setupWithServer(t, Options{RecoveryRuns: 2, Labels: []string{"area/test"}})
+	defer cleanup()
+	// An issue closed recently, predating the metadata.
+	closed := server.AddIssue("knative", "serving", github.Issue{
+		Title:  github.String("[performance] test reopened"),
+		State:  github.String("closed"),
+		Labels: []github.Label{{Name: github.String(perfLabel)}},
+	})
+
+	if err := handler.CreateIssueForTest("test new", "run1", "regressed"); err != nil {
+		t.Fatalf("CreateIssueForTest() = %v", err)
+	}
+	if err := handler.CreateIssueForTest("test reopened", "run1", "regressed"); err != nil {
+		t.Fatalf("CreateIssueForTest() = %v", err)
+	}
+	// The same run isn't reported twice.
+	if err := handler.CreateIssueForTest("test new", "run1", "regressed"); err != nil {
+		t.Fatalf("CreateIssueForTest() = %v", err)
+	}
+
+	issues := server.Issues("knative", "serving")
+	if got, want := len(issues), 2; got != want {
+		t.Fatalf("Got %d issues, want %d", got, want)
+	}
+	created := issues[1]
+	if got, want := created.GetTitle(), "[performance] test new"; got != want {
+		t.Errorf("Title = %q, want %q", got, want)
+	}
+	var labels []string
+	for _, label := range created.Labels {
+		labels = append(labels, label.GetName())
+	}
+	if got, want := strings.Join(labels, ","), perfLabel+",area/test"; got != want {
+		t.Errorf("Labels = %s, want %s", got, want)
+	}
+	if got := len(server.Comments("knative", "serving", created.GetNumber())); got != 1 {
+		t.Errorf("Got %d comments on the new issue, want the summary", got)
+	}
+	if got := issues[0].GetState(); got != "open" {
+		t.Errorf("State of issue %d = %s, want open", closed, got)
+	}
+	// The reopening comment is then edited into the summary.
+	if got := len(server.Comments("knative", "serving", closed)); got != 1 {
+		t.Errorf("Got %d comments on the reopened issue, want 1", got)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := handler.ResolveIssue("test new"); err != nil {
+			t.Fatalf("ResolveIssue() = %v", err)
+		}
+	}
+	if got := server.Issues("knative", "serving")[1].GetState(); got != "closed" {
+		t.Errorf("State of the resolved issue = %s, want closed", got)
+	}
+	if got := len(server.Comments("knative", "serving", created.GetNumber())); got != 2 {
+		t.Errorf("Got %d comments on the resolved issue, want 2", got)
+	}
+}
+
+func TestRetriesWithServer(t *testing.T) {
+	handler, server, cleanup := setupWithServer(t, Options{})
+	defer cleanup()
+	server.Script(
+		fakegithub.AbuseRateLimited(http.MethodPost, "/repos/*/*/issues", 0),
+		// The comment is added, but the response is lost.
+		fakegithub.Step{Method: http.MethodPost, Path: "/repos/*/*/issues/*/comments", Process: true, Status: http.StatusBadGateway},
+	)
+
+	if err := handler.CreateIssueForTest("test", "run1", "regressed"); err != nil {
+		t.Fatalf("CreateIssueForTest() = %v", err)
+	}
+	issues := server.Issues("knative", "serving")
+	if got := len(issues); got != 1 {
+		t.Fatalf("Got %d issues, want 1", got)
+	}
+	if got := len(server.Comments("knative", "serving", issues[0].GetNumber())); got != 1 {
+		t.Errorf("Got %d comments, want the summary once", got)
+	}
+
+	creates := 0
+	for _, r := range server.Requests() {
+		if r.Method == http.MethodPost && r.Path == "/repos/knative/serving/issues" {
+			creates++
+		}
+	}
+	if creates != 2 {
+		t.Errorf("Got %d requests creating the issue, want 2", creates)
+	}
+}
+
+func TestServerErrorWithServer(t *testing.T) {
+	handler, server, cleanup := setupWithServer(t, Options{})
+	defer cleanup()
+	server.Script(fakegithub.Step{Path: "/search/issues", Times: 3, Status: http.StatusBadGateway})
+
+	if err := handler.CreateIssueForTest("test", "run1", "regressed"); err == nil {
+		t.Error("CreateIssueForTest() = nil, wanted an error")
+	}
+	if got := len(server.Issues("knative", "serving")); got != 0 {
+		t.Errorf("Got %d issues without finding the existing ones, want 0", got)
+	}
+}
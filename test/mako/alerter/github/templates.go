@@ -0,0 +1,189 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	makoconfig "knative.dev/pkg/test/mako/config"
+)
+
+// TemplateData is the data the templates of the issues are executed with.
+type TemplateData struct {
+	// TestName is the name of the test the issue tracks the regressions of.
+	TestName string
+	// Org and Repo identify the repository the issue is filed in.
+	Org  string
+	Repo string
+	// Description describes the regression, in the Summary and Reopen templates.
+	Description string
+	// Runs is the number of consecutive runs without regression, in the
+	// Recovered template.
+	Runs int
+	// Period is the period the regressions are batched for in the digest
+	// templates, e.g. `2019-12-01` or `run 1234`.
+	Period string
+	// Suites are the regressions reported at once, grouped by test suite,
+	// in the DigestReport template.
+	Suites []Suite
+}
+
+// Templates are the templates of the issues and their comments, executed
+// with TemplateData. The nil ones default to the built-in templates.
+type Templates struct {
+	// Title is the title of the issues. Issues without metadata are
+	// identified by their title, so changing it orphans them.
+	Title *template.Template
+	// Body is the body of the issues, which the metadata is appended to.
+	Body *template.Template
+	// Summary is the first comment of the issues, summarizing the last
+	// regression detected.
+	Summary *template.Template
+	// Reopen is the comment of the issues reopened for a new regression.
+	Reopen *template.Template
+	// Close is the comment of the issues closed by CloseIssueForTest.
+	Close *template.Template
+	// Recovered is the comment of the issues closed by ResolveIssue.
+	Recovered *template.Template
+	// DigestTitle is the title of the issues batching the regressions of
+	// a period, in digest mode.
+	DigestTitle *template.Template
+	// DigestBody is the body of the digest issues.
+	DigestBody *template.Template
+	// DigestEntry is the comment of the digest issues for each regression.
+	DigestEntry *template.Template
+	// DigestReport is the report of the regressions of a run reported at
+	// once by ReportRegressions, in the body of a new digest issue or in a
+	// comment of the digest issue of the period.
+	DigestReport *template.Template
+}
+
+// defaultTemplates are the built-in templates.
+var defaultTemplates = Templates{
+	Title: template.Must(template.New("title").Parse(`[performance] {{.TestName}}`)),
+	Body: template.Must(template.New("body").Parse(`
+### Auto-generated issue tracking performance regression
+* **Test name**: {{.TestName}}
+* **Repository name**: {{.Repo}}`)),
+	Summary: template.Must(template.New("summary").Parse(`
+A new regression for this test has been detected:
+{{.Description}}`)),
+	Reopen: template.Must(template.New("reopen").Parse(`
+New regression has been detected, reopening this issue:
+{{.Description}}`)),
+	Close: template.Must(template.New("close").Parse(`
+The performance regression goes away for this test, closing this issue.`)),
+	Recovered: template.Must(template.New("recovered").Parse(`
+The performance of this test has recovered, no regression was detected in the last {{.Runs}} runs, closing this issue.`)),
+	DigestTitle: template.Must(template.New("digestTitle").Parse(`[performance] Regressions of {{.Period}}`)),
+	DigestBody: template.Must(template.New("digestBody").Parse(`
+### Auto-generated issue digesting the performance regressions of {{.Period}}
+* **Repository name**: {{.Repo}}`)),
+	DigestEntry: template.Must(template.New("digestEntry").Parse(`
+A regression has been detected for **{{.TestName}}**:
+{{.Description}}`)),
+	DigestReport: template.Must(template.New("digestReport").Parse(`
+Regressions have been detected in {{.Period}}:
+{{range .Suites}}
+#### {{.Name}} ({{len .Regressions}})
+{{range .Regressions}}
+<details><summary>{{.TestName}}</summary>
+
+{{.Description}}
+</details>
+{{end}}{{end}}`)),
+}
+
+// withDefaults returns the templates with the nil ones replaced by the
+// built-in ones.
+func (t Templates) withDefaults() Templates {
+	if t.Title == nil {
+		t.Title = defaultTemplates.Title
+	}
+	if t.Body == nil {
+		t.Body = defaultTemplates.Body
+	}
+	if t.Summary == nil {
+		t.Summary = defaultTemplates.Summary
+	}
+	if t.Reopen == nil {
+		t.Reopen = defaultTemplates.Reopen
+	}
+	if t.Close == nil {
+		t.Close = defaultTemplates.Close
+	}
+	if t.Recovered == nil {
+		t.Recovered = defaultTemplates.Recovered
+	}
+	if t.DigestTitle == nil {
+		t.DigestTitle = defaultTemplates.DigestTitle
+	}
+	if t.DigestBody == nil {
+		t.DigestBody = defaultTemplates.DigestBody
+	}
+	if t.DigestEntry == nil {
+		t.DigestEntry = defaultTemplates.DigestEntry
+	}
+	if t.DigestReport == nil {
+		t.DigestReport = defaultTemplates.DigestReport
+	}
+	return t
+}
+
+// ParseTemplates parses the templates of the given configuration. The
+// templates which aren't configured are left nil, to default to the
+// built-in ones.
+func ParseTemplates(cfg makoconfig.GithubTemplates) (Templates, error) {
+	var templates Templates
+	for _, t := range []struct {
+		name string
+		text string
+		into **template.Template
+	}{
+		{"title", cfg.Title, &templates.Title},
+		{"body", cfg.Body, &templates.Body},
+		{"summary", cfg.Summary, &templates.Summary},
+		{"reopen", cfg.Reopen, &templates.Reopen},
+		{"close", cfg.Close, &templates.Close},
+		{"recovered", cfg.Recovered, &templates.Recovered},
+		{"digestTitle", cfg.DigestTitle, &templates.DigestTitle},
+		{"digestBody", cfg.DigestBody, &templates.DigestBody},
+		{"digestEntry", cfg.DigestEntry, &templates.DigestEntry},
+		{"digestReport", cfg.DigestReport, &templates.DigestReport},
+	} {
+		if t.text == "" {
+			continue
+		}
+		parsed, err := template.New(t.name).Parse(t.text)
+		if err != nil {
+			return Templates{}, fmt.Errorf("failed to parse the %s template: %v", t.name, err)
+		}
+		*t.into = parsed
+	}
+	return templates, nil
+}
+
+// execute returns the text of the template executed with the data.
+func execute(t *template.Template, data TemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute the %s template: %v", t.Name(), err)
+	}
+	return buf.String(), nil
+}
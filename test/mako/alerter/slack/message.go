@@ -19,6 +19,8 @@ package slack
 import (
 	"flag"
 	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -27,14 +29,38 @@ import (
 	"knative.dev/pkg/test/slackutil"
 )
 
-var minInterval = flag.Duration("min-alert-interval", 24*time.Hour, "The minimum interval of sending Slack alerts.")
+var (
+	minInterval  = flag.Duration("min-alert-interval", 24*time.Hour, "The minimum interval of sending Slack alerts.")
+	threadWindow = flag.Duration("alert-thread-window", 7*24*time.Hour, "How long the follow-up Slack alerts of a test are threaded under its original alert.")
+)
 
 const (
 	messageTemplate = `
 As of %s, there is a new performance regression detected from test automation for **%s**:
 %s`
+	followUpTemplate = `
+As of %s, the performance regression is detected again:
+%s`
+	// alertMarkerTemplate identifies the alert message of a test.
+	alertMarkerTemplate = "detected from test automation for **%s**:"
+	// lastDetectedPrefix and resolvedPrefix start the status line appended
+	// to the alert message of a test.
+	lastDetectedPrefix = "\n\nLast detected as of "
+	resolvedPrefix     = "\n\nResolved as of "
 )
 
+// MessageOperations defines the operations on the Slack alert messages of
+// the tests. Each test has a single alert message per channel, under which
+// the follow-up regressions are threaded until it's resolved.
+type MessageOperations interface {
+	// SendAlert posts the alert message of the test, or replies in its
+	// thread if it's already been posted.
+	SendAlert(testName, summary string) error
+	// ResolveAlert updates the alert message of the test, if any, to mark
+	// it as resolved.
+	ResolveAlert(testName string) error
+}
+
 // MessageHandler handles methods for slack messages
 type MessageHandler struct {
 	readClient  slackutil.ReadOperations
@@ -43,6 +69,8 @@ type MessageHandler struct {
 	dryrun      bool
 }
 
+var _ MessageOperations = (*MessageHandler)(nil)
+
 // Setup creates the necessary setup to make calls to work with slack
 func Setup(userName, readTokenPath, writeTokenPath string, channels []config.Channel, dryrun bool) (*MessageHandler, error) {
 	readClient, err := slackutil.NewReadClient(userName, readTokenPath)
@@ -61,9 +89,121 @@ func Setup(userName, readTokenPath, writeTokenPath string, channels []config.Cha
 	}, nil
 }
 
-// SendAlert will send alert for performance regression to the slack channel(s)
+// SendAlert will send the alert text to the slack channels. The first alert
+// of a test is posted as a new message, and the follow-up ones are replied in
+// its thread, at most once every min-alert-interval.
 func (smh *MessageHandler) SendAlert(testName, summary string) error {
-	dryrun := smh.dryrun
+	return smh.forEachChannel(func(channel config.Channel) error {
+		original, err := smh.findAlert(channel, testName)
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+
+		// post the alert message to the channel if there's none yet
+		if original == nil {
+			message := fmt.Sprintf(messageTemplate, now, testName, summary)
+			if err := helpers.Run(
+				fmt.Sprintf("sending message %q to channel %q", message, channel.Name),
+				func() error {
+					return smh.writeClient.Post(message, channel.Identity)
+				},
+				smh.dryrun,
+			); err != nil {
+				return fmt.Errorf("failed to send message to channel %q", channel.Name)
+			}
+			return nil
+		}
+
+		// do not send message again if the test was alerted on a short while ago
+		lastAlert := original.Timestamp
+		if original.LatestReply != "" {
+			lastAlert = original.LatestReply
+		}
+		if time.Since(parseTimestamp(lastAlert)) < *minInterval {
+			return nil
+		}
+
+		// thread the follow-up under the alert message, and update it to
+		// show when the regression was last detected
+		message := fmt.Sprintf(followUpTemplate, now, summary)
+		if err := helpers.Run(
+			fmt.Sprintf("replying message %q in channel %q", message, channel.Name),
+			func() error {
+				_, err := smh.writeClient.PostMessage(message, channel.Identity, original.Timestamp)
+				return err
+			},
+			smh.dryrun,
+		); err != nil {
+			return fmt.Errorf("failed to reply to the alert in channel %q", channel.Name)
+		}
+		text := alertText(original.Text) + lastDetectedPrefix + fmt.Sprintf("%s.", now)
+		if err := helpers.Run(
+			fmt.Sprintf("updating message %q in channel %q", original.Timestamp, channel.Name),
+			func() error {
+				return smh.writeClient.Update(text, channel.Identity, original.Timestamp)
+			},
+			smh.dryrun,
+		); err != nil {
+			return fmt.Errorf("failed to update the alert in channel %q", channel.Name)
+		}
+		return nil
+	})
+}
+
+// ResolveAlert marks the alert message of the test in the slack channels as
+// resolved, so that the next alert is posted as a new message.
+func (smh *MessageHandler) ResolveAlert(testName string) error {
+	return smh.forEachChannel(func(channel config.Channel) error {
+		original, err := smh.findAlert(channel, testName)
+		if err != nil || original == nil {
+			return err
+		}
+		text := alertText(original.Text) + resolvedPrefix + fmt.Sprintf("%s.", time.Now().UTC())
+		if err := helpers.Run(
+			fmt.Sprintf("updating message %q in channel %q", original.Timestamp, channel.Name),
+			func() error {
+				return smh.writeClient.Update(text, channel.Identity, original.Timestamp)
+			},
+			smh.dryrun,
+		); err != nil {
+			return fmt.Errorf("failed to resolve the alert in channel %q", channel.Name)
+		}
+		return nil
+	})
+}
+
+// findAlert returns the most recent alert message of the test in the channel
+// which is not resolved, or nil if there's none.
+func (smh *MessageHandler) findAlert(channel config.Channel, testName string) (*slackutil.Message, error) {
+	var messages []slackutil.Message
+	if err := helpers.Run(
+		fmt.Sprintf("retrieving message history in channel %q", channel.Name),
+		func() error {
+			var err error
+			messages, err = smh.readClient.Messages(channel.Identity, time.Now().Add(-1**threadWindow))
+			return err
+		},
+		smh.dryrun,
+	); err != nil {
+		return nil, fmt.Errorf("failed to retrieve message history in channel %q", channel.Name)
+	}
+
+	marker := fmt.Sprintf(alertMarkerTemplate, testName)
+	for i := range messages {
+		if strings.Contains(messages[i].Text, marker) {
+			if isResolved(messages[i].Text) {
+				return nil, nil
+			}
+			return &messages[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// forEachChannel calls f concurrently for each of the channels, and combines
+// the errors it returns.
+func (smh *MessageHandler) forEachChannel(f func(config.Channel) error) error {
 	errCh := make(chan error)
 	var wg sync.WaitGroup
 	for i := range smh.channels {
@@ -71,34 +211,8 @@ func (smh *MessageHandler) SendAlert(testName, summary string) error {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			// get the recent message history in the channel for this user
-			startTime := time.Now().Add(-1 * *minInterval)
-			var messageHistory []string
-			if err := helpers.Run(
-				fmt.Sprintf("retrieving message history in channel %q", channel.Name),
-				func() error {
-					var err error
-					messageHistory, err = smh.readClient.MessageHistory(channel.Identity, startTime)
-					return err
-				},
-				dryrun,
-			); err != nil {
-				errCh <- fmt.Errorf("failed to retrieve message history in channel %q", channel.Name)
-			}
-			// do not send message again if messages were sent on the same channel a short while ago
-			if len(messageHistory) != 0 {
-				return
-			}
-			// send the alert message to the channel
-			message := fmt.Sprintf(messageTemplate, time.Now().UTC(), testName, summary)
-			if err := helpers.Run(
-				fmt.Sprintf("sending message %q to channel %q", message, channel.Name),
-				func() error {
-					return smh.writeClient.Post(message, channel.Identity)
-				},
-				dryrun,
-			); err != nil {
-				errCh <- fmt.Errorf("failed to send message to channel %q", channel.Name)
+			if err := f(channel); err != nil {
+				errCh <- err
 			}
 		}()
 	}
@@ -115,3 +229,26 @@ func (smh *MessageHandler) SendAlert(testName, summary string) error {
 
 	return helpers.CombineErrors(errs)
 }
+
+// alertText returns the text of an alert message without its status line.
+func alertText(text string) string {
+	if i := strings.Index(text, lastDetectedPrefix); i >= 0 {
+		return text[:i]
+	}
+	return text
+}
+
+// isResolved returns whether the alert message was marked as resolved.
+func isResolved(text string) bool {
+	return strings.Contains(text, resolvedPrefix)
+}
+
+// parseTimestamp parses the timestamp of a Slack message, which is the
+// number of seconds since the epoch.
+func parseTimestamp(ts string) time.Time {
+	secs, err := strconv.ParseFloat(ts, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(secs*float64(time.Second)))
+}
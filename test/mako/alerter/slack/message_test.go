@@ -18,6 +18,7 @@ package slack
 
 import (
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -70,3 +71,59 @@ func TestMessaging(t *testing.T) {
 		}
 	}
 }
+
+func TestMessageThreading(t *testing.T) {
+	defer func(interval time.Duration) { *minInterval = interval }(*minInterval)
+	*minInterval = 0
+
+	client := fakeslackutil.NewFakeSlackClient()
+	channel := config.Channel{Name: "test_channel", Identity: "fsfdsf"}
+	handler := &MessageHandler{
+		readClient:  client,
+		writeClient: client,
+		channels:    []config.Channel{channel},
+	}
+
+	if err := handler.SendAlert("threaded test", "first regression"); err != nil {
+		t.Fatalf("SendAlert() = %v", err)
+	}
+	if err := handler.SendAlert("threaded test", "second regression"); err != nil {
+		t.Fatalf("SendAlert() = %v", err)
+	}
+	messages, err := client.Messages(channel.Identity, time.Now().Add(-1*time.Hour))
+	if err != nil {
+		t.Fatalf("Messages() = %v", err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("len(messages) = %d, wanted 1", len(messages))
+	}
+	original := messages[0]
+	if !strings.Contains(original.Text, "first regression") || !strings.Contains(original.Text, "Last detected as of") {
+		t.Errorf("the alert message is not updated with the follow-up: %q", original.Text)
+	}
+	replies := client.Replies(channel.Identity, original.Timestamp)
+	if len(replies) != 1 || !strings.Contains(replies[0], "second regression") {
+		t.Errorf("Replies() = %v, wanted the second regression", replies)
+	}
+
+	// Once resolved, the next regression is alerted on in a new message.
+	if err := handler.ResolveAlert("threaded test"); err != nil {
+		t.Fatalf("ResolveAlert() = %v", err)
+	}
+	if err := handler.SendAlert("threaded test", "third regression"); err != nil {
+		t.Fatalf("SendAlert() = %v", err)
+	}
+	messages, err = client.Messages(channel.Identity, time.Now().Add(-1*time.Hour))
+	if err != nil {
+		t.Fatalf("Messages() = %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("len(messages) = %d, wanted 2", len(messages))
+	}
+	if !strings.Contains(messages[0].Text, "third regression") {
+		t.Errorf("the most recent message is %q, wanted the third regression", messages[0].Text)
+	}
+	if !isResolved(messages[1].Text) || strings.Contains(messages[1].Text, "Last detected as of") {
+		t.Errorf("the original alert message is not resolved: %q", messages[1].Text)
+	}
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mako
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/mako/go/quickstore"
+	qpb "github.com/google/mako/proto/quickstore/quickstore_go_proto"
+)
+
+// Storage stores the samples of the runs of a benchmark, e.g. in Mako through
+// a Quickstore.
+type Storage interface {
+	// AddSamplePoint adds the values of the metrics, by value key, at the
+	// given x-value, e.g. XTime of the time they were measured at.
+	AddSamplePoint(xval float64, values map[string]float64) error
+	// AddError adds an error which happened at the given x-value.
+	AddError(xval float64, message string) error
+	// AddRunAggregate adds an aggregate of the whole run.
+	AddRunAggregate(valueKey string, value float64) error
+	// Store stores the run with everything added so far, and returns its key.
+	Store(ctx context.Context) (string, error)
+}
+
+// Analyzer analyzes the stored runs of a benchmark for regressions.
+type Analyzer interface {
+	// Analyze analyzes the run with the given key.
+	Analyze(ctx context.Context, runKey string) (Analysis, error)
+}
+
+// Analysis is the result of the analysis of a run.
+type Analysis struct {
+	// RunKey is the key of the run analyzed.
+	RunKey string
+	// Regressions describes the regressions detected in the run, if any.
+	Regressions []string
+	// Link is a link to a chart of the run, if any.
+	Link string
+	// GoodCommit is the commit of the last run without regression before
+	// the analyzed one, if known, to correlate the regressions with the
+	// changes merged since, see alerter.CommitRange. Only the LocalBackend
+	// knows it, the QuickstoreBackend can't query the past runs.
+	GoodCommit string
+}
+
+// Regressed returns true if regressions were detected in the run.
+func (a Analysis) Regressed() bool {
+	return len(a.Regressions) > 0
+}
+
+// Summary summarizes the regressions for alerts.
+func (a Analysis) Summary() string {
+	summary := strings.Join(a.Regressions, "\n")
+	if a.Link != "" {
+		summary += fmt.Sprintf("\n\nSee run chart at: %s", a.Link)
+	}
+	return summary
+}
+
+// QuickstoreBackend is the Storage and Analyzer backed by the Mako service.
+// The runs are stored through the Quickstore, and analyzed by the analyzers
+// of the Mako service as they are stored.
+type QuickstoreBackend struct {
+	*quickstore.Quickstore
+
+	mu      sync.Mutex
+	outputs map[string]qpb.QuickstoreOutput
+}
+
+var (
+	_ Storage  = (*QuickstoreBackend)(nil)
+	_ Analyzer = (*QuickstoreBackend)(nil)
+)
+
+// NewQuickstoreBackend creates a QuickstoreBackend storing the runs through
+// the given Quickstore.
+func NewQuickstoreBackend(q *quickstore.Quickstore) *QuickstoreBackend {
+	return &QuickstoreBackend{
+		Quickstore: q,
+		outputs:    make(map[string]qpb.QuickstoreOutput),
+	}
+}
+
+// Store implements Storage. Runs failing the analysis are stored nonetheless.
+func (b *QuickstoreBackend) Store(ctx context.Context) (string, error) {
+	out, err := b.Quickstore.Store()
+	if err != nil && out.GetStatus() != qpb.QuickstoreOutput_ANALYSIS_FAIL {
+		return "", err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.outputs[out.GetRunKey()] = out
+	return out.GetRunKey(), nil
+}
+
+// Analyze implements Analyzer, for the runs stored by this backend. The
+// analysis never has a GoodCommit, since the Mako sidecar can't query the past
+// runs, so the regressions aren't correlated with the commits.
+func (b *QuickstoreBackend) Analyze(ctx context.Context, runKey string) (Analysis, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out, ok := b.outputs[runKey]
+	if !ok {
+		return Analysis{}, fmt.Errorf("run %q was not stored by this backend", runKey)
+	}
+	analysis := Analysis{RunKey: runKey, Link: out.GetRunChartLink()}
+	if out.GetStatus() == qpb.QuickstoreOutput_ANALYSIS_FAIL {
+		analysis.Regressions = []string{out.GetSummaryOutput()}
+	}
+	return analysis, nil
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mako
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	tpb "github.com/google/mako/clients/proto/analyzers/threshold_analyzer_go_proto"
+	mpb "github.com/google/mako/spec/proto/mako_go_proto"
+)
+
+// madScale scales the median absolute deviation to estimate the standard
+// deviation of normally distributed values.
+const madScale = 1.4826
+
+// Baseline is a robust estimate of the value of an aggregate over past runs,
+// insensitive to the few outliers a standard deviation would be skewed by.
+type Baseline struct {
+	// Runs is the number of runs the baseline is computed from.
+	Runs int
+	// Median is the median of the values.
+	Median float64
+	// MAD is the median absolute deviation of the values from their median.
+	MAD float64
+}
+
+// ComputeBaseline computes the baseline of the aggregate selected by the filter
+// over the given runs. Runs missing the aggregate are skipped.
+func ComputeBaseline(runs []*mpb.RunInfo, filter *mpb.DataFilter) Baseline {
+	values := make([]float64, 0, len(runs))
+	for _, run := range runs {
+		if v, ok := runValue(run, filter); ok {
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return Baseline{}
+	}
+	med := median(values)
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(v - med)
+	}
+	return Baseline{Runs: len(values), Median: med, MAD: median(deviations)}
+}
+
+// median returns the median of the values, which it sorts.
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
+
+// Calibration defines how thresholds are calibrated from baselines.
+type Calibration struct {
+	// Runs is the number of most recent runs the baselines are computed over.
+	Runs int
+	// MinRuns is the minimum number of runs with data for a baseline to be
+	// used. Thresholds without enough data keep their configured bounds.
+	MinRuns int
+	// Deviations is the number of scaled MADs, i.e. estimated standard
+	// deviations, the bounds are set away from the median.
+	Deviations float64
+	// MinMargin is the minimum distance of the bounds from the median,
+	// relative to the median, so perfectly stable values don't make any
+	// deviation a regression.
+	MinMargin float64
+}
+
+// DefaultCalibration returns a Calibration setting the bounds 3 estimated
+// standard deviations, and at least 5%, away from the median of the last 30 runs.
+func DefaultCalibration() Calibration {
+	return Calibration{
+		Runs:       30,
+		MinRuns:    10,
+		Deviations: 3,
+		MinMargin:  0.05,
+	}
+}
+
+// margin returns the distance of the bounds from the median of the baseline.
+func (c Calibration) margin(b Baseline) float64 {
+	return math.Max(c.Deviations*madScale*b.MAD, c.MinMargin*math.Abs(b.Median))
+}
+
+// Calibrate returns a copy of the threshold config with its bounds set from the
+// baseline. Only the bounds set in the config are calibrated, or the max if none is.
+// The config is returned unchanged if the baseline has too few runs.
+func (c Calibration) Calibrate(config *tpb.ThresholdConfig, b Baseline) *tpb.ThresholdConfig {
+	calibrated := proto.Clone(config).(*tpb.ThresholdConfig)
+	if b.Runs < c.MinRuns || b.Runs == 0 {
+		return calibrated
+	}
+	margin := c.margin(b)
+	if config.Min != nil {
+		calibrated.Min = proto.Float64(b.Median - margin)
+	}
+	if config.Max != nil || config.Min == nil {
+		calibrated.Max = proto.Float64(b.Median + margin)
+	}
+	return calibrated
+}
+
+// CalibrateThresholds fetches the recent runs of the benchmark and returns copies
+// of the given threshold configs with their bounds calibrated from the baselines
+// of the aggregates they filter on. The LocalBackend is the only RunHistory of
+// the package, the runs stored in Mako can't be fetched through its sidecar.
+func CalibrateThresholds(ctx context.Context, history RunHistory, benchmarkKey string, c Calibration, configs ...*tpb.ThresholdConfig) ([]*tpb.ThresholdConfig, error) {
+	runs, err := history.RecentRuns(ctx, benchmarkKey, c.Runs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the recent runs of benchmark %q: %v", benchmarkKey, err)
+	}
+	calibrated := make([]*tpb.ThresholdConfig, 0, len(configs))
+	for _, config := range configs {
+		calibrated = append(calibrated, c.Calibrate(config, ComputeBaseline(runs, config.GetDataFilter())))
+	}
+	return calibrated, nil
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mako
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	tpb "github.com/google/mako/clients/proto/analyzers/threshold_analyzer_go_proto"
+	mpb "github.com/google/mako/spec/proto/mako_go_proto"
+)
+
+var p95Filter = &mpb.DataFilter{
+	DataType:            mpb.DataFilter_METRIC_AGGREGATE_PERCENTILE.Enum(),
+	ValueKey:            proto.String("l"),
+	PercentileMilliRank: proto.Int32(95000),
+}
+
+func TestComputeBaseline(t *testing.T) {
+	tests := []struct {
+		name string
+		runs []*mpb.RunInfo
+		want Baseline
+	}{{
+		name: "no runs",
+	}, {
+		name: "odd",
+		runs: runs(1, 2, 3, 4, 100),
+		want: Baseline{Runs: 5, Median: 3, MAD: 1},
+	}, {
+		name: "even",
+		runs: runs(1, 2, 3, 4),
+		want: Baseline{Runs: 4, Median: 2.5, MAD: 1},
+	}, {
+		name: "runs without data are skipped",
+		runs: append(runs(2, 2, 2), &mpb.RunInfo{}),
+		want: Baseline{Runs: 3, Median: 2},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ComputeBaseline(test.runs, p95Filter); got != test.want {
+				t.Errorf("ComputeBaseline() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCalibrate(t *testing.T) {
+	c := Calibration{MinRuns: 3, Deviations: 2, MinMargin: 0.1}
+	baseline := Baseline{Runs: 5, Median: 10, MAD: 1}
+	margin := 2 * madScale
+
+	tests := []struct {
+		name     string
+		config   *tpb.ThresholdConfig
+		baseline Baseline
+		wantMin  *float64
+		wantMax  *float64
+	}{{
+		name:     "max only",
+		config:   &tpb.ThresholdConfig{Max: proto.Float64(100)},
+		baseline: baseline,
+		wantMax:  proto.Float64(10 + margin),
+	}, {
+		name:     "min only",
+		config:   &tpb.ThresholdConfig{Min: proto.Float64(0)},
+		baseline: baseline,
+		wantMin:  proto.Float64(10 - margin),
+	}, {
+		name:     "both bounds",
+		config:   &tpb.ThresholdConfig{Min: proto.Float64(0), Max: proto.Float64(100)},
+		baseline: baseline,
+		wantMin:  proto.Float64(10 - margin),
+		wantMax:  proto.Float64(10 + margin),
+	}, {
+		name:     "no bounds",
+		config:   &tpb.ThresholdConfig{},
+		baseline: baseline,
+		wantMax:  proto.Float64(10 + margin),
+	}, {
+		name:     "minimum margin",
+		config:   &tpb.ThresholdConfig{Max: proto.Float64(100)},
+		baseline: Baseline{Runs: 5, Median: 10},
+		wantMax:  proto.Float64(11),
+	}, {
+		name:     "too few runs",
+		config:   &tpb.ThresholdConfig{Max: proto.Float64(100)},
+		baseline: Baseline{Runs: 2, Median: 10, MAD: 1},
+		wantMax:  proto.Float64(100),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			original := proto.Clone(test.config)
+			got := c.Calibrate(test.config, test.baseline)
+			if !floatPtrEqual(got.Min, test.wantMin) {
+				t.Errorf("Min = %v, want %v", got.Min, test.wantMin)
+			}
+			if !floatPtrEqual(got.Max, test.wantMax) {
+				t.Errorf("Max = %v, want %v", got.Max, test.wantMax)
+			}
+			if !proto.Equal(original, test.config) {
+				t.Errorf("Calibrate() modified the config: %v", test.config)
+			}
+		})
+	}
+}
+
+func TestCalibrateThresholds(t *testing.T) {
+	history := &fakeHistory{runs: runs(10, 10, 11, 9, 10, 50)}
+	c := DefaultCalibration()
+	c.MinRuns = 3
+	configs, err := CalibrateThresholds(context.Background(), history, "key", c, &tpb.ThresholdConfig{
+		Max:        proto.Float64(1000),
+		DataFilter: p95Filter,
+		ConfigName: proto.String("p95"),
+	})
+	if err != nil {
+		t.Fatalf("CalibrateThresholds() = %v", err)
+	}
+	if history.limit != c.Runs {
+		t.Errorf("Fetched %d runs, want %d", history.limit, c.Runs)
+	}
+	// The median is 10 and the MAD 0.5, the outlier doesn't matter.
+	if want := 10 + 3*madScale*0.5; len(configs) != 1 || !floatPtrEqual(configs[0].Max, &want) {
+		t.Errorf("CalibrateThresholds() = %v, want a max of %v", configs, want)
+	}
+	if got := configs[0].GetConfigName(); got != "p95" {
+		t.Errorf("ConfigName = %q, want p95", got)
+	}
+
+	if _, err := CalibrateThresholds(context.Background(), &fakeHistory{}, "key", c); err == nil {
+		t.Error("CalibrateThresholds() = nil, wanted an error when the history fails")
+	}
+}
+
+func floatPtrEqual(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return almostEqual(*a, *b)
+}
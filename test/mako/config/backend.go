@@ -0,0 +1,228 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// MakoBackend stores the benchmark samples in Mako, whose analyzers
+	// detect the regressions.
+	MakoBackend = "mako"
+	// LocalBackend stores the benchmark samples in local JSON files, and
+	// detects the regressions with the configured checks.
+	LocalBackend = "local"
+
+	// defaultLocalPath is the directory the local backend stores the runs in
+	// by default.
+	defaultLocalPath = "/var/mako"
+)
+
+// Aggregates of the metrics of a run the regression checks can be done on,
+// besides the percentiles, e.g. `p95`.
+const (
+	AggregateMean   = "mean"
+	AggregateMedian = "median"
+	AggregateMin    = "min"
+	AggregateMax    = "max"
+	AggregateCount  = "count"
+	AggregateStdDev = "stddev"
+	// AggregateRun is the run aggregate with the metric as key.
+	AggregateRun = "run"
+	// AggregateErrors is the number of errors of the run, the metric is ignored.
+	AggregateErrors = "errors"
+)
+
+// RegressionCheck is a check of the local backend that a run of a benchmark
+// didn't regress, either by crossing absolute bounds or by deviating from the
+// median of the previous runs.
+type RegressionCheck struct {
+	// Name identifies the check among the ones of the benchmark.
+	Name string `yaml:"name"`
+	// Metric is the value key of the metric.
+	Metric string `yaml:"metric"`
+	// Aggregate is the aggregate of the metric in each run which is checked,
+	// e.g. `p95`. Defaults to the median.
+	Aggregate string `yaml:"aggregate,omitempty"`
+
+	// Min and Max are the absolute bounds of the aggregate, if any.
+	Min *float64 `yaml:"min,omitempty"`
+	Max *float64 `yaml:"max,omitempty"`
+
+	// Window is the number of previous runs the median the aggregate is
+	// compared to is computed over.
+	Window int `yaml:"window,omitempty"`
+	// MinRuns is the minimum number of previous runs with the aggregate for
+	// the comparison to be done. Defaults to the window.
+	MinRuns int `yaml:"minRuns,omitempty"`
+	// MaxIncrease and MaxDecrease are how much the aggregate may deviate from
+	// the median of the previous runs, relative to it, e.g. 0.1 for 10%.
+	// Zero means unbounded.
+	MaxIncrease float64 `yaml:"maxIncrease,omitempty"`
+	MaxDecrease float64 `yaml:"maxDecrease,omitempty"`
+}
+
+// BackendConfig configures the backend storing the benchmark samples and
+// analyzing the runs for regressions.
+type BackendConfig struct {
+	// Type is the type of the backend, `mako` or `local`. Defaults to `mako`.
+	Type string `yaml:"type,omitempty"`
+	// Path is the directory the local backend stores the runs in.
+	Path string `yaml:"path,omitempty"`
+	// BenchmarkChecks are the regression checks of the benchmarks done by
+	// the local backend.
+	BenchmarkChecks map[string][]RegressionCheck `yaml:"benchmarkChecks,omitempty"`
+	// Retention is the retention policy of the runs stored by the local
+	// backend, if they're pruned.
+	Retention *Retention `yaml:"retention,omitempty"`
+}
+
+// RetentionLimit limits what is retained by age and by count. The zero
+// fields don't limit anything.
+type RetentionLimit struct {
+	// MaxAge is the age of the oldest runs retained, e.g. `720h`.
+	MaxAge time.Duration `yaml:"maxAge,omitempty"`
+	// MaxCount is the number of most recent runs retained.
+	MaxCount int `yaml:"maxCount,omitempty"`
+}
+
+// Retention defines which runs of the benchmarks, and which of their sample
+// batches, the local backend retains once a run is stored.
+type Retention struct {
+	// Runs limits the runs retained, the others are deleted entirely.
+	Runs RetentionLimit `yaml:"runs,omitempty"`
+	// SampleBatches limits the runs whose samples are retained, the others
+	// only retain their aggregates.
+	SampleBatches RetentionLimit `yaml:"sampleBatches,omitempty"`
+	// KeepTags are the tags of the runs which are always retained entirely.
+	KeepTags []string `yaml:"keepTags,omitempty"`
+}
+
+// Validate checks the retention policy is well-formed.
+func (r *Retention) Validate() error {
+	for name, l := range map[string]RetentionLimit{"runs": r.Runs, "sample batches": r.SampleBatches} {
+		if l.MaxAge < 0 || l.MaxCount < 0 {
+			return fmt.Errorf("retention of the %s cannot be negative", name)
+		}
+	}
+	return nil
+}
+
+// Validate checks the regression check is well-formed.
+func (c *RegressionCheck) Validate() error {
+	switch {
+	case c.Name == "":
+		return fmt.Errorf("regression check name cannot be empty")
+	case c.Metric == "" && c.Aggregate != AggregateErrors:
+		return fmt.Errorf("regression check %q must have a metric", c.Name)
+	case c.Min != nil && c.Max != nil && *c.Min > *c.Max:
+		return fmt.Errorf("regression check %q min %v is above its max %v", c.Name, *c.Min, *c.Max)
+	case c.Window < 0:
+		return fmt.Errorf("regression check %q window cannot be negative, got %d", c.Name, c.Window)
+	case c.MinRuns < 0 || c.MinRuns > c.Window:
+		return fmt.Errorf("regression check %q min runs must be in [0, %d], got %d", c.Name, c.Window, c.MinRuns)
+	case c.MaxIncrease < 0 || c.MaxDecrease < 0:
+		return fmt.Errorf("regression check %q max increase and decrease cannot be negative", c.Name)
+	case c.Window == 0 && (c.MaxIncrease > 0 || c.MaxDecrease > 0):
+		return fmt.Errorf("regression check %q must have a window to bound the increase or decrease", c.Name)
+	case c.Window > 0 && c.MaxIncrease == 0 && c.MaxDecrease == 0:
+		return fmt.Errorf("regression check %q must bound the increase or decrease over its window", c.Name)
+	case c.Window == 0 && c.Min == nil && c.Max == nil:
+		return fmt.Errorf("regression check %q must have bounds or a window", c.Name)
+	}
+	if _, err := ParsePercentile(c.Aggregate); err != nil {
+		return fmt.Errorf("regression check %q: %v", c.Name, err)
+	}
+	return nil
+}
+
+// setDefaults sets the defaults of the optional fields.
+func (c *RegressionCheck) setDefaults() {
+	if c.Aggregate == "" {
+		c.Aggregate = AggregateMedian
+	}
+	if c.MinRuns == 0 {
+		c.MinRuns = c.Window
+	}
+}
+
+// ParsePercentile returns the percentile of the given aggregate, e.g. 95 for
+// `p95`, or 0 if it's another known aggregate.
+func ParsePercentile(aggregate string) (float64, error) {
+	switch aggregate {
+	case "", AggregateMean, AggregateMedian, AggregateMin, AggregateMax,
+		AggregateCount, AggregateStdDev, AggregateRun, AggregateErrors:
+		return 0, nil
+	}
+	if !strings.HasPrefix(aggregate, "p") {
+		return 0, fmt.Errorf("unknown aggregate %q", aggregate)
+	}
+	p, err := strconv.ParseFloat(strings.TrimPrefix(aggregate, "p"), 64)
+	if err != nil || p <= 0 || p >= 100 {
+		return 0, fmt.Errorf("invalid percentile aggregate %q, want p followed by a number in (0, 100)", aggregate)
+	}
+	return p, nil
+}
+
+// GetBackendConfig returns the configuration of the backend. If the config
+// is not found, the Mako backend is used.
+func GetBackendConfig() (*BackendConfig, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return parseBackendConfig("")
+	}
+	return parseBackendConfig(cfg.BackendConfig)
+}
+
+// parseBackendConfig parses and validates the given backend configuration,
+// and sets its defaults.
+func parseBackendConfig(configStr string) (*BackendConfig, error) {
+	backendConfig := &BackendConfig{}
+	if err := yaml.Unmarshal([]byte(configStr), backendConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse the backend config: %v", err)
+	}
+	switch backendConfig.Type {
+	case "":
+		backendConfig.Type = MakoBackend
+	case MakoBackend, LocalBackend:
+	default:
+		return nil, fmt.Errorf("unknown backend type %q, want %q or %q", backendConfig.Type, MakoBackend, LocalBackend)
+	}
+	if backendConfig.Path == "" {
+		backendConfig.Path = defaultLocalPath
+	}
+	if backendConfig.Retention != nil {
+		if err := backendConfig.Retention.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	for name, checks := range backendConfig.BenchmarkChecks {
+		for i := range checks {
+			if err := checks[i].Validate(); err != nil {
+				return nil, fmt.Errorf("invalid regression check for benchmark %q: %v", name, err)
+			}
+			checks[i].setDefaults()
+		}
+	}
+	return backendConfig, nil
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBackendConfig(t *testing.T) {
+	backendConfig, err := parseBackendConfig(`
+type: local
+path: /tmp/mako
+benchmarkChecks:
+  benchmark1:
+  - name: p95-latency
+    metric: l
+    aggregate: p95
+    max: 0.1
+    window: 10
+    maxIncrease: 0.1
+  - name: errors
+    aggregate: errors
+    max: 0
+retention:
+  runs:
+    maxAge: 2160h
+  sampleBatches:
+    maxCount: 10
+  keepTags: [reference]`)
+	if err != nil {
+		t.Fatalf("parseBackendConfig() = %v", err)
+	}
+
+	want := &BackendConfig{
+		Type: LocalBackend,
+		Path: "/tmp/mako",
+		BenchmarkChecks: map[string][]RegressionCheck{
+			"benchmark1": {{
+				Name:        "p95-latency",
+				Metric:      "l",
+				Aggregate:   "p95",
+				Max:         proto.Float64(0.1),
+				Window:      10,
+				MinRuns:     10,
+				MaxIncrease: 0.1,
+			}, {
+				Name:      "errors",
+				Aggregate: AggregateErrors,
+				Max:       proto.Float64(0),
+			}},
+		},
+		Retention: &Retention{
+			Runs:          RetentionLimit{MaxAge: 90 * 24 * time.Hour},
+			SampleBatches: RetentionLimit{MaxCount: 10},
+			KeepTags:      []string{"reference"},
+		},
+	}
+	if diff := cmp.Diff(want, backendConfig); diff != "" {
+		t.Errorf("backend config (-want, +got): %s", diff)
+	}
+}
+
+func TestDefaultBackendConfig(t *testing.T) {
+	backendConfig, err := parseBackendConfig("")
+	if err != nil {
+		t.Fatalf("parseBackendConfig() = %v", err)
+	}
+	want := &BackendConfig{Type: MakoBackend, Path: defaultLocalPath}
+	if diff := cmp.Diff(want, backendConfig); diff != "" {
+		t.Errorf("backend config (-want, +got): %s", diff)
+	}
+}
+
+func TestInvalidBackendConfig(t *testing.T) {
+	if _, err := parseBackendConfig("type: prometheus"); err == nil {
+		t.Error("parseBackendConfig() = nil, wanted an error for the unknown type")
+	}
+	if _, err := parseBackendConfig("retention: {runs: {maxCount: -1}}"); err == nil {
+		t.Error("parseBackendConfig() = nil, wanted an error for the negative retention")
+	}
+
+	valid := RegressionCheck{Name: "check", Metric: "l", Window: 10, MaxIncrease: 0.1}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	tests := map[string]func(*RegressionCheck){
+		"no name":                 func(c *RegressionCheck) { c.Name = "" },
+		"no metric":               func(c *RegressionCheck) { c.Metric = "" },
+		"unknown aggregate":       func(c *RegressionCheck) { c.Aggregate = "average" },
+		"percentile too high":     func(c *RegressionCheck) { c.Aggregate = "p100" },
+		"min above max":           func(c *RegressionCheck) { c.Min, c.Max = proto.Float64(2), proto.Float64(1) },
+		"negative window":         func(c *RegressionCheck) { c.Window = -1 },
+		"min runs above window":   func(c *RegressionCheck) { c.MinRuns = 11 },
+		"negative max increase":   func(c *RegressionCheck) { c.MaxIncrease = -0.1 },
+		"window without bounds":   func(c *RegressionCheck) { c.MaxIncrease = 0 },
+		"max increase, no window": func(c *RegressionCheck) { c.Window = 0 },
+		"nothing checked": func(c *RegressionCheck) {
+			c.Window, c.MaxIncrease = 0, 0
+		},
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			check := valid
+			mutate(&check)
+			if err := check.Validate(); err == nil {
+				t.Errorf("Validate() = nil, wanted an error")
+			}
+		})
+	}
+}
+
+func TestParsePercentile(t *testing.T) {
+	for aggregate, want := range map[string]float64{
+		"":      0,
+		"mean":  0,
+		"p95":   95,
+		"p99.9": 99.9,
+	} {
+		if got, err := ParsePercentile(aggregate); err != nil || got != want {
+			t.Errorf("ParsePercentile(%q) = %v, %v, want %v", aggregate, got, err, want)
+		}
+	}
+}
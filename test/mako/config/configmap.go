@@ -47,6 +47,18 @@ type Config struct {
 	// SlackConfig holds the slack configurations for the benchmarks,
 	// it's used to determine which slack channels to alert on if there is performance regression.
 	SlackConfig string
+
+	// GithubConfig holds the Github configurations for the benchmarks,
+	// it's used to determine whom to mention in the issues of performance regressions.
+	GithubConfig string
+
+	// SLOConfig holds the SLOs of the benchmarks, which are alerted on
+	// when they are violated over their recent runs.
+	SLOConfig string
+
+	// BackendConfig holds the configuration of the backend storing the
+	// benchmark samples and detecting the regressions, Mako by default.
+	BackendConfig string
 }
 
 // NewConfigFromMap creates a Config from the supplied map
@@ -69,8 +81,26 @@ func NewConfigFromMap(data map[string]string) (*Config, error) {
 		lc.AdditionalTags = strings.Split(raw, ",")
 	}
 	if raw, ok := data["slackConfig"]; ok {
+		if _, err := parseSlackConfig(raw); err != nil {
+			return nil, err
+		}
 		lc.SlackConfig = raw
 	}
+	if raw, ok := data["githubConfig"]; ok {
+		lc.GithubConfig = raw
+	}
+	if raw, ok := data["sloConfig"]; ok {
+		if _, err := parseSLOConfig(raw); err != nil {
+			return nil, err
+		}
+		lc.SLOConfig = raw
+	}
+	if raw, ok := data["backendConfig"]; ok {
+		if _, err := parseBackendConfig(raw); err != nil {
+			return nil, err
+		}
+		lc.BackendConfig = raw
+	}
 
 	return lc, nil
 }
@@ -0,0 +1,367 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// GithubConfig contains the Github configuration for the benchmarks.
+type GithubConfig struct {
+	// Mentions maps severities, e.g. `p1`, to the Github users or teams
+	// to mention in the issues of performance regressions of that severity.
+	Mentions map[string][]string `yaml:"mentions,omitempty"`
+
+	// BenchmarkSeverities maps benchmark names to the severity of their
+	// performance regressions.
+	BenchmarkSeverities map[string]string `yaml:"benchmarkSeverities,omitempty"`
+
+	// DefaultSeverity is the severity of the performance regressions of
+	// benchmarks not listed in BenchmarkSeverities.
+	DefaultSeverity string `yaml:"defaultSeverity,omitempty"`
+
+	// Routes route the issues of the tests matching their patterns to other
+	// repositories than the one running the benchmarks. The first matching
+	// route applies.
+	Routes []GithubRoute `yaml:"routes,omitempty"`
+
+	// RecoveryRuns is the number of consecutive runs without regression
+	// after which the issue of a benchmark is closed as recovered. If zero,
+	// the alerter's default is used.
+	RecoveryRuns int `yaml:"recoveryRuns,omitempty"`
+
+	// Labels are added to the issues, in addition to `auto:perf`.
+	Labels []string `yaml:"labels,omitempty"`
+
+	// Assignees are the Github users assigned to the issues.
+	Assignees []string `yaml:"assignees,omitempty"`
+
+	// Templates customize the issues and their comments.
+	Templates GithubTemplates `yaml:"templates,omitempty"`
+
+	// CacheTTL is how long the issues listed in a repository are reused,
+	// e.g. `10m`.
+	CacheTTL time.Duration `yaml:"cacheTTL,omitempty"`
+
+	// Digest batches the regressions filed in the default repository in a
+	// single issue per run or per day, see DigestPerRun and DigestDaily,
+	// instead of an issue per benchmark.
+	Digest string `yaml:"digest,omitempty"`
+
+	// Batch reports all the regressions of a run at once, grouped by test
+	// suite, see BatchIssue and BatchComment, instead of alerting on every
+	// test separately.
+	Batch string `yaml:"batch,omitempty"`
+
+	// BaseURL is the URL of the API of a Github Enterprise server, e.g.
+	// `https://github.example.com/api/v3/`. Defaults to github.com.
+	BaseURL string `yaml:"baseURL,omitempty"`
+
+	// App authenticates as an installation of a Github App instead of with
+	// the personal access token.
+	App *GithubApp `yaml:"app,omitempty"`
+
+	// Overflow is where the full text of the issue bodies and comments too
+	// long for Github is stored, which they then link to, e.g.
+	// `gs://bucket/perf-reports`, see gcs.NewArtifactStore.
+	Overflow string `yaml:"overflow,omitempty"`
+}
+
+// GithubApp is an installation of a Github App to authenticate as.
+type GithubApp struct {
+	// AppID is the ID of the Github App.
+	AppID int64 `yaml:"appID"`
+	// InstallationID is the ID of the installation of the App.
+	InstallationID int64 `yaml:"installationID"`
+	// PrivateKey is the name of the secret file holding the private key of
+	// the App, mounted like the tokens. Defaults to `github-app-key`.
+	PrivateKey string `yaml:"privateKey,omitempty"`
+}
+
+// DefaultGithubAppPrivateKey is the default name of the secret file holding
+// the private key of the Github App.
+const DefaultGithubAppPrivateKey = "github-app-key"
+
+// Validate checks the App and its installation are identified.
+func (a GithubApp) Validate() error {
+	if a.AppID <= 0 {
+		return fmt.Errorf("invalid Github App ID %d", a.AppID)
+	}
+	if a.InstallationID <= 0 {
+		return fmt.Errorf("invalid installation ID %d of Github App %d", a.InstallationID, a.AppID)
+	}
+	return nil
+}
+
+const (
+	// DigestPerRun batches the regressions of a run in a single issue.
+	DigestPerRun = "run"
+	// DigestDaily batches the regressions of a day in a single issue.
+	DigestDaily = "day"
+)
+
+// ValidateDigest checks the digest mode is either empty or a known one.
+func ValidateDigest(digest string) error {
+	switch digest {
+	case "", DigestPerRun, DigestDaily:
+		return nil
+	default:
+		return fmt.Errorf("invalid digest %q, must be %q or %q", digest, DigestPerRun, DigestDaily)
+	}
+}
+
+const (
+	// BatchIssue reports the regressions of a run in a new issue.
+	BatchIssue = "issue"
+	// BatchComment reports the regressions of a run in a single comment of
+	// the digest issue of the run or the day.
+	BatchComment = "comment"
+)
+
+// ValidateBatch checks the batch mode is either empty or a known one.
+func ValidateBatch(batch string) error {
+	switch batch {
+	case "", BatchIssue, BatchComment:
+		return nil
+	default:
+		return fmt.Errorf("invalid batch %q, must be %q or %q", batch, BatchIssue, BatchComment)
+	}
+}
+
+// GithubTemplates are the text/template templates of the issues and their
+// comments. The empty ones default to the built-in templates.
+type GithubTemplates struct {
+	Title     string `yaml:"title,omitempty"`
+	Body      string `yaml:"body,omitempty"`
+	Summary   string `yaml:"summary,omitempty"`
+	Reopen    string `yaml:"reopen,omitempty"`
+	Close     string `yaml:"close,omitempty"`
+	Recovered string `yaml:"recovered,omitempty"`
+
+	DigestTitle string `yaml:"digestTitle,omitempty"`
+	DigestBody  string `yaml:"digestBody,omitempty"`
+	DigestEntry string `yaml:"digestEntry,omitempty"`
+
+	DigestReport string `yaml:"digestReport,omitempty"`
+}
+
+// GithubRoute routes the issues of tests to a Github repository.
+type GithubRoute struct {
+	// Pattern is the pattern of the test names, as defined by path.Match,
+	// e.g. `serving-*`.
+	Pattern string `yaml:"pattern"`
+	// Org and Repo identify the repository to file the issues in.
+	Org  string `yaml:"org"`
+	Repo string `yaml:"repo"`
+	// Digest batches the regressions filed in the repository, like
+	// GithubConfig.Digest does for the default one.
+	Digest string `yaml:"digest,omitempty"`
+}
+
+// Validate checks the route is complete and its pattern well-formed.
+func (r GithubRoute) Validate() error {
+	if r.Pattern == "" {
+		return errors.New("route pattern cannot be empty")
+	}
+	if _, err := path.Match(r.Pattern, ""); err != nil {
+		return fmt.Errorf("invalid route pattern %q: %v", r.Pattern, err)
+	}
+	if r.Org == "" || r.Repo == "" {
+		return fmt.Errorf("route %q must have both an org and a repo", r.Pattern)
+	}
+	if err := ValidateDigest(r.Digest); err != nil {
+		return fmt.Errorf("route %q: %v", r.Pattern, err)
+	}
+	return nil
+}
+
+// Matches returns true if the test name matches the route's pattern.
+func (r GithubRoute) Matches(testName string) bool {
+	matched, _ := path.Match(r.Pattern, testName)
+	return matched
+}
+
+// GetGithubMentions returns the Github users or teams to mention in the
+// issues of performance regressions of the given benchmark.
+// If any error happens, or the config is not found, return no mentions.
+func GetGithubMentions(benchmarkName string) []string {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil
+	}
+	return getGithubMentions(cfg.GithubConfig, benchmarkName)
+}
+
+// GetGithubSeverity returns the severity of the performance regressions of the given benchmark.
+// If any error happens, or the config is not found, return an empty severity.
+func GetGithubSeverity(benchmarkName string) string {
+	cfg, err := loadConfig()
+	if err != nil {
+		return ""
+	}
+	return getGithubSeverity(cfg.GithubConfig, benchmarkName)
+}
+
+// GetGithubRoutes returns the routes of the issues to other repositories.
+// If any error happens, or the config is not found, return no routes.
+func GetGithubRoutes() []GithubRoute {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil
+	}
+	return parseGithubConfig(cfg.GithubConfig).Routes
+}
+
+// GetGithubRecoveryRuns returns the number of consecutive runs without regression
+// after which issues are closed as recovered.
+// If any error happens, or the config is not found, return zero.
+func GetGithubRecoveryRuns() int {
+	cfg, err := loadConfig()
+	if err != nil {
+		return 0
+	}
+	return parseGithubConfig(cfg.GithubConfig).RecoveryRuns
+}
+
+// GetGithubLabels returns the labels to add to the issues.
+// If any error happens, or the config is not found, return no labels.
+func GetGithubLabels() []string {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil
+	}
+	return parseGithubConfig(cfg.GithubConfig).Labels
+}
+
+// GetGithubAssignees returns the Github users to assign the issues to.
+// If any error happens, or the config is not found, return no assignees.
+func GetGithubAssignees() []string {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil
+	}
+	return parseGithubConfig(cfg.GithubConfig).Assignees
+}
+
+// GetGithubTemplates returns the templates of the issues and their comments.
+// If any error happens, or the config is not found, return empty templates.
+func GetGithubTemplates() GithubTemplates {
+	cfg, err := loadConfig()
+	if err != nil {
+		return GithubTemplates{}
+	}
+	return parseGithubConfig(cfg.GithubConfig).Templates
+}
+
+// GetGithubCacheTTL returns how long the issues listed in a repository are reused.
+// If any error happens, or the config is not found, return 0 for the default.
+func GetGithubCacheTTL() time.Duration {
+	cfg, err := loadConfig()
+	if err != nil {
+		return 0
+	}
+	return parseGithubConfig(cfg.GithubConfig).CacheTTL
+}
+
+// GetGithubDigest returns the digest mode of the default repository.
+// If any error happens, or the config is not found, return no digest mode.
+func GetGithubDigest() string {
+	cfg, err := loadConfig()
+	if err != nil {
+		return ""
+	}
+	return parseGithubConfig(cfg.GithubConfig).Digest
+}
+
+// GetGithubBatch returns the batch mode of the regressions.
+// If any error happens, or the config is not found, return no batch mode.
+func GetGithubBatch() string {
+	cfg, err := loadConfig()
+	if err != nil {
+		return ""
+	}
+	return parseGithubConfig(cfg.GithubConfig).Batch
+}
+
+// GetGithubBaseURL returns the URL of the Github API.
+// If any error happens, or the config is not found, return an empty URL for github.com.
+func GetGithubBaseURL() string {
+	cfg, err := loadConfig()
+	if err != nil {
+		return ""
+	}
+	return parseGithubConfig(cfg.GithubConfig).BaseURL
+}
+
+// GetGithubOverflow returns where the full text of the issue bodies and comments
+// too long for Github is stored.
+// If any error happens, or the config is not found, return an empty location to only truncate them.
+func GetGithubOverflow() string {
+	cfg, err := loadConfig()
+	if err != nil {
+		return ""
+	}
+	return parseGithubConfig(cfg.GithubConfig).Overflow
+}
+
+// GetGithubApp returns the Github App to authenticate as, with the default
+// name of its private key if unset.
+// If any error happens, or the config is not found, return nil to authenticate with the token.
+func GetGithubApp() *GithubApp {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil
+	}
+	return getGithubApp(cfg.GithubConfig)
+}
+
+func getGithubApp(configStr string) *GithubApp {
+	app := parseGithubConfig(configStr).App
+	if app != nil && app.PrivateKey == "" {
+		app.PrivateKey = DefaultGithubAppPrivateKey
+	}
+	return app
+}
+
+func parseGithubConfig(configStr string) *GithubConfig {
+	githubConfig := &GithubConfig{}
+	if err := yaml.Unmarshal([]byte(configStr), githubConfig); err != nil {
+		return &GithubConfig{}
+	}
+	return githubConfig
+}
+
+func (gc *GithubConfig) severity(benchmarkName string) string {
+	if severity, ok := gc.BenchmarkSeverities[benchmarkName]; ok {
+		return severity
+	}
+	return gc.DefaultSeverity
+}
+
+func getGithubSeverity(configStr, benchmarkName string) string {
+	return parseGithubConfig(configStr).severity(benchmarkName)
+}
+
+func getGithubMentions(configStr, benchmarkName string) []string {
+	githubConfig := parseGithubConfig(configStr)
+	return githubConfig.Mentions[githubConfig.severity(benchmarkName)]
+}
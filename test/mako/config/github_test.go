@@ -0,0 +1,204 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGithubMentions(t *testing.T) {
+	configStr := `
+mentions:
+  p1:
+  - "@knative/serving-wg-leads"
+  - "@someone"
+  p2:
+  - "@knative/serving-writers"
+benchmarkSeverities:
+  benchmark1: p1
+  benchmark3: p3
+defaultSeverity: p2`
+
+	testCases := []struct {
+		benchmarkName string
+		configStr     string
+		want          []string
+	}{
+		{"benchmark1", configStr, []string{"@knative/serving-wg-leads", "@someone"}},
+		{"benchmark2", configStr, []string{"@knative/serving-writers"}},
+		{"benchmark3", configStr, nil},
+		{"benchmark1", "", nil},
+		{"benchmark1", "mentions: [", nil},
+	}
+	for _, v := range testCases {
+		if got := getGithubMentions(v.configStr, v.benchmarkName); !cmp.Equal(v.want, got) {
+			t.Errorf("getGithubMentions(%q) = %v, want %v", v.benchmarkName, got, v.want)
+		}
+	}
+}
+
+func TestGithubSeverity(t *testing.T) {
+	configStr := `
+benchmarkSeverities:
+  benchmark1: p1
+defaultSeverity: p2`
+
+	testCases := []struct {
+		benchmarkName string
+		configStr     string
+		want          string
+	}{
+		{"benchmark1", configStr, "p1"},
+		{"benchmark2", configStr, "p2"},
+		{"benchmark1", "", ""},
+	}
+	for _, v := range testCases {
+		if got := getGithubSeverity(v.configStr, v.benchmarkName); got != v.want {
+			t.Errorf("getGithubSeverity(%q) = %q, want %q", v.benchmarkName, got, v.want)
+		}
+	}
+}
+
+func TestGithubRoutes(t *testing.T) {
+	routes := parseGithubConfig(`
+routes:
+- pattern: "serving-*"
+  org: knative
+  repo: serving
+- pattern: "*"
+  org: knative
+  repo: catch-all`).Routes
+	if len(routes) != 2 {
+		t.Fatalf("expected 2 routes, but got %v", routes)
+	}
+	for _, r := range routes {
+		if err := r.Validate(); err != nil {
+			t.Errorf("Validate(%v) = %v", r, err)
+		}
+	}
+	if !routes[0].Matches("serving-dataplane-probe") {
+		t.Error("expected the serving route to match serving-dataplane-probe")
+	}
+	if routes[0].Matches("eventing-broker") {
+		t.Error("expected the serving route not to match eventing-broker")
+	}
+
+	for _, r := range []GithubRoute{
+		{Org: "knative", Repo: "serving"},
+		{Pattern: "[", Org: "knative", Repo: "serving"},
+		{Pattern: "serving-*", Repo: "serving"},
+		{Pattern: "serving-*", Org: "knative"},
+	} {
+		if err := r.Validate(); err == nil {
+			t.Errorf("Validate(%v) = nil, wanted an error", r)
+		}
+	}
+}
+
+func TestGithubRecoveryRuns(t *testing.T) {
+	if got := parseGithubConfig("recoveryRuns: 5").RecoveryRuns; got != 5 {
+		t.Errorf("RecoveryRuns = %d, want 5", got)
+	}
+	if got := parseGithubConfig("").RecoveryRuns; got != 0 {
+		t.Errorf("RecoveryRuns = %d, want 0 when not configured", got)
+	}
+}
+
+func TestGithubIssueCustomization(t *testing.T) {
+	cfg := parseGithubConfig(`
+labels:
+- kind/performance
+assignees:
+- foo
+templates:
+  title: "perf: {{.TestName}}"
+`)
+	if diff := cmp.Diff([]string{"kind/performance"}, cfg.Labels); diff != "" {
+		t.Errorf("Labels (-want, +got) = %s", diff)
+	}
+	if diff := cmp.Diff([]string{"foo"}, cfg.Assignees); diff != "" {
+		t.Errorf("Assignees (-want, +got) = %s", diff)
+	}
+	if diff := cmp.Diff(GithubTemplates{Title: "perf: {{.TestName}}"}, cfg.Templates); diff != "" {
+		t.Errorf("Templates (-want, +got) = %s", diff)
+	}
+}
+
+func TestGithubCacheTTL(t *testing.T) {
+	if got, want := parseGithubConfig("cacheTTL: 5m").CacheTTL, 5*time.Minute; got != want {
+		t.Errorf("CacheTTL = %v, want %v", got, want)
+	}
+}
+
+func TestValidateDigest(t *testing.T) {
+	for _, digest := range []string{"", DigestPerRun, DigestDaily} {
+		if err := ValidateDigest(digest); err != nil {
+			t.Errorf("ValidateDigest(%q) = %v", digest, err)
+		}
+	}
+	if err := ValidateDigest("weekly"); err == nil {
+		t.Error("ValidateDigest(weekly) = nil, wanted an error")
+	}
+	route := GithubRoute{Pattern: "*", Org: "knative", Repo: "serving", Digest: "weekly"}
+	if err := route.Validate(); err == nil {
+		t.Error("Validate() = nil, wanted an error for the invalid digest")
+	}
+}
+
+func TestValidateBatch(t *testing.T) {
+	for _, batch := range []string{"", BatchIssue, BatchComment} {
+		if err := ValidateBatch(batch); err != nil {
+			t.Errorf("ValidateBatch(%q) = %v", batch, err)
+		}
+	}
+	if err := ValidateBatch("email"); err == nil {
+		t.Error("ValidateBatch(email) = nil, wanted an error")
+	}
+	if got, want := parseGithubConfig("batch: comment").Batch, BatchComment; got != want {
+		t.Errorf("Batch = %q, want %q", got, want)
+	}
+}
+
+func TestGithubApp(t *testing.T) {
+	if app := getGithubApp("batch: issue"); app != nil {
+		t.Errorf("getGithubApp() = %v, want nil", app)
+	}
+	app := getGithubApp(`
+baseURL: https://github.example.com/api/v3/
+app:
+  appID: 7
+  installationID: 42`)
+	want := &GithubApp{AppID: 7, InstallationID: 42, PrivateKey: DefaultGithubAppPrivateKey}
+	if diff := cmp.Diff(want, app); diff != "" {
+		t.Errorf("getGithubApp() (-want, +got) = %s", diff)
+	}
+	if err := app.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	if err := (GithubApp{AppID: 7}).Validate(); err == nil {
+		t.Error("Validate() = nil, wanted an error for the missing installation ID")
+	}
+	if got, want := parseGithubConfig("baseURL: https://github.example.com/api/v3/").BaseURL, "https://github.example.com/api/v3/"; got != want {
+		t.Errorf("BaseURL = %q, want %q", got, want)
+	}
+	if got, want := parseGithubConfig("overflow: gs://bucket/reports").Overflow, "gs://bucket/reports"; got != want {
+		t.Errorf("Overflow = %q, want %q", got, want)
+	}
+}
@@ -17,6 +17,9 @@ limitations under the License.
 package config
 
 import (
+	"fmt"
+	"path"
+
 	yaml "gopkg.in/yaml.v2"
 )
 
@@ -30,7 +33,86 @@ type Channel struct {
 
 // SlackConfig contains slack configuration for the benchmarks.
 type SlackConfig struct {
+	// BenchmarkChannels maps benchmark names to the channels to alert on.
 	BenchmarkChannels map[string][]Channel `yaml:"benchmarkChannels,omitempty"`
+
+	// ChannelRoutes route the alerts of the benchmarks matching their
+	// patterns, if they're not listed in BenchmarkChannels. The first
+	// matching route applies.
+	ChannelRoutes []ChannelRoute `yaml:"channelRoutes,omitempty"`
+
+	// DefaultChannels are the channels to alert on for the benchmarks that
+	// are neither listed nor routed. If empty, the performance channel is used.
+	DefaultChannels []Channel `yaml:"defaultChannels,omitempty"`
+}
+
+// ChannelRoute routes the alerts of benchmarks to Slack channels.
+type ChannelRoute struct {
+	// Pattern is the pattern of the benchmark names, as defined by
+	// path.Match, e.g. `networking-*`.
+	Pattern  string    `yaml:"pattern"`
+	Channels []Channel `yaml:"channels"`
+}
+
+// Validate checks all channels are complete and all patterns well-formed.
+func (sc *SlackConfig) Validate() error {
+	for name, channels := range sc.BenchmarkChannels {
+		if err := validateChannels(channels); err != nil {
+			return fmt.Errorf("invalid channels for benchmark %q: %v", name, err)
+		}
+	}
+	for _, route := range sc.ChannelRoutes {
+		if _, err := path.Match(route.Pattern, ""); err != nil || route.Pattern == "" {
+			return fmt.Errorf("invalid channel route pattern %q", route.Pattern)
+		}
+		if len(route.Channels) == 0 {
+			return fmt.Errorf("channel route %q has no channels", route.Pattern)
+		}
+		if err := validateChannels(route.Channels); err != nil {
+			return fmt.Errorf("invalid channels for route %q: %v", route.Pattern, err)
+		}
+	}
+	if err := validateChannels(sc.DefaultChannels); err != nil {
+		return fmt.Errorf("invalid default channels: %v", err)
+	}
+	return nil
+}
+
+func validateChannels(channels []Channel) error {
+	for _, channel := range channels {
+		if channel.Name == "" || channel.Identity == "" {
+			return fmt.Errorf("channel %v must have both a name and an identity", channel)
+		}
+	}
+	return nil
+}
+
+// channels returns the channels to alert on for the given benchmark.
+func (sc *SlackConfig) channels(benchmarkName string) []Channel {
+	if channels, ok := sc.BenchmarkChannels[benchmarkName]; ok {
+		return channels
+	}
+	for _, route := range sc.ChannelRoutes {
+		if matched, _ := path.Match(route.Pattern, benchmarkName); matched {
+			return route.Channels
+		}
+	}
+	if len(sc.DefaultChannels) != 0 {
+		return sc.DefaultChannels
+	}
+	return []Channel{defaultChannel}
+}
+
+// parseSlackConfig parses and validates the given slack configuration.
+func parseSlackConfig(configStr string) (*SlackConfig, error) {
+	slackConfig := &SlackConfig{}
+	if err := yaml.Unmarshal([]byte(configStr), slackConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse the slack config: %v", err)
+	}
+	if err := slackConfig.Validate(); err != nil {
+		return nil, err
+	}
+	return slackConfig, nil
 }
 
 // GetSlackChannels returns the slack channels to alert on for the given benchmark.
@@ -44,13 +126,9 @@ func GetSlackChannels(benchmarkName string) []Channel {
 }
 
 func getSlackChannels(configStr, benchmarkName string) []Channel {
-	slackConfig := &SlackConfig{}
-	if err := yaml.Unmarshal([]byte(configStr), slackConfig); err != nil {
+	slackConfig, err := parseSlackConfig(configStr)
+	if err != nil {
 		return []Channel{defaultChannel}
 	}
-	if channels, ok := slackConfig.BenchmarkChannels[benchmarkName]; ok {
-		return channels
-	}
-
-	return []Channel{defaultChannel}
+	return slackConfig.channels(benchmarkName)
 }
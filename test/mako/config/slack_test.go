@@ -77,3 +77,63 @@ func TestEmptySlackChannelsConfig(t *testing.T) {
 		t.Fatalf("expected to get the default channel but actual is %q", channels[0])
 	}
 }
+
+func TestRoutedSlackChannelsConfig(t *testing.T) {
+	configStr := `
+benchmarkChannels:
+  networking-listed:
+  - name: listed
+    identity: listed
+channelRoutes:
+- pattern: "networking-*"
+  channels:
+  - name: networking
+    identity: networking
+defaultChannels:
+- name: fallback
+  identity: fallback`
+
+	testCases := []struct {
+		benchmarkName string
+		want          string
+	}{
+		{"networking-listed", "listed"},
+		{"networking-ingress", "networking"},
+		{"serving-activator", "fallback"},
+	}
+	for _, v := range testCases {
+		channels := getSlackChannels(configStr, v.benchmarkName)
+		if len(channels) != 1 || channels[0].Name != v.want {
+			t.Errorf("expected to get channel %q for benchmark %q but actual is %v", v.want, v.benchmarkName, channels)
+		}
+	}
+}
+
+func TestInvalidSlackConfig(t *testing.T) {
+	for _, configStr := range []string{
+		"benchmarkChannels: [",
+		`
+benchmarkChannels:
+  benchmark1:
+  - name: channel`,
+		`
+channelRoutes:
+- pattern: "["
+  channels:
+  - name: channel
+    identity: identity`,
+		`
+channelRoutes:
+- pattern: "networking-*"`,
+		`
+defaultChannels:
+- identity: identity`,
+	} {
+		if _, err := NewConfigFromMap(map[string]string{"slackConfig": configStr}); err == nil {
+			t.Errorf("expected an error for slack config %q", configStr)
+		}
+		if channels := getSlackChannels(configStr, "benchmark1"); len(channels) != 1 || channels[0] != defaultChannel {
+			t.Errorf("expected to fall back to the default channel for slack config %q but actual is %v", configStr, channels)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// defaultSLOMaxBurnRate is the burn rate above which SLOs are violated by default,
+	// i.e. when the error budget is spent faster than it's allowed to.
+	defaultSLOMaxBurnRate = 1.0
+)
+
+// SLO is a service level objective of a benchmark, e.g. the p95 latency is at most
+// 100ms in 90% of the last 20 runs.
+type SLO struct {
+	// Name identifies the SLO among the ones of the benchmark.
+	Name string `yaml:"name"`
+	// Metric is the value key of the metric.
+	Metric string `yaml:"metric"`
+	// Percentile is the percentile of the metric in each run, e.g. 95.
+	Percentile float64 `yaml:"percentile"`
+	// Max is the maximum value of the percentile for a run to comply.
+	Max float64 `yaml:"max"`
+
+	// Window is the number of most recent runs the compliance is computed over.
+	Window int `yaml:"window"`
+	// Objective is the fraction of the runs in the window that must comply,
+	// e.g. 0.9. The rest is the error budget. Defaults to 1, i.e. no budget.
+	Objective float64 `yaml:"objective,omitempty"`
+
+	// MaxBurnRate is the rate the error budget may be spent at, relative to the
+	// objective, before the SLO is violated. Defaults to 1.
+	MaxBurnRate float64 `yaml:"maxBurnRate,omitempty"`
+	// ShortWindow is the number of most recent runs the burn rate must exceed
+	// MaxBurnRate over too, so an SLO is only violated while the regression is
+	// ongoing. Defaults to a quarter of the window.
+	ShortWindow int `yaml:"shortWindow,omitempty"`
+}
+
+// SLOConfig contains the SLOs of the benchmarks.
+type SLOConfig struct {
+	BenchmarkSLOs map[string][]SLO `yaml:"benchmarkSLOs,omitempty"`
+}
+
+// Validate checks the SLO is well-formed.
+func (s *SLO) Validate() error {
+	switch {
+	case s.Name == "":
+		return fmt.Errorf("SLO name cannot be empty")
+	case s.Metric == "":
+		return fmt.Errorf("SLO %q must have a metric", s.Name)
+	case s.Percentile <= 0 || s.Percentile > 100:
+		return fmt.Errorf("SLO %q percentile must be in (0, 100], got %v", s.Name, s.Percentile)
+	case s.Window <= 0:
+		return fmt.Errorf("SLO %q window must be positive, got %d", s.Name, s.Window)
+	case s.Objective < 0 || s.Objective > 1:
+		return fmt.Errorf("SLO %q objective must be in [0, 1], got %v", s.Name, s.Objective)
+	case s.MaxBurnRate < 0:
+		return fmt.Errorf("SLO %q max burn rate cannot be negative, got %v", s.Name, s.MaxBurnRate)
+	case s.ShortWindow < 0 || s.ShortWindow > s.Window:
+		return fmt.Errorf("SLO %q short window must be in [0, %d], got %d", s.Name, s.Window, s.ShortWindow)
+	}
+	return nil
+}
+
+// setDefaults sets the defaults of the optional fields.
+func (s *SLO) setDefaults() {
+	if s.Objective == 0 {
+		s.Objective = 1
+	}
+	if s.MaxBurnRate == 0 {
+		s.MaxBurnRate = defaultSLOMaxBurnRate
+	}
+	if s.ShortWindow == 0 {
+		s.ShortWindow = s.Window / 4
+		if s.ShortWindow == 0 {
+			s.ShortWindow = 1
+		}
+	}
+}
+
+// GetSLOs returns the SLOs of the given benchmark.
+// If the config is not found, return no SLOs.
+func GetSLOs(benchmarkName string) ([]SLO, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, nil
+	}
+	sloConfig, err := parseSLOConfig(cfg.SLOConfig)
+	if err != nil {
+		return nil, err
+	}
+	return sloConfig.BenchmarkSLOs[benchmarkName], nil
+}
+
+// parseSLOConfig parses and validates the given SLO configuration, and sets
+// the defaults of the SLOs.
+func parseSLOConfig(configStr string) (*SLOConfig, error) {
+	sloConfig := &SLOConfig{}
+	if err := yaml.Unmarshal([]byte(configStr), sloConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse the SLO config: %v", err)
+	}
+	for name, slos := range sloConfig.BenchmarkSLOs {
+		for i := range slos {
+			if err := slos[i].Validate(); err != nil {
+				return nil, fmt.Errorf("invalid SLO for benchmark %q: %v", name, err)
+			}
+			slos[i].setDefaults()
+		}
+	}
+	return sloConfig, nil
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSLOConfig(t *testing.T) {
+	sloConfig, err := parseSLOConfig(`
+benchmarkSLOs:
+  benchmark1:
+  - name: p95-latency
+    metric: l
+    percentile: 95
+    max: 0.1
+    window: 20
+    objective: 0.9
+  - name: p99-latency
+    metric: l
+    percentile: 99
+    max: 0.5
+    window: 2
+    maxBurnRate: 2
+    shortWindow: 2`)
+	if err != nil {
+		t.Fatalf("parseSLOConfig() = %v", err)
+	}
+
+	want := []SLO{{
+		Name:        "p95-latency",
+		Metric:      "l",
+		Percentile:  95,
+		Max:         0.1,
+		Window:      20,
+		Objective:   0.9,
+		MaxBurnRate: 1,
+		ShortWindow: 5,
+	}, {
+		Name:        "p99-latency",
+		Metric:      "l",
+		Percentile:  99,
+		Max:         0.5,
+		Window:      2,
+		Objective:   1,
+		MaxBurnRate: 2,
+		ShortWindow: 2,
+	}}
+	if diff := cmp.Diff(want, sloConfig.BenchmarkSLOs["benchmark1"]); diff != "" {
+		t.Errorf("SLOs (-want, +got): %s", diff)
+	}
+}
+
+func TestInvalidSLOConfig(t *testing.T) {
+	valid := SLO{Name: "slo", Metric: "l", Percentile: 95, Max: 1, Window: 10}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	tests := map[string]func(*SLO){
+		"no name":             func(s *SLO) { s.Name = "" },
+		"no metric":           func(s *SLO) { s.Metric = "" },
+		"no percentile":       func(s *SLO) { s.Percentile = 0 },
+		"percentile too high": func(s *SLO) { s.Percentile = 101 },
+		"no window":           func(s *SLO) { s.Window = 0 },
+		"objective too high":  func(s *SLO) { s.Objective = 1.5 },
+		"negative burn rate":  func(s *SLO) { s.MaxBurnRate = -1 },
+		"short window larger": func(s *SLO) { s.ShortWindow = 11 },
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			slo := valid
+			mutate(&slo)
+			if err := slo.Validate(); err == nil {
+				t.Error("Validate() = nil, wanted an error")
+			}
+		})
+	}
+
+	if _, err := NewConfigFromMap(map[string]string{"sloConfig": "benchmarkSLOs: ["}); err == nil {
+		t.Error("NewConfigFromMap() = nil, wanted an error for a malformed SLO config")
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mako
+
+import (
+	"context"
+
+	mpb "github.com/google/mako/spec/proto/mako_go_proto"
+)
+
+// RunHistory gives access to the past runs of benchmarks, e.g. through a Mako
+// client querying the Mako server.
+type RunHistory interface {
+	// RecentRuns returns up to limit of the most recent runs of the benchmark,
+	// most recent first. The runs must include their aggregates.
+	RecentRuns(ctx context.Context, benchmarkKey string, limit int) ([]*mpb.RunInfo, error)
+}
+
+// runPercentile returns the given percentile, e.g. 95, of the metric in the run,
+// if it has been aggregated.
+func runPercentile(run *mpb.RunInfo, metricKey string, percentile float64) (float64, bool) {
+	if percentile == 50 {
+		if v, ok := runValue(run, &mpb.DataFilter{
+			DataType: mpb.DataFilter_METRIC_AGGREGATE_MEDIAN.Enum(),
+			ValueKey: &metricKey,
+		}); ok {
+			return v, true
+		}
+	}
+	rank := int32(percentile * 1000)
+	return runValue(run, &mpb.DataFilter{
+		DataType:            mpb.DataFilter_METRIC_AGGREGATE_PERCENTILE.Enum(),
+		ValueKey:            &metricKey,
+		PercentileMilliRank: &rank,
+	})
+}
+
+// runValue returns the value of the aggregate selected by the filter in the run,
+// if it has been aggregated. Sample points are not supported.
+func runValue(run *mpb.RunInfo, filter *mpb.DataFilter) (float64, bool) {
+	agg := run.GetAggregate()
+	ra := agg.GetRunAggregate()
+	switch filter.GetDataType() {
+	case mpb.DataFilter_BENCHMARK_SCORE:
+		return float64(ra.GetBenchmarkScore()), ra.BenchmarkScore != nil
+	case mpb.DataFilter_ERROR_COUNT:
+		return float64(ra.GetErrorSampleCount()), ra.ErrorSampleCount != nil
+	case mpb.DataFilter_CUSTOM_AGGREGATE:
+		for _, kv := range ra.GetCustomAggregateList() {
+			if kv.GetValueKey() == filter.GetValueKey() {
+				return kv.GetValue(), kv.Value != nil
+			}
+		}
+		return 0, false
+	}
+
+	for _, ma := range agg.GetMetricAggregateList() {
+		if ma.GetMetricKey() != filter.GetValueKey() {
+			continue
+		}
+		switch filter.GetDataType() {
+		case mpb.DataFilter_METRIC_AGGREGATE_COUNT:
+			return float64(ma.GetCount()), ma.Count != nil
+		case mpb.DataFilter_METRIC_AGGREGATE_MIN:
+			return ma.GetMin(), ma.Min != nil
+		case mpb.DataFilter_METRIC_AGGREGATE_MAX:
+			return ma.GetMax(), ma.Max != nil
+		case mpb.DataFilter_METRIC_AGGREGATE_MEAN:
+			return ma.GetMean(), ma.Mean != nil
+		case mpb.DataFilter_METRIC_AGGREGATE_MEDIAN:
+			return ma.GetMedian(), ma.Median != nil
+		case mpb.DataFilter_METRIC_AGGREGATE_STDDEV:
+			return ma.GetStandardDeviation(), ma.StandardDeviation != nil
+		case mpb.DataFilter_METRIC_AGGREGATE_MAD:
+			return ma.GetMedianAbsoluteDeviation(), ma.MedianAbsoluteDeviation != nil
+		case mpb.DataFilter_METRIC_AGGREGATE_PERCENTILE:
+			for i, r := range agg.GetPercentileMilliRankList() {
+				if r == filter.GetPercentileMilliRank() && i < len(ma.GetPercentileList()) {
+					return ma.GetPercentileList()[i], true
+				}
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,452 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mako
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	mpb "github.com/google/mako/spec/proto/mako_go_proto"
+
+	"knative.dev/pkg/test/mako/config"
+)
+
+const (
+	// samplesBatch is the name of the single sample batch of the local runs.
+	samplesBatch = "samples"
+
+	// goodRunLookback is the number of runs before the analyzed one which
+	// are looked at for the last run without regression.
+	goodRunLookback = 10
+
+	// commitTagPrefix prefixes the tag of the commit of the runs.
+	commitTagPrefix = "commit="
+)
+
+// defaultPercentiles are the percentiles of the metrics aggregated for every
+// local run, the same as Mako's.
+var defaultPercentiles = []float64{1, 2, 5, 10, 20, 30, 40, 50, 60, 70, 80, 90, 95, 98, 99}
+
+// LocalBackend is a self-contained Storage and Analyzer of the runs of a
+// benchmark, which doesn't need the Mako service. The runs are stored as JSON
+// files under `<dir>/<benchmark key>/`, along with the aggregates of their
+// metrics, and are analyzed with the regression checks of the benchmark.
+// It can also serve as the RunHistory and the RunStore of the benchmark.
+type LocalBackend struct {
+	dir          string
+	benchmarkKey string
+	tags         []string
+	checks       []config.RegressionCheck
+	now          func() time.Time
+
+	mu  sync.Mutex
+	run localRun
+}
+
+var (
+	_ Storage    = (*LocalBackend)(nil)
+	_ Analyzer   = (*LocalBackend)(nil)
+	_ RunHistory = (*LocalBackend)(nil)
+	_ RunStore   = (*LocalBackend)(nil)
+)
+
+// localRun is a run stored by the LocalBackend.
+type localRun struct {
+	RunKey       string         `json:"runKey"`
+	BenchmarkKey string         `json:"benchmarkKey"`
+	TimestampMs  float64        `json:"timestampMs"`
+	Tags         []string       `json:"tags,omitempty"`
+	Samples      []localSample  `json:"samples,omitempty"`
+	Errors       []localError   `json:"errors,omitempty"`
+	Aggregate    *mpb.Aggregate `json:"aggregate,omitempty"`
+}
+
+type localSample struct {
+	X      float64            `json:"x"`
+	Values map[string]float64 `json:"values"`
+}
+
+type localError struct {
+	X       float64 `json:"x"`
+	Message string  `json:"message"`
+}
+
+// NewLocalBackend creates a LocalBackend storing the runs of the benchmark
+// with the given tags in the given directory, and analyzing them with the
+// given regression checks.
+func NewLocalBackend(dir, benchmarkKey string, tags []string, checks []config.RegressionCheck) *LocalBackend {
+	return &LocalBackend{
+		dir:          dir,
+		benchmarkKey: benchmarkKey,
+		tags:         tags,
+		checks:       checks,
+		now:          time.Now,
+	}
+}
+
+// AddSamplePoint implements Storage.
+func (b *LocalBackend) AddSamplePoint(xval float64, values map[string]float64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	copied := make(map[string]float64, len(values))
+	for k, v := range values {
+		copied[k] = v
+	}
+	b.run.Samples = append(b.run.Samples, localSample{X: xval, Values: copied})
+	return nil
+}
+
+// AddError implements Storage.
+func (b *LocalBackend) AddError(xval float64, message string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.run.Errors = append(b.run.Errors, localError{X: xval, Message: message})
+	return nil
+}
+
+// AddRunAggregate implements Storage.
+func (b *LocalBackend) AddRunAggregate(valueKey string, value float64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.run.Aggregate == nil {
+		b.run.Aggregate = &mpb.Aggregate{RunAggregate: &mpb.RunAggregate{}}
+	}
+	ra := b.run.Aggregate.RunAggregate
+	ra.CustomAggregateList = append(ra.CustomAggregateList, &mpb.KeyedValue{
+		ValueKey: proto.String(valueKey),
+		Value:    proto.Float64(value),
+	})
+	return nil
+}
+
+// Store implements Storage. The run is aggregated and written to its file,
+// and a new run is started.
+func (b *LocalBackend) Store(ctx context.Context) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	run := b.run
+	run.RunKey = fmt.Sprintf("%s/%019d", b.benchmarkKey, now.UnixNano())
+	run.BenchmarkKey = b.benchmarkKey
+	run.TimestampMs = XTime(now)
+	run.Tags = b.tags
+	run.Aggregate = aggregate(run, b.percentiles())
+	if err := b.writeRun(run); err != nil {
+		return "", err
+	}
+	b.run = localRun{}
+	return run.RunKey, nil
+}
+
+// percentiles returns the percentiles of the metrics to aggregate, including
+// the ones of the regression checks.
+func (b *LocalBackend) percentiles() []float64 {
+	percentiles := append([]float64{}, defaultPercentiles...)
+	for _, check := range b.checks {
+		if p, err := config.ParsePercentile(check.Aggregate); err == nil && p > 0 {
+			percentiles = append(percentiles, p)
+		}
+	}
+	sort.Float64s(percentiles)
+	unique := percentiles[:0]
+	for i, p := range percentiles {
+		if i == 0 || p != percentiles[i-1] {
+			unique = append(unique, p)
+		}
+	}
+	return unique
+}
+
+// Analyze implements Analyzer, running the regression checks of the benchmark
+// on the run against the runs stored before it.
+func (b *LocalBackend) Analyze(ctx context.Context, runKey string) (Analysis, error) {
+	run, err := b.readRun(runKey)
+	if err != nil {
+		return Analysis{}, err
+	}
+	window := 0
+	for _, check := range b.checks {
+		if check.Window > window {
+			window = check.Window
+		}
+	}
+	// The previous runs are checked against the runs before them, to find
+	// the last one without regression.
+	previous, err := b.runsBefore(run.BenchmarkKey, runKey, window+goodRunLookback)
+	if err != nil {
+		return Analysis{}, err
+	}
+
+	analysis := Analysis{RunKey: runKey}
+	analysis.Regressions = b.regressions(run.info(), previous)
+	for i, p := range previous {
+		if i == goodRunLookback {
+			break
+		}
+		if len(b.regressions(p, previous[i+1:])) == 0 {
+			analysis.GoodCommit = runCommit(p)
+			break
+		}
+	}
+	return analysis, nil
+}
+
+// regressions returns the regressions detected in the run by the checks of
+// the benchmark, against the given previous runs, most recent first.
+func (b *LocalBackend) regressions(run *mpb.RunInfo, previous []*mpb.RunInfo) []string {
+	var regressions []string
+	for _, check := range b.checks {
+		if regression := checkRegression(check, run, previous); regression != "" {
+			regressions = append(regressions, regression)
+		}
+	}
+	return regressions
+}
+
+// runCommit returns the commit the run was tagged with, if any.
+func runCommit(run *mpb.RunInfo) string {
+	for _, tag := range run.GetTags() {
+		if strings.HasPrefix(tag, commitTagPrefix) {
+			return strings.TrimPrefix(tag, commitTagPrefix)
+		}
+	}
+	return ""
+}
+
+// RecentRuns implements RunHistory.
+func (b *LocalBackend) RecentRuns(ctx context.Context, benchmarkKey string, limit int) ([]*mpb.RunInfo, error) {
+	return b.runsBefore(benchmarkKey, "", limit)
+}
+
+// Runs implements RunStore.
+func (b *LocalBackend) Runs(ctx context.Context, benchmarkKey string) ([]*mpb.RunInfo, error) {
+	return b.runsBefore(benchmarkKey, "", 0)
+}
+
+// DeleteRun implements RunStore.
+func (b *LocalBackend) DeleteRun(ctx context.Context, runKey string) error {
+	path, err := b.runPath(runKey)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// DeleteSampleBatches implements RunStore. The local runs have a single
+// sample batch.
+func (b *LocalBackend) DeleteSampleBatches(ctx context.Context, runKey string, batchKeys []string) error {
+	run, err := b.readRun(runKey)
+	if err != nil {
+		return err
+	}
+	for _, key := range batchKeys {
+		if key != run.batchKey() {
+			return fmt.Errorf("unknown sample batch %q of run %q", key, runKey)
+		}
+	}
+	if len(batchKeys) == 0 {
+		return nil
+	}
+	run.Samples, run.Errors = nil, nil
+	return b.writeRun(run)
+}
+
+// runsBefore returns up to limit of the most recent runs of the benchmark
+// stored before the given run, most recent first, or all of them if the run
+// is empty or the limit is 0.
+func (b *LocalBackend) runsBefore(benchmarkKey, runKey string, limit int) ([]*mpb.RunInfo, error) {
+	files, err := ioutil.ReadDir(filepath.Join(b.dir, benchmarkKey))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, f := range files {
+		if name := f.Name(); !f.IsDir() && strings.HasSuffix(name, ".json") {
+			key := benchmarkKey + "/" + strings.TrimSuffix(name, ".json")
+			if runKey == "" || key < runKey {
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	runs := make([]*mpb.RunInfo, 0, len(keys))
+	for _, key := range keys {
+		run, err := b.readRun(key)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run.info())
+	}
+	return runs, nil
+}
+
+// runPath returns the path of the file of the run, making sure it's in the
+// directory of the backend.
+func (b *LocalBackend) runPath(runKey string) (string, error) {
+	path := filepath.Join(b.dir, filepath.FromSlash(runKey)+".json")
+	if rel, err := filepath.Rel(b.dir, path); err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("invalid run key %q", runKey)
+	}
+	return path, nil
+}
+
+func (b *LocalBackend) readRun(runKey string) (localRun, error) {
+	var run localRun
+	path, err := b.runPath(runKey)
+	if err != nil {
+		return run, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return run, fmt.Errorf("failed to read run %q: %v", runKey, err)
+	}
+	if err := json.Unmarshal(data, &run); err != nil {
+		return run, fmt.Errorf("failed to parse run %q: %v", runKey, err)
+	}
+	return run, nil
+}
+
+func (b *LocalBackend) writeRun(run localRun) error {
+	path, err := b.runPath(run.RunKey)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write run %q: %v", run.RunKey, err)
+	}
+	return nil
+}
+
+// batchKey returns the key of the sample batch of the run, if it has samples.
+func (r localRun) batchKey() string {
+	if len(r.Samples) == 0 && len(r.Errors) == 0 {
+		return ""
+	}
+	return r.RunKey + "/" + samplesBatch
+}
+
+// info returns the RunInfo of the run.
+func (r localRun) info() *mpb.RunInfo {
+	info := &mpb.RunInfo{
+		BenchmarkKey: proto.String(r.BenchmarkKey),
+		RunKey:       proto.String(r.RunKey),
+		TimestampMs:  proto.Float64(r.TimestampMs),
+		Tags:         r.Tags,
+		Aggregate:    r.Aggregate,
+	}
+	if key := r.batchKey(); key != "" {
+		info.BatchKeyList = []string{key}
+	}
+	return info
+}
+
+// aggregate aggregates the samples of the run, along with its run aggregates.
+func aggregate(run localRun, percentiles []float64) *mpb.Aggregate {
+	agg := &mpb.Aggregate{RunAggregate: &mpb.RunAggregate{}}
+	if run.Aggregate != nil && run.Aggregate.RunAggregate != nil {
+		agg.RunAggregate.CustomAggregateList = run.Aggregate.RunAggregate.CustomAggregateList
+	}
+	agg.RunAggregate.UsableSampleCount = proto.Int64(int64(len(run.Samples)))
+	agg.RunAggregate.ErrorSampleCount = proto.Int64(int64(len(run.Errors)))
+	for _, p := range percentiles {
+		agg.PercentileMilliRankList = append(agg.PercentileMilliRankList, int32(p*1000))
+	}
+
+	values := make(map[string][]float64)
+	for _, s := range run.Samples {
+		for k, v := range s.Values {
+			values[k] = append(values[k], v)
+		}
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		agg.MetricAggregateList = append(agg.MetricAggregateList, aggregateMetric(k, values[k], percentiles))
+	}
+	return agg
+}
+
+// aggregateMetric aggregates the values of the metric, which it sorts.
+func aggregateMetric(key string, values []float64, percentiles []float64) *mpb.MetricAggregate {
+	sort.Float64s(values)
+	n := float64(len(values))
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean, med := sum/n, percentile(values, 50)
+	variance := 0.0
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		variance += (v - mean) * (v - mean)
+		deviations[i] = math.Abs(v - med)
+	}
+
+	ma := &mpb.MetricAggregate{
+		MetricKey:               proto.String(key),
+		Count:                   proto.Int64(int64(len(values))),
+		Min:                     proto.Float64(values[0]),
+		Max:                     proto.Float64(values[len(values)-1]),
+		Mean:                    proto.Float64(mean),
+		Median:                  proto.Float64(med),
+		StandardDeviation:       proto.Float64(math.Sqrt(variance / n)),
+		MedianAbsoluteDeviation: proto.Float64(median(deviations)),
+	}
+	for _, p := range percentiles {
+		ma.PercentileList = append(ma.PercentileList, percentile(values, p))
+	}
+	return ma
+}
+
+// percentile returns the given percentile of the sorted values, interpolating
+// linearly between the closest ranks.
+func percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	if lower+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lower] + (rank-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
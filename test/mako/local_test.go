@@ -0,0 +1,317 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mako
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/go-cmp/cmp"
+	mpb "github.com/google/mako/spec/proto/mako_go_proto"
+
+	"knative.dev/pkg/test/mako/config"
+)
+
+const testBenchmarkKey = "benchmark"
+
+// newTestLocalBackend creates a LocalBackend in a temporary directory, whose
+// clock advances by a minute every time it's read.
+func newTestLocalBackend(t *testing.T, checks ...config.RegressionCheck) (*LocalBackend, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "mako")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	b := NewLocalBackend(dir, testBenchmarkKey, []string{"tag"}, checks)
+	now := time.Date(2019, 12, 1, 10, 0, 0, 0, time.UTC)
+	b.now = func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+	return b, func() { os.RemoveAll(dir) }
+}
+
+// storeRun stores a run with the given latencies and errors.
+func storeRun(t *testing.T, b *LocalBackend, errors int, latencies ...float64) string {
+	t.Helper()
+	for i, l := range latencies {
+		if err := b.AddSamplePoint(float64(i), map[string]float64{"l": l}); err != nil {
+			t.Fatalf("AddSamplePoint() = %v", err)
+		}
+	}
+	for i := 0; i < errors; i++ {
+		if err := b.AddError(float64(i), "failed"); err != nil {
+			t.Fatalf("AddError() = %v", err)
+		}
+	}
+	runKey, err := b.Store(context.Background())
+	if err != nil {
+		t.Fatalf("Store() = %v", err)
+	}
+	return runKey
+}
+
+func TestLocalBackendStore(t *testing.T) {
+	b, cleanup := newTestLocalBackend(t)
+	defer cleanup()
+
+	if err := b.AddRunAggregate("score", 42); err != nil {
+		t.Fatalf("AddRunAggregate() = %v", err)
+	}
+	runKey := storeRun(t, b, 1, 4, 1, 3, 2, 5)
+	if !strings.HasPrefix(runKey, testBenchmarkKey+"/") {
+		t.Errorf("Store() = %q, want a key prefixed with the benchmark key", runKey)
+	}
+
+	runs, err := b.RecentRuns(context.Background(), testBenchmarkKey, 10)
+	if err != nil {
+		t.Fatalf("RecentRuns() = %v", err)
+	}
+	if len(runs) != 1 {
+		t.Fatalf("RecentRuns() = %d runs, want 1", len(runs))
+	}
+	run := runs[0]
+	if got, want := run.GetRunKey(), runKey; got != want {
+		t.Errorf("RunKey = %q, want %q", got, want)
+	}
+	if diff := cmp.Diff([]string{"tag"}, run.GetTags()); diff != "" {
+		t.Errorf("Tags (-want, +got) = %s", diff)
+	}
+	for _, test := range []struct {
+		filter *mpb.DataFilter
+		want   float64
+	}{{
+		filter: checkFilter(config.RegressionCheck{Metric: "l", Aggregate: config.AggregateMedian}),
+		want:   3,
+	}, {
+		filter: checkFilter(config.RegressionCheck{Metric: "l", Aggregate: config.AggregateMean}),
+		want:   3,
+	}, {
+		filter: checkFilter(config.RegressionCheck{Metric: "l", Aggregate: config.AggregateMax}),
+		want:   5,
+	}, {
+		filter: checkFilter(config.RegressionCheck{Metric: "l", Aggregate: config.AggregateCount}),
+		want:   5,
+	}, {
+		filter: checkFilter(config.RegressionCheck{Metric: "l", Aggregate: "p90"}),
+		want:   4.6,
+	}, {
+		filter: checkFilter(config.RegressionCheck{Metric: "score", Aggregate: config.AggregateRun}),
+		want:   42,
+	}, {
+		filter: checkFilter(config.RegressionCheck{Aggregate: config.AggregateErrors}),
+		want:   1,
+	}} {
+		got, ok := runValue(run, test.filter)
+		if !ok || got != test.want {
+			t.Errorf("runValue(%v) = %v, %v, want %v", test.filter, got, ok, test.want)
+		}
+	}
+
+	// The next run starts from scratch.
+	next := storeRun(t, b, 0)
+	runs, err = b.RecentRuns(context.Background(), testBenchmarkKey, 10)
+	if err != nil {
+		t.Fatalf("RecentRuns() = %v", err)
+	}
+	if len(runs) != 2 || runs[0].GetRunKey() != next {
+		t.Fatalf("RecentRuns() = %v, want %q first", runs, next)
+	}
+	if got := runs[0].GetAggregate().GetRunAggregate().GetErrorSampleCount(); got != 0 {
+		t.Errorf("ErrorSampleCount = %d, want 0", got)
+	}
+	if got := len(runs[0].GetBatchKeyList()); got != 0 {
+		t.Errorf("BatchKeyList has %d keys, want none for a run without samples", got)
+	}
+}
+
+func TestLocalBackendAnalyze(t *testing.T) {
+	b, cleanup := newTestLocalBackend(t, config.RegressionCheck{
+		Name:      "max-latency",
+		Metric:    "l",
+		Aggregate: config.AggregateMax,
+		Max:       proto.Float64(10),
+	}, config.RegressionCheck{
+		Name:        "median-latency",
+		Metric:      "l",
+		Aggregate:   config.AggregateMedian,
+		Window:      3,
+		MinRuns:     2,
+		MaxIncrease: 0.2,
+		MaxDecrease: 0.5,
+	}, config.RegressionCheck{
+		Name:      "errors",
+		Aggregate: config.AggregateErrors,
+		Max:       proto.Float64(0),
+	})
+	defer cleanup()
+
+	tests := []struct {
+		name      string
+		errors    int
+		latencies []float64
+		want      []string
+	}{{
+		name:      "first run",
+		latencies: []float64{1, 2, 3},
+	}, {
+		name:      "not enough runs to compare",
+		latencies: []float64{1, 4, 5},
+	}, {
+		name:      "within the window's bounds",
+		latencies: []float64{3, 3, 3},
+	}, {
+		name:      "above the maximum",
+		latencies: []float64{1, 3, 11},
+		want:      []string{`max-latency: max of "l" is 11, above the maximum 10`},
+	}, {
+		name:      "increase",
+		latencies: []float64{5, 5, 5},
+		want:      []string{`median-latency: median of "l" is 5, 66.7% above the median 3 of the last 3 runs, max +20.0%`},
+	}, {
+		name:      "decrease and errors",
+		errors:    2,
+		latencies: []float64{1, 1, 1},
+		want: []string{
+			`median-latency: median of "l" is 1, 66.7% below the median 3 of the last 3 runs, max -50.0%`,
+			"errors: error count is 2, above the maximum 0",
+		},
+	}}
+
+	for _, test := range tests {
+		runKey := storeRun(t, b, test.errors, test.latencies...)
+		analysis, err := b.Analyze(context.Background(), runKey)
+		if err != nil {
+			t.Fatalf("%s: Analyze() = %v", test.name, err)
+		}
+		if analysis.RunKey != runKey {
+			t.Errorf("%s: RunKey = %q, want %q", test.name, analysis.RunKey, runKey)
+		}
+		if diff := cmp.Diff(test.want, analysis.Regressions); diff != "" {
+			t.Errorf("%s: Regressions (-want, +got) = %s", test.name, diff)
+		}
+		if got, want := analysis.Regressed(), len(test.want) > 0; got != want {
+			t.Errorf("%s: Regressed() = %v, want %v", test.name, got, want)
+		}
+	}
+
+	if _, err := b.Analyze(context.Background(), testBenchmarkKey+"/unknown"); err == nil {
+		t.Error("Analyze() = nil, wanted an error for an unknown run")
+	}
+	if _, err := b.Analyze(context.Background(), "../../etc/passwd"); err == nil {
+		t.Error("Analyze() = nil, wanted an error for a run outside of the directory")
+	}
+}
+
+func TestLocalBackendGoodCommit(t *testing.T) {
+	b, cleanup := newTestLocalBackend(t, config.RegressionCheck{
+		Name:      "max-latency",
+		Metric:    "l",
+		Aggregate: config.AggregateMax,
+		Max:       proto.Float64(10),
+	})
+	defer cleanup()
+
+	tests := []struct {
+		commit    string
+		latencies []float64
+		want      string
+	}{{
+		commit:    "first",
+		latencies: []float64{1},
+	}, {
+		commit:    "good",
+		latencies: []float64{1},
+		want:      "first",
+	}, {
+		commit:    "bad",
+		latencies: []float64{11},
+		want:      "good",
+	}, {
+		commit:    "worse",
+		latencies: []float64{12},
+		want:      "good",
+	}}
+
+	for _, test := range tests {
+		b.tags = []string{commitTagPrefix + test.commit}
+		runKey := storeRun(t, b, 0, test.latencies...)
+		analysis, err := b.Analyze(context.Background(), runKey)
+		if err != nil {
+			t.Fatalf("%s: Analyze() = %v", test.commit, err)
+		}
+		if analysis.GoodCommit != test.want {
+			t.Errorf("%s: GoodCommit = %q, want %q", test.commit, analysis.GoodCommit, test.want)
+		}
+	}
+}
+
+func TestLocalBackendPrune(t *testing.T) {
+	b, cleanup := newTestLocalBackend(t)
+	defer cleanup()
+
+	var keys []string
+	for i := 0; i < 4; i++ {
+		keys = append(keys, storeRun(t, b, 0, float64(i)))
+	}
+
+	policy := RetentionPolicy{
+		Runs:          RetentionLimit{MaxCount: 3},
+		SampleBatches: RetentionLimit{MaxCount: 1},
+	}
+	report, err := Prune(context.Background(), b, testBenchmarkKey, policy, b.now(), false)
+	if err != nil {
+		t.Fatalf("Prune() = %v", err)
+	}
+	if diff := cmp.Diff([]string{keys[0]}, report.DeletedRuns); diff != "" {
+		t.Errorf("DeletedRuns (-want, +got) = %s", diff)
+	}
+
+	runs, err := b.Runs(context.Background(), testBenchmarkKey)
+	if err != nil {
+		t.Fatalf("Runs() = %v", err)
+	}
+	if len(runs) != 3 {
+		t.Fatalf("Runs() = %d runs, want 3", len(runs))
+	}
+	for i, run := range runs {
+		if got, want := len(run.GetBatchKeyList()) > 0, i == 0; got != want {
+			t.Errorf("run %q has samples = %v, want %v", run.GetRunKey(), got, want)
+		}
+		// The aggregates are retained along with the runs.
+		if v, ok := runValue(run, checkFilter(config.RegressionCheck{Metric: "l"})); !ok || v != float64(3-i) {
+			t.Errorf("median of run %q = %v, %v, want %v", run.GetRunKey(), v, ok, 3-i)
+		}
+	}
+}
+
+func TestAnalysisSummary(t *testing.T) {
+	a := Analysis{
+		RunKey:      "run",
+		Regressions: []string{"first", "second"},
+		Link:        "https://mako.dev/run?run_key=run",
+	}
+	want := "first\nsecond\n\nSee run chart at: https://mako.dev/run?run_key=run"
+	if got := a.Summary(); got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+}
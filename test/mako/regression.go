@@ -0,0 +1,108 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mako
+
+import (
+	"fmt"
+	"math"
+
+	mpb "github.com/google/mako/spec/proto/mako_go_proto"
+
+	"knative.dev/pkg/test/mako/config"
+)
+
+// checkFilter returns the filter selecting the aggregate checked by the
+// regression check.
+func checkFilter(check config.RegressionCheck) *mpb.DataFilter {
+	filter := &mpb.DataFilter{ValueKey: &check.Metric}
+	switch check.Aggregate {
+	case config.AggregateMean:
+		filter.DataType = mpb.DataFilter_METRIC_AGGREGATE_MEAN.Enum()
+	case config.AggregateMedian, "":
+		filter.DataType = mpb.DataFilter_METRIC_AGGREGATE_MEDIAN.Enum()
+	case config.AggregateMin:
+		filter.DataType = mpb.DataFilter_METRIC_AGGREGATE_MIN.Enum()
+	case config.AggregateMax:
+		filter.DataType = mpb.DataFilter_METRIC_AGGREGATE_MAX.Enum()
+	case config.AggregateCount:
+		filter.DataType = mpb.DataFilter_METRIC_AGGREGATE_COUNT.Enum()
+	case config.AggregateStdDev:
+		filter.DataType = mpb.DataFilter_METRIC_AGGREGATE_STDDEV.Enum()
+	case config.AggregateRun:
+		filter.DataType = mpb.DataFilter_CUSTOM_AGGREGATE.Enum()
+	case config.AggregateErrors:
+		filter.DataType = mpb.DataFilter_ERROR_COUNT.Enum()
+	default:
+		p, _ := config.ParsePercentile(check.Aggregate)
+		rank := int32(p * 1000)
+		filter.DataType = mpb.DataFilter_METRIC_AGGREGATE_PERCENTILE.Enum()
+		filter.PercentileMilliRank = &rank
+	}
+	return filter
+}
+
+// checkRegression runs the regression check on the run, against the previous
+// runs, most recent first. It returns the description of the regression, or
+// an empty string if the run passes the check or lacks the aggregate.
+func checkRegression(check config.RegressionCheck, run *mpb.RunInfo, previous []*mpb.RunInfo) string {
+	filter := checkFilter(check)
+	value, ok := runValue(run, filter)
+	if !ok {
+		return ""
+	}
+	what := fmt.Sprintf("%s of %q", check.Aggregate, check.Metric)
+	if check.Aggregate == config.AggregateErrors {
+		what = "error count"
+	}
+
+	switch {
+	case check.Max != nil && value > *check.Max:
+		return fmt.Sprintf("%s: %s is %v, above the maximum %v", check.Name, what, value, *check.Max)
+	case check.Min != nil && value < *check.Min:
+		return fmt.Sprintf("%s: %s is %v, below the minimum %v", check.Name, what, value, *check.Min)
+	}
+
+	if check.Window == 0 {
+		return ""
+	}
+	if len(previous) > check.Window {
+		previous = previous[:check.Window]
+	}
+	b := ComputeBaseline(previous, filter)
+	if b.Runs == 0 || b.Runs < check.MinRuns {
+		return ""
+	}
+	margin := math.Abs(b.Median)
+	switch {
+	case check.MaxIncrease > 0 && value > b.Median+check.MaxIncrease*margin:
+		return fmt.Sprintf("%s: %s is %v, %.1f%% above the median %v of the last %d runs, max +%.1f%%",
+			check.Name, what, value, relativeChange(value, b.Median)*100, b.Median, b.Runs, check.MaxIncrease*100)
+	case check.MaxDecrease > 0 && value < b.Median-check.MaxDecrease*margin:
+		return fmt.Sprintf("%s: %s is %v, %.1f%% below the median %v of the last %d runs, max -%.1f%%",
+			check.Name, what, value, -relativeChange(value, b.Median)*100, b.Median, b.Runs, check.MaxDecrease*100)
+	}
+	return ""
+}
+
+// relativeChange returns the change from the base to the value, relative to
+// the base.
+func relativeChange(value, base float64) float64 {
+	if base == 0 {
+		return math.Inf(int(math.Copysign(1, value)))
+	}
+	return (value - base) / math.Abs(base)
+}
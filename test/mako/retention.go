@@ -0,0 +1,165 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mako
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	mpb "github.com/google/mako/spec/proto/mako_go_proto"
+
+	"knative.dev/pkg/test/helpers"
+)
+
+// RunStore gives access to all of the stored runs of benchmarks and deletes
+// them, e.g. through a Mako client talking to the Mako server.
+type RunStore interface {
+	// Runs returns all of the stored runs of the benchmark, in any order.
+	// The runs must include their sample batch keys.
+	Runs(ctx context.Context, benchmarkKey string) ([]*mpb.RunInfo, error)
+	// DeleteRun deletes the run along with its sample batches.
+	DeleteRun(ctx context.Context, runKey string) error
+	// DeleteSampleBatches deletes the given sample batches of the run,
+	// keeping the run and its aggregates.
+	DeleteSampleBatches(ctx context.Context, runKey string, batchKeys []string) error
+}
+
+// RetentionLimit limits what is retained by age and by count. The zero
+// fields don't limit anything.
+type RetentionLimit struct {
+	// MaxAge is the age of the oldest runs retained.
+	MaxAge time.Duration
+	// MaxCount is the number of most recent runs retained.
+	MaxCount int
+}
+
+// retains returns true if the run, the index-th most recent, is within the limit.
+func (l RetentionLimit) retains(run *mpb.RunInfo, index int, now time.Time) bool {
+	if l.MaxCount > 0 && index >= l.MaxCount {
+		return false
+	}
+	if l.MaxAge > 0 && now.Sub(runTime(run)) > l.MaxAge {
+		return false
+	}
+	return true
+}
+
+// RetentionPolicy defines which runs of a benchmark, and which of their sample
+// batches, are retained.
+type RetentionPolicy struct {
+	// Runs limits the runs retained, the others are deleted entirely.
+	Runs RetentionLimit
+	// SampleBatches limits the runs whose sample batches are retained, the
+	// others only retain their aggregates, which is what the analyzers and
+	// the baselines use. It should be tighter than Runs.
+	SampleBatches RetentionLimit
+	// KeepTags are the tags of the runs which are always retained entirely,
+	// e.g. the tag of reference runs.
+	KeepTags []string
+}
+
+// PruneReport reports what was pruned, or would be in dry run.
+type PruneReport struct {
+	// DeletedRuns are the keys of the runs deleted.
+	DeletedRuns []string
+	// DeletedSampleBatches maps the keys of runs to their sample batches
+	// deleted.
+	DeletedSampleBatches map[string][]string
+}
+
+// String summarizes the report.
+func (r PruneReport) String() string {
+	batches := 0
+	for _, keys := range r.DeletedSampleBatches {
+		batches += len(keys)
+	}
+	return fmt.Sprintf("deleted %d runs and %d sample batches of %d runs",
+		len(r.DeletedRuns), batches, len(r.DeletedSampleBatches))
+}
+
+// Prune deletes the runs of the benchmark and their sample batches beyond the
+// retention policy, as of now. It carries on past the deletions which fail, and
+// returns their errors combined along with what was deleted. In dry run,
+// nothing is deleted, the report lists what would be.
+func Prune(ctx context.Context, store RunStore, benchmarkKey string, policy RetentionPolicy, now time.Time, dryrun bool) (PruneReport, error) {
+	report := PruneReport{DeletedSampleBatches: make(map[string][]string)}
+	runs, err := store.Runs(ctx, benchmarkKey)
+	if err != nil {
+		return report, fmt.Errorf("failed to list the runs of benchmark %q: %v", benchmarkKey, err)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].GetTimestampMs() > runs[j].GetTimestampMs()
+	})
+
+	var errs []error
+	for i, run := range runs {
+		if hasAnyTag(run, policy.KeepTags) {
+			continue
+		}
+		runKey := run.GetRunKey()
+		if !policy.Runs.retains(run, i, now) {
+			if err := helpers.Run(
+				fmt.Sprintf("deleting run %q of benchmark %q", runKey, benchmarkKey),
+				func() error {
+					return store.DeleteRun(ctx, runKey)
+				},
+				dryrun,
+			); err != nil {
+				errs = append(errs, fmt.Errorf("failed to delete run %q: %v", runKey, err))
+				continue
+			}
+			report.DeletedRuns = append(report.DeletedRuns, runKey)
+			continue
+		}
+
+		batchKeys := run.GetBatchKeyList()
+		if len(batchKeys) == 0 || policy.SampleBatches.retains(run, i, now) {
+			continue
+		}
+		if err := helpers.Run(
+			fmt.Sprintf("deleting %d sample batches of run %q of benchmark %q", len(batchKeys), runKey, benchmarkKey),
+			func() error {
+				return store.DeleteSampleBatches(ctx, runKey, batchKeys)
+			},
+			dryrun,
+		); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete the sample batches of run %q: %v", runKey, err))
+			continue
+		}
+		report.DeletedSampleBatches[runKey] = batchKeys
+	}
+	return report, helpers.CombineErrors(errs)
+}
+
+// runTime returns the time of the run.
+func runTime(run *mpb.RunInfo) time.Time {
+	return time.Unix(0, int64(run.GetTimestampMs()*float64(time.Millisecond)))
+}
+
+// hasAnyTag returns true if the run has any of the tags.
+func hasAnyTag(run *mpb.RunInfo, tags []string) bool {
+	for _, tag := range run.GetTags() {
+		for _, keep := range tags {
+			if tag == keep {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mako
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/go-cmp/cmp"
+	mpb "github.com/google/mako/spec/proto/mako_go_proto"
+)
+
+type fakeStore struct {
+	runs           map[string]*mpb.RunInfo
+	failDeletesOf  string
+	deletedBatches map[string][]string
+}
+
+func newFakeStore(now time.Time, days int) *fakeStore {
+	s := &fakeStore{runs: make(map[string]*mpb.RunInfo), deletedBatches: make(map[string][]string)}
+	// One run per day, the most recent one first.
+	for i := 0; i < days; i++ {
+		key := fmt.Sprintf("run%d", i)
+		s.runs[key] = &mpb.RunInfo{
+			RunKey:       proto.String(key),
+			TimestampMs:  proto.Float64(XTime(now.Add(-time.Duration(i) * 24 * time.Hour))),
+			BatchKeyList: []string{key + "-batch"},
+		}
+	}
+	return s
+}
+
+func (s *fakeStore) Runs(context.Context, string) ([]*mpb.RunInfo, error) {
+	runs := make([]*mpb.RunInfo, 0, len(s.runs))
+	for _, run := range s.runs {
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+func (s *fakeStore) DeleteRun(_ context.Context, runKey string) error {
+	if runKey == s.failDeletesOf {
+		return errors.New("failed")
+	}
+	delete(s.runs, runKey)
+	return nil
+}
+
+func (s *fakeStore) DeleteSampleBatches(_ context.Context, runKey string, batchKeys []string) error {
+	if runKey == s.failDeletesOf {
+		return errors.New("failed")
+	}
+	s.deletedBatches[runKey] = batchKeys
+	s.runs[runKey].BatchKeyList = nil
+	return nil
+}
+
+func (s *fakeStore) runKeys() []string {
+	var keys []string
+	for key := range s.runs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestPrune(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name        string
+		policy      RetentionPolicy
+		tag         string
+		failing     string
+		wantRuns    []string
+		wantBatches map[string][]string
+		wantErr     bool
+	}{{
+		name:        "no limits",
+		wantRuns:    []string{"run0", "run1", "run2", "run3", "run4"},
+		wantBatches: map[string][]string{},
+	}, {
+		name:        "by count",
+		policy:      RetentionPolicy{Runs: RetentionLimit{MaxCount: 4}, SampleBatches: RetentionLimit{MaxCount: 2}},
+		wantRuns:    []string{"run0", "run1", "run2", "run3"},
+		wantBatches: map[string][]string{"run2": {"run2-batch"}, "run3": {"run3-batch"}},
+	}, {
+		name:        "by age",
+		policy:      RetentionPolicy{Runs: RetentionLimit{MaxAge: 60 * time.Hour}, SampleBatches: RetentionLimit{MaxAge: 36 * time.Hour}},
+		wantRuns:    []string{"run0", "run1", "run2"},
+		wantBatches: map[string][]string{"run2": {"run2-batch"}},
+	}, {
+		name:        "kept tags",
+		policy:      RetentionPolicy{Runs: RetentionLimit{MaxCount: 1}, KeepTags: []string{"reference"}},
+		tag:         "run3",
+		wantRuns:    []string{"run0", "run3"},
+		wantBatches: map[string][]string{},
+	}, {
+		name:        "failures",
+		policy:      RetentionPolicy{Runs: RetentionLimit{MaxCount: 2}},
+		failing:     "run3",
+		wantRuns:    []string{"run0", "run1", "run3"},
+		wantBatches: map[string][]string{},
+		wantErr:     true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newFakeStore(now, 5)
+			store.failDeletesOf = test.failing
+			if test.tag != "" {
+				store.runs[test.tag].Tags = []string{"reference"}
+			}
+
+			report, err := Prune(context.Background(), store, "key", test.policy, now, false)
+			if (err != nil) != test.wantErr {
+				t.Errorf("Prune() = %v, wantErr %v", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.wantRuns, store.runKeys()); diff != "" {
+				t.Errorf("Retained runs (-want, +got) = %s", diff)
+			}
+			if diff := cmp.Diff(test.wantBatches, store.deletedBatches); diff != "" {
+				t.Errorf("Deleted sample batches (-want, +got) = %s", diff)
+			}
+			if diff := cmp.Diff(test.wantBatches, report.DeletedSampleBatches); diff != "" {
+				t.Errorf("Reported sample batches (-want, +got) = %s", diff)
+			}
+			if got, want := len(report.DeletedRuns), 5-len(test.wantRuns); got != want {
+				t.Errorf("len(DeletedRuns) = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestPruneDryRun(t *testing.T) {
+	now := time.Now()
+	store := newFakeStore(now, 5)
+	policy := RetentionPolicy{Runs: RetentionLimit{MaxCount: 3}, SampleBatches: RetentionLimit{MaxCount: 1}}
+	report, err := Prune(context.Background(), store, "key", policy, now, true)
+	if err != nil {
+		t.Fatalf("Prune() = %v", err)
+	}
+	if got, want := len(store.runs), 5; got != want {
+		t.Errorf("len(runs) = %d, want %d, nothing should be deleted in dry run", got, want)
+	}
+	if got, want := report.String(), "deleted 2 runs and 2 sample batches of 2 runs"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/compute/metadata"
 	kubeclient "knative.dev/pkg/client/injection/kube/client"
@@ -34,7 +35,11 @@ import (
 	"knative.dev/pkg/changeset"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/injection"
+	"knative.dev/pkg/test/gcs"
+	"knative.dev/pkg/test/ghutil"
+	"knative.dev/pkg/test/helpers"
 	"knative.dev/pkg/test/mako/alerter"
+	"knative.dev/pkg/test/mako/alerter/github"
 	"knative.dev/pkg/test/mako/config"
 )
 
@@ -62,17 +67,105 @@ const (
 
 // Client is a wrapper that wraps all Mako related operations
 type Client struct {
-	Quickstore    *quickstore.Quickstore
+	// Quickstore is the Quickstore of the Mako backend, nil with the
+	// local backend. Prefer Storage, which works with both.
+	Quickstore *quickstore.Quickstore
+	// Storage stores the benchmarking data in the configured backend.
+	Storage       Storage
 	Context       context.Context
 	ShutDownFunc  func(context.Context)
+	benchmarkKey  string
 	benchmarkName string
-	alerter       *alerter.Alerter
+	analyzer      Analyzer
+	// history gives access to the past runs the SLOs are checked over, if
+	// the backend provides it. The Mako backend doesn't, as the sidecar
+	// only stores runs.
+	history RunHistory
+	// runs stores the runs pruned beyond the retention policy once a run is
+	// stored, if the backend provides it and a policy is configured.
+	runs      RunStore
+	retention RetentionPolicy
+	alerter   alertHandler
+	// batch batches the alerts until FlushAlerts is called, or the client
+	// is shut down, if enabled.
+	batch alertFlusher
+	// commits sets the commit range of the regressions alerted on, if set.
+	commits commitRangeSetter
+	// commit is the commit of the benchmarks.
+	commit string
 }
 
-// StoreAndHandleResult stores the benchmarking data and handles the result.
+// commitRangeSetter sets the commit range of the regressions alerted on next.
+type commitRangeSetter interface {
+	SetCommitRange(alerter.CommitRange)
+}
+
+// alertFlusher reports the alerts batched since the last flush.
+type alertFlusher interface {
+	Flush() error
+}
+
+// alertHandler handles the alerts of the benchmark, either right away or in a batch.
+type alertHandler interface {
+	HandleAnalysis(testName, runID string, regressed bool, summary string) error
+	HandleSLOStatus(testName, runID string, violated bool, summary string) error
+}
+
+// StoreAndHandleResult stores the benchmarking data, analyzes the run and
+// alerts on the regressions detected, if any. With a backend providing the run
+// history, the SLOs of the benchmark are checked too, see CheckSLOs, and the
+// runs beyond the retention policy are pruned, see Prune.
 func (c *Client) StoreAndHandleResult() error {
-	out, err := c.Quickstore.Store()
-	return c.alerter.HandleBenchmarkResult(c.benchmarkName, out, err)
+	runKey, err := c.Storage.Store(c.Context)
+	if err != nil {
+		return err
+	}
+	analysis, err := c.analyzer.Analyze(c.Context, runKey)
+	if err != nil {
+		return err
+	}
+	if c.commits != nil {
+		c.commits.SetCommitRange(alerter.CommitRange{Good: analysis.GoodCommit, Bad: c.commit})
+	}
+	var errs []error
+	if err := c.alerter.HandleAnalysis(c.benchmarkName, analysis.RunKey, analysis.Regressed(), analysis.Summary()); err != nil {
+		errs = append(errs, err)
+	}
+	if c.history != nil {
+		if err := c.CheckSLOs(c.Context, c.history); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.runs != nil {
+		report, err := Prune(c.Context, c.runs, c.benchmarkKey, c.retention, time.Now(), false)
+		log.Printf("Pruned the runs of %s: %v", c.benchmarkKey, report)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return helpers.CombineErrors(errs)
+}
+
+// FlushAlerts reports the regressions batched since the last flush, if the
+// Github alerts are batched. It should be called once the run is done, it is
+// called by ShutDownFunc otherwise.
+func (c *Client) FlushAlerts() error {
+	if c.batch == nil {
+		return nil
+	}
+	return c.batch.Flush()
+}
+
+// flushAlertsOnShutDown returns the shut down function flushing the batched
+// alerts before shutting down, so that they aren't lost if FlushAlerts wasn't
+// called.
+func (c *Client) flushAlertsOnShutDown(shutDown func(context.Context)) func(context.Context) {
+	return func(ctx context.Context) {
+		if err := c.FlushAlerts(); err != nil {
+			log.Printf("Failed to flush the batched alerts: %v", err)
+		}
+		shutDown(ctx)
+	}
 }
 
 // EscapeTag replaces characters that Mako doesn't accept with ones it does.
@@ -134,39 +227,121 @@ func SetupHelper(ctx context.Context, benchmarkKey *string, benchmarkName *strin
 		tags = append(tags, "instanceType="+EscapeTag(parts[3]))
 	}
 
-	// Create a new Quickstore that connects to the microservice
-	qs, qclose, err := quickstore.NewAtAddress(ctx, &qpb.QuickstoreInput{
-		BenchmarkKey: benchmarkKey,
-		Tags: append(tags,
-			"commit="+commitID,
-			"kubernetes="+EscapeTag(version.String()),
-			EscapeTag(runtime.Version()),
-		),
-	}, sidecarAddress)
+	tags = append(tags,
+		commitTagPrefix+commitID,
+		"kubernetes="+EscapeTag(version.String()),
+		EscapeTag(runtime.Version()),
+	)
+	client := &Client{
+		Context:       ctx,
+		benchmarkKey:  *benchmarkKey,
+		benchmarkName: *benchmarkName,
+		commit:        commitID,
+	}
+
+	backendConfig, err := config.GetBackendConfig()
 	if err != nil {
 		return nil, err
 	}
+	switch backendConfig.Type {
+	case config.LocalBackend:
+		// Store the runs locally, when the Mako service can't be reached.
+		local := NewLocalBackend(backendConfig.Path, *benchmarkKey, tags,
+			backendConfig.BenchmarkChecks[*benchmarkName])
+		client.Storage, client.analyzer, client.history = local, local, local
+		if r := backendConfig.Retention; r != nil {
+			client.runs = local
+			client.retention = RetentionPolicy{
+				Runs:          RetentionLimit{MaxAge: r.Runs.MaxAge, MaxCount: r.Runs.MaxCount},
+				SampleBatches: RetentionLimit{MaxAge: r.SampleBatches.MaxAge, MaxCount: r.SampleBatches.MaxCount},
+				KeepTags:      r.KeepTags,
+			}
+		}
+		client.ShutDownFunc = func(context.Context) {}
+	default:
+		// Create a new Quickstore that connects to the microservice
+		qs, qclose, err := quickstore.NewAtAddress(ctx, &qpb.QuickstoreInput{
+			BenchmarkKey: benchmarkKey,
+			Tags:         tags,
+		}, sidecarAddress)
+		if err != nil {
+			return nil, err
+		}
+		if slos, err := config.GetSLOs(*benchmarkName); err == nil && len(slos) > 0 {
+			log.Printf("Ignoring the SLOs of %s, which need the run history of the local backend", *benchmarkName)
+		}
+		if backendConfig.Retention != nil {
+			log.Print("Ignoring the retention, the runs stored in Mako can't be pruned through the sidecar")
+		}
+		backend := NewQuickstoreBackend(qs)
+		client.Quickstore = qs
+		client.Storage, client.analyzer = backend, backend
+		client.ShutDownFunc = qclose
+	}
 
 	// Create a new Alerter that alerts for performance regressions
+	templates, err := github.ParseTemplates(config.GetGithubTemplates())
+	if err != nil {
+		log.Printf("Ignoring the Github templates: %v", err)
+	}
+	var githubAuth ghutil.Auth
+	if app := config.GetGithubApp(); app != nil {
+		if err := app.Validate(); err != nil {
+			log.Printf("Ignoring the Github App: %v", err)
+		} else {
+			githubAuth = ghutil.AppAuth{AppID: app.AppID, InstallationID: app.InstallationID, PrivateKeyPath: tokenPath(app.PrivateKey)}
+		}
+	}
+	var overflow gcs.ArtifactStore
+	if location := config.GetGithubOverflow(); location != "" {
+		store, err := gcs.NewArtifactStore(ctx, location)
+		if err != nil {
+			log.Printf("Ignoring the Github overflow: %v", err)
+		} else {
+			overflow = store
+		}
+	}
 	alerter := &alerter.Alerter{}
 	alerter.SetupGitHub(
 		org,
 		config.GetRepository(),
 		tokenPath(githubToken),
+		github.Options{
+			Severity:     config.GetGithubSeverity(*benchmarkName),
+			Mentions:     config.GetGithubMentions(*benchmarkName),
+			Routes:       config.GetGithubRoutes(),
+			RecoveryRuns: config.GetGithubRecoveryRuns(),
+			Templates:    templates,
+			Labels:       config.GetGithubLabels(),
+			Assignees:    config.GetGithubAssignees(),
+			CacheTTL:     config.GetGithubCacheTTL(),
+			Digest:       config.GetGithubDigest(),
+			Batch:        config.GetGithubBatch(),
+			Auth:         githubAuth,
+			BaseURL:      config.GetGithubBaseURL(),
+			Overflow:     overflow,
+			Context:      ctx,
+		},
 	)
+	if client.history != nil {
+		// Only the local backend knows the last run without regression.
+		alerter.SetupCommits(org, config.GetRepository(), ghutil.ClientOptions{
+			Auth:    commitsAuth(githubAuth),
+			BaseURL: config.GetGithubBaseURL(),
+		})
+		client.commits = alerter
+	}
 	alerter.SetupSlack(
 		slackUserName,
 		tokenPath(slackReadToken),
 		tokenPath(slackWriteToken),
 		config.GetSlackChannels(*benchmarkName),
 	)
-
-	client := &Client{
-		Quickstore:    qs,
-		Context:       ctx,
-		ShutDownFunc:  qclose,
-		alerter:       alerter,
-		benchmarkName: *benchmarkName,
+	client.alerter = alerter
+	if config.GetGithubBatch() != "" {
+		batch := alerter.NewBatch()
+		client.batch, client.alerter = batch, batch
+		client.ShutDownFunc = client.flushAlertsOnShutDown(client.ShutDownFunc)
 	}
 
 	return client, nil
@@ -181,6 +356,15 @@ func SetupWithBenchmarkConfig(ctx context.Context, benchmarkKey *string, benchma
 	return SetupHelper(ctx, benchmarkKey, benchmarkName, extraTags...)
 }
 
+// commitsAuth returns the authentication of the Github client listing the
+// PRs merged between runs, the same as the alerter's.
+func commitsAuth(auth ghutil.Auth) ghutil.Auth {
+	if auth == nil {
+		return ghutil.TokenAuth{TokenFilePath: tokenPath(githubToken)}
+	}
+	return auth
+}
+
 func tokenPath(token string) string {
 	return filepath.Join(tokenFolder, token)
 }
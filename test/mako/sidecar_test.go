@@ -0,0 +1,146 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mako
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"knative.dev/pkg/test/mako/config"
+)
+
+// fakeAlerts records the alerts of the tests.
+type fakeAlerts struct {
+	alerts []string
+}
+
+func (f *fakeAlerts) HandleAnalysis(testName, runID string, regressed bool, summary string) error {
+	if regressed {
+		f.alerts = append(f.alerts, testName)
+	}
+	return nil
+}
+
+func (f *fakeAlerts) HandleSLOStatus(testName, runID string, violated bool, summary string) error {
+	if violated {
+		f.alerts = append(f.alerts, testName)
+	}
+	return nil
+}
+
+type fakeFlusher struct {
+	flushes int
+	err     error
+}
+
+func (f *fakeFlusher) Flush() error {
+	f.flushes++
+	return f.err
+}
+
+func TestShutDownFlushesAlerts(t *testing.T) {
+	for _, err := range []error{nil, errors.New("github is down")} {
+		batch := &fakeFlusher{err: err}
+		client := &Client{batch: batch}
+		closed := false
+		client.ShutDownFunc = client.flushAlertsOnShutDown(func(context.Context) {
+			if batch.flushes != 1 {
+				t.Errorf("Flushed %d times before closing, want 1", batch.flushes)
+			}
+			closed = true
+		})
+
+		client.ShutDownFunc(context.Background())
+		if !closed {
+			t.Errorf("ShutDownFunc() didn't close the client after flushing with %v", err)
+		}
+	}
+}
+
+func TestStoreAndHandleResultChecksSLOs(t *testing.T) {
+	defer func(f func(string) ([]config.SLO, error)) { getSLOs = f }(getSLOs)
+	getSLOs = func(string) ([]config.SLO, error) {
+		return []config.SLO{{
+			Name:        "latency",
+			Metric:      "l",
+			Percentile:  95,
+			Max:         0.1,
+			Window:      2,
+			Objective:   1,
+			MaxBurnRate: 1,
+			ShortWindow: 1,
+		}}, nil
+	}
+
+	b, cleanup := newTestLocalBackend(t)
+	defer cleanup()
+	alerts := &fakeAlerts{}
+	client := &Client{
+		Storage:       b,
+		Context:       context.Background(),
+		benchmarkKey:  testBenchmarkKey,
+		benchmarkName: "dataplane-probe",
+		analyzer:      b,
+		history:       b,
+		alerter:       alerts,
+	}
+
+	// The SLO isn't violated until its window is full.
+	for i := 0; i < 2; i++ {
+		if err := b.AddSamplePoint(0, map[string]float64{"l": 1}); err != nil {
+			t.Fatalf("AddSamplePoint() = %v", err)
+		}
+		if err := client.StoreAndHandleResult(); err != nil {
+			t.Fatalf("StoreAndHandleResult() = %v", err)
+		}
+	}
+	if diff := cmp.Diff([]string{"dataplane-probe SLO latency"}, alerts.alerts); diff != "" {
+		t.Errorf("Alerts (-want, +got) = %s", diff)
+	}
+}
+
+func TestStoreAndHandleResultPrunes(t *testing.T) {
+	b, cleanup := newTestLocalBackend(t)
+	defer cleanup()
+	client := &Client{
+		Storage:      b,
+		Context:      context.Background(),
+		benchmarkKey: testBenchmarkKey,
+		analyzer:     b,
+		alerter:      &fakeAlerts{},
+		runs:         b,
+		retention:    RetentionPolicy{Runs: RetentionLimit{MaxCount: 2}},
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := b.AddSamplePoint(0, map[string]float64{"l": 1}); err != nil {
+			t.Fatalf("AddSamplePoint() = %v", err)
+		}
+		if err := client.StoreAndHandleResult(); err != nil {
+			t.Fatalf("StoreAndHandleResult() = %v", err)
+		}
+	}
+	runs, err := b.Runs(context.Background(), testBenchmarkKey)
+	if err != nil {
+		t.Fatalf("Runs() = %v", err)
+	}
+	if got, want := len(runs), 2; got != want {
+		t.Errorf("len(runs) = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mako
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	mpb "github.com/google/mako/spec/proto/mako_go_proto"
+	"knative.dev/pkg/test/helpers"
+	"knative.dev/pkg/test/mako/config"
+)
+
+// SLOStatus is the compliance of a benchmark with one of its SLOs.
+type SLOStatus struct {
+	SLO config.SLO
+
+	// Runs is the number of runs in the window the SLO could be evaluated for.
+	Runs int
+	// Breaches is the number of these runs that didn't comply.
+	Breaches int
+	// Compliance is the fraction of the runs that complied.
+	Compliance float64
+
+	// BurnRate and ShortBurnRate are the rates the error budget is spent at
+	// over the window and the short window, relative to the objective.
+	BurnRate      float64
+	ShortBurnRate float64
+
+	// LastRunKey is the key of the most recent run.
+	LastRunKey string
+}
+
+// Violated returns true if the error budget of the SLO is being burnt too fast,
+// over both its window and its short window. SLOs are not violated until the
+// window is full, so single bad runs of new benchmarks don't trigger alerts.
+func (s SLOStatus) Violated() bool {
+	return s.Runs >= s.SLO.Window &&
+		s.BurnRate > s.SLO.MaxBurnRate && s.ShortBurnRate > s.SLO.MaxBurnRate
+}
+
+// String summarizes the status for alerts.
+func (s SLOStatus) String() string {
+	return fmt.Sprintf("SLO %q (p%v of %q <= %v in %.0f%% of the last %d runs): "+
+		"%.1f%% of the last %d runs complied, error budget burn rate %.2f (last %d runs: %.2f), max %.2f",
+		s.SLO.Name, s.SLO.Percentile, s.SLO.Metric, s.SLO.Max, s.SLO.Objective*100, s.SLO.Window,
+		s.Compliance*100, s.Runs, s.BurnRate, s.SLO.ShortWindow, s.ShortBurnRate, s.SLO.MaxBurnRate)
+}
+
+// EvaluateSLO computes the compliance with the SLO of the given runs, most recent first.
+// Runs missing the percentile of the metric are skipped.
+func EvaluateSLO(slo config.SLO, runs []*mpb.RunInfo) SLOStatus {
+	status := SLOStatus{SLO: slo}
+	shortRuns, shortBreaches := 0, 0
+	for _, run := range runs {
+		if status.Runs == slo.Window {
+			break
+		}
+		value, ok := runPercentile(run, slo.Metric, slo.Percentile)
+		if !ok {
+			continue
+		}
+		if status.Runs == 0 {
+			status.LastRunKey = run.GetRunKey()
+		}
+		status.Runs++
+		breached := value > slo.Max
+		if breached {
+			status.Breaches++
+		}
+		if shortRuns < slo.ShortWindow {
+			shortRuns++
+			if breached {
+				shortBreaches++
+			}
+		}
+	}
+	if status.Runs == 0 {
+		status.Compliance = 1
+		return status
+	}
+	status.Compliance = 1 - float64(status.Breaches)/float64(status.Runs)
+	status.BurnRate = burnRate(status.Breaches, status.Runs, slo.Objective)
+	status.ShortBurnRate = burnRate(shortBreaches, shortRuns, slo.Objective)
+	return status
+}
+
+// burnRate returns the rate the error budget is spent at: 1 means the budget
+// is spent exactly as fast as allowed by the objective.
+func burnRate(breaches, runs int, objective float64) float64 {
+	if breaches == 0 {
+		return 0
+	}
+	budget := 1 - objective
+	if budget <= 0 {
+		return math.Inf(1)
+	}
+	// Round off the floating point error of the budget, e.g. 1-0.8, so
+	// spending the budget exactly as allowed doesn't exceed a burn rate of 1.
+	return math.Round(float64(breaches)/float64(runs)/budget*1e9) / 1e9
+}
+
+// getSLOs returns the SLOs of the benchmark.
+var getSLOs = config.GetSLOs
+
+// CheckSLOs evaluates the SLOs of the benchmark over its recent runs, and
+// alerts on the violated ones. Alerts for SLOs that are met again are closed.
+// It is called by StoreAndHandleResult with the local backend, which is the
+// only one providing a RunHistory.
+func (c *Client) CheckSLOs(ctx context.Context, history RunHistory) error {
+	slos, err := getSLOs(c.benchmarkName)
+	if err != nil {
+		return err
+	}
+	statuses, err := evaluateSLOs(ctx, history, c.benchmarkKey, slos)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, status := range statuses {
+		testName := fmt.Sprintf("%s SLO %s", c.benchmarkName, status.SLO.Name)
+		if err := c.alerter.HandleSLOStatus(testName, status.LastRunKey, status.Violated(), status.String()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return helpers.CombineErrors(errs)
+}
+
+// evaluateSLOs fetches the runs required to evaluate all the SLOs, and evaluates them.
+func evaluateSLOs(ctx context.Context, history RunHistory, benchmarkKey string, slos []config.SLO) ([]SLOStatus, error) {
+	if len(slos) == 0 {
+		return nil, nil
+	}
+	limit := 0
+	for _, slo := range slos {
+		if slo.Window > limit {
+			limit = slo.Window
+		}
+	}
+	runs, err := history.RecentRuns(ctx, benchmarkKey, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the recent runs of benchmark %q: %v", benchmarkKey, err)
+	}
+	statuses := make([]SLOStatus, 0, len(slos))
+	for _, slo := range slos {
+		statuses = append(statuses, EvaluateSLO(slo, runs))
+	}
+	return statuses, nil
+}
@@ -0,0 +1,206 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mako
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mpb "github.com/google/mako/spec/proto/mako_go_proto"
+	"knative.dev/pkg/test/mako/config"
+)
+
+// run returns a run whose p95 and median of the "l" metric are the given value.
+func run(key string, value float64) *mpb.RunInfo {
+	return &mpb.RunInfo{
+		RunKey: proto.String(key),
+		Aggregate: &mpb.Aggregate{
+			PercentileMilliRankList: []int32{90000, 95000},
+			MetricAggregateList: []*mpb.MetricAggregate{{
+				MetricKey:      proto.String("l"),
+				Median:         proto.Float64(value),
+				PercentileList: []float64{value, value},
+			}},
+		},
+	}
+}
+
+// runs returns runs with the given values, most recent first.
+func runs(values ...float64) []*mpb.RunInfo {
+	rs := make([]*mpb.RunInfo, 0, len(values))
+	for i, v := range values {
+		rs = append(rs, run(fmt.Sprintf("run%d", i), v))
+	}
+	return rs
+}
+
+type fakeHistory struct {
+	runs  []*mpb.RunInfo
+	limit int
+}
+
+func (h *fakeHistory) RecentRuns(_ context.Context, _ string, limit int) ([]*mpb.RunInfo, error) {
+	h.limit = limit
+	if h.runs == nil {
+		return nil, errors.New("no history")
+	}
+	if limit < len(h.runs) {
+		return h.runs[:limit], nil
+	}
+	return h.runs, nil
+}
+
+func TestEvaluateSLO(t *testing.T) {
+	slo := config.SLO{
+		Name:        "p95",
+		Metric:      "l",
+		Percentile:  95,
+		Max:         1,
+		Window:      10,
+		Objective:   0.8,
+		MaxBurnRate: 1,
+		ShortWindow: 3,
+	}
+
+	tests := []struct {
+		name           string
+		slo            config.SLO
+		runs           []*mpb.RunInfo
+		wantBreaches   int
+		wantBurnRate   float64
+		wantShortRate  float64
+		wantViolated   bool
+		wantLastRunKey string
+	}{{
+		name:           "compliant",
+		slo:            slo,
+		runs:           runs(0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5),
+		wantLastRunKey: "run0",
+	}, {
+		name:           "within budget",
+		slo:            slo,
+		runs:           runs(2, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 2),
+		wantBreaches:   2,
+		wantBurnRate:   1,
+		wantShortRate:  1.0 / 3 / 0.2,
+		wantLastRunKey: "run0",
+	}, {
+		name:           "sustained violation",
+		slo:            slo,
+		runs:           runs(2, 2, 0.5, 2, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5),
+		wantBreaches:   3,
+		wantBurnRate:   1.5,
+		wantShortRate:  2.0 / 3 / 0.2,
+		wantViolated:   true,
+		wantLastRunKey: "run0",
+	}, {
+		name:           "recovered",
+		slo:            slo,
+		runs:           runs(0.5, 0.5, 0.5, 2, 2, 2, 0.5, 0.5, 0.5, 0.5),
+		wantBreaches:   3,
+		wantBurnRate:   1.5,
+		wantLastRunKey: "run0",
+	}, {
+		name:           "window not full",
+		slo:            slo,
+		runs:           runs(2, 2, 2),
+		wantBreaches:   3,
+		wantBurnRate:   5,
+		wantShortRate:  5,
+		wantLastRunKey: "run0",
+	}, {
+		name:           "only the window is evaluated",
+		slo:            slo,
+		runs:           runs(0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 2, 2, 2),
+		wantLastRunKey: "run0",
+	}, {
+		name: "no budget",
+		slo: func() config.SLO {
+			s := slo
+			s.Objective = 1
+			s.Window = 2
+			s.ShortWindow = 1
+			return s
+		}(),
+		runs:           runs(2, 0.5),
+		wantBreaches:   1,
+		wantBurnRate:   math.Inf(1),
+		wantShortRate:  math.Inf(1),
+		wantViolated:   true,
+		wantLastRunKey: "run0",
+	}, {
+		name: "runs without the metric are skipped",
+		slo:  slo,
+		runs: append([]*mpb.RunInfo{{RunKey: proto.String("empty")}},
+			runs(0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5)...),
+		wantLastRunKey: "run0",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status := EvaluateSLO(test.slo, test.runs)
+			if status.Breaches != test.wantBreaches {
+				t.Errorf("Breaches = %d, want %d", status.Breaches, test.wantBreaches)
+			}
+			if !almostEqual(status.BurnRate, test.wantBurnRate) {
+				t.Errorf("BurnRate = %v, want %v", status.BurnRate, test.wantBurnRate)
+			}
+			if !almostEqual(status.ShortBurnRate, test.wantShortRate) {
+				t.Errorf("ShortBurnRate = %v, want %v", status.ShortBurnRate, test.wantShortRate)
+			}
+			if got := status.Violated(); got != test.wantViolated {
+				t.Errorf("Violated() = %v, want %v (%s)", got, test.wantViolated, status)
+			}
+			if status.LastRunKey != test.wantLastRunKey {
+				t.Errorf("LastRunKey = %q, want %q", status.LastRunKey, test.wantLastRunKey)
+			}
+		})
+	}
+}
+
+func TestEvaluateSLOs(t *testing.T) {
+	slos := []config.SLO{
+		{Name: "p95", Metric: "l", Percentile: 95, Max: 1, Window: 2, Objective: 1, ShortWindow: 1},
+		{Name: "median", Metric: "l", Percentile: 50, Max: 3, Window: 4, Objective: 1, ShortWindow: 1},
+	}
+	history := &fakeHistory{runs: runs(2, 2, 2, 2, 2)}
+	statuses, err := evaluateSLOs(context.Background(), history, "key", slos)
+	if err != nil {
+		t.Fatalf("evaluateSLOs() = %v", err)
+	}
+	if history.limit != 4 {
+		t.Errorf("Fetched %d runs, want the largest window: 4", history.limit)
+	}
+	if len(statuses) != 2 || !statuses[0].Violated() || statuses[1].Violated() {
+		t.Errorf("evaluateSLOs() = %v, want only the p95 SLO to be violated", statuses)
+	}
+
+	if _, err := evaluateSLOs(context.Background(), &fakeHistory{}, "key", slos); err == nil {
+		t.Error("evaluateSLOs() = nil, wanted an error when the history fails")
+	}
+}
+
+func almostEqual(a, b float64) bool {
+	if math.IsInf(a, 0) || math.IsInf(b, 0) {
+		return a == b
+	}
+	return math.Abs(a-b) < 1e-9
+}
@@ -59,10 +59,11 @@ func Cleanup(pid int) error {
 }
 
 // PortForward sets up local port forward to the pod specified by the "app" label in the given namespace
+//
+// Deprecated: Use NewPortForwarder, which re-establishes the forward when it breaks.
 func PortForward(logf logging.FormatLogger, podList *v1.PodList, localPort, remotePort int, namespace string) (int, error) {
 	podName := podList.Items[0].Name
-	portFwdCmd := fmt.Sprintf("kubectl port-forward %s %d:%d -n %s", podName, localPort, remotePort, namespace)
-	portFwdProcess, err := executeCmdBackground(logf, portFwdCmd)
+	portFwdProcess, err := executeCmdBackground(logf, "kubectl port-forward %s %d:%d -n %s", podName, localPort, remotePort, namespace)
 
 	if err != nil {
 		return 0, fmt.Errorf("failed to port forward: %v", err)
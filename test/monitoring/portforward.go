@@ -0,0 +1,198 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"knative.dev/pkg/test/logging"
+)
+
+const (
+	// readyTimeout is the time NewPortForwarder waits for the forward to
+	// accept connections.
+	readyTimeout = 30 * time.Second
+	// restartBackoff is the time waited before re-establishing a forward.
+	restartBackoff = time.Second
+)
+
+// portForwardCommand returns the command forwarding the local port to the
+// remote port of the pod. Overridden by tests.
+var portForwardCommand = func(namespace, pod string, localPort, remotePort int) *exec.Cmd {
+	return exec.Command("kubectl", "port-forward", "-n", namespace, "pod/"+pod,
+		fmt.Sprintf("%d:%d", localPort, remotePort)) // #nosec
+}
+
+// PortForwarder forwards a local port to a port of a running pod matching
+// a label selector. The forward is re-established, possibly to another pod,
+// whenever it breaks, e.g. because the pod restarted. Several forwarders can
+// run simultaneously, as long as their local ports differ.
+type PortForwarder struct {
+	kube       kubernetes.Interface
+	logf       logging.FormatLogger
+	namespace  string
+	selector   string
+	localPort  int
+	remotePort int
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	closed bool
+
+	stopCh chan struct{}
+	done   chan struct{}
+}
+
+// NewPortForwarder starts forwarding localPort to remotePort of a running
+// pod matching the selector in the namespace, and waits for the forward to
+// accept connections. The returned PortForwarder must be closed.
+func NewPortForwarder(logf logging.FormatLogger, kube kubernetes.Interface, namespace, selector string, localPort, remotePort int) (*PortForwarder, error) {
+	pf := &PortForwarder{
+		kube:       kube,
+		logf:       logf,
+		namespace:  namespace,
+		selector:   selector,
+		localPort:  localPort,
+		remotePort: remotePort,
+		stopCh:     make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go pf.run()
+
+	if err := pf.WaitReady(readyTimeout); err != nil {
+		pf.Close()
+		return nil, err
+	}
+	return pf, nil
+}
+
+// LocalPort returns the local port being forwarded.
+func (pf *PortForwarder) LocalPort() int {
+	return pf.localPort
+}
+
+// WaitReady waits for the forward to accept connections on the local port.
+func (pf *PortForwarder) WaitReady(timeout time.Duration) error {
+	addr := net.JoinHostPort("localhost", strconv.Itoa(pf.localPort))
+	err := wait.PollImmediate(100*time.Millisecond, timeout, func() (bool, error) {
+		select {
+		case <-pf.done:
+			return false, fmt.Errorf("port-forward to %s/%s has been closed", pf.namespace, pf.selector)
+		default:
+		}
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err != nil {
+			return false, nil
+		}
+		conn.Close()
+		return true, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("port-forward to %s/%s isn't ready after %v", pf.namespace, pf.selector, timeout)
+	}
+	return err
+}
+
+// Close stops forwarding, waiting for the forwarding process to exit.
+func (pf *PortForwarder) Close() error {
+	pf.mu.Lock()
+	if pf.closed {
+		pf.mu.Unlock()
+		return nil
+	}
+	pf.closed = true
+	close(pf.stopCh)
+	var err error
+	if pf.cmd != nil {
+		err = pf.cmd.Process.Kill()
+	}
+	pf.mu.Unlock()
+
+	<-pf.done
+	return err
+}
+
+// run (re-)establishes the forward until closed.
+func (pf *PortForwarder) run() {
+	defer close(pf.done)
+	for {
+		if err := pf.forward(); err != nil {
+			pf.logf("port-forward to %s/%s failed: %v", pf.namespace, pf.selector, err)
+		}
+		select {
+		case <-pf.stopCh:
+			return
+		case <-time.After(restartBackoff):
+		}
+		pf.logf("Re-establishing port-forward to %s/%s", pf.namespace, pf.selector)
+	}
+}
+
+// forward runs the forwarding process to a running pod until it exits.
+func (pf *PortForwarder) forward() error {
+	pod, err := pf.runningPod()
+	if err != nil {
+		return err
+	}
+
+	pf.mu.Lock()
+	if pf.closed {
+		pf.mu.Unlock()
+		return nil
+	}
+	cmd := portForwardCommand(pf.namespace, pod, pf.localPort, pf.remotePort)
+	if err := cmd.Start(); err != nil {
+		pf.mu.Unlock()
+		return fmt.Errorf("failed to start port-forward to %s: %v", pod, err)
+	}
+	pf.cmd = cmd
+	pf.mu.Unlock()
+	pf.logf("Forwarding port %d to %s/%s:%d", pf.localPort, pf.namespace, pod, pf.remotePort)
+
+	err = cmd.Wait()
+
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	pf.cmd = nil
+	if pf.closed {
+		return nil
+	}
+	return fmt.Errorf("port-forward to %s exited: %v", pod, err)
+}
+
+// runningPod returns the name of a running pod matching the selector.
+func (pf *PortForwarder) runningPod() (string, error) {
+	pods, err := pf.kube.CoreV1().Pods(pf.namespace).List(metav1.ListOptions{LabelSelector: pf.selector})
+	if err != nil {
+		return "", err
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil {
+			return pod.Name, nil
+		}
+	}
+	return "", fmt.Errorf("no running pod matches %q in namespace %s", pf.selector, pf.namespace)
+}
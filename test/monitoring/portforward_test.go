@@ -0,0 +1,131 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"net"
+	"os/exec"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func runningPod(name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "monitoring",
+			Name:      name,
+			Labels:    map[string]string{"app": "zipkin"},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+// fakeForward makes the forwarding processes run the given command,
+// counting them. The local port is served by a listener of the test.
+func fakeForward(t *testing.T, name string, args ...string) (port int, starts *int32, cleanup func()) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen() = %v", err)
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+
+	starts = new(int32)
+	orig := portForwardCommand
+	portForwardCommand = func(namespace, pod string, localPort, remotePort int) *exec.Cmd {
+		atomic.AddInt32(starts, 1)
+		return exec.Command(name, args...)
+	}
+	return l.Addr().(*net.TCPAddr).Port, starts, func() {
+		portForwardCommand = orig
+		l.Close()
+	}
+}
+
+func TestPortForwarder(t *testing.T) {
+	port, starts, cleanup := fakeForward(t, "sleep", "60")
+	defer cleanup()
+
+	kube := kubefake.NewSimpleClientset(runningPod("zipkin-1"))
+	pf, err := NewPortForwarder(t.Logf, kube, "monitoring", "app=zipkin", port, 9411)
+	if err != nil {
+		t.Fatalf("NewPortForwarder() = %v", err)
+	}
+	if got := pf.LocalPort(); got != port {
+		t.Errorf("LocalPort() = %d, want %d", got, port)
+	}
+
+	done := make(chan error)
+	go func() { done <- pf.Close() }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close() didn't return")
+	}
+	if got := atomic.LoadInt32(starts); got != 1 {
+		t.Errorf("Started %d forwards, want 1", got)
+	}
+	// Closing again is a no-op.
+	if err := pf.Close(); err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+}
+
+func TestPortForwarderReestablishes(t *testing.T) {
+	// The forwarding process exits immediately, like on pod restarts.
+	port, starts, cleanup := fakeForward(t, "true")
+	defer cleanup()
+
+	kube := kubefake.NewSimpleClientset(runningPod("zipkin-1"))
+	pf, err := NewPortForwarder(t.Logf, kube, "monitoring", "app=zipkin", port, 9411)
+	if err != nil {
+		t.Fatalf("NewPortForwarder() = %v", err)
+	}
+	defer pf.Close()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for atomic.LoadInt32(starts) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("The forward hasn't been re-established")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPortForwarderNoPods(t *testing.T) {
+	kube := kubefake.NewSimpleClientset()
+	pf := &PortForwarder{
+		kube:      kube,
+		namespace: "monitoring",
+		selector:  "app=zipkin",
+	}
+	if _, err := pf.runningPod(); err == nil {
+		t.Error("runningPod() = nil, wanted an error")
+	}
+}
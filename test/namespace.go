@@ -0,0 +1,137 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	"knative.dev/pkg/test/helpers"
+)
+
+const (
+	// namespaceAttempts is the number of random names tried before giving up
+	// creating a namespace.
+	namespaceAttempts = 5
+
+	// TestNameLabelKey is the label holding the name of the test a
+	// namespace has been created for.
+	TestNameLabelKey = "knative.dev/test"
+)
+
+// NamespaceOptions define the setup of a test namespace.
+type NamespaceOptions struct {
+	// Labels are added to the namespace.
+	Labels map[string]string
+
+	// Quota, if set, is the spec of a ResourceQuota created in the
+	// namespace.
+	Quota *corev1.ResourceQuotaSpec
+
+	// ServiceAccountRole, if set, is the name of the ClusterRole bound to
+	// the namespace's default service account within the namespace, e.g.
+	// "edit".
+	ServiceAccountRole string
+}
+
+// CreateNamespaceForTest creates a uniquely named namespace for the test,
+// set up as defined by opts, and registers it with the cleaner for deletion,
+// which deletes everything in it as well. It returns the namespace's name.
+func CreateNamespaceForTest(t *testing.T, kube kubernetes.Interface, cleaner *Cleaner, opts NamespaceOptions) (string, error) {
+	ns, err := createNamespace(kube, helpers.ObjectPrefixForTest(t), opts.Labels)
+	if err != nil {
+		return "", err
+	}
+	cleaner.AddTyped(kube.CoreV1().Namespaces(), ns)
+
+	if opts.Quota != nil {
+		quota := &corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-quota"},
+			Spec:       *opts.Quota,
+		}
+		if _, err := kube.CoreV1().ResourceQuotas(ns.Name).Create(quota); err != nil {
+			return ns.Name, fmt.Errorf("failed to create the quota in namespace %s: %v", ns.Name, err)
+		}
+	}
+
+	if opts.ServiceAccountRole != "" {
+		binding := &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-" + opts.ServiceAccountRole},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: rbacv1.GroupName,
+				Kind:     "ClusterRole",
+				Name:     opts.ServiceAccountRole,
+			},
+			Subjects: []rbacv1.Subject{{
+				Kind:      rbacv1.ServiceAccountKind,
+				Namespace: ns.Name,
+				Name:      "default",
+			}},
+		}
+		if _, err := kube.RbacV1().RoleBindings(ns.Name).Create(binding); err != nil {
+			return ns.Name, fmt.Errorf("failed to bind the role in namespace %s: %v", ns.Name, err)
+		}
+	}
+	return ns.Name, nil
+}
+
+// createNamespace creates a namespace with a random name starting with the
+// given prefix, trying other names if the name is already taken.
+func createNamespace(kube kubernetes.Interface, prefix string, labels map[string]string) (*corev1.Namespace, error) {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{TestNameLabelKey: namespacePrefix(prefix)},
+		},
+	}
+	for k, v := range labels {
+		ns.Labels[k] = v
+	}
+
+	var err error
+	for i := 0; i < namespaceAttempts; i++ {
+		ns.Name = helpers.AppendRandomString(namespacePrefix(prefix))
+		var created *corev1.Namespace
+		if created, err = kube.CoreV1().Namespaces().Create(ns); err == nil {
+			return created, nil
+		} else if !apierrs.IsAlreadyExists(err) {
+			break
+		}
+	}
+	return nil, fmt.Errorf("failed to create a namespace for %s: %v", prefix, err)
+}
+
+// namespacePrefix shortens the prefix so that the name generated from it is
+// a valid namespace name and label value.
+func namespacePrefix(prefix string) string {
+	// Leave room for the random suffix and its separator.
+	const maxLen = validation.DNS1123LabelMaxLength - 9
+	if len(prefix) > maxLen {
+		prefix = prefix[:maxLen]
+	}
+	prefix = strings.TrimRight(prefix, "-")
+	if prefix == "" {
+		prefix = "test"
+	}
+	return prefix
+}
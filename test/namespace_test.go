@@ -0,0 +1,109 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clientgotesting "k8s.io/client-go/testing"
+)
+
+func TestCreateNamespaceForTest(t *testing.T) {
+	kube := kubefake.NewSimpleClientset()
+	// The first name is taken.
+	taken := true
+	kube.PrependReactor("create", "namespaces", func(clientgotesting.Action) (bool, runtime.Object, error) {
+		if taken {
+			taken = false
+			return true, nil, apierrs.NewAlreadyExists(corev1.Resource("namespaces"), "taken")
+		}
+		return false, nil, nil
+	})
+
+	cleaner := NewCleaner(&fakeT{})
+	name, err := CreateNamespaceForTest(t, kube, cleaner, NamespaceOptions{
+		Labels: map[string]string{"foo": "bar"},
+		Quota: &corev1.ResourceQuotaSpec{
+			Hard: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("10")},
+		},
+		ServiceAccountRole: "edit",
+	})
+	if err != nil {
+		t.Fatalf("CreateNamespaceForTest() = %v", err)
+	}
+	if !strings.HasPrefix(name, "create-namespace-for-test-") {
+		t.Errorf("Namespace = %s, wanted a name based on the test's", name)
+	}
+
+	ns, err := kube.CoreV1().Namespaces().Get(name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get(namespace) = %v", err)
+	}
+	if got, want := ns.Labels["foo"], "bar"; got != want {
+		t.Errorf("Label foo = %q, want %q", got, want)
+	}
+	if got, want := ns.Labels[TestNameLabelKey], "create-namespace-for-test"; got != want {
+		t.Errorf("Label %s = %q, want %q", TestNameLabelKey, got, want)
+	}
+	if _, err := kube.CoreV1().ResourceQuotas(name).Get("test-quota", metav1.GetOptions{}); err != nil {
+		t.Errorf("Get(quota) = %v", err)
+	}
+	if rb, err := kube.RbacV1().RoleBindings(name).Get("test-edit", metav1.GetOptions{}); err != nil {
+		t.Errorf("Get(rolebinding) = %v", err)
+	} else if rb.RoleRef.Name != "edit" {
+		t.Errorf("RoleRef.Name = %s, want edit", rb.RoleRef.Name)
+	}
+
+	cleaner.Cleanup()
+	if _, err := kube.CoreV1().Namespaces().Get(name, metav1.GetOptions{}); !apierrs.IsNotFound(err) {
+		t.Errorf("Get(namespace) = %v after Cleanup, want NotFound", err)
+	}
+}
+
+func TestNamespacePrefix(t *testing.T) {
+	long := strings.Repeat("a", 60) + "-b"
+	tests := []struct {
+		in, want string
+	}{{
+		in:   "my-test",
+		want: "my-test",
+	}, {
+		in:   long,
+		want: strings.Repeat("a", 54),
+	}, {
+		in:   "",
+		want: "test",
+	}}
+
+	for _, test := range tests {
+		got := namespacePrefix(test.in)
+		if got != test.want {
+			t.Errorf("namespacePrefix(%q) = %q, want %q", test.in, got, test.want)
+		}
+		if errs := validation.IsDNS1123Label(got + "-abcdefgh"); len(errs) > 0 {
+			t.Errorf("namespacePrefix(%q) results in invalid names: %v", test.in, errs)
+		}
+	}
+}
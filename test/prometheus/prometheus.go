@@ -47,7 +47,7 @@ var (
 // PromProxy defines a proxy to the prometheus server
 type PromProxy struct {
 	Namespace string
-	processID int
+	forwarder *monitoring.PortForwarder
 }
 
 // Setup performs a port forwarding for app prometheus-test in given namespace
@@ -58,24 +58,22 @@ func (p *PromProxy) Setup(kubeClientset *kubernetes.Clientset, logf logging.Form
 			return
 		}
 
-		promPods, err := monitoring.GetPods(kubeClientset, appLabel, p.Namespace)
+		var err error
+		p.forwarder, err = monitoring.NewPortForwarder(logf, kubeClientset, p.Namespace, "app="+appLabel, prometheusPort, prometheusPort)
 		if err != nil {
-			logf("Error retrieving prometheus pod details: %v", err)
-			return
-		}
-
-		p.processID, err = monitoring.PortForward(logf, promPods, prometheusPort, prometheusPort, p.Namespace)
-		if err != nil {
-			logf("Error starting kubectl port-forward command: %v", err)
+			logf("Error setting up the prometheus port-forward: %v", err)
 			return
 		}
 	})
 }
 
-// Teardown will kill the port forwarding process if running.
+// Teardown will stop the port forwarding if running.
 func (p *PromProxy) Teardown(logf logging.FormatLogger) {
 	teardownOnce.Do(func() {
-		if err := monitoring.Cleanup(p.processID); err != nil {
+		if p.forwarder == nil {
+			return
+		}
+		if err := p.forwarder.Close(); err != nil {
 			logf("Encountered error killing port-forward process: %v", err)
 			return
 		}
@@ -0,0 +1,138 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package prow provides the metadata of the Prow job tests run in, as
+// described in https://github.com/kubernetes/test-infra/blob/master/prow/jobs.md#job-environment-variables,
+// with fallbacks for local runs.
+package prow
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// JobType is the type of a Prow job.
+type JobType string
+
+const (
+	// Presubmit jobs run against pull requests.
+	Presubmit JobType = "presubmit"
+	// Postsubmit jobs run after merges.
+	Postsubmit JobType = "postsubmit"
+	// Periodic jobs run on a schedule.
+	Periodic JobType = "periodic"
+	// Batch jobs run against several pull requests at once.
+	Batch JobType = "batch"
+	// Local is the type of runs outside of Prow.
+	Local JobType = "local"
+
+	// defaultArtifactsDir is the artifacts directory of local runs.
+	defaultArtifactsDir = "artifacts"
+)
+
+// Job is the metadata of a Prow job.
+type Job struct {
+	// Name is the job's name, "local" for local runs.
+	Name string
+	// Type is the job's type, Local for local runs.
+	Type JobType
+	// BuildID identifies the run of the job, empty for local runs.
+	BuildID string
+	// ProwJobID identifies the ProwJob resource of the run.
+	ProwJobID string
+
+	// RepoOwner and RepoName identify the repository tested, empty for
+	// periodic jobs and local runs.
+	RepoOwner string
+	RepoName  string
+	// BaseRef and BaseSHA are the tested branch and its commit.
+	BaseRef string
+	BaseSHA string
+	// PullNumber and PullSHA identify the tested pull request of presubmit
+	// jobs; PullNumber is zero otherwise.
+	PullNumber int
+	PullSHA    string
+
+	// ArtifactsDir is the directory whose contents are uploaded when the
+	// job finishes, "artifacts" for local runs.
+	ArtifactsDir string
+}
+
+// GetJob returns the metadata of the Prow job the process runs in, read
+// from the environment.
+func GetJob() *Job {
+	j := &Job{
+		Name:         os.Getenv("JOB_NAME"),
+		Type:         JobType(os.Getenv("JOB_TYPE")),
+		BuildID:      os.Getenv("BUILD_ID"),
+		ProwJobID:    os.Getenv("PROW_JOB_ID"),
+		RepoOwner:    os.Getenv("REPO_OWNER"),
+		RepoName:     os.Getenv("REPO_NAME"),
+		BaseRef:      os.Getenv("PULL_BASE_REF"),
+		BaseSHA:      os.Getenv("PULL_BASE_SHA"),
+		PullSHA:      os.Getenv("PULL_PULL_SHA"),
+		ArtifactsDir: os.Getenv("ARTIFACTS"),
+	}
+	if j.BuildID == "" {
+		// Older jobs set BUILD_NUMBER instead.
+		j.BuildID = os.Getenv("BUILD_NUMBER")
+	}
+	if n, err := strconv.Atoi(os.Getenv("PULL_NUMBER")); err == nil {
+		j.PullNumber = n
+	}
+
+	if j.Name == "" {
+		j.Name = string(Local)
+	}
+	if j.Type == "" {
+		j.Type = Local
+	}
+	if j.ArtifactsDir == "" {
+		j.ArtifactsDir = defaultArtifactsDir
+	}
+	return j
+}
+
+// IsCI returns true if the process runs in a Prow job.
+func (j *Job) IsCI() bool {
+	return j.Type != Local
+}
+
+// Repo returns the tested repository as "owner/name", or an empty string
+// if none is tested.
+func (j *Job) Repo() string {
+	if j.RepoOwner == "" || j.RepoName == "" {
+		return ""
+	}
+	return j.RepoOwner + "/" + j.RepoName
+}
+
+// String describes the job run, e.g. "pull-knative-serving-build-tests #1234
+// (knative/serving#567)".
+func (j *Job) String() string {
+	if !j.IsCI() {
+		return "local run"
+	}
+	s := j.Name
+	if j.BuildID != "" {
+		s += " #" + j.BuildID
+	}
+	if repo := j.Repo(); repo != "" && j.PullNumber != 0 {
+		s += fmt.Sprintf(" (%s#%d)", repo, j.PullNumber)
+	}
+	return s
+}
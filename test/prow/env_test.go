@@ -0,0 +1,124 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prow
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var envKeys = []string{
+	"JOB_NAME", "JOB_TYPE", "BUILD_ID", "BUILD_NUMBER", "PROW_JOB_ID", "REPO_OWNER", "REPO_NAME",
+	"PULL_BASE_REF", "PULL_BASE_SHA", "PULL_NUMBER", "PULL_PULL_SHA", "ARTIFACTS",
+}
+
+func setEnv(t *testing.T, env map[string]string) func() {
+	saved := make(map[string]string)
+	for _, k := range envKeys {
+		if v, ok := os.LookupEnv(k); ok {
+			saved[k] = v
+		}
+		os.Unsetenv(k)
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	return func() {
+		for _, k := range envKeys {
+			os.Unsetenv(k)
+		}
+		for k, v := range saved {
+			os.Setenv(k, v)
+		}
+	}
+}
+
+func TestGetJob(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		want       *Job
+		wantString string
+	}{{
+		name: "local",
+		want: &Job{
+			Name:         "local",
+			Type:         Local,
+			ArtifactsDir: "artifacts",
+		},
+		wantString: "local run",
+	}, {
+		name: "presubmit",
+		env: map[string]string{
+			"JOB_NAME":      "pull-knative-serving-build-tests",
+			"JOB_TYPE":      "presubmit",
+			"BUILD_ID":      "1234",
+			"PROW_JOB_ID":   "abcd",
+			"REPO_OWNER":    "knative",
+			"REPO_NAME":     "serving",
+			"PULL_BASE_REF": "master",
+			"PULL_BASE_SHA": "base",
+			"PULL_NUMBER":   "567",
+			"PULL_PULL_SHA": "pull",
+			"ARTIFACTS":     "/logs/artifacts",
+		},
+		want: &Job{
+			Name:         "pull-knative-serving-build-tests",
+			Type:         Presubmit,
+			BuildID:      "1234",
+			ProwJobID:    "abcd",
+			RepoOwner:    "knative",
+			RepoName:     "serving",
+			BaseRef:      "master",
+			BaseSHA:      "base",
+			PullNumber:   567,
+			PullSHA:      "pull",
+			ArtifactsDir: "/logs/artifacts",
+		},
+		wantString: "pull-knative-serving-build-tests #1234 (knative/serving#567)",
+	}, {
+		name: "periodic with a build number",
+		env: map[string]string{
+			"JOB_NAME":     "ci-knative-serving-continuous",
+			"JOB_TYPE":     "periodic",
+			"BUILD_NUMBER": "42",
+		},
+		want: &Job{
+			Name:         "ci-knative-serving-continuous",
+			Type:         Periodic,
+			BuildID:      "42",
+			ArtifactsDir: "artifacts",
+		},
+		wantString: "ci-knative-serving-continuous #42",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer setEnv(t, test.env)()
+
+			got := GetJob()
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("GetJob() (-want, +got) = %s", diff)
+			}
+			if s := got.String(); s != test.wantString {
+				t.Errorf("String() = %q, want %q", s, test.wantString)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quarantine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// Entry is a quarantined test.
+type Entry struct {
+	// Name is the name of the test as reported by testing.T.Name, e.g.
+	// "TestAutoscaleUpDown" or "TestProbe/http2". Quarantining a test
+	// quarantines its subtests as well.
+	Name string `json:"name"`
+	// Reason is why the test is quarantined.
+	Reason string `json:"reason"`
+	// Issue is the URL of the issue tracking the fix of the test, if any.
+	Issue string `json:"issue,omitempty"`
+}
+
+// List is the list of the quarantined tests, e.g.
+//
+//	tests:
+//	- name: TestAutoscaleUpDown
+//	  reason: Times out when the nodes are scaled up.
+//	  issue: https://github.com/knative/serving/issues/1234
+type List struct {
+	Tests []Entry `json:"tests"`
+}
+
+// Load reads the list of quarantined tests from the given YAML or JSON file.
+func Load(path string) (*List, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	l := &List{}
+	if err := yaml.Unmarshal(b, l); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if err := l.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid quarantine list %s: %v", path, err)
+	}
+	return l, nil
+}
+
+// Validate checks that every test is named once and has a reason to be
+// quarantined, so that none stays quarantined without anyone knowing why.
+func (l *List) Validate() error {
+	seen := make(map[string]bool, len(l.Tests))
+	for i, e := range l.Tests {
+		switch {
+		case e.Name == "":
+			return fmt.Errorf("tests[%d]: missing name", i)
+		case seen[e.Name]:
+			return fmt.Errorf("tests[%d]: %s is listed twice", i, e.Name)
+		case strings.TrimSpace(e.Reason) == "":
+			return fmt.Errorf("tests[%d]: %s has no reason", i, e.Name)
+		}
+		seen[e.Name] = true
+	}
+	return nil
+}
+
+// Lookup returns the entry quarantining the named test, i.e. the one of the
+// test itself or the closest one of its parents.
+func (l *List) Lookup(name string) (Entry, bool) {
+	if l == nil {
+		return Entry{}, false
+	}
+	var (
+		found Entry
+		ok    bool
+	)
+	for _, e := range l.Tests {
+		if (e.Name == name || strings.HasPrefix(name, e.Name+"/")) && len(e.Name) > len(found.Name) {
+			found, ok = e, true
+		}
+	}
+	return found, ok
+}
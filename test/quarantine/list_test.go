@@ -0,0 +1,148 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quarantine
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var (
+	flaky = Entry{
+		Name:   "TestFlaky",
+		Reason: "Times out when the nodes are scaled up.",
+		Issue:  "https://github.com/knative/pkg/issues/1234",
+	}
+	http2 = Entry{
+		Name:   "TestProbe/http2",
+		Reason: "The ingress drops HTTP/2 connections.",
+	}
+)
+
+func TestLoad(t *testing.T) {
+	l, err := Load("testdata/quarantine.yaml")
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if diff := cmp.Diff(&List{Tests: []Entry{flaky, http2}}, l); diff != "" {
+		t.Errorf("Load (-want, +got) = %s", diff)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{{
+		name:    "not yaml",
+		content: "tests: [",
+		want:    "failed to parse",
+	}, {
+		name:    "missing name",
+		content: "tests:\n- reason: flaky",
+		want:    "tests[0]: missing name",
+	}, {
+		name:    "missing reason",
+		content: "tests:\n- name: TestFlaky",
+		want:    "tests[0]: TestFlaky has no reason",
+	}, {
+		name:    "listed twice",
+		content: "tests:\n- name: TestFlaky\n  reason: flaky\n- name: TestFlaky\n  reason: flaky",
+		want:    "tests[1]: TestFlaky is listed twice",
+	}}
+
+	dir, err := ioutil.TempDir("", "quarantine")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, "quarantine.yaml")
+			if err := ioutil.WriteFile(path, []byte(test.content), 0644); err != nil {
+				t.Fatalf("WriteFile() = %v", err)
+			}
+			if _, err := Load(path); err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("Load() = %v, want an error containing %q", err, test.want)
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("Load(missing.yaml) = nil, want an error")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	l := &List{Tests: []Entry{
+		flaky,
+		http2,
+		{Name: "TestFlaky/sub", Reason: "Flakier than its parent."},
+	}}
+
+	tests := []struct {
+		name   string
+		want   Entry
+		wantOK bool
+	}{{
+		name:   "TestFlaky",
+		want:   flaky,
+		wantOK: true,
+	}, {
+		name:   "TestFlaky/other",
+		want:   flaky,
+		wantOK: true,
+	}, {
+		name:   "TestFlaky/sub/case",
+		want:   l.Tests[2],
+		wantOK: true,
+	}, {
+		name:   "TestProbe/http2",
+		want:   http2,
+		wantOK: true,
+	}, {
+		name: "TestProbe",
+	}, {
+		name: "TestProbe/http1",
+	}, {
+		name: "TestFlakyToo",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := l.Lookup(test.name)
+			if ok != test.wantOK {
+				t.Errorf("Lookup() = %v, want %v", ok, test.wantOK)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Lookup (-want, +got) = %s", diff)
+			}
+		})
+	}
+
+	var nilList *List
+	if _, ok := nilList.Lookup("TestFlaky"); ok {
+		t.Error("Lookup() on a nil list = true, want false")
+	}
+}
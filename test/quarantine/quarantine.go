@@ -0,0 +1,363 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package quarantine isolates known-flaky e2e tests without skipping them:
+// the tests listed in a quarantine file still run, but their failures are
+// logged and reported apart, in the JUnit results and the JSON summary of the
+// quarantine suite in the artifacts directory, rather than failing the suite.
+package quarantine
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"knative.dev/pkg/test"
+	"knative.dev/pkg/test/junit"
+)
+
+// DefaultSuite is the name of the suite the results of the quarantined tests
+// are written as, i.e. junit_quarantine.xml and quarantine.json.
+const DefaultSuite = "quarantine"
+
+// Flags holds the quarantine flags.
+var Flags = struct {
+	// File is the path of the quarantine list, see Load.
+	File string
+}{}
+
+func init() {
+	test.RegisterFlags("Quarantine flags", func(fs *flag.FlagSet) {
+		fs.StringVar(&Flags.File, "quarantine", "",
+			"Provide the path of the YAML file listing the quarantined tests, whose failures don't fail the suite.")
+	}, func() error {
+		_, err := Default()
+		return err
+	})
+}
+
+// T is the subset of testing.TB the body of a test run by Quarantine.Run can
+// use. It is implemented by *testing.T, and satisfies test.TestingT.
+type T interface {
+	Name() string
+	Helper()
+	Log(args ...interface{})
+	Logf(format string, args ...interface{})
+	Error(args ...interface{})
+	Errorf(format string, args ...interface{})
+	Fatal(args ...interface{})
+	Fatalf(format string, args ...interface{})
+	Fail()
+	FailNow()
+	Failed() bool
+	Skip(args ...interface{})
+	Skipf(format string, args ...interface{})
+	SkipNow()
+	Skipped() bool
+}
+
+// Quarantine runs tests, reporting the failures of the quarantined ones
+// apart. It is safe for concurrent use.
+type Quarantine struct {
+	list     *List
+	recorder *junit.Recorder
+}
+
+// New returns a Quarantine for the tests of the given list, which can be
+// nil, recording their results as the named suite.
+func New(list *List, suite string) *Quarantine {
+	return &Quarantine{
+		list:     list,
+		recorder: junit.NewRecorder(suite),
+	}
+}
+
+// Run runs the body of the test. If the test is quarantined, the body runs
+// on a T which logs its failures instead of failing the test, and its result
+// is recorded along with the reason and the issue of the quarantine, so that
+// it can be tracked and taken out of quarantine once it passes reliably.
+// Otherwise the body runs on t itself. Use it as:
+//
+//	func TestAutoscaleUpDown(t *testing.T) {
+//		quarantine.Run(t, func(t quarantine.T) {
+//			...
+//		})
+//	}
+//
+// The subtests calling Run are quarantined along with their test.
+func (q *Quarantine) Run(t *testing.T, fn func(T)) {
+	t.Helper()
+	e, ok := q.list.Lookup(t.Name())
+	if !ok {
+		fn(t)
+		return
+	}
+
+	props := map[string]string{"quarantined": "true", "reason": e.Reason}
+	if e.Issue != "" {
+		props["issue"] = e.Issue
+	}
+	qt := &quarantinedT{t: t}
+	start := time.Now()
+	qt.run(fn)
+	d := time.Since(start)
+
+	switch {
+	case qt.Failed():
+		msg := qt.message()
+		q.recorder.Quarantine(t.Name(), msg, d, props)
+		t.Logf("Ignoring the failure of the quarantined test (%s%s):\n%s", e.Reason, issueSuffix(e.Issue), msg)
+	case qt.Skipped():
+		q.recorder.Skip(t.Name(), qt.skipMessage)
+		t.Skip(qt.skipMessage)
+	default:
+		q.recorder.Pass(t.Name(), d, props)
+		t.Logf("The quarantined test passed (%s%s)", e.Reason, issueSuffix(e.Issue))
+	}
+}
+
+func issueSuffix(issue string) string {
+	if issue == "" {
+		return ""
+	}
+	return ", see " + issue
+}
+
+// Summary returns the JSON summary of the results of the quarantined tests.
+func (q *Quarantine) Summary() junit.Summary {
+	return q.recorder.Summary()
+}
+
+// Write writes the results of the quarantined tests to the artifacts
+// directory, see WriteTo.
+func (q *Quarantine) Write() error {
+	return q.WriteTo(test.ArtifactsDir())
+}
+
+// WriteTo writes the results of the quarantined tests as the JUnit results
+// and the JSON summary of the suite to the given directory, see
+// junit.Recorder.WriteTo. It writes nothing if there is no quarantine list.
+func (q *Quarantine) WriteTo(dir string) error {
+	if q.list == nil {
+		return nil
+	}
+	return q.recorder.WriteTo(dir)
+}
+
+var (
+	defaultOnce       sync.Once
+	defaultQuarantine *Quarantine
+	defaultErr        error
+)
+
+// Default returns the Quarantine of the list of the --quarantine flag, or of
+// no tests if the flag isn't set, recording their results as DefaultSuite.
+// It must be called once the flags are parsed.
+func Default() (*Quarantine, error) {
+	defaultOnce.Do(func() {
+		var list *List
+		if Flags.File != "" {
+			if list, defaultErr = Load(Flags.File); defaultErr != nil {
+				return
+			}
+		}
+		defaultQuarantine = New(list, DefaultSuite)
+	})
+	return defaultQuarantine, defaultErr
+}
+
+// Run runs the body of the test through the Default Quarantine, see
+// Quarantine.Run. It fails the test if the quarantine list can't be loaded.
+func Run(t *testing.T, fn func(T)) {
+	t.Helper()
+	q, err := Default()
+	if err != nil {
+		t.Fatalf("Failed to load the quarantine list: %v", err)
+	}
+	q.Run(t, fn)
+}
+
+// Write writes the results of the quarantined tests run by Run to the
+// artifacts directory. Call it in TestMain once the tests ran, e.g.
+//
+//	func TestMain(m *testing.M) {
+//		flag.Parse()
+//		code := m.Run()
+//		if err := quarantine.Write(); err != nil {
+//			log.Printf("Failed to write the quarantine results: %v", err)
+//		}
+//		os.Exit(code)
+//	}
+func Write() error {
+	q, err := Default()
+	if err != nil {
+		return err
+	}
+	return q.Write()
+}
+
+// quarantinedT is the T of a quarantined test. It logs the failures on the
+// test instead of failing it, and records them.
+type quarantinedT struct {
+	t *testing.T
+
+	mu          sync.Mutex
+	failed      bool
+	failures    []string
+	skipped     bool
+	skipMessage string
+}
+
+var _ T = (*quarantinedT)(nil)
+
+// run runs the body of the test in its own goroutine, like testing.T.Run, so
+// that FailNow and SkipNow can stop it, and turns its panics into failures.
+func (qt *quarantinedT) run(fn func(T)) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			if r := recover(); r != nil {
+				qt.fail(fmt.Sprintf("panic: %v\n%s", r, debug.Stack()))
+			}
+		}()
+		fn(qt)
+	}()
+	<-done
+}
+
+func (qt *quarantinedT) fail(msg string) {
+	qt.mu.Lock()
+	defer qt.mu.Unlock()
+	qt.failed = true
+	if msg != "" {
+		qt.failures = append(qt.failures, msg)
+	}
+}
+
+// message returns the failures of the test.
+func (qt *quarantinedT) message() string {
+	qt.mu.Lock()
+	defer qt.mu.Unlock()
+	if len(qt.failures) == 0 {
+		return "failed"
+	}
+	return strings.Join(qt.failures, "\n")
+}
+
+// Name implements T.
+func (qt *quarantinedT) Name() string {
+	return qt.t.Name()
+}
+
+// Helper implements T. It is a no-op, as the helpers can't be marked on
+// behalf of the caller.
+func (qt *quarantinedT) Helper() {}
+
+// Log implements T.
+func (qt *quarantinedT) Log(args ...interface{}) {
+	qt.t.Helper()
+	qt.t.Log(args...)
+}
+
+// Logf implements T.
+func (qt *quarantinedT) Logf(format string, args ...interface{}) {
+	qt.t.Helper()
+	qt.t.Logf(format, args...)
+}
+
+// Error implements T.
+func (qt *quarantinedT) Error(args ...interface{}) {
+	qt.t.Helper()
+	msg := strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+	qt.t.Log("[quarantined] " + msg)
+	qt.fail(msg)
+}
+
+// Errorf implements T.
+func (qt *quarantinedT) Errorf(format string, args ...interface{}) {
+	qt.t.Helper()
+	msg := fmt.Sprintf(format, args...)
+	qt.t.Log("[quarantined] " + msg)
+	qt.fail(msg)
+}
+
+// Fatal implements T.
+func (qt *quarantinedT) Fatal(args ...interface{}) {
+	qt.t.Helper()
+	qt.Error(args...)
+	runtime.Goexit()
+}
+
+// Fatalf implements T.
+func (qt *quarantinedT) Fatalf(format string, args ...interface{}) {
+	qt.t.Helper()
+	qt.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+// Fail implements T.
+func (qt *quarantinedT) Fail() {
+	qt.fail("")
+}
+
+// FailNow implements T.
+func (qt *quarantinedT) FailNow() {
+	qt.fail("")
+	runtime.Goexit()
+}
+
+// Failed implements T.
+func (qt *quarantinedT) Failed() bool {
+	qt.mu.Lock()
+	defer qt.mu.Unlock()
+	return qt.failed
+}
+
+// Skip implements T.
+func (qt *quarantinedT) Skip(args ...interface{}) {
+	qt.skip(strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+}
+
+// Skipf implements T.
+func (qt *quarantinedT) Skipf(format string, args ...interface{}) {
+	qt.skip(fmt.Sprintf(format, args...))
+}
+
+// SkipNow implements T.
+func (qt *quarantinedT) SkipNow() {
+	qt.skip("")
+}
+
+func (qt *quarantinedT) skip(msg string) {
+	qt.mu.Lock()
+	qt.skipped = true
+	qt.skipMessage = msg
+	qt.mu.Unlock()
+	runtime.Goexit()
+}
+
+// Skipped implements T.
+func (qt *quarantinedT) Skipped() bool {
+	qt.mu.Lock()
+	defer qt.mu.Unlock()
+	return qt.skipped
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quarantine
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"knative.dev/pkg/test"
+	"knative.dev/pkg/test/junit"
+)
+
+func TestRun(t *testing.T) {
+	q := New(&List{Tests: []Entry{{
+		Name:   "TestRun",
+		Reason: "flaky",
+		Issue:  "https://github.com/knative/pkg/issues/1234",
+	}}}, "quarantine")
+	props := map[string]string{
+		"quarantined": "true",
+		"reason":      "flaky",
+		"issue":       "https://github.com/knative/pkg/issues/1234",
+	}
+
+	tests := []struct {
+		name string
+		body func(T)
+		want junit.Result
+	}{{
+		name: "errors",
+		body: func(t T) {
+			t.Errorf("first %d", 1)
+			t.Error("second", 2)
+			t.Log("still running")
+		},
+		want: junit.Result{Status: junit.StatusQuarantined, Message: "first 1\nsecond 2"},
+	}, {
+		name: "fatal",
+		body: func(t T) {
+			t.Fatal("stopped")
+			t.Error("not reached")
+		},
+		want: junit.Result{Status: junit.StatusQuarantined, Message: "stopped"},
+	}, {
+		name: "fail now",
+		body: func(t T) {
+			t.FailNow()
+		},
+		want: junit.Result{Status: junit.StatusQuarantined, Message: "failed"},
+	}, {
+		name: "panic",
+		body: func(t T) {
+			panic("boom")
+		},
+		want: junit.Result{Status: junit.StatusQuarantined, Message: "panic: boom"},
+	}, {
+		name: "pass",
+		body: func(t T) {},
+		want: junit.Result{Status: junit.StatusPassed},
+	}, {
+		name: "skip",
+		body: func(t T) {
+			t.Skipf("not %s", "supported")
+		},
+		want: junit.Result{Status: junit.StatusSkipped, Message: "not supported"},
+	}}
+
+	for _, tc := range tests {
+		// The subtest exits when it is skipped, so check its result here.
+		var name string
+		if !t.Run(tc.name, func(t *testing.T) {
+			name = t.Name()
+			q.Run(t, tc.body)
+		}) {
+			t.Errorf("%s: failed, want the failure ignored", tc.name)
+		}
+
+		results := q.Summary().Results
+		got := results[len(results)-1]
+		want := tc.want
+		want.Name = name
+		if want.Status != junit.StatusSkipped {
+			want.Properties = props
+		}
+		if diff := cmp.Diff(want, got,
+			cmpopts.IgnoreFields(junit.Result{}, "Duration"),
+			cmp.Comparer(func(a, b string) bool {
+				// The message of a panic is followed by its stack.
+				return a == b || strings.HasPrefix(a, b+"\n") || strings.HasPrefix(b, a+"\n")
+			})); diff != "" {
+			t.Errorf("%s: Result (-want, +got) = %s", tc.name, diff)
+		}
+	}
+
+	if got, want := len(q.Summary().Results), len(tests); got != want {
+		t.Errorf("Recorded %d results, want %d", got, want)
+	}
+	if got := q.Summary().Failures; got != 0 {
+		t.Errorf("Failures = %d, want 0", got)
+	}
+	if got := q.Summary().Quarantined; got != 4 {
+		t.Errorf("Quarantined = %d, want 4", got)
+	}
+}
+
+func TestRunNotQuarantined(t *testing.T) {
+	q := New(&List{Tests: []Entry{{Name: "TestOther", Reason: "flaky"}}}, "quarantine")
+
+	var got T
+	q.Run(t, func(t T) { got = t })
+	if _, ok := got.(*testing.T); !ok {
+		t.Errorf("Run() ran on %T, want *testing.T", got)
+	}
+	if n := len(q.Summary().Results); n != 0 {
+		t.Errorf("Recorded %d results, want none", n)
+	}
+}
+
+func TestQuarantinedTIsTestingT(t *testing.T) {
+	// The cleaner and the dumps can be used by the quarantined tests.
+	var _ test.TestingT = (*quarantinedT)(nil)
+}
+
+func TestWriteTo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "quarantine")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Nothing is written without a list.
+	if err := New(nil, DefaultSuite).WriteTo(dir); err != nil {
+		t.Fatalf("WriteTo() = %v", err)
+	}
+	if files, err := ioutil.ReadDir(dir); err != nil || len(files) != 0 {
+		t.Errorf("ReadDir() = %v, %v, want no files", files, err)
+	}
+
+	q := New(&List{Tests: []Entry{{Name: "TestWriteTo", Reason: "flaky"}}}, DefaultSuite)
+	q.Run(t, func(t T) { t.Error("flaked") })
+	if err := q.WriteTo(dir); err != nil {
+		t.Fatalf("WriteTo() = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "junit_quarantine.xml")); err != nil {
+		t.Errorf("Stat(junit_quarantine.xml) = %v", err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "quarantine.json"))
+	if err != nil {
+		t.Fatalf("ReadFile() = %v", err)
+	}
+	var summary junit.Summary
+	if err := json.Unmarshal(b, &summary); err != nil {
+		t.Fatalf("json.Unmarshal() = %v", err)
+	}
+	if summary.Quarantined != 1 || summary.Failures != 0 {
+		t.Errorf("Summary = %d quarantined, %d failures, want 1, 0", summary.Quarantined, summary.Failures)
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// ready contains functions which wait for arbitrary resources following
+// the Knative conventions to become ready, by reading them through the
+// KResource duck type.
+
+package test
+
+import (
+	"fmt"
+	"time"
+
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"knative.dev/pkg/test/logging"
+)
+
+// ConditionState is the state of a resource's condition, as observed while
+// waiting for it.
+type ConditionState struct {
+	// Generation is the generation of the resource's spec.
+	Generation int64 `json:"generation"`
+	// ObservedGeneration is the generation the resource's status reflects.
+	ObservedGeneration int64 `json:"observedGeneration"`
+	// Condition is the observed condition, nil if it isn't set.
+	Condition *apis.Condition `json:"condition"`
+}
+
+// WaitForReady polls the resource of the given type called name every
+// interval until its Ready condition is True for its current generation.
+// If that doesn't happen within the timeout, the returned *TimeoutError
+// carries the last observed state of the Ready condition, including its
+// reason and message.
+func WaitForReady(logf logging.FormatLogger, client dynamic.Interface, gvr schema.GroupVersionResource,
+	name, namespace string, timeout time.Duration) error {
+	return WaitForCondition(logf, client, gvr, name, namespace, apis.ConditionReady, timeout)
+}
+
+// WaitForCondition is like WaitForReady for an arbitrary condition type.
+// Resources which don't exist yet are waited for as well.
+func WaitForCondition(logf logging.FormatLogger, client dynamic.Interface, gvr schema.GroupVersionResource,
+	name, namespace string, ct apis.ConditionType, timeout time.Duration) error {
+	desc := fmt.Sprintf("%s %s/%s to become %s", gvr.GroupResource(), namespace, name, ct)
+	return Eventually(logf, desc, interval, timeout, func() (interface{}, bool, error) {
+		kr, err := getKResource(client, gvr, name, namespace)
+		if apierrs.IsNotFound(err) {
+			return "not found", false, nil
+		} else if err != nil {
+			return nil, true, err
+		}
+		state := ConditionState{
+			Generation:         kr.Generation,
+			ObservedGeneration: kr.Status.ObservedGeneration,
+			Condition:          kr.Status.GetCondition(ct),
+		}
+		return state, state.isTrue(), nil
+	})
+}
+
+// isTrue returns whether the condition is True and reflects the current
+// generation of the resource.
+func (s ConditionState) isTrue() bool {
+	return s.Condition != nil && s.Condition.IsTrue() && s.ObservedGeneration >= s.Generation
+}
+
+// getKResource fetches the resource and converts it to the KResource duck.
+func getKResource(client dynamic.Interface, gvr schema.GroupVersionResource, name, namespace string) (*duckv1.KResource, error) {
+	u, err := client.Resource(gvr).Namespace(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	kr := &duckv1.KResource{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, kr); err != nil {
+		return nil, fmt.Errorf("failed to convert %s %s/%s to a KResource: %w", gvr.GroupResource(), namespace, name, err)
+	}
+	return kr, nil
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+var widgetsGVR = schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+
+func readyWidget(generation, observed int64, status, reason, message string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{
+			"observedGeneration": observed,
+			"conditions": []interface{}{
+				map[string]interface{}{
+					"type":    "Ready",
+					"status":  status,
+					"reason":  reason,
+					"message": message,
+				},
+			},
+		},
+	}}
+	u.SetAPIVersion("example.com/v1")
+	u.SetKind("Widget")
+	u.SetNamespace("ns")
+	u.SetName("widget")
+	u.SetGeneration(generation)
+	return u
+}
+
+func TestWaitForReady(t *testing.T) {
+	tests := []struct {
+		name     string
+		objects  []runtime.Object
+		wantErrs []string
+	}{{
+		name:    "ready",
+		objects: []runtime.Object{readyWidget(2, 2, "True", "", "")},
+	}, {
+		name:     "not ready",
+		objects:  []runtime.Object{readyWidget(2, 2, "False", "Broken", "the widget is broken")},
+		wantErrs: []string{"widgets.example.com ns/widget to become Ready", "Broken", "the widget is broken"},
+	}, {
+		name:     "stale generation",
+		objects:  []runtime.Object{readyWidget(3, 2, "True", "", "")},
+		wantErrs: []string{`"generation": 3`, `"observedGeneration": 2`},
+	}, {
+		name:     "not found",
+		wantErrs: []string{"not found"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), test.objects...)
+			err := WaitForReady(t.Logf, client, widgetsGVR, "widget", "ns", 10*time.Millisecond)
+			if len(test.wantErrs) == 0 {
+				if err != nil {
+					t.Fatalf("WaitForReady() = %v", err)
+				}
+				return
+			}
+			var te *TimeoutError
+			if !errors.As(err, &te) {
+				t.Fatalf("WaitForReady() = %v, want a *TimeoutError", err)
+			}
+			for _, want := range test.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("WaitForReady() = %v, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
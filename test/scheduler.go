@@ -0,0 +1,118 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"sync"
+	"testing"
+)
+
+// Resources are the cluster resources a test declares to use.
+type Resources struct {
+	// Pods is the number of pods the test runs at most.
+	Pods int
+	// Exclusive tests, like ones mutating cluster-scoped resources, run
+	// while no other test scheduled by the same Scheduler runs.
+	Exclusive bool
+}
+
+// Scheduler limits the tests running concurrently by the resources they
+// declare, rather than by their number like -parallel does. Tests are
+// admitted in the order they ask to run, so that heavy and exclusive tests
+// don't starve. Use it as:
+//
+//	func TestFoo(t *testing.T) {
+//		defer scheduler.Run(t, test.Resources{Pods: 3})()
+//		...
+//	}
+type Scheduler struct {
+	capacity int
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	pods      int
+	running   int
+	exclusive bool
+	// next is the ticket of the next test to be admitted, last the one of
+	// the last test asking to run.
+	next, last uint64
+}
+
+// NewScheduler returns a Scheduler running tests using up to the given
+// number of pods at a time.
+func NewScheduler(podCapacity int) *Scheduler {
+	s := &Scheduler{capacity: podCapacity}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// Run marks the test as parallel and blocks until the resources it declares
+// are available. The returned function releases them and must be called when
+// the test is done. Tests declaring more pods than the capacity run alone.
+func (s *Scheduler) Run(t *testing.T, r Resources) func() {
+	t.Parallel()
+	if r.Pods > s.capacity {
+		t.Logf("Test declares %d pods, more than the capacity of %d, running it alone", r.Pods, s.capacity)
+		r.Pods = s.capacity
+	}
+	return s.acquire(r)
+}
+
+// acquire blocks until the resources are available and returns the function
+// releasing them.
+func (s *Scheduler) acquire(r Resources) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ticket := s.last
+	s.last++
+	for ticket != s.next || !s.fits(r) {
+		s.cond.Wait()
+	}
+	s.next++
+	s.pods += r.Pods
+	s.running++
+	s.exclusive = r.Exclusive
+	// Let the next test check whether it fits, too.
+	s.cond.Broadcast()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.pods -= r.Pods
+			s.running--
+			if r.Exclusive {
+				s.exclusive = false
+			}
+			s.cond.Broadcast()
+		})
+	}
+}
+
+// fits returns true if a test with the given resources can run now. It's
+// called with the lock held.
+func (s *Scheduler) fits(r Resources) bool {
+	if s.exclusive {
+		return false
+	}
+	if r.Exclusive {
+		return s.running == 0
+	}
+	return s.pods+r.Pods <= s.capacity
+}
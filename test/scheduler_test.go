@@ -0,0 +1,137 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// usage tracks the resources in use by the running tests.
+type usage struct {
+	mu        sync.Mutex
+	pods      int
+	maxPods   int
+	running   int
+	exclusive bool
+	violation string
+}
+
+func (u *usage) start(r Resources) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.exclusive || (r.Exclusive && u.running > 0) {
+		u.violation = "an exclusive test ran concurrently with another test"
+	}
+	u.pods += r.Pods
+	u.running++
+	u.exclusive = r.Exclusive
+	if u.pods > u.maxPods {
+		u.maxPods = u.pods
+	}
+}
+
+func (u *usage) stop(r Resources) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.pods -= r.Pods
+	u.running--
+	u.exclusive = false
+}
+
+func TestScheduler(t *testing.T) {
+	const capacity = 5
+	s := NewScheduler(capacity)
+	u := &usage{}
+
+	resources := []Resources{
+		{Pods: 3}, {Pods: 2}, {Pods: 4}, {Exclusive: true},
+		{Pods: 1}, {Pods: 5}, {Pods: 2, Exclusive: true}, {Pods: 10},
+	}
+	t.Run("group", func(t *testing.T) {
+		for i, r := range resources {
+			r := r
+			t.Run(fmt.Sprint(i), func(t *testing.T) {
+				defer s.Run(t, r)()
+				if r.Pods > capacity {
+					r.Pods = capacity
+				}
+				u.start(r)
+				defer u.stop(r)
+				time.Sleep(5 * time.Millisecond)
+			})
+		}
+	})
+
+	if u.maxPods > capacity {
+		t.Errorf("Max pods = %d, want at most %d", u.maxPods, capacity)
+	}
+	if u.violation != "" {
+		t.Error(u.violation)
+	}
+}
+
+func TestSchedulerOrder(t *testing.T) {
+	s := NewScheduler(2)
+	release := s.acquire(Resources{Pods: 2})
+
+	// A heavy test asking first is admitted before a light one asking later,
+	// even though the light one would fit earlier.
+	var (
+		mu    sync.Mutex
+		order []string
+		wg    sync.WaitGroup
+	)
+	run := func(name string, r Resources) {
+		defer wg.Done()
+		rel := s.acquire(r)
+		mu.Lock()
+		order = append(order, name)
+		mu.Unlock()
+		rel()
+	}
+	wg.Add(1)
+	go run("heavy", Resources{Pods: 2})
+	waitForTickets(s, 2)
+	wg.Add(1)
+	go run("light", Resources{Pods: 1})
+	waitForTickets(s, 3)
+
+	release()
+	// Releasing twice is a no-op.
+	release()
+	wg.Wait()
+
+	if len(order) != 2 || order[0] != "heavy" {
+		t.Errorf("Order = %v, want heavy first", order)
+	}
+}
+
+// waitForTickets waits until the given number of tests asked to run.
+func waitForTickets(s *Scheduler, n uint64) {
+	for {
+		s.mu.Lock()
+		last := s.last
+		s.mu.Unlock()
+		if last >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
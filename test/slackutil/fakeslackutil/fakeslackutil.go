@@ -19,19 +19,25 @@ limitations under the License.
 package fakeslackutil
 
 import (
+	"fmt"
 	"sync"
 	"time"
+
+	"knative.dev/pkg/test/slackutil"
 )
 
 type messageEntry struct {
 	text     string
 	sentTime time.Time
+	ts       string
+	replies  []messageEntry
 }
 
 // FakeSlackClient is a faked client, implements all functions of slackutil.ReadOperations and slackutil.WriteOperations
 type FakeSlackClient struct {
 	History map[string][]messageEntry
 	mutex   sync.RWMutex
+	count   int
 }
 
 // NewFakeSlackClient creates a FakeSlackClient and initialize it's maps
@@ -44,28 +50,88 @@ func NewFakeSlackClient() *FakeSlackClient {
 
 // MessageHistory returns the messages to the channel from the given startTime
 func (c *FakeSlackClient) MessageHistory(channel string, startTime time.Time) ([]string, error) {
-	c.mutex.Lock()
-	messages := make([]string, 0)
-	if history, ok := c.History[channel]; ok {
-		for _, msg := range history {
-			if time.Now().After(startTime) {
-				messages = append(messages, msg.text)
-			}
+	messages, err := c.Messages(channel, startTime)
+	if err != nil {
+		return nil, err
+	}
+	texts := make([]string, len(messages))
+	for i, msg := range messages {
+		texts[i] = msg.Text
+	}
+	return texts, nil
+}
+
+// Messages returns the messages to the channel from the given startTime, most recent first
+func (c *FakeSlackClient) Messages(channel string, startTime time.Time) ([]slackutil.Message, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	messages := make([]slackutil.Message, 0)
+	history := c.History[channel]
+	for i := len(history) - 1; i >= 0; i-- {
+		msg := history[i]
+		if !msg.sentTime.After(startTime) {
+			continue
+		}
+		message := slackutil.Message{Timestamp: msg.ts, Text: msg.text}
+		if len(msg.replies) != 0 {
+			message.LatestReply = msg.replies[len(msg.replies)-1].ts
 		}
+		messages = append(messages, message)
 	}
-	c.mutex.Unlock()
 	return messages, nil
 }
 
+// Replies returns the texts of the replies in the thread of the message with the given timestamp
+func (c *FakeSlackClient) Replies(channel, timestamp string) []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	var replies []string
+	for _, msg := range c.History[channel] {
+		if msg.ts == timestamp {
+			for _, reply := range msg.replies {
+				replies = append(replies, reply.text)
+			}
+		}
+	}
+	return replies
+}
+
 // Post sends the text as a message to the given channel
 func (c *FakeSlackClient) Post(text, channel string) error {
+	_, err := c.PostMessage(text, channel, "")
+	return err
+}
+
+// PostMessage sends the text as a message to the given channel, in the thread of
+// the message with the given timestamp if it's not empty
+func (c *FakeSlackClient) PostMessage(text, channel, threadTimestamp string) (string, error) {
 	c.mutex.Lock()
-	messages := make([]messageEntry, 0)
-	if history, ok := c.History[channel]; ok {
-		messages = history
+	defer c.mutex.Unlock()
+	now := time.Now()
+	c.count++
+	msg := messageEntry{text: text, sentTime: now, ts: fmt.Sprintf("%d.%06d", now.Unix(), c.count)}
+	if threadTimestamp == "" {
+		c.History[channel] = append(c.History[channel], msg)
+		return msg.ts, nil
+	}
+	for i, parent := range c.History[channel] {
+		if parent.ts == threadTimestamp {
+			c.History[channel][i].replies = append(parent.replies, msg)
+			return msg.ts, nil
+		}
+	}
+	return "", fmt.Errorf("message %q not found in channel %q", threadTimestamp, channel)
+}
+
+// Update replaces the text of the message with the given timestamp in the given channel
+func (c *FakeSlackClient) Update(text, channel, timestamp string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i, msg := range c.History[channel] {
+		if msg.ts == timestamp {
+			c.History[channel][i].text = text
+			return nil
+		}
 	}
-	messages = append(messages, messageEntry{text: text, sentTime: time.Now()})
-	c.History[channel] = messages
-	c.mutex.Unlock()
-	return nil
+	return fmt.Errorf("message %q not found in channel %q", timestamp, channel)
 }
@@ -30,9 +30,20 @@ import (
 
 const conversationHistoryURL = "https://slack.com/api/conversations.history"
 
+// Message is a message read from Slack
+type Message struct {
+	// Timestamp identifies the message in its channel
+	Timestamp string
+	Text      string
+	// LatestReply is the timestamp of the latest reply in the thread of
+	// the message, empty if there's none
+	LatestReply string
+}
+
 // ReadOperations defines the read operations that can be done to Slack
 type ReadOperations interface {
 	MessageHistory(channel string, startTime time.Time) ([]string, error)
+	Messages(channel string, startTime time.Time) ([]Message, error)
 }
 
 // readClient contains Slack bot related information to perform read operations
@@ -53,7 +64,23 @@ func NewReadClient(userName, tokenPath string) (ReadOperations, error) {
 	}, nil
 }
 
+// MessageHistory returns the text of the messages in channel since startTime
 func (c *readClient) MessageHistory(channel string, startTime time.Time) ([]string, error) {
+	messages, err := c.Messages(channel, startTime)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, len(messages))
+	for i, message := range messages {
+		res[i] = message.Text
+	}
+
+	return res, nil
+}
+
+// Messages returns the messages in channel since startTime, most recent first
+func (c *readClient) Messages(channel string, startTime time.Time) ([]Message, error) {
 	u, _ := url.Parse(conversationHistoryURL)
 	q := u.Query()
 	q.Add("username", c.userName)
@@ -69,7 +96,9 @@ func (c *readClient) MessageHistory(channel string, startTime time.Time) ([]stri
 
 	// response code could also be 200 if channel doesn't exist, parse response body to find out
 	type m struct {
-		Text string `json:"text"`
+		TS          string `json:"ts"`
+		Text        string `json:"text"`
+		LatestReply string `json:"latest_reply"`
 	}
 	var r struct {
 		OK       bool `json:"ok"`
@@ -79,9 +108,13 @@ func (c *readClient) MessageHistory(channel string, startTime time.Time) ([]stri
 		return nil, fmt.Errorf("response not ok '%s'", string(content))
 	}
 
-	res := make([]string, len(r.Messages))
+	res := make([]Message, len(r.Messages))
 	for i, message := range r.Messages {
-		res[i] = message.Text
+		res[i] = Message{
+			Timestamp:   message.TS,
+			Text:        message.Text,
+			LatestReply: message.LatestReply,
+		}
 	}
 
 	return res, nil
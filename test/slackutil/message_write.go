@@ -27,11 +27,16 @@ import (
 	"net/url"
 )
 
-const postMessageURL = "https://slack.com/api/chat.postMessage"
+const (
+	postMessageURL   = "https://slack.com/api/chat.postMessage"
+	updateMessageURL = "https://slack.com/api/chat.update"
+)
 
 // WriteOperations defines the write operations that can be done to Slack
 type WriteOperations interface {
 	Post(text, channel string) error
+	PostMessage(text, channel, threadTimestamp string) (string, error)
+	Update(text, channel, timestamp string) error
 }
 
 // writeClient contains Slack bot related information to perform write operations
@@ -54,24 +59,52 @@ func NewWriteClient(userName, tokenPath string) (WriteOperations, error) {
 
 // Post posts the given text to channel
 func (c *writeClient) Post(text, channel string) error {
+	_, err := c.PostMessage(text, channel, "")
+	return err
+}
+
+// PostMessage posts the given text to channel, in the thread of the message
+// with the given timestamp if it's not empty, and returns the timestamp of
+// the new message
+func (c *writeClient) PostMessage(text, channel, threadTimestamp string) (string, error) {
 	uv := url.Values{}
 	uv.Add("username", c.userName)
 	uv.Add("token", c.tokenStr)
 	uv.Add("channel", channel)
 	uv.Add("text", text)
+	if threadTimestamp != "" {
+		uv.Add("thread_ts", threadTimestamp)
+	}
+	return write(postMessageURL, uv)
+}
+
+// Update replaces the text of the message with the given timestamp in channel
+func (c *writeClient) Update(text, channel, timestamp string) error {
+	uv := url.Values{}
+	uv.Add("token", c.tokenStr)
+	uv.Add("channel", channel)
+	uv.Add("ts", timestamp)
+	uv.Add("text", text)
+	_, err := write(updateMessageURL, uv)
+	return err
+}
 
-	content, err := post(postMessageURL, uv)
+// write sends a write request and returns the timestamp of the message
+// written
+func write(url string, uv url.Values) (string, error) {
+	content, err := post(url, uv)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	// response code could also be 200 if channel doesn't exist, parse response body to find out
 	var b struct {
-		OK bool `json:"ok"`
+		OK bool   `json:"ok"`
+		TS string `json:"ts"`
 	}
 	if err = json.Unmarshal(content, &b); nil != err || !b.OK {
-		return fmt.Errorf("response not ok '%s'", string(content))
+		return "", fmt.Errorf("response not ok '%s'", string(content))
 	}
 
-	return nil
+	return b.TS, nil
 }
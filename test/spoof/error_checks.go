@@ -21,6 +21,8 @@ package spoof
 import (
 	"net"
 	"strings"
+
+	"knative.dev/pkg/network"
 )
 
 func isTCPTimeout(e error) bool {
@@ -32,8 +34,11 @@ func isDNSError(err error) bool {
 	if err == nil {
 		return false
 	}
-	// Checking by casting to url.Error and casting the nested error
-	// seems to be not as robust as string check.
+	if network.IsDNSError(err) {
+		return true
+	}
+	// Fall back to the error message for errors that don't carry a
+	// *net.DNSError, e.g. ones that have been flattened to strings.
 	msg := strings.ToLower(err.Error())
 	// Example error message:
 	//   > Get http://this.url.does.not.exist: dial tcp: lookup this.url.does.not.exist on 127.0.0.1:53: no such host
@@ -41,13 +46,11 @@ func isDNSError(err error) bool {
 }
 
 func isConnectionRefused(err error) bool {
-	// The alternative for the string check is:
-	// 	errNo := (((err.(*url.Error)).Err.(*net.OpError)).Err.(*os.SyscallError).Err).(syscall.Errno)
-	// if errNo == syscall.Errno(0x6f) {...}
-	// But with assertions, of course.
-	return err != nil && strings.Contains(err.Error(), "connect: connection refused")
+	return err != nil && (network.IsConnectionRefused(err) ||
+		strings.Contains(err.Error(), "connect: connection refused"))
 }
 
 func isConnectionReset(err error) bool {
-	return err != nil && strings.Contains(err.Error(), "connection reset by peer")
+	return err != nil && (network.IsConnectionReset(err) ||
+		strings.Contains(err.Error(), "connection reset by peer"))
 }
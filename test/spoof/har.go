@@ -0,0 +1,397 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// har.go records the requests made by the spoofing client and exports them
+// as HAR, see http://www.softwareishard.com/blog/har-12-spec/.
+
+package spoof
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"knative.dev/pkg/test/prow"
+)
+
+// defaultMaxBodySize is the default size above which the recorded bodies
+// are truncated.
+const defaultMaxBodySize = 64 * 1024
+
+// HAR is an HTTP Archive, holding the recorded requests and responses.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog is the root of the HTTP Archive.
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator is the application which recorded the archive.
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is a single request and its response. Requests that failed
+// without response have a zero status, and the error as comment.
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+// HARNameValue is a header or query string parameter.
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARRequest is a recorded request.
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARPostData is the body of a recorded request.
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HARResponse is a recorded response.
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARContent is the body of a recorded response.
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// HARTimings are the durations of the phases of a request, in milliseconds.
+// The phases which weren't measured are -1.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// Recorder records the requests made by a SpoofingClient and their
+// responses, including the retries of Poll, to export them as HAR.
+type Recorder struct {
+	// MaxBodySize is the size above which the recorded bodies are
+	// truncated. Defaults to 64KiB.
+	MaxBodySize int
+
+	m       sync.Mutex
+	entries []HAREntry
+}
+
+// NewRecorder returns a new Recorder, to set on a SpoofingClient.
+func NewRecorder() *Recorder {
+	return &Recorder{MaxBodySize: defaultMaxBodySize}
+}
+
+// exchange is a request being recorded.
+type exchange struct {
+	req     *http.Request
+	body    []byte
+	attempt int
+	start   time.Time
+	headers time.Time
+}
+
+// start starts recording the given request, the given attempt of a Poll if
+// attempt is not zero. It must be called before the request is sent, for its
+// body to be recorded.
+func (r *Recorder) start(req *http.Request, attempt int) *exchange {
+	e := &exchange{req: req, attempt: attempt, start: time.Now()}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			e.body, _ = ioutil.ReadAll(body)
+			body.Close()
+		}
+	}
+	return e
+}
+
+// headersReceived records when the response headers were received.
+func (e *exchange) headersReceived() {
+	e.headers = time.Now()
+}
+
+// finish records the response, or the error if there's none.
+func (r *Recorder) finish(e *exchange, resp *http.Response, body []byte, err error) {
+	end := time.Now()
+	if e.headers.IsZero() {
+		e.headers = end
+	}
+	host := e.req.Host
+	if host == "" {
+		host = e.req.URL.Host
+	}
+	entry := HAREntry{
+		StartedDateTime: e.start,
+		Time:            millis(end.Sub(e.start)),
+		Request: HARRequest{
+			Method:      e.req.Method,
+			URL:         e.req.URL.String(),
+			HTTPVersion: e.req.Proto,
+			Cookies:     []HARNameValue{},
+			Headers:     harHeaders(e.req.Header, host),
+			QueryString: harQueryString(e.req),
+			HeadersSize: -1,
+			BodySize:    len(e.body),
+		},
+		Timings: HARTimings{
+			Blocked: -1,
+			DNS:     -1,
+			Connect: -1,
+			Send:    0,
+			Wait:    millis(e.headers.Sub(e.start)),
+			Receive: millis(end.Sub(e.headers)),
+			SSL:     -1,
+		},
+	}
+	if entry.Request.HTTPVersion == "" {
+		entry.Request.HTTPVersion = "HTTP/1.1"
+	}
+	if len(e.body) > 0 {
+		entry.Request.PostData = &HARPostData{
+			MimeType: e.req.Header.Get("Content-Type"),
+			Text:     r.truncate(e.body),
+		}
+	}
+
+	var comments []string
+	if e.attempt > 0 {
+		comments = append(comments, fmt.Sprintf("attempt %d", e.attempt))
+	}
+	if resp != nil {
+		entry.Response = HARResponse{
+			Status:      resp.StatusCode,
+			StatusText:  strings.TrimSpace(strings.TrimPrefix(resp.Status, fmt.Sprint(resp.StatusCode))),
+			HTTPVersion: resp.Proto,
+			Cookies:     []HARNameValue{},
+			Headers:     harHeaders(resp.Header, ""),
+			Content: HARContent{
+				Size:     len(body),
+				MimeType: resp.Header.Get("Content-Type"),
+				Text:     r.truncate(body),
+			},
+			RedirectURL: resp.Header.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    len(body),
+		}
+		if len(body) > r.maxBodySize() {
+			entry.Response.Content.Comment = fmt.Sprintf("truncated to %d bytes", r.maxBodySize())
+		}
+	} else {
+		entry.Response = HARResponse{
+			HTTPVersion: entry.Request.HTTPVersion,
+			Cookies:     []HARNameValue{},
+			Headers:     []HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		}
+	}
+	if err != nil {
+		comments = append(comments, fmt.Sprintf("error: %v", err))
+	}
+	entry.Comment = strings.Join(comments, ", ")
+
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.entries = append(r.entries, entry)
+}
+
+// Entries returns the entries recorded so far, in the order the requests
+// were started.
+func (r *Recorder) Entries() []HAREntry {
+	r.m.Lock()
+	defer r.m.Unlock()
+	entries := append([]HAREntry(nil), r.entries...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedDateTime.Before(entries[j].StartedDateTime)
+	})
+	return entries
+}
+
+// Reset forgets the entries recorded so far.
+func (r *Recorder) Reset() {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.entries = nil
+}
+
+// HAR returns the HTTP Archive of the entries recorded so far.
+func (r *Recorder) HAR() *HAR {
+	entries := r.Entries()
+	if entries == nil {
+		entries = []HAREntry{}
+	}
+	return &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "knative.dev/pkg/test/spoof", Version: "1.0"},
+		Entries: entries,
+	}}
+}
+
+// WriteHAR writes the HTTP Archive of the entries recorded so far to w.
+func (r *Recorder) WriteHAR(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r.HAR())
+}
+
+// WriteFile writes the HTTP Archive of the entries recorded so far to the
+// file at the given path, creating its directory if needed.
+func (r *Recorder) WriteFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := r.WriteHAR(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// FailureT is the subset of testing.T used by DumpHAROnFailure.
+type FailureT interface {
+	Name() string
+	Failed() bool
+	Logf(format string, args ...interface{})
+}
+
+// DumpHAROnFailure writes the HTTP Archive of the requests recorded by the
+// recorder to the har directory of the test artifacts, in a file named after
+// the test, if the test has failed. Use it as:
+//
+//	defer spoof.DumpHAROnFailure(t, recorder)
+//
+// Errors while writing are logged rather than failing the test.
+func DumpHAROnFailure(t FailureT, r *Recorder) {
+	if !t.Failed() {
+		return
+	}
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	path := filepath.Join(prow.GetJob().ArtifactsDir, "har", name+".har")
+	if err := r.WriteFile(path); err != nil {
+		t.Logf("Failed to write the HTTP Archive: %v", err)
+		return
+	}
+	t.Logf("Wrote the HTTP Archive of the requests to %s", path)
+}
+
+func (r *Recorder) maxBodySize() int {
+	if r.MaxBodySize <= 0 {
+		return defaultMaxBodySize
+	}
+	return r.MaxBodySize
+}
+
+// truncate returns the body as text, truncated to the maximum body size.
+func (r *Recorder) truncate(body []byte) string {
+	if max := r.maxBodySize(); len(body) > max {
+		body = body[:max]
+	}
+	return string(body)
+}
+
+// harHeaders returns the headers sorted by name, with the Host header first
+// if host is set.
+func harHeaders(h http.Header, host string) []HARNameValue {
+	headers := []HARNameValue{}
+	if host != "" {
+		headers = append(headers, HARNameValue{Name: "Host", Value: host})
+	}
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range h[name] {
+			headers = append(headers, HARNameValue{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+// harQueryString returns the query string parameters of the request.
+func harQueryString(req *http.Request) []HARNameValue {
+	params := []HARNameValue{}
+	query := req.URL.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range query[name] {
+			params = append(params, HARNameValue{Name: name, Value: value})
+		}
+	}
+	return params
+}
+
+// millis returns the duration in milliseconds.
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spoof
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("hello world"))
+	}))
+	defer server.Close()
+
+	recorder := NewRecorder()
+	recorder.MaxBodySize = 5
+	sc := &SpoofingClient{
+		Client:          server.Client(),
+		RequestInterval: time.Millisecond,
+		RequestTimeout:  5 * time.Second,
+		Logf:            t.Logf,
+		Recorder:        recorder,
+	}
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/path?q=1", strings.NewReader("body"))
+	req.Header.Set("Content-Type", "text/plain")
+	policy := RetryPolicy{RetryResponse: RetryStatusCodes(http.StatusServiceUnavailable)}
+	if _, err := sc.PollWithPolicy(req, isOK, policy); err != nil {
+		t.Fatalf("PollWithPolicy() = %v", err)
+	}
+
+	entries := recorder.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("len(Entries()) = %d, want 2", len(entries))
+	}
+	for i, want := range []int{http.StatusServiceUnavailable, http.StatusOK} {
+		e := entries[i]
+		if got := e.Response.Status; got != want {
+			t.Errorf("entries[%d].Response.Status = %d, want %d", i, got, want)
+		}
+		if got, want := e.Comment, fmt.Sprintf("attempt %d", i+1); got != want {
+			t.Errorf("entries[%d].Comment = %q, want %q", i, got, want)
+		}
+		if e.Request.Method != http.MethodPost || e.Request.URL != server.URL+"/path?q=1" {
+			t.Errorf("entries[%d].Request = %s %s, want POST %s/path?q=1", i, e.Request.Method, e.Request.URL, server.URL)
+		}
+		if e.Request.PostData == nil || e.Request.PostData.Text != "body" {
+			t.Errorf("entries[%d].Request.PostData = %v, want the request body", i, e.Request.PostData)
+		}
+		if len(e.Request.QueryString) != 1 || e.Request.QueryString[0] != (HARNameValue{Name: "q", Value: "1"}) {
+			t.Errorf("entries[%d].Request.QueryString = %v, want q=1", i, e.Request.QueryString)
+		}
+		for _, h := range e.Request.Headers {
+			if h.Name == pollReqHeader {
+				t.Errorf("entries[%d] recorded the internal %s header", i, pollReqHeader)
+			}
+		}
+		if e.Time < 0 || e.Timings.Wait < 0 || e.Timings.Receive < 0 {
+			t.Errorf("entries[%d] has negative timings: %v, %+v", i, e.Time, e.Timings)
+		}
+	}
+	content := entries[1].Response.Content
+	if content.Text != "hello" || content.Size != len("hello world") || content.Comment == "" {
+		t.Errorf("Content = %+v, want the body truncated to 5 bytes", content)
+	}
+
+	// Errors are recorded too.
+	server.Close()
+	if _, err := sc.Do(req); err == nil {
+		t.Fatal("Do() = nil, wanted an error")
+	}
+	entries = recorder.Entries()
+	if got := entries[len(entries)-1]; got.Response.Status != 0 || !strings.HasPrefix(got.Comment, "error: ") {
+		t.Errorf("entry = %+v, wanted an error entry", got)
+	}
+
+	var buf bytes.Buffer
+	if err := recorder.WriteHAR(&buf); err != nil {
+		t.Fatalf("WriteHAR() = %v", err)
+	}
+	var har HAR
+	if err := json.Unmarshal(buf.Bytes(), &har); err != nil {
+		t.Fatalf("Unmarshal() = %v", err)
+	}
+	if har.Log.Version != "1.2" || len(har.Log.Entries) != 3 {
+		t.Errorf("HAR version = %q with %d entries, want 1.2 with 3", har.Log.Version, len(har.Log.Entries))
+	}
+
+	recorder.Reset()
+	if got := recorder.HAR().Log.Entries; got == nil || len(got) != 0 {
+		t.Errorf("Entries after Reset() = %v, want none", got)
+	}
+}
+
+type fakeT struct {
+	failed bool
+	logs   []string
+}
+
+func (f *fakeT) Name() string {
+	return "TestFoo/sub test"
+}
+
+func (f *fakeT) Failed() bool {
+	return f.failed
+}
+
+func (f *fakeT) Logf(format string, args ...interface{}) {
+	f.logs = append(f.logs, fmt.Sprintf(format, args...))
+}
+
+func TestDumpHAROnFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "har")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("ARTIFACTS", os.Getenv("ARTIFACTS"))
+	os.Setenv("ARTIFACTS", dir)
+
+	recorder := NewRecorder()
+	path := filepath.Join(dir, "har", "TestFoo_sub_test.har")
+
+	DumpHAROnFailure(&fakeT{}, recorder)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Stat() = %v, wanted no HAR file for a passing test", err)
+	}
+
+	ft := &fakeT{failed: true}
+	DumpHAROnFailure(ft, recorder)
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() = %v, logs: %v", err, ft.logs)
+	}
+	var har HAR
+	if err := json.Unmarshal(b, &har); err != nil {
+		t.Errorf("Unmarshal() = %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spoof
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxErrorSamples is the number of errors kept by a LoadResult.
+const maxErrorSamples = 5
+
+// LoadResult aggregates the responses to the requests sent by Load.
+type LoadResult struct {
+	// Requests is the number of requests sent.
+	Requests int
+	// StatusCodes counts the responses by status code.
+	StatusCodes map[int]int
+	// Errors is the number of requests which failed without a response.
+	Errors int
+	// ErrorSamples are some of these errors.
+	ErrorSamples []error
+	// Latencies are the latencies of all requests, sorted ascendingly.
+	Latencies []time.Duration
+}
+
+// Percentile returns the given percentile (0-100) of the latencies, using
+// the nearest-rank method.
+func (r *LoadResult) Percentile(p float64) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(r.Latencies))))
+	if rank < 1 {
+		rank = 1
+	} else if rank > len(r.Latencies) {
+		rank = len(r.Latencies)
+	}
+	return r.Latencies[rank-1]
+}
+
+// SuccessRate returns the share of requests which got the given status code.
+func (r *LoadResult) SuccessRate(code int) float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return float64(r.StatusCodes[code]) / float64(r.Requests)
+}
+
+// String summarizes the result.
+func (r *LoadResult) String() string {
+	codes := make([]int, 0, len(r.StatusCodes))
+	for code := range r.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	var dist []string
+	for _, code := range codes {
+		dist = append(dist, fmt.Sprintf("%d: %d", code, r.StatusCodes[code]))
+	}
+	s := fmt.Sprintf("%d requests, status codes {%s}, %d errors, latency p50 %v, p90 %v, p99 %v",
+		r.Requests, strings.Join(dist, ", "), r.Errors, r.Percentile(50), r.Percentile(90), r.Percentile(99))
+	for _, err := range r.ErrorSamples {
+		s += "\n  error: " + err.Error()
+	}
+	return s
+}
+
+// Load sends the request n times, with up to concurrency requests in flight,
+// through Do and aggregates the outcomes. Requests with a body must be
+// replayable, i.e. have GetBody set. Load doesn't retry failed requests, as
+// their failures are part of the result.
+func (sc *SpoofingClient) Load(req *http.Request, n, concurrency int) (*LoadResult, error) {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return nil, errors.New("the request's body can't be replayed, set GetBody")
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	result := &LoadResult{
+		StatusCodes: make(map[int]int),
+		Latencies:   make([]time.Duration, 0, n),
+	}
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, concurrency)
+	)
+	for i := 0; i < n; i++ {
+		r := req.Clone(req.Context())
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r.Body = body
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			start := time.Now()
+			resp, err := sc.Do(r)
+			latency := time.Since(start)
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.Requests++
+			result.Latencies = append(result.Latencies, latency)
+			if err != nil {
+				result.Errors++
+				if len(result.ErrorSamples) < maxErrorSamples {
+					result.ErrorSamples = append(result.ErrorSamples, err)
+				}
+				return
+			}
+			result.StatusCodes[resp.StatusCode]++
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(result.Latencies, func(i, j int) bool {
+		return result.Latencies[i] < result.Latencies[j]
+	})
+	return result, nil
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spoof
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	var (
+		inflight, maxInflight int32
+		count                 int32
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cur := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInflight)
+			if cur <= max || atomic.CompareAndSwapInt32(&maxInflight, max, cur) {
+				break
+			}
+		}
+		if body, _ := ioutil.ReadAll(r.Body); string(body) != "payload" {
+			t.Errorf("Body = %q, want payload", body)
+		}
+		time.Sleep(5 * time.Millisecond)
+		// Every fourth request fails.
+		if atomic.AddInt32(&count, 1)%4 == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	sc := &SpoofingClient{
+		Client: server.Client(),
+		Logf:   t.Logf,
+	}
+	req, _ := http.NewRequest(http.MethodPost, server.URL, bytes.NewBufferString("payload"))
+
+	result, err := sc.Load(req, 20, 4)
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	t.Log(result)
+
+	if result.Requests != 20 {
+		t.Errorf("Requests = %d, want 20", result.Requests)
+	}
+	if got, want := result.StatusCodes[http.StatusOK], 15; got != want {
+		t.Errorf("200s = %d, want %d", got, want)
+	}
+	if got, want := result.StatusCodes[http.StatusServiceUnavailable], 5; got != want {
+		t.Errorf("503s = %d, want %d", got, want)
+	}
+	if got, want := result.SuccessRate(http.StatusOK), 0.75; got != want {
+		t.Errorf("SuccessRate() = %v, want %v", got, want)
+	}
+	if got := atomic.LoadInt32(&maxInflight); got > 4 {
+		t.Errorf("Max in-flight requests = %d, want at most 4", got)
+	}
+	if p := result.Percentile(50); p < 5*time.Millisecond {
+		t.Errorf("Percentile(50) = %v, want at least 5ms", p)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	sc := &SpoofingClient{
+		Client: http.DefaultClient,
+		Logf:   t.Logf,
+	}
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	result, err := sc.Load(req, 10, 2)
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if result.Errors != 10 {
+		t.Errorf("Errors = %d, want 10", result.Errors)
+	}
+	if got := len(result.ErrorSamples); got != maxErrorSamples {
+		t.Errorf("ErrorSamples = %d, want %d", got, maxErrorSamples)
+	}
+}
+
+func TestLoadUnreplayableBody(t *testing.T) {
+	sc := &SpoofingClient{Client: http.DefaultClient, Logf: t.Logf}
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com", nil)
+	req.Body = ioutil.NopCloser(bytes.NewBufferString("payload"))
+	if _, err := sc.Load(req, 1, 1); err == nil {
+		t.Error("Load() = nil, wanted an error")
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	r := &LoadResult{}
+	if got := r.Percentile(50); got != 0 {
+		t.Errorf("Percentile() = %v without latencies, want 0", got)
+	}
+	for i := 1; i <= 10; i++ {
+		r.Latencies = append(r.Latencies, time.Duration(i)*time.Millisecond)
+	}
+	for p, want := range map[float64]time.Duration{
+		0:   time.Millisecond,
+		50:  5 * time.Millisecond,
+		90:  9 * time.Millisecond,
+		100: 10 * time.Millisecond,
+	} {
+		if got := r.Percentile(p); got != want {
+			t.Errorf("Percentile(%v) = %v, want %v", p, got, want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spoof
+
+import (
+	"strings"
+	"time"
+)
+
+// ErrorRetryChecker decides whether a request that failed with the given
+// error is retried.
+type ErrorRetryChecker func(err error) bool
+
+// ResponseRetryChecker decides whether a request that got the given response
+// is retried, before the response is passed to the ResponseChecker.
+type ResponseRetryChecker func(resp *Response) bool
+
+// RetryPolicy defines which outcomes of the requests made by Poll are
+// retried and how long Poll keeps retrying.
+type RetryPolicy struct {
+	// RetryError decides which errors are retried. If nil, no errors are
+	// retried.
+	RetryError ErrorRetryChecker
+
+	// RetryResponse decides which responses are retried. If nil, all
+	// responses are passed to the ResponseChecker.
+	RetryResponse ResponseRetryChecker
+
+	// MaxAttempts is the maximum number of requests made by a single Poll.
+	// Zero means Poll is only bounded by the timeout.
+	MaxAttempts int
+
+	// Interval is the time between two requests. Zero means the client's
+	// RequestInterval.
+	Interval time.Duration
+
+	// Timeout is the time a single Poll keeps retrying. Zero means the
+	// client's RequestTimeout.
+	Timeout time.Duration
+}
+
+// DefaultRetryPolicy returns the RetryPolicy used by Poll unless the client
+// has another one. It retries the errors which are usually transient while
+// a route is being set up, i.e. TCP timeouts, DNS errors and refused
+// connections.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		RetryError: DefaultErrorRetryChecker,
+	}
+}
+
+// DefaultErrorRetryChecker retries TCP timeouts, DNS errors, since we may be
+// using xip.io or nip.io in tests, and refused connections, which are usually
+// transient Istio errors.
+func DefaultErrorRetryChecker(err error) bool {
+	return isTCPTimeout(err) || isDNSError(err) || isConnectionRefused(err)
+}
+
+// RetryConnectionReset retries connections which have been reset.
+func RetryConnectionReset(err error) bool {
+	return isConnectionReset(err)
+}
+
+// RetryErrorsContaining retries the errors whose message contains any of the
+// given substrings.
+func RetryErrorsContaining(substrs ...string) ErrorRetryChecker {
+	return func(err error) bool {
+		for _, s := range substrs {
+			if strings.Contains(err.Error(), s) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// AnyErrorOf retries the errors retried by any of the given checkers.
+func AnyErrorOf(checkers ...ErrorRetryChecker) ErrorRetryChecker {
+	return func(err error) bool {
+		for _, c := range checkers {
+			if c(err) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RetryStatusCodes retries the responses with any of the given status codes.
+func RetryStatusCodes(codes ...int) ResponseRetryChecker {
+	return func(resp *Response) bool {
+		for _, code := range codes {
+			if resp.StatusCode == code {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RetryStatusClasses retries the responses whose status code is in any of
+// the given classes, which are given by their first digit, e.g. 5 for all
+// of the 5xx server errors.
+func RetryStatusClasses(classes ...int) ResponseRetryChecker {
+	return func(resp *Response) bool {
+		for _, class := range classes {
+			if resp.StatusCode/100 == class {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RetryBodyContains retries the responses whose body contains the given
+// substring, e.g. the default page of an ingress that isn't ready yet.
+func RetryBodyContains(substr string) ResponseRetryChecker {
+	return func(resp *Response) bool {
+		return strings.Contains(string(resp.Body), substr)
+	}
+}
+
+// AnyResponseOf retries the responses retried by any of the given checkers.
+func AnyResponseOf(checkers ...ResponseRetryChecker) ResponseRetryChecker {
+	return func(resp *Response) bool {
+		for _, c := range checkers {
+			if c(resp) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spoof
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func isOK(resp *Response) (bool, error) {
+	return resp.StatusCode == http.StatusOK, nil
+}
+
+func TestPollWithPolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		bodies       []string
+		policy       RetryPolicy
+		wantErr      bool
+		wantAttempts int32
+		// maxAttempts is checked instead of wantAttempts if set.
+		maxAttempts int32
+	}{{
+		name:         "status codes are retried",
+		statuses:     []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+		policy:       RetryPolicy{RetryResponse: RetryStatusCodes(http.StatusServiceUnavailable, http.StatusBadGateway)},
+		wantAttempts: 3,
+	}, {
+		name:         "status classes are retried",
+		statuses:     []int{http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusOK},
+		policy:       RetryPolicy{RetryResponse: RetryStatusClasses(5)},
+		wantAttempts: 3,
+	}, {
+		name:         "bodies are retried",
+		statuses:     []int{http.StatusOK, http.StatusOK},
+		bodies:       []string{"default backend", "hello"},
+		policy:       RetryPolicy{RetryResponse: RetryBodyContains("default backend")},
+		wantAttempts: 2,
+	}, {
+		name:         "attempts are bounded",
+		statuses:     []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK},
+		policy:       RetryPolicy{RetryResponse: RetryStatusClasses(5), MaxAttempts: 2},
+		wantErr:      true,
+		wantAttempts: 2,
+	}, {
+		name:         "attempts of the checker are bounded",
+		statuses:     []int{http.StatusNotFound, http.StatusNotFound, http.StatusOK},
+		policy:       RetryPolicy{MaxAttempts: 2},
+		wantErr:      true,
+		wantAttempts: 2,
+	}, {
+		name:        "timeout is bounded",
+		statuses:    []int{http.StatusNotFound, http.StatusNotFound, http.StatusNotFound, http.StatusNotFound, http.StatusOK},
+		policy:      RetryPolicy{Interval: 50 * time.Millisecond, Timeout: 75 * time.Millisecond},
+		wantErr:     true,
+		maxAttempts: 3,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var attempts int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := atomic.AddInt32(&attempts, 1) - 1
+				w.WriteHeader(test.statuses[i])
+				if int(i) < len(test.bodies) {
+					w.Write([]byte(test.bodies[i]))
+				}
+			}))
+			defer server.Close()
+
+			sc := &SpoofingClient{
+				Client:          server.Client(),
+				RequestInterval: time.Millisecond,
+				RequestTimeout:  5 * time.Second,
+				Logf:            t.Logf,
+			}
+			req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+			_, err := sc.PollWithPolicy(req, isOK, test.policy)
+			if (err != nil) != test.wantErr {
+				t.Errorf("PollWithPolicy() = %v, wantErr = %v", err, test.wantErr)
+			}
+			got := atomic.LoadInt32(&attempts)
+			if test.maxAttempts > 0 {
+				if got > test.maxAttempts {
+					t.Errorf("Attempts = %d, want at most %d", got, test.maxAttempts)
+				}
+			} else if got != test.wantAttempts {
+				t.Errorf("Attempts = %d, want %d", got, test.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestErrorRetryCheckers(t *testing.T) {
+	err := errors.New("upstream connect error or disconnect/reset before headers")
+	if DefaultErrorRetryChecker(err) {
+		t.Errorf("DefaultErrorRetryChecker(%v) = true, wanted false", err)
+	}
+	checker := AnyErrorOf(DefaultErrorRetryChecker, RetryErrorsContaining("upstream connect error"))
+	if !checker(err) {
+		t.Errorf("checker(%v) = false, wanted true", err)
+	}
+	if checker(errors.New("unrelated")) {
+		t.Error("checker(unrelated) = true, wanted false")
+	}
+}
+
+func TestPollUsesClientPolicy(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sc := &SpoofingClient{
+		Client:          server.Client(),
+		RequestInterval: time.Millisecond,
+		RequestTimeout:  5 * time.Second,
+		Logf:            t.Logf,
+		RetryPolicy: &RetryPolicy{
+			RetryResponse: RetryStatusCodes(http.StatusServiceUnavailable),
+		},
+	}
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	// The checker would fail on the first response, if it wasn't retried.
+	if _, err := sc.Poll(req, func(resp *Response) (bool, error) {
+		if resp.StatusCode != http.StatusOK {
+			return true, errors.New("unexpected status")
+		}
+		return true, nil
+	}); err != nil {
+		t.Errorf("Poll() = %v", err)
+	}
+}
+
+func TestPollStopsWithTheRequestContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	sc := &SpoofingClient{
+		Client:          server.Client(),
+		RequestInterval: time.Millisecond,
+		RequestTimeout:  time.Minute,
+		Logf:            t.Logf,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	start := time.Now()
+	if _, err := sc.Poll(req.WithContext(ctx), isOK); err == nil {
+		t.Error("Poll() = nil, wanted an error")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Poll() took %v, wanted it to stop with the context", elapsed)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"strings"
 	"time"
 
@@ -88,6 +89,17 @@ type SpoofingClient struct {
 	RequestInterval time.Duration
 	RequestTimeout  time.Duration
 	Logf            logging.FormatLogger
+
+	// RetryPolicy is used by Poll. If nil, the DefaultRetryPolicy is used.
+	RetryPolicy *RetryPolicy
+
+	// Recorder records the requests and responses, if set, e.g. to dump
+	// them with DumpHAROnFailure.
+	Recorder *Recorder
+
+	// dialContext spoofs the domain for the connections which are not
+	// made by Client, like websocket connections.
+	dialContext func(ctx context.Context, network, addr string) (net.Conn, error)
 }
 
 // TransportOption allows callers to customize the http.Transport used by a SpoofingClient
@@ -139,6 +151,7 @@ func New(
 		RequestInterval: requestInterval,
 		RequestTimeout:  RequestTimeout,
 		Logf:            logf,
+		dialContext:     transport.DialContext,
 	}
 	return &sc, nil
 }
@@ -162,6 +175,11 @@ func ResolveEndpoint(kubeClientset *kubernetes.Clientset, domain string, resolva
 // and transforming the http.Response into a spoof.Response.
 // Each response is augmented with "ZipkinTraceID" header that identifies the zipkin trace corresponding to the request.
 func (sc *SpoofingClient) Do(req *http.Request) (*Response, error) {
+	return sc.do(req, 0)
+}
+
+// do is Do, recording the given attempt of a Poll if attempt is not zero.
+func (sc *SpoofingClient) do(req *http.Request, attempt int) (*Response, error) {
 	// Starting span to capture zipkin trace.
 	traceContext, span := trace.StartSpan(req.Context(), "SpoofingClient-Trace")
 	defer span.End()
@@ -172,8 +190,17 @@ func (sc *SpoofingClient) Do(req *http.Request) (*Response, error) {
 		req.Header.Del(pollReqHeader)
 		logZipkinTrace = false
 	}
+
+	var e *exchange
+	if sc.Recorder != nil {
+		e = sc.Recorder.start(req, attempt)
+		traceContext = httptrace.WithClientTrace(traceContext, &httptrace.ClientTrace{
+			GotFirstResponseByte: e.headersReceived,
+		})
+	}
 	resp, err := sc.Client.Do(req.WithContext(traceContext))
 	if err != nil {
+		sc.record(e, nil, nil, err)
 		return nil, err
 	}
 
@@ -181,6 +208,7 @@ func (sc *SpoofingClient) Do(req *http.Request) (*Response, error) {
 
 	resp.Header.Add(zipkin.ZipkinTraceIDHeader, span.SpanContext().TraceID.String())
 	body, err := ioutil.ReadAll(resp.Body)
+	sc.record(e, resp, body, err)
 	if err != nil {
 		return nil, err
 	}
@@ -199,42 +227,78 @@ func (sc *SpoofingClient) Do(req *http.Request) (*Response, error) {
 	return spoofResp, nil
 }
 
+// record records the exchange with the Recorder, if it's being recorded.
+func (sc *SpoofingClient) record(e *exchange, resp *http.Response, body []byte, err error) {
+	if e != nil {
+		sc.Recorder.finish(e, resp, body, err)
+	}
+}
+
 // Poll executes an http request until it satisfies the inState condition or encounters an error.
 func (sc *SpoofingClient) Poll(req *http.Request, inState ResponseChecker) (*Response, error) {
+	return sc.PollWithPolicy(req, inState, sc.retryPolicy())
+}
+
+// retryPolicy returns the client's RetryPolicy or the default one.
+func (sc *SpoofingClient) retryPolicy() RetryPolicy {
+	if sc.RetryPolicy != nil {
+		return *sc.RetryPolicy
+	}
+	return DefaultRetryPolicy()
+}
+
+// PollWithPolicy is like Poll, but retries the requests according to the given policy
+// instead of the client's.
+func (sc *SpoofingClient) PollWithPolicy(req *http.Request, inState ResponseChecker, policy RetryPolicy) (*Response, error) {
 	var (
-		resp *Response
-		err  error
+		resp     *Response
+		err      error
+		attempts int
 	)
 
-	err = wait.PollImmediate(sc.RequestInterval, sc.RequestTimeout, func() (bool, error) {
+	interval, timeout := sc.RequestInterval, sc.RequestTimeout
+	if policy.Interval > 0 {
+		interval = policy.Interval
+	}
+	if policy.Timeout > 0 {
+		timeout = policy.Timeout
+	}
+
+	// The polling stops early once the context of the request is done, e.g.
+	// the one of test.Context when the test is about to time out.
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+	err = wait.PollImmediateUntil(interval, func() (bool, error) {
+		attempts++
+		lastAttempt := policy.MaxAttempts > 0 && attempts >= policy.MaxAttempts
+
 		// As we may do multiple Do calls as part of a single Poll we add this temporary header
 		// to the request to indicate to Do method not to log Zipkin trace, instead it is
 		// handled by this method itself.
 		req.Header.Add(pollReqHeader, "True")
-		resp, err = sc.Do(req)
+		resp, err = sc.do(req, attempts)
 		if err != nil {
-			if isTCPTimeout(err) {
-				sc.Logf("Retrying %s for TCP timeout: %v", req.URL, err)
-				return false, nil
-			}
-			// Retrying on DNS error, since we may be using xip.io or nip.io in tests.
-			if isDNSError(err) {
-				sc.Logf("Retrying %s for DNS error: %v", req.URL, err)
+			if policy.RetryError != nil && policy.RetryError(err) && !lastAttempt {
+				sc.Logf("Retrying %s for error: %v", req.URL, err)
 				return false, nil
 			}
-			// Repeat the poll on `connection refused` errors, which are usually transient Istio errors.
-			if isConnectionRefused(err) {
-				sc.Logf("Retrying %s for connection refused: %v", req.URL, err)
-				return false, nil
-			}
-			if isConnectionReset(err) {
-				sc.Logf("Retrying %s for connection reset: %v", req.URL, err)
-			}
 			return true, err
 		}
 
-		return inState(resp)
-	})
+		if policy.RetryResponse != nil && policy.RetryResponse(resp) {
+			if lastAttempt {
+				return true, fmt.Errorf("giving up after %d attempts", attempts)
+			}
+			sc.Logf("Retrying %s for response: %s", req.URL, resp)
+			return false, nil
+		}
+
+		done, err := inState(resp)
+		if !done && err == nil && lastAttempt {
+			return true, fmt.Errorf("giving up after %d attempts", attempts)
+		}
+		return done, err
+	}, ctx.Done())
 
 	if resp != nil {
 		sc.logZipkinTrace(resp)
@@ -0,0 +1,199 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spoof
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// DialWebSocket establishes a websocket connection to the given ws:// or wss://
+// URL, spoofing its domain like Do. Failed attempts are retried according to the
+// client's RetryPolicy, with the handshake response being checked by its
+// RetryResponse, until the client's RequestTimeout has passed.
+func (sc *SpoofingClient) DialWebSocket(url string, header http.Header) (*websocket.Conn, error) {
+	dialer := &websocket.Dialer{
+		NetDialContext:   sc.dialContext,
+		HandshakeTimeout: websocket.DefaultDialer.HandshakeTimeout,
+	}
+	policy := sc.retryPolicy()
+
+	var (
+		conn    *websocket.Conn
+		lastErr error
+	)
+	err := wait.PollImmediate(sc.RequestInterval, sc.RequestTimeout, func() (bool, error) {
+		var resp *http.Response
+		conn, resp, lastErr = dialer.Dial(url, header)
+		if lastErr == nil {
+			return true, nil
+		}
+		if resp != nil {
+			// The handshake has been rejected.
+			r, err := toResponse(resp)
+			if err != nil {
+				return true, err
+			}
+			if policy.RetryResponse != nil && policy.RetryResponse(r) {
+				sc.Logf("Retrying websocket %s for response: %s", url, r)
+				return false, nil
+			}
+			return true, fmt.Errorf("websocket handshake failed with %s: %v", r, lastErr)
+		}
+		if policy.RetryError != nil && policy.RetryError(lastErr) {
+			sc.Logf("Retrying websocket %s for error: %v", url, lastErr)
+			return false, nil
+		}
+		return true, lastErr
+	})
+	if err == wait.ErrWaitTimeout && lastErr != nil {
+		err = lastErr
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to connect to websocket %s", url)
+	}
+	return conn, nil
+}
+
+// Event is a server-sent event.
+type Event struct {
+	// ID is the event's id field, if any.
+	ID string
+	// Type is the event's event field, if any.
+	Type string
+	// Data is the event's data, with the lines of multiple data fields
+	// joined by newlines.
+	Data string
+}
+
+// EventHandler is called with every server-sent event received. Returning
+// done or an error stops consuming the events.
+type EventHandler func(Event) (done bool, err error)
+
+// ConsumeEvents sends the request, spoofing its domain like Do, and passes the
+// server-sent events in the response to the handler until it is done, the
+// stream ends or the request's context is done. Failed attempts to open the
+// stream are retried according to the client's RetryPolicy until the client's
+// RequestTimeout has passed.
+func (sc *SpoofingClient) ConsumeEvents(req *http.Request, handler EventHandler) error {
+	req.Header.Set("Accept", "text/event-stream")
+	policy := sc.retryPolicy()
+
+	var resp *http.Response
+	err := wait.PollImmediate(sc.RequestInterval, sc.RequestTimeout, func() (bool, error) {
+		var err error
+		resp, err = sc.Client.Do(req)
+		if err != nil {
+			if policy.RetryError != nil && policy.RetryError(err) {
+				sc.Logf("Retrying event stream %s for error: %v", req.URL, err)
+				return false, nil
+			}
+			return true, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			return true, nil
+		}
+
+		r, err := toResponse(resp)
+		resp.Body.Close()
+		resp = nil
+		if err != nil {
+			return true, err
+		}
+		if policy.RetryResponse != nil && policy.RetryResponse(r) {
+			sc.Logf("Retrying event stream %s for response: %s", req.URL, r)
+			return false, nil
+		}
+		return true, fmt.Errorf("unexpected response: %s", r)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to open event stream %s", req.URL)
+	}
+	defer resp.Body.Close()
+
+	if ct, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); ct != "text/event-stream" {
+		return fmt.Errorf("event stream %s has content type %q, want text/event-stream", req.URL, ct)
+	}
+	return readEvents(bufio.NewScanner(resp.Body), handler)
+}
+
+// readEvents parses the server-sent events as defined by
+// https://html.spec.whatwg.org/multipage/server-sent-events.html.
+func readEvents(scanner *bufio.Scanner, handler EventHandler) error {
+	var (
+		event Event
+		data  []string
+		seen  bool
+	)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			// A blank line dispatches the event.
+			if seen {
+				event.Data = strings.Join(data, "\n")
+				if done, err := handler(event); done || err != nil {
+					return err
+				}
+			}
+			event, data, seen = Event{}, nil, false
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			// Comments are used to keep the connection alive.
+			continue
+		}
+		field, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "id":
+			event.ID = value
+		case "event":
+			event.Type = value
+		case "data":
+			data = append(data, value)
+		default:
+			// Unknown fields, like retry, are ignored.
+			continue
+		}
+		seen = true
+	}
+	return scanner.Err()
+}
+
+// toResponse reads the given response into a Response.
+func toResponse(resp *http.Response) (*Response, error) {
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		Status:     resp.Status,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+	}, nil
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spoof
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/websocket"
+)
+
+func TestDialWebSocket(t *testing.T) {
+	var attempts int32
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			mt, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(mt, msg)
+		}
+	}))
+	defer server.Close()
+
+	sc := &SpoofingClient{
+		Client:          server.Client(),
+		RequestInterval: time.Millisecond,
+		RequestTimeout:  5 * time.Second,
+		Logf:            t.Logf,
+		RetryPolicy: &RetryPolicy{
+			RetryResponse: RetryStatusCodes(http.StatusServiceUnavailable),
+		},
+	}
+	conn, err := sc.DialWebSocket("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("DialWebSocket() = %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+		t.Fatalf("WriteMessage() = %v", err)
+	}
+	if _, msg, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("ReadMessage() = %v", err)
+	} else if got, want := string(msg), "hello"; got != want {
+		t.Errorf("ReadMessage() = %q, want %q", got, want)
+	}
+	if got, want := atomic.LoadInt32(&attempts), int32(2); got != want {
+		t.Errorf("Attempts = %d, want %d", got, want)
+	}
+}
+
+func TestDialWebSocketRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	sc := &SpoofingClient{
+		Client:          server.Client(),
+		RequestInterval: time.Millisecond,
+		RequestTimeout:  5 * time.Second,
+		Logf:            t.Logf,
+	}
+	if _, err := sc.DialWebSocket("ws"+strings.TrimPrefix(server.URL, "http"), nil); err == nil {
+		t.Error("DialWebSocket() = nil, wanted an error")
+	}
+}
+
+func TestConsumeEvents(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, "id: 1\nevent: greeting\ndata: hello\n\n")
+		fmt.Fprint(w, "retry: 100\ndata: multi\ndata:line\n\n")
+		fmt.Fprint(w, "data: never read\n\n")
+	}))
+	defer server.Close()
+
+	sc := &SpoofingClient{
+		Client:          server.Client(),
+		RequestInterval: time.Millisecond,
+		RequestTimeout:  5 * time.Second,
+		Logf:            t.Logf,
+		RetryPolicy: &RetryPolicy{
+			RetryResponse: RetryStatusCodes(http.StatusServiceUnavailable),
+		},
+	}
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	var got []Event
+	if err := sc.ConsumeEvents(req, func(e Event) (bool, error) {
+		got = append(got, e)
+		return len(got) == 2, nil
+	}); err != nil {
+		t.Fatalf("ConsumeEvents() = %v", err)
+	}
+
+	want := []Event{{
+		ID:   "1",
+		Type: "greeting",
+		Data: "hello",
+	}, {
+		Data: "multi\nline",
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Events (-want, +got) = %s", diff)
+	}
+}
+
+func TestConsumeEventsWrongContentType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, "data: hello\n\n")
+	}))
+	defer server.Close()
+
+	sc := &SpoofingClient{
+		Client:          server.Client(),
+		RequestInterval: time.Millisecond,
+		RequestTimeout:  5 * time.Second,
+		Logf:            t.Logf,
+	}
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err := sc.ConsumeEvents(req, func(Event) (bool, error) { return true, nil }); err == nil {
+		t.Error("ConsumeEvents() = nil, wanted an error")
+	}
+}
+
+func TestReadEventsUnterminated(t *testing.T) {
+	// An event without a terminating blank line is not dispatched.
+	var got []Event
+	if err := readEvents(bufio.NewScanner(strings.NewReader("data: partial")), func(e Event) (bool, error) {
+		got = append(got, e)
+		return false, nil
+	}); err != nil {
+		t.Fatalf("readEvents() = %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("Events = %v, want none", got)
+	}
+}
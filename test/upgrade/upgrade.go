@@ -0,0 +1,208 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package upgrade provides a framework for upgrade tests: resources are set
+// up before the upgrade, continually probed in the background while the
+// system is upgraded (and downgraded) and verified afterwards.
+//
+//	suite := upgrade.Suite{
+//		PreUpgrade:  []upgrade.Operation{{Name: "CreateService", Handler: createService}},
+//		Background:  []upgrade.BackgroundOperation{{Name: "ProbeService", Probe: probeService}},
+//		Upgrade:     []upgrade.Operation{{Name: "InstallHead", Handler: installHead}},
+//		PostUpgrade: []upgrade.Operation{{Name: "VerifyService", Handler: verifyService}},
+//	}
+//	suite.Execute(t)
+package upgrade
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	// defaultProbeInterval is the interval of probes without one.
+	defaultProbeInterval = time.Second
+	// maxReportedFailures bounds the number of probe failures reported per
+	// background operation.
+	maxReportedFailures = 10
+)
+
+// Operation is a named step of an upgrade test.
+type Operation struct {
+	Name    string
+	Handler func(t *testing.T)
+}
+
+// BackgroundOperation probes the system while it is being upgraded.
+type BackgroundOperation struct {
+	Name string
+
+	// Setup, if set, is called before the probing starts, after the
+	// PreUpgrade operations.
+	Setup func(t *testing.T)
+
+	// Probe is called every Interval from the start of the upgrade until
+	// all upgrade and downgrade steps and their tests are done. Every error
+	// it returns fails the test. It must not use the test's T, as it runs
+	// in the background.
+	Probe func() error
+	// Interval defaults to a second.
+	Interval time.Duration
+
+	// Verify, if set, is called after the probing has stopped.
+	Verify func(t *testing.T)
+}
+
+// Suite defines an upgrade test.
+type Suite struct {
+	// PreUpgrade operations set up and test the system before the upgrade.
+	PreUpgrade []Operation
+	// Background operations probe the system during the upgrade.
+	Background []BackgroundOperation
+	// Upgrade operations upgrade the system, e.g. by installing a newer
+	// release of an operator.
+	Upgrade []Operation
+	// PostUpgrade operations test the system after the upgrade.
+	PostUpgrade []Operation
+	// Downgrade operations, if any, downgrade the system again.
+	Downgrade []Operation
+	// PostDowngrade operations test the system after the downgrade.
+	PostDowngrade []Operation
+}
+
+// Execute runs the suite as subtests of t, in the order of the fields of
+// Suite. A failing Upgrade or Downgrade operation skips the rest of the
+// suite, apart from stopping and verifying the background operations.
+func (s *Suite) Execute(t *testing.T) {
+	if !runPhase(t, "PreUpgradeTests", s.PreUpgrade) {
+		return
+	}
+
+	probes := make([]*probe, 0, len(s.Background))
+	ok := t.Run("BackgroundSetup", func(t *testing.T) {
+		for _, bo := range s.Background {
+			if bo.Setup != nil {
+				t.Run(bo.Name, bo.Setup)
+			}
+		}
+	})
+	if !ok {
+		return
+	}
+	for _, bo := range s.Background {
+		probes = append(probes, startProbe(bo))
+	}
+
+	func() {
+		if !runPhase(t, "UpgradeWith", s.Upgrade) {
+			return
+		}
+		if !runPhase(t, "PostUpgradeTests", s.PostUpgrade) {
+			return
+		}
+		if !runPhase(t, "DowngradeWith", s.Downgrade) {
+			return
+		}
+		runPhase(t, "PostDowngradeTests", s.PostDowngrade)
+	}()
+
+	t.Run("BackgroundVerify", func(t *testing.T) {
+		for _, p := range probes {
+			p := p
+			t.Run(p.op.Name, func(t *testing.T) {
+				for _, f := range p.stop() {
+					t.Error(f)
+				}
+				if p.op.Verify != nil {
+					p.op.Verify(t)
+				}
+			})
+		}
+	})
+}
+
+// runPhase runs the operations as subtests of a subtest with the given
+// name, returning whether they all succeeded. Empty phases are skipped.
+func runPhase(t *testing.T, name string, ops []Operation) bool {
+	if len(ops) == 0 {
+		return true
+	}
+	return t.Run(name, func(t *testing.T) {
+		for _, op := range ops {
+			t.Run(op.Name, op.Handler)
+		}
+	})
+}
+
+// probe runs a background operation's Probe until stopped.
+type probe struct {
+	op   BackgroundOperation
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	// failures and total are only accessed by the probing goroutine until
+	// it is stopped.
+	failures []string
+	total    int
+}
+
+func startProbe(op BackgroundOperation) *probe {
+	p := &probe{
+		op:   op,
+		done: make(chan struct{}),
+	}
+	interval := op.Interval
+	if interval <= 0 {
+		interval = defaultProbeInterval
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			p.run()
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return p
+}
+
+func (p *probe) run() {
+	if err := p.op.Probe(); err != nil {
+		p.total++
+		if len(p.failures) < maxReportedFailures {
+			p.failures = append(p.failures, fmt.Sprintf("%s: probe failed: %v", time.Now().Format(time.RFC3339), err))
+		}
+	}
+}
+
+// stop stops the probing and returns its failures.
+func (p *probe) stop() []string {
+	close(p.done)
+	p.wg.Wait()
+	if p.total > len(p.failures) {
+		return append(p.failures, fmt.Sprintf("... and %d more failures", p.total-len(p.failures)))
+	}
+	return p.failures
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExecute(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		steps []string
+	)
+	record := func(name string) func(*testing.T) {
+		return func(*testing.T) {
+			mu.Lock()
+			defer mu.Unlock()
+			steps = append(steps, name)
+		}
+	}
+
+	var probes int32
+	suite := Suite{
+		PreUpgrade: []Operation{{Name: "Pre", Handler: record("pre")}},
+		Background: []BackgroundOperation{{
+			Name:  "Probe",
+			Setup: record("setup"),
+			Probe: func() error {
+				atomic.AddInt32(&probes, 1)
+				return nil
+			},
+			Interval: time.Millisecond,
+			Verify:   record("verify"),
+		}},
+		Upgrade: []Operation{{Name: "Upgrade", Handler: func(t *testing.T) {
+			record("upgrade")(t)
+			// Give the probe a chance to run during the upgrade.
+			time.Sleep(10 * time.Millisecond)
+		}}},
+		PostUpgrade:   []Operation{{Name: "PostUpgrade", Handler: record("post-upgrade")}},
+		Downgrade:     []Operation{{Name: "Downgrade", Handler: record("downgrade")}},
+		PostDowngrade: []Operation{{Name: "PostDowngrade", Handler: record("post-downgrade")}},
+	}
+	suite.Execute(t)
+
+	want := []string{"pre", "setup", "upgrade", "post-upgrade", "downgrade", "post-downgrade", "verify"}
+	if diff := cmp.Diff(want, steps); diff != "" {
+		t.Errorf("Steps (-want, +got) = %s", diff)
+	}
+	if atomic.LoadInt32(&probes) < 2 {
+		t.Errorf("Probes = %d, wanted the probe to run repeatedly", probes)
+	}
+}
+
+func TestProbeFailures(t *testing.T) {
+	var calls int32
+	p := startProbe(BackgroundOperation{
+		Name: "failing",
+		Probe: func() error {
+			atomic.AddInt32(&calls, 1)
+			return errors.New("boom")
+		},
+		Interval: time.Millisecond,
+	})
+	for atomic.LoadInt32(&calls) <= maxReportedFailures {
+		time.Sleep(time.Millisecond)
+	}
+
+	failures := p.stop()
+	if got, want := len(failures), maxReportedFailures+1; got != want {
+		t.Errorf("Failures = %d, want %d: %v", got, want, failures)
+	}
+}
+
+func TestProbeSuccess(t *testing.T) {
+	p := startProbe(BackgroundOperation{
+		Name:     "passing",
+		Probe:    func() error { return nil },
+		Interval: time.Millisecond,
+	})
+	time.Sleep(5 * time.Millisecond)
+	if failures := p.stop(); len(failures) != 0 {
+		t.Errorf("Failures = %v, want none", failures)
+	}
+}
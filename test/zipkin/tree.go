@@ -0,0 +1,205 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zipkin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// RequestIDTag is the tag Envoy stores the x-request-id header of a request in.
+const RequestIDTag = "guid:x-request-id"
+
+// zipkinAPI is the base URL of the port-forwarded Zipkin API. Overridden by tests.
+var zipkinAPI = "http://localhost:9411/api/v2/"
+
+// TraceIDFromHeader returns the ID of the trace of a request made by the
+// SpoofingClient, from the headers of its response.
+func TraceIDFromHeader(h http.Header) string {
+	return h.Get(ZipkinTraceIDHeader)
+}
+
+// TraceByRequestID returns the trace of the request with the given
+// x-request-id, waiting up to timeout for it to be reported.
+func TraceByRequestID(requestID string, timeout time.Duration) ([]model.SpanModel, error) {
+	return TraceByTag(RequestIDTag, requestID, timeout)
+}
+
+// TraceByTag returns the trace containing a span with the given tag,
+// waiting up to timeout for it to be reported.
+func TraceByTag(key, value string, timeout time.Duration) ([]model.SpanModel, error) {
+	q := url.Values{
+		"annotationQuery": []string{key + "=" + value},
+		"lookback":        []string{"3600000"},
+	}
+	var trace []model.SpanModel
+	err := wait.PollImmediate(time.Second, timeout, func() (bool, error) {
+		var traces [][]model.SpanModel
+		if err := getJSON(zipkinAPI+"traces?"+q.Encode(), &traces); err != nil {
+			return false, err
+		}
+		switch len(traces) {
+		case 0:
+			return false, nil
+		case 1:
+			trace = traces[0]
+			return true, nil
+		default:
+			return false, fmt.Errorf("found %d traces with %s=%s, want 1", len(traces), key, value)
+		}
+	})
+	if err == wait.ErrWaitTimeout {
+		return nil, &TimeoutError{}
+	}
+	return trace, err
+}
+
+func getJSON(u string, v interface{}) error {
+	resp, err := http.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s: %s", u, resp.Status, body)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// SpanTree is a span and its child spans, ordered by their start.
+type SpanTree struct {
+	Span     model.SpanModel
+	Children []*SpanTree
+}
+
+// BuildSpanTree builds the tree of the spans of a trace, which must have
+// exactly one root span.
+func BuildSpanTree(spans []model.SpanModel) (*SpanTree, error) {
+	nodes := make(map[model.ID]*SpanTree, len(spans))
+	for _, s := range spans {
+		nodes[s.ID] = &SpanTree{Span: s}
+	}
+
+	var roots []*SpanTree
+	for _, s := range spans {
+		node := nodes[s.ID]
+		if s.ParentID == nil {
+			roots = append(roots, node)
+			continue
+		}
+		parent, ok := nodes[*s.ParentID]
+		if !ok {
+			return nil, fmt.Errorf("parent %v of span %q is missing from the trace", *s.ParentID, s.Name)
+		}
+		parent.Children = append(parent.Children, node)
+	}
+	if len(roots) != 1 {
+		return nil, fmt.Errorf("trace has %d root spans, want 1", len(roots))
+	}
+
+	for _, node := range nodes {
+		sort.SliceStable(node.Children, func(i, j int) bool {
+			return node.Children[i].Span.Timestamp.Before(node.Children[j].Span.Timestamp)
+		})
+	}
+	return roots[0], nil
+}
+
+// Find returns the spans of the tree with the given name, in depth-first
+// order.
+func (t *SpanTree) Find(name string) []*SpanTree {
+	var found []*SpanTree
+	t.walk(func(n *SpanTree) {
+		if n.Span.Name == name {
+			found = append(found, n)
+		}
+	})
+	return found
+}
+
+func (t *SpanTree) walk(f func(*SpanTree)) {
+	f(t)
+	for _, c := range t.Children {
+		c.walk(f)
+	}
+}
+
+// String renders the tree with one indented span per line.
+func (t *SpanTree) String() string {
+	var sb strings.Builder
+	t.render(&sb, 0)
+	return sb.String()
+}
+
+func (t *SpanTree) render(sb *strings.Builder, depth int) {
+	fmt.Fprintf(sb, "%s%s (%v)\n", strings.Repeat("  ", depth), t.Span.Name, t.Span.Duration)
+	for _, c := range t.Children {
+		c.render(sb, depth+1)
+	}
+}
+
+// ExpectSpan returns the only span with the given name, or an error if
+// there is none or more than one.
+func (t *SpanTree) ExpectSpan(name string) (*SpanTree, error) {
+	found := t.Find(name)
+	if len(found) != 1 {
+		return nil, fmt.Errorf("found %d spans named %q, want 1, in:\n%s", len(found), name, t)
+	}
+	return found[0], nil
+}
+
+// ExpectChild returns an error unless a span named child is a direct child
+// of a span named parent.
+func (t *SpanTree) ExpectChild(parent, child string) error {
+	for _, p := range t.Find(parent) {
+		for _, c := range p.Children {
+			if c.Span.Name == child {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("no span %q is a child of a span %q in:\n%s", child, parent, t)
+}
+
+// ExpectMaxDuration returns an error unless all spans with the given name,
+// of which there must be at least one, took at most max.
+func (t *SpanTree) ExpectMaxDuration(name string, max time.Duration) error {
+	found := t.Find(name)
+	if len(found) == 0 {
+		return fmt.Errorf("found no span named %q in:\n%s", name, t)
+	}
+	for _, s := range found {
+		if s.Span.Duration > max {
+			return fmt.Errorf("span %q took %v, want at most %v", name, s.Span.Duration, max)
+		}
+	}
+	return nil
+}
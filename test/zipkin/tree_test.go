@@ -0,0 +1,113 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zipkin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+// trace is a request through the activator to the user container.
+const traceJSON = `[
+  {"traceId": "0000000000000001", "id": "0000000000000001", "name": "ingress", "timestamp": 1000, "duration": 5000},
+  {"traceId": "0000000000000001", "id": "0000000000000003", "parentId": "0000000000000001", "name": "queue-proxy", "timestamp": 3000, "duration": 2000},
+  {"traceId": "0000000000000001", "id": "0000000000000002", "parentId": "0000000000000001", "name": "activator", "timestamp": 2000, "duration": 1000},
+  {"traceId": "0000000000000001", "id": "0000000000000004", "parentId": "0000000000000003", "name": "user-container", "timestamp": 3500, "duration": 1000}
+]`
+
+func spans(t *testing.T) []model.SpanModel {
+	var spans []model.SpanModel
+	if err := json.Unmarshal([]byte(traceJSON), &spans); err != nil {
+		t.Fatalf("Unmarshal() = %v", err)
+	}
+	return spans
+}
+
+func TestBuildSpanTree(t *testing.T) {
+	tree, err := BuildSpanTree(spans(t))
+	if err != nil {
+		t.Fatalf("BuildSpanTree() = %v", err)
+	}
+
+	want := `ingress (5ms)
+  activator (1ms)
+  queue-proxy (2ms)
+    user-container (1ms)
+`
+	if got := tree.String(); got != want {
+		t.Errorf("String() = %s, want %s", got, want)
+	}
+
+	if _, err := tree.ExpectSpan("activator"); err != nil {
+		t.Errorf("ExpectSpan(activator) = %v", err)
+	}
+	if _, err := tree.ExpectSpan("missing"); err == nil {
+		t.Error("ExpectSpan(missing) = nil, wanted an error")
+	}
+	if err := tree.ExpectChild("queue-proxy", "user-container"); err != nil {
+		t.Errorf("ExpectChild() = %v", err)
+	}
+	if err := tree.ExpectChild("activator", "user-container"); err == nil {
+		t.Error("ExpectChild(activator, user-container) = nil, wanted an error")
+	}
+	if err := tree.ExpectMaxDuration("queue-proxy", 2*time.Millisecond); err != nil {
+		t.Errorf("ExpectMaxDuration() = %v", err)
+	}
+	if err := tree.ExpectMaxDuration("queue-proxy", time.Millisecond); err == nil {
+		t.Error("ExpectMaxDuration(1ms) = nil, wanted an error")
+	}
+}
+
+func TestBuildSpanTreeErrors(t *testing.T) {
+	all := spans(t)
+	if _, err := BuildSpanTree(all[1:]); err == nil {
+		t.Error("BuildSpanTree() without the root = nil, wanted an error")
+	}
+
+	twoRoots := append(all, model.SpanModel{SpanContext: model.SpanContext{ID: 5}, Name: "other"})
+	if _, err := BuildSpanTree(twoRoots); err == nil {
+		t.Error("BuildSpanTree() with two roots = nil, wanted an error")
+	}
+}
+
+func TestTraceByRequestID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Query().Get("annotationQuery"), RequestIDTag+"=abc"; got != want {
+			t.Errorf("annotationQuery = %q, want %q", got, want)
+		}
+		fmt.Fprintf(w, "[%s]", traceJSON)
+	}))
+	defer server.Close()
+
+	orig := zipkinAPI
+	defer func() { zipkinAPI = orig }()
+	zipkinAPI = server.URL + "/api/v2/"
+
+	got, err := TraceByRequestID("abc", 5*time.Second)
+	if err != nil {
+		t.Fatalf("TraceByRequestID() = %v", err)
+	}
+	if len(got) != 4 {
+		t.Errorf("TraceByRequestID() = %d spans, want 4", len(got))
+	}
+}
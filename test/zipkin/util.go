@@ -52,7 +52,7 @@ const (
 )
 
 var (
-	zipkinPortForwardPID int
+	zipkinPortForwarder *monitoring.PortForwarder
 
 	// ZipkinTracingEnabled variable indicating if zipkin tracing is enabled.
 	ZipkinTracingEnabled = false
@@ -66,7 +66,7 @@ var (
 
 // SetupZipkinTracing sets up zipkin tracing which involves:
 // 1. Setting up port-forwarding from localhost to zipkin pod on the cluster
-//    (pid of the process doing Port-Forward is stored in a global variable).
+//    (the forwarder is stored in a global variable).
 // 2. Enable AlwaysSample config for tracing for the SpoofingClient.
 func SetupZipkinTracing(kubeClientset *kubernetes.Clientset, logf logging.FormatLogger) bool {
 	setupOnce.Do(func() {
@@ -75,19 +75,14 @@ func SetupZipkinTracing(kubeClientset *kubernetes.Clientset, logf logging.Format
 			return
 		}
 
-		zipkinPods, err := monitoring.GetPods(kubeClientset, app, istioNS)
+		var err error
+		zipkinPortForwarder, err = monitoring.NewPortForwarder(logf, kubeClientset, istioNS, "app="+app, ZipkinPort, ZipkinPort)
 		if err != nil {
-			logf("Error retrieving Zipkin pod details: %v", err)
+			logf("Error setting up the Zipkin port-forward: %v", err)
 			return
 		}
 
-		zipkinPortForwardPID, err = monitoring.PortForward(logf, zipkinPods, ZipkinPort, ZipkinPort, istioNS)
-		if err != nil {
-			logf("Error starting kubectl port-forward command: %v", err)
-			return
-		}
-
-		logf("Zipkin port-forward process started with PID: %d", zipkinPortForwardPID)
+		logf("Zipkin port-forward started")
 
 		// Applying AlwaysSample config to ensure we propagate zipkin header for every request made by this client.
 		trace.ApplyConfig(trace.Config{DefaultSampler: trace.AlwaysSample()})
@@ -97,7 +92,7 @@ func SetupZipkinTracing(kubeClientset *kubernetes.Clientset, logf logging.Format
 	return ZipkinTracingEnabled
 }
 
-// CleanupZipkinTracingSetup cleans up the Zipkin tracing setup on the machine. This involves killing the process performing port-forward.
+// CleanupZipkinTracingSetup cleans up the Zipkin tracing setup on the machine. This involves stopping the port-forward.
 // This should be called exactly once in TestMain. Likely in the form:
 //
 // func TestMain(m *testing.M) {
@@ -117,7 +112,7 @@ func CleanupZipkinTracingSetup(logf logging.FormatLogger) {
 			return
 		}
 
-		if err := monitoring.Cleanup(zipkinPortForwardPID); err != nil {
+		if err := zipkinPortForwarder.Close(); err != nil {
 			logf("Encountered error killing port-forward process in CleanupZipkinTracingSetup() : %v", err)
 			return
 		}
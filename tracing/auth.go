@@ -0,0 +1,121 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"knative.dev/pkg/tracing/config"
+)
+
+// zipkinHTTPClient returns an http.Client that authenticates with the Zipkin
+// endpoint according to the given configuration. It returns nil if no
+// authentication is configured, in which case the reporter default is used.
+func zipkinHTTPClient(auth config.ZipkinAuth) (*http.Client, error) {
+	if auth.IsEmpty() {
+		return nil, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if auth.CACertFile != "" || auth.ClientCertFile != "" {
+		tlsConfig, err := zipkinTLSConfig(auth)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	var rt http.RoundTripper = transport
+	if auth.BearerTokenFile != "" {
+		// Fail early if the token cannot be read at all.
+		if _, err := readBearerToken(auth.BearerTokenFile); err != nil {
+			return nil, err
+		}
+		rt = &bearerTokenRoundTripper{
+			tokenFile: auth.BearerTokenFile,
+			inner:     transport,
+		}
+	}
+	return &http.Client{Transport: rt}, nil
+}
+
+func zipkinTLSConfig(auth config.ZipkinAuth) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if auth.CACertFile != "" {
+		caCert, err := ioutil.ReadFile(auth.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read zipkin CA certificate: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, errors.New("failed to parse zipkin CA certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if auth.ClientCertFile != "" {
+		certFile, keyFile := auth.ClientCertFile, auth.ClientKeyFile
+		// Validate the key pair upfront, but load it on every handshake so
+		// that rotated certificates are picked up.
+		if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+			return nil, fmt.Errorf("failed to load zipkin client certificate: %v", err)
+		}
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				return nil, err
+			}
+			return &cert, nil
+		}
+	}
+	return tlsConfig, nil
+}
+
+// bearerTokenRoundTripper sets the Authorization header on every request from
+// the contents of tokenFile. The file is re-read for every request so that
+// rotated tokens are picked up.
+type bearerTokenRoundTripper struct {
+	tokenFile string
+	inner     http.RoundTripper
+}
+
+func (rt *bearerTokenRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	token, err := readBearerToken(rt.tokenFile)
+	if err != nil {
+		return nil, err
+	}
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+token)
+	return rt.inner.RoundTrip(r)
+}
+
+func readBearerToken(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read zipkin bearer token: %v", err)
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("zipkin bearer token file %q is empty", path)
+	}
+	return token, nil
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"knative.dev/pkg/tracing/config"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile() = %v", err)
+	}
+	return path
+}
+
+func TestZipkinHTTPClientEmpty(t *testing.T) {
+	client, err := zipkinHTTPClient(config.ZipkinAuth{})
+	if err != nil {
+		t.Fatalf("zipkinHTTPClient() = %v", err)
+	}
+	if client != nil {
+		t.Errorf("zipkinHTTPClient() = %v, wanted nil", client)
+	}
+}
+
+func TestZipkinHTTPClientBearerTokenAndCA(t *testing.T) {
+	var gotAuth string
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "zipkin-auth")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	auth := config.ZipkinAuth{
+		BearerTokenFile: writeFile(t, dir, "token", "secret-token\n"),
+		CACertFile:      writeFile(t, dir, "ca.crt", string(ca)),
+	}
+
+	client, err := zipkinHTTPClient(auth)
+	if err != nil {
+		t.Fatalf("zipkinHTTPClient() = %v", err)
+	}
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	resp.Body.Close()
+
+	if want := "Bearer secret-token"; gotAuth != want {
+		t.Errorf("Authorization = %q, want %q", gotAuth, want)
+	}
+
+	// Rotate the token and make sure it's picked up.
+	writeFile(t, dir, "token", "rotated-token")
+	resp, err = client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	resp.Body.Close()
+	if want := "Bearer rotated-token"; gotAuth != want {
+		t.Errorf("Authorization = %q, want %q", gotAuth, want)
+	}
+}
+
+func TestZipkinHTTPClientErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zipkin-auth")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name string
+		auth config.ZipkinAuth
+	}{{
+		name: "missing token",
+		auth: config.ZipkinAuth{BearerTokenFile: filepath.Join(dir, "missing")},
+	}, {
+		name: "empty token",
+		auth: config.ZipkinAuth{BearerTokenFile: writeFile(t, dir, "empty", "")},
+	}, {
+		name: "bad CA",
+		auth: config.ZipkinAuth{CACertFile: writeFile(t, dir, "bad.crt", "not a cert")},
+	}, {
+		name: "bad client cert",
+		auth: config.ZipkinAuth{
+			ClientCertFile: writeFile(t, dir, "tls.crt", "not a cert"),
+			ClientKeyFile:  writeFile(t, dir, "tls.key", "not a key"),
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := zipkinHTTPClient(test.auth); err == nil {
+				t.Error("zipkinHTTPClient() = nil, wanted error")
+			}
+		})
+	}
+}
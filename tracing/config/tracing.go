@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"time"
 
 	"cloud.google.com/go/compute/metadata"
 	corev1 "k8s.io/api/core/v1"
@@ -36,6 +37,16 @@ const (
 	debugKey                = "debug"
 	sampleRateKey           = "sample-rate"
 	stackdriverProjectIDKey = "stackdriver-project-id"
+
+	zipkinBearerTokenFileKey = "zipkin-bearer-token-file"
+	zipkinCACertFileKey      = "zipkin-ca-cert-file"
+	zipkinClientCertFileKey  = "zipkin-client-cert-file"
+	zipkinClientKeyFileKey   = "zipkin-client-key-file"
+
+	queueSizeKey    = "queue-size"
+	batchSizeKey    = "batch-size"
+	batchTimeoutKey = "batch-timeout"
+	dropPolicyKey   = "drop-policy"
 )
 
 // BackendType specifies the backend to use for tracing
@@ -50,14 +61,69 @@ const (
 	Zipkin BackendType = "zipkin"
 )
 
+// DropPolicy specifies which spans are dropped when the queue of the spans to
+// export is full.
+type DropPolicy string
+
+const (
+	// DropOldest drops the oldest span of the queue to make room for the
+	// new one.
+	DropOldest DropPolicy = "drop-oldest"
+	// DropNewest drops the new span, keeping the queue as it is.
+	DropNewest DropPolicy = "drop-newest"
+)
+
 // Config holds the configuration for tracers
 type Config struct {
 	Backend              BackendType
 	ZipkinEndpoint       string
 	StackdriverProjectID string
 
+	// ZipkinAuth holds the credentials used when talking to the Zipkin endpoint.
+	ZipkinAuth ZipkinAuth
+
 	Debug      bool
 	SampleRate float64
+
+	// Batching configures how the spans are queued and batched before
+	// they're exported.
+	Batching Batching
+}
+
+// Batching configures the queue of the spans to export and how they're
+// batched by the exporter. The zero values select the defaults.
+type Batching struct {
+	// QueueSize is the maximum number of spans waiting to be exported,
+	// beyond which they're dropped according to DropPolicy.
+	QueueSize int
+	// BatchSize is the maximum number of spans exported at once.
+	BatchSize int
+	// BatchTimeout is the maximum time the spans wait for their batch to be
+	// full before being exported.
+	BatchTimeout time.Duration
+	// DropPolicy specifies which spans are dropped when the queue is full.
+	DropPolicy DropPolicy
+}
+
+// ZipkinAuth holds paths to the credentials used to authenticate with a Zipkin
+// endpoint. The paths are expected to point at files projected from a Secret
+// volume, so that rotated credentials are picked up without a restart.
+type ZipkinAuth struct {
+	// BearerTokenFile is the path to a file containing a bearer token that is
+	// sent in the Authorization header of every request.
+	BearerTokenFile string
+	// CACertFile is the path to a PEM encoded CA bundle used to verify the
+	// endpoint's serving certificate.
+	CACertFile string
+	// ClientCertFile and ClientKeyFile are the paths to a PEM encoded client
+	// certificate and key used for mTLS.
+	ClientCertFile string
+	ClientKeyFile  string
+}
+
+// IsEmpty returns true if no authentication has been configured.
+func (za *ZipkinAuth) IsEmpty() bool {
+	return *za == ZipkinAuth{}
 }
 
 // Equals returns true if two Configs are identical
@@ -99,6 +165,16 @@ func NewTracingConfigFromMap(cfgMap map[string]string) (*Config, error) {
 		tc.ZipkinEndpoint = endpoint
 	}
 
+	tc.ZipkinAuth = ZipkinAuth{
+		BearerTokenFile: cfgMap[zipkinBearerTokenFileKey],
+		CACertFile:      cfgMap[zipkinCACertFileKey],
+		ClientCertFile:  cfgMap[zipkinClientCertFileKey],
+		ClientKeyFile:   cfgMap[zipkinClientKeyFileKey],
+	}
+	if (tc.ZipkinAuth.ClientCertFile == "") != (tc.ZipkinAuth.ClientKeyFile == "") {
+		return nil, fmt.Errorf("tracing config %q and %q must be specified together", zipkinClientCertFileKey, zipkinClientKeyFileKey)
+	}
+
 	if projectID, ok := cfgMap[stackdriverProjectIDKey]; ok {
 		tc.StackdriverProjectID = projectID
 	} else if tc.Backend == Stackdriver {
@@ -125,9 +201,57 @@ func NewTracingConfigFromMap(cfgMap map[string]string) (*Config, error) {
 		tc.SampleRate = sampleRateFloat
 	}
 
+	if err := parseBatching(cfgMap, &tc.Batching); err != nil {
+		return nil, err
+	}
+
 	return &tc, nil
 }
 
+func parseBatching(cfgMap map[string]string, b *Batching) error {
+	for _, i := range []struct {
+		key  string
+		into *int
+	}{
+		{queueSizeKey, &b.QueueSize},
+		{batchSizeKey, &b.BatchSize},
+	} {
+		value, ok := cfgMap[i.key]
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("failed parsing tracing config %q: %v", i.key, err)
+		}
+		if n <= 0 {
+			return fmt.Errorf("tracing config %q must be positive, got %d", i.key, n)
+		}
+		*i.into = n
+	}
+
+	if timeout, ok := cfgMap[batchTimeoutKey]; ok {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return fmt.Errorf("failed parsing tracing config %q: %v", batchTimeoutKey, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("tracing config %q must be positive, got %v", batchTimeoutKey, d)
+		}
+		b.BatchTimeout = d
+	}
+
+	if policy, ok := cfgMap[dropPolicyKey]; ok {
+		switch dp := DropPolicy(policy); dp {
+		case DropOldest, DropNewest:
+			b.DropPolicy = dp
+		default:
+			return fmt.Errorf("unsupported tracing drop policy %q", policy)
+		}
+	}
+	return nil
+}
+
 // NewTracingConfigFromConfigMap returns a Config for the given configmap
 func NewTracingConfigFromConfigMap(config *corev1.ConfigMap) (*Config, error) {
 	return NewTracingConfigFromMap(config.Data)
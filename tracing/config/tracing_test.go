@@ -18,6 +18,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	corev1 "k8s.io/api/core/v1"
@@ -123,6 +124,48 @@ func TestNewConfigFromMap(t *testing.T) {
 			StackdriverProjectID: "my-project",
 			SampleRate:           0.5,
 		},
+	}, {
+		name: "Zipkin with authentication",
+		input: map[string]string{
+			backendKey:               "zipkin",
+			zipkinEndpointKey:        "some-endpoint",
+			zipkinBearerTokenFileKey: "/etc/tracing/token",
+			zipkinCACertFileKey:      "/etc/tracing/ca.crt",
+			zipkinClientCertFileKey:  "/etc/tracing/tls.crt",
+			zipkinClientKeyFileKey:   "/etc/tracing/tls.key",
+		},
+		output: Config{
+			Backend:        Zipkin,
+			ZipkinEndpoint: "some-endpoint",
+			ZipkinAuth: ZipkinAuth{
+				BearerTokenFile: "/etc/tracing/token",
+				CACertFile:      "/etc/tracing/ca.crt",
+				ClientCertFile:  "/etc/tracing/tls.crt",
+				ClientKeyFile:   "/etc/tracing/tls.key",
+			},
+			SampleRate: 0.1,
+		},
+	}, {
+		name: "Batching",
+		input: map[string]string{
+			backendKey:        "zipkin",
+			zipkinEndpointKey: "some-endpoint",
+			queueSizeKey:      "5000",
+			batchSizeKey:      "200",
+			batchTimeoutKey:   "5s",
+			dropPolicyKey:     "drop-newest",
+		},
+		output: Config{
+			Backend:        Zipkin,
+			ZipkinEndpoint: "some-endpoint",
+			SampleRate:     0.1,
+			Batching: Batching{
+				QueueSize:    5000,
+				BatchSize:    200,
+				BatchTimeout: 5 * time.Second,
+				DropPolicy:   DropNewest,
+			},
+		},
 	}}
 
 	for _, tc := range tt {
@@ -138,6 +181,50 @@ func TestNewConfigFromMap(t *testing.T) {
 	}
 }
 
+func TestNewConfigFromMapFailures(t *testing.T) {
+	tt := []struct {
+		name  string
+		input map[string]string
+	}{{
+		name: "Client cert without key",
+		input: map[string]string{
+			backendKey:              "zipkin",
+			zipkinEndpointKey:       "some-endpoint",
+			zipkinClientCertFileKey: "/etc/tracing/tls.crt",
+		},
+	}, {
+		name: "Client key without cert",
+		input: map[string]string{
+			backendKey:             "zipkin",
+			zipkinEndpointKey:      "some-endpoint",
+			zipkinClientKeyFileKey: "/etc/tracing/tls.key",
+		},
+	}, {
+		name:  "Invalid queue size",
+		input: map[string]string{queueSizeKey: "many"},
+	}, {
+		name:  "Negative batch size",
+		input: map[string]string{batchSizeKey: "-1"},
+	}, {
+		name:  "Invalid batch timeout",
+		input: map[string]string{batchTimeoutKey: "5"},
+	}, {
+		name:  "Zero batch timeout",
+		input: map[string]string{batchTimeoutKey: "0s"},
+	}, {
+		name:  "Unsupported drop policy",
+		input: map[string]string{dropPolicyKey: "drop-all"},
+	}}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewTracingConfigFromMap(tc.input); err == nil {
+				t.Error("NewTracingConfigFromMap() = nil, wanted error")
+			}
+		})
+	}
+}
+
 func TestConfigFromConfigMap(t *testing.T) {
 	cfg, err := NewTracingConfigFromConfigMap(&corev1.ConfigMap{
 		Data: map[string]string{
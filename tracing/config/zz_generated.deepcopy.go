@@ -20,9 +20,27 @@ limitations under the License.
 
 package config
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Batching) DeepCopyInto(out *Batching) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Batching.
+func (in *Batching) DeepCopy() *Batching {
+	if in == nil {
+		return nil
+	}
+	out := new(Batching)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Config) DeepCopyInto(out *Config) {
 	*out = *in
+	out.ZipkinAuth = in.ZipkinAuth
+	out.Batching = in.Batching
 	return
 }
 
@@ -35,3 +53,19 @@ func (in *Config) DeepCopy() *Config {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZipkinAuth) DeepCopyInto(out *ZipkinAuth) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZipkinAuth.
+func (in *ZipkinAuth) DeepCopy() *ZipkinAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(ZipkinAuth)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"sync"
 
@@ -122,13 +123,19 @@ func WithExporter(name string, logger *zap.SugaredLogger) ConfigOption {
 		switch cfg.Backend {
 		case config.Stackdriver:
 			exp, err := stackdriver.NewExporter(stackdriver.Options{
-				ProjectID: cfg.StackdriverProjectID,
+				ProjectID:            cfg.StackdriverProjectID,
+				BundleCountThreshold: cfg.Batching.BatchSize,
+				BundleDelayThreshold: cfg.Batching.BatchTimeout,
 			})
 			if err != nil {
 				logger.Errorw("error reading project-id from metadata", zap.Error(err))
 				return err
 			}
 			exporter = exp
+			closer = closerFunc(func() error {
+				exp.Flush()
+				return nil
+			})
 		case config.Zipkin:
 			// If name isn't specified, then zipkin.NewEndpoint will return an error saying that it
 			// can't find the host named ''. So, if not specified, default it to this machine's
@@ -146,13 +153,27 @@ func WithExporter(name string, logger *zap.SugaredLogger) ConfigOption {
 				logger.Errorw("error building zipkin endpoint", zap.Error(err))
 				return err
 			}
-			reporter := httpreporter.NewReporter(cfg.ZipkinEndpoint)
+			var reporterOpts []httpreporter.ReporterOption
+			client, err := zipkinHTTPClient(cfg.ZipkinAuth)
+			if err != nil {
+				logger.Errorw("error configuring zipkin authentication", zap.Error(err))
+				return err
+			}
+			if client != nil {
+				reporterOpts = append(reporterOpts, httpreporter.Client(client))
+			}
+			reporterOpts = append(reporterOpts, zipkinBatchingOptions(cfg.Batching)...)
+			reporter := httpreporter.NewReporter(cfg.ZipkinEndpoint, reporterOpts...)
 			exporter = oczipkin.NewExporter(reporter, zipEP)
 			closer = reporter
 		default:
 			// Disables tracing.
 		}
 		if exporter != nil {
+			// The spans are queued, so that a slow backend neither blocks
+			// the requests nor drops the spans silently.
+			queue := newQueuedExporter(exporter, cfg.Backend, cfg.Batching)
+			exporter, closer = queue, closers{queue, closer}
 			trace.RegisterExporter(exporter)
 		}
 		// We know this is set because we are called with acquireGlobal lock held
@@ -160,7 +181,9 @@ func WithExporter(name string, logger *zap.SugaredLogger) ConfigOption {
 			trace.UnregisterExporter(globalOct.exporter)
 		}
 		if globalOct.closer != nil {
-			globalOct.closer.Close()
+			if err := globalOct.closer.Close(); err != nil {
+				logger.Warnw("error closing the previous exporter", zap.Error(err))
+			}
 		}
 
 		globalOct.exporter = exporter
@@ -169,3 +192,22 @@ func WithExporter(name string, logger *zap.SugaredLogger) ConfigOption {
 		return nil
 	}
 }
+
+// zipkinBatchingOptions returns the options of the Zipkin HTTP reporter for the
+// batching configuration, along with a logger counting the spans it drops.
+func zipkinBatchingOptions(b config.Batching) []httpreporter.ReporterOption {
+	opts := []httpreporter.ReporterOption{
+		// The default logger of the reporter, plus the counting.
+		httpreporter.Logger(log.New(zipkinLogWriter{out: os.Stderr}, "", log.LstdFlags)),
+	}
+	if b.QueueSize > 0 {
+		opts = append(opts, httpreporter.MaxBacklog(b.QueueSize))
+	}
+	if b.BatchSize > 0 {
+		opts = append(opts, httpreporter.BatchSize(b.BatchSize))
+	}
+	if b.BatchTimeout > 0 {
+		opts = append(opts, httpreporter.BatchInterval(b.BatchTimeout))
+	}
+	return opts
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+
+	"knative.dev/pkg/metrics"
+	"knative.dev/pkg/tracing/config"
+)
+
+// defaultQueueSize is the size of the queue of the spans to export, unless
+// configured otherwise. It's the default backlog of the Zipkin reporter.
+const defaultQueueSize = 1000
+
+const (
+	// reasonQueueFull is the reason of the spans dropped because the
+	// queue of the spans to export was full.
+	reasonQueueFull = "queue_full"
+	// reasonBacklog is the reason of the spans dropped by the exporter
+	// because its own buffer was full, e.g. because the backend is slow.
+	reasonBacklog = "backlog"
+)
+
+var (
+	droppedSpansStat = stats.Int64(
+		"tracing_dropped_spans",
+		"Number of spans dropped instead of being exported",
+		stats.UnitDimensionless)
+
+	backendKey = tag.MustNewKey("backend")
+	reasonKey  = tag.MustNewKey("reason")
+)
+
+func init() {
+	register()
+}
+
+func register() {
+	if err := view.Register(&view.View{
+		Description: droppedSpansStat.Description(),
+		Measure:     droppedSpansStat,
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{backendKey, reasonKey},
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// reportDroppedSpans records that n spans to export to the backend were
+// dropped for the reason.
+func reportDroppedSpans(backend config.BackendType, reason string, n int) {
+	ctx, err := tag.New(context.Background(),
+		tag.Insert(backendKey, string(backend)),
+		tag.Insert(reasonKey, reason))
+	if err != nil {
+		return
+	}
+	metrics.Record(ctx, droppedSpansStat.M(int64(n)))
+}
+
+// queuedExporter is a trace.Exporter queueing the spans, which are exported by
+// another exporter in the background, so that the spans aren't exported in the
+// critical path. When the queue is full, spans are dropped according to the
+// drop policy and counted in the tracing_dropped_spans metric.
+type queuedExporter struct {
+	exporter trace.Exporter
+	backend  config.BackendType
+	policy   config.DropPolicy
+
+	// mu guards closed. ExportSpan holds it for reading while it queues
+	// the spans, so that Close doesn't close the queue meanwhile. The
+	// concurrent evictions of the oldest spans aren't serialized, a new
+	// span is dropped too if another one took the room first.
+	mu     sync.RWMutex
+	closed bool
+	queue  chan *trace.SpanData
+	done   chan struct{}
+}
+
+var _ trace.Exporter = (*queuedExporter)(nil)
+
+// newQueuedExporter starts a queuedExporter exporting the spans with the
+// exporter of the backend, as configured by the batching configuration.
+func newQueuedExporter(exporter trace.Exporter, backend config.BackendType, b config.Batching) *queuedExporter {
+	size := b.QueueSize
+	if size == 0 {
+		size = defaultQueueSize
+	}
+	policy := b.DropPolicy
+	if policy == "" {
+		policy = config.DropOldest
+	}
+	q := &queuedExporter{
+		exporter: exporter,
+		backend:  backend,
+		policy:   policy,
+		queue:    make(chan *trace.SpanData, size),
+		done:     make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+func (q *queuedExporter) run() {
+	defer close(q.done)
+	for sd := range q.queue {
+		q.exporter.ExportSpan(sd)
+	}
+}
+
+// ExportSpan implements trace.Exporter.
+func (q *queuedExporter) ExportSpan(sd *trace.SpanData) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return
+	}
+	select {
+	case q.queue <- sd:
+		return
+	default:
+	}
+
+	dropped := 1
+	if q.policy == config.DropOldest {
+		// Make room for the new span, which is dropped too if another
+		// one got the room first.
+		select {
+		case <-q.queue:
+		default:
+			// The queue was emptied meanwhile.
+			dropped = 0
+		}
+		select {
+		case q.queue <- sd:
+		default:
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		reportDroppedSpans(q.backend, reasonQueueFull, dropped)
+	}
+}
+
+// Close stops queueing the spans, and returns once the queued ones are
+// exported.
+func (q *queuedExporter) Close() error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.queue)
+	}
+	q.mu.Unlock()
+	<-q.done
+	return nil
+}
+
+// closers closes all of its closers in order, returning the first error.
+type closers []io.Closer
+
+func (cs closers) Close() error {
+	var first error
+	for _, c := range cs {
+		if c == nil {
+			continue
+		}
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// closerFunc is an io.Closer calling the function.
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// zipkinDisposedRegexp matches the lines the Zipkin HTTP reporter logs when it
+// drops spans because its backlog is full.
+var zipkinDisposedRegexp = regexp.MustCompile(`backlog too long, disposing (\d+) spans`)
+
+// zipkinLogWriter is the writer of the logger of the Zipkin HTTP reporter,
+// counting the spans it drops before writing the lines to out.
+type zipkinLogWriter struct {
+	out io.Writer
+}
+
+func (w zipkinLogWriter) Write(p []byte) (int, error) {
+	if m := zipkinDisposedRegexp.FindSubmatch(p); m != nil {
+		if n, err := strconv.Atoi(string(m[1])); err == nil {
+			reportDroppedSpans(config.Zipkin, reasonBacklog, n)
+		}
+	}
+	return w.out.Write(p)
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/trace"
+
+	"knative.dev/pkg/metrics/metricstest"
+	"knative.dev/pkg/tracing/config"
+)
+
+// blockingExporter records the names of the spans it exports, blocking on the
+// first one until released.
+type blockingExporter struct {
+	started  chan struct{}
+	release  chan struct{}
+	mu       sync.Mutex
+	exported []string
+}
+
+func newBlockingExporter() *blockingExporter {
+	return &blockingExporter{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+}
+
+func (e *blockingExporter) ExportSpan(sd *trace.SpanData) {
+	e.mu.Lock()
+	first := len(e.exported) == 0
+	e.exported = append(e.exported, sd.Name)
+	e.mu.Unlock()
+	if first {
+		close(e.started)
+		<-e.release
+	}
+}
+
+func droppedSpans(t *testing.T, backend config.BackendType, reason string) float64 {
+	t.Helper()
+	rows, err := view.RetrieveData(droppedSpansStat.Name())
+	if err != nil {
+		t.Fatalf("RetrieveData() = %v", err)
+	}
+	var sum float64
+	for _, row := range rows {
+		tags := make(map[string]string, len(row.Tags))
+		for _, tag := range row.Tags {
+			tags[tag.Key.Name()] = tag.Value
+		}
+		if tags[backendKey.Name()] == string(backend) && tags[reasonKey.Name()] == reason {
+			sum += row.Data.(*view.SumData).Value
+		}
+	}
+	return sum
+}
+
+// OpenCensus metrics carry global state that need to be reset between unit tests.
+func resetMetrics() {
+	metricstest.Unregister(droppedSpansStat.Name())
+	register()
+}
+
+func TestQueuedExporter(t *testing.T) {
+	resetMetrics()
+	tests := []struct {
+		name     string
+		backend  config.BackendType
+		batching config.Batching
+		spans    int
+		want     []string
+		dropped  float64
+	}{{
+		name:     "fits",
+		backend:  "test-fits",
+		batching: config.Batching{QueueSize: 3},
+		spans:    4,
+		want:     []string{"0", "1", "2", "3"},
+	}, {
+		name:     "default drops the oldest",
+		backend:  "test-default",
+		batching: config.Batching{QueueSize: 2},
+		spans:    5,
+		want:     []string{"0", "3", "4"},
+		dropped:  2,
+	}, {
+		name:     "drop oldest",
+		backend:  "test-oldest",
+		batching: config.Batching{QueueSize: 2, DropPolicy: config.DropOldest},
+		spans:    4,
+		want:     []string{"0", "2", "3"},
+		dropped:  1,
+	}, {
+		name:     "drop newest",
+		backend:  "test-newest",
+		batching: config.Batching{QueueSize: 2, DropPolicy: config.DropNewest},
+		spans:    5,
+		want:     []string{"0", "1", "2"},
+		dropped:  2,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inner := newBlockingExporter()
+			q := newQueuedExporter(inner, test.backend, test.batching)
+
+			// The first span blocks the worker, so that the others
+			// are queued.
+			q.ExportSpan(&trace.SpanData{Name: "0"})
+			<-inner.started
+			for i := 1; i < test.spans; i++ {
+				q.ExportSpan(&trace.SpanData{Name: strconv.Itoa(i)})
+			}
+			close(inner.release)
+			if err := q.Close(); err != nil {
+				t.Fatalf("Close() = %v", err)
+			}
+
+			if diff := cmp.Diff(test.want, inner.exported); diff != "" {
+				t.Errorf("Exported spans (-want, +got): %s", diff)
+			}
+			if got := droppedSpans(t, test.backend, reasonQueueFull); got != test.dropped {
+				t.Errorf("Dropped spans = %v, want: %v", got, test.dropped)
+			}
+		})
+	}
+}
+
+func TestQueuedExporterClosed(t *testing.T) {
+	inner := newBlockingExporter()
+	close(inner.release)
+	q := newQueuedExporter(inner, "test-closed", config.Batching{})
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	// Neither exported nor panicking.
+	q.ExportSpan(&trace.SpanData{Name: "late"})
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if len(inner.exported) != 0 {
+		t.Errorf("Exported spans = %v, want none", inner.exported)
+	}
+}
+
+func TestZipkinLogWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := zipkinLogWriter{out: &out}
+	before := droppedSpans(t, config.Zipkin, reasonBacklog)
+	for _, line := range []string{
+		"2019/11/20 10:00:00 backlog too long, disposing 3 spans\n",
+		"2019/11/20 10:00:01 failed to send the request: EOF\n",
+		"2019/11/20 10:00:02 backlog too long, disposing 4 spans\n",
+	} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() = %v", err)
+		}
+	}
+	if got, want := droppedSpans(t, config.Zipkin, reasonBacklog)-before, 7.0; got != want {
+		t.Errorf("Dropped spans = %v, want: %v", got, want)
+	}
+	if got, want := bytes.Count(out.Bytes(), []byte("\n")), 3; got != want {
+		t.Errorf("Logged %d lines, want: %d", got, want)
+	}
+}
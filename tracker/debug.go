@@ -0,0 +1,102 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracker
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Entry describes an object tracking a reference.
+// +k8s:deepcopy-gen=false
+type Entry struct {
+	// Reference is the tracked reference.
+	Reference Reference `json:"reference"`
+	// Tracker is the namespace/name key of the tracking object.
+	Tracker string `json:"tracker"`
+	// Expiry is when the lease of the tracking object expires, unless
+	// it is renewed.
+	Expiry time.Time `json:"expiry"`
+	// Expired is true if the lease has expired, but the entry has not
+	// been cleaned up yet.
+	Expired bool `json:"expired"`
+}
+
+// Dumper is implemented by the trackers returned by New to list their
+// tracking table.
+type Dumper interface {
+	// Dump returns the current entries of the tracking table.
+	Dump() []Entry
+}
+
+// Check that impl implements Dumper.
+var _ Dumper = (*impl)(nil)
+
+// Dump implements Dumper.
+func (i *impl) Dump() []Entry {
+	i.m.Lock()
+	defer i.m.Unlock()
+
+	entries := []Entry{}
+	for or, s := range i.mapping {
+		for key, expiry := range s {
+			entries = append(entries, Entry{
+				Reference: nameReference(or),
+				Tracker:   key.String(),
+				Expiry:    expiry,
+				Expired:   i.isExpired(expiry),
+			})
+		}
+	}
+	for kin, ms := range i.selectors {
+		for mk, m := range ms {
+			entries = append(entries, Entry{
+				Reference: selectorReference(kin, m),
+				Tracker:   mk.key.String(),
+				Expiry:    m.expiry,
+				Expired:   i.isExpired(m.expiry),
+			})
+		}
+	}
+
+	sort.Slice(entries, func(a, b int) bool {
+		if entries[a].Tracker != entries[b].Tracker {
+			return entries[a].Tracker < entries[b].Tracker
+		}
+		return entries[a].Reference.String() < entries[b].Reference.String()
+	})
+	return entries
+}
+
+// DebugHandler returns a handler responding with the tracking table of the
+// given tracker as JSON, to diagnose why a tracking object was or wasn't
+// called back. Trackers not implementing Dumper are answered with 501.
+func DebugHandler(t Interface) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, ok := t.(Dumper)
+		if !ok {
+			http.Error(w, "the tracker doesn't support dumping its tracking table", http.StatusNotImplemented)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(d.Dump())
+	})
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	. "knative.dev/pkg/testing"
+)
+
+func TestDebugHandler(t *testing.T) {
+	trk := New(func(types.NamespacedName) {}, time.Minute)
+
+	tracking := &Resource{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "tracking",
+		},
+	}
+	byName := corev1.ObjectReference{
+		APIVersion: "ref.knative.dev/v1alpha1",
+		Kind:       "Thing1",
+		Namespace:  "ns",
+		Name:       "foo",
+	}
+	bySelector := Reference{
+		APIVersion: "ref.knative.dev/v1alpha1",
+		Kind:       "Thing1",
+		Namespace:  "ns",
+		Selector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"app": "foo"},
+		},
+	}
+	if err := trk.Track(byName, tracking); err != nil {
+		t.Fatalf("Track() = %v", err)
+	}
+	if err := trk.TrackReference(bySelector, tracking); err != nil {
+		t.Fatalf("TrackReference() = %v", err)
+	}
+
+	resp := httptest.NewRecorder()
+	DebugHandler(trk).ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got, want := resp.Code, http.StatusOK; got != want {
+		t.Fatalf("StatusCode = %d, want %d", got, want)
+	}
+
+	var got []Entry
+	if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal() = %v", err)
+	}
+	want := []Entry{{
+		Reference: Reference{
+			APIVersion: byName.APIVersion,
+			Kind:       byName.Kind,
+			Namespace:  byName.Namespace,
+			Name:       byName.Name,
+		},
+		Tracker: "ns/tracking",
+	}, {
+		Reference: bySelector,
+		Tracker:   "ns/tracking",
+	}}
+	if !cmp.Equal(got, want, cmpopts.IgnoreFields(Entry{}, "Expiry")) {
+		t.Errorf("Dump (-want, +got): %s", cmp.Diff(want, got, cmpopts.IgnoreFields(Entry{}, "Expiry")))
+	}
+	for _, e := range got {
+		if e.Expiry.Before(time.Now()) {
+			t.Errorf("Expiry = %v, wanted it in the future", e.Expiry)
+		}
+	}
+}
+
+type notDumper struct {
+	Interface
+}
+
+func TestDebugHandlerNotImplemented(t *testing.T) {
+	resp := httptest.NewRecorder()
+	DebugHandler(notDumper{}).ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got, want := resp.Code, http.StatusNotImplemented; got != want {
+		t.Errorf("StatusCode = %d, want %d", got, want)
+	}
+}
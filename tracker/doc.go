@@ -14,8 +14,12 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
+// +k8s:deepcopy-gen=package
+
 // Package tracker defines a utility to enable Reconcilers to trigger
 // reconciliations when objects that are cross-referenced change, so
 // that the level-based reconciliation can react to the change.  The
 // prototypical cross-reference in Kubernetes is corev1.ObjectReference.
+// References may also select all of the objects of a kind in a namespace
+// that match a label selector.
 package tracker
@@ -17,6 +17,7 @@ limitations under the License.
 package tracker
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
@@ -24,9 +25,14 @@ import (
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
+	k8sclock "k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/cache"
 
+	"knative.dev/pkg/clock"
 	"knative.dev/pkg/kmeta"
 )
 
@@ -42,20 +48,71 @@ func New(callback func(types.NamespacedName), lease time.Duration) Interface {
 	return &impl{
 		leaseDuration: lease,
 		cb:            callback,
+		clock:         k8sclock.RealClock{},
 	}
 }
 
+// NewWithExpiration is like New, but additionally calls onExpired with the
+// key of the tracking object and the tracked reference once a lease expires
+// without having been renewed, e.g. to enqueue the tracking object so it
+// refreshes or cleans up. Expired leases are collected proactively every
+// lease duration until the context is cancelled. Leases expire as per the
+// clock attached to the context, see clock.WithClock.
+func NewWithExpiration(ctx context.Context, callback func(types.NamespacedName),
+	onExpired func(types.NamespacedName, Reference), lease time.Duration) Interface {
+	i := &impl{
+		leaseDuration: lease,
+		cb:            callback,
+		onExpired:     onExpired,
+		clock:         clock.FromContext(ctx),
+	}
+	go func() {
+		ticker := i.clock.NewTicker(lease)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C():
+				i.collectExpired()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return i
+}
+
 type impl struct {
 	m sync.Mutex
 	// mapping maps from an object reference to the set of
 	// keys for objects watching it.
 	mapping map[corev1.ObjectReference]set
+	// selectors maps from the kind and namespace of the objects
+	// selected by label selectors to the matchers of the objects
+	// watching them.
+	selectors map[kindInNamespace]matchers
+	// lastLabels maps from an object reference to the labels the
+	// object had when last seen by OnChanged, so that the selectors
+	// it stops matching are notified too. Only the objects of the
+	// kinds and namespaces with selectors are remembered, and they
+	// are forgotten once seen deleted or no longer selected.
+	lastLabels map[corev1.ObjectReference]labels.Set
 
 	// The amount of time that an object may watch another
 	// before having to renew the lease.
 	leaseDuration time.Duration
 
 	cb func(types.NamespacedName)
+	// onExpired is called for leases that expired, if set.
+	onExpired func(types.NamespacedName, Reference)
+
+	// clock is the clock leases expire by.
+	clock k8sclock.Clock
+}
+
+// expiration is a lease that expired.
+type expiration struct {
+	key types.NamespacedName
+	ref Reference
 }
 
 // Check that impl implements Interface.
@@ -64,6 +121,29 @@ var _ Interface = (*impl)(nil)
 // set is a map from keys to expirations
 type set map[types.NamespacedName]time.Time
 
+// kindInNamespace identifies the objects of a kind in a namespace.
+type kindInNamespace struct {
+	apiVersion string
+	kind       string
+	namespace  string
+}
+
+// matcherKey identifies a label selector tracked by an object.
+type matcherKey struct {
+	key      types.NamespacedName
+	selector string
+}
+
+// matcher is a label selector tracked by an object until it expires.
+type matcher struct {
+	labelSelector *metav1.LabelSelector
+	selector      labels.Selector
+	expiry        time.Time
+}
+
+// matchers is a map from tracked label selectors to their matchers.
+type matchers map[matcherKey]matcher
+
 // Track implements Interface.
 func (i *impl) Track(ref corev1.ObjectReference, obj interface{}) error {
 	invalidFields := map[string][]string{
@@ -83,13 +163,11 @@ func (i *impl) Track(ref corev1.ObjectReference, obj interface{}) error {
 		return fmt.Errorf("invalid ObjectReference:\n%s", strings.Join(fieldErrors, "\n"))
 	}
 
-	object, err := kmeta.DeletionHandlingAccessor(obj)
+	key, err := trackingKey(obj)
 	if err != nil {
 		return err
 	}
 
-	key := types.NamespacedName{Namespace: object.GetNamespace(), Name: object.GetName()}
-
 	i.m.Lock()
 	defer i.m.Unlock()
 	if i.mapping == nil {
@@ -100,7 +178,13 @@ func (i *impl) Track(ref corev1.ObjectReference, obj interface{}) error {
 	if !ok {
 		l = set{}
 	}
-	if expiry, ok := l[key]; !ok || isExpired(expiry) {
+	expiry, ok := l[key]
+	if !ok {
+		reportTracked(1)
+	} else if i.isExpired(expiry) {
+		reportExpired()
+	}
+	if !ok || i.isExpired(expiry) {
 		// When covering an uncovered key, immediately call the
 		// registered callback to ensure that the following pattern
 		// doesn't create problems:
@@ -115,14 +199,79 @@ func (i *impl) Track(ref corev1.ObjectReference, obj interface{}) error {
 		i.cb(key)
 	}
 	// Overwrite the key with a new expiration.
-	l[key] = time.Now().Add(i.leaseDuration)
+	l[key] = i.clock.Now().Add(i.leaseDuration)
 
 	i.mapping[ref] = l
 	return nil
 }
 
-func isExpired(expiry time.Time) bool {
-	return time.Now().After(expiry)
+// TrackReference implements Interface.
+func (i *impl) TrackReference(ref Reference, obj interface{}) error {
+	if err := ref.Validate(); err != nil {
+		return err
+	}
+	if ref.Selector == nil {
+		return i.Track(ref.ObjectReference(), obj)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(ref.Selector)
+	if err != nil {
+		return err
+	}
+
+	key, err := trackingKey(obj)
+	if err != nil {
+		return err
+	}
+
+	i.m.Lock()
+	defer i.m.Unlock()
+	if i.selectors == nil {
+		i.selectors = make(map[kindInNamespace]matchers)
+	}
+
+	kin := kindInNamespace{
+		apiVersion: ref.APIVersion,
+		kind:       ref.Kind,
+		namespace:  ref.Namespace,
+	}
+	ms, ok := i.selectors[kin]
+	if !ok {
+		ms = matchers{}
+	}
+	mk := matcherKey{key: key, selector: selector.String()}
+	m, ok := ms[mk]
+	if !ok {
+		reportTracked(1)
+	} else if i.isExpired(m.expiry) {
+		reportExpired()
+	}
+	if !ok || i.isExpired(m.expiry) {
+		// Catch up on the changes of the selected objects while the
+		// selector wasn't tracked, like in Track.
+		i.cb(key)
+	}
+	ms[mk] = matcher{
+		labelSelector: ref.Selector.DeepCopy(),
+		selector:      selector,
+		expiry:        i.clock.Now().Add(i.leaseDuration),
+	}
+
+	i.selectors[kin] = ms
+	return nil
+}
+
+// trackingKey returns the key of the tracking object.
+func trackingKey(obj interface{}) (types.NamespacedName, error) {
+	object, err := kmeta.DeletionHandlingAccessor(obj)
+	if err != nil {
+		return types.NamespacedName{}, err
+	}
+	return types.NamespacedName{Namespace: object.GetNamespace(), Name: object.GetName()}, nil
+}
+
+// isExpired returns whether the given expiry has passed.
+func (i *impl) isExpired(expiry time.Time) bool {
+	return i.clock.Now().After(expiry)
 }
 
 // OnChanged implements Interface.
@@ -133,28 +282,188 @@ func (i *impl) OnChanged(obj interface{}) {
 		return
 	}
 
+	_, tombstone := obj.(cache.DeletedFinalStateUnknown)
+	deleted := tombstone || item.GetDeletionTimestamp() != nil
+	i.notifyExpired(i.onChanged(item, deleted))
+}
+
+// onChanged calls back the objects tracking the given one and returns the
+// leases found to be expired on the way.
+func (i *impl) onChanged(item kmeta.Accessor, deleted bool) []expiration {
 	or := kmeta.ObjectReference(item)
 
 	// TODO(mattmoor): Consider locking the mapping (global) for a
 	// smaller scope and leveraging a per-set lock to guard its access.
 	i.m.Lock()
 	defer i.m.Unlock()
-	s, ok := i.mapping[or]
-	if !ok {
-		// TODO(mattmoor): We should consider logging here.
+	kin := kindInNamespace{
+		apiVersion: or.APIVersion,
+		kind:       or.Kind,
+		namespace:  or.Namespace,
+	}
+	ms, selectorsOK := i.selectors[kin]
+	ls := labels.Set(item.GetLabels())
+	previous, seen := i.lastLabels[or]
+	if deleted || !selectorsOK {
+		delete(i.lastLabels, or)
+	} else {
+		if i.lastLabels == nil {
+			i.lastLabels = make(map[corev1.ObjectReference]labels.Set)
+		}
+		i.lastLabels[or] = labels.Merge(nil, ls)
+	}
+
+	var expired []expiration
+	callbacks := 0
+	s, exactOK := i.mapping[or]
+	if exactOK {
+		for key, expiry := range s {
+			// If the expiration has lapsed, then delete the key.
+			if i.isExpired(expiry) {
+				delete(s, key)
+				expired = append(expired, expiration{key: key, ref: nameReference(or)})
+				continue
+			}
+			i.cb(key)
+			callbacks++
+		}
+
+		if len(s) == 0 {
+			delete(i.mapping, or)
+		}
+	}
+
+	if selectorsOK {
+		for mk, m := range ms {
+			// If the expiration has lapsed, then delete the matcher.
+			if i.isExpired(m.expiry) {
+				delete(ms, mk)
+				expired = append(expired, expiration{key: mk.key, ref: selectorReference(kin, m)})
+				continue
+			}
+			// The objects which stop matching are selected by
+			// their previous labels.
+			if m.selector.Matches(ls) || (seen && m.selector.Matches(previous)) {
+				i.cb(mk.key)
+				callbacks++
+			}
+		}
+
+		if len(ms) == 0 {
+			i.forgetSelectors(kin)
+		}
+	}
+
+	if exactOK || selectorsOK {
+		reportFanOut(callbacks)
+	}
+	return expired
+}
+
+// ForgetAllFor implements Interface.
+func (i *impl) ForgetAllFor(obj interface{}) {
+	key, err := trackingKey(obj)
+	if err != nil {
 		return
 	}
 
-	for key, expiry := range s {
-		// If the expiration has lapsed, then delete the key.
-		if isExpired(expiry) {
+	i.m.Lock()
+	defer i.m.Unlock()
+	for or, s := range i.mapping {
+		if _, ok := s[key]; ok {
 			delete(s, key)
-			continue
+			reportTracked(-1)
+		}
+		if len(s) == 0 {
+			delete(i.mapping, or)
+		}
+	}
+	for kin, ms := range i.selectors {
+		for mk := range ms {
+			if mk.key == key {
+				delete(ms, mk)
+				reportTracked(-1)
+			}
+		}
+		if len(ms) == 0 {
+			i.forgetSelectors(kin)
+		}
+	}
+}
+
+// forgetSelectors removes the selectors of the given kind and namespace
+// along with the labels remembered for its objects. It must be called
+// with the lock held.
+func (i *impl) forgetSelectors(kin kindInNamespace) {
+	delete(i.selectors, kin)
+	for or := range i.lastLabels {
+		if or.APIVersion == kin.apiVersion && or.Kind == kin.kind && or.Namespace == kin.namespace {
+			delete(i.lastLabels, or)
 		}
-		i.cb(key)
 	}
+}
+
+// collectExpired removes all of the expired leases.
+func (i *impl) collectExpired() {
+	i.notifyExpired(func() []expiration {
+		i.m.Lock()
+		defer i.m.Unlock()
+		var expired []expiration
+		for or, s := range i.mapping {
+			for key, expiry := range s {
+				if i.isExpired(expiry) {
+					delete(s, key)
+					expired = append(expired, expiration{key: key, ref: nameReference(or)})
+				}
+			}
+			if len(s) == 0 {
+				delete(i.mapping, or)
+			}
+		}
+		for kin, ms := range i.selectors {
+			for mk, m := range ms {
+				if i.isExpired(m.expiry) {
+					delete(ms, mk)
+					expired = append(expired, expiration{key: mk.key, ref: selectorReference(kin, m)})
+				}
+			}
+			if len(ms) == 0 {
+				i.forgetSelectors(kin)
+			}
+		}
+		return expired
+	}())
+}
+
+// notifyExpired reports the expired leases and calls the onExpired callback
+// for them. It must not be called with the lock held, so the callback may
+// track again.
+func (i *impl) notifyExpired(expired []expiration) {
+	for _, e := range expired {
+		reportExpired()
+		reportTracked(-1)
+		if i.onExpired != nil {
+			i.onExpired(e.key, e.ref)
+		}
+	}
+}
+
+// nameReference returns the Reference for the given object reference.
+func nameReference(or corev1.ObjectReference) Reference {
+	return Reference{
+		APIVersion: or.APIVersion,
+		Kind:       or.Kind,
+		Namespace:  or.Namespace,
+		Name:       or.Name,
+	}
+}
 
-	if len(s) == 0 {
-		delete(i.mapping, or)
+// selectorReference returns the Reference for the given matcher.
+func selectorReference(kin kindInNamespace, m matcher) Reference {
+	return Reference{
+		APIVersion: kin.apiVersion,
+		Kind:       kin.kind,
+		Namespace:  kin.namespace,
+		Selector:   m.labelSelector.DeepCopy(),
 	}
 }
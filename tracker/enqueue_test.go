@@ -17,15 +17,18 @@ limitations under the License.
 package tracker
 
 import (
+	"context"
 	"regexp"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/cache"
 
+	"knative.dev/pkg/clock"
 	"knative.dev/pkg/kmeta"
 	. "knative.dev/pkg/testing"
 )
@@ -374,3 +377,382 @@ func TestBadObjectReferences(t *testing.T) {
 		})
 	}
 }
+
+func TestSelectorPaths(t *testing.T) {
+	calls := map[types.NamespacedName]int{}
+	f := func(key types.NamespacedName) {
+		calls[key]++
+	}
+
+	trk := New(f, 100*time.Millisecond)
+
+	thing := func(ns, name string, l map[string]string) *Resource {
+		return &Resource{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "ref.knative.dev/v1alpha1",
+				Kind:       "Thing1",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns,
+				Name:      name,
+				Labels:    l,
+			},
+		}
+	}
+	matching := thing("ns", "foo", map[string]string{"app": "foo"})
+	other := thing("ns", "bar", map[string]string{"app": "bar"})
+	elsewhere := thing("other", "foo", map[string]string{"app": "foo"})
+
+	tracking := thing("ns", "tracking", nil)
+	key := types.NamespacedName{Namespace: "ns", Name: "tracking"}
+	ref := Reference{
+		APIVersion: "ref.knative.dev/v1alpha1",
+		Kind:       "Thing1",
+		Namespace:  "ns",
+		Selector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"app": "foo"},
+		},
+	}
+
+	// New registrations result in an immediate callback.
+	if err := trk.TrackReference(ref, tracking); err != nil {
+		t.Fatalf("TrackReference() = %v", err)
+	}
+	if got, want := calls[key], 1; got != want {
+		t.Errorf("TrackReference() = %v, wanted %v", got, want)
+	}
+
+	// Only matching objects in the namespace trigger callbacks.
+	trk.OnChanged(matching)
+	trk.OnChanged(other)
+	trk.OnChanged(elsewhere)
+	if got, want := calls[key], 2; got != want {
+		t.Errorf("OnChanged() = %v, wanted %v", got, want)
+	}
+	// Only the objects selectors could select are remembered.
+	if _, ok := trk.(*impl).lastLabels[kmeta.ObjectReference(elsewhere)]; ok {
+		t.Error("The labels of an object in an untracked namespace are recorded")
+	}
+
+	// Deleted objects trigger callbacks too.
+	trk.OnChanged(cache.DeletedFinalStateUnknown{Key: "ns/foo", Obj: matching})
+	if got, want := calls[key], 3; got != want {
+		t.Errorf("OnChanged() = %v, wanted %v", got, want)
+	}
+
+	// Objects which stop matching trigger callbacks, but only once.
+	trk.OnChanged(matching)
+	relabeled := thing("ns", "foo", map[string]string{"app": "bar"})
+	trk.OnChanged(relabeled)
+	trk.OnChanged(relabeled)
+	if got, want := calls[key], 5; got != want {
+		t.Errorf("OnChanged() = %v, wanted %v", got, want)
+	}
+	// Objects seen deleted are forgotten.
+	trk.OnChanged(cache.DeletedFinalStateUnknown{Key: "ns/foo", Obj: relabeled})
+	if _, ok := trk.(*impl).lastLabels[kmeta.ObjectReference(relabeled)]; ok {
+		t.Error("The labels of the deleted object are still recorded")
+	}
+
+	// Refreshing the lease doesn't result in a callback.
+	if err := trk.TrackReference(ref, tracking); err != nil {
+		t.Fatalf("TrackReference() = %v", err)
+	}
+	if got, want := calls[key], 5; got != want {
+		t.Errorf("TrackReference() = %v, wanted %v", got, want)
+	}
+
+	// Check that after the sleep duration, we stop getting called.
+	time.Sleep(200 * time.Millisecond)
+	trk.OnChanged(matching)
+	if got, want := calls[key], 5; got != want {
+		t.Errorf("OnChanged() = %v, wanted %v", got, want)
+	}
+	if len(trk.(*impl).selectors) != 0 {
+		t.Error("Timeout passed, but the selector is still tracked")
+	}
+	if len(trk.(*impl).lastLabels) != 0 {
+		t.Error("Timeout passed, but the labels of the selected objects are still recorded")
+	}
+
+	// References by name are tracked like by Track.
+	byName := Reference{
+		APIVersion: "ref.knative.dev/v1alpha1",
+		Kind:       "Thing1",
+		Namespace:  "ns",
+		Name:       "bar",
+	}
+	if err := trk.TrackReference(byName, tracking); err != nil {
+		t.Fatalf("TrackReference() = %v", err)
+	}
+	trk.OnChanged(other)
+	if got, want := calls[key], 7; got != want {
+		t.Errorf("OnChanged() = %v, wanted %v", got, want)
+	}
+}
+
+func TestBadReferences(t *testing.T) {
+	trk := New(func(key types.NamespacedName) {}, 10*time.Millisecond)
+	thing1 := &Resource{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "foo",
+		},
+	}
+
+	tests := []struct {
+		name  string
+		ref   Reference
+		match string
+	}{{
+		name: "Neither Name nor Selector",
+		ref: Reference{
+			APIVersion: "build.knative.dev/v1alpha1",
+			Kind:       "Build",
+			Namespace:  "default",
+		},
+		match: "Name",
+	}, {
+		name: "Both Name and Selector",
+		ref: Reference{
+			APIVersion: "build.knative.dev/v1alpha1",
+			Kind:       "Build",
+			Namespace:  "default",
+			Name:       "kaniko",
+			Selector:   &metav1.LabelSelector{},
+		},
+		match: "Name: must not be set together with Selector",
+	}, {
+		name: "Invalid Selector",
+		ref: Reference{
+			APIVersion: "build.knative.dev/v1alpha1",
+			Kind:       "Build",
+			Namespace:  "default",
+			Selector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key:      "app",
+					Operator: "Bogus",
+				}},
+			},
+		},
+		match: "Selector:",
+	}, {
+		name: "Missing Namespace",
+		ref: Reference{
+			APIVersion: "build.knative.dev/v1alpha1",
+			Kind:       "Build",
+			Selector:   &metav1.LabelSelector{},
+		},
+		match: "Namespace",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := trk.TrackReference(test.ref, thing1); err == nil {
+				t.Error("TrackReference() = nil, wanted error")
+			} else if match, _ := regexp.MatchString(test.match, err.Error()); !match {
+				t.Errorf("TrackReference() = %v, wanted match: %s", err, test.match)
+			}
+		})
+	}
+}
+
+func TestExpirationCallback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	expired := make(chan Reference, 10)
+	trk := NewWithExpiration(ctx, func(types.NamespacedName) {}, func(key types.NamespacedName, ref Reference) {
+		if got, want := key, (types.NamespacedName{Namespace: "ns", Name: "tracking"}); got != want {
+			t.Errorf("onExpired() key = %v, want %v", got, want)
+		}
+		expired <- ref
+	}, 20*time.Millisecond)
+
+	tracking := &Resource{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "tracking",
+		},
+	}
+	ref := Reference{
+		APIVersion: "ref.knative.dev/v1alpha1",
+		Kind:       "Thing1",
+		Namespace:  "ns",
+		Name:       "foo",
+	}
+	if err := trk.TrackReference(ref, tracking); err != nil {
+		t.Fatalf("TrackReference() = %v", err)
+	}
+
+	// The expired lease is collected without any changes to the tracked
+	// object.
+	select {
+	case got := <-expired:
+		if !cmp.Equal(got, ref) {
+			t.Errorf("onExpired() ref (-want, +got): %s", cmp.Diff(ref, got))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the lease to expire")
+	}
+	if len(trk.(*impl).mapping) != 0 {
+		t.Error("Lease expired, but mapping for the reference is still there")
+	}
+
+	// The expiration is only reported once.
+	select {
+	case got := <-expired:
+		t.Errorf("onExpired() = %v, wanted no further calls", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestForgetAllFor(t *testing.T) {
+	calls := 0
+	trk := New(func(types.NamespacedName) {
+		calls++
+	}, time.Minute)
+
+	thing1 := &Resource{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "ref.knative.dev/v1alpha1",
+			Kind:       "Thing1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "foo",
+			Labels:    map[string]string{"app": "foo"},
+		},
+	}
+	tracking := func(name string) *Resource {
+		return &Resource{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ns",
+				Name:      name,
+			},
+		}
+	}
+	deleted, kept := tracking("deleted"), tracking("kept")
+	bySelector := Reference{
+		APIVersion: "ref.knative.dev/v1alpha1",
+		Kind:       "Thing1",
+		Namespace:  "ns",
+		Selector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"app": "foo"},
+		},
+	}
+	for _, obj := range []*Resource{deleted, kept} {
+		if err := trk.Track(kmeta.ObjectReference(thing1), obj); err != nil {
+			t.Fatalf("Track() = %v", err)
+		}
+		if err := trk.TrackReference(bySelector, obj); err != nil {
+			t.Fatalf("TrackReference() = %v", err)
+		}
+	}
+
+	trk.ForgetAllFor(cache.DeletedFinalStateUnknown{Key: "ns/deleted", Obj: deleted})
+
+	calls = 0
+	trk.OnChanged(thing1)
+	if got, want := calls, 2; got != want {
+		t.Errorf("OnChanged() = %v, wanted %v", got, want)
+	}
+
+	trk.ForgetAllFor(kept)
+	if got := trk.(Dumper).Dump(); len(got) != 0 {
+		t.Errorf("Dump() = %v, wanted all references forgotten", got)
+	}
+}
+
+func TestExpirationWithFakeClock(t *testing.T) {
+	fc := clock.NewFake(time.Now())
+	ctx, cancel := context.WithCancel(clock.WithClock(context.Background(), fc))
+	defer cancel()
+
+	expired := make(chan Reference, 10)
+	trk := NewWithExpiration(ctx, func(types.NamespacedName) {}, func(_ types.NamespacedName, ref Reference) {
+		expired <- ref
+	}, time.Hour)
+
+	tracking := &Resource{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "tracking",
+		},
+	}
+	ref := Reference{
+		APIVersion: "ref.knative.dev/v1alpha1",
+		Kind:       "Thing1",
+		Namespace:  "ns",
+		Name:       "foo",
+	}
+	if err := trk.TrackReference(ref, tracking); err != nil {
+		t.Fatalf("TrackReference() = %v", err)
+	}
+
+	// Wait for the collection loop to set up its ticker.
+	for !fc.HasWaiters() {
+		time.Sleep(time.Millisecond)
+	}
+	if entries := trk.(Dumper).Dump(); len(entries) != 1 || entries[0].Expired {
+		t.Fatalf("Dump() = %v, want one unexpired lease", entries)
+	}
+
+	// Two hours later, the lease has expired and been collected.
+	fc.Step(time.Hour)
+	fc.Step(time.Hour)
+	select {
+	case got := <-expired:
+		if !cmp.Equal(got, ref) {
+			t.Errorf("onExpired() ref (-want, +got): %s", cmp.Diff(ref, got))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the lease to expire")
+	}
+}
+
+func TestForgetAllForSelectors(t *testing.T) {
+	trk := New(func(types.NamespacedName) {}, time.Hour)
+
+	thing := &Resource{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "ref.knative.dev/v1alpha1",
+			Kind:       "Thing1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "foo",
+			Labels:    map[string]string{"app": "foo"},
+		},
+	}
+	tracking := &Resource{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "tracking",
+		},
+	}
+	ref := Reference{
+		APIVersion: "ref.knative.dev/v1alpha1",
+		Kind:       "Thing1",
+		Namespace:  "ns",
+		Selector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"app": "foo"},
+		},
+	}
+
+	if err := trk.TrackReference(ref, tracking); err != nil {
+		t.Fatalf("TrackReference() = %v", err)
+	}
+	trk.OnChanged(thing)
+	if _, ok := trk.(*impl).lastLabels[kmeta.ObjectReference(thing)]; !ok {
+		t.Error("The labels of the selectable object are not recorded")
+	}
+
+	trk.ForgetAllFor(tracking)
+	if len(trk.(*impl).selectors) != 0 {
+		t.Error("ForgetAllFor() left the selector tracked")
+	}
+	if len(trk.(*impl).lastLabels) != 0 {
+		t.Error("ForgetAllFor() left the labels of the selected objects recorded")
+	}
+}
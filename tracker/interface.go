@@ -17,9 +17,39 @@ limitations under the License.
 package tracker
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
+// Reference is used to refer to objects of a particular kind in a
+// particular namespace, either a single one by name or all of the ones
+// matching a label selector.
+type Reference struct {
+	// APIVersion of the referenced objects.
+	APIVersion string `json:"apiVersion"`
+
+	// Kind of the referenced objects.
+	Kind string `json:"kind"`
+
+	// Namespace of the referenced objects.
+	Namespace string `json:"namespace"`
+
+	// Name of the referenced object. Exactly one of Name or Selector
+	// must be set.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Selector of the referenced objects. Exactly one of Name or Selector
+	// must be set.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
 // Interface defines the interface through which an object can register
 // that it is tracking another object by reference.
 type Interface interface {
@@ -27,7 +57,68 @@ type Interface interface {
 	// referenced object.
 	Track(ref corev1.ObjectReference, obj interface{}) error
 
+	// TrackReference tells us that "obj" is tracking changes to the
+	// objects matching the reference, which may be a label selector.
+	TrackReference(ref Reference, obj interface{}) error
+
 	// OnChanged is a callback to register with the InformerFactory
 	// so that we are notified for appropriate object changes.
 	OnChanged(obj interface{})
+
+	// ForgetAllFor forgets all of the references tracked by "obj". It is
+	// meant to be called when "obj" is deleted, e.g. by registering it as
+	// the DeleteFunc of the tracking objects' informer, so the references
+	// don't linger until their leases expire.
+	ForgetAllFor(obj interface{})
+}
+
+// Validate checks that the reference is valid, returning an error listing
+// the invalid fields otherwise.
+func (ref *Reference) Validate() error {
+	invalidFields := map[string][]string{
+		"APIVersion": validation.IsQualifiedName(ref.APIVersion),
+		"Kind":       validation.IsCIdentifier(ref.Kind),
+		"Namespace":  validation.IsDNS1123Label(ref.Namespace),
+	}
+	switch {
+	case ref.Selector != nil && ref.Name != "":
+		invalidFields["Name"] = []string{"must not be set together with Selector"}
+	case ref.Selector != nil:
+		if _, err := metav1.LabelSelectorAsSelector(ref.Selector); err != nil {
+			invalidFields["Selector"] = []string{err.Error()}
+		}
+	default:
+		invalidFields["Name"] = validation.IsDNS1123Subdomain(ref.Name)
+	}
+
+	fieldErrors := []string{}
+	for k, v := range invalidFields {
+		for _, msg := range v {
+			fieldErrors = append(fieldErrors, fmt.Sprintf("%s: %s", k, msg))
+		}
+	}
+	if len(fieldErrors) > 0 {
+		sort.Strings(fieldErrors)
+		return fmt.Errorf("invalid Reference:\n%s", strings.Join(fieldErrors, "\n"))
+	}
+	return nil
+}
+
+// ObjectReference returns the corev1.ObjectReference of a reference by name.
+func (ref *Reference) ObjectReference() corev1.ObjectReference {
+	return corev1.ObjectReference{
+		APIVersion: ref.APIVersion,
+		Kind:       ref.Kind,
+		Namespace:  ref.Namespace,
+		Name:       ref.Name,
+	}
+}
+
+// String returns a readable representation of the reference.
+func (ref *Reference) String() string {
+	s := ref.APIVersion + ", Kind=" + ref.Kind + ", " + ref.Namespace + "/"
+	if ref.Selector != nil {
+		return s + "{" + metav1.FormatLabelSelector(ref.Selector) + "}"
+	}
+	return s + ref.Name
 }
@@ -0,0 +1,269 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"knative.dev/pkg/clock"
+	"knative.dev/pkg/logging"
+)
+
+// entriesKey is the key of the ConfigMaps' data holding the entries.
+const entriesKey = "entries"
+
+// Store persists the tracking table of a tracker.
+type Store interface {
+	// Load returns the persisted entries, none if nothing was persisted yet.
+	Load() ([]Entry, error)
+
+	// Save persists the given entries, replacing the ones previously saved.
+	Save([]Entry) error
+}
+
+// Restorer is implemented by the trackers returned by New to restore
+// a tracking table persisted from their Dump.
+type Restorer interface {
+	// Restore adds the entries whose leases haven't expired to the
+	// tracking table, without calling back the tracking objects.
+	Restore([]Entry) error
+}
+
+// Check that impl implements Restorer.
+var _ Restorer = (*impl)(nil)
+
+// Restore implements Restorer.
+func (i *impl) Restore(entries []Entry) error {
+	i.m.Lock()
+	defer i.m.Unlock()
+	if i.mapping == nil {
+		i.mapping = make(map[corev1.ObjectReference]set)
+	}
+	if i.selectors == nil {
+		i.selectors = make(map[kindInNamespace]matchers)
+	}
+
+	// Validate all of the entries first, so they're restored all or none.
+	keys := make([]types.NamespacedName, len(entries))
+	for idx, e := range entries {
+		if err := e.Reference.Validate(); err != nil {
+			return err
+		}
+		key, err := parseTrackingKey(e.Tracker)
+		if err != nil {
+			return err
+		}
+		keys[idx] = key
+	}
+
+	for idx, e := range entries {
+		if i.isExpired(e.Expiry) {
+			continue
+		}
+		key := keys[idx]
+		if e.Reference.Selector == nil {
+			or := e.Reference.ObjectReference()
+			s, ok := i.mapping[or]
+			if !ok {
+				s = make(set)
+				i.mapping[or] = s
+			}
+			if expiry, ok := s[key]; !ok {
+				reportTracked(1)
+			} else if expiry.After(e.Expiry) {
+				continue
+			}
+			s[key] = e.Expiry
+			continue
+		}
+
+		// The selector was validated above.
+		selector, _ := metav1.LabelSelectorAsSelector(e.Reference.Selector)
+		kin := kindInNamespace{
+			apiVersion: e.Reference.APIVersion,
+			kind:       e.Reference.Kind,
+			namespace:  e.Reference.Namespace,
+		}
+		ms, ok := i.selectors[kin]
+		if !ok {
+			ms = matchers{}
+			i.selectors[kin] = ms
+		}
+		mk := matcherKey{key: key, selector: selector.String()}
+		if m, ok := ms[mk]; !ok {
+			reportTracked(1)
+		} else if m.expiry.After(e.Expiry) {
+			continue
+		}
+		ms[mk] = matcher{
+			labelSelector: e.Reference.Selector.DeepCopy(),
+			selector:      selector,
+			expiry:        e.Expiry,
+		}
+	}
+	return nil
+}
+
+// parseTrackingKey parses the namespace/name key of a tracking object.
+func parseTrackingKey(s string) (types.NamespacedName, error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return types.NamespacedName{}, fmt.Errorf("invalid tracker key %q", s)
+	}
+	return types.NamespacedName{Namespace: parts[0], Name: parts[1]}, nil
+}
+
+// Persist restores the tracking table of the tracker from the store, then
+// saves it to the store every period until the context is cancelled, and
+// once more then. This lets controllers be called back for the changes of
+// the tracked objects which happen while they restart, before the tracking
+// objects are resynced and track them again. The tracker must implement both
+// Dumper and Restorer, as the trackers returned by New do. Leases are saved
+// as per the clock attached to the context, see clock.WithClock.
+func Persist(ctx context.Context, t Interface, store Store, period time.Duration) error {
+	d, ok := t.(Dumper)
+	if !ok {
+		return fmt.Errorf("the tracker doesn't support dumping its tracking table")
+	}
+	r, ok := t.(Restorer)
+	if !ok {
+		return fmt.Errorf("the tracker doesn't support restoring its tracking table")
+	}
+
+	entries, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load the tracking table: %v", err)
+	}
+	if err := r.Restore(entries); err != nil {
+		return fmt.Errorf("failed to restore the tracking table: %v", err)
+	}
+
+	logger := logging.FromContext(ctx)
+	save := func() {
+		if err := store.Save(unexpired(d.Dump())); err != nil {
+			logger.Errorw("Failed to save the tracking table", zap.Error(err))
+		}
+	}
+	go func() {
+		ticker := clock.FromContext(ctx).NewTicker(period)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C():
+				save()
+			case <-ctx.Done():
+				save()
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// unexpired returns the entries whose leases haven't expired.
+func unexpired(entries []Entry) []Entry {
+	res := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		if !e.Expired {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// configMapStore is a Store persisting the entries as JSON in a ConfigMap.
+type configMapStore struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+	labels    map[string]string
+}
+
+// NewConfigMapStore returns a Store persisting the entries in the ConfigMap
+// with the given name and namespace, which it creates with the given labels
+// if it doesn't exist. Since ConfigMaps are limited to 1MiB, it is meant for
+// trackers with moderately sized tracking tables.
+func NewConfigMapStore(client kubernetes.Interface, namespace, name string, lbls map[string]string) Store {
+	return &configMapStore{
+		client:    client,
+		namespace: namespace,
+		name:      name,
+		labels:    lbls,
+	}
+}
+
+// Load implements Store.
+func (s *configMapStore) Load() ([]Entry, error) {
+	cm, err := s.client.CoreV1().ConfigMaps(s.namespace).Get(s.name, metav1.GetOptions{})
+	if apierrs.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	raw, ok := cm.Data[entriesKey]
+	if !ok {
+		return nil, nil
+	}
+	var entries []Entry
+	if err := json.Unmarshal([]byte(raw), &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse the entries of ConfigMap %s/%s: %v", s.namespace, s.name, err)
+	}
+	return entries, nil
+}
+
+// Save implements Store.
+func (s *configMapStore) Save(entries []Entry) error {
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	cms := s.client.CoreV1().ConfigMaps(s.namespace)
+	cm, err := cms.Get(s.name, metav1.GetOptions{})
+	if apierrs.IsNotFound(err) {
+		_, err = cms.Create(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: s.namespace,
+				Name:      s.name,
+				Labels:    s.labels,
+			},
+			Data: map[string]string{entriesKey: string(b)},
+		})
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	cm = cm.DeepCopy()
+	if cm.Data == nil {
+		cm.Data = make(map[string]string, 1)
+	}
+	cm.Data[entriesKey] = string(b)
+	_, err = cms.Update(cm)
+	return err
+}
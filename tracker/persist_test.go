@@ -0,0 +1,198 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakekubeclientset "k8s.io/client-go/kubernetes/fake"
+
+	"knative.dev/pkg/clock"
+	. "knative.dev/pkg/testing"
+)
+
+func TestConfigMapStore(t *testing.T) {
+	client := fakekubeclientset.NewSimpleClientset()
+	store := NewConfigMapStore(client, "system", "tracker", map[string]string{"app": "controller"})
+
+	if entries, err := store.Load(); err != nil || len(entries) != 0 {
+		t.Fatalf("Load() = %v, %v, wanted no entries", entries, err)
+	}
+
+	expiry := time.Now().Add(time.Hour).Round(time.Second)
+	want := []Entry{{
+		Reference: Reference{
+			APIVersion: "ref.knative.dev/v1alpha1",
+			Kind:       "Thing1",
+			Namespace:  "ns",
+			Name:       "foo",
+		},
+		Tracker: "ns/tracking",
+		Expiry:  expiry,
+	}}
+	// Saving creates the ConfigMap, then updates it.
+	for i := 0; i < 2; i++ {
+		if err := store.Save(want); err != nil {
+			t.Fatalf("Save() = %v", err)
+		}
+		got, err := store.Load()
+		if err != nil {
+			t.Fatalf("Load() = %v", err)
+		}
+		if !cmp.Equal(got, want) {
+			t.Errorf("Load (-want, +got): %s", cmp.Diff(want, got))
+		}
+	}
+
+	cm, err := client.CoreV1().ConfigMaps("system").Get("tracker", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if got, want := cm.Labels["app"], "controller"; got != want {
+		t.Errorf("Labels[app] = %q, wanted %q", got, want)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	calls := 0
+	trk := New(func(types.NamespacedName) { calls++ }, time.Minute)
+
+	ref := Reference{
+		APIVersion: "ref.knative.dev/v1alpha1",
+		Kind:       "Thing1",
+		Namespace:  "ns",
+		Name:       "foo",
+	}
+	bySelector := Reference{
+		APIVersion: "ref.knative.dev/v1alpha1",
+		Kind:       "Thing1",
+		Namespace:  "ns",
+		Selector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"app": "foo"},
+		},
+	}
+	entries := []Entry{{
+		Reference: ref,
+		Tracker:   "ns/tracking",
+		Expiry:    time.Now().Add(time.Hour),
+	}, {
+		Reference: bySelector,
+		Tracker:   "ns/selecting",
+		Expiry:    time.Now().Add(time.Hour),
+	}, {
+		Reference: ref,
+		Tracker:   "ns/expired",
+		Expiry:    time.Now().Add(-time.Hour),
+	}}
+	if err := trk.(Restorer).Restore(entries); err != nil {
+		t.Fatalf("Restore() = %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("Restore() called back %d times, wanted none", calls)
+	}
+	if got := trk.(Dumper).Dump(); len(got) != 2 {
+		t.Errorf("Dump() = %v, wanted the 2 unexpired entries", got)
+	}
+
+	// The restored entries are called back on changes.
+	trk.OnChanged(&Resource{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: ref.APIVersion,
+			Kind:       ref.Kind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: ref.Namespace,
+			Name:      ref.Name,
+			Labels:    map[string]string{"app": "foo"},
+		},
+	})
+	if calls != 2 {
+		t.Errorf("OnChanged() called back %d times, wanted 2", calls)
+	}
+
+	// Invalid entries aren't restored.
+	trk = New(func(types.NamespacedName) {}, time.Minute)
+	invalid := append(entries, Entry{Reference: ref, Tracker: "tracking", Expiry: time.Now().Add(time.Hour)})
+	if err := trk.(Restorer).Restore(invalid); err == nil {
+		t.Error("Restore() = nil, wanted an error")
+	}
+	if got := trk.(Dumper).Dump(); len(got) != 0 {
+		t.Errorf("Dump() = %v, wanted no entries", got)
+	}
+}
+
+func TestPersist(t *testing.T) {
+	client := fakekubeclientset.NewSimpleClientset()
+	store := NewConfigMapStore(client, "system", "tracker", nil)
+
+	fc := clock.NewFake(time.Now())
+	ctx, cancel := context.WithCancel(clock.WithClock(context.Background(), fc))
+	defer cancel()
+
+	trk := New(func(types.NamespacedName) {}, time.Hour)
+	if err := Persist(ctx, trk, store, time.Minute); err != nil {
+		t.Fatalf("Persist() = %v", err)
+	}
+	ref := corev1.ObjectReference{
+		APIVersion: "ref.knative.dev/v1alpha1",
+		Kind:       "Thing1",
+		Namespace:  "ns",
+		Name:       "foo",
+	}
+	tracking := &Resource{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "tracking",
+		},
+	}
+	if err := trk.Track(ref, tracking); err != nil {
+		t.Fatalf("Track() = %v", err)
+	}
+
+	// Wait for the saving loop to set up its ticker, then tick.
+	for !fc.HasWaiters() {
+		time.Sleep(time.Millisecond)
+	}
+	fc.Step(time.Minute)
+	var entries []Entry
+	for start := time.Now(); len(entries) == 0 && time.Since(start) < 5*time.Second; {
+		time.Sleep(10 * time.Millisecond)
+		entries, _ = store.Load()
+	}
+	if len(entries) != 1 || entries[0].Tracker != "ns/tracking" {
+		t.Fatalf("Load() = %v, wanted the tracked entry", entries)
+	}
+
+	// A new tracker picks up where the previous one left off.
+	restarted := New(func(types.NamespacedName) {}, time.Hour)
+	if err := Persist(ctx, restarted, store, time.Minute); err != nil {
+		t.Fatalf("Persist() = %v", err)
+	}
+	if got := restarted.(Dumper).Dump(); len(got) != 1 || got[0].Tracker != "ns/tracking" {
+		t.Errorf("Dump() = %v, wanted the tracked entry", got)
+	}
+
+	if err := Persist(ctx, notDumper{trk}, store, time.Minute); err == nil {
+		t.Error("Persist() = nil, wanted an error for a tracker not implementing Dumper")
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracker
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"knative.dev/pkg/metrics"
+)
+
+var (
+	trackedReferencesStat = stats.Int64(
+		"tracker_tracked_references",
+		"Number of references tracked by objects, by name or by label selector",
+		stats.UnitDimensionless)
+	leaseExpirationsStat = stats.Int64(
+		"tracker_lease_expirations",
+		"Number of tracked references whose lease expired without being renewed",
+		stats.UnitDimensionless)
+	callbackFanOutStat = stats.Int64(
+		"tracker_callback_fanout",
+		"Number of tracking objects called back for a change of a tracked object",
+		stats.UnitDimensionless)
+)
+
+func init() {
+	if err := view.Register(&view.View{
+		Description: trackedReferencesStat.Description(),
+		Measure:     trackedReferencesStat,
+		// The measurements are deltas, summing them up yields the current count.
+		Aggregation: view.Sum(),
+	}, &view.View{
+		Description: leaseExpirationsStat.Description(),
+		Measure:     leaseExpirationsStat,
+		Aggregation: view.Count(),
+	}, &view.View{
+		Description: callbackFanOutStat.Description(),
+		Measure:     callbackFanOutStat,
+		Aggregation: view.Distribution(metrics.Buckets125(1, 1000)...),
+	}); err != nil {
+		panic(err)
+	}
+}
+
+func reportTracked(delta int64) {
+	metrics.Record(context.Background(), trackedReferencesStat.M(delta))
+}
+
+func reportExpired() {
+	metrics.Record(context.Background(), leaseExpirationsStat.M(1))
+}
+
+func reportFanOut(callbacks int) {
+	metrics.Record(context.Background(), callbackFanOutStat.M(int64(callbacks)))
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"knative.dev/pkg/kmeta"
+	"knative.dev/pkg/metrics/metricstest"
+	. "knative.dev/pkg/testing"
+)
+
+func TestStatsReported(t *testing.T) {
+	trk := New(func(types.NamespacedName) {}, 10*time.Millisecond)
+
+	tracked := &Resource{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "ref.knative.dev/v1alpha1",
+			Kind:       "Thing1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "foo",
+		},
+	}
+	tracking := &Resource{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "tracking",
+		},
+	}
+	if err := trk.Track(kmeta.ObjectReference(tracked), tracking); err != nil {
+		t.Fatalf("Track() = %v", err)
+	}
+	trk.OnChanged(tracked)
+	time.Sleep(20 * time.Millisecond)
+	trk.OnChanged(tracked)
+
+	metricstest.CheckStatsReported(t,
+		"tracker_tracked_references",
+		"tracker_lease_expirations",
+		"tracker_callback_fanout")
+}
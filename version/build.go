@@ -0,0 +1,179 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package version
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"knative.dev/pkg/changeset"
+)
+
+// DefaultModules are the prefixes of the paths of the modules reported by
+// default, i.e. the Knative and Kubernetes libraries.
+var DefaultModules = []string{"knative.dev/", "k8s.io/", "sigs.k8s.io/"}
+
+// readBuildInfo is replaced by the tests, whose binaries aren't stamped with
+// the modules.
+var readBuildInfo = debug.ReadBuildInfo
+
+// BuildInfo is what code a binary runs: the commit it was built from, see
+// changeset.GetInfo, the Go toolchain, and the versions of the modules it was
+// built with.
+type BuildInfo struct {
+	Commit    string     `json:"commit,omitempty"`
+	Branch    string     `json:"branch,omitempty"`
+	Tag       string     `json:"tag,omitempty"`
+	Dirty     bool       `json:"dirty,omitempty"`
+	BuildDate *time.Time `json:"buildDate,omitempty"`
+	// CommitError is why the commit is unknown, if it is.
+	CommitError string `json:"commitError,omitempty"`
+
+	// GoVersion is the version of Go the binary was built with, e.g.
+	// "go1.13.4", and Platform its OS and architecture, e.g. "linux/amd64".
+	GoVersion string `json:"goVersion"`
+	Platform  string `json:"platform"`
+
+	// Main is the module of the binary, if it was built in module mode.
+	Main *Module `json:"main,omitempty"`
+	// Modules are the dependencies of the binary which are reported, sorted
+	// by path.
+	Modules []Module `json:"modules,omitempty"`
+}
+
+// Module is a module a binary was built with.
+type Module struct {
+	Path    string `json:"path"`
+	Version string `json:"version,omitempty"`
+	// Replace is the module it was replaced with, if any.
+	Replace *Module `json:"replace,omitempty"`
+}
+
+// GetBuildInfo returns the build information of the binary, with the modules
+// whose path starts with one of the given prefixes, e.g. DefaultModules, or
+// all of them if none is given.
+func GetBuildInfo(modules ...string) BuildInfo {
+	info := BuildInfo{
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if ci, err := changeset.GetInfo(); err != nil {
+		info.CommitError = err.Error()
+	} else {
+		info.Commit = ci.Commit
+		info.Branch = ci.Branch
+		info.Tag = ci.Tag
+		info.Dirty = ci.Dirty
+		if !ci.BuildDate.IsZero() {
+			info.BuildDate = &ci.BuildDate
+		}
+	}
+
+	bi, ok := readBuildInfo()
+	if !ok {
+		return info
+	}
+	if bi.Main.Path != "" {
+		mainModule := newModule(&bi.Main)
+		info.Main = &mainModule
+	}
+	for _, dep := range bi.Deps {
+		if dep != nil && hasPrefix(dep.Path, modules) {
+			info.Modules = append(info.Modules, newModule(dep))
+		}
+	}
+	sort.Slice(info.Modules, func(i, j int) bool {
+		return info.Modules[i].Path < info.Modules[j].Path
+	})
+	return info
+}
+
+func newModule(m *debug.Module) Module {
+	mod := Module{Path: m.Path, Version: m.Version}
+	if m.Replace != nil {
+		replace := newModule(m.Replace)
+		mod.Replace = &replace
+	}
+	return mod
+}
+
+func hasPrefix(path string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// String describes the build information on a line, e.g. to log it at
+// startup, without the modules.
+func (i BuildInfo) String() string {
+	var sb strings.Builder
+	if i.Commit == "" {
+		sb.WriteString("unknown commit")
+	} else {
+		sb.WriteString("commit " + i.Commit)
+	}
+	if i.Dirty {
+		sb.WriteString(" (dirty)")
+	}
+	if i.Tag != "" {
+		fmt.Fprintf(&sb, ", tag %s", i.Tag)
+	}
+	if i.Branch != "" {
+		fmt.Fprintf(&sb, ", branch %s", i.Branch)
+	}
+	if i.BuildDate != nil {
+		fmt.Fprintf(&sb, ", built %s", i.BuildDate.Format(time.RFC3339))
+	}
+	fmt.Fprintf(&sb, ", %s %s", i.GoVersion, i.Platform)
+	return sb.String()
+}
+
+// BuildInfoHandler returns an HTTP handler serving the BuildInfo of the binary
+// as JSON, with the modules whose path starts with one of the given prefixes,
+// or with the ones of the "module" query parameters, to tell what code a pod
+// runs while debugging, e.g.
+//
+//	http.Handle("/version", version.BuildInfoHandler(version.DefaultModules...))
+//
+//	kubectl exec <pod> -- curl -s localhost:8008/version?module=knative.dev/
+func BuildInfoHandler(modules ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		selected := modules
+		if query := r.URL.Query()["module"]; len(query) > 0 {
+			selected = query
+		}
+		b, err := json.MarshalIndent(GetBuildInfo(selected...), "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+}
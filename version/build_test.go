@@ -0,0 +1,201 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package version
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"runtime"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"knative.dev/pkg/changeset"
+)
+
+var (
+	testCommit = "0123456789abcdef0123456789abcdef01234567"
+	testDate   = time.Date(2019, 12, 1, 10, 0, 0, 0, time.UTC)
+	testModule = Module{Path: "knative.dev/serving", Version: "(devel)"}
+	testPkg    = Module{
+		Path:    "knative.dev/pkg",
+		Version: "v0.0.0-20191201100000-0123456789ab",
+		Replace: &Module{Path: "../pkg"},
+	}
+	testAPI  = Module{Path: "k8s.io/api", Version: "v0.16.4"}
+	testZap  = Module{Path: "go.uber.org/zap", Version: "v1.10.0"}
+	platform = runtime.GOOS + "/" + runtime.GOARCH
+)
+
+// setBuildInfo makes the binary look built in module mode, and returns the
+// function restoring its build information.
+func setBuildInfo(t *testing.T) func() {
+	t.Helper()
+	restoreInfo := changeset.SetForTesting(changeset.Info{
+		Commit:    testCommit,
+		Branch:    "master",
+		Tag:       "v0.11.0",
+		BuildDate: testDate,
+	})
+	old := readBuildInfo
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{
+			Path: "knative.dev/serving/cmd/controller",
+			Main: debug.Module{Path: testModule.Path, Version: testModule.Version},
+			Deps: []*debug.Module{
+				{Path: testZap.Path, Version: testZap.Version},
+				{Path: testPkg.Path, Version: testPkg.Version, Replace: &debug.Module{Path: "../pkg"}},
+				{Path: testAPI.Path, Version: testAPI.Version},
+			},
+		}, true
+	}
+	return func() {
+		readBuildInfo = old
+		restoreInfo()
+	}
+}
+
+func TestGetBuildInfo(t *testing.T) {
+	defer setBuildInfo(t)()
+
+	tests := []struct {
+		name    string
+		modules []string
+		want    []Module
+	}{{
+		name:    "default modules",
+		modules: DefaultModules,
+		want:    []Module{testAPI, testPkg},
+	}, {
+		name:    "selected modules",
+		modules: []string{"go.uber.org/"},
+		want:    []Module{testZap},
+	}, {
+		name: "all modules",
+		want: []Module{testZap, testAPI, testPkg},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			want := BuildInfo{
+				Commit:    testCommit,
+				Branch:    "master",
+				Tag:       "v0.11.0",
+				BuildDate: &testDate,
+				GoVersion: runtime.Version(),
+				Platform:  platform,
+				Main:      &testModule,
+				Modules:   test.want,
+			}
+			if diff := cmp.Diff(want, GetBuildInfo(test.modules...)); diff != "" {
+				t.Errorf("GetBuildInfo (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestGetBuildInfoWithoutModules(t *testing.T) {
+	defer changeset.SetForTesting(changeset.Info{Commit: testCommit})()
+	old := readBuildInfo
+	defer func() { readBuildInfo = old }()
+	readBuildInfo = func() (*debug.BuildInfo, bool) { return nil, false }
+
+	want := BuildInfo{
+		Commit:    testCommit,
+		GoVersion: runtime.Version(),
+		Platform:  platform,
+	}
+	if diff := cmp.Diff(want, GetBuildInfo()); diff != "" {
+		t.Errorf("GetBuildInfo (-want, +got): %s", diff)
+	}
+}
+
+func TestBuildInfoString(t *testing.T) {
+	tests := []struct {
+		name string
+		info BuildInfo
+		want string
+	}{{
+		name: "full",
+		info: BuildInfo{
+			Commit:    testCommit,
+			Dirty:     true,
+			Tag:       "v0.11.0",
+			Branch:    "master",
+			BuildDate: &testDate,
+			GoVersion: "go1.13.4",
+			Platform:  "linux/amd64",
+		},
+		want: "commit " + testCommit + " (dirty), tag v0.11.0, branch master, built 2019-12-01T10:00:00Z, go1.13.4 linux/amd64",
+	}, {
+		name: "unknown commit",
+		info: BuildInfo{
+			CommitError: "no commit",
+			GoVersion:   "go1.13.4",
+			Platform:    "linux/amd64",
+		},
+		want: "unknown commit, go1.13.4 linux/amd64",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.info.String(); got != test.want {
+				t.Errorf("String() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBuildInfoHandler(t *testing.T) {
+	defer setBuildInfo(t)()
+
+	tests := []struct {
+		name string
+		url  string
+		want []Module
+	}{{
+		name: "handler modules",
+		url:  "/version",
+		want: []Module{testAPI, testPkg},
+	}, {
+		name: "query modules",
+		url:  "/version?module=go.uber.org/&module=knative.dev/",
+		want: []Module{testZap, testPkg},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			BuildInfoHandler(DefaultModules...).ServeHTTP(w, httptest.NewRequest("GET", test.url, nil))
+
+			if got, want := w.Header().Get("Content-Type"), "application/json"; got != want {
+				t.Errorf("Content-Type = %q, want %q", got, want)
+			}
+			var got BuildInfo
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("Unmarshal() = %v", err)
+			}
+			if got.Commit != testCommit {
+				t.Errorf("Commit = %q, want %q", got.Commit, testCommit)
+			}
+			if diff := cmp.Diff(test.want, got.Modules); diff != "" {
+				t.Errorf("Modules (-want, +got): %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package version
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rogpeppe/go-internal/semver"
+)
+
+// Feature is an optional feature which requires a minimum version of
+// Kubernetes.
+type Feature struct {
+	// Name is the name of the feature, e.g. "LeaseElection".
+	Name string
+	// MinimumVersion is the minimum Kubernetes version supporting the
+	// feature, e.g. "v1.14.0".
+	MinimumVersion string
+}
+
+// FeatureReport reports which features are available on a cluster.
+type FeatureReport struct {
+	// ServerVersion is the version of the cluster, without any pre-release
+	// or build suffix.
+	ServerVersion string
+	// Available are the features supported by the cluster.
+	Available []Feature
+	// Unavailable are the features the cluster is too old for.
+	Unavailable []Feature
+}
+
+// IsAvailable returns whether the named feature is available. Features
+// which haven't been checked are not available.
+func (r *FeatureReport) IsAvailable(name string) bool {
+	for _, f := range r.Available {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns a human-readable summary of the report, e.g. for logging
+// it on startup.
+func (r *FeatureReport) String() string {
+	if len(r.Unavailable) == 0 {
+		return fmt.Sprintf("kubernetes version %q supports all features", r.ServerVersion)
+	}
+	missing := make([]string, 0, len(r.Unavailable))
+	for _, f := range r.Unavailable {
+		missing = append(missing, fmt.Sprintf("%s (needs %s)", f.Name, f.MinimumVersion))
+	}
+	return fmt.Sprintf("kubernetes version %q doesn't support: %s", r.ServerVersion, strings.Join(missing, ", "))
+}
+
+// CheckFeatures checks which of the given features the currently installed
+// version of Kubernetes supports. Unlike CheckMinimumVersion, it doesn't
+// fail if features are unsupported, but reports them so that they can be
+// disabled. It returns an error only if the version can't be determined or
+// a feature's minimum version is invalid.
+//
+// Pre-release suffixes of the server version, like the "-gke.2" of
+// "v1.14.8-gke.2", are ignored.
+func CheckFeatures(versioner ServerVersioner, features ...Feature) (*FeatureReport, error) {
+	v, err := versioner.ServerVersion()
+	if err != nil {
+		return nil, err
+	}
+	current := semver.Canonical(v.String())
+	if current == "" {
+		return nil, fmt.Errorf("kubernetes version %q is not a valid semantic version", v.String())
+	}
+	if pre := semver.Prerelease(current); pre != "" {
+		current = strings.TrimSuffix(current, pre)
+	}
+
+	report := &FeatureReport{ServerVersion: current}
+	for _, f := range features {
+		if !semver.IsValid(f.MinimumVersion) {
+			return nil, fmt.Errorf("minimum version %q of feature %q is not a valid semantic version", f.MinimumVersion, f.Name)
+		}
+		if semver.Compare(f.MinimumVersion, current) == 1 {
+			report.Unavailable = append(report.Unavailable, f)
+		} else {
+			report.Available = append(report.Available, f)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package version
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var (
+	leases   = Feature{Name: "LeaseElection", MinimumVersion: "v1.14.0"}
+	dryRun   = Feature{Name: "ServerSideDryRun", MinimumVersion: "v1.13"}
+	future   = Feature{Name: "Future", MinimumVersion: "v1.99.0"}
+	features = []Feature{leases, dryRun, future}
+)
+
+func TestCheckFeatures(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  *testVersioner
+		features []Feature
+		want     *FeatureReport
+		wantErr  bool
+	}{{
+		name:     "mixed",
+		version:  &testVersioner{version: "v1.14.1"},
+		features: features,
+		want: &FeatureReport{
+			ServerVersion: "v1.14.1",
+			Available:     []Feature{leases, dryRun},
+			Unavailable:   []Feature{future},
+		},
+	}, {
+		name:     "pre-release suffix is ignored",
+		version:  &testVersioner{version: "v1.14.0-gke.2"},
+		features: []Feature{leases},
+		want: &FeatureReport{
+			ServerVersion: "v1.14.0",
+			Available:     []Feature{leases},
+		},
+	}, {
+		name:     "old cluster",
+		version:  &testVersioner{version: "v1.12.3"},
+		features: features,
+		want: &FeatureReport{
+			ServerVersion: "v1.12.3",
+			Unavailable:   features,
+		},
+	}, {
+		name:     "invalid feature version",
+		version:  &testVersioner{version: "v1.14.1"},
+		features: []Feature{{Name: "Broken", MinimumVersion: "1.14"}},
+		wantErr:  true,
+	}, {
+		name:    "invalid server version",
+		version: &testVersioner{version: "latest"},
+		wantErr: true,
+	}, {
+		name:    "error while fetching",
+		version: &testVersioner{err: errors.New("random error")},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := CheckFeatures(test.version, test.features...)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("CheckFeatures() = %v, wantErr = %v", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("CheckFeatures (-want, +got) = %s", diff)
+			}
+		})
+	}
+}
+
+func TestFeatureReport(t *testing.T) {
+	r, err := CheckFeatures(&testVersioner{version: "v1.14.1"}, features...)
+	if err != nil {
+		t.Fatal("CheckFeatures() =", err)
+	}
+	if !r.IsAvailable("LeaseElection") {
+		t.Error("IsAvailable(LeaseElection) = false, want true")
+	}
+	if r.IsAvailable("Future") || r.IsAvailable("Unknown") {
+		t.Error("IsAvailable() = true for an unavailable feature")
+	}
+	if got, want := r.String(), `kubernetes version "v1.14.1" doesn't support: Future (needs v1.99.0)`; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	authenticationv1 "k8s.io/api/authentication/v1"
+
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/logging/logkey"
+)
+
+// ValidationCallback identifies a validation callback of the webhook which
+// can be bypassed.
+type ValidationCallback string
+
+const (
+	// ResourceValidation is the apis.Validatable validation of the resources.
+	ResourceValidation ValidationCallback = "resource"
+
+	// ImmutabilityValidation is the deprecated apis.Immutable validation of
+	// the updates of the resources.
+	ImmutabilityValidation ValidationCallback = "immutability"
+
+	// ConfigValidation is the validation of the ConfigMaps by their
+	// constructors.
+	ConfigValidation ValidationCallback = "config"
+)
+
+// serviceAccountUsernamePrefix is the prefix of the usernames of the service
+// accounts, which are followed by `<namespace>:<name>`.
+const serviceAccountUsernamePrefix = "system:serviceaccount:"
+
+// ValidationBypass is a break-glass allow-list of principals whose requests
+// are admitted even though they fail some of the validation callbacks, so that
+// cluster admins can repair resources which are stuck behind overly strict
+// validations. Every bypassed validation failure is audit logged.
+type ValidationBypass struct {
+	// Users are the usernames of the principals allowed to bypass the
+	// validations.
+	Users []string
+
+	// Groups are the groups whose members are allowed to bypass the
+	// validations.
+	Groups []string
+
+	// ServiceAccounts are the service accounts, as `<namespace>/<name>`,
+	// allowed to bypass the validations.
+	ServiceAccounts []string
+
+	// Callbacks are the validation callbacks which are bypassed, or all of
+	// them if empty.
+	Callbacks []ValidationCallback
+}
+
+// Validate returns an error if the service accounts aren't `<namespace>/<name>`
+// or the callbacks are unknown.
+func (b *ValidationBypass) Validate() error {
+	for _, sa := range b.ServiceAccounts {
+		if parts := strings.Split(sa, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid service account %q, want <namespace>/<name>", sa)
+		}
+	}
+	for _, cb := range b.Callbacks {
+		switch cb {
+		case ResourceValidation, ImmutabilityValidation, ConfigValidation:
+		default:
+			return fmt.Errorf("unknown validation callback %q", cb)
+		}
+	}
+	return nil
+}
+
+// Allows returns true if the given principal is allowed to bypass the given
+// validation callback.
+func (b *ValidationBypass) Allows(ui authenticationv1.UserInfo, cb ValidationCallback) bool {
+	if len(b.Callbacks) > 0 && !containsCallback(b.Callbacks, cb) {
+		return false
+	}
+	for _, user := range b.Users {
+		if user == ui.Username {
+			return true
+		}
+	}
+	for _, group := range b.Groups {
+		for _, g := range ui.Groups {
+			if group == g {
+				return true
+			}
+		}
+	}
+	for _, sa := range b.ServiceAccounts {
+		if serviceAccountUsernamePrefix+strings.Replace(sa, "/", ":", 1) == ui.Username {
+			return true
+		}
+	}
+	return false
+}
+
+func containsCallback(callbacks []ValidationCallback, cb ValidationCallback) bool {
+	for _, c := range callbacks {
+		if c == cb {
+			return true
+		}
+	}
+	return false
+}
+
+// bypassValidation returns nil instead of the given validation error if the
+// given principal is allowed to bypass the validation callback by any of the
+// bypasses, and audit logs it.
+func bypassValidation(ctx context.Context, bypasses []ValidationBypass, ui authenticationv1.UserInfo, cb ValidationCallback, err error) error {
+	if err == nil {
+		return nil
+	}
+	for i := range bypasses {
+		if bypasses[i].Allows(ui, cb) {
+			logging.FromContext(ctx).Warnw("Admitting a request failing validation for a break-glass principal",
+				zap.String(logkey.ValidationBypass, string(cb)),
+				zap.String(logkey.UserInfo, fmt.Sprint(ui)),
+				zap.Error(err))
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+
+	"knative.dev/pkg/apis"
+	. "knative.dev/pkg/logging/testing"
+)
+
+func TestValidationBypassValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		bypass  ValidationBypass
+		wantErr bool
+	}{{
+		name: "valid",
+		bypass: ValidationBypass{
+			Users:           []string{user1},
+			Groups:          []string{"system:masters"},
+			ServiceAccounts: []string{"knative-serving/controller"},
+			Callbacks:       []ValidationCallback{ResourceValidation, ImmutabilityValidation, ConfigValidation},
+		},
+	}, {
+		name:   "empty",
+		bypass: ValidationBypass{},
+	}, {
+		name:    "service account without namespace",
+		bypass:  ValidationBypass{ServiceAccounts: []string{"controller"}},
+		wantErr: true,
+	}, {
+		name:    "service account with empty name",
+		bypass:  ValidationBypass{ServiceAccounts: []string{"knative-serving/"}},
+		wantErr: true,
+	}, {
+		name:    "unknown callback",
+		bypass:  ValidationBypass{Callbacks: []ValidationCallback{"defaulting"}},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.bypass.Validate(); (err != nil) != test.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidationBypassAllows(t *testing.T) {
+	bypass := ValidationBypass{
+		Users:           []string{user1},
+		Groups:          []string{"system:masters"},
+		ServiceAccounts: []string{"knative-serving/controller"},
+	}
+	tests := []struct {
+		name      string
+		callbacks []ValidationCallback
+		ui        authenticationv1.UserInfo
+		cb        ValidationCallback
+		want      bool
+	}{{
+		name: "user",
+		ui:   authenticationv1.UserInfo{Username: user1},
+		cb:   ResourceValidation,
+		want: true,
+	}, {
+		name: "group",
+		ui:   authenticationv1.UserInfo{Username: user2, Groups: []string{"system:authenticated", "system:masters"}},
+		cb:   ConfigValidation,
+		want: true,
+	}, {
+		name: "service account",
+		ui:   authenticationv1.UserInfo{Username: "system:serviceaccount:knative-serving:controller"},
+		cb:   ImmutabilityValidation,
+		want: true,
+	}, {
+		name: "service account in another namespace",
+		ui:   authenticationv1.UserInfo{Username: "system:serviceaccount:default:controller"},
+		cb:   ResourceValidation,
+	}, {
+		name: "other user",
+		ui:   authenticationv1.UserInfo{Username: user2, Groups: []string{"system:authenticated"}},
+		cb:   ResourceValidation,
+	}, {
+		name:      "bypassed callback",
+		callbacks: []ValidationCallback{ImmutabilityValidation, ResourceValidation},
+		ui:        authenticationv1.UserInfo{Username: user1},
+		cb:        ResourceValidation,
+		want:      true,
+	}, {
+		name:      "enforced callback",
+		callbacks: []ValidationCallback{ImmutabilityValidation},
+		ui:        authenticationv1.UserInfo{Username: user1},
+		cb:        ResourceValidation,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := bypass
+			b.Callbacks = test.callbacks
+			if got := b.Allows(test.ui, test.cb); got != test.want {
+				t.Errorf("Allows(%v, %q) = %v, want %v", test.ui, test.cb, got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewWithInvalidValidationBypass(t *testing.T) {
+	opts := newDefaultOptions()
+	opts.ValidationBypasses = []ValidationBypass{{ServiceAccounts: []string{"controller"}}}
+	if _, err := New(nil, opts, nil, nil, nil); err == nil {
+		t.Error("New() = nil, wanted an error for the invalid validation bypass")
+	}
+}
+
+func TestAdmitCreateBypassesResourceValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		user      string
+		callbacks []ValidationCallback
+		rejection string
+	}{{
+		name: "break-glass user",
+		user: user1,
+	}, {
+		name:      "break-glass user for the validation",
+		user:      user1,
+		callbacks: []ValidationCallback{ResourceValidation},
+	}, {
+		name:      "break-glass user for another validation",
+		user:      user1,
+		callbacks: []ValidationCallback{ImmutabilityValidation},
+		rejection: "invalid value",
+	}, {
+		name:      "other user",
+		user:      user2,
+		rejection: "invalid value",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := newDefaultOptions()
+			opts.ValidationBypasses = []ValidationBypass{{
+				Users:     []string{user1},
+				Callbacks: test.callbacks,
+			}}
+			_, ac := newNonRunningTestResourceAdmissionController(t, opts)
+
+			ctx := apis.WithinCreate(apis.WithUserInfo(
+				TestContextWithLogger(t),
+				&authenticationv1.UserInfo{Username: test.user}))
+			r := createResource("a name")
+			r.SetDefaults(ctx)
+			r.Spec.FieldWithValidation = "not what's expected"
+
+			resp := ac.Admit(ctx, createCreateResource(ctx, r))
+			if test.rejection == "" {
+				expectAllowed(t, resp)
+			} else {
+				expectFailsWith(t, resp, test.rejection)
+			}
+		})
+	}
+}
+
+func TestAdmitUpdateBypassesValidation(t *testing.T) {
+	opts := newDefaultOptions()
+	opts.ValidationBypasses = []ValidationBypass{{
+		Groups: []string{"system:masters"},
+	}}
+	_, ac := newNonRunningTestResourceAdmissionController(t, opts)
+
+	ctx := TestContextWithLogger(t)
+	old := createResource("a name")
+	old.SetDefaults(ctx)
+	new := old.DeepCopy()
+	new.Spec.FieldThatsImmutableWithDefault = "something different"
+
+	admin := apis.WithUserInfo(apis.WithinUpdate(ctx, old),
+		&authenticationv1.UserInfo{Username: user1, Groups: []string{"system:masters"}})
+	expectAllowed(t, ac.Admit(admin, createUpdateResource(admin, old, new)))
+
+	user := apis.WithUserInfo(apis.WithinUpdate(ctx, old),
+		&authenticationv1.UserInfo{Username: user2, Groups: []string{"system:authenticated"}})
+	expectFailsWith(t, ac.Admit(user, createUpdateResource(user, old, new)), "Immutable field changed")
+}
+
+func TestAdmitBypassesConfigValidation(t *testing.T) {
+	opts := newDefaultOptions()
+	opts.ValidationBypasses = []ValidationBypass{{
+		ServiceAccounts: []string{"knative-serving/controller"},
+		Callbacks:       []ValidationCallback{ConfigValidation},
+	}}
+	_, ac := newNonRunningTestConfigValidationController(t, opts)
+
+	r := createWrongValueConfigMap()
+	ctx := apis.WithinCreate(apis.WithUserInfo(
+		TestContextWithLogger(t),
+		&authenticationv1.UserInfo{Username: "system:serviceaccount:knative-serving:controller"}))
+	expectAllowed(t, ac.Admit(ctx, createCreateConfigMapRequest(ctx, r)))
+
+	ctx = apis.WithinCreate(apis.WithUserInfo(
+		TestContextWithLogger(t),
+		&authenticationv1.UserInfo{Username: user1}))
+	expectFailsWith(t, ac.Admit(ctx, createCreateConfigMapRequest(ctx, r)), "out of range")
+}
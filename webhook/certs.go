@@ -17,14 +17,20 @@ limitations under the License.
 package webhook
 
 import (
+	"bytes"
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"errors"
+	"fmt"
 	"math/big"
+	"net"
 	"time"
 
 	"go.uber.org/zap"
@@ -34,11 +40,191 @@ import (
 
 const (
 	organization = "knative.dev"
+
+	defaultRSAKeySize       = 2048
+	defaultECDSAKeySize     = 256
+	defaultCertLifetime     = 365 * 24 * time.Hour
+	defaultRotationLeadTime = 30 * 24 * time.Hour
 )
 
+// KeyType is the type of the private keys of the webhook certificates.
+type KeyType string
+
+const (
+	// RSAKey generates RSA keys.
+	RSAKey KeyType = "RSA"
+	// ECDSAKey generates ECDSA keys.
+	ECDSAKey KeyType = "ECDSA"
+)
+
+// CertOptions configures the certificates generated for the webhook.
+// The zero value generates 2048 bit RSA keys and certificates valid for a year,
+// rotated 30 days before they expire.
+type CertOptions struct {
+	// KeyType is the type of the keys. Defaults to RSAKey.
+	KeyType KeyType
+
+	// KeySize is the size of the keys in bits. For RSA keys it defaults to 2048,
+	// for ECDSA keys it's the size of the curve, one of 256, 384 or 521,
+	// and defaults to 256.
+	KeySize int
+
+	// Lifetime is how long the certificates are valid for. Defaults to a year.
+	Lifetime time.Duration
+
+	// RotationLeadTime is how long before they expire the certificates are
+	// replaced with new ones. Defaults to 30 days, or half the lifetime if
+	// that's shorter.
+	RotationLeadTime time.Duration
+
+	// Hosts are the DNS names or IP addresses the server certificate is
+	// valid for in addition to the names of the service, e.g. 127.0.0.1 for
+	// an API server calling the webhook by URL in integration tests.
+	Hosts []string
+}
+
+// withDefaults returns the options with the defaults of the unset ones.
+func (o CertOptions) withDefaults() CertOptions {
+	if o.KeyType == "" {
+		o.KeyType = RSAKey
+	}
+	if o.KeySize == 0 {
+		if o.KeyType == ECDSAKey {
+			o.KeySize = defaultECDSAKeySize
+		} else {
+			o.KeySize = defaultRSAKeySize
+		}
+	}
+	if o.Lifetime == 0 {
+		o.Lifetime = defaultCertLifetime
+	}
+	if o.RotationLeadTime == 0 {
+		o.RotationLeadTime = defaultRotationLeadTime
+		if half := o.Lifetime / 2; half < o.RotationLeadTime {
+			o.RotationLeadTime = half
+		}
+	}
+	return o
+}
+
+// Validate checks the options are consistent.
+func (o CertOptions) Validate() error {
+	o = o.withDefaults()
+	switch o.KeyType {
+	case RSAKey:
+		if o.KeySize < 2048 {
+			return fmt.Errorf("RSA key size must be at least 2048 bits, got %d", o.KeySize)
+		}
+	case ECDSAKey:
+		if _, err := ellipticCurve(o.KeySize); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported key type %q", o.KeyType)
+	}
+	if o.Lifetime < 0 || o.RotationLeadTime < 0 {
+		return errors.New("certificate lifetime and rotation lead time cannot be negative")
+	}
+	if o.RotationLeadTime >= o.Lifetime {
+		return fmt.Errorf("rotation lead time %v must be shorter than the certificate lifetime %v", o.RotationLeadTime, o.Lifetime)
+	}
+	return nil
+}
+
+func ellipticCurve(size int) (elliptic.Curve, error) {
+	switch size {
+	case 256:
+		return elliptic.P256(), nil
+	case 384:
+		return elliptic.P384(), nil
+	case 521:
+		return elliptic.P521(), nil
+	}
+	return nil, fmt.Errorf("unsupported ECDSA key size %d, must be one of 256, 384 or 521", size)
+}
+
+// generateKey generates a private key of the configured type and size.
+func (o CertOptions) generateKey() (crypto.Signer, error) {
+	switch o.KeyType {
+	case RSAKey:
+		return rsa.GenerateKey(rand.Reader, o.KeySize)
+	case ECDSAKey:
+		curve, err := ellipticCurve(o.KeySize)
+		if err != nil {
+			return nil, err
+		}
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	}
+	return nil, fmt.Errorf("unsupported key type %q", o.KeyType)
+}
+
+// encodeKey returns the PEM encoding of the private key.
+func encodeKey(key crypto.Signer) ([]byte, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return pem.EncodeToMemory(&pem.Block{
+			Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k),
+		}), nil
+	case *ecdsa.PrivateKey:
+		b, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b}), nil
+	}
+	return nil, fmt.Errorf("unsupported private key type %T", key)
+}
+
+// certExpiry returns when the PEM encoded certificate expires.
+func certExpiry(certPEM []byte) (time.Time, error) {
+	b, _ := pem.Decode(certPEM)
+	if b == nil {
+		return time.Time{}, errors.New("failed to decode the certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(b.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cert.NotAfter, nil
+}
+
+// caBundle returns the PEM encoded CA certificate followed by the ones of the
+// previous bundle which haven't expired yet. The webhook configurations are
+// registered with the bundle, so that the replicas still serving a
+// certificate signed by a previous CA keep being trusted until they switch to
+// the new certificate, which they do long before their CA expires.
+func caBundle(caCert, previous []byte, now time.Time) []byte {
+	bundle := append([]byte(nil), caCert...)
+	for rest := previous; ; {
+		var b *pem.Block
+		if b, rest = pem.Decode(rest); b == nil {
+			break
+		}
+		if b.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(b.Bytes)
+		if err != nil || !now.Before(cert.NotAfter) || bytes.Contains(caCert, pem.EncodeToMemory(b)) {
+			continue
+		}
+		bundle = append(bundle, pem.EncodeToMemory(b)...)
+	}
+	return bundle
+}
+
+// needsRotation returns whether the PEM encoded certificate expires within the
+// rotation lead time, or can't be parsed.
+func (o CertOptions) needsRotation(certPEM []byte, now time.Time) bool {
+	expiry, err := certExpiry(certPEM)
+	if err != nil {
+		return true
+	}
+	return !now.Add(o.withDefaults().RotationLeadTime).Before(expiry)
+}
+
 // Create the common parts of the cert. These don't change between
 // the root/CA cert and the server cert.
-func createCertTemplate(name, namespace string) (*x509.Certificate, error) {
+func createCertTemplate(name, namespace string, lifetime time.Duration) (*x509.Certificate, error) {
 	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
 	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
 	if err != nil {
@@ -53,12 +239,14 @@ func createCertTemplate(name, namespace string) (*x509.Certificate, error) {
 		serviceName + ".svc.cluster.local",
 	}
 
+	// The signature algorithm is picked from the type of the signing key,
+	// e.g. SHA256WithRSA for RSA keys.
+	now := time.Now()
 	tmpl := x509.Certificate{
 		SerialNumber:          serialNumber,
 		Subject:               pkix.Name{Organization: []string{organization}},
-		SignatureAlgorithm:    x509.SHA256WithRSA,
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().AddDate(1, 0, 0), // valid for 1 years
+		NotBefore:             now,
+		NotAfter:              now.Add(lifetime),
 		BasicConstraintsValid: true,
 		DNSNames:              serviceNames,
 	}
@@ -66,8 +254,8 @@ func createCertTemplate(name, namespace string) (*x509.Certificate, error) {
 }
 
 // Create cert template suitable for CA and hence signing
-func createCACertTemplate(name, namespace string) (*x509.Certificate, error) {
-	rootCert, err := createCertTemplate(name, namespace)
+func createCACertTemplate(name, namespace string, lifetime time.Duration) (*x509.Certificate, error) {
+	rootCert, err := createCertTemplate(name, namespace, lifetime)
 	if err != nil {
 		return nil, err
 	}
@@ -79,8 +267,8 @@ func createCACertTemplate(name, namespace string) (*x509.Certificate, error) {
 }
 
 // Create cert template that we can use on the server for TLS
-func createServerCertTemplate(name, namespace string) (*x509.Certificate, error) {
-	serverCert, err := createCertTemplate(name, namespace)
+func createServerCertTemplate(name, namespace string, lifetime time.Duration) (*x509.Certificate, error) {
+	serverCert, err := createCertTemplate(name, namespace, lifetime)
 	if err != nil {
 		return nil, err
 	}
@@ -106,21 +294,21 @@ func createCert(template, parent *x509.Certificate, pub interface{}, parentPriv
 	return
 }
 
-func createCA(ctx context.Context, name, namespace string) (*rsa.PrivateKey, *x509.Certificate, []byte, error) {
+func createCA(ctx context.Context, name, namespace string, opts CertOptions) (crypto.Signer, *x509.Certificate, []byte, error) {
 	logger := logging.FromContext(ctx)
-	rootKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	rootKey, err := opts.generateKey()
 	if err != nil {
 		logger.Errorw("error generating random key", zap.Error(err))
 		return nil, nil, nil, err
 	}
 
-	rootCertTmpl, err := createCACertTemplate(name, namespace)
+	rootCertTmpl, err := createCACertTemplate(name, namespace, opts.Lifetime)
 	if err != nil {
 		logger.Errorw("error generating CA cert", zap.Error(err))
 		return nil, nil, nil, err
 	}
 
-	rootCert, rootCertPEM, err := createCert(rootCertTmpl, rootCertTmpl, &rootKey.PublicKey, rootKey)
+	rootCert, rootCertPEM, err := createCert(rootCertTmpl, rootCertTmpl, rootKey.Public(), rootKey)
 	if err != nil {
 		logger.Errorw("error signing the CA cert", zap.Error(err))
 		return nil, nil, nil, err
@@ -133,33 +321,53 @@ func createCA(ctx context.Context, name, namespace string) (*rsa.PrivateKey, *x5
 // to establish trust for clients, CA certificate is used by the
 // client to verify the server authentication chain.
 func CreateCerts(ctx context.Context, name, namespace string) (serverKey, serverCert, caCert []byte, err error) {
+	return CreateCertsWithOptions(ctx, name, namespace, CertOptions{})
+}
+
+// CreateCertsWithOptions is like CreateCerts, but generates the keys and
+// certificates as configured by the options.
+func CreateCertsWithOptions(ctx context.Context, name, namespace string, opts CertOptions) (serverKey, serverCert, caCert []byte, err error) {
 	logger := logging.FromContext(ctx)
+	if err := opts.Validate(); err != nil {
+		return nil, nil, nil, err
+	}
+	opts = opts.withDefaults()
+
 	// First create a CA certificate and private key
-	caKey, caCertificate, caCertificatePEM, err := createCA(ctx, name, namespace)
+	caKey, caCertificate, caCertificatePEM, err := createCA(ctx, name, namespace, opts)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
 	// Then create the private key for the serving cert
-	servKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	servKey, err := opts.generateKey()
 	if err != nil {
 		logger.Errorw("error generating random key", zap.Error(err))
 		return nil, nil, nil, err
 	}
-	servCertTemplate, err := createServerCertTemplate(name, namespace)
+	servCertTemplate, err := createServerCertTemplate(name, namespace, opts.Lifetime)
 	if err != nil {
 		logger.Errorw("failed to create the server certificate template", zap.Error(err))
 		return nil, nil, nil, err
 	}
+	for _, host := range opts.Hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			servCertTemplate.IPAddresses = append(servCertTemplate.IPAddresses, ip)
+		} else {
+			servCertTemplate.DNSNames = append(servCertTemplate.DNSNames, host)
+		}
+	}
 
 	// create a certificate which wraps the server's public key, sign it with the CA private key
-	_, servCertPEM, err := createCert(servCertTemplate, caCertificate, &servKey.PublicKey, caKey)
+	_, servCertPEM, err := createCert(servCertTemplate, caCertificate, servKey.Public(), caKey)
 	if err != nil {
 		logger.Errorw("error signing server certificate template", zap.Error(err))
 		return nil, nil, nil, err
 	}
-	servKeyPEM := pem.EncodeToMemory(&pem.Block{
-		Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(servKey),
-	})
+	servKeyPEM, err := encodeKey(servKey)
+	if err != nil {
+		logger.Errorw("error encoding the server key", zap.Error(err))
+		return nil, nil, nil, err
+	}
 	return servKeyPEM, servCertPEM, caCertificatePEM, nil
 }
@@ -17,10 +17,12 @@ limitations under the License.
 package webhook
 
 import (
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 
@@ -75,6 +77,118 @@ func TestCreateCerts(t *testing.T) {
 	}
 }
 
+func TestCreateCertsWithOptions(t *testing.T) {
+	sKey, serverCertPEM, caCertBytes, err := CreateCertsWithOptions(TestContextWithLogger(t), "got-the-hook", "knative-webhook", CertOptions{
+		KeyType:  ECDSAKey,
+		KeySize:  384,
+		Lifetime: 24 * time.Hour,
+		Hosts:    []string{"127.0.0.1", "webhook.example.com"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create certs %v", err)
+	}
+
+	p, _ := pem.Decode(sKey)
+	if p.Type != "EC PRIVATE KEY" {
+		t.Fatalf("Key type = %s, want EC PRIVATE KEY", p.Type)
+	}
+	key, err := x509.ParseECPrivateKey(p.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse private key %v", err)
+	}
+	if got, want := key.Curve.Params().BitSize, 384; got != want {
+		t.Errorf("Key size = %d, want %d", got, want)
+	}
+
+	for _, b := range [][]byte{serverCertPEM, caCertBytes} {
+		p, _ := pem.Decode(b)
+		cert, err := x509.ParseCertificate(p.Bytes)
+		if err != nil {
+			t.Fatalf("Failed to parse cert %v", err)
+		}
+		if got, want := cert.SignatureAlgorithm, x509.ECDSAWithSHA384; got != want {
+			t.Errorf("SignatureAlgorithm = %v, want %v", got, want)
+		}
+		if got, want := cert.NotAfter.Sub(cert.NotBefore), 24*time.Hour; got != want {
+			t.Errorf("Lifetime = %v, want %v", got, want)
+		}
+	}
+
+	if _, err := tls.X509KeyPair(serverCertPEM, sKey); err != nil {
+		t.Errorf("X509KeyPair() = %v", err)
+	}
+
+	p, _ = pem.Decode(serverCertPEM)
+	cert, err := x509.ParseCertificate(p.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse cert %v", err)
+	}
+	for _, host := range []string{"127.0.0.1", "webhook.example.com", "got-the-hook.knative-webhook.svc"} {
+		if err := cert.VerifyHostname(host); err != nil {
+			t.Errorf("VerifyHostname(%q) = %v", host, err)
+		}
+	}
+}
+
+func TestCertOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    CertOptions
+		wantErr bool
+	}{{
+		name: "defaults",
+	}, {
+		name: "ecdsa",
+		opts: CertOptions{KeyType: ECDSAKey, KeySize: 521},
+	}, {
+		name:    "small rsa key",
+		opts:    CertOptions{KeySize: 1024},
+		wantErr: true,
+	}, {
+		name:    "bad ecdsa key size",
+		opts:    CertOptions{KeyType: ECDSAKey, KeySize: 2048},
+		wantErr: true,
+	}, {
+		name:    "unknown key type",
+		opts:    CertOptions{KeyType: "DSA"},
+		wantErr: true,
+	}, {
+		name:    "lead time longer than the lifetime",
+		opts:    CertOptions{Lifetime: 24 * time.Hour, RotationLeadTime: 48 * time.Hour},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.opts.Validate(); (err != nil) != test.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestNeedsRotation(t *testing.T) {
+	_, cert, _, err := CreateCertsWithOptions(TestContextWithLogger(t), "got-the-hook", "knative-webhook", CertOptions{
+		Lifetime:         10 * 24 * time.Hour,
+		RotationLeadTime: 24 * time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create certs %v", err)
+	}
+	opts := CertOptions{RotationLeadTime: 24 * time.Hour}
+	now := time.Now()
+
+	if opts.needsRotation(cert, now) {
+		t.Error("needsRotation() = true for a new certificate")
+	}
+	if !opts.needsRotation(cert, now.Add(9*24*time.Hour+time.Minute)) {
+		t.Error("needsRotation() = false within the lead time")
+	}
+	if !opts.needsRotation([]byte("garbage"), now) {
+		t.Error("needsRotation() = false for an invalid certificate")
+	}
+}
+
 func validCertificate(cert []byte, t *testing.T) (*x509.Certificate, error) {
 	t.Helper()
 	caCert, _ := pem.Decode(cert)
@@ -90,3 +204,33 @@ func validCertificate(cert []byte, t *testing.T) (*x509.Certificate, error) {
 	}
 	return parsedCert, nil
 }
+
+func TestCABundle(t *testing.T) {
+	ctx := TestContextWithLogger(t)
+	opts := CertOptions{KeyType: ECDSAKey, Lifetime: 48 * time.Hour, RotationLeadTime: time.Hour}
+	_, _, oldCA, err := CreateCertsWithOptions(ctx, "webhook", "ns", opts)
+	if err != nil {
+		t.Fatalf("CreateCertsWithOptions() = %v", err)
+	}
+	_, _, newCA, err := CreateCertsWithOptions(ctx, "webhook", "ns", opts)
+	if err != nil {
+		t.Fatalf("CreateCertsWithOptions() = %v", err)
+	}
+	now := time.Now()
+
+	// The previous CA is kept until it expires.
+	bundle := caBundle(newCA, oldCA, now)
+	if want := append(append([]byte(nil), newCA...), oldCA...); !cmp.Equal(bundle, want) {
+		t.Errorf("caBundle() = %s, want %s", bundle, want)
+	}
+	// Rotating again keeps the unexpired CAs once.
+	if got := caBundle(newCA, bundle, now); !cmp.Equal(got, bundle) {
+		t.Errorf("caBundle(bundle) = %s, want %s", got, bundle)
+	}
+	if got := caBundle(newCA, bundle, now.Add(49*time.Hour)); !cmp.Equal(got, newCA) {
+		t.Errorf("caBundle() after the expiry = %s, want %s", got, newCA)
+	}
+	if got := caBundle(newCA, []byte("garbage"), now); !cmp.Equal(got, newCA) {
+		t.Errorf("caBundle(garbage) = %s, want %s", got, newCA)
+	}
+}
@@ -190,6 +190,10 @@ func (ac *ConfigValidationController) validate(ctx context.Context, req *admissi
 		}
 	}
 
+	if err := configmap.ValidateExampleChecksum(&newObj); err != nil {
+		return err
+	}
+
 	var err error
 	if constructor, ok := ac.constructors[newObj.Name]; ok {
 
@@ -205,7 +209,7 @@ func (ac *ConfigValidationController) validate(ctx context.Context, req *admissi
 		}
 	}
 
-	return err
+	return bypassValidation(ctx, ac.options.ValidationBypasses, req.UserInfo, ConfigValidation, err)
 }
 
 func (ac *ConfigValidationController) registerConfig(name string, constructor interface{}) {
@@ -208,6 +208,23 @@ func TestDenyInvalidUpdateConfigMapOutOfRange(t *testing.T) {
 	expectFailsWith(t, resp, "out of range")
 }
 
+func TestDenyUpdateConfigMapWithEditedExample(t *testing.T) {
+	_, ac := newNonRunningTestConfigValidationController(t, newDefaultOptions())
+
+	r := createValidConfigMap()
+	r.Data[configmap.ExampleKey] = "value: 1.0"
+	r.Annotations = map[string]string{
+		configmap.ExampleChecksumAnnotation: configmap.Checksum("value: 2.0"),
+	}
+	ctx := apis.WithinCreate(apis.WithUserInfo(
+		TestContextWithLogger(t),
+		&authenticationv1.UserInfo{Username: user1}))
+
+	resp := ac.Admit(ctx, updateCreateConfigMapRequest(ctx, r))
+
+	expectFailsWith(t, resp, "copy the respective setting to the top-level")
+}
+
 func createConfigValidationWebhook(kubeClient kubernetes.Interface, webhook *admissionregistrationv1beta1.ValidatingWebhookConfiguration) {
 	client := kubeClient.AdmissionregistrationV1beta1().ValidatingWebhookConfigurations()
 	_, err := client.Create(webhook)
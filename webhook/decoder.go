@@ -0,0 +1,186 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"sort"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer/protobuf"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+const (
+	// JSONContentType is the content type of the JSON AdmissionReviews.
+	JSONContentType = "application/json"
+
+	// ProtobufContentType is the content type of the protobuf
+	// AdmissionReviews, in the Kubernetes envelope.
+	ProtobufContentType = "application/vnd.kubernetes.protobuf"
+)
+
+var (
+	admissionReviewGVK = admissionv1beta1.SchemeGroupVersion.WithKind("AdmissionReview")
+
+	// protobufPrefix is the magic number the objects serialized as protobuf
+	// by Kubernetes start with.
+	protobufPrefix = []byte{0x6b, 0x38, 0x73, 0x00}
+
+	// defaultDecoders are the Decoders of the webhooks without any.
+	defaultDecoders = DefaultDecoders()
+)
+
+// Decoder decodes the AdmissionReviews sent to the webhook in a content type,
+// and encodes the reviews it responds with in the same content type.
+type Decoder interface {
+	Decode(body []byte) (*admissionv1beta1.AdmissionReview, error)
+	Encode(review *admissionv1beta1.AdmissionReview) ([]byte, error)
+}
+
+// Decoders is a registry of the Decoders of the content types the webhook
+// accepts, by media type.
+type Decoders map[string]Decoder
+
+// DefaultDecoders returns a registry of the JSON and protobuf Decoders.
+func DefaultDecoders() Decoders {
+	return Decoders{
+		JSONContentType:     JSONDecoder{},
+		ProtobufContentType: NewProtobufDecoder(),
+	}
+}
+
+// Register registers the Decoder of the given media type, replacing the one
+// already registered if any.
+func (d Decoders) Register(mediaType string, decoder Decoder) {
+	d[mediaType] = decoder
+}
+
+// lookup returns the Decoder of the media type of the Content-Type header,
+// ignoring its parameters, e.g. its charset.
+func (d Decoders) lookup(contentType string) (string, Decoder, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", nil, false
+	}
+	decoder, ok := d[mediaType]
+	if !ok {
+		return "", nil, false
+	}
+	return mediaType, decoder, true
+}
+
+// mediaTypes returns the sorted media types of the registry.
+func (d Decoders) mediaTypes() []string {
+	types := make([]string, 0, len(d))
+	for t := range d {
+		types = append(types, fmt.Sprintf("`%s`", t))
+	}
+	sort.Strings(types)
+	return types
+}
+
+// JSONDecoder decodes and encodes the AdmissionReviews as JSON.
+type JSONDecoder struct{}
+
+var _ Decoder = JSONDecoder{}
+
+// Decode implements Decoder.
+func (JSONDecoder) Decode(body []byte) (*admissionv1beta1.AdmissionReview, error) {
+	var review admissionv1beta1.AdmissionReview
+	if err := json.Unmarshal(body, &review); err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// Encode implements Decoder.
+func (JSONDecoder) Encode(review *admissionv1beta1.AdmissionReview) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(review); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ProtobufDecoder decodes and encodes the AdmissionReviews as protobuf. The
+// objects of the requests which are serialized as protobuf, e.g. the built-in
+// types, are converted to JSON, which the admission controllers decode.
+type ProtobufDecoder struct {
+	serializer *protobuf.Serializer
+}
+
+var _ Decoder = (*ProtobufDecoder)(nil)
+
+// NewProtobufDecoder creates a ProtobufDecoder knowing about the built-in
+// types of Kubernetes.
+func NewProtobufDecoder() *ProtobufDecoder {
+	s := runtime.NewScheme()
+	scheme.AddToScheme(s)
+	admissionv1beta1.AddToScheme(s)
+	return &ProtobufDecoder{serializer: protobuf.NewSerializer(s, s)}
+}
+
+// Decode implements Decoder.
+func (d *ProtobufDecoder) Decode(body []byte) (*admissionv1beta1.AdmissionReview, error) {
+	var review admissionv1beta1.AdmissionReview
+	if _, _, err := d.serializer.Decode(body, &admissionReviewGVK, &review); err != nil {
+		return nil, err
+	}
+	if review.Request != nil {
+		for _, raw := range []*runtime.RawExtension{&review.Request.Object, &review.Request.OldObject} {
+			if err := d.toJSON(raw); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &review, nil
+}
+
+// toJSON converts the object, if it's serialized as protobuf, to JSON.
+func (d *ProtobufDecoder) toJSON(raw *runtime.RawExtension) error {
+	if !bytes.HasPrefix(raw.Raw, protobufPrefix) {
+		return nil
+	}
+	obj, gvk, err := d.serializer.Decode(raw.Raw, nil, nil)
+	if err != nil {
+		return fmt.Errorf("could not decode the object: %v", err)
+	}
+	obj.GetObjectKind().SetGroupVersionKind(*gvk)
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	raw.Raw = b
+	return nil
+}
+
+// Encode implements Decoder.
+func (d *ProtobufDecoder) Encode(review *admissionv1beta1.AdmissionReview) ([]byte, error) {
+	// The envelope of the response is typed after the TypeMeta of the review.
+	review = review.DeepCopy()
+	review.SetGroupVersionKind(admissionReviewGVK)
+	var buf bytes.Buffer
+	if err := d.serializer.Encode(review, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
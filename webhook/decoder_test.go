@@ -0,0 +1,226 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	. "knative.dev/pkg/logging/testing"
+)
+
+// admittingController records the requests it admits.
+type admittingController struct {
+	AdmissionController
+	requests []*admissionv1beta1.AdmissionRequest
+}
+
+func (a *admittingController) Admit(_ context.Context, req *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	a.requests = append(a.requests, req)
+	return &admissionv1beta1.AdmissionResponse{Allowed: true}
+}
+
+var testConfigMap = &corev1.ConfigMap{
+	TypeMeta: metav1.TypeMeta{
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+	},
+	ObjectMeta: metav1.ObjectMeta{
+		Namespace: "knative-testing",
+		Name:      "config-test",
+	},
+	Data: map[string]string{"key": "value"},
+}
+
+// protobufReview returns a protobuf AdmissionReview of the creation of the
+// test ConfigMap, which is itself serialized as protobuf.
+func protobufReview(t *testing.T, d *ProtobufDecoder) []byte {
+	t.Helper()
+	var cm bytes.Buffer
+	if err := d.serializer.Encode(testConfigMap, &cm); err != nil {
+		t.Fatalf("Encode(ConfigMap) = %v", err)
+	}
+	review := &admissionv1beta1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: admissionReviewGVK.GroupVersion().String(),
+			Kind:       admissionReviewGVK.Kind,
+		},
+		Request: &admissionv1beta1.AdmissionRequest{
+			UID:       types.UID("1234"),
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+			Operation: admissionv1beta1.Create,
+			Object:    runtime.RawExtension{Raw: cm.Bytes()},
+		},
+	}
+	var body bytes.Buffer
+	if err := d.serializer.Encode(review, &body); err != nil {
+		t.Fatalf("Encode(AdmissionReview) = %v", err)
+	}
+	return body.Bytes()
+}
+
+func TestDecodersLookup(t *testing.T) {
+	d := DefaultDecoders()
+	tests := []struct {
+		contentType string
+		want        string
+	}{{
+		contentType: "application/json",
+		want:        JSONContentType,
+	}, {
+		contentType: "application/json; charset=utf-8",
+		want:        JSONContentType,
+	}, {
+		contentType: "application/vnd.kubernetes.protobuf",
+		want:        ProtobufContentType,
+	}, {
+		contentType: "",
+	}, {
+		contentType: "text/plain",
+	}}
+	for _, test := range tests {
+		got, _, ok := d.lookup(test.contentType)
+		if ok != (test.want != "") || got != test.want {
+			t.Errorf("lookup(%q) = %q, %v, want %q", test.contentType, got, ok, test.want)
+		}
+	}
+
+	d.Register("application/yaml", JSONDecoder{})
+	if _, _, ok := d.lookup("application/yaml"); !ok {
+		t.Error("lookup(application/yaml) = false after registering it")
+	}
+	want := []string{"`application/json`", "`application/vnd.kubernetes.protobuf`", "`application/yaml`"}
+	if diff := cmp.Diff(want, d.mediaTypes()); diff != "" {
+		t.Errorf("mediaTypes (-want, +got): %s", diff)
+	}
+}
+
+func TestProtobufDecoder(t *testing.T) {
+	d := NewProtobufDecoder()
+	review, err := d.Decode(protobufReview(t, d))
+	if err != nil {
+		t.Fatalf("Decode() = %v", err)
+	}
+	if got, want := review.Request.UID, types.UID("1234"); got != want {
+		t.Errorf("UID = %v, want %v", got, want)
+	}
+	// The ConfigMap is converted to JSON.
+	var got corev1.ConfigMap
+	if err := json.Unmarshal(review.Request.Object.Raw, &got); err != nil {
+		t.Fatalf("Unmarshal(Object) = %v", err)
+	}
+	if diff := cmp.Diff(testConfigMap, &got); diff != "" {
+		t.Errorf("Object (-want, +got): %s", diff)
+	}
+	if review.Request.OldObject.Raw != nil {
+		t.Errorf("OldObject = %s, wanted none", review.Request.OldObject.Raw)
+	}
+
+	if _, err := d.Decode([]byte("{}")); err == nil {
+		t.Error("Decode(JSON) = nil, wanted an error")
+	}
+}
+
+func TestProtobufDecoderEncode(t *testing.T) {
+	d := NewProtobufDecoder()
+	response := &admissionv1beta1.AdmissionReview{
+		Response: &admissionv1beta1.AdmissionResponse{
+			UID:     types.UID("1234"),
+			Allowed: true,
+		},
+	}
+	b, err := d.Encode(response)
+	if err != nil {
+		t.Fatalf("Encode() = %v", err)
+	}
+	if !bytes.HasPrefix(b, protobufPrefix) {
+		t.Errorf("Encode() = %q, wanted the protobuf envelope", b)
+	}
+	got, err := d.Decode(b)
+	if err != nil {
+		t.Fatalf("Decode() = %v", err)
+	}
+	if diff := cmp.Diff(response.Response, got.Response); diff != "" {
+		t.Errorf("Response (-want, +got): %s", diff)
+	}
+	if response.Kind != "" {
+		t.Error("Encode() modified the review")
+	}
+}
+
+func TestServeHTTPProtobuf(t *testing.T) {
+	admitting := &admittingController{}
+	ac := &Webhook{
+		Logger:               TestLogger(t),
+		admissionControllers: map[string]AdmissionController{"/": admitting},
+	}
+	d := NewProtobufDecoder()
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(protobufReview(t, d)))
+	req.Header.Set("Content-Type", ProtobufContentType)
+	w := httptest.NewRecorder()
+	ac.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Code = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if got := w.Header().Get("Content-Type"); got != ProtobufContentType {
+		t.Errorf("Content-Type = %q, want %q", got, ProtobufContentType)
+	}
+	if len(admitting.requests) != 1 || !json.Valid(admitting.requests[0].Object.Raw) {
+		t.Errorf("Admitted requests = %v, wanted one with a JSON object", admitting.requests)
+	}
+	response, err := d.Decode(w.Body.Bytes())
+	if err != nil {
+		t.Fatalf("Decode(response) = %v", err)
+	}
+	want := &admissionv1beta1.AdmissionResponse{UID: types.UID("1234"), Allowed: true}
+	if diff := cmp.Diff(want, response.Response); diff != "" {
+		t.Errorf("Response (-want, +got): %s", diff)
+	}
+}
+
+func TestServeHTTPUnsupportedContentType(t *testing.T) {
+	ac := &Webhook{
+		Logger: TestLogger(t),
+		Options: ControllerOptions{
+			Decoders: Decoders{JSONContentType: JSONDecoder{}},
+		},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Content-Type", ProtobufContentType)
+	w := httptest.NewRecorder()
+	ac.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Code = %d, want %d", w.Code, http.StatusUnsupportedMediaType)
+	}
+	if got, want := w.Body.String(), "invalid Content-Type, want one of `application/json`\n"; got != want {
+		t.Errorf("Body = %q, want %q", got, want)
+	}
+}
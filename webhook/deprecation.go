@@ -0,0 +1,111 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"knative.dev/pkg/apis"
+)
+
+// deprecationAuditAnnotation is the audit annotation of the admission
+// responses listing the deprecated fields set in the admitted resources.
+const deprecationAuditAnnotation = "deprecated-fields"
+
+// KindDeprecations are the deprecated fields of a kind of resources.
+type KindDeprecations struct {
+	Group        string             `json:"group"`
+	Version      string             `json:"version"`
+	Kind         string             `json:"kind"`
+	Deprecations []apis.Deprecation `json:"deprecations"`
+}
+
+// DeprecationReport is the machine-readable report of the deprecated fields
+// of the resources admitted by the webhook.
+type DeprecationReport struct {
+	// ReleaseVersion is the release of the webhook, if known.
+	ReleaseVersion string `json:"releaseVersion,omitempty"`
+	// Kinds are the kinds of resources with deprecated fields.
+	Kinds []KindDeprecations `json:"kinds"`
+}
+
+// deprecationReporter is implemented by the admission controllers which can
+// report the deprecated fields of the resources they admit.
+type deprecationReporter interface {
+	deprecations() ([]KindDeprecations, error)
+}
+
+var _ deprecationReporter = (*ResourceAdmissionController)(nil)
+
+// deprecations implements deprecationReporter.
+func (ac *ResourceAdmissionController) deprecations() ([]KindDeprecations, error) {
+	var kinds []KindDeprecations
+	for gvk, handler := range ac.handlers {
+		deprecations, err := apis.Deprecations(handler)
+		if err != nil {
+			return nil, err
+		}
+		if len(deprecations) == 0 {
+			continue
+		}
+		kinds = append(kinds, KindDeprecations{
+			Group:        gvk.Group,
+			Version:      gvk.Version,
+			Kind:         gvk.Kind,
+			Deprecations: deprecations,
+		})
+	}
+	return kinds, nil
+}
+
+// serveDeprecationReport serves the DeprecationReport of the admission
+// controllers as JSON.
+func (ac *Webhook) serveDeprecationReport(w http.ResponseWriter) {
+	report := DeprecationReport{
+		ReleaseVersion: ac.Options.ReleaseVersion,
+		Kinds:          []KindDeprecations{},
+	}
+	for _, c := range ac.admissionControllers {
+		reporter, ok := c.(deprecationReporter)
+		if !ok {
+			continue
+		}
+		kinds, err := reporter.deprecations()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		report.Kinds = append(report.Kinds, kinds...)
+	}
+	sort.Slice(report.Kinds, func(i, j int) bool {
+		lhs, rhs := report.Kinds[i], report.Kinds[j]
+		if lhs.Group != rhs.Group {
+			return lhs.Group < rhs.Group
+		}
+		if lhs.Version != rhs.Version {
+			return lhs.Version < rhs.Version
+		}
+		return lhs.Kind < rhs.Kind
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"knative.dev/pkg/apis"
+	. "knative.dev/pkg/logging/testing"
+)
+
+// deprecatedResource is a resource with a deprecated field.
+type deprecatedResource struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec deprecatedResourceSpec `json:"spec,omitempty"`
+}
+
+type deprecatedResourceSpec struct {
+	Field    string `json:"field,omitempty"`
+	OldField string `json:"oldField,omitempty" deprecation:"since=v0.9,removedIn=v0.11,use=spec.field"`
+}
+
+func (r *deprecatedResource) DeepCopyObject() runtime.Object {
+	c := *r
+	r.ObjectMeta.DeepCopyInto(&c.ObjectMeta)
+	return &c
+}
+
+func (r *deprecatedResource) SetDefaults(context.Context) {}
+
+func (r *deprecatedResource) Validate(context.Context) *apis.FieldError {
+	return nil
+}
+
+var deprecatedResourceGVK = schema.GroupVersionKind{
+	Group:   "pkg.knative.dev",
+	Version: "v1alpha1",
+	Kind:    "DeprecatedResource",
+}
+
+func deprecatedResourceRequest(t *testing.T, op admissionv1beta1.Operation, old, new *deprecatedResource) *admissionv1beta1.AdmissionRequest {
+	t.Helper()
+	req := &admissionv1beta1.AdmissionRequest{
+		Operation: op,
+		Kind: metav1.GroupVersionKind{
+			Group:   deprecatedResourceGVK.Group,
+			Version: deprecatedResourceGVK.Version,
+			Kind:    deprecatedResourceGVK.Kind,
+		},
+		UserInfo: authenticationv1.UserInfo{Username: user1},
+	}
+	var err error
+	if req.Object.Raw, err = json.Marshal(new); err != nil {
+		t.Fatalf("Marshal() = %v", err)
+	}
+	if old != nil {
+		if req.OldObject.Raw, err = json.Marshal(old); err != nil {
+			t.Fatalf("Marshal() = %v", err)
+		}
+	}
+	req.Resource.Group = deprecatedResourceGVK.Group
+	return req
+}
+
+func TestAdmitDeprecatedFields(t *testing.T) {
+	const warning = "spec.oldField is deprecated since v0.9.0 and removed in v0.11.0, use spec.field instead"
+	old := &deprecatedResource{Spec: deprecatedResourceSpec{OldField: "old"}}
+
+	tests := []struct {
+		name           string
+		releaseVersion string
+		old            *deprecatedResource
+		new            *deprecatedResource
+		wantWarning    string
+		rejection      string
+	}{{
+		name: "no deprecated field",
+		new:  &deprecatedResource{Spec: deprecatedResourceSpec{Field: "new"}},
+	}, {
+		name:        "deprecated field set",
+		new:         &deprecatedResource{Spec: deprecatedResourceSpec{OldField: "old"}},
+		wantWarning: warning,
+	}, {
+		name:           "deprecated field set before its removal",
+		releaseVersion: "v0.10.3",
+		new:            &deprecatedResource{Spec: deprecatedResourceSpec{OldField: "old"}},
+		wantWarning:    warning,
+	}, {
+		name:           "removed field set",
+		releaseVersion: "v0.11.0",
+		new:            &deprecatedResource{Spec: deprecatedResourceSpec{OldField: "old"}},
+		rejection:      "field removed in v0.11.0: spec.oldField",
+	}, {
+		name:           "removed field unchanged",
+		releaseVersion: "v0.11.0",
+		old:            old,
+		new:            &deprecatedResource{Spec: deprecatedResourceSpec{OldField: "old", Field: "new"}},
+		wantWarning:    warning,
+	}, {
+		name:           "removed field changed",
+		releaseVersion: "v0.12.0",
+		old:            old,
+		new:            &deprecatedResource{Spec: deprecatedResourceSpec{OldField: "changed"}},
+		rejection:      "field removed in v0.11.0: spec.oldField",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := newDefaultOptions()
+			opts.ReleaseVersion = test.releaseVersion
+			ac := NewResourceAdmissionController(map[schema.GroupVersionKind]GenericCRD{
+				deprecatedResourceGVK: &deprecatedResource{},
+			}, opts, true)
+
+			op := admissionv1beta1.Create
+			if test.old != nil {
+				op = admissionv1beta1.Update
+			}
+			resp := ac.Admit(TestContextWithLogger(t), deprecatedResourceRequest(t, op, test.old, test.new))
+			if test.rejection != "" {
+				expectFailsWith(t, resp, test.rejection)
+				return
+			}
+			expectAllowed(t, resp)
+			if got := resp.AuditAnnotations[deprecationAuditAnnotation]; got != test.wantWarning {
+				t.Errorf("AuditAnnotations[%q] = %q, want %q", deprecationAuditAnnotation, got, test.wantWarning)
+			}
+		})
+	}
+}
+
+func TestDeprecationReport(t *testing.T) {
+	opts := newDefaultOptions()
+	opts.ReleaseVersion = "v0.10.0"
+	opts.DeprecationReportPath = "/deprecations"
+	ac, err := New(nil, opts, map[string]AdmissionController{
+		opts.ResourceAdmissionControllerPath: NewResourceAdmissionController(map[schema.GroupVersionKind]GenericCRD{
+			deprecatedResourceGVK: &deprecatedResource{},
+		}, opts, true),
+	}, TestLogger(t), nil)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	ac.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/deprecations", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ServeHTTP() = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var got DeprecationReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal() = %v", err)
+	}
+	removedIn := apis.Version{Minor: 11}
+	want := DeprecationReport{
+		ReleaseVersion: "v0.10.0",
+		Kinds: []KindDeprecations{{
+			Group:   deprecatedResourceGVK.Group,
+			Version: deprecatedResourceGVK.Version,
+			Kind:    deprecatedResourceGVK.Kind,
+			Deprecations: []apis.Deprecation{{
+				Field:       "spec.oldField",
+				Since:       apis.Version{Minor: 9},
+				RemovedIn:   &removedIn,
+				Replacement: "spec.field",
+			}},
+		}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("DeprecationReport (-want, +got) = %s", diff)
+	}
+
+	rec = httptest.NewRecorder()
+	ac.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/deprecations", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("ServeHTTP(POST) = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestNewWithInvalidReleaseVersion(t *testing.T) {
+	opts := newDefaultOptions()
+	opts.ReleaseVersion = "latest"
+	if _, err := New(nil, opts, nil, nil, nil); err == nil {
+		t.Error("New() = nil, wanted an error for the invalid release version")
+	}
+}
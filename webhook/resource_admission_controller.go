@@ -29,6 +29,7 @@ import (
 	"go.uber.org/zap"
 	admissionv1beta1 "k8s.io/api/admission/v1beta1"
 	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 
@@ -89,13 +90,13 @@ func (ac *ResourceAdmissionController) Admit(ctx context.Context, request *admis
 		return &admissionv1beta1.AdmissionResponse{Allowed: true}
 	}
 
-	patchBytes, err := ac.mutate(ctx, request)
+	patchBytes, warnings, err := ac.mutate(ctx, request)
 	if err != nil {
 		return makeErrorStatus("mutation failed: %v", err)
 	}
 	logger.Infof("Kind: %q PatchBytes: %v", request.Kind, string(patchBytes))
 
-	return &admissionv1beta1.AdmissionResponse{
+	response := &admissionv1beta1.AdmissionResponse{
 		Patch:   patchBytes,
 		Allowed: true,
 		PatchType: func() *admissionv1beta1.PatchType {
@@ -103,6 +104,13 @@ func (ac *ResourceAdmissionController) Admit(ctx context.Context, request *admis
 			return &pt
 		}(),
 	}
+	if len(warnings) > 0 {
+		logger.Warnf("Deprecated fields are set: %s", strings.Join(warnings, "; "))
+		response.AuditAnnotations = map[string]string{
+			deprecationAuditAnnotation: strings.Join(warnings, "; "),
+		}
+	}
+	return response
 }
 
 func (ac *ResourceAdmissionController) Register(ctx context.Context, kubeClient kubernetes.Interface, caCert []byte) error {
@@ -195,7 +203,9 @@ func (ac *ResourceAdmissionController) Register(ctx context.Context, kubeClient
 	return nil
 }
 
-func (ac *ResourceAdmissionController) mutate(ctx context.Context, req *admissionv1beta1.AdmissionRequest) ([]byte, error) {
+// mutate returns the patch of the resource, along with warnings about the
+// deprecated fields set in it.
+func (ac *ResourceAdmissionController) mutate(ctx context.Context, req *admissionv1beta1.AdmissionRequest) ([]byte, []string, error) {
 	kind := req.Kind
 	newBytes := req.Object.Raw
 	oldBytes := req.OldObject.Raw
@@ -210,7 +220,7 @@ func (ac *ResourceAdmissionController) mutate(ctx context.Context, req *admissio
 	handler, ok := ac.handlers[gvk]
 	if !ok {
 		logger.Errorf("Unhandled kind: %v", gvk)
-		return nil, fmt.Errorf("unhandled kind: %v", gvk)
+		return nil, nil, fmt.Errorf("unhandled kind: %v", gvk)
 	}
 
 	// nil values denote absence of `old` (create) or `new` (delete) objects.
@@ -223,7 +233,7 @@ func (ac *ResourceAdmissionController) mutate(ctx context.Context, req *admissio
 			newDecoder.DisallowUnknownFields()
 		}
 		if err := newDecoder.Decode(&newObj); err != nil {
-			return nil, fmt.Errorf("cannot decode incoming new object: %v", err)
+			return nil, nil, fmt.Errorf("cannot decode incoming new object: %v", err)
 		}
 	}
 	if len(oldBytes) != 0 {
@@ -233,7 +243,7 @@ func (ac *ResourceAdmissionController) mutate(ctx context.Context, req *admissio
 			oldDecoder.DisallowUnknownFields()
 		}
 		if err := oldDecoder.Decode(&oldObj); err != nil {
-			return nil, fmt.Errorf("cannot decode incoming old object: %v", err)
+			return nil, nil, fmt.Errorf("cannot decode incoming old object: %v", err)
 		}
 	}
 	var patches duck.JSONPatch
@@ -246,7 +256,7 @@ func (ac *ResourceAdmissionController) mutate(ctx context.Context, req *admissio
 		// because it expects the round tripped through Golang fields to be present already.
 		rtp, err := roundTripPatch(newBytes, newObj)
 		if err != nil {
-			return nil, fmt.Errorf("cannot create patch for round tripped newBytes: %v", err)
+			return nil, nil, fmt.Errorf("cannot create patch for round tripped newBytes: %v", err)
 		}
 		patches = append(patches, rtp...)
 	}
@@ -272,32 +282,44 @@ func (ac *ResourceAdmissionController) mutate(ctx context.Context, req *admissio
 		ctx = apis.WithinCreate(ctx)
 	}
 	ctx = apis.WithUserInfo(ctx, &req.UserInfo)
+	if ac.options.ReleaseVersion != "" {
+		if v, err := apis.ParseVersion(ac.options.ReleaseVersion); err == nil {
+			ctx = apis.WithReleaseVersion(ctx, v)
+		}
+	}
 
 	// Default the new object.
 	if patches, err = setDefaults(ctx, patches, newObj); err != nil {
 		logger.Errorw("Failed the resource specific defaulter", zap.Error(err))
 		// Return the error message as-is to give the defaulter callback
 		// discretion over (our portion of) the message that the user sees.
-		return nil, err
+		return nil, nil, err
 	}
 
 	if patches, err = ac.setUserInfoAnnotations(ctx, patches, newObj, req.Resource.Group); err != nil {
 		logger.Errorw("Failed the resource user info annotator", zap.Error(err))
-		return nil, err
+		return nil, nil, err
 	}
 
 	// None of the validators will accept a nil value for newObj.
 	if newObj == nil {
-		return nil, errMissingNewObject
+		return nil, nil, errMissingNewObject
 	}
-	if err := validate(ctx, newObj); err != nil {
+	if err := validate(ctx, newObj, ac.options.ValidationBypasses...); err != nil {
 		logger.Errorw("Failed the resource specific validation", zap.Error(err))
 		// Return the error message as-is to give the validation callback
 		// discretion over (our portion of) the message that the user sees.
-		return nil, err
+		return nil, nil, err
+	}
+
+	warnings, errs := apis.CheckFieldDeprecations(ctx, newObj)
+	if errs != nil {
+		logger.Errorw("Failed the deprecation validation", zap.Error(errs))
+		return nil, nil, errs
 	}
 
-	return json.Marshal(patches)
+	patchBytes, err := json.Marshal(patches)
+	return patchBytes, warnings, err
 }
 
 func (ac *ResourceAdmissionController) setUserInfoAnnotations(ctx context.Context, patches duck.JSONPatch, new GenericCRD, groupName string) (duck.JSONPatch, error) {
@@ -341,7 +363,14 @@ func roundTripPatch(bytes []byte, unmarshalled interface{}) (duck.JSONPatch, err
 // validate performs validation on the provided "new" CRD.
 // For legacy purposes, this also does apis.Immutable validation,
 // which is deprecated and will be removed in a future release.
-func validate(ctx context.Context, new apis.Validatable) error {
+// The validation failures are ignored for the user in the context
+// if any of the bypasses allows them to.
+func validate(ctx context.Context, new apis.Validatable, bypasses ...ValidationBypass) error {
+	var ui authenticationv1.UserInfo
+	if u := apis.GetUserInfo(ctx); u != nil {
+		ui = *u
+	}
+
 	if apis.IsInUpdate(ctx) {
 		old := apis.GetBaseline(ctx)
 		if immutableNew, ok := new.(apis.Immutable); ok {
@@ -350,14 +379,16 @@ func validate(ctx context.Context, new apis.Validatable) error {
 				return fmt.Errorf("unexpected type mismatch %T vs. %T", old, new)
 			}
 			if err := immutableNew.CheckImmutableFields(ctx, immutableOld); err != nil {
-				return err
+				if err := bypassValidation(ctx, bypasses, ui, ImmutabilityValidation, err); err != nil {
+					return err
+				}
 			}
 		}
 	}
 
 	// Can't just `return new.Validate()` because it doesn't properly nil-check.
 	if err := new.Validate(ctx); err != nil {
-		return err
+		return bypassValidation(ctx, bypasses, ui, ResourceValidation, err)
 	}
 
 	return nil
@@ -31,6 +31,7 @@ import (
 const (
 	requestCountName     = "request_count"
 	requestLatenciesName = "request_latencies"
+	certExpiryName       = "certificate_expiry_days"
 )
 
 var (
@@ -42,6 +43,10 @@ var (
 		requestLatenciesName,
 		"The response time in milliseconds",
 		stats.UnitMilliseconds)
+	certExpiryM = stats.Float64(
+		certExpiryName,
+		"The number of days until the webhook serving certificate expires",
+		"d")
 
 	// Create the tag keys that will be used to add tags to our measurements.
 	// Tag keys must conform to the restrictions described in
@@ -58,6 +63,7 @@ var (
 	resourceNameKey      = tag.MustNewKey("resource_name")
 	resourceNamespaceKey = tag.MustNewKey("resource_namespace")
 	admissionAllowedKey  = tag.MustNewKey("admission_allowed")
+	secretNameKey        = tag.MustNewKey("secret_name")
 )
 
 func init() {
@@ -111,6 +117,16 @@ func (r *reporter) ReportRequest(req *admissionv1beta1.AdmissionRequest, resp *a
 	return nil
 }
 
+// reportCertExpiry records how long until the serving certificate stored in
+// the given secret expires.
+func reportCertExpiry(secretName string, untilExpiry time.Duration) {
+	ctx, err := tag.New(context.Background(), tag.Insert(secretNameKey, secretName))
+	if err != nil {
+		return
+	}
+	metrics.Record(ctx, certExpiryM.M(untilExpiry.Hours()/24))
+}
+
 func register() {
 	tagKeys := []tag.Key{
 		requestOperationKey,
@@ -137,6 +153,12 @@ func register() {
 			Aggregation: view.Distribution(metrics.Buckets125(1, 100000)...), // [1 2 5 10 20 50 100 200 500 1000 2000 5000 10000 20000 50000 100000]ms
 			TagKeys:     tagKeys,
 		},
+		&view.View{
+			Description: certExpiryM.Description(),
+			Measure:     certExpiryM,
+			Aggregation: view.LastValue(),
+			TagKeys:     []tag.Key{secretNameKey},
+		},
 	); err != nil {
 		panic(err)
 	}
@@ -65,12 +65,18 @@ func TestWebhookStatsReporter(t *testing.T) {
 	metricstest.CheckDistributionData(t, requestLatenciesName, expectedTags, 2, shortTime, longTime)
 }
 
+func TestReportCertExpiry(t *testing.T) {
+	setup()
+	reportCertExpiry("webhook-certs", 36*time.Hour)
+	metricstest.CheckLastValueData(t, certExpiryName, map[string]string{secretNameKey.Name(): "webhook-certs"}, 1.5)
+}
+
 func setup() {
 	resetMetrics()
 }
 
 // opencensus metrics carry global state that need to be reset between unit tests
 func resetMetrics() {
-	metricstest.Unregister(requestCountName, requestLatenciesName)
+	metricstest.Unregister(requestCountName, requestLatenciesName, certExpiryName)
 	register()
 }
@@ -17,17 +17,23 @@ limitations under the License.
 package webhook
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
-	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
 
+	"knative.dev/pkg/apis"
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/logging/logkey"
 
@@ -43,6 +49,14 @@ const (
 	secretServerKey  = "server-key.pem"
 	secretServerCert = "server-cert.pem"
 	secretCACert     = "ca-cert.pem"
+
+	// certCheckPeriod is how often the webhook checks whether its
+	// certificates need to be rotated.
+	certCheckPeriod = time.Hour
+
+	// shutdownTimeout is how long the admission requests in flight are given
+	// to finish when the webhook is stopped.
+	shutdownTimeout = 30 * time.Second
 )
 
 var (
@@ -106,6 +120,31 @@ type ControllerOptions struct {
 
 	// NamespaceLabel is the label for the Namespace we bind ConfigValidationController to
 	ConfigValidationNamespaceLabel string
+
+	// CertOptions configures the key type and size, lifetime and rotation of
+	// the certificates generated for the webhook.
+	CertOptions CertOptions
+
+	// ValidationBypasses are the break-glass principals allowed to bypass
+	// some of the validation callbacks, e.g. to repair resources which are
+	// stuck behind overly strict validations. Leave it empty to enforce all
+	// of the validations for everyone.
+	ValidationBypasses []ValidationBypass
+
+	// ReleaseVersion is the version of the Knative release the webhook is
+	// part of, e.g. v0.10.0. The resources setting fields which are removed
+	// in, or before, this release are rejected. Leave it empty to only warn
+	// about the deprecated fields.
+	ReleaseVersion string
+
+	// DeprecationReportPath is the path the report of the deprecated fields
+	// of the resources is served on, or empty not to serve it.
+	DeprecationReportPath string
+
+	// Decoders decode the AdmissionReviews by content type. The webhook
+	// responds in the content type of the request. Defaults to
+	// DefaultDecoders, i.e. JSON and protobuf.
+	Decoders Decoders
 }
 
 // AdmissionController provides the interface for different admission controllers
@@ -123,6 +162,10 @@ type Webhook struct {
 	admissionControllers map[string]AdmissionController
 
 	WithContext func(context.Context) context.Context
+
+	// serving is set while the webhook is registered and serving, and is
+	// accessed atomically.
+	serving int32
 }
 
 // New constructs a Webhook
@@ -134,6 +177,19 @@ func New(
 	ctx func(context.Context) context.Context,
 ) (*Webhook, error) {
 
+	if err := opts.CertOptions.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid certificate options: %v", err)
+	}
+	if opts.ReleaseVersion != "" {
+		if _, err := apis.ParseVersion(opts.ReleaseVersion); err != nil {
+			return nil, fmt.Errorf("invalid release version: %v", err)
+		}
+	}
+	for i := range opts.ValidationBypasses {
+		if err := opts.ValidationBypasses[i].Validate(); err != nil {
+			return nil, fmt.Errorf("invalid validation bypass: %v", err)
+		}
+	}
 	if opts.StatsReporter == nil {
 		reporter, err := NewStatsReporter()
 		if err != nil {
@@ -161,6 +217,10 @@ func (ac *Webhook) Run(stop <-chan struct{}) error {
 		return err
 	}
 
+	// Serve the current certificate, so it can be rotated while running.
+	current := &servingCert{cert: &tlsConfig.Certificates[0]}
+	tlsConfig.Certificates = nil
+	tlsConfig.GetCertificate = current.get
 	server := &http.Server{
 		Handler:   ac,
 		Addr:      fmt.Sprintf(":%v", ac.Options.Port),
@@ -185,9 +245,13 @@ func (ac *Webhook) Run(stop <-chan struct{}) error {
 		return nil
 	}
 
+	go ac.rotateCerts(ctx, stop, current)
+
+	atomic.StoreInt32(&ac.serving, 1)
+	defer atomic.StoreInt32(&ac.serving, 0)
 	serverBootstrapErrCh := make(chan struct{})
 	go func() {
-		if err := server.ListenAndServeTLS("", ""); err != nil {
+		if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
 			logger.Errorw("ListenAndServeTLS for admission webhook returned error", zap.Error(err))
 			close(serverBootstrapErrCh)
 		}
@@ -195,12 +259,24 @@ func (ac *Webhook) Run(stop <-chan struct{}) error {
 
 	select {
 	case <-stop:
-		return server.Close()
+		// Let the admission requests in flight finish.
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return server.Shutdown(ctx)
 	case <-serverBootstrapErrCh:
 		return errors.New("webhook server bootstrap failed")
 	}
 }
 
+// Ready returns an error until the webhook is registered and serves, and once
+// it stopped, so that it's only probed ready while it admits requests.
+func (ac *Webhook) Ready() error {
+	if atomic.LoadInt32(&ac.serving) == 0 {
+		return errors.New("the webhook isn't serving")
+	}
+	return nil
+}
+
 // ServeHTTP implements the external admission webhook for mutating
 // serving resources.
 func (ac *Webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -208,15 +284,34 @@ func (ac *Webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	logger := ac.Logger
 	logger.Infof("Webhook ServeHTTP request=%#v", r)
 
+	if ac.Options.DeprecationReportPath != "" && r.URL.Path == ac.Options.DeprecationReportPath {
+		if r.Method != http.MethodGet {
+			http.Error(w, "the deprecation report must be fetched with GET", http.StatusMethodNotAllowed)
+			return
+		}
+		ac.serveDeprecationReport(w)
+		return
+	}
+
 	// Verify the content type is accurate.
-	contentType := r.Header.Get("Content-Type")
-	if contentType != "application/json" {
-		http.Error(w, "invalid Content-Type, want `application/json`", http.StatusUnsupportedMediaType)
+	decoders := ac.Options.Decoders
+	if decoders == nil {
+		decoders = defaultDecoders
+	}
+	contentType, decoder, ok := decoders.lookup(r.Header.Get("Content-Type"))
+	if !ok {
+		http.Error(w, fmt.Sprintf("invalid Content-Type, want one of %s", strings.Join(decoders.mediaTypes(), ", ")),
+			http.StatusUnsupportedMediaType)
 		return
 	}
 
-	var review admissionv1beta1.AdmissionReview
-	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not read body: %v", err), http.StatusBadRequest)
+		return
+	}
+	review, err := decoder.Decode(body)
+	if err != nil {
 		http.Error(w, fmt.Sprintf("could not decode body: %v", err), http.StatusBadRequest)
 		return
 	}
@@ -251,10 +346,13 @@ func (ac *Webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	logger.Infof("AdmissionReview for %#v: %s/%s response=%#v",
 		review.Request.Kind, review.Request.Namespace, review.Request.Name, reviewResponse)
 
-	if err := json.NewEncoder(w).Encode(response); err != nil {
+	b, err := decoder.Encode(&response)
+	if err != nil {
 		http.Error(w, fmt.Sprintf("could encode response: %v", err), http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(b)
 
 	if ac.Options.StatsReporter != nil {
 		// Only report valid requests
@@ -322,6 +420,13 @@ func getOrGenerateKeyCertsFromSecret(ctx context.Context, client kubernetes.Inte
 		}
 	}
 
+	if options.CertOptions.needsRotation(secret.Data[secretServerCert], time.Now()) {
+		logger.Info("Certificates are about to expire, rotating them")
+		if secret, err = rotateSecret(ctx, client, secret, options); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
 	var ok bool
 	if serverKey, ok = secret.Data[secretServerKey]; !ok {
 		return nil, nil, nil, errors.New("server key missing")
@@ -332,9 +437,100 @@ func getOrGenerateKeyCertsFromSecret(ctx context.Context, client kubernetes.Inte
 	if caCert, ok = secret.Data[secretCACert]; !ok {
 		return nil, nil, nil, errors.New("ca cert missing")
 	}
+	if expiry, err := certExpiry(serverCert); err == nil {
+		reportCertExpiry(options.SecretName, time.Until(expiry))
+	}
 	return serverKey, serverCert, caCert, nil
 }
 
+// rotateSecret replaces the certificates in the secret with new ones. The
+// previous CA certificate stays in the CA bundle until it expires, see
+// caBundle, as the other replicas keep serving the previous certificate until
+// they check the secret again.
+func rotateSecret(ctx context.Context, client kubernetes.Interface, secret *corev1.Secret,
+	options *ControllerOptions) (*corev1.Secret, error) {
+	newSecret, err := generateSecret(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	newSecret.Data[secretCACert] = caBundle(newSecret.Data[secretCACert], secret.Data[secretCACert], time.Now())
+	secret = secret.DeepCopy()
+	secret.Data = newSecret.Data
+	updated, err := client.CoreV1().Secrets(secret.Namespace).Update(secret)
+	if apierrors.IsConflict(err) {
+		// Another replica rotated the certificates first, use theirs.
+		return client.CoreV1().Secrets(secret.Namespace).Get(secret.Name, metav1.GetOptions{})
+	}
+	return updated, err
+}
+
+// servingCert holds the certificate the webhook serves.
+type servingCert struct {
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func (sc *servingCert) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	return sc.cert, nil
+}
+
+// rotateCerts periodically rotates the certificates of the webhook when they are
+// about to expire, serving the new ones and registering the admission controllers
+// with the new CA certificate.
+func (ac *Webhook) rotateCerts(ctx context.Context, stop <-chan struct{}, current *servingCert) {
+	logger := logging.FromContext(ctx)
+	ticker := time.NewTicker(certCheckPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		serverKey, serverCert, caCert, err := getOrGenerateKeyCertsFromSecret(ctx, ac.Client, &ac.Options)
+		if err != nil {
+			logger.Errorw("failed to check the webhook certificates", zap.Error(err))
+			continue
+		}
+		if err := ac.swapCerts(ctx, current, serverKey, serverCert, caCert); err != nil {
+			logger.Errorw("failed to rotate the webhook certificates", zap.Error(err))
+		}
+	}
+}
+
+// swapCerts serves the given certificate if it isn't served yet, and registers
+// the admission controllers with the CA bundle holding the CA certificate that
+// signed it, and the previous ones the other replicas may still be served
+// with.
+func (ac *Webhook) swapCerts(ctx context.Context, current *servingCert, serverKey, serverCert, caCert []byte) error {
+	if b, _ := pem.Decode(serverCert); b != nil {
+		current.mu.RLock()
+		same := bytes.Equal(current.cert.Certificate[0], b.Bytes)
+		current.mu.RUnlock()
+		if same {
+			return nil
+		}
+	}
+
+	cert, err := tls.X509KeyPair(serverCert, serverKey)
+	if err != nil {
+		return err
+	}
+	for _, c := range ac.admissionControllers {
+		if err := c.Register(ctx, ac.Client, caCert); err != nil {
+			return err
+		}
+	}
+	current.mu.Lock()
+	current.cert = &cert
+	current.mu.Unlock()
+	logging.FromContext(ctx).Info("Rotated the webhook certificates")
+	return nil
+}
+
 func configureCerts(ctx context.Context, client kubernetes.Interface, options *ControllerOptions) (*tls.Config, []byte, error) {
 	var apiServerCACert []byte
 	if options.ClientAuth >= tls.VerifyClientCertIfGiven {
@@ -365,7 +561,7 @@ func makeErrorStatus(reason string, args ...interface{}) *admissionv1beta1.Admis
 }
 
 func generateSecret(ctx context.Context, options *ControllerOptions) (*corev1.Secret, error) {
-	serverKey, serverCert, caCert, err := CreateCerts(ctx, options.ServiceName, options.Namespace)
+	serverKey, serverCert, caCert, err := CreateCertsWithOptions(ctx, options.ServiceName, options.Namespace, options.CertOptions)
 	if err != nil {
 		return nil, err
 	}
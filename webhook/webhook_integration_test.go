@@ -93,6 +93,38 @@ func TestMissingContentType(t *testing.T) {
 	metricstest.CheckStatsNotReported(t, requestCountName, requestLatenciesName)
 }
 
+func TestReady(t *testing.T) {
+	ac, serverURL, err := testSetup(t)
+	if err != nil {
+		t.Fatalf("testSetup() = %v", err)
+	}
+	if err := ac.Ready(); err == nil {
+		t.Error("Ready() = nil before running, wanted an error")
+	}
+
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		if err := ac.Run(stopCh); err != nil {
+			t.Errorf("Unable to run controller: %s", err)
+		}
+	}()
+
+	if err := waitForServerAvailable(t, serverURL, testTimeout); err != nil {
+		t.Fatalf("waitForServerAvailable() = %v", err)
+	}
+	if err := ac.Ready(); err != nil {
+		t.Errorf("Ready() = %v while serving", err)
+	}
+
+	close(stopCh)
+	<-doneCh
+	if err := ac.Ready(); err == nil {
+		t.Error("Ready() = nil once stopped, wanted an error")
+	}
+}
+
 func TestEmptyRequestBody(t *testing.T) {
 	ac, serverURL, err := testSetup(t)
 	if err != nil {
@@ -17,7 +17,9 @@ limitations under the License.
 package webhook
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/pem"
 	"fmt"
 	"net"
@@ -251,6 +253,113 @@ func TestCertConfigurationForGeneratedSecret(t *testing.T) {
 	}
 }
 
+func TestCertRotationForExpiringSecret(t *testing.T) {
+	opts := newDefaultOptions()
+	opts.CertOptions = CertOptions{KeyType: ECDSAKey}
+	kubeClient, ac := newNonRunningTestWebhook(t, opts)
+	ctx := TestContextWithLogger(t)
+
+	// Create a secret with certificates expiring within the rotation lead time.
+	expiring := opts
+	expiring.CertOptions = CertOptions{Lifetime: 48 * time.Hour, RotationLeadTime: time.Hour}
+	oldSecret, err := generateSecret(ctx, &expiring)
+	if err != nil {
+		t.Fatalf("Failed to generate secret: %v", err)
+	}
+	if _, err := kubeClient.CoreV1().Secrets(oldSecret.Namespace).Create(oldSecret); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	serverKey, serverCert, caCert, err := getOrGenerateKeyCertsFromSecret(ctx, kubeClient, &ac.Options)
+	if err != nil {
+		t.Fatalf("Failed to get the certificates: %v", err)
+	}
+	if cmp.Equal(serverCert, oldSecret.Data[secretServerCert]) {
+		t.Fatal("Expected the expiring certificate to be rotated")
+	}
+	if ac.Options.CertOptions.needsRotation(serverCert, time.Now()) {
+		t.Error("The rotated certificate needs rotation")
+	}
+	if p, _ := pem.Decode(serverKey); p == nil || p.Type != "EC PRIVATE KEY" {
+		t.Errorf("Expected the rotated key to be an EC key, got %v", p)
+	}
+
+	secret, err := kubeClient.CoreV1().Secrets(opts.Namespace).Get(opts.SecretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get the secret: %v", err)
+	}
+	if !cmp.Equal(secret.Data[secretCACert], caCert) {
+		t.Error("Expected the rotated certificates to be stored in the secret")
+	}
+
+	// The replicas still serving the old certificate are trusted until they
+	// switch to the new one.
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caCert) {
+		t.Fatal("Failed to parse the CA bundle")
+	}
+	for name, certPEM := range map[string][]byte{"old": oldSecret.Data[secretServerCert], "new": serverCert} {
+		b, _ := pem.Decode(certPEM)
+		cert, err := x509.ParseCertificate(b.Bytes)
+		if err != nil {
+			t.Fatalf("Failed to parse the %s certificate: %v", name, err)
+		}
+		if _, err := cert.Verify(x509.VerifyOptions{Roots: roots}); err != nil {
+			t.Errorf("The %s certificate isn't trusted by the CA bundle: %v", name, err)
+		}
+	}
+}
+
+type recordingAdmissionController struct {
+	AdmissionController
+	caCert []byte
+}
+
+func (r *recordingAdmissionController) Register(_ context.Context, _ kubernetes.Interface, caCert []byte) error {
+	r.caCert = caCert
+	return nil
+}
+
+func TestSwapCerts(t *testing.T) {
+	ctx := TestContextWithLogger(t)
+	rac := &recordingAdmissionController{}
+	ac := &Webhook{admissionControllers: map[string]AdmissionController{"/": rac}}
+
+	key, cert, _, err := CreateCerts(ctx, "webhook", "ns")
+	if err != nil {
+		t.Fatalf("Failed to create certs: %v", err)
+	}
+	pair, err := tls.X509KeyPair(cert, key)
+	if err != nil {
+		t.Fatalf("Failed to create the key pair: %v", err)
+	}
+	current := &servingCert{cert: &pair}
+
+	// The served certificate isn't swapped.
+	if err := ac.swapCerts(ctx, current, key, cert, []byte("ca")); err != nil {
+		t.Fatalf("swapCerts() = %v", err)
+	}
+	if rac.caCert != nil {
+		t.Error("Expected the admission controllers not to be registered again")
+	}
+
+	newKey, newCert, newCA, err := CreateCerts(ctx, "webhook", "ns")
+	if err != nil {
+		t.Fatalf("Failed to create certs: %v", err)
+	}
+	if err := ac.swapCerts(ctx, current, newKey, newCert, newCA); err != nil {
+		t.Fatalf("swapCerts() = %v", err)
+	}
+	got, _ := current.get(nil)
+	want, _ := tls.X509KeyPair(newCert, newKey)
+	if !cmp.Equal(got.Certificate, want.Certificate) {
+		t.Error("Expected the new certificate to be served")
+	}
+	if !cmp.Equal(rac.caCert, newCA) {
+		t.Error("Expected the admission controllers to be registered with the new CA certificate")
+	}
+}
+
 func TestSettingWebhookClientAuth(t *testing.T) {
 	opts := newDefaultOptions()
 	if opts.ClientAuth != tls.NoClientCert {
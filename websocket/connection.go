@@ -20,8 +20,10 @@ import (
 	"bytes"
 	"encoding/gob"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"sync"
 	"time"
 
@@ -37,14 +39,42 @@ var (
 	// but no connection is already created.
 	ErrConnectionNotEstablished = errors.New("connection has not yet been established")
 
+	// ErrReconnectAttemptsExhausted is returned by methods that need a connection
+	// once the connection gave up reconnecting, as configured by its ReconnectPolicy.
+	ErrReconnectAttemptsExhausted = errors.New("exhausted all attempts to reconnect")
+
+	// ErrPongTimeout is passed to the unhealthy connection handler if no pong
+	// has been received within the configured pong timeout.
+	ErrPongTimeout = errors.New("no pong received within the timeout")
+
 	// errShuttingDown is returned internally once the shutdown signal has been sent.
 	errShuttingDown = errors.New("shutdown in progress")
 
-	// pongTimeout defines the amount of time allowed between two pongs to arrive
-	// before the connection is considered broken.
+	// pongTimeout defines the default amount of time allowed between two pongs
+	// to arrive before the connection is considered broken. Pings are sent 3
+	// times per pongTimeout interval by default.
 	pongTimeout = 10 * time.Second
 )
 
+// defaultDrainTimeout is the default time allowed to flush queued messages
+// when shutting down.
+const defaultDrainTimeout = time.Second
+
+// closeFrameTimeout is the time allowed to send the close frame when shutting
+// down, after the queued messages are flushed.
+const closeFrameTimeout = time.Second
+
+// connectionAttemptsPerRound is the number of connection attempts made before
+// the failure is reported and the backoff starts over.
+const connectionAttemptsPerRound = 20
+
+// Message is a message received over a websocket connection.
+type Message struct {
+	// Type is either websocket.TextMessage or websocket.BinaryMessage.
+	Type    int
+	Payload []byte
+}
+
 // RawConnection is an interface defining the methods needed
 // from a websocket connection
 type rawConnection interface {
@@ -53,6 +83,7 @@ type rawConnection interface {
 	Close() error
 
 	SetReadDeadline(deadline time.Time) error
+	SetWriteDeadline(deadline time.Time) error
 	SetPongHandler(func(string) error)
 }
 
@@ -64,12 +95,21 @@ type ManagedConnection struct {
 	closeChan chan struct{}
 	closeOnce sync.Once
 
+	// Used to reject new messages and flush the queued
+	// ones when shutting down gracefully.
+	drainOnce    sync.Once
+	draining     bool
+	drainTimeout time.Duration
+
 	// Used to capture asynchronous processes to be waited
 	// on when shutting the connection down.
 	processingWg sync.WaitGroup
 
 	// If set, messages will be forwarded to this channel
 	messageChan chan []byte
+	// If set, messages will be forwarded to this channel along
+	// with their type.
+	typedMessageChan chan<- Message
 
 	// This mutex controls access to the connection reference
 	// itself.
@@ -82,14 +122,44 @@ type ManagedConnection struct {
 
 	// Used for the exponential backoff when connecting
 	connectionBackoff wait.Backoff
+	reconnectPolicy   ReconnectPolicy
+	// The number of consecutive failed connection attempts and
+	// whether a connection has ever been established. Only
+	// accessed by the connecting goroutine.
+	failedAttempts int
+	hasConnected   bool
+
+	stateLock          sync.Mutex
+	state              ConnectionState
+	connectedAt        time.Time
+	stateChangeHandler func(from, to ConnectionState)
+
+	// Used to dial new connections.
+	dialer *websocket.Dialer
+	// If set, outgoing messages are compressed with compressionLevel
+	// if compression has been negotiated.
+	compression      bool
+	compressionLevel int
+
+	// Keepalive configuration. A ping is sent every pingInterval and the
+	// connection is considered broken if no pong arrived in pongTimeout.
+	// Either is disabled if not positive.
+	pingInterval     time.Duration
+	pongTimeout      time.Duration
+	unhealthyHandler func(error)
+
+	// If set, messages are buffered and written asynchronously.
+	sendQueue *sendQueue
+
+	stats *statsReporter
 }
 
 // NewDurableSendingConnection creates a new websocket connection
 // that can only send messages to the endpoint it connects to.
 // The connection will continuously be kept alive and reconnected
 // in case of a loss of connectivity.
-func NewDurableSendingConnection(target string, logger *zap.SugaredLogger) *ManagedConnection {
-	return NewDurableConnection(target, nil, logger)
+func NewDurableSendingConnection(target string, logger *zap.SugaredLogger, opts ...ConnectionOption) *ManagedConnection {
+	return NewDurableConnection(target, nil, logger, opts...)
 }
 
 // NewDurableConnection creates a new websocket connection, that
@@ -104,16 +174,30 @@ func NewDurableSendingConnection(target string, logger *zap.SugaredLogger) *Mana
 //
 // go func() {conn.Shutdown(); close(messageChan)}
 // go func() {for range messageChan {}}
-func NewDurableConnection(target string, messageChan chan []byte, logger *zap.SugaredLogger) *ManagedConnection {
-	websocketConnectionFactory := func() (rawConnection, error) {
-		dialer := &websocket.Dialer{
-			HandshakeTimeout: 3 * time.Second,
+func NewDurableConnection(target string, messageChan chan []byte, logger *zap.SugaredLogger, opts ...ConnectionOption) *ManagedConnection {
+	c := newConnection(nil, messageChan)
+	c.stats = newStatsReporter(target)
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.connectionFactory = func() (rawConnection, error) {
+		conn, _, err := c.dialer.Dial(target, nil)
+		if err != nil {
+			return nil, err
+		}
+		if c.compression {
+			conn.EnableWriteCompression(true)
+			if err := conn.SetCompressionLevel(c.compressionLevel); err != nil {
+				conn.Close()
+				return nil, err
+			}
 		}
-		conn, _, err := dialer.Dial(target, nil)
-		return conn, err
+		return conn, nil
 	}
 
-	c := newConnection(websocketConnectionFactory, messageChan)
+	if c.sendQueue != nil {
+		c.startQueue()
+	}
 
 	// Keep the connection alive asynchronously and reconnect on
 	// connection failure.
@@ -126,16 +210,28 @@ func NewDurableConnection(target string, messageChan chan []byte, logger *zap.Su
 			default:
 				logger.Infof("Connecting to %s", target)
 				if err := c.connect(); err != nil {
+					if err == ErrReconnectAttemptsExhausted {
+						logger.Errorf("Giving up connecting to %s after %d attempts", target, c.failedAttempts)
+						c.setState(StateFailed)
+						return
+					}
 					logger.With(zap.Error(err)).Errorf("Connecting to %s failed", target)
 					continue
 				}
 				logger.Debugf("Connected to %s", target)
 				if err := c.keepalive(); err != nil {
+					if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+						err = ErrPongTimeout
+					}
 					logger.With(zap.Error(err)).Errorf("Connection to %s broke down, reconnecting...", target)
+					if err != errShuttingDown && c.unhealthyHandler != nil {
+						c.unhealthyHandler(err)
+					}
 				}
 				if err := c.closeConnection(); err != nil {
 					logger.Errorw("Failed to close the connection after crashing", zap.Error(err))
 				}
+				c.setState(StateConnecting)
 			case <-c.closeChan:
 				logger.Infof("Connection to %s is being shutdown", target)
 				return
@@ -143,16 +239,20 @@ func NewDurableConnection(target string, messageChan chan []byte, logger *zap.Su
 		}
 	}()
 
-	// Keep sending pings 3 times per pongTimeout interval.
+	// Keep sending pings every pingInterval, unless disabled.
+	if c.pingInterval <= 0 {
+		return c
+	}
 	c.processingWg.Add(1)
 	go func() {
 		defer c.processingWg.Done()
 
-		ticker := time.NewTicker(pongTimeout / 3)
+		ticker := time.NewTicker(c.pingInterval)
 		defer ticker.Stop()
 		for {
 			select {
 			case <-ticker.C:
+				c.stats.reportUptime(c.uptime())
 				if err := c.write(websocket.PingMessage, []byte{}); err != nil {
 					logger.Errorw("Failed to send ping message to "+target, zap.Error(err))
 				}
@@ -167,50 +267,83 @@ func NewDurableConnection(target string, messageChan chan []byte, logger *zap.Su
 
 // newConnection creates a new connection primitive.
 func newConnection(connFactory func() (rawConnection, error), messageChan chan []byte) *ManagedConnection {
+	policy := DefaultReconnectPolicy()
 	conn := &ManagedConnection{
 		connectionFactory: connFactory,
 		closeChan:         make(chan struct{}),
 		messageChan:       messageChan,
-		connectionBackoff: wait.Backoff{
-			Duration: 100 * time.Millisecond,
-			Factor:   1.3,
-			Steps:    20,
-			Jitter:   0.5,
+		dialer: &websocket.Dialer{
+			HandshakeTimeout: 3 * time.Second,
 		},
+		connectionBackoff: policy.backoff(),
+		reconnectPolicy:   policy,
+		pingInterval:      pongTimeout / 3,
+		pongTimeout:       pongTimeout,
+		drainTimeout:      defaultDrainTimeout,
+		stats:             newStatsReporter(""),
 	}
 
 	return conn
 }
 
-// connect tries to establish a websocket connection.
+// connect tries to establish a websocket connection. It makes up to
+// connectionBackoff.Steps attempts before returning wait.ErrWaitTimeout
+// and returns ErrReconnectAttemptsExhausted once the overall attempts
+// allowed by the ReconnectPolicy are used up.
 func (c *ManagedConnection) connect() error {
-	return wait.ExponentialBackoff(c.connectionBackoff, func() (bool, error) {
+	backoff := c.connectionBackoff
+	for attempt := 1; ; attempt++ {
 		select {
+		case <-c.closeChan:
+			return errShuttingDown
 		default:
-			conn, err := c.connectionFactory()
-			if err != nil {
-				return false, nil
-			}
+		}
 
+		conn, err := c.connectionFactory()
+		if err == nil {
 			// Setting the read deadline will cause NextReader in read
 			// to fail if it is exceeded. This deadline is reset each
 			// time we receive a pong message so we know the connection
 			// is still intact.
-			conn.SetReadDeadline(time.Now().Add(pongTimeout))
-			conn.SetPongHandler(func(string) error {
-				conn.SetReadDeadline(time.Now().Add(pongTimeout))
-				return nil
-			})
+			if c.pongTimeout > 0 {
+				conn.SetReadDeadline(time.Now().Add(c.pongTimeout))
+				conn.SetPongHandler(func(string) error {
+					conn.SetReadDeadline(time.Now().Add(c.pongTimeout))
+					return nil
+				})
+			}
 
 			c.connectionLock.Lock()
-			defer c.connectionLock.Unlock()
-
 			c.connection = conn
-			return true, nil
+			c.connectionLock.Unlock()
+
+			reconnected := c.hasConnected
+			c.hasConnected = true
+			c.failedAttempts = 0
+			c.setState(StateConnected)
+			if reconnected {
+				c.stats.reportReconnect()
+				if c.reconnectPolicy.OnReconnect != nil {
+					c.reconnectPolicy.OnReconnect()
+				}
+			}
+			return nil
+		}
+
+		c.failedAttempts++
+		if max := c.reconnectPolicy.MaxAttempts; max > 0 && c.failedAttempts >= max {
+			return ErrReconnectAttemptsExhausted
+		}
+		if attempt >= c.connectionBackoff.Steps {
+			return wait.ErrWaitTimeout
+		}
+
+		select {
+		case <-time.After(backoff.Step()):
 		case <-c.closeChan:
-			return false, errShuttingDown
+			return errShuttingDown
 		}
-	})
+	}
 }
 
 // keepalive keeps the connection open.
@@ -259,12 +392,17 @@ func (c *ManagedConnection) read() error {
 		return err
 	}
 
-	// Send the message to the channel if its an application level message
-	// and if that channel is set.
-	// TODO(markusthoemmes): Return the messageType along with the payload.
-	if c.messageChan != nil && (messageType == websocket.TextMessage || messageType == websocket.BinaryMessage) {
+	// Send the message to the channels if its an application level message
+	// and if any channel is set.
+	if (c.messageChan != nil || c.typedMessageChan != nil) && (messageType == websocket.TextMessage || messageType == websocket.BinaryMessage) {
 		if message, _ := ioutil.ReadAll(reader); message != nil {
-			c.messageChan <- message
+			c.stats.reportReceived()
+			if c.messageChan != nil {
+				c.messageChan <- message
+			}
+			if c.typedMessageChan != nil {
+				c.typedMessageChan <- Message{Type: messageType, Payload: message}
+			}
 		}
 	}
 
@@ -282,7 +420,23 @@ func (c *ManagedConnection) write(messageType int, body []byte) error {
 	c.writerLock.Lock()
 	defer c.writerLock.Unlock()
 
-	return c.connection.WriteMessage(messageType, body)
+	if err := c.connection.WriteMessage(messageType, body); err != nil {
+		return err
+	}
+	if messageType == websocket.TextMessage || messageType == websocket.BinaryMessage {
+		c.stats.reportSent()
+	}
+	return nil
+}
+
+// writeDirect writes an application message bypassing the send queue and
+// records failures.
+func (c *ManagedConnection) writeDirect(messageType int, body []byte) error {
+	err := c.write(messageType, body)
+	if err != nil {
+		c.stats.reportSendError()
+	}
+	return err
 }
 
 // Status checks the connection status of the webhook.
@@ -291,29 +445,107 @@ func (c *ManagedConnection) Status() error {
 	defer c.connectionLock.RUnlock()
 
 	if c.connection == nil {
+		if c.State() == StateFailed {
+			return ErrReconnectAttemptsExhausted
+		}
 		return ErrConnectionNotEstablished
 	}
 	return nil
 }
 
 // Send sends an encodable message over the websocket connection.
+// If the connection has a send queue, the message is queued and
+// written asynchronously.
 func (c *ManagedConnection) Send(msg interface{}) error {
+	if c.isDraining() {
+		return errShuttingDown
+	}
+
 	var b bytes.Buffer
 	enc := gob.NewEncoder(&b)
 	if err := enc.Encode(msg); err != nil {
 		return err
 	}
 
-	return c.write(websocket.BinaryMessage, b.Bytes())
+	if c.sendQueue != nil {
+		return c.enqueue(queuedMessage{messageType: websocket.BinaryMessage, payload: b.Bytes()})
+	}
+	return c.writeDirect(websocket.BinaryMessage, b.Bytes())
 }
 
-// Shutdown closes the websocket connection.
+// SendRaw sends a message of the given type over the websocket connection
+// without encoding it. messageType is either websocket.TextMessage or
+// websocket.BinaryMessage. If the connection has a send queue, the message
+// is queued and written asynchronously.
+func (c *ManagedConnection) SendRaw(messageType int, msg []byte) error {
+	if messageType != websocket.TextMessage && messageType != websocket.BinaryMessage {
+		return fmt.Errorf("unsupported message type %d", messageType)
+	}
+	if c.isDraining() {
+		return errShuttingDown
+	}
+	if c.sendQueue != nil {
+		return c.enqueue(queuedMessage{messageType: messageType, payload: msg})
+	}
+	return c.writeDirect(messageType, msg)
+}
+
+// Shutdown gracefully closes the websocket connection, allowing up to the
+// drain timeout to flush queued messages and send a close frame.
 func (c *ManagedConnection) Shutdown() error {
+	_, err := c.GracefulShutdown(c.drainTimeout)
+	return err
+}
+
+// GracefulShutdown closes the websocket connection. New messages are
+// rejected right away, while queued messages are written until the
+// timeout expires. A close frame is then sent, with its own deadline so that
+// it's sent even if flushing took the whole timeout, before the underlying
+// connection is torn down. It returns the number of queued messages that were
+// dropped.
+func (c *ManagedConnection) GracefulShutdown(timeout time.Duration) (int, error) {
+	dropped := 0
+	c.drainOnce.Do(func() {
+		c.stateLock.Lock()
+		c.draining = true
+		c.stateLock.Unlock()
+
+		if c.sendQueue != nil {
+			dropped = c.flushQueue(time.Now().Add(timeout))
+		}
+	})
+
 	c.closeOnce.Do(func() {
 		close(c.closeChan)
+		c.setState(StateClosed)
+		c.sendCloseFrame(time.Now().Add(closeFrameTimeout))
 	})
 
 	err := c.closeConnection()
 	c.processingWg.Wait()
-	return err
+	return dropped, err
+}
+
+// isDraining returns true once the connection started shutting down.
+func (c *ManagedConnection) isDraining() bool {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	return c.draining
+}
+
+// sendCloseFrame tells the other end that the connection is about to be
+// closed. Errors are ignored as the connection is closed regardless.
+func (c *ManagedConnection) sendCloseFrame(deadline time.Time) {
+	c.connectionLock.RLock()
+	defer c.connectionLock.RUnlock()
+
+	if c.connection == nil {
+		return
+	}
+
+	c.writerLock.Lock()
+	defer c.writerLock.Unlock()
+
+	c.connection.SetWriteDeadline(deadline)
+	c.connection.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 }
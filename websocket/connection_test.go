@@ -37,14 +37,22 @@ const propagationTimeout = 5 * time.Second
 type inspectableConnection struct {
 	nextReaderCalls      chan struct{}
 	writeMessageCalls    chan struct{}
+	writeCloseCalls      chan struct{}
 	closeCalls           chan struct{}
 	setReadDeadlineCalls chan struct{}
 	setPongHandlerCalls  chan struct{}
+	writeDeadlines       chan time.Time
 
 	nextReaderFunc func() (int, io.Reader, error)
 }
 
 func (c *inspectableConnection) WriteMessage(messageType int, data []byte) error {
+	if messageType == websocket.CloseMessage {
+		if c.writeCloseCalls != nil {
+			c.writeCloseCalls <- struct{}{}
+		}
+		return nil
+	}
 	if c.writeMessageCalls != nil {
 		c.writeMessageCalls <- struct{}{}
 	}
@@ -72,6 +80,13 @@ func (c *inspectableConnection) SetReadDeadline(deadline time.Time) error {
 	return nil
 }
 
+func (c *inspectableConnection) SetWriteDeadline(deadline time.Time) error {
+	if c.writeDeadlines != nil {
+		c.writeDeadlines <- deadline
+	}
+	return nil
+}
+
 func (c *inspectableConnection) SetPongHandler(func(string) error) {
 	if c.setPongHandlerCalls != nil {
 		c.setPongHandlerCalls <- struct{}{}
@@ -397,3 +412,272 @@ func TestDurableConnectionSendsPingsRegularly(t *testing.T) {
 		<-pingReceived
 	}
 }
+
+func TestDurableConnectionKeepaliveDisabled(t *testing.T) {
+	defer ktesting.ClearAll()
+
+	upgrader := websocket.Upgrader{}
+	pingReceived := make(chan struct{}, 10)
+	messageReceived := make(chan []byte, 10)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		c.SetPingHandler(func(string) error {
+			pingReceived <- struct{}{}
+			return nil
+		})
+		for {
+			_, msg, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			messageReceived <- msg
+		}
+	}))
+	defer s.Close()
+
+	logger := ktesting.TestLogger(t)
+	target := "ws" + strings.TrimPrefix(s.URL, "http")
+	conn := NewDurableSendingConnection(target, logger, WithKeepalive(0, 0))
+	defer conn.Shutdown()
+
+	if err := wait.PollImmediate(10*time.Millisecond, propagationTimeout, func() (bool, error) {
+		return conn.SendRaw(websocket.TextMessage, []byte("hello")) == nil, nil
+	}); err != nil {
+		t.Fatalf("Failed to send a message: %v", err)
+	}
+	select {
+	case <-messageReceived:
+	case <-time.After(propagationTimeout):
+		t.Fatal("Timed out waiting for the message")
+	}
+	// Without the pings nor their pongs, the connection stays up.
+	select {
+	case <-pingReceived:
+		t.Error("Received a ping, wanted none")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := conn.Status(); err != nil {
+		t.Errorf("Status() = %v, want nil", err)
+	}
+}
+
+func TestConnectGivesUpAfterMaxAttempts(t *testing.T) {
+	gotConnects := 0
+	connFactory := func() (rawConnection, error) {
+		gotConnects++
+		return nil, errors.New("connection error")
+	}
+	conn := newConnection(connFactory, nil)
+	policy := DefaultReconnectPolicy()
+	policy.InitialDelay = time.Millisecond
+	policy.MaxAttempts = 3
+	WithReconnectPolicy(policy)(conn)
+
+	if err := conn.connect(); err != ErrReconnectAttemptsExhausted {
+		t.Errorf("connect() = %v, want %v", err, ErrReconnectAttemptsExhausted)
+	}
+	if gotConnects != policy.MaxAttempts {
+		t.Errorf("Got %d connection attempts, want %d", gotConnects, policy.MaxAttempts)
+	}
+}
+
+func TestDurableConnectionFailsAfterMaxAttempts(t *testing.T) {
+	defer ktesting.ClearAll()
+	logger := ktesting.TestLogger(t)
+
+	policy := DefaultReconnectPolicy()
+	policy.InitialDelay = time.Millisecond
+	policy.MaxAttempts = 2
+
+	failed := make(chan struct{})
+	conn := NewDurableSendingConnection("ws://127.0.0.1:0", logger,
+		WithReconnectPolicy(policy),
+		WithStateChangeHandler(func(from, to ConnectionState) {
+			if to == StateFailed {
+				close(failed)
+			}
+		}))
+	defer conn.Shutdown()
+
+	select {
+	case <-failed:
+	case <-time.After(propagationTimeout):
+		t.Fatal("Timed out waiting for the connection to fail")
+	}
+	if got, want := conn.Status(), ErrReconnectAttemptsExhausted; got != want {
+		t.Errorf("Status() = %v, want %v", got, want)
+	}
+}
+
+func TestDurableConnectionStateChanges(t *testing.T) {
+	defer ktesting.ClearAll()
+	reconnectChan := make(chan struct{})
+
+	upgrader := websocket.Upgrader{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		<-reconnectChan
+		c.Close()
+	}))
+	defer s.Close()
+
+	states := make(chan ConnectionState, 10)
+	reconnected := make(chan struct{}, 1)
+	policy := DefaultReconnectPolicy()
+	policy.InitialDelay = 10 * time.Millisecond
+	policy.OnReconnect = func() {
+		reconnected <- struct{}{}
+	}
+
+	logger := ktesting.TestLogger(t)
+	target := "ws" + strings.TrimPrefix(s.URL, "http")
+	conn := NewDurableSendingConnection(target, logger,
+		WithReconnectPolicy(policy),
+		WithStateChangeHandler(func(from, to ConnectionState) {
+			select {
+			case states <- to:
+			default:
+			}
+		}))
+
+	if got := <-states; got != StateConnected {
+		t.Errorf("State = %v, want %v", got, StateConnected)
+	}
+	reconnectChan <- struct{}{}
+	if got := <-states; got != StateConnecting {
+		t.Errorf("State = %v, want %v", got, StateConnecting)
+	}
+	if got := <-states; got != StateConnected {
+		t.Errorf("State = %v, want %v", got, StateConnected)
+	}
+	select {
+	case <-reconnected:
+	case <-time.After(propagationTimeout):
+		t.Error("Timed out waiting for the reconnect callback")
+	}
+
+	conn.Shutdown()
+	close(reconnectChan)
+	if got, want := conn.State(), StateClosed; got != want {
+		t.Errorf("State() = %v, want %v", got, want)
+	}
+}
+
+func TestDurableConnectionDetectsMissingPongs(t *testing.T) {
+	defer ktesting.ClearAll()
+
+	upgrader := websocket.Upgrader{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		// Swallow pings without ever answering with a pong.
+		c.SetPingHandler(func(string) error { return nil })
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer s.Close()
+
+	unhealthy := make(chan error, 10)
+	logger := ktesting.TestLogger(t)
+	target := "ws" + strings.TrimPrefix(s.URL, "http")
+	conn := NewDurableSendingConnection(target, logger,
+		WithKeepalive(20*time.Millisecond, 100*time.Millisecond),
+		WithUnhealthyConnectionHandler(func(err error) {
+			select {
+			case unhealthy <- err:
+			default:
+			}
+		}))
+	defer conn.Shutdown()
+
+	select {
+	case err := <-unhealthy:
+		if err != ErrPongTimeout {
+			t.Errorf("Unhealthy connection error = %v, want %v", err, ErrPongTimeout)
+		}
+	case <-time.After(propagationTimeout):
+		t.Fatal("Timed out waiting for the connection to be detected unhealthy")
+	}
+}
+
+func TestSendRawUnsupportedType(t *testing.T) {
+	conn := newConnection(nil, nil)
+	if err := conn.SendRaw(websocket.PingMessage, nil); err == nil {
+		t.Error("SendRaw() = nil, wanted an error")
+	}
+}
+
+func TestDurableConnectionBinaryMessages(t *testing.T) {
+	defer ktesting.ClearAll()
+
+	for _, compress := range []bool{false, true} {
+		upgrader := websocket.Upgrader{EnableCompression: compress}
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			// Echo every message with its type.
+			for {
+				messageType, message, err := c.ReadMessage()
+				if err != nil {
+					return
+				}
+				if err := c.WriteMessage(messageType, message); err != nil {
+					return
+				}
+			}
+		}))
+
+		messages := make(chan Message, 2)
+		opts := []ConnectionOption{WithTypedMessageChannel(messages)}
+		if compress {
+			opts = append(opts, WithCompression(1))
+		}
+		logger := ktesting.TestLogger(t)
+		target := "ws" + strings.TrimPrefix(s.URL, "http")
+		conn := NewDurableConnection(target, nil, logger, opts...)
+
+		err := wait.PollImmediate(50*time.Millisecond, 5*time.Second, func() (bool, error) {
+			return conn.SendRaw(websocket.BinaryMessage, []byte{0x1, 0x2}) == nil, nil
+		})
+		if err != nil {
+			t.Fatalf("Timed out trying to send a message: %v", err)
+		}
+		if err := conn.SendRaw(websocket.TextMessage, []byte("text")); err != nil {
+			t.Fatalf("SendRaw() = %v", err)
+		}
+
+		want := []Message{{
+			Type:    websocket.BinaryMessage,
+			Payload: []byte{0x1, 0x2},
+		}, {
+			Type:    websocket.TextMessage,
+			Payload: []byte("text"),
+		}}
+		for _, w := range want {
+			select {
+			case got := <-messages:
+				if got.Type != w.Type || string(got.Payload) != string(w.Payload) {
+					t.Errorf("Received %v, want %v", got, w)
+				}
+			case <-time.After(propagationTimeout):
+				t.Fatal("Timed out waiting for the echoed message")
+			}
+		}
+
+		conn.Shutdown()
+		s.Close()
+	}
+}
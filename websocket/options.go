@@ -0,0 +1,138 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package websocket
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// ConnectionOption configures a ManagedConnection.
+type ConnectionOption func(*ManagedConnection)
+
+// ReconnectPolicy defines how a ManagedConnection (re)establishes its
+// underlying connection.
+type ReconnectPolicy struct {
+	// InitialDelay is the delay after the first failed connection attempt.
+	InitialDelay time.Duration
+	// MaxDelay caps the delay between two connection attempts. Zero means
+	// no cap.
+	MaxDelay time.Duration
+	// Factor is the multiplier applied to the delay after each failed attempt.
+	Factor float64
+	// Jitter is the amount of randomness added to each delay, as a fraction
+	// of the delay.
+	Jitter float64
+	// MaxAttempts is the number of consecutive failed connection attempts
+	// after which the connection gives up and transitions to StateFailed.
+	// Zero means the connection is retried forever.
+	MaxAttempts int
+	// OnReconnect, if set, is called every time the connection has been
+	// re-established after it was lost.
+	OnReconnect func()
+}
+
+// DefaultReconnectPolicy returns the ReconnectPolicy used if none is given.
+func DefaultReconnectPolicy() ReconnectPolicy {
+	return ReconnectPolicy{
+		InitialDelay: 100 * time.Millisecond,
+		Factor:       1.3,
+		Jitter:       0.5,
+	}
+}
+
+// backoff returns the backoff for a single round of connection attempts.
+func (p ReconnectPolicy) backoff() wait.Backoff {
+	return wait.Backoff{
+		Duration: p.InitialDelay,
+		Factor:   p.Factor,
+		Jitter:   p.Jitter,
+		Steps:    connectionAttemptsPerRound,
+		Cap:      p.MaxDelay,
+	}
+}
+
+// WithReconnectPolicy sets the policy used to (re)establish the connection.
+// Start from DefaultReconnectPolicy and adjust as needed.
+func WithReconnectPolicy(policy ReconnectPolicy) ConnectionOption {
+	return func(c *ManagedConnection) {
+		c.reconnectPolicy = policy
+		c.connectionBackoff = policy.backoff()
+	}
+}
+
+// WithStateChangeHandler registers a function that is called whenever the
+// state of the connection changes. The handler is called synchronously and
+// must not block.
+func WithStateChangeHandler(handler func(from, to ConnectionState)) ConnectionOption {
+	return func(c *ManagedConnection) {
+		c.stateChangeHandler = handler
+	}
+}
+
+// WithKeepalive configures the connection to send a ping every pingInterval
+// and to consider the connection broken and reconnect if no pong has been
+// received within pongTimeout. pingInterval should be well below pongTimeout
+// to tolerate a lost ping. A pingInterval of zero or less disables the pings
+// and a pongTimeout of zero or less disables the detection of dead
+// connections, so both should be disabled together.
+func WithKeepalive(pingInterval, pongTimeout time.Duration) ConnectionOption {
+	return func(c *ManagedConnection) {
+		c.pingInterval = pingInterval
+		c.pongTimeout = pongTimeout
+	}
+}
+
+// WithUnhealthyConnectionHandler registers a function that is called when
+// the connection broke down, before it is reconnected. The error is
+// ErrPongTimeout if the connection was detected dead by the keepalive.
+// The handler is called synchronously and must not block.
+func WithUnhealthyConnectionHandler(handler func(error)) ConnectionOption {
+	return func(c *ManagedConnection) {
+		c.unhealthyHandler = handler
+	}
+}
+
+// WithTypedMessageChannel forwards incoming messages to the given channel
+// along with their type, so that binary and text messages can be told
+// apart. The same draining rules as for the channel passed to
+// NewDurableConnection apply.
+func WithTypedMessageChannel(messageChan chan<- Message) ConnectionOption {
+	return func(c *ManagedConnection) {
+		c.typedMessageChan = messageChan
+	}
+}
+
+// WithCompression negotiates permessage-deflate compression with the server
+// and, if accepted, compresses outgoing messages with the given level as
+// defined by compress/flate.
+func WithCompression(level int) ConnectionOption {
+	return func(c *ManagedConnection) {
+		c.dialer.EnableCompression = true
+		c.compression = true
+		c.compressionLevel = level
+	}
+}
+
+// WithDrainTimeout sets the time Shutdown allows to flush queued messages
+// before a close frame is sent and the connection is torn down.
+func WithDrainTimeout(timeout time.Duration) ConnectionOption {
+	return func(c *ManagedConnection) {
+		c.drainTimeout = timeout
+	}
+}
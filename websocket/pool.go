@@ -0,0 +1,226 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"go.uber.org/zap"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// ErrNoTargets is returned by Pool methods that need at least one
+// connection if the pool has none.
+var ErrNoTargets = errors.New("pool has no targets")
+
+// TargetResolver returns the set of targets a Pool should be connected to.
+type TargetResolver func() ([]string, error)
+
+// Pool maintains a ManagedConnection to each target returned by a
+// TargetResolver. Targets are only re-resolved when Resync is called,
+// for example from an informer's event handler or on a timer.
+type Pool struct {
+	resolve TargetResolver
+	logger  *zap.SugaredLogger
+
+	// newConnection creates the connection to a single target.
+	newConnection func(target string) *ManagedConnection
+
+	mu          sync.RWMutex
+	connections map[string]*ManagedConnection
+	shutdown    bool
+}
+
+// NewPool creates a Pool connecting to the targets returned by resolve.
+// Incoming messages of all connections are passed to messageChan, which
+// can be nil, with the same caveats as for NewDurableConnection. The given
+// options are applied to every connection. The pool is empty until Resync
+// is called for the first time.
+func NewPool(resolve TargetResolver, messageChan chan []byte, logger *zap.SugaredLogger, opts ...ConnectionOption) *Pool {
+	return &Pool{
+		resolve: resolve,
+		logger:  logger,
+		newConnection: func(target string) *ManagedConnection {
+			return NewDurableConnection(target, messageChan, logger.With(zap.String("target", target)), opts...)
+		},
+		connections: make(map[string]*ManagedConnection),
+	}
+}
+
+// Resync resolves the targets and reconciles the pool's connections:
+// connections to new targets are created and connections to targets that
+// are gone are shut down.
+func (p *Pool) Resync() error {
+	targets, err := p.resolve()
+	if err != nil {
+		return fmt.Errorf("failed to resolve targets: %v", err)
+	}
+	want := sets.NewString(targets...)
+
+	p.mu.Lock()
+	if p.shutdown {
+		p.mu.Unlock()
+		return errShuttingDown
+	}
+	var removed []*ManagedConnection
+	for target, conn := range p.connections {
+		if !want.Has(target) {
+			removed = append(removed, conn)
+			delete(p.connections, target)
+		}
+	}
+	for target := range want {
+		if _, ok := p.connections[target]; !ok {
+			p.connections[target] = p.newConnection(target)
+		}
+	}
+	p.mu.Unlock()
+
+	// Shut connections down outside of the lock as it might take a while.
+	for _, conn := range removed {
+		if err := conn.Shutdown(); err != nil {
+			p.logger.Errorw("Failed to shut down the connection to a removed target", zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// Targets returns the sorted list of targets the pool maintains connections to.
+func (p *Pool) Targets() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	targets := make([]string, 0, len(p.connections))
+	for target := range p.connections {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// SendAll sends the message to every target. It returns an aggregate of
+// the errors of all failed sends.
+func (p *Pool) SendAll(msg interface{}) error {
+	return p.forAll(func(c *ManagedConnection) error {
+		return c.Send(msg)
+	})
+}
+
+// SendRawAll is like SendAll for messages that are not to be encoded.
+func (p *Pool) SendRawAll(messageType int, msg []byte) error {
+	return p.forAll(func(c *ManagedConnection) error {
+		return c.SendRaw(messageType, msg)
+	})
+}
+
+// SendAny sends the message to a single, randomly chosen target. Other
+// targets are tried in turn if sending fails.
+func (p *Pool) SendAny(msg interface{}) error {
+	return p.forAny(func(c *ManagedConnection) error {
+		return c.Send(msg)
+	})
+}
+
+// SendRawAny is like SendAny for messages that are not to be encoded.
+func (p *Pool) SendRawAny(messageType int, msg []byte) error {
+	return p.forAny(func(c *ManagedConnection) error {
+		return c.SendRaw(messageType, msg)
+	})
+}
+
+// Shutdown shuts down all connections of the pool. The pool cannot be
+// used afterwards.
+func (p *Pool) Shutdown() error {
+	p.mu.Lock()
+	p.shutdown = true
+	connections := p.connections
+	p.connections = make(map[string]*ManagedConnection)
+	p.mu.Unlock()
+
+	var errs []error
+	for target, conn := range connections {
+		if err := conn.Shutdown(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", target, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// snapshot returns the current connections keyed by target.
+func (p *Pool) snapshot() map[string]*ManagedConnection {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	connections := make(map[string]*ManagedConnection, len(p.connections))
+	for target, conn := range p.connections {
+		connections[target] = conn
+	}
+	return connections
+}
+
+func (p *Pool) forAll(send func(*ManagedConnection) error) error {
+	connections := p.snapshot()
+	if len(connections) == 0 {
+		return ErrNoTargets
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for target, conn := range connections {
+		wg.Add(1)
+		go func(target string, conn *ManagedConnection) {
+			defer wg.Done()
+			if err := send(conn); err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				errs = append(errs, fmt.Errorf("%s: %v", target, err))
+			}
+		}(target, conn)
+	}
+	wg.Wait()
+	return utilerrors.NewAggregate(errs)
+}
+
+func (p *Pool) forAny(send func(*ManagedConnection) error) error {
+	connections := p.snapshot()
+	if len(connections) == 0 {
+		return ErrNoTargets
+	}
+
+	targets := make([]string, 0, len(connections))
+	for target := range connections {
+		targets = append(targets, target)
+	}
+	var errs []error
+	for _, i := range rand.Perm(len(targets)) {
+		target := targets[i]
+		if err := send(connections[target]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", target, err))
+			continue
+		}
+		return nil
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package websocket
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	ktesting "knative.dev/pkg/logging/testing"
+)
+
+// fakePool returns a Pool whose connections are established immediately
+// using the inspectable connections of spies. Targets without a spy never
+// connect.
+func fakePool(t *testing.T, targets *[]string, spies map[string]*inspectableConnection) *Pool {
+	p := NewPool(func() ([]string, error) {
+		return *targets, nil
+	}, nil, ktesting.TestLogger(t))
+	p.newConnection = func(target string) *ManagedConnection {
+		spy, ok := spies[target]
+		if !ok {
+			return newConnection(errConnFactory(ErrConnectionNotEstablished), nil)
+		}
+		c := newConnection(staticConnFactory(spy), nil)
+		c.connect()
+		return c
+	}
+	return p
+}
+
+func TestPoolResync(t *testing.T) {
+	defer ktesting.ClearAll()
+	targets := []string{"a", "b"}
+	spies := map[string]*inspectableConnection{
+		"a": {closeCalls: make(chan struct{}, 1)},
+		"b": {closeCalls: make(chan struct{}, 1)},
+		"c": {closeCalls: make(chan struct{}, 1)},
+	}
+	p := fakePool(t, &targets, spies)
+	defer p.Shutdown()
+
+	if got := p.Targets(); len(got) != 0 {
+		t.Errorf("Targets() = %v, wanted an empty pool before Resync", got)
+	}
+
+	if err := p.Resync(); err != nil {
+		t.Fatalf("Resync() = %v", err)
+	}
+	if got, want := p.Targets(), []string{"a", "b"}; !cmp.Equal(got, want) {
+		t.Errorf("Targets() = %v, want %v", got, want)
+	}
+
+	targets = []string{"b", "c"}
+	if err := p.Resync(); err != nil {
+		t.Fatalf("Resync() = %v", err)
+	}
+	if got, want := p.Targets(), []string{"b", "c"}; !cmp.Equal(got, want) {
+		t.Errorf("Targets() = %v, want %v", got, want)
+	}
+	if got := len(spies["a"].closeCalls); got != 1 {
+		t.Errorf("Connection to removed target closed %d times, want 1", got)
+	}
+	if got := len(spies["b"].closeCalls); got != 0 {
+		t.Errorf("Connection to kept target closed %d times, want 0", got)
+	}
+}
+
+func TestPoolResyncError(t *testing.T) {
+	defer ktesting.ClearAll()
+	p := NewPool(func() ([]string, error) {
+		return nil, errors.New("resolver failed")
+	}, nil, ktesting.TestLogger(t))
+
+	if err := p.Resync(); err == nil {
+		t.Error("Resync() = nil, wanted an error")
+	}
+}
+
+func TestPoolSendAll(t *testing.T) {
+	defer ktesting.ClearAll()
+	targets := []string{"a", "b", "broken"}
+	spies := map[string]*inspectableConnection{
+		"a": {writeMessageCalls: make(chan struct{}, 1)},
+		"b": {writeMessageCalls: make(chan struct{}, 1)},
+	}
+	p := fakePool(t, &targets, spies)
+	defer p.Shutdown()
+
+	if err := p.SendAll("test"); err != ErrNoTargets {
+		t.Errorf("SendAll() = %v, want %v", err, ErrNoTargets)
+	}
+
+	p.Resync()
+	if err := p.SendAll("test"); err == nil {
+		t.Error("SendAll() = nil, wanted an error for the broken target")
+	}
+	for _, target := range []string{"a", "b"} {
+		if got := len(spies[target].writeMessageCalls); got != 1 {
+			t.Errorf("Target %s got %d messages, want 1", target, got)
+		}
+	}
+}
+
+func TestPoolSendAny(t *testing.T) {
+	defer ktesting.ClearAll()
+	targets := []string{"a", "broken"}
+	spies := map[string]*inspectableConnection{
+		"a": {writeMessageCalls: make(chan struct{}, 10)},
+	}
+	p := fakePool(t, &targets, spies)
+	defer p.Shutdown()
+
+	if err := p.SendAny("test"); err != ErrNoTargets {
+		t.Errorf("SendAny() = %v, want %v", err, ErrNoTargets)
+	}
+
+	p.Resync()
+	// The broken target is skipped, whatever order the targets are tried in.
+	for i := 0; i < 5; i++ {
+		if err := p.SendAny("test"); err != nil {
+			t.Errorf("SendAny() = %v", err)
+		}
+	}
+	if got := len(spies["a"].writeMessageCalls); got != 5 {
+		t.Errorf("Target a got %d messages, want 5", got)
+	}
+
+	targets = []string{"broken"}
+	p.Resync()
+	if err := p.SendAny("test"); err == nil {
+		t.Error("SendAny() = nil, wanted an error")
+	}
+}
+
+func TestPoolShutdown(t *testing.T) {
+	defer ktesting.ClearAll()
+	targets := []string{"a"}
+	spies := map[string]*inspectableConnection{
+		"a": {closeCalls: make(chan struct{}, 1)},
+	}
+	p := fakePool(t, &targets, spies)
+	p.Resync()
+
+	if err := p.Shutdown(); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if got := len(spies["a"].closeCalls); got != 1 {
+		t.Errorf("Connection closed %d times, want 1", got)
+	}
+	if err := p.Resync(); err == nil {
+		t.Error("Resync() = nil, wanted an error after Shutdown")
+	}
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package websocket
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrSendQueueFull is returned by Send if the message could not be queued
+// because the send queue is full.
+var ErrSendQueueFull = errors.New("send queue is full")
+
+// queueRetryInterval is the time to wait before retrying to write a queued
+// message after a failed attempt.
+const queueRetryInterval = 100 * time.Millisecond
+
+// OverflowPolicy defines what happens when a message is sent while the send
+// queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowError rejects the message with ErrSendQueueFull.
+	OverflowError OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest queued message to make room.
+	OverflowDropOldest
+	// OverflowBlock blocks the sender until there is room in the queue or
+	// the block timeout expires, in which case ErrSendQueueFull is returned.
+	OverflowBlock
+)
+
+// queuedMessage is a message waiting to be written to the connection.
+type queuedMessage struct {
+	messageType int
+	payload     []byte
+}
+
+// sendQueue is a bounded queue of outgoing messages.
+type sendQueue struct {
+	messages     chan queuedMessage
+	policy       OverflowPolicy
+	blockTimeout time.Duration
+
+	// stopChan is closed to stop processing the queue and doneChan is
+	// closed once processing stopped. A message that was being written
+	// when processing stopped is kept in pending.
+	stopChan chan struct{}
+	doneChan chan struct{}
+	pending  *queuedMessage
+}
+
+// WithSendQueue makes the connection buffer up to size outgoing messages,
+// which are written by a single goroutine as soon as the connection is
+// established. Send only fails if the message cannot be queued, as defined
+// by the given OverflowPolicy. blockTimeout is only used by OverflowBlock.
+func WithSendQueue(size int, policy OverflowPolicy, blockTimeout time.Duration) ConnectionOption {
+	return func(c *ManagedConnection) {
+		c.sendQueue = &sendQueue{
+			messages:     make(chan queuedMessage, size),
+			policy:       policy,
+			blockTimeout: blockTimeout,
+			stopChan:     make(chan struct{}),
+		}
+	}
+}
+
+// enqueue adds the message to the send queue, applying the overflow policy
+// if the queue is full.
+func (c *ManagedConnection) enqueue(msg queuedMessage) error {
+	q := c.sendQueue
+	defer func() {
+		c.stats.reportQueueDepth(len(q.messages))
+	}()
+
+	select {
+	case q.messages <- msg:
+		return nil
+	case <-c.closeChan:
+		return errShuttingDown
+	default:
+	}
+
+	switch q.policy {
+	case OverflowDropOldest:
+		for {
+			select {
+			case <-q.messages:
+				c.stats.reportQueueDrop()
+			default:
+			}
+			select {
+			case q.messages <- msg:
+				return nil
+			default:
+			}
+		}
+	case OverflowBlock:
+		timer := time.NewTimer(q.blockTimeout)
+		defer timer.Stop()
+		select {
+		case q.messages <- msg:
+			return nil
+		case <-timer.C:
+		case <-q.stopChan:
+			return errShuttingDown
+		case <-c.closeChan:
+			return errShuttingDown
+		}
+	}
+	c.stats.reportQueueDrop()
+	return ErrSendQueueFull
+}
+
+// processQueue writes queued messages to the connection until the
+// connection is shut down. Messages that fail to be written are retried
+// until they succeed.
+func (c *ManagedConnection) processQueue() {
+	q := c.sendQueue
+	defer close(q.doneChan)
+	for {
+		select {
+		case msg := <-q.messages:
+			c.stats.reportQueueDepth(len(q.messages))
+			for c.write(msg.messageType, msg.payload) != nil {
+				select {
+				case <-time.After(queueRetryInterval):
+				case <-q.stopChan:
+					q.pending = &msg
+					return
+				case <-c.closeChan:
+					return
+				}
+			}
+		case <-q.stopChan:
+			return
+		case <-c.closeChan:
+			return
+		}
+	}
+}
+
+// startQueue starts processing the send queue in the background.
+func (c *ManagedConnection) startQueue() {
+	c.sendQueue.doneChan = make(chan struct{})
+	c.processingWg.Add(1)
+	go func() {
+		defer c.processingWg.Done()
+		c.processQueue()
+	}()
+}
+
+// flushQueue stops the background processing of the send queue and writes
+// all remaining messages, retrying failed writes until the deadline. It
+// returns the number of messages that could not be written.
+func (c *ManagedConnection) flushQueue(deadline time.Time) int {
+	q := c.sendQueue
+	close(q.stopChan)
+	if q.doneChan != nil {
+		<-q.doneChan
+	}
+
+	var remaining []queuedMessage
+	if q.pending != nil {
+		remaining = append(remaining, *q.pending)
+	}
+	for len(q.messages) > 0 {
+		remaining = append(remaining, <-q.messages)
+	}
+	c.stats.reportQueueDepth(0)
+
+	for i, msg := range remaining {
+		for c.write(msg.messageType, msg.payload) != nil {
+			if time.Now().Add(queueRetryInterval).After(deadline) {
+				dropped := len(remaining) - i
+				for j := 0; j < dropped; j++ {
+					c.stats.reportQueueDrop()
+				}
+				return dropped
+			}
+			time.Sleep(queueRetryInterval)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,207 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+func queuedPayloads(c *ManagedConnection) []string {
+	var got []string
+	for len(c.sendQueue.messages) > 0 {
+		msg := <-c.sendQueue.messages
+		got = append(got, string(msg.payload))
+	}
+	return got
+}
+
+func TestSendQueueOverflowError(t *testing.T) {
+	conn := newConnection(nil, nil)
+	WithSendQueue(1, OverflowError, 0)(conn)
+
+	if err := conn.enqueue(queuedMessage{payload: []byte("first")}); err != nil {
+		t.Fatalf("enqueue() = %v", err)
+	}
+	if err := conn.enqueue(queuedMessage{payload: []byte("second")}); err != ErrSendQueueFull {
+		t.Errorf("enqueue() = %v, want %v", err, ErrSendQueueFull)
+	}
+	if got := queuedPayloads(conn); len(got) != 1 || got[0] != "first" {
+		t.Errorf("Queued messages = %v, want [first]", got)
+	}
+}
+
+func TestSendQueueOverflowDropOldest(t *testing.T) {
+	conn := newConnection(nil, nil)
+	conn.stats = newStatsReporter("drop-oldest")
+	WithSendQueue(2, OverflowDropOldest, 0)(conn)
+	dropsBefore := recordedCount(t, sendQueueDropsStat.Name(), "drop-oldest")
+
+	for _, m := range []string{"first", "second", "third"} {
+		if err := conn.enqueue(queuedMessage{payload: []byte(m)}); err != nil {
+			t.Fatalf("enqueue(%q) = %v", m, err)
+		}
+	}
+	if got := queuedPayloads(conn); len(got) != 2 || got[0] != "second" || got[1] != "third" {
+		t.Errorf("Queued messages = %v, want [second third]", got)
+	}
+	if drops := recordedCount(t, sendQueueDropsStat.Name(), "drop-oldest") - dropsBefore; drops != 1 {
+		t.Errorf("Recorded %d drops, want 1", drops)
+	}
+}
+
+func TestSendQueueOverflowBlock(t *testing.T) {
+	conn := newConnection(nil, nil)
+	WithSendQueue(1, OverflowBlock, 50*time.Millisecond)(conn)
+
+	if err := conn.enqueue(queuedMessage{payload: []byte("first")}); err != nil {
+		t.Fatalf("enqueue() = %v", err)
+	}
+
+	// Times out if nobody consumes the queue.
+	start := time.Now()
+	if err := conn.enqueue(queuedMessage{payload: []byte("second")}); err != ErrSendQueueFull {
+		t.Errorf("enqueue() = %v, want %v", err, ErrSendQueueFull)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("enqueue() returned after %v, wanted it to block for the timeout", elapsed)
+	}
+
+	// Succeeds if the queue is drained in the meantime.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		<-conn.sendQueue.messages
+	}()
+	if err := conn.enqueue(queuedMessage{payload: []byte("third")}); err != nil {
+		t.Errorf("enqueue() = %v", err)
+	}
+}
+
+func TestSendQueueIsProcessedOnceConnected(t *testing.T) {
+	spy := &inspectableConnection{
+		writeMessageCalls: make(chan struct{}, 2),
+	}
+	conn := newConnection(staticConnFactory(spy), nil)
+	WithSendQueue(10, OverflowError, 0)(conn)
+
+	// Messages are accepted before a connection is established.
+	if err := conn.Send("first"); err != nil {
+		t.Fatalf("Send() = %v", err)
+	}
+	if err := conn.Send("second"); err != nil {
+		t.Fatalf("Send() = %v", err)
+	}
+
+	conn.startQueue()
+	conn.connect()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-spy.writeMessageCalls:
+		case <-time.After(propagationTimeout):
+			t.Fatal("Timed out waiting for the queued messages to be written")
+		}
+	}
+
+	conn.Shutdown()
+}
+
+func TestGracefulShutdownFlushesQueue(t *testing.T) {
+	spy := &inspectableConnection{
+		writeMessageCalls: make(chan struct{}, 10),
+		writeCloseCalls:   make(chan struct{}, 1),
+		closeCalls:        make(chan struct{}, 1),
+	}
+	conn := newConnection(staticConnFactory(spy), nil)
+	WithSendQueue(10, OverflowError, 0)(conn)
+
+	// Queue messages while not being connected, so they're all pending
+	// when shutting down.
+	for i := 0; i < 3; i++ {
+		if err := conn.Send("test"); err != nil {
+			t.Fatalf("Send() = %v", err)
+		}
+	}
+	conn.connect()
+
+	dropped, err := conn.GracefulShutdown(time.Second)
+	if err != nil {
+		t.Fatalf("GracefulShutdown() = %v", err)
+	}
+	if dropped != 0 {
+		t.Errorf("GracefulShutdown() dropped %d messages, want 0", dropped)
+	}
+	if got := len(spy.writeMessageCalls); got != 3 {
+		t.Errorf("Got %d 'WriteMessage' calls, want 3", got)
+	}
+	if got := len(spy.writeCloseCalls); got != 1 {
+		t.Errorf("Got %d close frames, want 1", got)
+	}
+	if got := len(spy.closeCalls); got != 1 {
+		t.Errorf("Got %d 'Close' calls, want 1", got)
+	}
+
+	if err := conn.Send("test"); err == nil {
+		t.Error("Send() = nil, wanted an error after shutdown")
+	}
+}
+
+func TestGracefulShutdownSendsCloseFrameAfterTimeout(t *testing.T) {
+	spy := &inspectableConnection{
+		writeCloseCalls: make(chan struct{}, 1),
+		writeDeadlines:  make(chan time.Time, 10),
+	}
+	conn := newConnection(staticConnFactory(spy), nil)
+	conn.connect()
+
+	// Even if flushing used up the whole timeout, the close frame gets its
+	// own deadline.
+	before := time.Now()
+	if _, err := conn.GracefulShutdown(0); err != nil {
+		t.Fatalf("GracefulShutdown() = %v", err)
+	}
+	if got := len(spy.writeCloseCalls); got != 1 {
+		t.Fatalf("Got %d close frames, want 1", got)
+	}
+	var deadline time.Time
+	for len(spy.writeDeadlines) > 0 {
+		deadline = <-spy.writeDeadlines
+	}
+	if want := before.Add(closeFrameTimeout); deadline.Before(want) {
+		t.Errorf("Close frame deadline = %v, want at least %v", deadline, want)
+	}
+}
+
+func TestGracefulShutdownReportsDroppedMessages(t *testing.T) {
+	conn := newConnection(errConnFactory(ErrConnectionNotEstablished), nil)
+	WithSendQueue(10, OverflowError, 0)(conn)
+	conn.startQueue()
+
+	for i := 0; i < 3; i++ {
+		if err := conn.Send("test"); err != nil {
+			t.Fatalf("Send() = %v", err)
+		}
+	}
+
+	dropped, err := conn.GracefulShutdown(10 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("GracefulShutdown() = %v", err)
+	}
+	if dropped != 3 {
+		t.Errorf("GracefulShutdown() dropped %d messages, want 3", dropped)
+	}
+}
@@ -0,0 +1,271 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// defaultRPCTimeout is the default time a call waits for its response.
+const defaultRPCTimeout = 10 * time.Second
+
+var (
+	// ErrRPCTimeout is returned by RPCClient.Call if no response has been
+	// received within the timeout.
+	ErrRPCTimeout = errors.New("timed out waiting for the response")
+
+	// ErrRPCClientClosed is returned by RPCClient.Call once the client is
+	// closed.
+	ErrRPCClientClosed = errors.New("the RPC client is closed")
+)
+
+// RPCMessage is the envelope of the requests and responses exchanged by an
+// RPCClient and an RPCHandler, gob-encoded in binary messages.
+type RPCMessage struct {
+	// ID correlates a response with its request.
+	ID uint64
+	// Response is whether the message is a response rather than a request.
+	Response bool
+	// Error is the error of a failed request, if any.
+	Error string
+	// Payload is the body of the request or of the response.
+	Payload []byte
+}
+
+// RemoteError is returned by RPCClient.Call if the peer failed to serve the
+// request.
+type RemoteError struct {
+	Message string
+}
+
+// Error implements error.
+func (e *RemoteError) Error() string {
+	return "remote error: " + e.Message
+}
+
+func encodeRPCMessage(msg RPCMessage) ([]byte, error) {
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(msg); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// decodeRPCMessage decodes the message, returning false if it isn't an
+// RPCMessage.
+func decodeRPCMessage(msg Message) (RPCMessage, bool) {
+	var rpc RPCMessage
+	if msg.Type != websocket.BinaryMessage {
+		return rpc, false
+	}
+	if err := gob.NewDecoder(bytes.NewReader(msg.Payload)).Decode(&rpc); err != nil {
+		return rpc, false
+	}
+	return rpc, rpc.ID != 0
+}
+
+// RPCOptions configures an RPCClient.
+type RPCOptions struct {
+	// Timeout is the time a call waits for its response, unless its
+	// context expires first. Defaults to 10 seconds.
+	Timeout time.Duration
+
+	// Unsolicited is called with the incoming messages which aren't the
+	// response of a pending call, e.g. notifications or late responses.
+	// They're dropped if nil. It's called from the receiving goroutine
+	// and must not block.
+	Unsolicited func(Message)
+}
+
+// RPCClient sends requests over a ManagedConnection and waits for the
+// matching responses, correlated by the ID of their RPCMessage. The peer
+// answers them with an RPCHandler.
+type RPCClient struct {
+	conn *ManagedConnection
+	opts RPCOptions
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan RPCMessage
+
+	closeChan chan struct{}
+	closeOnce sync.Once
+	doneChan  chan struct{}
+}
+
+// NewRPCClient returns an RPCClient sending its requests over the connection,
+// whose incoming messages must be forwarded to the given channel, i.e.
+//
+//	messages := make(chan Message)
+//	conn := NewDurableConnection(target, nil, logger, WithTypedMessageChannel(messages))
+//	client := NewRPCClient(conn, messages, RPCOptions{})
+//
+// The client reads the channel until it's closed, which drains it as required
+// after the connection is shut down.
+func NewRPCClient(conn *ManagedConnection, incoming <-chan Message, opts RPCOptions) *RPCClient {
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultRPCTimeout
+	}
+	c := &RPCClient{
+		conn:      conn,
+		opts:      opts,
+		pending:   make(map[uint64]chan RPCMessage),
+		closeChan: make(chan struct{}),
+		doneChan:  make(chan struct{}),
+	}
+	go c.receive(incoming)
+	return c
+}
+
+// receive passes the responses to their pending calls and the other messages
+// to the unsolicited handler, until the incoming channel is closed.
+func (c *RPCClient) receive(incoming <-chan Message) {
+	defer close(c.doneChan)
+	for msg := range incoming {
+		if rpc, ok := decodeRPCMessage(msg); ok && rpc.Response {
+			c.mu.Lock()
+			ch, pending := c.pending[rpc.ID]
+			c.mu.Unlock()
+			if pending {
+				// The channel has room for the one response.
+				select {
+				case ch <- rpc:
+				default:
+				}
+				continue
+			}
+		}
+		if c.opts.Unsolicited != nil {
+			c.opts.Unsolicited(msg)
+		}
+	}
+}
+
+// Call sends the request and returns the payload of its response. It fails
+// with ErrRPCTimeout if no response has been received within the timeout,
+// with the error of the context if it's done first, or with a RemoteError if
+// the peer failed to serve the request.
+func (c *RPCClient) Call(ctx context.Context, payload []byte) ([]byte, error) {
+	ch := make(chan RPCMessage, 1)
+	c.mu.Lock()
+	select {
+	case <-c.closeChan:
+		c.mu.Unlock()
+		return nil, ErrRPCClientClosed
+	default:
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = ch
+	c.mu.Unlock()
+	// The call is forgotten however it ends, so that a late response is
+	// passed on as unsolicited.
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	request, err := encodeRPCMessage(RPCMessage{ID: id, Payload: payload})
+	if err != nil {
+		return nil, err
+	}
+	if err := c.conn.SendRaw(websocket.BinaryMessage, request); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(c.opts.Timeout)
+	defer timer.Stop()
+	select {
+	case response := <-ch:
+		if response.Error != "" {
+			return nil, &RemoteError{Message: response.Error}
+		}
+		return response.Payload, nil
+	case <-timer.C:
+		return nil, ErrRPCTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.closeChan:
+		return nil, ErrRPCClientClosed
+	}
+}
+
+// Pending returns the number of calls waiting for their response.
+func (c *RPCClient) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pending)
+}
+
+// Close fails the pending calls and the later ones with ErrRPCClientClosed.
+// It doesn't shut the connection down, and the incoming channel is still
+// read until it's closed.
+func (c *RPCClient) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closeChan)
+	})
+	return nil
+}
+
+// Done returns a channel closed once the incoming channel is closed and
+// drained.
+func (c *RPCClient) Done() <-chan struct{} {
+	return c.doneChan
+}
+
+// RPCServeFunc serves the payload of a request, returning the payload of its
+// response.
+type RPCServeFunc func(payload []byte) ([]byte, error)
+
+// RPCHandler returns a MessageHandler serving the requests of RPCClients with
+// the given function, and replying with their responses. The messages which
+// aren't requests are passed to next, if not nil. Like any MessageHandler,
+// serve is called from the read pump and must not block for long.
+func RPCHandler(serve RPCServeFunc, next MessageHandler) MessageHandler {
+	return func(c *ServerConnection, msg Message) {
+		request, ok := decodeRPCMessage(msg)
+		if !ok || request.Response {
+			if next != nil {
+				next(c, msg)
+			}
+			return
+		}
+
+		response := RPCMessage{ID: request.ID, Response: true}
+		if payload, err := serve(request.Payload); err != nil {
+			response.Error = err.Error()
+		} else {
+			response.Payload = payload
+		}
+		b, err := encodeRPCMessage(response)
+		if err == nil {
+			err = c.SendRaw(websocket.BinaryMessage, b)
+		}
+		if err != nil {
+			c.logger.Errorw("Failed to send the response of an RPC request", zap.Error(err))
+		}
+	}
+}
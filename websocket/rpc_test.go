@@ -0,0 +1,210 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	ktesting "knative.dev/pkg/logging/testing"
+)
+
+// rpcServer serves requests by upper-casing them, failing the ones saying
+// "fail" and never answering the ones saying "ignore". Text messages are
+// echoed.
+func rpcServer(t *testing.T) *httptest.Server {
+	serve := func(payload []byte) ([]byte, error) {
+		switch string(payload) {
+		case "fail":
+			return nil, errors.New("failed on purpose")
+		default:
+			return bytes.ToUpper(payload), nil
+		}
+	}
+	echo := func(c *ServerConnection, msg Message) {
+		c.SendRaw(msg.Type, msg.Payload)
+	}
+	handler := RPCHandler(serve, echo)
+	hub := NewHub(ServerOptions{}, func(c *ServerConnection, msg Message) {
+		if request, ok := decodeRPCMessage(msg); ok && string(request.Payload) == "ignore" {
+			return
+		}
+		handler(c, msg)
+	}, ktesting.TestLogger(t))
+	return httptest.NewServer(hub)
+}
+
+func newRPCClient(t *testing.T, s *httptest.Server, opts RPCOptions) (*RPCClient, func()) {
+	t.Helper()
+	messages := make(chan Message)
+	target := "ws" + strings.TrimPrefix(s.URL, "http")
+	conn := NewDurableConnection(target, nil, ktesting.TestLogger(t), WithTypedMessageChannel(messages))
+	client := NewRPCClient(conn, messages, opts)
+
+	if err := wait.PollImmediate(10*time.Millisecond, propagationTimeout, func() (bool, error) {
+		return conn.Status() == nil, nil
+	}); err != nil {
+		t.Fatalf("Timed out waiting for the connection: %v", err)
+	}
+	return client, func() {
+		client.Close()
+		conn.Shutdown()
+		close(messages)
+		<-client.Done()
+	}
+}
+
+func TestRPCCall(t *testing.T) {
+	defer ktesting.ClearAll()
+	s := rpcServer(t)
+	defer s.Close()
+	client, stop := newRPCClient(t, s, RPCOptions{})
+	defer stop()
+
+	got, err := client.Call(context.Background(), []byte("hello"))
+	if err != nil {
+		t.Fatalf("Call() = %v", err)
+	}
+	if want := "HELLO"; string(got) != want {
+		t.Errorf("Call() = %q, want: %q", got, want)
+	}
+
+	_, err = client.Call(context.Background(), []byte("fail"))
+	if remote, ok := err.(*RemoteError); !ok || remote.Message != "failed on purpose" {
+		t.Errorf("Call() = %v, want a RemoteError", err)
+	}
+	if got := client.Pending(); got != 0 {
+		t.Errorf("Pending() = %d, want: 0", got)
+	}
+}
+
+func TestRPCConcurrentCalls(t *testing.T) {
+	defer ktesting.ClearAll()
+	s := rpcServer(t)
+	defer s.Close()
+	client, stop := newRPCClient(t, s, RPCOptions{})
+	defer stop()
+
+	const calls = 20
+	var wg sync.WaitGroup
+	wg.Add(calls)
+	for i := 0; i < calls; i++ {
+		go func(i int) {
+			defer wg.Done()
+			request := fmt.Sprintf("request-%d", i)
+			got, err := client.Call(context.Background(), []byte(request))
+			if err != nil {
+				t.Errorf("Call(%q) = %v", request, err)
+			} else if want := strings.ToUpper(request); string(got) != want {
+				t.Errorf("Call(%q) = %q, want: %q", request, got, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got := client.Pending(); got != 0 {
+		t.Errorf("Pending() = %d, want: 0", got)
+	}
+}
+
+func TestRPCCallUnanswered(t *testing.T) {
+	defer ktesting.ClearAll()
+	s := rpcServer(t)
+	defer s.Close()
+	client, stop := newRPCClient(t, s, RPCOptions{Timeout: 50 * time.Millisecond})
+	defer stop()
+
+	if _, err := client.Call(context.Background(), []byte("ignore")); err != ErrRPCTimeout {
+		t.Errorf("Call() = %v, want: %v", err, ErrRPCTimeout)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.Call(ctx, []byte("ignore")); err != context.Canceled {
+		t.Errorf("Call() = %v, want: %v", err, context.Canceled)
+	}
+
+	if got := client.Pending(); got != 0 {
+		t.Errorf("Pending() = %d, want: 0", got)
+	}
+}
+
+func TestRPCClientClosed(t *testing.T) {
+	defer ktesting.ClearAll()
+	s := rpcServer(t)
+	defer s.Close()
+	client, stop := newRPCClient(t, s, RPCOptions{Timeout: time.Minute})
+	defer stop()
+
+	errs := make(chan error)
+	go func() {
+		_, err := client.Call(context.Background(), []byte("ignore"))
+		errs <- err
+	}()
+	if err := wait.PollImmediate(10*time.Millisecond, propagationTimeout, func() (bool, error) {
+		return client.Pending() == 1, nil
+	}); err != nil {
+		t.Fatalf("Timed out waiting for the call to be pending: %v", err)
+	}
+	client.Close()
+	select {
+	case err := <-errs:
+		if err != ErrRPCClientClosed {
+			t.Errorf("Call() = %v, want: %v", err, ErrRPCClientClosed)
+		}
+	case <-time.After(propagationTimeout):
+		t.Fatal("Timed out waiting for the pending call to fail")
+	}
+
+	if _, err := client.Call(context.Background(), []byte("hello")); err != ErrRPCClientClosed {
+		t.Errorf("Call() = %v, want: %v", err, ErrRPCClientClosed)
+	}
+}
+
+func TestRPCUnsolicited(t *testing.T) {
+	defer ktesting.ClearAll()
+	s := rpcServer(t)
+	defer s.Close()
+	unsolicited := make(chan Message, 1)
+	client, stop := newRPCClient(t, s, RPCOptions{
+		Unsolicited: func(msg Message) {
+			unsolicited <- msg
+		},
+	})
+	defer stop()
+
+	if err := client.conn.SendRaw(websocket.TextMessage, []byte("notification")); err != nil {
+		t.Fatalf("SendRaw() = %v", err)
+	}
+	select {
+	case msg := <-unsolicited:
+		if msg.Type != websocket.TextMessage || string(msg.Payload) != "notification" {
+			t.Errorf("Unsolicited message = %v, want the notification", msg)
+		}
+	case <-time.After(propagationTimeout):
+		t.Fatal("Timed out waiting for the unsolicited message")
+	}
+}
@@ -0,0 +1,442 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package websocket
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// ServerOptions configures the connections accepted by Accept and Hub.
+type ServerOptions struct {
+	// PingInterval is the interval at which pings are sent to the client.
+	PingInterval time.Duration
+	// PongTimeout is the time allowed between two pongs before the
+	// connection is considered broken and closed.
+	PongTimeout time.Duration
+	// WriteTimeout is the time limit of writing a single message.
+	WriteTimeout time.Duration
+
+	// SendQueueSize is the number of outgoing messages buffered per
+	// connection. Sending to a connection whose queue is full fails with
+	// ErrSendQueueFull, so a slow client doesn't hold up the others.
+	SendQueueSize int
+	// MaxMessageSize is the maximum size in bytes of an incoming message,
+	// the connection is closed if a larger message is received. Zero
+	// means no limit.
+	MaxMessageSize int64
+
+	// CheckOrigin decides whether the request's Origin header is accepted.
+	// If nil, only same-origin requests are accepted.
+	CheckOrigin func(r *http.Request) bool
+	// EnableCompression negotiates permessage-deflate compression with
+	// the client.
+	EnableCompression bool
+}
+
+// DefaultServerOptions returns the ServerOptions used for the unset fields.
+func DefaultServerOptions() ServerOptions {
+	return ServerOptions{
+		PingInterval:  pongTimeout / 3,
+		PongTimeout:   pongTimeout,
+		WriteTimeout:  defaultDrainTimeout,
+		SendQueueSize: 100,
+	}
+}
+
+// withDefaults returns the options with the unset fields defaulted, or an
+// error if any of them is negative.
+func (o ServerOptions) withDefaults() (ServerOptions, error) {
+	switch {
+	case o.PingInterval < 0:
+		return o, fmt.Errorf("the ping interval must not be negative, got %v", o.PingInterval)
+	case o.PongTimeout < 0:
+		return o, fmt.Errorf("the pong timeout must not be negative, got %v", o.PongTimeout)
+	case o.WriteTimeout < 0:
+		return o, fmt.Errorf("the write timeout must not be negative, got %v", o.WriteTimeout)
+	case o.SendQueueSize < 0:
+		return o, fmt.Errorf("the send queue size must not be negative, got %d", o.SendQueueSize)
+	case o.MaxMessageSize < 0:
+		return o, fmt.Errorf("the maximum message size must not be negative, got %d", o.MaxMessageSize)
+	}
+	d := DefaultServerOptions()
+	if o.PingInterval == 0 {
+		o.PingInterval = d.PingInterval
+	}
+	if o.PongTimeout == 0 {
+		o.PongTimeout = d.PongTimeout
+	}
+	if o.WriteTimeout == 0 {
+		o.WriteTimeout = d.WriteTimeout
+	}
+	if o.SendQueueSize == 0 {
+		o.SendQueueSize = d.SendQueueSize
+	}
+	return o, nil
+}
+
+// MessageHandler is called with every message received over a server
+// connection, from the connection's read pump. It can reply through the
+// connection, but must not block for long as reading is paused meanwhile.
+type MessageHandler func(*ServerConnection, Message)
+
+// ServerConnection is a websocket connection accepted by a server. A read
+// pump passes the incoming messages to a MessageHandler and a write pump
+// writes the outgoing messages and keeps the connection alive with pings.
+// The connection is closed if the client is gone.
+type ServerConnection struct {
+	conn   *websocket.Conn
+	logger *zap.SugaredLogger
+	opts   ServerOptions
+
+	messages  chan queuedMessage
+	closeChan chan struct{}
+	closeOnce sync.Once
+	doneChan  chan struct{}
+	pumpsWg   sync.WaitGroup
+}
+
+// Accept upgrades the request to a websocket connection and starts its read
+// and write pumps. If the upgrade fails, an HTTP error has been sent to the
+// client, as it has if the options are invalid. The handler can be nil if
+// incoming messages are ignored.
+func Accept(w http.ResponseWriter, r *http.Request, opts ServerOptions, handler MessageHandler, logger *zap.SugaredLogger) (*ServerConnection, error) {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		http.Error(w, "invalid websocket server options", http.StatusInternalServerError)
+		return nil, fmt.Errorf("invalid server options: %v", err)
+	}
+	upgrader := websocket.Upgrader{
+		CheckOrigin:       opts.CheckOrigin,
+		EnableCompression: opts.EnableCompression,
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade the connection: %v", err)
+	}
+
+	c := &ServerConnection{
+		conn:      conn,
+		logger:    logger.With(zap.String("remote", conn.RemoteAddr().String())),
+		opts:      opts,
+		messages:  make(chan queuedMessage, opts.SendQueueSize),
+		closeChan: make(chan struct{}),
+		doneChan:  make(chan struct{}),
+	}
+	if opts.MaxMessageSize > 0 {
+		conn.SetReadLimit(opts.MaxMessageSize)
+	}
+	conn.SetReadDeadline(time.Now().Add(opts.PongTimeout))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(opts.PongTimeout))
+		return nil
+	})
+
+	c.pumpsWg.Add(2)
+	go c.readPump(handler)
+	go c.writePump()
+	go func() {
+		c.pumpsWg.Wait()
+		conn.Close()
+		close(c.doneChan)
+	}()
+	return c, nil
+}
+
+// readPump passes incoming messages to the handler until the connection
+// breaks down or is closed.
+func (c *ServerConnection) readPump(handler MessageHandler) {
+	defer c.pumpsWg.Done()
+	// The write pump stops when reading fails.
+	defer c.stop()
+
+	for {
+		messageType, reader, err := c.conn.NextReader()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) && !c.isClosed() {
+				c.logger.Debugw("Connection broke down", zap.Error(err))
+			}
+			return
+		}
+		if messageType != websocket.TextMessage && messageType != websocket.BinaryMessage {
+			continue
+		}
+		payload, err := ioutil.ReadAll(reader)
+		if err != nil {
+			c.logger.Debugw("Failed to read a message", zap.Error(err))
+			return
+		}
+		if handler != nil {
+			handler(c, Message{Type: messageType, Payload: payload})
+		}
+	}
+}
+
+// writePump writes the queued messages and sends pings until the connection
+// is closed, then sends a close frame.
+func (c *ServerConnection) writePump() {
+	defer c.pumpsWg.Done()
+
+	ticker := time.NewTicker(c.opts.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg := <-c.messages:
+			if err := c.write(msg.messageType, msg.payload); err != nil {
+				c.logger.Debugw("Failed to write a message", zap.Error(err))
+				c.stop()
+				// Unblock the read pump.
+				c.conn.Close()
+				return
+			}
+		case <-ticker.C:
+			if err := c.write(websocket.PingMessage, []byte{}); err != nil {
+				c.logger.Debugw("Failed to send a ping", zap.Error(err))
+				c.stop()
+				c.conn.Close()
+				return
+			}
+		case <-c.closeChan:
+			c.flush()
+			c.conn.SetWriteDeadline(time.Now().Add(c.opts.WriteTimeout))
+			c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			// Unblock the read pump if the client doesn't answer the close frame.
+			c.conn.SetReadDeadline(time.Now().Add(c.opts.WriteTimeout))
+			return
+		}
+	}
+}
+
+// flush writes the messages still queued when the connection is closed.
+func (c *ServerConnection) flush() {
+	for {
+		select {
+		case msg := <-c.messages:
+			if err := c.write(msg.messageType, msg.payload); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (c *ServerConnection) write(messageType int, body []byte) error {
+	c.conn.SetWriteDeadline(time.Now().Add(c.opts.WriteTimeout))
+	return c.conn.WriteMessage(messageType, body)
+}
+
+// Send gob-encodes the message and queues it to be written over the
+// connection, like ManagedConnection.Send.
+func (c *ServerConnection) Send(msg interface{}) error {
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(msg); err != nil {
+		return err
+	}
+	return c.enqueue(websocket.BinaryMessage, b.Bytes())
+}
+
+// SendRaw queues a message of the given type to be written over the
+// connection without encoding it. messageType is either
+// websocket.TextMessage or websocket.BinaryMessage.
+func (c *ServerConnection) SendRaw(messageType int, msg []byte) error {
+	if messageType != websocket.TextMessage && messageType != websocket.BinaryMessage {
+		return fmt.Errorf("unsupported message type %d", messageType)
+	}
+	return c.enqueue(messageType, msg)
+}
+
+func (c *ServerConnection) enqueue(messageType int, payload []byte) error {
+	if c.isClosed() {
+		return errShuttingDown
+	}
+	select {
+	case c.messages <- queuedMessage{messageType: messageType, payload: payload}:
+		return nil
+	case <-c.closeChan:
+		return errShuttingDown
+	default:
+		return ErrSendQueueFull
+	}
+}
+
+// RemoteAddr returns the address of the client.
+func (c *ServerConnection) RemoteAddr() string {
+	return c.conn.RemoteAddr().String()
+}
+
+// Done returns a channel closed once the connection is closed, either by
+// Close or because the client is gone.
+func (c *ServerConnection) Done() <-chan struct{} {
+	return c.doneChan
+}
+
+// Close writes the queued messages, sends a close frame and closes the
+// connection. It waits for the pumps to stop.
+func (c *ServerConnection) Close() error {
+	c.stop()
+	<-c.doneChan
+	return nil
+}
+
+// stop signals the pumps to stop.
+func (c *ServerConnection) stop() {
+	c.closeOnce.Do(func() {
+		close(c.closeChan)
+	})
+}
+
+func (c *ServerConnection) isClosed() bool {
+	select {
+	case <-c.closeChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// Hub keeps track of the connections accepted by its Handler, so messages
+// can be broadcast to all of them.
+type Hub struct {
+	opts    ServerOptions
+	handler MessageHandler
+	logger  *zap.SugaredLogger
+
+	mu          sync.RWMutex
+	connections map[*ServerConnection]struct{}
+	shutdown    bool
+}
+
+// NewHub creates a Hub accepting connections with the given options and
+// passing their incoming messages to the given handler, which can be nil.
+// If the options are invalid, every connection is refused.
+func NewHub(opts ServerOptions, handler MessageHandler, logger *zap.SugaredLogger) *Hub {
+	if _, err := opts.withDefaults(); err != nil {
+		logger.Errorw("Invalid server options, the hub refuses every connection", zap.Error(err))
+	}
+	return &Hub{
+		opts:        opts,
+		handler:     handler,
+		logger:      logger,
+		connections: make(map[*ServerConnection]struct{}),
+	}
+}
+
+// ServeHTTP implements http.Handler, accepting websocket connections into
+// the hub. Connections are removed from the hub once they're closed.
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	shutdown := h.shutdown
+	h.mu.RUnlock()
+	if shutdown {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	c, err := Accept(w, r, h.opts, h.handler, h.logger)
+	if err != nil {
+		h.logger.Debugw("Failed to accept a connection", zap.Error(err))
+		return
+	}
+
+	h.mu.Lock()
+	if h.shutdown {
+		h.mu.Unlock()
+		c.Close()
+		return
+	}
+	h.connections[c] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		<-c.Done()
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.connections, c)
+	}()
+}
+
+// Len returns the number of open connections.
+func (h *Hub) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.connections)
+}
+
+// Broadcast sends the gob-encoded message to all connections. It returns
+// the aggregated errors of the connections it couldn't be queued for.
+func (h *Hub) Broadcast(msg interface{}) error {
+	return h.forAll(func(c *ServerConnection) error {
+		return c.Send(msg)
+	})
+}
+
+// BroadcastRaw sends the message of the given type to all connections
+// without encoding it. It returns the aggregated errors of the connections
+// it couldn't be queued for.
+func (h *Hub) BroadcastRaw(messageType int, msg []byte) error {
+	return h.forAll(func(c *ServerConnection) error {
+		return c.SendRaw(messageType, msg)
+	})
+}
+
+// Shutdown closes all connections and rejects new ones.
+func (h *Hub) Shutdown() error {
+	h.mu.Lock()
+	h.shutdown = true
+	conns := h.snapshotLocked()
+	h.mu.Unlock()
+
+	var errs []error
+	for _, c := range conns {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (h *Hub) snapshotLocked() []*ServerConnection {
+	conns := make([]*ServerConnection, 0, len(h.connections))
+	for c := range h.connections {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+func (h *Hub) forAll(send func(*ServerConnection) error) error {
+	h.mu.RLock()
+	conns := h.snapshotLocked()
+	h.mu.RUnlock()
+
+	var errs []error
+	for _, c := range conns {
+		if err := send(c); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", c.RemoteAddr(), err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package websocket
+
+import (
+	"bytes"
+	"encoding/gob"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	ktesting "knative.dev/pkg/logging/testing"
+)
+
+func dialHub(t *testing.T, s *httptest.Server) *websocket.Conn {
+	t.Helper()
+	target := "ws" + strings.TrimPrefix(s.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(target, nil)
+	if err != nil {
+		t.Fatalf("Dial() = %v", err)
+	}
+	return conn
+}
+
+func waitForLen(t *testing.T, hub *Hub, want int) {
+	t.Helper()
+	if err := wait.PollImmediate(10*time.Millisecond, propagationTimeout, func() (bool, error) {
+		return hub.Len() == want, nil
+	}); err != nil {
+		t.Fatalf("Len() = %d, want %d", hub.Len(), want)
+	}
+}
+
+func TestHubEcho(t *testing.T) {
+	defer ktesting.ClearAll()
+	echo := func(c *ServerConnection, msg Message) {
+		if err := c.SendRaw(msg.Type, msg.Payload); err != nil {
+			t.Errorf("SendRaw() = %v", err)
+		}
+	}
+	hub := NewHub(ServerOptions{}, echo, ktesting.TestLogger(t))
+	s := httptest.NewServer(hub)
+	defer s.Close()
+	defer hub.Shutdown()
+
+	conn := dialHub(t, s)
+	defer conn.Close()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+		t.Fatalf("WriteMessage() = %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(propagationTimeout))
+	messageType, payload, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage() = %v", err)
+	}
+	if messageType != websocket.TextMessage || string(payload) != "hello" {
+		t.Errorf("ReadMessage() = %d, %q, want %d, %q", messageType, payload, websocket.TextMessage, "hello")
+	}
+}
+
+func TestHubBroadcast(t *testing.T) {
+	defer ktesting.ClearAll()
+	hub := NewHub(ServerOptions{}, nil, ktesting.TestLogger(t))
+	s := httptest.NewServer(hub)
+	defer s.Close()
+	defer hub.Shutdown()
+
+	conns := []*websocket.Conn{dialHub(t, s), dialHub(t, s)}
+	waitForLen(t, hub, len(conns))
+
+	if err := hub.Broadcast("broadcast"); err != nil {
+		t.Fatalf("Broadcast() = %v", err)
+	}
+	for i, conn := range conns {
+		conn.SetReadDeadline(time.Now().Add(propagationTimeout))
+		_, payload, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("conns[%d].ReadMessage() = %v", i, err)
+		}
+		var got string
+		if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&got); err != nil {
+			t.Fatalf("Decode() = %v", err)
+		}
+		if got != "broadcast" {
+			t.Errorf("conns[%d] got %q, want %q", i, got, "broadcast")
+		}
+	}
+
+	// Connections closed by the clients leave the hub.
+	conns[0].Close()
+	waitForLen(t, hub, 1)
+	if err := hub.BroadcastRaw(websocket.TextMessage, []byte("raw")); err != nil {
+		t.Errorf("BroadcastRaw() = %v", err)
+	}
+	conns[1].Close()
+	waitForLen(t, hub, 0)
+}
+
+func TestHubShutdown(t *testing.T) {
+	defer ktesting.ClearAll()
+	hub := NewHub(ServerOptions{}, nil, ktesting.TestLogger(t))
+	s := httptest.NewServer(hub)
+	defer s.Close()
+
+	conn := dialHub(t, s)
+	defer conn.Close()
+	waitForLen(t, hub, 1)
+
+	if err := hub.BroadcastRaw(websocket.TextMessage, []byte("last")); err != nil {
+		t.Fatalf("BroadcastRaw() = %v", err)
+	}
+	if err := hub.Shutdown(); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+
+	// The queued message is flushed before the close frame.
+	conn.SetReadDeadline(time.Now().Add(propagationTimeout))
+	if _, payload, err := conn.ReadMessage(); err != nil || string(payload) != "last" {
+		t.Errorf("ReadMessage() = %q, %v, want %q", payload, err, "last")
+	}
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("ReadMessage() = %v, want a normal close error", err)
+	}
+
+	// New connections are rejected.
+	target := "ws" + strings.TrimPrefix(s.URL, "http")
+	if _, resp, err := websocket.DefaultDialer.Dial(target, nil); err == nil {
+		t.Error("Dial() succeeded after Shutdown")
+	} else if resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Dial() = %v, want a %d response", err, http.StatusServiceUnavailable)
+	}
+}
+
+func TestAcceptInvalidOptions(t *testing.T) {
+	for _, opts := range []ServerOptions{
+		{PingInterval: -time.Second},
+		{PongTimeout: -time.Second},
+		{WriteTimeout: -time.Second},
+		{SendQueueSize: -1},
+		{MaxMessageSize: -1},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if _, err := Accept(w, r, opts, nil, ktesting.TestLogger(t)); err == nil {
+			t.Errorf("Accept(%+v) = nil, wanted an error", opts)
+		}
+		if got, want := w.Code, http.StatusInternalServerError; got != want {
+			t.Errorf("Accept(%+v) status = %d, want %d", opts, got, want)
+		}
+	}
+}
+
+func TestServerConnectionSendQueueFull(t *testing.T) {
+	defer ktesting.ClearAll()
+	accepted := make(chan *ServerConnection, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Accept(w, r, ServerOptions{SendQueueSize: 1}, nil, ktesting.TestLogger(t))
+		if err != nil {
+			t.Errorf("Accept() = %v", err)
+			return
+		}
+		accepted <- c
+	}))
+	defer s.Close()
+
+	conn := dialHub(t, s)
+	defer conn.Close()
+	c := <-accepted
+	defer c.Close()
+
+	if err := c.SendRaw(websocket.PingMessage, nil); err == nil {
+		t.Error("SendRaw(PingMessage) = nil, want an error")
+	}
+
+	// The client doesn't read, so the queue eventually fills up.
+	payload := make([]byte, 1<<20)
+	if err := wait.PollImmediate(time.Millisecond, propagationTimeout, func() (bool, error) {
+		return c.SendRaw(websocket.BinaryMessage, payload) == ErrSendQueueFull, nil
+	}); err != nil {
+		t.Error("SendRaw() never returned ErrSendQueueFull")
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package websocket
+
+import "time"
+
+// ConnectionState describes the state of a ManagedConnection.
+type ConnectionState int
+
+const (
+	// StateConnecting means the connection is being (re)established.
+	StateConnecting ConnectionState = iota
+	// StateConnected means the connection is established.
+	StateConnected
+	// StateFailed means the connection gave up reconnecting after exhausting
+	// the attempts allowed by its ReconnectPolicy.
+	StateFailed
+	// StateClosed means the connection has been shut down.
+	StateClosed
+)
+
+// String implements fmt.Stringer.
+func (s ConnectionState) String() string {
+	switch s {
+	case StateConnecting:
+		return "Connecting"
+	case StateConnected:
+		return "Connected"
+	case StateFailed:
+		return "Failed"
+	case StateClosed:
+		return "Closed"
+	default:
+		return "Unknown"
+	}
+}
+
+// State returns the current state of the connection.
+func (c *ManagedConnection) State() ConnectionState {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	return c.state
+}
+
+// uptime returns for how long the current connection has been established,
+// or zero if there is no connection.
+func (c *ManagedConnection) uptime() time.Duration {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	if c.connectedAt.IsZero() {
+		return 0
+	}
+	return time.Since(c.connectedAt)
+}
+
+// setState transitions the connection into the given state and notifies
+// the registered handler, if any. Once closed, the state is final.
+func (c *ManagedConnection) setState(to ConnectionState) {
+	c.stateLock.Lock()
+	from := c.state
+	if from == to || from == StateClosed {
+		c.stateLock.Unlock()
+		return
+	}
+	c.state = to
+	if to == StateConnected {
+		c.connectedAt = time.Now()
+	} else {
+		c.connectedAt = time.Time{}
+	}
+	c.stateLock.Unlock()
+
+	if c.stateChangeHandler != nil {
+		c.stateChangeHandler(from, to)
+	}
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package websocket
+
+import (
+	"context"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"knative.dev/pkg/metrics"
+)
+
+var (
+	sendQueueDepthStat = stats.Int64(
+		"websocket_send_queue_depth",
+		"Number of messages waiting in the send queue of a websocket connection",
+		stats.UnitNone)
+	sendQueueDropsStat = stats.Int64(
+		"websocket_send_queue_drops",
+		"Number of messages dropped from the send queue of a websocket connection",
+		stats.UnitNone)
+
+	messagesSentStat = stats.Int64(
+		"websocket_messages_sent",
+		"Number of messages sent over a websocket connection",
+		stats.UnitNone)
+	messagesReceivedStat = stats.Int64(
+		"websocket_messages_received",
+		"Number of messages received over a websocket connection",
+		stats.UnitNone)
+	sendErrorsStat = stats.Int64(
+		"websocket_send_errors",
+		"Number of messages that failed to be sent over a websocket connection",
+		stats.UnitNone)
+	reconnectsStat = stats.Int64(
+		"websocket_reconnects",
+		"Number of times a websocket connection has been re-established",
+		stats.UnitNone)
+	uptimeStat = stats.Float64(
+		"websocket_connection_uptime",
+		"Time in seconds the current websocket connection has been established for",
+		"s")
+
+	// targetTagKey is the tag key holding the target a connection is
+	// connected to.
+	targetTagKey = tag.MustNewKey("target")
+)
+
+func init() {
+	views := []*view.View{{
+		Description: sendQueueDepthStat.Description(),
+		Measure:     sendQueueDepthStat,
+		Aggregation: view.LastValue(),
+	}, {
+		Description: sendQueueDropsStat.Description(),
+		Measure:     sendQueueDropsStat,
+		Aggregation: view.Count(),
+	}, {
+		Description: messagesSentStat.Description(),
+		Measure:     messagesSentStat,
+		Aggregation: view.Count(),
+	}, {
+		Description: messagesReceivedStat.Description(),
+		Measure:     messagesReceivedStat,
+		Aggregation: view.Count(),
+	}, {
+		Description: sendErrorsStat.Description(),
+		Measure:     sendErrorsStat,
+		Aggregation: view.Count(),
+	}, {
+		Description: reconnectsStat.Description(),
+		Measure:     reconnectsStat,
+		Aggregation: view.Count(),
+	}, {
+		Description: uptimeStat.Description(),
+		Measure:     uptimeStat,
+		Aggregation: view.LastValue(),
+	}}
+	for _, v := range views {
+		v.TagKeys = []tag.Key{targetTagKey}
+	}
+	if err := view.Register(views...); err != nil {
+		panic(err)
+	}
+}
+
+// statsReporter records the metrics of a single connection.
+type statsReporter struct {
+	ctx context.Context
+}
+
+// newStatsReporter creates a statsReporter tagging all metrics with the
+// given target.
+func newStatsReporter(target string) *statsReporter {
+	ctx, err := tag.New(context.Background(), tag.Insert(targetTagKey, target))
+	if err != nil {
+		// The target is not a valid tag value, record the metrics untagged
+		// rather than not at all.
+		ctx = context.Background()
+	}
+	return &statsReporter{ctx: ctx}
+}
+
+// The report methods are no-ops on a nil reporter.
+
+func (r *statsReporter) reportQueueDepth(depth int) {
+	r.record(sendQueueDepthStat.M(int64(depth)))
+}
+
+func (r *statsReporter) reportQueueDrop() {
+	r.record(sendQueueDropsStat.M(1))
+}
+
+func (r *statsReporter) reportSent() {
+	r.record(messagesSentStat.M(1))
+}
+
+func (r *statsReporter) reportReceived() {
+	r.record(messagesReceivedStat.M(1))
+}
+
+func (r *statsReporter) reportSendError() {
+	r.record(sendErrorsStat.M(1))
+}
+
+func (r *statsReporter) reportReconnect() {
+	r.record(reconnectsStat.M(1))
+}
+
+func (r *statsReporter) reportUptime(uptime time.Duration) {
+	r.record(uptimeStat.M(uptime.Seconds()))
+}
+
+func (r *statsReporter) record(m stats.Measurement) {
+	if r == nil {
+		return
+	}
+	metrics.Record(r.ctx, m)
+}
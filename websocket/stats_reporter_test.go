@@ -0,0 +1,114 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package websocket
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.opencensus.io/stats/view"
+)
+
+// recordedRow returns the data recorded in the given view for the given
+// target, or nil if nothing has been recorded.
+func recordedRow(t *testing.T, name, target string) view.AggregationData {
+	t.Helper()
+	rows, err := view.RetrieveData(name)
+	if err != nil {
+		t.Fatalf("RetrieveData(%s) = %v", name, err)
+	}
+	for _, row := range rows {
+		for _, tag := range row.Tags {
+			if tag.Key == targetTagKey && tag.Value == target {
+				return row.Data
+			}
+		}
+	}
+	return nil
+}
+
+// recordedCount returns the count recorded in the given view for the given target.
+func recordedCount(t *testing.T, name, target string) int64 {
+	t.Helper()
+	if data := recordedRow(t, name, target); data != nil {
+		return data.(*view.CountData).Value
+	}
+	return 0
+}
+
+func TestConnectionMetrics(t *testing.T) {
+	const target = "metrics-test"
+	before := map[string]int64{}
+	for _, name := range []string{
+		messagesSentStat.Name(),
+		messagesReceivedStat.Name(),
+		sendErrorsStat.Name(),
+		reconnectsStat.Name(),
+	} {
+		before[name] = recordedCount(t, name, target)
+	}
+
+	spy := &inspectableConnection{
+		nextReaderFunc: func() (int, io.Reader, error) {
+			return websocket.TextMessage, strings.NewReader("message"), nil
+		},
+	}
+	messageChan := make(chan []byte, 1)
+	conn := newConnection(staticConnFactory(spy), messageChan)
+	conn.stats = newStatsReporter(target)
+
+	// Fails as there is no connection yet.
+	if err := conn.Send("test"); err == nil {
+		t.Error("Send() = nil, wanted an error")
+	}
+
+	conn.connect()
+	conn.closeConnection()
+	conn.connect()
+	if err := conn.Send("test"); err != nil {
+		t.Errorf("Send() = %v", err)
+	}
+	if err := conn.read(); err != nil {
+		t.Errorf("read() = %v", err)
+	}
+	<-messageChan
+
+	want := map[string]int64{
+		messagesSentStat.Name():     1,
+		messagesReceivedStat.Name(): 1,
+		sendErrorsStat.Name():       1,
+		reconnectsStat.Name():       1,
+	}
+	for name, w := range want {
+		if got := recordedCount(t, name, target) - before[name]; got != w {
+			t.Errorf("%s = %d, want %d", name, got, w)
+		}
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	conn.stats.reportUptime(conn.uptime())
+	data := recordedRow(t, uptimeStat.Name(), target)
+	if data == nil {
+		t.Fatal("No uptime recorded")
+	}
+	if got := data.(*view.LastValueData).Value; got <= 0 {
+		t.Errorf("Uptime = %v, wanted a positive value", got)
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package websocket
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+)
+
+// tlsConfig returns the TLS configuration of the connection's dialer,
+// creating it if necessary.
+func (c *ManagedConnection) tlsConfig() *tls.Config {
+	if c.dialer.TLSClientConfig == nil {
+		c.dialer.TLSClientConfig = &tls.Config{}
+	}
+	return c.dialer.TLSClientConfig
+}
+
+// WithTLSConfig sets the TLS configuration used when dialing wss:// targets.
+// Options applied after this one modify a clone of the given configuration.
+func WithTLSConfig(cfg *tls.Config) ConnectionOption {
+	return func(c *ManagedConnection) {
+		c.dialer.TLSClientConfig = cfg.Clone()
+	}
+}
+
+// WithRootCAs sets the certificate authorities used to verify the server's
+// certificate, instead of the host's root CAs.
+func WithRootCAs(pool *x509.CertPool) ConnectionOption {
+	return func(c *ManagedConnection) {
+		c.tlsConfig().RootCAs = pool
+	}
+}
+
+// WithClientCertificate presents the given certificate to the server, for
+// endpoints that require mutual TLS.
+func WithClientCertificate(cert tls.Certificate) ConnectionOption {
+	return func(c *ManagedConnection) {
+		cfg := c.tlsConfig()
+		cfg.Certificates = append(cfg.Certificates, cert)
+	}
+}
+
+// WithServerName overrides the server name used for SNI and to verify the
+// server's certificate, for example when dialing a target by IP.
+func WithServerName(name string) ConnectionOption {
+	return func(c *ManagedConnection) {
+		c.tlsConfig().ServerName = name
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package websocket
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	ktesting "knative.dev/pkg/logging/testing"
+)
+
+// selfSignedCert generates a self-signed client certificate.
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() = %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() = %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate() = %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, cert
+}
+
+func TestDurableConnectionMutualTLS(t *testing.T) {
+	defer ktesting.ClearAll()
+
+	clientCert, clientX509 := selfSignedCert(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientX509)
+
+	upgrader := websocket.Upgrader{}
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	s.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	s.StartTLS()
+	defer s.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(s.Certificate())
+
+	logger := ktesting.TestLogger(t)
+	target := "wss" + strings.TrimPrefix(s.URL, "https")
+	conn := NewDurableSendingConnection(target, logger,
+		WithRootCAs(rootCAs),
+		WithClientCertificate(clientCert),
+		// The test server's certificate is valid for example.com.
+		WithServerName("example.com"))
+	defer conn.Shutdown()
+
+	err := wait.PollImmediate(50*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return conn.Status() == nil, nil
+	})
+	if err != nil {
+		t.Fatalf("Timed out waiting for the connection to be established: %v", err)
+	}
+}
+
+func TestTLSOptionsDoNotMutateGivenConfig(t *testing.T) {
+	cfg := &tls.Config{ServerName: "foo"}
+	conn := newConnection(nil, nil)
+	WithTLSConfig(cfg)(conn)
+	WithServerName("bar")(conn)
+
+	if cfg.ServerName != "foo" {
+		t.Errorf("ServerName = %q, want foo", cfg.ServerName)
+	}
+	if got := conn.dialer.TLSClientConfig.ServerName; got != "bar" {
+		t.Errorf("Dialer ServerName = %q, want bar", got)
+	}
+}